@@ -0,0 +1,165 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// stepDefaultKeys are the keys of the step fields that the defaults of the scenario can set
+var stepDefaultKeys = map[string]bool{
+	"headers":        true,
+	"timeout":        true,
+	"max_body_size":  true,
+	"auth":           true,
+	"success_status": true,
+	"cert_path":      true,
+	"cert_key_path":  true,
+	"others":         true,
+}
+
+// mergeStepDefaults returns the config with the defaults block merged into its steps and teardown steps, so each step
+// is parsed and validated as if it declared the defaults itself. The values of a step override the defaults, the
+// headers and the others are merged by their keys. A null value of a step removes the inherited one.
+// The config is returned as it is if it has no defaults.
+func mergeStepDefaults(data []byte) ([]byte, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	raw, ok := keys["defaults"]
+	if !ok || isNull(raw) {
+		return data, nil
+	}
+
+	var defaults map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &defaults); err != nil {
+		return nil, problemf("defaults", types.CodeInvalidType, "defaults should be an object")
+	}
+	names := make([]string, 0, len(defaults))
+	for k := range defaults {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if !stepDefaultKeys[k] {
+			return nil, problemf("defaults."+k, types.CodeUnsupported, "unsupported default: %s", k)
+		}
+	}
+
+	for list, name := range map[string]string{"steps": "step", "teardown": "teardown step"} {
+		raw, ok := keys[list]
+		if !ok || isNull(raw) {
+			continue
+		}
+		var steps []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &steps); err != nil {
+			return nil, problemf(list, types.CodeInvalidType, "%s should be a list of objects", list)
+		}
+		for i, s := range steps {
+			if s == nil {
+				s = make(map[string]json.RawMessage, len(defaults))
+			}
+			if err := mergeStep(defaults, s); err != nil {
+				var ve *types.ValidationError
+				if !errors.As(err, &ve) {
+					return nil, fmt.Errorf("%s %d: %v", name, i+1, err)
+				}
+				return nil, problemf(fmt.Sprintf("%s[%d].%s", list, i, ve.Path), ve.Code, "%s %d: %s", name, i+1,
+					ve.Message)
+			}
+			steps[i] = s
+		}
+		merged, err := json.Marshal(steps)
+		if err != nil {
+			return nil, err
+		}
+		keys[list] = merged
+	}
+	delete(keys, "defaults")
+	return json.Marshal(keys)
+}
+
+// mergeStep merges the defaults into the fields of the step.
+func mergeStep(defaults, s map[string]json.RawMessage) error {
+	for k, d := range defaults {
+		v, set := s[k]
+		switch {
+		case k == "headers" || k == "others":
+			merged, err := mergeObject(k, d, v, set, k == "headers")
+			if err != nil {
+				return err
+			}
+			s[k] = merged
+		case !set:
+			s[k] = d
+		}
+	}
+	return nil
+}
+
+// mergeObject returns the object of the defaults with the values of the step. The header names are matched
+// case-insensitively, the name of the step is kept.
+func mergeObject(key string, d, v json.RawMessage, set, header bool) (json.RawMessage, error) {
+	// Step removes all the inherited values
+	if set && isNull(v) {
+		return v, nil
+	}
+
+	var merged, own map[string]json.RawMessage
+	if err := json.Unmarshal(d, &merged); err != nil {
+		return nil, problemf(key, types.CodeInvalidType, "default %s should be an object", key)
+	}
+	if set {
+		if err := json.Unmarshal(v, &own); err != nil {
+			return nil, problemf(key, types.CodeInvalidType, "%s should be an object", key)
+		}
+	}
+	if merged == nil {
+		merged = make(map[string]json.RawMessage, len(own))
+	}
+	for name, value := range own {
+		if header {
+			for inherited := range merged {
+				if http.CanonicalHeaderKey(inherited) == http.CanonicalHeaderKey(name) {
+					delete(merged, inherited)
+				}
+			}
+		}
+		merged[name] = value
+	}
+	for name, value := range merged {
+		if isNull(value) {
+			delete(merged, name)
+		}
+	}
+	return json.Marshal(merged)
+}
+
+func isNull(raw json.RawMessage) bool {
+	return string(raw) == "null"
+}
@@ -22,7 +22,9 @@ package config
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -32,7 +34,10 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.ddosify.com/ddosify/core/proxy"
 	"go.ddosify.com/ddosify/core/types"
@@ -62,6 +67,15 @@ type multipartFormData struct {
 	Src   string `json:"src"`
 }
 
+// payloadProtobuf is the JSON of the protobuf message sent as the payload of a step. The JSON is either an object or
+// a string, the string form can have the dynamic variables out of the JSON strings like {"id": {{_randomInt}}}.
+type payloadProtobuf struct {
+	Proto           string          `json:"proto"`
+	Message         string          `json:"message"`
+	JSON            json.RawMessage `json:"json"`
+	ResponseMessage string          `json:"response_message"`
+}
+
 type step struct {
 	Id               uint16                 `json:"id"`
 	Name             string                 `json:"name"`
@@ -69,15 +83,35 @@ type step struct {
 	Protocol         string                 `json:"protocol"`
 	Auth             auth                   `json:"auth"`
 	Method           string                 `json:"method"`
-	Headers          map[string]string      `json:"headers"`
+	Headers          map[string]string      `json:"-"` // Headers with a single value
+	RepeatedHeaders  map[string][]string    `json:"-"` // Headers with a list of values
+	RawHeaders       []string               `json:"raw_headers"`
 	Payload          string                 `json:"payload"`
 	PayloadFile      string                 `json:"payload_file"`
 	PayloadMultipart []multipartFormData    `json:"payload_multipart"`
+	PayloadBase64    string                 `json:"payload_base64"`
+	PayloadProtobuf  *payloadProtobuf       `json:"payload_protobuf"`
+	Chunked          bool                   `json:"chunked"`
+	ExpectContinue   bool                   `json:"expect_continue"`
+	Templating       *bool                  `json:"templating"`
 	Timeout          int                    `json:"timeout"`
+	MaxBodySize      interface{}            `json:"max_body_size"`
 	Sleep            string                 `json:"sleep"`
+	SuccessStatus    interface{}            `json:"success_status"`
+	ParallelGroup    string                 `json:"parallel_group"`
+	Proxy            string                 `json:"proxy"`
 	Others           map[string]interface{} `json:"others"`
 	CertPath         string                 `json:"cert_path"`
 	CertKeyPath      string                 `json:"cert_key_path"`
+
+	// Requests of the step revalidate the previous response to the same URL of the virtual user
+	ConditionalRequests bool `json:"conditional_requests"`
+
+	// Share of the iterations sending the request of the step twice, either a percentage like "5%" or a ratio
+	DuplicateRate interface{} `json:"duplicate_rate"`
+
+	// Names or ids of the steps that the step runs after
+	DependsOn []interface{} `json:"depends_on"`
 }
 
 func (s *step) UnmarshalJSON(data []byte) error {
@@ -88,15 +122,85 @@ func (s *step) UnmarshalJSON(data []byte) error {
 		Timeout:  types.DefaultTimeout,
 	}
 
-	err := json.Unmarshal(data, defaultFields)
+	// A header is either a string or a list of strings sent as the repeated header lines
+	fields := struct {
+		*stepAlias
+		Headers map[string]json.RawMessage `json:"headers"`
+	}{stepAlias: defaultFields}
+	err := json.Unmarshal(data, &fields)
 	if err != nil {
 		return err
 	}
 
+	for k, raw := range fields.Headers {
+		var value string
+		if json.Unmarshal(raw, &value) == nil {
+			if defaultFields.Headers == nil {
+				defaultFields.Headers = make(map[string]string)
+			}
+			defaultFields.Headers[k] = value
+			continue
+		}
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil || len(values) == 0 {
+			return problemf("", types.CodeInvalidType, "header %s should be a string or a non-empty list of strings", k)
+		}
+		if defaultFields.RepeatedHeaders == nil {
+			defaultFields.RepeatedHeaders = make(map[string][]string)
+		}
+		defaultFields.RepeatedHeaders[k] = values
+	}
+
 	*s = step(*defaultFields)
 	return nil
 }
 
+type dynamicRate struct {
+	URL        string  `json:"url"`
+	Prometheus string  `json:"prometheus"`
+	Query      string  `json:"query"`
+	Expression string  `json:"expression"`
+	Interval   float64 `json:"interval"` // In seconds
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+}
+
+type loadSchedule struct {
+	Timezone string `json:"timezone"`
+	Points   []struct {
+		Time string  `json:"time"`
+		Rate float64 `json:"rate"`
+	} `json:"points"`
+}
+
+type loadBurst struct {
+	Size        int     `json:"size"`
+	Interval    float64 `json:"interval"` // In seconds
+	Count       int     `json:"count"`
+	MaxInFlight int     `json:"max_in_flight"`
+	Policy      string  `json:"policy"`
+}
+
+type virtualUsers struct {
+	Users             int `json:"users"`
+	IterationsPerUser int `json:"iterations_per_user"`
+}
+
+type percentiles struct {
+	Sketch            string `json:"sketch"`
+	SignificantDigits int    `json:"significant_digits"`
+	Compression       int    `json:"compression"`
+	ExactLimit        int    `json:"exact_limit"`
+}
+
+type cost struct {
+	PerRequest   float64     `json:"per_request"`
+	PerGB        float64     `json:"per_gb"`
+	ResponseSize interface{} `json:"response_size"`
+	Budget       float64     `json:"budget"`
+	Currency     string      `json:"currency"`
+}
+
 type JsonReader struct {
 	ReqCount     *int         `json:"request_count"`
 	IterCount    *int         `json:"iteration_count"`
@@ -107,6 +211,75 @@ type JsonReader struct {
 	Output       string       `json:"output"`
 	Proxy        string       `json:"proxy"`
 	Debug        bool         `json:"debug"`
+	Seed         int64        `json:"seed"`
+
+	ConnectionScope string `json:"connection_scope"`
+
+	// Steps run once after the test over the collected values, and the upper bound of the values of each collected
+	// capture
+	Teardown     []step `json:"teardown"`
+	CollectLimit int    `json:"collect_limit"`
+
+	// Share of the iterations running the b variants of the steps with ab-variants, like "30%" or 0.3
+	ABSplit interface{} `json:"ab_split"`
+
+	CaptureRate     interface{} `json:"capture_rate"`
+	CaptureCount    int         `json:"capture_count"`
+	CaptureFailures *int        `json:"capture_failures"`
+	CaptureFile     string      `json:"capture_file"`
+
+	SuccessCriteria   string `json:"success_criteria"`
+	StopAfterFailures int    `json:"stop_after_failures"`
+	SkipPreflight     bool   `json:"skip_preflight"`
+	NoConfigEcho      bool   `json:"no_config_echo"`
+	Anonymize         bool   `json:"anonymize"`
+
+	// Either a byte size string like "50GB" or a byte count
+	MaxTransfer interface{} `json:"max_transfer"`
+
+	// Like "steps.2.p95 > 800ms over 1m for 3 windows"
+	AbortRules []string `json:"abort_rules"`
+
+	// In seconds
+	ShutdownTimeout int `json:"shutdown_timeout"`
+
+	ResultBufferSize   int     `json:"result_buffer_size"`
+	ResultBlockWarning float64 `json:"result_block_warning"` // In seconds
+
+	// Like "{{rate .RPS}}, p99 {{duration (.Step 2).P99}}"
+	LiveTemplate string `json:"live_template"`
+
+	// Labels of the run, either a value or an object like {"value": "...", "secret": true}
+	Metadata map[string]interface{} `json:"metadata"`
+
+	// Network option of the steps that don't have their own
+	Network interface{} `json:"network"`
+
+	// TLS session cache option of the steps that don't have their own, the cache is enabled if it is not set
+	TLSSessionCache interface{} `json:"tls_session_cache"`
+
+	// Response header names grouping the results of the steps that don't have their own, and the limit of
+	// their distinct values
+	ReportDimensions     interface{} `json:"report_dimensions"`
+	ReportDimensionLimit interface{} `json:"report_dimension_limit"`
+
+	// Limit of the distinct hosts reported by the steps that don't have their own
+	ReportHostLimit interface{} `json:"report_host_limit"`
+
+	// Leak scan option of the steps that don't have their own
+	LeakScan interface{} `json:"leak_scan"`
+
+	DynamicRate *dynamicRate `json:"dynamic_rate"`
+
+	Schedule *loadSchedule `json:"schedule"`
+
+	Burst *loadBurst `json:"burst"`
+
+	VirtualUsers *virtualUsers `json:"vu"`
+
+	Percentiles *percentiles `json:"percentiles"`
+
+	Cost *cost `json:"cost"`
 }
 
 func (j *JsonReader) UnmarshalJSON(data []byte) error {
@@ -117,7 +290,11 @@ func (j *JsonReader) UnmarshalJSON(data []byte) error {
 		Output:   types.DefaultOutputType,
 	}
 
-	err := json.Unmarshal(data, defaultFields)
+	data, err := mergeStepDefaults(data)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, defaultFields)
 	if err != nil {
 		return err
 	}
@@ -128,37 +305,106 @@ func (j *JsonReader) UnmarshalJSON(data []byte) error {
 
 func (j *JsonReader) Init(jsonByte []byte) (err error) {
 	if !json.Valid(jsonByte) {
-		err = fmt.Errorf("provided json is invalid")
+		err = &types.ValidationError{
+			Code:    types.CodeInvalidSyntax,
+			Message: "provided json is invalid",
+			Fix:     syntaxFix(jsonByte),
+		}
 		return
 	}
 
 	err = json.Unmarshal(jsonByte, &j)
-	if err != nil {
-		return
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		err = &types.ValidationError{
+			Path:    typeErr.Field,
+			Code:    types.CodeInvalidType,
+			Message: err.Error(),
+			Fix:     fmt.Sprintf("set a value of type %s", typeErr.Type),
+		}
 	}
 	return
 }
 
+// syntaxFix returns the fix of the invalid json, pointing to the line and the column of the syntax error.
+func syntaxFix(jsonByte []byte) string {
+	var v interface{}
+	var syntaxErr *json.SyntaxError
+	if err := json.Unmarshal(jsonByte, &v); !errors.As(err, &syntaxErr) {
+		return ""
+	}
+	before := jsonByte[:syntaxErr.Offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("fix the json near line %d, column %d: %v", line, column, syntaxErr)
+}
+
+// problemf returns a problem of the config at the path with the code, whose message is formatted like fmt.Sprintf.
+func problemf(path, code, format string, args ...interface{}) *types.ValidationError {
+	return &types.ValidationError{Path: path, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// CreateHammer returns the hammer of the config. The problems of every section of the config are returned
+// together as types.ValidationErrors.
 func (j *JsonReader) CreateHammer() (h types.Hammer, err error) {
+	var errs types.ValidationErrors
+
 	// Scenario
-	s := types.Scenario{}
+	s := types.Scenario{Seed: j.Seed, ConnectionScope: j.ConnectionScope, CollectLimit: j.CollectLimit}
+	s.Capture, err = j.capture()
+	errs.Add("capture_rate", err)
+	if j.ABSplit != nil {
+		s.ABSplit, err = types.ParseABSplit(j.ABSplit)
+		errs.Add("ab_split", err)
+	}
 	var si types.ScenarioStep
-	for _, step := range j.Steps {
+	for i, step := range j.Steps {
 		si, err = stepToScenarioStep(step)
 		if err != nil {
-			return
+			errs.Add(fmt.Sprintf("steps[%d]", i), err)
+			continue
+		}
+		// Top-level options apply to the steps that don't have their own
+		for _, o := range []struct {
+			key string
+			val interface{}
+		}{
+			{"network", j.Network},
+			{"tls-session-cache", j.TLSSessionCache},
+			{"report-dimensions", j.ReportDimensions},
+			{"report-dimension-limit", j.ReportDimensionLimit},
+			{"report-host-limit", j.ReportHostLimit},
+			{"leak-scan", j.LeakScan},
+		} {
+			if _, ok := si.Custom[o.key]; !ok && o.val != nil {
+				if si.Custom == nil {
+					si.Custom = make(map[string]interface{})
+				}
+				si.Custom[o.key] = o.val
+			}
 		}
 
 		s.Steps = append(s.Steps, si)
 	}
+	if errs == nil {
+		// Dependencies are only resolved between the steps without problems
+		s.Steps, err = types.OrderSteps(s.Steps)
+		errs.Add("steps", err)
+	}
+	for i, step := range j.Teardown {
+		si, err = stepToScenarioStep(step)
+		if err != nil {
+			errs.Add(fmt.Sprintf("teardown[%d]", i), err)
+			continue
+		}
+		s.Teardown = append(s.Teardown, si)
+	}
 
 	// Proxy
 	var proxyURL *url.URL
 	if j.Proxy != "" {
 		proxyURL, err = url.Parse(j.Proxy)
-		if err != nil {
-			return
-		}
+		errs.Add("proxy", err)
 	}
 	p := proxy.Proxy{
 		Strategy: proxy.ProxyTypeSingle,
@@ -187,14 +433,189 @@ func (j *JsonReader) CreateHammer() (h types.Hammer, err error) {
 
 	// Hammer
 	h = types.Hammer{
-		IterationCount:    *j.IterCount,
-		LoadType:          strings.ToLower(j.LoadType),
-		TestDuration:      j.Duration,
-		TimeRunCountMap:   types.TimeRunCount(j.TimeRunCount),
-		Scenario:          s,
-		Proxy:             p,
-		ReportDestination: j.Output,
-		Debug:             j.Debug,
+		IterationCount:     *j.IterCount,
+		LoadType:           strings.ToLower(j.LoadType),
+		TestDuration:       j.Duration,
+		TimeRunCountMap:    types.TimeRunCount(j.TimeRunCount),
+		Scenario:           s,
+		Proxy:              p,
+		ReportDestination:  j.Output,
+		Debug:              j.Debug,
+		SuccessCriteria:    j.SuccessCriteria,
+		StopAfterFailures:  j.StopAfterFailures,
+		AbortRules:         j.AbortRules,
+		SkipPreflight:      j.SkipPreflight,
+		NoConfigEcho:       j.NoConfigEcho,
+		Anonymize:          j.Anonymize,
+		ShutdownTimeout:    time.Duration(j.ShutdownTimeout) * time.Second,
+		ResultBufferSize:   j.ResultBufferSize,
+		ResultBlockWarning: time.Duration(j.ResultBlockWarning * float64(time.Second)),
+		LiveTemplate:       j.LiveTemplate,
+	}
+	h.Metadata, err = j.metadata()
+	errs.Add("metadata", err)
+	if d := j.DynamicRate; d != nil {
+		h.DynamicRate = &types.DynamicRate{
+			URL:        d.URL,
+			Prometheus: d.Prometheus,
+			Query:      d.Query,
+			Expression: d.Expression,
+			Interval:   time.Duration(d.Interval * float64(time.Second)),
+			Min:        d.Min,
+			Max:        d.Max,
+		}
+	}
+	h.Schedule, err = j.schedule()
+	errs.Add("schedule", err)
+	if b := j.Burst; b != nil {
+		h.Burst = &types.LoadBurst{
+			Size:        b.Size,
+			Interval:    time.Duration(b.Interval * float64(time.Second)),
+			Count:       b.Count,
+			MaxInFlight: b.MaxInFlight,
+			Policy:      strings.ToLower(b.Policy),
+		}
+		if b.Count > 0 {
+			// Duration of the test is set by the burst count
+			h.TestDuration = h.Burst.TestDuration()
+		}
+	}
+	if v := j.VirtualUsers; v != nil {
+		h.VirtualUsers = &types.VirtualUsers{Users: v.Users, IterationsPerUser: v.IterationsPerUser}
+		// Iteration count of the test is set by the users
+		h.IterationCount = h.VirtualUsers.IterationCount()
+	}
+	if pc := j.Percentiles; pc != nil {
+		h.Percentiles = types.PercentileSketch{
+			Kind:              strings.ToLower(pc.Sketch),
+			SignificantDigits: pc.SignificantDigits,
+			Compression:       pc.Compression,
+			ExactLimit:        pc.ExactLimit,
+		}
+	}
+	if c := j.Cost; c != nil {
+		h.Cost = &types.Cost{PerRequest: c.PerRequest, PerGB: c.PerGB, Budget: c.Budget, Currency: c.Currency}
+		h.Cost.ResponseSize, err = byteSize("cost response_size", c.ResponseSize)
+		errs.Add("cost.response_size", err)
+	}
+	h.MaxTransfer, err = j.maxTransfer()
+	errs.Add("max_transfer", err)
+	err = errs.Err()
+	return
+}
+
+// schedule returns the load schedule with the parsed times of day and time zone, nil if it is not set.
+func (j *JsonReader) schedule() (*types.LoadSchedule, error) {
+	if j.Schedule == nil {
+		return nil, nil
+	}
+	s := &types.LoadSchedule{}
+	if j.Schedule.Timezone != "" {
+		loc, err := time.LoadLocation(j.Schedule.Timezone)
+		if err != nil {
+			return nil, problemf("timezone", types.CodeInvalidValue,
+				"invalid schedule timezone: %s", j.Schedule.Timezone)
+		}
+		s.Location = loc
+	}
+	for _, p := range j.Schedule.Points {
+		d, err := types.ParseTimeOfDay(p.Time)
+		if err != nil {
+			return nil, err
+		}
+		s.Points = append(s.Points, types.SchedulePoint{TimeOfDay: d, Rate: p.Rate})
+	}
+	return s, nil
+}
+
+// metadata returns the labels of the metadata block sorted by their keys.
+func (j *JsonReader) metadata() (m types.Metadata, err error) {
+	keys := make([]string, 0, len(j.Metadata))
+	for k := range j.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		l := types.Label{Key: k}
+		switch v := j.Metadata[k].(type) {
+		case string:
+			l.Value = v
+		case float64:
+			l.Value = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			l.Value = strconv.FormatBool(v)
+		case map[string]interface{}:
+			value, ok := v["value"].(string)
+			if !ok {
+				return m, problemf(k+".value", types.CodeInvalidType,
+					"value of the metadata label %s should be a string", k)
+			}
+			l.Value = value
+			if secret, ok := v["secret"]; ok {
+				if l.Secret, ok = secret.(bool); !ok {
+					return m, problemf(k+".secret", types.CodeInvalidType,
+						"secret of the metadata label %s should be a boolean", k)
+				}
+			}
+		default:
+			return m, problemf(k, types.CodeInvalidType, "invalid metadata label %s: %v", k, v)
+		}
+		m.Labels = append(m.Labels, l)
+	}
+	return
+}
+
+// maxTransfer returns the transfer limit of the test, max_transfer is either a byte size string like "50GB"
+// or a byte count.
+func (j *JsonReader) maxTransfer() (int64, error) {
+	return byteSize("max_transfer", j.MaxTransfer)
+}
+
+// byteSize parses the option of the given name, either a byte size string like "10MB" or a byte count. 0 if it is
+// not set.
+func byteSize(name string, val interface{}) (int64, error) {
+	switch v := val.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if v < 0 {
+			return 0, problemf("", types.CodeOutOfRange, "invalid %s: %v", name, v)
+		}
+		return int64(v), nil
+	case string:
+		return types.ParseByteSize(v)
+	default:
+		return 0, problemf("", types.CodeInvalidType, "invalid %s: %v", name, v)
+	}
+}
+
+// capture returns the request capture config, capture_rate is either a percentage string like "0.1%" or a ratio.
+func (j *JsonReader) capture() (c types.Capture, err error) {
+	switch rate := j.CaptureRate.(type) {
+	case nil:
+	case float64:
+		c.Rate = rate
+	case string:
+		if c.Rate, err = types.ParseCaptureRate(rate); err != nil {
+			return
+		}
+	default:
+		err = problemf("", types.CodeInvalidType, "invalid capture_rate: %v", rate)
+		return
+	}
+
+	c.Count = j.CaptureCount
+	if j.CaptureFailures != nil {
+		c.Failures = *j.CaptureFailures
+	} else if c.Enabled() {
+		c.Failures = types.DefaultCaptureFailures
+	}
+	if c.Enabled() {
+		c.File = types.DefaultCaptureFile
+		if j.CaptureFile != "" {
+			c.File = j.CaptureFile
+		}
 	}
 	return
 }
@@ -202,6 +623,32 @@ func (j *JsonReader) CreateHammer() (h types.Hammer, err error) {
 func stepToScenarioStep(s step) (types.ScenarioStep, error) {
 	var payload string
 	var err error
+	// Templating is on unless it is disabled, a base64 payload is binary so it is never templated
+	raw := s.Templating != nil && !*s.Templating
+	if s.PayloadBase64 != "" {
+		if s.Payload != "" || s.PayloadFile != "" || len(s.PayloadMultipart) > 0 {
+			return types.ScenarioStep{}, problemf("payload_base64", types.CodeConflict,
+				"payload_base64 can not be combined with payload, payload_file or payload_multipart")
+		}
+		if s.Templating != nil && *s.Templating {
+			return types.ScenarioStep{}, problemf("templating", types.CodeConflict,
+				"templating can not be applied to payload_base64")
+		}
+		raw = true
+	}
+
+	if s.Chunked && s.PayloadFile == "" {
+		return types.ScenarioStep{}, problemf("chunked", types.CodeRequired,
+			"chunked can only be used with payload_file")
+	}
+
+	var protobuf *types.ProtobufPayload
+	if s.PayloadProtobuf != nil {
+		if protobuf, err = s.protobufPayload(); err != nil {
+			return types.ScenarioStep{}, err
+		}
+	}
+
 	if len(s.PayloadMultipart) > 0 {
 		if s.Headers == nil {
 			s.Headers = make(map[string]string)
@@ -218,6 +665,16 @@ func stepToScenarioStep(s step) (types.ScenarioStep, error) {
 		}
 
 		payload = string(buf)
+	} else if s.PayloadBase64 != "" {
+		buf, err := base64.StdEncoding.DecodeString(s.PayloadBase64)
+		if err != nil {
+			return types.ScenarioStep{}, problemf("payload_base64", types.CodeInvalidValue,
+				"invalid payload_base64: %v", err)
+		}
+
+		payload = string(buf)
+	} else if protobuf != nil && protobuf.Message != "" {
+		payload = protobufJSON(s.PayloadProtobuf.JSON)
 	} else {
 		payload = s.Payload
 	}
@@ -229,25 +686,59 @@ func stepToScenarioStep(s step) (types.ScenarioStep, error) {
 
 	// TODO:V1 - Remove protocol flag at v1
 	// Protocol & URL
-	s.Url, s.Protocol, err = types.AdjustUrlProtocol(s.Url, s.Protocol)
-	if err != nil {
-		return types.ScenarioStep{}, err
+	// Steps fed by a targets file may omit the url
+	if _, fed := s.Others["targets-file"]; !fed || s.Url != "" {
+		s.Url, s.Protocol, err = types.AdjustUrlProtocol(s.Url, s.Protocol)
+		if err != nil {
+			return types.ScenarioStep{}, err
+		}
 	}
 
 	s.Protocol = strings.ToUpper(s.Protocol)
 
 	item := types.ScenarioStep{
-		ID:       s.Id,
-		Name:     s.Name,
-		URL:      s.Url,
-		Protocol: s.Protocol,
-		Auth:     types.Auth(s.Auth),
-		Method:   strings.ToUpper(s.Method),
-		Headers:  s.Headers,
-		Payload:  payload,
-		Timeout:  s.Timeout,
-		Sleep:    strings.ReplaceAll(s.Sleep, " ", ""),
-		Custom:   s.Others,
+		ID:              s.Id,
+		Name:            s.Name,
+		URL:             s.Url,
+		Protocol:        s.Protocol,
+		Auth:            types.Auth(s.Auth),
+		Method:          strings.ToUpper(s.Method),
+		Headers:         s.Headers,
+		Payload:         payload,
+		RepeatedHeaders: s.RepeatedHeaders,
+		RawHeaders:      s.RawHeaders,
+		RawPayload:      raw,
+		ExpectContinue:  s.ExpectContinue,
+		Chunked:         s.Chunked,
+		Timeout:         s.Timeout,
+		Sleep:           strings.ReplaceAll(s.Sleep, " ", ""),
+		ParallelGroup:   s.ParallelGroup,
+		Proxy:           strings.TrimSpace(s.Proxy),
+		Protobuf:        protobuf,
+		Custom:          s.Others,
+	}
+	item.ConditionalRequests = s.ConditionalRequests
+	if s.DuplicateRate != nil {
+		if item.DuplicateRate, err = types.ParseDuplicateRate(s.DuplicateRate); err != nil {
+			return item, err
+		}
+	}
+	for _, d := range s.DependsOn {
+		switch v := d.(type) {
+		case string:
+			item.DependsOn = append(item.DependsOn, v)
+		case float64:
+			item.DependsOn = append(item.DependsOn, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return item, problemf("depends_on", types.CodeInvalidType,
+				"depends_on of step %d should be a list of step names or ids", s.Id)
+		}
+	}
+	if item.SuccessStatus, err = successStatusSpec(s.SuccessStatus); err != nil {
+		return item, err
+	}
+	if item.MaxBodySize, err = byteSize("max_body_size", s.MaxBodySize); err != nil {
+		return item, err
 	}
 
 	if s.CertPath != "" && s.CertKeyPath != "" {
@@ -263,6 +754,52 @@ func stepToScenarioStep(s step) (types.ScenarioStep, error) {
 	return item, nil
 }
 
+// protobufPayload returns the protobuf schema of the step. A step with a request message sends its JSON as the
+// payload, so it can't have the other payloads. The content type of its requests is set unless it is in the headers.
+func (s *step) protobufPayload() (*types.ProtobufPayload, error) {
+	p := s.PayloadProtobuf
+	if p.Message == "" {
+		if len(p.JSON) > 0 {
+			return nil, problemf("payload_protobuf.json", types.CodeRequired,
+				"payload_protobuf json can only be used with a message")
+		}
+		return &types.ProtobufPayload{File: p.Proto, ResponseMessage: p.ResponseMessage}, nil
+	}
+	if s.Payload != "" || s.PayloadFile != "" || len(s.PayloadMultipart) > 0 || s.PayloadBase64 != "" {
+		return nil, problemf("payload_protobuf", types.CodeConflict,
+			"payload_protobuf with a message can not be combined with payload, payload_file, payload_multipart or "+
+				"payload_base64")
+	}
+
+	if s.Headers == nil {
+		s.Headers = make(map[string]string)
+	}
+	hasContentType := false
+	for k := range s.Headers {
+		hasContentType = hasContentType || strings.EqualFold(k, "Content-Type")
+	}
+	for k := range s.RepeatedHeaders {
+		hasContentType = hasContentType || strings.EqualFold(k, "Content-Type")
+	}
+	if !hasContentType {
+		s.Headers["Content-Type"] = types.ProtobufContentType
+	}
+	return &types.ProtobufPayload{File: p.Proto, Message: p.Message, ResponseMessage: p.ResponseMessage}, nil
+}
+
+// protobufJSON returns the text of the JSON of a protobuf payload, a JSON string is unquoted. The empty JSON is the
+// empty message.
+func protobufJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return "{}"
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	return string(raw)
+}
+
 func prepareMultipartPayload(parts []multipartFormData) (body string, contentType string, err error) {
 	byteBody := &bytes.Buffer{}
 	writer := multipart.NewWriter(byteBody)
@@ -318,3 +855,29 @@ func prepareMultipartPayload(parts []multipartFormData) (body string, contentTyp
 	writer.Close()
 	return byteBody.String(), writer.FormDataContentType(), err
 }
+
+// successStatusSpec returns the success_status of a step given as a string like "200-299,404" or as a list of
+// codes and ranges like [200, "300-399"], in the string form.
+func successStatusSpec(v interface{}) (string, error) {
+	switch e := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return e, nil
+	case float64:
+		return strconv.FormatFloat(e, 'f', -1, 64), nil
+	case []interface{}:
+		parts := make([]string, 0, len(e))
+		for _, p := range e {
+			part, err := successStatusSpec(p)
+			if _, isList := p.([]interface{}); err != nil || isList {
+				return "", problemf("success_status", types.CodeInvalidType,
+					"success_status should be a list of status codes and ranges: %v", v)
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", problemf("success_status", types.CodeInvalidType,
+		"success_status should be a list of status codes and ranges: %v", v)
+}
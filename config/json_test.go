@@ -30,6 +30,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"go.ddosify.com/ddosify/core/proxy"
 	"go.ddosify.com/ddosify/core/report"
@@ -329,6 +330,247 @@ func TestCreateHammerPayload(t *testing.T) {
 	}
 }
 
+func TestCreateHammerPayloadBase64(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_payload_base64.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerPayloadBase64 error occurred: %v", err)
+	}
+
+	steps := h.Scenario.Steps
+	if expected := "\x0a\x03foo\x10\xff\x01"; steps[0].Payload != expected || !steps[0].RawPayload {
+		t.Errorf("Expected: %q, Found: %q %v", expected, steps[0].Payload, steps[0].RawPayload)
+	}
+	if !steps[1].RawPayload {
+		t.Errorf("Templating should be disabled")
+	}
+	if steps[2].RawPayload {
+		t.Errorf("Templating should be enabled")
+	}
+}
+
+func TestCreateHammerInvalidPayloadBase64(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		step string
+	}{
+		{"InvalidBase64", `"payload_base64": "not base64"`},
+		{"WithPayload", `"payload_base64": "CgNmb28=", "payload": "foo"`},
+		{"WithPayloadFile", `"payload_base64": "CgNmb28=", "payload_file": "config_testdata/payload.txt"`},
+		{"WithMultipart", `"payload_base64": "CgNmb28=", "payload_multipart": [{"name": "foo", "value": "bar"}]`},
+		{"WithTemplating", `"payload_base64": "CgNmb28=", "templating": true`},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := fmt.Sprintf(`{"steps": [{"id": 1, "url": "https://test.com", %s}]}`, test.step)
+			jsonReader, _ := NewConfigReader([]byte(config), ConfigTypeJson)
+			if _, err := jsonReader.CreateHammer(); err == nil {
+				t.Errorf("Should be errored")
+			}
+		})
+	}
+}
+
+func TestCreateHammerLargeUpload(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_large_upload.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerLargeUpload error occurred: %v", err)
+	}
+
+	steps := h.Scenario.Steps
+	if !steps[0].ExpectContinue || !steps[0].Chunked {
+		t.Errorf("Expect continue and chunked should be set, Found %v %v", steps[0].ExpectContinue, steps[0].Chunked)
+	}
+	if steps[1].ExpectContinue || steps[1].Chunked {
+		t.Errorf("Expect continue and chunked should be off by default, Found %v %v", steps[1].ExpectContinue,
+			steps[1].Chunked)
+	}
+
+	jsonReader, _ = NewConfigReader([]byte(`{"steps": [{"id": 1, "url": "https://test.com", "payload": "foo", `+
+		`"chunked": true}]}`), ConfigTypeJson)
+	if _, err := jsonReader.CreateHammer(); err == nil {
+		t.Errorf("Chunked without payload_file should be errored")
+	}
+}
+
+func TestCreateHammerConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	jsonReader, _ := NewConfigReader([]byte(`{"steps": [{"id": 1, "url": "https://test.com", `+
+		`"conditional_requests": true}, {"id": 2, "url": "https://test.com"}]}`), ConfigTypeJson)
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerConditionalRequests error occurred: %v", err)
+	}
+	if steps := h.Scenario.Steps; !steps[0].ConditionalRequests || steps[1].ConditionalRequests {
+		t.Errorf("Expected conditional requests only on step 1, Found %v %v", steps[0].ConditionalRequests,
+			steps[1].ConditionalRequests)
+	}
+}
+
+func TestCreateHammerDuplicateRate(t *testing.T) {
+	t.Parallel()
+
+	jsonReader, _ := NewConfigReader([]byte(`{"steps": [{"id": 1, "url": "https://test.com", `+
+		`"duplicate_rate": "10%"}, {"id": 2, "url": "https://test.com", "duplicate_rate": 0.5}]}`), ConfigTypeJson)
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerDuplicateRate error occurred: %v", err)
+	}
+	if steps := h.Scenario.Steps; steps[0].DuplicateRate != 0.1 || steps[1].DuplicateRate != 0.5 {
+		t.Errorf("Expected duplicate rates 0.1 and 0.5, Found %v %v", steps[0].DuplicateRate, steps[1].DuplicateRate)
+	}
+
+	jsonReader, _ = NewConfigReader([]byte(`{"steps": [{"id": 1, "url": "https://test.com", `+
+		`"duplicate_rate": "150%"}]}`), ConfigTypeJson)
+	if _, err := jsonReader.CreateHammer(); err == nil {
+		t.Errorf("Duplicate rate over 100%% should be errored")
+	}
+}
+
+func TestCreateHammerHeaders(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_headers.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerHeaders error occurred: %v", err)
+	}
+
+	step := h.Scenario.Steps[0]
+	headers := map[string]string{"Accept": "application/json", "x-API-key": "abc"}
+	if !reflect.DeepEqual(step.Headers, headers) {
+		t.Errorf("Headers Expected %v, Found %v", headers, step.Headers)
+	}
+	repeated := map[string][]string{"Forwarded": {"for=192.0.2.60", "for=198.51.100.17;proto=https"}}
+	if !reflect.DeepEqual(step.RepeatedHeaders, repeated) {
+		t.Errorf("RepeatedHeaders Expected %v, Found %v", repeated, step.RepeatedHeaders)
+	}
+	if !reflect.DeepEqual(step.RawHeaders, []string{"X-Api-Key"}) {
+		t.Errorf("RawHeaders Expected [X-Api-Key], Found %v", step.RawHeaders)
+	}
+}
+
+func TestCreateHammerInvalidHeaders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		headers string
+	}{
+		{"Number", `{"X-Id": 1}`},
+		{"EmptyList", `{"Forwarded": []}`},
+		{"NumberList", `{"Forwarded": ["for=a", 2]}`},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := fmt.Sprintf(`{"steps": [{"id": 1, "url": "https://test.com", "headers": %s}]}`, test.headers)
+			jsonReader, err := NewConfigReader([]byte(config), ConfigTypeJson)
+			if err == nil {
+				_, err = jsonReader.CreateHammer()
+			}
+			if err == nil {
+				t.Errorf("Should be errored")
+			}
+		})
+	}
+}
+
+func TestCreateHammerPayloadProtobuf(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_payload_protobuf.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerPayloadProtobuf error occurred: %v", err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Fatalf("TestCreateHammerPayloadProtobuf validation error occurred: %v", err)
+	}
+
+	steps := h.Scenario.Steps
+	expected := &types.ProtobufPayload{File: "config_testdata/user.proto", Message: "users.v1.CreateUser",
+		ResponseMessage: "users.v1.User"}
+	if !reflect.DeepEqual(steps[0].Protobuf, expected) {
+		t.Errorf("Expected: %v, Found: %v", expected, steps[0].Protobuf)
+	}
+	if steps[0].Payload != `{"id": "7", "name": "ada"}` {
+		t.Errorf("Expected the JSON object as the payload, Found: %q", steps[0].Payload)
+	}
+	if ct := steps[0].Headers["Content-Type"]; ct != types.ProtobufContentType {
+		t.Errorf("Expected: %s, Found: %s", types.ProtobufContentType, ct)
+	}
+
+	if steps[1].Payload != `{"id": {{_randomInt}}}` {
+		t.Errorf("Expected the unquoted JSON string as the payload, Found: %q", steps[1].Payload)
+	}
+	if _, ok := steps[1].Headers["Content-Type"]; ok || len(steps[1].Headers) != 1 {
+		t.Errorf("Content type of the headers should be kept, Found: %v", steps[1].Headers)
+	}
+
+	expected = &types.ProtobufPayload{File: "config_testdata/user.proto", ResponseMessage: "users.v1.User"}
+	if !reflect.DeepEqual(steps[2].Protobuf, expected) || steps[2].Payload != "" {
+		t.Errorf("Expected: %v, Found: %v %q", expected, steps[2].Protobuf, steps[2].Payload)
+	}
+}
+
+func TestCreateHammerInvalidPayloadProtobuf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		step string
+	}{
+		{"WithPayload", `"payload": "foo", "payload_protobuf": {"proto": "config_testdata/user.proto", ` +
+			`"message": "users.v1.CreateUser"}`},
+		{"WithPayloadBase64", `"payload_base64": "CgNmb28=", "payload_protobuf": {` +
+			`"proto": "config_testdata/user.proto", "message": "users.v1.CreateUser"}`},
+		{"JSONWithoutMessage", `"payload_protobuf": {"proto": "config_testdata/user.proto", "json": {"id": 1}, ` +
+			`"response_message": "users.v1.User"}`},
+		{"MissingProto", `"payload_protobuf": {"message": "users.v1.CreateUser"}`},
+		{"NoMessage", `"payload_protobuf": {"proto": "config_testdata/user.proto"}`},
+		{"ProtoNotFound", `"payload_protobuf": {"proto": "config_testdata/missing.proto", ` +
+			`"message": "users.v1.CreateUser"}`},
+		{"MessageNotFound", `"payload_protobuf": {"proto": "config_testdata/user.proto", "message": "users.v1.Missing"}`},
+		{"ResponseMessageNotFound", `"payload_protobuf": {"proto": "config_testdata/user.proto", ` +
+			`"response_message": "users.v1.Missing"}`},
+		{"UnknownField", `"payload_protobuf": {"proto": "config_testdata/user.proto", ` +
+			`"message": "users.v1.CreateUser", "json": {"email": "a"}}`},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := fmt.Sprintf(`{"steps": [{"id": 1, "url": "https://test.com", %s}]}`, test.step)
+			jsonReader, _ := NewConfigReader([]byte(config), ConfigTypeJson)
+			h, err := jsonReader.CreateHammer()
+			if err == nil {
+				err = h.Validate()
+			}
+			if err == nil {
+				t.Errorf("Should be errored")
+			}
+		})
+	}
+}
+
 func TestCreateHammerMultipartPayload(t *testing.T) {
 	t.Parallel()
 	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_multipart_payload.json"), ConfigTypeJson)
@@ -677,3 +919,938 @@ OLDW6kKz5pI4T8lQJhdqjCU=
 
 	return cert, certKey
 }
+
+func TestCreateHammerTargetsFile(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_targets_file.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerTargetsFile error occurred: %v", err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Errorf("Steps fed by a targets file should be valid without url: %v", err)
+	}
+
+	step := h.Scenario.Steps[0]
+	if step.URL != "" || step.Protocol != types.ProtocolHTTP {
+		t.Errorf("Expected empty url with protocol %s, Found url %q protocol %s", types.ProtocolHTTP, step.URL, step.Protocol)
+	}
+	if step.Custom["targets-file"] != "urls.txt" {
+		t.Errorf("Expected targets-file %s, Found %v", "urls.txt", step.Custom["targets-file"])
+	}
+}
+
+func TestCreateHammerSeed(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_seed.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerSeed error occurred: %v", err)
+	}
+	if h.Scenario.Seed != 1234567 {
+		t.Errorf("Seed Expected %d, Found %d", 1234567, h.Scenario.Seed)
+	}
+}
+
+func TestCreateHammerConnectionScope(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_connection_scope.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerConnectionScope error occurred: %v", err)
+	}
+	if h.Scenario.ConnectionScope != types.ConnectionScopeUser {
+		t.Errorf("ConnectionScope Expected %s, Found %s", types.ConnectionScopeUser, h.Scenario.ConnectionScope)
+	}
+}
+
+func TestCreateHammerTeardown(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_teardown.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerTeardown error occurred: %v", err)
+	}
+	if h.Scenario.CollectLimit != 5000 {
+		t.Errorf("CollectLimit Expected %d, Found %d", 5000, h.Scenario.CollectLimit)
+	}
+	if got := h.Scenario.CollectedCaptures(); !reflect.DeepEqual(got, []string{"order_id"}) {
+		t.Errorf("CollectedCaptures Expected %v, Found %v", []string{"order_id"}, got)
+	}
+	if len(h.Scenario.Teardown) != 2 {
+		t.Fatalf("Teardown step count Expected %d, Found %d", 2, len(h.Scenario.Teardown))
+	}
+
+	expected := []struct {
+		name    string
+		method  string
+		url     string
+		foreach interface{}
+		batch   interface{}
+	}{
+		{"Delete Orders", http.MethodDelete, "https://test.com/orders/{{ .order_id }}", "order_id", nil},
+		{"", http.MethodPost, "https://test.com/orders/bulk-delete", "order_id", float64(100)},
+	}
+	for i, e := range expected {
+		st := h.Scenario.Teardown[i]
+		if st.Name != e.name || st.Method != e.method || st.URL != e.url {
+			t.Errorf("Teardown step %d Expected %s %s %s, Found %s %s %s", i, e.name, e.method, e.url,
+				st.Name, st.Method, st.URL)
+		}
+		if st.Custom["foreach"] != e.foreach || st.Custom["foreach-batch"] != e.batch {
+			t.Errorf("Teardown step %d foreach Expected %v %v, Found %v %v", i, e.foreach, e.batch,
+				st.Custom["foreach"], st.Custom["foreach-batch"])
+		}
+	}
+}
+
+func TestCreateHammerParallelGroup(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_parallel_group.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerParallelGroup error occurred: %v", err)
+	}
+
+	expected := [][2]int{{0, 1}, {1, 4}}
+	if got := types.ParallelGroups(h.Scenario.Steps); !reflect.DeepEqual(got, expected) {
+		t.Errorf("ParallelGroups Expected %v, Found %v", expected, got)
+	}
+	if g := h.Scenario.Steps[3].ParallelGroup; g != "home" {
+		t.Errorf("ParallelGroup Expected %s, Found %s", "home", g)
+	}
+}
+
+func TestCreateHammerDependsOn(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_depends_on.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerDependsOn error occurred: %v", err)
+	}
+	if err := h.Validate(); err != nil {
+		t.Fatalf("Captures of the dependencies should be visible, Found %v", err)
+	}
+
+	ids := make([]uint16, 0, len(h.Scenario.Steps))
+	for _, st := range h.Scenario.Steps {
+		ids = append(ids, st.ID)
+	}
+	if expected := []uint16{4, 2, 3, 1}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Step order Expected %v, Found %v", expected, ids)
+	}
+	if expected := [][2]int{{0, 1}, {1, 3}, {3, 4}}; !reflect.DeepEqual(types.ParallelGroups(h.Scenario.Steps),
+		expected) {
+		t.Errorf("ParallelGroups Expected %v, Found %v", expected, types.ParallelGroups(h.Scenario.Steps))
+	}
+	if d := h.Scenario.Steps[3].DependsOn; !reflect.DeepEqual(d, []string{"profile", "3"}) {
+		t.Errorf("DependsOn Expected [profile 3], Found %v", d)
+	}
+
+	for _, invalid := range []string{
+		`{"steps": [{"id": 1, "url": "https://test.com", "depends_on": [true]}]}`,
+		`{"steps": [{"id": 1, "name": "a", "url": "https://test.com", "depends_on": ["b"]},
+			{"id": 2, "name": "b", "url": "https://test.com", "depends_on": ["a"]}]}`,
+	} {
+		jsonReader, _ := NewConfigReader([]byte(invalid), ConfigTypeJson)
+		if _, err := jsonReader.CreateHammer(); err == nil {
+			t.Errorf("Invalid depends_on should be errored: %s", invalid)
+		}
+	}
+}
+
+func TestCreateHammerCapture(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_capture.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerCapture error occurred: %v", err)
+	}
+
+	expected := types.Capture{
+		Rate:     0.001,
+		Count:    100,
+		Failures: types.DefaultCaptureFailures,
+		File:     "captures.ndjson",
+	}
+	if h.Scenario.Capture != expected {
+		t.Errorf("Capture Expected %#v, Found %#v", expected, h.Scenario.Capture)
+	}
+}
+
+func TestCreateHammerCaptureRate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		json      string
+		expected  types.Capture
+		shouldErr bool
+	}{
+		{"Ratio", `{"capture_rate": 0.5}`,
+			types.Capture{Rate: 0.5, Failures: types.DefaultCaptureFailures, File: types.DefaultCaptureFile}, false},
+		{"FailuresOnly", `{"capture_failures": 3}`,
+			types.Capture{Failures: 3, File: types.DefaultCaptureFile}, false},
+		{"NoFailures", `{"capture_rate": "1%", "capture_failures": 0}`,
+			types.Capture{Rate: 0.01, File: types.DefaultCaptureFile}, false},
+		{"Disabled", `{}`, types.Capture{}, false},
+		{"InvalidRate", `{"capture_rate": "a%"}`, types.Capture{}, true},
+		{"InvalidType", `{"capture_rate": true}`, types.Capture{}, true},
+	}
+
+	for _, test := range tests {
+		j := &JsonReader{}
+		if err := j.Init([]byte(test.json)); err != nil {
+			t.Fatalf("%s: Init errored: %v", test.name, err)
+		}
+
+		c, err := j.capture()
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("%s: Should be errored", test.name)
+			}
+			continue
+		}
+		if err != nil || c != test.expected {
+			t.Errorf("%s: Expected %#v, Found %#v, err: %v", test.name, test.expected, c, err)
+		}
+	}
+}
+
+func TestCreateHammerSuccessCriteria(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_success_criteria.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerSuccessCriteria error occurred: %v", err)
+	}
+
+	expected := "steps.login.p95 < 300ms && result.fail_rate < 1%"
+	if h.SuccessCriteria != expected {
+		t.Errorf("SuccessCriteria Expected %q, Found %q", expected, h.SuccessCriteria)
+	}
+}
+
+func TestCreateHammerAbortRules(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_abort_rules.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerAbortRules error occurred: %v", err)
+	}
+
+	expected := []string{"steps.login.p95 > 800ms over 1m for 3 windows", "steps.login.fail_rate > 5%"}
+	if !reflect.DeepEqual(h.AbortRules, expected) {
+		t.Errorf("AbortRules Expected %q, Found %q", expected, h.AbortRules)
+	}
+}
+
+func TestCreateHammerStopAfterFailures(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_stop_after_failures.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerStopAfterFailures error occurred: %v", err)
+	}
+
+	if h.StopAfterFailures != 25 {
+		t.Errorf("StopAfterFailures Expected %d, Found %d", 25, h.StopAfterFailures)
+	}
+}
+
+func TestCreateHammerMaxTransfer(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_max_transfer.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerMaxTransfer error occurred: %v", err)
+	}
+
+	if h.MaxTransfer != 50e9 {
+		t.Errorf("MaxTransfer Expected %d, Found %d", int64(50e9), h.MaxTransfer)
+	}
+}
+
+func TestCreateHammerShutdownTimeout(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_shutdown_timeout.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerShutdownTimeout error occurred: %v", err)
+	}
+
+	if h.ShutdownTimeout != time.Minute {
+		t.Errorf("ShutdownTimeout Expected %v, Found %v", time.Minute, h.ShutdownTimeout)
+	}
+}
+
+func TestCreateHammerResultBuffer(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_result_buffer.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerResultBuffer error occurred: %v", err)
+	}
+
+	if h.ResultBufferSize != 500 {
+		t.Errorf("ResultBufferSize Expected %v, Found %v", 500, h.ResultBufferSize)
+	}
+	if h.ResultBlockWarning != 2500*time.Millisecond {
+		t.Errorf("ResultBlockWarning Expected %v, Found %v", 2500*time.Millisecond, h.ResultBlockWarning)
+	}
+}
+
+func TestCreateHammerMetadata(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_metadata.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerMetadata error occurred: %v", err)
+	}
+
+	expected := []types.Label{
+		{Key: "api_token", Value: "abc", Secret: true},
+		{Key: "build", Value: "1024"},
+		{Key: "env", Value: "staging"},
+	}
+	if !reflect.DeepEqual(h.Metadata.Labels, expected) {
+		t.Errorf("Labels Expected %v, Found %v", expected, h.Metadata.Labels)
+	}
+}
+
+func TestCreateHammerNetwork(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_network.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerNetwork error occurred: %v", err)
+	}
+
+	if n := h.Scenario.Steps[0].Custom["network"]; n != "slow-3g" {
+		t.Errorf("Global network should be applied to the step, Found %v", n)
+	}
+	expected := map[string]interface{}{"latency": float64(100)}
+	if n := h.Scenario.Steps[1].Custom["network"]; !reflect.DeepEqual(n, expected) {
+		t.Errorf("Network of the step should override the global one, Expected %v, Found %v", expected, n)
+	}
+}
+
+func TestCreateHammerReportDimensions(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_report_dimensions.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerReportDimensions error occurred: %v", err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Fatalf("TestCreateHammerReportDimensions validation error occurred: %v", err)
+	}
+
+	expected := []interface{}{"X-Backend-Pod"}
+	if d := h.Scenario.Steps[0].Custom["report-dimensions"]; !reflect.DeepEqual(d, expected) {
+		t.Errorf("Global report dimensions should be applied to the step, Expected %v, Found %v", expected, d)
+	}
+	expected = []interface{}{"X-Region", "X-Cache"}
+	if d := h.Scenario.Steps[1].Custom["report-dimensions"]; !reflect.DeepEqual(d, expected) {
+		t.Errorf("Report dimensions of the step should override the global ones, Expected %v, Found %v", expected, d)
+	}
+	for _, st := range h.Scenario.Steps {
+		if l := st.Custom["report-dimension-limit"]; l != float64(20) {
+			t.Errorf("Global report dimension limit should be applied to the step %d, Found %v", st.ID, l)
+		}
+	}
+	if l := h.Scenario.Steps[0].Custom["report-host-limit"]; l != float64(200) {
+		t.Errorf("Global report host limit should be applied to the step, Found %v", l)
+	}
+	if l := h.Scenario.Steps[1].Custom["report-host-limit"]; l != float64(5) {
+		t.Errorf("Report host limit of the step should override the global one, Found %v", l)
+	}
+}
+
+func TestCreateHammerLeakScan(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_leak_scan.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerLeakScan error occurred: %v", err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Fatalf("TestCreateHammerLeakScan validation error occurred: %v", err)
+	}
+
+	if s := h.Scenario.Steps[0].Custom["leak-scan"]; s != true {
+		t.Errorf("Global leak scan should be applied to the step, Found %v", s)
+	}
+	scan, err := types.ParseLeakScan(h.Scenario.Steps[1].Custom["leak-scan"])
+	if err != nil {
+		t.Fatalf("TestCreateHammerLeakScan parse error occurred: %v", err)
+	}
+	if len(scan.Patterns) != 2 || scan.Patterns[1].Name != "jwt" || scan.Sample != 0.01 || scan.MaxBody != 16000 {
+		t.Errorf("Leak scan of the step should override the global one, Found %+v", scan)
+	}
+}
+
+func TestCreateHammerDefaults(t *testing.T) {
+	t.Parallel()
+	jsonReader, err := NewConfigReader(readConfigFile("config_testdata/config_defaults.json"), ConfigTypeJson)
+	if err != nil {
+		t.Fatalf("TestCreateHammerDefaults error occurred: %v", err)
+	}
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerDefaults error occurred: %v", err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Fatalf("TestCreateHammerDefaults validation error occurred: %v", err)
+	}
+
+	auth := types.Auth{Type: types.AuthHttpBasic, Username: "test_user", Password: "12345"}
+	repeated := map[string][]string{"Forwarded": {"for=192.0.2.60", "for=198.51.100.17"}}
+	tests := []struct {
+		name          string
+		step          types.ScenarioStep
+		headers       map[string]string
+		repeated      map[string][]string
+		timeout       int
+		auth          types.Auth
+		successStatus string
+		others        map[string]interface{}
+	}{
+		{"Inherited", h.Scenario.Steps[0], map[string]string{"Accept": "application/json", "X-Api-Key": "abc"},
+			repeated, 30, auth, "200-299", map[string]interface{}{"keep-alive": false, "h2": true}},
+		{"Overridden", h.Scenario.Steps[1], map[string]string{"accept": "text/html", "X-Order": "{{_randomInt}}"},
+			repeated, 10, types.Auth{}, "", map[string]interface{}{"keep-alive": true}},
+		{"Removed", h.Scenario.Steps[2], nil, nil, types.DefaultTimeout, auth, "200-299",
+			map[string]interface{}{"keep-alive": false, "h2": true}},
+		{"Teardown", h.Scenario.Teardown[0], map[string]string{"Accept": "application/json", "X-Api-Key": "abc"},
+			repeated, 30, auth, "200-299", map[string]interface{}{"keep-alive": false, "h2": true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := test.step
+			if !reflect.DeepEqual(s.Headers, test.headers) {
+				t.Errorf("Headers Expected %v, Found %v", test.headers, s.Headers)
+			}
+			if !reflect.DeepEqual(s.RepeatedHeaders, test.repeated) {
+				t.Errorf("RepeatedHeaders Expected %v, Found %v", test.repeated, s.RepeatedHeaders)
+			}
+			if s.Timeout != test.timeout {
+				t.Errorf("Timeout Expected %d, Found %d", test.timeout, s.Timeout)
+			}
+			if s.Auth != test.auth {
+				t.Errorf("Auth Expected %v, Found %v", test.auth, s.Auth)
+			}
+			if s.SuccessStatus != test.successStatus {
+				t.Errorf("SuccessStatus Expected %q, Found %q", test.successStatus, s.SuccessStatus)
+			}
+			if !reflect.DeepEqual(s.Custom, test.others) {
+				t.Errorf("Others Expected %v, Found %v", test.others, s.Custom)
+			}
+		})
+	}
+}
+
+func TestCreateHammerDefaultsTLS(t *testing.T) {
+	t.Parallel()
+
+	cert, certKey := generateCerts()
+	certFile, keyFile, err := createCertPairFiles(cert, certKey)
+	if err != nil {
+		t.Fatalf("Failed to prepare certs %v", err)
+	}
+	defer os.Remove(certFile.Name())
+	defer os.Remove(keyFile.Name())
+
+	config := fmt.Sprintf(`{"defaults": {"cert_path": %q, "cert_key_path": %q}, "steps": [
+		{"id": 1, "url": "https://test.com"},
+		{"id": 2, "url": "https://test.com", "cert_path": null, "cert_key_path": null}]}`,
+		certFile.Name(), keyFile.Name())
+	jsonReader, err := NewConfigReader([]byte(config), ConfigTypeJson)
+	if err != nil {
+		t.Fatalf("TestCreateHammerDefaultsTLS error occurred: %v", err)
+	}
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerDefaultsTLS error occurred: %v", err)
+	}
+
+	certVal, _, err := types.ParseTLS(certFile.Name(), keyFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to gen certs %v", err)
+	}
+	if !reflect.DeepEqual(certVal, h.Scenario.Steps[0].Cert) || h.Scenario.Steps[0].CertPool == nil {
+		t.Errorf("Certificate of the defaults should be applied to the step")
+	}
+	if h.Scenario.Steps[1].Cert.Certificate != nil || h.Scenario.Steps[1].CertPool != nil {
+		t.Errorf("Certificate of the defaults should be removed by the step")
+	}
+}
+
+func TestInvalidDefaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		defaults string
+		step     string
+		errMsg   string
+	}{
+		{"NotObject", `"x"`, `{"id": 1}`, "defaults should be an object"},
+		{"UnsupportedKey", `{"url": "https://test.com", "timeout": 3}`, `{"id": 1}`, "unsupported default: url"},
+		{"DefaultHeadersNotObject", `{"headers": ["Accept"]}`, `{"id": 1}`,
+			"step 1: default headers should be an object"},
+		{"StepOthersNotObject", `{"others": {"h2": true}}`, `{"id": 1, "others": true}`,
+			"step 1: others should be an object"},
+		{"InvalidInheritedHeader", `{"headers": {"X-Id": 1}}`, `{"id": 1}`,
+			"header X-Id should be a string or a non-empty list of strings"},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := fmt.Sprintf(`{"defaults": %s, "steps": [%s]}`, test.defaults, test.step)
+			_, err := NewConfigReader([]byte(config), ConfigTypeJson)
+			if err == nil || err.Error() != test.errMsg {
+				t.Errorf("Error Expected %q, Found %v", test.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestCreateHammerMaxBodySize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		config    string
+		expected  []int64
+		shouldErr bool
+	}{
+		{"ByteSize", `{"steps": [{"id": 1, "url": "https://test.com", "max_body_size": "1MB"}]}`,
+			[]int64{1e6}, false},
+		{"ByteCount", `{"steps": [{"id": 1, "url": "https://test.com", "max_body_size": 2048}]}`,
+			[]int64{2048}, false},
+		{"Default", `{"defaults": {"max_body_size": "512KiB"}, "steps": [{"id": 1, "url": "https://test.com"},
+			{"id": 2, "url": "https://test.com", "max_body_size": null}]}`, []int64{512 << 10, 0}, false},
+		{"InvalidSize", `{"steps": [{"id": 1, "url": "https://test.com", "max_body_size": "10XB"}]}`, nil, true},
+		{"Negative", `{"steps": [{"id": 1, "url": "https://test.com", "max_body_size": -1}]}`, nil, true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			jsonReader, err := NewConfigReader([]byte(test.config), ConfigTypeJson)
+			if err != nil {
+				t.Fatalf("NewConfigReader errored: %v", err)
+			}
+			h, err := jsonReader.CreateHammer()
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateHammer errored: %v", err)
+			}
+			for i, expected := range test.expected {
+				if size := h.Scenario.Steps[i].MaxBodySize; size != expected {
+					t.Errorf("Step %d MaxBodySize Expected %d, Found %d", i+1, expected, size)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateHammerABSplit(t *testing.T) {
+	t.Parallel()
+
+	step := `"steps": [{"id": 1, "url": "https://test.com",
+		"others": {"ab-variants": {"a": {}, "b": {"base_url": "https://new.test.com"}}}}]`
+	tests := []struct {
+		name      string
+		config    string
+		expected  float64
+		shouldErr bool
+	}{
+		{"Percentage", `{"ab_split": "30%", ` + step + `}`, 0.3, false},
+		{"Ratio", `{"ab_split": 0.1, ` + step + `}`, 0.1, false},
+		{"NotSet", `{` + step + `}`, 0, false},
+		{"Invalid", `{"ab_split": "150%", ` + step + `}`, 0, true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			jsonReader, err := NewConfigReader([]byte(test.config), ConfigTypeJson)
+			if err != nil {
+				t.Fatalf("NewConfigReader errored: %v", err)
+			}
+			h, err := jsonReader.CreateHammer()
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateHammer errored: %v", err)
+			}
+			if h.Scenario.ABSplit != test.expected {
+				t.Errorf("ABSplit Expected %v, Found %v", test.expected, h.Scenario.ABSplit)
+			}
+		})
+	}
+}
+
+func TestCreateHammerSuccessStatus(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_success_status.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerSuccessStatus error occurred: %v", err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Fatalf("TestCreateHammerSuccessStatus validation error occurred: %v", err)
+	}
+
+	for i, expected := range []string{"200-299,404", "201,300-399", ""} {
+		if s := h.Scenario.Steps[i].SuccessStatus; s != expected {
+			t.Errorf("Step %d Expected %q, Found %q", i+1, expected, s)
+		}
+	}
+}
+
+func TestSuccessStatusSpec(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []interface{}{true, map[string]interface{}{}, []interface{}{"200", []interface{}{"404"}}} {
+		if _, err := successStatusSpec(v); err == nil {
+			t.Errorf("success_status %v should be errored", v)
+		}
+	}
+}
+
+func TestInvalidMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"Array", `{"metadata": {"env": ["a"]}}`},
+		{"ObjectWithoutValue", `{"metadata": {"env": {"secret": true}}}`},
+		{"NonBooleanSecret", `{"metadata": {"env": {"value": "a", "secret": "yes"}}}`},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			jsonReader, _ := NewConfigReader([]byte(test.json), ConfigTypeJson)
+			if _, err := jsonReader.CreateHammer(); err == nil {
+				t.Errorf("Invalid metadata should be errored")
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestMaxTransfer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		json      string
+		expected  int64
+		shouldErr bool
+	}{
+		{"ByteSize", `{"max_transfer": "512MiB"}`, 512 << 20, false},
+		{"ByteCount", `{"max_transfer": 1000}`, 1000, false},
+		{"Disabled", `{}`, 0, false},
+		{"InvalidSize", `{"max_transfer": "50XB"}`, 0, true},
+		{"Negative", `{"max_transfer": -1}`, 0, true},
+		{"InvalidType", `{"max_transfer": true}`, 0, true},
+	}
+
+	for _, test := range tests {
+		j := &JsonReader{}
+		if err := j.Init([]byte(test.json)); err != nil {
+			t.Fatalf("%s: Init errored: %v", test.name, err)
+		}
+
+		n, err := j.maxTransfer()
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("%s: Should be errored", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Errored: %v", test.name, err)
+		}
+		if n != test.expected {
+			t.Errorf("%s: Expected %d, Found %d", test.name, test.expected, n)
+		}
+	}
+}
+
+func TestCreateHammerDynamicRate(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_dynamic_rate.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerDynamicRate error occurred: %v", err)
+	}
+
+	expected := &types.DynamicRate{
+		Prometheus: "http://prometheus:9090",
+		Query:      "sum(rate(http_requests_total[1m]))",
+		Expression: "value * 2",
+		Interval:   2500 * time.Millisecond,
+		Min:        1,
+		Max:        500,
+	}
+	if !reflect.DeepEqual(h.DynamicRate, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, h.DynamicRate)
+	}
+}
+
+func TestCreateHammerSchedule(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_schedule.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerSchedule error occurred: %v", err)
+	}
+
+	loc, _ := time.LoadLocation("Europe/Istanbul")
+	expected := &types.LoadSchedule{
+		Points: []types.SchedulePoint{
+			{TimeOfDay: 3 * time.Hour, Rate: 2},
+			{TimeOfDay: 9 * time.Hour, Rate: 50},
+			{TimeOfDay: 20*time.Hour + 30*time.Second, Rate: 80},
+		},
+		Location: loc,
+	}
+	if h.LoadType != types.LoadTypeSchedule || !reflect.DeepEqual(h.Schedule, expected) {
+		t.Errorf("Expected %+v, Found %s %+v", expected, h.LoadType, h.Schedule)
+	}
+
+	for _, invalid := range []string{
+		`{"load_type": "schedule", "schedule": {"timezone": "Mars/Olympus", "points": [{"time": "09:00", "rate": 1}]}}`,
+		`{"load_type": "schedule", "schedule": {"points": [{"time": "9am", "rate": 1}]}}`,
+	} {
+		jsonReader, _ := NewConfigReader([]byte(invalid), ConfigTypeJson)
+		if _, err := jsonReader.CreateHammer(); err == nil {
+			t.Errorf("Invalid schedule should be errored: %s", invalid)
+		}
+	}
+}
+
+func TestCreateHammerBurst(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_burst.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerBurst error occurred: %v", err)
+	}
+
+	expected := &types.LoadBurst{
+		Size:        500,
+		Interval:    30 * time.Second,
+		Count:       4,
+		MaxInFlight: 100,
+		Policy:      types.BurstPolicySkip,
+	}
+	if h.LoadType != types.LoadTypeBurst || !reflect.DeepEqual(h.Burst, expected) {
+		t.Errorf("Expected %+v, Found %s %+v", expected, h.LoadType, h.Burst)
+	}
+	// Duration of the test fits the bursts instead of the duration of the config
+	if h.TestDuration != 120 {
+		t.Errorf("Expected duration 120, Found %d", h.TestDuration)
+	}
+}
+
+func TestCreateHammerVirtualUsers(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_vu.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerVirtualUsers error occurred: %v", err)
+	}
+
+	expected := &types.VirtualUsers{Users: 20, IterationsPerUser: 5}
+	if h.LoadType != types.LoadTypeVU || !reflect.DeepEqual(h.VirtualUsers, expected) {
+		t.Errorf("Expected %+v, Found %s %+v", expected, h.LoadType, h.VirtualUsers)
+	}
+	// Iteration count of the test is set by the users instead of the iteration count of the config
+	if h.IterationCount != 100 {
+		t.Errorf("Expected iteration count 100, Found %d", h.IterationCount)
+	}
+}
+
+func TestCreateHammerPercentiles(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_percentiles.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerPercentiles error occurred: %v", err)
+	}
+
+	expected := types.PercentileSketch{
+		Kind:              types.SketchHDR,
+		SignificantDigits: 4,
+		ExactLimit:        100000,
+	}
+	if !reflect.DeepEqual(h.Percentiles, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, h.Percentiles)
+	}
+}
+
+func TestCreateHammerCost(t *testing.T) {
+	t.Parallel()
+	jsonReader, _ := NewConfigReader(readConfigFile("config_testdata/config_cost.json"), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerCost error occurred: %v", err)
+	}
+
+	expected := &types.Cost{PerRequest: 0.0000035, PerGB: 0.09, ResponseSize: 2000, Budget: 5, Currency: "EUR"}
+	if !reflect.DeepEqual(h.Cost, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, h.Cost)
+	}
+
+	config := `{"cost": {"response_size": "2XB"}, "steps": [{"id": 1, "url": "https://test.com"}]}`
+	jsonReader, _ = NewConfigReader([]byte(config), ConfigTypeJson)
+	if _, err = jsonReader.CreateHammer(); err == nil {
+		t.Errorf("Invalid response_size Expected an error")
+	}
+}
+
+func TestCreateHammerValidationErrors(t *testing.T) {
+	t.Parallel()
+	config := `{"capture_rate": true, "steps": [
+		{"id": 1, "url": "https://test.com", "chunked": true},
+		{"id": 2, "url": "https://test.com"},
+		{"id": 3, "url": "https://test.com", "payload": "a", "payload_base64": "YQ=="}
+	], "max_transfer": "2XB"}`
+	jsonReader, _ := NewConfigReader([]byte(config), ConfigTypeJson)
+
+	_, err := jsonReader.CreateHammer()
+	errs, ok := err.(types.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected types.ValidationErrors, Found %T: %v", err, err)
+	}
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	expected := []string{"capture_rate", "steps[0].chunked", "steps[2].payload_base64", "max_transfer"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, Found %v", expected, paths)
+	}
+	if errs[1].Code != types.CodeRequired || errs[2].Code != types.CodeConflict {
+		t.Errorf("Expected codes %s and %s, Found %s and %s", types.CodeRequired, types.CodeConflict, errs[1].Code,
+			errs[2].Code)
+	}
+}
+
+func TestInitValidationErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		config string
+		path   string
+		code   string
+		fix    string
+	}{
+		{"Syntax", "{\n  \"steps\": [\n    {\"id\": 1,}\n  ]\n}", "", types.CodeInvalidSyntax,
+			"fix the json near line 3, column 15: invalid character '}' looking for beginning of object key string"},
+		{"Type", `{"duration": "10"}`, "duration", types.CodeInvalidType, "set a value of type int"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewConfigReader([]byte(test.config), ConfigTypeJson)
+			ve, ok := err.(*types.ValidationError)
+			if !ok {
+				t.Fatalf("Expected *types.ValidationError, Found %T: %v", err, err)
+			}
+			if ve.Path != test.path || ve.Code != test.code || ve.Fix != test.fix {
+				t.Errorf("Expected %s %s %q, Found %s %s %q", test.path, test.code, test.fix, ve.Path, ve.Code, ve.Fix)
+			}
+		})
+	}
+}
+
+func TestCreateHammerStepProxy(t *testing.T) {
+	t.Parallel()
+	config := `{"proxy": "http://proxy_host:80", "steps": [
+		{"id": 1, "url": "https://admin.internal", "proxy": "none"},
+		{"id": 2, "url": "https://test.com"}
+	]}`
+	jsonReader, _ := NewConfigReader([]byte(config), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerStepProxy error occurred: %v", err)
+	}
+	if p := h.Scenario.Steps[0].Proxy; p != types.StepProxyNone {
+		t.Errorf("Expected %q, Found %q", types.StepProxyNone, p)
+	}
+	if p := h.Scenario.Steps[1].Proxy; p != "" {
+		t.Errorf("Step without a proxy should use the proxy of the test, Found %q", p)
+	}
+}
+
+func TestCreateHammerTLSSessionCache(t *testing.T) {
+	t.Parallel()
+	config := `{"tls_session_cache": false, "steps": [
+		{"id": 1, "url": "https://test.com"},
+		{"id": 2, "url": "https://test.com", "others": {"tls-session-cache": true}}
+	]}`
+	jsonReader, _ := NewConfigReader([]byte(config), ConfigTypeJson)
+
+	h, err := jsonReader.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestCreateHammerTLSSessionCache error occurred: %v", err)
+	}
+	for i, expected := range []bool{false, true} {
+		if v := h.Scenario.Steps[i].Custom["tls-session-cache"]; v != expected {
+			t.Errorf("Step %d: Expected %v, Found %v", i+1, expected, v)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultSuiteArtifactsDir is the artifacts directory of a suite without one, relative to the suite file.
+const DefaultSuiteArtifactsDir = "artifacts"
+
+// Suite is the configs run back-to-back by the suite subcommand, like the smoke, soak and spike profiles of a
+// release.
+type Suite struct {
+	Name    string
+	Entries []SuiteEntry
+
+	// Directory of the per-entry artifact folders and the suite summary
+	ArtifactsDir string
+
+	// Runs the remaining entries after a failed entry instead of stopping the suite
+	ContinueOnFailure bool
+
+	// Resets the connection state between the entries, so each entry starts cold. Otherwise, the entries take the
+	// resolved hosts and the TLS sessions of the previous entries.
+	Cold bool
+
+	// Keeps the idle connections of the steps for the next entries too
+	KeepConnections bool
+}
+
+// SuiteEntry is a config of the suite with the overrides of its top-level keys.
+type SuiteEntry struct {
+	Name string
+
+	// Path of the config file, relative paths of the suite file are resolved against its directory
+	Path string
+
+	// Top-level keys of the config replaced by the entry, like "duration" or "success_criteria"
+	Overrides map[string]json.RawMessage
+
+	// Wait before the next entry is run
+	Pause time.Duration
+}
+
+type suiteJson struct {
+	Name              string           `json:"name"`
+	ArtifactsDir      string           `json:"artifacts_dir"`
+	ContinueOnFailure bool             `json:"continue_on_failure"`
+	Cold              bool             `json:"cold"`
+	KeepConnections   bool             `json:"keep_connections"`
+	Pause             float64          `json:"pause"` // In seconds, the pause of the entries without one
+	Entries           []suiteEntryJson `json:"entries"`
+}
+
+type suiteEntryJson struct {
+	Name      string                     `json:"name"`
+	Config    string                     `json:"config"`
+	Overrides map[string]json.RawMessage `json:"overrides"`
+	Pause     *float64                   `json:"pause"` // In seconds
+}
+
+// NewSuite parses the suite file, dir is the directory of the file.
+func NewSuite(data []byte, dir string) (*Suite, error) {
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("provided suite json is invalid")
+	}
+	var j suiteJson
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	if len(j.Entries) == 0 {
+		return nil, fmt.Errorf("suite should have at least one entry")
+	}
+	if j.Pause < 0 {
+		return nil, fmt.Errorf("pause of the suite should be greater than or equal to 0")
+	}
+
+	if j.Cold && j.KeepConnections {
+		return nil, fmt.Errorf("keep_connections of the suite can not be used with cold")
+	}
+
+	s := &Suite{Name: j.Name, ArtifactsDir: j.ArtifactsDir, ContinueOnFailure: j.ContinueOnFailure, Cold: j.Cold,
+		KeepConnections: j.KeepConnections}
+	if s.ArtifactsDir == "" {
+		s.ArtifactsDir = DefaultSuiteArtifactsDir
+	}
+	s.ArtifactsDir = suitePath(dir, s.ArtifactsDir)
+	for i, e := range j.Entries {
+		if e.Config == "" {
+			return nil, fmt.Errorf("config of suite entry %d is missing", i+1)
+		}
+		pause := j.Pause
+		if e.Pause != nil {
+			pause = *e.Pause
+		}
+		if pause < 0 {
+			return nil, fmt.Errorf("pause of suite entry %d should be greater than or equal to 0", i+1)
+		}
+		for k, v := range e.Overrides {
+			if !json.Valid(v) {
+				return nil, fmt.Errorf("override %s of suite entry %d is invalid", k, i+1)
+			}
+		}
+
+		entry := SuiteEntry{
+			Name:      e.Name,
+			Path:      suitePath(dir, e.Config),
+			Overrides: e.Overrides,
+			Pause:     time.Duration(pause * float64(time.Second)),
+		}
+		if entry.Name == "" {
+			entry.Name = strings.TrimSuffix(filepath.Base(e.Config), filepath.Ext(e.Config))
+		}
+		s.Entries = append(s.Entries, entry)
+	}
+	return s, nil
+}
+
+// suitePath resolves the relative path of the suite file against its directory.
+func suitePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// ReadConfig returns the config of the entry with its overrides applied. The config is returned as it is if the
+// entry has no override, so its hash is the hash of the file.
+func (e SuiteEntry) ReadConfig() ([]byte, error) {
+	data, err := os.ReadFile(e.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Overrides) == 0 {
+		return data, nil
+	}
+
+	var keys map[string]json.RawMessage
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("config %s can not be read: %v", e.Path, err)
+	}
+	if keys == nil {
+		keys = make(map[string]json.RawMessage, len(e.Overrides))
+	}
+	for k, v := range e.Overrides {
+		keys[k] = v
+	}
+	return json.MarshalIndent(keys, "", "    ")
+}
@@ -0,0 +1,143 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewSuite(t *testing.T) {
+	t.Parallel()
+	s, err := NewSuite(readConfigFile("config_testdata/suite.json"), "config_testdata")
+	if err != nil {
+		t.Fatalf("TestNewSuite error occurred: %v", err)
+	}
+
+	expected := &Suite{
+		Name:              "release",
+		ArtifactsDir:      filepath.Join("config_testdata", DefaultSuiteArtifactsDir),
+		ContinueOnFailure: true,
+		KeepConnections:   true,
+		Entries: []SuiteEntry{
+			{
+				Name: "smoke",
+				Path: filepath.Join("config_testdata", "config_success_criteria.json"),
+				Overrides: map[string]json.RawMessage{
+					"duration":         json.RawMessage(`30`),
+					"success_criteria": json.RawMessage(`"result.fail_rate < 5%"`),
+				},
+			},
+			{
+				Name:  "config_seed",
+				Path:  filepath.Join("config_testdata", "config_seed.json"),
+				Pause: 5 * time.Second,
+			},
+		},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("Expected %#v, Found %#v", expected, s)
+	}
+}
+
+func TestNewSuiteInvalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		suite string
+		err   string
+	}{
+		{"InvalidJson", `{"entries": [`, "provided suite json is invalid"},
+		{"NoEntry", `{"name": "release"}`, "suite should have at least one entry"},
+		{"MissingConfig", `{"entries": [{"config": "a.json"}, {"name": "b"}]}`, "config of suite entry 2 is missing"},
+		{"NegativePause", `{"pause": -1, "entries": [{"config": "a.json"}]}`,
+			"pause of the suite should be greater than or equal to 0"},
+		{"NegativeEntryPause", `{"entries": [{"config": "a.json", "pause": -1}]}`,
+			"pause of suite entry 1 should be greater than or equal to 0"},
+		{"ColdKeepConnections", `{"cold": true, "keep_connections": true, "entries": [{"config": "a.json"}]}`,
+			"keep_connections of the suite can not be used with cold"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			_, err := NewSuite([]byte(test.suite), ".")
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Expected error %q, Found %v", test.err, err)
+			}
+		}
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			tf(t)
+		})
+	}
+}
+
+func TestNewSuiteAbsolutePaths(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	data := []byte(`{"artifacts_dir": "` + dir + `", "entries": [{"config": "` + filepath.Join(dir, "a.json") + `"}]}`)
+	s, err := NewSuite(data, "suites")
+	if err != nil {
+		t.Fatalf("TestNewSuiteAbsolutePaths error occurred: %v", err)
+	}
+	if s.ArtifactsDir != dir || s.Entries[0].Path != filepath.Join(dir, "a.json") || s.Entries[0].Name != "a" {
+		t.Errorf("Absolute paths should be kept, Found %s, %s, %s", s.ArtifactsDir, s.Entries[0].Path, s.Entries[0].Name)
+	}
+}
+
+func TestSuiteEntryReadConfig(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join("config_testdata", "config_success_criteria.json")
+	raw, _ := os.ReadFile(path)
+
+	data, err := SuiteEntry{Path: path}.ReadConfig()
+	if err != nil || string(data) != string(raw) {
+		t.Errorf("Config without overrides should be returned as it is, Found %s, %v", data, err)
+	}
+
+	overrides := map[string]json.RawMessage{"duration": json.RawMessage(`30`), "seed": json.RawMessage(`7`)}
+	data, err = SuiteEntry{Path: path, Overrides: overrides}.ReadConfig()
+	if err != nil {
+		t.Fatalf("TestSuiteEntryReadConfig error occurred: %v", err)
+	}
+	h, err := NewConfigReader(data, ConfigTypeJson)
+	if err != nil {
+		t.Fatalf("TestSuiteEntryReadConfig error occurred: %v", err)
+	}
+	hammer, err := h.CreateHammer()
+	if err != nil {
+		t.Fatalf("TestSuiteEntryReadConfig error occurred: %v", err)
+	}
+	if hammer.TestDuration != 30 || hammer.Scenario.Seed != 7 {
+		t.Errorf("Overrides should be applied, Found duration %d, seed %d", hammer.TestDuration, hammer.Scenario.Seed)
+	}
+	if hammer.SuccessCriteria == "" {
+		t.Errorf("Keys of the config which are not overridden should be kept")
+	}
+
+	if _, err = (SuiteEntry{Path: filepath.Join("config_testdata", "missing.json")}).ReadConfig(); err == nil {
+		t.Errorf("Missing config should fail")
+	}
+}
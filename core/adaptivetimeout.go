@@ -0,0 +1,71 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package core
+
+import (
+	"context"
+	"sync"
+
+	"go.ddosify.com/ddosify/core/report"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// startTimeoutAdapter recomputes the timeouts of the steps with an adaptive-timeout from the rolling p99 that the
+// report service feeds back, each step every interval of its option, and pushes them to the requesters. A step keeps
+// its timeout while there is no latency to compute it from.
+func (e *engine) startTimeoutAdapter() (stop func()) {
+	source, ok := e.reportService.(report.LatencySource)
+	if !ok {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	var wg sync.WaitGroup
+	for _, st := range e.hammer.Scenario.Steps {
+		val, isSet := st.Custom["adaptive-timeout"]
+		if !isSet {
+			continue
+		}
+		// Validated with the hammer
+		at, _ := types.ParseAdaptiveTimeout(val, st.Timeout)
+
+		wg.Add(1)
+		go func(id uint16, at types.AdaptiveTimeout) {
+			defer wg.Done()
+			ticker := e.clock.NewTicker(at.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C():
+					if p99, ok := source.RollingP99(id); ok {
+						e.scenarioService.SetTimeout(id, at.Timeout(p99))
+					}
+				}
+			}
+		}(st.ID, at)
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
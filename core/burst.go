@@ -0,0 +1,141 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.ddosify.com/ddosify/core/report"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// burstRunner fires the bursts of the burst load type on the ticks planned by createBurstReqCountArr.
+// The iterations of a burst are started as fast as the in-flight cap of the burst allows.
+type burstRunner struct {
+	e        *engine
+	burst    *types.LoadBurst
+	interval time.Duration
+
+	// Order of the last fired burst, only used by the tick loop
+	index int
+
+	// Running bursts, decremented by the bursts once their last iteration is finished
+	running int64
+}
+
+func newBurstRunner(e *engine, b *types.LoadBurst) *burstRunner {
+	return &burstRunner{e: e, burst: b, interval: burstTicks(b) * time.Duration(tickerInterval) * time.Millisecond}
+}
+
+// burstTicks returns the interval of the bursts in ticks, at least one tick.
+func burstTicks(b *types.LoadBurst) time.Duration {
+	tick := time.Duration(tickerInterval) * time.Millisecond
+	n := (b.Interval + tick/2) / tick
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// fire starts a burst of size iterations, or skips it by the policy if the previous burst is still running.
+// Skipped bursts are recorded right away, the others once they are completed.
+func (r *burstRunner) fire(size int) {
+	r.index++
+	s := report.BurstSummary{Index: r.index, Start: r.e.clock.Now(), Size: size, Interval: float32(r.interval.Seconds())}
+	if atomic.LoadInt64(&r.running) > 0 {
+		if r.burst.Policy == types.BurstPolicySkip {
+			s.Skipped = true
+			r.e.logOut.printf("warning: burst %d is skipped, the previous burst is still running", s.Index)
+			r.record(s)
+			return
+		}
+		s.Overlapped = true
+	}
+
+	atomic.AddInt64(&r.running, 1)
+	r.e.wg.Add(1)
+	go func() {
+		defer r.e.wg.Done()
+		r.run(&s)
+		atomic.AddInt64(&r.running, -1)
+		r.record(s)
+	}()
+}
+
+// run starts the iterations of the burst and waits for them. The iterations that are not started yet are dropped
+// if the test is stopped in the burst.
+func (r *burstRunner) run(s *report.BurstSummary) {
+	var slots chan struct{}
+	if r.burst.MaxInFlight > 0 {
+		slots = make(chan struct{}, r.burst.MaxInFlight)
+	}
+
+	var wg sync.WaitGroup
+	var requests, failures int64
+	e := r.e
+loop:
+	for i := 0; i < s.Size; i++ {
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-e.ctx.Done():
+				break loop
+			}
+		} else if e.ctx.Err() != nil {
+			break loop
+		}
+
+		s.Started++
+		atomic.AddInt64(&e.startedIterations, 1)
+		atomic.AddInt64(&e.inFlight, 1)
+		wg.Add(1)
+		e.wg.Add(1)
+		go func(t time.Time) {
+			res := e.runWorker(t)
+			atomic.AddInt64(&e.inFlight, -1)
+			if res != nil {
+				for _, sr := range res.StepResults {
+					atomic.AddInt64(&requests, 1)
+					if sr.Err.Type != "" {
+						atomic.AddInt64(&failures, 1)
+					}
+				}
+			}
+			if slots != nil {
+				<-slots
+			}
+			wg.Done()
+			e.wg.Done()
+		}(e.clock.Now())
+	}
+	wg.Wait()
+
+	s.Completion = float32(e.clock.Since(s.Start).Seconds())
+	s.Requests, s.Failures = atomic.LoadInt64(&requests), atomic.LoadInt64(&failures)
+}
+
+func (r *burstRunner) record(s report.BurstSummary) {
+	if rs, ok := r.e.reportService.(report.BurstAware); ok {
+		rs.RecordBurst(s)
+	}
+}
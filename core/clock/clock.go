@@ -0,0 +1,283 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of the time of the engine, the sleeps of the scenario steps and the report services, so a test
+// or a simulation can run them on a Fake. The requests are timed by the real clock, since they wait on the network,
+// except the duplicates of the duplicate_rate, whose pair timings follow the clock of the requester.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Until(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the time.Timer of a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is the time.Ticker of a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// Real returns the clock of the system time.
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Until(t time.Time) time.Duration        { return time.Until(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Fake is a Clock whose time moves only by Advance. The timers and the sleeps fire once the time reaches them.
+// Unlike the real tickers that drop the ticks of a slow reader, Advance waits each tick to be received by the reader
+// of its ticker, so the work of a tick starts before the time moves on. A ticker should be stopped once it is not read.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a timer or a ticker of the fake clock, the sleeps and the After channels are timers.
+type waiter struct {
+	f      *Fake
+	at     time.Time
+	period time.Duration // Zero for the timers
+	c      chan time.Time
+
+	// Closed by the Stop of a ticker, so a tick waiting for its reader is dropped
+	stopped chan struct{}
+}
+
+// NewFake returns a fake clock starting at the given time.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) Until(t time.Time) time.Duration {
+	return t.Sub(f.Now())
+}
+
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	w := &waiter{f: f, c: make(chan time.Time, 1)}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(w, d)
+	return (*fakeTimer)(w)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	w := &waiter{f: f, period: d, c: make(chan time.Time), stopped: make(chan struct{})}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(w, d)
+	return (*fakeTicker)(w)
+}
+
+// Advance moves the time by d, firing the timers and the tickers in the order of their times on the way.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.now.Add(d)
+	for len(f.waiters) > 0 && !f.waiters[0].at.After(end) {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		if w.at.After(f.now) {
+			f.now = w.at
+		}
+		if w.period == 0 {
+			w.c <- f.now
+			continue
+		}
+
+		// Next tick is scheduled before the reader receives this one, so the reader can stop the ticker
+		w.at = w.at.Add(w.period)
+		f.insert(w)
+		now, stopped := f.now, w.stopped
+		f.mu.Unlock()
+		select {
+		case w.c <- now:
+		case <-stopped:
+		}
+		f.mu.Lock()
+	}
+	if end.After(f.now) {
+		f.now = end
+	}
+}
+
+// Waiters returns the count of the timers, the tickers and the sleeps waiting for the time.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil waits until at least n timers, tickers or sleeps are waiting for the time.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// schedule adds the waiter to fire after d, a timer with a non-positive d fires right away. f.mu should be held.
+func (f *Fake) schedule(w *waiter, d time.Duration) {
+	w.at = f.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		w.c <- f.now
+		return
+	}
+	f.insert(w)
+	f.cond.Broadcast()
+}
+
+// insert adds the waiter in the order of the times, after the waiters of the same time. f.mu should be held.
+func (f *Fake) insert(w *waiter) {
+	i := sort.Search(len(f.waiters), func(i int) bool { return f.waiters[i].at.After(w.at) })
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = w
+}
+
+// remove removes the waiter, it returns false if the waiter is not waiting. f.mu should be held.
+func (f *Fake) remove(w *waiter) bool {
+	for i, o := range f.waiters {
+		if o == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer waiter
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	return t.f.remove((*waiter)(t))
+}
+
+// Reset reschedules the timer, a fired value that is not received yet is dropped.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	active := t.f.remove((*waiter)(t))
+	select {
+	case <-t.c:
+	default:
+	}
+	t.f.schedule((*waiter)(t), d)
+	return active
+}
+
+type fakeTicker waiter
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	if t.f.remove((*waiter)(t)) {
+		close(t.stopped)
+	}
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	if !t.f.remove((*waiter)(t)) {
+		t.stopped = make(chan struct{})
+	}
+	t.period = d
+	t.f.schedule((*waiter)(t), d)
+}
@@ -0,0 +1,124 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTimers(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	late := c.NewTimer(300 * time.Millisecond)
+	early := c.After(100 * time.Millisecond)
+	stopped := c.NewTimer(200 * time.Millisecond)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Errorf("Stop should report the pending timer once")
+	}
+	if n := c.Waiters(); n != 2 {
+		t.Errorf("Expected 2 waiters, Found %d", n)
+	}
+
+	c.Advance(150 * time.Millisecond)
+	select {
+	case at := <-early:
+		if !at.Equal(start.Add(100 * time.Millisecond)) {
+			t.Errorf("Timer should fire at its time, Found %v", at)
+		}
+	default:
+		t.Errorf("Timer should fire once the time reaches it")
+	}
+	select {
+	case <-late.C():
+		t.Errorf("Timer should not fire before its time")
+	default:
+	}
+	if c.Since(start) != 150*time.Millisecond {
+		t.Errorf("Expected 150ms since the start, Found %v", c.Since(start))
+	}
+
+	late.Reset(time.Second)
+	c.Advance(999 * time.Millisecond)
+	if c.Waiters() != 1 {
+		t.Errorf("Reset timer should wait for its new time")
+	}
+	c.Advance(time.Millisecond)
+	if at := <-late.C(); !at.Equal(start.Add(1150 * time.Millisecond)) {
+		t.Errorf("Reset timer should fire at its new time, Found %v", at)
+	}
+	if at := <-c.After(0); !at.Equal(c.Now()) {
+		t.Errorf("Zero timer should fire right away, Found %v", at)
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	ticker := c.NewTicker(100 * time.Millisecond)
+	ticks := make(chan time.Time, 10)
+	go func() {
+		for at := range ticker.C() {
+			ticks <- at
+			if len(ticks) == 3 {
+				ticker.Stop()
+			}
+		}
+	}()
+
+	// Advance waits for each tick to be received, so no tick is dropped
+	c.Advance(time.Second)
+	if len(ticks) != 3 {
+		t.Fatalf("Expected 3 ticks until the ticker is stopped, Found %d", len(ticks))
+	}
+	for i := 1; i <= 3; i++ {
+		if at := <-ticks; !at.Equal(start.Add(time.Duration(i) * 100 * time.Millisecond)) {
+			t.Errorf("Tick %d Expected at %v, Found %v", i, start.Add(time.Duration(i)*100*time.Millisecond), at)
+		}
+	}
+	if c.Waiters() != 0 || !c.Now().Equal(start.Add(time.Second)) {
+		t.Errorf("Stopped ticker should not wait, Found %d waiters at %v", c.Waiters(), c.Now())
+	}
+}
+
+func TestFakeSleep(t *testing.T) {
+	t.Parallel()
+
+	c := NewFake(time.Now())
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Minute)
+		close(done)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(59 * time.Second)
+	select {
+	case <-done:
+		t.Fatalf("Sleep should not return before its duration")
+	default:
+	}
+	c.Advance(time.Second)
+	<-done
+}
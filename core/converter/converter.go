@@ -0,0 +1,278 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Formats of the test files that can be converted.
+const (
+	FormatK6     = "k6"
+	FormatJMeter = "jmeter"
+)
+
+// assumedIterationSeconds is the iteration duration of a virtual user whose iterations have no sleep or timer.
+const assumedIterationSeconds = 1
+
+// defaultDuration is the duration of the loads whose source has no duration, in seconds.
+const defaultDuration = types.DefaultDuration
+
+// Result is the converted ddosify config and the constructs of the source that couldn't be translated.
+type Result struct {
+	Config   []byte
+	Warnings []string
+}
+
+// Convert converts a k6 script or a JMeter test plan into a ddosify config file.
+// The conversion is best-effort, every construct that is not translated is reported in the warnings.
+func Convert(format string, src []byte) (*Result, error) {
+	var (
+		c   *configFile
+		w   warnings
+		err error
+	)
+	switch format {
+	case FormatK6:
+		c, err = convertK6(src, &w)
+	case FormatJMeter:
+		c, err = convertJMeter(src, &w)
+	default:
+		err = fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Steps) == 0 {
+		return nil, fmt.Errorf("no http request is found to convert")
+	}
+
+	// Urls, payloads and criteria are kept readable, without the HTML escapes
+	b := bytes.Buffer{}
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err = enc.Encode(c); err != nil {
+		return nil, err
+	}
+	return &Result{Config: b.Bytes(), Warnings: w}, nil
+}
+
+// DetectFormat returns the format of the file by its extension, .js for k6 and .jmx for JMeter.
+func DetectFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".js":
+		return FormatK6, nil
+	case ".jmx":
+		return FormatJMeter, nil
+	}
+	return "", fmt.Errorf("format of %s can not be detected, it should be one of %s, %s", path, FormatK6, FormatJMeter)
+}
+
+type warnings []string
+
+// add adds the warning once, the constructs used in several places are reported once.
+func (w *warnings) add(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	for _, m := range *w {
+		if m == msg {
+			return
+		}
+	}
+	*w = append(*w, msg)
+}
+
+// configFile is the subset of the config file schema read by config.JsonReader.
+type configFile struct {
+	IterCount       int          `json:"iteration_count,omitempty"`
+	LoadType        string       `json:"load_type,omitempty"`
+	Duration        int          `json:"duration,omitempty"`
+	ManualLoad      []manualLoad `json:"manual_load,omitempty"`
+	SuccessCriteria string       `json:"success_criteria,omitempty"`
+	Steps           []*stepFile  `json:"steps"`
+}
+
+type manualLoad struct {
+	Duration int `json:"duration"`
+	Count    int `json:"count"`
+}
+
+type stepFile struct {
+	Id      uint16            `json:"id"`
+	Name    string            `json:"name,omitempty"`
+	Url     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload string            `json:"payload,omitempty"`
+	Timeout int               `json:"timeout,omitempty"`
+	Sleep   string            `json:"sleep,omitempty"`
+}
+
+func (c *configFile) addStep(name, method, rawURL string) *stepFile {
+	s := &stepFile{Id: uint16(len(c.Steps) + 1), Name: name, Url: rawURL, Method: strings.ToUpper(method)}
+	if s.Name == "" {
+		s.Name = stepName(s.Method, rawURL)
+	}
+	c.Steps = append(c.Steps, s)
+	return s
+}
+
+// loadStage is a stage of the test whose iteration rate changes linearly from the start to the end rate,
+// in iterations per second.
+type loadStage struct {
+	seconds  int
+	from, to float64
+}
+
+// setLoad sets the load of the config from the stages. A single constant stage is a linear load,
+// the others are converted to a manual load whose segments have the average rate of their stages.
+func (c *configFile) setLoad(stages []loadStage) {
+	if len(stages) == 1 && stages[0].from == stages[0].to {
+		c.setIterations(int(math.Round(stages[0].from*float64(stages[0].seconds))), stages[0].seconds)
+		return
+	}
+
+	for _, s := range stages {
+		c.ManualLoad = append(c.ManualLoad, manualLoad{
+			Duration: s.seconds,
+			Count:    int(math.Round((s.from + s.to) / 2 * float64(s.seconds))),
+		})
+	}
+}
+
+func (c *configFile) setIterations(count, seconds int) {
+	if count < 1 {
+		count = 1
+	}
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.IterCount, c.Duration, c.LoadType = count, seconds, types.LoadTypeLinear
+}
+
+// addCriteria appends the clauses to the success criteria of the config.
+func (c *configFile) addCriteria(clauses ...string) {
+	for _, clause := range clauses {
+		if c.SuccessCriteria != "" {
+			c.SuccessCriteria += " && "
+		}
+		c.SuccessCriteria += clause
+	}
+}
+
+// statusCheck is a check of the status code of the responses of a step.
+type statusCheck struct {
+	step uint16
+	code int
+}
+
+// statusClauses returns the success criteria clauses of the checks, the rate of each status code
+// is compared with the given value, like "> 0.99". All the responses should pass if cmp is empty.
+func statusClauses(checks []statusCheck, cmp string) []string {
+	if cmp == "" {
+		cmp = "== 1"
+	}
+	clauses := make([]string, len(checks))
+	for i, ch := range checks {
+		clauses[i] = fmt.Sprintf("steps.%d.status_%d_rate %s", ch.step, ch.code, cmp)
+	}
+	return clauses
+}
+
+// stepName returns a name like "POST /api/login".
+func stepName(method, rawURL string) string {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		path = u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+	}
+	return method + " " + path
+}
+
+// iterationRate returns the iterations per second of a virtual user whose iterations sleep the given seconds.
+// The request durations are not known, so an iteration without a sleep is assumed to take assumedIterationSeconds.
+func iterationRate(sleepSeconds float64, w *warnings) float64 {
+	if sleepSeconds <= 0 {
+		w.add("iterations have no sleep or timer, each virtual user is assumed to run %d iteration per second",
+			assumedIterationSeconds)
+		sleepSeconds = assumedIterationSeconds
+	}
+	return 1 / sleepSeconds
+}
+
+// sleepPlan accumulates the sleeps after the steps, the ranges are in seconds.
+type sleepPlan map[*stepFile][2]float64
+
+func (p sleepPlan) add(step *stepFile, min, max float64) {
+	s := p[step]
+	p[step] = [2]float64{s[0] + min, s[1] + max}
+}
+
+// apply sets the sleeps of the steps in milliseconds, a range like "500-1500" for the random sleeps.
+func (p sleepPlan) apply() {
+	for step, s := range p {
+		min, max := int(math.Round(s[0]*1000)), int(math.Round(s[1]*1000))
+		if max == 0 {
+			continue
+		}
+		if min == max {
+			step.Sleep = strconv.Itoa(min)
+		} else {
+			step.Sleep = fmt.Sprintf("%d-%d", min, max)
+		}
+	}
+}
+
+// ceilSeconds returns the duration in whole seconds, at least 1.
+func ceilSeconds(seconds float64) int {
+	s := int(math.Ceil(seconds))
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
+
+func (s *stepFile) setHeader(key, value string) {
+	if s.Headers == nil {
+		s.Headers = make(map[string]string)
+	}
+	s.Headers[key] = value
+}
+
+func hasHeader(headers map[string]string, key string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,384 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/config"
+)
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		source   string
+		warnings []string
+	}{
+		{"K6ConstantVUs", "k6_constant_vus.js", nil},
+		{"K6RampingStages", "k6_stages.js", []string{
+			"line 4: __ENV.BASE_URL is not resolved, its fallback value is used",
+		}},
+		{"K6RequestsWithChecks", "k6_checks.js", []string{
+			`line 28: check "has token" is not translated`,
+			"line 37: value of token is not translated",
+			"line 39: token is not resolved, it is left as ${token}",
+			"line 42: statement is not translated: if ( __VU === 1 )",
+		}},
+		{"JMeterThreadGroup", "jmeter_thread_group.jmx", []string{
+			`CookieManager "HTTP Cookie Manager" is not translated`,
+			`RegexExtractor "Item Id" is not translated`,
+			"${itemId} is not resolved, it is left as is",
+			"property threads is not available, its default value 20 is used",
+		}},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, _ := os.ReadFile(filepath.Join("testdata", test.source))
+			format, err := DetectFormat(test.source)
+			if err != nil {
+				t.Fatalf("DetectFormat errored: %v", err)
+			}
+			res, err := Convert(format, src)
+			if err != nil {
+				t.Fatalf("Convert errored: %v", err)
+			}
+
+			golden := strings.TrimSuffix(test.source, filepath.Ext(test.source)) + ".json"
+			expected, _ := os.ReadFile(filepath.Join("testdata", golden))
+			if !bytes.Equal(res.Config, expected) {
+				t.Errorf("Config Expected %s, Found %s", expected, res.Config)
+			}
+			if !reflect.DeepEqual(res.Warnings, test.warnings) {
+				t.Errorf("Warnings Expected %q, Found %q", test.warnings, res.Warnings)
+			}
+
+			// Converted config should run without edits.
+			c, err := config.NewConfigReader(res.Config, config.ConfigTypeJson)
+			if err != nil {
+				t.Fatalf("Converted config could not be read: %v", err)
+			}
+			h, err := c.CreateHammer()
+			if err != nil {
+				t.Fatalf("Converted config could not be converted to hammer: %v", err)
+			}
+			if err = h.Validate(); err != nil {
+				t.Errorf("Converted hammer is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestConvertK6Load(t *testing.T) {
+	t.Parallel()
+
+	const body = `
+import http from 'k6/http';
+import { sleep } from 'k6';
+%s
+export default function () {
+  http.get('https://test.k6.io/');
+  sleep(2);
+}`
+
+	tests := []struct {
+		name     string
+		options  string
+		expected configFile
+	}{
+		{"NoOptions", "", configFile{IterCount: 1, Duration: 2, LoadType: "linear"}},
+		{"Iterations", "export const options = { vus: 5, iterations: 50 };",
+			configFile{IterCount: 50, Duration: 20, LoadType: "linear"}},
+		{"PerVUIterations", `export const options = {
+  scenarios: { s: { executor: 'per-vu-iterations', vus: 4, iterations: 3 } },
+};`, configFile{IterCount: 12, Duration: 6, LoadType: "linear"}},
+		{"RampingVUsWithStartVUs", `export const options = {
+  scenarios: { s: { executor: 'ramping-vus', startVUs: 10, stages: [{ duration: '0s', target: 20 }, { duration: '10s', target: 40 }] } },
+};`, configFile{ManualLoad: []manualLoad{{Duration: 10, Count: 150}}}},
+		{"ConstantArrivalRatePerMinute", `export const options = {
+  scenarios: { s: { executor: 'constant-arrival-rate', rate: 120, timeUnit: '1m', duration: '1m30s' } },
+};`, configFile{IterCount: 180, Duration: 90, LoadType: "linear"}},
+		{"RampingArrivalRate", `const stages = [{ duration: '10s', target: 100 }, { duration: 5000, target: 100 }];
+export const options = {
+  scenarios: { s: { executor: 'ramping-arrival-rate', startRate: 0, stages: stages } },
+};`, configFile{ManualLoad: []manualLoad{{Duration: 10, Count: 500}, {Duration: 5, Count: 500}}}},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := Convert(FormatK6, []byte(strings.Replace(body, "%s", test.options, 1)))
+			if err != nil {
+				t.Fatalf("Convert errored: %v", err)
+			}
+			var c configFile
+			json.Unmarshal(res.Config, &c)
+			c.Steps = nil
+			if !reflect.DeepEqual(c, test.expected) {
+				t.Errorf("Expected %+v, Found %+v", test.expected, c)
+			}
+			if len(res.Warnings) != 0 {
+				t.Errorf("Expected no warnings, Found %q", res.Warnings)
+			}
+		})
+	}
+}
+
+func TestConvertK6Statements(t *testing.T) {
+	t.Parallel()
+
+	src := `
+import http from 'k6/http';
+import { check } from 'k6';
+
+export function setup() {
+  return { token: 'x' };
+}
+
+export default function (data) {
+  for (let i = 0; i < 3; i++) {
+    http.get('https://test.k6.io/loop');
+  }
+  if (Math.random() > 0.5)
+    http.get('https://test.k6.io/maybe')
+  else {
+    http.get('https://test.k6.io/other')
+  }
+  const res = http.request('PUT', 'https://test.k6.io/items/1', 'a=b', {
+    headers: { 'X-Id': String(data.token) },
+    timeout: '1500ms',
+    redirects: 0,
+  })
+  check(res, { 'updated': function (r) { return r.status === 204; } })
+  http.batch([['GET', 'https://test.k6.io/a']]);
+}`
+	res, err := Convert(FormatK6, []byte(src))
+	if err != nil {
+		t.Fatalf("Convert errored: %v", err)
+	}
+
+	var c configFile
+	json.Unmarshal(res.Config, &c)
+	if len(c.Steps) != 1 {
+		t.Fatalf("Expected 1 step, Found %d", len(c.Steps))
+	}
+	expected := stepFile{Id: 1, Name: "PUT /items/1", Url: "https://test.k6.io/items/1", Method: "PUT",
+		Headers: map[string]string{"X-Id": "${String()}"}, Payload: "a=b", Timeout: 2}
+	if !reflect.DeepEqual(*c.Steps[0], expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, *c.Steps[0])
+	}
+	if c.SuccessCriteria != "steps.1.status_204_rate == 1" {
+		t.Errorf("Unexpected success criteria %s", c.SuccessCriteria)
+	}
+
+	expectedWarnings := []string{
+		"line 5: setup function is not translated",
+		"line 10: statement is not translated: for ( let i = 0",
+		"line 13: statement is not translated: if ( Math . random (",
+		"line 19: String() is not resolved, it is left as ${String()}",
+		"line 21: redirects param of the request is not translated",
+		"line 24: http.batch is not translated",
+		"iterations have no sleep or timer, each virtual user is assumed to run 1 iteration per second",
+	}
+	if !reflect.DeepEqual(res.Warnings, expectedWarnings) {
+		t.Errorf("Warnings Expected %q, Found %q", expectedWarnings, res.Warnings)
+	}
+}
+
+func TestConvertK6UnbalancedBrackets(t *testing.T) {
+	t.Parallel()
+
+	for _, bracket := range []string{")", "]", "}"} {
+		// Stray closing bracket is skipped as an untranslated statement, at the top level and in a function
+		src := "x " + bracket + "\nexport default function () {\n  http.get('https://test.k6.io/')\n  " + bracket +
+			"\n}"
+		done := make(chan struct{})
+		var res *Result
+		var err error
+		go func() {
+			res, err = Convert(FormatK6, []byte(src))
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: Convert should return on an unbalanced bracket", bracket)
+		}
+		if err != nil {
+			t.Fatalf("%s: Convert errored: %v", bracket, err)
+		}
+		var c configFile
+		json.Unmarshal(res.Config, &c)
+		if len(c.Steps) != 1 || c.Steps[0].Url != "https://test.k6.io/" {
+			t.Errorf("%s: Expected the request of the default function, Found %+v", bracket, c.Steps)
+		}
+		// Brace on the 4th line closes the function, the one after it is the stray
+		stray := "line 4: statement is not translated: " + bracket
+		if bracket == "}" {
+			stray = "line 5: statement is not translated: }"
+		}
+		if !strings.Contains(strings.Join(res.Warnings, "\n"), stray) {
+			t.Errorf("%s: Expected the warning %q, Found %q", bracket, stray, res.Warnings)
+		}
+	}
+}
+
+func TestConvertJMeterLoops(t *testing.T) {
+	t.Parallel()
+
+	src := `<?xml version="1.0" encoding="UTF-8"?>
+<jmeterTestPlan version="1.2">
+  <hashTree>
+    <TestPlan testname="Plan"/>
+    <hashTree>
+      <ThreadGroup testname="Users">
+        <elementProp name="ThreadGroup.main_controller" elementType="LoopController">
+          <boolProp name="LoopController.continue_forever">false</boolProp>
+          <intProp name="LoopController.loops">5</intProp>
+        </elementProp>
+        <stringProp name="ThreadGroup.num_threads">4</stringProp>
+        <stringProp name="ThreadGroup.ramp_time">2</stringProp>
+      </ThreadGroup>
+      <hashTree>
+        <HTTPSamplerProxy testname="Home">
+          <stringProp name="HTTPSampler.domain">example.com</stringProp>
+          <stringProp name="HTTPSampler.port">8080</stringProp>
+          <stringProp name="HTTPSampler.path">api</stringProp>
+          <stringProp name="HTTPSampler.method">post</stringProp>
+          <elementProp name="HTTPsampler.Arguments" elementType="Arguments">
+            <collectionProp name="Arguments.arguments">
+              <elementProp name="id" elementType="HTTPArgument">
+                <stringProp name="Argument.name">id</stringProp>
+                <stringProp name="Argument.value">${__UUID()}</stringProp>
+              </elementProp>
+            </collectionProp>
+          </elementProp>
+        </HTTPSamplerProxy>
+        <hashTree>
+          <UniformRandomTimer testname="Wait">
+            <stringProp name="ConstantTimer.delay">1000</stringProp>
+            <stringProp name="RandomTimer.range">2000</stringProp>
+          </UniformRandomTimer>
+          <hashTree/>
+        </hashTree>
+        <IfController testname="Maybe"/>
+        <hashTree>
+          <HTTPSamplerProxy testname="Next">
+            <stringProp name="HTTPSampler.path">https://other.example.com/next</stringProp>
+          </HTTPSamplerProxy>
+          <hashTree/>
+        </hashTree>
+      </hashTree>
+      <SetupThreadGroup testname="Setup"/>
+      <hashTree/>
+    </hashTree>
+  </hashTree>
+</jmeterTestPlan>`
+	res, err := Convert(FormatJMeter, []byte(src))
+	if err != nil {
+		t.Fatalf("Convert errored: %v", err)
+	}
+
+	var c configFile
+	json.Unmarshal(res.Config, &c)
+	if c.IterCount != 20 || c.Duration != 12 || c.LoadType != "linear" {
+		t.Errorf("Expected 20 iterations in 12s, Found %+v", c)
+	}
+	expected := []stepFile{
+		{Id: 1, Name: "Home", Url: "http://example.com:8080/api", Method: "POST",
+			Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, Payload: "id={{_randomUUID}}"},
+		{Id: 2, Name: "Next", Url: "https://other.example.com/next", Method: "GET"},
+	}
+	for i, e := range expected {
+		if !reflect.DeepEqual(*c.Steps[i], e) {
+			t.Errorf("Step %d Expected %+v, Found %+v", i, e, *c.Steps[i])
+		}
+	}
+	expectedWarnings := []string{
+		`SetupThreadGroup "Setup" is not translated`,
+		`IfController "Maybe" is converted as a simple controller, its samplers run once in order`,
+	}
+	if !reflect.DeepEqual(res.Warnings, expectedWarnings) {
+		t.Errorf("Warnings Expected %q, Found %q", expectedWarnings, res.Warnings)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format string
+		src    string
+	}{
+		{"UnknownFormat", "gatling", ""},
+		{"NoDefaultFunction", FormatK6, "import http from 'k6/http';\nexport const options = { vus: 1 };"},
+		{"NoRequest", FormatK6, "export default function () { console.log('hi') }"},
+		{"UnterminatedString", FormatK6, "export default function () { http.get('https://a.com) }"},
+		{"InvalidXML", FormatJMeter, "<jmeterTestPlan><hashTree>"},
+		{"NotATestPlan", FormatJMeter, "<project/>"},
+		{"NoThreadGroup", FormatJMeter, "<jmeterTestPlan><hashTree><TestPlan/><hashTree/></hashTree></jmeterTestPlan>"},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := Convert(test.format, []byte(test.src)); err == nil {
+				t.Errorf("Should be errored")
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"script.js", FormatK6},
+		{"tests/Plan.JMX", FormatJMeter},
+		{"config.json", ""},
+	}
+
+	for _, test := range tests {
+		format, err := DetectFormat(test.path)
+		if format != test.expected || (err != nil) != (test.expected == "") {
+			t.Errorf("%s: Expected %q, Found %q, err: %v", test.path, test.expected, format, err)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package converter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JMeter variable references and function calls like ${host} and ${__P(users,10)}.
+var jmeterVarRegexp = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+var jmeterPropertyRegexp = regexp.MustCompile(`^__(?:P|property)\(([^,)]*)(?:,([^)]*))?\)$`)
+
+// JMeter functions with the equivalent dynamic variables.
+var jmeterFunctions = map[string]string{
+	"__UUID":   "{{_randomUUID}}",
+	"__UUID()": "{{_randomUUID}}",
+	"__time":   "{{_timestamp}}",
+	"__time()": "{{_timestamp}}",
+}
+
+// Bits of the Assertion.test_type of the response assertions.
+const (
+	jmeterAssertMatch     = 1
+	jmeterAssertContains  = 2
+	jmeterAssertNot       = 4
+	jmeterAssertEquals    = 8
+	jmeterAssertSubstring = 16
+)
+
+// jmxElement is an element of a JMX file. The test elements are followed by a hashTree element of their children.
+type jmxElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr   `xml:",any,attr"`
+	Text    string       `xml:",chardata"`
+	Nodes   []jmxElement `xml:",any"`
+}
+
+func (e *jmxElement) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// prop returns the property of the element, like <stringProp name="HTTPSampler.path">.
+func (e *jmxElement) prop(name string) *jmxElement {
+	for i := range e.Nodes {
+		if e.Nodes[i].attr("name") == name {
+			return &e.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func (e *jmxElement) str(name string) string {
+	if p := e.prop(name); p != nil {
+		return p.Text
+	}
+	return ""
+}
+
+func (e *jmxElement) boolean(name string) bool {
+	return strings.TrimSpace(e.str(name)) == "true"
+}
+
+// collection returns the items of a collection property, like the headers of a header manager.
+func (e *jmxElement) collection(name string) []jmxElement {
+	if p := e.prop(name); p != nil {
+		return p.Nodes
+	}
+	return nil
+}
+
+func (e *jmxElement) describe() string {
+	return fmt.Sprintf("%s %q", e.XMLName.Local, e.attr("testname"))
+}
+
+// jmxItem is a test element with the hashTree of its children.
+type jmxItem struct {
+	elem *jmxElement
+	tree *jmxElement
+}
+
+func (i jmxItem) children() []jmxItem {
+	if i.tree == nil {
+		return nil
+	}
+	return jmxItems(i.tree)
+}
+
+// jmxItems returns the enabled test elements of the hashTree.
+func jmxItems(tree *jmxElement) (items []jmxItem) {
+	for i := 0; i < len(tree.Nodes); i++ {
+		n := &tree.Nodes[i]
+		if n.XMLName.Local == "hashTree" {
+			continue
+		}
+		item := jmxItem{elem: n}
+		if i+1 < len(tree.Nodes) && tree.Nodes[i+1].XMLName.Local == "hashTree" {
+			item.tree = &tree.Nodes[i+1]
+			i++
+		}
+		if n.attr("enabled") != "false" {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+// jmxScope is the configuration applied to the samplers of a controller, the timers delay each sampler of
+// their scope.
+type jmxScope struct {
+	protocol, domain, port string
+	timeout                string
+	headers                map[string]string
+	timers                 [][2]float64
+	assertions             []*jmxElement
+}
+
+func (s jmxScope) child() jmxScope {
+	c := s
+	c.headers = make(map[string]string, len(s.headers))
+	for k, v := range s.headers {
+		c.headers[k] = v
+	}
+	c.timers = append([][2]float64{}, s.timers...)
+	c.assertions = append([]*jmxElement{}, s.assertions...)
+	return c
+}
+
+// jmeterConverter translates the first thread group of a JMeter test plan.
+type jmeterConverter struct {
+	w      *warnings
+	config *configFile
+
+	// User defined variables
+	vars map[string]string
+
+	checks []statusCheck
+	sleeps sleepPlan
+
+	// Total delay of the timers of an iteration in seconds, the midpoints of the random timers
+	iterationSleep float64
+}
+
+func convertJMeter(src []byte, w *warnings) (*configFile, error) {
+	var root jmxElement
+	if err := xml.Unmarshal(src, &root); err != nil {
+		return nil, fmt.Errorf("jmx file is not valid: %v", err)
+	}
+	if root.XMLName.Local != "jmeterTestPlan" {
+		return nil, fmt.Errorf("jmx file is not a jmeter test plan")
+	}
+
+	c := &jmeterConverter{
+		w:      w,
+		config: &configFile{},
+		vars:   make(map[string]string),
+		sleeps: make(sleepPlan),
+	}
+
+	var plan *jmxItem
+	for i := range root.Nodes {
+		if root.Nodes[i].XMLName.Local != "hashTree" {
+			continue
+		}
+		for _, item := range jmxItems(&root.Nodes[i]) {
+			if item.elem.XMLName.Local == "TestPlan" {
+				plan = &item
+				break
+			}
+		}
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("test plan element is not found")
+	}
+	c.variables(plan.elem.prop("TestPlan.user_defined_variables"))
+
+	// Config elements of the test plan apply to all the thread groups regardless of their positions
+	scope := jmxScope{headers: make(map[string]string)}
+	var groups []jmxItem
+	for _, item := range plan.children() {
+		if strings.Contains(item.elem.XMLName.Local, "ThreadGroup") {
+			groups = append(groups, item)
+		} else {
+			c.configElement(item, &scope)
+		}
+	}
+
+	var group *jmxItem
+	for i, g := range groups {
+		switch {
+		case g.elem.XMLName.Local != "ThreadGroup":
+			w.add("%s is not translated", g.elem.describe())
+		case group == nil:
+			group = &groups[i]
+		default:
+			w.add("%s is not translated, only the first thread group is converted", g.elem.describe())
+		}
+	}
+	if group == nil {
+		return nil, fmt.Errorf("thread group is not found")
+	}
+
+	c.controller(*group, scope.child())
+	c.sleeps.apply()
+	c.load(group.elem)
+	c.config.addCriteria(statusClauses(c.checks, "")...)
+	return c.config, nil
+}
+
+// variables defines the user defined variables, the values can refer to the variables defined before.
+func (c *jmeterConverter) variables(args *jmxElement) {
+	if args == nil {
+		return
+	}
+	for _, arg := range args.collection("Arguments.arguments") {
+		c.vars[arg.str("Argument.name")] = c.resolve(arg.str("Argument.value"))
+	}
+}
+
+// configElement applies a config element, a timer or an assertion to the scope, reports the others.
+func (c *jmeterConverter) configElement(item jmxItem, scope *jmxScope) {
+	e := item.elem
+	switch name := e.XMLName.Local; {
+	case name == "Arguments":
+		c.variables(e)
+	case name == "ConfigTestElement" && e.attr("guiclass") == "HttpDefaultsGui":
+		for _, field := range []struct {
+			dst  *string
+			name string
+		}{
+			{&scope.protocol, "HTTPSampler.protocol"},
+			{&scope.domain, "HTTPSampler.domain"},
+			{&scope.port, "HTTPSampler.port"},
+			{&scope.timeout, "HTTPSampler.response_timeout"},
+		} {
+			if v := strings.TrimSpace(c.resolve(e.str(field.name))); v != "" {
+				*field.dst = v
+			}
+		}
+		if args := e.collection("HTTPsampler.Arguments"); len(args) > 0 {
+			c.w.add("parameters of %s are not translated", e.describe())
+		}
+	case name == "HeaderManager":
+		for _, h := range e.collection("HeaderManager.headers") {
+			scope.headers[c.resolve(h.str("Header.name"))] = c.resolve(h.str("Header.value"))
+		}
+	case name == "ConstantTimer":
+		delay := c.milliseconds(e, "ConstantTimer.delay")
+		scope.timers = append(scope.timers, [2]float64{delay, delay})
+	case name == "UniformRandomTimer":
+		offset := c.milliseconds(e, "ConstantTimer.delay")
+		scope.timers = append(scope.timers, [2]float64{offset, offset + c.milliseconds(e, "RandomTimer.range")})
+	case name == "ResponseAssertion":
+		scope.assertions = append(scope.assertions, e)
+	case name == "ResultCollector" || name == "BackendListener":
+		// Listeners only report the results
+	default:
+		c.w.add("%s is not translated", e.describe())
+	}
+}
+
+// controller translates the samplers of a thread group or a controller in order.
+func (c *jmeterConverter) controller(item jmxItem, scope jmxScope) {
+	children := item.children()
+	for _, child := range children {
+		if !isJMeterSampler(child.elem) && !isJMeterController(child.elem) {
+			c.configElement(child, &scope)
+		}
+	}
+
+	for _, child := range children {
+		e := child.elem
+		switch {
+		case isJMeterSampler(e):
+			c.sampler(child, scope.child())
+		case isJMeterController(e):
+			if n := e.XMLName.Local; n != "GenericController" && n != "TransactionController" {
+				c.w.add("%s is converted as a simple controller, its samplers run once in order", e.describe())
+			}
+			c.controller(child, scope.child())
+		}
+	}
+}
+
+func isJMeterSampler(e *jmxElement) bool {
+	return e.XMLName.Local == "HTTPSamplerProxy" || e.XMLName.Local == "HTTPSampler"
+}
+
+func isJMeterController(e *jmxElement) bool {
+	return strings.HasSuffix(e.XMLName.Local, "Controller")
+}
+
+// sampler translates an HTTP sampler to a step. The timers of the sampler delay it, so they are converted to
+// the sleep of the previous step.
+func (c *jmeterConverter) sampler(item jmxItem, scope jmxScope) {
+	for _, child := range item.children() {
+		c.configElement(child, &scope)
+	}
+
+	e := item.elem
+	method := strings.ToUpper(strings.TrimSpace(e.str("HTTPSampler.method")))
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var min, max float64
+	for _, t := range scope.timers {
+		min, max = min+t[0]/1000, max+t[1]/1000
+	}
+	c.iterationSleep += (min + max) / 2
+	if steps := c.config.Steps; len(steps) > 0 && max > 0 {
+		c.sleeps.add(steps[len(steps)-1], min, max)
+	}
+
+	step := c.config.addStep(e.attr("testname"), method, c.url(e, scope))
+	for k, v := range scope.headers {
+		step.setHeader(k, v)
+	}
+	c.arguments(e, step)
+	if timeout := strings.TrimSpace(c.resolve(e.str("HTTPSampler.response_timeout"))); timeout != "" {
+		scope.timeout = timeout
+	}
+	if ms, err := strconv.ParseFloat(scope.timeout, 64); err == nil && ms > 0 {
+		step.Timeout = ceilSeconds(ms / 1000)
+	}
+	if files := e.collection("HTTPsampler.Files"); len(files) > 0 && len(files[0].Nodes) > 0 {
+		c.w.add("file upload of %s is not translated", e.describe())
+	}
+
+	for _, a := range scope.assertions {
+		c.assertion(a, step)
+	}
+}
+
+func (c *jmeterConverter) url(e *jmxElement, scope jmxScope) string {
+	path := strings.TrimSpace(c.resolve(e.str("HTTPSampler.path")))
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+
+	value := func(name, def string) string {
+		if v := strings.TrimSpace(c.resolve(e.str(name))); v != "" {
+			return v
+		}
+		return def
+	}
+	protocol := strings.ToLower(value("HTTPSampler.protocol", scope.protocol))
+	if protocol == "" {
+		protocol = "http"
+	}
+	host := value("HTTPSampler.domain", scope.domain)
+	if host == "" {
+		c.w.add("server name of %s is not found", e.describe())
+	}
+	if port := value("HTTPSampler.port", scope.port); port != "" &&
+		!(protocol == "http" && port == "80" || protocol == "https" && port == "443") {
+		host += ":" + port
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return protocol + "://" + host + path
+}
+
+// arguments translates the parameters of the sampler to the query of the url or to an url encoded form body,
+// the raw body is the value of the single argument.
+func (c *jmeterConverter) arguments(e *jmxElement, step *stepFile) {
+	args := e.prop("HTTPsampler.Arguments")
+	if args == nil {
+		return
+	}
+	list := args.collection("Arguments.arguments")
+	if e.boolean("HTTPSampler.postBodyRaw") {
+		if len(list) > 0 {
+			step.Payload = c.resolve(list[0].str("Argument.value"))
+		}
+		return
+	}
+	if len(list) == 0 {
+		return
+	}
+
+	pairs := make([]string, len(list))
+	for i, arg := range list {
+		name, value := c.resolve(arg.str("Argument.name")), c.resolve(arg.str("Argument.value"))
+		if arg.boolean("HTTPArgument.always_encode") {
+			name, value = url.QueryEscape(name), url.QueryEscape(value)
+		}
+		pairs[i] = name + "=" + value
+	}
+	query := strings.Join(pairs, "&")
+
+	switch step.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+		if strings.Contains(step.Url, "?") {
+			step.Url += "&" + query
+		} else {
+			step.Url += "?" + query
+		}
+	default:
+		step.Payload = query
+		if !hasHeader(step.Headers, "Content-Type") {
+			step.setHeader("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+}
+
+// assertion translates the response code assertions with a single code to the success criteria.
+func (c *jmeterConverter) assertion(a *jmxElement, step *stepFile) {
+	testType, _ := strconv.Atoi(strings.TrimSpace(a.str("Assertion.test_type")))
+	strs := a.collection("Asserion.test_strings")
+	if strings.TrimSpace(a.str("Assertion.test_field")) != "Assertion.response_code" ||
+		testType&jmeterAssertNot != 0 ||
+		testType&(jmeterAssertMatch|jmeterAssertContains|jmeterAssertEquals|jmeterAssertSubstring) == 0 ||
+		len(strs) != 1 {
+		c.w.add("%s of %q is not translated", a.describe(), step.Name)
+		return
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(c.resolve(strs[0].Text)))
+	if err != nil {
+		c.w.add("%s of %q is not translated", a.describe(), step.Name)
+		return
+	}
+	c.checks = append(c.checks, statusCheck{step: step.Id, code: code})
+}
+
+// load sets the load of the config from the thread group. The threads run the iterations one after another,
+// so their iteration rates are estimated from the timers of an iteration.
+func (c *jmeterConverter) load(g *jmxElement) {
+	threads := c.number(g, "ThreadGroup.num_threads", 1)
+	ramp := c.number(g, "ThreadGroup.ramp_time", 0)
+	duration := 0.0
+	if g.boolean("ThreadGroup.scheduler") {
+		duration = c.number(g, "ThreadGroup.duration", 0)
+		if c.number(g, "ThreadGroup.delay", 0) > 0 {
+			c.w.add("startup delay of %s is not translated", g.describe())
+		}
+	}
+	loops := -1.0
+	if lc := g.prop("ThreadGroup.main_controller"); lc != nil && !lc.boolean("LoopController.continue_forever") {
+		loops = c.number(lc, "LoopController.loops", 1)
+	}
+
+	threadRate := iterationRate(c.iterationSleep, c.w)
+	rate := threads * threadRate
+	switch {
+	case duration > 0:
+		if loops > 0 {
+			c.w.add("loop count of %s is not translated, the duration is used", g.describe())
+		}
+		if ramp > 0 && ramp < duration {
+			c.config.setLoad([]loadStage{
+				{seconds: ceilSeconds(ramp), from: 0, to: rate},
+				{seconds: ceilSeconds(duration - ramp), from: rate, to: rate},
+			})
+		} else {
+			c.config.setLoad([]loadStage{{seconds: ceilSeconds(duration), from: rate, to: rate}})
+		}
+	case loops > 0:
+		c.config.setIterations(int(threads*loops), ceilSeconds(ramp+loops/threadRate))
+	default:
+		c.w.add("%s runs until it is stopped, %ds is used", g.describe(), defaultDuration)
+		c.config.setLoad([]loadStage{{seconds: defaultDuration, from: rate, to: rate}})
+	}
+}
+
+func (c *jmeterConverter) number(e *jmxElement, name string, def float64) float64 {
+	s := strings.TrimSpace(c.resolve(e.str(name)))
+	if s == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		c.w.add("%s of %s is not a number, %g is used", name, e.describe(), def)
+		return def
+	}
+	return f
+}
+
+func (c *jmeterConverter) milliseconds(e *jmxElement, name string) float64 {
+	ms := c.number(e, name, 0)
+	if ms < 0 {
+		return 0
+	}
+	return ms
+}
+
+// resolve replaces the variables of the text. The property functions are replaced with their default values,
+// the references that can't be resolved are left as they are and reported.
+func (c *jmeterConverter) resolve(s string) string {
+	return jmeterVarRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		if v, ok := c.vars[name]; ok {
+			return v
+		}
+		if v, ok := jmeterFunctions[name]; ok {
+			return v
+		}
+		if m := jmeterPropertyRegexp.FindStringSubmatch(name); m != nil && m[2] != "" {
+			c.w.add("property %s is not available, its default value %s is used", m[1], m[2])
+			return m[2]
+		}
+		c.w.add("%s is not resolved, it is left as is", ref)
+		return ref
+	})
+}
@@ -0,0 +1,927 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The k6 scripts are read by a constrained JavaScript parser, not a runtime. It understands the declarations,
+// the calls, the object and array literals and the functions, which are enough for the options and the requests
+// of the common scripts. The other statements are parsed as opaque and reported by the converter.
+
+type jsTokenKind int
+
+const (
+	jsEOF jsTokenKind = iota
+	jsIdent
+	jsNumber
+	jsString
+	jsTemplate
+	jsPunct
+)
+
+type jsToken struct {
+	kind jsTokenKind
+
+	// Name of the identifiers, punctuator, number literal, value of the strings and raw content of the templates
+	text string
+	line int
+
+	// Token is the first one of its line
+	newline bool
+}
+
+// Punctuators of more than one character, the longest ones first.
+var jsPuncts = []string{
+	"===", "!==", "**=", "...", "&&=", "||=", "??=",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--", "+=", "-=", "*=", "/=", "%=", "**",
+}
+
+func tokenizeJS(src string) ([]jsToken, error) {
+	var toks []jsToken
+	line, newline := 1, true
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			newline = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			comment := src[i : i+2+end+2]
+			if n := strings.Count(comment, "\n"); n > 0 {
+				line += n
+				newline = true
+			}
+			i += len(comment)
+			continue
+		}
+
+		t := jsToken{line: line, newline: newline}
+		newline = false
+		switch {
+		case c == '"' || c == '\'':
+			s, n, err := unquoteJS(src[i:], line)
+			if err != nil {
+				return nil, err
+			}
+			t.kind, t.text = jsString, s
+			i += n
+		case c == '`':
+			j := i + 1
+			for j < len(src) && src[j] != '`' {
+				if src[j] == '\\' {
+					j++
+				} else if src[j] == '\n' {
+					line++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated template literal", t.line)
+			}
+			t.kind, t.text = jsTemplate, src[i+1:j]
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (isJSIdentPart(rune(src[j])) || src[j] == '.' ||
+				(src[j] == '+' || src[j] == '-') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+			t.kind, t.text = jsNumber, src[i:j]
+			i = j
+		default:
+			r, size := utf8.DecodeRuneInString(src[i:])
+			if isJSIdentStart(r) {
+				j := i + size
+				for j < len(src) {
+					r, size := utf8.DecodeRuneInString(src[j:])
+					if !isJSIdentPart(r) {
+						break
+					}
+					j += size
+				}
+				t.kind, t.text = jsIdent, src[i:j]
+				i = j
+				break
+			}
+			t.kind, t.text = jsPunct, string(c)
+			for _, p := range jsPuncts {
+				if strings.HasPrefix(src[i:], p) {
+					t.text = p
+					break
+				}
+			}
+			i += len(t.text)
+		}
+		toks = append(toks, t)
+	}
+	return append(toks, jsToken{kind: jsEOF, line: line, newline: true}), nil
+}
+
+func isJSIdentStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+func isJSIdentPart(r rune) bool {
+	return isJSIdentStart(r) || unicode.IsDigit(r)
+}
+
+// unquoteJS reads the string literal at the start of s, returns its value and its length in s.
+func unquoteJS(s string, line int) (string, int, error) {
+	quote := s[0]
+	b := strings.Builder{}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			return "", 0, fmt.Errorf("line %d: unterminated string", line)
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'u':
+				if i+4 < len(s) {
+					if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+						b.WriteRune(rune(r))
+						i += 4
+						continue
+					}
+				}
+				b.WriteByte('u')
+			case '\n':
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("line %d: unterminated string", line)
+}
+
+type jsNodeKind int
+
+const (
+	jsLiteral jsNodeKind = iota
+	jsTemplateLiteral
+	jsObject
+	jsArray
+	jsRef
+	jsIndex
+	jsCall
+	jsFunc
+	jsBinary
+	jsOpaque
+)
+
+// jsNode is a node of the expression tree.
+type jsNode struct {
+	kind jsNodeKind
+	line int
+
+	// Value of the literals; a string, a float64, a bool or nil.
+	// Raw content of the templates.
+	value interface{}
+
+	// Keys and values of the objects in the source order, items of the arrays, arguments of the calls
+	keys  []string
+	items []*jsNode
+
+	// Object of the references, indexes and calls, left operand of the binary expressions.
+	// Plain identifiers have no object.
+	object *jsNode
+	name   string
+
+	// Right operand of the binary expressions, the index of the index expressions, the expression body of the
+	// arrow functions
+	right *jsNode
+
+	// Parameters and the block body of the functions
+	params []string
+	body   []*jsStmt
+}
+
+// path returns the dotted path of the plain references like "http.get", empty for the other nodes.
+func (n *jsNode) path() string {
+	if n == nil || n.kind != jsRef {
+		return ""
+	}
+	if n.object == nil {
+		return n.name
+	}
+	if p := n.object.path(); p != "" {
+		return p + "." + n.name
+	}
+	return ""
+}
+
+// prop returns the value of the key of an object node, nil if the node is not an object or it has no such key.
+func (n *jsNode) prop(key string) *jsNode {
+	if n == nil || n.kind != jsObject {
+		return nil
+	}
+	for i, k := range n.keys {
+		if k == key {
+			return n.items[i]
+		}
+	}
+	return nil
+}
+
+type jsStmtKind int
+
+const (
+	jsStmtExpr jsStmtKind = iota
+	jsStmtVar
+	jsStmtFunc
+	jsStmtImport
+	jsStmtReturn
+	jsStmtOther
+)
+
+type jsStmt struct {
+	kind jsStmtKind
+	line int
+
+	// Name of the declared variables and functions, module of the imports
+	name string
+	expr *jsNode
+
+	exported  bool
+	isDefault bool
+
+	// First words of the statement for the warnings
+	summary string
+}
+
+// jsSyntaxError aborts the parsing of the current statement, the statement is skipped as opaque.
+type jsSyntaxError struct {
+	line int
+	msg  string
+}
+
+type jsParser struct {
+	toks []jsToken
+	pos  int
+}
+
+func parseJS(src string) ([]*jsStmt, error) {
+	toks, err := tokenizeJS(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &jsParser{toks: toks}
+	return p.statements(false), nil
+}
+
+// parseJSExpression parses the expression of a template placeholder, line is the line of the template.
+func parseJSExpression(src string, line int) (n *jsNode, err error) {
+	toks, err := tokenizeJS(src)
+	if err != nil {
+		return nil, err
+	}
+	for i := range toks {
+		toks[i].line += line - 1
+	}
+
+	p := &jsParser{toks: toks}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(jsSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			n, err = nil, fmt.Errorf("line %d: %s", e.line, e.msg)
+		}
+	}()
+	n = p.expression()
+	if t := p.peek(); t.kind != jsEOF {
+		p.fail("unexpected %s", tokenText(t))
+	}
+	return n, nil
+}
+
+// templateParts splits the raw content of a template literal into the texts and the placeholder expressions
+// between them. Texts are at the even indexes, the escape sequences of the texts are resolved.
+func templateParts(raw string) []string {
+	var parts []string
+	b := strings.Builder{}
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(raw[i])
+			}
+		case c == '$' && i+1 < len(raw) && raw[i+1] == '{':
+			depth, j := 1, i+2
+			for ; j < len(raw) && depth > 0; j++ {
+				if raw[j] == '{' {
+					depth++
+				} else if raw[j] == '}' {
+					depth--
+				}
+			}
+			parts = append(parts, b.String(), raw[i+2:j-1])
+			b.Reset()
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(parts, b.String())
+}
+
+func (p *jsParser) peek() jsToken {
+	return p.toks[p.pos]
+}
+
+func (p *jsParser) peekAt(offset int) jsToken {
+	if p.pos+offset >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.pos+offset]
+}
+
+func (p *jsParser) next() jsToken {
+	t := p.toks[p.pos]
+	if t.kind != jsEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *jsParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == jsPunct || t.kind == jsIdent) && t.text == text
+}
+
+func (p *jsParser) accept(text string) bool {
+	if p.is(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *jsParser) expect(text string) {
+	if !p.accept(text) {
+		p.fail("%s is expected", text)
+	}
+}
+
+func (p *jsParser) fail(format string, args ...interface{}) {
+	panic(jsSyntaxError{line: p.peek().line, msg: fmt.Sprintf(format, args...)})
+}
+
+// statements parses the statements until the end of the source, or the closing brace of the block.
+func (p *jsParser) statements(block bool) []*jsStmt {
+	var stmts []*jsStmt
+	for {
+		t := p.peek()
+		if t.kind == jsEOF || block && t.kind == jsPunct && t.text == "}" {
+			return stmts
+		}
+		stmts = append(stmts, p.statement()...)
+	}
+}
+
+// statement parses the next statement, an unsupported one is skipped and returned as jsStmtOther.
+func (p *jsParser) statement() (stmts []*jsStmt) {
+	start := p.pos
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(jsSyntaxError); !ok {
+				panic(r)
+			}
+			p.pos = start
+			stmts = []*jsStmt{p.opaqueStatement()}
+		}
+	}()
+
+	t := p.peek()
+	if p.accept(";") {
+		return nil
+	}
+
+	switch {
+	case t.kind == jsIdent && t.text == "import":
+		p.next()
+		s := &jsStmt{kind: jsStmtImport, line: t.line}
+		for !p.is(";") && p.peek().kind != jsEOF {
+			if tok := p.next(); tok.kind == jsString {
+				s.name = tok.text
+				break
+			}
+		}
+		p.accept(";")
+		return []*jsStmt{s}
+	case t.kind == jsIdent && t.text == "export":
+		p.next()
+		isDefault := p.accept("default")
+		var inner []*jsStmt
+		if isDefault && !p.is("function") && !p.is("async") {
+			e := p.expression()
+			p.endStatement()
+			inner = []*jsStmt{{kind: jsStmtExpr, line: t.line, expr: e}}
+		} else {
+			inner = p.statement()
+		}
+		for _, s := range inner {
+			s.exported, s.isDefault = true, isDefault
+			s.line = t.line
+		}
+		return inner
+	case t.kind == jsIdent && (t.text == "const" || t.text == "let" || t.text == "var"):
+		p.next()
+		for {
+			name := p.next()
+			if name.kind != jsIdent {
+				p.fail("destructuring is not supported")
+			}
+			s := &jsStmt{kind: jsStmtVar, line: name.line, name: name.text}
+			if p.accept("=") {
+				s.expr = p.assignment()
+			}
+			stmts = append(stmts, s)
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.endStatement()
+		return stmts
+	case t.kind == jsIdent && t.text == "return":
+		p.next()
+		s := &jsStmt{kind: jsStmtReturn, line: t.line}
+		if next := p.peek(); !next.newline && next.kind != jsEOF && next.text != ";" && next.text != "}" {
+			s.expr = p.expression()
+		}
+		p.endStatement()
+		return []*jsStmt{s}
+	case t.kind == jsIdent && (t.text == "function" || t.text == "async" && p.peekAt(1).text == "function"):
+		p.accept("async")
+		f := p.function()
+		return []*jsStmt{{kind: jsStmtFunc, line: t.line, name: f.name, expr: f}}
+	case t.kind == jsIdent && isJSKeyword(t.text), t.kind == jsPunct && t.text == "{":
+		return []*jsStmt{p.opaqueStatement()}
+	}
+
+	e := p.expression()
+	p.endStatement()
+	return []*jsStmt{{kind: jsStmtExpr, line: t.line, expr: e}}
+}
+
+func isJSKeyword(s string) bool {
+	switch s {
+	case "if", "else", "for", "while", "do", "switch", "try", "throw", "class", "break", "continue":
+		return true
+	}
+	return false
+}
+
+// endStatement consumes the semicolon of the statement, the statements can also end with a line break.
+func (p *jsParser) endStatement() {
+	if p.accept(";") {
+		return
+	}
+	t := p.peek()
+	if t.kind == jsEOF || t.newline || t.kind == jsPunct && t.text == "}" {
+		return
+	}
+	p.fail("unexpected %s", t.text)
+}
+
+// opaqueStatement skips the tokens of the statement, balancing the brackets.
+func (p *jsParser) opaqueStatement() *jsStmt {
+	first := p.peek()
+	s := &jsStmt{kind: jsStmtOther, line: first.line}
+	var words []string
+
+	// The body of if, for and while can start on the next line of their headers
+	depth := 0
+	headerParen, awaitBody := false, false
+	for {
+		t := p.peek()
+		if t.kind == jsEOF {
+			break
+		}
+		if depth == 0 && t.newline && len(words) > 0 && !awaitBody {
+			prev := p.toks[p.pos-1]
+			continued := t.kind == jsIdent && (t.text == "else" || t.text == "catch" || t.text == "finally" ||
+				t.text == "while" && first.text == "do") ||
+				t.kind == jsPunct && (t.text == "." || t.text == "?.") ||
+				prev.kind == jsIdent && (prev.text == "else" || prev.text == "do")
+			ended := prev.kind != jsPunct || prev.text == "}" || prev.text == ")" || prev.text == "]"
+			if ended && !continued {
+				break
+			}
+		}
+		awaitBody = false
+		if t.kind == jsPunct {
+			switch t.text {
+			case "(":
+				if depth == 0 && p.pos > 0 {
+					prev := p.toks[p.pos-1]
+					headerParen = prev.kind == jsIdent && (prev.text == "if" || prev.text == "for" || prev.text == "while")
+				}
+				depth++
+			case "[", "{":
+				depth++
+			case ")", "]", "}":
+				// Closing bracket of the enclosing block ends the statement, a stray one is skipped as a statement
+				if depth == 0 {
+					if len(words) == 0 {
+						p.next()
+						words = append(words, t.text)
+					}
+					s.summary = strings.Join(words, " ")
+					return s
+				}
+				depth--
+				if depth == 0 && headerParen && t.text == ")" {
+					headerParen, awaitBody = false, true
+				}
+			case ";":
+				if depth == 0 {
+					p.next()
+					s.summary = strings.Join(words, " ")
+					return s
+				}
+			}
+		}
+		if len(words) < 6 {
+			words = append(words, tokenText(t))
+		}
+		p.next()
+	}
+	s.summary = strings.Join(words, " ")
+	return s
+}
+
+func tokenText(t jsToken) string {
+	switch t.kind {
+	case jsString:
+		return strconv.Quote(t.text)
+	case jsTemplate:
+		return "`" + t.text + "`"
+	}
+	return t.text
+}
+
+// Binary operator precedences, the assignments are parsed by assignment().
+var jsPrecedence = map[string]int{
+	"??": 1, "||": 2, "&&": 3,
+	"==": 4, "!=": 4, "===": 4, "!==": 4,
+	"<": 5, ">": 5, "<=": 5, ">=": 5, "instanceof": 5, "in": 5,
+	"+": 6, "-": 6,
+	"*": 7, "/": 7, "%": 7, "**": 8,
+}
+
+func (p *jsParser) expression() *jsNode {
+	n := p.assignment()
+	for p.accept(",") {
+		n = &jsNode{kind: jsOpaque, line: n.line, object: n, right: p.assignment()}
+	}
+	return n
+}
+
+func (p *jsParser) assignment() *jsNode {
+	n := p.conditional()
+	switch t := p.peek(); t.text {
+	case "=", "+=", "-=", "*=", "/=", "%=", "**=", "&&=", "||=", "??=":
+		if t.kind == jsPunct {
+			p.next()
+			return &jsNode{kind: jsOpaque, line: n.line, object: n, right: p.assignment()}
+		}
+	}
+	return n
+}
+
+func (p *jsParser) conditional() *jsNode {
+	n := p.binary(1)
+	if p.accept("?") {
+		then := p.assignment()
+		p.expect(":")
+		return &jsNode{kind: jsOpaque, line: n.line, object: then, right: p.assignment()}
+	}
+	return n
+}
+
+func (p *jsParser) binary(minPrec int) *jsNode {
+	left := p.unary()
+	for {
+		t := p.peek()
+		prec, ok := jsPrecedence[t.text]
+		if !ok || t.kind == jsString || t.kind == jsTemplate || prec < minPrec {
+			return left
+		}
+		p.next()
+		right := p.binary(prec + 1)
+		left = &jsNode{kind: jsBinary, line: left.line, name: t.text, object: left, right: right}
+	}
+}
+
+func (p *jsParser) unary() *jsNode {
+	t := p.peek()
+	if t.kind == jsPunct && (t.text == "!" || t.text == "-" || t.text == "+" || t.text == "~" ||
+		t.text == "++" || t.text == "--") ||
+		t.kind == jsIdent && (t.text == "typeof" || t.text == "void" || t.text == "delete" ||
+			t.text == "await" || t.text == "new") {
+		p.next()
+		operand := p.unary()
+		if t.text == "-" && operand.kind == jsLiteral {
+			if f, ok := operand.value.(float64); ok {
+				return &jsNode{kind: jsLiteral, line: t.line, value: -f}
+			}
+		}
+		return &jsNode{kind: jsOpaque, line: t.line, object: operand}
+	}
+	return p.postfix()
+}
+
+func (p *jsParser) postfix() *jsNode {
+	n := p.primary()
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == jsPunct && (t.text == "." || t.text == "?."):
+			p.next()
+			name := p.next()
+			if name.kind != jsIdent {
+				p.fail("property name is expected")
+			}
+			n = &jsNode{kind: jsRef, line: n.line, object: n, name: name.text}
+		case t.kind == jsPunct && t.text == "[":
+			p.next()
+			index := p.expression()
+			p.expect("]")
+			n = &jsNode{kind: jsIndex, line: n.line, object: n, right: index}
+		case t.kind == jsPunct && t.text == "(":
+			p.next()
+			n = &jsNode{kind: jsCall, line: n.line, object: n, items: p.arguments(")")}
+		case t.kind == jsTemplate:
+			// Tagged templates
+			p.next()
+			n = &jsNode{kind: jsOpaque, line: n.line, object: n}
+		case t.kind == jsPunct && (t.text == "++" || t.text == "--") && !t.newline:
+			p.next()
+			n = &jsNode{kind: jsOpaque, line: n.line, object: n}
+		default:
+			return n
+		}
+	}
+}
+
+// arguments parses the comma separated expressions until the closing bracket.
+func (p *jsParser) arguments(closing string) []*jsNode {
+	var args []*jsNode
+	for !p.accept(closing) {
+		if p.accept("...") {
+			args = append(args, &jsNode{kind: jsOpaque, line: p.peek().line, object: p.assignment()})
+		} else {
+			args = append(args, p.assignment())
+		}
+		if !p.accept(",") {
+			p.expect(closing)
+			break
+		}
+	}
+	return args
+}
+
+func (p *jsParser) primary() *jsNode {
+	t := p.peek()
+	switch t.kind {
+	case jsNumber:
+		p.next()
+		f, err := parseJSNumber(t.text)
+		if err != nil {
+			p.fail("invalid number %s", t.text)
+		}
+		return &jsNode{kind: jsLiteral, line: t.line, value: f}
+	case jsString:
+		p.next()
+		return &jsNode{kind: jsLiteral, line: t.line, value: t.text}
+	case jsTemplate:
+		p.next()
+		return &jsNode{kind: jsTemplateLiteral, line: t.line, value: t.text}
+	case jsIdent:
+		switch t.text {
+		case "true", "false":
+			p.next()
+			return &jsNode{kind: jsLiteral, line: t.line, value: t.text == "true"}
+		case "null", "undefined":
+			p.next()
+			return &jsNode{kind: jsLiteral, line: t.line}
+		case "function":
+			return p.function()
+		case "async":
+			if next := p.peekAt(1); next.text == "function" || next.text == "(" || next.kind == jsIdent {
+				p.next()
+				return p.primary()
+			}
+		}
+		if p.peekAt(1).kind == jsPunct && p.peekAt(1).text == "=>" {
+			p.next()
+			p.next()
+			return p.arrowBody(t.line, []string{t.text})
+		}
+		p.next()
+		return &jsNode{kind: jsRef, line: t.line, name: t.text}
+	case jsPunct:
+		switch t.text {
+		case "(":
+			if params, ok := p.arrowParams(); ok {
+				return p.arrowBody(t.line, params)
+			}
+			p.next()
+			n := p.expression()
+			p.expect(")")
+			return n
+		case "[":
+			p.next()
+			return &jsNode{kind: jsArray, line: t.line, items: p.arguments("]")}
+		case "{":
+			return p.object()
+		}
+	}
+	p.fail("unexpected %s", tokenText(t))
+	return nil
+}
+
+func parseJSNumber(s string) (float64, error) {
+	s = strings.ReplaceAll(s, "_", "")
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		i, err := strconv.ParseInt(s[2:], 16, 64)
+		return float64(i), err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// arrowParams consumes the parameter list of an arrow function, it doesn't consume anything if the parenthesis
+// is not the start of an arrow function.
+func (p *jsParser) arrowParams() ([]string, bool) {
+	depth, end := 0, p.pos
+	for ; end < len(p.toks); end++ {
+		t := p.toks[end]
+		if t.kind == jsEOF {
+			return nil, false
+		}
+		if t.kind != jsPunct {
+			continue
+		}
+		if t.text == "(" || t.text == "[" || t.text == "{" {
+			depth++
+		} else if t.text == ")" || t.text == "]" || t.text == "}" {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if after := p.peekAt(end - p.pos + 1); after.kind != jsPunct || after.text != "=>" {
+		return nil, false
+	}
+
+	var params []string
+	for _, t := range p.toks[p.pos+1 : end] {
+		if t.kind == jsIdent {
+			params = append(params, t.text)
+		}
+	}
+	p.pos = end + 2
+	return params, true
+}
+
+func (p *jsParser) arrowBody(line int, params []string) *jsNode {
+	f := &jsNode{kind: jsFunc, line: line, params: params}
+	if p.is("{") {
+		p.next()
+		f.body = p.statements(true)
+		p.expect("}")
+	} else {
+		f.right = p.assignment()
+	}
+	return f
+}
+
+func (p *jsParser) function() *jsNode {
+	t := p.next()
+	p.accept("*")
+	f := &jsNode{kind: jsFunc, line: t.line}
+	if p.peek().kind == jsIdent {
+		f.name = p.next().text
+	}
+	p.expect("(")
+	for !p.accept(")") {
+		if tok := p.next(); tok.kind == jsIdent {
+			f.params = append(f.params, tok.text)
+		} else if tok.kind == jsEOF {
+			p.fail(") is expected")
+		}
+	}
+	p.expect("{")
+	f.body = p.statements(true)
+	p.expect("}")
+	return f
+}
+
+func (p *jsParser) object() *jsNode {
+	t := p.next()
+	n := &jsNode{kind: jsObject, line: t.line}
+	for !p.accept("}") {
+		if p.accept("...") {
+			p.assignment()
+			n.keys = append(n.keys, "...")
+			n.items = append(n.items, &jsNode{kind: jsOpaque, line: t.line})
+		} else {
+			key := p.next()
+			var name string
+			switch key.kind {
+			case jsIdent, jsString:
+				name = key.text
+			case jsNumber:
+				f, _ := parseJSNumber(key.text)
+				name = strconv.FormatFloat(f, 'f', -1, 64)
+			default:
+				if key.text != "[" {
+					p.fail("property name is expected")
+				}
+				p.expression()
+				p.expect("]")
+				name = "[]"
+			}
+
+			var value *jsNode
+			switch {
+			case p.accept(":"):
+				value = p.assignment()
+			case p.is("("):
+				// Method definitions
+				p.pos--
+				value = p.function()
+			default:
+				value = &jsNode{kind: jsRef, line: key.line, name: name}
+			}
+			n.keys = append(n.keys, name)
+			n.items = append(n.items, value)
+		}
+		if !p.accept(",") {
+			p.expect("}")
+			break
+		}
+	}
+	return n
+}
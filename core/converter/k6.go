@@ -0,0 +1,938 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Methods of the k6/http module, the post like methods have a body argument before the params.
+var k6Methods = map[string]struct {
+	method  string
+	hasBody bool
+}{
+	"http.get":     {http.MethodGet, false},
+	"http.head":    {http.MethodHead, false},
+	"http.post":    {http.MethodPost, true},
+	"http.put":     {http.MethodPut, true},
+	"http.patch":   {http.MethodPatch, true},
+	"http.del":     {http.MethodDelete, true},
+	"http.options": {http.MethodOptions, true},
+}
+
+// Options of the k6 executors that are translated or that have no effect on the converted load.
+var k6ScenarioOptions = map[string]bool{
+	"executor": true, "vus": true, "startVUs": true, "duration": true, "iterations": true, "stages": true,
+	"rate": true, "startRate": true, "timeUnit": true, "exec": true, "preAllocatedVUs": true, "maxVUs": true,
+	"gracefulStop": true, "gracefulRampDown": true, "maxDuration": true,
+}
+
+// Threshold expressions like "p(95)<500" and "rate<0.01".
+var k6ThresholdRegexp = regexp.MustCompile(`^\s*(avg|min|max|med|rate|count|p\((\d+(?:\.\d+)?)\))\s*(<=|>=|===|==|!=|<|>)\s*(\d+(?:\.\d+)?)\s*$`)
+
+// k6Converter translates the options and the default function of a k6 script.
+type k6Converter struct {
+	w      *warnings
+	config *configFile
+
+	// Declarations of the constants, the functions, and the variables holding the responses of the requests
+	vars      map[string]*jsNode
+	funcs     map[string]*jsNode
+	responses map[string]*stepFile
+
+	checks []statusCheck
+	sleeps sleepPlan
+
+	// Total sleep of an iteration in seconds, the midpoints of the random sleeps
+	iterationSleep float64
+}
+
+// k6Executor is the load of the first scenario of the script, the shortcut options are converted to their
+// equivalent executors.
+type k6Executor struct {
+	kind string
+	exec string
+	line int
+
+	vus, startVUs, iterations float64
+	rate, startRate           float64
+
+	// In seconds
+	duration, timeUnit float64
+	stages             []k6Stage
+}
+
+type k6Stage struct {
+	seconds float64
+	target  float64
+}
+
+func convertK6(src []byte, w *warnings) (*configFile, error) {
+	stmts, err := parseJS(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &k6Converter{
+		w:         w,
+		config:    &configFile{},
+		vars:      make(map[string]*jsNode),
+		funcs:     make(map[string]*jsNode),
+		responses: make(map[string]*stepFile),
+		sleeps:    make(sleepPlan),
+	}
+
+	var options, main *jsNode
+	for _, s := range stmts {
+		switch s.kind {
+		case jsStmtImport:
+			// Unsupported functions of the modules are reported where they are used
+		case jsStmtVar:
+			switch {
+			case s.exported && s.name == "options":
+				options = s.expr
+			case s.expr != nil && s.expr.kind == jsFunc:
+				c.funcs[s.name] = s.expr
+			default:
+				c.declare(s)
+			}
+		case jsStmtFunc:
+			if s.isDefault {
+				main = s.expr
+			} else {
+				c.funcs[s.name] = s.expr
+			}
+			if s.exported && (s.name == "setup" || s.name == "teardown" || s.name == "handleSummary") {
+				w.add("line %d: %s function is not translated", s.line, s.name)
+			}
+		case jsStmtExpr:
+			if s.isDefault && s.expr.kind == jsFunc {
+				main = s.expr
+			} else if f := c.funcs[s.expr.path()]; s.isDefault && f != nil {
+				main = f
+			} else {
+				w.add("line %d: statement is not translated", s.line)
+			}
+		default:
+			w.add("line %d: statement is not translated: %s", s.line, s.summary)
+		}
+	}
+
+	exec, thresholds := c.options(options)
+	if exec.exec != "" {
+		if f := c.funcs[exec.exec]; f != nil {
+			main = f
+		} else {
+			w.add("line %d: exec function %s is not found, the default function is converted", exec.line, exec.exec)
+		}
+	}
+	if main == nil || main.kind != jsFunc {
+		return nil, fmt.Errorf("default function of the script is not found")
+	}
+
+	c.function(main)
+	c.finishSleeps()
+	c.load(exec)
+	c.thresholds(thresholds)
+	return c.config, nil
+}
+
+// declare keeps the constants of the script to resolve the urls, the headers and the bodies of the requests.
+func (c *k6Converter) declare(s *jsStmt) {
+	if s.expr == nil {
+		return
+	}
+	if _, ok := c.eval(s.expr); !ok {
+		c.w.add("line %d: value of %s is not translated", s.line, s.name)
+	}
+	c.vars[s.name] = s.expr
+}
+
+// options reads the load and the thresholds of the options.
+func (c *k6Converter) options(options *jsNode) (exec k6Executor, thresholds *jsNode) {
+	exec = k6Executor{kind: "per-vu-iterations", vus: 1, iterations: 1}
+	if options == nil {
+		return
+	}
+	if options.kind != jsObject {
+		c.w.add("line %d: options are not translated", options.line)
+		return
+	}
+
+	exec.line = options.line
+	for i, key := range options.keys {
+		switch key {
+		case "vus", "duration", "stages", "iterations", "scenarios":
+		case "thresholds":
+			thresholds = options.items[i]
+		default:
+			c.w.add("line %d: option %s is not translated", options.items[i].line, key)
+		}
+	}
+
+	if scenarios := c.resolve(options.prop("scenarios")); scenarios != nil {
+		if scenarios.kind == jsObject && len(scenarios.keys) > 0 {
+			for _, other := range scenarios.keys[1:] {
+				c.w.add("line %d: scenario %s is not translated, only the first scenario %s is converted",
+					scenarios.line, other, scenarios.keys[0])
+			}
+			return c.scenario(c.resolve(scenarios.items[0])), thresholds
+		}
+		c.w.add("line %d: scenarios are not translated", scenarios.line)
+	}
+
+	vus := c.number(options.prop("vus"), 1)
+	switch {
+	case options.prop("stages") != nil:
+		exec = k6Executor{kind: "ramping-vus", startVUs: vus, stages: c.stages(options.prop("stages"))}
+	case options.prop("iterations") != nil:
+		exec = k6Executor{kind: "shared-iterations", vus: vus, iterations: c.number(options.prop("iterations"), 1)}
+	case options.prop("duration") != nil:
+		exec = k6Executor{kind: "constant-vus", vus: vus, duration: c.duration(options.prop("duration"), 0)}
+	default:
+		exec.vus = vus
+	}
+	exec.line = options.line
+	return exec, thresholds
+}
+
+func (c *k6Converter) scenario(s *jsNode) k6Executor {
+	exec := k6Executor{line: s.line}
+	if s.kind != jsObject {
+		c.w.add("line %d: scenario is not translated", s.line)
+		return exec
+	}
+	for i, key := range s.keys {
+		if !k6ScenarioOptions[key] {
+			c.w.add("line %d: scenario option %s is not translated", s.items[i].line, key)
+		}
+	}
+
+	exec.kind, _ = c.string(s.prop("executor"))
+	exec.exec, _ = c.string(s.prop("exec"))
+	exec.vus = c.number(s.prop("vus"), 1)
+	exec.startVUs = c.number(s.prop("startVUs"), 1)
+	exec.iterations = c.number(s.prop("iterations"), 1)
+	exec.rate = c.number(s.prop("rate"), 0)
+	exec.startRate = c.number(s.prop("startRate"), 0)
+	exec.timeUnit = c.duration(s.prop("timeUnit"), 1)
+	exec.duration = c.duration(s.prop("duration"), 0)
+	if s.prop("stages") != nil {
+		exec.stages = c.stages(s.prop("stages"))
+	}
+	return exec
+}
+
+func (c *k6Converter) stages(n *jsNode) (stages []k6Stage) {
+	if n = c.resolve(n); n.kind != jsArray {
+		c.w.add("line %d: stages are not translated", n.line)
+		return nil
+	}
+	for _, s := range n.items {
+		if s = c.resolve(s); s.kind != jsObject {
+			c.w.add("line %d: stage is not translated", s.line)
+			continue
+		}
+		stages = append(stages, k6Stage{seconds: c.duration(s.prop("duration"), 0), target: c.number(s.prop("target"), 0)})
+	}
+	return stages
+}
+
+// load sets the load of the config. The virtual users run the iterations one after another, so their
+// iteration rates are estimated from the sleeps of an iteration.
+func (c *k6Converter) load(exec k6Executor) {
+	cfg := c.config
+	switch exec.kind {
+	case "constant-vus":
+		rate := exec.vus * iterationRate(c.iterationSleep, c.w)
+		cfg.setLoad([]loadStage{{seconds: c.requiredSeconds(exec.duration, exec.line), from: rate, to: rate}})
+	case "ramping-vus":
+		rate := iterationRate(c.iterationSleep, c.w)
+		cfg.setLoad(rampStages(exec.startVUs*rate, exec.stages, rate))
+	case "per-vu-iterations":
+		cfg.setIterations(int(exec.vus*exec.iterations), ceilSeconds(exec.iterations/iterationRate(c.iterationSleep, c.w)))
+	case "shared-iterations":
+		perVU := exec.iterations / math.Max(exec.vus, 1)
+		cfg.setIterations(int(exec.iterations), ceilSeconds(perVU/iterationRate(c.iterationSleep, c.w)))
+	case "constant-arrival-rate":
+		rate := exec.rate / exec.timeUnit
+		cfg.setLoad([]loadStage{{seconds: c.requiredSeconds(exec.duration, exec.line), from: rate, to: rate}})
+	case "ramping-arrival-rate":
+		cfg.setLoad(rampStages(exec.startRate/exec.timeUnit, exec.stages, 1/exec.timeUnit))
+	default:
+		c.w.add("line %d: executor %q is not translated, the default load is used", exec.line, exec.kind)
+	}
+}
+
+func (c *k6Converter) requiredSeconds(seconds float64, line int) int {
+	if seconds <= 0 {
+		c.w.add("line %d: duration of the load is not found, %ds is used", line, defaultDuration)
+		return defaultDuration
+	}
+	return ceilSeconds(seconds)
+}
+
+// rampStages converts the k6 stages to the load stages, the targets are multiplied by the rate of a target unit.
+// The stages of zero duration change the start of the next stage.
+func rampStages(start float64, stages []k6Stage, unitRate float64) []loadStage {
+	var load []loadStage
+	from := start
+	for _, s := range stages {
+		to := s.target * unitRate
+		if s.seconds > 0 {
+			load = append(load, loadStage{seconds: ceilSeconds(s.seconds), from: from, to: to})
+		}
+		from = to
+	}
+	return load
+}
+
+// function translates the statements of the function that runs an iteration.
+func (c *k6Converter) function(f *jsNode) {
+	if f.right != nil {
+		c.expression(f.right)
+		return
+	}
+	c.block(f.body)
+}
+
+func (c *k6Converter) block(stmts []*jsStmt) {
+	for _, s := range stmts {
+		switch s.kind {
+		case jsStmtVar:
+			if s.expr == nil {
+				continue
+			}
+			if s.expr.kind == jsCall {
+				if step := c.request(s.expr); step != nil {
+					c.responses[s.name] = step
+					continue
+				}
+				if p := s.expr.object.path(); p == "check" || p == "group" || p == "sleep" {
+					c.expression(s.expr)
+					continue
+				}
+			}
+			c.declare(s)
+		case jsStmtExpr:
+			c.expression(s.expr)
+		case jsStmtFunc:
+			c.funcs[s.name] = s.expr
+		case jsStmtReturn:
+			c.w.add("line %d: return is not translated", s.line)
+		default:
+			c.w.add("line %d: statement is not translated: %s", s.line, s.summary)
+		}
+	}
+}
+
+func (c *k6Converter) expression(e *jsNode) {
+	if e.kind != jsCall {
+		c.w.add("line %d: expression is not translated", e.line)
+		return
+	}
+	// Unsupported requests are reported by request()
+	p := e.object.path()
+	if c.request(e) != nil || strings.HasPrefix(p, "http.") {
+		return
+	}
+
+	switch p {
+	case "check":
+		c.check(e)
+	case "sleep":
+		c.sleep(e)
+	case "group":
+		if len(e.items) < 2 || e.items[1].kind != jsFunc {
+			c.w.add("line %d: group is not translated", e.line)
+			return
+		}
+		c.function(e.items[1])
+	case "":
+		c.w.add("line %d: call is not translated", e.line)
+	default:
+		c.w.add("line %d: call of %s is not translated", e.line, p)
+	}
+}
+
+// request translates a request call to a step, returns nil if the call is not a request.
+func (c *k6Converter) request(e *jsNode) *stepFile {
+	if e.kind != jsCall {
+		return nil
+	}
+
+	var method string
+	var urlArg, bodyArg, paramsArg *jsNode
+	arg := func(i int) *jsNode {
+		if i < len(e.items) {
+			return e.items[i]
+		}
+		return nil
+	}
+
+	p := e.object.path()
+	if m, ok := k6Methods[p]; ok {
+		method, urlArg = m.method, arg(0)
+		if m.hasBody {
+			bodyArg, paramsArg = arg(1), arg(2)
+		} else {
+			paramsArg = arg(1)
+		}
+	} else if p == "http.request" {
+		var ok bool
+		if method, ok = c.string(arg(0)); !ok {
+			c.w.add("line %d: method of the request is not translated, GET is used", e.line)
+			method = http.MethodGet
+		}
+		urlArg, bodyArg, paramsArg = arg(1), arg(2), arg(3)
+	} else if strings.HasPrefix(p, "http.") {
+		c.w.add("line %d: %s is not translated", e.line, p)
+		return nil
+	} else {
+		return nil
+	}
+
+	if urlArg == nil {
+		c.w.add("line %d: url of the request is not found", e.line)
+		return nil
+	}
+	step := c.config.addStep("", method, c.render(urlArg))
+
+	if params := c.resolve(paramsArg); params != nil {
+		c.params(step, params)
+	}
+	if body := c.resolve(bodyArg); body != nil && !(body.kind == jsLiteral && body.value == nil) {
+		if body.kind == jsObject {
+			step.Payload = c.formBody(body)
+			if !hasHeader(step.Headers, "Content-Type") {
+				step.setHeader("Content-Type", "application/x-www-form-urlencoded")
+			}
+		} else {
+			step.Payload = c.render(body)
+		}
+	}
+	if step.Name == "" {
+		step.Name = stepName(step.Method, step.Url)
+	}
+	return step
+}
+
+func (c *k6Converter) params(step *stepFile, params *jsNode) {
+	if params.kind != jsObject {
+		c.w.add("line %d: params of the request are not translated", params.line)
+		return
+	}
+	for i, key := range params.keys {
+		v := c.resolve(params.items[i])
+		switch key {
+		case "headers":
+			if v.kind != jsObject {
+				c.w.add("line %d: headers of the request are not translated", v.line)
+				continue
+			}
+			for j, h := range v.keys {
+				step.setHeader(h, c.render(v.items[j]))
+			}
+		case "tags":
+			if name := c.resolve(v.prop("name")); name != nil {
+				step.Name = c.render(name)
+			}
+			for _, tag := range v.keys {
+				if tag != "name" {
+					c.w.add("line %d: tag %s of the request is not translated", v.line, tag)
+				}
+			}
+		case "timeout":
+			if seconds := c.duration(v, 0); seconds > 0 {
+				step.Timeout = ceilSeconds(seconds)
+			}
+		default:
+			c.w.add("line %d: %s param of the request is not translated", v.line, key)
+		}
+	}
+}
+
+// formBody encodes the object body of a request as an url encoded form like k6.
+func (c *k6Converter) formBody(body *jsNode) string {
+	pairs := make([]string, len(body.keys))
+	for i, k := range body.keys {
+		pairs[i] = url.QueryEscape(k) + "=" + url.QueryEscape(c.render(body.items[i]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// check translates the status code checks of a response to the success criteria.
+func (c *k6Converter) check(e *jsNode) {
+	if len(e.items) < 2 {
+		c.w.add("line %d: check is not translated", e.line)
+		return
+	}
+
+	target := e.items[0]
+	step := c.responses[target.path()]
+	if target.kind == jsCall {
+		step = c.request(target)
+	}
+	checks := c.resolve(e.items[1])
+	if step == nil || checks.kind != jsObject {
+		c.w.add("line %d: check is not translated", e.line)
+		return
+	}
+
+	for i, name := range checks.keys {
+		if code, ok := statusCode(checks.items[i]); ok {
+			c.checks = append(c.checks, statusCheck{step: step.Id, code: code})
+		} else {
+			c.w.add("line %d: check %q is not translated", checks.items[i].line, name)
+		}
+	}
+}
+
+// statusCode returns the status code of a check function like "(r) => r.status === 200".
+func statusCode(f *jsNode) (int, bool) {
+	if f.kind != jsFunc || len(f.params) != 1 {
+		return 0, false
+	}
+	e := f.right
+	if e == nil && len(f.body) == 1 && f.body[0].kind == jsStmtReturn {
+		e = f.body[0].expr
+	}
+	if e == nil || e.kind != jsBinary || e.name != "===" && e.name != "==" {
+		return 0, false
+	}
+
+	status, code := e.object, e.right
+	if code.kind != jsLiteral {
+		status, code = code, status
+	}
+	if status.path() != f.params[0]+".status" || code.kind != jsLiteral {
+		return 0, false
+	}
+	v, ok := code.value.(float64)
+	return int(v), ok && v == math.Trunc(v)
+}
+
+// sleep adds the sleep to the last step, the sleeps before the first request only count in the iteration duration.
+func (c *k6Converter) sleep(e *jsNode) {
+	if len(e.items) != 1 {
+		c.w.add("line %d: sleep is not translated", e.line)
+		return
+	}
+
+	min, max, ok := c.sleepRange(c.resolve(e.items[0]))
+	if !ok || min < 0 || max < min {
+		c.w.add("line %d: sleep duration is not translated", e.line)
+		return
+	}
+	c.iterationSleep += (min + max) / 2
+	if len(c.config.Steps) > 0 {
+		c.sleeps.add(c.config.Steps[len(c.config.Steps)-1], min, max)
+	}
+}
+
+// sleepRange returns the range of a sleep duration in seconds, like "Math.random() * 3" or
+// "randomIntBetween(1, 5)" of the k6 utils.
+func (c *k6Converter) sleepRange(n *jsNode) (min, max float64, ok bool) {
+	if v, ok := c.eval(n); ok {
+		f, ok := v.(float64)
+		return f, f, ok
+	}
+	if n.kind == jsCall && n.object.path() == "randomIntBetween" && len(n.items) == 2 {
+		min, ok1 := c.eval(n.items[0])
+		max, ok2 := c.eval(n.items[1])
+		minF, ok3 := min.(float64)
+		maxF, ok4 := max.(float64)
+		return minF, maxF, ok1 && ok2 && ok3 && ok4
+	}
+	if n.kind == jsBinary && n.name == "*" {
+		random, factor := n.object, n.right
+		if random.kind != jsCall {
+			random, factor = factor, random
+		}
+		if random.kind == jsCall && random.object.path() == "Math.random" && len(random.items) == 0 {
+			if v, ok := c.eval(factor); ok {
+				f, ok := v.(float64)
+				return 0, f, ok
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// finishSleeps sets the sleeps of the steps. The sleeps after the last request are dropped,
+// the load of the converted config already paces the iterations.
+func (c *k6Converter) finishSleeps() {
+	if steps := c.config.Steps; len(steps) > 0 {
+		delete(c.sleeps, steps[len(steps)-1])
+	}
+	c.sleeps.apply()
+}
+
+// thresholds translates the thresholds of the request durations, the failures and the checks to the success
+// criteria.
+func (c *k6Converter) thresholds(n *jsNode) {
+	checksCmp := ""
+	defer func() {
+		if checksCmp != "" && len(c.checks) == 0 {
+			c.w.add("line %d: threshold of checks is not translated since no check is translated", n.line)
+		}
+		c.config.addCriteria(statusClauses(c.checks, checksCmp)...)
+	}()
+	if n == nil {
+		return
+	}
+	if n.kind != jsObject {
+		c.w.add("line %d: thresholds are not translated", n.line)
+		return
+	}
+
+	for i, metric := range n.keys {
+		list := c.resolve(n.items[i])
+		exprs := []*jsNode{list}
+		if list.kind == jsArray {
+			exprs = list.items
+		}
+
+		for _, e := range exprs {
+			if obj := c.resolve(e); obj.kind == jsObject {
+				e = obj.prop("threshold")
+				if abort := obj.prop("abortOnFail"); abort != nil {
+					c.w.add("line %d: abortOnFail of the threshold of %s is not translated", abort.line, metric)
+				}
+				if e == nil {
+					c.w.add("line %d: threshold of %s is not translated", obj.line, metric)
+					continue
+				}
+			}
+			expr, ok := c.string(e)
+			m := k6ThresholdRegexp.FindStringSubmatch(expr)
+			if !ok || m == nil {
+				c.w.add("line %d: threshold %q of %s is not translated", e.line, expr, metric)
+				continue
+			}
+
+			agg, percentile, op, value := m[1], m[2], m[3], m[4]
+			if op == "===" {
+				op = "=="
+			}
+			switch {
+			case metric == "http_req_duration":
+				var key string
+				switch {
+				case agg == "avg":
+					key = "avg_duration"
+				case agg == "med":
+					key = "p50"
+				case percentile == "50" || percentile == "90" || percentile == "95" || percentile == "99":
+					key = "p" + percentile
+				default:
+					c.w.add("line %d: threshold %q of %s is not translated", e.line, expr, metric)
+					continue
+				}
+				for _, s := range c.config.Steps {
+					c.config.addCriteria(fmt.Sprintf("steps.%d.%s %s %sms", s.Id, key, op, value))
+				}
+			case metric == "http_req_failed" && agg == "rate":
+				c.config.addCriteria(fmt.Sprintf("result.fail_rate %s %s", op, value))
+			case metric == "checks" && agg == "rate":
+				checksCmp = op + " " + value
+			default:
+				c.w.add("line %d: threshold %q of %s is not translated", e.line, expr, metric)
+			}
+		}
+	}
+}
+
+// resolve returns the declaration of the constant references, the node itself for the others.
+func (c *k6Converter) resolve(n *jsNode) *jsNode {
+	for depth := 0; n != nil && n.kind == jsRef && n.object == nil && depth < 32; depth++ {
+		v, ok := c.vars[n.name]
+		if !ok {
+			break
+		}
+		n = v
+	}
+	return n
+}
+
+// eval evaluates the constant expressions. Values are strings, float64s, bools, nil, []interface{} and
+// jsObjectValue.
+func (c *k6Converter) eval(n *jsNode) (interface{}, bool) {
+	n = c.resolve(n)
+	if n == nil {
+		return nil, false
+	}
+
+	switch n.kind {
+	case jsLiteral:
+		return n.value, true
+	case jsTemplateLiteral:
+		parts := templateParts(n.value.(string))
+		b := strings.Builder{}
+		for i, part := range parts {
+			if i%2 == 0 {
+				b.WriteString(part)
+				continue
+			}
+			e, err := parseJSExpression(part, n.line)
+			if err != nil {
+				return nil, false
+			}
+			v, ok := c.eval(e)
+			if !ok {
+				return nil, false
+			}
+			b.WriteString(jsToString(v))
+		}
+		return b.String(), true
+	case jsObject:
+		obj := jsObjectValue{keys: n.keys, values: make([]interface{}, len(n.items))}
+		for i, item := range n.items {
+			v, ok := c.eval(item)
+			if !ok {
+				return nil, false
+			}
+			obj.values[i] = v
+		}
+		return obj, true
+	case jsArray:
+		arr := make([]interface{}, len(n.items))
+		for i, item := range n.items {
+			v, ok := c.eval(item)
+			if !ok {
+				return nil, false
+			}
+			arr[i] = v
+		}
+		return arr, true
+	case jsBinary:
+		return c.evalBinary(n)
+	case jsCall:
+		if n.object.path() == "JSON.stringify" && len(n.items) > 0 {
+			v, ok := c.eval(n.items[0])
+			if !ok {
+				return nil, false
+			}
+			b, err := json.Marshal(v)
+			return string(b), err == nil
+		}
+	}
+	return nil, false
+}
+
+func (c *k6Converter) evalBinary(n *jsNode) (interface{}, bool) {
+	left, okLeft := c.eval(n.object)
+	if n.name == "||" || n.name == "??" {
+		if okLeft && (n.name == "??" && left != nil || n.name == "||" && jsTruthy(left)) {
+			return left, true
+		}
+		right, ok := c.eval(n.right)
+		if ok && !okLeft {
+			c.w.add("line %d: %s is not resolved, its fallback value is used", n.line, describe(n.object))
+		}
+		return right, ok
+	}
+
+	right, okRight := c.eval(n.right)
+	if !okLeft || !okRight {
+		return nil, false
+	}
+	l, lNum := left.(float64)
+	r, rNum := right.(float64)
+	switch {
+	case n.name == "+" && lNum && rNum:
+		return l + r, true
+	case n.name == "+":
+		return jsToString(left) + jsToString(right), true
+	case n.name == "-" && lNum && rNum:
+		return l - r, true
+	case n.name == "*" && lNum && rNum:
+		return l * r, true
+	case n.name == "/" && lNum && rNum && r != 0:
+		return l / r, true
+	}
+	return nil, false
+}
+
+// render returns the string value of the expression. The parts that can't be resolved, like the values of
+// the responses or the environment variables, are left as ${expression} in the string and reported.
+func (c *k6Converter) render(n *jsNode) string {
+	if v, ok := c.eval(n); ok {
+		return jsToString(v)
+	}
+
+	n = c.resolve(n)
+	switch {
+	case n.kind == jsTemplateLiteral:
+		parts := templateParts(n.value.(string))
+		b := strings.Builder{}
+		for i, part := range parts {
+			if i%2 == 0 {
+				b.WriteString(part)
+				continue
+			}
+			if e, err := parseJSExpression(part, n.line); err == nil {
+				if v, ok := c.eval(e); ok {
+					b.WriteString(jsToString(v))
+					continue
+				}
+			}
+			c.w.add("line %d: %s is not resolved, it is left as ${%s}", n.line, part, part)
+			b.WriteString("${" + part + "}")
+		}
+		return b.String()
+	case n.kind == jsBinary && n.name == "+":
+		return c.render(n.object) + c.render(n.right)
+	}
+
+	d := describe(n)
+	c.w.add("line %d: %s is not resolved, it is left as ${%s}", n.line, d, d)
+	return "${" + d + "}"
+}
+
+func (c *k6Converter) string(n *jsNode) (string, bool) {
+	v, ok := c.eval(n)
+	s, isString := v.(string)
+	return s, ok && isString
+}
+
+func (c *k6Converter) number(n *jsNode, def float64) float64 {
+	if n == nil {
+		return def
+	}
+	v, ok := c.eval(n)
+	if f, isNumber := v.(float64); ok && isNumber {
+		return f
+	}
+	c.w.add("line %d: %s is not a number, %g is used", n.line, describe(n), def)
+	return def
+}
+
+// duration returns the seconds of a k6 duration like "1m30s", or of milliseconds.
+func (c *k6Converter) duration(n *jsNode, def float64) float64 {
+	if n == nil {
+		return def
+	}
+	v, _ := c.eval(n)
+	switch v := v.(type) {
+	case float64:
+		return v / 1000
+	case string:
+		if d, err := parseK6Duration(v); err == nil {
+			return d.Seconds()
+		}
+	}
+	c.w.add("line %d: %s is not a duration, %gs is used", n.line, describe(n), def)
+	return def
+}
+
+// parseK6Duration parses the Go durations, with the days unit of k6 like "1d12h".
+func parseK6Duration(s string) (time.Duration, error) {
+	var days time.Duration
+	if i := strings.Index(s, "d"); i > 0 {
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, err
+		}
+		days, s = time.Duration(n*24)*time.Hour, s[i+1:]
+		if s == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	return days + d, err
+}
+
+// describe returns a short text of the expression for the warnings, like "__ENV.BASE_URL".
+func describe(n *jsNode) string {
+	if p := n.path(); p != "" {
+		return p
+	}
+	switch n.kind {
+	case jsCall:
+		if p := n.object.path(); p != "" {
+			return p + "()"
+		}
+	case jsIndex, jsRef:
+		return describe(n.object) + "." + n.name
+	}
+	return "expression"
+}
+
+// jsObjectValue is an evaluated object literal, it keeps the order of the keys in JSON.
+type jsObjectValue struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o jsObjectValue) MarshalJSON() ([]byte, error) {
+	b := []byte{'{'}
+	for i, k := range o.keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b = append(append(append(b, key...), ':'), value...)
+	}
+	return append(b, '}'), nil
+}
+
+// jsToString converts the value to a string like the string conversion of JavaScript, objects are in JSON.
+func jsToString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func jsTruthy(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case bool:
+		return v
+	case nil:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+import http from 'k6/http';
+import { check, group, sleep } from 'k6';
+
+const BASE = 'https://shop.example.com';
+
+export const options = {
+  scenarios: {
+    checkout: {
+      executor: 'constant-arrival-rate',
+      rate: 50,
+      timeUnit: '1s',
+      duration: '2m',
+      preAllocatedVUs: 20,
+    },
+  },
+  thresholds: {
+    checks: ['rate>0.99'],
+  },
+};
+
+export default function () {
+  const params = { headers: { 'Content-Type': 'application/json', 'X-Client': 'k6' } };
+
+  group('login', function () {
+    const res = http.post(`${BASE}/api/login`, JSON.stringify({ user: 'test', password: 'secret' }), params);
+    check(res, {
+      'logged in': (r) => r.status === 200,
+      'has token': (r) => r.json('token') !== '',
+    });
+  });
+  sleep(0.5);
+
+  const cart = http.get(BASE + '/api/cart', params);
+  check(cart, { 'cart ok': (r) => r.status == 200 });
+  sleep(Math.random() * 2);
+
+  const token = cart.json('token');
+  http.post(`${BASE}/api/checkout`, { item: 'sku-1', qty: 2 }, {
+    headers: { Authorization: `Bearer ${token}` },
+  });
+
+  if (__VU === 1) {
+    console.log('first vu');
+  }
+}
@@ -0,0 +1,21 @@
+import http from 'k6/http';
+import { sleep } from 'k6';
+
+const BASE_URL = __ENV.BASE_URL || 'https://api.example.com';
+
+export const options = {
+  stages: [
+    { duration: '30s', target: 20 },
+    { duration: '1m', target: 20 },
+    { duration: '20s', target: 0 },
+  ],
+  thresholds: {
+    http_req_duration: ['p(95)<500'],
+    http_req_failed: ['rate<0.01'],
+  },
+};
+
+export default function () {
+  http.get(`${BASE_URL}/products?page=1`, { tags: { name: 'products' } });
+  sleep(2);
+}
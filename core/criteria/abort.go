@@ -0,0 +1,147 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package criteria
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAbortWindow is the window length of the abort rules without an "over" clause.
+const DefaultAbortWindow = time.Minute
+
+// windowMetrics are the step metrics the abort rules can refer to, evaluated over the results of a window.
+var windowMetrics = map[string]bool{
+	"fail_count":   false,
+	"fail_rate":    false,
+	"avg_duration": true,
+	"p50":          true,
+	"p90":          true,
+	"p95":          true,
+	"p99":          true,
+}
+
+// AbortRule is a parsed abort rule, a comparison of a step metric with a value that stops the test once it holds
+// for the given count of consecutive windows. The windows are the back to back periods of the test.
+// Example: steps.2.p95 > 800ms over 1m for 3 windows
+type AbortRule struct {
+	expr string
+	cmp  *comparison
+
+	Window  time.Duration
+	Windows int
+}
+
+// ParseAbortRule parses the abort rule expression. The window is DefaultAbortWindow and the count is 1 if
+// the rule has no "over" or "for" clause.
+func ParseAbortRule(expr string) (*AbortRule, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, abortRuleError(err)
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseComparison()
+	if err != nil {
+		return nil, abortRuleError(err)
+	}
+	cmp := n.(*comparison)
+	if cmp.left.ident == nil || cmp.right.ident != nil || cmp.left.ident.Step == "" ||
+		!isWindowMetric(cmp.left.ident.Metric) {
+		return nil, fmt.Errorf("invalid abort rule: %s should compare a step metric with a value, "+
+			"like steps.2.p95 > 800ms", cmp)
+	}
+
+	r := &AbortRule{expr: strings.TrimSpace(expr), cmp: cmp, Window: DefaultAbortWindow, Windows: 1}
+	var hasWindow, hasCount bool
+	for p.peek().kind != tokenEOF {
+		t := p.next()
+		switch {
+		case t.kind == tokenIdent && t.text == "over" && !hasWindow:
+			d := p.next()
+			if r.Window, err = time.ParseDuration(d.text); d.kind != tokenNumber || err != nil || r.Window <= 0 {
+				return nil, fmt.Errorf("invalid abort rule: expected window duration like 1m at %d", d.pos)
+			}
+			hasWindow = true
+		case t.kind == tokenIdent && t.text == "for" && !hasCount:
+			c := p.next()
+			if r.Windows, err = strconv.Atoi(c.text); c.kind != tokenNumber || err != nil || r.Windows < 1 {
+				return nil, fmt.Errorf("invalid abort rule: expected window count at %d", c.pos)
+			}
+			if w := p.next(); w.kind != tokenIdent || (w.text != "windows" && w.text != "window") {
+				return nil, fmt.Errorf("invalid abort rule: expected \"windows\" at %d", w.pos)
+			}
+			hasCount = true
+		default:
+			return nil, fmt.Errorf("invalid abort rule: unexpected %q at %d", t.text, t.pos)
+		}
+	}
+	return r, nil
+}
+
+func (r *AbortRule) String() string {
+	return r.expr
+}
+
+// Ident returns the step metric of the rule.
+func (r *AbortRule) Ident() Ident {
+	return *r.cmp.left.ident
+}
+
+// Duration reports whether the metric of the rule is a duration in seconds.
+func (r *AbortRule) Duration() bool {
+	return r.cmp.left.isDuration
+}
+
+// Violated reports whether the value of the metric over a window holds the comparison of the rule.
+func (r *AbortRule) Violated(value float64) bool {
+	passed, _, _ := r.cmp.evaluate(windowValue(value))
+	return passed
+}
+
+// FormatValue returns the printable value of the metric over a window.
+func (r *AbortRule) FormatValue(value float64) string {
+	return ClauseResult{Actual: &value, Duration: r.Duration()}.FormatActual()
+}
+
+// windowValue is the Env of a rule, the value of the metric over a window.
+type windowValue float64
+
+func (v windowValue) CriteriaValue(Ident) (float64, bool) {
+	return float64(v), true
+}
+
+func isWindowMetric(metric string) bool {
+	_, ok := windowMetrics[metric]
+	return ok
+}
+
+// abortRuleError rewords the errors of the criteria parser for the abort rules, keeping their types.
+func abortRuleError(err error) error {
+	msg := "invalid abort rule: " + strings.TrimPrefix(err.Error(), "invalid success criteria: ")
+	if _, ok := err.(*UnknownIdentError); ok {
+		return &UnknownIdentError{msg}
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,592 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package criteria
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Metrics of the success criteria identifiers. Duration metrics are evaluated in seconds.
+var (
+	resultMetrics = map[string]bool{
+		"iteration_count": false,
+		"success_count":   false,
+		"fail_count":      false,
+		"success_rate":    false,
+		"fail_rate":       false,
+		"avg_duration":    true,
+	}
+	stepMetrics = map[string]bool{
+		"request_count":      false,
+		"success_count":      false,
+		"fail_count":         false,
+		"success_rate":       false,
+		"fail_rate":          false,
+		"rate_limited_count": false,
+		"avg_duration":       true,
+		"p50":                true,
+		"p90":                true,
+		"p95":                true,
+		"p99":                true,
+	}
+	statusMetric = regexp.MustCompile(`^status_([1-5][0-9][0-9]|[1-5]xx)_(rate|count)$`)
+)
+
+// Percentiles are the duration percentiles the success criteria can refer to.
+var Percentiles = []int{50, 90, 95, 99}
+
+// Ident is an identifier of the success criteria, like result.fail_rate or steps.checkout.p95.
+type Ident struct {
+	// Name or ID of the step, empty for the identifiers of the whole result
+	Step   string
+	Metric string
+}
+
+func (id Ident) String() string {
+	if id.Step == "" {
+		return "result." + id.Metric
+	}
+	if isName(id.Step) {
+		return "steps." + id.Step + "." + id.Metric
+	}
+	return fmt.Sprintf("steps[%q].%s", id.Step, id.Metric)
+}
+
+// Env provides the values of the identifiers when the success criteria is evaluated.
+// Returns false if the value is not available, like the percentiles of a step without successful requests.
+type Env interface {
+	CriteriaValue(id Ident) (float64, bool)
+}
+
+// UnknownIdentError is the error of an identifier of the success criteria that refers to no metric.
+type UnknownIdentError struct {
+	msg string
+}
+
+func (e *UnknownIdentError) Error() string {
+	return e.msg
+}
+
+// Criteria is a parsed success criteria expression, comparisons combined with &&, || and !.
+// Example: steps.checkout.p95 < 300ms && result.fail_rate < 0.01 && steps.login.status_2xx_rate > 99.9%
+type Criteria struct {
+	expr    string
+	root    node
+	clauses []*comparison
+}
+
+// Result is the evaluation of the success criteria.
+type Result struct {
+	Passed  bool           `json:"passed"`
+	Clauses []ClauseResult `json:"clauses"`
+}
+
+// ClauseResult is the evaluation of a comparison of the success criteria.
+type ClauseResult struct {
+	Clause string `json:"clause"`
+	Passed bool   `json:"passed"`
+
+	// Value of the identifier of the clause, nil if it is not available
+	Actual *float64 `json:"actual"`
+
+	// Set if the actual value is a duration in seconds
+	Duration bool `json:"-"`
+}
+
+// FormatActual returns the printable actual value of the clause.
+func (c ClauseResult) FormatActual() string {
+	if c.Actual == nil {
+		return "n/a"
+	}
+	if c.Duration {
+		return fmt.Sprintf("%.4fs", *c.Actual)
+	}
+	if *c.Actual == math.Trunc(*c.Actual) {
+		return strconv.FormatFloat(*c.Actual, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(*c.Actual, 'f', 4, 64)
+}
+
+// Parse parses the success criteria expression.
+func Parse(expr string) (*Criteria, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid success criteria: unexpected %q at %d", t.text, t.pos)
+	}
+	return &Criteria{expr: expr, root: root, clauses: p.clauses}, nil
+}
+
+func (c *Criteria) String() string {
+	return c.expr
+}
+
+// Idents returns the identifiers referred by the criteria.
+func (c *Criteria) Idents() []Ident {
+	idents := make([]Ident, 0, len(c.clauses))
+	for _, cl := range c.clauses {
+		for _, o := range []operand{cl.left, cl.right} {
+			if o.ident != nil {
+				idents = append(idents, *o.ident)
+			}
+		}
+	}
+	return idents
+}
+
+// Evaluate evaluates the criteria with the values of env. All the clauses are evaluated to be reported.
+func (c *Criteria) Evaluate(env Env) *Result {
+	r := &Result{Clauses: make([]ClauseResult, 0, len(c.clauses))}
+	results := make(map[*comparison]bool, len(c.clauses))
+	for _, cl := range c.clauses {
+		passed, actual, ok := cl.evaluate(env)
+		results[cl] = passed
+
+		cr := ClauseResult{Clause: cl.String(), Passed: passed, Duration: cl.duration()}
+		if ok {
+			cr.Actual = &actual
+		}
+		r.Clauses = append(r.Clauses, cr)
+	}
+	r.Passed = c.root.passed(results)
+	return r
+}
+
+type node interface {
+	passed(results map[*comparison]bool) bool
+}
+
+type logical struct {
+	and         bool
+	left, right node
+}
+
+func (n *logical) passed(results map[*comparison]bool) bool {
+	if n.and {
+		return n.left.passed(results) && n.right.passed(results)
+	}
+	return n.left.passed(results) || n.right.passed(results)
+}
+
+type not struct {
+	node node
+}
+
+func (n *not) passed(results map[*comparison]bool) bool {
+	return !n.node.passed(results)
+}
+
+type operand struct {
+	ident *Ident
+	value float64
+	text  string
+
+	// Set for the duration literals and the duration metrics
+	isDuration bool
+}
+
+func (o operand) resolve(env Env) (float64, bool) {
+	if o.ident == nil {
+		return o.value, true
+	}
+	return env.CriteriaValue(*o.ident)
+}
+
+func (o operand) String() string {
+	if o.ident != nil {
+		return o.ident.String()
+	}
+	return o.text
+}
+
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+func (n *comparison) passed(results map[*comparison]bool) bool {
+	return results[n]
+}
+
+func (n *comparison) String() string {
+	return n.left.String() + " " + n.op + " " + n.right.String()
+}
+
+// duration reports whether the actual value of the clause is a duration.
+func (n *comparison) duration() bool {
+	if n.left.ident != nil {
+		return n.left.isDuration
+	}
+	return n.right.isDuration
+}
+
+// evaluate returns the result of the comparison and the value of its identifier side.
+// Comparisons with unavailable values fail.
+func (n *comparison) evaluate(env Env) (passed bool, actual float64, ok bool) {
+	l, lok := n.left.resolve(env)
+	r, rok := n.right.resolve(env)
+	if n.left.ident != nil {
+		actual, ok = l, lok
+	} else {
+		actual, ok = r, rok
+	}
+
+	if !lok || !rok {
+		return false, actual, ok
+	}
+	switch n.op {
+	case "<":
+		passed = l < r
+	case "<=":
+		passed = l <= r
+	case ">":
+		passed = l > r
+	case ">=":
+		passed = l >= r
+	case "==":
+		passed = l == r
+	case "!=":
+		passed = l != r
+	}
+	return
+}
+
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenNumber
+	tokenOp
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+
+	// Path segments of the identifiers
+	segments []string
+}
+
+func tokenize(expr string) (tokens []token, err error) {
+	r := []rune(expr)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')':
+			kind := tokenLParen
+			if c == ')' {
+				kind = tokenRParen
+			}
+			tokens = append(tokens, token{kind: kind, text: string(c), pos: i})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(r) || r[i+1] != c {
+				return nil, fmt.Errorf("invalid success criteria: unexpected %q at %d", string(c), i)
+			}
+			kind := tokenAnd
+			if c == '|' {
+				kind = tokenOr
+			}
+			tokens = append(tokens, token{kind: kind, text: string(r[i : i+2]), pos: i})
+			i += 2
+		case c == '<' || c == '>' || c == '=' || c == '!':
+			op := string(c)
+			if i+1 < len(r) && r[i+1] == '=' {
+				op += "="
+			}
+			if op == "=" {
+				return nil, fmt.Errorf("invalid success criteria: unexpected \"=\" at %d, use ==", i)
+			}
+			kind := tokenOp
+			if op == "!" {
+				kind = tokenNot
+			}
+			tokens = append(tokens, token{kind: kind, text: op, pos: i})
+			i += len(op)
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(r) && (unicode.IsDigit(r[i]) || r[i] == '.' || unicode.IsLetter(r[i]) || r[i] == '%') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(r[start:i]), pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			t, n, e := tokenizeIdent(r, i)
+			if e != nil {
+				return nil, e
+			}
+			tokens = append(tokens, t)
+			i = n
+		default:
+			return nil, fmt.Errorf("invalid success criteria: unexpected %q at %d", string(c), i)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, text: "end of expression", pos: len(r)})
+	return
+}
+
+// tokenizeIdent reads a dotted identifier starting at i. Segments can be quoted in brackets,
+// like steps["Checkout Page"].p95, for the step names that are not valid identifiers.
+func tokenizeIdent(r []rune, i int) (token, int, error) {
+	start := i
+	var segments []string
+	for {
+		if i < len(r) && r[i] == '[' {
+			if i+1 >= len(r) || r[i+1] != '"' {
+				return token{}, i, fmt.Errorf("invalid success criteria: expected quoted name at %d", i)
+			}
+			end := -1
+			for j := i + 2; j+1 < len(r); j++ {
+				if r[j] == '"' && r[j+1] == ']' {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				return token{}, i, fmt.Errorf("invalid success criteria: unterminated name at %d", i)
+			}
+			segments = append(segments, string(r[i+2:end]))
+			i = end + 2
+		} else {
+			s := i
+			for i < len(r) && isNameRune(r[i]) {
+				i++
+			}
+			if s == i {
+				return token{}, i, fmt.Errorf("invalid success criteria: expected name at %d", i)
+			}
+			segments = append(segments, string(r[s:i]))
+		}
+
+		if i < len(r) && r[i] == '.' {
+			i++
+			continue
+		}
+		if i < len(r) && r[i] == '[' {
+			continue
+		}
+		break
+	}
+	return token{kind: tokenIdent, text: string(r[start:i]), pos: start, segments: segments}, i, nil
+}
+
+func isNameRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '-'
+}
+
+func isName(s string) bool {
+	for _, c := range s {
+		if !isNameRune(c) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+type parser struct {
+	tokens  []token
+	pos     int
+	clauses []*comparison
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch p.peek().kind {
+	case tokenNot:
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{node: n}, nil
+	case tokenLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenRParen {
+			return nil, fmt.Errorf("invalid success criteria: expected \")\" at %d", t.pos)
+		}
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, fmt.Errorf("invalid success criteria: expected comparison operator at %d", op.pos)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &comparison{op: op.text, left: left, right: right}
+	if left.ident == nil && right.ident == nil {
+		return nil, fmt.Errorf("invalid success criteria: %s doesn't refer to any result", c)
+	}
+	if (left.ident == nil && left.isDuration && !right.isDuration) ||
+		(right.ident == nil && right.isDuration && !left.isDuration) {
+		return nil, fmt.Errorf("invalid success criteria: %s compares a duration with a non-duration metric", c)
+	}
+	p.clauses = append(p.clauses, c)
+	return c, nil
+}
+
+func (p *parser) parseOperand() (o operand, err error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return parseNumber(t)
+	case tokenIdent:
+		return parseIdent(t)
+	}
+	return o, fmt.Errorf("invalid success criteria: expected identifier or number at %d", t.pos)
+}
+
+// parseNumber parses the numbers, percentages like 99.9% and durations like 300ms.
+func parseNumber(t token) (o operand, err error) {
+	o.text = t.text
+	i := strings.IndexFunc(t.text, func(c rune) bool { return !unicode.IsDigit(c) && c != '.' })
+	if i < 0 {
+		o.value, err = strconv.ParseFloat(t.text, 64)
+	} else if t.text[i:] == "%" {
+		o.value, err = strconv.ParseFloat(t.text[:i], 64)
+		o.value /= 100
+	} else {
+		var d time.Duration
+		d, err = time.ParseDuration(t.text)
+		o.value, o.isDuration = d.Seconds(), true
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid success criteria: invalid number %q at %d", t.text, t.pos)
+	}
+	return
+}
+
+func parseIdent(t token) (o operand, err error) {
+	s := t.segments
+	switch {
+	case len(s) == 2 && s[0] == "result":
+		isDuration, ok := resultMetrics[s[1]]
+		if !ok {
+			return o, &UnknownIdentError{fmt.Sprintf("invalid success criteria: unknown result metric %q at %d", s[1],
+				t.pos)}
+		}
+		o.ident, o.isDuration = &Ident{Metric: s[1]}, isDuration
+	case len(s) == 3 && s[0] == "steps":
+		isDuration, ok := stepMetrics[s[2]]
+		if !ok && !statusMetric.MatchString(s[2]) {
+			return o, &UnknownIdentError{fmt.Sprintf("invalid success criteria: unknown step metric %q at %d", s[2],
+				t.pos)}
+		}
+		o.ident, o.isDuration = &Ident{Step: s[1], Metric: s[2]}, isDuration
+	default:
+		return o, &UnknownIdentError{fmt.Sprintf("invalid success criteria: unknown identifier %q at %d, "+
+			"expected result.<metric> or steps.<name or id>.<metric>", t.text, t.pos)}
+	}
+	return
+}
+
+// ParseStatusMetric parses the status metrics like status_200_rate and status_2xx_count.
+// class is set for the status classes like 2xx, and code is the first digit for them.
+func ParseStatusMetric(metric string) (code int, class bool, rate bool, ok bool) {
+	m := statusMetric.FindStringSubmatch(metric)
+	if m == nil {
+		return 0, false, false, false
+	}
+	if strings.HasSuffix(m[1], "xx") {
+		code, _ = strconv.Atoi(m[1][:1])
+		class = true
+	} else {
+		code, _ = strconv.Atoi(m[1])
+	}
+	return code, class, m[2] == "rate", true
+}
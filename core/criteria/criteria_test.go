@@ -0,0 +1,232 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package criteria
+
+import (
+	"testing"
+	"time"
+)
+
+type envMock map[string]float64
+
+func (m envMock) CriteriaValue(id Ident) (float64, bool) {
+	v, ok := m[id.String()]
+	return v, ok
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		expr      string
+		idents    []Ident
+		shouldErr bool
+	}{
+		{"Result", "result.fail_rate < 0.01", []Ident{{Metric: "fail_rate"}}, false},
+		{"Step", "steps.checkout.p95 < 300ms", []Ident{{Step: "checkout", Metric: "p95"}}, false},
+		{"StepID", "steps.2.status_2xx_rate >= 99.9%", []Ident{{Step: "2", Metric: "status_2xx_rate"}}, false},
+		{"QuotedStep", `steps["Get Users"].status_404_count == 0`,
+			[]Ident{{Step: "Get Users", Metric: "status_404_count"}}, false},
+		{"Logical", "!(result.fail_count > 0) || result.success_rate > 0.5 && result.avg_duration <= 1s",
+			[]Ident{{Metric: "fail_count"}, {Metric: "success_rate"}, {Metric: "avg_duration"}}, false},
+		{"Empty", "", nil, true},
+		{"UnknownMetric", "result.p95 < 1s", nil, true},
+		{"UnknownStepMetric", "steps.login.status_600_rate < 1", nil, true},
+		{"UnknownIdent", "foo.bar < 1", nil, true},
+		{"MissingOperand", "result.fail_rate <", nil, true},
+		{"MissingOperator", "result.fail_rate 1", nil, true},
+		{"UnbalancedParen", "(result.fail_rate < 1", nil, true},
+		{"TrailingToken", "result.fail_rate < 1)", nil, true},
+		{"InvalidNumber", "result.fail_rate < 1.2.3", nil, true},
+		{"DurationOfCount", "result.fail_count < 300ms", nil, true},
+		{"UnterminatedQuote", `steps["login].p95 < 1s`, nil, true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := Parse(test.expr)
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error occurred %v", err)
+			}
+
+			idents := c.Idents()
+			if len(idents) != len(test.idents) {
+				t.Fatalf("Expected idents %v, Found %v", test.idents, idents)
+			}
+			for i := range idents {
+				if idents[i] != test.idents[i] {
+					t.Errorf("Expected ident %v, Found %v", test.idents[i], idents[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	env := envMock{
+		"result.fail_rate":              0.02,
+		"steps.checkout.p95":            0.25,
+		`steps["Get Users"].fail_count`: 3,
+	}
+
+	tests := []struct {
+		name    string
+		expr    string
+		passed  bool
+		clauses []bool
+	}{
+		{"Pass", "steps.checkout.p95 < 300ms", true, []bool{true}},
+		{"Fail", "result.fail_rate < 1%", false, []bool{false}},
+		{"And", "steps.checkout.p95 < 300ms && result.fail_rate < 0.01", false, []bool{true, false}},
+		{"Or", "steps.checkout.p95 < 300ms || result.fail_rate < 0.01", true, []bool{true, false}},
+		{"Not", `!(steps["Get Users"].fail_count != 3)`, true, []bool{false}},
+		{"Precedence", "result.fail_rate > 1 && result.fail_rate > 2 || steps.checkout.p95 == 250ms",
+			true, []bool{false, false, true}},
+		{"Unavailable", "steps.checkout.p99 < 1s", false, []bool{false}},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := Parse(test.expr)
+			if err != nil {
+				t.Fatalf("Error occurred %v", err)
+			}
+
+			r := c.Evaluate(env)
+			if r.Passed != test.passed {
+				t.Errorf("Expected passed %v, Found %v", test.passed, r.Passed)
+			}
+			if len(r.Clauses) != len(test.clauses) {
+				t.Fatalf("Expected %d clauses, Found %d", len(test.clauses), len(r.Clauses))
+			}
+			for i, cl := range r.Clauses {
+				if cl.Passed != test.clauses[i] {
+					t.Errorf("Clause %q: Expected passed %v, Found %v", cl.Clause, test.clauses[i], cl.Passed)
+				}
+			}
+		})
+	}
+}
+
+func TestClauseResultFormatActual(t *testing.T) {
+	t.Parallel()
+
+	v1, v2, v3 := 0.25, 3.0, 0.012345
+	tests := []struct {
+		clause   ClauseResult
+		expected string
+	}{
+		{ClauseResult{}, "n/a"},
+		{ClauseResult{Actual: &v1, Duration: true}, "0.2500s"},
+		{ClauseResult{Actual: &v2}, "3"},
+		{ClauseResult{Actual: &v3}, "0.0123"},
+	}
+
+	for _, test := range tests {
+		if found := test.clause.FormatActual(); found != test.expected {
+			t.Errorf("Expected %q, Found %q", test.expected, found)
+		}
+	}
+}
+
+func TestParseAbortRule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		expr      string
+		ident     Ident
+		window    time.Duration
+		windows   int
+		shouldErr bool
+	}{
+		{"Full", "steps.2.p95 > 800ms over 1m for 3 windows", Ident{Step: "2", Metric: "p95"}, time.Minute, 3, false},
+		{"Defaults", "steps.checkout.fail_rate >= 5%", Ident{Step: "checkout", Metric: "fail_rate"},
+			DefaultAbortWindow, 1, false},
+		{"CountFirst", `steps["Get Users"].avg_duration > 1s for 1 window over 30s`,
+			Ident{Step: "Get Users", Metric: "avg_duration"}, 30 * time.Second, 1, false},
+		{"ResultMetric", "result.fail_rate > 1%", Ident{}, 0, 0, true},
+		{"NotWindowMetric", "steps.2.request_count > 100", Ident{}, 0, 0, true},
+		{"ValueOnLeft", "800ms < steps.2.p95", Ident{}, 0, 0, true},
+		{"Logical", "steps.2.p95 > 1s && steps.2.p99 > 2s", Ident{}, 0, 0, true},
+		{"MissingWindow", "steps.2.p95 > 1s over", Ident{}, 0, 0, true},
+		{"InvalidWindow", "steps.2.p95 > 1s over 5", Ident{}, 0, 0, true},
+		{"ZeroWindows", "steps.2.p95 > 1s for 0 windows", Ident{}, 0, 0, true},
+		{"MissingWindowsWord", "steps.2.p95 > 1s for 3", Ident{}, 0, 0, true},
+		{"DuplicateClause", "steps.2.p95 > 1s over 1m over 2m", Ident{}, 0, 0, true},
+		{"DurationOfRate", "steps.2.fail_rate > 1s", Ident{}, 0, 0, true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := ParseAbortRule(test.expr)
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error occurred %v", err)
+			}
+			if r.Ident() != test.ident || r.Window != test.window || r.Windows != test.windows {
+				t.Errorf("Expected %v over %v for %d, Found %v over %v for %d", test.ident, test.window, test.windows,
+					r.Ident(), r.Window, r.Windows)
+			}
+			if r.String() != test.expr {
+				t.Errorf("Expected %q, Found %q", test.expr, r.String())
+			}
+		})
+	}
+}
+
+func TestAbortRuleViolated(t *testing.T) {
+	t.Parallel()
+
+	r, err := ParseAbortRule("steps.2.p95 > 800ms")
+	if err != nil {
+		t.Fatalf("Error occurred %v", err)
+	}
+	if !r.Violated(0.9) || r.Violated(0.8) {
+		t.Errorf("Rule should be violated only over 800ms")
+	}
+	if v := r.FormatValue(0.9); v != "0.9000s" {
+		t.Errorf("Expected 0.9000s, Found %s", v)
+	}
+}
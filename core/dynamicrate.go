@@ -0,0 +1,252 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.ddosify.com/ddosify/core/report"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Upper bound of the response body of the dynamic rate source
+const maxRateSourceBody = 1 << 20
+
+// ratePacer paces the iterations of the test by a rate that can be adjusted while the test is running.
+type ratePacer struct {
+	// math.Float64bits of the iterations per second
+	rate uint64
+
+	// Fractional iterations carried to the next tick, only used by the tick loop
+	carry float64
+}
+
+func (p *ratePacer) set(rps float64) {
+	atomic.StoreUint64(&p.rate, math.Float64bits(rps))
+}
+
+func (p *ratePacer) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&p.rate))
+}
+
+// next returns the iteration count of the next tick.
+func (p *ratePacer) next(tick time.Duration) int {
+	p.carry += p.get() * tick.Seconds()
+	n := int(p.carry)
+	p.carry -= float64(n)
+	return n
+}
+
+// rateSource polls the value of the dynamic rate from an HTTP endpoint or a Prometheus query.
+type rateSource struct {
+	config types.DynamicRate
+	expr   *types.RateExpression
+	client *http.Client
+}
+
+func newRateSource(d types.DynamicRate) (*rateSource, error) {
+	expr, err := types.ParseRateExpression(d.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return &rateSource{config: d, expr: expr, client: &http.Client{Timeout: d.PollInterval()}}, nil
+}
+
+// poll returns the current value of the source.
+func (s *rateSource) poll(ctx context.Context) (float64, error) {
+	target := s.config.URL
+	if s.config.Prometheus != "" {
+		target = strings.TrimSuffix(s.config.Prometheus, "/") + "/api/v1/query?query=" + url.QueryEscape(s.config.Query)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRateSourceBody))
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("source responded with %d", resp.StatusCode)
+	}
+	if s.config.Prometheus != "" {
+		return prometheusValue(body)
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(body)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("source response is not a number: %.32q", body)
+	}
+	return v, nil
+}
+
+// prometheusValue returns the value of the instant query response, the first sample of a vector or a scalar.
+func prometheusValue(body []byte) (float64, error) {
+	var r struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return 0, fmt.Errorf("invalid prometheus response: %v", err)
+	}
+	if r.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s", r.Error)
+	}
+
+	var sample []interface{}
+	switch r.Data.ResultType {
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(r.Data.Result, &vector); err != nil {
+			return 0, fmt.Errorf("invalid prometheus response: %v", err)
+		}
+		if len(vector) == 0 {
+			return 0, fmt.Errorf("prometheus query returned no sample")
+		}
+		sample = vector[0].Value
+	case "scalar":
+		if err := json.Unmarshal(r.Data.Result, &sample); err != nil {
+			return 0, fmt.Errorf("invalid prometheus response: %v", err)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported prometheus result type: %s", r.Data.ResultType)
+	}
+
+	// Samples are [timestamp, "value"] pairs
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("invalid prometheus sample: %v", sample)
+	}
+	str, _ := sample[1].(string)
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid prometheus sample: %v", sample)
+	}
+	return v, nil
+}
+
+// initDynamicRate polls the dynamic rate source once, so the test starts at the rate of the source. If the poll
+// fails, the test starts at the min rate. The load plan is planned at the initial rate.
+func (e *engine) initDynamicRate() (err error) {
+	if e.hammer.DynamicRate == nil {
+		return nil
+	}
+	if e.rateSource, err = newRateSource(*e.hammer.DynamicRate); err != nil {
+		return
+	}
+
+	e.pacer = &ratePacer{}
+	e.pacer.set(e.hammer.DynamicRate.Min)
+	e.pollRate(e.ctx)
+
+	e.hammer.LoadType = types.LoadTypeLinear
+	e.hammer.IterationCount = int(math.Round(e.pacer.get() * float64(e.hammer.TestDuration)))
+	return nil
+}
+
+// pollRate polls the source and adjusts the rate of the test. A failed poll keeps the last rate.
+func (e *engine) pollRate(ctx context.Context) {
+	v, err := e.rateSource.poll(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.logOut.printf("warning: dynamic rate poll failed, the rate stays at %g iterations per second: %v",
+				e.pacer.get(), err)
+		}
+		return
+	}
+	e.setRate(e.rateSource.expr.Eval(v), v)
+}
+
+// setRate bounds the rate by the min and max of the dynamic rate and paces the next ticks by it.
+// The report service records the change with the source value it is evaluated from.
+func (e *engine) setRate(rps float64, value float64) {
+	rps = e.hammer.DynamicRate.Clamp(rps)
+	if e.rateChanged && rps == e.pacer.get() {
+		return
+	}
+	e.pacer.set(rps)
+	e.rateChanged = true
+	if rs, ok := e.reportService.(report.RateAware); ok {
+		rs.RecordRateChange(report.RateChange{Time: e.clock.Now(), Value: value, Rate: rps})
+	}
+}
+
+// startRatePoller polls the dynamic rate source on its interval until the returned stop is called.
+func (e *engine) startRatePoller() (stop func()) {
+	if e.rateSource == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := e.clock.NewTicker(e.hammer.DynamicRate.PollInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				e.pollRate(ctx)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// resultBufferSize returns the capacity of the result channel, the ResultBufferSize of the test if it is set.
+// Otherwise it is the upper bound of the iteration count of the test, so the workers never block on it.
+func (e *engine) resultBufferSize() int {
+	if e.hammer.ResultBufferSize > 0 {
+		return e.hammer.ResultBufferSize
+	}
+	if e.pacer == nil {
+		return e.hammer.IterationCount
+	}
+	return int(math.Ceil(e.hammer.DynamicRate.Max*float64(e.hammer.TestDuration))) + 1
+}
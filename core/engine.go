@@ -22,15 +22,25 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"math"
+	"net/url"
+	"os"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/live"
 	"go.ddosify.com/ddosify/core/proxy"
 	"go.ddosify.com/ddosify/core/report"
 	"go.ddosify.com/ddosify/core/scenario"
 	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
 )
 
 const (
@@ -40,11 +50,17 @@ const (
 	// test result status
 	resultDone    = "done"
 	resultStopped = "stopped"
+
+	// pacing of the iterations in the verify mode
+	verifyInterval = time.Second
 )
 
 type engine struct {
 	hammer types.Hammer
 
+	// Clock of the hammer, the real clock if it is not set
+	clock clock.Clock
+
 	proxyService    proxy.ProxyService
 	scenarioService *scenario.ScenarioService
 	reportService   report.ReportService
@@ -53,9 +69,52 @@ type engine struct {
 	reqCountArr []int
 	wg          sync.WaitGroup
 
+	// Set if the test has a dynamic rate, the ticks are paced by it instead of reqCountArr
+	pacer       *ratePacer
+	rateSource  *rateSource
+	rateChanged bool
+
+	// Set if the test has bursts, the planned ticks of reqCountArr fire the bursts
+	bursts *burstRunner
+
+	// Set if the test has virtual users, the only planned tick of reqCountArr starts them
+	users *userRunner
+
 	resultChan chan *types.ScenarioResult
 
-	ctx context.Context
+	// Instrumented sends of the load test into resultChan
+	results *resultQueue
+
+	// Generator metrics, written by the workers and read by WriteStatus
+	startedAt           int64 // UnixNano
+	startedIterations   int64
+	inFlight            int64
+	completedIterations int64
+
+	// Drain phase of the gracefully stopped test, the results of the iterations completed after drainStartedAt are
+	// tagged as drained
+	drainStartedAt  int64 // UnixNano, 0 until the test is stopped
+	completedAtStop int64
+	drain           *report.DrainSummary
+
+	// Closed by Abort to stop without waiting the in-flight iterations
+	abortChan chan struct{}
+	abortOnce sync.Once
+
+	// Notices of the engine, like the prewarm results and the unprocessed results at shutdown, are logged into it.
+	// The lines are rate limited, so the warnings repeated by a failing target don't flood the terminal.
+	logOut *logLimiter
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Context of the requests, it outlives ctx so the in-flight iterations of a stopped test complete. Canceled by
+	// Abort and the shutdown deadline of the drain.
+	reqCtx    context.Context
+	reqCancel context.CancelFunc
+
+	// Previous run of the -compare flag checked against the test, nil if the test has no baseline
+	baseline *report.Baseline
 }
 
 // NewEngine is the constructor of the engine.
@@ -77,50 +136,241 @@ func NewEngine(ctx context.Context, h types.Hammer) (e *engine, err error) {
 		return
 	}
 
+	c := h.Clock
+	if c == nil {
+		c = clock.Real()
+	}
 	ss := scenario.NewScenarioService()
+	ss.SetClock(c)
+	ss.SetWarmState(h.WarmState)
 
+	// Engine cancels its own context to stop the test when a limit is reached.
+	ctx, cancel := context.WithCancel(ctx)
+	reqCtx, reqCancel := context.WithCancel(context.Background())
 	e = &engine{
 		hammer:          h,
+		clock:           c,
 		ctx:             ctx,
+		cancel:          cancel,
+		reqCtx:          reqCtx,
+		reqCancel:       reqCancel,
 		proxyService:    ps,
 		scenarioService: ss,
 		reportService:   rs,
+		abortChan:       make(chan struct{}),
+		logOut:          newLogLimiter(os.Stderr),
 	}
 
 	return
 }
 
 func (e *engine) Init() (err error) {
+	// Incompatible baseline is refused before any request is sent
+	if err = e.initBaseline(); err != nil {
+		return
+	}
 	if err = e.proxyService.Init(e.hammer.Proxy); err != nil {
 		return
 	}
 
-	if err = e.scenarioService.Init(e.ctx, e.hammer.Scenario, e.proxyService.GetAll(), e.hammer.Debug); err != nil {
+	// Generated seed is reported, so the run can be replayed.
+	if e.hammer.Scenario.Seed == 0 {
+		e.hammer.Scenario.Seed = util.NewSeed()
+	}
+	// Run id and hostname tag the records of the outputs, so the runs can be told apart.
+	if e.hammer.Metadata.RunID == "" {
+		e.hammer.Metadata.RunID = uuid.NewString()
+	}
+	if e.hammer.Metadata.Hostname == "" {
+		e.hammer.Metadata.Hostname, _ = os.Hostname()
+	}
+	if ws := e.hammer.WarmState; ws != nil {
+		e.hammer.Metadata.Start = types.StartCold
+		if ws.Begin() {
+			e.hammer.Metadata.Start = types.StartWarm
+		}
+	}
+	e.warnURLEncodings()
+	if e.hammer.VirtualUsers != nil {
+		e.warnUserTargets()
+	}
+	// Capture count without a rate samples the iterations uniformly over the test.
+	if c := &e.hammer.Scenario.Capture; c.Rate == 0 && c.Count > 0 && e.hammer.IterationCount > 0 {
+		c.Rate = math.Min(1, float64(c.Count)/float64(e.hammer.IterationCount))
+	}
+	if e.hammer.VerifyCount > 0 {
+		e.hammer.Scenario.Steps = strictSteps(e.hammer.Scenario.Steps)
+	}
+	// Verify mode captures the full detail of all the requests, the failed ones are reported with it.
+	debug := e.hammer.Debug || e.hammer.VerifyCount > 0
+	if err = e.scenarioService.Init(e.reqCtx, e.hammer.Scenario, e.proxyService.GetAll(), debug); err != nil {
+		return
+	}
+	probes, err := e.preflight()
+	if err != nil {
+		return
+	}
+	if err = e.prewarm(); err != nil {
 		return
 	}
 
-	if err = e.reportService.Init(e.hammer.Debug); err != nil {
+	if rs, ok := e.reportService.(report.ClockAware); ok {
+		rs.SetClock(e.clock)
+	}
+	// Report services print the rendered requests of the preview in the debug format.
+	if err = report.InitReport(e.reportService, e.hammer.Debug || e.hammer.PreviewCount > 0,
+		report.NewRunInfo(e.hammer)); err != nil {
+		return
+	}
+	if rs, ok := e.reportService.(report.PercentilesAware); ok {
+		rs.SetPercentiles(e.hammer.Percentiles)
+	}
+	if rs, ok := e.reportService.(report.ScenarioAware); ok {
+		rs.SetScenario(e.hammer.Scenario)
+	}
+	if rs, ok := e.reportService.(report.ScheduleAware); ok && e.hammer.Schedule != nil {
+		rs.SetSchedule(e.hammer.Schedule)
+	}
+	if rs, ok := e.reportService.(report.PreflightAware); ok && len(probes) > 0 {
+		rs.SetPreflight(probes)
+	}
+	if rs, ok := e.reportService.(report.MetadataAware); ok {
+		rs.SetMetadata(e.hammer.Metadata)
+	}
+	if rs, ok := e.reportService.(report.MetricMetaAware); ok {
+		rs.SetMetricMeta(e.scenarioService.MetricMeta())
+	}
+	if rs, ok := e.reportService.(report.HeadlessAware); ok {
+		if err = rs.SetHeadless(e.hammer.Headless); err != nil {
+			return
+		}
+	}
+	if err = e.initVerify(); err != nil {
+		return
+	}
+	if err = e.initCriteria(); err != nil {
+		return
+	}
+	if err = e.initLiveTemplate(); err != nil {
+		return
+	}
+	if err = e.initStopLimits(); err != nil {
+		return
+	}
+	if err = e.initDynamicRate(); err != nil {
 		return
 	}
 
 	e.initReqCountArr()
-	return
+	if rs, ok := e.reportService.(report.LoadPlanAware); ok {
+		rs.SetLoadPlan(time.Duration(tickerInterval)*time.Millisecond, e.reqCountArr)
+	}
+	// Debug and preview runs send a single iteration or none, their estimate is not reported
+	if rs, ok := e.reportService.(report.EstimateAware); ok && !e.hammer.Debug && e.hammer.PreviewCount == 0 {
+		rs.SetEstimate(e.estimate())
+	}
+	// Echo is set once the load is planned, the dynamic rate overrides the load type of the hammer.
+	if rs, ok := e.reportService.(report.ConfigAware); ok && !e.hammer.NoConfigEcho {
+		rs.SetConfig(e.hammer, e.proxyService.GetAll())
+	}
+	if rs, ok := e.reportService.(report.BaselineAware); ok && e.baseline != nil {
+		rs.SetBaseline(e.baseline)
+	}
+	return e.initAnonymizer()
+}
+
+// initBaseline loads the baseline of the -compare flag and checks that the load and the steps of the test match it,
+// unless the comparison is forced.
+func (e *engine) initBaseline() (err error) {
+	if e.hammer.Compare == "" {
+		return nil
+	}
+	b, err := report.LoadBaseline(e.hammer.Compare)
+	if err != nil {
+		return
+	}
+	if err = b.Check(e.hammer, e.hammer.ForceCompare); err != nil {
+		return
+	}
+	e.baseline = b
+	return nil
+}
+
+// initAnonymizer passes the anonymizer of the report to the report service. The hosts of the steps are numbered
+// first in the step order, then the proxies, the dynamic rate source and the hostname of the generator.
+func (e *engine) initAnonymizer() error {
+	if !e.hammer.Anonymize {
+		return nil
+	}
+
+	rs, ok := e.reportService.(report.AnonymizeAware)
+	if !ok {
+		return fmt.Errorf("anonymize is not supported by the %s output", e.hammer.ReportDestination)
+	}
+	var hosts []string
+	addHost := func(rawURL string) {
+		if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	for _, steps := range [][]types.ScenarioStep{e.hammer.Scenario.Steps, e.hammer.Scenario.Teardown} {
+		for _, st := range steps {
+			addHost(st.URL)
+		}
+	}
+	for _, p := range e.proxyService.GetAll() {
+		if p != nil {
+			hosts = append(hosts, p.Host)
+		}
+	}
+	if d := e.hammer.DynamicRate; d != nil {
+		addHost(d.URL)
+		addHost(d.Prometheus)
+	}
+	hosts = append(hosts, e.hammer.Metadata.Hostname)
+	rs.SetAnonymizer(report.NewAnonymizer(hosts...))
+	return nil
+}
+
+// warnURLEncodings warns the URLs of the steps that are encoded on the wire, with the URL that is sent.
+func (e *engine) warnURLEncodings() {
+	for _, steps := range [][]types.ScenarioStep{e.hammer.Scenario.Steps, e.hammer.Scenario.Teardown} {
+		for _, st := range steps {
+			if wire, changed, err := types.WireURLString(st.URL); err == nil && changed {
+				e.logOut.printf("warning: step %d: url %s is sent as %s", st.ID, types.RedactURL(st.URL),
+					types.RedactURL(wire))
+			}
+		}
+	}
 }
 
 func (e *engine) Start() string {
-	ticker := time.NewTicker(time.Duration(tickerInterval) * time.Millisecond)
-	e.resultChan = make(chan *types.ScenarioResult, e.hammer.IterationCount)
+	if e.hammer.PreviewCount > 0 {
+		return e.preview()
+	}
+	if e.hammer.VerifyCount > 0 {
+		return e.verify()
+	}
+
+	ticker := e.clock.NewTicker(time.Duration(tickerInterval) * time.Millisecond)
+	e.resultChan = make(chan *types.ScenarioResult, e.resultBufferSize())
+	e.results = newResultQueue(e.resultChan, e.abortChan)
 	go e.reportService.Start(e.resultChan)
 
+	stopRatePoller := e.startRatePoller()
+	stopTimeoutAdapter := e.startTimeoutAdapter()
 	defer func() {
 		ticker.Stop()
+		stopRatePoller()
+		stopTimeoutAdapter()
 		e.stop()
 	}()
 
 	e.tickCounter = 0
+	atomic.StoreInt64(&e.startedAt, e.clock.Now().UnixNano())
 	e.wg = sync.WaitGroup{}
 	var mutex = &sync.Mutex{}
-	for range ticker.C {
+	for range ticker.C() {
 		if e.tickCounter >= len(e.reqCountArr) {
 			return resultDone
 		}
@@ -130,8 +380,21 @@ func (e *engine) Start() string {
 			return resultStopped
 		default:
 			mutex.Lock()
-			e.wg.Add(e.reqCountArr[e.tickCounter])
-			go e.runWorkers(e.tickCounter)
+			e.results.sample()
+			count := e.reqCountArr[e.tickCounter]
+			if e.pacer != nil {
+				count = e.pacer.next(time.Duration(tickerInterval) * time.Millisecond)
+			}
+			if e.bursts != nil {
+				if count > 0 {
+					e.bursts.fire(count)
+				}
+			} else if e.users != nil {
+				e.users.start()
+			} else {
+				e.wg.Add(count)
+				go e.runWorkers(count)
+			}
 			e.tickCounter++
 			mutex.Unlock()
 		}
@@ -139,24 +402,124 @@ func (e *engine) Start() string {
 	return resultDone
 }
 
-func (e *engine) runWorkers(c int) {
-	for i := 1; i <= e.reqCountArr[c]; i++ {
-		scenarioStartTime := time.Now()
+// preview renders the scenario PreviewCount times and passes the results to the report service
+// without sending any request to the target.
+func (e *engine) preview() string {
+	e.resultChan = make(chan *types.ScenarioResult, e.hammer.PreviewCount)
+	go e.reportService.Start(e.resultChan)
+
+	// Previews send no request, the result listener gets no result
+	defer func() {
+		e.closeResultListener()
+		close(e.resultChan)
+		e.waitReportService()
+		e.proxyService.Done()
+		e.scenarioService.Done()
+		e.reqCancel()
+	}()
+
+	p := e.proxyService.GetProxy()
+	for i := 0; i < e.hammer.PreviewCount; i++ {
+		select {
+		case <-e.ctx.Done():
+			return resultStopped
+		default:
+		}
+
+		res, err := e.scenarioService.Preview(p)
+		if err != nil {
+			return resultStopped
+		}
+		e.resultChan <- res
+	}
+	return resultDone
+}
+
+// verify runs the scenario VerifyCount times serially, one iteration per verifyInterval, and passes the results
+// to the report service. Unlike the load test, an iteration doesn't start before the previous one is finished.
+func (e *engine) verify() string {
+	e.resultChan = make(chan *types.ScenarioResult, e.hammer.VerifyCount)
+	go e.reportService.Start(e.resultChan)
+
+	defer func() {
+		e.closeResultListener()
+		e.teardown()
+		close(e.resultChan)
+		e.waitReportService()
+		e.proxyService.Done()
+		e.scenarioService.Done()
+		e.logOut.flush()
+		e.reqCancel()
+	}()
+
+	atomic.StoreInt64(&e.startedAt, e.clock.Now().UnixNano())
+	p := e.proxyService.GetProxy()
+	next := e.clock.Now()
+	for i := 0; i < e.hammer.VerifyCount; i++ {
+		select {
+		case <-e.ctx.Done():
+			return resultStopped
+		case <-e.clock.After(e.clock.Until(next)):
+		}
+
+		start := e.clock.Now()
+		next = start.Add(verifyInterval)
+		atomic.AddInt64(&e.startedIterations, 1)
+		res, err := e.scenarioService.Do(p, start)
+		if err != nil && err.Type == types.ErrorIntented {
+			return resultStopped
+		}
+		if res == nil {
+			// Requesters of the proxy can not be created, the iteration has no step result to report.
+			e.logOut.printf("warning: iteration is not run: %s", err.Reason)
+			continue
+		}
+
+		res.Others = make(map[string]interface{})
+		res.Others["hammerOthers"] = e.hammer.Others
+		res.Others["proxyCountry"] = e.proxyService.GetProxyCountry(p)
+		if e.hammer.ResultListener != nil {
+			e.hammer.ResultListener.Dispatch(res)
+		}
+		e.resultChan <- res
+	}
+	return resultDone
+}
+
+func (e *engine) runWorkers(count int) {
+	for i := 1; i <= count; i++ {
+		scenarioStartTime := e.clock.Now()
+		atomic.AddInt64(&e.startedIterations, 1)
+		atomic.AddInt64(&e.inFlight, 1)
 		go func(t time.Time) {
 			e.runWorker(t)
+			atomic.AddInt64(&e.completedIterations, 1)
+			atomic.AddInt64(&e.inFlight, -1)
 			e.wg.Done()
 		}(scenarioStartTime)
 	}
 }
 
-func (e *engine) runWorker(scenarioStartTime time.Time) {
+// runWorker runs an iteration and passes its result to the report service. The result is returned,
+// nil if it is not reported.
+func (e *engine) runWorker(scenarioStartTime time.Time) *types.ScenarioResult {
+	return e.runIteration(scenarioStartTime, nil)
+}
+
+// runIteration is runWorker for an iteration of a virtual user of the vu load type, ui is nil for the other
+// load types.
+func (e *engine) runIteration(scenarioStartTime time.Time, ui *types.UserIteration) *types.ScenarioResult {
 	var res *types.ScenarioResult
 	var err *types.RequestError
 
 	p := e.proxyService.GetProxy()
 	retryCount := 3
 	for i := 1; i <= retryCount; i++ {
-		res, err = e.scenarioService.Do(p, scenarioStartTime)
+		if ui != nil {
+			res, err = e.scenarioService.DoUser(p, scenarioStartTime, *ui)
+		} else {
+			res, err = e.scenarioService.Do(p, scenarioStartTime)
+		}
 
 		if err != nil && err.Type == types.ErrorProxy {
 			p = e.proxyService.ReportProxy(p, err.Reason)
@@ -165,23 +528,397 @@ func (e *engine) runWorker(scenarioStartTime time.Time) {
 
 		if err != nil && err.Type == types.ErrorIntented {
 			// Don't report intentionally created errors. Like canceled requests.
-			return
+			return nil
 		}
 		break
 	}
 
+	if res == nil {
+		// Requesters of the proxy can not be created, the iteration has no step result to report.
+		e.logOut.printf("warning: iteration is not run: %s", err.Reason)
+		return nil
+	}
+
 	res.Others = make(map[string]interface{})
 	res.Others["hammerOthers"] = e.hammer.Others
 	res.Others["proxyCountry"] = e.proxyService.GetProxyCountry(p)
-	e.resultChan <- res
+	if atomic.LoadInt64(&e.drainStartedAt) != 0 {
+		res.Others["drained"] = true
+	}
+	if e.hammer.ResultListener != nil {
+		e.hammer.ResultListener.Dispatch(res)
+	}
+	e.results.send(res)
+	return res
+}
+
+// closeResultListener closes the result listener of the hammer once no iteration dispatches to it.
+func (e *engine) closeResultListener() {
+	if e.hammer.ResultListener != nil {
+		e.hammer.ResultListener.Close()
+	}
 }
 
 func (e *engine) stop() {
-	e.wg.Wait()
-	close(e.resultChan)
-	<-e.reportService.DoneChan()
+	drained := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(drained)
+	}()
+
+	stopped := e.ctx.Done()
+	var deadline <-chan time.Time
+	for {
+		select {
+		case <-stopped:
+			// Stopped test drains its in-flight iterations until the shutdown deadline
+			stopped = nil
+			if e.reqCtx.Err() == nil {
+				t := e.clock.NewTimer(e.shutdownTimeout())
+				defer t.Stop()
+				deadline = t.C()
+				e.startDrain()
+			}
+			continue
+		case <-deadline:
+			deadline = nil
+			e.cancelDrain()
+			continue
+		case <-drained:
+			e.closeResultListener()
+			e.reportGeneratorHealth()
+			e.reportDrain()
+			e.teardown()
+			close(e.resultChan)
+			e.waitReportService()
+		case <-e.abortChan:
+			// In-flight iterations may still write to the result channel, so it is left open.
+			// Their results are dropped instead of blocking on a full channel.
+			e.closeResultListener()
+			if len(e.hammer.Scenario.Teardown) > 0 {
+				e.logOut.printf("teardown is skipped, test is aborted")
+			}
+			if rs, ok := e.reportService.(report.Abortable); ok && !e.hammer.Debug {
+				rs.Abort()
+				e.waitReportService()
+			}
+		}
+		break
+	}
 	e.proxyService.Done()
 	e.scenarioService.Done()
+	e.logOut.flush()
+	e.reqCancel()
+	e.cancel()
+}
+
+// startDrain starts the drain phase of the gracefully stopped test. No new iteration is started, the in-flight ones
+// are waited and the results they complete are tagged as drained.
+func (e *engine) startDrain() {
+	now := e.clock.Now()
+	atomic.StoreInt64(&e.drainStartedAt, now.UnixNano())
+	e.completedAtStop = atomic.LoadInt64(&e.completedIterations)
+	e.drain = &report.DrainSummary{StartedAt: now, InFlight: atomic.LoadInt64(&e.inFlight)}
+	if e.drain.InFlight > 0 {
+		e.logOut.printf("test is stopped, draining %d in-flight iterations, CTRL+C again to abort", e.drain.InFlight)
+	}
+
+	if rs, ok := e.reportService.(report.DrainAware); ok {
+		rs.StartDrain(func() report.DrainProgress {
+			return report.DrainProgress{
+				InFlight:  atomic.LoadInt64(&e.inFlight),
+				Completed: atomic.LoadInt64(&e.completedIterations) - e.completedAtStop,
+			}
+		})
+	}
+}
+
+// cancelDrain cancels the requests of the iterations still in flight at the shutdown deadline of the drain.
+func (e *engine) cancelDrain() {
+	e.drain.Canceled = atomic.LoadInt64(&e.inFlight)
+	e.logOut.printf("%d in-flight iterations are canceled at the shutdown deadline of %s", e.drain.Canceled,
+		e.shutdownTimeout())
+	e.reqCancel()
+}
+
+// reportDrain passes the drain phase to the report service once the in-flight iterations are finished.
+func (e *engine) reportDrain() {
+	if e.drain == nil {
+		return
+	}
+	e.drain.Duration = float32(e.clock.Since(e.drain.StartedAt).Seconds())
+	e.drain.Completed = atomic.LoadInt64(&e.completedIterations) - e.completedAtStop - e.drain.Canceled
+	if rs, ok := e.reportService.(report.DrainAware); ok {
+		rs.SetDrain(*e.drain)
+	}
+}
+
+// reportGeneratorHealth passes the back-pressure of the result channel to the report service, and warns if
+// the iterations waited on the full channel over ResultBlockWarning in total.
+func (e *engine) reportGeneratorHealth() {
+	warnAfter := e.hammer.ResultBlockWarning
+	if warnAfter == 0 {
+		warnAfter = types.DefaultResultBlockWarning
+	}
+
+	g := e.results.health(warnAfter)
+	if g.BlockWarning {
+		e.logOut.printf("warning: iterations waited %s in total to send their results to the %s output, "+
+			"a larger result_buffer_size or a faster output is suggested",
+			e.results.blockedDuration().Round(time.Millisecond), e.hammer.ReportDestination)
+	}
+	if rs, ok := e.reportService.(report.GeneratorHealthAware); ok {
+		rs.SetGeneratorHealth(g)
+	}
+}
+
+// teardown runs the teardown steps once the iterations are finished, even if the test is stopped by its ctx,
+// and passes their results to the report service. Abort cancels the teardown, the values left are reported as skipped.
+func (e *engine) teardown() {
+	if len(e.hammer.Scenario.Teardown) == 0 {
+		return
+	}
+
+	e.logOut.printf("running the teardown steps, CTRL+C again to abort")
+	var summaries []report.TeardownSummary
+	for _, r := range e.scenarioService.Teardown() {
+		t := report.TeardownSummary{
+			StepID:         r.StepID,
+			StepName:       r.StepName,
+			Foreach:        r.Foreach,
+			Collected:      r.Collected,
+			Dropped:        r.Dropped,
+			Processed:      r.Processed,
+			Failed:         r.Failed,
+			Skipped:        r.Skipped,
+			Requests:       r.Requests,
+			FailedRequests: r.FailedRequests,
+		}
+		if r.Err != nil {
+			t.Error = fmt.Sprintf("collected values can not be read: %v", r.Err)
+		}
+		summaries = append(summaries, t)
+	}
+	if rs, ok := e.reportService.(report.TeardownAware); ok {
+		rs.SetTeardown(summaries)
+	}
+}
+
+// waitReportService waits the report service to consume the results until the shutdown deadline. Past the deadline,
+// the count of the results left in the result channel is logged and the engine stops without waiting the report
+// service, so a slow output doesn't hang the process.
+func (e *engine) waitReportService() {
+	timeout := e.shutdownTimeout()
+	t := e.clock.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-e.reportService.DoneChan():
+	case <-t.C():
+		e.logOut.printf("%s output did not finish in %s, %d results are left unprocessed",
+			e.hammer.ReportDestination, timeout, len(e.resultChan))
+	}
+}
+
+func (e *engine) shutdownTimeout() time.Duration {
+	if e.hammer.ShutdownTimeout == 0 {
+		return types.DefaultShutdownTimeout
+	}
+	return e.hammer.ShutdownTimeout
+}
+
+// preflight probes the connectivity of the targets of the steps before the test, so a typo in a host fails the test
+// with the step and the error instead of a run where all the requests fail. Returns the latencies of the probes
+// as the baseline of the report. Preview mode doesn't connect to the target.
+func (e *engine) preflight() (probes []report.PreflightProbe, err error) {
+	if e.hammer.SkipPreflight || e.hammer.PreviewCount > 0 {
+		return nil, nil
+	}
+
+	for _, r := range e.scenarioService.Preflight() {
+		if r.Err != nil {
+			return nil, fmt.Errorf("step %d: preflight to %s failed: %v (skip_preflight skips the check)",
+				r.StepID, r.Addr, r.Err)
+		}
+		probes = append(probes, report.PreflightProbe{StepID: r.StepID, Addr: r.Addr, Latency: r.Latency.Seconds()})
+	}
+	return probes, nil
+}
+
+// prewarm establishes the connections of the steps before the test and logs how many of them are established.
+// A failed prewarm is a warning unless the step has prewarm-required. Preview mode doesn't connect to the target.
+func (e *engine) prewarm() error {
+	if e.hammer.PreviewCount > 0 {
+		return nil
+	}
+
+	for _, r := range e.scenarioService.Prewarm() {
+		if r.Err == nil {
+			e.logOut.printf("step %d: %d connections are prewarmed", r.StepID, r.Established)
+			continue
+		}
+		if r.Required {
+			return fmt.Errorf("step %d: %d of %d connections are prewarmed: %v", r.StepID, r.Established, r.Requested, r.Err)
+		}
+		e.logOut.printf("warning: step %d: %d of %d connections are prewarmed: %v",
+			r.StepID, r.Established, r.Requested, r.Err)
+	}
+	return nil
+}
+
+// initVerify passes the planned iteration count of the verify mode to the report service.
+func (e *engine) initVerify() error {
+	if e.hammer.VerifyCount == 0 {
+		return nil
+	}
+
+	rs, ok := e.reportService.(report.VerifyAware)
+	if !ok {
+		return fmt.Errorf("verify is not supported by the %s output", e.hammer.ReportDestination)
+	}
+	rs.SetVerify(e.hammer.VerifyCount)
+	return nil
+}
+
+// strictSteps returns a copy of the steps whose assertions are applied to all the responses instead of a sample.
+func strictSteps(steps []types.ScenarioStep) []types.ScenarioStep {
+	strict := make([]types.ScenarioStep, len(steps))
+	for i, s := range steps {
+		if _, ok := s.Custom["json-schema-sample"]; ok {
+			custom := make(map[string]interface{}, len(s.Custom))
+			for k, v := range s.Custom {
+				custom[k] = v
+			}
+			delete(custom, "json-schema-sample")
+			s.Custom = custom
+		}
+		strict[i] = s
+	}
+	return strict
+}
+
+// initCriteria passes the success criteria to the report service. The criteria is ignored in the debug, preview
+// and verify modes since they have no load result to evaluate.
+func (e *engine) initCriteria() error {
+	if e.hammer.SuccessCriteria == "" || e.hammer.Debug || e.hammer.PreviewCount > 0 || e.hammer.VerifyCount > 0 {
+		return nil
+	}
+
+	c, err := criteria.Parse(e.hammer.SuccessCriteria)
+	if err != nil {
+		return err
+	}
+	rs, ok := e.reportService.(report.CriteriaAware)
+	if !ok {
+		return fmt.Errorf("success criteria is not supported by the %s output", e.hammer.ReportDestination)
+	}
+	rs.SetCriteria(c)
+	return nil
+}
+
+// initLiveTemplate passes the live template to the report service. The template is ignored in the debug, preview
+// and verify modes since they have no live result.
+func (e *engine) initLiveTemplate() error {
+	if e.hammer.LiveTemplate == "" || e.hammer.Debug || e.hammer.PreviewCount > 0 || e.hammer.VerifyCount > 0 {
+		return nil
+	}
+
+	t, err := live.Parse(e.hammer.LiveTemplate)
+	if err != nil {
+		return err
+	}
+	rs, ok := e.reportService.(report.LiveTemplateAware)
+	if !ok {
+		return fmt.Errorf("live template is not supported by the %s output", e.hammer.ReportDestination)
+	}
+	rs.SetLiveTemplate(t)
+	return nil
+}
+
+// initStopLimits passes the failed request and transfer limits and the abort rules to the report service, which
+// cancels the engine context once a limit is reached or a rule holds. The limits are ignored in the debug, preview and verify modes.
+func (e *engine) initStopLimits() error {
+	l := report.StopLimits{Failures: e.hammer.StopAfterFailures, Transfer: e.hammer.MaxTransfer}
+	for _, expr := range e.hammer.AbortRules {
+		r, err := criteria.ParseAbortRule(expr)
+		if err != nil {
+			return err
+		}
+		stepID, err := types.ResolveAbortRuleStep(e.hammer.Scenario.Steps, r.Ident().Step)
+		if err != nil {
+			return err
+		}
+		l.AbortRules = append(l.AbortRules, report.AbortRule{Rule: r, StepID: stepID})
+	}
+	if (l.Failures == 0 && l.Transfer == 0 && len(l.AbortRules) == 0) ||
+		e.hammer.Debug || e.hammer.PreviewCount > 0 || e.hammer.VerifyCount > 0 {
+		return nil
+	}
+
+	rs, ok := e.reportService.(report.StopLimitAware)
+	if !ok {
+		if len(l.AbortRules) > 0 {
+			return fmt.Errorf("abort rules are not supported by the %s output", e.hammer.ReportDestination)
+		}
+		if l.Failures == 0 {
+			return fmt.Errorf("max transfer is not supported by the %s output", e.hammer.ReportDestination)
+		}
+		return fmt.Errorf("stop after failures is not supported by the %s output", e.hammer.ReportDestination)
+	}
+	rs.SetStopLimits(l, e.cancel)
+	return nil
+}
+
+// CriteriaResult returns the evaluation of the success criteria once the test is finished.
+// Returns nil if the test has no success criteria.
+func (e *engine) CriteriaResult() *criteria.Result {
+	if rs, ok := e.reportService.(report.CriteriaAware); ok && e.hammer.SuccessCriteria != "" {
+		return rs.CriteriaResult()
+	}
+	return nil
+}
+
+// VerifyResult returns the pass/fail matrix of the verify mode once the test is finished.
+// Returns nil if the test is not run in the verify mode.
+func (e *engine) VerifyResult() *report.VerifyResult {
+	if rs, ok := e.reportService.(report.VerifyAware); ok && e.hammer.VerifyCount > 0 {
+		return rs.VerifyResult()
+	}
+	return nil
+}
+
+// Abort stops the test without waiting the in-flight iterations. The report services print the results
+// collected so far as partial. The ctx given to NewEngine should be canceled along with it.
+// A running teardown is canceled, its results are reported with the values left as skipped.
+func (e *engine) Abort() {
+	e.abortOnce.Do(func() {
+		close(e.abortChan)
+		e.reqCancel()
+		e.scenarioService.AbortTeardown()
+	})
+}
+
+// WriteStatus writes the live stats of the running test into w, without stopping the test.
+func (e *engine) WriteStatus(w io.Writer) {
+	var elapsed time.Duration
+	if startedAt := atomic.LoadInt64(&e.startedAt); startedAt != 0 {
+		elapsed = e.clock.Since(time.Unix(0, startedAt)).Truncate(time.Second)
+	}
+	planned := 0
+	for _, c := range e.reqCountArr {
+		planned += c
+	}
+
+	if e.pacer != nil {
+		// Planned count of the dynamic rate is not known up front.
+		fmt.Fprintf(w, "Elapsed: %s  Started Iterations: %d  Rate: %g/s  In-flight Iterations: %d\n", elapsed,
+			atomic.LoadInt64(&e.startedIterations), e.pacer.get(), atomic.LoadInt64(&e.inFlight))
+	} else {
+		fmt.Fprintf(w, "Elapsed: %s  Started Iterations: %d/%d  In-flight Iterations: %d\n", elapsed,
+			atomic.LoadInt64(&e.startedIterations), planned, atomic.LoadInt64(&e.inFlight))
+	}
+	if rs, ok := e.reportService.(report.StatusWriter); ok {
+		rs.WriteStatus(w)
+	}
 }
 
 func (e *engine) initReqCountArr() {
@@ -202,6 +939,12 @@ func (e *engine) initReqCountArr() {
 			e.createIncrementalReqCountArr()
 		case types.LoadTypeWaved:
 			e.createWavedReqCountArr()
+		case types.LoadTypeSchedule:
+			e.createScheduleReqCountArr(e.clock.Now())
+		case types.LoadTypeBurst:
+			e.createBurstReqCountArr()
+		case types.LoadTypeVU:
+			e.createUsersReqCountArr()
 		}
 	}
 }
@@ -276,6 +1019,46 @@ func (e *engine) createWavedReqCountArr() {
 	}
 }
 
+// createScheduleReqCountArr plans each tick by the target rate of the schedule at the wall clock time of the tick,
+// as if the test starts at start. The iteration count of the test is the sum of the plan.
+func (e *engine) createScheduleReqCountArr(start time.Time) {
+	tick := time.Duration(tickerInterval) * time.Millisecond
+	var carry float64
+	total := 0
+	for i := range e.reqCountArr {
+		// Engine ticker fires at the end of each tick interval.
+		carry += e.hammer.Schedule.RateAt(start.Add(tick*time.Duration(i+1))) * tick.Seconds()
+		n := int(carry)
+		carry -= float64(n)
+		e.reqCountArr[i] = n
+		total += n
+	}
+	e.hammer.IterationCount = total
+}
+
+// createBurstReqCountArr plans a burst on the first tick of each burst interval, up to the burst count or until the
+// test duration is over. The iteration count of the test is the sum of the bursts.
+func (e *engine) createBurstReqCountArr() {
+	b := e.hammer.Burst
+	step := int(burstTicks(b))
+	total := 0
+	for i, t := 0, 0; t < len(e.reqCountArr) && (b.Count == 0 || i < b.Count); i, t = i+1, t+step {
+		e.reqCountArr[t] = b.Size
+		total += b.Size
+	}
+	e.hammer.IterationCount = total
+	e.bursts = newBurstRunner(e, b)
+}
+
+// createUsersReqCountArr plans all the iterations of the virtual users on the first tick, which starts the users.
+// The users run their iterations regardless of the test duration.
+func (e *engine) createUsersReqCountArr() {
+	v := e.hammer.VirtualUsers
+	e.hammer.IterationCount = v.IterationCount()
+	e.reqCountArr = []int{e.hammer.IterationCount}
+	e.users = newUserRunner(e, v)
+}
+
 func createLinearDistArr(count int, arr []int) {
 	arrLen := len(arr)
 	minReqCount := int(count / arrLen)
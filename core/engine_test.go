@@ -21,26 +21,34 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ddosify/go-faker/faker"
+	"github.com/google/uuid"
+	"go.ddosify.com/ddosify/core/clock"
 	"go.ddosify.com/ddosify/core/proxy"
 	"go.ddosify.com/ddosify/core/report"
+	"go.ddosify.com/ddosify/core/testserver"
 	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/warm"
 )
 
 //TODO: Engine stop channel close order test
@@ -52,6 +60,8 @@ func newDummyHammer() types.Hammer {
 		LoadType:          types.LoadTypeLinear,
 		TestDuration:      1,
 		IterationCount:    1,
+		// Nothing listens on the target of the dummy step, the preflight is tested by TestEnginePreflight.
+		SkipPreflight: true,
 		Scenario: types.Scenario{
 			Steps: []types.ScenarioStep{
 				{
@@ -65,6 +75,33 @@ func newDummyHammer() types.Hammer {
 	}
 }
 
+// startOnFakeClock starts the test of the engine on the fake clock, the next tick is advanced after the iterations
+// of the previous ticks are completed. It returns the result of Start, the clock isn't advanced after the planned
+// ticks so the timers of the shutdown don't fire.
+func startOnFakeClock(e *engine, c *clock.Fake) string {
+	result := make(chan string, 1)
+	go func() { result <- e.Start() }()
+	for atomic.LoadInt64(&e.startedAt) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	planned := 0
+	for i := 0; i <= len(e.reqCountArr); i++ {
+		c.Advance(time.Duration(tickerInterval) * time.Millisecond)
+		if i < len(e.reqCountArr) {
+			planned += e.reqCountArr[i]
+		}
+		for atomic.LoadInt64(&e.completedIterations) < int64(planned) {
+			select {
+			case res := <-result:
+				return res
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+	return <-result
+}
+
 func TestCreateEngine(t *testing.T) {
 	t.Parallel()
 
@@ -154,50 +191,49 @@ func TestRequestCount(t *testing.T) {
 		reqCount       int
 		timeRunCount   types.TimeRunCount
 		expectedReqArr []int
-		delta          int
 	}{
-		{"Linear1", types.LoadTypeLinear, 1, 100, nil, []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, 1},
-		{"Linear2", types.LoadTypeLinear, 1, 5, nil, []int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0}, 0},
+		{"Linear1", types.LoadTypeLinear, 1, 100, nil, []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}},
+		{"Linear2", types.LoadTypeLinear, 1, 5, nil, []int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0}},
 		{"Linear3", types.LoadTypeLinear, 2, 4, nil,
-			[]int{1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0}, 0},
+			[]int{1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0}},
 		{"Linear4", types.LoadTypeLinear, 2, 23, nil,
-			[]int{2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1}, 0},
+			[]int{2, 2, 1, 1, 1, 1, 1, 1, 1, 1, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
 		{"Incremental1", types.LoadTypeIncremental, 1, 5, nil,
-			[]int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0}, 2},
+			[]int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0}},
 		{"Incremental2", types.LoadTypeIncremental, 3, 1022, nil,
 			[]int{17, 17, 17, 17, 17, 17, 17, 17, 17, 17, 35, 34, 34, 34,
-				34, 34, 34, 34, 34, 34, 52, 51, 51, 51, 51, 51, 51, 51, 51, 51}, 2},
+				34, 34, 34, 34, 34, 34, 52, 51, 51, 51, 51, 51, 51, 51, 51, 51}},
 		{"Incremental3", types.LoadTypeIncremental, 5, 10, nil,
 			[]int{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1,
-				0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0}, 0},
+				0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0}},
 		{"Incremental4", types.LoadTypeIncremental, 4, 10, nil,
 			[]int{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1,
-				0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0}, 0},
+				0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0}},
 		{"Waved1", types.LoadTypeWaved, 1, 5, nil,
-			[]int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0}, 0},
+			[]int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0}},
 		{"Waved2", types.LoadTypeWaved, 4, 32, nil,
 			[]int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 2, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 1, 1,
-				1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0}, 0},
+				1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0}},
 		{"Waved3", types.LoadTypeWaved, 5, 10, nil,
 			[]int{1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1,
-				0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 0},
+				0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
 		{"Waved4", types.LoadTypeWaved, 9, 1000, nil,
 			[]int{6, 6, 6, 6, 6, 6, 5, 5, 5, 5, 12, 11, 11, 11, 11, 11, 11, 11, 11, 11, 17, 17, 17, 17,
 				17, 17, 16, 16, 16, 16, 17, 17, 17, 17, 17, 17, 16, 16, 16, 16, 12, 11, 11, 11, 11, 11,
 				11, 11, 11, 11, 6, 6, 6, 6, 6, 6, 5, 5, 5, 5, 6, 6, 6, 6, 6, 6, 5, 5, 5, 5, 12, 11, 11,
-				11, 11, 11, 11, 11, 11, 11, 17, 17, 17, 17, 17, 17, 17, 16, 16, 16}, 1},
+				11, 11, 11, 11, 11, 11, 11, 17, 17, 17, 17, 17, 17, 17, 16, 16, 16}},
 		{"TimeRunCount1", "", 1, 100, types.TimeRunCount{{Duration: 1, Count: 100}},
-			[]int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, 1},
+			[]int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}},
 		{"TimeRunCount2", "", 1, 5, types.TimeRunCount{{Duration: 1, Count: 5}},
-			[]int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0}, 0},
+			[]int{1, 1, 1, 1, 1, 0, 0, 0, 0, 0}},
 		{"TimeRunCount3", "", 6, 55,
 			types.TimeRunCount{{Duration: 1, Count: 20}, {Duration: 2, Count: 30}, {Duration: 3, Count: 5}},
 			[]int{2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1,
-				1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 0},
+				1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
 		{"TimeRunCount4", "", 5, 40,
 			types.TimeRunCount{{Duration: 1, Count: 20}, {Duration: 2, Count: 0}, {Duration: 2, Count: 20}},
 			[]int{2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-				0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, 0},
+				0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
 	}
 
 	for _, tc := range tests {
@@ -206,13 +242,14 @@ func TestRequestCount(t *testing.T) {
 			t.Parallel()
 
 			var timeReqMap map[int]int
-			var now time.Time
 			var m sync.Mutex
+			c := clock.NewFake(time.Now())
+			now := c.Now()
 
 			// Test server
 			handler := func(w http.ResponseWriter, r *http.Request) {
 				m.Lock()
-				i := time.Since(now).Milliseconds()/tickerInterval - 1
+				i := c.Since(now).Milliseconds()/tickerInterval - 1
 				timeReqMap[int(i)]++
 				m.Unlock()
 			}
@@ -226,8 +263,8 @@ func TestRequestCount(t *testing.T) {
 			h.TimeRunCountMap = test.timeRunCount
 			h.IterationCount = test.reqCount
 			h.Scenario.Steps[0].URL = server.URL
+			h.Clock = c
 
-			now = time.Now()
 			timeReqMap = make(map[int]int, 0)
 
 			e, err := NewEngine(context.TODO(), h)
@@ -241,7 +278,7 @@ func TestRequestCount(t *testing.T) {
 				t.Errorf("TestRequestCount error occurred %v", err)
 			}
 
-			e.Start()
+			startOnFakeClock(e, c)
 
 			m.Lock()
 			// Assert create reqCountArr
@@ -250,21 +287,9 @@ func TestRequestCount(t *testing.T) {
 			}
 
 			// Assert sent request count
-			if testing.Short() {
-				// Poor machine's test case assertions are special since they can't run the test fast.
-				totalRecieved := 0
-				for _, v := range timeReqMap {
-					totalRecieved += v
-				}
-				expected := arraySum(test.expectedReqArr)
-				if totalRecieved != expected {
-					t.Errorf("Poor Machine Expected: %v, Received: %v", totalRecieved, expected)
-				}
-			} else {
-				for i, v := range test.expectedReqArr {
-					if timeReqMap[i] > v+test.delta || timeReqMap[i] < v-test.delta {
-						t.Errorf("Expected: %v, Received: %v, Tick: %v", v, timeReqMap[i], i)
-					}
+			for i, v := range test.expectedReqArr {
+				if timeReqMap[i] != v {
+					t.Errorf("Expected: %v, Received: %v, Tick: %v", v, timeReqMap[i], i)
 				}
 			}
 
@@ -293,7 +318,9 @@ func TestRequestData(t *testing.T) {
 	defer server.Close()
 
 	// Prepare
+	c := clock.NewFake(time.Now())
 	h := newDummyHammer()
+	h.Clock = c
 	h.Scenario.Steps[0] = types.ScenarioStep{
 		ID:       1,
 		Protocol: "HTTP",
@@ -314,7 +341,7 @@ func TestRequestData(t *testing.T) {
 		t.Errorf("TestRequestData error occurred %v", err)
 	}
 
-	e.Start()
+	startOnFakeClock(e, c)
 
 	// Assert
 	if uri != "/get_test_data" {
@@ -365,7 +392,9 @@ func TestRequestDataForMultiScenarioStep(t *testing.T) {
 	defer server.Close()
 
 	// Prepare
+	c := clock.NewFake(time.Now())
 	h := newDummyHammer()
+	h.Clock = c
 	h.Scenario = types.Scenario{
 		Steps: []types.ScenarioStep{
 			{
@@ -397,7 +426,7 @@ func TestRequestDataForMultiScenarioStep(t *testing.T) {
 		t.Errorf("TestRequestDataForMultiScenarioStep error occurred %v", err)
 	}
 
-	e.Start()
+	startOnFakeClock(e, c)
 
 	// Assert
 	expected := []string{"/api_get", "/api_post"}
@@ -504,19 +533,23 @@ func TestEngineResult(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			var m sync.Mutex
+			ctx, cancel := context.WithCancel(context.Background())
 
-			// Test server
+			// Test server, the context is canceled by the request of the first tick
 			handler := func(w http.ResponseWriter, r *http.Request) {
-				return
+				if test.cancelCtx {
+					cancel()
+				}
 			}
 			server := httptest.NewServer(http.HandlerFunc(handler))
 			defer server.Close()
 
+			c := clock.NewFake(time.Now())
 			h := newDummyHammer()
 			h.TestDuration = 2
 			h.Scenario.Steps[0].URL = server.URL
+			h.Clock = c
 
-			ctx, cancel := context.WithCancel(context.Background())
 			e, err := NewEngine(ctx, h)
 			if err != nil {
 				t.Errorf("TestRequestTimeout error occurred %v", err)
@@ -527,13 +560,7 @@ func TestEngineResult(t *testing.T) {
 				t.Errorf("TestRequestTimeout error occurred %v", err)
 			}
 
-			if test.cancelCtx {
-				time.AfterFunc(time.Duration(500)*time.Millisecond, func() {
-					cancel()
-				})
-			}
-
-			res := e.Start()
+			res := startOnFakeClock(e, c)
 			cancel()
 
 			// Assert
@@ -546,6 +573,43 @@ func TestEngineResult(t *testing.T) {
 	}
 }
 
+func TestPreviewDoesNotSendRequests(t *testing.T) {
+	t.Parallel()
+
+	var m sync.Mutex
+	requestCount := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		requestCount++
+		m.Unlock()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	h := newDummyHammer()
+	h.ReportDestination = report.OutputTypeStdoutJson
+	h.PreviewCount = 3
+	h.Scenario.Steps[0].URL = server.URL
+
+	e, err := NewEngine(context.TODO(), h)
+	if err != nil {
+		t.Fatalf("TestPreviewDoesNotSendRequests error occurred %v", err)
+	}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestPreviewDoesNotSendRequests error occurred %v", err)
+	}
+
+	if res := e.Start(); res != resultDone {
+		t.Errorf("Expected %v, Found %v", resultDone, res)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if requestCount != 0 {
+		t.Errorf("Preview should not send any request, found: %d", requestCount)
+	}
+}
+
 func TestDynamicData(t *testing.T) {
 	t.Parallel()
 
@@ -563,7 +627,9 @@ func TestDynamicData(t *testing.T) {
 	defer server.Close()
 
 	// Prepare
+	c := clock.NewFake(time.Now())
 	h := newDummyHammer()
+	h.Clock = c
 	h.Scenario.Steps[0] = types.ScenarioStep{
 		ID:       1,
 		Protocol: "HTTP",
@@ -594,7 +660,7 @@ func TestDynamicData(t *testing.T) {
 		t.Errorf("TestRequestData error occurred %v", err)
 	}
 
-	e.Start()
+	startOnFakeClock(e, c)
 
 	// Assert
 	if i, err := strconv.Atoi(headers.Get("Test1")); err != nil {
@@ -1013,3 +1079,2028 @@ z0xcfR+tEmGlvxaHqXlQg9o=
 
 	return cert, certKey
 }
+
+type abortableReport struct {
+	doneChan chan struct{}
+	abort    chan struct{}
+	aborted  bool
+}
+
+func (r *abortableReport) Init(debug bool) error {
+	r.doneChan = make(chan struct{})
+	r.abort = make(chan struct{})
+	return nil
+}
+
+func (r *abortableReport) Start(input chan *types.ScenarioResult) {
+	for {
+		select {
+		case _, ok := <-input:
+			if ok {
+				continue
+			}
+		case <-r.abort:
+			r.aborted = true
+		}
+		break
+	}
+	r.doneChan <- struct{}{}
+}
+
+func (r *abortableReport) DoneChan() <-chan struct{} {
+	return r.doneChan
+}
+
+func (r *abortableReport) Abort() {
+	close(r.abort)
+}
+
+func TestEngineResultListener(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var m sync.Mutex
+	received := make(map[uint64][]uint16)
+	output := report.NewCallbackOutput(func(sr *types.ScenarioStepResult) {
+		m.Lock()
+		received[sr.IterationID] = append(received[sr.IterationID], sr.StepID)
+		m.Unlock()
+	})
+
+	c := clock.NewFake(time.Now())
+	h := newDummyHammer()
+	h.Clock = c
+	h.IterationCount = 10
+	h.ResultListener = output
+	h.Scenario.Steps[0].URL = server.URL
+	h.Scenario.Steps = append(h.Scenario.Steps, types.ScenarioStep{ID: 2, Protocol: "HTTP", Method: "GET",
+		URL: server.URL})
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineResultListener error occurred %v", err)
+	}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineResultListener error occurred %v", err)
+	}
+	startOnFakeClock(e, c)
+
+	// Listener is closed by the engine, all the results are passed to the callback once the test ends
+	m.Lock()
+	defer m.Unlock()
+	if len(received) != 10 || output.Dropped() != 0 {
+		t.Fatalf("Expected the results of 10 iterations, Found %v and %d dropped", received, output.Dropped())
+	}
+	for id, steps := range received {
+		if !reflect.DeepEqual(steps, []uint16{1, 2}) {
+			t.Errorf("Iteration %d Expected the steps in order, Found %v", id, steps)
+		}
+	}
+}
+
+func TestEngineAbort(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Sleeps of the steps don't honor the ctx, graceful stop would wait them.
+	h := newDummyHammer()
+	h.IterationCount = 10
+	h.Scenario.Steps[0].URL = server.URL
+	h.Scenario.Steps[0].Sleep = "5000"
+	h.Scenario.Steps = append(h.Scenario.Steps, types.ScenarioStep{
+		ID:       2,
+		Protocol: "HTTP",
+		Method:   "GET",
+		URL:      server.URL,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e, err := NewEngine(ctx, h)
+	if err != nil {
+		t.Fatalf("TestEngineAbort error occurred %v", err)
+	}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineAbort error occurred %v", err)
+	}
+	rs := &abortableReport{}
+	rs.Init(false)
+	e.reportService = rs
+
+	time.AfterFunc(500*time.Millisecond, func() {
+		status := new(strings.Builder)
+		e.WriteStatus(status)
+		if !strings.Contains(status.String(), "In-flight Iterations: ") ||
+			strings.Contains(status.String(), "In-flight Iterations: 0") {
+			t.Errorf("Status should list the in-flight iterations, Found: %s", status.String())
+		}
+
+		cancel()
+		time.AfterFunc(200*time.Millisecond, e.Abort)
+	})
+
+	start := time.Now()
+	res := e.Start()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Abort should not wait the in-flight iterations, Start returned in %v", elapsed)
+	}
+	if res != resultStopped {
+		t.Errorf("Expected %v, Found %v", resultStopped, res)
+	}
+	if !rs.aborted {
+		t.Errorf("Report service should be aborted")
+	}
+}
+
+// drainReport is a slowReport that counts the drained results and keeps the drain phase set by the engine.
+type drainReport struct {
+	slowReport
+	drained  int64
+	progress func() report.DrainProgress
+	drain    *report.DrainSummary
+}
+
+func (r *drainReport) Start(input chan *types.ScenarioResult) {
+	for res := range input {
+		if d, _ := res.Others["drained"].(bool); d {
+			atomic.AddInt64(&r.drained, 1)
+		}
+		atomic.AddInt64(&r.processed, 1)
+	}
+	r.doneChan <- struct{}{}
+}
+
+func (r *drainReport) StartDrain(progress func() report.DrainProgress) {
+	r.progress = progress
+}
+
+func (r *drainReport) SetDrain(d report.DrainSummary) {
+	r.drain = &d
+}
+
+func TestEngineDrain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		latency  time.Duration
+		timeout  time.Duration
+		canceled bool
+	}{
+		{"Drained", 500 * time.Millisecond, 5 * time.Second, false},
+		{"ShutdownDeadline", 5 * time.Second, 300 * time.Millisecond, true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(test.latency):
+				case <-r.Context().Done():
+				}
+			}))
+			defer server.Close()
+
+			h := newDummyHammer()
+			h.IterationCount = 40
+			h.TestDuration = 4
+			h.ShutdownTimeout = test.timeout
+			h.Scenario.Steps[0].URL = server.URL
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			e, err := NewEngine(ctx, h)
+			if err != nil {
+				t.Fatalf("TestEngineDrain error occurred %v", err)
+			}
+			rs := &drainReport{}
+			e.reportService = rs
+			log := new(bytes.Buffer)
+			e.logOut = newLogLimiter(log)
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineDrain error occurred %v", err)
+			}
+
+			time.AfterFunc(1200*time.Millisecond, cancel)
+			start := time.Now()
+			if res := e.Start(); res != resultStopped {
+				t.Errorf("Expected %v, Found %v", resultStopped, res)
+			}
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("Drain should finish in the shutdown deadline, Start returned in %v", elapsed)
+			}
+
+			d := rs.drain
+			if d == nil || rs.progress == nil {
+				t.Fatalf("Drain phase should be passed to the report service")
+			}
+			if d.InFlight == 0 || d.Duration <= 0 {
+				t.Errorf("Drain should start with the in-flight iterations, Found %+v", d)
+			}
+			if p := rs.progress(); p.InFlight != 0 || p.Completed != d.InFlight {
+				t.Errorf("Drain progress should end with no iteration in flight, Found %+v", p)
+			}
+			if !strings.Contains(log.String(), fmt.Sprintf("draining %d in-flight iterations", d.InFlight)) {
+				t.Errorf("Drain should be logged, Found %q", log.String())
+			}
+
+			if !test.canceled {
+				if d.Completed != d.InFlight || d.Canceled != 0 || atomic.LoadInt64(&rs.drained) != d.InFlight {
+					t.Errorf("In-flight iterations should complete in the drain, Found %+v, drained results: %d",
+						d, atomic.LoadInt64(&rs.drained))
+				}
+				return
+			}
+			if d.Canceled != d.InFlight || d.Completed != 0 || atomic.LoadInt64(&rs.drained) != 0 {
+				t.Errorf("In-flight iterations should be canceled at the deadline, Found %+v, drained results: %d",
+					d, atomic.LoadInt64(&rs.drained))
+			}
+			if !strings.Contains(log.String(), "canceled at the shutdown deadline of 300ms") {
+				t.Errorf("Canceled iterations should be logged, Found %q", log.String())
+			}
+		})
+	}
+}
+
+func TestEngineSuccessCriteria(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		criteria string
+		debug    bool
+		passed   bool
+		hasRes   bool
+	}{
+		{"Passed", "result.fail_count == 0 && steps.1.status_2xx_rate == 100%", false, true, true},
+		{"Failed", "steps.1.p95 > 1m", false, false, true},
+		{"Disabled", "", false, false, false},
+		{"Debug", "result.fail_count == 0", true, false, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(testserver.New(testserver.Config{Seed: 1}))
+			defer server.Close()
+
+			c := clock.NewFake(time.Now())
+			h := newDummyHammer()
+			h.Clock = c
+			h.Scenario.Steps[0].URL = server.URL + "/status"
+			h.SuccessCriteria = test.criteria
+			h.Debug = test.debug
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineSuccessCriteria error occurred %v", err)
+			}
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineSuccessCriteria error occurred %v", err)
+			}
+			startOnFakeClock(e, c)
+
+			r := e.CriteriaResult()
+			if (r != nil) != test.hasRes {
+				t.Fatalf("Expected criteria result %v, Found %#v", test.hasRes, r)
+			}
+			if r != nil && r.Passed != test.passed {
+				t.Errorf("Expected passed %v, Found %#v", test.passed, r)
+			}
+		})
+	}
+}
+
+func TestEngineTestServer(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(testserver.New(testserver.Config{Seed: 1}))
+	defer server.Close()
+
+	c := clock.NewFake(time.Now())
+	h := newDummyHammer()
+	h.Clock = c
+	h.IterationCount = 5
+	h.Scenario.Steps = nil
+	for i, path := range []string{"/redirect?n=2", "/gzip?size=2000", "/cookies/set?session=abc", "/etag",
+		"/latency/random?dist=normal&mean=5&stddev=1", "/fail?rate=1&code=503"} {
+		h.Scenario.Steps = append(h.Scenario.Steps, types.ScenarioStep{ID: uint16(i + 1), Protocol: "HTTP",
+			Method: "GET", URL: server.URL + path, Timeout: types.DefaultTimeout})
+	}
+	h.SuccessCriteria = "steps.1.status_2xx_rate == 100% && steps.2.status_2xx_rate == 100% && " +
+		"steps.3.status_2xx_rate == 100% && steps.4.status_2xx_rate == 100% && steps.5.status_2xx_rate == 100% && " +
+		"steps.6.status_503_count == 5"
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineTestServer error occurred %v", err)
+	}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineTestServer error occurred %v", err)
+	}
+	startOnFakeClock(e, c)
+
+	if r := e.CriteriaResult(); r == nil || !r.Passed {
+		t.Errorf("Expected the responses of the test server to pass the criteria, Found %#v", r)
+	}
+}
+
+func TestEngineStopAfterFailures(t *testing.T) {
+	t.Parallel()
+
+	// Requests to the closed listener fail with the connection errors.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	h := newDummyHammer()
+	h.IterationCount = 100
+	h.TestDuration = 10
+	h.StopAfterFailures = 5
+	h.Scenario.Steps[0].URL = "http://" + l.Addr().String()
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineStopAfterFailures error occurred %v", err)
+	}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineStopAfterFailures error occurred %v", err)
+	}
+
+	start := time.Now()
+	res := e.Start()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Test should be stopped after the failures, Start returned in %v", elapsed)
+	}
+	if res != resultStopped {
+		t.Errorf("Expected %v, Found %v", resultStopped, res)
+	}
+}
+
+func TestEngineAbortRule(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(testserver.New(testserver.Config{Seed: 1}))
+	defer server.Close()
+
+	h := newDummyHammer()
+	h.IterationCount = 100
+	h.TestDuration = 10
+	h.AbortRules = []string{"steps.1.p95 > 10ms over 200ms for 2 windows"}
+	h.Scenario.Steps[0].URL = server.URL + "/latency?ms=30"
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineAbortRule error occurred %v", err)
+	}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineAbortRule error occurred %v", err)
+	}
+
+	start := time.Now()
+	res := e.Start()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Test should be stopped by the abort rule, Start returned in %v", elapsed)
+	}
+	if res != resultStopped {
+		t.Errorf("Expected %v, Found %v", resultStopped, res)
+	}
+}
+
+func TestEngineStopAfterFailuresUnsupportedOutput(t *testing.T) {
+	t.Parallel()
+
+	h := newDummyHammer()
+	h.StopAfterFailures = 5
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineStopAfterFailuresUnsupportedOutput error occurred %v", err)
+	}
+	e.reportService = &abortableReport{}
+	if err = e.Init(); err == nil {
+		t.Errorf("Expected an error for the output without the failure limit support")
+	}
+}
+
+func TestEngineMaxTransfer(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(testserver.New(testserver.Config{Seed: 1}))
+	defer server.Close()
+
+	h := newDummyHammer()
+	h.IterationCount = 1000
+	h.TestDuration = 10
+	h.MaxTransfer = 50000
+	h.Scenario.Steps[0].URL = server.URL + "/bytes?size=10000"
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineMaxTransfer error occurred %v", err)
+	}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineMaxTransfer error occurred %v", err)
+	}
+
+	start := time.Now()
+	res := e.Start()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Test should be stopped after the transfer limit, Start returned in %v", elapsed)
+	}
+	if res != resultStopped {
+		t.Errorf("Expected %v, Found %v", resultStopped, res)
+	}
+}
+
+func TestEngineMaxTransferUnsupportedOutput(t *testing.T) {
+	t.Parallel()
+
+	h := newDummyHammer()
+	h.MaxTransfer = 1000
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineMaxTransferUnsupportedOutput error occurred %v", err)
+	}
+	e.reportService = &abortableReport{}
+	if err = e.Init(); err == nil || !strings.Contains(err.Error(), "max transfer") {
+		t.Errorf("Expected the max transfer error for the output without the stop limit support, Found %v", err)
+	}
+}
+
+func TestEngineHeadlessHealthcheckAddrInUse(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	h := newDummyHammer()
+	h.ReportDestination = report.OutputTypeHeadless
+	h.Headless = types.HeadlessOptions{HealthcheckAddr: l.Addr().String()}
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineHeadlessHealthcheckAddrInUse error occurred %v", err)
+	}
+	if err = e.Init(); err == nil {
+		t.Errorf("Engine init should fail when the healthcheck address is in use")
+	}
+}
+
+func TestEngineVerify(t *testing.T) {
+	t.Parallel()
+
+	var m sync.Mutex
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		m.Unlock()
+		if r.URL.Path == "/order" && n == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	fake := clock.NewFake(time.Now())
+	h := newDummyHammer()
+	h.Clock = fake
+	h.ReportDestination = report.OutputTypeStdoutJson
+	h.VerifyCount = 3
+	h.SuccessCriteria = "result.fail_count == 0"
+	h.Scenario.Steps[0].URL = server.URL + "/login"
+	h.Scenario.Steps = append(h.Scenario.Steps, types.ScenarioStep{
+		ID:       2,
+		Protocol: "HTTP",
+		Method:   "GET",
+		URL:      server.URL + "/order",
+	})
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineVerify error occurred %v", err)
+	}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineVerify error occurred %v", err)
+	}
+
+	start := fake.Now()
+	result := make(chan string, 1)
+	go func() { result <- e.Start() }()
+	for i := 1; i < h.VerifyCount; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(verifyInterval)
+	}
+	if res := <-result; res != resultDone {
+		t.Errorf("Expected %v, Found %v", resultDone, res)
+	}
+	if elapsed := fake.Since(start); elapsed != 2*verifyInterval {
+		t.Errorf("Iterations should be paced by %v, all of them finished in %v", verifyInterval, elapsed)
+	}
+	if e.CriteriaResult() != nil {
+		t.Errorf("Success criteria should be ignored in the verify mode")
+	}
+
+	m.Lock()
+	if calls["/login"] != 3 || calls["/order"] != 3 {
+		t.Errorf("Expected 3 requests per step, Found %v", calls)
+	}
+	m.Unlock()
+
+	r := e.VerifyResult()
+	if r == nil {
+		t.Fatalf("Verify result should be reported")
+	}
+	if r.Passed || r.Iterations != 3 || r.Failed != 1 {
+		t.Errorf("Expected a failed verify of 3 iterations with 1 failed request, Found %#v", r)
+	}
+	c := r.Steps[1].Checks[1]
+	if c.Passed || c.StatusCode != http.StatusInternalServerError || c.Failure == nil {
+		t.Errorf("Expected the captured failure of the second order request, Found %#v", c)
+	}
+	if !r.Steps[0].Checks[1].Passed {
+		t.Errorf("Login request of the second iteration should be passed")
+	}
+}
+
+func TestEngineVerifyUnsupportedOutput(t *testing.T) {
+	t.Parallel()
+
+	h := newDummyHammer()
+	h.VerifyCount = 1
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineVerifyUnsupportedOutput error occurred %v", err)
+	}
+	e.reportService = &abortableReport{}
+	if err = e.Init(); err == nil || !strings.Contains(err.Error(), "verify") {
+		t.Errorf("Expected the verify error for the output without the verify support, Found %v", err)
+	}
+}
+
+func TestStrictSteps(t *testing.T) {
+	t.Parallel()
+
+	steps := []types.ScenarioStep{
+		{ID: 1, Custom: map[string]interface{}{"json-schema": "schema.json", "json-schema-sample": 0.1}},
+		{ID: 2},
+	}
+	strict := strictSteps(steps)
+
+	if _, ok := strict[0].Custom["json-schema-sample"]; ok {
+		t.Errorf("Schema sample should be removed, Found %v", strict[0].Custom)
+	}
+	if strict[0].Custom["json-schema"] != "schema.json" {
+		t.Errorf("Schema should be kept, Found %v", strict[0].Custom)
+	}
+	if _, ok := steps[0].Custom["json-schema-sample"]; !ok {
+		t.Errorf("Steps of the hammer should not be modified")
+	}
+}
+
+// slowReport consumes a result per delay, like an output writing to a slow remote store.
+type slowReport struct {
+	doneChan  chan struct{}
+	delay     time.Duration
+	processed int64
+}
+
+func (r *slowReport) Init(debug bool) error {
+	r.doneChan = make(chan struct{})
+	return nil
+}
+
+func (r *slowReport) Start(input chan *types.ScenarioResult) {
+	for range input {
+		time.Sleep(r.delay)
+		atomic.AddInt64(&r.processed, 1)
+	}
+	r.doneChan <- struct{}{}
+}
+
+func (r *slowReport) DoneChan() <-chan struct{} {
+	return r.doneChan
+}
+
+func TestEngineShutdownDeadline(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		delay       time.Duration
+		timeout     time.Duration
+		unprocessed bool
+	}{
+		{"SlowOutput", time.Second, 200 * time.Millisecond, true},
+		{"WellBehavedOutput", 0, 5 * time.Second, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newDummyHammer()
+			h.IterationCount = 10
+			h.ShutdownTimeout = test.timeout
+			h.Scenario.Steps[0].URL = server.URL
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineShutdownDeadline error occurred %v", err)
+			}
+			rs := &slowReport{delay: test.delay}
+			e.reportService = rs
+			log := new(bytes.Buffer)
+			e.logOut = newLogLimiter(log)
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineShutdownDeadline error occurred %v", err)
+			}
+
+			start := time.Now()
+			e.Start()
+			elapsed := time.Since(start)
+
+			if !test.unprocessed {
+				if log.Len() != 0 || atomic.LoadInt64(&rs.processed) != 10 {
+					t.Errorf("Well behaved output should process all the results, processed: %d, log: %s",
+						atomic.LoadInt64(&rs.processed), log.String())
+				}
+				return
+			}
+
+			// Ticks of the test take 1s, the slow output consumes a result per second.
+			if elapsed > 3*time.Second {
+				t.Errorf("Engine should stop at the shutdown deadline, Start returned in %v", elapsed)
+			}
+			var unprocessed int
+			if _, err := fmt.Sscanf(log.String(), "stdout output did not finish in 200ms, %d results are left unprocessed",
+				&unprocessed); err != nil {
+				t.Fatalf("Unprocessed results should be logged, Found %q, err: %v", log.String(), err)
+			}
+			if processed := int(atomic.LoadInt64(&rs.processed)); unprocessed == 0 || processed+unprocessed > 10 {
+				t.Errorf("Expected the unprocessed count of the 10 results, processed: %d, unprocessed: %d",
+					processed, unprocessed)
+			}
+		})
+	}
+}
+
+// generatorReport is a slowReport that keeps the generator health set by the engine.
+type generatorReport struct {
+	slowReport
+	health *report.GeneratorHealth
+}
+
+func (r *generatorReport) SetGeneratorHealth(g report.GeneratorHealth) {
+	r.health = &g
+}
+
+func TestEngineResultBackPressure(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		bufferSize int
+		delay      time.Duration
+		blocked    bool
+	}{
+		// 20 results are produced in 1s, the slow output consumes 10 per second
+		{"SlowOutput", 1, 100 * time.Millisecond, true},
+		{"DefaultBuffer", 0, 100 * time.Millisecond, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newDummyHammer()
+			h.IterationCount = 20
+			h.ResultBufferSize = test.bufferSize
+			h.ResultBlockWarning = 50 * time.Millisecond
+			h.Scenario.Steps[0].URL = server.URL
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineResultBackPressure error occurred %v", err)
+			}
+			rs := &generatorReport{slowReport: slowReport{delay: test.delay}}
+			e.reportService = rs
+			log := new(bytes.Buffer)
+			e.logOut = newLogLimiter(log)
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineResultBackPressure error occurred %v", err)
+			}
+			e.Start()
+
+			g := rs.health
+			if g == nil {
+				t.Fatalf("Generator health should be set")
+			}
+			expectedSize := test.bufferSize
+			if expectedSize == 0 {
+				expectedSize = h.IterationCount
+			}
+			if g.ResultBufferSize != expectedSize {
+				t.Errorf("ResultBufferSize Expected %d, Found %d", expectedSize, g.ResultBufferSize)
+			}
+			if g.MaxQueueLength > expectedSize {
+				t.Errorf("MaxQueueLength should be at most the buffer size, Found %d", g.MaxQueueLength)
+			}
+
+			warned := strings.Contains(log.String(), "a larger result_buffer_size or a faster output is suggested")
+			if !test.blocked {
+				if g.BlockedSends != 0 || g.BlockWarning || warned {
+					t.Errorf("Iterations should not wait on the result channel, Found %+v, log: %s", g, log.String())
+				}
+				return
+			}
+			if g.BlockedSends == 0 || g.BlockedDuration <= 0.05 || !g.BlockWarning || !warned {
+				t.Errorf("Blocked sends should be reported and warned, Found %+v, log: %s", g, log.String())
+			}
+		})
+	}
+}
+
+func TestEnginePrewarm(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	// Dials to the closed listener are refused.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	closedURL := "http://" + l.Addr().String()
+
+	tests := []struct {
+		name     string
+		url      string
+		required bool
+		errored  bool
+		log      string
+	}{
+		{"Prewarmed", server.URL, false, false, "step 1: 3 connections are prewarmed"},
+		{"Failed", closedURL, false, false, "warning: step 1: 0 of 3 connections are prewarmed"},
+		{"FailedRequired", closedURL, true, true, ""},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newDummyHammer()
+			h.Scenario.Steps[0].URL = test.url
+			h.Scenario.Steps[0].Custom = map[string]interface{}{
+				"prewarm-connections": float64(3),
+				"prewarm-required":    test.required,
+			}
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEnginePrewarm error occurred %v", err)
+			}
+			log := new(bytes.Buffer)
+			e.logOut = newLogLimiter(log)
+			err = e.Init()
+			if test.errored {
+				if err == nil || !strings.Contains(err.Error(), "step 1: 0 of 3 connections are prewarmed") {
+					t.Errorf("Required prewarm should fail the init, Found: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TestEnginePrewarm error occurred %v", err)
+			}
+			if !strings.HasPrefix(log.String(), test.log) {
+				t.Errorf("Expected log %q, Found %q", test.log, log.String())
+			}
+		})
+	}
+}
+
+// preflightReport records the preflight probes set by the engine.
+type preflightReport struct {
+	slowReport
+	probes []report.PreflightProbe
+}
+
+func (r *preflightReport) SetPreflight(probes []report.PreflightProbe) {
+	r.probes = probes
+}
+
+func TestEnginePreflight(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	serverAddr := strings.TrimPrefix(server.URL, "http://")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	closedAddr := l.Addr().String()
+
+	tests := []struct {
+		name    string
+		urls    []string
+		skip    bool
+		preview bool
+		probes  []string
+		err     string
+	}{
+		{"Reachable", []string{server.URL, server.URL + "/next"}, false, false,
+			[]string{"1 " + serverAddr, "2 " + serverAddr}, ""},
+		{"Unreachable", []string{server.URL, "http://" + closedAddr}, false, false, nil,
+			"step 2: preflight to " + closedAddr + " failed"},
+		{"Skipped", []string{"http://" + closedAddr}, true, false, nil, ""},
+		{"Preview", []string{"http://" + closedAddr}, false, true, nil, ""},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newDummyHammer()
+			h.SkipPreflight = test.skip
+			if test.preview {
+				h.PreviewCount = 1
+			}
+			h.Scenario.Steps = nil
+			for i, u := range test.urls {
+				h.Scenario.Steps = append(h.Scenario.Steps,
+					types.ScenarioStep{ID: uint16(i + 1), Protocol: "HTTP", Method: "GET", URL: u})
+			}
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEnginePreflight error occurred %v", err)
+			}
+			rs := &preflightReport{}
+			e.reportService = rs
+			err = e.Init()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected error %q, Found: %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TestEnginePreflight error occurred %v", err)
+			}
+
+			var probes []string
+			for _, p := range rs.probes {
+				probes = append(probes, fmt.Sprintf("%d %s", p.StepID, p.Addr))
+				if p.Latency <= 0 {
+					t.Errorf("Latency of the probe should be set, Found %v", p.Latency)
+				}
+			}
+			if !reflect.DeepEqual(probes, test.probes) {
+				t.Errorf("Expected probes %v, Found %v", test.probes, probes)
+			}
+		})
+	}
+}
+
+// metadataReport records the metadata set by the engine.
+type metadataReport struct {
+	slowReport
+	metadata types.Metadata
+}
+
+func (r *metadataReport) SetMetadata(m types.Metadata) {
+	r.metadata = m
+}
+
+func TestEngineMetadata(t *testing.T) {
+	t.Parallel()
+
+	hostname, _ := os.Hostname()
+	tests := []struct {
+		name     string
+		metadata types.Metadata
+	}{
+		{"Generated", types.Metadata{Labels: []types.Label{{Key: "env", Value: "staging"}}}},
+		{"Given", types.Metadata{RunID: "r1", Hostname: "runner-1"}},
+	}
+
+	runIDs := make(chan string, len(tests))
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			h := newDummyHammer()
+			h.Metadata = test.metadata
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineMetadata error occurred %v", err)
+			}
+			rs := &metadataReport{}
+			e.reportService = rs
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineMetadata error occurred %v", err)
+			}
+
+			m := rs.metadata
+			runIDs <- m.RunID
+			if !reflect.DeepEqual(m.Labels, test.metadata.Labels) {
+				t.Errorf("Labels Expected %v, Found %v", test.metadata.Labels, m.Labels)
+			}
+			if test.metadata.RunID != "" {
+				if m.RunID != test.metadata.RunID || m.Hostname != test.metadata.Hostname {
+					t.Errorf("Given run id and hostname should be kept, Found %s, %s", m.RunID, m.Hostname)
+				}
+				return
+			}
+			if _, err := uuid.Parse(m.RunID); err != nil || m.Hostname != hostname {
+				t.Errorf("Run id and hostname should be generated, Found %q, %q", m.RunID, m.Hostname)
+			}
+		})
+	}
+
+	if a, b := <-runIDs, <-runIDs; a == b {
+		t.Errorf("Run ids should be unique, Found %s twice", a)
+	}
+}
+
+func TestEngineWarmStart(t *testing.T) {
+	t.Parallel()
+
+	ws := warm.New(false)
+	start := func(ws *warm.State) string {
+		h := newDummyHammer()
+		h.WarmState = ws
+		e, err := NewEngine(context.Background(), h)
+		if err != nil {
+			t.Fatalf("TestEngineWarmStart error occurred %v", err)
+		}
+		rs := &metadataReport{}
+		e.reportService = rs
+		if err = e.Init(); err != nil {
+			t.Fatalf("TestEngineWarmStart error occurred %v", err)
+		}
+		return rs.metadata.Start
+	}
+
+	var starts []string
+	for i := 0; i < 2; i++ {
+		starts = append(starts, start(ws))
+	}
+	ws.Reset()
+	starts = append(starts, start(ws), start(nil))
+	expected := []string{types.StartCold, types.StartWarm, types.StartCold, ""}
+	if !reflect.DeepEqual(starts, expected) {
+		t.Errorf("Expected the starts %v, Found %v", expected, starts)
+	}
+}
+
+// configReport records the config echo set by the engine.
+type configReport struct {
+	slowReport
+	hammer  *types.Hammer
+	proxies []*url.URL
+}
+
+func (r *configReport) SetConfig(h types.Hammer, proxies []*url.URL) {
+	r.hammer = &h
+	r.proxies = proxies
+}
+
+func TestEngineConfigEcho(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		noConfigEcho bool
+	}{
+		{"Enabled", false},
+		{"Disabled", true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			h := newDummyHammer()
+			h.NoConfigEcho = test.noConfigEcho
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineConfigEcho error occurred %v", err)
+			}
+			rs := &configReport{}
+			e.reportService = rs
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineConfigEcho error occurred %v", err)
+			}
+
+			if test.noConfigEcho {
+				if rs.hammer != nil {
+					t.Errorf("Config echo should not be set")
+				}
+				return
+			}
+			if rs.hammer == nil || len(rs.hammer.Scenario.Steps) != len(h.Scenario.Steps) || len(rs.proxies) != 1 {
+				t.Errorf("Config echo should be set with the hammer and the proxies, Found %v, %v", rs.hammer, rs.proxies)
+			}
+		})
+	}
+}
+
+// anonymizeReport records the anonymizer set by the engine.
+type anonymizeReport struct {
+	slowReport
+	anonymizer *report.Anonymizer
+}
+
+func (r *anonymizeReport) SetAnonymizer(a *report.Anonymizer) {
+	r.anonymizer = a
+}
+
+func TestEngineAnonymize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		anonymize bool
+		rs        report.ReportService
+		err       string
+	}{
+		{"Disabled", false, &anonymizeReport{}, ""},
+		{"Enabled", true, &anonymizeReport{}, ""},
+		{"Unsupported", true, &slowReport{}, "anonymize is not supported by the stdout output"},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			h := newDummyHammer()
+			h.Anonymize = test.anonymize
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineAnonymize error occurred %v", err)
+			}
+			e.reportService = test.rs
+			err = e.Init()
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Expected error %q, Found %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TestEngineAnonymize error occurred %v", err)
+			}
+			if a := test.rs.(*anonymizeReport).anonymizer; (a != nil) != test.anonymize {
+				t.Errorf("Anonymizer should be set %v, Found %v", test.anonymize, a)
+			}
+		})
+	}
+}
+
+// baselineReport records the baseline of the -compare flag.
+type baselineReport struct {
+	slowReport
+	baseline *report.Baseline
+}
+
+func (r *baselineReport) SetBaseline(b *report.Baseline) {
+	r.baseline = b
+}
+
+func TestEngineCompare(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := dir + "/" + name
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	same := write("same.json", `{"success_count":1,"config":{"load_type":"linear","iteration_count":1,`+
+		`"duration":1,"rate":1,"steps":[{"id":1,"method":"GET","url":"http://127.0.0.1"}]}}`)
+	stress := write("stress.json", `{"success_count":1,"config":{"load_type":"linear","iteration_count":50,`+
+		`"duration":1,"rate":50,"steps":[{"id":1,"method":"GET","url":"http://127.0.0.1"}]}}`)
+	invalid := write("invalid.json", `RESULT`)
+
+	tests := []struct {
+		name     string
+		compare  string
+		force    bool
+		baseline bool
+		err      string
+	}{
+		{"Disabled", "", false, false, ""},
+		{"Compatible", same, false, true, ""},
+		{"Incompatible", stress, false, false, "run is not comparable with the baseline " + stress + ":\n" +
+			"  iteration_count: 50 in the baseline, 1 in the run\n" +
+			"  rate: 50.0 iterations/s in the baseline, 1.0 iterations/s in the run\n" +
+			"use -force_compare to compare them anyway"},
+		{"Forced", stress, true, true, ""},
+		{"InvalidBaseline", invalid, true, false,
+			"baseline " + invalid + " is not a json report: invalid character 'R' looking for beginning of value"},
+		{"MissingBaseline", dir + "/missing.json", false, false,
+			"open " + dir + "/missing.json: no such file or directory"},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			h := newDummyHammer()
+			h.Compare = test.compare
+			h.ForceCompare = test.force
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineCompare error occurred %v", err)
+			}
+			rs := &baselineReport{}
+			e.reportService = rs
+			err = e.Init()
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Expected error %q, Found %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TestEngineCompare error occurred %v", err)
+			}
+			if (rs.baseline != nil) != test.baseline {
+				t.Errorf("Baseline should be set %v, Found %v", test.baseline, rs.baseline)
+			}
+		})
+	}
+}
+
+func TestEngineWarnsURLEncoding(t *testing.T) {
+	t.Parallel()
+
+	h := newDummyHammer()
+	h.Scenario.Steps[0].URL = "https://test.com/a%2Fb/über?token=abc&q=a b"
+	h.Scenario.Steps = append(h.Scenario.Steps, types.ScenarioStep{ID: 2, Protocol: "HTTPS", Method: "GET",
+		URL: "https://test.com/a%2Fb?q={{_randomInt}}", Timeout: types.DefaultTimeout})
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineWarnsURLEncoding error occurred %v", err)
+	}
+	log := new(strings.Builder)
+	e.logOut = newLogLimiter(log)
+	e.reportService = &slowReport{}
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineWarnsURLEncoding error occurred %v", err)
+	}
+
+	expected := "warning: step 1: url https://test.com/a%2Fb/über?token=***&q=a b is sent as " +
+		"https://test.com/a%2Fb/%C3%BCber?token=***&q=a%20b\n"
+	if log.String() != expected {
+		t.Errorf("Expected %q, Found %q", expected, log.String())
+	}
+}
+
+// rateReport records the rate changes of the dynamic rate.
+type rateReport struct {
+	slowReport
+	mu      sync.Mutex
+	changes []report.RateChange
+}
+
+func (r *rateReport) RecordRateChange(c report.RateChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, c)
+}
+
+func (r *rateReport) rates() (rates []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.changes {
+		rates = append(rates, c.Value, c.Rate)
+	}
+	return
+}
+
+func TestEngineDynamicRate(t *testing.T) {
+	t.Parallel()
+
+	var requests int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+	}))
+	t.Cleanup(target.Close)
+
+	// Source value is 5 on the first poll and 10 afterwards.
+	var polls int64
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&polls, 1) == 1 {
+			fmt.Fprint(w, "5")
+			return
+		}
+		fmt.Fprint(w, " 10\n")
+	}))
+	t.Cleanup(source.Close)
+
+	h := newDummyHammer()
+	h.Scenario.Steps[0].URL = target.URL
+	h.TestDuration = 2
+	h.DynamicRate = &types.DynamicRate{URL: source.URL, Expression: "value * 2", Interval: 500 * time.Millisecond,
+		Min: 1, Max: 50}
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineDynamicRate error occurred %v", err)
+	}
+	rs := &rateReport{}
+	e.reportService = rs
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineDynamicRate error occurred %v", err)
+	}
+	if e.hammer.IterationCount != 20 {
+		t.Errorf("Load plan should be planned at the initial rate, Expected 20 iterations, Found %d",
+			e.hammer.IterationCount)
+	}
+	e.Start()
+
+	expected := []float64{5, 10, 10, 20}
+	if rates := rs.rates(); !reflect.DeepEqual(rates, expected) {
+		t.Errorf("Rate changes Expected %v, Found %v", expected, rates)
+	}
+	// 10 RPS until the first poll at 500ms, 20 RPS afterwards.
+	if r := atomic.LoadInt64(&requests); r < 25 || r > 40 {
+		t.Errorf("Expected about 35 requests, Found %d", r)
+	}
+}
+
+func TestEngineDynamicRatePollFailure(t *testing.T) {
+	t.Parallel()
+
+	var polls int64
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&polls, 1) == 1 {
+			fmt.Fprint(w, "8")
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(source.Close)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	closedURL := "http://" + l.Addr().String()
+
+	tests := []struct {
+		name     string
+		url      string
+		rate     float64
+		log      string
+		recorded []float64
+	}{
+		{"FreezesAtLastValue", source.URL, 8,
+			"warning: dynamic rate poll failed, the rate stays at 8 iterations per second: source responded with 503",
+			[]float64{8, 8}},
+		{"StartsAtMin", closedURL, 2, "warning: dynamic rate poll failed, the rate stays at 2 iterations per second",
+			nil},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newDummyHammer()
+			h.DynamicRate = &types.DynamicRate{URL: test.url, Min: 2, Max: 50}
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineDynamicRatePollFailure error occurred %v", err)
+			}
+			log := new(bytes.Buffer)
+			e.logOut = newLogLimiter(log)
+			rs := &rateReport{}
+			e.reportService = rs
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineDynamicRatePollFailure error occurred %v", err)
+			}
+			e.pollRate(context.Background())
+
+			if r := e.pacer.get(); r != test.rate {
+				t.Errorf("Rate Expected %v, Found %v", test.rate, r)
+			}
+			if !strings.Contains(log.String(), test.log) {
+				t.Errorf("Expected log %q, Found %q", test.log, log.String())
+			}
+			if rates := rs.rates(); !reflect.DeepEqual(rates, test.recorded) {
+				t.Errorf("Rate changes Expected %v, Found %v", test.recorded, rates)
+			}
+		})
+	}
+}
+
+func TestRateSourcePrometheus(t *testing.T) {
+	t.Parallel()
+
+	query := `sum(rate(http_requests_total{job="api"}[1m]))`
+	responses := map[string]string{
+		"vector": `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1760436000.1,"42.5"]}]}}`,
+		"scalar": `{"status":"success","data":{"resultType":"scalar","result":[1760436000.1,"7"]}}`,
+		"empty":  `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+		"error":  `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+		"matrix": `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+		"nan":    `{"status":"success","data":{"resultType":"scalar","result":[1760436000.1,"abc"]}}`,
+	}
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prom/api/v1/query" || r.URL.Query().Get("query") != query {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, responses[r.Header.Get("X-Response")])
+	}))
+	t.Cleanup(prometheus.Close)
+
+	tests := []struct {
+		response  string
+		expected  float64
+		shouldErr bool
+	}{
+		{"vector", 42.5, false},
+		{"scalar", 7, false},
+		{"empty", 0, true},
+		{"error", 0, true},
+		{"matrix", 0, true},
+		{"nan", 0, true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.response, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := newRateSource(types.DynamicRate{Prometheus: prometheus.URL + "/prom/", Query: query, Max: 10})
+			if err != nil {
+				t.Fatalf("TestRateSourcePrometheus error occurred %v", err)
+			}
+			s.client.Transport = headerTransport{"X-Response": test.response}
+
+			v, err := s.poll(context.Background())
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TestRateSourcePrometheus error occurred %v", err)
+			}
+			if v != test.expected {
+				t.Errorf("Expected %v, Found %v", test.expected, v)
+			}
+		})
+	}
+}
+
+// headerTransport adds the headers to the requests of the client.
+type headerTransport map[string]string
+
+func (h headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	for k, v := range h {
+		r.Header.Set(k, v)
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestRatePacer(t *testing.T) {
+	t.Parallel()
+
+	p := &ratePacer{}
+	p.set(15)
+	counts := make([]int, 0, 10)
+	for i := 0; i < 10; i++ {
+		counts = append(counts, p.next(100*time.Millisecond))
+	}
+	expected := []int{1, 3, 4, 6, 7, 9, 10, 12, 13, 15}
+	sum := 0
+	for i, c := range counts {
+		sum += c
+		if sum != expected[i] {
+			t.Fatalf("Cumulative iterations Expected %v, Found per tick %v", expected, counts)
+		}
+	}
+}
+
+func TestScheduleReqCountArr(t *testing.T) {
+	t.Parallel()
+
+	h := newDummyHammer()
+	h.LoadType = types.LoadTypeSchedule
+	h.TestDuration = 4
+	// 10/s at 23:59:58 rising to 30/s at 00:00:00, wrapping around the midnight
+	h.Schedule = &types.LoadSchedule{Points: []types.SchedulePoint{
+		{TimeOfDay: 0, Rate: 30},
+		{TimeOfDay: 12 * time.Hour, Rate: 30},
+		{TimeOfDay: 24*time.Hour - 2*time.Second, Rate: 10},
+	}}
+	e, err := NewEngine(context.TODO(), h)
+	if err != nil {
+		t.Fatalf("TestScheduleReqCountArr error occurred %v", err)
+	}
+	e.reqCountArr = make([]int, h.TestDuration*10)
+
+	start := time.Date(2026, 10, 14, 23, 59, 58, 0, time.UTC).Add(-100 * time.Millisecond)
+	e.createScheduleReqCountArr(start)
+
+	perSecond := make([]int, h.TestDuration)
+	for i, c := range e.reqCountArr {
+		perSecond[i/10] += c
+	}
+	// Ticks of the first 2 seconds ramp from 10/s to 30/s, the next ones stay at 30/s
+	expected := []int{14, 24, 30, 30}
+	for i := range expected {
+		if d := perSecond[i] - expected[i]; d < -1 || d > 1 {
+			t.Errorf("Second %d: Expected %d, Found %d", i, expected[i], perSecond[i])
+		}
+	}
+	if e.hammer.IterationCount != arraySum(e.reqCountArr) {
+		t.Errorf("Iteration count should be the sum of the plan, Found %d", e.hammer.IterationCount)
+	}
+}
+
+// teardownReport records the teardown results set by the engine.
+type teardownReport struct {
+	slowReport
+	teardown []report.TeardownSummary
+}
+
+func (r *teardownReport) SetTeardown(teardown []report.TeardownSummary) {
+	r.teardown = teardown
+}
+
+func TestEngineTeardown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		stopped bool
+	}{
+		{"Done", false},
+		{"Stopped", true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var created, deleted int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodDelete {
+					atomic.AddInt64(&deleted, 1)
+					return
+				}
+				fmt.Fprintf(w, `{"id": "%d"}`, atomic.AddInt64(&created, 1))
+			}))
+			defer server.Close()
+
+			h := newDummyHammer()
+			h.IterationCount = 20
+			h.Scenario.Steps[0].URL = server.URL
+			h.Scenario.Steps[0].Custom = map[string]interface{}{"capture": map[string]interface{}{
+				"order_id": map[string]interface{}{"json_path": "id", "collect": true},
+			}}
+			h.Scenario.Teardown = []types.ScenarioStep{{ID: 1, Protocol: "HTTP", Method: http.MethodDelete,
+				URL: server.URL + "/{{ .order_id }}", Custom: map[string]interface{}{"foreach": "order_id"}}}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			e, err := NewEngine(ctx, h)
+			if err != nil {
+				t.Fatalf("TestEngineTeardown error occurred %v", err)
+			}
+			rs := &teardownReport{}
+			e.reportService = rs
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineTeardown error occurred %v", err)
+			}
+			logOut := new(strings.Builder)
+			e.logOut = newLogLimiter(logOut)
+
+			if test.stopped {
+				time.AfterFunc(300*time.Millisecond, cancel)
+			}
+			e.Start()
+
+			if len(rs.teardown) != 1 {
+				t.Fatalf("Expected 1 teardown result, Found %v", rs.teardown)
+			}
+			// Requests canceled by the stop may be created without collecting their values
+			c := atomic.LoadInt64(&created)
+			r := rs.teardown[0]
+			if r.Collected == 0 || r.Processed != r.Collected || r.Requests != r.Collected ||
+				atomic.LoadInt64(&deleted) != r.Collected {
+				t.Errorf("All the collected values should be processed, Found %+v", r)
+			}
+			if !test.stopped && r.Collected != c {
+				t.Errorf("Collected Expected %d, Found %d", c, r.Collected)
+			}
+			if test.stopped && c == int64(h.IterationCount) {
+				t.Errorf("Test should be stopped before all the iterations")
+			}
+			if !strings.Contains(logOut.String(), "running the teardown steps") {
+				t.Errorf("Teardown notice should be logged, Found: %s", logOut.String())
+			}
+		})
+	}
+}
+
+func TestLogLimiter(t *testing.T) {
+	t.Parallel()
+
+	out := new(bytes.Buffer)
+	l := newLogLimiter(out)
+	now := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	// 25 failures and 5 other warnings in the first second, 10 of them are written
+	for i := 0; i < 25; i++ {
+		l.printf("warning: request %d failed", i)
+	}
+	for i := 0; i < 5; i++ {
+		l.printf("warning: poll %d failed", i)
+	}
+	if n := strings.Count(out.String(), "\n"); n != maxLogRate {
+		t.Errorf("Expected %d lines in the first second, Found %d: %s", maxLogRate, n, out.String())
+	}
+
+	// Suppressed counts are written once the next second starts, before its first line
+	out.Reset()
+	now = now.Add(time.Second)
+	l.printf("warning: request %d failed", 25)
+	expected := "suppressed 15 similar messages, the last one: warning: request 24 failed\n" +
+		"suppressed 5 similar messages, the last one: warning: poll 4 failed\n" +
+		"warning: request 25 failed\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, Found %q", expected, out.String())
+	}
+
+	for i := 26; i < 40; i++ {
+		l.printf("warning: request %d failed", i)
+	}
+	out.Reset()
+	l.flush()
+	if expected := "suppressed 5 similar messages, the last one: warning: request 39 failed\n"; out.String() != expected {
+		t.Errorf("Expected %q, Found %q", expected, out.String())
+	}
+	if n := l.suppressedCount(); n != 25 {
+		t.Errorf("Suppressed count Expected %d, Found %d", 25, n)
+	}
+
+	out.Reset()
+	l.flush()
+	if out.Len() != 0 {
+		t.Errorf("Flush should not repeat the suppressed counts, Found %q", out.String())
+	}
+}
+
+func TestLogLimiterConcurrent(t *testing.T) {
+	t.Parallel()
+
+	out := new(bytes.Buffer)
+	l := newLogLimiter(out)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.printf("warning: request failed")
+			}
+		}()
+	}
+	wg.Wait()
+	l.flush()
+
+	// Written and suppressed lines add up to all the lines
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != maxLogRate+1 {
+		t.Fatalf("Expected %d lines, Found %d", maxLogRate+1, len(lines))
+	}
+	if expected := fmt.Sprintf("suppressed %d similar messages", 800-maxLogRate); !strings.HasPrefix(lines[maxLogRate], expected) {
+		t.Errorf("Expected %q, Found %q", expected, lines[maxLogRate])
+	}
+	if n := l.suppressedCount(); n != 800-maxLogRate {
+		t.Errorf("Suppressed count Expected %d, Found %d", 800-maxLogRate, n)
+	}
+}
+
+func TestBurstReqCountArr(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		burst    types.LoadBurst
+		duration int
+		ticks    []int
+	}{
+		{"ByDuration", types.LoadBurst{Size: 5, Interval: 300 * time.Millisecond}, 1, []int{0, 3, 6, 9}},
+		{"ByCount", types.LoadBurst{Size: 5, Interval: 300 * time.Millisecond, Count: 2}, 1, []int{0, 3}},
+		{"RoundedToTick", types.LoadBurst{Size: 5, Interval: 260 * time.Millisecond}, 1, []int{0, 3, 6, 9}},
+		{"ShorterThanTick", types.LoadBurst{Size: 5, Interval: time.Millisecond, Count: 3}, 1, []int{0, 1, 2}},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newDummyHammer()
+			h.LoadType = types.LoadTypeBurst
+			h.TestDuration = test.duration
+			h.Burst = &test.burst
+			e, err := NewEngine(context.TODO(), h)
+			if err != nil {
+				t.Fatalf("TestBurstReqCountArr error occurred %v", err)
+			}
+			e.initReqCountArr()
+
+			var ticks []int
+			for i, c := range e.reqCountArr {
+				if c != 0 {
+					if c != test.burst.Size {
+						t.Errorf("Tick %d: Expected %d, Found %d", i, test.burst.Size, c)
+					}
+					ticks = append(ticks, i)
+				}
+			}
+			if !reflect.DeepEqual(ticks, test.ticks) {
+				t.Errorf("Expected bursts on the ticks %v, Found %v", test.ticks, ticks)
+			}
+			if e.hammer.IterationCount != arraySum(e.reqCountArr) || e.bursts == nil {
+				t.Errorf("Iteration count should be the sum of the bursts, Found %d", e.hammer.IterationCount)
+			}
+		})
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	t.Parallel()
+
+	step := types.ScenarioStep{ID: 1, Protocol: "HTTP", Method: "POST", URL: "http://127.0.0.1/orders",
+		Headers: map[string]string{"Content-Type": "application/json"}, Payload: `{"item": 1}`}
+	// POST http://127.0.0.1/orders HTTP/1.1, Host: 127.0.0.1, Content-Type: application/json and the body
+	stepSize := int64(len("POST http://127.0.0.1/orders HTTP/1.1\r\n") + len("Host: 127.0.0.1\r\n") +
+		len("Content-Type: application/json\r\n") + len("\r\n") + len(`{"item": 1}`))
+	cost := &types.Cost{PerRequest: 0.001, ResponseSize: 100, Budget: 1}
+
+	tests := []struct {
+		name       string
+		hammer     func(h *types.Hammer)
+		iterations int64
+		requests   int64
+		upperBound bool
+	}{
+		{"Linear", func(h *types.Hammer) { h.IterationCount, h.TestDuration = 300, 3 }, 300, 600, false},
+		{"Incremental", func(h *types.Hammer) {
+			h.LoadType, h.IterationCount, h.TestDuration = types.LoadTypeIncremental, 120, 4
+		}, 120, 240, false},
+		{"ManualLoad", func(h *types.Hammer) {
+			h.TimeRunCountMap = types.TimeRunCount{{Duration: 2, Count: 50}, {Duration: 1, Count: 30}}
+			h.TestDuration = 3
+		}, 80, 160, false},
+		{"Burst", func(h *types.Hammer) {
+			h.LoadType, h.TestDuration = types.LoadTypeBurst, 1
+			h.Burst = &types.LoadBurst{Size: 5, Interval: 300 * time.Millisecond}
+		}, 20, 40, false},
+		{"DynamicRate", func(h *types.Hammer) {
+			h.TestDuration = 10
+			h.DynamicRate = &types.DynamicRate{URL: "http://127.0.0.1/rate", Interval: time.Second, Max: 25}
+		}, 250, 500, true},
+		{"Teardown", func(h *types.Hammer) {
+			h.IterationCount, h.TestDuration = 10, 1
+			h.Scenario.Teardown = []types.ScenarioStep{{ID: 10, Protocol: "HTTP", Method: "POST",
+				URL: "http://127.0.0.1/orders", Headers: step.Headers, Payload: step.Payload}}
+		}, 10, 21, false},
+		{"Verify", func(h *types.Hammer) { h.VerifyCount = 3 }, 3, 6, false},
+		{"Preview", func(h *types.Hammer) { h.PreviewCount = 3 }, 0, 0, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newDummyHammer()
+			h.Scenario.Steps = []types.ScenarioStep{step, step}
+			h.Scenario.Steps[1].ID = 2
+			h.Cost = cost
+			test.hammer(&h)
+			if err := h.Validate(); err != nil {
+				t.Fatalf("Validate errored: %v", err)
+			}
+
+			e := Estimate(h)
+			if e.Iterations != test.iterations || e.Requests != test.requests || e.UpperBound != test.upperBound {
+				t.Errorf("Expected %d iterations and %d requests (upper bound %v), Found %d and %d (%v)",
+					test.iterations, test.requests, test.upperBound, e.Iterations, e.Requests, e.UpperBound)
+			}
+			if e.BytesSent != test.requests*stepSize {
+				t.Errorf("Bytes sent Expected %d, Found %d", test.requests*stepSize, e.BytesSent)
+			}
+			if e.BytesReceived != test.requests*cost.ResponseSize {
+				t.Errorf("Bytes received Expected %d, Found %d", test.requests*cost.ResponseSize, e.BytesReceived)
+			}
+		})
+	}
+}
+
+// burstReport records the bursts reported by the engine.
+type burstReport struct {
+	slowReport
+	mu     sync.Mutex
+	bursts []report.BurstSummary
+}
+
+func (r *burstReport) RecordBurst(b report.BurstSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bursts = append(r.bursts, b)
+}
+
+func TestEngineBurst(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		policy      string
+		maxInFlight int
+		delay       time.Duration
+		overlapped  int
+		skipped     int
+		completion  time.Duration
+	}{
+		// Bursts are 200ms apart, the slow target keeps the first burst running past the next ones
+		{"Idle", types.BurstPolicySkip, 0, 0, 0, 0, 0},
+		{"Skip", types.BurstPolicySkip, 0, 500 * time.Millisecond, 0, 2, 500 * time.Millisecond},
+		{"Overlap", "", 0, 500 * time.Millisecond, 2, 0, 500 * time.Millisecond},
+		{"MaxInFlight", types.BurstPolicySkip, 2, 50 * time.Millisecond, 0, 0, 100 * time.Millisecond},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var running, maxRunning int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt64(&running, 1)
+				defer atomic.AddInt64(&running, -1)
+				for m := atomic.LoadInt64(&maxRunning); n > m; m = atomic.LoadInt64(&maxRunning) {
+					if atomic.CompareAndSwapInt64(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(test.delay)
+			}))
+			defer server.Close()
+
+			h := newDummyHammer()
+			h.LoadType = types.LoadTypeBurst
+			h.Burst = &types.LoadBurst{Size: 4, Interval: 200 * time.Millisecond, Count: 3,
+				MaxInFlight: test.maxInFlight, Policy: test.policy}
+			h.TestDuration = h.Burst.TestDuration()
+			h.Scenario.Steps[0].URL = server.URL
+			h.Scenario.Steps[0].Timeout = types.DefaultTimeout
+
+			e, err := NewEngine(context.Background(), h)
+			if err != nil {
+				t.Fatalf("TestEngineBurst error occurred %v", err)
+			}
+			rs := &burstReport{}
+			e.reportService = rs
+			if err = e.Init(); err != nil {
+				t.Fatalf("TestEngineBurst error occurred %v", err)
+			}
+			e.Start()
+
+			if len(rs.bursts) != 3 {
+				t.Fatalf("Expected 3 bursts, Found %+v", rs.bursts)
+			}
+			overlapped, skipped := 0, 0
+			for _, b := range rs.bursts {
+				if b.Overlapped {
+					overlapped++
+				}
+				if b.Skipped {
+					skipped++
+					if b.Started != 0 {
+						t.Errorf("Skipped burst should not start iterations, Found %+v", b)
+					}
+					continue
+				}
+				if b.Size != 4 || b.Started != 4 || b.Requests != 4 || b.Failures != 0 {
+					t.Errorf("Burst should complete its iterations, Found %+v", b)
+				}
+				if c := time.Duration(float64(b.Completion) * float64(time.Second)); c < test.completion {
+					t.Errorf("Burst completion should be at least %v, Found %v", test.completion, c)
+				}
+			}
+			if overlapped != test.overlapped || skipped != test.skipped {
+				t.Errorf("Expected %d overlapped and %d skipped bursts, Found %d and %d",
+					test.overlapped, test.skipped, overlapped, skipped)
+			}
+			if test.maxInFlight > 0 && maxRunning > int64(test.maxInFlight) {
+				t.Errorf("Running iterations of a burst should be at most %d, Found %d", test.maxInFlight, maxRunning)
+			}
+		})
+	}
+}
+
+func TestCreateUsersReqCountArr(t *testing.T) {
+	t.Parallel()
+
+	h := newDummyHammer()
+	h.LoadType = types.LoadTypeVU
+	h.VirtualUsers = &types.VirtualUsers{Users: 4, IterationsPerUser: 3}
+	e, err := NewEngine(context.TODO(), h)
+	if err != nil {
+		t.Fatalf("TestCreateUsersReqCountArr error occurred %v", err)
+	}
+	e.initReqCountArr()
+
+	// All the iterations are planned on the tick starting the users
+	if !reflect.DeepEqual(e.reqCountArr, []int{12}) || e.hammer.IterationCount != 12 || e.users == nil {
+		t.Errorf("Expected the plan [12], Found %v with %d iterations", e.reqCountArr, e.hammer.IterationCount)
+	}
+}
+
+// userReport records the users reported by the engine.
+type userReport struct {
+	slowReport
+	mu    sync.Mutex
+	users []report.UserSummary
+}
+
+func (r *userReport) RecordUser(u report.UserSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users = append(r.users, u)
+}
+
+func TestEngineVirtualUsers(t *testing.T) {
+	t.Parallel()
+
+	// Targets requested by each user, by the vu id of the requests
+	var mu sync.Mutex
+	requested := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		vu := r.Header.Get("X-Vu")
+		requested[vu] = append(requested[vu], r.URL.Path)
+	}))
+	defer server.Close()
+
+	// 8 targets for 3 users, the first 2 users take a target more
+	var lines []string
+	for i := 0; i < 8; i++ {
+		lines = append(lines, fmt.Sprintf("%s/%d", server.URL, i))
+	}
+	path := t.TempDir() + "/targets.txt"
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newDummyHammer()
+	h.LoadType = types.LoadTypeVU
+	h.VirtualUsers = &types.VirtualUsers{Users: 3, IterationsPerUser: 3}
+	h.Scenario.Steps[0].URL = server.URL
+	h.Scenario.Steps[0].Timeout = types.DefaultTimeout
+	h.Scenario.Steps[0].Headers = map[string]string{"X-Vu": "{{_vuId}}"}
+	h.Scenario.Steps[0].Custom = map[string]interface{}{"targets-file": path}
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineVirtualUsers error occurred %v", err)
+	}
+	rs := &userReport{}
+	e.reportService = rs
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineVirtualUsers error occurred %v", err)
+	}
+	e.Start()
+
+	expected := map[string][]string{"0": {"/0", "/1", "/2"}, "1": {"/3", "/4", "/5"}, "2": {"/6", "/7", "/6"}}
+	if !reflect.DeepEqual(requested, expected) {
+		t.Errorf("Expected the targets %v, Found %v", expected, requested)
+	}
+	if len(rs.users) != 3 {
+		t.Fatalf("Expected 3 users, Found %+v", rs.users)
+	}
+	for _, u := range rs.users {
+		if u.Iterations != 3 || u.Completed != 3 {
+			t.Errorf("User should complete its iterations, Found %+v", u)
+		}
+	}
+}
+
+type latencyReport struct {
+	slowReport
+	mu       sync.Mutex
+	timeouts []time.Duration
+}
+
+func (r *latencyReport) Start(input chan *types.ScenarioResult) {
+	for res := range input {
+		r.mu.Lock()
+		for _, sr := range res.StepResults {
+			r.timeouts = append(r.timeouts, sr.Custom["adaptiveTimeout"].(time.Duration))
+		}
+		r.mu.Unlock()
+	}
+	r.doneChan <- struct{}{}
+}
+
+func (r *latencyReport) RollingP99(stepID uint16) (time.Duration, bool) {
+	return 100 * time.Millisecond, stepID == 1
+}
+
+func TestEngineAdaptiveTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	c := clock.NewFake(time.Now())
+	h := newDummyHammer()
+	h.Clock = c
+	h.TestDuration = 3
+	h.IterationCount = 30
+	h.Scenario.Steps[0].URL = server.URL
+	h.Scenario.Steps[0].Timeout = types.DefaultTimeout
+	h.Scenario.Steps[0].Custom = map[string]interface{}{"adaptive-timeout": map[string]interface{}{
+		"factor": float64(2), "interval": float64(1)}}
+
+	e, err := NewEngine(context.Background(), h)
+	if err != nil {
+		t.Fatalf("TestEngineAdaptiveTimeout error occurred %v", err)
+	}
+	rs := &latencyReport{}
+	e.reportService = rs
+	if err = e.Init(); err != nil {
+		t.Fatalf("TestEngineAdaptiveTimeout error occurred %v", err)
+	}
+	startOnFakeClock(e, c)
+
+	// Requests time out at the step timeout until the first update, factor times the p99 afterwards
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.timeouts) != 30 {
+		t.Fatalf("Expected 30 results, Found %d", len(rs.timeouts))
+	}
+	if first := rs.timeouts[0]; first != time.Duration(types.DefaultTimeout)*time.Second {
+		t.Errorf("First timeout Expected the step timeout, Found %s", first)
+	}
+	if last := rs.timeouts[29]; last != 200*time.Millisecond {
+		t.Errorf("Last timeout Expected 200ms, Found %s", last)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package core
+
+import (
+	"math"
+
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Estimate returns the load of the test planned by the validated hammer, before the test runs. The iterations of the
+// load types are planned like the engine plans them, the schedule as if the test starts now.
+func Estimate(h types.Hammer) types.Estimate {
+	c := h.Clock
+	if c == nil {
+		c = clock.Real()
+	}
+	e := &engine{hammer: h, clock: c}
+	if h.PreviewCount == 0 && h.VerifyCount == 0 && h.DynamicRate == nil {
+		e.initReqCountArr()
+	}
+	return e.estimate()
+}
+
+// estimate returns the estimated load of the planned iterations. The dynamic rate is planned at its max rate, the
+// preview sends no request and the verify mode runs its iterations.
+func (e *engine) estimate() types.Estimate {
+	h := e.hammer
+	var iterations int64
+	upperBound := false
+	switch {
+	case h.PreviewCount > 0:
+		return types.NewEstimate(types.Scenario{}, 0, false, h.Cost)
+	case h.VerifyCount > 0:
+		iterations = int64(h.VerifyCount)
+	case h.DynamicRate != nil:
+		iterations, upperBound = int64(math.Ceil(h.DynamicRate.Max*float64(h.TestDuration))), true
+	default:
+		iterations = int64(arraySum(e.reqCountArr))
+	}
+	return types.NewEstimate(h.Scenario, iterations, upperBound, h.Cost)
+}
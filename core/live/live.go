@@ -0,0 +1,120 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package live
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Snapshot is the aggregated result of the running test that the live template is rendered with. Durations are in
+// seconds and the rates are in percentages.
+type Snapshot struct {
+	// running, draining or finished
+	Status string
+
+	Elapsed float64
+
+	// Completed percentage of the planned iterations and the estimated seconds to the end, 0 if unknown
+	Progress int
+	ETA      float64
+
+	Iterations  int64
+	Success     int64
+	Failed      int64
+	SuccessRate float64
+	FailRate    float64
+	RPS         float64
+	AvgDuration float64
+
+	BytesSent     int64
+	BytesReceived int64
+
+	Steps map[uint16]StepSnapshot
+}
+
+// Step returns the snapshot of the step, zero if the step has no result yet.
+func (s Snapshot) Step(id uint16) StepSnapshot {
+	return s.Steps[id]
+}
+
+// StepSnapshot is the aggregated result of a step. Percentiles are of the successful requests so far.
+type StepSnapshot struct {
+	Name        string
+	Success     int64
+	Failed      int64
+	SuccessRate float64
+	FailRate    float64
+	AvgDuration float64
+	P50         float64
+	P90         float64
+	P95         float64
+	P99         float64
+
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// Template is the parsed live template.
+type Template struct {
+	t *template.Template
+}
+
+// Formatting functions of the live template in the raw format, the report services override them by WithFuncs
+var defaultFuncs = template.FuncMap{
+	"duration": func(seconds float64) string { return strconv.FormatFloat(seconds, 'f', 4, 64) + "s" },
+	"count":    func(n int64) string { return strconv.FormatInt(n, 10) },
+	"bytes":    func(n int64) string { return strconv.FormatInt(n, 10) + " B" },
+	"rate":     func(rate float64) string { return strconv.FormatFloat(rate, 'f', 1, 64) + "/s" },
+	"percent":  func(p float64) string { return strconv.FormatFloat(p, 'f', 1, 64) + "%" },
+}
+
+// Parse parses the live template like "{{.RPS}} rps, p99 {{duration (.Step 2).P99}}". The template is rendered once
+// with an empty snapshot, so the unknown fields fail the parse instead of the live print.
+func Parse(text string) (*Template, error) {
+	t, err := template.New("live").Funcs(defaultFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid live template: %v", err)
+	}
+	l := &Template{t: t}
+	if _, err = l.Render(Snapshot{}); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// WithFuncs returns the template with the formatting functions overridden by the given ones.
+func (l *Template) WithFuncs(funcs template.FuncMap) *Template {
+	t, _ := l.t.Clone()
+	return &Template{t: t.Funcs(funcs)}
+}
+
+// Render renders the template as a single line, the line breaks of the template are replaced by spaces.
+func (l *Template) Render(s Snapshot) (string, error) {
+	var b bytes.Buffer
+	if err := l.t.Execute(&b, s); err != nil {
+		return "", fmt.Errorf("invalid live template: %v", err)
+	}
+	return strings.TrimSpace(strings.ReplaceAll(b.String(), "\n", " ")), nil
+}
@@ -0,0 +1,99 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package live
+
+import (
+	"testing"
+	"text/template"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	snapshot := Snapshot{
+		Status:      "running",
+		Iterations:  120,
+		RPS:         40.3,
+		SuccessRate: 99.5,
+		BytesSent:   2048,
+		Steps: map[uint16]StepSnapshot{
+			2: {Name: "checkout", P99: 0.25},
+		},
+	}
+	tests := []struct {
+		name      string
+		text      string
+		expected  string
+		shouldErr bool
+	}{
+		{"Fields", "{{.Status}} {{.Iterations}} iterations", "running 120 iterations", false},
+		{"Step", "{{(.Step 2).Name}} p99 {{duration (.Step 2).P99}}", "checkout p99 0.2500s", false},
+		{"MissingStep", "p99 {{duration (.Step 7).P99}}", "p99 0.0000s", false},
+		{"Funcs", "{{rate .RPS}} {{percent .SuccessRate}} {{bytes .BytesSent}} {{count .Iterations}}",
+			"40.3/s 99.5% 2048 B 120", false},
+		{"SingleLine", "{{.Status}}\n{{.Iterations}}\n", "running 120", false},
+		{"InvalidSyntax", "{{.Status", "", true},
+		{"UnknownField", "{{.P99}}", "", true},
+		{"UnknownFunc", "{{round .RPS}}", "", true},
+		{"InvalidArg", "{{duration .Iterations}}", "", true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			l, err := Parse(test.text)
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse errored: %v", err)
+			}
+			line, err := l.Render(snapshot)
+			if err != nil {
+				t.Fatalf("Render errored: %v", err)
+			}
+			if line != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, line)
+			}
+		})
+	}
+}
+
+func TestWithFuncs(t *testing.T) {
+	t.Parallel()
+
+	l, err := Parse("{{count .Iterations}}")
+	if err != nil {
+		t.Fatalf("Parse errored: %v", err)
+	}
+	human := l.WithFuncs(template.FuncMap{"count": func(n int64) string { return "many" }})
+	if line, _ := human.Render(Snapshot{Iterations: 1000}); line != "many" {
+		t.Errorf("Expected the overridden format, Found %q", line)
+	}
+	if line, _ := l.Render(Snapshot{Iterations: 1000}); line != "1000" {
+		t.Errorf("Original template should keep the raw format, Found %q", line)
+	}
+}
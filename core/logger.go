@@ -0,0 +1,114 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLogRate is the upper bound of the lines logged by the engine in a second, so a failing target doesn't flood
+// the terminal with the same warning and slow the test.
+const maxLogRate = 10
+
+// logLimiter writes the log lines of the engine into out, up to limit lines per second. The lines over the limit are
+// only counted by their format, and a "suppressed N similar messages" line is written for each format once the next
+// second starts or flush is called. It is safe for concurrent use.
+type logLimiter struct {
+	mu    sync.Mutex
+	out   io.Writer
+	limit int
+	now   func() time.Time
+
+	windowStart time.Time
+	written     int
+
+	// Suppressed lines of the current window by their formats, in the order of the first suppression
+	suppressed map[string]*suppressedLog
+	order      []string
+	total      int64
+}
+
+type suppressedLog struct {
+	count int64
+	last  string
+}
+
+func newLogLimiter(out io.Writer) *logLimiter {
+	return &logLimiter{out: out, limit: maxLogRate, now: time.Now, suppressed: make(map[string]*suppressedLog)}
+}
+
+// printf writes the line formatted by fmt.Sprintf unless the limit of the current second is reached.
+func (l *logLimiter) printf(format string, a ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.windowStart) >= time.Second {
+		l.writeSuppressed()
+		l.windowStart = now
+		l.written = 0
+	}
+
+	msg := strings.TrimSuffix(fmt.Sprintf(format, a...), "\n")
+	if l.written >= l.limit {
+		s, ok := l.suppressed[format]
+		if !ok {
+			s = &suppressedLog{}
+			l.suppressed[format] = s
+			l.order = append(l.order, format)
+		}
+		s.count++
+		s.last = msg
+		l.total++
+		return
+	}
+	l.written++
+	fmt.Fprintln(l.out, msg)
+}
+
+// flush writes the counts of the lines suppressed in the current second.
+func (l *logLimiter) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writeSuppressed()
+}
+
+// suppressedCount returns the count of all the lines suppressed so far.
+func (l *logLimiter) suppressedCount() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// writeSuppressed writes a line for each suppressed format with the last suppressed message, l.mu should be held.
+// The summary lines are not limited, there is one line for each format.
+func (l *logLimiter) writeSuppressed() {
+	for _, format := range l.order {
+		s := l.suppressed[format]
+		fmt.Fprintf(l.out, "suppressed %d similar messages, the last one: %s\n", s.count, s.last)
+		delete(l.suppressed, format)
+	}
+	l.order = l.order[:0]
+}
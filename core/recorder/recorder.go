@@ -0,0 +1,286 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExcludeContentTypes are the content types of the static assets which are not recorded by default.
+var DefaultExcludeContentTypes = []string{"image/", "font/", "text/css", "javascript", "video/", "audio/"}
+
+// Hop-by-hop headers are meaningful only for a single connection, they are neither forwarded nor recorded.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Config is used for initializing the Recorder.
+type Config struct {
+	// Directory of the CA material used for intercepting the HTTPS connections.
+	CADir string
+
+	// Requests whose response content type contains one of these values are not recorded.
+	ExcludeContentTypes []string
+
+	// If set, only the requests to these domains and their subdomains are recorded.
+	AllowedDomains []string
+}
+
+// Recorder is an HTTP proxy that records the requests passing through it as the steps of a scenario.
+// HTTPS requests are intercepted by the certificates signed by the recorder's CA.
+type Recorder struct {
+	cfg       Config
+	ca        *certAuthority
+	transport *http.Transport
+
+	mu      sync.Mutex
+	records []record
+}
+
+// record is a request captured by the Recorder.
+type record struct {
+	method  string
+	url     string
+	headers http.Header
+	body    []byte
+}
+
+// NewRecorder creates a Recorder. The CA is generated into cfg.CADir if it doesn't exist yet.
+func NewRecorder(cfg Config) (*Recorder, error) {
+	ca, err := loadOrCreateCA(cfg.CADir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		cfg: cfg,
+		ca:  ca,
+		transport: &http.Transport{
+			TLSClientConfig:     &tls.Config{},
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+			// Pass the compressed bodies as is, the client asked for them.
+			DisableCompression: true,
+		},
+	}, nil
+}
+
+// CACertPath returns the path of the CA certificate that should be trusted on the recorded device.
+func (r *Recorder) CACertPath() string {
+	return filepath.Join(r.cfg.CADir, CACertFile)
+}
+
+// StepCount returns the recorded step count.
+func (r *Recorder) StepCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.records)
+}
+
+// ListenAndServe runs the recording proxy on the given address until the context is done.
+func (r *Recorder) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: r}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		r.intercept(w, req)
+		return
+	}
+	if !req.URL.IsAbs() {
+		http.Error(w, "ddosify recorder is a proxy, configure it as the http proxy of the client", http.StatusBadRequest)
+		return
+	}
+
+	res, err := r.forward(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+
+	removeHopHeaders(res.Header)
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(res.StatusCode)
+	io.Copy(w, res.Body)
+}
+
+// intercept terminates the TLS of the CONNECT tunnel with a certificate signed by the CA
+// and proxies the HTTP requests flowing in it.
+func (r *Recorder) intercept(w http.ResponseWriter, req *http.Request) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection can not be hijacked", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if _, err = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	connectHost := req.URL.Host
+	tlsConn := tls.Server(conn, &tls.Config{
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name, _, _ = net.SplitHostPort(connectHost)
+			}
+			return r.ca.leafFor(name)
+		},
+	})
+	if err = tlsConn.Handshake(); err != nil {
+		return
+	}
+
+	br := bufio.NewReader(tlsConn)
+	for {
+		tReq, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+
+		tReq.URL.Scheme = "https"
+		tReq.URL.Host = tReq.Host
+		if tReq.URL.Host == "" {
+			tReq.URL.Host = connectHost
+		}
+
+		res, err := r.forward(tReq)
+		if err != nil {
+			res = &http.Response{
+				StatusCode:    http.StatusBadGateway,
+				Header:        http.Header{},
+				Body:          io.NopCloser(strings.NewReader(err.Error())),
+				ContentLength: int64(len(err.Error())),
+			}
+		}
+
+		removeHopHeaders(res.Header)
+		res.Proto, res.ProtoMajor, res.ProtoMinor = "HTTP/1.1", 1, 1
+		err = res.Write(tlsConn)
+		res.Body.Close()
+		if err != nil || tReq.Close || res.Close {
+			return
+		}
+	}
+}
+
+// forward sends the request to its target and records it if it passes the filters.
+func (r *Recorder) forward(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	out.ContentLength = int64(len(body))
+	out.Body = http.NoBody
+	if len(body) > 0 {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	removeHopHeaders(out.Header)
+
+	res, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.shouldRecord(out.URL, res.Header.Get("Content-Type")) {
+		r.mu.Lock()
+		r.records = append(r.records, record{
+			method:  out.Method,
+			url:     out.URL.String(),
+			headers: out.Header.Clone(),
+			body:    body,
+		})
+		r.mu.Unlock()
+	}
+	return res, nil
+}
+
+func (r *Recorder) shouldRecord(u *url.URL, contentType string) bool {
+	if len(r.cfg.AllowedDomains) > 0 {
+		host := strings.ToLower(u.Hostname())
+		allowed := false
+		for _, d := range r.cfg.AllowedDomains {
+			d = strings.ToLower(strings.TrimPrefix(d, "."))
+			if host == d || strings.HasSuffix(host, "."+d) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	contentType = strings.ToLower(contentType)
+	for _, t := range r.cfg.ExcludeContentTypes {
+		if t != "" && strings.Contains(contentType, strings.ToLower(t)) {
+			return false
+		}
+	}
+	return true
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package recorder
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.ddosify.com/ddosify/config"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func newTestRecorder(t *testing.T, cfg Config) (*Recorder, *http.Client) {
+	cfg.CADir = t.TempDir()
+	rec, err := NewRecorder(cfg)
+	if err != nil {
+		t.Fatalf("NewRecorder errored: %v", err)
+	}
+
+	proxyServer := httptest.NewServer(rec)
+	t.Cleanup(proxyServer.Close)
+	proxyURL, _ := url.Parse(proxyServer.URL)
+
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: rec.ca.pool()},
+	}}
+	return rec, client
+}
+
+func TestRecordReplay(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logo.png" {
+			w.Header().Set("Content-Type", "image/png")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		io.Copy(w, r.Body)
+	})
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	httpsServer := httptest.NewTLSServer(handler)
+	defer httpsServer.Close()
+
+	rec, client := newTestRecorder(t, Config{ExcludeContentTypes: DefaultExcludeContentTypes})
+	rec.transport.TLSClientConfig = httpsServer.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	binaryBody := []byte{0xff, 0xfe, 0x00, 0x01}
+	requests := []struct {
+		method string
+		url    string
+		body   []byte
+	}{
+		{http.MethodPost, httpServer.URL + "/login", []byte(`{"user":"test"}`)},
+		{http.MethodGet, httpServer.URL + "/logo.png", nil},
+		{http.MethodGet, httpsServer.URL + "/profile?id=1", nil},
+		{http.MethodPut, httpsServer.URL + "/avatar", binaryBody},
+	}
+	for _, r := range requests {
+		req, _ := http.NewRequest(r.method, r.url, bytes.NewReader(r.body))
+		req.Header.Set("X-Test", "ddosify")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request through the recorder errored: %v", err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if !bytes.Equal(body, r.body) {
+			t.Errorf("Response body Expected %q, Found %q", r.body, body)
+		}
+	}
+
+	if rec.StepCount() != 3 {
+		t.Fatalf("StepCount Expected %d, Found %d", 3, rec.StepCount())
+	}
+
+	out := filepath.Join(t.TempDir(), "scenario.json")
+	if err := rec.WriteScenario(out); err != nil {
+		t.Fatalf("WriteScenario errored: %v", err)
+	}
+
+	// Recorded config should be read by the config reader without edits.
+	b, _ := os.ReadFile(out)
+	c, err := config.NewConfigReader(b, config.ConfigTypeJson)
+	if err != nil {
+		t.Fatalf("Recorded config could not be read: %v", err)
+	}
+	h, err := c.CreateHammer()
+	if err != nil {
+		t.Fatalf("Recorded config could not be converted to hammer: %v", err)
+	}
+	if err = h.Validate(); err != nil {
+		t.Fatalf("Recorded hammer is invalid: %v", err)
+	}
+
+	expected := []types.ScenarioStep{
+		{ID: 1, Name: "POST /login", Method: http.MethodPost, URL: httpServer.URL + "/login",
+			Protocol: types.ProtocolHTTP, Payload: `{"user":"test"}`},
+		{ID: 2, Name: "GET /profile", Method: http.MethodGet, URL: httpsServer.URL + "/profile?id=1",
+			Protocol: types.ProtocolHTTPS},
+		{ID: 3, Name: "PUT /avatar", Method: http.MethodPut, URL: httpsServer.URL + "/avatar",
+			Protocol: types.ProtocolHTTPS, Payload: string(binaryBody), RawPayload: true},
+	}
+	steps := h.Scenario.Steps
+	for i, e := range expected {
+		s := steps[i]
+		if s.ID != e.ID || s.Name != e.Name || s.Method != e.Method || s.URL != e.URL ||
+			s.Protocol != e.Protocol || s.Payload != e.Payload || s.RawPayload != e.RawPayload {
+			t.Errorf("Step %d Expected %+v, Found %+v", i, e, s)
+		}
+		if s.Headers["X-Test"] != "ddosify" {
+			t.Errorf("Step %d header should be recorded, Found %v", i, s.Headers)
+		}
+		if _, ok := s.Headers["Content-Length"]; ok {
+			t.Errorf("Step %d Content-Length header should not be recorded", i)
+		}
+	}
+}
+
+func TestShouldRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		url         string
+		contentType string
+		expected    bool
+	}{
+		{"NoFilter", Config{}, "https://example.com/a.css", "text/css", true},
+		{"ExcludedType", Config{ExcludeContentTypes: DefaultExcludeContentTypes},
+			"https://example.com/app.js", "application/javascript; charset=utf-8", false},
+		{"IncludedType", Config{ExcludeContentTypes: DefaultExcludeContentTypes},
+			"https://example.com/api", "application/json", true},
+		{"AllowedDomain", Config{AllowedDomains: []string{"example.com"}}, "https://example.com/api", "", true},
+		{"AllowedSubdomain", Config{AllowedDomains: []string{"example.com"}}, "https://api.Example.com/", "", true},
+		{"NotAllowedDomain", Config{AllowedDomains: []string{"example.com"}}, "https://cdn.other.com/", "", false},
+		{"SuffixNotSubdomain", Config{AllowedDomains: []string{"example.com"}}, "https://badexample.com/", "", false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			r := &Recorder{cfg: test.cfg}
+			u, _ := url.Parse(test.url)
+			if got := r.shouldRecord(u, test.contentType); got != test.expected {
+				t.Errorf("Expected %v, Found %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir := t.TempDir()
+	created, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("CA could not be created: %v", err)
+	}
+
+	loaded, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("CA could not be loaded: %v", err)
+	}
+	if !created.cert.Equal(loaded.cert) {
+		t.Errorf("Existing CA should be loaded instead of generating a new one")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, CAKeyFile))
+	if err != nil {
+		t.Fatalf("CA key could not be found: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("CA key permissions Expected %v, Found %v", os.FileMode(0600), info.Mode().Perm())
+	}
+
+	leaf, err := loaded.leafFor("example.com")
+	if err != nil {
+		t.Fatalf("Leaf certificate could not be created: %v", err)
+	}
+	cached, _ := loaded.leafFor("example.com")
+	if leaf != cached {
+		t.Errorf("Leaf certificates should be cached per host")
+	}
+}
@@ -0,0 +1,139 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// scenarioFile is the subset of the config file schema read by config.JsonReader.
+type scenarioFile struct {
+	IterCount int        `json:"iteration_count"`
+	LoadType  string     `json:"load_type"`
+	Duration  int        `json:"duration"`
+	Steps     []stepFile `json:"steps"`
+}
+
+type stepFile struct {
+	Id          uint16            `json:"id"`
+	Name        string            `json:"name"`
+	Url         string            `json:"url"`
+	Method      string            `json:"method"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Payload     string            `json:"payload,omitempty"`
+	PayloadFile string            `json:"payload_file,omitempty"`
+	Templating  *bool             `json:"templating,omitempty"`
+}
+
+// Scenario returns the recorded requests as a config file, steps are in the recording order.
+// Bodies which are not valid UTF-8 can't be kept in the json as is, they are written into payloadDir
+// and referenced by the payload_file field, they are sent verbatim.
+func (r *Recorder) Scenario(payloadDir string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := scenarioFile{
+		IterCount: types.DefaultIterCount,
+		LoadType:  types.DefaultLoadType,
+		Duration:  types.DefaultDuration,
+		Steps:     make([]stepFile, 0, len(r.records)),
+	}
+
+	for i, rec := range r.records {
+		s := stepFile{
+			Id:      uint16(i + 1),
+			Name:    stepName(rec),
+			Url:     rec.url,
+			Method:  rec.method,
+			Headers: flattenHeaders(rec.headers),
+		}
+
+		if utf8.Valid(rec.body) {
+			s.Payload = string(rec.body)
+		} else {
+			if err := os.MkdirAll(payloadDir, 0755); err != nil {
+				return nil, err
+			}
+			s.PayloadFile = filepath.Join(payloadDir, fmt.Sprintf("step_%d.bin", s.Id))
+			if err := os.WriteFile(s.PayloadFile, rec.body, 0644); err != nil {
+				return nil, err
+			}
+			templating := false
+			s.Templating = &templating
+		}
+		f.Steps = append(f.Steps, s)
+	}
+
+	return json.MarshalIndent(f, "", "    ")
+}
+
+// WriteScenario writes the recorded scenario config into the given path.
+// Binary bodies are written into the "<path without extension>_payloads" directory.
+func (r *Recorder) WriteScenario(path string) error {
+	payloadDir := strings.TrimSuffix(path, filepath.Ext(path)) + "_payloads"
+	b, err := r.Scenario(payloadDir)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// stepName returns a name like "POST /api/login".
+func stepName(rec record) string {
+	path := rec.url
+	if u, err := url.Parse(rec.url); err == nil {
+		path = u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+	}
+	return rec.method + " " + path
+}
+
+// flattenHeaders converts the multi value headers to the single value headers of the config file.
+// Content-Length is dropped since it is calculated from the payload while sending.
+func flattenHeaders(h http.Header) map[string]string {
+	if len(h) == 0 {
+		return nil
+	}
+
+	m := make(map[string]string, len(h))
+	for k, v := range h {
+		if k == "Content-Length" {
+			continue
+		}
+		sep := ", "
+		if k == "Cookie" {
+			sep = "; "
+		}
+		m[k] = strings.Join(v, sep)
+	}
+	return m
+}
@@ -0,0 +1,182 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// AbortRule is an abort rule of the test resolved to the ID of the step it refers to.
+type AbortRule struct {
+	Rule   *criteria.AbortRule
+	StepID uint16
+}
+
+// AbortWindow is a window of the abort rule that stopped the test, with the value of the rule metric.
+type AbortWindow struct {
+	// Seconds since the start of the test
+	Start    float64 `json:"start"`
+	Value    float64 `json:"value"`
+	Requests int64   `json:"requests"`
+}
+
+// FiredRule is the abort rule that stopped the test with its consecutive violating windows.
+type FiredRule struct {
+	Rule    string        `json:"rule"`
+	Windows []AbortWindow `json:"windows"`
+
+	rule *criteria.AbortRule
+}
+
+// abortWindows evaluates the abort rules over the back to back windows of the test. Each result is added to
+// the current window of the rules of its step in O(1), a window is evaluated once when the next one starts.
+// It is safe for concurrent use.
+type abortWindows struct {
+	mu    sync.Mutex
+	rules []*ruleWindow
+}
+
+func newAbortWindows(rules []AbortRule) *abortWindows {
+	if len(rules) == 0 {
+		return nil
+	}
+	w := &abortWindows{rules: make([]*ruleWindow, len(rules))}
+	for i, r := range rules {
+		w.rules[i] = &ruleWindow{AbortRule: r, durations: newHistogram()}
+	}
+	return w
+}
+
+// observe adds the step results of the iteration finished at elapsed since the start of the test. Returns the
+// first rule that held for its windows, nil if none.
+func (w *abortWindows) observe(r *types.ScenarioResult, elapsed time.Duration) *FiredRule {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var fired *FiredRule
+	for _, rw := range w.rules {
+		if f := rw.advance(int64(elapsed / rw.Rule.Window)); f != nil && fired == nil {
+			fired = f
+		}
+		for _, sr := range r.StepResults {
+			if sr.StepID == rw.StepID {
+				rw.add(sr)
+			}
+		}
+	}
+	return fired
+}
+
+// ruleWindow is the current window of an abort rule and the violating windows right before it.
+type ruleWindow struct {
+	AbortRule
+
+	index       int64
+	requests    int64
+	failures    int64
+	durations   *histogram
+	durationSum time.Duration
+
+	violations []AbortWindow
+}
+
+func (rw *ruleWindow) add(sr *types.ScenarioStepResult) {
+	rw.requests++
+	if sr.Err.Type != "" {
+		rw.failures++
+		return
+	}
+	rw.durations.add(sr.Duration)
+	rw.durationSum += sr.Duration
+}
+
+// advance closes the current window if the given window is a later one. Returns the rule if the closed window
+// completes its consecutive violations. A window without a value, like a skipped one without any request,
+// breaks the violations.
+func (rw *ruleWindow) advance(index int64) *FiredRule {
+	if index <= rw.index {
+		return nil
+	}
+
+	if value, ok := rw.value(); ok && rw.Rule.Violated(value) {
+		rw.violations = append(rw.violations, AbortWindow{
+			Start:    (time.Duration(rw.index) * rw.Rule.Window).Seconds(),
+			Value:    value,
+			Requests: rw.requests,
+		})
+	} else {
+		rw.violations = nil
+	}
+
+	var fired *FiredRule
+	if len(rw.violations) >= rw.Rule.Windows {
+		fired = &FiredRule{Rule: rw.Rule.String(), Windows: rw.violations, rule: rw.Rule}
+	}
+	if fired != nil || index > rw.index+1 {
+		rw.violations = nil
+	}
+
+	rw.index = index
+	rw.requests, rw.failures, rw.durationSum = 0, 0, 0
+	rw.durations = newHistogram()
+	return fired
+}
+
+// value returns the metric of the rule over the current window, false if it is not available.
+// Durations are of the successful requests.
+func (rw *ruleWindow) value() (float64, bool) {
+	success := rw.requests - rw.failures
+	switch metric := rw.Rule.Ident().Metric; metric {
+	case "fail_count":
+		return float64(rw.failures), rw.requests > 0
+	case "fail_rate":
+		return ratio(rw.failures, rw.requests)
+	case "avg_duration":
+		if success == 0 {
+			return 0, false
+		}
+		return (rw.durationSum / time.Duration(success)).Seconds(), true
+	default:
+		p, _ := strconv.Atoi(strings.TrimPrefix(metric, "p"))
+		return rw.durations.percentile(p).Seconds(), success > 0
+	}
+}
+
+// printFiredRule prints the violating windows of the abort rule that stopped the test.
+func printFiredRule(w io.Writer, f *FiredRule) {
+	fmt.Fprintln(w, "Abort Rule Windows (Start:Value:Requests):")
+	for _, win := range f.Windows {
+		value := fmt.Sprint(win.Value)
+		if f.rule != nil {
+			value = f.rule.FormatValue(win.Value)
+		}
+		fmt.Fprintf(w, "  %s\t:%s\t:%s\n", time.Duration(win.Start*float64(time.Second)), value,
+			formatCount(win.Requests))
+	}
+}
@@ -0,0 +1,197 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"sync"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Seconds of the window of the rolling p99
+const latencyWindowSeconds = int64(types.AdaptiveTimeoutWindow / time.Second)
+
+// AdaptiveTimeoutSummary is the timeouts applied to the requests of a step with an adaptive-timeout.
+type AdaptiveTimeoutSummary struct {
+	// Range of the timeouts applied over the run in seconds
+	MinTimeout float32 `json:"min_timeout"`
+	MaxTimeout float32 `json:"max_timeout"`
+
+	// Requests timed out by an adaptive timeout under the max of the option, which the static max would have given
+	// more time, and the requests timed out at the max, which would have timed out with the static max too
+	CutCount        int64 `json:"cut_count"`
+	MaxTimeoutCount int64 `json:"max_timeout_count"`
+}
+
+// adaptiveTimeoutTracker tracks the timeouts applied to the requests of a step, created by the first request of a step
+// with an adaptive-timeout. It keeps the window of the durations that the rolling p99 is computed from. The window is
+// not merged, the timeouts are fed from the aggregators of the report service.
+type adaptiveTimeoutTracker struct {
+	minTimeout, maxTimeout time.Duration
+	cut, maxCut            int64
+	window                 latencyWindow
+}
+
+func newAdaptiveTimeoutTracker() *adaptiveTimeoutTracker {
+	return &adaptiveTimeoutTracker{window: latencyWindow{buckets: make(map[int64]*histogram)}}
+}
+
+// add tracks the timeout of a request. The requests cut by the timeout are in the window with their durations, a lower
+// bound of their latencies, so the timeout grows back once the latency of the step rises.
+func (t *adaptiveTimeoutTracker) add(sr *types.ScenarioStepResult, timeout time.Duration) {
+	if t.maxTimeout == 0 || timeout < t.minTimeout {
+		t.minTimeout = timeout
+	}
+	if timeout > t.maxTimeout {
+		t.maxTimeout = timeout
+	}
+	cut, _ := sr.Custom["timeoutCut"].(string)
+	switch cut {
+	case "adaptive":
+		t.cut++
+	case "max":
+		t.maxCut++
+	}
+	if sr.Err.Type == "" || cut != "" {
+		t.window.add(sr.RequestTime, sr.Duration)
+	}
+}
+
+func (t *adaptiveTimeoutTracker) merge(o *adaptiveTimeoutTracker) {
+	if t.maxTimeout == 0 || (o.maxTimeout != 0 && o.minTimeout < t.minTimeout) {
+		t.minTimeout = o.minTimeout
+	}
+	if o.maxTimeout > t.maxTimeout {
+		t.maxTimeout = o.maxTimeout
+	}
+	t.cut += o.cut
+	t.maxCut += o.maxCut
+}
+
+// summary returns the summary of the timeouts, nil if the step has no adaptive-timeout.
+func (t *adaptiveTimeoutTracker) summary() *AdaptiveTimeoutSummary {
+	if t == nil || t.maxTimeout == 0 {
+		return nil
+	}
+	return &AdaptiveTimeoutSummary{
+		MinTimeout:      float32(t.minTimeout.Seconds()),
+		MaxTimeout:      float32(t.maxTimeout.Seconds()),
+		CutCount:        t.cut,
+		MaxTimeoutCount: t.maxCut,
+	}
+}
+
+// latencyWindow keeps the histograms of the durations of a step per second of their request times, over the last
+// types.AdaptiveTimeoutWindow before the newest one.
+type latencyWindow struct {
+	buckets map[int64]*histogram
+	newest  int64
+}
+
+func (w *latencyWindow) add(at time.Time, d time.Duration) {
+	k := at.Unix()
+	h, ok := w.buckets[k]
+	if !ok {
+		if k <= w.newest-latencyWindowSeconds {
+			return
+		}
+		// Old buckets are dropped once per second
+		if k > w.newest {
+			w.newest = k
+			for b := range w.buckets {
+				if b <= k-latencyWindowSeconds {
+					delete(w.buckets, b)
+				}
+			}
+		}
+		h = newHistogram()
+		w.buckets[k] = h
+	}
+	h.add(d)
+}
+
+// window merges the latency window of the step into the buckets, the step has none if it has no adaptive-timeout.
+func (a *aggregator) window(id uint16, into map[int64]*histogram) {
+	st, ok := a.steps[id]
+	if !ok || st.adaptive == nil {
+		return
+	}
+	for k, h := range st.adaptive.window.buckets {
+		m, ok := into[k]
+		if !ok {
+			m = newHistogram()
+			into[k] = m
+		}
+		m.merge(h)
+	}
+}
+
+// window merges the latency windows of the step of all the workers into the buckets.
+func (p *pipeline) window(id uint16, into map[int64]*histogram) {
+	for _, sh := range p.shards {
+		sh.mu.Lock()
+		sh.agg.window(id, into)
+		sh.mu.Unlock()
+	}
+}
+
+// latencyFeed serves the rolling p99 of the steps to the engine from the aggregation of a report service, there is no
+// latency until the aggregation starts.
+type latencyFeed struct {
+	mu     sync.Mutex
+	source func(id uint16, into map[int64]*histogram)
+}
+
+func (f *latencyFeed) feedFrom(source func(id uint16, into map[int64]*histogram)) {
+	f.mu.Lock()
+	f.source = source
+	f.mu.Unlock()
+}
+
+// RollingP99 returns the p99 of the durations of the step over the last types.AdaptiveTimeoutWindow of the results
+// aggregated so far.
+func (f *latencyFeed) RollingP99(id uint16) (time.Duration, bool) {
+	f.mu.Lock()
+	source := f.source
+	f.mu.Unlock()
+	if source == nil {
+		return 0, false
+	}
+
+	buckets := make(map[int64]*histogram)
+	source(id, buckets)
+	var newest int64
+	for k := range buckets {
+		if k > newest {
+			newest = k
+		}
+	}
+	h := newHistogram()
+	for k, b := range buckets {
+		if k > newest-latencyWindowSeconds {
+			h.merge(b)
+		}
+	}
+	if h.total == 0 {
+		return 0, false
+	}
+	return h.percentile(99), true
+}
@@ -0,0 +1,140 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"sort"
+	"time"
+)
+
+// Distinct host and IP address pairs tracked for a step. The new connections to the addresses seen after that
+// are only counted, so a host resolved to a new address on each lookup doesn't grow the memory.
+const resolvedAddressCapacity = 32
+
+// ResolvedAddress is an IP address that a host of a step is resolved to during the run, listed in the report.
+type ResolvedAddress struct {
+	Host        string `json:"host" anonymize:"host"`
+	IP          string `json:"ip" anonymize:"host"`
+	Connections int64  `json:"connections"`
+	Requests    int64  `json:"requests"`
+
+	// Avg. duration of the successful requests sent to the address, only set if the host is resolved to more than
+	// one address, so a slow instance behind a round-robin DNS is visible
+	AvgDuration float32 `json:"avg_duration,omitempty"`
+}
+
+type resolvedAddr struct {
+	host string
+	ip   string
+}
+
+type addressStats struct {
+	conns        int64
+	requests     int64
+	successCount int64
+	durationSum  time.Duration
+}
+
+// addressTracker counts the connections and the requests of the IP addresses of the hosts of a step.
+type addressTracker struct {
+	addrs map[resolvedAddr]*addressStats
+
+	// New connections to the addresses over the capacity
+	overflow int64
+}
+
+func newAddressTracker() *addressTracker {
+	return &addressTracker{addrs: make(map[resolvedAddr]*addressStats)}
+}
+
+// add counts a request sent to the address, over a new connection unless reused is set.
+func (t *addressTracker) add(host, ip string, reused bool, d time.Duration, failed bool) {
+	a := resolvedAddr{host: host, ip: ip}
+	st, ok := t.addrs[a]
+	if !ok {
+		if len(t.addrs) >= resolvedAddressCapacity {
+			if !reused {
+				t.overflow++
+			}
+			return
+		}
+		st = &addressStats{}
+		t.addrs[a] = st
+	}
+
+	st.requests++
+	if !reused {
+		st.conns++
+	}
+	if !failed {
+		st.successCount++
+		st.durationSum += d
+	}
+}
+
+// merge adds the addresses tracked by o into t.
+func (t *addressTracker) merge(o *addressTracker) {
+	t.overflow += o.overflow
+	for a, ost := range o.addrs {
+		st, ok := t.addrs[a]
+		if !ok {
+			if len(t.addrs) >= resolvedAddressCapacity {
+				t.overflow += ost.conns
+				continue
+			}
+			st = &addressStats{}
+			t.addrs[a] = st
+		}
+		st.conns += ost.conns
+		st.requests += ost.requests
+		st.successCount += ost.successCount
+		st.durationSum += ost.durationSum
+	}
+}
+
+// summary returns the addresses ordered by their hosts and their connection counts, and the overflow count.
+func (t *addressTracker) summary() (addrs []ResolvedAddress, overflow int64) {
+	if t == nil {
+		return
+	}
+
+	ips := make(map[string]int)
+	for a := range t.addrs {
+		ips[a.host]++
+	}
+	for a, st := range t.addrs {
+		r := ResolvedAddress{Host: a.host, IP: a.ip, Connections: st.conns, Requests: st.requests}
+		if ips[a.host] > 1 && st.successCount > 0 {
+			r.AvgDuration = float32(st.durationSum.Seconds() / float64(st.successCount))
+		}
+		addrs = append(addrs, r)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		if addrs[i].Host != addrs[j].Host {
+			return addrs[i].Host < addrs[j].Host
+		}
+		if addrs[i].Connections != addrs[j].Connections {
+			return addrs[i].Connections > addrs[j].Connections
+		}
+		return addrs[i].IP < addrs[j].IP
+	})
+	return addrs, t.overflow
+}
@@ -0,0 +1,122 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestAddressTracker(t *testing.T) {
+	tr := newAddressTracker()
+	tr.add("api.test.com", "10.0.0.1", false, time.Second, false)
+	tr.add("api.test.com", "10.0.0.1", true, 3*time.Second, false)
+	tr.add("api.test.com", "10.0.0.2", false, 4*time.Second, false)
+	tr.add("api.test.com", "10.0.0.2", true, time.Second, true)
+	tr.add("cdn.test.com", "10.0.1.1", false, time.Second, false)
+
+	addrs, overflow := tr.summary()
+	expected := []ResolvedAddress{
+		{Host: "api.test.com", IP: "10.0.0.1", Connections: 1, Requests: 2, AvgDuration: 2},
+		{Host: "api.test.com", IP: "10.0.0.2", Connections: 1, Requests: 2, AvgDuration: 4},
+		{Host: "cdn.test.com", IP: "10.0.1.1", Connections: 1, Requests: 1},
+	}
+	if !reflect.DeepEqual(addrs, expected) || overflow != 0 {
+		t.Errorf("Resolved addresses Expected %v, Found %v %d", expected, addrs, overflow)
+	}
+}
+
+func TestAddressTrackerOverflow(t *testing.T) {
+	tr := newAddressTracker()
+	for i := 0; i < resolvedAddressCapacity+10; i++ {
+		tr.add("api.test.com", fmt.Sprintf("10.0.0.%d", i), false, time.Second, false)
+	}
+	// Tracked addresses are still counted after the capacity is reached
+	tr.add("api.test.com", "10.0.0.0", false, time.Second, false)
+	// Reused connections of the untracked addresses are not new connections
+	tr.add("api.test.com", "10.0.1.0", true, time.Second, false)
+
+	addrs, overflow := tr.summary()
+	if len(addrs) != resolvedAddressCapacity {
+		t.Fatalf("Resolved addresses should be bounded by %d, Found %d", resolvedAddressCapacity, len(addrs))
+	}
+	if overflow != 10 {
+		t.Errorf("Overflow Expected %d, Found %d", 10, overflow)
+	}
+	if addrs[0].IP != "10.0.0.0" || addrs[0].Connections != 2 {
+		t.Errorf("Most connected address Expected %s, Found %+v", "10.0.0.0", addrs[0])
+	}
+}
+
+func TestAddressTrackerMerge(t *testing.T) {
+	a, b := newAddressTracker(), newAddressTracker()
+	for i := 0; i < resolvedAddressCapacity; i++ {
+		a.add("api.test.com", fmt.Sprintf("10.0.0.%d", i), false, time.Second, false)
+	}
+	b.add("api.test.com", "10.0.0.0", false, 3*time.Second, false)
+	b.add("api.test.com", "10.0.1.0", false, time.Second, false)
+	b.add("api.test.com", "10.0.1.0", false, time.Second, false)
+	b.add("api.test.com", "10.0.1.1", false, time.Second, false)
+
+	a.merge(b)
+	addrs, overflow := a.summary()
+	if len(addrs) != resolvedAddressCapacity || overflow != 3 {
+		t.Fatalf("Resolved addresses Expected %d with %d overflow, Found %d with %d overflow",
+			resolvedAddressCapacity, 3, len(addrs), overflow)
+	}
+	expected := ResolvedAddress{Host: "api.test.com", IP: "10.0.0.0", Connections: 2, Requests: 2, AvgDuration: 2}
+	if addrs[0] != expected {
+		t.Errorf("Merged address Expected %+v, Found %+v", expected, addrs[0])
+	}
+}
+
+func TestAggregateResolvedAddresses(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	stepResult := func(ip string, reused bool) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, Duration: time.Second, Custom: map[string]interface{}{
+			"connReused": reused, "resolvedHost": "api.test.com", "resolvedIP": ip,
+		}}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+
+	agg.add(stepResult("10.0.0.1", false))
+	agg.add(stepResult("10.0.0.1", true))
+	other.add(stepResult("10.0.0.2", false))
+	other.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 2, Duration: time.Second}}})
+	agg.merge(other)
+	result := agg.result()
+
+	expected := []ResolvedAddress{
+		{Host: "api.test.com", IP: "10.0.0.1", Connections: 1, Requests: 2, AvgDuration: 1},
+		{Host: "api.test.com", IP: "10.0.0.2", Connections: 1, Requests: 1, AvgDuration: 1},
+	}
+	if s := result.StepResults[1]; !reflect.DeepEqual(s.ResolvedAddresses, expected) {
+		t.Errorf("Resolved addresses Expected %v, Found %v", expected, s.ResolvedAddresses)
+	}
+	if s := result.StepResults[2]; s.ResolvedAddresses != nil {
+		t.Errorf("Steps without the resolved addresses should not list them, Found %v", s.ResolvedAddresses)
+	}
+}
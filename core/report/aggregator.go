@@ -21,64 +21,1042 @@
 package report
 
 import (
+	"sort"
 	"strings"
 	"time"
 
+	"go.ddosify.com/ddosify/core/criteria"
 	"go.ddosify.com/ddosify/core/types"
 )
 
-func aggregate(result *Result, scr *types.ScenarioResult) {
-	var scenarioDuration float32
+const (
+	// Distinct error reasons kept per step. After the limit, new reasons are counted under OverflowErrorReason,
+	// so long runs against a target returning unique error messages don't grow the memory unboundedly.
+	maxErrorReasons     = 100
+	OverflowErrorReason = "other errors"
+)
+
+// ClientWaitThreshold is the p95 of the client wait of a step over which the step is reported as generator-bound,
+// the requests waited in the client for a connection and the durations may not be the durations of the target.
+var ClientWaitThreshold = 100 * time.Millisecond
+
+func newResult() *Result {
+	return &Result{StepResults: make(map[uint16]*ScenarioStepResultSummary)}
+}
+
+// aggregator accumulates the scenario results. Durations and counts are kept as exact sums instead of running
+// averages, so partial aggregators of the parallel pipeline merge into the same result regardless of
+// how the results are distributed between them.
+type aggregator struct {
+	successCount int64
+	failedCount  int64
+
+	// Total scenario duration of the successful iterations
+	durationSum time.Duration
+
+	// Iterations completed during the drain phase of a gracefully stopped test, also counted above
+	drainSuccessCount int64
+	drainFailedCount  int64
+
+	// Iterations without a failure whose step results are quarantined, not counted above
+	anomalousCount int64
+
+	// First and last request times of the test, tracked if Observations is set
+	start time.Time
+	end   time.Time
+
+	// Leaf certificates of the TLS hosts, created by the first one
+	certificates certificateTracker
+
+	// Sampled step durations of the iterations, created by the first one if Correlations is set
+	durations *durationSampler
+
+	// Wall times of the iterations and the times of their steps, created by the first one with a wall time
+	iterations *iterationTimeTracker
+
+	steps map[uint16]*stepAggregator
+
+	// Sketch of the duration percentiles of the steps, the aggregators are merged only with the ones of the same sketch
+	sketch types.PercentileSketch
+}
+
+type stepAggregator struct {
+	name         string
+	successCount int64
+	failedCount  int64
+
+	statusCodes map[int]int
+	errors      map[string]int
+
+	// Earliest failed requests of the error reasons
+	samples failureSamples
+
+	// Tracked if Observations is set
+	seen firstSeen
+
+	// Sums of the successful requests. Averages are calculated over the success count.
+	durationSums map[string]time.Duration
+	countSums    map[string]int64
+
+	// Durations of the successful requests for the percentiles
+	durations percentileSketch
+	timeline  timeline
+
+	// Client waits of all the requests that got a connection, created by the first one
+	clientWaits *histogram
+
+	rateLimitedCount int64
+	retryAfterCount  int64
+	retryAfterSum    time.Duration
+
+	// Sleeps resolved from the captured values that are out of range
+	sleepClampedCount int64
+
+	// Requests with the "Expect: 100-continue" header that got no interim response before the timeout
+	continueTimeoutCount int64
+
+	// Responses whose body is cut by the server, with the body bytes and the TTFB before the cut
+	truncatedCount int64
+	truncatedBytes int64
+	truncatedTTFB  time.Duration
+
+	// Requests retried on a fresh connection after a stale reused one, with the time lost on the stale attempts
+	churnRetryCount int64
+	churnRetryTime  time.Duration
+
+	// Responses whose body is over the max_body_size of the step, the rest of the body is not read
+	bodyTooLargeCount int64
+
+	// Bytes sent and received over the connections, including the failed requests
+	bytesSent     int64
+	bytesReceived int64
+
+	// Responses decompressed by the requester, with their body bytes on the wire and after the decompression
+	compressedCount   int64
+	compressedBytes   int64
+	decompressedBytes int64
+
+	// Requests sent over a new connection and over an idle connection of a previous request
+	newConns    int64
+	reusedConns int64
+
+	// Requests by their position on their connections and the connections closed by the server, created by the
+	// first request over a counted connection
+	conns *connTracker
+
+	// TLS handshakes of the new connections, created by the first one
+	tls *tlsTracker
+
+	// Leak scans of the sampled response bodies, created by the first one
+	leaks *leakTracker
+
+	// Hedges of the requests of the step, created by the first request of a step with a hedge
+	hedges *hedgeTracker
+
+	// Conditional requests of the step, created by the first request of a step with conditional_requests
+	conditional *conditionalTracker
+
+	// Duplicated requests of the step, created by the first duplicate of a step with a duplicate_rate
+	duplicates *duplicateTracker
+
+	// Timeouts of the step, created by the first request of a step with an adaptive-timeout
+	adaptive *adaptiveTimeoutTracker
+
+	// Redirected requests of the step, created by the first one
+	redirects *redirectTracker
+
+	// Page loads of the step, created by the first loaded page of a step with a page-load
+	pageLoads *pageLoadTracker
+
+	// Results split by the variants of the iterations in the A/B mode, created by the first result with a variant
+	variants variantTracker
+
+	targets   *targetTracker
+	addresses *addressTracker
+	endpoints map[string]*endpointAggregator
+	hosts     map[string]*endpointAggregator
+
+	// Groups of the values of the report dimensions keyed by the header names, bounded by the dimension limit
+	dimensions     map[string]map[string]*endpointAggregator
+	dimensionLimit int
+
+	// Count of the distinct hosts reported, the new hosts after it are grouped under types.OverflowHost
+	hostLimit int
+
+	// Results quarantined for their negative or over twice the timeout durations, 0 timeout bounds only the negative
+	anomalousCount int64
+	timeout        time.Duration
+}
+
+// endpointAggregator accumulates the results of a group of the requests of a step, like an url group or a host.
+type endpointAggregator struct {
+	successCount int64
+	failedCount  int64
+	durationSum  time.Duration
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{steps: make(map[uint16]*stepAggregator)}
+}
+
+// initSteps creates the step aggregators of the scenario once, instead of creating them lazily in add.
+func (a *aggregator) initSteps(steps []types.ScenarioStep) {
+	a.steps = make(map[uint16]*stepAggregator, len(steps))
+	for _, st := range steps {
+		a.steps[st.ID] = newStepAggregator(st.Name, a.sketch)
+		if limit, err := types.ParseReportDimensionLimit(st.Custom["report-dimension-limit"]); err == nil {
+			a.steps[st.ID].dimensionLimit = limit
+		}
+		if limit, err := types.ParseReportHostLimit(st.Custom["report-host-limit"]); err == nil {
+			a.steps[st.ID].hostLimit = limit
+		}
+		a.steps[st.ID].timeout = time.Duration(st.Timeout) * time.Second
+	}
+}
+
+func newStepAggregator(name string, sketch types.PercentileSketch) *stepAggregator {
+	return &stepAggregator{
+		name:         name,
+		statusCodes:  make(map[int]int),
+		errors:       make(map[string]int),
+		durationSums: make(map[string]time.Duration),
+		durations:    newPercentileSketch(sketch),
+		timeline:     make(timeline),
+
+		dimensionLimit: types.DefaultReportDimensionLimit,
+		hostLimit:      types.DefaultReportHostLimit,
+	}
+}
+
+func (a *aggregator) step(id uint16, name string) *stepAggregator {
+	st, ok := a.steps[id]
+	if !ok {
+		st = newStepAggregator(name, a.sketch)
+		a.steps[id] = st
+	}
+	return st
+}
+
+// anomalous reports whether the duration of a result of the step is out of the bounds of its timeout, like the ones
+// measured over a step of the wall clock, so it would poison the averages and the percentiles of the step.
+func (st *stepAggregator) anomalous(d time.Duration) bool {
+	return d < 0 || (st.timeout > 0 && d > 2*st.timeout)
+}
+
+func (a *aggregator) add(scr *types.ScenarioResult) {
+	var scenarioDuration time.Duration
 	errOccured := false
+	anomalous := false
+
+	// Steps of a parallel group run concurrently, the group takes as long as its slowest step
+	var group string
+	var groupDuration time.Duration
 	for _, sr := range scr.StepResults {
-		scenarioDuration += float32(sr.Duration.Seconds())
+		st := a.step(sr.StepID, sr.StepName)
+		if st.anomalous(sr.Duration) {
+			st.anomalousCount++
+			anomalous = true
+			continue
+		}
 
-		if _, ok := result.StepResults[sr.StepID]; !ok {
-			result.StepResults[sr.StepID] = &ScenarioStepResultSummary{
-				Name:           sr.StepName,
-				StatusCodeDist: make(map[int]int, 0),
-				ErrorDist:      make(map[string]int),
-				Durations:      map[string]float32{},
+		if g, _ := sr.Custom["parallelGroup"].(string); g != "" && g == group {
+			if sr.Duration > groupDuration {
+				scenarioDuration += sr.Duration - groupDuration
+				groupDuration = sr.Duration
 			}
+		} else {
+			scenarioDuration += sr.Duration
+			group, groupDuration = g, sr.Duration
 		}
-		stepResult := result.StepResults[sr.StepID]
 
-		if sr.Err.Type != "" {
-			errOccured = true
-			stepResult.FailedCount++
-			stepResult.ErrorDist[sr.Err.Reason]++
-		} else {
-			stepResult.StatusCodeDist[sr.StatusCode]++
-			stepResult.SuccessCount++
+		failed := sr.Err.Type != ""
+		if Observations {
+			a.extendSpan(sr.RequestTime)
+			st.seen.addRequest(sr.RequestTime)
+		}
 
-			totalDur := float32(stepResult.SuccessCount-1)*stepResult.Durations["duration"] + float32(sr.Duration.Seconds())
-			stepResult.Durations["duration"] = totalDur / float32(stepResult.SuccessCount)
-			for k, v := range sr.Custom {
-				if strings.Contains(k, "Duration") {
-					totalDur := float32(stepResult.SuccessCount-1)*stepResult.Durations[k] + float32(v.(time.Duration).Seconds())
-					stepResult.Durations[k] = float32(totalDur / float32(stepResult.SuccessCount))
-				}
+		// Custom metrics are read in one pass over the map, a lookup of each metric costs more at the high RPS
+		conns := false
+		for k, v := range sr.Custom {
+			if !failed {
+				st.addCustomSum(k, v)
+			}
+			conns = a.addCustom(st, sr, k, v, failed) || conns
+		}
+		if conns {
+			if st.conns == nil {
+				st.conns = newConnTracker()
+			}
+			st.conns.add(sr)
+		}
+		if sr.Variant != "" {
+			if st.variants == nil {
+				st.variants = make(variantTracker)
 			}
+			st.variants.add(sr)
+		}
+		if sr.Err.Type == types.ErrorTruncated {
+			st.truncatedCount++
+		}
+
+		// Status code distribution has the raw codes of the responses failed by the success_status of the step too
+		if sr.Err.Type == types.ErrorStatus {
+			st.statusCodes[sr.StatusCode]++
 		}
 
+		if failed {
+			errOccured = true
+			st.failedCount++
+			reason := st.addErrors(sr.Err.Reason, 1)
+			st.samples.add(reason, newFailureSample(sr))
+			if Observations {
+				st.seen.addError(reason, sr.RequestTime)
+			}
+			continue
+		}
+
+		st.statusCodes[sr.StatusCode]++
+		if Observations {
+			st.seen.addCode(sr.StatusCode, sr.RequestTime)
+		}
+		st.successCount++
+		st.durationSums["duration"] += sr.Duration
+		st.durations.add(sr.Duration)
+		st.timeline.add(sr.RequestTime, sr.Duration)
+	}
+
+	if scr.Duration > 0 && !anomalous {
+		if a.iterations == nil {
+			a.iterations = newIterationTimeTracker()
+		}
+		a.iterations.add(scr, scenarioDuration)
+	}
+
+	// Scenario duration of the iteration is not known without its quarantined results
+	if anomalous && !errOccured {
+		a.anomalousCount++
+		return
 	}
 
 	// Don't change avg duration if there is a error
 	if !errOccured {
-		totalDuration := float32(result.SuccessCount)*result.AvgDuration + scenarioDuration
-		result.SuccessCount++
-		result.AvgDuration = totalDuration / float32(result.SuccessCount)
-	} else if errOccured {
-		result.FailedCount++
+		a.successCount++
+		a.durationSum += scenarioDuration
+	} else {
+		a.failedCount++
+	}
+	if Correlations && !anomalous {
+		if a.durations == nil {
+			a.durations = newDurationSampler()
+		}
+		a.durations.add(scr.StepResults)
+	}
+	if drained, _ := scr.Others["drained"].(bool); drained && !errOccured {
+		a.drainSuccessCount++
+	} else if drained {
+		a.drainFailedCount++
+	}
+}
+
+// addCustomSum adds the durations and the counts among the custom metrics of a successful result to the sums of
+// the step, by the Duration and the Count suffixes of their keys.
+func (st *stepAggregator) addCustomSum(k string, v interface{}) {
+	if d, ok := v.(time.Duration); ok && strings.Contains(k, "Duration") {
+		st.durationSums[k] += d
+	} else if c, ok := v.(int64); ok && strings.HasSuffix(k, "Count") {
+		if st.countSums == nil {
+			st.countSums = make(map[string]int64)
+		}
+		st.countSums[k] += c
+	}
+}
+
+// addCustom adds a custom metric of the result to the trackers of the step. The connection metrics are added once
+// per result by the caller, it reports whether the metric is one of them.
+func (a *aggregator) addCustom(st *stepAggregator, sr *types.ScenarioStepResult, k string, v interface{},
+	failed bool) (conns bool) {
+	switch k {
+	case "targetURL":
+		if target, ok := v.(string); ok {
+			if st.targets == nil {
+				st.targets = newTargetTracker()
+			}
+			st.targets.add(target, sr.Duration, failed)
+		}
+	case "endpoint":
+		if endpoint, ok := v.(string); ok {
+			groupOf(&st.endpoints, endpoint).add(sr.Duration, failed)
+		}
+	case "host":
+		if host, ok := v.(string); ok {
+			st.host(host).add(sr.Duration, failed)
+		}
+	case "dimensions":
+		if dimensions, ok := v.(map[string]string); ok {
+			for name, value := range dimensions {
+				st.dimension(name, value).add(sr.Duration, failed)
+			}
+		}
+	case "bytesSent":
+		if n, ok := v.(int64); ok {
+			st.bytesSent += n
+		}
+	case "bytesReceived":
+		if n, ok := v.(int64); ok {
+			st.bytesReceived += n
+		}
+	case "compressedBytes":
+		if n, ok := v.(int64); ok {
+			decompressed, _ := sr.Custom["decompressedBytes"].(int64)
+			st.compressedCount++
+			st.compressedBytes += n
+			st.decompressedBytes += decompressed
+		}
+	case "clientWaitDuration":
+		if d, ok := v.(time.Duration); ok {
+			if st.clientWaits == nil {
+				st.clientWaits = newHistogram()
+			}
+			st.clientWaits.add(d)
+		}
+	case "connReused":
+		if reused, ok := v.(bool); ok && reused {
+			st.reusedConns++
+		} else if ok {
+			st.newConns++
+		}
+	case "connRequest":
+		return true
+	case "serverCloses":
+		return v != nil
+	case "tlsCertificate":
+		if cert, ok := v.(types.Certificate); ok {
+			if a.certificates == nil {
+				a.certificates = make(certificateTracker)
+			}
+			a.certificates.add(cert, sr.RequestTime)
+		}
+	case "tlsResumed":
+		if st.tls == nil {
+			st.tls = newTLSTracker()
+		}
+		st.tls.add(sr)
+	case "unhedgedTime":
+		if unhedged, ok := v.(time.Duration); ok {
+			if st.hedges == nil {
+				st.hedges = newHedgeTracker()
+			}
+			st.hedges.add(sr, unhedged)
+		}
+	case "conditional":
+		if conditional, ok := v.(bool); ok {
+			if st.conditional == nil {
+				st.conditional = newConditionalTracker()
+			}
+			st.conditional.add(sr, conditional)
+		}
+	case "duplicate":
+		if d, ok := v.(types.DuplicateResult); ok {
+			if st.duplicates == nil {
+				st.duplicates = newDuplicateTracker()
+			}
+			st.duplicates.add(sr, d)
+		}
+	case "adaptiveTimeout":
+		if timeout, ok := v.(time.Duration); ok {
+			if st.adaptive == nil {
+				st.adaptive = newAdaptiveTimeoutTracker()
+			}
+			st.adaptive.add(sr, timeout)
+		}
+	case "redirects":
+		if chain, ok := v.([]types.RedirectHop); ok && len(chain) > 0 {
+			if st.redirects == nil {
+				st.redirects = newRedirectTracker()
+			}
+			st.redirects.add(sr, chain)
+		}
+	case "pageLoadTime":
+		if loadTime, ok := v.(time.Duration); ok {
+			if st.pageLoads == nil {
+				st.pageLoads = newPageLoadTracker()
+			}
+			st.pageLoads.add(sr, loadTime)
+		}
+	case "leakScan":
+		if scan, ok := v.(types.LeakScanResult); ok {
+			if st.leaks == nil {
+				st.leaks = newLeakTracker()
+			}
+			st.leaks.add(sr, scan)
+		}
+	case "resolvedIP":
+		if ip, ok := v.(string); ok {
+			if st.addresses == nil {
+				st.addresses = newAddressTracker()
+			}
+			reused, _ := sr.Custom["connReused"].(bool)
+			st.addresses.add(sr.Custom["resolvedHost"].(string), ip, reused, sr.Duration, failed)
+		}
+	case "truncatedBytes":
+		if n, ok := v.(int64); ok && sr.Err.Type == types.ErrorTruncated {
+			st.truncatedBytes += n
+		}
+	case "serverProcessDuration":
+		if d, ok := v.(time.Duration); ok && sr.Err.Type == types.ErrorTruncated {
+			st.truncatedTTFB += d
+		}
+	case "continueTimedOut":
+		st.continueTimeoutCount++
+	case "churnRetryTime":
+		if d, ok := v.(time.Duration); ok {
+			st.churnRetryCount++
+			st.churnRetryTime += d
+		}
+	case "bodyTooLarge":
+		st.bodyTooLargeCount++
+	case "rateLimited":
+		// Rate limits and the clamped sleeps are counted over the successful results
+		if !failed {
+			st.rateLimitedCount++
+			if backoff, ok := sr.Custom["retryAfter"].(time.Duration); ok {
+				st.retryAfterCount++
+				st.retryAfterSum += backoff
+			}
+		}
+	case "sleepClamped":
+		if !failed {
+			st.sleepClampedCount++
+		}
+	}
+	return false
+}
+
+// extendSpan extends the time span of the test with the request time.
+func (a *aggregator) extendSpan(t time.Time) {
+	if a.start.IsZero() || t.Before(a.start) {
+		a.start = t
+	}
+	if t.After(a.end) {
+		a.end = t
+	}
+}
+
+// transferredBytes returns the bytes sent and received over the connections by the request.
+func transferredBytes(sr *types.ScenarioStepResult) int64 {
+	sent, _ := sr.Custom["bytesSent"].(int64)
+	received, _ := sr.Custom["bytesReceived"].(int64)
+	return sent + received
+}
+
+// addErrors counts the error reason and returns the reason it is counted under. After maxErrorReasons distinct
+// reasons, the new ones are counted under OverflowErrorReason.
+func (st *stepAggregator) addErrors(reason string, count int) string {
+	if _, ok := st.errors[reason]; !ok && len(st.errors) >= maxErrorReasons-1 {
+		reason = OverflowErrorReason
+	}
+	st.errors[reason] += count
+	return reason
+}
+
+// dimension returns the group of the value of the named dimension. After dimensionLimit distinct values,
+// the new ones are grouped under types.OverflowDimensionValue.
+func (st *stepAggregator) dimension(name, value string) *endpointAggregator {
+	if st.dimensions == nil {
+		st.dimensions = make(map[string]map[string]*endpointAggregator)
+	}
+	values := st.dimensions[name]
+	if _, ok := values[value]; !ok && len(values) >= st.dimensionLimit {
+		value = types.OverflowDimensionValue
+	}
+	e := groupOf(&values, value)
+	st.dimensions[name] = values
+	return e
+}
+
+// host returns the group of the host. After hostLimit distinct hosts, the new ones are grouped under
+// types.OverflowHost, so a template rendering unbounded hostnames doesn't grow the report.
+func (st *stepAggregator) host(host string) *endpointAggregator {
+	if _, ok := st.hosts[host]; !ok && len(st.hosts) >= st.hostLimit {
+		host = types.OverflowHost
+	}
+	return groupOf(&st.hosts, host)
+}
+
+// sortedValues returns the values of a dimension with the overflow value as the last, so the values are merged
+// in the same order and the same ones get their own groups within the limit.
+func sortedValues(values map[string]*endpointAggregator) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == types.OverflowDimensionValue) != (keys[j] == types.OverflowDimensionValue) {
+			return keys[j] == types.OverflowDimensionValue
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// groupOf returns the aggregator of the named group, the groups map is created on the first use.
+func groupOf(groups *map[string]*endpointAggregator, name string) *endpointAggregator {
+	if *groups == nil {
+		*groups = make(map[string]*endpointAggregator)
+	}
+	e, ok := (*groups)[name]
+	if !ok {
+		e = &endpointAggregator{}
+		(*groups)[name] = e
 	}
+	return e
+}
+
+func (e *endpointAggregator) add(d time.Duration, failed bool) {
+	if failed {
+		e.failedCount++
+	} else {
+		e.successCount++
+		e.durationSum += d
+	}
+}
+
+// sortedErrors returns the reasons of the error distribution in descending count order, so the merge keeps the
+// most frequent reasons when they exceed maxErrorReasons and gives the same result on every snapshot.
+// OverflowErrorReason is the last.
+func sortedErrors(errors map[string]int) []string {
+	reasons := make([]string, 0, len(errors))
+	for r := range errors {
+		reasons = append(reasons, r)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if (reasons[i] == OverflowErrorReason) != (reasons[j] == OverflowErrorReason) {
+			return reasons[j] == OverflowErrorReason
+		}
+		if errors[reasons[i]] != errors[reasons[j]] {
+			return errors[reasons[i]] > errors[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	return reasons
+}
+
+func mergeGroups(groups *map[string]*endpointAggregator, o map[string]*endpointAggregator) {
+	for name, oe := range o {
+		e := groupOf(groups, name)
+		e.successCount += oe.successCount
+		e.failedCount += oe.failedCount
+		e.durationSum += oe.durationSum
+	}
+}
+
+func groupSummaries(groups map[string]*endpointAggregator) map[string]*EndpointSummary {
+	if len(groups) == 0 {
+		return nil
+	}
+	summaries := make(map[string]*EndpointSummary, len(groups))
+	for name, e := range groups {
+		summaries[name] = &EndpointSummary{
+			SuccessCount: e.successCount,
+			FailedCount:  e.failedCount,
+			AvgDuration:  avgSeconds(e.durationSum, e.successCount),
+		}
+	}
+	return summaries
+}
+
+// merge adds the results of the other aggregator into a.
+func (a *aggregator) merge(o *aggregator) {
+	a.successCount += o.successCount
+	a.failedCount += o.failedCount
+	a.durationSum += o.durationSum
+	a.drainSuccessCount += o.drainSuccessCount
+	a.drainFailedCount += o.drainFailedCount
+	a.anomalousCount += o.anomalousCount
+	if !o.start.IsZero() {
+		a.extendSpan(o.start)
+		a.extendSpan(o.end)
+	}
+	if len(o.certificates) > 0 {
+		if a.certificates == nil {
+			a.certificates = make(certificateTracker)
+		}
+		a.certificates.merge(o.certificates)
+	}
+	if o.durations != nil {
+		if a.durations == nil {
+			a.durations = newDurationSampler()
+		}
+		a.durations.merge(o.durations)
+	}
+	if o.iterations != nil {
+		if a.iterations == nil {
+			a.iterations = newIterationTimeTracker()
+		}
+		a.iterations.merge(o.iterations)
+	}
+
+	for id, os := range o.steps {
+		st := a.step(id, os.name)
+		if st.name == "" {
+			st.name = os.name
+		}
+		st.successCount += os.successCount
+		st.failedCount += os.failedCount
+		for c, n := range os.statusCodes {
+			st.statusCodes[c] += n
+		}
+		for _, r := range sortedErrors(os.errors) {
+			reason := st.addErrors(r, os.errors[r])
+			st.samples.merge(reason, os.samples[r])
+			if t, ok := os.seen.errors[r]; ok {
+				st.seen.addError(reason, t)
+			}
+		}
+		st.seen.merge(os.seen)
+		for k, d := range os.durationSums {
+			st.durationSums[k] += d
+		}
+		st.durations.merge(os.durations)
+		if os.clientWaits != nil {
+			if st.clientWaits == nil {
+				st.clientWaits = newHistogram()
+			}
+			st.clientWaits.merge(os.clientWaits)
+		}
+		st.timeline.merge(os.timeline)
+		for k, c := range os.countSums {
+			if st.countSums == nil {
+				st.countSums = make(map[string]int64)
+			}
+			st.countSums[k] += c
+		}
+		st.rateLimitedCount += os.rateLimitedCount
+		st.retryAfterCount += os.retryAfterCount
+		st.retryAfterSum += os.retryAfterSum
+		st.sleepClampedCount += os.sleepClampedCount
+		st.continueTimeoutCount += os.continueTimeoutCount
+		st.truncatedCount += os.truncatedCount
+		st.truncatedBytes += os.truncatedBytes
+		st.truncatedTTFB += os.truncatedTTFB
+		st.churnRetryCount += os.churnRetryCount
+		st.churnRetryTime += os.churnRetryTime
+		st.bodyTooLargeCount += os.bodyTooLargeCount
+		st.anomalousCount += os.anomalousCount
+		st.bytesSent += os.bytesSent
+		st.bytesReceived += os.bytesReceived
+		st.compressedCount += os.compressedCount
+		st.compressedBytes += os.compressedBytes
+		st.decompressedBytes += os.decompressedBytes
+		st.newConns += os.newConns
+		st.reusedConns += os.reusedConns
+		if os.conns != nil {
+			if st.conns == nil {
+				st.conns = newConnTracker()
+			}
+			st.conns.merge(os.conns)
+		}
+		if os.tls != nil {
+			if st.tls == nil {
+				st.tls = newTLSTracker()
+			}
+			st.tls.merge(os.tls)
+		}
+		if os.hedges != nil {
+			if st.hedges == nil {
+				st.hedges = newHedgeTracker()
+			}
+			st.hedges.merge(os.hedges)
+		}
+		if os.conditional != nil {
+			if st.conditional == nil {
+				st.conditional = newConditionalTracker()
+			}
+			st.conditional.merge(os.conditional)
+		}
+		if os.duplicates != nil {
+			if st.duplicates == nil {
+				st.duplicates = newDuplicateTracker()
+			}
+			st.duplicates.merge(os.duplicates)
+		}
+		if os.adaptive != nil {
+			if st.adaptive == nil {
+				st.adaptive = newAdaptiveTimeoutTracker()
+			}
+			st.adaptive.merge(os.adaptive)
+		}
+		if os.redirects != nil {
+			if st.redirects == nil {
+				st.redirects = newRedirectTracker()
+			}
+			st.redirects.merge(os.redirects)
+		}
+		if os.pageLoads != nil {
+			if st.pageLoads == nil {
+				st.pageLoads = newPageLoadTracker()
+			}
+			st.pageLoads.merge(os.pageLoads)
+		}
+		if os.variants != nil {
+			if st.variants == nil {
+				st.variants = make(variantTracker)
+			}
+			st.variants.merge(os.variants)
+		}
+		if os.leaks != nil {
+			if st.leaks == nil {
+				st.leaks = newLeakTracker()
+			}
+			st.leaks.merge(os.leaks)
+		}
+
+		if os.targets != nil {
+			if st.targets == nil {
+				st.targets = newTargetTracker()
+			}
+			st.targets.merge(os.targets)
+		}
+		if os.addresses != nil {
+			if st.addresses == nil {
+				st.addresses = newAddressTracker()
+			}
+			st.addresses.merge(os.addresses)
+		}
+		mergeGroups(&st.endpoints, os.endpoints)
+		for _, host := range sortedValues(os.hosts) {
+			oh := os.hosts[host]
+			h := st.host(host)
+			h.successCount += oh.successCount
+			h.failedCount += oh.failedCount
+			h.durationSum += oh.durationSum
+		}
+		for name, values := range os.dimensions {
+			for _, value := range sortedValues(values) {
+				oe := values[value]
+				e := st.dimension(name, value)
+				e.successCount += oe.successCount
+				e.failedCount += oe.failedCount
+				e.durationSum += oe.durationSum
+			}
+		}
+	}
+}
+
+// result returns the report of the aggregated results.
+func (a *aggregator) result() *Result {
+	r := &Result{
+		SuccessCount: a.successCount,
+		FailedCount:  a.failedCount,
+		AvgDuration:  avgSeconds(a.durationSum, a.successCount),
+		StepResults:  make(map[uint16]*ScenarioStepResultSummary, len(a.steps)),
+		Certificates: a.certificates.summary(),
+
+		AnomalousCount: a.anomalousCount,
+
+		drainSuccessCount: a.drainSuccessCount,
+		drainFailedCount:  a.drainFailedCount,
+	}
+
+	for id, st := range a.steps {
+		s := &ScenarioStepResultSummary{
+			Name:             st.name,
+			StatusCodeDist:   make(map[int]int, len(st.statusCodes)),
+			ErrorDist:        make(map[string]int, len(st.errors)),
+			Durations:        make(map[string]float32, len(st.durationSums)),
+			SuccessCount:     st.successCount,
+			FailedCount:      st.failedCount,
+			RateLimitedCount: st.rateLimitedCount,
+			AvgRetryAfter:    avgSeconds(st.retryAfterSum, st.retryAfterCount),
+
+			SleepClampedCount:    st.sleepClampedCount,
+			ContinueTimeoutCount: st.continueTimeoutCount,
+			TruncatedCount:       st.truncatedCount,
+			AvgTruncatedTTFB:     avgSeconds(st.truncatedTTFB, st.truncatedCount),
+			ChurnRetryCount:      st.churnRetryCount,
+			AvgChurnRetryTime:    avgSeconds(st.churnRetryTime, st.churnRetryCount),
+			BodyTooLargeCount:    st.bodyTooLargeCount,
+			AnomalousCount:       st.anomalousCount,
+			BytesSent:            st.bytesSent,
+			BytesReceived:        st.bytesReceived,
+			CompressedCount:      st.compressedCount,
+			CompressedBytes:      st.compressedBytes,
+			DecompressedBytes:    st.decompressedBytes,
+			NewConnections:       st.newConns,
+			ReusedConnections:    st.reusedConns,
+		}
+		for c, n := range st.statusCodes {
+			s.StatusCodeDist[c] = n
+		}
+		for e, n := range st.errors {
+			s.ErrorDist[e] = n
+		}
+		for k, d := range st.durationSums {
+			s.Durations[k] = avgSeconds(d, st.successCount)
+		}
+		if st.durations.count() > 0 {
+			s.Percentiles = percentileSummary(st.durations, a.sketch)
+			s.percentiles = s.Percentiles.byRank()
+		}
+		if st.clientWaits != nil {
+			p95 := st.clientWaits.percentile(95)
+			s.ClientWaitP95 = float32(p95.Seconds())
+			s.GeneratorBound = p95 > ClientWaitThreshold
+		}
+		if len(st.timeline) > 0 {
+			s.Timeline = st.timeline.buckets(TimelineInterval)
+			s.timeline = st.timeline
+		}
+		if len(st.countSums) > 0 {
+			s.Counts = make(map[string]float32, len(st.countSums))
+			for k, c := range st.countSums {
+				s.Counts[k] = float32(float64(c) / float64(st.successCount))
+			}
+		}
+		s.FailureSamples = st.samples.summary()
+		if Observations {
+			s.firstSeen = st.seen.clone()
+		}
+		s.SlowestTargets, s.FailingTargets = st.targets.summary()
+		s.ResolvedAddresses, s.ResolvedAddressOverflow = st.addresses.summary()
+		s.TLS = st.tls.summary()
+		s.Connections = st.conns.summary()
+		s.LeakScan = st.leaks.summary()
+		s.Hedge = st.hedges.summary()
+		s.Conditional = st.conditional.summary()
+		s.Duplicates = st.duplicates.summary()
+		s.AdaptiveTimeout = st.adaptive.summary()
+		s.Redirects = st.redirects.summary()
+		s.PageLoad = st.pageLoads.summary()
+		s.Variants = st.variants.summary()
+		if st.truncatedCount > 0 {
+			s.AvgTruncatedBytes = float32(float64(st.truncatedBytes) / float64(st.truncatedCount))
+		}
+		s.Endpoints = groupSummaries(st.endpoints)
+		s.Hosts = groupSummaries(st.hosts)
+		if _, ok := st.hosts[types.OverflowHost]; ok {
+			s.HostLimit = st.hostLimit
+		}
+		if len(st.dimensions) > 0 {
+			s.Dimensions = make(map[string]map[string]*EndpointSummary, len(st.dimensions))
+			for name, values := range st.dimensions {
+				s.Dimensions[name] = groupSummaries(values)
+			}
+		}
+		r.StepResults[id] = s
+		r.BytesSent += st.bytesSent
+		r.BytesReceived += st.bytesReceived
+	}
+	if Observations {
+		r.start, r.end = a.start, a.end
+		r.Observations = observe(r)
+	}
+	if a.durations != nil {
+		r.Correlations = a.durations.correlate()
+	}
+	r.IterationTime = a.iterations.summary()
+	return r
+}
+
+func avgSeconds(sum time.Duration, count int64) float32 {
+	if count == 0 {
+		return 0
+	}
+	return float32(sum.Seconds() / float64(count))
 }
 
 // Total test result, all scenario iterations combined
 type Result struct {
+	// Seed of the run, set by the reports
+	Seed int64 `json:"seed,omitempty"`
+	// Set if the test is aborted without waiting the in-flight iterations
+	Partial bool `json:"partial,omitempty"`
+	// Evaluation of the success criteria of the test, set by the reports
+	Criteria *criteria.Result `json:"success_criteria,omitempty"`
+	// Set if the test is stopped by a limit before its planned end
+	Stopped *StopReason `json:"stop_reason,omitempty"`
+
 	SuccessCount int64                                 `json:"success_count"`
 	FailedCount  int64                                 `json:"fail_count"`
 	AvgDuration  float32                               `json:"avg_duration"`
 	StepResults  map[uint16]*ScenarioStepResultSummary `json:"steps"`
+
+	// Bytes sent and received over the connections by all the steps
+	BytesSent     int64 `json:"bytes_sent,omitempty"`
+	BytesReceived int64 `json:"bytes_received,omitempty"`
+
+	// Iterations without a failure that have a result quarantined by its duration, not counted as successful
+	AnomalousCount int64 `json:"anomalous_count,omitempty"`
+
+	// Latencies of the connectivity probes of the targets before the test, set by the reports
+	Preflight []PreflightProbe `json:"preflight,omitempty"`
+
+	// Load estimated before the run and the actual load, set by the reports
+	Estimate *EstimateSummary `json:"estimate,omitempty"`
+
+	// Leaf certificates of the TLS hosts from the first connection to each host, sorted by the hosts
+	Certificates []CertificateSummary `json:"certificates,omitempty"`
+
+	// Settings of the run with the secrets redacted, set by the reports
+	Config *ConfigEcho `json:"config,omitempty"`
+
+	// Teardown steps run after the test, set by the reports
+	Teardown []TeardownSummary `json:"teardown,omitempty"`
+
+	// Back-pressure of the result channel, set by the reports
+	Generator *GeneratorHealth `json:"generator_health,omitempty"`
+
+	// Drain phase of the gracefully stopped test, set by the reports
+	Drain *DrainSummary `json:"drain,omitempty"`
+
+	// Changes of the dynamic rate in the recording order, set by the reports
+	RateChanges []RateChange `json:"rate_changes,omitempty"`
+
+	// Bursts of the burst load type in the firing order and the counts of the bursts overlapped and skipped by
+	// the burst policy, set by the reports
+	Bursts           []BurstSummary `json:"bursts,omitempty"`
+	OverlappedBursts int            `json:"overlapped_bursts,omitempty"`
+	SkippedBursts    int            `json:"skipped_bursts,omitempty"`
+
+	// Completion spread of the virtual users of the vu load type, set by the reports
+	Users *UsersSummary `json:"users,omitempty"`
+
+	// Findings of the analysis of the result, set if Observations is set
+	Observations []Observation `json:"observations,omitempty"`
+
+	// Correlations of the step durations, set if Correlations is set
+	Correlations *CorrelationMatrix `json:"correlations,omitempty"`
+
+	// Wall time of the iterations and the shares of the steps in it, nil for a single step scenario
+	IterationTime *IterationTimeSummary `json:"iteration_time,omitempty"`
+
+	// Deltas of the run from the baseline of the -compare flag, set by the reports
+	Comparison *Comparison `json:"comparison,omitempty"`
+
+	// Run id, labels and build info of the run, set by the reports. Fields are at the top level of the JSON.
+	*RunMetadata
+
+	// First and last request times of the test for the observations
+	start time.Time
+	end   time.Time
+
+	// Counts of the iterations completed during the drain phase
+	drainSuccessCount int64
+	drainFailedCount  int64
+
+	// Load schedule of the test, set by the reports. nil if the test has no schedule.
+	schedule *types.LoadSchedule
+}
+
+// setSchedule annotates the timelines of the steps with the achieved and the target rates of the schedule.
+func (r *Result) setSchedule(s *types.LoadSchedule) {
+	if s == nil {
+		return
+	}
+	r.schedule = s
+	for _, st := range r.StepResults {
+		annotateSchedule(st.Timeline, TimelineInterval, s, r.start, r.end)
+	}
 }
 
 func (r *Result) successPercentage() int {
@@ -101,8 +1079,147 @@ type ScenarioStepResultSummary struct {
 	StatusCodeDist map[int]int        `json:"status_code_dist"`
 	ErrorDist      map[string]int     `json:"error_dist"`
 	Durations      map[string]float32 `json:"durations"`
+	Counts         map[string]float32 `json:"counts,omitempty"`
 	SuccessCount   int64              `json:"success_count"`
 	FailedCount    int64              `json:"fail_count"`
+
+	// Rate limited (429) response count and the average backoff advertised by Retry-After headers
+	RateLimitedCount int64   `json:"rate_limited_count,omitempty"`
+	AvgRetryAfter    float32 `json:"avg_retry_after,omitempty"`
+
+	// 95th percentile of the time the requests waited in the client for a connection. Generator-bound is set if it is
+	// over ClientWaitThreshold, the requests are queued by the saturated generator and blamed on the target.
+	ClientWaitP95  float32 `json:"client_wait_p95,omitempty"`
+	GeneratorBound bool    `json:"generator_bound,omitempty"`
+
+	// Count of the sleeps resolved from the captured values that are clamped into [0, 90s]
+	SleepClampedCount int64 `json:"sleep_clamped_count,omitempty"`
+
+	// Count of the requests with the "Expect: 100-continue" header that got no interim response before the timeout,
+	// their body is sent at the timeout
+	ContinueTimeoutCount int64 `json:"continue_timeout_count,omitempty"`
+
+	// Responses whose body is cut by the server after the headers, they are also counted as failed.
+	// Averages of the body bytes and the time to first byte of the truncated responses.
+	TruncatedCount    int64   `json:"truncated_count,omitempty"`
+	AvgTruncatedBytes float32 `json:"avg_truncated_bytes,omitempty"`
+	AvgTruncatedTTFB  float32 `json:"avg_truncated_ttfb,omitempty"`
+
+	// Requests failed on a stale reused connection and retried once on a fresh one, they are counted by the result
+	// of the retry. Average time of the stale attempts, it is not in the durations.
+	ChurnRetryCount   int64   `json:"churn_retry_count,omitempty"`
+	AvgChurnRetryTime float32 `json:"avg_churn_retry_time,omitempty"`
+
+	// Responses over the max_body_size of the step, only the checks over their body fail
+	BodyTooLargeCount int64 `json:"body_too_large_count,omitempty"`
+
+	// Results with a negative or over twice the timeout duration, like the ones measured over a step of the wall
+	// clock. They are not in the counts, the durations and the groups of the step.
+	AnomalousCount int64 `json:"anomalous_count,omitempty"`
+
+	// Bytes sent and received over the connections, including the headers and the failed requests
+	BytesSent     int64 `json:"bytes_sent,omitempty"`
+	BytesReceived int64 `json:"bytes_received,omitempty"`
+
+	// Responses decompressed by the requester, with their body bytes on the wire and after the decompression
+	CompressedCount   int64 `json:"compressed_count,omitempty"`
+	CompressedBytes   int64 `json:"compressed_bytes,omitempty"`
+	DecompressedBytes int64 `json:"decompressed_bytes,omitempty"`
+
+	// Requests sent over a new connection and over an idle connection of a previous request, the reuse depends on
+	// the connection scope of the scenario
+	NewConnections    int64 `json:"new_connections,omitempty"`
+	ReusedConnections int64 `json:"reused_connections,omitempty"`
+
+	// Requests per connection and the connections closed by the server, nil if no request got a counted connection
+	Connections *ConnectionSummary `json:"connections,omitempty"`
+
+	// TLS handshakes of the new connections, nil if the step does no TLS handshake
+	TLS *TLSSummary `json:"tls,omitempty"`
+
+	// Hedges of the requests of the step, nil if the step has no hedge
+	Hedge *HedgeSummary `json:"hedge,omitempty"`
+
+	// Conditional requests of the step and the durations of its 304 and 200 responses, nil if the step has no
+	// conditional_requests
+	Conditional *ConditionalSummary `json:"conditional,omitempty"`
+
+	// Requests of the step sent twice and the match of the responses of the pairs, nil if the step has no
+	// duplicate_rate
+	Duplicates *DuplicateSummary `json:"duplicates,omitempty"`
+
+	// Timeouts applied to the requests of the step, nil if the step has no adaptive-timeout
+	AdaptiveTimeout *AdaptiveTimeoutSummary `json:"adaptive_timeout,omitempty"`
+
+	// Redirected requests of the step and their final urls, nil if no request of the step is redirected
+	Redirects *RedirectSummary `json:"redirects,omitempty"`
+
+	// Page loads of the step and its resources, nil if the step has no page-load or loaded no page
+	PageLoad *PageLoadSummary `json:"page_load,omitempty"`
+
+	// Results of the step in the iterations of the A/B variants keyed by types.VariantA and types.VariantB, nil if
+	// the scenario has no step with ab-variants
+	Variants map[string]*VariantSummary `json:"variants,omitempty"`
+
+	// IP addresses that the hosts of the step are resolved to, and the new connections to the addresses over the
+	// tracked capacity
+	ResolvedAddresses       []ResolvedAddress `json:"resolved_addresses,omitempty"`
+	ResolvedAddressOverflow int64             `json:"resolved_address_overflow,omitempty"`
+
+	// Earliest failed requests of each error reason, keyed as the ErrorDist
+	FailureSamples map[string][]FailureSample `json:"failure_samples,omitempty"`
+
+	// Scan of the sampled response bodies of the steps with a leak-scan, nil if no response is scanned
+	LeakScan *LeakScanSummary `json:"leak_scan,omitempty"`
+
+	// Slowest and most failing targets of the steps fed by a targets file
+	SlowestTargets []TargetSummary `json:"slowest_targets,omitempty"`
+	FailingTargets []TargetSummary `json:"failing_targets,omitempty"`
+
+	// Results of the targets grouped by the url-groups rules of the step
+	Endpoints map[string]*EndpointSummary `json:"endpoints,omitempty"`
+
+	// Results of the hosts of the steps with a hosts rotation or a templated host
+	Hosts map[string]*EndpointSummary `json:"hosts,omitempty" anonymize:"host"`
+
+	// Limit of the distinct hosts, only set if the hosts after the limit are grouped under types.OverflowHost
+	HostLimit int `json:"host_limit,omitempty"`
+
+	// Results grouped by the response header values of the report dimensions, keyed by the header names
+	Dimensions map[string]map[string]*EndpointSummary `json:"dimensions,omitempty"`
+
+	// Duration percentiles of the successful requests over time, per TimelineInterval
+	Timeline []TimelineBucket `json:"timeline,omitempty"`
+
+	// Duration percentiles of the successful requests in seconds, used by the success criteria
+	percentiles map[int]float32
+
+	// Duration percentiles of the successful requests with the sketch that estimated them
+	Percentiles *PercentileSummary `json:"percentiles,omitempty"`
+
+	// Histograms of the timeline, used to coarsen it for the long runs
+	timeline timeline
+
+	// First request times of the step, its error reasons and status codes for the observations
+	firstSeen firstSeen
+}
+
+// EndpointSummary is the result of a url group or a host, the avg duration is calculated from the successful requests.
+type EndpointSummary struct {
+	SuccessCount int64   `json:"success_count"`
+	FailedCount  int64   `json:"fail_count"`
+	AvgDuration  float32 `json:"avg_duration"`
+}
+
+func (e *EndpointSummary) successPercentage() int {
+	if e.SuccessCount+e.FailedCount == 0 {
+		return 0
+	}
+	return int(float32(e.SuccessCount) / float32(e.SuccessCount+e.FailedCount) * 100)
+}
+
+func (s *ScenarioStepResultSummary) hasResults() bool {
+	return s.SuccessCount+s.FailedCount+s.AnomalousCount > 0
 }
 
 func (s *ScenarioStepResultSummary) successPercentage() int {
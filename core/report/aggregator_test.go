@@ -0,0 +1,1220 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestAggregateErrorReasonsBounded(t *testing.T) {
+	agg := newAggregator()
+	for i := 0; i < 3*maxErrorReasons; i++ {
+		agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+			StepID: 1,
+			Err:    types.RequestError{Type: types.ErrorConn, Reason: fmt.Sprintf("dial tcp 10.0.0.1:%d: refused", i)},
+		}}})
+	}
+
+	dist := agg.result().StepResults[1].ErrorDist
+	if len(dist) != maxErrorReasons {
+		t.Errorf("Distinct error reasons Expected %d, Found %d", maxErrorReasons, len(dist))
+	}
+	if dist[OverflowErrorReason] != 2*maxErrorReasons+1 {
+		t.Errorf("Overflow count Expected %d, Found %d", 2*maxErrorReasons+1, dist[OverflowErrorReason])
+	}
+
+	// Known reasons are still counted in their own buckets after the overflow
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+		StepID: 1,
+		Err:    types.RequestError{Type: types.ErrorConn, Reason: "dial tcp 10.0.0.1:0: refused"},
+	}}})
+	dist = agg.result().StepResults[1].ErrorDist
+	if dist["dial tcp 10.0.0.1:0: refused"] != 2 {
+		t.Errorf("Known reason count Expected %d, Found %d", 2, dist["dial tcp 10.0.0.1:0: refused"])
+	}
+}
+
+func TestInitSteps(t *testing.T) {
+	agg := newAggregator()
+	agg.initSteps([]types.ScenarioStep{{ID: 1, Name: "login"}, {ID: 2, Name: "search"}})
+
+	result := agg.result()
+	if len(result.StepResults) != 2 || result.StepResults[2].Name != "search" {
+		t.Fatalf("Step summaries should be created from the scenario, Found %v", result.StepResults)
+	}
+
+	step := agg.steps[1]
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StepName: "login"}}})
+	if agg.steps[1] != step || step.successCount != 1 {
+		t.Errorf("Pre-allocated step aggregator should be used by add")
+	}
+	if agg.result().StepResults[2].hasResults() {
+		t.Errorf("Step without traffic should have no results")
+	}
+}
+
+// TestAggregateSoak feeds 10M results of a flaky target to an aggregator and checks that the memory of the
+// aggregation stays bounded and the throughput doesn't regress.
+func TestAggregateSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test is skipped in short mode")
+	}
+
+	const (
+		total        = 10_000_000
+		memoryBudget = 2 << 20   // bytes
+		minRate      = 1_000_000 // results per second
+	)
+
+	// Synthetic results are created up front, so the measured memory belongs to the aggregation only.
+	pool := make([]*types.ScenarioResult, 10000)
+	for i := range pool {
+		sr := &types.ScenarioStepResult{
+			StepID:     uint16(i%2 + 1),
+			StatusCode: 200,
+			Duration:   time.Duration(i%500) * time.Millisecond,
+			Custom: map[string]interface{}{
+				"dnsDuration":  time.Millisecond,
+				"connDuration": 2 * time.Millisecond,
+				"resDuration":  3 * time.Millisecond,
+			},
+		}
+		if i%3 == 0 {
+			// Unique error message per result, like the ones containing the local port
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: fmt.Sprintf("read tcp 10.0.0.1:%d: reset", i)}
+		}
+		pool[i] = &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+
+	agg := newAggregator()
+	agg.initSteps([]types.ScenarioStep{{ID: 1}, {ID: 2}})
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	for i := 0; i < total; i++ {
+		agg.add(pool[i%len(pool)])
+	}
+	elapsed := time.Since(start)
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(pool)
+
+	var growth int64 = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	if growth > memoryBudget {
+		t.Errorf("Aggregation memory Expected under %d bytes, Found %d bytes", memoryBudget, growth)
+	}
+	if rate := float64(total) / elapsed.Seconds(); rate < minRate && !raceEnabled {
+		t.Errorf("Aggregation throughput Expected over %d results/s, Found %.0f results/s", minRate, rate)
+	}
+	t.Logf("aggregated %d results in %v, heap growth %d bytes", total, elapsed, growth)
+
+	if result := agg.result(); result.SuccessCount+result.FailedCount != total {
+		t.Errorf("Aggregated iteration count Expected %d, Found %d", total, result.SuccessCount+result.FailedCount)
+	}
+}
+
+func TestAggregateSleepClamped(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	for i, a := range []*aggregator{agg, agg, other} {
+		custom := map[string]interface{}{}
+		if i != 1 {
+			custom["sleepClamped"] = true
+		}
+		a.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200, Custom: custom}}})
+	}
+	agg.merge(other)
+
+	if c := agg.result().StepResults[1].SleepClampedCount; c != 2 {
+		t.Errorf("SleepClampedCount Expected %d, Found %d", 2, c)
+	}
+}
+
+func TestAggregateParallelGroup(t *testing.T) {
+	agg := newAggregator()
+	group := map[string]interface{}{"parallelGroup": "home"}
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+		{StepID: 1, StatusCode: 200, Duration: 100 * time.Millisecond},
+		{StepID: 2, StatusCode: 200, Duration: 200 * time.Millisecond, Custom: group},
+		{StepID: 3, StatusCode: 200, Duration: 500 * time.Millisecond, Custom: group},
+		{StepID: 4, StatusCode: 200, Duration: 300 * time.Millisecond, Custom: group},
+		{StepID: 5, StatusCode: 200, Duration: 100 * time.Millisecond},
+	}})
+
+	// The group takes as long as its slowest step
+	if d := agg.result().AvgDuration; d != 0.7 {
+		t.Errorf("AvgDuration Expected %v, Found %v", 0.7, d)
+	}
+}
+
+func TestAggregateClientWait(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	for i := 0; i < 100; i++ {
+		// Step 1 is queued in the client for the 10% of the requests, step 2 only for a few ones
+		a, wait1, wait2 := agg, time.Millisecond, time.Millisecond
+		if i%2 == 0 {
+			a = other
+		}
+		if i%10 == 0 {
+			wait1 = 500 * time.Millisecond
+		}
+		if i == 0 {
+			wait2 = time.Second
+		}
+		a.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StatusCode: 200, Custom: map[string]interface{}{"clientWaitDuration": wait1}},
+			{StepID: 2, StatusCode: 200, Custom: map[string]interface{}{"clientWaitDuration": wait2}},
+			{StepID: 3, StatusCode: 200},
+		}})
+	}
+	agg.merge(other)
+	result := agg.result()
+
+	tests := []struct {
+		stepID         uint16
+		generatorBound bool
+		minP95, maxP95 time.Duration
+	}{
+		{1, true, 400 * time.Millisecond, 600 * time.Millisecond},
+		{2, false, 0, 2 * time.Millisecond},
+		{3, false, 0, 0},
+	}
+	for _, test := range tests {
+		s := result.StepResults[test.stepID]
+		p95 := time.Duration(float64(s.ClientWaitP95) * float64(time.Second))
+		if s.GeneratorBound != test.generatorBound || p95 < test.minP95 || p95 > test.maxP95 {
+			t.Errorf("Step %d Expected generator-bound %v with p95 in [%v, %v], Found %v, %v", test.stepID,
+				test.generatorBound, test.minP95, test.maxP95, s.GeneratorBound, p95)
+		}
+	}
+}
+
+func TestAggregateTLSHandshakes(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	handshake := func(resumed bool, d time.Duration, version string) map[string]interface{} {
+		return map[string]interface{}{"tlsDuration": d, "tlsResumed": resumed, "tlsVersion": version,
+			"tlsALPN": "h2"}
+	}
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+		{StepID: 1, StatusCode: 200, Custom: handshake(false, 30*time.Millisecond, "TLS 1.3")},
+		{StepID: 2, StatusCode: 200, Custom: map[string]interface{}{"tlsDuration": time.Duration(0)}},
+	}})
+	for i := 0; i < 3; i++ {
+		other.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			// Failed requests did the handshake too
+			{StepID: 1, StatusCode: 500, Err: types.RequestError{Type: types.ErrorStatus, Reason: "status"},
+				Custom: handshake(true, 6*time.Millisecond, "TLS 1.2")},
+			{StepID: 2, StatusCode: 200},
+		}})
+	}
+	agg.merge(other)
+	result := agg.result()
+
+	expected := &TLSSummary{
+		FullHandshakes:     1,
+		ResumedHandshakes:  3,
+		ResumptionRatio:    0.75,
+		AvgFullDuration:    0.03,
+		AvgResumedDuration: 0.006,
+		Versions:           map[string]int64{"TLS 1.3": 1, "TLS 1.2": 3},
+		ALPN:               map[string]int64{"h2": 4},
+	}
+	if s := result.StepResults[1].TLS; !reflect.DeepEqual(s, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, s)
+	}
+	if s := result.StepResults[2].TLS; s != nil {
+		t.Errorf("Step without a handshake should have no TLS summary, Found %+v", s)
+	}
+}
+func TestAggregateConnectionUse(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	use := func(position int64, reconnect bool, closes int64) map[string]interface{} {
+		custom := map[string]interface{}{"connReused": position > 1, "connRequest": position}
+		if reconnect {
+			custom["connReconnect"] = true
+		}
+		if closes > 0 {
+			custom["serverCloses"] = closes
+		}
+		return custom
+	}
+	// A connection of 3 requests closed by the server, its replacement of 2 requests, and a connection of 1 request
+	for _, c := range []map[string]interface{}{use(1, false, 0), use(2, false, 0), use(3, false, 1)} {
+		agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StatusCode: 200, Custom: c},
+		}})
+	}
+	for _, c := range []map[string]interface{}{use(1, true, 0), use(2, false, 0), use(1, false, 0)} {
+		other.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StatusCode: 200, Custom: c},
+			{StepID: 2, StatusCode: 200},
+		}})
+	}
+	agg.merge(other)
+	result := agg.result()
+
+	expected := &ConnectionSummary{Connections: 3, Requests: 6, AvgRequests: 2, P50Requests: 2, P90Requests: 3,
+		MaxRequests: 3, ServerCloses: 1, Reconnects: 1}
+	if s := result.StepResults[1].Connections; !reflect.DeepEqual(s, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, s)
+	}
+	if s := result.StepResults[2].Connections; s != nil {
+		t.Errorf("Step without a counted connection should have no connection summary, Found %+v", s)
+	}
+}
+
+func TestAggregateCertificates(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	cert := func(host, issuer string, notAfter time.Time) map[string]interface{} {
+		return map[string]interface{}{"tlsCertificate": types.Certificate{Host: host, Subject: "CN=" + host,
+			Issuer: issuer, NotAfter: notAfter, SANs: []string{host}, ChainLength: 2}}
+	}
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+		{StepID: 1, RequestTime: start.Add(time.Second), Custom: cert("api.test.com", "CN=Later", start.AddDate(0, 0, 90))},
+		{StepID: 2, RequestTime: start, Custom: map[string]interface{}{}},
+	}})
+	other.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+		// Certificate of the earliest request of a host is kept, regardless of the merge order
+		{StepID: 1, RequestTime: start, Custom: cert("api.test.com", "CN=First", start.AddDate(0, 0, 6).Add(time.Hour))},
+		{StepID: 2, RequestTime: start, Custom: cert("auth.test.com", "CN=CA", start.AddDate(0, 0, -2))},
+	}})
+	agg.merge(other)
+
+	expected := []CertificateSummary{
+		{Host: "api.test.com", Subject: "CN=api.test.com", Issuer: "CN=First",
+			NotAfter: start.AddDate(0, 0, 6).Add(time.Hour), SANs: []string{"api.test.com"}, ChainLength: 2, DaysLeft: 6},
+		{Host: "auth.test.com", Subject: "CN=auth.test.com", Issuer: "CN=CA", NotAfter: start.AddDate(0, 0, -2),
+			SANs: []string{"auth.test.com"}, ChainLength: 2, DaysLeft: -2},
+	}
+	if c := agg.result().Certificates; !reflect.DeepEqual(c, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, c)
+	}
+	if c := newAggregator().result().Certificates; c != nil {
+		t.Errorf("Result without a TLS host should have no certificates, Found %+v", c)
+	}
+}
+
+func TestAggregateTransferredBytes(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	results := []struct {
+		a      *aggregator
+		stepID uint16
+		failed bool
+		sent   int64
+	}{
+		{agg, 1, false, 100},
+		{agg, 1, true, 50},
+		{other, 1, false, 100},
+		{other, 2, false, 10},
+	}
+	for _, r := range results {
+		sr := &types.ScenarioStepResult{StepID: r.stepID, StatusCode: 200,
+			Custom: map[string]interface{}{"bytesSent": r.sent, "bytesReceived": r.sent * 2}}
+		if r.failed {
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: "connection reset by peer"}
+		}
+		r.a.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}})
+	}
+	agg.merge(other)
+	result := agg.result()
+
+	if st := result.StepResults[1]; st.BytesSent != 250 || st.BytesReceived != 500 {
+		t.Errorf("Step bytes Expected 250/500, Found %d/%d", st.BytesSent, st.BytesReceived)
+	}
+	if result.BytesSent != 260 || result.BytesReceived != 520 {
+		t.Errorf("Total bytes Expected 260/520, Found %d/%d", result.BytesSent, result.BytesReceived)
+	}
+}
+
+func TestAggregatePercentiles(t *testing.T) {
+	sketch := types.PercentileSketch{Kind: types.SketchHDR, SignificantDigits: 2}
+	agg, other := newAggregator(), newAggregator()
+	agg.sketch, other.sketch = sketch, sketch
+	for i := 1; i <= 100; i++ {
+		a := agg
+		if i%2 == 0 {
+			a = other
+		}
+		a.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StatusCode: 200, Duration: time.Duration(i) * time.Millisecond}}})
+	}
+	agg.merge(other)
+	st := agg.result().StepResults[1]
+
+	if st.Percentiles == nil || st.Percentiles.Mode != types.SketchHDR || st.Percentiles.SignificantDigits != 2 {
+		t.Fatalf("Expected the percentiles of the hdr sketch, Found %+v", st.Percentiles)
+	}
+	if p99 := st.percentiles[99]; math.Abs(float64(p99)-0.099) > 0.001 {
+		t.Errorf("p99: Expected 0.099, Found %g", p99)
+	}
+	j, _ := json.Marshal(st.Percentiles)
+	if !strings.Contains(string(j), `"mode":"hdr","significant_digits":2`) {
+		t.Errorf("Percentiles should state the sketch that produced them, Found %s", j)
+	}
+}
+
+func TestAggregateTruncated(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	truncated := func(n int64, ttfb time.Duration) *types.ScenarioResult {
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+			StepID:     1,
+			StatusCode: 200,
+			Err:        types.RequestError{Type: types.ErrorTruncated, Reason: types.ReasonTruncated},
+			Custom:     map[string]interface{}{"truncatedBytes": n, "serverProcessDuration": ttfb},
+		}}}
+	}
+	agg.add(truncated(100, time.Second))
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+		StepID: 1, Err: types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnRefused}}}})
+	other.add(truncated(300, 3*time.Second))
+	agg.merge(other)
+
+	st := agg.result().StepResults[1]
+	if st.TruncatedCount != 2 || st.FailedCount != 3 {
+		t.Errorf("Expected 2 truncated of 3 failed requests, Found %d of %d", st.TruncatedCount, st.FailedCount)
+	}
+	if st.AvgTruncatedBytes != 200 || st.AvgTruncatedTTFB != 2 {
+		t.Errorf("Expected avg 200 bytes and 2s TTFB, Found %v bytes and %vs", st.AvgTruncatedBytes, st.AvgTruncatedTTFB)
+	}
+	expected := map[string]int{types.ReasonTruncated: 2, types.ReasonConnRefused: 1}
+	if !reflect.DeepEqual(st.ErrorDist, expected) {
+		t.Errorf("ErrorDist Expected %v, Found %v", expected, st.ErrorDist)
+	}
+}
+
+func TestAggregateChurnRetry(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	retried := func(status int, lost time.Duration) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, StatusCode: status, Duration: time.Second,
+			Custom: map[string]interface{}{"churnRetried": true, "churnRetryTime": lost}}
+		if status == 0 {
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnRefused}
+		}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+	agg.add(retried(200, 100*time.Millisecond))
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1,
+		Err: types.RequestError{Type: types.ErrorConn, Reason: types.ReasonStaleConn}}}})
+	other.add(retried(0, 300*time.Millisecond))
+	agg.merge(other)
+	result := agg.result()
+
+	// Retried requests are counted by the result of their retry, the stale attempts are not in the durations
+	st := result.StepResults[1]
+	if st.ChurnRetryCount != 2 || st.SuccessCount != 1 || st.FailedCount != 2 {
+		t.Errorf("Expected 2 retries, 1 successful and 2 failed requests, Found %d, %d and %d", st.ChurnRetryCount,
+			st.SuccessCount, st.FailedCount)
+	}
+	if st.AvgChurnRetryTime != 0.2 || st.Durations["duration"] != 1 {
+		t.Errorf("Expected avg 0.2s lost and 1s duration, Found %v and %v", st.AvgChurnRetryTime,
+			st.Durations["duration"])
+	}
+	if _, ok := st.Durations["churnRetryTime"]; ok {
+		t.Errorf("Time of the stale attempts should not be a duration, Found %v", st.Durations)
+	}
+	expected := map[string]int{types.ReasonStaleConn: 1, types.ReasonConnRefused: 1}
+	if !reflect.DeepEqual(st.ErrorDist, expected) {
+		t.Errorf("ErrorDist Expected %v, Found %v", expected, st.ErrorDist)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed,
+		"Churn Retries:    2 requests retried on a fresh connection, avg 0.2000s lost on the stale one") {
+		t.Errorf("Churn retries should be printed, Found: %s", printed)
+	}
+}
+
+func TestAggregateHedge(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	request := func(d, unhedged time.Duration, custom map[string]interface{}) *types.ScenarioResult {
+		if custom == nil {
+			custom = map[string]interface{}{}
+		}
+		custom["unhedgedTime"] = unhedged
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200,
+			Duration: d, Custom: custom}}}
+	}
+	agg.add(request(100*time.Millisecond, 100*time.Millisecond, nil))
+	agg.add(request(300*time.Millisecond, 500*time.Millisecond, map[string]interface{}{"hedged": true, "hedgeWon": true}))
+	other.add(request(200*time.Millisecond, 200*time.Millisecond, map[string]interface{}{"hedged": true}))
+	other.add(request(100*time.Millisecond, 100*time.Millisecond, nil))
+	agg.merge(other)
+	result := agg.result()
+
+	// Hedges are not in the request counts of the step
+	st := result.StepResults[1]
+	if st.SuccessCount != 4 || st.Hedge == nil {
+		t.Fatalf("Expected 4 successful requests and the hedges, Found %d and %v", st.SuccessCount, st.Hedge)
+	}
+	expected := &HedgeSummary{HedgeCount: 2, HedgeWinCount: 1, HedgeRate: 0.5,
+		Hedged:   HedgePercentiles{P50: 0.1, P95: 0.3, P99: 0.3},
+		Unhedged: HedgePercentiles{P50: 0.1, P95: 0.5, P99: 0.5}}
+	approx := func(a, b float32) bool { return math.Abs(float64(a-b)) < 0.01*float64(b) }
+	h := st.Hedge
+	if h.HedgeCount != expected.HedgeCount || h.HedgeWinCount != expected.HedgeWinCount ||
+		h.HedgeRate != expected.HedgeRate || !approx(h.Hedged.P95, expected.Hedged.P95) ||
+		!approx(h.Unhedged.P99, expected.Unhedged.P99) || !approx(h.Unhedged.P50, expected.Unhedged.P50) {
+		t.Errorf("Hedge Expected %+v, Found %+v", expected, h)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed,
+		"2 extra requests, 50% of the requests hedged, 1 won by the hedge") {
+		t.Errorf("Hedges should be printed, Found: %s", printed)
+	}
+	if _, ok := st.Durations["unhedgedTime"]; ok {
+		t.Errorf("Unhedged time should not be a duration, Found %v", st.Durations)
+	}
+}
+
+func TestAggregateConditional(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	request := func(code int, d time.Duration, conditional bool) *types.ScenarioResult {
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: code,
+			Duration: d, Custom: map[string]interface{}{"conditional": conditional}}}}
+	}
+	agg.add(request(200, 300*time.Millisecond, false))
+	agg.add(request(304, 10*time.Millisecond, true))
+	agg.add(request(304, 30*time.Millisecond, true))
+	other.add(request(200, 100*time.Millisecond, false))
+	other.add(request(200, 200*time.Millisecond, true))
+	agg.merge(other)
+	result := agg.result()
+
+	c := result.StepResults[1].Conditional
+	if c == nil {
+		t.Fatalf("Conditional requests should be reported")
+	}
+	approx := func(a, b float32) bool { return math.Abs(float64(a-b)) < 0.01*float64(b) }
+	if c.ConditionalCount != 3 || c.UnconditionalCount != 2 || c.NotModifiedCount != 2 ||
+		!approx(c.NotModifiedRate, 2.0/3) {
+		t.Errorf("Expected 3 conditional, 2 unconditional and 2 not modified requests, Found %+v", c)
+	}
+	if c.NotModified.Count != 2 || !approx(c.NotModified.Avg, 0.02) || !approx(c.NotModified.P95, 0.03) ||
+		c.Full.Count != 3 || !approx(c.Full.Avg, 0.2) || !approx(c.Full.P95, 0.3) {
+		t.Errorf("Expected the durations of the 304 and 200 responses apart, Found %+v %+v", c.NotModified, c.Full)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed,
+		"3 conditional, 2 unconditional, 2 not modified") {
+		t.Errorf("Conditional requests should be printed, Found: %s", printed)
+	}
+
+	agg = newAggregator()
+	agg.add(request(200, time.Millisecond, false))
+	if c := agg.result().StepResults[1].Conditional; c == nil || c.NotModifiedRate != 0 {
+		t.Errorf("Step without a conditional request should have no 304 rate, Found %+v", c)
+	}
+}
+
+func TestAggregateDuplicates(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	request := func(code int, d *types.DuplicateResult) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, StatusCode: code, Duration: 100 * time.Millisecond,
+			Custom: map[string]interface{}{}}
+		if d != nil {
+			sr.Custom["duplicate"] = *d
+		}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+	agg.add(request(201, nil))
+	agg.add(request(201, &types.DuplicateResult{StatusCode: 201, Duration: 50 * time.Millisecond,
+		StatusMatch: true, BodyMatch: true}))
+	agg.add(request(201, &types.DuplicateResult{StatusCode: 409, Duration: 10 * time.Millisecond}))
+	other.add(request(201, &types.DuplicateResult{StatusCode: 409, Duration: 30 * time.Millisecond}))
+	other.add(request(201, &types.DuplicateResult{Duration: time.Second,
+		Err: types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}}))
+	agg.merge(other)
+	result := agg.result()
+
+	d := result.StepResults[1].Duplicates
+	if d == nil {
+		t.Fatalf("Duplicates should be reported")
+	}
+	approx := func(a, b float32) bool { return math.Abs(float64(a-b)) < 0.01*float64(b) }
+	if d.PairCount != 4 || d.FailedCount != 1 || d.StatusMatchCount != 1 || d.BodyMatchCount != 1 ||
+		!approx(d.StatusMatchRate, 1.0/3) || !approx(d.BodyMatchRate, 1.0/3) {
+		t.Errorf("Expected 4 pairs, 1 failed and 1 match of the 3 answered pairs, Found %+v", d)
+	}
+	if d.Original.Count != 3 || !approx(d.Original.Avg, 0.1) || d.Duplicate.Count != 3 ||
+		!approx(d.Duplicate.Avg, 0.03) {
+		t.Errorf("Expected the durations of the answered pairs, Found %+v %+v", d.Original, d.Duplicate)
+	}
+	if !reflect.DeepEqual(d.StatusMismatches, map[string]int64{"201 -> 409": 2}) {
+		t.Errorf("Expected 2 mismatches of 201 -> 409, Found %v", d.StatusMismatches)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed,
+		"4 pairs, 1 without a response, 33% same status, 33% same body") ||
+		!strings.Contains(printed, "201 -> 409 (2)") {
+		t.Errorf("Duplicates should be printed, Found: %s", printed)
+	}
+
+	agg = newAggregator()
+	agg.add(request(201, nil))
+	if d := agg.result().StepResults[1].Duplicates; d != nil {
+		t.Errorf("Step without a duplicate should have no duplicates, Found %+v", d)
+	}
+}
+
+func TestAggregateAdaptiveTimeout(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	request := func(timeout time.Duration, cut string) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, StatusCode: 200, Duration: 50 * time.Millisecond,
+			Custom: map[string]interface{}{"adaptiveTimeout": timeout}}
+		if cut != "" {
+			sr.StatusCode, sr.Duration = 0, timeout
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}
+			sr.Custom["timeoutCut"] = cut
+		}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+	agg.add(request(5*time.Second, ""))
+	agg.add(request(5*time.Second, "max"))
+	other.add(request(300*time.Millisecond, ""))
+	other.add(request(150*time.Millisecond, "adaptive"))
+	other.add(request(150*time.Millisecond, "adaptive"))
+	agg.merge(other)
+	result := agg.result()
+
+	expected := &AdaptiveTimeoutSummary{MinTimeout: 0.15, MaxTimeout: 5, CutCount: 2, MaxTimeoutCount: 1}
+	if at := result.StepResults[1].AdaptiveTimeout; !reflect.DeepEqual(at, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, at)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed,
+		"2 cut under the max, 1 timed out at the max") {
+		t.Errorf("Adaptive timeouts should be printed, Found: %s", printed)
+	}
+
+	agg = newAggregator()
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200}}})
+	if at := agg.result().StepResults[1].AdaptiveTimeout; at != nil {
+		t.Errorf("Step without an adaptive-timeout should have no summary, Found %+v", at)
+	}
+}
+
+func TestAggregateRedirects(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	request := func(final string, hops int, loop bool) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, StatusCode: 200, Custom: map[string]interface{}{}}
+		if hops > 0 {
+			chain := []types.RedirectHop{}
+			for i := 0; i < hops; i++ {
+				chain = append(chain, types.RedirectHop{URL: fmt.Sprintf("http://test.com/%d", i), StatusCode: 302})
+			}
+			sr.Custom["redirects"] = append(chain, types.RedirectHop{URL: final, StatusCode: 200})
+			sr.Custom["redirectTime"] = time.Duration(hops) * 100 * time.Millisecond
+			if loop {
+				sr.Custom["redirectLoop"] = true
+			}
+		}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+	agg.add(request("http://test.com/a", 1, false))
+	agg.add(request("", 0, false))
+	other.add(request("http://test.com/a", 3, false))
+	other.add(request("http://test.com/b", 2, true))
+	for i := 0; i < maxRedirectFinalURLs; i++ {
+		other.add(request(fmt.Sprintf("http://test.com/c%02d", i), 1, false))
+	}
+	agg.merge(other)
+	result := agg.result()
+
+	r := result.StepResults[1].Redirects
+	if r == nil || r.RedirectedCount != 23 || r.LoopCount != 1 {
+		t.Fatalf("Expected 23 redirected requests and a loop, Found %+v", r)
+	}
+	if r.AvgRedirects != float32(26)/23 || r.AvgTime != float32(2.6/23) {
+		t.Errorf("Expected avg 26/23 redirects in 2.6s/23, Found %v in %v", r.AvgRedirects, r.AvgTime)
+	}
+	// Final urls over the limit are grouped, the ones merged in the sorted order get their own groups
+	if len(r.FinalURLs) != maxRedirectFinalURLs+1 || r.FinalURLs["http://test.com/a"] != 2 ||
+		r.FinalURLs["http://test.com/b"] != 1 || r.FinalURLs[types.OverflowRedirectURL] != 2 {
+		t.Errorf("Unexpected final urls %v", r.FinalURLs)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed, "Redirects (Count:Final URL)") ||
+		!strings.Contains(printed, "1 loops") || !strings.Contains(printed, "http://test.com/a") {
+		t.Errorf("Redirects should be printed, Found: %s", printed)
+	}
+
+	agg = newAggregator()
+	agg.add(request("", 0, false))
+	if r := agg.result().StepResults[1].Redirects; r != nil {
+		t.Errorf("Step without the redirected requests should have no summary, Found %+v", r)
+	}
+}
+
+func TestAggregatePageLoad(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	page := func(loadTime time.Duration, resources ...types.PageResource) *types.ScenarioResult {
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200,
+			Duration: 100 * time.Millisecond, Custom: map[string]interface{}{"pageLoadTime": loadTime,
+				"pageResources": resources}}}}
+	}
+	css := types.PageResource{ContentType: "text/css", StatusCode: 200, Duration: 20 * time.Millisecond, Bytes: 300}
+	png := types.PageResource{ContentType: "image/png", StatusCode: 200, Duration: 40 * time.Millisecond, Bytes: 1000}
+	missing := types.PageResource{StatusCode: 404, Duration: 10 * time.Millisecond, Failed: true}
+	agg.add(page(200*time.Millisecond, css, png))
+	other.add(page(400*time.Millisecond, css, png, png, missing))
+	other.add(page(300 * time.Millisecond))
+	agg.merge(other)
+	result := agg.result()
+
+	p := result.StepResults[1].PageLoad
+	if p == nil || p.PageCount != 3 || p.AvgResources != 2 || p.FailedResourceCount != 1 {
+		t.Fatalf("Expected 3 pages with 6 resources and a failed one, Found %+v", p)
+	}
+	if p.AvgLoadTime != 0.3 || p.P50LoadTime < 0.29 || p.P50LoadTime > 0.31 {
+		t.Errorf("Expected the load times about 300ms, Found %+v", p)
+	}
+	expected := map[string]*PageResourceSummary{
+		"text/css":               {Count: 2, AvgDuration: 0.02, AvgBytes: 300},
+		"image/png":              {Count: 3, AvgDuration: 0.04, AvgBytes: 1000},
+		types.MissingContentType: {Count: 1, FailedCount: 1, AvgDuration: 0.01},
+	}
+	if !reflect.DeepEqual(p.ContentTypes, expected) {
+		t.Errorf("Content types Expected %+v, Found %+v", expected, p.ContentTypes)
+	}
+	if result.StepResults[1].SuccessCount != 3 {
+		t.Errorf("Resources should not be counted as the requests of the step, Found %d",
+			result.StepResults[1].SuccessCount)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed, "Page Load (Content Type") ||
+		!strings.Contains(printed, "image/png") {
+		t.Errorf("Page loads should be printed, Found: %s", printed)
+	}
+
+	agg = newAggregator()
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200}}})
+	if p := agg.result().StepResults[1].PageLoad; p != nil {
+		t.Errorf("Step without a page-load should have no summary, Found %+v", p)
+	}
+}
+
+func TestRollingP99(t *testing.T) {
+	var feed latencyFeed
+	if _, ok := feed.RollingP99(1); ok {
+		t.Errorf("Rolling p99 should not be available before the aggregation starts")
+	}
+
+	steps := []types.ScenarioStep{{ID: 1}, {ID: 2}}
+	p := newPipeline(2, steps, types.PercentileSketch{})
+	feed.feedFrom(p.window)
+	start := time.Unix(1700000000, 0)
+	request := func(at time.Time, d time.Duration, err types.RequestError, cut string) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, RequestTime: at, Duration: d, Err: err,
+			Custom: map[string]interface{}{"adaptiveTimeout": time.Second}}
+		if cut != "" {
+			sr.Custom["timeoutCut"] = cut
+		}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+	failed := types.RequestError{Type: types.ErrorStatus, Reason: "unexpected status code: 500"}
+	timedOut := types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}
+
+	// Slow requests at the start fall out of the window, the fast failures are not in it, the cut requests are
+	p.shards[0].agg.add(request(start, 5*time.Second, types.RequestError{}, ""))
+	p.shards[1].agg.add(request(start.Add(time.Second), 4*time.Second, types.RequestError{}, ""))
+	for i := 0; i < 98; i++ {
+		p.shards[i%2].agg.add(request(start.Add(40*time.Second), 100*time.Millisecond, types.RequestError{}, ""))
+	}
+	p.shards[0].agg.add(request(start.Add(40*time.Second), time.Millisecond, failed, ""))
+	p.shards[1].agg.add(request(start.Add(45*time.Second), 300*time.Millisecond, timedOut, "adaptive"))
+	p.shards[0].agg.add(request(start.Add(45*time.Second), 200*time.Millisecond, types.RequestError{}, ""))
+
+	p99, ok := feed.RollingP99(1)
+	if !ok || p99 < 200*time.Millisecond || p99 > 205*time.Millisecond {
+		t.Errorf("Expected the rolling p99 about 200ms, Found %s %v", p99, ok)
+	}
+	if _, ok := feed.RollingP99(2); ok {
+		t.Errorf("Step without an adaptive-timeout should have no rolling p99")
+	}
+}
+
+func TestAggregateVariants(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	request := func(variant string, code int, d time.Duration, err types.RequestError) *types.ScenarioResult {
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: code,
+			Duration: d, Err: err, Variant: variant}}}
+	}
+	for i := 0; i < 4; i++ {
+		agg.add(request(types.VariantA, 200, 100*time.Millisecond, types.RequestError{}))
+	}
+	other.add(request(types.VariantB, 200, 200*time.Millisecond, types.RequestError{}))
+	other.add(request(types.VariantB, 200, 200*time.Millisecond, types.RequestError{}))
+	other.add(request(types.VariantB, 503, 0, types.RequestError{Type: types.ErrorStatus, Reason: "status 503"}))
+	other.add(request(types.VariantB, 0, 0, types.RequestError{Type: types.ErrorConn, Reason: "connection refused"}))
+	agg.merge(other)
+	result := agg.result()
+
+	st := result.StepResults[1]
+	a, b := st.Variants[types.VariantA], st.Variants[types.VariantB]
+	if a == nil || b == nil {
+		t.Fatalf("Expected the results of both variants, Found %v", st.Variants)
+	}
+	approx := func(a, b float32) bool { return math.Abs(float64(a-b)) < 0.01*float64(b) }
+	if a.SuccessCount != 4 || a.FailedCount != 0 || a.SuccessRate != 1 || !approx(a.AvgDuration, 0.1) ||
+		!approx(a.P95, 0.1) || !reflect.DeepEqual(a.StatusCodeDist, map[int]int{200: 4}) {
+		t.Errorf("Variant a Expected 4 successful requests of 0.1s, Found %+v", a)
+	}
+	if b.SuccessCount != 2 || b.FailedCount != 2 || b.SuccessRate != 0.5 || !approx(b.AvgDuration, 0.2) ||
+		!reflect.DeepEqual(b.StatusCodeDist, map[int]int{200: 2, 503: 1}) {
+		t.Errorf("Variant b Expected 2 successful and 2 failed requests, Found %+v", b)
+	}
+
+	printed := printedDetails(result)
+	for _, expected := range []string{"A/B Variants (Metric:A:B:Delta):", ":100%", ":50%", ":-50.0pp", ":+100.0%",
+		"200 (OK)", ":4 (100%)", ":2 (50%)", ":-50.0pp", "503 (Service Unavailable)", ":0 (0%)", ":+25.0pp"} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("Variants should be printed with %q, Found: %s", expected, printed)
+		}
+	}
+}
+
+func TestAggregateAnomalousDurations(t *testing.T) {
+	clean, agg, other := newAggregator(), newAggregator(), newAggregator()
+	steps := []types.ScenarioStep{{ID: 1, Timeout: 5}, {ID: 2, Timeout: 5}}
+	for _, a := range []*aggregator{clean, agg, other} {
+		a.initSteps(steps)
+	}
+	iteration := func(first, second time.Duration) *types.ScenarioResult {
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StatusCode: 200, Duration: first, Custom: map[string]interface{}{"dnsDuration": first}},
+			{StepID: 2, StatusCode: 200, Duration: second},
+		}}
+	}
+	for i := 1; i <= 100; i++ {
+		r := iteration(time.Duration(i)*time.Millisecond, 10*time.Millisecond)
+		clean.add(r)
+		if i%2 == 0 {
+			agg.add(r)
+		} else {
+			other.add(r)
+		}
+	}
+	// Durations measured over the steps of the wall clock, the second one is over twice the timeout of the step
+	agg.add(iteration(-3*time.Second, 10*time.Millisecond))
+	other.add(iteration(11*time.Second, 10*time.Millisecond))
+	agg.merge(other)
+
+	expected, result := clean.result(), agg.result()
+	first, second := result.StepResults[1], result.StepResults[2]
+	if result.AnomalousCount != 2 || first.AnomalousCount != 2 || second.AnomalousCount != 0 {
+		t.Errorf("Expected 2 anomalous iterations of step 1, Found %d, %d and %d of step 2", result.AnomalousCount,
+			first.AnomalousCount, second.AnomalousCount)
+	}
+	if result.SuccessCount != expected.SuccessCount || result.AvgDuration != expected.AvgDuration {
+		t.Errorf("Iterations Expected %d with avg %g, Found %d with avg %g", expected.SuccessCount,
+			expected.AvgDuration, result.SuccessCount, result.AvgDuration)
+	}
+	// Other steps of the anomalous iterations are still counted
+	if second.SuccessCount != 102 {
+		t.Errorf("Step 2 Expected 102 successful results, Found %d", second.SuccessCount)
+	}
+	es := expected.StepResults[1]
+	if first.SuccessCount != es.SuccessCount || !reflect.DeepEqual(first.Durations, es.Durations) ||
+		!reflect.DeepEqual(first.percentiles, es.percentiles) {
+		t.Errorf("Step 1 Expected %d %v %v, Found %d %v %v", es.SuccessCount, es.Durations, es.percentiles,
+			first.SuccessCount, first.Durations, first.percentiles)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed,
+		"2 results with a negative or over twice the timeout duration are excluded") {
+		t.Errorf("Anomalous results should be printed, Found: %s", printed)
+	}
+}
+
+func TestAggregateBodyTooLarge(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	tooLarge := func(err types.RequestError) *types.ScenarioResult {
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200, Err: err,
+			Custom: map[string]interface{}{"bodyTooLarge": true, "bodyBytesRead": int64(100)}}}}
+	}
+	agg.add(tooLarge(types.RequestError{}))
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200}}})
+	other.add(tooLarge(types.RequestError{Type: types.ErrorBodyLimit,
+		Reason: "body too large: truncated at max_body_size 100 bytes"}))
+	agg.merge(other)
+	result := agg.result()
+
+	// Bodies over the limit fail only the steps checking them
+	st := result.StepResults[1]
+	if st.BodyTooLargeCount != 2 || st.SuccessCount != 2 || st.FailedCount != 1 {
+		t.Errorf("Expected 2 too large bodies, 2 successful and 1 failed requests, Found %d, %d and %d",
+			st.BodyTooLargeCount, st.SuccessCount, st.FailedCount)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed,
+		"2 responses, the rest of the body over the max_body_size is not read") {
+		t.Errorf("Too large bodies should be printed, Found: %s", printed)
+	}
+}
+
+func TestAggregateSuccessStatus(t *testing.T) {
+	agg := newAggregator()
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 404}}})
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200,
+		Err: types.RequestError{Type: types.ErrorStatus, Reason: "unexpected status code: 200"}}}})
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1,
+		Err: types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnRefused}}}})
+
+	st := agg.result().StepResults[1]
+	if st.SuccessCount != 1 || st.FailedCount != 2 {
+		t.Errorf("Expected 1 successful and 2 failed requests, Found %d and %d", st.SuccessCount, st.FailedCount)
+	}
+	expected := map[int]int{200: 1, 404: 1}
+	if !reflect.DeepEqual(st.StatusCodeDist, expected) {
+		t.Errorf("StatusCodeDist Expected %v, Found %v", expected, st.StatusCodeDist)
+	}
+}
+
+func TestAggregateCompressedResponses(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	compressed := func(wire, decompressed int64) *types.ScenarioResult {
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+			StepID:     1,
+			StatusCode: 200,
+			Custom: map[string]interface{}{"compressedBytes": wire, "decompressedBytes": decompressed,
+				"decompressDuration": 2 * time.Millisecond},
+		}}}
+	}
+	agg.add(compressed(100, 1000))
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200}}})
+	other.add(compressed(300, 2000))
+	agg.merge(other)
+
+	st := agg.result().StepResults[1]
+	if st.CompressedCount != 2 || st.CompressedBytes != 400 || st.DecompressedBytes != 3000 {
+		t.Errorf("Expected 2 compressed responses of 400/3000 bytes, Found %d of %d/%d",
+			st.CompressedCount, st.CompressedBytes, st.DecompressedBytes)
+	}
+}
+
+func TestAggregateFailureSamples(t *testing.T) {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	failed := func(i int, reason string) *types.ScenarioResult {
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+			StepID:      1,
+			StatusCode:  503,
+			RequestTime: start.Add(time.Duration(i) * time.Second),
+			Duration:    time.Duration(i) * time.Millisecond,
+			Err:         types.RequestError{Type: types.ErrorAssertion, Reason: reason},
+			Custom: map[string]interface{}{
+				"failedURL":       fmt.Sprintf("https://example.com/%d", i),
+				"responseSnippet": "unavailable",
+			},
+		}}}
+	}
+
+	// Results are distributed to two aggregators out of order, the earliest samples are kept after the merge
+	agg1, agg2 := newAggregator(), newAggregator()
+	for i := 20; i > 0; i-- {
+		if i%2 == 0 {
+			agg1.add(failed(i, "status 503"))
+		} else {
+			agg2.add(failed(i, "status 503"))
+		}
+	}
+	agg2.add(failed(30, "timeout"))
+	agg1.merge(agg2)
+
+	samples := agg1.result().StepResults[1].FailureSamples
+	if len(samples["status 503"]) != maxFailureSamples {
+		t.Fatalf("Samples Expected %d, Found %d", maxFailureSamples, len(samples["status 503"]))
+	}
+	for i, s := range samples["status 503"] {
+		expected := FailureSample{
+			Time:       start.Add(time.Duration(i+1) * time.Second),
+			URL:        fmt.Sprintf("https://example.com/%d", i+1),
+			StatusCode: 503,
+			Duration:   float32((time.Duration(i+1) * time.Millisecond).Seconds()),
+			Response:   "unavailable",
+		}
+		if !reflect.DeepEqual(expected, s) {
+			t.Errorf("Sample %d Expected %#v, Found %#v", i, expected, s)
+		}
+	}
+	if len(samples["timeout"]) != 1 {
+		t.Errorf("Timeout samples Expected 1, Found %d", len(samples["timeout"]))
+	}
+
+	// Stderr of the failed sign command is kept in its samples
+	agg := newAggregator()
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+		StepID:      1,
+		RequestTime: start,
+		Err:         types.RequestError{Type: types.ErrorSign, Reason: "sign command exited with status 3"},
+		Custom:      map[string]interface{}{"failedURL": "https://example.com", "signStderr": "key not found"},
+	}}})
+	signed := agg.result().StepResults[1].FailureSamples["sign command exited with status 3"]
+	if len(signed) != 1 || signed[0].Stderr != "key not found" {
+		t.Errorf("Sign samples Expected the stderr of the command, Found %#v", signed)
+	}
+}
+
+func TestAggregateFailureSamplesOverflow(t *testing.T) {
+	agg := newAggregator()
+	for i := 0; i < 2*maxErrorReasons; i++ {
+		agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+			StepID: 1,
+			Err:    types.RequestError{Type: types.ErrorConn, Reason: fmt.Sprintf("dial tcp 10.0.0.1:%d: refused", i)},
+		}}})
+	}
+
+	samples := agg.result().StepResults[1].FailureSamples
+	if len(samples) != maxErrorReasons {
+		t.Errorf("Sampled reasons Expected %d, Found %d", maxErrorReasons, len(samples))
+	}
+	if len(samples[OverflowErrorReason]) != maxFailureSamples {
+		t.Errorf("Overflow samples Expected %d, Found %d", maxFailureSamples, len(samples[OverflowErrorReason]))
+	}
+}
+
+func TestAggregateDimensions(t *testing.T) {
+	result := func(pod string, d time.Duration, failed bool) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{
+			StepID:   1,
+			Duration: d,
+			Custom:   map[string]interface{}{"dimensions": map[string]string{"X-Backend-Pod": pod}},
+		}
+		if failed {
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}
+		}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+	steps := []types.ScenarioStep{{ID: 1, Custom: map[string]interface{}{
+		"report-dimensions": []interface{}{"X-Backend-Pod"}, "report-dimension-limit": float64(2),
+	}}}
+
+	agg1, agg2 := newAggregator(), newAggregator()
+	agg1.initSteps(steps)
+	agg2.initSteps(steps)
+	agg1.add(result("pod-a", 100*time.Millisecond, false))
+	agg1.add(result("pod-a", 300*time.Millisecond, false))
+	agg2.add(result("pod-b", time.Second, true))
+	agg2.add(result("pod-c", 200*time.Millisecond, false))
+	agg2.add(result(types.MissingDimensionValue, 200*time.Millisecond, false))
+
+	merged := newAggregator()
+	merged.initSteps(steps)
+	merged.merge(agg1)
+	merged.merge(agg2)
+
+	// pod-c is the third distinct value of the merged results, it is grouped under the overflow value
+	expected := map[string]*EndpointSummary{
+		"pod-a":                      {SuccessCount: 2, AvgDuration: 0.2},
+		"pod-b":                      {FailedCount: 1},
+		types.OverflowDimensionValue: {SuccessCount: 2, AvgDuration: 0.2},
+	}
+	dimensions := merged.result().StepResults[1].Dimensions
+	if !reflect.DeepEqual(dimensions["X-Backend-Pod"], expected) {
+		t.Errorf("Expected %v, Found %v", expected, dimensions["X-Backend-Pod"])
+	}
+}
+
+func TestAggregateDrained(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	results := []struct {
+		a       *aggregator
+		drained bool
+		failed  bool
+	}{
+		{agg, false, false},
+		{agg, true, false},
+		{agg, true, true},
+		{other, true, false},
+		{other, false, true},
+	}
+	for _, r := range results {
+		sr := &types.ScenarioStepResult{StepID: 1, StatusCode: 200}
+		if r.failed {
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: "connection reset by peer"}
+		}
+		scr := &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}, Others: map[string]interface{}{}}
+		if r.drained {
+			scr.Others["drained"] = true
+		}
+		r.a.add(scr)
+	}
+	agg.merge(other)
+	result := agg.result()
+
+	if result.SuccessCount != 3 || result.FailedCount != 2 {
+		t.Errorf("Drained results should be included in the counts, Found %d/%d", result.SuccessCount,
+			result.FailedCount)
+	}
+	if result.drainSuccessCount != 2 || result.drainFailedCount != 1 {
+		t.Errorf("Drained counts Expected 2/1, Found %d/%d", result.drainSuccessCount, result.drainFailedCount)
+	}
+}
+
+func TestAggregateLeakScan(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		a := agg
+		if i%2 == 1 {
+			a = other
+		}
+		scan := types.LeakScanResult{URL: fmt.Sprintf("https://test.com/%d", i)}
+		if i > 0 {
+			scan.Matches = []types.LeakMatch{{Pattern: "stack-trace", Excerpt: "goroutine 1 [running]"}}
+		}
+		if i == 5 {
+			scan.Matches = append(scan.Matches, types.LeakMatch{Pattern: "internal-ip", Excerpt: "at 10.0.0.1"})
+		}
+		// Failed requests are scanned too
+		sr := &types.ScenarioStepResult{StepID: 1, StatusCode: 500,
+			RequestTime: start.Add(time.Duration(6-i) * time.Second),
+			Err:         types.RequestError{Type: types.ErrorStatus, Reason: "unexpected status code: 500"},
+			Custom:      map[string]interface{}{"leakScan": scan}}
+		a.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}})
+	}
+	unscanned := newAggregator()
+	unscanned.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 2, StatusCode: 200}}})
+	agg.merge(unscanned)
+	agg.merge(other)
+	result := agg.result()
+
+	l := result.StepResults[1].LeakScan
+	if l == nil || l.Scanned != 6 || !reflect.DeepEqual(l.Matches, map[string]int64{"stack-trace": 5, "internal-ip": 1}) {
+		t.Fatalf("Expected 6 scanned responses with the matches, Found %+v", l)
+	}
+	var urls []string
+	for _, s := range l.Samples["stack-trace"] {
+		urls = append(urls, s.URL)
+	}
+	if expected := []string{"https://test.com/5", "https://test.com/4", "https://test.com/3"}; !reflect.DeepEqual(urls,
+		expected) {
+		t.Errorf("Expected the earliest samples %v, Found %v", expected, urls)
+	}
+	if result.StepResults[2].LeakScan != nil {
+		t.Errorf("Step without a leak scan should have no summary, Found %+v", result.StepResults[2].LeakScan)
+	}
+
+	printed := printedDetails(result)
+	for _, expected := range []string{
+		"Security (Leak Scan of 6 Responses, Pattern:Matching Responses):\n  stack-trace       :5\n",
+		"  internal-ip       :1\n",
+		":500    :https://test.com/5\n      \"at 10.0.0.1\"",
+	} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("Expected %q in the report, Found: %s", expected, printed)
+		}
+	}
+}
+
+func TestAggregateContinueTimeouts(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	for i, a := range []*aggregator{agg, agg, other, other} {
+		sr := &types.ScenarioStepResult{StepID: 1, StatusCode: 200,
+			Custom: map[string]interface{}{"continueWaitDuration": time.Second}}
+		if i%2 == 0 {
+			sr.Custom["continueTimedOut"] = true
+		}
+		if i == 2 {
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: "connection reset by peer"}
+		}
+		a.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}})
+	}
+	agg.merge(other)
+	result := agg.result()
+
+	// Timed out waits are counted for the failed requests too
+	if st := result.StepResults[1]; st.ContinueTimeoutCount != 2 || st.Durations["continueWaitDuration"] != 1 {
+		t.Errorf("Expected 2 continue timeouts with 1s wait, Found %d %v", st.ContinueTimeoutCount, st.Durations)
+	}
+	if printed := printedDetails(result); !strings.Contains(printed,
+		"Continue Timeout:    2 requests, no 100 Continue before the timeout, body sent after it") {
+		t.Errorf("Continue timeouts should be printed, Found: %s", printed)
+	}
+}
+
+func TestAggregateIterationTime(t *testing.T) {
+	step := func(id uint16, d, sleep time.Duration) *types.ScenarioStepResult {
+		sr := &types.ScenarioStepResult{StepID: id, StepName: fmt.Sprintf("step%d", id), StatusCode: 200, Duration: d}
+		if sleep > 0 {
+			sr.Custom = map[string]interface{}{"sleepTime": sleep}
+		}
+		return sr
+	}
+	agg := newAggregator()
+	other := newAggregator()
+	agg.add(&types.ScenarioResult{Duration: 10 * time.Second, StepResults: []*types.ScenarioStepResult{
+		step(1, 2*time.Second, time.Second), step(2, 5*time.Second, 0), step(3, time.Second, 0)}})
+	// Step 2 doesn't run in the iteration
+	other.add(&types.ScenarioResult{Duration: 6 * time.Second, StepResults: []*types.ScenarioStepResult{
+		step(1, 2*time.Second, time.Second), step(3, 2*time.Second, 0)}})
+	// Iterations without a wall time, like the ones not run by the scenario service, are not counted
+	other.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{step(1, time.Second, 0)}})
+	agg.merge(other)
+	result := agg.result()
+
+	expected := &IterationTimeSummary{Iterations: 2, AvgDuration: 8, AvgOther: 1, OtherShare: 0.125,
+		Steps: []StepTimeSummary{
+			{ID: 1, Name: "step1", Iterations: 2, AvgDuration: 2, AvgSleep: 1, Share: 0.25, SleepShare: 0.125},
+			{ID: 2, Name: "step2", Iterations: 1, AvgDuration: 5, Share: 0.5},
+			{ID: 3, Name: "step3", Iterations: 2, AvgDuration: 1.5, Share: 0.1875},
+		}}
+	if !reflect.DeepEqual(result.IterationTime, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, result.IterationTime)
+	}
+	printed := printedDetails(result)
+	for _, line := range []string{"Iteration Time (Avg. 8.0000s over 2 iterations, Share:Avg. Time):",
+		"1. step1", "sleep", "2. step2", "in 1/2 iterations", "Other"} {
+		if !strings.Contains(printed, line) {
+			t.Errorf("Expected %q in the report, Found: %s", line, printed)
+		}
+	}
+
+	// Single step takes all the time of its iterations
+	single := newAggregator()
+	single.add(&types.ScenarioResult{Duration: time.Second, StepResults: []*types.ScenarioStepResult{
+		step(1, time.Second, 0)}})
+	if it := single.result().IterationTime; it != nil {
+		t.Errorf("Expected no iteration time of a single step scenario, Found %+v", it)
+	}
+}
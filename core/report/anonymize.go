@@ -0,0 +1,345 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+var (
+	textURLRegexp  = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+	textIPv4Regexp = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	textIPv6Regexp = regexp.MustCompile(`(?i)[0-9a-f]*:[0-9a-f:.]*:[0-9a-f.]*`)
+	textHostRegexp = regexp.MustCompile(`(?i)[a-z0-9](?:[a-z0-9-]*[a-z0-9])?(?:\.[a-z0-9](?:[a-z0-9-]*[a-z0-9])?)*`)
+)
+
+// Anonymizer replaces the hostnames and the IP addresses of a report with the placeholders like host-1 and ip-1,
+// hashes the query values of the URLs and redacts the header values, so the report can be shared externally.
+// The placeholders and the hashes are kept for the report, so the correlations between its sections survive.
+//
+// The fields of the report are anonymized by their anonymize tags:
+//
+//	anonymize:"host"     a host, a host:port or an IP address, the keys of a map
+//	anonymize:"url"      a url string or a slice of them, the keys of a map, the paths are kept
+//	anonymize:"headers"  a map[string]string or a map[string][]string of the headers, all the values are redacted
+//
+// The other strings and map keys are free texts, like the error reasons. The URLs, the IP addresses and the known
+// hosts in them are replaced.
+type Anonymizer struct {
+	mu    sync.Mutex
+	key   []byte
+	hosts map[string]string
+	ips   map[string]string
+}
+
+// anonymized is implemented by the report structs that derive their unexported fields from the exported ones,
+// it is called on the anonymized copy.
+type anonymized interface {
+	anonymized()
+}
+
+// NewAnonymizer returns an anonymizer with the hosts of the config, the hosts are numbered in the given order.
+func NewAnonymizer(hosts ...string) *Anonymizer {
+	a := &Anonymizer{key: make([]byte, 16), hosts: make(map[string]string), ips: make(map[string]string)}
+	// Query values are hashed with a key of the report, so the hashes can't be looked up by the known values.
+	rand.Read(a.key)
+	for _, h := range hosts {
+		a.hostPort(h)
+	}
+	return a
+}
+
+// result returns the anonymized copy of the result, r itself if a is nil.
+func (a *Anonymizer) result(r *Result) *Result {
+	if a == nil || r == nil {
+		return r
+	}
+	return a.copy(r).(*Result)
+}
+
+// configEcho returns the anonymized copy of the config echo, c itself if a is nil.
+func (a *Anonymizer) configEcho(c *ConfigEcho) *ConfigEcho {
+	if a == nil || c == nil {
+		return c
+	}
+	return a.copy(c).(*ConfigEcho)
+}
+
+// runInfo returns the anonymized copy of the run info, r itself if a is nil.
+func (a *Anonymizer) runInfo(r *RunInfo) *RunInfo {
+	if a == nil || r == nil {
+		return r
+	}
+	return a.copy(r).(*RunInfo)
+}
+
+// copy returns the anonymized deep copy of v. The hosts of the tagged fields are registered first, so they are
+// replaced in the free texts wherever they appear.
+func (a *Anonymizer) copy(v interface{}) interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rv := reflect.ValueOf(v)
+	a.collect(rv, "")
+	return a.value(rv, "").Interface()
+}
+
+func (a *Anonymizer) collect(v reflect.Value, tag string) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			a.collect(v.Elem(), tag)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				a.collect(v.Field(i), t.Field(i).Tag.Get("anonymize"))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			a.collect(v.Index(i), tag)
+		}
+	case reflect.Map:
+		// Placeholders are numbered in the order of registration, so the keys are visited in the sorted order
+		for _, k := range sortedKeys(v) {
+			if k.Kind() == reflect.String {
+				switch tag {
+				case "host":
+					a.hostPort(k.String())
+				case "url":
+					a.url(k.String())
+				}
+			}
+			a.collect(v.MapIndex(k), "")
+		}
+	case reflect.String:
+		switch tag {
+		case "host":
+			a.hostPort(v.String())
+		case "url":
+			a.url(v.String())
+		}
+	}
+}
+
+func (a *Anonymizer) value(v reflect.Value, tag string) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(a.value(v.Elem(), tag))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(a.value(v.Elem(), tag))
+		return c
+	case reflect.Struct:
+		// Unexported fields are copied as they are
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(a.value(v.Field(i), t.Field(i).Tag.Get("anonymize")))
+			}
+		}
+		if an, ok := c.Addr().Interface().(anonymized); ok {
+			an.anonymized()
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(a.value(v.Index(i), tag))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(a.value(v.Index(i), tag))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		// Free texts of the keys may collide once anonymized, the last key in the sorted order is kept.
+		for _, k := range sortedKeys(v) {
+			key := k
+			if k.Kind() == reflect.String {
+				keyTag := ""
+				if tag == "host" || tag == "url" {
+					keyTag = tag
+				}
+				key = a.value(k, keyTag)
+			}
+			valueTag := ""
+			if tag == "headers" {
+				valueTag = "headers"
+			}
+			c.SetMapIndex(key, a.value(v.MapIndex(k), valueTag))
+		}
+		return c
+	case reflect.String:
+		var s string
+		switch tag {
+		case "host":
+			s = a.hostPort(v.String())
+		case "url":
+			s = a.url(v.String())
+		case "headers":
+			s = v.String()
+			if s != "" {
+				s = types.RedactedValue
+			}
+		default:
+			s = a.text(v.String())
+		}
+		return reflect.ValueOf(s).Convert(v.Type())
+	}
+	return v
+}
+
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	return keys
+}
+
+// host returns the placeholder of the host or the IP address. Empty and templated hosts, and the group of the
+// hosts over the report limit are kept.
+func (a *Anonymizer) host(h string) string {
+	key := strings.TrimSuffix(strings.ToLower(strings.Trim(h, "[]")), ".")
+	if key == "" || strings.Contains(key, "{{") || h == types.OverflowHost {
+		return h
+	}
+	if ip := net.ParseIP(key); ip != nil {
+		return a.ip(ip.String())
+	}
+	p, ok := a.hosts[key]
+	if !ok {
+		p = fmt.Sprintf("host-%d", len(a.hosts)+1)
+		a.hosts[key] = p
+	}
+	return p
+}
+
+func (a *Anonymizer) ip(ip string) string {
+	p, ok := a.ips[ip]
+	if !ok {
+		p = fmt.Sprintf("ip-%d", len(a.ips)+1)
+		a.ips[ip] = p
+	}
+	return p
+}
+
+// hostPort returns the address with its host replaced, the port is kept.
+func (a *Anonymizer) hostPort(addr string) string {
+	if h, port, err := net.SplitHostPort(addr); err == nil {
+		return a.host(h) + ":" + port
+	}
+	return a.host(addr)
+}
+
+// url returns the url with its host replaced, its user info dropped and its query values hashed. The url is not
+// parsed, so the templated urls are anonymized as well.
+func (a *Anonymizer) url(u string) string {
+	rest, fragment, hasFragment := strings.Cut(u, "#")
+	base, query, hasQuery := strings.Cut(rest, "?")
+
+	if scheme, hostPart, ok := strings.Cut(base, "://"); ok {
+		authority, path, hasPath := strings.Cut(hostPart, "/")
+		if at := strings.LastIndex(authority, "@"); at >= 0 {
+			authority = authority[at+1:]
+		}
+		base = scheme + "://" + a.hostPort(authority)
+		if hasPath {
+			base += "/" + path
+		}
+	}
+
+	if hasQuery {
+		params := strings.Split(query, "&")
+		for i, p := range params {
+			if k, v, ok := strings.Cut(p, "="); ok && v != "" {
+				params[i] = k + "=" + a.hash(v)
+			}
+		}
+		base += "?" + strings.Join(params, "&")
+	}
+	if hasFragment {
+		base += "#" + fragment
+	}
+	return base
+}
+
+func (a *Anonymizer) hash(v string) string {
+	m := hmac.New(sha256.New, a.key)
+	m.Write([]byte(v))
+	return hex.EncodeToString(m.Sum(nil)[:6])
+}
+
+// text replaces the URLs, the IP addresses and the known hosts in the free text.
+func (a *Anonymizer) text(s string) string {
+	if s == "" {
+		return s
+	}
+	s = textURLRegexp.ReplaceAllStringFunc(s, func(u string) string {
+		// Punctuation after the url belongs to the text
+		trimmed := strings.TrimRight(u, ".,;:!?)")
+		return a.url(trimmed) + u[len(trimmed):]
+	})
+	replaceIP := func(m string) string {
+		if ip := net.ParseIP(m); ip != nil {
+			return a.ip(ip.String())
+		}
+		return m
+	}
+	s = textIPv4Regexp.ReplaceAllStringFunc(s, replaceIP)
+	s = textIPv6Regexp.ReplaceAllStringFunc(s, replaceIP)
+	return textHostRegexp.ReplaceAllStringFunc(s, func(h string) string {
+		if p, ok := a.hosts[strings.ToLower(h)]; ok {
+			return p
+		}
+		return h
+	})
+}
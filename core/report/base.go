@@ -22,8 +22,14 @@ package report
 
 import (
 	"fmt"
+	"io"
+	"net/url"
 	"reflect"
+	"time"
 
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/live"
 	"go.ddosify.com/ddosify/core/types"
 )
 
@@ -36,6 +42,180 @@ type ReportService interface {
 	Start(input chan *types.ScenarioResult)
 }
 
+// RunInitializer is the optional interface for the report services that render the steps of the scenario up front,
+// like the steps that never complete a request. The engine initializes the report services by InitReport, which
+// calls InitRun instead of Init with the run info.
+type RunInitializer interface {
+	InitRun(debug bool, info RunInfo) error
+}
+
+// LoadPlanAware is the optional interface for the report services that display the run progress.
+// The engine calls SetLoadPlan with the planned iteration count of each tick before starting the test.
+type LoadPlanAware interface {
+	SetLoadPlan(tickInterval time.Duration, reqCountArr []int)
+}
+
+// EstimateAware is the optional interface for the report services that compare the load of the test with its
+// estimate before the run.
+type EstimateAware interface {
+	SetEstimate(e types.Estimate)
+}
+
+// ScenarioAware is the optional interface for the report services that prepare the step summaries up front.
+// The engine calls SetScenario with the scenario of the test before starting the test.
+type ScenarioAware interface {
+	SetScenario(s types.Scenario)
+}
+
+// Abortable is the optional interface for the report services that can report the results collected so far,
+// when the test is aborted without waiting the in-flight iterations. The input channel is never closed in that case.
+// After Abort, the report service should stop reading the input, print the partial results once and signal DoneChan.
+type Abortable interface {
+	Abort()
+}
+
+// StatusWriter is the optional interface for the report services that can write their live stats on demand,
+// like on SIGUSR1. WriteStatus may be called concurrently with Start.
+type StatusWriter interface {
+	WriteStatus(w io.Writer)
+}
+
+// CriteriaAware is the optional interface for the report services that evaluate the success criteria of the test
+// over the final result. The engine calls SetCriteria before starting the test and CriteriaResult after DoneChan
+// is signaled. CriteriaResult returns nil if the criteria is not evaluated, like in the debug mode.
+type CriteriaAware interface {
+	SetCriteria(c *criteria.Criteria)
+	CriteriaResult() *criteria.Result
+}
+
+// LiveTemplateAware is the optional interface for the report services that render their live summary line by
+// a template of the user. The engine calls SetLiveTemplate before starting the test if the test has a live template.
+type LiveTemplateAware interface {
+	SetLiveTemplate(t *live.Template)
+}
+
+// ClockAware is the optional interface for the report services that follow the time of the test, like the progress
+// and the periodic prints. The engine calls SetClock before initializing the report service.
+type ClockAware interface {
+	SetClock(c clock.Clock)
+}
+
+// HeadlessAware is the optional interface for the report services that run without a terminal, like in the
+// containers. The engine calls SetHeadless with the headless options of the hammer after Init.
+type HeadlessAware interface {
+	SetHeadless(o types.HeadlessOptions) error
+}
+
+// StopLimitAware is the optional interface for the report services that stop the test once the failed request
+// count or the transferred bytes reach the limits. The engine calls SetStopLimits before starting the test, the report
+// service calls stop once from its aggregation and records the stop reason in the final result.
+type StopLimitAware interface {
+	SetStopLimits(l StopLimits, stop func())
+}
+
+// MetricMetaAware is the optional interface for the report services that render the duration and count keys of the
+// steps. The engine calls SetMetricMeta with the metrics declared by the requester of each step after Init.
+type MetricMetaAware interface {
+	SetMetricMeta(meta map[uint16][]types.MetricMeta)
+}
+
+// VerifyAware is the optional interface for the report services that support the verify mode, which runs the
+// scenario serially and reports a pass/fail matrix of the steps instead of the load summary. The engine calls
+// SetVerify with the planned iteration count after Init and VerifyResult after DoneChan is signaled.
+type VerifyAware interface {
+	SetVerify(iterations int)
+	VerifyResult() *VerifyResult
+}
+
+// MetadataAware is the optional interface for the report services that tag their records with the run id, labels
+// and build info of the run. The engine calls SetMetadata after Init.
+type MetadataAware interface {
+	SetMetadata(m types.Metadata)
+}
+
+// ConfigAware is the optional interface for the report services that echo the settings of the run in the report
+// header. The engine calls SetConfig after Init with the planned load and the proxies of the test, unless the echo
+// is disabled.
+type ConfigAware interface {
+	SetConfig(h types.Hammer, proxies []*url.URL)
+}
+
+// RateAware is the optional interface for the report services that record the changes of the dynamic rate of the test.
+// The engine calls RecordRateChange from its poller each time the rate changes, concurrently with Start.
+type RateAware interface {
+	RecordRateChange(c RateChange)
+}
+
+// BurstAware is the optional interface for the report services that report the bursts of the burst load type.
+// The engine calls RecordBurst once a burst is completed or skipped, concurrently with Start.
+type BurstAware interface {
+	RecordBurst(b BurstSummary)
+}
+
+// UserAware is the optional interface for the report services that report the virtual users of the vu load type.
+// The engine calls RecordUser once a user has run its iterations or is stopped, concurrently with Start.
+type UserAware interface {
+	RecordUser(u UserSummary)
+}
+
+// PreflightAware is the optional interface for the report services that print the latencies of the connectivity
+// probes of the targets as the baseline of the report. The engine calls SetPreflight after Init if any target
+// is probed.
+type PreflightAware interface {
+	SetPreflight(probes []PreflightProbe)
+}
+
+// TeardownAware is the optional interface for the report services that report the teardown steps run after the test.
+// The engine calls SetTeardown after the teardown, before the input is closed.
+type TeardownAware interface {
+	SetTeardown(teardown []TeardownSummary)
+}
+
+// GeneratorHealthAware is the optional interface for the report services that report the back-pressure of the result
+// channel. The engine calls SetGeneratorHealth once the iterations are finished, before the input is closed.
+type GeneratorHealthAware interface {
+	SetGeneratorHealth(g GeneratorHealth)
+}
+
+// ScheduleAware is the optional interface for the report services that annotate the timelines with the target rate
+// of the load schedule. The engine calls SetSchedule before starting the test if the test has a schedule.
+type ScheduleAware interface {
+	SetSchedule(s *types.LoadSchedule)
+}
+
+// DrainAware is the optional interface for the report services that display the drain phase of a gracefully stopped
+// test. The engine calls StartDrain with the live progress of the drain once the test is stopped, concurrently with
+// Start, and SetDrain with the summary of the drain before the input is closed.
+type DrainAware interface {
+	StartDrain(progress func() DrainProgress)
+	SetDrain(d DrainSummary)
+}
+
+// LatencySource is the optional interface for the report services that feed the latencies of the steps back to the
+// engine while the test is running, like for the adaptive timeouts. The engine calls RollingP99 from its poller,
+// concurrently with Start. ok is false if the step has no result in the last types.AdaptiveTimeoutWindow.
+type LatencySource interface {
+	RollingP99(stepID uint16) (p99 time.Duration, ok bool)
+}
+
+// PercentilesAware is the optional interface for the report services that report the duration percentiles of the
+// steps. The engine calls SetPercentiles with the sketch of the hammer after Init, before SetScenario.
+type PercentilesAware interface {
+	SetPercentiles(s types.PercentileSketch)
+}
+
+// AnonymizeAware is the optional interface for the report services that anonymize the report before it is written,
+// so it can be shared externally.
+type AnonymizeAware interface {
+	SetAnonymizer(a *Anonymizer)
+}
+
+// BaselineAware is the optional interface for the report services that compare the final result with a baseline run.
+// The engine calls SetBaseline after Init with the baseline checked against the run.
+type BaselineAware interface {
+	SetBaseline(b *Baseline)
+}
+
 // NewReportService is the factory method of the ReportService.
 func NewReportService(s string) (service ReportService, err error) {
 	if val, ok := AvailableOutputServices[s]; ok {
@@ -21,7 +21,10 @@
 package report
 
 import (
+	"reflect"
 	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
 )
 
 func TestNewReportService(t *testing.T) {
@@ -42,3 +45,72 @@ func TestNewReportService(t *testing.T) {
 		t.Errorf("TestNewReportService invalid output should errored")
 	}
 }
+
+// initReport records how it is initialized, it doesn't implement RunInitializer.
+type initReport struct {
+	ReportService
+	debug bool
+}
+
+func (r *initReport) Init(debug bool) error {
+	r.debug = debug
+	return nil
+}
+
+// runInitReport records the run info it is initialized with.
+type runInitReport struct {
+	initReport
+	info *RunInfo
+}
+
+func (r *runInitReport) InitRun(debug bool, info RunInfo) error {
+	r.info = &info
+	return r.Init(debug)
+}
+
+func TestInitReport(t *testing.T) {
+	info := RunInfo{LoadType: types.LoadTypeLinear, Steps: []StepInfo{{ID: 1}}}
+
+	old := &initReport{}
+	if err := InitReport(old, true, info); err != nil || !old.debug {
+		t.Errorf("Report service without InitRun should be initialized by Init, Found %v, %v", err, old.debug)
+	}
+
+	rs := &runInitReport{}
+	if err := InitReport(rs, true, info); err != nil || !rs.debug {
+		t.Errorf("Report service should be initialized, Found %v, %v", err, rs.debug)
+	}
+	if rs.info == nil || !reflect.DeepEqual(*rs.info, info) {
+		t.Errorf("Run info Expected %v, Found %v", info, rs.info)
+	}
+}
+
+func TestNewRunInfo(t *testing.T) {
+	h := types.Hammer{
+		LoadType:       types.LoadTypeWaved,
+		IterationCount: 100,
+		TestDuration:   10,
+		Scenario: types.Scenario{Seed: 42, Steps: []types.ScenarioStep{
+			{ID: 1, Name: "Login", Method: "POST", URL: "https://test.com/login?api_key=abc", SuccessStatus: "200-299",
+				Custom: map[string]interface{}{"json-schema": "login.json"}},
+			{ID: 2, Method: "GET", URL: "https://test.com/{{ .id }}",
+				Custom: map[string]interface{}{"xpath-assertions": []interface{}{"count(//Fault) = 0"}}},
+		}},
+	}
+
+	expected := RunInfo{LoadType: types.LoadTypeWaved, IterationCount: 100, Duration: 10, Seed: 42,
+		Steps: []StepInfo{
+			{ID: 1, Name: "Login", Method: "POST", URL: "https://test.com/login?api_key=***", SuccessStatus: "200-299",
+				JSONSchema: "login.json"},
+			{ID: 2, Method: "GET", URL: "https://test.com/{{ .id }}", XPathAssertions: []string{"count(//Fault) = 0"}},
+		}}
+	info := NewRunInfo(h)
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, info)
+	}
+
+	info.Steps[0].Name = "changed"
+	if h.Scenario.Steps[0].Name != "Login" {
+		t.Errorf("Run info should be a copy of the scenario")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Width of the on/off bars of the bursts in the report
+const burstBarWidth = 20
+
+// BurstSummary is a burst of the burst load type, fired by the engine at its due time.
+type BurstSummary struct {
+	// Order of the burst, starting from 1
+	Index int       `json:"index"`
+	Start time.Time `json:"start"`
+
+	// Iteration count of the burst and the iterations started, less than the size if the test is stopped in the
+	// burst or the burst is skipped
+	Size    int `json:"size"`
+	Started int `json:"started"`
+
+	// Requests of the iterations of the burst and the failed ones
+	Requests int64 `json:"requests"`
+	Failures int64 `json:"failures"`
+
+	// Time between the start of the burst and the end of its last iteration, and the time between the starts of
+	// the bursts, in seconds
+	Completion float32 `json:"completion"`
+	Interval   float32 `json:"interval"`
+
+	// Set if the burst is started while the previous one is still running, or it is not started at all
+	Overlapped bool `json:"overlapped,omitempty"`
+	Skipped    bool `json:"skipped,omitempty"`
+}
+
+// burstHistory collects the bursts recorded by the engine, concurrently with the aggregation.
+type burstHistory struct {
+	mu     sync.Mutex
+	bursts []BurstSummary
+}
+
+func (h *burstHistory) add(b BurstSummary) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bursts = append(h.bursts, b)
+}
+
+// list returns a copy of the bursts in the firing order, nil if there is none.
+// Overlapping bursts may complete out of order, so they are recorded out of order too.
+func (h *burstHistory) list() []BurstSummary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.bursts) == 0 {
+		return nil
+	}
+	bursts := append([]BurstSummary(nil), h.bursts...)
+	sort.SliceStable(bursts, func(i, j int) bool { return bursts[i].Index < bursts[j].Index })
+	return bursts
+}
+
+// setBursts sets the bursts of the result and counts the overlapped and the skipped ones.
+func (r *Result) setBursts(bursts []BurstSummary) {
+	r.Bursts = bursts
+	r.OverlappedBursts, r.SkippedBursts = 0, 0
+	for _, b := range bursts {
+		if b.Overlapped {
+			r.OverlappedBursts++
+		}
+		if b.Skipped {
+			r.SkippedBursts++
+		}
+	}
+}
+
+// printBursts writes the bursts section of the report, w is a tabwriter of the report. The bar of a burst is the part
+// of its interval that it is running, so the rows show the on/off shape of the load.
+func printBursts(w io.Writer, r *Result) {
+	if len(r.Bursts) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Bursts (Start:Running:Iterations:Completion:Failures):")
+	for _, b := range r.Bursts {
+		start := b.Start.Local().Format("15:04:05")
+		if b.Skipped {
+			fmt.Fprintf(w, "  #%d\t%s\t%s\tskipped, the previous burst is still running\n", b.Index, start,
+				progressBar(0, burstBarWidth))
+			continue
+		}
+		var ratio float64
+		if b.Interval > 0 {
+			ratio = float64(b.Completion / b.Interval)
+		}
+		fmt.Fprintf(w, "  #%d\t%s\t%s\t:%d/%d\t:%s\t:%s/%s", b.Index, start, progressBar(ratio, burstBarWidth),
+			b.Started, b.Size, formatDuration(float64(b.Completion)), formatCount(b.Failures), formatCount(b.Requests))
+		if b.Overlapped {
+			fmt.Fprint(w, "\toverlapped")
+		}
+		fmt.Fprintln(w)
+	}
+	if r.OverlappedBursts+r.SkippedBursts > 0 {
+		fmt.Fprintf(w, "  Overlapped: %d, Skipped: %d\n", r.OverlappedBursts, r.SkippedBursts)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,109 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// CallbackBufferSize is the count of the iteration results that the CallbackOutput queues for its callback. The
+// results of the iterations completed while the queue is full are dropped.
+const CallbackBufferSize = 4096
+
+// CallbackOutput passes the step results of the iterations to a callback of a library consumer, without implementing
+// a ReportService. It is set as the types.Hammer.ResultListener of the test, besides the report service of the output.
+//
+// The iterations queue their results without waiting the callback, and a single goroutine calls it for each step
+// result, so the callback is never called concurrently and a slow callback doesn't slow down the test. Once the queue
+// is full, the results of the completed iterations are dropped and counted by Dropped. The step results of an
+// iteration are passed in the order of the steps, the iterations are passed in the order they complete, which is
+// not the order they start.
+//
+// The step results are shared with the report service, and the callback must not change them. A step result that is
+// kept beyond the call should be copied by ScenarioStepResult.Clone.
+type CallbackOutput struct {
+	callback func(*types.ScenarioStepResult)
+	queue    chan *types.ScenarioResult
+	done     chan struct{}
+	dropped  int64
+
+	// Dispatch of the in-flight iterations after Close drops their results
+	mu     sync.RWMutex
+	closed bool
+	start  sync.Once
+}
+
+// NewCallbackOutput returns the output calling the callback for each step result of the test.
+func NewCallbackOutput(callback func(*types.ScenarioStepResult)) *CallbackOutput {
+	return &CallbackOutput{
+		callback: callback,
+		queue:    make(chan *types.ScenarioResult, CallbackBufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// Dispatch queues the step results of the iteration for the callback, they are dropped if the queue is full.
+func (o *CallbackOutput) Dispatch(r *types.ScenarioResult) {
+	o.start.Do(func() { go o.run() })
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		atomic.AddInt64(&o.dropped, int64(len(r.StepResults)))
+		return
+	}
+	select {
+	case o.queue <- r:
+	default:
+		atomic.AddInt64(&o.dropped, int64(len(r.StepResults)))
+	}
+}
+
+// Close stops the queue and waits the callback to complete the queued step results.
+func (o *CallbackOutput) Close() {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return
+	}
+	o.closed = true
+	close(o.queue)
+	o.mu.Unlock()
+
+	o.start.Do(func() { close(o.done) })
+	<-o.done
+}
+
+// Dropped returns the count of the step results dropped since the queue was full or the output was closed.
+func (o *CallbackOutput) Dropped() int64 {
+	return atomic.LoadInt64(&o.dropped)
+}
+
+func (o *CallbackOutput) run() {
+	defer close(o.done)
+	for r := range o.queue {
+		for _, sr := range r.StepResults {
+			o.callback(sr)
+		}
+	}
+}
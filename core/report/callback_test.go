@@ -0,0 +1,110 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func iterationResult(id uint64, steps ...uint16) *types.ScenarioResult {
+	r := &types.ScenarioResult{}
+	for _, s := range steps {
+		r.StepResults = append(r.StepResults, &types.ScenarioStepResult{StepID: s, IterationID: id})
+	}
+	return r
+}
+
+func TestCallbackOutput(t *testing.T) {
+	t.Parallel()
+
+	var received []uint16
+	o := NewCallbackOutput(func(sr *types.ScenarioStepResult) {
+		received = append(received, sr.StepID)
+	})
+	o.Dispatch(iterationResult(0, 1, 2, 3))
+	o.Dispatch(iterationResult(1, 1, 2, 3))
+	o.Close()
+
+	// Callback is complete once Close returns
+	if expected := []uint16{1, 2, 3, 1, 2, 3}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected %v, Found %v", expected, received)
+	}
+
+	// Results of the in-flight iterations after Close are dropped
+	o.Dispatch(iterationResult(2, 1, 2))
+	o.Close()
+	if o.Dropped() != 2 || len(received) != 6 {
+		t.Errorf("Expected 2 dropped results, Found %d dropped and %v", o.Dropped(), received)
+	}
+}
+
+func TestCallbackOutputSlowCallback(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	received := 0
+	o := NewCallbackOutput(func(sr *types.ScenarioStepResult) {
+		<-release
+		mu.Lock()
+		received++
+		mu.Unlock()
+	})
+
+	// The callback blocks on the first result, the queue takes CallbackBufferSize more iterations
+	start := time.Now()
+	o.Dispatch(iterationResult(0, 1))
+	for len(o.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i <= CallbackBufferSize+10; i++ {
+		o.Dispatch(iterationResult(uint64(i), 1, 2))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Dispatch should not wait the callback, took %v", elapsed)
+	}
+	if o.Dropped() != 20 {
+		t.Errorf("Expected 20 dropped results, Found %d", o.Dropped())
+	}
+
+	close(release)
+	o.Close()
+	if received != 1+2*CallbackBufferSize {
+		t.Errorf("Expected %d received results, Found %d", 1+2*CallbackBufferSize, received)
+	}
+}
+
+func TestCallbackOutputCloseWithoutResults(t *testing.T) {
+	t.Parallel()
+
+	o := NewCallbackOutput(func(sr *types.ScenarioStepResult) {
+		t.Errorf("Callback should not be called")
+	})
+	o.Close()
+	o.Dispatch(iterationResult(0, 1))
+	if o.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped result, Found %d", o.Dropped())
+	}
+}
@@ -0,0 +1,123 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Days to the expiry of a certificate under which it is printed as a warning and as critical.
+const (
+	certificateWarningDays  = 30
+	certificateCriticalDays = 7
+)
+
+var yellow = color.New(color.FgHiYellow).SprintFunc()
+
+// CertificateSummary is the leaf certificate of a TLS target host, read from the first connection to the host.
+type CertificateSummary struct {
+	Host        string    `json:"host" anonymize:"host"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotAfter    time.Time `json:"not_after"`
+	SANs        []string  `json:"sans,omitempty" anonymize:"host"`
+	ChainLength int       `json:"chain_length"`
+
+	// Whole days from the first connection to the expiry, negative if the certificate is expired
+	DaysLeft int `json:"days_left"`
+}
+
+type seenCertificate struct {
+	cert types.Certificate
+	at   time.Time
+}
+
+// certificateTracker keeps the certificate of the earliest request of each host, the requesters report a host once
+// per step.
+type certificateTracker map[string]seenCertificate
+
+func (t certificateTracker) add(c types.Certificate, at time.Time) {
+	if s, ok := t[c.Host]; !ok || at.Before(s.at) {
+		t[c.Host] = seenCertificate{cert: c, at: at}
+	}
+}
+
+func (t certificateTracker) merge(o certificateTracker) {
+	for _, s := range o {
+		t.add(s.cert, s.at)
+	}
+}
+
+// summary returns the certificates sorted by the hosts, nil if no TLS host is connected.
+func (t certificateTracker) summary() []CertificateSummary {
+	if len(t) == 0 {
+		return nil
+	}
+	summaries := make([]CertificateSummary, 0, len(t))
+	for _, s := range t {
+		summaries = append(summaries, CertificateSummary{
+			Host:        s.cert.Host,
+			Subject:     s.cert.Subject,
+			Issuer:      s.cert.Issuer,
+			NotAfter:    s.cert.NotAfter,
+			SANs:        s.cert.SANs,
+			ChainLength: s.cert.ChainLength,
+			DaysLeft:    int(math.Floor(s.cert.NotAfter.Sub(s.at).Hours() / 24)),
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Host < summaries[j].Host })
+	return summaries
+}
+
+// printCertificates writes a line for the certificate of each host in the report header, w is a tabwriter of the
+// report. The certificates close to their expiry are colored as warnings.
+func printCertificates(w io.Writer, certs []CertificateSummary) {
+	for _, c := range certs {
+		expiry := fmt.Sprintf("in %d days", c.DaysLeft)
+		if c.DaysLeft < 0 {
+			expiry = fmt.Sprintf("expired %d days ago", -c.DaysLeft)
+		}
+		line := fmt.Sprintf("TLS Certificate: %s (%s) issued by %s, expires %s (%s)", c.Host, c.Subject, c.Issuer,
+			c.NotAfter.UTC().Format("2006-01-02"), expiry)
+		switch {
+		case c.DaysLeft < certificateCriticalDays:
+			line = red(line) + reportColor()
+		case c.DaysLeft < certificateWarningDays:
+			line = yellow(line) + reportColor()
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// reportColor returns the escape code of the color of the report blocks, so the rest of a block is colored as
+// before once a warning in it resets the color.
+func reportColor() string {
+	if color.NoColor {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[%dm", color.FgHiCyan)
+}
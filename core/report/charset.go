@@ -0,0 +1,81 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import "github.com/enescakir/emoji"
+
+// charset is the set of the symbols used in the terminal outputs.
+type charset struct {
+	// ASCII replacements of the emoji, nil if the emoji are printed as is
+	icons map[emoji.Emoji]string
+
+	barFull    string
+	barEmpty   string
+	sparkTicks []rune
+}
+
+var unicodeCharset = charset{
+	barFull:    "█",
+	barEmpty:   "░",
+	sparkTicks: []rune("▁▂▃▄▅▆▇█"),
+}
+
+// asciiCharset is the plain-text mode of the consoles that can't render emoji and block elements,
+// like the legacy Windows console.
+var asciiCharset = charset{
+	icons: map[emoji.Emoji]string{
+		emoji.Gear:             "*",
+		emoji.Fire:             "*",
+		emoji.StopSign:         "!",
+		emoji.HourglassNotDone: "~",
+		emoji.CheckMark:        "+",
+		emoji.CrossMark:        "x",
+		emoji.Stopwatch:        "@",
+		emoji.Eye:              "*",
+		emoji.Bug:              "*",
+		emoji.Warning:          "!",
+		emoji.SosButton:        "!",
+	},
+	barFull:    "#",
+	barEmpty:   ".",
+	sparkTicks: []rune("_.,:-=+#"),
+}
+
+// symbols is the charset of the current console.
+var symbols = detectCharset()
+
+func detectCharset() charset {
+	if unicodeConsole() {
+		return unicodeCharset
+	}
+	return asciiCharset
+}
+
+// icon returns the emoji or its ASCII replacement in the plain-text mode.
+func (c charset) icon(e emoji.Emoji) string {
+	if c.icons == nil {
+		return e.String()
+	}
+	if r, ok := c.icons[e]; ok {
+		return r
+	}
+	return "*"
+}
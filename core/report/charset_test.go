@@ -0,0 +1,80 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"testing"
+
+	"github.com/enescakir/emoji"
+)
+
+// useCharset sets the charset of the outputs until the end of the test.
+func useCharset(t *testing.T, c charset) {
+	realSymbols := symbols
+	symbols = c
+	t.Cleanup(func() {
+		symbols = realSymbols
+	})
+}
+
+func TestCharsetIcon(t *testing.T) {
+	tests := []struct {
+		name     string
+		charset  charset
+		emoji    emoji.Emoji
+		expected string
+	}{
+		{"Unicode", unicodeCharset, emoji.CheckMark, emoji.CheckMark.String()},
+		{"ASCII", asciiCharset, emoji.CheckMark, "+"},
+		{"ASCIIUnknown", asciiCharset, emoji.Rocket, "*"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			if icon := test.charset.icon(test.emoji); icon != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, icon)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestCharsetPlainTextIsASCII(t *testing.T) {
+	symbols := []string{asciiCharset.barFull, asciiCharset.barEmpty, string(asciiCharset.sparkTicks)}
+	for _, s := range asciiCharset.icons {
+		symbols = append(symbols, s)
+	}
+
+	for _, s := range symbols {
+		for _, r := range s {
+			if r > 127 {
+				t.Errorf("Plain-text symbol %q is not ASCII", s)
+			}
+		}
+	}
+}
+
+func TestDetectCharsetDumbTerminal(t *testing.T) {
+	t.Setenv("TERM", "dumb")
+	if c := detectCharset(); c.icons == nil {
+		t.Errorf("Dumb terminals should use the plain-text mode")
+	}
+}
@@ -0,0 +1,41 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"go.ddosify.com/ddosify/core/clock"
+)
+
+// reportClock is the clock of a report service, set by the engine through ClockAware. The real clock is used if it is
+// not set.
+type reportClock struct {
+	c clock.Clock
+}
+
+func (r *reportClock) SetClock(c clock.Clock) {
+	r.c = c
+}
+
+func (r *reportClock) timeSource() clock.Clock {
+	if r.c == nil {
+		return clock.Real()
+	}
+	return r.c
+}
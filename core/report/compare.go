@@ -0,0 +1,362 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// CompareTolerance is the relative difference of the iteration count, the duration and the rates of the load up to
+// which a run is comparable with its baseline.
+var CompareTolerance = 0.1
+
+// Baseline is a previous run exported by the stdout-json output, the run of the -compare flag is compared with it.
+type Baseline struct {
+	Path string `json:"-"`
+
+	// Settings of the baseline run, nil if it is exported without the config echo
+	Config *ConfigEcho `json:"config"`
+
+	SuccessCount int64                   `json:"success_count"`
+	FailedCount  int64                   `json:"fail_count"`
+	AvgDuration  float32                 `json:"avg_duration"`
+	Steps        map[uint16]BaselineStep `json:"steps"`
+
+	// Settings of the run that differ from the baseline, set by Check
+	differences []BaselineDifference
+}
+
+// BaselineStep is the result of a step of the baseline run, the durations are keyed by their JSON keys.
+type BaselineStep struct {
+	Name         string             `json:"name"`
+	SuccessCount int64              `json:"success_count"`
+	FailedCount  int64              `json:"fail_count"`
+	Durations    map[string]float32 `json:"durations"`
+	Percentiles  *PercentileSummary `json:"percentiles"`
+}
+
+// BaselineDifference is a setting of the run that differs from its baseline. Field is the key of the setting in the
+// config of the JSON report, like rate or steps[1].url.
+type BaselineDifference struct {
+	Field    string `json:"field"`
+	Baseline string `json:"baseline"`
+	Current  string `json:"current"`
+
+	// Id of the step of the setting, 0 for the load and the step set of the test
+	Step uint16 `json:"step,omitempty"`
+}
+
+func (d BaselineDifference) String() string {
+	return fmt.Sprintf("%s: %s in the baseline, %s in the run", d.Field, d.Baseline, d.Current)
+}
+
+// IncompatibleBaselineError is returned for a run whose load or steps differ from its baseline, so the deltas would
+// be of the settings rather than of the target.
+type IncompatibleBaselineError struct {
+	Path        string
+	Differences []BaselineDifference
+}
+
+func (e *IncompatibleBaselineError) Error() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "run is not comparable with the baseline %s:", e.Path)
+	for _, d := range e.Differences {
+		fmt.Fprintf(&b, "\n  %s", d)
+	}
+	b.WriteString("\nuse -force_compare to compare them anyway")
+	return b.String()
+}
+
+// LoadBaseline reads the JSON report of the baseline run at path.
+func LoadBaseline(path string) (*Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{Path: path}
+	if err = json.Unmarshal(content, b); err != nil {
+		return nil, fmt.Errorf("baseline %s is not a json report: %v", path, err)
+	}
+	return b, nil
+}
+
+// Check compares the load and the steps of the hammer with the baseline. A run that differs is refused with an
+// IncompatibleBaselineError unless force is set, the differences are reported with the deltas then.
+func (b *Baseline) Check(h types.Hammer, force bool) error {
+	b.differences = b.diff(newConfigEcho(h, nil))
+	if len(b.differences) > 0 && !force {
+		return &IncompatibleBaselineError{Path: b.Path, Differences: b.differences}
+	}
+	return nil
+}
+
+// diff returns the settings of the load and the steps of c that differ from the baseline. The counts, the durations
+// and the rates are compared within CompareTolerance, the step set is compared exactly.
+func (b *Baseline) diff(c *ConfigEcho) (diffs []BaselineDifference) {
+	base := b.Config
+	if base == nil {
+		return []BaselineDifference{{Field: "config", Baseline: "missing", Current: "present"}}
+	}
+	add := func(field string, step uint16, baseline, current string) {
+		diffs = append(diffs, BaselineDifference{Field: field, Baseline: baseline, Current: current, Step: step})
+	}
+
+	// The dynamic rate overrides the load type and the iteration count, its source and bounds are the load shape
+	if base.DynamicRate != nil || c.DynamicRate != nil {
+		if bd, cd := base.DynamicRate, c.DynamicRate; bd == nil || cd == nil || bd.Source != cd.Source ||
+			bd.Query != cd.Query || !withinTolerance(bd.Min, cd.Min) || !withinTolerance(bd.Max, cd.Max) {
+			add("dynamic_rate", 0, formatEchoDynamicRate(bd), formatEchoDynamicRate(cd))
+		}
+	} else {
+		if base.LoadType != c.LoadType {
+			add("load_type", 0, base.LoadType, c.LoadType)
+		}
+		if !withinTolerance(float64(base.IterationCount), float64(c.IterationCount)) {
+			add("iteration_count", 0, strconv.Itoa(base.IterationCount), strconv.Itoa(c.IterationCount))
+		}
+		if !withinTolerance(base.Rate, c.Rate) {
+			add("rate", 0, formatEchoRate(base.Rate), formatEchoRate(c.Rate))
+		}
+	}
+	if !withinTolerance(float64(base.Duration), float64(c.Duration)) {
+		add("duration", 0, fmt.Sprintf("%ds", base.Duration), fmt.Sprintf("%ds", c.Duration))
+	}
+	if !sameStages(base.Stages, c.Stages) {
+		add("stages", 0, formatStages(base.Stages), formatStages(c.Stages))
+	}
+	if bb, cb := base.Burst, c.Burst; (bb == nil) != (cb == nil) || bb != nil && (bb.Policy != cb.Policy ||
+		!withinTolerance(float64(bb.Size), float64(cb.Size)) || !withinTolerance(bb.Interval, cb.Interval)) {
+		add("burst", 0, formatEchoBurst(bb), formatEchoBurst(cb))
+	}
+	if bv, cv := base.VirtualUsers, c.VirtualUsers; (bv == nil) != (cv == nil) || bv != nil &&
+		(!withinTolerance(float64(bv.Users), float64(cv.Users)) ||
+			!withinTolerance(float64(bv.IterationsPerUser), float64(cv.IterationsPerUser))) {
+		add("vu", 0, formatEchoUsers(bv), formatEchoUsers(cv))
+	}
+
+	if len(base.Steps) != len(c.Steps) {
+		add("steps", 0, fmt.Sprintf("%d steps", len(base.Steps)), fmt.Sprintf("%d steps", len(c.Steps)))
+	}
+	for i := 0; i < len(base.Steps) && i < len(c.Steps); i++ {
+		bs, s := base.Steps[i], c.Steps[i]
+		path := fmt.Sprintf("steps[%d]", i)
+		if bs.ID != s.ID {
+			add(path+".id", s.ID, strconv.Itoa(int(bs.ID)), strconv.Itoa(int(s.ID)))
+		}
+		if bs.Method != s.Method {
+			add(path+".method", s.ID, bs.Method, s.Method)
+		}
+		if bs.URL != s.URL {
+			add(path+".url", s.ID, bs.URL, s.URL)
+		}
+	}
+	return diffs
+}
+
+func withinTolerance(baseline, current float64) bool {
+	return math.Abs(current-baseline) <= CompareTolerance*math.Max(math.Abs(baseline), math.Abs(current))
+}
+
+func sameStages(baseline, current []ConfigEchoStage) bool {
+	if len(baseline) != len(current) {
+		return false
+	}
+	for i, s := range current {
+		if s.Duration != baseline[i].Duration || !withinTolerance(float64(baseline[i].Count), float64(s.Count)) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatEchoRate(rate float64) string {
+	return fmt.Sprintf("%.1f iterations/s", rate)
+}
+
+func formatEchoDynamicRate(d *ConfigEchoDynamicRate) string {
+	if d == nil {
+		return "none"
+	}
+	s := fmt.Sprintf("%s, %g-%g iterations/s", d.Source, d.Min, d.Max)
+	if d.Query != "" {
+		s += " of " + d.Query
+	}
+	return s
+}
+
+func formatEchoBurst(b *ConfigEchoBurst) string {
+	if b == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%d iterations every %gs (policy: %s)", b.Size, b.Interval, b.Policy)
+}
+
+func formatEchoUsers(v *ConfigEchoVirtualUsers) string {
+	if v == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%d users, %d iterations each", v.Users, v.IterationsPerUser)
+}
+
+// Comparison is the result of the run compared with its baseline.
+type Comparison struct {
+	Baseline string `json:"baseline"`
+
+	// Settings of the run that differ from the baseline, set if the comparison is forced
+	Differences []BaselineDifference `json:"differences,omitempty"`
+
+	Deltas []MetricDelta `json:"deltas"`
+}
+
+// MetricDelta is a metric of the run and of its baseline. Change is in percentage points for the success percentages
+// and in percents of the baseline for the durations.
+type MetricDelta struct {
+	// Id of the step of the metric, 0 for the total of the test
+	Step     uint16  `json:"step,omitempty"`
+	Metric   string  `json:"metric"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	Change   float64 `json:"change"`
+
+	// Fields of the differences from the baseline that may explain the change
+	ExplainedBy []string `json:"explained_by,omitempty"`
+}
+
+// Names of the metrics of the comparison in the stdout report
+var comparisonMetrics = map[string]string{
+	"success_perc": "Success %",
+	"avg_duration": "Avg. Duration",
+	"p50":          "P50",
+	"p95":          "P95",
+	"p99":          "P99",
+}
+
+// compare returns the deltas of the result from the baseline, the steps are matched by their ids. The result has
+// the raw duration keys, before they are renamed for the JSON report.
+func (b *Baseline) compare(r *Result) *Comparison {
+	c := &Comparison{Baseline: b.Path, Differences: b.differences}
+	add := func(step uint16, metric string, baseline, current float64) {
+		d := MetricDelta{Step: step, Metric: metric, Baseline: baseline, Current: current,
+			ExplainedBy: b.explaining(step)}
+		if metric == "success_perc" {
+			d.Change = current - baseline
+		} else {
+			// Durations keep the microseconds, like the percentiles of the JSON report
+			d.Baseline, d.Current = math.Round(baseline*1e6)/1e6, math.Round(current*1e6)/1e6
+			if baseline > 0 {
+				d.Change = (current - baseline) / baseline * 100
+			}
+		}
+		d.Change = math.Round(d.Change*10) / 10
+		c.Deltas = append(c.Deltas, d)
+	}
+
+	add(0, "success_perc", successPerc(b.SuccessCount, b.FailedCount), successPerc(r.SuccessCount, r.FailedCount))
+	add(0, "avg_duration", float64(b.AvgDuration), float64(r.AvgDuration))
+
+	ids := make([]int, 0, len(r.StepResults))
+	for id, s := range r.StepResults {
+		if _, ok := b.Steps[id]; ok && s.hasResults() {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+	for _, i := range ids {
+		id := uint16(i)
+		bs, s := b.Steps[id], r.StepResults[id]
+		add(id, "success_perc", successPerc(bs.SuccessCount, bs.FailedCount),
+			successPerc(s.SuccessCount, s.FailedCount))
+		add(id, "avg_duration", float64(bs.Durations["total"]), float64(s.Durations[totalDurationKey]))
+		if bp, p := bs.Percentiles, s.Percentiles; bp != nil && p != nil {
+			add(id, "p50", float64(bp.P50), float64(p.P50))
+			add(id, "p95", float64(bp.P95), float64(p.P95))
+			add(id, "p99", float64(bp.P99), float64(p.P99))
+		}
+	}
+	return c
+}
+
+// explaining returns the fields of the differences that may explain the deltas of the step, the differences of
+// the load explain all the deltas and the differences of the steps explain the total.
+func (b *Baseline) explaining(step uint16) (fields []string) {
+	for _, d := range b.differences {
+		if step == 0 || d.Step == 0 || d.Step == step {
+			fields = append(fields, d.Field)
+		}
+	}
+	return fields
+}
+
+func successPerc(success, failed int64) float64 {
+	if success+failed == 0 {
+		return 0
+	}
+	return math.Round(float64(success)/float64(success+failed)*1000) / 10
+}
+
+// printComparison writes the deltas of the run from its baseline, w is a tabwriter of the report.
+func printComparison(w io.Writer, r *Result) {
+	c := r.Comparison
+	fmt.Fprintf(w, "Comparison with the baseline %s:\n", c.Baseline)
+	if len(c.Differences) > 0 {
+		fmt.Fprintln(w, "  Forced, the run differs from the baseline in:")
+		for _, d := range c.Differences {
+			fmt.Fprintf(w, "    %s\n", d)
+		}
+		fmt.Fprintln(w, "  Metric\tBaseline\tCurrent\tChange\tMay Be Explained By")
+	} else {
+		fmt.Fprintln(w, "  Metric\tBaseline\tCurrent\tChange")
+	}
+	for _, d := range c.Deltas {
+		metric := "Total " + comparisonMetrics[d.Metric]
+		if d.Step != 0 {
+			step := "Step " + strconv.Itoa(int(d.Step))
+			if s, ok := r.StepResults[d.Step]; ok && s.Name != "" {
+				step = s.Name
+			}
+			metric = fmt.Sprintf("%d. %s %s", d.Step, step, comparisonMetrics[d.Metric])
+		}
+		baseline, current := formatDuration(d.Baseline), formatDuration(d.Current)
+		change := fmt.Sprintf("%+.1f%%", d.Change)
+		if d.Metric == "success_perc" {
+			baseline, current = fmt.Sprintf("%.1f%%", d.Baseline), fmt.Sprintf("%.1f%%", d.Current)
+			change = fmt.Sprintf("%+.1f pts", d.Change)
+		}
+		line := fmt.Sprintf("  %s\t%s\t%s\t%s", metric, baseline, current, change)
+		if len(c.Differences) > 0 {
+			explained := "-"
+			if len(d.ExplainedBy) > 0 {
+				explained = strings.Join(d.ExplainedBy, ", ")
+			}
+			line += "\t" + explained
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w)
+}
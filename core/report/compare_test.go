@@ -0,0 +1,150 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestBaselineDiff(t *testing.T) {
+	step := ConfigEchoStep{ID: 1, Method: "GET", URL: "https://test.com/{{_randomInt}}"}
+	smoke := ConfigEcho{LoadType: types.LoadTypeLinear, IterationCount: 1000, Duration: 10, Rate: 100,
+		Steps: []ConfigEchoStep{step}}
+
+	tests := []struct {
+		name     string
+		baseline *ConfigEcho
+		change   func(c *ConfigEcho)
+		expected []BaselineDifference
+	}{
+		{"Same", &smoke, func(c *ConfigEcho) {}, nil},
+		{"WithinTolerance", &smoke, func(c *ConfigEcho) { c.IterationCount, c.Rate = 1050, 105 }, nil},
+		{"Stress", &smoke, func(c *ConfigEcho) { c.IterationCount, c.Rate = 50000, 5000 }, []BaselineDifference{
+			{Field: "iteration_count", Baseline: "1000", Current: "50000"},
+			{Field: "rate", Baseline: "100.0 iterations/s", Current: "5000.0 iterations/s"},
+		}},
+		{"LoadType", &smoke, func(c *ConfigEcho) { c.LoadType = types.LoadTypeIncremental }, []BaselineDifference{
+			{Field: "load_type", Baseline: "linear", Current: "incremental"},
+		}},
+		{"Duration", &smoke, func(c *ConfigEcho) { c.Duration = 20 }, []BaselineDifference{
+			{Field: "duration", Baseline: "10s", Current: "20s"},
+		}},
+		{"Stages", &smoke, func(c *ConfigEcho) {
+			c.Stages = []ConfigEchoStage{{Duration: 5, Count: 200}, {Duration: 5, Count: 800}}
+		}, []BaselineDifference{
+			{Field: "stages", Baseline: "none", Current: "200 in 5s, 800 in 5s"},
+		}},
+		{"DynamicRate", &smoke, func(c *ConfigEcho) {
+			c.DynamicRate = &ConfigEchoDynamicRate{Source: "https://rate.test.com", Min: 1, Max: 50}
+		}, []BaselineDifference{
+			{Field: "dynamic_rate", Baseline: "none", Current: "https://rate.test.com, 1-50 iterations/s"},
+		}},
+		{"StepURL", &smoke, func(c *ConfigEcho) {
+			c.Steps = []ConfigEchoStep{{ID: 1, Method: "POST", URL: "https://test.com/login"}}
+		}, []BaselineDifference{
+			{Field: "steps[0].method", Baseline: "GET", Current: "POST", Step: 1},
+			{Field: "steps[0].url", Baseline: "https://test.com/{{_randomInt}}", Current: "https://test.com/login",
+				Step: 1},
+		}},
+		{"StepAdded", &smoke, func(c *ConfigEcho) {
+			c.Steps = []ConfigEchoStep{step, {ID: 2, Method: "GET", URL: "https://test.com/cart"}}
+		}, []BaselineDifference{
+			{Field: "steps", Baseline: "1 steps", Current: "2 steps"},
+		}},
+		{"NoConfig", nil, func(c *ConfigEcho) {}, []BaselineDifference{
+			{Field: "config", Baseline: "missing", Current: "present"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := smoke
+			c.Steps = append([]ConfigEchoStep(nil), smoke.Steps...)
+			test.change(&c)
+			b := &Baseline{Config: test.baseline}
+			if diffs := b.diff(&c); !reflect.DeepEqual(diffs, test.expected) {
+				t.Errorf("Expected %v, Found %v", test.expected, diffs)
+			}
+		})
+	}
+}
+
+func TestBaselineCheck(t *testing.T) {
+	path := t.TempDir() + "/baseline.json"
+	content := `{"success_perc":100,"success_count":1000,"fail_count":0,"avg_duration":0.1,"config":{` +
+		`"load_type":"linear","iteration_count":1000,"duration":10,"rate":100,` +
+		`"steps":[{"id":1,"method":"GET","url":"https://test.com"}]},` +
+		`"steps":{"1":{"name":"Home","success_count":1000,"fail_count":0,"durations":{"total":0.1}}}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("TestBaselineCheck errored: %v", err)
+	}
+
+	h := types.Hammer{LoadType: types.LoadTypeLinear, IterationCount: 50000, TestDuration: 10,
+		Scenario: types.Scenario{Steps: []types.ScenarioStep{{ID: 1, Method: "GET", URL: "https://test.com"}}}}
+	err = b.Check(h, false)
+	if _, ok := err.(*IncompatibleBaselineError); !ok {
+		t.Fatalf("Expected IncompatibleBaselineError, Found %v", err)
+	}
+	if err = b.Check(h, true); err != nil {
+		t.Fatalf("Forced check errored: %v", err)
+	}
+
+	r := &Result{SuccessCount: 900, FailedCount: 100, AvgDuration: 0.25,
+		StepResults: map[uint16]*ScenarioStepResultSummary{
+			1: {Name: "Home", SuccessCount: 900, FailedCount: 100, Durations: map[string]float32{"duration": 0.25}},
+		}}
+	explained := []string{"iteration_count", "rate"}
+	expected := &Comparison{Baseline: path, Differences: b.differences, Deltas: []MetricDelta{
+		{Metric: "success_perc", Baseline: 100, Current: 90, Change: -10, ExplainedBy: explained},
+		{Metric: "avg_duration", Baseline: 0.1, Current: 0.25, Change: 150, ExplainedBy: explained},
+		{Step: 1, Metric: "success_perc", Baseline: 100, Current: 90, Change: -10, ExplainedBy: explained},
+		{Step: 1, Metric: "avg_duration", Baseline: 0.1, Current: 0.25, Change: 150, ExplainedBy: explained},
+	}}
+	r.Comparison = b.compare(r)
+	if !reflect.DeepEqual(r.Comparison, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, r.Comparison)
+	}
+
+	out := strings.Builder{}
+	w := tabwriter.NewWriter(&out, 0, 0, 4, ' ', 0)
+	printComparison(w, r)
+	w.Flush()
+	for _, line := range []string{
+		"rate: 100.0 iterations/s in the baseline, 5000.0 iterations/s in the run",
+		"1. Home Avg. Duration",
+		"+150.0%",
+		"-10.0 pts",
+		"iteration_count, rate",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %q in the comparison, Found: %s", line, out.String())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"net/http"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// ConditionalSummary is the revalidation of the responses of a step with conditional_requests. The first request of
+// a virtual user to a URL has no validator yet, it is counted as unconditional.
+type ConditionalSummary struct {
+	ConditionalCount   int64 `json:"conditional_count"`
+	UnconditionalCount int64 `json:"unconditional_count"`
+	NotModifiedCount   int64 `json:"not_modified_count"`
+
+	// 304 responses over the conditional requests, in [0, 1]
+	NotModifiedRate float32 `json:"not_modified_rate"`
+
+	// Durations of the 304 and the 200 responses of the step, apart since a 304 response has no body to send
+	NotModified StatusDurations `json:"not_modified"`
+	Full        StatusDurations `json:"full"`
+}
+
+// StatusDurations are the durations of the responses of a status code in seconds.
+type StatusDurations struct {
+	Count int64   `json:"count"`
+	Avg   float32 `json:"avg"`
+	P50   float32 `json:"p50"`
+	P95   float32 `json:"p95"`
+}
+
+// conditionalTracker counts the conditional requests of a step, created by the first request of a step with
+// conditional_requests.
+type conditionalTracker struct {
+	conditional, unconditional int64
+	notModified, full          *statusTracker
+}
+
+// statusTracker is the durations of the responses of a status code
+type statusTracker struct {
+	sum  time.Duration
+	hist *histogram
+}
+
+func newConditionalTracker() *conditionalTracker {
+	return &conditionalTracker{
+		notModified: &statusTracker{hist: newHistogram()},
+		full:        &statusTracker{hist: newHistogram()},
+	}
+}
+
+func (t *conditionalTracker) add(sr *types.ScenarioStepResult, conditional bool) {
+	if conditional {
+		t.conditional++
+	} else {
+		t.unconditional++
+	}
+	switch sr.StatusCode {
+	case http.StatusNotModified:
+		t.notModified.add(sr.Duration)
+	case http.StatusOK:
+		t.full.add(sr.Duration)
+	}
+}
+
+func (t *conditionalTracker) merge(o *conditionalTracker) {
+	t.conditional += o.conditional
+	t.unconditional += o.unconditional
+	t.notModified.merge(o.notModified)
+	t.full.merge(o.full)
+}
+
+// summary returns the summary of the conditional requests, nil if the step has no conditional_requests.
+func (t *conditionalTracker) summary() *ConditionalSummary {
+	if t == nil {
+		return nil
+	}
+	s := &ConditionalSummary{
+		ConditionalCount:   t.conditional,
+		UnconditionalCount: t.unconditional,
+		NotModifiedCount:   t.notModified.hist.total,
+		NotModified:        t.notModified.summary(),
+		Full:               t.full.summary(),
+	}
+	if t.conditional > 0 {
+		s.NotModifiedRate = float32(float64(s.NotModifiedCount) / float64(t.conditional))
+	}
+	return s
+}
+
+func (t *statusTracker) add(d time.Duration) {
+	t.sum += d
+	t.hist.add(d)
+}
+
+func (t *statusTracker) merge(o *statusTracker) {
+	t.sum += o.sum
+	t.hist.merge(o.hist)
+}
+
+func (t *statusTracker) summary() StatusDurations {
+	s := StatusDurations{Count: t.hist.total}
+	if s.Count > 0 {
+		s.Avg = float32((t.sum / time.Duration(s.Count)).Seconds())
+		s.P50 = float32(t.hist.percentile(50).Seconds())
+		s.P95 = float32(t.hist.percentile(95).Seconds())
+	}
+	return s
+}
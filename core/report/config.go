@@ -0,0 +1,268 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// ConfigEcho is the summary of the settings of the run printed in the report header, so a shared report tells
+// the load it is produced with. The fields with the secret tags are redacted by types.Redact, the anonymize tags are
+// used by the Anonymizer of an anonymized report.
+type ConfigEcho struct {
+	LoadType       string `json:"load_type"`
+	IterationCount int    `json:"iteration_count"`
+	Duration       int    `json:"duration"`
+
+	// Average iterations per second of the planned load
+	Rate float64 `json:"rate"`
+
+	// Stages of the manual load in their order
+	Stages []ConfigEchoStage `json:"stages,omitempty"`
+
+	DynamicRate *ConfigEchoDynamicRate `json:"dynamic_rate,omitempty"`
+	Burst       *ConfigEchoBurst       `json:"burst,omitempty"`
+	Proxies     []string               `json:"proxies,omitempty" secret:"url" anonymize:"url"`
+	Steps       []ConfigEchoStep       `json:"steps"`
+
+	SuccessCriteria   string `json:"success_criteria,omitempty"`
+	StopAfterFailures int    `json:"stop_after_failures,omitempty"`
+	MaxTransfer       int64  `json:"max_transfer,omitempty"`
+
+	AbortRules []string `json:"abort_rules,omitempty"`
+
+	VirtualUsers *ConfigEchoVirtualUsers `json:"vu,omitempty"`
+}
+
+// ConfigEchoStage is the duration in seconds and the iteration count of a stage of the manual load.
+type ConfigEchoStage struct {
+	Duration int `json:"duration"`
+	Count    int `json:"count"`
+}
+
+// ConfigEchoDynamicRate is the source and the bounds of the dynamic rate of the run.
+type ConfigEchoDynamicRate struct {
+	Source string  `json:"source" secret:"url" anonymize:"url"`
+	Query  string  `json:"query,omitempty"`
+	Min    float64 `json:"min,omitempty"`
+	Max    float64 `json:"max,omitempty"`
+}
+
+// ConfigEchoBurst is the size, the interval in seconds and the planned count of the bursts of the run.
+type ConfigEchoBurst struct {
+	Size        int     `json:"size"`
+	Interval    float64 `json:"interval"`
+	Count       int     `json:"count"`
+	MaxInFlight int     `json:"max_in_flight,omitempty"`
+	Policy      string  `json:"policy"`
+}
+
+// ConfigEchoVirtualUsers is the user count and the iteration count of each user of the vu load type.
+type ConfigEchoVirtualUsers struct {
+	Users             int `json:"users"`
+	IterationsPerUser int `json:"iterations_per_user"`
+}
+
+// ConfigEchoStep is the request template of a step, the dynamic variables are not rendered.
+type ConfigEchoStep struct {
+	ID      uint16            `json:"id"`
+	Name    string            `json:"name,omitempty"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url" secret:"url" anonymize:"url"`
+	Headers map[string]string `json:"headers,omitempty" secret:"headers" anonymize:"headers"`
+	// Headers sent in a header line per value
+	RepeatedHeaders map[string][]string `json:"repeated_headers,omitempty" secret:"headers" anonymize:"headers"`
+	AuthType        string              `json:"auth_type,omitempty"`
+	AuthUsername    string              `json:"auth_username,omitempty"`
+	AuthPassword    string              `json:"auth_password,omitempty" secret:"true"`
+	Timeout         int                 `json:"timeout"`
+	ParallelGroup   string              `json:"parallel_group,omitempty"`
+	Proxy           string              `json:"proxy,omitempty" secret:"url" anonymize:"url"`
+
+	// Names or ids of the steps the step runs after
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+func newConfigEcho(h types.Hammer, proxies []*url.URL) *ConfigEcho {
+	c := &ConfigEcho{
+		LoadType:          h.LoadType,
+		IterationCount:    h.IterationCount,
+		Duration:          h.TestDuration,
+		SuccessCriteria:   h.SuccessCriteria,
+		StopAfterFailures: h.StopAfterFailures,
+		MaxTransfer:       h.MaxTransfer,
+		AbortRules:        h.AbortRules,
+	}
+	if h.TestDuration > 0 && h.VirtualUsers == nil {
+		c.Rate = float64(h.IterationCount) / float64(h.TestDuration)
+	}
+	for _, s := range h.TimeRunCountMap {
+		c.Stages = append(c.Stages, ConfigEchoStage{Duration: s.Duration, Count: s.Count})
+	}
+	if d := h.DynamicRate; d != nil {
+		c.DynamicRate = &ConfigEchoDynamicRate{Source: d.URL, Min: d.Min, Max: d.Max}
+		if d.Prometheus != "" {
+			c.DynamicRate.Source = d.Prometheus
+			c.DynamicRate.Query = d.Query
+		}
+	}
+	if b := h.Burst; b != nil && b.Size > 0 {
+		c.Burst = &ConfigEchoBurst{Size: b.Size, Interval: b.Interval.Seconds(), Count: h.IterationCount / b.Size,
+			MaxInFlight: b.MaxInFlight, Policy: b.Policy}
+		if c.Burst.Policy == "" {
+			c.Burst.Policy = types.DefaultBurstPolicy
+		}
+	}
+	if v := h.VirtualUsers; v != nil {
+		c.VirtualUsers = &ConfigEchoVirtualUsers{Users: v.Users, IterationsPerUser: v.IterationsPerUser}
+	}
+	for _, p := range proxies {
+		if p != nil {
+			c.Proxies = append(c.Proxies, p.String())
+		}
+	}
+	c.Steps = make([]ConfigEchoStep, 0, len(h.Scenario.Steps))
+	for _, s := range h.Scenario.Steps {
+		c.Steps = append(c.Steps, ConfigEchoStep{
+			ID:              s.ID,
+			Name:            s.Name,
+			Method:          s.Method,
+			URL:             s.URL,
+			Headers:         s.Headers,
+			RepeatedHeaders: s.RepeatedHeaders,
+			AuthType:        s.Auth.Type,
+			AuthUsername:    s.Auth.Username,
+			AuthPassword:    s.Auth.Password,
+			Timeout:         s.Timeout,
+			ParallelGroup:   s.ParallelGroup,
+			Proxy:           s.Proxy,
+			DependsOn:       s.DependsOn,
+		})
+	}
+	types.Redact(c)
+	return c
+}
+
+// printConfigEcho writes the config block of the report header, w is a tabwriter of the report.
+func printConfigEcho(w io.Writer, c *ConfigEcho) {
+	if c == nil {
+		return
+	}
+	fmt.Fprintln(w, "CONFIG")
+	fmt.Fprintln(w, "-------------------------------------")
+	if c.DynamicRate != nil {
+		fmt.Fprintf(w, "Load:\tdynamic rate of %s, %g-%g iterations/s in %ds\n", c.DynamicRate.Source,
+			c.DynamicRate.Min, c.DynamicRate.Max, c.Duration)
+		if c.DynamicRate.Query != "" {
+			fmt.Fprintf(w, "Rate Query:\t%s\n", c.DynamicRate.Query)
+		}
+	} else if v := c.VirtualUsers; v != nil {
+		// Users run until all their iterations are done, the test has no rate
+		fmt.Fprintf(w, "Load:\t%s, %d iterations by %d users, %d iterations each\n", c.LoadType, c.IterationCount,
+			v.Users, v.IterationsPerUser)
+	} else {
+		fmt.Fprintf(w, "Load:\t%s, %d iterations in %ds (%.1f iterations/s)\n", c.LoadType, c.IterationCount,
+			c.Duration, c.Rate)
+	}
+	if len(c.Stages) > 0 {
+		fmt.Fprintf(w, "Stages:\t%s\n", formatStages(c.Stages))
+	}
+	if b := c.Burst; b != nil {
+		fmt.Fprintf(w, "Burst:\t%d iterations every %gs, %d bursts (policy: %s", b.Size, b.Interval, b.Count, b.Policy)
+		if b.MaxInFlight > 0 {
+			fmt.Fprintf(w, ", max in-flight: %d", b.MaxInFlight)
+		}
+		fmt.Fprintln(w, ")")
+	}
+	if len(c.Proxies) > 0 {
+		fmt.Fprintf(w, "Proxies:\t%d (%s)\n", len(c.Proxies), strings.Join(c.Proxies, ", "))
+	}
+	if c.SuccessCriteria != "" {
+		fmt.Fprintf(w, "Success Criteria:\t%s\n", c.SuccessCriteria)
+	}
+	if c.StopAfterFailures > 0 {
+		fmt.Fprintf(w, "Stop After Failures:\t%d\n", c.StopAfterFailures)
+	}
+	if c.MaxTransfer > 0 {
+		fmt.Fprintf(w, "Max Transfer:\t%s\n", formatBytes(c.MaxTransfer))
+	}
+	for _, r := range c.AbortRules {
+		fmt.Fprintf(w, "Abort Rule:\t%s\n", r)
+	}
+	fmt.Fprintln(w, "Steps:")
+	for _, s := range c.Steps {
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("Step %d", s.ID)
+		}
+		opts := []string{fmt.Sprintf("timeout: %ds", s.Timeout)}
+		if s.ParallelGroup != "" {
+			opts = append(opts, "parallel_group: "+s.ParallelGroup)
+		}
+		if len(s.DependsOn) > 0 {
+			opts = append(opts, "depends_on: "+strings.Join(s.DependsOn, ", "))
+		}
+		if s.Proxy != "" {
+			opts = append(opts, "proxy: "+s.Proxy)
+		}
+		if s.AuthType != "" {
+			opts = append(opts, fmt.Sprintf("auth: %s %s:%s", s.AuthType, s.AuthUsername, s.AuthPassword))
+		}
+		fmt.Fprintf(w, "  %d. %s\t%s %s (%s)\n", s.ID, name, s.Method, s.URL, strings.Join(opts, ", "))
+
+		headers := make(map[string][]string, len(s.Headers)+len(s.RepeatedHeaders))
+		for k, v := range s.Headers {
+			headers[k] = []string{v}
+		}
+		for k, values := range s.RepeatedHeaders {
+			headers[k] = append(headers[k], values...)
+		}
+		keys := make([]string, 0, len(headers))
+		for k := range headers {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, v := range headers[k] {
+				fmt.Fprintf(w, "     %s: %s\n", k, v)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// formatStages returns the iteration counts and the durations of the stages of the manual load, none if it has none.
+func formatStages(stages []ConfigEchoStage) string {
+	if len(stages) == 0 {
+		return "none"
+	}
+	s := make([]string, 0, len(stages))
+	for _, st := range stages {
+		s = append(s, fmt.Sprintf("%d in %ds", st.Count, st.Duration))
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,130 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// MaxTrackedConnectionRequests is the count of the requests of a connection tracked for the distribution of the
+// requests per connection, a connection with more requests is counted with this count.
+const MaxTrackedConnectionRequests = 10000
+
+// ConnectionSummary is the use of the connections of a step: the requests sent over each connection, the streams
+// of an h2 connection, and the connections closed by the server, like by a load balancer closing its keep-alive
+// connections after a count of requests.
+type ConnectionSummary struct {
+	Connections int64 `json:"connections"`
+	Requests    int64 `json:"requests"`
+
+	// Average, median, 90th percentile and maximum of the requests per connection, the connections still open at
+	// the end are counted with their requests so far
+	AvgRequests float32 `json:"avg_requests"`
+	P50Requests int64   `json:"p50_requests"`
+	P90Requests int64   `json:"p90_requests"`
+	MaxRequests int64   `json:"max_requests"`
+
+	// Connections closed by the server, either by a "Connection: close" response or by closing an idle connection,
+	// and the requests that opened a new connection in place of a closed one
+	ServerCloses int64 `json:"server_closes"`
+	Reconnects   int64 `json:"reconnects"`
+}
+
+// connTracker counts the requests of a step by their position on their connections, created by the first request
+// over a counted connection.
+type connTracker struct {
+	// positions[k] is the count of the requests sent as the k+1th request of their connection, so the count of the
+	// connections with more than k requests
+	positions []int64
+	requests  int64
+
+	serverCloses int64
+	reconnects   int64
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{}
+}
+
+// add counts the request by its position on its connection, and the connections closed by the server that the
+// request claimed.
+func (t *connTracker) add(sr *types.ScenarioStepResult) {
+	if n, ok := sr.Custom["connRequest"].(int64); ok {
+		t.requests++
+		if n <= MaxTrackedConnectionRequests {
+			for int64(len(t.positions)) < n {
+				t.positions = append(t.positions, 0)
+			}
+			t.positions[n-1]++
+		}
+	}
+	if _, ok := sr.Custom["connReconnect"]; ok {
+		t.reconnects++
+	}
+	if n, ok := sr.Custom["serverCloses"].(int64); ok {
+		t.serverCloses += n
+	}
+}
+
+func (t *connTracker) merge(o *connTracker) {
+	for int64(len(t.positions)) < int64(len(o.positions)) {
+		t.positions = append(t.positions, 0)
+	}
+	for k, n := range o.positions {
+		t.positions[k] += n
+	}
+	t.requests += o.requests
+	t.serverCloses += o.serverCloses
+	t.reconnects += o.reconnects
+}
+
+// summary returns the use of the connections, nil if no request is sent over a counted connection.
+func (t *connTracker) summary() *ConnectionSummary {
+	if t == nil || len(t.positions) == 0 || t.positions[0] == 0 {
+		return nil
+	}
+	conns := t.positions[0]
+	return &ConnectionSummary{
+		Connections:  conns,
+		Requests:     t.requests,
+		AvgRequests:  float32(float64(t.requests) / float64(conns)),
+		P50Requests:  t.percentile(50),
+		P90Requests:  t.percentile(90),
+		MaxRequests:  int64(len(t.positions)),
+		ServerCloses: t.serverCloses,
+		Reconnects:   t.reconnects,
+	}
+}
+
+// percentile returns the smallest count of requests that p percent of the connections have at most.
+func (t *connTracker) percentile(p int64) int64 {
+	conns := t.positions[0]
+	for k := range t.positions {
+		// Connections with more than k+1 requests
+		more := int64(0)
+		if k+1 < len(t.positions) {
+			more = t.positions[k+1]
+		}
+		if (conns-more)*100 >= p*conns {
+			return int64(k + 1)
+		}
+	}
+	return int64(len(t.positions))
+}
@@ -0,0 +1,36 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import "os"
+
+// unicodeConsole reports whether the console renders emoji and block elements.
+// The legacy console host renders them as question marks. Windows Terminal, ConEmu and the terminal emulators
+// that set TERM like mintty and the VS Code terminal render them.
+func unicodeConsole() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return os.Getenv("WT_SESSION") != "" ||
+		os.Getenv("ConEmuANSI") == "ON" ||
+		os.Getenv("TERM_PROGRAM") != "" ||
+		os.Getenv("TERM") != ""
+}
@@ -0,0 +1,241 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Correlations enables the analysis of the final result that correlates the durations of the steps within the
+// iterations, like an auth step that is slow whenever the checkout step is slow.
+var Correlations bool
+
+const (
+	// Scenarios with more steps give a matrix too large to read
+	minCorrelationSteps = 2
+	maxCorrelationSteps = 6
+
+	// Coefficients of the step pairs with fewer sampled iterations are not reliable enough to be reported
+	minCorrelationSamples = 10
+)
+
+// correlationSampleLimit is the count of the iterations sampled for the correlations.
+var correlationSampleLimit = 50000
+
+// CorrelationMatrix is the Pearson correlation coefficients of the step durations over the sampled iterations.
+// Coefficients are in the order of Steps, a coefficient is null if the pair has too few samples or a step has
+// a constant duration.
+type CorrelationMatrix struct {
+	Steps        []uint16     `json:"steps"`
+	Coefficients [][]*float64 `json:"coefficients"`
+	Samples      int          `json:"samples"`
+	Iterations   int64        `json:"iterations"` // Iterations eligible for the sample
+}
+
+// iterationDurations is the step durations of an iteration whose steps all succeeded.
+type iterationDurations struct {
+	priority  float64
+	n         int
+	ids       [maxCorrelationSteps]uint16
+	durations [maxCorrelationSteps]float64 // In seconds
+}
+
+// durationSampler keeps a uniform sample of the iterationDurations of the run, bounded by correlationSampleLimit.
+// Each iteration gets a random priority and the ones with the lowest priorities are kept, so the partial samplers of
+// the pipeline merge into a uniform sample of all the iterations regardless of how they are distributed.
+type durationSampler struct {
+	rnd     *rand.Rand
+	samples sampleHeap
+	seen    int64
+}
+
+func newDurationSampler() *durationSampler {
+	return &durationSampler{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// add samples the step durations of the iteration. Iterations with a failed step, a repeated step or more steps
+// than the matrix allows are not sampled.
+func (s *durationSampler) add(results []*types.ScenarioStepResult) {
+	if len(results) < minCorrelationSteps || len(results) > maxCorrelationSteps {
+		return
+	}
+	d := iterationDurations{priority: s.rnd.Float64(), n: len(results)}
+	for i, sr := range results {
+		if sr.Err.Type != "" {
+			return
+		}
+		for _, id := range d.ids[:i] {
+			if id == sr.StepID {
+				return
+			}
+		}
+		d.ids[i], d.durations[i] = sr.StepID, sr.Duration.Seconds()
+	}
+	s.seen++
+	s.keep(d)
+}
+
+func (s *durationSampler) keep(d iterationDurations) {
+	if len(s.samples) < correlationSampleLimit {
+		heap.Push(&s.samples, d)
+	} else if len(s.samples) > 0 && d.priority < s.samples[0].priority {
+		s.samples[0] = d
+		heap.Fix(&s.samples, 0)
+	}
+}
+
+func (s *durationSampler) merge(o *durationSampler) {
+	s.seen += o.seen
+	for _, d := range o.samples {
+		s.keep(d)
+	}
+}
+
+// sampleHeap is a max-heap of the priorities, its root is the sample replaced first.
+type sampleHeap []iterationDurations
+
+func (h sampleHeap) Len() int            { return len(h) }
+func (h sampleHeap) Less(i, j int) bool  { return h[i].priority > h[j].priority }
+func (h sampleHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sampleHeap) Push(x interface{}) { *h = append(*h, x.(iterationDurations)) }
+func (h *sampleHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// correlate returns the correlation matrix of the sampled steps, nil if the scenario has fewer or more steps than
+// the matrix allows.
+func (s *durationSampler) correlate() *CorrelationMatrix {
+	positions := make(map[uint16]bool)
+	for _, d := range s.samples {
+		for _, id := range d.ids[:d.n] {
+			positions[id] = true
+		}
+	}
+	if len(positions) < minCorrelationSteps || len(positions) > maxCorrelationSteps {
+		return nil
+	}
+
+	m := &CorrelationMatrix{Samples: len(s.samples), Iterations: s.seen}
+	for id := range positions {
+		m.Steps = append(m.Steps, id)
+	}
+	sort.Slice(m.Steps, func(i, j int) bool { return m.Steps[i] < m.Steps[j] })
+
+	m.Coefficients = make([][]*float64, len(m.Steps))
+	for i := range m.Steps {
+		m.Coefficients[i] = make([]*float64, len(m.Steps))
+	}
+	for i, a := range m.Steps {
+		for j := i; j < len(m.Steps); j++ {
+			if c, ok := s.pearson(a, m.Steps[j]); ok {
+				m.Coefficients[i][j], m.Coefficients[j][i] = &c, &c
+			}
+		}
+	}
+	return m
+}
+
+// pearson returns the Pearson correlation coefficient of the durations of the steps over the samples having both.
+func (s *durationSampler) pearson(a, b uint16) (float64, bool) {
+	var xs, ys []float64
+	for _, d := range s.samples {
+		x, okX := d.duration(a)
+		y, okY := d.duration(b)
+		if okX && okY {
+			xs, ys = append(xs, x), append(ys, y)
+		}
+	}
+	if len(xs) < minCorrelationSamples {
+		return 0, false
+	}
+
+	// Mean of a constant duration may have a rounding error, so the constant durations are told apart by their values
+	constX, constY := true, true
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+		constX, constY = constX && xs[i] == xs[0], constY && ys[i] == ys[0]
+	}
+	if constX || constY {
+		return 0, false
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	// Rounding errors may put the coefficient of the identical durations slightly out of the range
+	return math.Max(-1, math.Min(1, cov/math.Sqrt(varX*varY))), true
+}
+
+func (d *iterationDurations) duration(id uint16) (float64, bool) {
+	for i := 0; i < d.n; i++ {
+		if d.ids[i] == id {
+			return d.durations[i], true
+		}
+	}
+	return 0, false
+}
+
+func printCorrelations(w io.Writer, r *Result) {
+	m := r.Correlations
+	fmt.Fprintf(w, "Correlations (Pearson coefficients of the step durations, %s of %s iterations sampled):\n",
+		formatCount(int64(m.Samples)), formatCount(m.Iterations))
+	fmt.Fprint(w, "  Step")
+	for _, id := range m.Steps {
+		fmt.Fprintf(w, "\t%d", id)
+	}
+	fmt.Fprintln(w)
+	for i, id := range m.Steps {
+		step := "Step " + strconv.Itoa(int(id))
+		if s, ok := r.StepResults[id]; ok && s.Name != "" {
+			step = s.Name
+		}
+		fmt.Fprintf(w, "  %d. %s", id, step)
+		for _, c := range m.Coefficients[i] {
+			if c == nil {
+				fmt.Fprint(w, "\t-")
+			} else {
+				fmt.Fprintf(w, "\t%.2f", *c)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,185 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// correlationTestResults returns the iterations of a scenario whose step 3 is slow whenever step 1 is slow, and whose
+// step 2 has independent durations.
+func correlationTestResults(count int) []*types.ScenarioResult {
+	rnd := rand.New(rand.NewSource(1))
+	results := make([]*types.ScenarioResult, count)
+	for i := range results {
+		load := time.Duration(rnd.Intn(100)) * time.Millisecond
+		results[i] = &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StepName: "login", StatusCode: 200, Duration: 10*time.Millisecond + load},
+			{StepID: 2, StepName: "search", StatusCode: 200, Duration: time.Duration(rnd.Intn(100)) * time.Millisecond},
+			{StepID: 3, StatusCode: 200,
+				Duration: 20*time.Millisecond + 2*load + time.Duration(rnd.Intn(5))*time.Millisecond},
+		}}
+	}
+	return results
+}
+
+func TestCorrelations(t *testing.T) {
+	Correlations = true
+	defer func() {
+		Correlations = false
+	}()
+
+	for _, workers := range []int{1, 4} {
+		m := runPipeline(workers, correlationTestResults(2000)).Correlations
+		if m == nil {
+			t.Fatalf("Workers %d, correlations should be reported", workers)
+		}
+		if len(m.Steps) != 3 || m.Steps[0] != 1 || m.Steps[2] != 3 || m.Samples != 2000 || m.Iterations != 2000 {
+			t.Fatalf("Workers %d, Expected 2000 samples of the steps 1-3, Found %+v", workers, m)
+		}
+		for i := range m.Steps {
+			if c := m.Coefficients[i][i]; c == nil || math.Abs(*c-1) > 1e-9 {
+				t.Errorf("Workers %d, coefficient of step %d with itself should be 1, Found %v", workers, m.Steps[i], c)
+			}
+		}
+		if c := m.Coefficients[0][2]; c == nil || *c < 0.95 || m.Coefficients[2][0] != c {
+			t.Errorf("Workers %d, steps 1 and 3 should be correlated, Found %v", workers, c)
+		}
+		if c := m.Coefficients[0][1]; c == nil || math.Abs(*c) > 0.1 {
+			t.Errorf("Workers %d, steps 1 and 2 should not be correlated, Found %v", workers, c)
+		}
+	}
+}
+
+func TestCorrelationsSampleUniform(t *testing.T) {
+	Correlations = true
+	oldLimit := correlationSampleLimit
+	correlationSampleLimit = 1000
+	defer func() {
+		Correlations = false
+		correlationSampleLimit = oldLimit
+	}()
+
+	// Durations of step 1 are the order of the iterations, the sample should cover the whole run
+	results := correlationTestResults(20000)
+	for i, r := range results {
+		r.StepResults[0].Duration = time.Duration(i) * time.Millisecond
+	}
+	p := newPipeline(4, nil, types.PercentileSketch{})
+	input := make(chan *types.ScenarioResult, len(results))
+	for _, r := range results {
+		input <- r
+	}
+	close(input)
+	p.run(input, nil)
+
+	merged := newAggregator()
+	for _, sh := range p.shards {
+		merged.merge(sh.agg)
+	}
+	s := merged.durations
+	if len(s.samples) != 1000 || s.seen != 20000 {
+		t.Fatalf("Expected 1000 samples of 20000 iterations, Found %d of %d", len(s.samples), s.seen)
+	}
+	var late int
+	for _, d := range s.samples {
+		if order, _ := d.duration(1); order >= 10 {
+			late++
+		}
+	}
+	if late < 400 || late > 600 {
+		t.Errorf("Half of the samples should be from the second half of the run, Found %d of 1000", late)
+	}
+}
+
+func TestCorrelationsSkipped(t *testing.T) {
+	Correlations = true
+	defer func() {
+		Correlations = false
+	}()
+
+	constant := correlationTestResults(100)
+	for _, r := range constant {
+		r.StepResults[1].Duration = 50 * time.Millisecond
+	}
+	failed := correlationTestResults(100)
+	for _, r := range failed {
+		r.StepResults[2].Err = types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnRefused}
+	}
+	single := correlationTestResults(100)
+	for _, r := range single {
+		r.StepResults = r.StepResults[:1]
+	}
+	long := correlationTestResults(100)
+	for _, r := range long {
+		for id := uint16(4); id <= 7; id++ {
+			r.StepResults = append(r.StepResults, &types.ScenarioStepResult{StepID: id, Duration: time.Millisecond})
+		}
+	}
+
+	if m := runPipeline(1, constant).Correlations; m == nil || m.Coefficients[0][1] != nil || m.Coefficients[0][2] == nil {
+		t.Errorf("Step with a constant duration should have no coefficient, Found %+v", m)
+	}
+	if m := runPipeline(1, failed).Correlations; m != nil {
+		t.Errorf("Failed iterations should not be sampled, Found %+v", m)
+	}
+	if m := runPipeline(1, single).Correlations; m != nil {
+		t.Errorf("Single step scenario should not be correlated, Found %+v", m)
+	}
+	if m := runPipeline(1, long).Correlations; m != nil {
+		t.Errorf("Scenario of 7 steps should not be correlated, Found %+v", m)
+	}
+	if m := runPipeline(1, correlationTestResults(minCorrelationSamples-1)).Correlations; m == nil ||
+		m.Coefficients[0][2] != nil {
+		t.Errorf("Steps with too few samples should have no coefficient, Found %+v", m)
+	}
+}
+
+func TestCorrelationsDisabled(t *testing.T) {
+	if m := runPipeline(2, correlationTestResults(100)).Correlations; m != nil {
+		t.Errorf("Correlations should be disabled by default, Found %+v", m)
+	}
+}
+
+func TestStdoutPrintsCorrelations(t *testing.T) {
+	Correlations = true
+	defer func() {
+		Correlations = false
+	}()
+
+	printed := printedDetails(runPipeline(1, correlationTestResults(2000)))
+	for _, expected := range []string{
+		"Correlations (Pearson coefficients of the step durations, 2000 of 2000 iterations sampled):",
+		"  Step         1       2       3",
+		"  1. login     1.00    ",
+		"  3. Step 3    ",
+	} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("%q should be printed in the report, Found: %s", expected, printed)
+		}
+	}
+}
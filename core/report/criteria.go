@@ -0,0 +1,138 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"go.ddosify.com/ddosify/core/criteria"
+)
+
+// CriteriaValue returns the value of the success criteria identifier. Rates are in [0, 1] and durations in seconds.
+// Rates and durations of the results without any request are not available.
+func (r *Result) CriteriaValue(id criteria.Ident) (float64, bool) {
+	if id.Step == "" {
+		total := r.SuccessCount + r.FailedCount
+		switch id.Metric {
+		case "iteration_count":
+			return float64(total), true
+		case "success_count":
+			return float64(r.SuccessCount), true
+		case "fail_count":
+			return float64(r.FailedCount), true
+		case "success_rate":
+			return ratio(r.SuccessCount, total)
+		case "fail_rate":
+			return ratio(r.FailedCount, total)
+		case "avg_duration":
+			return float64(r.AvgDuration), r.SuccessCount > 0
+		}
+		return 0, false
+	}
+
+	s, ok := r.criteriaStep(id.Step)
+	if !ok {
+		return 0, false
+	}
+	return s.criteriaValue(id.Metric)
+}
+
+// criteriaStep returns the step referred by name, or by ID if no step has the same name.
+func (r *Result) criteriaStep(ref string) (*ScenarioStepResultSummary, bool) {
+	for _, s := range r.StepResults {
+		if s.Name != "" && s.Name == ref {
+			return s, true
+		}
+	}
+	if id, err := strconv.ParseUint(ref, 10, 16); err == nil {
+		s, ok := r.StepResults[uint16(id)]
+		return s, ok
+	}
+	return nil, false
+}
+
+func (s *ScenarioStepResultSummary) criteriaValue(metric string) (float64, bool) {
+	total := s.SuccessCount + s.FailedCount
+	switch metric {
+	case "request_count":
+		return float64(total), true
+	case "success_count":
+		return float64(s.SuccessCount), true
+	case "fail_count":
+		return float64(s.FailedCount), true
+	case "success_rate":
+		return ratio(s.SuccessCount, total)
+	case "fail_rate":
+		return ratio(s.FailedCount, total)
+	case "rate_limited_count":
+		return float64(s.RateLimitedCount), true
+	case "avg_duration":
+		return float64(s.Durations["duration"]), s.SuccessCount > 0
+	}
+
+	if strings.HasPrefix(metric, "p") {
+		if p, err := strconv.Atoi(metric[1:]); err == nil {
+			v, ok := s.percentiles[p]
+			return float64(v), ok
+		}
+	}
+
+	if code, class, rate, ok := criteria.ParseStatusMetric(metric); ok {
+		var count int64
+		for c, n := range s.StatusCodeDist {
+			if c == code || (class && c/100 == code) {
+				count += int64(n)
+			}
+		}
+		if rate {
+			return ratio(count, total)
+		}
+		return float64(count), true
+	}
+	return 0, false
+}
+
+func ratio(count, total int64) (float64, bool) {
+	if total == 0 {
+		return 0, false
+	}
+	return float64(count) / float64(total), true
+}
+
+// printCriteria writes the evaluated success criteria with the actual value of each clause.
+func printCriteria(w io.Writer, c *criteria.Result) {
+	status := "PASSED"
+	if !c.Passed {
+		status = "FAILED"
+	}
+	fmt.Fprintf(w, "\nSUCCESS CRITERIA (%s)\n", status)
+	fmt.Fprintln(w, "-------------------------------------")
+	for _, cl := range c.Clauses {
+		result := "PASS"
+		if !cl.Passed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t(actual: %s)\n", result, cl.Clause, cl.FormatActual())
+	}
+}
@@ -0,0 +1,137 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func newCriteriaTestResult() *Result {
+	agg := newAggregator()
+	agg.initSteps([]types.ScenarioStep{{ID: 1, Name: "login"}, {ID: 2, Name: "Get Users"}})
+
+	for i := 1; i <= 4; i++ {
+		steps := []*types.ScenarioStepResult{
+			{StepID: 1, StatusCode: 200, Duration: time.Duration(i*100) * time.Millisecond},
+		}
+		if i == 4 {
+			steps = append(steps, &types.ScenarioStepResult{StepID: 2, Err: types.RequestError{
+				Type: types.ErrorConn, Reason: types.ReasonConnTimeout}})
+		} else {
+			steps = append(steps, &types.ScenarioStepResult{StepID: 2, StatusCode: 404,
+				Duration: 50 * time.Millisecond})
+		}
+		agg.add(&types.ScenarioResult{StepResults: steps})
+	}
+	return agg.result()
+}
+
+func TestResultCriteriaValue(t *testing.T) {
+	r := newCriteriaTestResult()
+
+	tests := []struct {
+		id        criteria.Ident
+		expected  float64
+		available bool
+	}{
+		{criteria.Ident{Metric: "iteration_count"}, 4, true},
+		{criteria.Ident{Metric: "fail_count"}, 1, true},
+		{criteria.Ident{Metric: "fail_rate"}, 0.25, true},
+		{criteria.Ident{Step: "login", Metric: "request_count"}, 4, true},
+		{criteria.Ident{Step: "1", Metric: "success_rate"}, 1, true},
+		{criteria.Ident{Step: "Get Users", Metric: "fail_count"}, 1, true},
+		{criteria.Ident{Step: "Get Users", Metric: "status_404_count"}, 3, true},
+		{criteria.Ident{Step: "Get Users", Metric: "status_4xx_rate"}, 0.75, true},
+		{criteria.Ident{Step: "Get Users", Metric: "status_2xx_rate"}, 0, true},
+		{criteria.Ident{Step: "unknown", Metric: "fail_count"}, 0, false},
+	}
+
+	for _, test := range tests {
+		v, ok := r.CriteriaValue(test.id)
+		if ok != test.available || v != test.expected {
+			t.Errorf("%s: Expected %v (%v), Found %v (%v)", test.id, test.expected, test.available, v, ok)
+		}
+	}
+
+	// Percentiles are approximated by the histogram
+	v, ok := r.CriteriaValue(criteria.Ident{Step: "login", Metric: "p99"})
+	if !ok || v < 0.39 || v > 0.41 {
+		t.Errorf("p99 Expected ~0.4, Found %v (%v)", v, ok)
+	}
+}
+
+func TestResultCriteriaValueWithoutRequests(t *testing.T) {
+	agg := newAggregator()
+	agg.initSteps([]types.ScenarioStep{{ID: 1, Name: "login"}})
+	r := agg.result()
+
+	for _, metric := range []string{"fail_rate", "avg_duration", "p95", "status_2xx_rate"} {
+		if _, ok := r.CriteriaValue(criteria.Ident{Step: "login", Metric: metric}); ok {
+			t.Errorf("%s should not be available without requests", metric)
+		}
+	}
+	if v, ok := r.CriteriaValue(criteria.Ident{Step: "login", Metric: "request_count"}); !ok || v != 0 {
+		t.Errorf("request_count Expected 0, Found %v (%v)", v, ok)
+	}
+}
+
+func TestStdoutPrintsCriteria(t *testing.T) {
+	c, err := criteria.Parse("steps.login.p95 < 1s && result.fail_rate < 1%")
+	if err != nil {
+		t.Fatalf("Error occurred %v", err)
+	}
+
+	s := &stdout{}
+	s.Init(false)
+	s.SetCriteria(c)
+	s.result = newCriteriaTestResult()
+
+	realOut := out
+	defer func() {
+		out = realOut
+	}()
+	buffer := new(bytes.Buffer)
+	out = buffer
+
+	s.finish()
+
+	r := s.CriteriaResult()
+	if r == nil || r.Passed || len(r.Clauses) != 2 || !r.Clauses[0].Passed || r.Clauses[1].Passed {
+		t.Fatalf("Unexpected criteria result %#v", r)
+	}
+
+	printed := buffer.String()
+	for _, expected := range []string{
+		"SUCCESS CRITERIA (FAILED)",
+		"PASS    steps.login.p95 < 1s",
+		"FAIL    result.fail_rate < 1%    (actual: 0.2500)",
+	} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("%q should be printed, Found: %s", expected, printed)
+		}
+	}
+}
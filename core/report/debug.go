@@ -2,9 +2,11 @@ package report
 
 import (
 	"encoding/json"
+	"fmt"
 	"html"
 	"net/http"
 	"strings"
+	"unicode/utf8"
 
 	"go.ddosify.com/ddosify/core/types"
 )
@@ -12,7 +14,12 @@ import (
 type verboseHttpRequestInfo struct {
 	StepId   uint16 `json:"stepId"`
 	StepName string `json:"stepName"`
-	Request  struct {
+
+	// Values of the {{_iterationId}} and {{_vuId}} variables of the request, zero in preview mode
+	IterationId uint64 `json:"iterationId"`
+	VuId        uint64 `json:"vuId"`
+
+	Request struct {
 		Url     string            `json:"url"`
 		Method  string            `json:"method"`
 		Headers map[string]string `json:"headers"`
@@ -24,6 +31,13 @@ type verboseHttpRequestInfo struct {
 		Body       interface{}       `json:"body"`
 	} `json:"response"`
 	Error string `json:"error"`
+
+	// Values of the xpath captures and assertions of the step over the response body
+	XPathMatches map[string][]string `json:"xpathMatches,omitempty"`
+
+	// Set for the requests rendered in preview mode, they have no response
+	Preview             bool     `json:"-"`
+	UnresolvedVariables []string `json:"-"`
 }
 
 func ScenarioStepResultToVerboseHttpRequestInfo(sr *types.ScenarioStepResult) verboseHttpRequestInfo {
@@ -31,8 +45,17 @@ func ScenarioStepResultToVerboseHttpRequestInfo(sr *types.ScenarioStepResult) ve
 
 	verboseInfo.StepId = sr.StepID
 	verboseInfo.StepName = sr.StepName
+	verboseInfo.IterationId, verboseInfo.VuId = sr.IterationID, sr.VUID
 	requestHeaders, requestBody, _ := decode(sr.DebugInfo["requestHeaders"].(http.Header),
 		sr.DebugInfo["requestBody"].([]byte))
+	// Binary bodies, like the verbatim payloads, are shown as their size and a hex preview
+	if b := sr.DebugInfo["requestBody"].([]byte); isBinary(b) {
+		requestBody = binaryPreview(b)
+	}
+	// Protobuf bodies are shown as the JSON of their messages
+	if msg, ok := sr.DebugInfo["requestMessage"].([]byte); ok {
+		json.Unmarshal(msg, &requestBody)
+	}
 	verboseInfo.Request = struct {
 		Url     string            "json:\"url\""
 		Method  string            "json:\"method\""
@@ -45,11 +68,24 @@ func ScenarioStepResultToVerboseHttpRequestInfo(sr *types.ScenarioStepResult) ve
 		Body:    requestBody,
 	}
 
-	if sr.Err.Type != "" {
+	if preview, ok := sr.DebugInfo["preview"].(bool); ok && preview {
+		verboseInfo.Preview = true
+		verboseInfo.UnresolvedVariables, _ = sr.DebugInfo["unresolvedVariables"].([]string)
+	} else if !hasResponse(sr) {
 		verboseInfo.Error = sr.Err.Error()
 	} else {
-		responseHeaders, responseBody, _ := decode(sr.DebugInfo["responseHeaders"].(http.Header),
+		// Partial body of the truncated responses is not decodable in most cases, it is shown as it is.
+		responseHeaders, responseBody, err := decode(sr.DebugInfo["responseHeaders"].(http.Header),
 			sr.DebugInfo["responseBody"].([]byte))
+		if sr.Err.Type != "" {
+			verboseInfo.Error = sr.Err.Error()
+		}
+		if sr.Err.Type == types.ErrorTruncated && err != nil {
+			responseBody = string(sr.DebugInfo["responseBody"].([]byte))
+		}
+		if msg, ok := sr.DebugInfo["responseMessage"].([]byte); ok {
+			json.Unmarshal(msg, &responseBody)
+		}
 		// TODO what to do with error
 		verboseInfo.Response = struct {
 			StatusCode int               "json:\"statusCode\""
@@ -60,11 +96,47 @@ func ScenarioStepResultToVerboseHttpRequestInfo(sr *types.ScenarioStepResult) ve
 			Headers:    responseHeaders,
 			Body:       responseBody,
 		}
+		verboseInfo.XPathMatches, _ = sr.DebugInfo["xpathMatches"].(map[string][]string)
 	}
 
 	return verboseInfo
 }
 
+// hasResponse reports whether the response of the failed request is received, like the truncated responses and
+// the responses failing the assertions.
+func hasResponse(sr *types.ScenarioStepResult) bool {
+	if sr.Err.Type == types.ErrorProtobuf || sr.Err.Type == types.ErrorStream || sr.Err.Type == types.ErrorGrpcStatus {
+		// Requests failing the encoding are not sent, and the streams may fail before their response.
+		_, ok := sr.DebugInfo["responseHeaders"]
+		return ok
+	}
+	return sr.Err.Type == "" || sr.Err.Type == types.ErrorTruncated || sr.Err.Type == types.ErrorAssertion
+}
+
+// binaryPreviewSize is the count of the leading bytes of a binary body shown in the debug output.
+const binaryPreviewSize = 64
+
+// isBinary reports whether the body is not a printable text, like the protobuf messages or the images.
+func isBinary(b []byte) bool {
+	if !utf8.Valid(b) {
+		return true
+	}
+	for _, c := range b {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			return true
+		}
+	}
+	return false
+}
+
+// binaryPreview returns the size of the body and the hex dump of its leading bytes.
+func binaryPreview(b []byte) string {
+	if len(b) <= binaryPreviewSize {
+		return fmt.Sprintf("<%d bytes> % x", len(b), b)
+	}
+	return fmt.Sprintf("<%d bytes> % x ...", len(b), b[:binaryPreviewSize])
+}
+
 func decode(headers http.Header, byteBody []byte) (map[string]string, interface{}, error) {
 	contentType := headers.Get("Content-Type")
 	var reqBody interface{}
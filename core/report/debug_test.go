@@ -0,0 +1,104 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestVerboseInfoBinaryRequestBody(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("\x00", binaryPreviewSize+1)
+	tests := []struct {
+		name     string
+		body     []byte
+		expected string
+	}{
+		{"Text", []byte("a=1\tb=2\n"), "a=1\tb=2\n"},
+		{"Empty", []byte{}, ""},
+		{"InvalidUTF8", []byte{0xff, 0xfe}, "<2 bytes> ff fe"},
+		{"Protobuf", []byte{0x0a, 0x03, 'f', 'o', 'o'}, "<5 bytes> 0a 03 66 6f 6f"},
+		{"Truncated", []byte(long), "<65 bytes> " + strings.TrimSpace(strings.Repeat("00 ", binaryPreviewSize)) + " ..."},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			sr := &types.ScenarioStepResult{
+				DebugInfo: map[string]interface{}{
+					"url":            "http://127.0.0.1",
+					"method":         http.MethodPost,
+					"requestHeaders": http.Header{"Content-Type": {"application/x-protobuf"}},
+					"requestBody":    test.body,
+					"preview":        true,
+				},
+			}
+
+			info := ScenarioStepResultToVerboseHttpRequestInfo(sr)
+			if info.Request.Body != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, info.Request.Body)
+			}
+		})
+	}
+}
+
+func TestVerboseInfoProtobufMessages(t *testing.T) {
+	t.Parallel()
+
+	header := http.Header{"Content-Type": {"application/x-protobuf"}}
+	sr := &types.ScenarioStepResult{
+		StatusCode: 200,
+		DebugInfo: map[string]interface{}{
+			"url":             "http://127.0.0.1",
+			"method":          http.MethodPost,
+			"requestHeaders":  header,
+			"requestBody":     []byte{0x0a, 0x03, 'a', 'd', 'a'},
+			"requestMessage":  []byte(`{"name":"ada"}`),
+			"responseHeaders": header,
+			"responseBody":    []byte{0x08, 0x01},
+			"responseMessage": []byte(`{"id":"1"}`),
+		},
+	}
+
+	info := ScenarioStepResultToVerboseHttpRequestInfo(sr)
+	if expected := map[string]interface{}{"name": "ada"}; !reflect.DeepEqual(info.Request.Body, expected) {
+		t.Errorf("Request: Expected %v, Found %v", expected, info.Request.Body)
+	}
+	if expected := map[string]interface{}{"id": "1"}; !reflect.DeepEqual(info.Response.Body, expected) {
+		t.Errorf("Response: Expected %v, Found %v", expected, info.Response.Body)
+	}
+
+	// Requests failing the encoding have no response
+	sr.Err = types.RequestError{Type: types.ErrorProtobuf, Reason: "protobuf request: field id: invalid int64 value: x"}
+	delete(sr.DebugInfo, "responseHeaders")
+	info = ScenarioStepResultToVerboseHttpRequestInfo(sr)
+	if info.Error != sr.Err.Error() {
+		t.Errorf("Expected %q, Found %q", sr.Err.Error(), info.Error)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DrainProgress is the live state of the drain phase of a gracefully stopped test.
+type DrainProgress struct {
+	InFlight  int64 // Iterations still in flight
+	Completed int64 // Iterations completed since the stop
+}
+
+// DrainSummary is the drain phase of a gracefully stopped test, in which no new iteration is started and the
+// in-flight iterations are waited until the shutdown deadline. The results completed during the drain are included
+// in the counts of the report, their counts and the start of the drain are for excluding them from the rates.
+type DrainSummary struct {
+	StartedAt    time.Time `json:"started_at"`
+	Duration     float32   `json:"duration"`  // In seconds
+	InFlight     int64     `json:"in_flight"` // Iterations in flight when the test is stopped
+	Completed    int64     `json:"completed"` // Iterations completed during the drain, reported or not
+	SuccessCount int64     `json:"success_count"`
+	FailedCount  int64     `json:"fail_count"`
+
+	// Iterations canceled at the shutdown deadline
+	Canceled int64 `json:"canceled,omitempty"`
+}
+
+// drainState is the drain phase of a report service, set by the engine concurrently with Start.
+type drainState struct {
+	mu       sync.Mutex
+	progress func() DrainProgress // nil until the test is stopped
+	summary  *DrainSummary
+}
+
+func (d *drainState) StartDrain(progress func() DrainProgress) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.progress = progress
+}
+
+func (d *drainState) SetDrain(s DrainSummary) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.summary = &s
+}
+
+// draining returns the live progress of the drain, false if the test is not draining.
+func (d *drainState) draining() (DrainProgress, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.progress == nil || d.summary != nil {
+		return DrainProgress{}, false
+	}
+	return d.progress(), true
+}
+
+func (d *drainState) drainSummary() *DrainSummary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.summary
+}
+
+// drainLine is the live print line of the drain phase.
+func drainLine(p DrainProgress) string {
+	return fmt.Sprintf("draining: %s iterations in flight, %s completed since stop", formatCount(p.InFlight),
+		formatCount(p.Completed))
+}
+
+// setDrain sets the drain phase of the test with the counts of the results completed during it.
+func (r *Result) setDrain(d *DrainSummary) {
+	if d == nil {
+		return
+	}
+	c := *d
+	c.SuccessCount, c.FailedCount = r.drainSuccessCount, r.drainFailedCount
+	r.Drain = &c
+}
+
+// printDrain prints the drain phase if the test is stopped gracefully.
+func printDrain(w io.Writer, d *DrainSummary) {
+	if d == nil {
+		return
+	}
+	fmt.Fprintln(w, "Drain:")
+	fmt.Fprintf(w, "  In-flight at Stop:\t%s iterations\n", formatCount(d.InFlight))
+	fmt.Fprintf(w, "  Completed:\t%s iterations in %s (%s successful, %s failed)\n", formatCount(d.Completed),
+		formatDuration(float64(d.Duration)), formatCount(d.SuccessCount), formatCount(d.FailedCount))
+	if d.Canceled > 0 {
+		fmt.Fprintf(w, "  Canceled:\t%s iterations at the shutdown deadline\n", formatCount(d.Canceled))
+	}
+	fmt.Fprintln(w, "  Results completed during the drain are included in the counts above.")
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,140 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// DuplicateSummary is the pairs of the requests of a step sent twice by its duplicate_rate. The match rates are over
+// the pairs whose duplicate got a response, the bodies are compared by their hashes.
+type DuplicateSummary struct {
+	PairCount        int64 `json:"pair_count"`
+	FailedCount      int64 `json:"failed_count"`
+	StatusMatchCount int64 `json:"status_match_count"`
+	BodyMatchCount   int64 `json:"body_match_count"`
+
+	// Pairs with the same status code and the same body over the answered pairs, in [0, 1]
+	StatusMatchRate float32 `json:"status_match_rate"`
+	BodyMatchRate   float32 `json:"body_match_rate"`
+
+	// Durations of the originals and of their duplicates
+	Original  StatusDurations `json:"original"`
+	Duplicate StatusDurations `json:"duplicate"`
+
+	// Counts of the status codes of the mismatched pairs, by the original and the duplicate like "201 -> 409"
+	StatusMismatches map[string]int64 `json:"status_mismatches,omitempty"`
+}
+
+// duplicateTracker counts the duplicated requests of a step, created by the first duplicate of a step with
+// a duplicate_rate.
+type duplicateTracker struct {
+	pairs, failed, statusMatch, bodyMatch int64
+	original, duplicate                   *statusTracker
+	mismatches                            map[string]int64
+}
+
+func newDuplicateTracker() *duplicateTracker {
+	return &duplicateTracker{
+		original:   &statusTracker{hist: newHistogram()},
+		duplicate:  &statusTracker{hist: newHistogram()},
+		mismatches: make(map[string]int64),
+	}
+}
+
+func (t *duplicateTracker) add(sr *types.ScenarioStepResult, d types.DuplicateResult) {
+	t.pairs++
+	if d.Err.Type != "" {
+		t.failed++
+		return
+	}
+	t.original.add(sr.Duration)
+	t.duplicate.add(d.Duration)
+	if d.StatusMatch {
+		t.statusMatch++
+	} else {
+		t.mismatches[fmt.Sprintf("%d -> %d", sr.StatusCode, d.StatusCode)]++
+	}
+	if d.BodyMatch {
+		t.bodyMatch++
+	}
+}
+
+func (t *duplicateTracker) merge(o *duplicateTracker) {
+	t.pairs += o.pairs
+	t.failed += o.failed
+	t.statusMatch += o.statusMatch
+	t.bodyMatch += o.bodyMatch
+	t.original.merge(o.original)
+	t.duplicate.merge(o.duplicate)
+	for k, n := range o.mismatches {
+		t.mismatches[k] += n
+	}
+}
+
+// summary returns the summary of the duplicated requests, nil if no request of the step is duplicated.
+func (t *duplicateTracker) summary() *DuplicateSummary {
+	if t == nil {
+		return nil
+	}
+	s := &DuplicateSummary{
+		PairCount:        t.pairs,
+		FailedCount:      t.failed,
+		StatusMatchCount: t.statusMatch,
+		BodyMatchCount:   t.bodyMatch,
+		Original:         t.original.summary(),
+		Duplicate:        t.duplicate.summary(),
+	}
+	if answered := t.pairs - t.failed; answered > 0 {
+		s.StatusMatchRate = float32(float64(t.statusMatch) / float64(answered))
+		s.BodyMatchRate = float32(float64(t.bodyMatch) / float64(answered))
+	}
+	if len(t.mismatches) > 0 {
+		s.StatusMismatches = make(map[string]int64, len(t.mismatches))
+		for k, n := range t.mismatches {
+			s.StatusMismatches[k] = n
+		}
+	}
+	return s
+}
+
+// formatStatusMismatches lists the status codes of the mismatched pairs by their counts, the most frequent first.
+func formatStatusMismatches(m map[string]int64) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s (%s)", k, formatCount(m[k]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,120 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"fmt"
+	"io"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// EstimateSummary is the load of the test estimated before the run, and the actual load of the run to compare with.
+type EstimateSummary struct {
+	Requests      int64 `json:"requests"`
+	UpperBound    bool  `json:"upper_bound,omitempty"`
+	BytesSent     int64 `json:"bytes_sent"`
+	BytesReceived int64 `json:"bytes_received,omitempty"`
+
+	// Cost of the estimated load and the budget, set if the test has a cost
+	Cost     *float64 `json:"cost,omitempty"`
+	Budget   float64  `json:"budget,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+
+	Actual EstimateActual `json:"actual"`
+
+	pricing *types.Cost
+}
+
+// EstimateActual is the actual load of the run, the requests of the steps and the teardown steps.
+type EstimateActual struct {
+	Requests      int64    `json:"requests"`
+	BytesSent     int64    `json:"bytes_sent"`
+	BytesReceived int64    `json:"bytes_received"`
+	Cost          *float64 `json:"cost,omitempty"`
+}
+
+func newEstimateSummary(e types.Estimate) *EstimateSummary {
+	s := &EstimateSummary{
+		Requests:      e.Requests,
+		UpperBound:    e.UpperBound,
+		BytesSent:     e.BytesSent,
+		BytesReceived: e.BytesReceived,
+		pricing:       e.Cost,
+	}
+	if e.Cost != nil {
+		price := e.Price()
+		s.Cost, s.Budget, s.Currency = &price, e.Cost.Budget, e.Cost.Currency
+	}
+	return s
+}
+
+// setEstimate compares the result with the estimate of the test, nil if the test has no estimate. The teardown of the
+// result is set before.
+func (r *Result) setEstimate(e *types.Estimate) {
+	if e == nil {
+		return
+	}
+	s := newEstimateSummary(*e)
+	for _, st := range r.StepResults {
+		s.Actual.Requests += st.SuccessCount + st.FailedCount
+	}
+	for _, t := range r.Teardown {
+		s.Actual.Requests += t.Requests
+	}
+	s.Actual.BytesSent, s.Actual.BytesReceived = r.BytesSent, r.BytesReceived
+	if s.pricing != nil {
+		cost := s.pricing.Of(s.Actual.Requests, s.Actual.BytesSent+s.Actual.BytesReceived)
+		s.Actual.Cost = &cost
+	}
+	r.Estimate = s
+}
+
+// printEstimate writes the estimate of the test, before the run without the actual load, w is a tabwriter of the
+// report.
+func printEstimate(w io.Writer, s *EstimateSummary, actual bool) {
+	if s == nil {
+		return
+	}
+	requests := formatCount(s.Requests)
+	if s.UpperBound {
+		requests = "up to " + requests
+	}
+	line := fmt.Sprintf("%s requests, %s sent", requests, formatBytes(s.BytesSent))
+	if s.BytesReceived > 0 {
+		line += fmt.Sprintf(", %s received", formatBytes(s.BytesReceived))
+	}
+	if s.Cost != nil {
+		line += ", " + s.pricing.Format(*s.Cost)
+		if s.Budget > 0 {
+			line += fmt.Sprintf(" (budget %s)", s.pricing.Format(s.Budget))
+		}
+	}
+	fmt.Fprintf(w, "Estimate:\t%s\n", line)
+	if !actual {
+		return
+	}
+	line = fmt.Sprintf("%s requests, %s sent, %s received", formatCount(s.Actual.Requests),
+		formatBytes(s.Actual.BytesSent), formatBytes(s.Actual.BytesReceived))
+	if s.Actual.Cost != nil {
+		line += ", " + s.pricing.Format(*s.Actual.Cost)
+	}
+	fmt.Fprintf(w, "Actual:\t%s\n", line)
+}
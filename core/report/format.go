@@ -0,0 +1,190 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatHuman = "human"
+	FormatRaw   = "raw"
+)
+
+// Separators of the locales whose numbers are written differently than the English ones, keyed by the language
+var localeSeparators = map[string]separators{
+	"da": {thousands: ".", decimal: ","},
+	"de": {thousands: ".", decimal: ","},
+	"es": {thousands: ".", decimal: ","},
+	"id": {thousands: ".", decimal: ","},
+	"it": {thousands: ".", decimal: ","},
+	"nl": {thousands: ".", decimal: ","},
+	"pt": {thousands: ".", decimal: ","},
+	"tr": {thousands: ".", decimal: ","},
+	"cs": {thousands: " ", decimal: ","},
+	"fi": {thousands: " ", decimal: ","},
+	"fr": {thousands: " ", decimal: ","},
+	"nb": {thousands: " ", decimal: ","},
+	"pl": {thousands: " ", decimal: ","},
+	"ru": {thousands: " ", decimal: ","},
+	"sv": {thousands: " ", decimal: ","},
+	"uk": {thousands: " ", decimal: ","},
+}
+
+type separators struct {
+	thousands string
+	decimal   string
+}
+
+var englishSeparators = separators{thousands: ",", decimal: "."}
+
+// Formatting of the numbers in the text outputs, the JSON outputs always have the raw values
+var numbers = struct {
+	human bool
+	separators
+}{separators: englishSeparators}
+
+// SetFormat sets the formatting of the durations, counts and percentages of the text outputs.
+// The raw format keeps the plain values like 0.0063s, the human format scales them like 6.31ms. The locale like
+// "de_DE.UTF-8" or "fr" sets the separators of the human format, empty locale uses the English ones.
+func SetFormat(format, locale string) error {
+	if format != FormatHuman && format != FormatRaw {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
+	// Language of the locale, like "de" of "de_DE.UTF-8"
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+
+	sep := englishSeparators
+	if lang != "" && lang != "en" && lang != "c" && lang != "posix" {
+		var ok bool
+		if sep, ok = localeSeparators[lang]; !ok {
+			return fmt.Errorf("unsupported format locale: %s", locale)
+		}
+	}
+
+	numbers.human = format == FormatHuman
+	numbers.separators = sep
+	return nil
+}
+
+// formatDuration formats the duration in seconds, the human format scales it to µs, ms or s with 3 significant
+// digits like 631µs, 6.31ms or 12.3s.
+func formatDuration(seconds float64) string {
+	return formatDurationPrec(seconds, 4)
+}
+
+// formatDurationPrec is formatDuration with the given decimals of the raw format.
+func formatDurationPrec(seconds float64, rawPrec int) string {
+	if !numbers.human {
+		return strconv.FormatFloat(seconds, 'f', rawPrec, 64) + "s"
+	}
+	if seconds == 0 {
+		return "0s"
+	}
+
+	v, unit := seconds*1e6, "µs"
+	for _, next := range []string{"ms", "s"} {
+		if math.Abs(v) < 999.5 {
+			break
+		}
+		v, unit = v/1000, next
+	}
+
+	prec := 0
+	switch a := math.Abs(v); {
+	case a < 9.995:
+		prec = 2
+	case a < 99.95:
+		prec = 1
+	}
+	return formatFloat(v, prec) + unit
+}
+
+// formatCount formats the count, the human format adds the thousands separators like 1,234,567.
+func formatCount(n int64) string {
+	if !numbers.human {
+		return strconv.FormatInt(n, 10)
+	}
+	return formatFloat(float64(n), 0)
+}
+
+// formatPercent formats the part of the whole as a percentage. The raw format keeps the integer percentage that is
+// calculated by the result, the human format shows one decimal like 99.5%.
+func formatPercent(raw int, part, whole int64) string {
+	if !numbers.human {
+		return strconv.Itoa(raw) + "%"
+	}
+	if whole == 0 {
+		return formatFloat(0, 1) + "%"
+	}
+	return formatFloat(float64(part)/float64(whole)*100, 1) + "%"
+}
+
+// formatSigned formats the value with its sign, like +1.5 or -0.3.
+func formatSigned(v float64, prec int) string {
+	s := formatNumber(math.Abs(v), prec)
+	if v < 0 && math.Round(v*math.Pow10(prec)) != 0 {
+		return "-" + s
+	}
+	return "+" + s
+}
+
+// formatRate formats the rate per second with one decimal like 1,234.5/s.
+func formatRate(rate float64) string {
+	return formatNumber(rate, 1) + "/s"
+}
+
+// formatNumber formats the value with the given decimals, the human format adds the separators.
+func formatNumber(v float64, prec int) string {
+	if !numbers.human {
+		return strconv.FormatFloat(v, 'f', prec, 64)
+	}
+	return formatFloat(v, prec)
+}
+
+// formatFloat formats the value with the given decimals and the separators of the locale.
+func formatFloat(v float64, prec int) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', prec, 64)
+	intPart, frac, _ := strings.Cut(s, ".")
+
+	b := strings.Builder{}
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(numbers.thousands)
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		b.WriteString(numbers.decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import "testing"
+
+// Not parallel since the format is shared by all the outputs
+func TestFormat(t *testing.T) {
+	defer SetFormat(FormatRaw, "")
+
+	tests := []struct {
+		name     string
+		format   string
+		locale   string
+		fn       func() string
+		expected string
+	}{
+		{"RawDuration", FormatRaw, "", func() string { return formatDuration(0.00631) }, "0.0063s"},
+		{"RawDurationPrec", FormatRaw, "", func() string { return formatDurationPrec(0.00631, 5) }, "0.00631s"},
+		{"RawCount", FormatRaw, "", func() string { return formatCount(1234567) }, "1234567"},
+		{"RawPercent", FormatRaw, "", func() string { return formatPercent(99, 995, 1000) }, "99%"},
+		{"RawRate", FormatRaw, "", func() string { return formatRate(1234.56) }, "1234.6/s"},
+		{"Microseconds", FormatHuman, "", func() string { return formatDuration(0.000631) }, "631µs"},
+		{"Milliseconds", FormatHuman, "", func() string { return formatDuration(0.00631) }, "6.31ms"},
+		{"TensOfMilliseconds", FormatHuman, "", func() string { return formatDuration(0.0631) }, "63.1ms"},
+		{"Seconds", FormatHuman, "", func() string { return formatDuration(1.2345) }, "1.23s"},
+		{"RoundedUpUnit", FormatHuman, "", func() string { return formatDuration(0.9996) }, "1.00s"},
+		{"LongSeconds", FormatHuman, "", func() string { return formatDuration(1234.6) }, "1,235s"},
+		{"ZeroDuration", FormatHuman, "", func() string { return formatDuration(0) }, "0s"},
+		{"Count", FormatHuman, "", func() string { return formatCount(1234567) }, "1,234,567"},
+		{"SmallCount", FormatHuman, "", func() string { return formatCount(123) }, "123"},
+		{"NegativeCount", FormatHuman, "", func() string { return formatCount(-1234) }, "-1,234"},
+		{"Percent", FormatHuman, "", func() string { return formatPercent(99, 995, 1000) }, "99.5%"},
+		{"ZeroPercent", FormatHuman, "", func() string { return formatPercent(0, 0, 0) }, "0.0%"},
+		{"Rate", FormatHuman, "", func() string { return formatRate(1234.56) }, "1,234.6/s"},
+		{"GermanCount", FormatHuman, "de_DE.UTF-8", func() string { return formatCount(1234567) }, "1.234.567"},
+		{"GermanDuration", FormatHuman, "de", func() string { return formatDuration(0.00631) }, "6,31ms"},
+		{"FrenchRate", FormatHuman, "fr-FR", func() string { return formatRate(1234.56) }, "1 234,6/s"},
+		{"EnglishLocale", FormatHuman, "en_US.UTF-8", func() string { return formatCount(1234) }, "1,234"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := SetFormat(test.format, test.locale); err != nil {
+				t.Fatalf("Error occurred %v", err)
+			}
+			if found := test.fn(); found != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, found)
+			}
+		})
+	}
+
+	for _, f := range [][2]string{{"pretty", ""}, {FormatHuman, "xx"}} {
+		if err := SetFormat(f[0], f[1]); err == nil {
+			t.Errorf("SetFormat(%q, %q) should be errored", f[0], f[1])
+		}
+	}
+}
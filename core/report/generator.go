@@ -0,0 +1,55 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+)
+
+// GeneratorHealth is the back-pressure of the result channel between the engine and the report service.
+// Iterations wait on sending their results once the channel is full, so a slow output delays the load.
+type GeneratorHealth struct {
+	ResultBufferSize int     `json:"result_buffer_size"`
+	AvgQueueLength   float32 `json:"avg_queue_length"` // Sampled on each tick of the engine
+	MaxQueueLength   int     `json:"max_queue_length"`
+	BlockedSends     int64   `json:"blocked_sends"`
+	BlockedDuration  float32 `json:"blocked_duration"` // Total of the waits of the iterations in seconds
+
+	// Set if BlockedDuration is over the result_block_warning of the test
+	BlockWarning bool `json:"block_warning,omitempty"`
+}
+
+// printGeneratorHealth prints the result channel stats if any iteration waited on the full channel.
+func printGeneratorHealth(w io.Writer, g *GeneratorHealth) {
+	if g == nil || g.BlockedSends == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Generator Health:")
+	fmt.Fprintf(w, "  Result Buffer:\t%s (avg %.1f, max %s queued)\n", formatCount(int64(g.ResultBufferSize)),
+		g.AvgQueueLength, formatCount(int64(g.MaxQueueLength)))
+	fmt.Fprintf(w, "  Blocked Sends:\t%s (%s in total)\n", formatCount(g.BlockedSends),
+		formatDuration(float64(g.BlockedDuration)))
+	if g.BlockWarning {
+		fmt.Fprintln(w, "  Results are delayed by the output, a larger result_buffer_size or a faster output is suggested")
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,362 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/live"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+const OutputTypeHeadless = "headless"
+
+// Progress logs are written to the stderr, so the stdout only has the final summary.
+var progressOut io.Writer = os.Stderr
+
+func init() {
+	AvailableOutputServices[OutputTypeHeadless] = &headless{}
+}
+
+// headless is the output for the containers and the CI runners. It writes a single-line structured progress log
+// periodically and the final summary as a single-line JSON object. Optionally, it serves the progress over HTTP
+// for the liveness probes.
+type headless struct {
+	stdoutJson
+
+	options     types.HeadlessOptions
+	aggregation *pipeline
+	progress    *progressTracker
+	start       time.Time
+	mu          sync.Mutex
+	finished    bool
+
+	// Renders the summary of the progress logs, nil if the test has no live template
+	live *live.Template
+
+	listener net.Listener
+	server   *http.Server
+
+	stopChan chan struct{}
+	logDone  chan struct{}
+}
+
+// headlessStatus is the progress of the test at a moment. Durations are in seconds.
+type headlessStatus struct {
+	Time        string   `json:"time"`
+	RunID       string   `json:"run_id,omitempty"`
+	Status      string   `json:"status"`
+	Elapsed     float64  `json:"elapsed"`
+	Progress    *int     `json:"progress,omitempty"`
+	ETA         *float64 `json:"eta,omitempty"`
+	Iterations  int64    `json:"iterations"`
+	Success     int64    `json:"success"`
+	Failed      int64    `json:"failed"`
+	RPS         float64  `json:"rps"`
+	AvgDuration float64  `json:"avg_duration"`
+
+	// Set while the gracefully stopped test is draining its in-flight iterations
+	InFlight           *int64 `json:"in_flight,omitempty"`
+	CompletedSinceStop *int64 `json:"completed_since_stop,omitempty"`
+
+	// Line rendered by the live template of the test, the same as the live line of the stdout output
+	Summary string `json:"summary,omitempty"`
+}
+
+func (h *headless) Init(debug bool) (err error) {
+	h.options = types.HeadlessOptions{
+		ProgressInterval: types.DefaultProgressInterval,
+		ProgressFormat:   types.DefaultProgressFormat,
+	}
+	return h.stdoutJson.Init(debug)
+}
+
+func (h *headless) SetLiveTemplate(t *live.Template) {
+	h.live = t.WithFuncs(liveFuncs)
+}
+
+func (h *headless) SetLoadPlan(tickInterval time.Duration, reqCountArr []int) {
+	h.progress = newProgressTracker(tickInterval, reqCountArr)
+}
+
+// SetHeadless applies the options and binds the healthcheck address, so an unavailable address fails the test
+// before it starts.
+func (h *headless) SetHeadless(o types.HeadlessOptions) (err error) {
+	if o.ProgressFormat == "" {
+		o.ProgressFormat = types.DefaultProgressFormat
+	}
+	h.options = o
+
+	if o.HealthcheckAddr == "" || h.debug {
+		return nil
+	}
+	if h.listener, err = net.Listen("tcp", o.HealthcheckAddr); err != nil {
+		return fmt.Errorf("healthcheck endpoint can not listen on %s: %v", o.HealthcheckAddr, err)
+	}
+	return nil
+}
+
+func (h *headless) Start(input chan *types.ScenarioResult) {
+	if h.debug {
+		h.printInDebugMode(input)
+		h.doneChan <- struct{}{}
+		return
+	}
+	if h.verifyCount > 0 {
+		// Verify mode reports the matrix once, there is no progress to log or serve.
+		if h.listener != nil {
+			h.listener.Close()
+		}
+		h.printInVerifyMode(input)
+		h.doneChan <- struct{}{}
+		return
+	}
+
+	h.mu.Lock()
+	h.start = h.timeSource().Now()
+	if h.progress != nil {
+		h.progress.begin(h.start)
+	}
+	h.limit.begin(h.timeSource())
+	h.aggregation = newPipeline(aggregationWorkers, h.steps, h.percentiles)
+	h.aggregation.limit = h.limit
+	h.mu.Unlock()
+	h.feedFrom(h.aggregation.window)
+
+	h.serve()
+	h.startProgressLog()
+
+	aborted := h.aggregation.run(input, h.abortChan)
+	h.mu.Lock()
+	h.result = h.aggregation.snapshot()
+	h.result.Partial = aborted
+	h.finished = true
+	h.mu.Unlock()
+
+	h.stopProgressLog()
+
+	h.mu.Lock()
+	if h.criteria != nil {
+		h.result.Criteria = h.criteria.Evaluate(h.result)
+	}
+	h.result.RunMetadata = h.metadata
+	h.result.RateChanges = h.rates.list()
+	h.result.setBursts(h.bursts.list())
+	h.result.Users = h.users.get()
+	h.result.Preflight = h.preflight
+	h.result.Config = h.config
+	h.result.Teardown = h.teardown
+	h.result.Generator = h.generator
+	h.result.setDrain(h.drainSummary())
+	h.result.setSchedule(h.schedule)
+	h.result.setEstimate(h.estimate)
+	h.result = h.anonymizer.result(h.result)
+	if err := writeTimelineFile(h.result); err != nil {
+		fmt.Fprintln(progressOut, err)
+	}
+	h.report()
+	h.mu.Unlock()
+
+	h.shutdown()
+	h.doneChan <- struct{}{}
+}
+
+func (h *headless) WriteStatus(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.aggregation == nil {
+		return
+	}
+	h.writeProgress(w, h.status(h.timeSource().Now()))
+}
+
+func (h *headless) CriteriaResult() *criteria.Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.result.Criteria
+}
+
+func (h *headless) startProgressLog() {
+	h.stopChan = make(chan struct{})
+	h.logDone = make(chan struct{})
+	if h.options.ProgressInterval <= 0 {
+		close(h.logDone)
+		return
+	}
+
+	go func() {
+		defer close(h.logDone)
+		ticker := h.timeSource().NewTicker(h.options.ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stopChan:
+				return
+			case now := <-ticker.C():
+				h.mu.Lock()
+				st := h.status(now)
+				h.mu.Unlock()
+				h.writeProgress(progressOut, st)
+			}
+		}
+	}()
+}
+
+func (h *headless) stopProgressLog() {
+	close(h.stopChan)
+	<-h.logDone
+}
+
+// status returns the progress of the test and refreshes the recent completion rate, h.mu should be held.
+func (h *headless) status(now time.Time) headlessStatus {
+	if !h.finished {
+		h.result = h.aggregation.snapshot()
+	}
+	completed := h.result.SuccessCount + h.result.FailedCount
+
+	st := headlessStatus{
+		Time:        now.UTC().Format(time.RFC3339),
+		RunID:       h.metadata.runID(),
+		Status:      "running",
+		Elapsed:     roundTo(now.Sub(h.start).Seconds(), 1),
+		Iterations:  completed,
+		Success:     h.result.SuccessCount,
+		Failed:      h.result.FailedCount,
+		AvgDuration: roundTo(float64(h.result.AvgDuration), 5),
+	}
+	if h.finished {
+		st.Status = "finished"
+	} else if d, ok := h.draining(); ok {
+		st.Status = "draining"
+		st.InFlight, st.CompletedSinceStop = &d.InFlight, &d.Completed
+	}
+
+	if h.progress != nil {
+		h.progress.update(completed, now)
+		st.RPS = roundTo(h.progress.rate, 1)
+
+		p := int(h.progress.ratio(completed) * 100)
+		st.Progress = &p
+		if eta, ok := h.progress.eta(completed, now); ok {
+			e := roundTo(eta.Seconds(), 1)
+			st.ETA = &e
+		}
+	}
+
+	if h.live != nil {
+		snapshot := liveSnapshot(h.result)
+		snapshot.Status, snapshot.Elapsed, snapshot.RPS = st.Status, st.Elapsed, st.RPS
+		if st.Progress != nil {
+			snapshot.Progress = *st.Progress
+		}
+		if st.ETA != nil {
+			snapshot.ETA = *st.ETA
+		}
+		st.Summary, _ = h.live.Render(snapshot)
+	}
+	return st
+}
+
+// writeProgress writes the status as a single line in the progress log format.
+func (h *headless) writeProgress(w io.Writer, st headlessStatus) {
+	if h.options.ProgressFormat == types.ProgressFormatJSON {
+		j, _ := json.Marshal(struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+			headlessStatus
+		}{Level: "info", Msg: "progress", headlessStatus: st})
+		fmt.Fprintln(w, string(j))
+		return
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "time=%s level=info msg=progress", st.Time)
+	if st.RunID != "" {
+		fmt.Fprintf(&b, " run_id=%s", st.RunID)
+	}
+	fmt.Fprintf(&b, " status=%s elapsed=%g", st.Status, st.Elapsed)
+	if st.Progress != nil {
+		fmt.Fprintf(&b, " progress=%d", *st.Progress)
+	}
+	if st.ETA != nil {
+		fmt.Fprintf(&b, " eta=%g", *st.ETA)
+	}
+	fmt.Fprintf(&b, " iterations=%d success=%d failed=%d rps=%g avg_duration=%g",
+		st.Iterations, st.Success, st.Failed, st.RPS, st.AvgDuration)
+	if st.InFlight != nil {
+		fmt.Fprintf(&b, " in_flight=%d completed_since_stop=%d", *st.InFlight, *st.CompletedSinceStop)
+	}
+	if st.Summary != "" {
+		fmt.Fprintf(&b, " summary=%s", strconv.Quote(st.Summary))
+	}
+	fmt.Fprintln(w, b.String())
+}
+
+// serve starts the healthcheck endpoint if its address is bound by SetHeadless.
+// "/healthz" is the liveness endpoint and "/progress" returns the status of the test as JSON.
+func (h *headless) serve() {
+	if h.listener == nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/progress", func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		st := h.status(h.timeSource().Now())
+		h.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+	})
+
+	h.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go h.server.Serve(h.listener)
+}
+
+func (h *headless) shutdown() {
+	if h.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	h.server.Shutdown(ctx)
+}
+
+func roundTo(v float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,283 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestHeadlessOutput(t *testing.T) {
+	oldPrintJson, oldProgressOut := printJson, progressOut
+	defer func() { printJson, progressOut = oldPrintJson, oldProgressOut }()
+
+	var output []byte
+	printJson = func(j []byte) {
+		output = j
+	}
+	progress := new(bytes.Buffer)
+	progressOut = progress
+
+	c := clock.NewFake(time.Now())
+	h := &headless{}
+	h.SetClock(c)
+	h.Init(false)
+	h.SetLoadPlan(100*time.Millisecond, []int{2, 2})
+	if err := h.SetHeadless(types.HeadlessOptions{
+		ProgressInterval: 20 * time.Millisecond,
+		ProgressFormat:   types.ProgressFormatJSON,
+	}); err != nil {
+		t.Fatalf("SetHeadless error occurred: %v", err)
+	}
+
+	input := make(chan *types.ScenarioResult)
+	go h.Start(input)
+	c.BlockUntil(1)
+	for i := 0; i < 4; i++ {
+		input <- &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StatusCode: 200, Duration: 10 * time.Millisecond},
+		}}
+		c.Advance(30 * time.Millisecond)
+	}
+	close(input)
+	<-h.DoneChan()
+
+	if bytes.ContainsRune(output, '\n') {
+		t.Errorf("Summary should be a single line, Found: %s", output)
+	}
+	summary := map[string]interface{}{}
+	if err := json.Unmarshal(output, &summary); err != nil {
+		t.Fatalf("Summary should be a JSON object: %v, Found: %s", err, output)
+	}
+	if summary["success_count"] != float64(4) {
+		t.Errorf("Expected success_count: 4, Found: %v", summary["success_count"])
+	}
+
+	lines := strings.Split(strings.TrimSpace(progress.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected 6 progress lines, Found: %d", len(lines))
+	}
+	for _, l := range lines {
+		st := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(l), &st); err != nil {
+			t.Fatalf("Progress line should be a JSON object: %v, Found: %s", err, l)
+		}
+		if st["msg"] != "progress" || st["status"] != "running" {
+			t.Errorf("Unexpected progress line: %s", l)
+		}
+	}
+}
+
+func TestHeadlessProgressDisabled(t *testing.T) {
+	oldPrintJson, oldProgressOut := printJson, progressOut
+	defer func() { printJson, progressOut = oldPrintJson, oldProgressOut }()
+
+	printJson = func(j []byte) {}
+	progress := new(bytes.Buffer)
+	progressOut = progress
+
+	h := &headless{}
+	h.Init(false)
+	h.SetHeadless(types.HeadlessOptions{ProgressInterval: 0})
+
+	input := make(chan *types.ScenarioResult, 1)
+	input <- &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200}}}
+	close(input)
+	go h.Start(input)
+	<-h.DoneChan()
+
+	if progress.Len() != 0 {
+		t.Errorf("Progress should not be logged, Found: %s", progress.String())
+	}
+}
+
+func TestHeadlessWriteProgress(t *testing.T) {
+	progress, eta := 40, 18.5
+	st := headlessStatus{
+		Time:        "2026-10-14T10:00:00Z",
+		Status:      "running",
+		Elapsed:     12,
+		Progress:    &progress,
+		ETA:         &eta,
+		Iterations:  120,
+		Success:     118,
+		Failed:      2,
+		RPS:         10.5,
+		AvgDuration: 0.01234,
+	}
+	inFlight, completedSinceStop := int64(3), int64(12)
+	draining := headlessStatus{Time: st.Time, Status: "draining", Iterations: 132, Success: 130, Failed: 2,
+		InFlight: &inFlight, CompletedSinceStop: &completedSinceStop}
+
+	tests := []struct {
+		name     string
+		format   string
+		status   headlessStatus
+		expected string
+	}{
+		{"Logfmt", types.ProgressFormatLogfmt, st,
+			"time=2026-10-14T10:00:00Z level=info msg=progress status=running elapsed=12 progress=40 eta=18.5 " +
+				"iterations=120 success=118 failed=2 rps=10.5 avg_duration=0.01234\n"},
+		{"LogfmtWithoutLoadPlan", types.ProgressFormatLogfmt, headlessStatus{Time: st.Time, Status: "running"},
+			"time=2026-10-14T10:00:00Z level=info msg=progress status=running elapsed=0 " +
+				"iterations=0 success=0 failed=0 rps=0 avg_duration=0\n"},
+		{"LogfmtWithRunID", types.ProgressFormatLogfmt, headlessStatus{Time: st.Time, RunID: "r1", Status: "running"},
+			"time=2026-10-14T10:00:00Z level=info msg=progress run_id=r1 status=running elapsed=0 " +
+				"iterations=0 success=0 failed=0 rps=0 avg_duration=0\n"},
+		{"LogfmtDraining", types.ProgressFormatLogfmt, draining,
+			"time=2026-10-14T10:00:00Z level=info msg=progress status=draining elapsed=0 " +
+				"iterations=132 success=130 failed=2 rps=0 avg_duration=0 in_flight=3 completed_since_stop=12\n"},
+		{"JSONDraining", types.ProgressFormatJSON, draining,
+			`{"level":"info","msg":"progress","time":"2026-10-14T10:00:00Z","status":"draining","elapsed":0,` +
+				`"iterations":132,"success":130,"failed":2,"rps":0,"avg_duration":0,"in_flight":3,` +
+				`"completed_since_stop":12}` + "\n"},
+		{"LogfmtSummary", types.ProgressFormatLogfmt, headlessStatus{Time: st.Time, Status: "running",
+			Summary: `10.5/s, p99 "checkout" 0.2500s`},
+			"time=2026-10-14T10:00:00Z level=info msg=progress status=running elapsed=0 " +
+				`iterations=0 success=0 failed=0 rps=0 avg_duration=0 summary="10.5/s, p99 \"checkout\" 0.2500s"` + "\n"},
+		{"JSONSummary", types.ProgressFormatJSON, headlessStatus{Time: st.Time, Status: "running", Summary: "10.5/s"},
+			`{"level":"info","msg":"progress","time":"2026-10-14T10:00:00Z","status":"running","elapsed":0,` +
+				`"iterations":0,"success":0,"failed":0,"rps":0,"avg_duration":0,"summary":"10.5/s"}` + "\n"},
+		{"JSON", types.ProgressFormatJSON, st,
+			`{"level":"info","msg":"progress","time":"2026-10-14T10:00:00Z","status":"running","elapsed":12,` +
+				`"progress":40,"eta":18.5,"iterations":120,"success":118,"failed":2,"rps":10.5,"avg_duration":0.01234}` + "\n"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			h := &headless{options: types.HeadlessOptions{ProgressFormat: test.format}}
+			b := new(bytes.Buffer)
+			h.writeProgress(b, test.status)
+
+			if b.String() != test.expected {
+				t.Errorf("Expected: %q, Found: %q", test.expected, b.String())
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestHeadlessHealthcheck(t *testing.T) {
+	oldPrintJson := printJson
+	defer func() { printJson = oldPrintJson }()
+	printJson = func(j []byte) {}
+
+	h := &headless{}
+	h.Init(false)
+	h.SetLoadPlan(100*time.Millisecond, []int{5})
+	if err := h.SetHeadless(types.HeadlessOptions{HealthcheckAddr: "127.0.0.1:0"}); err != nil {
+		t.Fatalf("SetHeadless error occurred: %v", err)
+	}
+	url := "http://" + h.listener.Addr().String()
+
+	input := make(chan *types.ScenarioResult, 1)
+	input <- &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200}}}
+	go h.Start(input)
+	time.Sleep(50 * time.Millisecond)
+
+	res, err := http.Get(url + "/healthz")
+	if err != nil {
+		t.Fatalf("Liveness endpoint error occurred: %v", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(body) != "ok\n" {
+		t.Errorf("Expected: 200 ok, Found: %d %s", res.StatusCode, body)
+	}
+
+	res, err = http.Get(url + "/progress")
+	if err != nil {
+		t.Fatalf("Progress endpoint error occurred: %v", err)
+	}
+	st := headlessStatus{}
+	err = json.NewDecoder(res.Body).Decode(&st)
+	res.Body.Close()
+	if err != nil {
+		t.Fatalf("Progress should be a JSON object: %v", err)
+	}
+	if st.Status != "running" || st.Iterations != 1 || st.Progress == nil || *st.Progress != 20 {
+		t.Errorf("Unexpected progress: %+v", st)
+	}
+
+	close(input)
+	<-h.DoneChan()
+	if _, err = http.Get(url + "/healthz"); err == nil {
+		t.Errorf("Healthcheck endpoint should be closed after the test")
+	}
+}
+
+func TestHeadlessHealthcheckAddrInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	h := &headless{}
+	h.Init(false)
+	if err = h.SetHeadless(types.HeadlessOptions{HealthcheckAddr: l.Addr().String()}); err == nil {
+		t.Errorf("SetHeadless should fail when the address is in use")
+	}
+}
+
+func TestHeadlessVerifyMode(t *testing.T) {
+	oldPrintJson, oldProgressOut := printJson, progressOut
+	defer func() { printJson, progressOut = oldPrintJson, oldProgressOut }()
+	var printed []string
+	printJson = func(j []byte) {
+		printed = append(printed, string(j))
+	}
+	progress := new(bytes.Buffer)
+	progressOut = progress
+
+	h := &headless{}
+	h.Init(false)
+	h.SetScenario(types.Scenario{Steps: []types.ScenarioStep{{ID: 1}}})
+	if err := h.SetHeadless(types.HeadlessOptions{HealthcheckAddr: "127.0.0.1:0"}); err != nil {
+		t.Fatalf("SetHeadless error occurred: %v", err)
+	}
+	h.SetVerify(1)
+
+	input := make(chan *types.ScenarioResult, 1)
+	input <- &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 500}}}
+	close(input)
+	go h.Start(input)
+	<-h.DoneChan()
+
+	if len(printed) != 1 || !strings.HasPrefix(printed[0], `{"verify":{"passed":false`) {
+		t.Errorf("Expected the single verify result, Found %v", printed)
+	}
+	if progress.Len() != 0 {
+		t.Errorf("Verify mode should not log the progress, Found %s", progress.String())
+	}
+	if r := h.VerifyResult(); r == nil || r.Passed {
+		t.Errorf("Verify should be failed, Found %#v", r)
+	}
+}
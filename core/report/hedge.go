@@ -0,0 +1,103 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// HedgeSummary is the hedging of the requests of a step. Hedges are the extra requests sent to the target, they are not
+// in the request counts of the step.
+type HedgeSummary struct {
+	HedgeCount    int64 `json:"hedge_count"`
+	HedgeWinCount int64 `json:"hedge_win_count"`
+
+	// Hedged requests over all the requests of the step, in [0, 1]
+	HedgeRate float32 `json:"hedge_rate"`
+
+	// Duration percentiles of the successful requests in seconds, with the hedging and without it by the durations of
+	// the first requests. First requests cancelled by a winning hedge count with their time until the cancel, so the
+	// percentiles without the hedging are a lower bound.
+	Hedged   HedgePercentiles `json:"hedged"`
+	Unhedged HedgePercentiles `json:"unhedged"`
+}
+
+type HedgePercentiles struct {
+	P50 float32 `json:"p50"`
+	P95 float32 `json:"p95"`
+	P99 float32 `json:"p99"`
+}
+
+// hedgeTracker counts the hedges of the requests of a step, created by the first request of a step with a hedge.
+type hedgeTracker struct {
+	requests, hedges, wins int64
+	hedged, unhedged       *histogram
+}
+
+func newHedgeTracker() *hedgeTracker {
+	return &hedgeTracker{hedged: newHistogram(), unhedged: newHistogram()}
+}
+
+func (t *hedgeTracker) add(sr *types.ScenarioStepResult, unhedged time.Duration) {
+	t.requests++
+	if _, ok := sr.Custom["hedged"]; ok {
+		t.hedges++
+	}
+	if _, ok := sr.Custom["hedgeWon"]; ok {
+		t.wins++
+	}
+	if sr.Err.Type == "" {
+		t.hedged.add(sr.Duration)
+		t.unhedged.add(unhedged)
+	}
+}
+
+func (t *hedgeTracker) merge(o *hedgeTracker) {
+	t.requests += o.requests
+	t.hedges += o.hedges
+	t.wins += o.wins
+	t.hedged.merge(o.hedged)
+	t.unhedged.merge(o.unhedged)
+}
+
+// summary returns the summary of the hedges, nil if the step has no hedge.
+func (t *hedgeTracker) summary() *HedgeSummary {
+	if t == nil || t.requests == 0 {
+		return nil
+	}
+	return &HedgeSummary{
+		HedgeCount:    t.hedges,
+		HedgeWinCount: t.wins,
+		HedgeRate:     float32(float64(t.hedges) / float64(t.requests)),
+		Hedged:        hedgePercentiles(t.hedged),
+		Unhedged:      hedgePercentiles(t.unhedged),
+	}
+}
+
+func hedgePercentiles(h *histogram) HedgePercentiles {
+	return HedgePercentiles{
+		P50: float32(h.percentile(50).Seconds()),
+		P95: float32(h.percentile(95).Seconds()),
+		P99: float32(h.percentile(99).Seconds()),
+	}
+}
@@ -0,0 +1,99 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Each bucket of the histogram is histogramGrowth times wider than the previous one,
+// so the percentiles are accurate within 1% for any duration range with a bounded memory.
+const histogramGrowth = 1.02
+
+var logHistogramGrowth = math.Log(histogramGrowth)
+
+// histogram counts the durations in logarithmic buckets of microseconds. Bucket 0 is for the durations under 1µs.
+type histogram struct {
+	counts map[int]int64
+	total  int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make(map[int]int64)}
+}
+
+func (h *histogram) add(d time.Duration) {
+	h.counts[histogramBucket(d)]++
+	h.total++
+}
+
+func (h *histogram) merge(o *histogram) {
+	for b, c := range o.counts {
+		h.counts[b] += c
+	}
+	h.total += o.total
+}
+
+// percentile returns the p-th percentile of the durations by the nearest-rank method.
+func (h *histogram) percentile(p int) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	buckets := make([]int, 0, len(h.counts))
+	for b := range h.counts {
+		buckets = append(buckets, b)
+	}
+	sort.Ints(buckets)
+
+	rank := int64(math.Ceil(float64(p) / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, b := range buckets {
+		seen += h.counts[b]
+		if seen >= rank {
+			return histogramValue(b)
+		}
+	}
+	return histogramValue(buckets[len(buckets)-1])
+}
+
+func histogramBucket(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us < 1 {
+		return 0
+	}
+	return int(math.Log(us)/logHistogramGrowth) + 1
+}
+
+// histogramValue returns the middle of the bucket.
+func histogramValue(b int) time.Duration {
+	if b == 0 {
+		return 0
+	}
+	lower := math.Pow(histogramGrowth, float64(b-1))
+	upper := lower * histogramGrowth
+	return time.Duration((lower + upper) / 2 * float64(time.Microsecond))
+}
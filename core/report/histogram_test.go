@@ -0,0 +1,96 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// histogramPercentiles returns the percentiles of the given durations as they are reported by the default sketch.
+func histogramPercentiles(durations ...time.Duration) *PercentileSummary {
+	h := logSketch{newHistogram()}
+	for _, d := range durations {
+		h.add(d)
+	}
+	return percentileSummary(h, types.PercentileSketch{})
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	h := newHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.add(time.Duration(i) * time.Millisecond)
+	}
+
+	tests := []struct {
+		p        int
+		expected time.Duration
+	}{
+		{50, 500 * time.Millisecond},
+		{90, 900 * time.Millisecond},
+		{95, 950 * time.Millisecond},
+		{99, 990 * time.Millisecond},
+		{100, 1000 * time.Millisecond},
+	}
+	for _, test := range tests {
+		found := h.percentile(test.p)
+		if diff := math.Abs(float64(found-test.expected)) / float64(test.expected); diff > 0.01 {
+			t.Errorf("p%d: Expected %s, Found %s", test.p, test.expected, found)
+		}
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	h1, h2, all := newHistogram(), newHistogram(), newHistogram()
+	for i := 1; i <= 100; i++ {
+		d := time.Duration(i*i) * time.Microsecond
+		if i%2 == 0 {
+			h1.add(d)
+		} else {
+			h2.add(d)
+		}
+		all.add(d)
+	}
+	h1.merge(h2)
+
+	if h1.total != all.total {
+		t.Errorf("Total Expected %d, Found %d", all.total, h1.total)
+	}
+	for _, p := range []int{1, 50, 99} {
+		if h1.percentile(p) != all.percentile(p) {
+			t.Errorf("p%d: Expected %s, Found %s", p, all.percentile(p), h1.percentile(p))
+		}
+	}
+}
+
+func TestHistogramEmptyAndSubMicrosecond(t *testing.T) {
+	h := newHistogram()
+	if p := h.percentile(50); p != 0 {
+		t.Errorf("Percentile of the empty histogram should be 0, Found %s", p)
+	}
+	h.add(500 * time.Nanosecond)
+	if p := h.percentile(50); p != 0 {
+		t.Errorf("Sub-microsecond durations should be in the first bucket, Found %s", p)
+	}
+}
@@ -0,0 +1,224 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Width of the bars of the shares of the iteration time
+const iterationBarWidth = 20
+
+// IterationTimeSummary is the wall time of the iterations and the shares of their steps and sleeps in it.
+type IterationTimeSummary struct {
+	// Iterations with a wall time, the successful and the failed ones
+	Iterations int64 `json:"iterations"`
+
+	// Average wall time of the iterations in seconds, from the start of their first step to the end of the sleep of
+	// their last step
+	AvgDuration float32 `json:"avg_duration"`
+
+	// Average time of the iterations out of their steps and sleeps, like the captures and the scripts, in seconds
+	// and its share of the wall time in [0, 1]
+	AvgOther   float32 `json:"avg_other"`
+	OtherShare float32 `json:"other_share"`
+
+	Steps []StepTimeSummary `json:"steps"`
+}
+
+// StepTimeSummary is the time of a step in the iterations that ran it. The shares are over the wall time of these
+// iterations only, so a step that doesn't run in every iteration doesn't lower the shares of the others.
+type StepTimeSummary struct {
+	ID         uint16 `json:"id"`
+	Name       string `json:"name"`
+	Iterations int64  `json:"iterations"`
+
+	// Averages of the duration of the step, the failed requests included, and of the sleep and the Retry-After
+	// backoff after it in seconds, and their shares of the wall time in [0, 1]
+	AvgDuration float32 `json:"avg_duration"`
+	AvgSleep    float32 `json:"avg_sleep"`
+	Share       float32 `json:"share"`
+	SleepShare  float32 `json:"sleep_share"`
+
+	// Parallel group of the step, the steps of a group overlap so their shares add up to more than the group takes
+	ParallelGroup string `json:"parallel_group,omitempty"`
+}
+
+// iterationTimeTracker accumulates the wall times of the iterations and the times of their steps, created by the
+// first iteration with a wall time.
+type iterationTimeTracker struct {
+	count    int64
+	wallSum  time.Duration
+	otherSum time.Duration
+	steps    map[uint16]*stepTime
+}
+
+type stepTime struct {
+	name          string
+	parallelGroup string
+	iterations    int64
+	durationSum   time.Duration
+	sleepSum      time.Duration
+
+	// Wall time of the iterations that ran the step
+	wallSum time.Duration
+}
+
+func newIterationTimeTracker() *iterationTimeTracker {
+	return &iterationTimeTracker{steps: make(map[uint16]*stepTime)}
+}
+
+// add adds an iteration, stepsDuration is the time of its steps with the parallel groups taking as long as their
+// slowest step.
+func (t *iterationTimeTracker) add(scr *types.ScenarioResult, stepsDuration time.Duration) {
+	t.count++
+	t.wallSum += scr.Duration
+	other := scr.Duration - stepsDuration
+	for _, sr := range scr.StepResults {
+		st := t.step(sr.StepID, sr.StepName)
+		if g, ok := sr.Custom["parallelGroup"].(string); ok {
+			st.parallelGroup = g
+		}
+		sleep, _ := sr.Custom["sleepTime"].(time.Duration)
+		st.iterations++
+		st.durationSum += sr.Duration
+		st.sleepSum += sleep
+		st.wallSum += scr.Duration
+		other -= sleep
+	}
+	// The clocks of the steps and of the iteration are read apart, the difference can be slightly negative
+	if other > 0 {
+		t.otherSum += other
+	}
+}
+
+func (t *iterationTimeTracker) step(id uint16, name string) *stepTime {
+	st, ok := t.steps[id]
+	if !ok {
+		st = &stepTime{name: name}
+		t.steps[id] = st
+	}
+	return st
+}
+
+func (t *iterationTimeTracker) merge(o *iterationTimeTracker) {
+	t.count += o.count
+	t.wallSum += o.wallSum
+	t.otherSum += o.otherSum
+	for id, os := range o.steps {
+		st := t.step(id, os.name)
+		if os.parallelGroup != "" {
+			st.parallelGroup = os.parallelGroup
+		}
+		st.iterations += os.iterations
+		st.durationSum += os.durationSum
+		st.sleepSum += os.sleepSum
+		st.wallSum += os.wallSum
+	}
+}
+
+// summary returns the summary of the iterations sorted by the step IDs, nil for a single step scenario where the
+// step takes all the time.
+func (t *iterationTimeTracker) summary() *IterationTimeSummary {
+	if t == nil || len(t.steps) < 2 {
+		return nil
+	}
+	s := &IterationTimeSummary{
+		Iterations:  t.count,
+		AvgDuration: avgSeconds(t.wallSum, t.count),
+		AvgOther:    avgSeconds(t.otherSum, t.count),
+		OtherShare:  timeShare(t.otherSum, t.wallSum),
+		Steps:       make([]StepTimeSummary, 0, len(t.steps)),
+	}
+	for id, st := range t.steps {
+		s.Steps = append(s.Steps, StepTimeSummary{
+			ID:            id,
+			Name:          st.name,
+			Iterations:    st.iterations,
+			AvgDuration:   avgSeconds(st.durationSum, st.iterations),
+			AvgSleep:      avgSeconds(st.sleepSum, st.iterations),
+			Share:         timeShare(st.durationSum, st.wallSum),
+			SleepShare:    timeShare(st.sleepSum, st.wallSum),
+			ParallelGroup: st.parallelGroup,
+		})
+	}
+	sort.Slice(s.Steps, func(i, j int) bool { return s.Steps[i].ID < s.Steps[j].ID })
+	return s
+}
+
+// timeShare returns the part of the whole in [0, 1], 0 if the whole is not positive.
+func timeShare(part, whole time.Duration) float32 {
+	if whole <= 0 {
+		return 0
+	}
+	return float32(float64(part) / float64(whole))
+}
+
+// printIterationTime writes the iteration time section of the report, w is a tabwriter of the report. The steps are
+// in the order of the scenario, each with the bar of its share of the wall time and the sleep after it.
+func printIterationTime(w io.Writer, t *IterationTimeSummary, run *RunInfo) {
+	if t == nil || t.Iterations == 0 {
+		return
+	}
+	ids := make([]int, 0, len(t.Steps))
+	steps := make(map[int]StepTimeSummary, len(t.Steps))
+	for _, st := range t.Steps {
+		ids = append(ids, int(st.ID))
+		steps[int(st.ID)] = st
+	}
+	run.sortSteps(ids)
+
+	fmt.Fprintf(w, "Iteration Time (Avg. %s over %s iterations, Share:Avg. Time):\n",
+		formatDuration(float64(t.AvgDuration)), formatCount(t.Iterations))
+	for _, id := range ids {
+		st := steps[id]
+		name := st.Name
+		if name == "" {
+			name = fmt.Sprintf("Step %d", id)
+		}
+		fmt.Fprintf(w, "  %d. %s\t%s\t%s\t:%s", id, name, progressBar(float64(st.Share), iterationBarWidth),
+			formatShare(st.Share), formatDuration(float64(st.AvgDuration)))
+		if st.Iterations < t.Iterations {
+			fmt.Fprintf(w, "\tin %s/%s iterations", formatCount(st.Iterations), formatCount(t.Iterations))
+		}
+		if st.ParallelGroup != "" {
+			fmt.Fprintf(w, "\tparallel %s", st.ParallelGroup)
+		}
+		fmt.Fprintln(w)
+		if st.AvgSleep > 0 {
+			fmt.Fprintf(w, "     sleep\t%s\t%s\t:%s\n", progressBar(float64(st.SleepShare), iterationBarWidth),
+				formatShare(st.SleepShare), formatDuration(float64(st.AvgSleep)))
+		}
+	}
+	fmt.Fprintf(w, "  Other\t%s\t%s\t:%s\n", progressBar(float64(t.OtherShare), iterationBarWidth),
+		formatShare(t.OtherShare), formatDuration(float64(t.AvgOther)))
+	fmt.Fprintln(w)
+}
+
+// formatShare formats the share in [0, 1] as a percentage with one decimal like 35.2%.
+func formatShare(s float32) string {
+	return formatNumber(float64(s)*100, 1) + "%"
+}
@@ -0,0 +1,158 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Responses kept per pattern of each step as the samples of its matches, bounded regardless of the match count.
+const maxLeakSamples = 3
+
+// LeakScanSummary is the scan of the sampled response bodies of a step for the sensitive data, with the count of the
+// responses matching each pattern.
+type LeakScanSummary struct {
+	Scanned int64                   `json:"scanned"`
+	Matches map[string]int64        `json:"matches,omitempty"`
+	Samples map[string][]LeakSample `json:"samples,omitempty"`
+}
+
+// LeakSample is a response matching a pattern, with the match and its context in the body.
+type LeakSample struct {
+	Time       time.Time `json:"time"`
+	URL        string    `json:"url" anonymize:"url"`
+	StatusCode int       `json:"status_code"`
+	Excerpt    string    `json:"excerpt"`
+}
+
+// before orders the samples by their times, ties are broken by the other fields like the failure samples.
+func (s LeakSample) before(o LeakSample) bool {
+	if !s.Time.Equal(o.Time) {
+		return s.Time.Before(o.Time)
+	}
+	if s.StatusCode != o.StatusCode {
+		return s.StatusCode < o.StatusCode
+	}
+	if s.URL != o.URL {
+		return s.URL < o.URL
+	}
+	return s.Excerpt < o.Excerpt
+}
+
+// leakTracker counts the scanned responses of a step and their matches, created by the first scanned response.
+type leakTracker struct {
+	scanned int64
+	matches map[string]int64
+
+	// Earliest maxLeakSamples matching responses of each pattern, ordered by their times
+	samples map[string][]LeakSample
+}
+
+func newLeakTracker() *leakTracker {
+	return &leakTracker{matches: make(map[string]int64), samples: make(map[string][]LeakSample)}
+}
+
+// add counts the scan of the response of the request.
+func (l *leakTracker) add(sr *types.ScenarioStepResult, scan types.LeakScanResult) {
+	l.scanned++
+	for _, m := range scan.Matches {
+		l.matches[m.Pattern]++
+		l.addSample(m.Pattern, LeakSample{Time: sr.RequestTime, URL: scan.URL, StatusCode: sr.StatusCode,
+			Excerpt: m.Excerpt})
+	}
+}
+
+// addSample keeps the sample if the pattern has room for it or it is earlier than the last kept sample.
+func (l *leakTracker) addSample(pattern string, s LeakSample) {
+	samples := l.samples[pattern]
+	if len(samples) == maxLeakSamples && !s.before(samples[len(samples)-1]) {
+		return
+	}
+
+	i := sort.Search(len(samples), func(i int) bool { return s.before(samples[i]) })
+	samples = append(samples, LeakSample{})
+	copy(samples[i+1:], samples[i:])
+	samples[i] = s
+	if len(samples) > maxLeakSamples {
+		samples = samples[:maxLeakSamples]
+	}
+	l.samples[pattern] = samples
+}
+
+func (l *leakTracker) merge(o *leakTracker) {
+	l.scanned += o.scanned
+	for p, n := range o.matches {
+		l.matches[p] += n
+	}
+	for p, samples := range o.samples {
+		for _, s := range samples {
+			l.addSample(p, s)
+		}
+	}
+}
+
+// summary returns the summary of the scans, nil if no response is scanned.
+func (l *leakTracker) summary() *LeakScanSummary {
+	if l == nil || l.scanned == 0 {
+		return nil
+	}
+	s := &LeakScanSummary{Scanned: l.scanned}
+	if len(l.matches) > 0 {
+		s.Matches = make(map[string]int64, len(l.matches))
+		s.Samples = make(map[string][]LeakSample, len(l.samples))
+		for p, n := range l.matches {
+			s.Matches[p] = n
+			s.Samples[p] = append([]LeakSample(nil), l.samples[p]...)
+		}
+	}
+	return s
+}
+
+// printLeakScan prints the matches of the patterns in the order of their counts, with their samples.
+func printLeakScan(w io.Writer, l *LeakScanSummary) {
+	fmt.Fprintf(w, "\nSecurity (Leak Scan of %s Responses, Pattern:Matching Responses):\n", formatCount(l.Scanned))
+	if len(l.Matches) == 0 {
+		fmt.Fprintln(w, "  No sensitive data found")
+		return
+	}
+	patterns := make([]string, 0, len(l.Matches))
+	for p := range l.Matches {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if l.Matches[patterns[i]] != l.Matches[patterns[j]] {
+			return l.Matches[patterns[i]] > l.Matches[patterns[j]]
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, p := range patterns {
+		fmt.Fprintf(w, "  %s\t:%s\n", p, formatCount(l.Matches[p]))
+		for _, s := range l.Samples[p] {
+			fmt.Fprintf(w, "    %s\t:%d\t:%s\n", s.Time.Local().Format("15:04:05.000"), s.StatusCode, s.URL)
+			fmt.Fprintf(w, "      %s\n", strconv.Quote(s.Excerpt))
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"text/template"
+
+	"go.ddosify.com/ddosify/core/live"
+)
+
+// liveFuncs format the values of the live template like the rest of the text outputs
+var liveFuncs = template.FuncMap{
+	"duration": formatDuration,
+	"count":    formatCount,
+	"bytes":    formatBytes,
+	"rate":     formatRate,
+	"percent":  func(p float64) string { return formatNumber(p, 1) + "%" },
+}
+
+// liveSnapshot returns the counts and the durations of the result for the live template, the status, the elapsed
+// time, the progress and the rate are set by the caller.
+func liveSnapshot(r *Result) live.Snapshot {
+	s := live.Snapshot{
+		Iterations:    r.SuccessCount + r.FailedCount,
+		Success:       r.SuccessCount,
+		Failed:        r.FailedCount,
+		AvgDuration:   float64(r.AvgDuration),
+		BytesSent:     r.BytesSent,
+		BytesReceived: r.BytesReceived,
+		Steps:         make(map[uint16]live.StepSnapshot, len(r.StepResults)),
+	}
+	s.SuccessRate, s.FailRate = liveRates(r.SuccessCount, r.FailedCount)
+	for id, st := range r.StepResults {
+		step := live.StepSnapshot{
+			Name:          st.Name,
+			Success:       st.SuccessCount,
+			Failed:        st.FailedCount,
+			AvgDuration:   float64(st.Durations["duration"]),
+			P50:           float64(st.percentiles[50]),
+			P90:           float64(st.percentiles[90]),
+			P95:           float64(st.percentiles[95]),
+			P99:           float64(st.percentiles[99]),
+			BytesSent:     st.BytesSent,
+			BytesReceived: st.BytesReceived,
+		}
+		step.SuccessRate, step.FailRate = liveRates(st.SuccessCount, st.FailedCount)
+		s.Steps[id] = step
+	}
+	return s
+}
+
+// liveRates returns the success and the fail percentages, 0 if there is no result yet.
+func liveRates(success, failed int64) (float64, float64) {
+	if success+failed == 0 {
+		return 0, 0
+	}
+	successRate := float64(success) / float64(success+failed) * 100
+	return successRate, 100 - successRate
+}
@@ -0,0 +1,105 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// RunMetadata identifies the run in the records of the outputs, the values of the secret labels are redacted.
+type RunMetadata struct {
+	RunID      string            `json:"run_id"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	ConfigHash string            `json:"config_hash,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Hostname   string            `json:"hostname,omitempty" anonymize:"host"`
+
+	// Start of the run with a warm state, warm or cold
+	Start string `json:"start,omitempty"`
+
+	// Redacted labels as key=value pairs sorted by their keys
+	sortedLabels []string
+}
+
+func newRunMetadata(m types.Metadata) *RunMetadata {
+	return &RunMetadata{
+		RunID:        m.RunID,
+		Labels:       m.RedactedLabels(),
+		ConfigHash:   m.ConfigHash,
+		Version:      m.Version,
+		Hostname:     m.Hostname,
+		Start:        m.Start,
+		sortedLabels: m.SortedLabels(),
+	}
+}
+
+// labels returns the labels in the "key=value key=value" format.
+func (m *RunMetadata) labels() string {
+	if m == nil {
+		return ""
+	}
+	return strings.Join(m.sortedLabels, " ")
+}
+
+// anonymized rebuilds the printed labels from the anonymized labels.
+func (m *RunMetadata) anonymized() {
+	m.sortedLabels = make([]string, 0, len(m.Labels))
+	for k, v := range m.Labels {
+		m.sortedLabels = append(m.sortedLabels, k+"="+v)
+	}
+	sort.Strings(m.sortedLabels)
+}
+
+// runID returns the run id, empty if the metadata is not set.
+func (m *RunMetadata) runID() string {
+	if m == nil {
+		return ""
+	}
+	return m.RunID
+}
+
+// printMetadata writes the metadata lines of the report header, w is a tabwriter of the report.
+func printMetadata(w io.Writer, m *RunMetadata) {
+	if m == nil {
+		return
+	}
+	fmt.Fprintf(w, "Run ID: %s\n", m.RunID)
+	if l := m.labels(); l != "" {
+		fmt.Fprintf(w, "Labels: %s\n", l)
+	}
+	if m.Version != "" {
+		fmt.Fprintf(w, "Version: %s\n", m.Version)
+	}
+	if m.Hostname != "" {
+		fmt.Fprintf(w, "Hostname: %s\n", m.Hostname)
+	}
+	if m.ConfigHash != "" {
+		fmt.Fprintf(w, "Config Hash: %s\n", m.ConfigHash)
+	}
+	if m.Start != "" {
+		fmt.Fprintf(w, "Start: %s\n", m.Start)
+	}
+}
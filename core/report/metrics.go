@@ -0,0 +1,120 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// totalDurationKey is the duration key of the whole step, added by the aggregator for all the requester types.
+const totalDurationKey = "duration"
+
+// metricName is the rendering of a duration or a count key of the step results.
+type metricName struct {
+	name    string
+	jsonKey string
+	order   int
+}
+
+// metricNames renders the duration and count keys by the metadata declared by the requesters of the steps.
+type metricNames map[uint16]map[string]metricName
+
+func newMetricNames(meta map[uint16][]types.MetricMeta) metricNames {
+	m := make(metricNames, len(meta))
+	for id, metas := range meta {
+		names := make(map[string]metricName, len(metas))
+		for i, mm := range metas {
+			names[mm.Key] = metricName{name: mm.Name, jsonKey: mm.JSONKey, order: i}
+		}
+		m[id] = names
+	}
+	return m
+}
+
+// lookup returns the rendering of the key of the given step. Keys not declared by the requester are rendered by their
+// words, after the declared ones. Total duration is always the last one.
+func (m metricNames) lookup(stepID uint16, key string) metricName {
+	if key == totalDurationKey {
+		return metricName{name: "Total", jsonKey: "total", order: math.MaxInt32}
+	}
+	if n, ok := m[stepID][key]; ok {
+		return n
+	}
+	words := metricWords(key)
+	if len(words) == 0 {
+		return metricName{name: key, jsonKey: key, order: math.MaxInt32 - 1}
+	}
+	name, jsonKey := make([]string, len(words)), make([]string, len(words))
+	for i, w := range words {
+		name[i] = strings.ToUpper(w[:1]) + w[1:]
+		jsonKey[i] = strings.ToLower(w)
+	}
+	return metricName{name: strings.Join(name, " "), jsonKey: strings.Join(jsonKey, "_"),
+		order: math.MaxInt32 - 1}
+}
+
+// sorted returns the keys of the given values in the display order of the step.
+func (m metricNames) sorted(stepID uint16, values map[string]float32) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		oi, oj := m.lookup(stepID, keys[i]).order, m.lookup(stepID, keys[j]).order
+		if oi == oj {
+			return keys[i] < keys[j]
+		}
+		return oi < oj
+	})
+	return keys
+}
+
+// metricWords splits the camel case key into its words, without the trailing "Duration" of the duration keys.
+// Acronyms are kept as one word, so "queueWaitDuration" is "queue Wait" and "DNSLookupDuration" is "DNS Lookup".
+func metricWords(key string) []string {
+	r := []rune(strings.TrimSuffix(key, "Duration"))
+	var words []string
+	var word []rune
+	for i, c := range r {
+		if c == '_' || c == '-' {
+			words, word = appendWord(words, word), nil
+			continue
+		}
+		// An upper case letter starts a word, unless it is in an acronym not followed by a lower case letter
+		if i > 0 && unicode.IsUpper(c) && (!unicode.IsUpper(r[i-1]) || i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			words, word = appendWord(words, word), nil
+		}
+		word = append(word, c)
+	}
+	return appendWord(words, word)
+}
+
+func appendWord(words []string, word []rune) []string {
+	if len(word) == 0 {
+		return words
+	}
+	return append(words, string(word))
+}
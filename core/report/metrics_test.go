@@ -0,0 +1,88 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestMetricNames(t *testing.T) {
+	t.Parallel()
+	m := newMetricNames(map[uint16][]types.MetricMeta{
+		1: {
+			{Key: "handshakeDuration", Name: "Handshake", JSONKey: "handshake"},
+			{Key: "frameDuration", Name: "Frame", JSONKey: "frame"},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		stepID   uint16
+		key      string
+		expected metricName
+	}{
+		{"Declared", 1, "frameDuration", metricName{name: "Frame", jsonKey: "frame", order: 1}},
+		{"Total", 1, "duration", metricName{name: "Total", jsonKey: "total", order: 1<<31 - 1}},
+		{"Unknown", 1, "queueDuration", metricName{name: "Queue", jsonKey: "queue", order: 1<<31 - 2}},
+		{"UnknownStep", 2, "frameDuration", metricName{name: "Frame", jsonKey: "frame", order: 1<<31 - 2}},
+		{"UnknownWords", 1, "queueWaitDuration", metricName{name: "Queue Wait", jsonKey: "queue_wait", order: 1<<31 - 2}},
+		{"UnknownAcronym", 1, "DNSLookupDuration", metricName{name: "DNS Lookup", jsonKey: "dns_lookup", order: 1<<31 - 2}},
+		{"UnknownTrailingAcronym", 1, "timeToTTFBDuration", metricName{name: "Time To TTFB", jsonKey: "time_to_ttfb",
+			order: 1<<31 - 2}},
+		{"UnknownCount", 1, "retryCount", metricName{name: "Retry Count", jsonKey: "retry_count", order: 1<<31 - 2}},
+		{"UnknownSnakeCase", 1, "gc_pause-Duration", metricName{name: "Gc Pause", jsonKey: "gc_pause", order: 1<<31 - 2}},
+		{"UnknownOnlySuffix", 1, "Duration", metricName{name: "Duration", jsonKey: "Duration", order: 1<<31 - 2}},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			if got := m.lookup(test.stepID, test.key); got != test.expected {
+				t.Errorf("Expected %#v, Found %#v", test.expected, got)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestMetricNamesSorted(t *testing.T) {
+	t.Parallel()
+	m := newMetricNames(map[uint16][]types.MetricMeta{
+		1: {
+			{Key: "handshakeDuration", Name: "Handshake", JSONKey: "handshake"},
+			{Key: "frameDuration", Name: "Frame", JSONKey: "frame"},
+		},
+	})
+	values := map[string]float32{
+		"duration":          3,
+		"zDuration":         1,
+		"frameDuration":     1,
+		"aDuration":         1,
+		"handshakeDuration": 1,
+	}
+
+	expected := []string{"handshakeDuration", "frameDuration", "aDuration", "zDuration", "duration"}
+	if got := m.sorted(1, values); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, Found %v", expected, got)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Observations enables the analysis of the final result that calls out the anomalies of the steps, like a slow tail
+// or an error that appeared late in the run.
+var Observations bool
+
+// Rules of the observations
+const (
+	ObservationTailLatency    = "tail_latency"
+	ObservationLateError      = "late_error"
+	ObservationLateStatusCode = "late_status_code"
+)
+
+const (
+	// p99 of the steps with fewer successful requests is not reliable enough to call out a slow tail
+	minTailRequests = 100
+	tailRatio       = 10
+)
+
+// Observation is a plain-language finding of the analysis with its supporting numbers.
+type Observation struct {
+	StepID  uint16 `json:"step_id"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// firstSeen keeps the first request times of the step with its error reasons and status codes for the analysis.
+type firstSeen struct {
+	request time.Time
+	errors  map[string]time.Time
+	codes   map[int]time.Time
+}
+
+func (f *firstSeen) addRequest(t time.Time) {
+	if f.request.IsZero() || t.Before(f.request) {
+		f.request = t
+	}
+}
+
+func (f *firstSeen) addError(reason string, t time.Time) {
+	if f.errors == nil {
+		f.errors = make(map[string]time.Time)
+	}
+	if first, ok := f.errors[reason]; !ok || t.Before(first) {
+		f.errors[reason] = t
+	}
+}
+
+func (f *firstSeen) addCode(code int, t time.Time) {
+	if f.codes == nil {
+		f.codes = make(map[int]time.Time)
+	}
+	if first, ok := f.codes[code]; !ok || t.Before(first) {
+		f.codes[code] = t
+	}
+}
+
+// merge keeps the earlier times of the other. Error reasons are merged by the caller, since they may be counted
+// under another reason after the merge.
+func (f *firstSeen) merge(o firstSeen) {
+	if !o.request.IsZero() {
+		f.addRequest(o.request)
+	}
+	for code, t := range o.codes {
+		f.addCode(code, t)
+	}
+}
+
+func (f firstSeen) clone() firstSeen {
+	c := firstSeen{request: f.request}
+	for reason, t := range f.errors {
+		c.addError(reason, t)
+	}
+	for code, t := range f.codes {
+		c.addCode(code, t)
+	}
+	return c
+}
+
+// observe analyzes the result. The findings are ordered by the step ids and the rules, so the same results
+// give the same findings regardless of the aggregation order.
+func observe(r *Result) []Observation {
+	ids := make([]int, 0, len(r.StepResults))
+	for id := range r.StepResults {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	span := r.end.Sub(r.start)
+	firstQuarter, lastQuarter := r.start.Add(span/4), r.start.Add(span*3/4)
+
+	var observations []Observation
+	for _, id := range ids {
+		s := r.StepResults[uint16(id)]
+		add := func(rule, format string, a ...interface{}) {
+			observations = append(observations, Observation{StepID: uint16(id), Rule: rule, Message: fmt.Sprintf(format, a...)})
+		}
+
+		if p50, p99 := s.percentiles[50], s.percentiles[99]; s.SuccessCount >= minTailRequests && p50 > 0 &&
+			p99 > tailRatio*p50 {
+			add(ObservationTailLatency, "p99 duration %.4fs is %.1fx the p50 duration %.4fs", p99, p99/p50, p50)
+		}
+
+		// Late values are told apart only if the step has results before them
+		if span <= 0 || s.firstSeen.request.IsZero() {
+			continue
+		}
+		if s.firstSeen.request.Before(lastQuarter) {
+			for _, reason := range sortedReasons(s.firstSeen.errors) {
+				if first := s.firstSeen.errors[reason]; !first.Before(lastQuarter) {
+					add(ObservationLateError, "error %q appeared only in the last quarter of the run, first at %s of %s, %d requests",
+						reason, roundOffset(first.Sub(r.start)), roundOffset(span), s.ErrorDist[reason])
+				}
+			}
+		}
+		if s.firstSeen.request.Before(firstQuarter) {
+			for _, code := range sortedCodes(s.firstSeen.codes) {
+				if first := s.firstSeen.codes[code]; first.After(firstQuarter) {
+					add(ObservationLateStatusCode, "status code %d appeared only after %s of %s, %d responses",
+						code, roundOffset(first.Sub(r.start)), roundOffset(span), s.StatusCodeDist[code])
+				}
+			}
+		}
+	}
+	return observations
+}
+
+func sortedReasons(m map[string]time.Time) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedCodes(m map[int]time.Time) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// roundOffset rounds the offsets of the long runs to the seconds.
+func roundOffset(d time.Duration) time.Duration {
+	if d >= time.Minute {
+		return d.Round(time.Second)
+	}
+	return d.Round(time.Millisecond)
+}
+
+func printObservations(w io.Writer, r *Result) {
+	fmt.Fprintln(w, "Observations:")
+	for _, o := range r.Observations {
+		step := "Step " + strconv.Itoa(int(o.StepID))
+		if s, ok := r.StepResults[o.StepID]; ok && s.Name != "" {
+			step = s.Name
+		}
+		fmt.Fprintf(w, "  %d. %s\t: %s\n", o.StepID, step, o.Message)
+	}
+	fmt.Fprintln(w)
+}
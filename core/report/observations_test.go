@@ -0,0 +1,141 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// observationTestResults returns 400 iterations one per second. Step 1 has a slow tail, step 2 starts failing
+// with a new error in the last quarter and step 3 starts returning 503 halfway through the run.
+// Step 4 is healthy.
+func observationTestResults() []*types.ScenarioResult {
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	results := make([]*types.ScenarioResult, 400)
+	for i := range results {
+		t := start.Add(time.Duration(i) * time.Second)
+
+		tail := &types.ScenarioStepResult{StepID: 1, StepName: "login", StatusCode: 200, RequestTime: t,
+			Duration: 10 * time.Millisecond}
+		if i%50 == 0 {
+			tail.Duration = 500 * time.Millisecond
+		}
+
+		late := &types.ScenarioStepResult{StepID: 2, StatusCode: 200, RequestTime: t, Duration: 20 * time.Millisecond}
+		if i >= 350 {
+			late.Err = types.RequestError{Type: types.ErrorConn, Reason: "connection reset by peer"}
+		} else if i%40 == 0 {
+			late.Err = types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}
+		}
+
+		status := &types.ScenarioStepResult{StepID: 3, StatusCode: 200, RequestTime: t, Duration: 30 * time.Millisecond}
+		if i >= 200 && i%2 == 0 {
+			status.StatusCode = 503
+		}
+
+		healthy := &types.ScenarioStepResult{StepID: 4, StatusCode: 200, RequestTime: t,
+			Duration: time.Duration(i%10+5) * time.Millisecond}
+
+		results[i] = &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{tail, late, status, healthy}}
+	}
+	return results
+}
+
+func TestObservations(t *testing.T) {
+	Observations = true
+	defer func() {
+		Observations = false
+	}()
+
+	expected := []Observation{
+		{StepID: 1, Rule: ObservationTailLatency, Message: "p99 duration 0.4985s is 49.5x the p50 duration 0.0101s"},
+		{StepID: 2, Rule: ObservationLateError,
+			Message: `error "connection reset by peer" appeared only in the last quarter of the run, first at 5m50s of 6m39s, 50 requests`},
+		{StepID: 3, Rule: ObservationLateStatusCode, Message: "status code 503 appeared only after 3m20s of 6m39s, 100 responses"},
+	}
+
+	// Findings don't depend on how the results are distributed between the aggregators
+	results := observationTestResults()
+	for _, workers := range []int{1, 4} {
+		r := runPipeline(workers, results)
+		if !reflect.DeepEqual(r.Observations, expected) {
+			t.Errorf("Workers %d, Expected %#v, Found %#v", workers, expected, r.Observations)
+		}
+	}
+}
+
+func TestObservationsOfShortSteps(t *testing.T) {
+	Observations = true
+	defer func() {
+		Observations = false
+	}()
+
+	// The tail of a step with few requests and the values of a step that only ran late are not called out
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	agg := newAggregator()
+	for i := 0; i < minTailRequests-1; i++ {
+		steps := []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200, RequestTime: start.Add(time.Duration(i) * time.Second),
+			Duration: time.Millisecond}}
+		if i%10 == 0 {
+			steps[0].Duration = time.Second
+		}
+		if i >= 89 {
+			steps = append(steps, &types.ScenarioStepResult{StepID: 2, StatusCode: 500,
+				RequestTime: start.Add(time.Duration(i) * time.Second),
+				Err:         types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnRefused}})
+		}
+		agg.add(&types.ScenarioResult{StepResults: steps})
+	}
+
+	if o := agg.result().Observations; len(o) != 0 {
+		t.Errorf("Expected no observations, Found %#v", o)
+	}
+}
+
+func TestObservationsDisabled(t *testing.T) {
+	r := runPipeline(2, observationTestResults())
+	if r.Observations != nil {
+		t.Errorf("Observations should be disabled by default, Found %#v", r.Observations)
+	}
+}
+
+func TestStdoutPrintsObservations(t *testing.T) {
+	Observations = true
+	defer func() {
+		Observations = false
+	}()
+
+	printed := printedDetails(runPipeline(1, observationTestResults()))
+	for _, expected := range []string{"Observations:", "1. login", "p99 duration", "2. search",
+		`error "connection reset by peer" appeared only in the last quarter`, "3. Step 3", "status code 503"} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("%q should be printed in the report, Found: %s", expected, printed)
+		}
+	}
+	if strings.Contains(printed, "4. Step 4\t:") {
+		t.Errorf("Healthy step should not be observed, Found: %s", printed)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Distinct content types of the page resources reported per step, the new ones after the limit are grouped under
+// types.OverflowContentType
+const maxPageContentTypes = 20
+
+// PageLoadSummary is the loads of the HTML pages of a step with a page-load and the stats of their resources. The
+// resources are not in the request counts of the step.
+type PageLoadSummary struct {
+	PageCount int64 `json:"page_count"`
+
+	// Load times of the pages in seconds, from the request of the page until its last resource is fetched
+	AvgLoadTime float32 `json:"avg_load_time"`
+	P50LoadTime float32 `json:"p50_load_time"`
+	P95LoadTime float32 `json:"p95_load_time"`
+	P99LoadTime float32 `json:"p99_load_time"`
+
+	// Average resources fetched per page, and the resources failed or responded with an error status
+	AvgResources        float32 `json:"avg_resources"`
+	FailedResourceCount int64   `json:"failed_resource_count"`
+
+	// Resources rolled up by their content types, types.MissingContentType for the ones without a response or a
+	// Content-Type header
+	ContentTypes map[string]*PageResourceSummary `json:"content_types"`
+}
+
+type PageResourceSummary struct {
+	Count       int64 `json:"count"`
+	FailedCount int64 `json:"failed_count"`
+
+	// Average duration in seconds and the average body size of the resources
+	AvgDuration float32 `json:"avg_duration"`
+	AvgBytes    int64   `json:"avg_bytes"`
+}
+
+// pageLoadTracker tracks the page loads of a step, created by the first loaded page of a step with a page-load.
+type pageLoadTracker struct {
+	pages, resources, failed int64
+	loadTime                 time.Duration
+	loadTimes                *histogram
+	contentTypes             map[string]*pageResourceTracker
+}
+
+type pageResourceTracker struct {
+	count, failed, bytes int64
+	duration             time.Duration
+}
+
+func newPageLoadTracker() *pageLoadTracker {
+	return &pageLoadTracker{loadTimes: newHistogram(), contentTypes: make(map[string]*pageResourceTracker)}
+}
+
+func (t *pageLoadTracker) add(sr *types.ScenarioStepResult, loadTime time.Duration) {
+	t.pages++
+	t.loadTime += loadTime
+	t.loadTimes.add(loadTime)
+	resources, _ := sr.Custom["pageResources"].([]types.PageResource)
+	for _, r := range resources {
+		t.resources++
+		c := r.ContentType
+		if c == "" {
+			c = types.MissingContentType
+		}
+		rt := t.contentType(c)
+		rt.count++
+		rt.bytes += r.Bytes
+		rt.duration += r.Duration
+		if r.Failed {
+			t.failed++
+			rt.failed++
+		}
+	}
+}
+
+// contentType returns the tracker of the content type. After maxPageContentTypes distinct content types, the new ones
+// are grouped under types.OverflowContentType.
+func (t *pageLoadTracker) contentType(c string) *pageResourceTracker {
+	if _, ok := t.contentTypes[c]; !ok && len(t.contentTypes) >= maxPageContentTypes {
+		c = types.OverflowContentType
+	}
+	rt, ok := t.contentTypes[c]
+	if !ok {
+		rt = &pageResourceTracker{}
+		t.contentTypes[c] = rt
+	}
+	return rt
+}
+
+func (t *pageLoadTracker) merge(o *pageLoadTracker) {
+	t.pages += o.pages
+	t.resources += o.resources
+	t.failed += o.failed
+	t.loadTime += o.loadTime
+	t.loadTimes.merge(o.loadTimes)
+
+	contentTypes := make([]string, 0, len(o.contentTypes))
+	for c := range o.contentTypes {
+		contentTypes = append(contentTypes, c)
+	}
+	for _, c := range overflowLast(contentTypes, types.OverflowContentType) {
+		rt, ort := t.contentType(c), o.contentTypes[c]
+		rt.count += ort.count
+		rt.failed += ort.failed
+		rt.bytes += ort.bytes
+		rt.duration += ort.duration
+	}
+}
+
+// summary returns the summary of the page loads, nil if no page of the step is loaded.
+func (t *pageLoadTracker) summary() *PageLoadSummary {
+	if t == nil || t.pages == 0 {
+		return nil
+	}
+	s := &PageLoadSummary{
+		PageCount:           t.pages,
+		AvgLoadTime:         float32(t.loadTime.Seconds() / float64(t.pages)),
+		P50LoadTime:         float32(t.loadTimes.percentile(50).Seconds()),
+		P95LoadTime:         float32(t.loadTimes.percentile(95).Seconds()),
+		P99LoadTime:         float32(t.loadTimes.percentile(99).Seconds()),
+		AvgResources:        float32(float64(t.resources) / float64(t.pages)),
+		FailedResourceCount: t.failed,
+		ContentTypes:        make(map[string]*PageResourceSummary, len(t.contentTypes)),
+	}
+	for c, rt := range t.contentTypes {
+		s.ContentTypes[c] = &PageResourceSummary{
+			Count:       rt.count,
+			FailedCount: rt.failed,
+			AvgDuration: float32(rt.duration.Seconds() / float64(rt.count)),
+			AvgBytes:    rt.bytes / rt.count,
+		}
+	}
+	return s
+}
+
+// printPageLoad prints the page loads of a step and its resources by content type in the order of their counts.
+func printPageLoad(w io.Writer, p *PageLoadSummary) {
+	fmt.Fprintln(w, "\nPage Load (Content Type:Count:Failed:Avg. Duration:Avg. Size):")
+	fmt.Fprintf(w, "  %s pages, load time avg %s, p50/p95/p99 %s / %s / %s, avg %s resources, %s failed\n",
+		formatCount(p.PageCount), formatDuration(float64(p.AvgLoadTime)), formatDuration(float64(p.P50LoadTime)),
+		formatDuration(float64(p.P95LoadTime)), formatDuration(float64(p.P99LoadTime)),
+		formatNumber(float64(p.AvgResources), 1), formatCount(p.FailedResourceCount))
+	contentTypes := make([]string, 0, len(p.ContentTypes))
+	for c := range p.ContentTypes {
+		contentTypes = append(contentTypes, c)
+	}
+	sort.Slice(contentTypes, func(i, j int) bool {
+		ci, cj := p.ContentTypes[contentTypes[i]], p.ContentTypes[contentTypes[j]]
+		if ci.Count != cj.Count {
+			return ci.Count > cj.Count
+		}
+		return contentTypes[i] < contentTypes[j]
+	})
+	for _, c := range contentTypes {
+		rs := p.ContentTypes[c]
+		fmt.Fprintf(w, "  %s\t:%s\t:%s\t:%s\t:%s\n", c, formatCount(rs.Count), formatCount(rs.FailedCount),
+			formatDuration(float64(rs.AvgDuration)), formatBytes(rs.AvgBytes))
+	}
+}
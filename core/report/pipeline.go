@@ -0,0 +1,107 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// aggregationWorkers is the worker count of the aggregation pipelines.
+var aggregationWorkers = runtime.NumCPU()
+
+// pipeline aggregates the scenario results on multiple workers, so the report services keep up with the engine
+// at high RPS. Each worker owns a partial aggregator, partials are merged on each snapshot.
+type pipeline struct {
+	steps  []types.ScenarioStep
+	sketch types.PercentileSketch
+	shards []*shard
+
+	// Stops the test once a limit is reached, nil if there is no limit
+	limit *stopLimit
+}
+
+type shard struct {
+	mu  sync.Mutex
+	agg *aggregator
+}
+
+func newPipeline(workers int, steps []types.ScenarioStep, sketch types.PercentileSketch) *pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &pipeline{steps: steps, sketch: sketch, shards: make([]*shard, workers)}
+	// Shards know the steps for their limits, like the distinct values of the report dimensions
+	for i := range p.shards {
+		p.shards[i] = &shard{agg: newAggregator()}
+		p.shards[i].agg.sketch = sketch
+		p.shards[i].agg.initSteps(steps)
+	}
+	return p
+}
+
+// run aggregates the results of the input until it is closed or abort is closed.
+// Returns true if the aggregation is aborted before the input is closed.
+func (p *pipeline) run(input chan *types.ScenarioResult, abort <-chan struct{}) (aborted bool) {
+	var abortedWorkers int32
+	var wg sync.WaitGroup
+	for _, sh := range p.shards {
+		wg.Add(1)
+		go func(sh *shard) {
+			defer wg.Done()
+			for {
+				select {
+				case r, ok := <-input:
+					if !ok {
+						return
+					}
+					sh.mu.Lock()
+					sh.agg.add(r)
+					sh.mu.Unlock()
+					p.limit.observe(r)
+				case <-abort:
+					atomic.StoreInt32(&abortedWorkers, 1)
+					return
+				}
+			}
+		}(sh)
+	}
+	wg.Wait()
+	return atomic.LoadInt32(&abortedWorkers) == 1
+}
+
+// snapshot merges the partial aggregators into the result of the results aggregated so far.
+func (p *pipeline) snapshot() *Result {
+	merged := newAggregator()
+	merged.sketch = p.sketch
+	merged.initSteps(p.steps)
+	for _, sh := range p.shards {
+		sh.mu.Lock()
+		merged.merge(sh.agg)
+		sh.mu.Unlock()
+	}
+	r := merged.result()
+	r.Stopped = p.limit.stopReason()
+	return r
+}
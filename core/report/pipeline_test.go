@@ -0,0 +1,214 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// pipelineTestResults returns results covering all the aggregated metrics. Each step has a single status code and
+// a single error reason, since the printed distributions are not ordered.
+func pipelineTestResults(n int) []*types.ScenarioResult {
+	results := make([]*types.ScenarioResult, n)
+	for i := range results {
+		login := &types.ScenarioStepResult{
+			StepID:     1,
+			StepName:   "login",
+			StatusCode: 200,
+			Duration:   time.Duration(i%97+1) * time.Millisecond,
+			Custom: map[string]interface{}{
+				"dnsDuration":  time.Duration(i%7) * time.Millisecond,
+				"connDuration": time.Duration(i%11) * time.Millisecond,
+				"chunkCount":   int64(i % 5),
+			},
+		}
+		if i%13 == 0 {
+			login.Err = types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}
+		}
+
+		target := fmt.Sprintf("https://test.com/items/%d", i%50)
+		search := &types.ScenarioStepResult{
+			StepID:     2,
+			StepName:   "search",
+			StatusCode: 200,
+			// Durations are distinct per target, so the slowest targets are exact regardless of the order.
+			Duration: time.Duration(i%50+1) * time.Millisecond,
+			Custom:   map[string]interface{}{"targetURL": target, "endpoint": "/items/:id"},
+		}
+		if i%10 == 0 {
+			search.Err = types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnRefused}
+			search.Custom["endpoint"] = types.CatchAllURLGroup
+		}
+		results[i] = &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{login, search}}
+	}
+	return results
+}
+
+func runPipeline(workers int, results []*types.ScenarioResult) *Result {
+	p := newPipeline(workers, []types.ScenarioStep{{ID: 1, Name: "login"}, {ID: 2, Name: "search"}},
+		types.PercentileSketch{})
+	input := make(chan *types.ScenarioResult, len(results))
+	for _, r := range results {
+		input <- r
+	}
+	close(input)
+	p.run(input, nil)
+	return p.snapshot()
+}
+
+func printedDetails(result *Result) string {
+	realOut := out
+	defer func() {
+		out = realOut
+	}()
+	buffer := new(bytes.Buffer)
+	out = buffer
+
+	s := &stdout{result: result}
+	s.printDetails()
+	return buffer.String()
+}
+
+func TestPipelineShards(t *testing.T) {
+	results := pipelineTestResults(10000)
+
+	expected := runPipeline(1, results)
+	expectedJson, _ := json.Marshal(expected)
+	expectedPrint := printedDetails(expected)
+
+	for _, workers := range []int{2, 4, 16} {
+		workers := workers
+		t.Run(fmt.Sprintf("Workers_%d", workers), func(t *testing.T) {
+			result := runPipeline(workers, results)
+
+			if j, _ := json.Marshal(result); !bytes.Equal(j, expectedJson) {
+				t.Errorf("Expected %s, Found %s", expectedJson, j)
+			}
+			if p := printedDetails(result); p != expectedPrint {
+				t.Errorf("Expected printed output %s, Found %s", expectedPrint, p)
+			}
+		})
+	}
+}
+
+func TestPipelineSnapshot(t *testing.T) {
+	p := newPipeline(4, nil, types.PercentileSketch{})
+	input := make(chan *types.ScenarioResult)
+	done := make(chan struct{})
+	go func() {
+		p.run(input, nil)
+		close(done)
+	}()
+
+	results := pipelineTestResults(100)
+	for _, r := range results[:50] {
+		input <- r
+	}
+
+	// Snapshots are taken while the workers are running, like the live print does.
+	for {
+		if r := p.snapshot(); r.SuccessCount+r.FailedCount == 50 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, r := range results[50:] {
+		input <- r
+	}
+	close(input)
+	<-done
+
+	if r := p.snapshot(); r.SuccessCount+r.FailedCount != 100 {
+		t.Errorf("Iteration count Expected %d, Found %d", 100, r.SuccessCount+r.FailedCount)
+	}
+}
+
+func TestPipelineSnapshotErrorReasons(t *testing.T) {
+	// More distinct reasons than maxErrorReasons, the overflowing ones should be the same on every snapshot.
+	results := make([]*types.ScenarioResult, 0)
+	for i := 0; i < 150; i++ {
+		for j := 0; j <= i%4; j++ {
+			results = append(results, &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+				StepID:   1,
+				StepName: "login",
+				Duration: time.Millisecond,
+				Err:      types.RequestError{Type: types.ErrorConn, Reason: fmt.Sprintf("reason %d", i)},
+			}}})
+		}
+	}
+
+	p := newPipeline(2, []types.ScenarioStep{{ID: 1, Name: "login"}}, types.PercentileSketch{})
+	input := make(chan *types.ScenarioResult, len(results))
+	for _, r := range results {
+		input <- r
+	}
+	close(input)
+	p.run(input, nil)
+
+	expected := p.snapshot()
+	if len(expected.StepResults[1].ErrorDist) != maxErrorReasons {
+		t.Errorf("Error reason count Expected %d, Found %d", maxErrorReasons, len(expected.StepResults[1].ErrorDist))
+	}
+	total := 0
+	for _, n := range expected.StepResults[1].ErrorDist {
+		total += n
+	}
+	if total != len(results) {
+		t.Errorf("Error count Expected %d, Found %d", len(results), total)
+	}
+
+	expectedJson, _ := json.Marshal(expected)
+	for i := 0; i < 10; i++ {
+		if j, _ := json.Marshal(p.snapshot()); !bytes.Equal(j, expectedJson) {
+			t.Fatalf("Expected %s, Found %s", expectedJson, j)
+		}
+	}
+}
+
+// BenchmarkPipeline measures the consumer throughput of the aggregation, it should keep up with at least 200k results/s.
+func BenchmarkPipeline(b *testing.B) {
+	results := pipelineTestResults(10000)
+	p := newPipeline(aggregationWorkers, nil, types.PercentileSketch{})
+	input := make(chan *types.ScenarioResult, 1024)
+	done := make(chan struct{})
+	go func() {
+		p.run(input, nil)
+		close(done)
+	}()
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		input <- results[i%len(results)]
+	}
+	close(input)
+	<-done
+	b.StopTimer()
+
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "results/s")
+}
@@ -0,0 +1,49 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PreflightProbe is the latency of the connectivity probe of an address of a step before the test, the baseline
+// of the durations of the test.
+type PreflightProbe struct {
+	StepID uint16 `json:"step_id"`
+	Addr   string `json:"address" anonymize:"host"`
+
+	// Duration of the DNS resolution, the connection and the TLS handshake in seconds
+	Latency float64 `json:"latency"`
+}
+
+// printPreflight writes the preflight baseline line of the report header, w is a tabwriter of the report.
+func printPreflight(w io.Writer, probes []PreflightProbe) {
+	if len(probes) == 0 {
+		return
+	}
+	parts := make([]string, 0, len(probes))
+	for _, p := range probes {
+		parts = append(parts, fmt.Sprintf("step %d %s %.1fms", p.StepID, p.Addr, p.Latency*1000))
+	}
+	fmt.Fprintf(w, "Preflight Baseline: %s\n", strings.Join(parts, ", "))
+}
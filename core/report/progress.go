@@ -0,0 +1,155 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// progressTracker estimates the completion of the test from the load plan of the engine
+// and the recent iteration completion rate.
+type progressTracker struct {
+	// Cumulative planned iteration count at the end of each tick
+	scheduled    []int
+	tickInterval time.Duration
+
+	start         time.Time
+	lastCompleted int64
+	lastUpdate    time.Time
+
+	// Iteration completion per second since the previous update
+	rate float64
+}
+
+func newProgressTracker(tickInterval time.Duration, reqCountArr []int) *progressTracker {
+	scheduled := make([]int, len(reqCountArr))
+	sum := 0
+	for i, c := range reqCountArr {
+		sum += c
+		scheduled[i] = sum
+	}
+	return &progressTracker{scheduled: scheduled, tickInterval: tickInterval}
+}
+
+func (p *progressTracker) begin(t time.Time) {
+	p.start = t
+	p.lastUpdate = t
+	p.lastCompleted = 0
+	p.rate = 0
+}
+
+// total returns the planned iteration count of the test.
+func (p *progressTracker) total() int {
+	if len(p.scheduled) == 0 {
+		return 0
+	}
+	return p.scheduled[len(p.scheduled)-1]
+}
+
+// duration returns the planned duration of the test.
+func (p *progressTracker) duration() time.Duration {
+	return p.tickInterval * time.Duration(len(p.scheduled))
+}
+
+// ratio returns the completed iteration ratio in [0, 1].
+func (p *progressTracker) ratio(completed int64) float64 {
+	if p.total() == 0 {
+		return 0
+	}
+	r := float64(completed) / float64(p.total())
+	if r > 1 {
+		r = 1
+	}
+	return r
+}
+
+// update refreshes the recent completion rate.
+func (p *progressTracker) update(completed int64, now time.Time) {
+	if window := now.Sub(p.lastUpdate); window > 0 {
+		p.rate = float64(completed-p.lastCompleted) / window.Seconds()
+	}
+	p.lastCompleted = completed
+	p.lastUpdate = now
+}
+
+// eta estimates the remaining time of the test.
+// Since the engine starts the iterations at the planned ticks, the remaining stages take their planned durations
+// regardless of the completion rate. On top of that, the iterations started but not completed yet are drained
+// at the recent completion rate. Returns false if the estimation is not possible yet.
+func (p *progressTracker) eta(completed int64, now time.Time) (time.Duration, bool) {
+	elapsed := now.Sub(p.start)
+
+	remainingSchedule := p.duration() - elapsed
+	if remainingSchedule < 0 {
+		remainingSchedule = 0
+	}
+
+	// Engine ticker fires at the end of each tick interval.
+	scheduled := p.total()
+	if p.tickInterval > 0 {
+		if tick := int(elapsed/p.tickInterval) - 1; tick < 0 {
+			scheduled = 0
+		} else if tick < len(p.scheduled) {
+			scheduled = p.scheduled[tick]
+		}
+	}
+
+	backlog := int64(scheduled) - completed
+	if backlog <= 0 {
+		return remainingSchedule, true
+	}
+	if p.rate <= 0 {
+		return 0, false
+	}
+
+	lag := time.Duration(float64(backlog) / p.rate * float64(time.Second))
+	return remainingSchedule + lag, true
+}
+
+// summary returns the progress summary like "Progress: 40% (12s/30s) ETA: 18s".
+// On interactive terminals, a progress bar is prepended to the percentage.
+func (p *progressTracker) summary(completed int64, now time.Time, interactive bool) string {
+	ratio := p.ratio(completed)
+
+	b := strings.Builder{}
+	b.WriteString("Progress: ")
+	if interactive {
+		b.WriteString(progressBar(ratio, progressBarWidth) + " ")
+	}
+	fmt.Fprintf(&b, "%3d%% (%s/%s)", int(ratio*100), now.Sub(p.start).Truncate(time.Second), p.duration())
+
+	if eta, ok := p.eta(completed, now); ok {
+		fmt.Fprintf(&b, " ETA: %s", eta.Round(time.Second))
+	} else {
+		b.WriteString(" ETA: -")
+	}
+	return b.String()
+}
+
+func progressBar(ratio float64, width int) string {
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * float64(width))
+	return "[" + strings.Repeat(symbols.barFull, filled) + strings.Repeat(symbols.barEmpty, width-filled) + "]"
+}
@@ -0,0 +1,139 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetLoadPlan(t *testing.T) {
+	s := &stdout{}
+	s.SetLoadPlan(time.Duration(100)*time.Millisecond, []int{1, 2, 3, 4})
+
+	if s.progress.total() != 10 {
+		t.Errorf("Total Expected %d, Found %d", 10, s.progress.total())
+	}
+	if s.progress.duration() != time.Duration(400)*time.Millisecond {
+		t.Errorf("Duration Expected %v, Found %v", time.Duration(400)*time.Millisecond, s.progress.duration())
+	}
+}
+
+func TestProgressRatio(t *testing.T) {
+	p := newProgressTracker(time.Second, []int{5, 5})
+
+	tests := []struct {
+		completed int64
+		expected  float64
+	}{
+		{0, 0},
+		{5, 0.5},
+		{10, 1},
+		{12, 1},
+	}
+
+	for _, test := range tests {
+		if r := p.ratio(test.completed); r != test.expected {
+			t.Errorf("Ratio of %d Expected %v, Found %v", test.completed, test.expected, r)
+		}
+	}
+}
+
+func TestProgressEta(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name        string
+		reqCountArr []int
+		elapsed     time.Duration
+		completed   int64
+		rate        float64
+		expected    time.Duration
+		ok          bool
+	}{
+		// Completions keep up with the plan, remaining stages take their durations
+		{"OnSchedule", []int{10, 10, 10, 10}, 2 * time.Second, 30, 10, 2 * time.Second, true},
+
+		// Ramping profile, recent low rate doesn't affect the remaining stages' durations
+		{"Ramping", []int{1, 2, 40, 80}, 2 * time.Second, 3, 1, 2 * time.Second, true},
+
+		// Backlog of 10 iterations is drained at 10 iterations per second
+		{"Lagging", []int{10, 10, 10, 10}, 2 * time.Second, 10, 10, 3 * time.Second, true},
+
+		// The plan is over, only the backlog left
+		{"AfterPlan", []int{10, 10}, 5 * time.Second, 15, 5, time.Second, true},
+
+		{"UnknownRate", []int{10, 10}, time.Second, 0, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			p := newProgressTracker(time.Second, test.reqCountArr)
+			p.begin(start)
+			p.rate = test.rate
+
+			eta, ok := p.eta(test.completed, start.Add(test.elapsed))
+			if ok != test.ok {
+				t.Errorf("Ok Expected %v, Found %v", test.ok, ok)
+			}
+			if eta != test.expected {
+				t.Errorf("ETA Expected %v, Found %v", test.expected, eta)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestProgressUpdate(t *testing.T) {
+	start := time.Now()
+	p := newProgressTracker(time.Second, []int{10, 10})
+	p.begin(start)
+
+	p.update(8, start.Add(2*time.Second))
+	if p.rate != 4 {
+		t.Errorf("Rate Expected %v, Found %v", 4, p.rate)
+	}
+}
+
+func TestProgressSummary(t *testing.T) {
+	useCharset(t, unicodeCharset)
+	start := time.Now()
+	p := newProgressTracker(time.Second, []int{10, 10})
+	p.begin(start)
+
+	summary := p.summary(10, start.Add(time.Second), false)
+	if summary != "Progress:  50% (1s/2s) ETA: 1s" {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+	if strings.Contains(summary, "█") {
+		t.Errorf("Progress bar should not be printed on non-interactive terminals")
+	}
+
+	if summary := p.summary(10, start.Add(time.Second), true); !strings.Contains(summary, "[███") {
+		t.Errorf("Progress bar should be printed on interactive terminals, found: %s", summary)
+	}
+
+	useCharset(t, asciiCharset)
+	if summary := p.summary(10, start.Add(time.Second), true); !strings.Contains(summary, "[###") {
+		t.Errorf("Progress bar should be ASCII in the plain-text mode, found: %s", summary)
+	}
+}
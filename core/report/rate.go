@@ -0,0 +1,86 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateChange is a change of the iterations per second of the test, set from the polled value of the dynamic rate source.
+type RateChange struct {
+	Time time.Time `json:"time"`
+
+	// Polled value of the source, the rate is evaluated from it
+	Value float64 `json:"value"`
+
+	// Iterations per second after the expression and the min/max bounds of the dynamic rate
+	Rate float64 `json:"rate"`
+}
+
+// rateHistory collects the rate changes recorded by the engine, concurrently with the aggregation.
+type rateHistory struct {
+	mu      sync.Mutex
+	changes []RateChange
+}
+
+func (h *rateHistory) add(c RateChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changes = append(h.changes, c)
+}
+
+// list returns a copy of the rate changes in the recording order, nil if there is none.
+func (h *rateHistory) list() []RateChange {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.changes) == 0 {
+		return nil
+	}
+	return append([]RateChange(nil), h.changes...)
+}
+
+// rateAt returns the rate change in effect at t, false if t is before the first change.
+func rateAt(changes []RateChange, t time.Time) (RateChange, bool) {
+	var c RateChange
+	found := false
+	for _, rc := range changes {
+		if rc.Time.After(t) {
+			break
+		}
+		c, found = rc, true
+	}
+	return c, found
+}
+
+// printRateChanges writes the rate changes section of the report, w is a tabwriter of the report.
+func printRateChanges(w io.Writer, changes []RateChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Rate Changes (Time:Source Value:Iterations per Second):")
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\t:%g\t:%s\n", c.Time.Local().Format("15:04:05"), c.Value, formatNumber(c.Rate, 1))
+	}
+	fmt.Fprintln(w)
+}
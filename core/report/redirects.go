@@ -0,0 +1,147 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Distinct final urls of the redirected requests reported per step, the new ones after the limit are grouped under
+// types.OverflowRedirectURL
+const maxRedirectFinalURLs = 20
+
+// RedirectSummary is the redirected requests of a step and where they ended up.
+type RedirectSummary struct {
+	// Redirected requests of the step, and the ones whose chain requests an url twice
+	RedirectedCount int64 `json:"redirected_count"`
+	LoopCount       int64 `json:"loop_count"`
+
+	// Average redirects of a redirected request, and its average time before its final request in seconds
+	AvgRedirects float32 `json:"avg_redirects"`
+	AvgTime      float32 `json:"avg_time"`
+
+	// Redirected requests per the url of their final request
+	FinalURLs map[string]int64 `json:"final_urls" anonymize:"url"`
+}
+
+// redirectTracker counts the redirected requests of a step, created by the first redirected request.
+type redirectTracker struct {
+	count, loops, redirects int64
+	time                    time.Duration
+	finalURLs               map[string]int64
+}
+
+func newRedirectTracker() *redirectTracker {
+	return &redirectTracker{finalURLs: make(map[string]int64)}
+}
+
+func (t *redirectTracker) add(sr *types.ScenarioStepResult, chain []types.RedirectHop) {
+	t.count++
+	t.redirects += int64(len(chain) - 1)
+	if _, ok := sr.Custom["redirectLoop"]; ok {
+		t.loops++
+	}
+	if d, ok := sr.Custom["redirectTime"].(time.Duration); ok {
+		t.time += d
+	}
+	t.finalURL(chain[len(chain)-1].URL, 1)
+}
+
+// finalURL counts the requests ending up at the url. After maxRedirectFinalURLs distinct urls, the new ones are
+// grouped under types.OverflowRedirectURL.
+func (t *redirectTracker) finalURL(url string, count int64) {
+	if _, ok := t.finalURLs[url]; !ok && len(t.finalURLs) >= maxRedirectFinalURLs {
+		url = types.OverflowRedirectURL
+	}
+	t.finalURLs[url] += count
+}
+
+func (t *redirectTracker) merge(o *redirectTracker) {
+	t.count += o.count
+	t.loops += o.loops
+	t.redirects += o.redirects
+	t.time += o.time
+
+	urls := make([]string, 0, len(o.finalURLs))
+	for u := range o.finalURLs {
+		urls = append(urls, u)
+	}
+	for _, u := range overflowLast(urls, types.OverflowRedirectURL) {
+		t.finalURL(u, o.finalURLs[u])
+	}
+}
+
+// overflowLast sorts the keys of the bounded groups with the overflow group as the last, so the groups are merged in
+// the same order and the same keys get their own groups within the limit.
+func overflowLast(keys []string, overflow string) []string {
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == overflow) != (keys[j] == overflow) {
+			return keys[j] == overflow
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// summary returns the summary of the redirects, nil if no request of the step is redirected.
+func (t *redirectTracker) summary() *RedirectSummary {
+	if t == nil || t.count == 0 {
+		return nil
+	}
+	s := &RedirectSummary{
+		RedirectedCount: t.count,
+		LoopCount:       t.loops,
+		AvgRedirects:    float32(float64(t.redirects) / float64(t.count)),
+		AvgTime:         float32(t.time.Seconds() / float64(t.count)),
+		FinalURLs:       make(map[string]int64, len(t.finalURLs)),
+	}
+	for u, c := range t.finalURLs {
+		s.FinalURLs[u] = c
+	}
+	return s
+}
+
+// printRedirects prints the redirects of a step and its final urls in the order of their counts.
+func printRedirects(w io.Writer, v *ScenarioStepResultSummary) {
+	r := v.Redirects
+	requests := v.SuccessCount + v.FailedCount
+	fmt.Fprintln(w, "\nRedirects (Count:Final URL):")
+	fmt.Fprintf(w, "  %s of the requests redirected, avg %s redirects and %s before the final request, %s loops\n",
+		formatPercent(int(r.RedirectedCount*100/requests), r.RedirectedCount, requests),
+		formatNumber(float64(r.AvgRedirects), 1), formatDuration(float64(r.AvgTime)), formatCount(r.LoopCount))
+	urls := make([]string, 0, len(r.FinalURLs))
+	for u := range r.FinalURLs {
+		urls = append(urls, u)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if r.FinalURLs[urls[i]] != r.FinalURLs[urls[j]] {
+			return r.FinalURLs[urls[i]] > r.FinalURLs[urls[j]]
+		}
+		return urls[i] < urls[j]
+	})
+	for _, u := range urls {
+		fmt.Fprintf(w, "  %s\t:%s\n", formatCount(r.FinalURLs[u]), u)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"sort"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// RunInfo is the read-only view of the scenario and the settings of the run given to the report services at Init,
+// so they can render the full step list up front. It is a copy of the hammer, the secrets of the URLs are redacted.
+type RunInfo struct {
+	LoadType       string
+	IterationCount int
+	Duration       int
+	Seed           int64
+	Steps          []StepInfo
+}
+
+// StepInfo is the definition of a step of the scenario with the checks its responses are asserted by.
+type StepInfo struct {
+	ID     uint16
+	Name   string
+	Method string
+
+	// URL template of the step, the dynamic variables are not rendered
+	URL string `secret:"url" anonymize:"url"`
+
+	// Status codes counted as success, empty means all the responses are successful
+	SuccessStatus string
+
+	// Path of the json-schema file of the responses, empty if the step has no schema
+	JSONSchema string
+
+	XPathAssertions []string
+}
+
+// NewRunInfo returns the run info of the hammer.
+func NewRunInfo(h types.Hammer) RunInfo {
+	info := RunInfo{
+		LoadType:       h.LoadType,
+		IterationCount: h.IterationCount,
+		Duration:       h.TestDuration,
+		Seed:           h.Scenario.Seed,
+		Steps:          make([]StepInfo, 0, len(h.Scenario.Steps)),
+	}
+	for _, s := range h.Scenario.Steps {
+		st := StepInfo{ID: s.ID, Name: s.Name, Method: s.Method, URL: s.URL, SuccessStatus: s.SuccessStatus}
+		st.JSONSchema, _ = s.Custom["json-schema"].(string)
+		if val, ok := s.Custom["xpath-assertions"]; ok {
+			st.XPathAssertions, _ = types.ParseXPathAssertions(val)
+		}
+		info.Steps = append(info.Steps, st)
+	}
+	types.Redact(&info)
+	return info
+}
+
+// step returns the step with the id, nil if the run has no such step.
+func (r *RunInfo) step(id uint16) *StepInfo {
+	if r == nil {
+		return nil
+	}
+	for i := range r.Steps {
+		if r.Steps[i].ID == id {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// sortSteps sorts the step ids in the execution order of the run, like the order of the steps by their dependencies.
+// The steps that are not in the run are sorted after them by their ids.
+func (r *RunInfo) sortSteps(ids []int) {
+	order := make(map[int]int)
+	if r != nil {
+		for i, st := range r.Steps {
+			order[int(st.ID)] = i
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		oi, iok := order[ids[i]]
+		oj, jok := order[ids[j]]
+		if iok != jok {
+			return iok
+		}
+		if iok && oi != oj {
+			return oi < oj
+		}
+		return ids[i] < ids[j]
+	})
+}
+
+// InitReport initializes the report service by InitRun if it is a RunInitializer, otherwise by Init, so the report
+// services written before the run info are initialized as before.
+func InitReport(rs ReportService, debug bool, info RunInfo) error {
+	if ri, ok := rs.(RunInitializer); ok {
+		return ri.InitRun(debug, info)
+	}
+	return rs.Init(debug)
+}
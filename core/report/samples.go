@@ -0,0 +1,163 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Failed requests kept per error reason of each step, the samples are bounded regardless of the failure count.
+const maxFailureSamples = 5
+
+// ShowFailureSamples prints the failure samples of the steps in the stdout report. They are always in the JSON outputs.
+var ShowFailureSamples bool
+
+// FailureSample is a concrete failed request of a step to investigate its error reason.
+type FailureSample struct {
+	Time       time.Time `json:"time"`
+	URL        string    `json:"url,omitempty" anonymize:"url"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Duration   float32   `json:"duration"`
+	// Iteration of the request and its virtual user, to match the request in the server logs
+	IterationID uint64 `json:"iteration_id"`
+	VUID        uint64 `json:"vu_id"`
+	// Head of the response body with the authorization values redacted, if the body is read by the step
+	Response string `json:"response_snippet,omitempty"`
+
+	// Requests of the redirect chain from the first one, if the request is redirected
+	Redirects []types.RedirectHop `json:"redirects,omitempty"`
+
+	// Tail of the stderr of the sign command of the step, if the command failed
+	Stderr string `json:"stderr,omitempty"`
+}
+
+func newFailureSample(sr *types.ScenarioStepResult) FailureSample {
+	s := FailureSample{
+		Time:       sr.RequestTime,
+		StatusCode: sr.StatusCode,
+		Duration:   float32(sr.Duration.Seconds()),
+
+		IterationID: sr.IterationID,
+		VUID:        sr.VUID,
+	}
+	s.URL, _ = sr.Custom["failedURL"].(string)
+	s.Response, _ = sr.Custom["responseSnippet"].(string)
+	s.Redirects, _ = sr.Custom["redirects"].([]types.RedirectHop)
+	s.Stderr, _ = sr.Custom["signStderr"].(string)
+	return s
+}
+
+// before orders the samples by their times. Ties are broken by the other fields, so the kept samples don't depend on
+// how the results are distributed between the aggregators.
+func (s FailureSample) before(o FailureSample) bool {
+	if !s.Time.Equal(o.Time) {
+		return s.Time.Before(o.Time)
+	}
+	if s.Duration != o.Duration {
+		return s.Duration < o.Duration
+	}
+	if s.StatusCode != o.StatusCode {
+		return s.StatusCode < o.StatusCode
+	}
+	if s.URL != o.URL {
+		return s.URL < o.URL
+	}
+	if s.IterationID != o.IterationID {
+		return s.IterationID < o.IterationID
+	}
+	return s.Response < o.Response
+}
+
+// failureSamples keeps the earliest maxFailureSamples failed requests of each error reason, ordered by their times.
+type failureSamples map[string][]FailureSample
+
+// add keeps the sample if the reason has room for it or it is earlier than the last kept sample. The samples map is created on the
+// first use.
+func (f *failureSamples) add(reason string, s FailureSample) {
+	if *f == nil {
+		*f = make(failureSamples)
+	}
+	samples := (*f)[reason]
+	if len(samples) == maxFailureSamples && !s.before(samples[len(samples)-1]) {
+		return
+	}
+
+	i := sort.Search(len(samples), func(i int) bool { return s.before(samples[i]) })
+	samples = append(samples, FailureSample{})
+	copy(samples[i+1:], samples[i:])
+	samples[i] = s
+	if len(samples) > maxFailureSamples {
+		samples = samples[:maxFailureSamples]
+	}
+	(*f)[reason] = samples
+}
+
+func (f *failureSamples) merge(reason string, o []FailureSample) {
+	for _, s := range o {
+		f.add(reason, s)
+	}
+}
+
+func (f failureSamples) summary() map[string][]FailureSample {
+	if len(f) == 0 {
+		return nil
+	}
+	summary := make(map[string][]FailureSample, len(f))
+	for reason, samples := range f {
+		summary[reason] = append([]FailureSample(nil), samples...)
+	}
+	return summary
+}
+
+// printFailureSamples prints the samples of the error reasons in the order of their counts.
+func printFailureSamples(w io.Writer, v *ScenarioStepResultSummary) {
+	fmt.Fprintf(w, "\nFailure Samples (First %d per Reason, Time:Iteration/VU:Status:Duration:URL):\n", maxFailureSamples)
+	for _, reason := range topErrors(v.ErrorDist, len(v.ErrorDist)) {
+		samples := v.FailureSamples[reason]
+		if len(samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", reason)
+		for _, s := range samples {
+			status := "-"
+			if s.StatusCode != 0 {
+				status = strconv.Itoa(s.StatusCode)
+			}
+			fmt.Fprintf(w, "    %s\t:%d/%d\t:%s\t:%s\t:%s\n", s.Time.Local().Format("15:04:05.000"), s.IterationID,
+				s.VUID, status, formatDuration(float64(s.Duration)), s.URL)
+			if s.Response != "" {
+				fmt.Fprintf(w, "      %s\n", strconv.Quote(s.Response))
+			}
+			for _, hop := range s.Redirects {
+				fmt.Fprintf(w, "      -> %d %s\n", hop.StatusCode, hop.URL)
+			}
+			if s.Stderr != "" {
+				fmt.Fprintf(w, "      stderr: %s\n", strconv.Quote(s.Stderr))
+			}
+		}
+	}
+}
@@ -0,0 +1,394 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// PercentileModeExact is the mode of the percentiles of the raw durations of a step.
+const PercentileModeExact = "exact"
+
+// tdigestBufferFactor is the size of the buffer of the unmerged durations of a t-digest, times its compression.
+const tdigestBufferFactor = 5
+
+// PercentileSummary is the duration percentiles of the successful requests of a step in seconds with the sketch that
+// estimated them, so the percentiles of the runs are only compared if they are produced the same way.
+type PercentileSummary struct {
+	P50 float32 `json:"p50"`
+	P90 float32 `json:"p90"`
+	P95 float32 `json:"p95"`
+	P99 float32 `json:"p99"`
+
+	// PercentileModeExact if the percentiles are of the raw durations, otherwise the kind of the sketch
+	Mode string `json:"mode"`
+
+	// Significant digits of the hdr sketch
+	SignificantDigits int `json:"significant_digits,omitempty"`
+
+	// Compression factor of the tdigest sketch
+	Compression int `json:"compression,omitempty"`
+}
+
+// percentileSketch estimates the percentiles of the durations of a step. The sketches of a step are merged only with
+// the sketches of the same PercentileSketch.
+type percentileSketch interface {
+	add(d time.Duration)
+	merge(o percentileSketch)
+	percentile(p int) time.Duration
+	count() int64
+}
+
+// newPercentileSketch returns the sketch of the config, wrapped to keep the raw durations up to its exact limit.
+func newPercentileSketch(c types.PercentileSketch) percentileSketch {
+	var s percentileSketch
+	switch c.Sketch() {
+	case types.SketchHDR:
+		s = newHDRSketch(c.Digits())
+	case types.SketchTDigest:
+		s = newTDigest(c.CompressionFactor())
+	default:
+		s = logSketch{newHistogram()}
+	}
+	if c.ExactLimit > 0 {
+		return &exactSketch{limit: c.ExactLimit, sketch: s}
+	}
+	return s
+}
+
+// percentileSummary returns the percentiles of the criteria of the sketch with the mode that produced them, nil if the
+// sketch is empty.
+func percentileSummary(s percentileSketch, c types.PercentileSketch) *PercentileSummary {
+	if s == nil || s.count() == 0 {
+		return nil
+	}
+	p := &PercentileSummary{
+		P50:  float32(s.percentile(50).Seconds()),
+		P90:  float32(s.percentile(90).Seconds()),
+		P95:  float32(s.percentile(95).Seconds()),
+		P99:  float32(s.percentile(99).Seconds()),
+		Mode: c.Sketch(),
+	}
+	if e, ok := s.(*exactSketch); ok && e.exact() {
+		p.Mode = PercentileModeExact
+		return p
+	}
+	switch p.Mode {
+	case types.SketchHDR:
+		p.SignificantDigits = c.Digits()
+	case types.SketchTDigest:
+		p.Compression = c.CompressionFactor()
+	}
+	return p
+}
+
+// byRank returns the percentiles keyed by their ranks, like the criteria.Percentiles.
+func (p *PercentileSummary) byRank() map[int]float32 {
+	return map[int]float32{50: p.P50, 90: p.P90, 95: p.P95, 99: p.P99}
+}
+
+// logSketch is the histogram of the logarithmic buckets as a percentileSketch, the default sketch.
+type logSketch struct {
+	*histogram
+}
+
+func (l logSketch) merge(o percentileSketch) {
+	l.histogram.merge(o.(logSketch).histogram)
+}
+
+func (l logSketch) count() int64 {
+	return l.total
+}
+
+// hdrSketch is an HDR histogram of the durations in microseconds. The durations are counted in the buckets of the
+// powers of 2, each split into the sub-buckets of the significant digits, so the percentiles are accurate within
+// 10^-digits. The counts are sparse, only the sub-buckets of the seen durations take memory.
+type hdrSketch struct {
+	counts map[int]int64
+	total  int64
+
+	subBucketHalfCountMagnitude uint
+	subBucketHalfCount          int64
+	subBucketMask               int64
+	leadingZeroCountBase        int
+}
+
+func newHDRSketch(digits int) *hdrSketch {
+	// Sub-buckets tell apart the durations of the significant digits in any bucket
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(2 * math.Pow10(digits))))
+	halfCountMagnitude := subBucketCountMagnitude - 1
+	return &hdrSketch{
+		counts:                      make(map[int]int64),
+		subBucketHalfCountMagnitude: halfCountMagnitude,
+		subBucketHalfCount:          int64(1) << halfCountMagnitude,
+		subBucketMask:               int64(1)<<subBucketCountMagnitude - 1,
+		leadingZeroCountBase:        64 - int(halfCountMagnitude) - 1,
+	}
+}
+
+func (h *hdrSketch) add(d time.Duration) {
+	h.counts[h.index(int64(d/time.Microsecond))]++
+	h.total++
+}
+
+func (h *hdrSketch) merge(o percentileSketch) {
+	oh := o.(*hdrSketch)
+	for i, c := range oh.counts {
+		h.counts[i] += c
+	}
+	h.total += oh.total
+}
+
+func (h *hdrSketch) count() int64 {
+	return h.total
+}
+
+// percentile returns the p-th percentile of the durations by the nearest-rank method.
+func (h *hdrSketch) percentile(p int) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	indexes := make([]int, 0, len(h.counts))
+	for i := range h.counts {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	rank := int64(math.Ceil(float64(p) / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, i := range indexes {
+		seen += h.counts[i]
+		if seen >= rank {
+			return h.value(i)
+		}
+	}
+	return h.value(indexes[len(indexes)-1])
+}
+
+// index returns the index of the sub-bucket of the duration in microseconds.
+func (h *hdrSketch) index(us int64) int {
+	bucket := h.leadingZeroCountBase - bits.LeadingZeros64(uint64(us|h.subBucketMask))
+	subBucket := us >> uint(bucket)
+	return (bucket+1)<<h.subBucketHalfCountMagnitude + int(subBucket-h.subBucketHalfCount)
+}
+
+// value returns the middle of the sub-bucket of the index.
+func (h *hdrSketch) value(i int) time.Duration {
+	bucket := i>>h.subBucketHalfCountMagnitude - 1
+	subBucket := int64(i)&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= h.subBucketHalfCount
+		bucket = 0
+	}
+	lower := subBucket << uint(bucket)
+	width := int64(1) << uint(bucket)
+	return time.Duration((float64(lower) + float64(width-1)/2) * float64(time.Microsecond))
+}
+
+// centroid is the mean of the durations merged into it.
+type centroid struct {
+	mean  float64
+	count int64
+}
+
+// tdigest is a merging t-digest of the durations. The centroids near the median merge many durations, the ones at
+// the tails only a few, so the tail percentiles are accurate with a memory bounded by the compression.
+type tdigest struct {
+	compression float64
+	centroids   []centroid // Sorted by the means
+	buffer      []centroid // Not merged yet
+	total       int64
+	min, max    float64
+}
+
+func newTDigest(compression int) *tdigest {
+	return &tdigest{
+		compression: float64(compression),
+		buffer:      make([]centroid, 0, tdigestBufferFactor*compression),
+	}
+}
+
+func (t *tdigest) add(d time.Duration) {
+	t.addCentroid(centroid{mean: float64(d), count: 1})
+	if len(t.buffer) >= tdigestBufferFactor*int(t.compression) {
+		t.compress()
+	}
+}
+
+func (t *tdigest) addCentroid(c centroid) {
+	if t.total == 0 || c.mean < t.min {
+		t.min = c.mean
+	}
+	if t.total == 0 || c.mean > t.max {
+		t.max = c.mean
+	}
+	t.buffer = append(t.buffer, c)
+	t.total += c.count
+}
+
+func (t *tdigest) merge(o percentileSketch) {
+	ot := o.(*tdigest)
+	if ot.total == 0 {
+		return
+	}
+	// Extremes of the other digest may be merged into its centroids
+	empty := t.total == 0
+	for _, cs := range [][]centroid{ot.centroids, ot.buffer} {
+		for _, c := range cs {
+			t.addCentroid(c)
+		}
+	}
+	if empty || ot.min < t.min {
+		t.min = ot.min
+	}
+	if empty || ot.max > t.max {
+		t.max = ot.max
+	}
+	t.compress()
+}
+
+func (t *tdigest) count() int64 {
+	return t.total
+}
+
+// compress merges the buffer into the centroids. Neighbouring centroids are merged while the merged centroid spans
+// at most 1 of the k1 scale, k(q) = compression / 2π * asin(2q - 1).
+func (t *tdigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := append(t.buffer, t.centroids...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	total := float64(t.total)
+	k := func(q float64) float64 { return t.compression / (2 * math.Pi) * math.Asin(2*q-1) }
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	cur, soFar := all[0], 0.0
+	for _, c := range all[1:] {
+		proposed := float64(cur.count + c.count)
+		if k((soFar+proposed)/total)-k(soFar/total) <= 1 {
+			cur.mean += (c.mean - cur.mean) * float64(c.count) / proposed
+			cur.count += c.count
+			continue
+		}
+		soFar += float64(cur.count)
+		merged = append(merged, cur)
+		cur = c
+	}
+	t.centroids = append(merged, cur)
+	t.buffer = t.buffer[:0]
+}
+
+// percentile returns the p-th percentile of the durations, interpolated between the centers of the centroids.
+func (t *tdigest) percentile(p int) time.Duration {
+	t.compress()
+	if t.total == 0 {
+		return 0
+	}
+	rank := float64(p) / 100 * float64(t.total)
+	// Each centroid is centered on the middle of its durations, the min and the max are the ends
+	prevRank, prevMean := 0.0, t.min
+	var seen float64
+	for _, c := range t.centroids {
+		center := seen + float64(c.count)/2
+		if rank <= center {
+			return time.Duration(interpolate(rank, prevRank, center, prevMean, c.mean))
+		}
+		prevRank, prevMean = center, c.mean
+		seen += float64(c.count)
+	}
+	return time.Duration(interpolate(rank, prevRank, float64(t.total), prevMean, t.max))
+}
+
+// interpolate returns the value of x on the line between (x0, y0) and (x1, y1).
+func interpolate(x, x0, x1, y0, y1 float64) float64 {
+	if x1 <= x0 {
+		return y1
+	}
+	return y0 + (x-x0)/(x1-x0)*(y1-y0)
+}
+
+// exactSketch keeps the raw durations of a step up to the limit, the percentiles are exact until the step has more
+// durations. The durations are counted by the sketch too, its estimates are reported after the limit.
+type exactSketch struct {
+	limit  int
+	raw    []time.Duration
+	sorted bool
+	over   bool
+	sketch percentileSketch
+}
+
+func (e *exactSketch) add(d time.Duration) {
+	e.sketch.add(d)
+	if e.over {
+		return
+	}
+	if len(e.raw) == e.limit {
+		e.over, e.raw = true, nil
+		return
+	}
+	e.raw = append(e.raw, d)
+	e.sorted = false
+}
+
+func (e *exactSketch) merge(o percentileSketch) {
+	oe := o.(*exactSketch)
+	e.sketch.merge(oe.sketch)
+	if e.over || oe.over || len(e.raw)+len(oe.raw) > e.limit {
+		e.over, e.raw = true, nil
+		return
+	}
+	e.raw = append(e.raw, oe.raw...)
+	e.sorted = false
+}
+
+func (e *exactSketch) count() int64 {
+	return e.sketch.count()
+}
+
+// exact reports whether the percentiles are of the raw durations.
+func (e *exactSketch) exact() bool {
+	return !e.over && len(e.raw) > 0
+}
+
+// percentile returns the p-th percentile of the raw durations by the nearest-rank method, the estimate of the sketch
+// after the limit.
+func (e *exactSketch) percentile(p int) time.Duration {
+	if !e.exact() {
+		return e.sketch.percentile(p)
+	}
+	if !e.sorted {
+		sort.Slice(e.raw, func(i, j int) bool { return e.raw[i] < e.raw[j] })
+		e.sorted = true
+	}
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(e.raw))))
+	if rank < 1 {
+		rank = 1
+	}
+	return e.raw[rank-1]
+}
@@ -0,0 +1,212 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// lognormalDurations returns the durations of a long tailed distribution around 50ms.
+func lognormalDurations(n int) []time.Duration {
+	r := rand.New(rand.NewSource(1))
+	durations := make([]time.Duration, n)
+	for i := range durations {
+		durations[i] = time.Duration(math.Exp(r.NormFloat64()*0.8) * float64(50*time.Millisecond))
+	}
+	return durations
+}
+
+// exactPercentile returns the p-th percentile of the sorted durations by the nearest-rank method.
+func exactPercentile(sorted []time.Duration, p int) time.Duration {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func TestPercentileSketchAccuracy(t *testing.T) {
+	durations := lognormalDurations(100000)
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	tests := []struct {
+		name   string
+		sketch types.PercentileSketch
+		maxErr float64
+	}{
+		{"Log", types.PercentileSketch{}, 0.01},
+		{"HDR1", types.PercentileSketch{Kind: types.SketchHDR, SignificantDigits: 1}, 0.1},
+		{"HDR3", types.PercentileSketch{Kind: types.SketchHDR}, 0.001},
+		// Error of the t-digest is bounded in the ranks, the tail durations are far apart in a long tail
+		{"TDigest", types.PercentileSketch{Kind: types.SketchTDigest}, 0.02},
+		{"TDigest300", types.PercentileSketch{Kind: types.SketchTDigest, Compression: 300}, 0.005},
+		{"Exact", types.PercentileSketch{ExactLimit: len(durations)}, 0},
+	}
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			s := newPercentileSketch(test.sketch)
+			for _, d := range durations {
+				s.add(d)
+			}
+			if s.count() != int64(len(durations)) {
+				t.Errorf("Count Expected %d, Found %d", len(durations), s.count())
+			}
+			for _, p := range []int{50, 90, 95, 99} {
+				expected := exactPercentile(sorted, p)
+				found := s.percentile(p)
+				if diff := math.Abs(float64(found-expected)) / float64(expected); diff > test.maxErr {
+					t.Errorf("p%d: Expected %s within %g, Found %s", p, expected, test.maxErr, found)
+				}
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestPercentileSketchMerge(t *testing.T) {
+	durations := lognormalDurations(20000)
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for _, c := range []types.PercentileSketch{
+		{Kind: types.SketchHDR},
+		{Kind: types.SketchTDigest},
+		{ExactLimit: len(durations)},
+	} {
+		// Shards of the pipeline merge their sketches into the sketch of the step
+		shards := []percentileSketch{newPercentileSketch(c), newPercentileSketch(c), newPercentileSketch(c)}
+		for i, d := range durations {
+			shards[i%len(shards)].add(d)
+		}
+		merged := newPercentileSketch(c)
+		for _, s := range shards {
+			merged.merge(s)
+		}
+
+		if merged.count() != int64(len(durations)) {
+			t.Errorf("%+v: Count Expected %d, Found %d", c, len(durations), merged.count())
+		}
+		for _, p := range []int{50, 99} {
+			expected := exactPercentile(sorted, p)
+			found := merged.percentile(p)
+			if diff := math.Abs(float64(found-expected)) / float64(expected); diff > 0.01 {
+				t.Errorf("%+v p%d: Expected %s, Found %s", c, p, expected, found)
+			}
+		}
+	}
+}
+
+func TestExactSketchLimit(t *testing.T) {
+	c := types.PercentileSketch{Kind: types.SketchTDigest, ExactLimit: 10}
+	s := newPercentileSketch(c).(*exactSketch)
+	for i := 1; i <= 10; i++ {
+		s.add(time.Duration(i) * time.Millisecond)
+	}
+	if !s.exact() {
+		t.Fatal("Sketch should be exact up to its limit")
+	}
+	if p := s.percentile(90); p != 9*time.Millisecond {
+		t.Errorf("p90: Expected 9ms, Found %s", p)
+	}
+
+	// Limit is exceeded by the merged durations too
+	o := newPercentileSketch(c)
+	o.add(11 * time.Millisecond)
+	s.merge(o)
+	if s.exact() || s.raw != nil {
+		t.Error("Sketch over its limit should drop the raw durations")
+	}
+	if s.count() != 11 {
+		t.Errorf("Count Expected 11, Found %d", s.count())
+	}
+	if summary := percentileSummary(s, c); summary.Mode != types.SketchTDigest {
+		t.Errorf("Mode Expected %s after the limit, Found %s", types.SketchTDigest, summary.Mode)
+	}
+}
+
+func TestPercentileSummaryMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		sketch   types.PercentileSketch
+		expected PercentileSummary
+	}{
+		{"Default", types.PercentileSketch{}, PercentileSummary{Mode: types.SketchLog}},
+		{"HDR", types.PercentileSketch{Kind: types.SketchHDR, SignificantDigits: 4},
+			PercentileSummary{Mode: types.SketchHDR, SignificantDigits: 4}},
+		{"HDRDefault", types.PercentileSketch{Kind: types.SketchHDR},
+			PercentileSummary{Mode: types.SketchHDR, SignificantDigits: types.DefaultSignificantDigits}},
+		{"TDigest", types.PercentileSketch{Kind: types.SketchTDigest},
+			PercentileSummary{Mode: types.SketchTDigest, Compression: types.DefaultCompression}},
+		{"Exact", types.PercentileSketch{Kind: types.SketchHDR, ExactLimit: 5},
+			PercentileSummary{Mode: PercentileModeExact}},
+	}
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			s := newPercentileSketch(test.sketch)
+			if summary := percentileSummary(s, test.sketch); summary != nil {
+				t.Errorf("Summary of the empty sketch should be nil, Found %+v", summary)
+			}
+			s.add(100 * time.Millisecond)
+
+			summary := percentileSummary(s, test.sketch)
+			if summary.Mode != test.expected.Mode || summary.SignificantDigits != test.expected.SignificantDigits ||
+				summary.Compression != test.expected.Compression {
+				t.Errorf("Expected %+v, Found %+v", test.expected, *summary)
+			}
+			if math.Abs(float64(summary.P99)-0.1) > 0.001 {
+				t.Errorf("p99: Expected 0.1, Found %g", summary.P99)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+// BenchmarkSketchAdd compares the insert cost of the sketches, run with -benchmem for their allocations.
+func BenchmarkSketchAdd(b *testing.B) {
+	durations := lognormalDurations(1000000)
+
+	for _, bench := range []struct {
+		name   string
+		sketch types.PercentileSketch
+	}{
+		{"Log", types.PercentileSketch{}},
+		{"HDR3", types.PercentileSketch{Kind: types.SketchHDR, SignificantDigits: 3}},
+		{"TDigest100", types.PercentileSketch{Kind: types.SketchTDigest, Compression: 100}},
+		{"Exact", types.PercentileSketch{ExactLimit: len(durations)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s := newPercentileSketch(bench.sketch)
+				for _, d := range durations {
+					s.add(d)
+				}
+				s.percentile(99)
+			}
+		})
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -34,6 +36,9 @@ import (
 	"github.com/enescakir/emoji"
 	"github.com/fatih/color"
 	"github.com/mattn/go-colorable"
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/live"
 	"go.ddosify.com/ddosify/core/types"
 	"go.ddosify.com/ddosify/core/util"
 )
@@ -47,11 +52,62 @@ func init() {
 }
 
 type stdout struct {
+	reportClock
+
 	doneChan    chan struct{}
 	result      *Result
-	printTicker *time.Ticker
+	steps       []types.ScenarioStep
+	seed        int64
+	metadata    *RunMetadata
+	config      *ConfigEcho
+	run         *RunInfo
+	rates       rateHistory
+	bursts      burstHistory
+	users       userTracker
+	schedule    *types.LoadSchedule
+	preflight   []PreflightProbe
+	generator   *GeneratorHealth
+	teardown    []TeardownSummary
+	aggregation *pipeline
+	printTicker clock.Ticker
+	progress    *progressTracker
 	mu          sync.Mutex
 	debug       bool
+
+	// Closed by Abort to report the results without waiting the input to be closed
+	abortChan chan struct{}
+	abortOnce sync.Once
+
+	// Set once the final result is aggregated
+	finished bool
+
+	// Evaluated over the final result, nil if the test has no success criteria
+	criteria *criteria.Criteria
+
+	metrics metricNames
+	limit   *stopLimit
+
+	// Planned iteration count of the verify mode, 0 means disabled
+	verifyCount  int
+	verifyResult *VerifyResult
+
+	// Anonymizes the config echo and the final report, nil if the report is not anonymized
+	anonymizer *Anonymizer
+
+	// Renders the live summary line instead of the default one, nil if the test has no live template
+	live *live.Template
+
+	drainState
+	latencyFeed
+
+	// Sketch of the duration percentiles of the steps
+	percentiles types.PercentileSketch
+
+	// Load of the test estimated before the run, nil if the engine doesn't estimate it
+	estimate *types.Estimate
+
+	// Previous run that the final result is compared with, nil if the test has no baseline
+	baseline *Baseline
 }
 
 var white = color.New(color.FgHiWhite).SprintFunc()
@@ -62,37 +118,225 @@ var realTimePrintInterval = time.Duration(1500) * time.Millisecond
 
 func (s *stdout) Init(debug bool) (err error) {
 	s.doneChan = make(chan struct{})
-	s.result = &Result{
-		StepResults: make(map[uint16]*ScenarioStepResultSummary),
-	}
+	s.abortChan = make(chan struct{})
+	s.result = newResult()
 	s.debug = debug
 
-	color.Cyan("%s  Initializing... \n", emoji.Gear)
-	if s.debug {
-		color.Cyan("%s Running in debug mode, 1 iteration will be played... \n", emoji.Bug)
-	}
+	color.Cyan("%s  Initializing... \n", symbols.icon(emoji.Gear))
 	return
 }
 
+// InitRun initializes the report with the steps of the run, the steps without any result are listed in the report.
+func (s *stdout) InitRun(debug bool, info RunInfo) error {
+	s.run = &info
+	return s.Init(debug)
+}
+
+func (s *stdout) SetScenario(sc types.Scenario) {
+	s.steps = sc.Steps
+	s.seed = sc.Seed
+}
+
+func (s *stdout) SetPercentiles(p types.PercentileSketch) {
+	s.percentiles = p
+}
+
+func (s *stdout) SetEstimate(e types.Estimate) {
+	s.estimate = &e
+}
+
+func (s *stdout) SetBaseline(b *Baseline) {
+	s.baseline = b
+}
+
+func (s *stdout) SetMetadata(m types.Metadata) {
+	s.metadata = newRunMetadata(m)
+}
+
+func (s *stdout) SetConfig(h types.Hammer, proxies []*url.URL) {
+	s.config = newConfigEcho(h, proxies)
+}
+
+func (s *stdout) RecordRateChange(c RateChange) {
+	s.rates.add(c)
+}
+
+func (s *stdout) RecordBurst(b BurstSummary) {
+	s.bursts.add(b)
+}
+
+func (s *stdout) RecordUser(u UserSummary) {
+	s.users.add(u)
+}
+
+func (s *stdout) SetPreflight(probes []PreflightProbe) {
+	s.preflight = probes
+}
+
+func (s *stdout) SetTeardown(teardown []TeardownSummary) {
+	s.teardown = teardown
+}
+
+func (s *stdout) SetGeneratorHealth(g GeneratorHealth) {
+	s.generator = &g
+}
+
+func (s *stdout) SetSchedule(sc *types.LoadSchedule) {
+	s.schedule = sc
+}
+
+func (s *stdout) SetStopLimits(l StopLimits, stop func()) {
+	s.limit = newStopLimit(l, stop)
+}
+
+func (s *stdout) SetMetricMeta(meta map[uint16][]types.MetricMeta) {
+	s.metrics = newMetricNames(meta)
+}
+
+func (s *stdout) SetAnonymizer(a *Anonymizer) {
+	s.anonymizer = a
+}
+
+func (s *stdout) SetCriteria(c *criteria.Criteria) {
+	s.criteria = c
+}
+
+func (s *stdout) SetLiveTemplate(t *live.Template) {
+	s.live = t.WithFuncs(liveFuncs)
+}
+
+func (s *stdout) CriteriaResult() *criteria.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.result.Criteria
+}
+
+func (s *stdout) SetVerify(iterations int) {
+	s.verifyCount = iterations
+}
+
+func (s *stdout) VerifyResult() *VerifyResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.verifyResult
+}
+
+func (s *stdout) SetLoadPlan(tickInterval time.Duration, reqCountArr []int) {
+	s.progress = newProgressTracker(tickInterval, reqCountArr)
+}
+
 func (s *stdout) Start(input chan *types.ScenarioResult) {
+	s.printConfig()
 	if s.debug {
 		s.printInDebugMode(input)
 		s.doneChan <- struct{}{}
 		return
 	}
+	if s.verifyCount > 0 {
+		s.printInVerifyMode(input)
+		s.doneChan <- struct{}{}
+		return
+	}
+	if s.progress != nil {
+		s.progress.begin(s.timeSource().Now())
+	}
+	s.limit.begin(s.timeSource())
+	s.mu.Lock()
+	s.aggregation = newPipeline(aggregationWorkers, s.steps, s.percentiles)
+	s.aggregation.limit = s.limit
+	s.mu.Unlock()
+	s.feedFrom(s.aggregation.window)
 	go s.realTimePrintStart()
 
-	for r := range input {
-		s.mu.Lock()
-		aggregate(s.result, r)
-		s.mu.Unlock()
-	}
+	aborted := s.aggregation.run(input, s.abortChan)
+	s.mu.Lock()
+	s.result = s.aggregation.snapshot()
+	s.result.Partial = aborted
+	s.finished = true
+	s.mu.Unlock()
 
 	s.realTimePrintStop()
-	s.report()
+	s.finish()
 	s.doneChan <- struct{}{}
 }
 
+// finish evaluates the success criteria and prints the final report. Status writes are not interleaved with it.
+func (s *stdout) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.criteria != nil {
+		s.result.Criteria = s.criteria.Evaluate(s.result)
+	}
+	s.result.RunMetadata = s.metadata
+	s.result.RateChanges = s.rates.list()
+	s.result.setBursts(s.bursts.list())
+	s.result.Users = s.users.get()
+	s.result.Preflight = s.preflight
+	s.result.Config = s.config
+	s.result.Teardown = s.teardown
+	s.result.Generator = s.generator
+	s.result.setDrain(s.drainSummary())
+	s.result.setSchedule(s.schedule)
+	s.result.setEstimate(s.estimate)
+	if s.baseline != nil {
+		s.result.Comparison = s.baseline.compare(s.result)
+	}
+	if s.anonymizer != nil {
+		s.result = s.anonymizer.result(s.result)
+		s.metadata, s.preflight, s.run = s.result.RunMetadata, s.result.Preflight, s.anonymizer.runInfo(s.run)
+	}
+	if err := writeTimelineFile(s.result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	s.report()
+}
+
+func (s *stdout) Abort() {
+	s.abortOnce.Do(func() {
+		close(s.abortChan)
+	})
+}
+
+func (s *stdout) WriteStatus(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aggregation != nil && !s.finished {
+		s.result = s.aggregation.snapshot()
+	}
+	s.writeStatus(w)
+}
+
+// writeStatus writes the live stats of the result, s.mu should be held.
+func (s *stdout) writeStatus(w io.Writer) {
+	if d, ok := s.draining(); ok && !s.finished {
+		fmt.Fprintln(w, drainLine(d))
+	} else if s.progress != nil && !s.finished {
+		fmt.Fprintln(w, s.progress.summary(s.result.SuccessCount+s.result.FailedCount, s.timeSource().Now(), false))
+	}
+	total := s.result.SuccessCount + s.result.FailedCount
+	fmt.Fprintf(w, "Successful Run: %s (%s)  Failed Run: %s (%s)  Avg. Duration: %s\n",
+		formatCount(s.result.SuccessCount), formatPercent(s.result.successPercentage(), s.result.SuccessCount, total),
+		formatCount(s.result.FailedCount), formatPercent(s.result.failedPercentage(), s.result.FailedCount, total),
+		formatDurationPrec(float64(s.result.AvgDuration), 5))
+
+	keys := make([]int, 0, len(s.result.StepResults))
+	for k, v := range s.result.StepResults {
+		if v.hasResults() {
+			keys = append(keys, int(k))
+		}
+	}
+	s.run.sortSteps(keys)
+	for _, k := range keys {
+		v := s.result.StepResults[uint16(k)]
+		name := v.Name
+		if name == "" {
+			name = fmt.Sprintf("Step %d", k)
+		}
+		fmt.Fprintf(w, "  %d. %s  Success: %s  Failed: %s  Avg. Duration: %s\n", k, name,
+			formatCount(v.SuccessCount), formatCount(v.FailedCount), formatDurationPrec(float64(v.Durations["duration"]), 5))
+	}
+}
+
 func (s *stdout) report() {
 	s.printDetails()
 }
@@ -106,14 +350,18 @@ func (s *stdout) realTimePrintStart() {
 		return
 	}
 
-	s.printTicker = time.NewTicker(realTimePrintInterval)
+	s.printTicker = s.timeSource().NewTicker(realTimePrintInterval)
 
-	color.Cyan("%s Engine fired. \n\n", emoji.Fire)
-	color.Cyan("%s CTRL+C to gracefully stop.\n", emoji.StopSign)
+	color.Cyan("%s Engine fired. \n\n", symbols.icon(emoji.Fire))
+	color.Cyan("%s CTRL+C to gracefully stop, press again to abort without waiting the in-flight requests.\n",
+		symbols.icon(emoji.StopSign))
 
-	for range s.printTicker.C {
+	for range s.printTicker.C() {
 		go func() {
 			s.mu.Lock()
+			if !s.finished {
+				s.result = s.aggregation.snapshot()
+			}
 			s.liveResultPrint()
 			s.mu.Unlock()
 		}()
@@ -121,12 +369,57 @@ func (s *stdout) realTimePrintStart() {
 }
 
 func (s *stdout) liveResultPrint() {
-	fmt.Fprintf(out, "%s %s %s\n",
-		green(fmt.Sprintf("%s  Successful Run: %-6d %3d%% %5s",
-			emoji.CheckMark, s.result.SuccessCount, s.result.successPercentage(), "")),
-		red(fmt.Sprintf("%s Failed Run: %-6d %3d%% %5s",
-			emoji.CrossMark, s.result.FailedCount, s.result.failedPercentage(), "")),
-		blue(fmt.Sprintf("%s  Avg. Duration: %.5fs", emoji.Stopwatch, s.result.AvgDuration)))
+	if s.live != nil {
+		// Render errors are caught at the validation, a failing render falls back to the default line
+		if line, err := s.live.Render(s.liveSnapshot(s.timeSource().Now())); err == nil {
+			fmt.Fprintln(out, line)
+			return
+		}
+	}
+
+	var progress string
+	if d, ok := s.draining(); ok {
+		progress = white(fmt.Sprintf(" %5s%s  %s", "", symbols.icon(emoji.HourglassNotDone), drainLine(d)))
+	} else if s.progress != nil {
+		now := s.timeSource().Now()
+		completed := s.result.SuccessCount + s.result.FailedCount
+		s.progress.update(completed, now)
+		progress = white(fmt.Sprintf(" %5s%s  %s", "", symbols.icon(emoji.HourglassNotDone),
+			s.progress.summary(completed, now, isInteractiveTerminal())))
+	}
+
+	total := s.result.SuccessCount + s.result.FailedCount
+	fmt.Fprintf(out, "%s %s %s%s\n",
+		green(fmt.Sprintf("%s  Successful Run: %-6s %4s %5s", symbols.icon(emoji.CheckMark),
+			formatCount(s.result.SuccessCount), formatPercent(s.result.successPercentage(), s.result.SuccessCount, total), "")),
+		red(fmt.Sprintf("%s Failed Run: %-6s %4s %5s", symbols.icon(emoji.CrossMark),
+			formatCount(s.result.FailedCount), formatPercent(s.result.failedPercentage(), s.result.FailedCount, total), "")),
+		blue(fmt.Sprintf("%s  Avg. Duration: %s", symbols.icon(emoji.Stopwatch),
+			formatDurationPrec(float64(s.result.AvgDuration), 5))),
+		progress)
+}
+
+// liveSnapshot returns the live values of the result for the live template and refreshes the recent completion rate,
+// s.mu should be held.
+func (s *stdout) liveSnapshot(now time.Time) live.Snapshot {
+	snapshot := liveSnapshot(s.result)
+	snapshot.Status = "running"
+	if s.finished {
+		snapshot.Status = "finished"
+	} else if _, ok := s.draining(); ok {
+		snapshot.Status = "draining"
+	}
+
+	if s.progress != nil {
+		s.progress.update(snapshot.Iterations, now)
+		snapshot.Elapsed = now.Sub(s.progress.start).Seconds()
+		snapshot.RPS = s.progress.rate
+		snapshot.Progress = int(s.progress.ratio(snapshot.Iterations) * 100)
+		if eta, ok := s.progress.eta(snapshot.Iterations, now); ok {
+			snapshot.ETA = eta.Seconds()
+		}
+	}
+	return snapshot
 }
 
 func (s *stdout) realTimePrintStop() {
@@ -139,47 +432,151 @@ func (s *stdout) realTimePrintStop() {
 }
 
 func (s *stdout) printInDebugMode(input chan *types.ScenarioResult) {
-	color.Cyan("%s Engine fired. \n\n", emoji.Fire)
-	color.Cyan("%s CTRL+C to gracefully stop.\n", emoji.StopSign)
+	iteration := 0
+	for r := range input { // only 1 ScenarioResult expected, except the preview mode
+		iteration++
+		preview := isPreview(r)
+		if iteration == 1 {
+			if preview {
+				color.Cyan("%s Running in preview mode, requests will be rendered but not sent... \n",
+					symbols.icon(emoji.Eye))
+			} else {
+				color.Cyan("%s Running in debug mode, 1 iteration will be played... \n", symbols.icon(emoji.Bug))
+			}
+			color.Cyan("%s Engine fired. \n\n", symbols.icon(emoji.Fire))
+			color.Cyan("%s CTRL+C to gracefully stop.\n", symbols.icon(emoji.StopSign))
+		}
+		if preview {
+			color.Cyan("\n\nITERATION (%d)\n", iteration)
+			color.Cyan("=====================================")
+		}
 
-	for r := range input { // only 1 ScenarioResult expected
 		for _, sr := range r.StepResults {
-			verboseInfo := ScenarioStepResultToVerboseHttpRequestInfo(sr)
-
-			b := strings.Builder{}
-			w := tabwriter.NewWriter(&b, 0, 0, 4, ' ', 0)
-			color.Cyan("\n\nSTEP (%d) %-5s\n", verboseInfo.StepId, verboseInfo.StepName)
-			color.Cyan("-------------------------------------")
-			fmt.Fprintln(w, "***********  REQUEST  ***********")
-			fmt.Fprintf(w, "> Target: \t%-5s \n", verboseInfo.Request.Url)
-			fmt.Fprintf(w, "> Method: \t%-5s \n", verboseInfo.Request.Method)
-
-			fmt.Fprintf(w, "%s\n", blue(fmt.Sprintf("Request Headers: ")))
-			for hKey, hVal := range verboseInfo.Request.Headers {
-				fmt.Fprintf(w, "> %s:\t%-5s \n", hKey, hVal)
+			printStepVerbose(sr)
+		}
+	}
+}
+
+// printStepVerbose prints the curl-like verbose detail of the request of a step.
+func printStepVerbose(sr *types.ScenarioStepResult) {
+	verboseInfo := ScenarioStepResultToVerboseHttpRequestInfo(sr)
+
+	b := strings.Builder{}
+	w := tabwriter.NewWriter(&b, 0, 0, 4, ' ', 0)
+	color.Cyan("\n\nSTEP (%d) %-5s\n", verboseInfo.StepId, verboseInfo.StepName)
+	color.Cyan("-------------------------------------")
+	fmt.Fprintln(w, "***********  REQUEST  ***********")
+	fmt.Fprintf(w, "> Target: \t%-5s \n", verboseInfo.Request.Url)
+	fmt.Fprintf(w, "> Method: \t%-5s \n", verboseInfo.Request.Method)
+	fmt.Fprintf(w, "> Iteration: \t%d (VU %d) \n", verboseInfo.IterationId, verboseInfo.VuId)
+
+	fmt.Fprintf(w, "%s\n", blue(fmt.Sprintf("Request Headers: ")))
+	printHeaderLines(w, ">", sr.DebugInfo["requestHeaders"].(http.Header))
+
+	contentType := sr.DebugInfo["requestHeaders"].(http.Header).Get("content-type")
+	fmt.Fprintf(w, "%s\n", blue(fmt.Sprintf("Request Body: ")))
+	printBody(w, contentType, verboseInfo.Request.Body)
+
+	if verboseInfo.Preview {
+		if len(verboseInfo.UnresolvedVariables) > 0 {
+			fmt.Fprintf(w, "\n%s Unresolved Variables: \t%-5s \n", symbols.icon(emoji.Warning),
+				strings.Join(verboseInfo.UnresolvedVariables, ", "))
+		}
+	} else if !hasResponse(sr) {
+		fmt.Fprintf(w, "%s Error: \t%-5s \n", symbols.icon(emoji.SosButton), verboseInfo.Error)
+	} else {
+		if verboseInfo.Error != "" {
+			fmt.Fprintf(w, "%s Error: \t%-5s \n", symbols.icon(emoji.SosButton), verboseInfo.Error)
+		}
+		fmt.Fprintln(w, "\n***********  RESPONSE  ***********")
+		fmt.Fprintf(w, "< StatusCode:\t%-5d \n", verboseInfo.Response.StatusCode)
+		fmt.Fprintf(w, "%s\n", blue(fmt.Sprintf("Response Headers: ")))
+		printHeaderLines(w, "<", sr.DebugInfo["responseHeaders"].(http.Header))
+
+		contentType := sr.DebugInfo["responseHeaders"].(http.Header).Get("content-type")
+		fmt.Fprintf(w, "%s\n", blue(fmt.Sprintf("Response Body: ")))
+		printBody(w, contentType, verboseInfo.Response.Body)
+
+		if len(verboseInfo.XPathMatches) > 0 {
+			exprs := make([]string, 0, len(verboseInfo.XPathMatches))
+			for expr := range verboseInfo.XPathMatches {
+				exprs = append(exprs, expr)
 			}
+			sort.Strings(exprs)
+
+			fmt.Fprintf(w, "\n%s\n", blue("XPath Matches: "))
+			for _, expr := range exprs {
+				fmt.Fprintf(w, "< %s:\t%-5s \n", expr, strings.Join(verboseInfo.XPathMatches[expr], ", "))
+			}
+		}
+	}
 
-			contentType := sr.DebugInfo["requestHeaders"].(http.Header).Get("content-type")
-			fmt.Fprintf(w, "%s\n", blue(fmt.Sprintf("Request Body: ")))
-			printBody(w, contentType, verboseInfo.Request.Body)
+	fmt.Fprintln(w)
+	fmt.Fprint(out, b.String())
+}
 
-			if verboseInfo.Error != "" {
-				fmt.Fprintf(w, "%s Error: \t%-5s \n", emoji.SosButton, verboseInfo.Error)
-			} else {
-				fmt.Fprintln(w, "\n***********  RESPONSE  ***********")
-				fmt.Fprintf(w, "< StatusCode:\t%-5d \n", verboseInfo.Response.StatusCode)
-				fmt.Fprintf(w, "%s\n", blue(fmt.Sprintf("Response Headers: ")))
-				for hKey, hVal := range verboseInfo.Response.Headers {
-					fmt.Fprintf(w, "< %s:\t%-5s \n", hKey, hVal)
-				}
+func (s *stdout) printInVerifyMode(input chan *types.ScenarioResult) {
+	color.Cyan("%s Running in verify mode, %d iterations will be played one per second... \n",
+		symbols.icon(emoji.CheckMark), s.verifyCount)
+	color.Cyan("%s Engine fired. \n\n", symbols.icon(emoji.Fire))
+	color.Cyan("%s CTRL+C to gracefully stop.\n", symbols.icon(emoji.StopSign))
 
-				contentType := sr.DebugInfo["responseHeaders"].(http.Header).Get("content-type")
-				fmt.Fprintf(w, "%s\n", blue(fmt.Sprintf("Response Body: ")))
-				printBody(w, contentType, verboseInfo.Response.Body)
+	m := newVerifyMatrix(s.steps, s.verifyCount)
+	for r := range input {
+		m.add(r)
+	}
+	r := m.finish()
+	r.Teardown = s.teardown
+	s.mu.Lock()
+	s.verifyResult = r
+	s.mu.Unlock()
+
+	// Failed requests are printed in the order of the iterations, like the debug mode.
+	for i := 1; i <= r.Iterations; i++ {
+		for _, step := range r.Steps {
+			if c := step.checkOf(i); c != nil && c.sr != nil {
+				color.Cyan("\n\nFAILED REQUEST - ITERATION (%d)\n", i)
+				color.Cyan("=====================================")
+				printStepVerbose(c.sr)
 			}
+		}
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintln(&b, "\n\nVERIFY RESULT")
+	fmt.Fprintln(&b, "-------------------------------------")
+	printVerifyMatrix(&b, r)
+	if len(r.Teardown) > 0 {
+		fmt.Fprintln(&b)
+		w := tabwriter.NewWriter(&b, 0, 0, 4, ' ', 0)
+		printTeardown(w, r.Teardown)
+		w.Flush()
+	}
+	color.Set(color.FgHiCyan)
+	fmt.Fprint(out, b.String())
+	color.Unset()
+}
 
-			fmt.Fprintln(w)
-			fmt.Fprint(out, b.String())
+// isPreview reports whether the given result is rendered in preview mode without sending the requests.
+func isPreview(r *types.ScenarioResult) bool {
+	for _, sr := range r.StepResults {
+		if preview, ok := sr.DebugInfo["preview"].(bool); ok && preview {
+			return true
+		}
+	}
+	return false
+}
+
+// printHeaderLines prints a line per value of the headers, so the repeated headers are shown as they are sent.
+func printHeaderLines(w io.Writer, prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(w, "%s %s:\t%-5s \n", prefix, k, v)
 		}
 	}
 }
@@ -196,6 +593,38 @@ func printBody(w io.Writer, contentType string, body interface{}) {
 }
 
 // TODO:REFACTOR use template
+// stepName returns the name of the step in the results, or in the run info if the step has no results.
+func (s *stdout) stepName(id uint16) string {
+	if v, ok := s.result.StepResults[id]; ok && v.Name != "" {
+		return v.Name
+	}
+	if st := s.run.step(id); st != nil {
+		return st.Name
+	}
+	return ""
+}
+
+// printConfig prints the config echo once the test is initialized, before the first result.
+func (s *stdout) printConfig() {
+	if s.config == nil && s.estimate == nil {
+		return
+	}
+	color.Set(color.FgHiCyan)
+	defer color.Unset()
+
+	b := strings.Builder{}
+	w := tabwriter.NewWriter(&b, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w)
+	if s.config != nil {
+		printConfigEcho(w, s.anonymizer.configEcho(s.config))
+	}
+	if s.estimate != nil {
+		printEstimate(w, newEstimateSummary(*s.estimate), false)
+	}
+	w.Flush()
+	fmt.Fprint(out, b.String())
+}
+
 func (s *stdout) printDetails() {
 	color.Set(color.FgHiCyan)
 	defer color.Unset()
@@ -203,80 +632,368 @@ func (s *stdout) printDetails() {
 	b := strings.Builder{}
 	w := tabwriter.NewWriter(&b, 0, 0, 4, ' ', 0)
 
-	fmt.Fprintln(w, "\n\nRESULT")
-	fmt.Fprintln(w, "-------------------------------------")
+	if s.result.Partial {
+		fmt.Fprintln(w, "\n\nRESULT (PARTIAL)")
+		fmt.Fprintln(w, "-------------------------------------")
+		fmt.Fprintln(w, "Test is aborted, the in-flight iterations are not included.")
+	} else if s.result.Stopped != nil {
+		fmt.Fprintln(w, "\n\nRESULT (STOPPED)")
+		fmt.Fprintln(w, "-------------------------------------")
+		fmt.Fprintf(w, "Test is %s.\n", s.result.Stopped)
+		if s.result.Stopped.Rule != nil {
+			printFiredRule(w, s.result.Stopped.Rule)
+		}
+	} else {
+		fmt.Fprintln(w, "\n\nRESULT")
+		fmt.Fprintln(w, "-------------------------------------")
+	}
+	if s.seed != 0 {
+		fmt.Fprintf(w, "Seed: %d\n", s.seed)
+	}
+	printMetadata(w, s.metadata)
+	printPreflight(w, s.preflight)
+	printEstimate(w, s.result.Estimate, true)
+	printCertificates(w, s.result.Certificates)
 
 	keys := make([]int, 0)
-	for k := range s.result.StepResults {
-		keys = append(keys, int(k))
+	for k, v := range s.result.StepResults {
+		if v.hasResults() {
+			keys = append(keys, int(k))
+		}
+	}
+	// Steps of the run without any result are listed too, like the steps after a step that always fails
+	if s.run != nil {
+		for _, st := range s.run.Steps {
+			if v, ok := s.result.StepResults[st.ID]; !ok || !v.hasResults() {
+				keys = append(keys, int(st.ID))
+			}
+		}
 	}
 
 	// Since map is not a ordered data structure,
 	// We should sort scenarioItemIDs to traverse itemReports
-	sort.Ints(keys)
+	s.run.sortSteps(keys)
 
 	for _, k := range keys {
 		v := s.result.StepResults[uint16(k)]
 
 		if len(keys) > 1 {
-			stepHeader := v.Name
-			if v.Name == "" {
+			stepHeader := s.stepName(uint16(k))
+			if stepHeader == "" {
 				stepHeader = fmt.Sprintf("Step %d", k)
 			}
 			fmt.Fprintf(w, "\n%d. "+stepHeader+"\n", k)
 			fmt.Fprintln(w, "---------------------------------")
 		}
+		if v == nil || !v.hasResults() {
+			fmt.Fprintln(w, "No results, the step did not complete any request.")
+			continue
+		}
 
-		fmt.Fprintf(w, "Success Count:\t%-5d (%d%%)\n", v.SuccessCount, v.successPercentage())
-		fmt.Fprintf(w, "Failed Count:\t%-5d (%d%%)\n", v.FailedCount, v.failedPercentage())
+		total := v.SuccessCount + v.FailedCount
+		fmt.Fprintf(w, "Success Count:\t%-5s (%s)\n", formatCount(v.SuccessCount),
+			formatPercent(v.successPercentage(), v.SuccessCount, total))
+		fmt.Fprintf(w, "Failed Count:\t%-5s (%s)\n", formatCount(v.FailedCount),
+			formatPercent(v.failedPercentage(), v.FailedCount, total))
+		if v.RateLimitedCount > 0 {
+			fmt.Fprintf(w, "Rate Limited:\t%s requests, avg advertised backoff %s\n",
+				formatCount(v.RateLimitedCount), formatDurationPrec(float64(v.AvgRetryAfter), 1))
+		}
+		if v.GeneratorBound {
+			fmt.Fprintf(w, "Client Wait:\tp95 %s over %s, results may be generator-bound, requests are queued "+
+				"in the client\n", formatDuration(float64(v.ClientWaitP95)), formatDuration(ClientWaitThreshold.Seconds()))
+		}
+		if v.SleepClampedCount > 0 {
+			fmt.Fprintf(w, "Sleep Clamped:\t%s sleeps, captured value out of range\n", formatCount(v.SleepClampedCount))
+		}
+		if v.ContinueTimeoutCount > 0 {
+			fmt.Fprintf(w, "Continue Timeout:\t%s requests, no 100 Continue before the timeout, body sent after it\n",
+				formatCount(v.ContinueTimeoutCount))
+		}
+		if v.TruncatedCount > 0 {
+			fmt.Fprintf(w, "Truncated:\t%s responses, avg %s received before the cut, avg TTFB %s\n",
+				formatCount(v.TruncatedCount), formatBytes(int64(v.AvgTruncatedBytes)),
+				formatDuration(float64(v.AvgTruncatedTTFB)))
+		}
+		if v.ChurnRetryCount > 0 {
+			fmt.Fprintf(w, "Churn Retries:\t%s requests retried on a fresh connection, avg %s lost on the stale one\n",
+				formatCount(v.ChurnRetryCount), formatDuration(float64(v.AvgChurnRetryTime)))
+		}
+		if v.BodyTooLargeCount > 0 {
+			fmt.Fprintf(w, "Body Too Large:\t%s responses, the rest of the body over the max_body_size is not read\n",
+				formatCount(v.BodyTooLargeCount))
+		}
+		if v.AnomalousCount > 0 {
+			fmt.Fprintf(w, "Anomalous:\t%s results with a negative or over twice the timeout duration are excluded, "+
+				"the clock of the host may be adjusted during the test, like by NTP\n", formatCount(v.AnomalousCount))
+		}
+		if v.BytesSent+v.BytesReceived > 0 {
+			fmt.Fprintf(w, "Data Transferred:\t%s sent, %s received\n",
+				formatBytes(v.BytesSent), formatBytes(v.BytesReceived))
+		}
+		if conns := v.NewConnections + v.ReusedConnections; conns > 0 {
+			fmt.Fprintf(w, "Connections:\t%s new, %s reused (%s)\n", formatCount(v.NewConnections),
+				formatCount(v.ReusedConnections),
+				formatPercent(int(v.ReusedConnections*100/conns), v.ReusedConnections, conns))
+		}
+		if c := v.Connections; c != nil {
+			fmt.Fprintf(w, "Connection Use:\tAvg requests/connection: %s (p50 %s, p90 %s, max %s), "+
+				"server-initiated closes: %s, reconnects: %s\n", formatNumber(float64(c.AvgRequests), 1),
+				formatCount(c.P50Requests), formatCount(c.P90Requests), formatCount(c.MaxRequests),
+				formatCount(c.ServerCloses), formatCount(c.Reconnects))
+		}
+		if t := v.TLS; t != nil {
+			handshakes := t.FullHandshakes + t.ResumedHandshakes
+			fmt.Fprintf(w, "TLS Handshakes:\t%s full (avg %s), %s resumed (avg %s), %s resumed\n",
+				formatCount(t.FullHandshakes), formatDuration(float64(t.AvgFullDuration)),
+				formatCount(t.ResumedHandshakes), formatDuration(float64(t.AvgResumedDuration)),
+				formatPercent(int(t.ResumedHandshakes*100/handshakes), t.ResumedHandshakes, handshakes))
+			if len(t.Versions) > 0 {
+				fmt.Fprintf(w, "TLS Versions:\t%s\n", formatCounts(t.Versions))
+			}
+			if len(t.ALPN) > 0 {
+				fmt.Fprintf(w, "ALPN Protocols:\t%s\n", formatCounts(t.ALPN))
+			}
+		}
+		if v.CompressedCount > 0 {
+			fmt.Fprintf(w, "Compressed Responses:\t%s responses, %s on the wire, %s decompressed\n",
+				formatCount(v.CompressedCount), formatBytes(v.CompressedBytes), formatBytes(v.DecompressedBytes))
+		}
+		if hg := v.Hedge; hg != nil {
+			requests := v.SuccessCount + v.FailedCount
+			fmt.Fprintf(w, "Hedges:\t%s extra requests, %s of the requests hedged, %s won by the hedge\n",
+				formatCount(hg.HedgeCount), formatPercent(int(hg.HedgeCount*100/requests), hg.HedgeCount, requests),
+				formatCount(hg.HedgeWinCount))
+			fmt.Fprintf(w, "Hedged P50/P95/P99:\t%s / %s / %s, without the hedging at least %s / %s / %s\n",
+				formatDuration(float64(hg.Hedged.P50)), formatDuration(float64(hg.Hedged.P95)),
+				formatDuration(float64(hg.Hedged.P99)), formatDuration(float64(hg.Unhedged.P50)),
+				formatDuration(float64(hg.Unhedged.P95)), formatDuration(float64(hg.Unhedged.P99)))
+		}
+		if c := v.Conditional; c != nil {
+			fmt.Fprintf(w, "Conditional Requests:\t%s conditional, %s unconditional, %s not modified (%s)\n",
+				formatCount(c.ConditionalCount), formatCount(c.UnconditionalCount), formatCount(c.NotModifiedCount),
+				formatPercent(int(c.NotModifiedRate*100), c.NotModifiedCount, c.ConditionalCount))
+			fmt.Fprintf(w, "304/200 Avg/P95:\t%s / %s over %s responses, %s / %s over %s responses\n",
+				formatDuration(float64(c.NotModified.Avg)), formatDuration(float64(c.NotModified.P95)),
+				formatCount(c.NotModified.Count), formatDuration(float64(c.Full.Avg)),
+				formatDuration(float64(c.Full.P95)), formatCount(c.Full.Count))
+		}
+		if d := v.Duplicates; d != nil {
+			answered := d.PairCount - d.FailedCount
+			fmt.Fprintf(w, "Duplicates:\t%s pairs, %s without a response, %s same status, %s same body\n",
+				formatCount(d.PairCount), formatCount(d.FailedCount),
+				formatPercent(int(d.StatusMatchRate*100), d.StatusMatchCount, answered),
+				formatPercent(int(d.BodyMatchRate*100), d.BodyMatchCount, answered))
+			fmt.Fprintf(w, "Original/Duplicate Avg:\t%s / %s\n", formatDuration(float64(d.Original.Avg)),
+				formatDuration(float64(d.Duplicate.Avg)))
+			if len(d.StatusMismatches) > 0 {
+				fmt.Fprintf(w, "Status Mismatches:\t%s\n", formatStatusMismatches(d.StatusMismatches))
+			}
+		}
+		if at := v.AdaptiveTimeout; at != nil {
+			fmt.Fprintf(w, "Adaptive Timeout:	%s - %s, %s cut under the max, %s timed out at the max\n",
+				formatDuration(float64(at.MinTimeout)), formatDuration(float64(at.MaxTimeout)),
+				formatCount(at.CutCount), formatCount(at.MaxTimeoutCount))
+		}
 
 		fmt.Fprintln(w, "\nDurations (Avg):")
-		var durationList = make([]duration, 0)
-		for d, s := range v.Durations {
-			dur := keyToStr[d]
-			dur.duration = s
-			durationList = append(durationList, dur)
+		for _, d := range s.metrics.sorted(uint16(k), v.Durations) {
+			fmt.Fprintf(w, "  %s\t:%s\n", s.metrics.lookup(uint16(k), d).name, formatDuration(float64(v.Durations[d])))
 		}
-		sort.Slice(durationList, func(i, j int) bool {
-			return durationList[i].order < durationList[j].order
-		})
-		for _, v := range durationList {
-			fmt.Fprintf(w, "  %s\t:%.4fs\n", v.name, v.duration)
+
+		if len(v.Counts) > 0 {
+			fmt.Fprintln(w, "\nStream (Avg):")
+			for _, c := range s.metrics.sorted(uint16(k), v.Counts) {
+				fmt.Fprintf(w, "  %s\t:%s\n", s.metrics.lookup(uint16(k), c).name, formatNumber(float64(v.Counts[c]), 1))
+			}
+		}
+
+		if width, rows := v.timeline.coarse(maxTimelineRows); len(rows) > 1 && s.result.schedule != nil {
+			annotateSchedule(rows, width, s.result.schedule, s.result.start, s.result.end)
+			fmt.Fprintf(w, "\nPercentiles Over Time (Per %s, Start:Count:Rate:Target Rate:p50:p95:p99):\n",
+				formatWidth(width))
+			for _, r := range rows {
+				fmt.Fprintf(w, "  %s\t:%s\t:%s\t:%s\t:%s\t:%s\t:%s\n",
+					r.Start.Local().Format(timelineLayout(width)), formatCount(r.Count), formatRate(r.Rate),
+					formatRate(r.TargetRate), formatDuration(float64(r.P50)), formatDuration(float64(r.P95)),
+					formatDuration(float64(r.P99)))
+			}
+		} else if len(rows) > 1 {
+			fmt.Fprintf(w, "\nPercentiles Over Time (Per %s, Start:Count:p50:p95:p99):\n", formatWidth(width))
+			for _, r := range rows {
+				fmt.Fprintf(w, "  %s\t:%s\t:%s\t:%s\t:%s\n", r.Start.Local().Format(timelineLayout(width)),
+					formatCount(r.Count), formatDuration(float64(r.P50)), formatDuration(float64(r.P95)),
+					formatDuration(float64(r.P99)))
+			}
 		}
 
 		if len(v.StatusCodeDist) > 0 {
 			fmt.Fprintln(w, "\nStatus Code (Message) :Count")
 			for s, c := range v.StatusCodeDist {
 				desc := fmt.Sprintf("%3d (%s)", s, http.StatusText(s))
-				fmt.Fprintf(w, "  %s\t:%d\n", desc, c)
+				fmt.Fprintf(w, "  %s\t:%s\n", desc, formatCount(int64(c)))
 			}
 		}
 
 		if len(v.ErrorDist) > 0 {
 			fmt.Fprintln(w, "\nError Distribution (Count:Reason):")
 			for e, c := range v.ErrorDist {
-				fmt.Fprintf(w, "  %d\t :%s\n", c, e)
+				fmt.Fprintf(w, "  %s\t :%s\n", formatCount(int64(c)), e)
+			}
+		}
+		if ShowFailureSamples && len(v.FailureSamples) > 0 {
+			printFailureSamples(w, v)
+		}
+		if v.LeakScan != nil {
+			printLeakScan(w, v.LeakScan)
+		}
+		if len(v.Variants) > 0 {
+			printVariants(w, v.Variants)
+		}
+
+		if len(v.Endpoints) > 0 {
+			fmt.Fprintln(w, "\nEndpoints (Success:Failed:Avg. Duration):")
+			for _, e := range sortedEndpoints(v.Endpoints) {
+				ep := v.Endpoints[e]
+				fmt.Fprintf(w, "  %s\t:%s\t:%s\t:%s\n", e, formatCount(ep.SuccessCount), formatCount(ep.FailedCount),
+					formatDuration(float64(ep.AvgDuration)))
+			}
+		}
+
+		if len(v.Hosts) > 0 {
+			fmt.Fprintln(w, "\nHosts (Success:Failed:Avg. Duration):")
+			for _, h := range sortedEndpoints(v.Hosts) {
+				hs := v.Hosts[h]
+				fmt.Fprintf(w, "  %s\t:%s\t:%s\t:%s\n", h, formatCount(hs.SuccessCount), formatCount(hs.FailedCount),
+					formatDuration(float64(hs.AvgDuration)))
+			}
+			if v.HostLimit > 0 {
+				fmt.Fprintf(w, "  %s The hosts after the first %d are grouped under %s, see report-host-limit\n",
+					symbols.icon(emoji.Warning), v.HostLimit, types.OverflowHost)
+			}
+		}
+
+		if v.Redirects != nil {
+			printRedirects(w, v)
+		}
+		if v.PageLoad != nil {
+			printPageLoad(w, v.PageLoad)
+		}
+
+		for _, name := range sortedDimensions(v.Dimensions) {
+			values := v.Dimensions[name]
+			fmt.Fprintf(w, "\n%s (Success %%:Count:Avg. Duration):\n", name)
+			for _, d := range sortedEndpoints(values) {
+				ds := values[d]
+				count := ds.SuccessCount + ds.FailedCount
+				fmt.Fprintf(w, "  %s\t:%s\t:%s\t:%s\n", d, formatPercent(ds.successPercentage(), ds.SuccessCount, count),
+					formatCount(count), formatDuration(float64(ds.AvgDuration)))
+			}
+		}
+
+		if len(v.ResolvedAddresses) > 0 {
+			fmt.Fprintln(w, "\nResolved Addresses (Host:IP:Connections:Requests:Avg. Duration):")
+			for _, r := range v.ResolvedAddresses {
+				avg := "-"
+				if r.AvgDuration > 0 {
+					avg = formatDuration(float64(r.AvgDuration))
+				}
+				fmt.Fprintf(w, "  %s\t:%s\t:%s\t:%s\t:%s\n", r.Host, r.IP, formatCount(r.Connections),
+					formatCount(r.Requests), avg)
+			}
+			if v.ResolvedAddressOverflow > 0 {
+				fmt.Fprintf(w, "  %s connections to the addresses over the first %d are not listed\n",
+					formatCount(v.ResolvedAddressOverflow), resolvedAddressCapacity)
+			}
+		}
+
+		if len(v.SlowestTargets) > 0 {
+			fmt.Fprintf(w, "\nSlowest Targets (Top %d):\n", topTargetCount)
+			for _, t := range v.SlowestTargets {
+				fmt.Fprintf(w, "  %s\t :%s\n", formatDuration(float64(t.Duration)), t.URL)
+			}
+		}
+		if len(v.FailingTargets) > 0 {
+			fmt.Fprintf(w, "\nMost Failing Targets (Top %d, Count:Target):\n", topTargetCount)
+			for _, t := range v.FailingTargets {
+				fmt.Fprintf(w, "  %s\t :%s\n", formatCount(t.FailedCount), t.URL)
 			}
 		}
 		fmt.Fprintln(w)
 	}
 
+	if len(keys) > 1 && s.result.BytesSent+s.result.BytesReceived > 0 {
+		fmt.Fprintf(w, "Total Data Transferred:\t%s sent, %s received\n\n",
+			formatBytes(s.result.BytesSent), formatBytes(s.result.BytesReceived))
+	}
+
+	printIterationTime(w, s.result.IterationTime, s.run)
+	printRateChanges(w, s.result.RateChanges)
+	printBursts(w, s.result)
+	printUsers(w, s.result.Users)
+	printTeardown(w, s.result.Teardown)
+	printGeneratorHealth(w, s.result.Generator)
+	printDrain(w, s.result.Drain)
+
+	if s.result.Correlations != nil {
+		printCorrelations(w, s.result)
+	}
+
+	if len(s.result.Observations) > 0 {
+		printObservations(w, s.result)
+	}
+
+	if s.result.Comparison != nil {
+		printComparison(w, s.result)
+	}
+
+	if s.result.Criteria != nil {
+		printCriteria(w, s.result.Criteria)
+	}
+
 	w.Flush()
 	fmt.Fprint(out, b.String())
 }
 
-type duration struct {
-	name     string
-	duration float32
-	order    int
+func sortedDimensions(dimensions map[string]map[string]*EndpointSummary) []string {
+	names := make([]string, 0, len(dimensions))
+	for name := range dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedEndpoints returns the endpoints ordered by their request counts, the catch-all group is the last.
+func sortedEndpoints(endpoints map[string]*EndpointSummary) []string {
+	keys := make([]string, 0, len(endpoints))
+	for k := range endpoints {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == types.CatchAllURLGroup) != (keys[j] == types.CatchAllURLGroup) {
+			return keys[j] == types.CatchAllURLGroup
+		}
+		ci := endpoints[keys[i]].SuccessCount + endpoints[keys[i]].FailedCount
+		cj := endpoints[keys[j]].SuccessCount + endpoints[keys[j]].FailedCount
+		if ci == cj {
+			return keys[i] < keys[j]
+		}
+		return ci > cj
+	})
+	return keys
 }
 
-var keyToStr = map[string]duration{
-	"dnsDuration":           {name: "DNS", order: 1},
-	"connDuration":          {name: "Connection", order: 2},
-	"tlsDuration":           {name: "TLS", order: 3},
-	"reqDuration":           {name: "Request Write", order: 4},
-	"serverProcessDuration": {name: "Server Processing", order: 5},
-	"resDuration":           {name: "Response Read", order: 6},
-	"duration":              {name: "Total", order: 7},
+// formatBytes returns the byte size in decimal units like "1.5 MB".
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
 }
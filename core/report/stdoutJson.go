@@ -25,7 +25,11 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net/url"
+	"os"
+	"sync"
 
+	"go.ddosify.com/ddosify/core/criteria"
 	"go.ddosify.com/ddosify/core/types"
 )
 
@@ -36,26 +40,156 @@ func init() {
 }
 
 type stdoutJson struct {
-	doneChan chan struct{}
-	result   *Result
-	debug    bool
+	reportClock
+
+	doneChan  chan struct{}
+	result    *Result
+	steps     []types.ScenarioStep
+	seed      int64
+	metadata  *RunMetadata
+	config    *ConfigEcho
+	rates     rateHistory
+	bursts    burstHistory
+	users     userTracker
+	schedule  *types.LoadSchedule
+	preflight []PreflightProbe
+	generator *GeneratorHealth
+	teardown  []TeardownSummary
+	debug     bool
+
+	abortChan chan struct{}
+	abortOnce sync.Once
+
+	criteria *criteria.Criteria
+	metrics  metricNames
+	limit    *stopLimit
+
+	// Planned iteration count of the verify mode, 0 means disabled
+	verifyCount  int
+	verifyResult *VerifyResult
+
+	// Anonymizes the final report, nil if the report is not anonymized
+	anonymizer *Anonymizer
+
+	drainState
+	latencyFeed
+
+	// Sketch of the duration percentiles of the steps
+	percentiles types.PercentileSketch
+
+	// Load of the test estimated before the run, nil if the engine doesn't estimate it
+	estimate *types.Estimate
+
+	// Previous run that the final result is compared with, nil if the test has no baseline
+	baseline *Baseline
 }
 
 func (s *stdoutJson) Init(debug bool) (err error) {
 	s.doneChan = make(chan struct{})
-	s.result = &Result{
-		StepResults: make(map[uint16]*ScenarioStepResultSummary),
-	}
+	s.abortChan = make(chan struct{})
+	s.result = newResult()
 	s.debug = debug
 	return
 }
 
+func (s *stdoutJson) SetPercentiles(p types.PercentileSketch) {
+	s.percentiles = p
+}
+
+func (s *stdoutJson) SetEstimate(e types.Estimate) {
+	s.estimate = &e
+}
+
+func (s *stdoutJson) SetBaseline(b *Baseline) {
+	s.baseline = b
+}
+
+func (s *stdoutJson) SetPreflight(probes []PreflightProbe) {
+	s.preflight = probes
+}
+
+func (s *stdoutJson) SetTeardown(teardown []TeardownSummary) {
+	s.teardown = teardown
+}
+
+func (s *stdoutJson) SetGeneratorHealth(g GeneratorHealth) {
+	s.generator = &g
+}
+
+func (s *stdoutJson) SetSchedule(sc *types.LoadSchedule) {
+	s.schedule = sc
+}
+
+func (s *stdoutJson) Abort() {
+	s.abortOnce.Do(func() {
+		close(s.abortChan)
+	})
+}
+
+func (s *stdoutJson) SetAnonymizer(a *Anonymizer) {
+	s.anonymizer = a
+}
+
+func (s *stdoutJson) SetCriteria(c *criteria.Criteria) {
+	s.criteria = c
+}
+
+func (s *stdoutJson) CriteriaResult() *criteria.Result {
+	return s.result.Criteria
+}
+
+func (s *stdoutJson) SetStopLimits(l StopLimits, stop func()) {
+	s.limit = newStopLimit(l, stop)
+}
+
+func (s *stdoutJson) SetVerify(iterations int) {
+	s.verifyCount = iterations
+}
+
+func (s *stdoutJson) VerifyResult() *VerifyResult {
+	return s.verifyResult
+}
+
+func (s *stdoutJson) SetMetricMeta(meta map[uint16][]types.MetricMeta) {
+	s.metrics = newMetricNames(meta)
+}
+
+func (s *stdoutJson) SetMetadata(m types.Metadata) {
+	s.metadata = newRunMetadata(m)
+}
+
+func (s *stdoutJson) SetConfig(h types.Hammer, proxies []*url.URL) {
+	s.config = newConfigEcho(h, proxies)
+}
+
+func (s *stdoutJson) RecordRateChange(c RateChange) {
+	s.rates.add(c)
+}
+
+func (s *stdoutJson) RecordBurst(b BurstSummary) {
+	s.bursts.add(b)
+}
+
+func (s *stdoutJson) RecordUser(u UserSummary) {
+	s.users.add(u)
+}
+
+func (s *stdoutJson) SetScenario(sc types.Scenario) {
+	s.steps = sc.Steps
+	s.seed = sc.Seed
+}
+
 func (s *stdoutJson) Start(input chan *types.ScenarioResult) {
 	if s.debug {
 		s.printInDebugMode(input)
 		s.doneChan <- struct{}{}
 		return
 	}
+	if s.verifyCount > 0 {
+		s.printInVerifyMode(input)
+		s.doneChan <- struct{}{}
+		return
+	}
 	s.listenAndAggregate(input)
 	s.report()
 }
@@ -63,16 +197,59 @@ func (s *stdoutJson) Start(input chan *types.ScenarioResult) {
 func (s *stdoutJson) report() {
 	p := 1e3
 
+	s.result.Seed = s.seed
 	s.result.AvgDuration = float32(math.Round(float64(s.result.AvgDuration)*p) / p)
+	if s.result.Stopped != nil {
+		s.result.Stopped.Elapsed = math.Round(s.result.Stopped.Elapsed*p) / p
+	}
+
+	for id, itemReport := range s.result.StepResults {
+		if !itemReport.hasResults() {
+			delete(s.result.StepResults, id)
+			continue
+		}
 
-	for _, itemReport := range s.result.StepResults {
 		durations := make(map[string]float32)
-		for d, s := range itemReport.Durations {
+		for d, v := range itemReport.Durations {
 			// Less precision for durations.
-			t := math.Round(float64(s)*p) / p
-			durations[strKeyToJsonKey[d]] = float32(t)
+			t := math.Round(float64(v)*p) / p
+			durations[s.metrics.lookup(id, d).jsonKey] = float32(t)
 		}
 		itemReport.Durations = durations
+
+		if len(itemReport.Counts) > 0 {
+			counts := make(map[string]float32)
+			for c, v := range itemReport.Counts {
+				counts[s.metrics.lookup(id, c).jsonKey] = float32(math.Round(float64(v)*p) / p)
+			}
+			itemReport.Counts = counts
+		}
+		itemReport.AvgRetryAfter = float32(math.Round(float64(itemReport.AvgRetryAfter)*p) / p)
+
+		for _, e := range itemReport.Endpoints {
+			e.AvgDuration = float32(math.Round(float64(e.AvgDuration)*p) / p)
+		}
+
+		for i, b := range itemReport.Timeline {
+			itemReport.Timeline[i].P50 = float32(math.Round(float64(b.P50)*p) / p)
+			itemReport.Timeline[i].P95 = float32(math.Round(float64(b.P95)*p) / p)
+			itemReport.Timeline[i].P99 = float32(math.Round(float64(b.P99)*p) / p)
+			itemReport.Timeline[i].Rate = math.Round(b.Rate*p) / p
+			itemReport.Timeline[i].TargetRate = math.Round(b.TargetRate*p) / p
+		}
+		// Percentiles keep the microseconds, the precision of the sketches.
+		if pc := itemReport.Percentiles; pc != nil {
+			for _, v := range []*float32{&pc.P50, &pc.P90, &pc.P95, &pc.P99} {
+				*v = float32(math.Round(float64(*v)*1e6) / 1e6)
+			}
+		}
+
+		for i, t := range itemReport.SlowestTargets {
+			itemReport.SlowestTargets[i].Duration = float32(math.Round(float64(t.Duration)*p) / p)
+		}
+		for i, r := range itemReport.ResolvedAddresses {
+			itemReport.ResolvedAddresses[i].AvgDuration = float32(math.Round(float64(r.AvgDuration)*p) / p)
+		}
 	}
 
 	j, _ := json.Marshal(s.result)
@@ -84,8 +261,33 @@ func (s *stdoutJson) DoneChan() <-chan struct{} {
 }
 
 func (s *stdoutJson) listenAndAggregate(input chan *types.ScenarioResult) {
-	for r := range input {
-		aggregate(s.result, r)
+	p := newPipeline(aggregationWorkers, s.steps, s.percentiles)
+	p.limit = s.limit
+	s.feedFrom(p.window)
+	s.limit.begin(s.timeSource())
+	aborted := p.run(input, s.abortChan)
+	s.result = p.snapshot()
+	s.result.Partial = aborted
+	if s.criteria != nil {
+		s.result.Criteria = s.criteria.Evaluate(s.result)
+	}
+	s.result.RunMetadata = s.metadata
+	s.result.RateChanges = s.rates.list()
+	s.result.setBursts(s.bursts.list())
+	s.result.Users = s.users.get()
+	s.result.Preflight = s.preflight
+	s.result.Config = s.config
+	s.result.Teardown = s.teardown
+	s.result.Generator = s.generator
+	s.result.setDrain(s.drainSummary())
+	s.result.setSchedule(s.schedule)
+	s.result.setEstimate(s.estimate)
+	if s.baseline != nil {
+		s.result.Comparison = s.baseline.compare(s.result)
+	}
+	s.result = s.anonymizer.result(s.result)
+	if err := writeTimelineFile(s.result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 	}
 	s.doneChan <- struct{}{}
 }
@@ -96,16 +298,55 @@ func (s *stdoutJson) printInDebugMode(input chan *types.ScenarioResult) {
 	}{
 		DebugResults: map[uint16]verboseHttpRequestInfo{},
 	}
-	for r := range input { // only 1 sc ScenarioResult expected
+	previewResults := struct {
+		Iterations []map[uint16]verboseHttpRequestInfo "json:\"previews\""
+	}{
+		Iterations: []map[uint16]verboseHttpRequestInfo{},
+	}
+	for r := range input { // only 1 sc ScenarioResult expected, except the preview mode
+		if isPreview(r) {
+			steps := map[uint16]verboseHttpRequestInfo{}
+			for _, sr := range r.StepResults {
+				verboseInfo := ScenarioStepResultToVerboseHttpRequestInfo(sr)
+				steps[verboseInfo.StepId] = verboseInfo
+			}
+			previewResults.Iterations = append(previewResults.Iterations, steps)
+			continue
+		}
+
 		for _, sr := range r.StepResults {
 			verboseInfo := ScenarioStepResultToVerboseHttpRequestInfo(sr)
 			stepDebugResults.DebugResults[verboseInfo.StepId] = verboseInfo
 		}
 	}
 
+	if len(previewResults.Iterations) > 0 {
+		printPretty(out, previewResults)
+		return
+	}
 	printPretty(out, stepDebugResults)
 }
 
+func (s *stdoutJson) printInVerifyMode(input chan *types.ScenarioResult) {
+	m := newVerifyMatrix(s.steps, s.verifyCount)
+	for r := range input {
+		m.add(r)
+	}
+	s.verifyResult = m.finish()
+	s.verifyResult.Teardown = s.teardown
+
+	j, _ := json.Marshal(struct {
+		Verify *VerifyResult `json:"verify"`
+		Config *ConfigEcho   `json:"config,omitempty"`
+		*RunMetadata
+	}{
+		Verify:      s.verifyResult,
+		Config:      s.config,
+		RunMetadata: s.metadata,
+	})
+	printJson(j)
+}
+
 func printPretty(w io.Writer, info any) {
 	valPretty, _ := json.MarshalIndent(info, "", "  ")
 	fmt.Fprintf(out, "%s \n",
@@ -147,17 +388,29 @@ var printJson = func(j []byte) {
 	fmt.Println(string(j))
 }
 
-var strKeyToJsonKey = map[string]string{
-	"dnsDuration":           "dns",
-	"connDuration":          "connection",
-	"tlsDuration":           "tls",
-	"reqDuration":           "request_write",
-	"serverProcessDuration": "server_processing",
-	"resDuration":           "response_read",
-	"duration":              "total",
-}
-
 func (v verboseHttpRequestInfo) MarshalJSON() ([]byte, error) {
+	if v.Preview {
+		type alias struct {
+			StepId   uint16 `json:"stepId"`
+			StepName string `json:"stepName"`
+			Request  struct {
+				Url     string            `json:"url"`
+				Method  string            `json:"method"`
+				Headers map[string]string `json:"headers"`
+				Body    interface{}       `json:"body"`
+			} `json:"request"`
+			UnresolvedVariables []string `json:"unresolvedVariables"`
+		}
+
+		a := alias{
+			StepId:              v.StepId,
+			StepName:            v.StepName,
+			Request:             v.Request,
+			UnresolvedVariables: v.UnresolvedVariables,
+		}
+		return json.Marshal(a)
+	}
+
 	if v.Error != "" {
 		type alias struct {
 			StepId   uint16 `json:"stepId"`
@@ -23,11 +23,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"go.ddosify.com/ddosify/core/scenario/requester"
 	"go.ddosify.com/ddosify/core/types"
 )
 
@@ -46,14 +50,15 @@ func TestInitStdoutJson(t *testing.T) {
 }
 
 func TestStdoutJsonListenAndAggregate(t *testing.T) {
+	now := time.Now()
 	responses := []*types.ScenarioResult{
 		{
-			StartTime: time.Now(),
+			StartTime: now,
 			StepResults: []*types.ScenarioStepResult{
 				{
 					StepID:      1,
 					StatusCode:  200,
-					RequestTime: time.Now().Add(1),
+					RequestTime: now.Add(1),
 					Duration:    time.Duration(10) * time.Second,
 					Custom: map[string]interface{}{
 						"dnsDuration":  time.Duration(5) * time.Second,
@@ -62,7 +67,7 @@ func TestStdoutJsonListenAndAggregate(t *testing.T) {
 				},
 				{
 					StepID:      2,
-					RequestTime: time.Now().Add(2),
+					RequestTime: now.Add(2),
 					Duration:    time.Duration(30) * time.Second,
 					Err:         types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout},
 					Custom: map[string]interface{}{
@@ -73,12 +78,12 @@ func TestStdoutJsonListenAndAggregate(t *testing.T) {
 			},
 		},
 		{
-			StartTime: time.Now().Add(10),
+			StartTime: now.Add(10),
 			StepResults: []*types.ScenarioStepResult{
 				{
 					StepID:      1,
 					StatusCode:  200,
-					RequestTime: time.Now().Add(11),
+					RequestTime: now.Add(11),
 					Duration:    time.Duration(30) * time.Second,
 					Custom: map[string]interface{}{
 						"dnsDuration":  time.Duration(10) * time.Second,
@@ -88,7 +93,7 @@ func TestStdoutJsonListenAndAggregate(t *testing.T) {
 				{
 					StepID:      2,
 					StatusCode:  401,
-					RequestTime: time.Now().Add(12),
+					RequestTime: now.Add(12),
 					Duration:    time.Duration(60) * time.Second,
 					Custom: map[string]interface{}{
 						"dnsDuration":  time.Duration(20) * time.Second,
@@ -108,8 +113,12 @@ func TestStdoutJsonListenAndAggregate(t *testing.T) {
 			"connDuration": 12.5,
 			"duration":     20,
 		},
-		ErrorDist: map[string]int{},
+		ErrorDist:   map[string]int{},
+		Percentiles: histogramPercentiles(10*time.Second, 30*time.Second),
 	}
+	itemReport1.timeline = timelineOf(now, 10*time.Second, 30*time.Second)
+	itemReport1.percentiles = itemReport1.Percentiles.byRank()
+	itemReport1.Timeline = itemReport1.timeline.buckets(TimelineInterval)
 	itemReport2 := &ScenarioStepResultSummary{
 		StatusCodeDist: map[int]int{401: 1},
 		SuccessCount:   1,
@@ -119,8 +128,15 @@ func TestStdoutJsonListenAndAggregate(t *testing.T) {
 			"connDuration": 40,
 			"duration":     60,
 		},
-		ErrorDist: map[string]int{types.ReasonConnTimeout: 1},
+		ErrorDist:   map[string]int{types.ReasonConnTimeout: 1},
+		Percentiles: histogramPercentiles(60 * time.Second),
+		FailureSamples: map[string][]FailureSample{
+			types.ReasonConnTimeout: {{Time: now.Add(2), Duration: 30}},
+		},
 	}
+	itemReport2.timeline = timelineOf(now, 60*time.Second)
+	itemReport2.percentiles = itemReport2.Percentiles.byRank()
+	itemReport2.Timeline = itemReport2.timeline.buckets(TimelineInterval)
 
 	expectedResult := Result{
 		SuccessCount: 1,
@@ -251,6 +267,8 @@ func TestStdoutJsonOutput(t *testing.T) {
 
 	// Act
 	s := &stdoutJson{result: &result}
+	httpMeta := (&requester.HttpRequester{}).MetricMeta()
+	s.SetMetricMeta(map[uint16][]types.MetricMeta{1: httpMeta, 2: httpMeta})
 	s.report()
 
 	// Assert
@@ -259,6 +277,231 @@ func TestStdoutJsonOutput(t *testing.T) {
 	}
 }
 
+func TestStdoutJsonStopReason(t *testing.T) {
+	realPrintJson := printJson
+	defer func() {
+		printJson = realPrintJson
+	}()
+	var output string
+	printJson = func(j []byte) {
+		output = string(j)
+	}
+
+	result := newResult()
+	result.FailedCount = 7
+	result.Stopped = &StopReason{Reason: "stopped after 2 failed requests", Elapsed: 1.23456, Iteration: 7}
+	s := &stdoutJson{result: result}
+	s.report()
+
+	expected := `"stop_reason":{"reason":"stopped after 2 failed requests","elapsed":1.235,"iteration":7}`
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected %s in the output, Found: %s", expected, output)
+	}
+}
+
+func TestStdoutJsonFailureSamples(t *testing.T) {
+	realPrintJson := printJson
+	defer func() {
+		printJson = realPrintJson
+	}()
+	var output string
+	printJson = func(j []byte) {
+		output = string(j)
+	}
+
+	agg := newAggregator()
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+		StepID:      1,
+		RequestTime: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+		Duration:    250 * time.Millisecond,
+		Err:         types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout},
+		Custom:      map[string]interface{}{"failedURL": "https://example.com/"},
+		IterationID: 12,
+		VUID:        3,
+	}}})
+	s := &stdoutJson{result: agg.result()}
+	s.report()
+
+	// Samples are in the JSON output without a flag
+	expected := `"failure_samples":{"connection timeout":[{"time":"2023-01-01T12:00:00Z","url":"https://example.com/",` +
+		`"duration":0.25,"iteration_id":12,"vu_id":3}]}`
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected %s in the output, Found: %s", expected, output)
+	}
+}
+
+func TestStdoutJsonCertificates(t *testing.T) {
+	realPrintJson := printJson
+	defer func() {
+		printJson = realPrintJson
+	}()
+	var output string
+	printJson = func(j []byte) {
+		output = string(j)
+	}
+
+	agg := newAggregator()
+	agg.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+		StepID:      1,
+		RequestTime: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+		Custom: map[string]interface{}{"tlsCertificate": types.Certificate{Host: "test.com", Subject: "CN=test.com",
+			Issuer: "CN=R3", NotAfter: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			SANs: []string{"test.com", "www.test.com"}, ChainLength: 2}},
+	}}})
+	s := &stdoutJson{result: agg.result()}
+	s.report()
+
+	expected := `"certificates":[{"host":"test.com","subject":"CN=test.com","issuer":"CN=R3",` +
+		`"not_after":"2026-11-01T00:00:00Z","sans":["test.com","www.test.com"],"chain_length":2,"days_left":17}]`
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected %s in the output, Found: %s", expected, output)
+	}
+}
+
+func TestStdoutJsonMetadata(t *testing.T) {
+	realPrintJson := printJson
+	defer func() {
+		printJson = realPrintJson
+	}()
+	var output string
+	printJson = func(j []byte) {
+		output = string(j)
+	}
+
+	result := newResult()
+	result.RunMetadata = newRunMetadata(types.Metadata{
+		RunID:      "r1",
+		Labels:     []types.Label{{Key: "env", Value: "staging"}, {Key: "token", Value: "abc", Secret: true}},
+		ConfigHash: "c0ffee",
+		Version:    "v1.0.0",
+		Hostname:   "runner-1",
+	})
+	s := &stdoutJson{result: result}
+	s.report()
+
+	expected := `"run_id":"r1","labels":{"env":"staging","token":"[REDACTED]"},"config_hash":"c0ffee",` +
+		`"version":"v1.0.0","hostname":"runner-1"}`
+	if !strings.HasSuffix(output, expected) {
+		t.Errorf("Expected %s at the top level of the output, Found: %s", expected, output)
+	}
+	if strings.Contains(output, "abc") {
+		t.Errorf("Secret label should be redacted, Found: %s", output)
+	}
+}
+
+func TestStdoutJsonRateChanges(t *testing.T) {
+	realPrintJson := printJson
+	defer func() {
+		printJson = realPrintJson
+	}()
+	var output string
+	printJson = func(j []byte) {
+		output = string(j)
+	}
+
+	s := &stdoutJson{}
+	s.Init(false)
+	s.doneChan = make(chan struct{}, 1)
+	at := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	s.RecordRateChange(RateChange{Time: at, Value: 12.5, Rate: 25})
+	s.RecordRateChange(RateChange{Time: at.Add(10 * time.Second), Value: 300, Rate: 500})
+
+	input := make(chan *types.ScenarioResult)
+	close(input)
+	s.Start(input)
+
+	expected := `"rate_changes":[{"time":"2026-10-14T10:00:00Z","value":12.5,"rate":25},` +
+		`{"time":"2026-10-14T10:00:10Z","value":300,"rate":500}]`
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected %s in the output, Found: %s", expected, output)
+	}
+}
+
+func TestStdoutJsonTeardown(t *testing.T) {
+	realPrintJson := printJson
+	defer func() {
+		printJson = realPrintJson
+	}()
+	var output string
+	printJson = func(j []byte) {
+		output = string(j)
+	}
+
+	s := &stdoutJson{}
+	s.Init(false)
+	s.doneChan = make(chan struct{}, 1)
+	s.SetTeardown([]TeardownSummary{{StepID: 1, Foreach: "order_id", Collected: 3, Processed: 2, Failed: 1,
+		Requests: 3, FailedRequests: 1}})
+
+	input := make(chan *types.ScenarioResult)
+	close(input)
+	s.Start(input)
+
+	expected := `"teardown":[{"step_id":1,"foreach":"order_id","collected":3,"processed":2,"failed":1,` +
+		`"requests":3,"failed_requests":1}]`
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected %s in the output, Found: %s", expected, output)
+	}
+}
+
+func TestStdoutJsonConfigEcho(t *testing.T) {
+	realPrintJson := printJson
+	defer func() {
+		printJson = realPrintJson
+	}()
+	var output string
+	printJson = func(j []byte) {
+		output = string(j)
+	}
+
+	s := &stdoutJson{}
+	s.Init(false)
+	s.doneChan = make(chan struct{}, 1)
+	s.SetConfig(types.Hammer{LoadType: types.LoadTypeLinear, IterationCount: 20, TestDuration: 10,
+		DynamicRate: &types.DynamicRate{URL: "https://rate.test.com/?api_key=abc", Min: 1, Max: 50},
+		Scenario: types.Scenario{Steps: []types.ScenarioStep{{ID: 1, Method: "GET", URL: "https://test.com",
+			Timeout: 5, Headers: map[string]string{"Cookie": "session=abc"}}}}}, []*url.URL{nil})
+
+	input := make(chan *types.ScenarioResult)
+	close(input)
+	s.Start(input)
+
+	expected := `"config":{"load_type":"linear","iteration_count":20,"duration":10,"rate":2,` +
+		`"dynamic_rate":{"source":"https://rate.test.com/?api_key=***","min":1,"max":50},` +
+		`"steps":[{"id":1,"method":"GET","url":"https://test.com","headers":{"Cookie":"***"},"timeout":5}]}`
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected %s in the output, Found: %s", expected, output)
+	}
+}
+
+func TestStdoutJsonComparison(t *testing.T) {
+	realPrintJson := printJson
+	defer func() {
+		printJson = realPrintJson
+	}()
+	var output string
+	printJson = func(j []byte) {
+		output = string(j)
+	}
+
+	s := &stdoutJson{}
+	s.Init(false)
+	s.doneChan = make(chan struct{}, 1)
+	s.SetBaseline(&Baseline{Path: "baseline.json", SuccessCount: 1, AvgDuration: 0.5})
+
+	input := make(chan *types.ScenarioResult, 1)
+	input <- &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, Duration: time.Second}}}
+	close(input)
+	s.Start(input)
+
+	expected := `"comparison":{"baseline":"baseline.json","deltas":[` +
+		`{"metric":"success_perc","baseline":100,"current":100,"change":0},` +
+		`{"metric":"avg_duration","baseline":0.5,"current":1,"change":100}]}`
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected %s in the output, Found: %s", expected, output)
+	}
+}
+
 func TestStdoutJsonDebugModePrintsValidJson(t *testing.T) {
 	s := &stdoutJson{}
 	s.Init(true)
@@ -359,3 +602,73 @@ func TestVerboseHttpInfoMarshallingSuccessCase(t *testing.T) {
 		t.Errorf("Verbose Http Info should have response in success case")
 	}
 }
+
+func TestStdoutJsonPreviewPrintsValidJson(t *testing.T) {
+	s := &stdoutJson{}
+	s.Init(true)
+
+	realOut := out
+	r, w, _ := os.Pipe()
+	out = w
+	defer func() {
+		out = realOut
+	}()
+
+	previewResult := func() *types.ScenarioResult {
+		return &types.ScenarioResult{
+			StepResults: []*types.ScenarioStepResult{
+				{
+					StepID: 1,
+					DebugInfo: map[string]interface{}{
+						"url":                 "http://test.com",
+						"method":              http.MethodGet,
+						"requestHeaders":      http.Header{},
+						"requestBody":         []byte{},
+						"preview":             true,
+						"unresolvedVariables": []string{"{{token}}"},
+					},
+				},
+			},
+		}
+	}
+	inputChan := make(chan *types.ScenarioResult, 2)
+	inputChan <- previewResult()
+	inputChan <- previewResult()
+	close(inputChan)
+
+	go s.Start(inputChan)
+	<-s.DoneChan()
+	w.Close()
+
+	printedOutput, _ := ioutil.ReadAll(r)
+	var output struct {
+		Previews []map[string]struct {
+			UnresolvedVariables []string `json:"unresolvedVariables"`
+		} `json:"previews"`
+	}
+	if err := json.Unmarshal(printedOutput, &output); err != nil {
+		t.Fatalf("Printed output is not valid json: %v", string(printedOutput))
+	}
+	if len(output.Previews) != 2 {
+		t.Errorf("Expected %d previews, Found %d", 2, len(output.Previews))
+	}
+	if output.Previews[0]["1"].UnresolvedVariables[0] != "{{token}}" {
+		t.Errorf("Unresolved variables should be printed, found: %v", output.Previews[0])
+	}
+}
+
+func TestStdoutJsonAbort(t *testing.T) {
+	s := &stdoutJson{}
+	s.Init(false)
+
+	input := make(chan *types.ScenarioResult, 1)
+	input <- &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{StepID: 1, StatusCode: 200}}}
+	go s.listenAndAggregate(input)
+	time.AfterFunc(100*time.Millisecond, s.Abort)
+	<-s.DoneChan()
+
+	j, _ := json.Marshal(s.result)
+	if !strings.Contains(string(j), `"partial":true`) {
+		t.Errorf("Aborted result should be marked as partial, Found: %s", j)
+	}
+}
@@ -0,0 +1,372 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+)
+
+const OutputTypeStdoutUI = "stdout-ui"
+
+// UIRefreshInterval is the redraw interval of the full-screen dashboard.
+var UIRefreshInterval = time.Duration(500) * time.Millisecond
+
+const (
+	// ANSI sequences used to draw the dashboard on the alternate screen buffer.
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+
+	sparklineWidth   = 30
+	progressBarWidth = 30
+	topErrorCount    = 3
+)
+
+func init() {
+	AvailableOutputServices[OutputTypeStdoutUI] = &stdoutUI{}
+}
+
+// stdoutUI renders a full-screen terminal dashboard with one panel per step while the test is running.
+// The final summary is the same as the stdout report. On dumb terminals it falls back to the stdout behavior.
+type stdoutUI struct {
+	stdout
+
+	fallback bool
+	agg      *aggregator
+	panels   map[uint16]*stepPanel
+	start    time.Time
+	stopChan chan struct{}
+	uiDone   chan struct{}
+}
+
+// stepPanel keeps the rolling metrics of a step between two redraws.
+type stepPanel struct {
+	completed  int
+	durations  []float64
+	rps        float64
+	p95History []float64
+}
+
+func (s *stdoutUI) Init(debug bool) (err error) {
+	if err = s.stdout.Init(debug); err != nil {
+		return
+	}
+	s.agg = newAggregator()
+	s.panels = make(map[uint16]*stepPanel)
+	s.fallback = debug || !isInteractiveTerminal()
+	return
+}
+
+func (s *stdoutUI) InitRun(debug bool, info RunInfo) error {
+	s.run = &info
+	return s.Init(debug)
+}
+
+func (s *stdoutUI) SetPercentiles(p types.PercentileSketch) {
+	s.stdout.SetPercentiles(p)
+	s.agg.sketch = p
+}
+
+func (s *stdoutUI) SetScenario(sc types.Scenario) {
+	s.stdout.SetScenario(sc)
+	s.agg.initSteps(sc.Steps)
+}
+
+// SetVerify falls back to the stdout behavior, the matrix is printed once the verify mode is finished.
+func (s *stdoutUI) SetVerify(iterations int) {
+	s.stdout.SetVerify(iterations)
+	s.fallback = true
+}
+
+func (s *stdoutUI) Start(input chan *types.ScenarioResult) {
+	if s.fallback {
+		s.stdout.Start(input)
+		return
+	}
+
+	s.printConfig()
+	s.feedFrom(func(id uint16, into map[int64]*histogram) {
+		s.mu.Lock()
+		s.agg.window(id, into)
+		s.mu.Unlock()
+	})
+	s.startUI()
+	defer func() {
+		// Restore the terminal if the aggregation panics while the dashboard is on the screen.
+		if r := recover(); r != nil {
+			fmt.Fprint(out, exitAltScreen)
+			panic(r)
+		}
+	}()
+	aborted := false
+loop:
+	for {
+		select {
+		case r, ok := <-input:
+			if !ok {
+				break loop
+			}
+			s.mu.Lock()
+			s.agg.add(r)
+			s.record(r)
+			s.mu.Unlock()
+			s.limit.observe(r)
+		case <-s.abortChan:
+			aborted = true
+			break loop
+		}
+	}
+	s.stopUI()
+	s.mu.Lock()
+	s.result = s.agg.result()
+	s.result.Partial = aborted
+	s.result.Stopped = s.limit.stopReason()
+	s.finished = true
+	s.mu.Unlock()
+
+	s.finish()
+	s.doneChan <- struct{}{}
+}
+
+func (s *stdoutUI) WriteStatus(w io.Writer) {
+	if s.fallback {
+		s.stdout.WriteStatus(w)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.finished {
+		s.result = s.agg.result()
+	}
+	s.writeStatus(w)
+}
+
+func (s *stdoutUI) record(r *types.ScenarioResult) {
+	for _, sr := range r.StepResults {
+		p, ok := s.panels[sr.StepID]
+		if !ok {
+			p = &stepPanel{}
+			s.panels[sr.StepID] = p
+		}
+		p.completed++
+		if sr.Err.Type == "" {
+			p.durations = append(p.durations, sr.Duration.Seconds())
+		}
+	}
+}
+
+func (s *stdoutUI) startUI() {
+	s.start = s.timeSource().Now()
+	s.limit.begin(s.timeSource())
+	if s.progress != nil {
+		s.progress.begin(s.start)
+	}
+	s.stopChan = make(chan struct{})
+	s.uiDone = make(chan struct{})
+	fmt.Fprint(out, enterAltScreen)
+
+	go func() {
+		ticker := s.timeSource().NewTicker(UIRefreshInterval)
+		defer func() {
+			ticker.Stop()
+			fmt.Fprint(out, exitAltScreen)
+			close(s.uiDone)
+			if r := recover(); r != nil {
+				panic(r)
+			}
+		}()
+
+		last := s.timeSource().Now()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case now := <-ticker.C():
+				s.mu.Lock()
+				if !s.finished {
+					s.result = s.agg.result()
+				}
+				s.roll(now.Sub(last))
+				if s.progress != nil {
+					s.progress.update(s.result.SuccessCount+s.result.FailedCount, now)
+				}
+				screen := s.render(now.Sub(s.start))
+				s.mu.Unlock()
+				fmt.Fprint(out, clearScreen+screen)
+				last = now
+			}
+		}
+	}()
+}
+
+func (s *stdoutUI) stopUI() {
+	close(s.stopChan)
+	<-s.uiDone
+}
+
+// roll closes the current window of each panel and calculates its RPS and p95 values.
+func (s *stdoutUI) roll(window time.Duration) {
+	for _, p := range s.panels {
+		if window > 0 {
+			p.rps = float64(p.completed) / window.Seconds()
+		}
+		if len(p.durations) > 0 {
+			p.p95History = append(p.p95History, percentile(p.durations, 95))
+			if len(p.p95History) > sparklineWidth {
+				p.p95History = p.p95History[len(p.p95History)-sparklineWidth:]
+			}
+		}
+		p.completed = 0
+		p.durations = p.durations[:0]
+	}
+}
+
+func (s *stdoutUI) render(elapsed time.Duration) string {
+	b := strings.Builder{}
+
+	fmt.Fprintf(&b, "%s  ", white("DDOSIFY"))
+	if d, ok := s.draining(); ok {
+		fmt.Fprintf(&b, "%s  (CTRL+C to abort)\n\n", drainLine(d))
+	} else {
+		if s.progress != nil {
+			completed := s.result.SuccessCount + s.result.FailedCount
+			b.WriteString(s.progress.summary(completed, s.progress.start.Add(elapsed), true))
+		} else {
+			fmt.Fprintf(&b, "Elapsed: %s", elapsed.Truncate(time.Second))
+		}
+		fmt.Fprintf(&b, "  (CTRL+C to gracefully stop, twice to abort)\n\n")
+	}
+
+	total := s.result.SuccessCount + s.result.FailedCount
+	fmt.Fprintf(&b, "%s  %s  %s\n",
+		green(fmt.Sprintf("Successful Run: %-6s %4s", formatCount(s.result.SuccessCount),
+			formatPercent(s.result.successPercentage(), s.result.SuccessCount, total))),
+		red(fmt.Sprintf("Failed Run: %-6s %4s", formatCount(s.result.FailedCount),
+			formatPercent(s.result.failedPercentage(), s.result.FailedCount, total))),
+		blue(fmt.Sprintf("Avg. Duration: %s", formatDurationPrec(float64(s.result.AvgDuration), 5))))
+
+	keys := make([]int, 0, len(s.result.StepResults))
+	for k := range s.result.StepResults {
+		keys = append(keys, int(k))
+	}
+	s.run.sortSteps(keys)
+
+	for _, k := range keys {
+		v := s.result.StepResults[uint16(k)]
+		p, ok := s.panels[uint16(k)]
+		if !ok {
+			continue
+		}
+
+		stepHeader := v.Name
+		if v.Name == "" {
+			stepHeader = fmt.Sprintf("Step %d", k)
+		}
+		fmt.Fprintf(&b, "\n%s\n", blue(fmt.Sprintf("%d. %s", k, stepHeader)))
+		fmt.Fprintln(&b, "---------------------------------")
+		fmt.Fprintf(&b, "  RPS: %-10s Success: %4s (%s ok / %s failed)\n", formatNumber(p.rps, 1),
+			formatPercent(v.successPercentage(), v.SuccessCount, v.SuccessCount+v.FailedCount),
+			formatCount(v.SuccessCount), formatCount(v.FailedCount))
+
+		var lastP95 float64
+		if len(p.p95History) > 0 {
+			lastP95 = p.p95History[len(p.p95History)-1]
+		}
+		fmt.Fprintf(&b, "  p95: %-10s %s\n", formatDuration(lastP95), sparkline(p.p95History))
+
+		if errs := topErrors(v.ErrorDist, topErrorCount); len(errs) > 0 {
+			fmt.Fprintln(&b, "  Top Errors:")
+			for _, e := range errs {
+				fmt.Fprintf(&b, "    %s\n", red(fmt.Sprintf("%-6s %s", formatCount(int64(v.ErrorDist[e])), e)))
+			}
+		}
+	}
+	return b.String()
+}
+
+// isInteractiveTerminal reports whether the stdout is a terminal that supports the cursor movements.
+func isInteractiveTerminal() bool {
+	if util.IsSystemInTestMode() || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+func sparkline(values []float64) string {
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	b := strings.Builder{}
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(v / max * float64(len(symbols.sparkTicks)-1))
+		}
+		b.WriteRune(symbols.sparkTicks[i])
+	}
+	return b.String()
+}
+
+// percentile returns the p-th percentile of the given values by the nearest-rank method.
+func percentile(values []float64, p int) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// topErrors returns at most n error reasons ordered by their counts.
+func topErrors(dist map[string]int, n int) []string {
+	reasons := make([]string, 0, len(dist))
+	for r := range dist {
+		reasons = append(reasons, r)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if dist[reasons[i]] == dist[reasons[j]] {
+			return reasons[i] < reasons[j]
+		}
+		return dist[reasons[i]] > dist[reasons[j]]
+	})
+	if len(reasons) > n {
+		reasons = reasons[:n]
+	}
+	return reasons
+}
@@ -0,0 +1,124 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestInitStdoutUIFallsBackInTestMode(t *testing.T) {
+	s := &stdoutUI{}
+	s.Init(false)
+
+	if !s.fallback {
+		t.Errorf("Non interactive terminals should fall back to the stdout output")
+	}
+	if s.doneChan == nil {
+		t.Errorf("DoneChan should be initialized")
+	}
+}
+
+func TestInitStdoutUIWithRunInfo(t *testing.T) {
+	s := &stdoutUI{}
+	if err := InitReport(s, false, RunInfo{Steps: []StepInfo{{ID: 1}}}); err != nil {
+		t.Fatalf("InitReport errored: %v", err)
+	}
+
+	if s.agg == nil || s.panels == nil || s.doneChan == nil {
+		t.Errorf("Dashboard should be initialized by InitRun")
+	}
+	if s.run == nil || len(s.run.Steps) != 1 {
+		t.Errorf("Run info should be kept, Found %v", s.run)
+	}
+}
+
+func TestStdoutUIRender(t *testing.T) {
+	s := &stdoutUI{}
+	s.Init(false)
+	s.SetLoadPlan(time.Duration(100)*time.Millisecond, []int{2, 2})
+
+	r := &types.ScenarioResult{
+		StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StepName: "Login", StatusCode: 200, Duration: time.Duration(100) * time.Millisecond},
+			{StepID: 2, Err: types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}},
+		},
+	}
+	s.agg.add(r)
+	s.result = s.agg.result()
+	s.record(r)
+	s.roll(time.Second)
+
+	screen := s.render(time.Second)
+	expected := []string{"1. Login", "2. Step 2", "RPS: 1.0", "p95: 0.1000", types.ReasonConnTimeout, "25%"}
+	for _, e := range expected {
+		if !strings.Contains(screen, e) {
+			t.Errorf("Expected %q in the screen, Found: %s", e, screen)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3, 6, 7, 8, 9, 10}
+
+	tests := []struct {
+		p        int
+		expected float64
+	}{
+		{50, 5},
+		{95, 10},
+		{10, 1},
+	}
+
+	for _, test := range tests {
+		if v := percentile(values, test.p); v != test.expected {
+			t.Errorf("p%d Expected %v, Found %v", test.p, test.expected, v)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	useCharset(t, unicodeCharset)
+	if s := sparkline([]float64{0, 1, 2}); s != "▁▄█" {
+		t.Errorf("Expected %s, Found %s", "▁▄█", s)
+	}
+	if s := sparkline(nil); s != "" {
+		t.Errorf("Expected empty sparkline, Found %s", s)
+	}
+
+	useCharset(t, asciiCharset)
+	if s := sparkline([]float64{0, 1, 2}); s != "_:#" {
+		t.Errorf("Expected %s, Found %s", "_:#", s)
+	}
+}
+
+func TestTopErrors(t *testing.T) {
+	dist := map[string]int{"a": 1, "b": 5, "c": 3, "d": 3}
+	errs := topErrors(dist, 3)
+
+	expected := []string{"b", "c", "d"}
+	if strings.Join(errs, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, Found %v", expected, errs)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// StopReason is the reason of the test stopping before its planned end, recorded in the result.
+type StopReason struct {
+	Reason string `json:"reason"`
+	// Seconds since the start of the test
+	Elapsed float64 `json:"elapsed"`
+	// Iteration at which the test is stopped, in order of completion
+	Iteration int64 `json:"iteration"`
+	// Set if the test is stopped by an abort rule
+	Rule *FiredRule `json:"abort_rule,omitempty"`
+}
+
+func (r *StopReason) String() string {
+	return fmt.Sprintf("%s at %s, iteration %d", r.Reason,
+		(time.Duration(r.Elapsed * float64(time.Second))).Round(time.Millisecond), r.Iteration)
+}
+
+// StopLimits are the limits that stop the test before its planned end. Zero values mean the limit is disabled.
+type StopLimits struct {
+	// Failed request count
+	Failures int
+	// Bytes sent and received over the connections
+	Transfer int64
+	// Rules over the windows of the step results
+	AbortRules []AbortRule
+}
+
+// stopLimit stops the test once one of the limits is reached. observe is called concurrently
+// by the aggregation workers, so the counters are atomic.
+type stopLimit struct {
+	limits StopLimits
+	stop   func()
+	clock  clock.Clock
+	start  time.Time
+
+	failures    int64
+	transferred int64
+	iterations  int64
+
+	windows *abortWindows
+
+	mu     sync.Mutex
+	reason *StopReason
+}
+
+func newStopLimit(l StopLimits, stop func()) *stopLimit {
+	if l.Failures <= 0 && l.Transfer <= 0 && len(l.AbortRules) == 0 {
+		return nil
+	}
+	return &stopLimit{limits: l, stop: stop, windows: newAbortWindows(l.AbortRules)}
+}
+
+// begin starts the windows of the abort rules and the elapsed time of the stop reason on the clock.
+func (f *stopLimit) begin(c clock.Clock) {
+	if f != nil {
+		f.clock, f.start = c, c.Now()
+	}
+}
+
+// observe counts the failed requests and the transferred bytes of the iteration, adds it to the windows of
+// the abort rules and stops the test once a limit is reached or a rule is violated.
+func (f *stopLimit) observe(r *types.ScenarioResult) {
+	if f == nil {
+		return
+	}
+	iteration := atomic.AddInt64(&f.iterations, 1)
+
+	var failed, transferred int64
+	for _, sr := range r.StepResults {
+		if sr.Err.Type != "" {
+			failed++
+		}
+		transferred += transferredBytes(sr)
+	}
+
+	if f.limits.Failures > 0 && failed > 0 &&
+		atomic.AddInt64(&f.failures, failed) >= int64(f.limits.Failures) {
+		f.stopAt(iteration, fmt.Sprintf("stopped after %d failed requests", f.limits.Failures), nil)
+	}
+	if f.limits.Transfer > 0 && transferred > 0 &&
+		atomic.AddInt64(&f.transferred, transferred) >= f.limits.Transfer {
+		f.stopAt(iteration, fmt.Sprintf("stopped after %s transferred", formatBytes(f.limits.Transfer)), nil)
+	}
+	if f.windows != nil {
+		if fired := f.windows.observe(r, f.clock.Since(f.start)); fired != nil {
+			f.stopAt(iteration, fmt.Sprintf("aborted by the rule %s", fired.Rule), fired)
+		}
+	}
+}
+
+// stopAt records the reason and stops the test, only for the first reached limit.
+func (f *stopLimit) stopAt(iteration int64, reason string, rule *FiredRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reason != nil {
+		return
+	}
+	f.reason = &StopReason{
+		Reason:    reason,
+		Elapsed:   f.clock.Since(f.start).Seconds(),
+		Iteration: iteration,
+		Rule:      rule,
+	}
+	f.stop()
+}
+
+// stopReason returns the reason if a limit is reached, nil otherwise.
+func (f *stopLimit) stopReason() *StopReason {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reason
+}
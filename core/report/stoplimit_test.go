@@ -0,0 +1,291 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func failedResult(failedSteps, steps int) *types.ScenarioResult {
+	r := &types.ScenarioResult{}
+	for i := 0; i < steps; i++ {
+		sr := &types.ScenarioStepResult{StepID: uint16(i + 1)}
+		if i < failedSteps {
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: "connection refused"}
+		}
+		r.StepResults = append(r.StepResults, sr)
+	}
+	return r
+}
+
+func transferResult(sent, received int64) *types.ScenarioResult {
+	return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{{
+		StepID: 1,
+		Custom: map[string]interface{}{"bytesSent": sent, "bytesReceived": received},
+	}}}
+}
+
+func TestStopLimitFailures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		limit     int
+		results   []*types.ScenarioResult
+		stopped   bool
+		iteration int64
+	}{
+		{"BelowLimit", 3, []*types.ScenarioResult{failedResult(1, 2), failedResult(0, 2), failedResult(1, 2)}, false, 0},
+		{"ReachedLimit", 3, []*types.ScenarioResult{failedResult(1, 2), failedResult(0, 2), failedResult(2, 2)}, true, 3},
+		{"AfterLimit", 1, []*types.ScenarioResult{failedResult(0, 1), failedResult(1, 1), failedResult(1, 1)}, true, 2},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			stopCount := 0
+			f := newStopLimit(StopLimits{Failures: test.limit}, func() { stopCount++ })
+			f.begin(clock.Real())
+			for _, r := range test.results {
+				f.observe(r)
+			}
+
+			reason := f.stopReason()
+			if (reason != nil) != test.stopped {
+				t.Fatalf("Expected stopped %v, Found %#v", test.stopped, reason)
+			}
+			if !test.stopped {
+				if stopCount != 0 {
+					t.Errorf("Stop should not be called, Found %d calls", stopCount)
+				}
+				return
+			}
+			if stopCount != 1 {
+				t.Errorf("Stop should be called once, Found %d calls", stopCount)
+			}
+			if reason.Iteration != test.iteration {
+				t.Errorf("Expected iteration %d, Found %d", test.iteration, reason.Iteration)
+			}
+		})
+	}
+}
+
+func TestStopLimitDisabled(t *testing.T) {
+	t.Parallel()
+
+	f := newStopLimit(StopLimits{}, func() { t.Errorf("Stop should not be called") })
+	f.begin(clock.Real())
+	f.observe(failedResult(1, 1))
+	if f.stopReason() != nil {
+		t.Errorf("Disabled limit should not have a stop reason")
+	}
+}
+
+func TestStopLimitConcurrent(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	stopCount := 0
+	f := newStopLimit(StopLimits{Failures: 50}, func() {
+		mu.Lock()
+		stopCount++
+		mu.Unlock()
+	})
+	f.begin(clock.Real())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				f.observe(failedResult(1, 1))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stopCount != 1 {
+		t.Errorf("Stop should be called once, Found %d calls", stopCount)
+	}
+	if r := f.stopReason(); r == nil || r.Iteration < 50 {
+		t.Errorf("Expected the stop at or after the iteration 50, Found %#v", r)
+	}
+}
+
+func TestStopLimitTransfer(t *testing.T) {
+	t.Parallel()
+
+	stopCount := 0
+	f := newStopLimit(StopLimits{Transfer: 3000}, func() { stopCount++ })
+	f.begin(clock.Real())
+
+	f.observe(transferResult(200, 800))
+	f.observe(failedResult(1, 1))
+	if f.stopReason() != nil {
+		t.Fatalf("Limit should not be reached yet, Found %#v", f.stopReason())
+	}
+	f.observe(transferResult(500, 1500))
+	f.observe(transferResult(100, 100))
+
+	reason := f.stopReason()
+	if reason == nil || stopCount != 1 {
+		t.Fatalf("Stop should be called once, Found %d calls, reason %#v", stopCount, reason)
+	}
+	if reason.Reason != "stopped after 3.0 KB transferred" || reason.Iteration != 3 {
+		t.Errorf("Expected the transfer stop at iteration 3, Found %#v", reason)
+	}
+}
+
+func TestStopLimitFirstReached(t *testing.T) {
+	t.Parallel()
+
+	stopCount := 0
+	f := newStopLimit(StopLimits{Failures: 1, Transfer: 1000}, func() { stopCount++ })
+	f.begin(clock.Real())
+
+	r := failedResult(1, 1)
+	r.StepResults[0].Custom = map[string]interface{}{"bytesSent": int64(2000)}
+	f.observe(r)
+	f.observe(transferResult(1000, 1000))
+
+	if stopCount != 1 {
+		t.Errorf("Stop should be called once, Found %d calls", stopCount)
+	}
+	if reason := f.stopReason(); reason == nil || reason.Reason != "stopped after 1 failed requests" {
+		t.Errorf("Expected the failures stop reason, Found %#v", reason)
+	}
+}
+
+func durationResult(stepID uint16, d time.Duration, failed bool) *types.ScenarioResult {
+	sr := &types.ScenarioStepResult{StepID: stepID, Duration: d}
+	if failed {
+		sr.Err = types.RequestError{Type: types.ErrorConn, Reason: "connection refused"}
+	}
+	return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+}
+
+func TestAbortWindows(t *testing.T) {
+	t.Parallel()
+
+	type observation struct {
+		at       time.Duration
+		stepID   uint16
+		duration time.Duration
+		failed   bool
+	}
+	slow := func(at time.Duration) observation { return observation{at, 2, 900 * time.Millisecond, false} }
+	fast := func(at time.Duration) observation { return observation{at, 2, 100 * time.Millisecond, false} }
+
+	tests := []struct {
+		name         string
+		rule         string
+		observations []observation
+		firedAt      int // Index of the observation that fires the rule, -1 if none
+		windows      []AbortWindow
+	}{
+		{"Consecutive", "steps.2.p95 > 800ms over 1m for 2 windows",
+			[]observation{slow(0), slow(time.Minute), slow(90 * time.Second), slow(2 * time.Minute)}, 3,
+			[]AbortWindow{{Start: 0, Value: 0.9, Requests: 1}, {Start: 60, Value: 0.9, Requests: 2}}},
+		{"BrokenByFastWindow", "steps.2.p95 > 800ms over 1m for 2 windows",
+			[]observation{slow(0), fast(time.Minute), slow(2 * time.Minute), fast(3 * time.Minute)}, -1, nil},
+		{"BrokenByEmptyWindow", "steps.2.p95 > 800ms over 1m for 2 windows",
+			[]observation{slow(0), slow(2 * time.Minute), fast(3 * time.Minute)}, -1, nil},
+		{"OpenWindowIsNotEvaluated", "steps.2.p95 > 800ms over 1m",
+			[]observation{slow(0), slow(30 * time.Second)}, -1, nil},
+		{"OtherStepsCloseTheWindow", "steps.2.p95 > 800ms over 1m",
+			[]observation{slow(0), {time.Minute, 1, time.Millisecond, false}}, 1,
+			[]AbortWindow{{Start: 0, Value: 0.9, Requests: 1}}},
+		{"FailRate", "steps.2.fail_rate >= 50% over 10s",
+			[]observation{fast(0), {time.Second, 2, 0, true}, fast(10 * time.Second)}, 2,
+			[]AbortWindow{{Start: 0, Value: 0.5, Requests: 2}}},
+		{"DurationsWithoutSuccess", "steps.2.avg_duration > 1ms over 10s",
+			[]observation{{0, 2, 0, true}, fast(10 * time.Second)}, -1, nil},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rule, err := criteria.ParseAbortRule(test.rule)
+			if err != nil {
+				t.Fatalf("Error occurred %v", err)
+			}
+			w := newAbortWindows([]AbortRule{{Rule: rule, StepID: 2}})
+
+			firedAt := -1
+			var fired *FiredRule
+			for i, o := range test.observations {
+				if f := w.observe(durationResult(o.stepID, o.duration, o.failed), o.at); f != nil && fired == nil {
+					firedAt, fired = i, f
+				}
+			}
+			if firedAt != test.firedAt {
+				t.Fatalf("Expected fired at %d, Found %d", test.firedAt, firedAt)
+			}
+			if fired == nil {
+				return
+			}
+			if fired.Rule != test.rule || len(fired.Windows) != len(test.windows) {
+				t.Fatalf("Expected %v, Found %+v", test.windows, fired)
+			}
+			for i, win := range fired.Windows {
+				e := test.windows[i]
+				if win.Start != e.Start || win.Requests != e.Requests || math.Abs(win.Value-e.Value) > e.Value*0.02 {
+					t.Errorf("Window %d: Expected %+v, Found %+v", i, e, win)
+				}
+			}
+		})
+	}
+}
+
+func TestStopLimitAbortRule(t *testing.T) {
+	t.Parallel()
+
+	rule, _ := criteria.ParseAbortRule("steps.1.fail_count > 0 over 10ms")
+	stopCount := 0
+	f := newStopLimit(StopLimits{AbortRules: []AbortRule{{Rule: rule, StepID: 1}}}, func() { stopCount++ })
+	c := clock.NewFake(time.Now())
+	f.begin(c)
+
+	f.observe(failedResult(1, 1))
+	c.Advance(20 * time.Millisecond)
+	f.observe(failedResult(0, 1))
+
+	reason := f.stopReason()
+	if reason == nil || stopCount != 1 {
+		t.Fatalf("Stop should be called once, Found %d calls, reason %#v", stopCount, reason)
+	}
+	if reason.Reason != "aborted by the rule steps.1.fail_count > 0 over 10ms" || reason.Iteration != 2 ||
+		reason.Rule == nil || len(reason.Rule.Windows) != 1 || reason.Rule.Windows[0].Value != 1 {
+		t.Errorf("Expected the abort rule stop at iteration 2, Found %#v", reason)
+	}
+}
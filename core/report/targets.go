@@ -0,0 +1,155 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// Target count listed in the slowest and the most failing targets of a step.
+	topTargetCount = 20
+
+	// Capacity of the failure counters. Counts are exact until this many distinct targets fail,
+	// after that the counts of the listed targets may be overestimated.
+	failingTargetCapacity = 10 * topTargetCount
+)
+
+// TargetSummary is a target of a step fed by a targets file, listed in the report.
+type TargetSummary struct {
+	URL         string  `json:"url" anonymize:"url"`
+	Duration    float32 `json:"duration,omitempty"`
+	FailedCount int64   `json:"fail_count,omitempty"`
+}
+
+// targetTracker keeps the slowest and the most failing targets of a step in bounded memory,
+// since a targets file may contain millions of distinct targets.
+type targetTracker struct {
+	// Slowest single request duration of each target
+	slowest []slowTarget
+
+	// Space-Saving counters of the failing targets
+	failing map[string]int64
+}
+
+type slowTarget struct {
+	url      string
+	duration time.Duration
+}
+
+func newTargetTracker() *targetTracker {
+	return &targetTracker{
+		slowest: make([]slowTarget, 0, topTargetCount),
+		failing: make(map[string]int64),
+	}
+}
+
+func (t *targetTracker) add(url string, d time.Duration, failed bool) {
+	if failed {
+		t.addFailure(url)
+		return
+	}
+
+	min := -1
+	for i, s := range t.slowest {
+		if s.url == url {
+			if d > s.duration {
+				t.slowest[i].duration = d
+			}
+			return
+		}
+		if min == -1 || s.duration < t.slowest[min].duration {
+			min = i
+		}
+	}
+
+	if len(t.slowest) < topTargetCount {
+		t.slowest = append(t.slowest, slowTarget{url: url, duration: d})
+	} else if d > t.slowest[min].duration {
+		t.slowest[min] = slowTarget{url: url, duration: d}
+	}
+}
+
+func (t *targetTracker) addFailure(url string) {
+	if _, ok := t.failing[url]; ok || len(t.failing) < failingTargetCapacity {
+		t.failing[url]++
+		return
+	}
+
+	// Replace the least failing target, the newcomer inherits its count.
+	var minURL string
+	var minCount int64 = -1
+	for u, c := range t.failing {
+		if minCount == -1 || c < minCount || (c == minCount && u < minURL) {
+			minURL, minCount = u, c
+		}
+	}
+	delete(t.failing, minURL)
+	t.failing[url] = minCount + 1
+}
+
+// merge adds the targets tracked by o into t.
+func (t *targetTracker) merge(o *targetTracker) {
+	for _, s := range o.slowest {
+		t.add(s.url, s.duration, false)
+	}
+	for u, c := range o.failing {
+		if _, ok := t.failing[u]; ok || len(t.failing) < failingTargetCapacity {
+			t.failing[u] += c
+			continue
+		}
+		t.addFailure(u)
+		t.failing[u] += c - 1
+	}
+}
+
+// summary returns the slowest targets in descending duration order and
+// the most failing targets in descending failure order.
+func (t *targetTracker) summary() (slowest []TargetSummary, failing []TargetSummary) {
+	if t == nil {
+		return
+	}
+
+	for _, s := range t.slowest {
+		slowest = append(slowest, TargetSummary{URL: s.url, Duration: float32(s.duration.Seconds())})
+	}
+	sort.Slice(slowest, func(i, j int) bool {
+		if slowest[i].Duration == slowest[j].Duration {
+			return slowest[i].URL < slowest[j].URL
+		}
+		return slowest[i].Duration > slowest[j].Duration
+	})
+
+	for u, c := range t.failing {
+		failing = append(failing, TargetSummary{URL: u, FailedCount: c})
+	}
+	sort.Slice(failing, func(i, j int) bool {
+		if failing[i].FailedCount == failing[j].FailedCount {
+			return failing[i].URL < failing[j].URL
+		}
+		return failing[i].FailedCount > failing[j].FailedCount
+	})
+	if len(failing) > topTargetCount {
+		failing = failing[:topTargetCount]
+	}
+	return
+}
@@ -0,0 +1,248 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestTargetTrackerSlowest(t *testing.T) {
+	tr := newTargetTracker()
+	for i := 1; i <= topTargetCount+10; i++ {
+		tr.add(fmt.Sprintf("https://a.com/%d", i), time.Duration(i)*time.Millisecond, false)
+	}
+	// Slower request of a tracked target updates it instead of adding a new entry
+	tr.add("https://a.com/30", time.Second, false)
+	// Faster than all the tracked ones, not listed
+	tr.add("https://a.com/fast", time.Microsecond, false)
+
+	slowest, failing := tr.summary()
+	if len(failing) != 0 {
+		t.Errorf("Failing targets should be empty, Found %v", failing)
+	}
+	if len(slowest) != topTargetCount {
+		t.Fatalf("Slowest target count Expected %d, Found %d", topTargetCount, len(slowest))
+	}
+	if slowest[0].URL != "https://a.com/30" || slowest[0].Duration != 1 {
+		t.Errorf("Slowest target Expected %s, Found %+v", "https://a.com/30", slowest[0])
+	}
+	if last := slowest[len(slowest)-1]; last.URL != "https://a.com/11" {
+		t.Errorf("Last slowest target Expected %s, Found %+v", "https://a.com/11", last)
+	}
+}
+
+func TestTargetTrackerFailing(t *testing.T) {
+	tr := newTargetTracker()
+	for i := 0; i < 5; i++ {
+		tr.add("https://a.com/flaky", time.Second, true)
+	}
+	for i := 0; i < 3; i++ {
+		tr.add("https://a.com/broken", time.Second, true)
+	}
+	// Overflow the counters with the targets failed once
+	for i := 0; i < 2*failingTargetCapacity; i++ {
+		tr.add(fmt.Sprintf("https://a.com/once/%d", i), time.Second, true)
+	}
+
+	if len(tr.failing) != failingTargetCapacity {
+		t.Errorf("Failing counters should be bounded by %d, Found %d", failingTargetCapacity, len(tr.failing))
+	}
+
+	_, failing := tr.summary()
+	if len(failing) != topTargetCount {
+		t.Fatalf("Failing target count Expected %d, Found %d", topTargetCount, len(failing))
+	}
+	expected := []TargetSummary{
+		{URL: "https://a.com/flaky", FailedCount: 5},
+		{URL: "https://a.com/broken", FailedCount: 3},
+	}
+	if !reflect.DeepEqual(failing[:2], expected) {
+		t.Errorf("Most failing targets Expected %v, Found %v", expected, failing[:2])
+	}
+}
+
+func TestTargetTrackerMerge(t *testing.T) {
+	a, b := newTargetTracker(), newTargetTracker()
+	a.add("https://a.com/1", time.Second, false)
+	a.add("https://a.com/2", 2*time.Second, false)
+	b.add("https://a.com/1", 3*time.Second, false)
+	a.add("https://a.com/3", time.Second, true)
+	b.add("https://a.com/3", time.Second, true)
+	b.add("https://a.com/4", time.Second, true)
+
+	a.merge(b)
+	slowest, failing := a.summary()
+
+	expectedSlowest := []TargetSummary{{URL: "https://a.com/1", Duration: 3}, {URL: "https://a.com/2", Duration: 2}}
+	if !reflect.DeepEqual(slowest, expectedSlowest) {
+		t.Errorf("Slowest targets Expected %v, Found %v", expectedSlowest, slowest)
+	}
+	expectedFailing := []TargetSummary{{URL: "https://a.com/3", FailedCount: 2}, {URL: "https://a.com/4", FailedCount: 1}}
+	if !reflect.DeepEqual(failing, expectedFailing) {
+		t.Errorf("Failing targets Expected %v, Found %v", expectedFailing, failing)
+	}
+}
+
+func TestAggregateTargets(t *testing.T) {
+	agg := newAggregator()
+	responses := []*types.ScenarioResult{
+		{StepResults: []*types.ScenarioStepResult{{
+			StepID: 1, Duration: time.Second, Custom: map[string]interface{}{"targetURL": "https://a.com/1"},
+		}}},
+		{StepResults: []*types.ScenarioStepResult{{
+			StepID: 1, Duration: time.Second, Err: types.RequestError{Type: types.ErrorConn, Reason: "x"},
+			Custom: map[string]interface{}{"targetURL": "https://a.com/2"},
+		}}},
+		{StepResults: []*types.ScenarioStepResult{{StepID: 2, Duration: time.Second}}},
+	}
+	for _, r := range responses {
+		agg.add(r)
+	}
+	result := agg.result()
+
+	s := result.StepResults[1]
+	if !reflect.DeepEqual(s.SlowestTargets, []TargetSummary{{URL: "https://a.com/1", Duration: 1}}) {
+		t.Errorf("Unexpected slowest targets %v", s.SlowestTargets)
+	}
+	if !reflect.DeepEqual(s.FailingTargets, []TargetSummary{{URL: "https://a.com/2", FailedCount: 1}}) {
+		t.Errorf("Unexpected failing targets %v", s.FailingTargets)
+	}
+
+	s = result.StepResults[2]
+	if s.SlowestTargets != nil || s.FailingTargets != nil {
+		t.Errorf("Steps without a targets file should not list targets")
+	}
+}
+
+func TestAggregateEndpoints(t *testing.T) {
+	agg := newAggregator()
+	stepResult := func(endpoint string, d time.Duration, failed bool) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, Duration: d, Custom: map[string]interface{}{"endpoint": endpoint}}
+		if failed {
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: "x"}
+		}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+
+	responses := []*types.ScenarioResult{
+		stepResult("/users/:id", time.Second, false),
+		stepResult("/users/:id", 3*time.Second, false),
+		stepResult("/users/:id", time.Second, true),
+		stepResult(types.CatchAllURLGroup, time.Second, false),
+		stepResult(types.CatchAllURLGroup, time.Second, false),
+		stepResult(types.CatchAllURLGroup, time.Second, false),
+		stepResult(types.CatchAllURLGroup, time.Second, false),
+		stepResult("/orders/:id", time.Second, false),
+	}
+	for _, r := range responses {
+		agg.add(r)
+	}
+	result := agg.result()
+
+	endpoints := result.StepResults[1].Endpoints
+	expected := map[string]*EndpointSummary{
+		"/users/:id":           {SuccessCount: 2, FailedCount: 1, AvgDuration: 2},
+		"/orders/:id":          {SuccessCount: 1, AvgDuration: 1},
+		types.CatchAllURLGroup: {SuccessCount: 4, AvgDuration: 1},
+	}
+	if !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("Endpoints Expected %v, Found %v", expected, endpoints)
+	}
+
+	order := sortedEndpoints(endpoints)
+	expectedOrder := []string{"/users/:id", "/orders/:id", types.CatchAllURLGroup}
+	if !reflect.DeepEqual(order, expectedOrder) {
+		t.Errorf("Endpoint order Expected %v, Found %v", expectedOrder, order)
+	}
+}
+
+func TestAggregateHosts(t *testing.T) {
+	agg := newAggregator()
+	other := newAggregator()
+	stepResult := func(host string, d time.Duration, failed bool) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, Duration: d, Custom: map[string]interface{}{"host": host}}
+		if failed {
+			sr.Err = types.RequestError{Type: types.ErrorConn, Reason: "x"}
+		}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+
+	agg.add(stepResult("api1.example.com", time.Second, false))
+	agg.add(stepResult("api1.example.com", 3*time.Second, false))
+	agg.add(stepResult("api2.example.com", time.Second, true))
+	other.add(stepResult("api2.example.com", 2*time.Second, false))
+	other.add(stepResult("api3.example.com", time.Second, false))
+	agg.merge(other)
+	result := agg.result()
+
+	expected := map[string]*EndpointSummary{
+		"api1.example.com": {SuccessCount: 2, AvgDuration: 2},
+		"api2.example.com": {SuccessCount: 1, FailedCount: 1, AvgDuration: 2},
+		"api3.example.com": {SuccessCount: 1, AvgDuration: 1},
+	}
+	if hosts := result.StepResults[1].Hosts; !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Hosts Expected %v, Found %v", expected, hosts)
+	}
+	if result.StepResults[1].Endpoints != nil {
+		t.Errorf("Steps without url groups should not list endpoints")
+	}
+}
+
+func TestAggregateHostLimit(t *testing.T) {
+	stepResult := func(host string, d time.Duration) *types.ScenarioResult {
+		sr := &types.ScenarioStepResult{StepID: 1, Duration: d, Custom: map[string]interface{}{"host": host}}
+		return &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{sr}}
+	}
+	steps := []types.ScenarioStep{{ID: 1, Custom: map[string]interface{}{"report-host-limit": float64(2)}}}
+
+	agg1, agg2 := newAggregator(), newAggregator()
+	agg1.initSteps(steps)
+	agg2.initSteps(steps)
+	agg1.add(stepResult("eu.api.example.com", time.Second))
+	agg1.add(stepResult("eu.api.example.com", 3*time.Second))
+	agg2.add(stepResult("us.api.example.com", time.Second))
+	agg2.add(stepResult("ap.api.example.com", time.Second))
+	agg2.add(stepResult("sa.api.example.com", 3*time.Second))
+
+	merged := newAggregator()
+	merged.initSteps(steps)
+	merged.merge(agg1)
+	merged.merge(agg2)
+	result := merged.result().StepResults[1]
+
+	// sa is over the limit of agg2, us is over the limit of the merged results as it is merged after ap
+	expected := map[string]*EndpointSummary{
+		"eu.api.example.com": {SuccessCount: 2, AvgDuration: 2},
+		"ap.api.example.com": {SuccessCount: 1, AvgDuration: 1},
+		types.OverflowHost:   {SuccessCount: 2, AvgDuration: 2},
+	}
+	if !reflect.DeepEqual(result.Hosts, expected) {
+		t.Errorf("Hosts Expected %v, Found %v", expected, result.Hosts)
+	}
+	if result.HostLimit != 2 {
+		t.Errorf("HostLimit Expected 2, Found %d", result.HostLimit)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+)
+
+// TeardownSummary is the outcome of a teardown step run after the test over the values collected by the iterations.
+type TeardownSummary struct {
+	StepID   uint16 `json:"step_id"`
+	StepName string `json:"step_name,omitempty"`
+
+	// Collected capture iterated by the step, empty if the step runs once
+	Foreach string `json:"foreach,omitempty"`
+
+	// Counts of the collected values, the dropped ones are over the collect_limit
+	Collected int64 `json:"collected,omitempty"`
+	Dropped   int64 `json:"dropped,omitempty"`
+	Processed int64 `json:"processed"`
+	Failed    int64 `json:"failed"`
+
+	// Values left by an abort of the teardown
+	Skipped int64 `json:"skipped,omitempty"`
+
+	Requests       int64  `json:"requests"`
+	FailedRequests int64  `json:"failed_requests"`
+	Error          string `json:"error,omitempty"`
+}
+
+// printTeardown writes the teardown section of the report, w is a tabwriter of the report.
+func printTeardown(w io.Writer, teardown []TeardownSummary) {
+	if len(teardown) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Teardown (Step:Processed:Failed:Requests):")
+	for _, t := range teardown {
+		name := t.StepName
+		if name == "" {
+			name = fmt.Sprintf("Step %d", t.StepID)
+		}
+		fmt.Fprintf(w, "  %d. %s\t:%s\t:%s\t:%s\n", t.StepID, name, formatCount(t.Processed), formatCount(t.Failed),
+			formatCount(t.Requests))
+		if t.Foreach != "" && t.Dropped > 0 {
+			fmt.Fprintf(w, "  %s values of %s over the collect_limit are not collected\n", formatCount(t.Dropped), t.Foreach)
+		}
+		if t.Skipped > 0 {
+			fmt.Fprintf(w, "  %s values are skipped, teardown is aborted\n", formatCount(t.Skipped))
+		}
+		if t.Error != "" {
+			fmt.Fprintf(w, "  %s\n", t.Error)
+		}
+	}
+	fmt.Fprintln(w)
+}
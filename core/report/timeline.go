@@ -0,0 +1,239 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// TimelineInterval is the width of the time buckets of the percentiles over time. Bucket boundaries are aligned to
+// the wall clock, so the timelines of different runs can be overlaid.
+var TimelineInterval = time.Minute
+
+// TimelineFile is the CSV file that the percentiles over time are written to at the end of the test.
+// Empty means disabled.
+var TimelineFile string
+
+// Stdout table is coarsened to fit into this many rows on long runs.
+const maxTimelineRows = 60
+
+// timeline keeps a duration histogram per time bucket, keyed by the start of the bucket in unix nanoseconds.
+// Each histogram has a fixed precision, so the memory of a bucket is bounded regardless of the request count.
+type timeline map[int64]*histogram
+
+func (t timeline) add(at time.Time, d time.Duration) {
+	if at.IsZero() {
+		return
+	}
+	k := at.Truncate(TimelineInterval).UnixNano()
+	h, ok := t[k]
+	if !ok {
+		h = newHistogram()
+		t[k] = h
+	}
+	h.add(d)
+}
+
+func (t timeline) merge(o timeline) {
+	for k, oh := range o {
+		h, ok := t[k]
+		if !ok {
+			h = newHistogram()
+			t[k] = h
+		}
+		h.merge(oh)
+	}
+}
+
+// TimelineBucket is the duration percentiles of the successful requests that are started in a time bucket.
+type TimelineBucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+	P50   float32   `json:"p50"`
+	P95   float32   `json:"p95"`
+	P99   float32   `json:"p99"`
+
+	// Achieved successful requests per second and the target iterations per second of the load schedule over the
+	// part of the bucket covered by the test, set if the test has a schedule
+	Rate       float64 `json:"rate,omitempty"`
+	TargetRate float64 `json:"target_rate,omitempty"`
+}
+
+// buckets returns the percentiles of the buckets of the given width ordered by time.
+// The width should be a multiple of TimelineInterval.
+func (t timeline) buckets(width time.Duration) []TimelineBucket {
+	merged := make(map[int64]*histogram, len(t))
+	for k, h := range t {
+		mk := time.Unix(0, k).Truncate(width).UnixNano()
+		m, ok := merged[mk]
+		if !ok {
+			m = newHistogram()
+			merged[mk] = m
+		}
+		m.merge(h)
+	}
+
+	keys := make([]int64, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	buckets := make([]TimelineBucket, 0, len(keys))
+	for _, k := range keys {
+		h := merged[k]
+		buckets = append(buckets, TimelineBucket{
+			Start: time.Unix(0, k).UTC(),
+			Count: h.total,
+			P50:   float32(h.percentile(50).Seconds()),
+			P95:   float32(h.percentile(95).Seconds()),
+			P99:   float32(h.percentile(99).Seconds()),
+		})
+	}
+	return buckets
+}
+
+// coarse returns the buckets widened by a multiple of TimelineInterval, so the time range of the test fits into
+// maxRows buckets.
+func (t timeline) coarse(maxRows int) (time.Duration, []TimelineBucket) {
+	if len(t) == 0 {
+		return TimelineInterval, nil
+	}
+
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	for k := range t {
+		if k < first {
+			first = k
+		}
+		if k > last {
+			last = k
+		}
+	}
+	rows := int((last-first)/int64(TimelineInterval)) + 1
+	width := TimelineInterval * time.Duration((rows+maxRows-1)/maxRows)
+
+	buckets := t.buckets(width)
+	// Boundaries of the wider buckets may split the time range into one more bucket.
+	for len(buckets) > maxRows {
+		width += TimelineInterval
+		buckets = t.buckets(width)
+	}
+	return width, buckets
+}
+
+// annotateSchedule sets the achieved and the target rates of the buckets of the given width. The first and the last
+// buckets are only partially covered by the test between start and end, so the rates are calculated over the
+// covered part of them.
+func annotateSchedule(buckets []TimelineBucket, width time.Duration, s *types.LoadSchedule, start, end time.Time) {
+	for i := range buckets {
+		from, to := buckets[i].Start, buckets[i].Start.Add(width)
+		if !start.IsZero() && start.After(from) {
+			from = start
+		}
+		if !end.IsZero() && end.Before(to) {
+			to = end
+		}
+		covered := to.Sub(from)
+		if covered < time.Second {
+			covered = time.Second
+		}
+		buckets[i].Rate = float64(buckets[i].Count) / covered.Seconds()
+		buckets[i].TargetRate = s.MeanRate(from, to)
+	}
+}
+
+// timelineLayout returns the time layout of the bucket starts, seconds are omitted for the minute wide buckets.
+func timelineLayout(width time.Duration) string {
+	if width%time.Minute == 0 {
+		return "15:04"
+	}
+	return "15:04:05"
+}
+
+// formatWidth formats the bucket width without the zero units, like "1m" instead of "1m0s".
+func formatWidth(width time.Duration) string {
+	s := width.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// writeTimelineFile writes the percentiles over time of the steps into TimelineFile as CSV,
+// one row per step and time bucket.
+func writeTimelineFile(r *Result) error {
+	if TimelineFile == "" {
+		return nil
+	}
+
+	f, err := os.Create(TimelineFile)
+	if err != nil {
+		return fmt.Errorf("timeline file can not be created: %v", err)
+	}
+	defer f.Close()
+
+	ids := make([]int, 0, len(r.StepResults))
+	for id := range r.StepResults {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	formatSeconds := func(v float32) string {
+		return strconv.FormatFloat(float64(v), 'f', 6, 32)
+	}
+
+	w := csv.NewWriter(f)
+	runID, labels := r.RunMetadata.runID(), r.RunMetadata.labels()
+	w.Write([]string{"step_id", "step_name", "start", "count", "p50", "p95", "p99", "run_id", "labels",
+		"rate", "rate_source_value"})
+	for _, id := range ids {
+		s := r.StepResults[uint16(id)]
+		for _, b := range s.Timeline {
+			// Dynamic rate in effect at the start of the bucket or the target rate of the schedule over the bucket,
+			// empty if the test has neither
+			rate, value := "", ""
+			if c, ok := rateAt(r.RateChanges, b.Start); ok {
+				rate, value = strconv.FormatFloat(c.Rate, 'f', -1, 64), strconv.FormatFloat(c.Value, 'f', -1, 64)
+			} else if r.schedule != nil {
+				rate = strconv.FormatFloat(b.TargetRate, 'f', 2, 64)
+			}
+			w.Write([]string{strconv.Itoa(id), s.Name, b.Start.Format(time.RFC3339), strconv.FormatInt(b.Count, 10),
+				formatSeconds(b.P50), formatSeconds(b.P95), formatSeconds(b.P99), runID, labels, rate, value})
+		}
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return fmt.Errorf("timeline file can not be written: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,347 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// timelineOf returns the timeline of the given durations that are started at the given time.
+func timelineOf(at time.Time, durations ...time.Duration) timeline {
+	t := make(timeline)
+	for _, d := range durations {
+		t.add(at, d)
+	}
+	return t
+}
+
+func TestTimelineBuckets(t *testing.T) {
+	start := time.Date(2026, 10, 14, 10, 0, 30, 0, time.UTC)
+
+	tl := make(timeline)
+	tl.add(start, 10*time.Millisecond)
+	tl.add(start.Add(29*time.Second), 30*time.Millisecond)
+	tl.add(start.Add(40*time.Second), 100*time.Millisecond)
+	tl.add(time.Time{}, time.Second)
+
+	buckets := tl.buckets(time.Minute)
+	if len(buckets) != 2 {
+		t.Fatalf("Expected 2 buckets, Found %d: %#v", len(buckets), buckets)
+	}
+
+	first, second := buckets[0], buckets[1]
+	if !first.Start.Equal(time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)) || first.Count != 2 {
+		t.Errorf("Bucket should start at the wall-clock minute, Found %#v", first)
+	}
+	if !second.Start.Equal(time.Date(2026, 10, 14, 10, 1, 0, 0, time.UTC)) || second.Count != 1 {
+		t.Errorf("Bucket should start at the wall-clock minute, Found %#v", second)
+	}
+
+	expected := float32(histogramValue(histogramBucket(30 * time.Millisecond)).Seconds())
+	if first.P95 != expected || first.P99 != expected {
+		t.Errorf("Expected p95 and p99 %v, Found %#v", expected, first)
+	}
+	expected = float32(histogramValue(histogramBucket(10 * time.Millisecond)).Seconds())
+	if first.P50 != expected {
+		t.Errorf("Expected p50 %v, Found %#v", expected, first)
+	}
+}
+
+func TestTimelineMerge(t *testing.T) {
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+
+	a := timelineOf(start, 10*time.Millisecond)
+	a.merge(timelineOf(start.Add(time.Second), 20*time.Millisecond))
+	a.merge(timelineOf(start.Add(time.Minute), 30*time.Millisecond))
+
+	buckets := a.buckets(time.Minute)
+	if len(buckets) != 2 || buckets[0].Count != 2 || buckets[1].Count != 1 {
+		t.Errorf("Unexpected merged buckets %#v", buckets)
+	}
+}
+
+func TestTimelineCoarse(t *testing.T) {
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		minutes       int
+		expectedWidth time.Duration
+		expectedRows  int
+	}{
+		{"Empty", 0, time.Minute, 0},
+		{"Short", 5, time.Minute, 5},
+		{"FitsIntoMaxRows", 60, time.Minute, 60},
+		{"Doubled", 61, 2 * time.Minute, 31},
+		{"Long", 150, 3 * time.Minute, 50},
+		{"Day", 24 * 60, 24 * time.Minute, 60},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			tl := make(timeline)
+			for i := 0; i < test.minutes; i++ {
+				tl.add(start.Add(time.Duration(i)*time.Minute), time.Millisecond)
+			}
+
+			width, rows := tl.coarse(maxTimelineRows)
+			if width != test.expectedWidth || len(rows) != test.expectedRows {
+				t.Errorf("Expected %s and %d rows, Found %s and %d rows", test.expectedWidth, test.expectedRows,
+					width, len(rows))
+			}
+
+			var count int64
+			for _, r := range rows {
+				count += r.Count
+				if r.Start.Truncate(width) != r.Start {
+					t.Errorf("Bucket should be aligned to %s, Found %s", width, r.Start)
+				}
+			}
+			if count != int64(test.minutes) {
+				t.Errorf("Expected count %d, Found %d", test.minutes, count)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestFormatWidth(t *testing.T) {
+	tests := []struct {
+		width    time.Duration
+		expected string
+		layout   string
+	}{
+		{30 * time.Second, "30s", "15:04:05"},
+		{time.Minute, "1m", "15:04"},
+		{90 * time.Second, "1m30s", "15:04:05"},
+		{time.Hour, "1h", "15:04"},
+		{2*time.Hour + 30*time.Minute, "2h30m", "15:04"},
+	}
+
+	for _, test := range tests {
+		if f := formatWidth(test.width); f != test.expected {
+			t.Errorf("Expected %s, Found %s", test.expected, f)
+		}
+		if l := timelineLayout(test.width); l != test.layout {
+			t.Errorf("Expected layout %s for %s, Found %s", test.layout, test.width, l)
+		}
+	}
+}
+
+func TestAggregateTimeline(t *testing.T) {
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+
+	a := newAggregator()
+	for i, d := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		a.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+			{StepID: 1, StatusCode: 200, RequestTime: start.Add(time.Duration(i) * time.Minute), Duration: d},
+		}})
+	}
+	// Failed requests are not included in the percentiles
+	a.add(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+		{StepID: 1, RequestTime: start, Duration: time.Minute,
+			Err: types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}},
+	}})
+
+	merged := newAggregator()
+	merged.merge(a)
+	s := merged.result().StepResults[1]
+
+	if len(s.Timeline) != 3 {
+		t.Fatalf("Expected 3 buckets, Found %#v", s.Timeline)
+	}
+	for i, b := range s.Timeline {
+		if b.Count != 1 || !b.Start.Equal(start.Add(time.Duration(i)*time.Minute)) {
+			t.Errorf("Unexpected bucket %#v", b)
+		}
+	}
+}
+
+func TestWriteTimelineFile(t *testing.T) {
+	defer func() { TimelineFile = "" }()
+	TimelineFile = filepath.Join(t.TempDir(), "timeline.csv")
+
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	r := &Result{StepResults: map[uint16]*ScenarioStepResultSummary{
+		2: {Name: "checkout", Timeline: []TimelineBucket{{Start: start, Count: 3, P50: 0.5, P95: 0.75, P99: 1}}},
+		1: {Name: "login, home", Timeline: []TimelineBucket{
+			{Start: start, Count: 10, P50: 0.01, P95: 0.02, P99: 0.03},
+			{Start: start.Add(time.Minute), Count: 5, P50: 0.011, P95: 0.02, P99: 0.1},
+		}},
+	}}
+	r.RunMetadata = newRunMetadata(types.Metadata{RunID: "r1", Labels: []types.Label{
+		{Key: "token", Value: "abc", Secret: true}, {Key: "env", Value: "staging"}}})
+	r.RateChanges = []RateChange{
+		{Time: start.Add(30 * time.Second), Value: 12.5, Rate: 25},
+		{Time: start.Add(time.Minute), Value: 40, Rate: 80},
+	}
+	if err := writeTimelineFile(r); err != nil {
+		t.Fatalf("Error occurred %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(TimelineFile)
+	expected := "step_id,step_name,start,count,p50,p95,p99,run_id,labels,rate,rate_source_value\n" +
+		"1,\"login, home\",2026-10-14T10:00:00Z,10,0.010000,0.020000,0.030000,r1,env=staging token=[REDACTED],,\n" +
+		"1,\"login, home\",2026-10-14T10:01:00Z,5,0.011000,0.020000,0.100000,r1,env=staging token=[REDACTED],80,40\n" +
+		"2,checkout,2026-10-14T10:00:00Z,3,0.500000,0.750000,1.000000,r1,env=staging token=[REDACTED],,\n"
+	if string(content) != expected {
+		t.Errorf("Expected %q, Found %q", expected, content)
+	}
+
+	TimelineFile = filepath.Join(t.TempDir(), "missing", "timeline.csv")
+	if err := writeTimelineFile(r); err == nil {
+		t.Errorf("Unwritable timeline file should be errored")
+	}
+}
+
+func TestStdoutPrintsTimeline(t *testing.T) {
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.Local)
+	tl := make(timeline)
+	tl.add(start, 10*time.Millisecond)
+	tl.add(start.Add(time.Minute), 20*time.Millisecond)
+
+	s := &stdout{}
+	s.Init(false)
+	s.result = &Result{SuccessCount: 2, StepResults: map[uint16]*ScenarioStepResultSummary{
+		1: {SuccessCount: 2, Durations: map[string]float32{}, timeline: tl},
+	}}
+
+	realOut := out
+	defer func() {
+		out = realOut
+	}()
+	buffer := new(bytes.Buffer)
+	out = buffer
+
+	s.finish()
+
+	printed := buffer.String()
+	for _, expected := range []string{
+		"Percentiles Over Time (Per 1m, Start:Count:p50:p95:p99):",
+		"10:00    :1    :0.0101s    :0.0101s    :0.0101s",
+		"10:01    :1    :0.0202s    :0.0202s    :0.0202s",
+	} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("Expected %q in the output:\n%s", expected, printed)
+		}
+	}
+}
+
+func TestStdoutJsonTimeline(t *testing.T) {
+	realPrintJson := printJson
+	defer func() { printJson = realPrintJson }()
+
+	var output []byte
+	printJson = func(j []byte) {
+		output = j
+	}
+
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	s := &stdoutJson{result: &Result{StepResults: map[uint16]*ScenarioStepResultSummary{
+		1: {SuccessCount: 1, Durations: map[string]float32{},
+			Timeline: []TimelineBucket{{Start: start, Count: 1, P50: 0.01234, P95: 0.05678, P99: 0.1}}},
+	}}}
+	s.report()
+
+	r := struct {
+		Steps map[string]struct {
+			Timeline []TimelineBucket `json:"timeline"`
+		} `json:"steps"`
+	}{}
+	if err := json.Unmarshal(output, &r); err != nil {
+		t.Fatalf("Error occurred %v", err)
+	}
+	expected := []TimelineBucket{{Start: start, Count: 1, P50: 0.012, P95: 0.057, P99: 0.1}}
+	if !reflect.DeepEqual(r.Steps["1"].Timeline, expected) {
+		t.Errorf("Expected %#v, Found %#v", expected, r.Steps["1"].Timeline)
+	}
+}
+
+func TestTimelineSchedule(t *testing.T) {
+	defer func() { TimelineFile = "" }()
+	TimelineFile = filepath.Join(t.TempDir(), "timeline.csv")
+
+	start := time.Date(2026, 10, 14, 10, 0, 0, 0, time.Local)
+	tl := make(timeline)
+	for i := 0; i < 15; i++ {
+		tl.add(start.Add(30*time.Second), 10*time.Millisecond)
+	}
+	for i := 0; i < 30; i++ {
+		tl.add(start.Add(time.Minute), 20*time.Millisecond)
+	}
+
+	// Target rises from 10/s to 40/s between 10:00 and 10:02 local time
+	schedule := &types.LoadSchedule{Location: time.Local, Points: []types.SchedulePoint{
+		{TimeOfDay: 10 * time.Hour, Rate: 10},
+		{TimeOfDay: 10*time.Hour + 2*time.Minute, Rate: 40},
+	}}
+
+	s := &stdout{}
+	s.Init(false)
+	s.SetSchedule(schedule)
+	s.result = &Result{SuccessCount: 45, StepResults: map[uint16]*ScenarioStepResultSummary{
+		1: {SuccessCount: 45, Durations: map[string]float32{}, Timeline: tl.buckets(TimelineInterval), timeline: tl},
+	}, start: start.Add(30 * time.Second), end: start.Add(90 * time.Second)}
+
+	realOut := out
+	defer func() {
+		out = realOut
+	}()
+	buffer := new(bytes.Buffer)
+	out = buffer
+
+	s.finish()
+
+	// Rates are over the covered 30s of the buckets
+	buckets := s.result.StepResults[1].Timeline
+	if buckets[0].Rate != 0.5 || buckets[1].Rate != 1 {
+		t.Errorf("Unexpected achieved rates %#v", buckets)
+	}
+	if math.Abs(buckets[0].TargetRate-21.125) > 1e-9 || math.Abs(buckets[1].TargetRate-28.625) > 1e-9 {
+		t.Errorf("Unexpected target rates %#v", buckets)
+	}
+
+	printed := buffer.String()
+	for _, expected := range []string{
+		"Percentiles Over Time (Per 1m, Start:Count:Rate:Target Rate:p50:p95:p99):",
+		"10:00    :15    :0.5/s    :21.1/s    :0.0101s    :0.0101s    :0.0101s",
+		"10:01    :30    :1.0/s    :28.6/s    :0.0202s    :0.0202s    :0.0202s",
+	} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("Expected %q in the output:\n%s", expected, printed)
+		}
+	}
+
+	content, _ := ioutil.ReadFile(TimelineFile)
+	if !strings.Contains(string(content), ",15,0.010079,0.010079,0.010079,,,21.12,\n") {
+		t.Errorf("Target rate should be in the rate column, Found %q", content)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"sort"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// TLSSummary is the TLS handshakes of the new connections of a step, split by whether the session of a previous
+// connection is resumed, with the TLS versions and the ALPN protocols they negotiated.
+type TLSSummary struct {
+	FullHandshakes    int64 `json:"full_handshakes"`
+	ResumedHandshakes int64 `json:"resumed_handshakes"`
+
+	// Resumed handshakes over all the handshakes, in [0, 1]
+	ResumptionRatio float32 `json:"resumption_ratio"`
+
+	// Average handshake durations of each kind in seconds, mixing them hides the cost of the full handshakes
+	AvgFullDuration    float32 `json:"avg_full_duration,omitempty"`
+	AvgResumedDuration float32 `json:"avg_resumed_duration,omitempty"`
+
+	// Handshake counts by the negotiated TLS version, like "TLS 1.3", and ALPN protocol, like "h2"
+	Versions map[string]int64 `json:"versions,omitempty"`
+	ALPN     map[string]int64 `json:"alpn,omitempty"`
+}
+
+// tlsTracker counts the TLS handshakes of the requests of a step, created by the first request with a handshake.
+type tlsTracker struct {
+	full, resumed       int64
+	fullSum, resumedSum time.Duration
+	versions            map[string]int64
+	alpn                map[string]int64
+}
+
+func newTLSTracker() *tlsTracker {
+	return &tlsTracker{versions: make(map[string]int64), alpn: make(map[string]int64)}
+}
+
+// add counts the handshake of the request, the requests over a reused connection have none.
+func (t *tlsTracker) add(sr *types.ScenarioStepResult) {
+	resumed, ok := sr.Custom["tlsResumed"].(bool)
+	if !ok {
+		return
+	}
+	d, _ := sr.Custom["tlsDuration"].(time.Duration)
+	if resumed {
+		t.resumed++
+		t.resumedSum += d
+	} else {
+		t.full++
+		t.fullSum += d
+	}
+	if v, ok := sr.Custom["tlsVersion"].(string); ok {
+		t.versions[v]++
+	}
+	if p, ok := sr.Custom["tlsALPN"].(string); ok {
+		t.alpn[p]++
+	}
+}
+
+func (t *tlsTracker) merge(o *tlsTracker) {
+	t.full += o.full
+	t.resumed += o.resumed
+	t.fullSum += o.fullSum
+	t.resumedSum += o.resumedSum
+	for v, n := range o.versions {
+		t.versions[v] += n
+	}
+	for p, n := range o.alpn {
+		t.alpn[p] += n
+	}
+}
+
+// summary returns the summary of the handshakes, nil if there is none.
+func (t *tlsTracker) summary() *TLSSummary {
+	if t == nil || t.full+t.resumed == 0 {
+		return nil
+	}
+	s := &TLSSummary{
+		FullHandshakes:     t.full,
+		ResumedHandshakes:  t.resumed,
+		ResumptionRatio:    float32(float64(t.resumed) / float64(t.full+t.resumed)),
+		AvgFullDuration:    avgSeconds(t.fullSum, t.full),
+		AvgResumedDuration: avgSeconds(t.resumedSum, t.resumed),
+	}
+	if len(t.versions) > 0 {
+		s.Versions = make(map[string]int64, len(t.versions))
+		for v, n := range t.versions {
+			s.Versions[v] = n
+		}
+	}
+	if len(t.alpn) > 0 {
+		s.ALPN = make(map[string]int64, len(t.alpn))
+		for p, n := range t.alpn {
+			s.ALPN[p] = n
+		}
+	}
+	return s
+}
+
+// formatCounts formats the counts like "TLS 1.3: 90, TLS 1.2: 10", ordered by the counts and then the names.
+func formatCounts(counts map[string]int64) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	s := ""
+	for i, name := range names {
+		if i > 0 {
+			s += ", "
+		}
+		s += name + ": " + formatCount(counts[name])
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// UserSummary is a virtual user of the vu load type, recorded by the engine once the user is done.
+type UserSummary struct {
+	// Index of the user, starting from 0
+	User int
+
+	// Iteration count of the user and the iterations completed, less than the iterations if the test is stopped
+	Iterations int
+	Completed  int
+
+	// Time between the start of the first iteration of the user and the end of its last one, in seconds
+	Duration float32
+}
+
+// UsersSummary is the completion spread of the virtual users of the vu load type.
+type UsersSummary struct {
+	Users             int `json:"users"`
+	IterationsPerUser int `json:"iterations_per_user"`
+
+	// Users that completed all their iterations
+	Completed int `json:"completed"`
+
+	// Durations of the fastest and the slowest users in seconds, their indexes and the difference of the durations
+	FastestUser int     `json:"fastest_user"`
+	Fastest     float32 `json:"fastest"`
+	SlowestUser int     `json:"slowest_user"`
+	Slowest     float32 `json:"slowest"`
+	Spread      float32 `json:"spread"`
+}
+
+// userTracker collects the users recorded by the engine, concurrently with the aggregation.
+type userTracker struct {
+	mu      sync.Mutex
+	summary *UsersSummary
+}
+
+func (t *userTracker) add(u UserSummary) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.summary
+	if s == nil {
+		s = &UsersSummary{IterationsPerUser: u.Iterations, FastestUser: u.User, Fastest: u.Duration,
+			SlowestUser: u.User, Slowest: u.Duration}
+		t.summary = s
+	}
+	s.Users++
+	if u.Completed >= u.Iterations {
+		s.Completed++
+	}
+	// Ties are broken by the user index, so the summary doesn't depend on the recording order
+	if u.Duration < s.Fastest || (u.Duration == s.Fastest && u.User < s.FastestUser) {
+		s.FastestUser, s.Fastest = u.User, u.Duration
+	}
+	if u.Duration > s.Slowest || (u.Duration == s.Slowest && u.User < s.SlowestUser) {
+		s.SlowestUser, s.Slowest = u.User, u.Duration
+	}
+	s.Spread = s.Slowest - s.Fastest
+}
+
+// get returns a copy of the summary, nil if no user is recorded.
+func (t *userTracker) get() *UsersSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.summary == nil {
+		return nil
+	}
+	s := *t.summary
+	return &s
+}
+
+// printUsers writes the virtual users section of the report, w is a tabwriter of the report.
+func printUsers(w io.Writer, u *UsersSummary) {
+	if u == nil {
+		return
+	}
+	fmt.Fprintln(w, "Virtual Users:")
+	fmt.Fprintf(w, "  Completed:\t%d/%d users, %d iterations each\n", u.Completed, u.Users, u.IterationsPerUser)
+	fmt.Fprintf(w, "  Fastest User:\t#%d in %s\n", u.FastestUser, formatDuration(float64(u.Fastest)))
+	fmt.Fprintf(w, "  Slowest User:\t#%d in %s\n", u.SlowestUser, formatDuration(float64(u.Slowest)))
+	fmt.Fprintf(w, "  Completion Spread:\t%s\n", formatDuration(float64(u.Spread)))
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,194 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// VariantSummary is the result of the requests of a step in the iterations of a variant of the A/B mode.
+type VariantSummary struct {
+	SuccessCount int64 `json:"success_count"`
+	FailedCount  int64 `json:"fail_count"`
+
+	// Success count over all the requests of the variant, in [0, 1]
+	SuccessRate float32 `json:"success_rate"`
+
+	// Average and the 95th percentile of the durations of the successful requests in seconds
+	AvgDuration float32 `json:"avg_duration"`
+	P95         float32 `json:"p95"`
+
+	StatusCodeDist map[int]int `json:"status_code_dist"`
+}
+
+// variantTracker splits the results of a step by the variants of their iterations, created by the first result of
+// an iteration with a variant.
+type variantTracker map[string]*variantStats
+
+type variantStats struct {
+	success, failed int64
+	durationSum     time.Duration
+	durations       *histogram
+	statusCodes     map[int]int
+}
+
+func (t variantTracker) stats(variant string) *variantStats {
+	vs, ok := t[variant]
+	if !ok {
+		vs = &variantStats{durations: newHistogram(), statusCodes: make(map[int]int)}
+		t[variant] = vs
+	}
+	return vs
+}
+
+func (t variantTracker) add(sr *types.ScenarioStepResult) {
+	vs := t.stats(sr.Variant)
+	// Status codes are counted as the status code distribution of the step
+	if sr.Err.Type != "" {
+		vs.failed++
+		if sr.Err.Type == types.ErrorStatus {
+			vs.statusCodes[sr.StatusCode]++
+		}
+		return
+	}
+	vs.success++
+	vs.durationSum += sr.Duration
+	vs.durations.add(sr.Duration)
+	vs.statusCodes[sr.StatusCode]++
+}
+
+func (t variantTracker) merge(o variantTracker) {
+	for variant, ovs := range o {
+		vs := t.stats(variant)
+		vs.success += ovs.success
+		vs.failed += ovs.failed
+		vs.durationSum += ovs.durationSum
+		vs.durations.merge(ovs.durations)
+		for c, n := range ovs.statusCodes {
+			vs.statusCodes[c] += n
+		}
+	}
+}
+
+// summary returns the summaries of the variants, nil if the step has no result with a variant.
+func (t variantTracker) summary() map[string]*VariantSummary {
+	if len(t) == 0 {
+		return nil
+	}
+	summaries := make(map[string]*VariantSummary, len(t))
+	for variant, vs := range t {
+		s := &VariantSummary{
+			SuccessCount:   vs.success,
+			FailedCount:    vs.failed,
+			AvgDuration:    avgSeconds(vs.durationSum, vs.success),
+			StatusCodeDist: make(map[int]int, len(vs.statusCodes)),
+		}
+		if total := vs.success + vs.failed; total > 0 {
+			s.SuccessRate = float32(float64(vs.success) / float64(total))
+		}
+		if vs.durations.total > 0 {
+			s.P95 = float32(vs.durations.percentile(95).Seconds())
+		}
+		for c, n := range vs.statusCodes {
+			s.StatusCodeDist[c] = n
+		}
+		summaries[variant] = s
+	}
+	return summaries
+}
+
+// printVariants writes the results of the A/B variants of a step side by side, w is a tabwriter of the report.
+// The deltas of the b variant over the a variant are colored green if they are better and red if they are worse.
+func printVariants(w io.Writer, variants map[string]*VariantSummary) {
+	a, b := variants[types.VariantA], variants[types.VariantB]
+	if a == nil {
+		a = &VariantSummary{}
+	}
+	if b == nil {
+		b = &VariantSummary{}
+	}
+	aCount, bCount := a.SuccessCount+a.FailedCount, b.SuccessCount+b.FailedCount
+
+	fmt.Fprintln(w, "\nA/B Variants (Metric:A:B:Delta):")
+	fmt.Fprintf(w, "  Requests\t:%s\t:%s\t:\n", formatCount(aCount), formatCount(bCount))
+	fmt.Fprintf(w, "  Success %%\t:%s\t:%s\t:%s\n",
+		formatPercent(int(a.SuccessRate*100), a.SuccessCount, aCount),
+		formatPercent(int(b.SuccessRate*100), b.SuccessCount, bCount),
+		variantDelta(float64(b.SuccessRate-a.SuccessRate)*100, "pp", true))
+	fmt.Fprintf(w, "  Avg. Duration\t:%s\t:%s\t:%s\n", formatDuration(float64(a.AvgDuration)),
+		formatDuration(float64(b.AvgDuration)), durationDelta(a.AvgDuration, b.AvgDuration))
+	fmt.Fprintf(w, "  P95\t:%s\t:%s\t:%s\n", formatDuration(float64(a.P95)), formatDuration(float64(b.P95)),
+		durationDelta(a.P95, b.P95))
+
+	codes := make([]int, 0, len(a.StatusCodeDist)+len(b.StatusCodeDist))
+	for c := range a.StatusCodeDist {
+		codes = append(codes, c)
+	}
+	for c := range b.StatusCodeDist {
+		if _, ok := a.StatusCodeDist[c]; !ok {
+			codes = append(codes, c)
+		}
+	}
+	sort.Ints(codes)
+	for _, c := range codes {
+		an, bn := int64(a.StatusCodeDist[c]), int64(b.StatusCodeDist[c])
+		fmt.Fprintf(w, "  %3d (%s)\t:%s\t:%s\t:%s\n", c, http.StatusText(c), variantShare(an, aCount),
+			variantShare(bn, bCount), formatSigned(share(bn, bCount)-share(an, aCount), 1)+"pp")
+	}
+}
+
+// durationDelta returns the change of the duration of the b variant relative to the a variant. Shorter is better.
+func durationDelta(a, b float32) string {
+	if a == 0 || b == 0 {
+		return "-"
+	}
+	return variantDelta(float64(b-a)/float64(a)*100, "%", false)
+}
+
+// variantDelta returns the delta with its unit, colored by whether the change is better for the b variant.
+func variantDelta(delta float64, unit string, higherIsBetter bool) string {
+	s := formatSigned(delta, 1) + unit
+	if math.Round(delta*10) == 0 {
+		return s
+	}
+	if (delta > 0) == higherIsBetter {
+		return green(s) + reportColor()
+	}
+	return red(s) + reportColor()
+}
+
+func variantShare(n, total int64) string {
+	return fmt.Sprintf("%s (%s)", formatCount(n), formatPercent(int(share(n, total)), n, total))
+}
+
+// share returns the percentage of n in the total, 0 if the total is 0.
+func share(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
@@ -0,0 +1,190 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// VerifyResult is the pass/fail matrix of the steps over the iterations of the verify mode.
+type VerifyResult struct {
+	// Set if all the planned iterations are run and all the requests are passed
+	Passed bool `json:"passed"`
+
+	Planned    int           `json:"planned_iterations"`
+	Iterations int           `json:"iterations"`
+	Failed     int           `json:"failed_requests"`
+	Steps      []*VerifyStep `json:"steps"`
+
+	// Teardown steps run after the iterations, set by the reports
+	Teardown []TeardownSummary `json:"teardown,omitempty"`
+}
+
+// VerifyStep is a row of the matrix, the checks are ordered by the iterations.
+type VerifyStep struct {
+	ID     uint16         `json:"id"`
+	Name   string         `json:"name"`
+	Checks []*VerifyCheck `json:"checks"`
+}
+
+// VerifyCheck is the result of a step in an iteration. Failed requests are captured with their full detail.
+type VerifyCheck struct {
+	Iteration  int     `json:"iteration"`
+	Passed     bool    `json:"passed"`
+	StatusCode int     `json:"status_code,omitempty"`
+	Duration   float32 `json:"duration"`
+	Error      string  `json:"error,omitempty"`
+
+	Failure *verboseHttpRequestInfo `json:"failure,omitempty"`
+	sr      *types.ScenarioStepResult
+}
+
+// verifyMatrix builds the VerifyResult from the scenario results of the verify mode, which are sent one by
+// one in the order of the iterations.
+type verifyMatrix struct {
+	result *VerifyResult
+	rows   map[uint16]*VerifyStep
+
+	// Steps with a success_status, whose status codes are already checked by the requester
+	statusChecked map[uint16]bool
+}
+
+func newVerifyMatrix(steps []types.ScenarioStep, planned int) *verifyMatrix {
+	m := &verifyMatrix{
+		result: &VerifyResult{Planned: planned, Steps: make([]*VerifyStep, 0, len(steps))},
+		rows:   make(map[uint16]*VerifyStep, len(steps)),
+
+		statusChecked: make(map[uint16]bool),
+	}
+	for _, s := range steps {
+		row := &VerifyStep{ID: s.ID, Name: s.Name}
+		m.result.Steps = append(m.result.Steps, row)
+		m.rows[s.ID] = row
+		if s.SuccessStatus != "" {
+			m.statusChecked[s.ID] = true
+		}
+	}
+	return m
+}
+
+func (m *verifyMatrix) add(r *types.ScenarioResult) {
+	m.result.Iterations++
+	for _, sr := range r.StepResults {
+		row, ok := m.rows[sr.StepID]
+		if !ok {
+			row = &VerifyStep{ID: sr.StepID, Name: sr.StepName}
+			m.result.Steps = append(m.result.Steps, row)
+			m.rows[sr.StepID] = row
+		}
+
+		c := &VerifyCheck{
+			Iteration:  m.result.Iterations,
+			StatusCode: sr.StatusCode,
+			Duration:   float32(sr.Duration.Seconds()),
+		}
+		c.Error, c.Passed = verifyCheck(sr, m.statusChecked[sr.StepID])
+		if !c.Passed {
+			m.result.Failed++
+			if sr.DebugInfo != nil {
+				info := ScenarioStepResultToVerboseHttpRequestInfo(sr)
+				c.Failure = &info
+				c.sr = sr
+			}
+		}
+		row.Checks = append(row.Checks, c)
+	}
+}
+
+// finish decides the result of the verify mode. The steps which are not run in an iteration fail it as well.
+func (m *verifyMatrix) finish() *VerifyResult {
+	r := m.result
+	r.Passed = r.Failed == 0 && r.Iterations == r.Planned
+	for _, s := range r.Steps {
+		if len(s.Checks) < r.Iterations {
+			r.Passed = false
+		}
+	}
+	return r
+}
+
+// verifyCheck decides whether the request of a step is passed in the verify mode. In addition to the failed
+// requests, the responses with 4xx and 5xx status codes are failed unless the step has a success_status.
+func verifyCheck(sr *types.ScenarioStepResult, statusChecked bool) (reason string, passed bool) {
+	if sr.Err.Type != "" {
+		return sr.Err.Error(), false
+	}
+	if !statusChecked && sr.StatusCode >= 400 {
+		return fmt.Sprintf("unexpected status code: %d", sr.StatusCode), false
+	}
+	return "", true
+}
+
+// checkOf returns the check of the step in the given iteration, nil if the step is not run in it.
+func (s *VerifyStep) checkOf(iteration int) *VerifyCheck {
+	for _, c := range s.Checks {
+		if c.Iteration == iteration {
+			return c
+		}
+	}
+	return nil
+}
+
+// printVerifyMatrix writes the matrix with a column per iteration, "-" marks the steps which are not run.
+func printVerifyMatrix(w io.Writer, r *VerifyResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := []string{"Step"}
+	for i := 1; i <= r.Iterations; i++ {
+		header = append(header, fmt.Sprintf("%d", i))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, s := range r.Steps {
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("Step %d", s.ID)
+		}
+		row := []string{fmt.Sprintf("%d. %s", s.ID, name)}
+		for i := 1; i <= r.Iterations; i++ {
+			switch c := s.checkOf(i); {
+			case c == nil:
+				row = append(row, "-")
+			case c.Passed:
+				row = append(row, "PASS")
+			default:
+				row = append(row, "FAIL")
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+
+	status := "PASS"
+	if !r.Passed {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "\nVerify: %s (%d failed requests, %d/%d iterations)\n", status, r.Failed, r.Iterations, r.Planned)
+}
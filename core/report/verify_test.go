@@ -0,0 +1,215 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func stepResult(id uint16, status int, err types.RequestError) *types.ScenarioStepResult {
+	return &types.ScenarioStepResult{
+		StepID:     id,
+		StatusCode: status,
+		Err:        err,
+		Duration:   100 * time.Millisecond,
+		DebugInfo: map[string]interface{}{
+			"url":             "http://test.com",
+			"method":          http.MethodGet,
+			"requestHeaders":  http.Header{},
+			"requestBody":     []byte{},
+			"responseHeaders": http.Header{},
+			"responseBody":    []byte("body"),
+		},
+	}
+}
+
+func iteration(srs ...*types.ScenarioStepResult) *types.ScenarioResult {
+	return &types.ScenarioResult{StepResults: srs}
+}
+
+var noErr = types.RequestError{}
+
+var verifySteps = []types.ScenarioStep{{ID: 1, Name: "Login"}, {ID: 2, Name: "Order"}}
+
+func TestVerifyMatrix(t *testing.T) {
+	assertion := types.RequestError{Type: types.ErrorAssertion, Reason: "json schema mismatch at /id"}
+	tests := []struct {
+		name       string
+		iterations []*types.ScenarioResult
+		planned    int
+		passed     bool
+		failed     int
+	}{
+		{"Passed", []*types.ScenarioResult{
+			iteration(stepResult(1, 200, noErr), stepResult(2, 302, noErr)),
+			iteration(stepResult(1, 200, noErr), stepResult(2, 200, noErr)),
+		}, 2, true, 0},
+		{"Assertion", []*types.ScenarioResult{
+			iteration(stepResult(1, 200, noErr), stepResult(2, 200, assertion)),
+		}, 1, false, 1},
+		{"StatusCode", []*types.ScenarioResult{
+			iteration(stepResult(1, 404, noErr), stepResult(2, 503, noErr)),
+		}, 1, false, 2},
+		{"MissingStep", []*types.ScenarioResult{
+			iteration(stepResult(1, 200, noErr)),
+		}, 1, false, 0},
+		{"Stopped", []*types.ScenarioResult{
+			iteration(stepResult(1, 200, noErr), stepResult(2, 200, noErr)),
+		}, 10, false, 0},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			m := newVerifyMatrix(verifySteps, test.planned)
+			for _, r := range test.iterations {
+				m.add(r)
+			}
+			r := m.finish()
+
+			if r.Passed != test.passed || r.Failed != test.failed || r.Iterations != len(test.iterations) {
+				t.Errorf("Expected passed: %v failed: %d, Found %#v", test.passed, test.failed, r)
+			}
+			for _, s := range r.Steps {
+				for _, c := range s.Checks {
+					if !c.Passed && (c.Failure == nil || c.Error == "") {
+						t.Errorf("Failed request should be captured, Found %#v", c)
+					}
+					if c.Passed && c.Failure != nil {
+						t.Errorf("Passed request should not be captured, Found %#v", c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyMatrixSuccessStatus(t *testing.T) {
+	steps := []types.ScenarioStep{{ID: 1, Name: "Delete", SuccessStatus: "200-299,404"}, {ID: 2, Name: "Order"}}
+	status := types.RequestError{Type: types.ErrorStatus, Reason: "unexpected status code: 200"}
+
+	m := newVerifyMatrix(steps, 1)
+	m.add(iteration(stepResult(1, 404, noErr), stepResult(2, 404, noErr)))
+	m.add(iteration(stepResult(1, 500, status), stepResult(2, 200, noErr)))
+	m.finish()
+
+	expected := map[uint16][]bool{1: {true, false}, 2: {false, true}}
+	for _, s := range m.result.Steps {
+		for i, c := range s.Checks {
+			if c.Passed != expected[s.ID][i] {
+				t.Errorf("Step %d of iteration %d Expected passed: %v, Found %v", s.ID, c.Iteration, expected[s.ID][i],
+					c.Passed)
+			}
+		}
+	}
+}
+
+func TestPrintVerifyMatrix(t *testing.T) {
+	m := newVerifyMatrix(verifySteps, 3)
+	m.add(iteration(stepResult(1, 200, noErr), stepResult(2, 200, noErr)))
+	m.add(iteration(stepResult(1, 200, noErr), stepResult(2, 500, noErr)))
+	m.add(iteration(stepResult(1, 200, noErr)))
+
+	b := &strings.Builder{}
+	printVerifyMatrix(b, m.finish())
+
+	expected := "Step      1     2     3\n" +
+		"1. Login  PASS  PASS  PASS\n" +
+		"2. Order  PASS  FAIL  -\n" +
+		"\nVerify: FAIL (1 failed requests, 3/3 iterations)\n"
+	if b.String() != expected {
+		t.Errorf("Expected:\n%q\nFound:\n%q", expected, b.String())
+	}
+}
+
+func TestStdoutVerifyMode(t *testing.T) {
+	realOut := out
+	defer func() {
+		out = realOut
+	}()
+	buffer := new(bytes.Buffer)
+	out = buffer
+
+	s := &stdout{}
+	s.Init(false)
+	s.SetScenario(types.Scenario{Steps: verifySteps})
+	s.SetVerify(2)
+
+	input := make(chan *types.ScenarioResult, 2)
+	input <- iteration(stepResult(1, 200, noErr), stepResult(2, 200, noErr))
+	input <- iteration(stepResult(1, 200, noErr), stepResult(2, 500, noErr))
+	close(input)
+	go s.Start(input)
+	<-s.DoneChan()
+
+	if r := s.VerifyResult(); r == nil || r.Passed {
+		t.Errorf("Verify should be failed, Found %#v", r)
+	}
+	printed := buffer.String()
+	for _, p := range []string{"< StatusCode:    500", "2. Order  PASS  FAIL"} {
+		if !strings.Contains(printed, p) {
+			t.Errorf("Expected %q to be printed, Found: %s", p, printed)
+		}
+	}
+	if strings.Count(printed, "REQUEST  *") != 1 {
+		t.Errorf("Only the failed request should be printed in detail, Found: %s", printed)
+	}
+}
+
+func TestStdoutJsonVerifyMode(t *testing.T) {
+	realPrintJson := printJson
+	defer func() { printJson = realPrintJson }()
+	var output []byte
+	printJson = func(j []byte) {
+		output = j
+	}
+
+	s := &stdoutJson{}
+	s.Init(false)
+	s.SetScenario(types.Scenario{Steps: verifySteps})
+	s.SetVerify(1)
+
+	input := make(chan *types.ScenarioResult, 1)
+	input <- iteration(stepResult(1, 200, noErr), stepResult(2, 200, noErr))
+	close(input)
+	go s.Start(input)
+	<-s.DoneChan()
+
+	printed := struct {
+		Verify *VerifyResult `json:"verify"`
+	}{}
+	if err := json.Unmarshal(output, &printed); err != nil {
+		t.Fatalf("Verify result should be printed as json, Found %s, err: %v", output, err)
+	}
+	if printed.Verify == nil || !printed.Verify.Passed || len(printed.Verify.Steps) != 2 {
+		t.Errorf("Expected the passed verify of 2 steps, Found %s", output)
+	}
+	if !s.VerifyResult().Passed {
+		t.Errorf("Verify should be passed")
+	}
+}
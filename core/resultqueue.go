@@ -0,0 +1,106 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package core
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.ddosify.com/ddosify/core/report"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// resultQueue is the result channel of the load test, instrumented for the back-pressure of the report service.
+// The length of the channel is sampled on each tick, and the time the iterations wait on the full channel is summed.
+type resultQueue struct {
+	ch    chan *types.ScenarioResult
+	abort <-chan struct{}
+
+	// Written by the workers
+	blocked      int64 // Nanoseconds
+	blockedSends int64
+	maxLen       int64
+
+	// Written by the tick loop only
+	samples int64
+	lenSum  int64
+}
+
+func newResultQueue(ch chan *types.ScenarioResult, abort <-chan struct{}) *resultQueue {
+	return &resultQueue{ch: ch, abort: abort}
+}
+
+// send passes the result to the report service, the wait on the full channel is timed. Once the test is aborted,
+// the report service doesn't consume the results anymore, so a blocked result is dropped.
+func (q *resultQueue) send(res *types.ScenarioResult) {
+	select {
+	case q.ch <- res:
+		q.observeLen()
+		return
+	default:
+	}
+
+	start := time.Now()
+	select {
+	case q.ch <- res:
+	case <-q.abort:
+	}
+	atomic.AddInt64(&q.blocked, int64(time.Since(start)))
+	atomic.AddInt64(&q.blockedSends, 1)
+	q.observeLen()
+}
+
+// observeLen updates the max length with the current length of the channel.
+func (q *resultQueue) observeLen() {
+	n := int64(len(q.ch))
+	for {
+		max := atomic.LoadInt64(&q.maxLen)
+		if n <= max || atomic.CompareAndSwapInt64(&q.maxLen, max, n) {
+			return
+		}
+	}
+}
+
+// sample records the current length of the channel for the average length.
+func (q *resultQueue) sample() {
+	q.samples++
+	q.lenSum += int64(len(q.ch))
+}
+
+// blockedDuration returns the total time the iterations waited on the full channel.
+func (q *resultQueue) blockedDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&q.blocked))
+}
+
+// health returns the stats of the channel, the block warning is set if the total wait is over the given duration.
+func (q *resultQueue) health(warnAfter time.Duration) report.GeneratorHealth {
+	g := report.GeneratorHealth{
+		ResultBufferSize: cap(q.ch),
+		MaxQueueLength:   int(atomic.LoadInt64(&q.maxLen)),
+		BlockedSends:     atomic.LoadInt64(&q.blockedSends),
+		BlockedDuration:  float32(q.blockedDuration().Seconds()),
+		BlockWarning:     q.blockedDuration() > warnAfter,
+	}
+	if q.samples > 0 {
+		g.AvgQueueLength = float32(q.lenSum) / float32(q.samples)
+	}
+	return g
+}
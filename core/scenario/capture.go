@@ -0,0 +1,206 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package scenario
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Bodies longer than captureBodyLimit bytes are truncated in the capture file.
+const captureBodyLimit = 8 * 1024
+
+// Reasons of the captured requests
+const (
+	captureReasonSampled = "sampled"
+	captureReasonFailure = "failure"
+)
+
+// capturer samples the iterations whose requests are sent in the capture mode and writes the captured requests
+// to the NDJSON file of the types.Capture.
+type capturer struct {
+	rate     float64
+	count    int
+	failures int
+	rnd      *rand.Rand
+
+	mu            sync.Mutex
+	sampled       int
+	failureCounts map[string]int
+	file          *os.File
+	w             *bufio.Writer
+	enc           *json.Encoder
+
+	// Capturing is stopped on the first write error, the test goes on.
+	err error
+}
+
+// captureRecord is a line of the capture file.
+type captureRecord struct {
+	Time     time.Time `json:"time"`
+	Reason   string    `json:"reason"`
+	Proxy    string    `json:"proxy,omitempty"`
+	StepID   uint16    `json:"step_id"`
+	StepName string    `json:"step_name,omitempty"`
+
+	IterationID uint64 `json:"iteration_id"`
+	VUID        uint64 `json:"vu_id"`
+	Variant     string `json:"variant,omitempty"`
+
+	URL                   string      `json:"url"`
+	Method                string      `json:"method"`
+	RequestHeaders        http.Header `json:"request_headers"`
+	RequestBody           string      `json:"request_body"`
+	RequestBodyTruncated  bool        `json:"request_body_truncated,omitempty"`
+	StatusCode            int         `json:"status_code,omitempty"`
+	ResponseHeaders       http.Header `json:"response_headers,omitempty"`
+	ResponseBody          string      `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool        `json:"response_body_truncated,omitempty"`
+
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+
+	// Requests of the redirect chain from the first one, if the request is redirected
+	Redirects []types.RedirectHop `json:"redirects,omitempty"`
+}
+
+// newCapturer creates the capture file. rnd must be safe for concurrent use, it samples the iterations.
+func newCapturer(c types.Capture, rnd *rand.Rand) (*capturer, error) {
+	f, err := os.Create(c.File)
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &capturer{
+		rate:          c.Rate,
+		count:         c.Count,
+		failures:      c.Failures,
+		rnd:           rnd,
+		failureCounts: make(map[string]int),
+		file:          f,
+		w:             w,
+		enc:           enc,
+	}, nil
+}
+
+// sample decides whether the next iteration is captured. It is called before the requests of the iteration are built.
+func (c *capturer) sample() bool {
+	if c.rnd.Float64() >= c.rate {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.count > 0 && c.sampled >= c.count {
+		return false
+	}
+	c.sampled++
+	return true
+}
+
+// capture writes the captured requests of the iteration. Failed requests are captured until the failure limit of
+// their error type is reached, whether the iteration is sampled or not.
+func (c *capturer) capture(res *types.ScenarioResult, sampled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, sr := range res.StepResults {
+		reason := ""
+		if sampled {
+			reason = captureReasonSampled
+		}
+		if sr.Err.Type != "" && c.failureCounts[sr.Err.Type] < c.failures {
+			c.failureCounts[sr.Err.Type]++
+			if reason == "" {
+				reason = captureReasonFailure
+			}
+		}
+
+		if reason == "" || sr.DebugInfo == nil || c.err != nil {
+			continue
+		}
+		c.err = c.enc.Encode(newCaptureRecord(sr, sr.ProxyAddr, reason))
+	}
+}
+
+func (c *capturer) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.w.Flush(); err != nil && c.err == nil {
+		c.err = err
+	}
+	if err := c.file.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+	return c.err
+}
+
+func newCaptureRecord(sr *types.ScenarioStepResult, proxy *url.URL, reason string) captureRecord {
+	r := captureRecord{
+		Time:       sr.RequestTime,
+		Reason:     reason,
+		StepID:     sr.StepID,
+		StepName:   sr.StepName,
+		StatusCode: sr.StatusCode,
+
+		IterationID: sr.IterationID,
+		VUID:        sr.VUID,
+		Variant:     sr.Variant,
+		Duration:    sr.Duration.Seconds(),
+	}
+	if proxy != nil {
+		r.Proxy = proxy.String()
+	}
+	if sr.Err.Type != "" {
+		r.Error = sr.Err.Error()
+	}
+
+	r.URL, _ = sr.DebugInfo["url"].(string)
+	r.Method, _ = sr.DebugInfo["method"].(string)
+	r.RequestHeaders, _ = sr.DebugInfo["requestHeaders"].(http.Header)
+	r.ResponseHeaders, _ = sr.DebugInfo["responseHeaders"].(http.Header)
+
+	reqBody, _ := sr.DebugInfo["requestBody"].([]byte)
+	r.RequestBody, r.RequestBodyTruncated = truncateBody(reqBody)
+	resBody, _ := sr.DebugInfo["responseBody"].([]byte)
+	r.ResponseBody, r.ResponseBodyTruncated = truncateBody(resBody)
+	r.Redirects, _ = sr.Custom["redirects"].([]types.RedirectHop)
+	return r
+}
+
+func truncateBody(b []byte) (string, bool) {
+	if len(b) > captureBodyLimit {
+		return string(b[:captureBodyLimit]), true
+	}
+	return string(b), false
+}
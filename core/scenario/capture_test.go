@@ -0,0 +1,195 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package scenario
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+)
+
+func readCaptureRecords(t *testing.T, path string) (records []captureRecord) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("capture file couldn't be opened: %v", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		r := captureRecord{}
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("capture line is not a json: %s", sc.Text())
+		}
+		records = append(records, r)
+	}
+	return
+}
+
+func TestCapturerSample(t *testing.T) {
+	tests := []struct {
+		name     string
+		capture  types.Capture
+		expected int
+	}{
+		{"All", types.Capture{Rate: 1}, 10},
+		{"Count", types.Capture{Rate: 1, Count: 3}, 3},
+		{"FailuresOnly", types.Capture{Failures: 10}, 0},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			test.capture.File = filepath.Join(t.TempDir(), "captures.ndjson")
+			c, err := newCapturer(test.capture, util.NewRand(1))
+			if err != nil {
+				t.Fatalf("newCapturer errored: %v", err)
+			}
+			defer c.close()
+
+			sampled := 0
+			for i := 0; i < 10; i++ {
+				if c.sample() {
+					sampled++
+				}
+			}
+			if sampled != test.expected {
+				t.Errorf("Expected %d sampled iterations, Found %d", test.expected, sampled)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestCapturerCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "captures.ndjson")
+	c, err := newCapturer(types.Capture{Rate: 0.5, Failures: 2, File: path}, util.NewRand(1))
+	if err != nil {
+		t.Fatalf("newCapturer errored: %v", err)
+	}
+
+	debugInfo := map[string]interface{}{
+		"url":             "https://ddosify.com",
+		"method":          http.MethodPost,
+		"requestHeaders":  http.Header{"X": {"y"}},
+		"requestBody":     []byte(strings.Repeat("a", captureBodyLimit+1)),
+		"responseBody":    []byte("resbody"),
+		"responseHeaders": http.Header{},
+	}
+	failed := func(errType string) *types.ScenarioStepResult {
+		return &types.ScenarioStepResult{StepID: 1, DebugInfo: debugInfo,
+			Err: types.RequestError{Type: errType, Reason: "reason"}}
+	}
+
+	// Sampled iteration
+	c.capture(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+		{StepID: 1, StatusCode: 200, DebugInfo: debugInfo, IterationID: 5, VUID: 2},
+		failed(types.ErrorConn),
+	}}, true)
+	// Not sampled iterations, only the failures are captured until the limit of their type.
+	c.capture(&types.ScenarioResult{StepResults: []*types.ScenarioStepResult{
+		{StepID: 1, StatusCode: 200},
+		failed(types.ErrorConn),
+		failed(types.ErrorConn),
+		failed(types.ErrorDns),
+	}}, false)
+
+	if err := c.close(); err != nil {
+		t.Fatalf("close errored: %v", err)
+	}
+
+	records := readCaptureRecords(t, path)
+	reasons := []string{}
+	for _, r := range records {
+		reasons = append(reasons, r.Reason)
+	}
+	expected := "sampled,sampled,failure,failure"
+	if strings.Join(reasons, ",") != expected {
+		t.Fatalf("Expected reasons %s, Found %s", expected, strings.Join(reasons, ","))
+	}
+
+	r := records[0]
+	if r.URL != "https://ddosify.com" || r.Method != http.MethodPost || r.RequestHeaders.Get("X") != "y" {
+		t.Errorf("Unexpected captured request: %#v", r)
+	}
+	if r.IterationID != 5 || r.VUID != 2 {
+		t.Errorf("Iteration Expected 5 of user 2, Found %d of %d", r.IterationID, r.VUID)
+	}
+	if len(r.RequestBody) != captureBodyLimit || !r.RequestBodyTruncated {
+		t.Errorf("Request body should be truncated to %d bytes, Found: %d", captureBodyLimit, len(r.RequestBody))
+	}
+	if r.ResponseBody != "resbody" || r.ResponseBodyTruncated {
+		t.Errorf("ResponseBody Expected resbody, Found: %s", r.ResponseBody)
+	}
+	if records[3].Error != types.ErrorDns+": reason" {
+		t.Errorf("Unexpected captured error: %s", records[3].Error)
+	}
+}
+
+func TestDoCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("resbody"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "captures.ndjson")
+	scenario := types.Scenario{
+		Steps: []types.ScenarioStep{
+			{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      server.URL,
+				Timeout:  types.DefaultTimeout,
+			},
+		},
+		Seed:    1,
+		Capture: types.Capture{Rate: 1, Count: 2, File: path},
+	}
+	var proxy *url.URL
+
+	service := NewScenarioService()
+	if err := service.Init(context.TODO(), scenario, []*url.URL{proxy}, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		service.Do(proxy, time.Now())
+	}
+	service.Done()
+
+	records := readCaptureRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 captured requests, Found %d", len(records))
+	}
+	if records[0].ResponseBody != "resbody" || records[0].StatusCode != http.StatusOK {
+		t.Errorf("Unexpected captured response: %#v", records[0])
+	}
+}
@@ -0,0 +1,866 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+// Schema is a compiled JSON Schema to validate the response bodies. The validation keywords of the draft 7
+// are supported, except format and the remote references. The annotations and the content keywords are ignored
+// like the unknown keywords.
+type Schema struct {
+	root *schemaNode
+}
+
+// Error is the first validation error of a document, Pointer is the JSON pointer of the invalid value.
+// Messages do not include the invalid values, so the errors of the different documents are deduplicated.
+type Error struct {
+	Pointer string
+	Message string
+}
+
+func (e *Error) Error() string {
+	p := e.Pointer
+	if p == "" {
+		p = "(root)"
+	}
+	return fmt.Sprintf("%s: %s", p, e.Message)
+}
+
+type schemaNode struct {
+	// Boolean schemas accept or reject every value
+	always *bool
+
+	types    []string
+	enum     []interface{}
+	constVal interface{}
+	hasConst bool
+
+	properties           map[string]*schemaNode
+	patternProperties    []patternSchema
+	additionalProperties *schemaNode
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+	propertyNames        *schemaNode
+	dependencies         []schemaDependency
+
+	items           *schemaNode
+	tupleItems      []*schemaNode
+	additionalItems *schemaNode
+	contains        *schemaNode
+	minItems        *int
+	maxItems        *int
+	uniqueItems     bool
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	allOf []*schemaNode
+	anyOf []*schemaNode
+	oneOf []*schemaNode
+	not   *schemaNode
+
+	ifSchema   *schemaNode
+	thenSchema *schemaNode
+	elseSchema *schemaNode
+
+	ref *schemaNode
+}
+
+// schemaDependency is a dependency of a property, either the required properties or a schema of the object.
+type schemaDependency struct {
+	property string
+	required []string
+	schema   *schemaNode
+}
+
+type patternSchema struct {
+	pattern *regexp.Regexp
+	schema  *schemaNode
+}
+
+var schemaTypes = [...]string{"null", "boolean", "object", "array", "number", "integer", "string"}
+
+// Load reads and compiles the JSON Schema file.
+func Load(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("json schema file can not be read: %v", err)
+	}
+	s, err := Compile(data)
+	if err != nil {
+		return nil, fmt.Errorf("json schema %s is not valid: %v", path, err)
+	}
+	return s, nil
+}
+
+// Compile compiles the JSON Schema document. The local references like "#/definitions/user"
+// are resolved once here. A reference cycle that never descends into a property or an item of the value, like
+// {"$ref": "#"}, would not end its validation, it is an invalid schema.
+func Compile(data []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid json: %v", err)
+	}
+
+	c := &schemaCompiler{doc: doc, refs: make(map[string]*schemaNode), paths: make(map[*schemaNode]string)}
+	root, err := c.compileRef("#")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkCycles(root); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate validates the decoded JSON document and returns the first validation error.
+func (s *Schema) Validate(v interface{}) *Error {
+	return s.root.validate(v, "")
+}
+
+// ValidateJSON decodes and validates the JSON document.
+func (s *Schema) ValidateJSON(data []byte) *Error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return &Error{Message: "body is not a valid json"}
+	}
+	return s.Validate(v)
+}
+
+type schemaCompiler struct {
+	doc interface{}
+
+	// Compiled schemas by their references, so the recursive schemas are compiled once
+	refs map[string]*schemaNode
+
+	// Paths of the compiled schemas for the errors of the cycle check
+	paths map[*schemaNode]string
+}
+
+func (c *schemaCompiler) compileRef(ref string) (*schemaNode, error) {
+	if n, ok := c.refs[ref]; ok {
+		return n, nil
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only the local references are supported: %s", ref)
+	}
+
+	v := c.doc
+	if p := strings.TrimPrefix(ref, "#"); p != "" {
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("invalid reference: %s", ref)
+		}
+		for _, token := range strings.Split(p[1:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch t := v.(type) {
+			case map[string]interface{}:
+				var ok bool
+				if v, ok = t[token]; !ok {
+					return nil, fmt.Errorf("reference can not be resolved: %s", ref)
+				}
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(t) {
+					return nil, fmt.Errorf("reference can not be resolved: %s", ref)
+				}
+				v = t[i]
+			default:
+				return nil, fmt.Errorf("reference can not be resolved: %s", ref)
+			}
+		}
+	}
+
+	n := &schemaNode{}
+	c.refs[ref] = n
+	c.paths[n] = ref
+	if err := c.compile(n, v, ref); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (c *schemaCompiler) compileSub(v interface{}, path string) (*schemaNode, error) {
+	n := &schemaNode{}
+	c.paths[n] = path
+	if err := c.compile(n, v, path); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (c *schemaCompiler) compileList(v interface{}, path string) ([]*schemaNode, error) {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("%s should be a non-empty array of schemas", path)
+	}
+	nodes := make([]*schemaNode, 0, len(list))
+	for i, s := range list {
+		n, err := c.compileSub(s, fmt.Sprintf("%s/%d", path, i))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func (c *schemaCompiler) compile(n *schemaNode, v interface{}, path string) (err error) {
+	if b, ok := v.(bool); ok {
+		n.always = &b
+		return nil
+	}
+	s, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s should be an object or a boolean", path)
+	}
+
+	if r, ok := s["$ref"]; ok {
+		ref, isStr := r.(string)
+		if !isStr {
+			return fmt.Errorf("%s/$ref should be a string", path)
+		}
+		// Siblings of $ref are ignored in the draft 7
+		n.ref, err = c.compileRef(ref)
+		return err
+	}
+
+	if t, ok := s["type"]; ok {
+		switch t := t.(type) {
+		case string:
+			n.types = []string{t}
+		case []interface{}:
+			for _, e := range t {
+				str, _ := e.(string)
+				n.types = append(n.types, str)
+			}
+		}
+		if len(n.types) == 0 {
+			return fmt.Errorf("%s/type should be a type name or an array of type names", path)
+		}
+		for _, t := range n.types {
+			if !util.StringInSlice(t, schemaTypes[:]) {
+				return fmt.Errorf("%s/type has an unknown type: %s", path, t)
+			}
+		}
+	}
+	if e, ok := s["enum"]; ok {
+		if n.enum, ok = e.([]interface{}); !ok {
+			return fmt.Errorf("%s/enum should be an array", path)
+		}
+	}
+	if cv, ok := s["const"]; ok {
+		n.constVal, n.hasConst = cv, true
+	}
+
+	if p, ok := s["properties"]; ok {
+		props, isObj := p.(map[string]interface{})
+		if !isObj {
+			return fmt.Errorf("%s/properties should be an object", path)
+		}
+		n.properties = make(map[string]*schemaNode, len(props))
+		for name, ps := range props {
+			if n.properties[name], err = c.compileSub(ps, path+"/properties/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	if p, ok := s["patternProperties"]; ok {
+		props, isObj := p.(map[string]interface{})
+		if !isObj {
+			return fmt.Errorf("%s/patternProperties should be an object", path)
+		}
+		patterns := make([]string, 0, len(props))
+		for pattern := range props {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s/patternProperties has an invalid pattern: %s", path, pattern)
+			}
+			ps, err := c.compileSub(props[pattern], path+"/patternProperties/"+pattern)
+			if err != nil {
+				return err
+			}
+			n.patternProperties = append(n.patternProperties, patternSchema{pattern: re, schema: ps})
+		}
+	}
+	if a, ok := s["additionalProperties"]; ok {
+		if n.additionalProperties, err = c.compileSub(a, path+"/additionalProperties"); err != nil {
+			return err
+		}
+	}
+	if r, ok := s["required"]; ok {
+		list, isArr := r.([]interface{})
+		if !isArr {
+			return fmt.Errorf("%s/required should be an array of property names", path)
+		}
+		for _, e := range list {
+			name, isStr := e.(string)
+			if !isStr {
+				return fmt.Errorf("%s/required should be an array of property names", path)
+			}
+			n.required = append(n.required, name)
+		}
+	}
+
+	if p, ok := s["propertyNames"]; ok {
+		if n.propertyNames, err = c.compileSub(p, path+"/propertyNames"); err != nil {
+			return err
+		}
+	}
+	if d, ok := s["dependencies"]; ok {
+		deps, isObj := d.(map[string]interface{})
+		if !isObj {
+			return fmt.Errorf("%s/dependencies should be an object", path)
+		}
+		// Dependencies are validated in order, so the first error is the same for the same document
+		properties := make([]string, 0, len(deps))
+		for property := range deps {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+		for _, property := range properties {
+			dep := schemaDependency{property: property}
+			if list, isArr := deps[property].([]interface{}); isArr {
+				for _, e := range list {
+					name, isStr := e.(string)
+					if !isStr {
+						return fmt.Errorf("%s/dependencies/%s should be an array of property names or a schema",
+							path, property)
+					}
+					dep.required = append(dep.required, name)
+				}
+			} else if dep.schema, err = c.compileSub(deps[property], path+"/dependencies/"+property); err != nil {
+				return err
+			}
+			n.dependencies = append(n.dependencies, dep)
+		}
+	}
+
+	if i, ok := s["items"]; ok {
+		if _, isArr := i.([]interface{}); isArr {
+			if n.tupleItems, err = c.compileList(i, path+"/items"); err != nil {
+				return err
+			}
+		} else if n.items, err = c.compileSub(i, path+"/items"); err != nil {
+			return err
+		}
+	}
+	if a, ok := s["additionalItems"]; ok {
+		if n.additionalItems, err = c.compileSub(a, path+"/additionalItems"); err != nil {
+			return err
+		}
+	}
+	if ct, ok := s["contains"]; ok {
+		if n.contains, err = c.compileSub(ct, path+"/contains"); err != nil {
+			return err
+		}
+	}
+	if u, ok := s["uniqueItems"]; ok {
+		if n.uniqueItems, ok = u.(bool); !ok {
+			return fmt.Errorf("%s/uniqueItems should be a boolean", path)
+		}
+	}
+
+	for k, dst := range map[string]**int{
+		"minProperties": &n.minProperties, "maxProperties": &n.maxProperties,
+		"minItems": &n.minItems, "maxItems": &n.maxItems,
+		"minLength": &n.minLength, "maxLength": &n.maxLength,
+	} {
+		if val, ok := s[k]; ok {
+			f, isNum := val.(float64)
+			if !isNum || f < 0 || f != math.Trunc(f) {
+				return fmt.Errorf("%s/%s should be a non-negative integer", path, k)
+			}
+			i := int(f)
+			*dst = &i
+		}
+	}
+	for k, dst := range map[string]**float64{
+		"minimum": &n.minimum, "maximum": &n.maximum,
+		"exclusiveMinimum": &n.exclusiveMinimum, "exclusiveMaximum": &n.exclusiveMaximum,
+		"multipleOf": &n.multipleOf,
+	} {
+		if val, ok := s[k]; ok {
+			f, isNum := val.(float64)
+			if !isNum {
+				return fmt.Errorf("%s/%s should be a number", path, k)
+			}
+			*dst = &f
+		}
+	}
+	if n.multipleOf != nil && *n.multipleOf <= 0 {
+		return fmt.Errorf("%s/multipleOf should be greater than 0", path)
+	}
+
+	if p, ok := s["pattern"]; ok {
+		pattern, isStr := p.(string)
+		if !isStr {
+			return fmt.Errorf("%s/pattern should be a string", path)
+		}
+		if n.pattern, err = regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s/pattern is not a valid regular expression: %s", path, pattern)
+		}
+	}
+
+	if a, ok := s["allOf"]; ok {
+		if n.allOf, err = c.compileList(a, path+"/allOf"); err != nil {
+			return err
+		}
+	}
+	if a, ok := s["anyOf"]; ok {
+		if n.anyOf, err = c.compileList(a, path+"/anyOf"); err != nil {
+			return err
+		}
+	}
+	if o, ok := s["oneOf"]; ok {
+		if n.oneOf, err = c.compileList(o, path+"/oneOf"); err != nil {
+			return err
+		}
+	}
+	if nt, ok := s["not"]; ok {
+		if n.not, err = c.compileSub(nt, path+"/not"); err != nil {
+			return err
+		}
+	}
+
+	// then and else are ignored without if
+	if i, ok := s["if"]; ok {
+		if n.ifSchema, err = c.compileSub(i, path+"/if"); err != nil {
+			return err
+		}
+		if t, ok := s["then"]; ok {
+			if n.thenSchema, err = c.compileSub(t, path+"/then"); err != nil {
+				return err
+			}
+		}
+		if e, ok := s["else"]; ok {
+			if n.elseSchema, err = c.compileSub(e, path+"/else"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkCycles rejects the schemas that apply to the same value again without descending into it, like a $ref to an
+// enclosing schema or the definitions referring to each other through allOf. Their validation would recurse
+// forever. The recursive schemas through the properties and the items end with the value.
+func (c *schemaCompiler) checkCycles(root *schemaNode) error {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[*schemaNode]int)
+	var visit func(n *schemaNode) error
+	visit = func(n *schemaNode) error {
+		switch state[n] {
+		case visiting:
+			return fmt.Errorf("%s refers back to itself without descending into a property or an item", c.paths[n])
+		case visited:
+			return nil
+		}
+		state[n] = visiting
+		for _, s := range n.sameValueSchemas() {
+			if err := visit(s); err != nil {
+				return err
+			}
+		}
+		state[n] = visited
+		return nil
+	}
+
+	// Every schema is checked, the nested ones are reached through the subschemas of the values
+	seen := map[*schemaNode]bool{root: true}
+	queue := []*schemaNode{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if err := visit(n); err != nil {
+			return err
+		}
+		for _, s := range append(n.sameValueSchemas(), n.nestedValueSchemas()...) {
+			if !seen[s] {
+				seen[s] = true
+				queue = append(queue, s)
+			}
+		}
+	}
+	return nil
+}
+
+// sameValueSchemas returns the subschemas that validate the value of the schema itself.
+func (n *schemaNode) sameValueSchemas() []*schemaNode {
+	var list []*schemaNode
+	for _, s := range []*schemaNode{n.ref, n.not, n.ifSchema, n.thenSchema, n.elseSchema} {
+		if s != nil {
+			list = append(list, s)
+		}
+	}
+	list = append(list, n.allOf...)
+	list = append(list, n.anyOf...)
+	list = append(list, n.oneOf...)
+	for _, d := range n.dependencies {
+		if d.schema != nil {
+			list = append(list, d.schema)
+		}
+	}
+	return list
+}
+
+// nestedValueSchemas returns the subschemas that validate the properties, the property names or the items of
+// the value of the schema.
+func (n *schemaNode) nestedValueSchemas() []*schemaNode {
+	var list []*schemaNode
+	for _, s := range []*schemaNode{n.additionalProperties, n.propertyNames, n.items, n.additionalItems, n.contains} {
+		if s != nil {
+			list = append(list, s)
+		}
+	}
+	names := make([]string, 0, len(n.properties))
+	for name := range n.properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		list = append(list, n.properties[name])
+	}
+	for _, p := range n.patternProperties {
+		list = append(list, p.schema)
+	}
+	return append(list, n.tupleItems...)
+}
+
+func (n *schemaNode) validate(v interface{}, ptr string) *Error {
+	if n.ref != nil {
+		return n.ref.validate(v, ptr)
+	}
+	if n.always != nil {
+		if *n.always {
+			return nil
+		}
+		return &Error{Pointer: ptr, Message: "value is not allowed"}
+	}
+
+	if len(n.types) > 0 && !n.matchesType(v) {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("type should be %s", strings.Join(n.types, " or "))}
+	}
+	if n.enum != nil {
+		found := false
+		for _, e := range n.enum {
+			if jsonEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &Error{Pointer: ptr, Message: "value should be one of the enum values"}
+		}
+	}
+	if n.hasConst && !jsonEqual(n.constVal, v) {
+		return &Error{Pointer: ptr, Message: "value should be the const value"}
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if err := n.validateObject(t, ptr); err != nil {
+			return err
+		}
+	case []interface{}:
+		if err := n.validateArray(t, ptr); err != nil {
+			return err
+		}
+	case float64:
+		if err := n.validateNumber(t, ptr); err != nil {
+			return err
+		}
+	case string:
+		if err := n.validateString(t, ptr); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range n.allOf {
+		if err := s.validate(v, ptr); err != nil {
+			return err
+		}
+	}
+	if len(n.anyOf) > 0 {
+		matched := false
+		for _, s := range n.anyOf {
+			if s.validate(v, ptr) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &Error{Pointer: ptr, Message: "value should match at least one schema of anyOf"}
+		}
+	}
+	if len(n.oneOf) > 0 {
+		matched := 0
+		for _, s := range n.oneOf {
+			if s.validate(v, ptr) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return &Error{Pointer: ptr, Message: "value should match exactly one schema of oneOf"}
+		}
+	}
+	if n.not != nil && n.not.validate(v, ptr) == nil {
+		return &Error{Pointer: ptr, Message: "value should not match the schema of not"}
+	}
+	if n.ifSchema != nil {
+		branch := n.elseSchema
+		if n.ifSchema.validate(v, ptr) == nil {
+			branch = n.thenSchema
+		}
+		if branch != nil {
+			return branch.validate(v, ptr)
+		}
+	}
+	return nil
+}
+
+func (n *schemaNode) matchesType(v interface{}) bool {
+	for _, t := range n.types {
+		switch t {
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "object":
+			if _, ok := v.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]interface{}); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := v.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *schemaNode) validateObject(o map[string]interface{}, ptr string) *Error {
+	for _, name := range n.required {
+		if _, ok := o[name]; !ok {
+			return &Error{Pointer: ptr, Message: fmt.Sprintf("required property %q is missing", name)}
+		}
+	}
+	if n.minProperties != nil && len(o) < *n.minProperties {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should have at least %d properties", *n.minProperties)}
+	}
+	if n.maxProperties != nil && len(o) > *n.maxProperties {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should have at most %d properties", *n.maxProperties)}
+	}
+	for _, dep := range n.dependencies {
+		if _, ok := o[dep.property]; !ok {
+			continue
+		}
+		for _, name := range dep.required {
+			if _, ok := o[name]; !ok {
+				return &Error{Pointer: ptr,
+					Message: fmt.Sprintf("property %q is required by the property %q", name, dep.property)}
+			}
+		}
+		if dep.schema != nil {
+			if err := dep.schema.validate(o, ptr); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Properties are validated in order, so the first error is the same for the same document
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		propPtr := ptr + "/" + escapePointer(name)
+		if n.propertyNames != nil {
+			if err := n.propertyNames.validate(name, propPtr); err != nil {
+				err.Message = "property name is not valid: " + err.Message
+				return err
+			}
+		}
+		matched := false
+		if s, ok := n.properties[name]; ok {
+			matched = true
+			if err := s.validate(o[name], propPtr); err != nil {
+				return err
+			}
+		}
+		for _, ps := range n.patternProperties {
+			if ps.pattern.MatchString(name) {
+				matched = true
+				if err := ps.schema.validate(o[name], propPtr); err != nil {
+					return err
+				}
+			}
+		}
+		if !matched && n.additionalProperties != nil {
+			if err := n.additionalProperties.validate(o[name], propPtr); err != nil {
+				if n.additionalProperties.always != nil {
+					err.Message = "additional property is not allowed"
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (n *schemaNode) validateArray(a []interface{}, ptr string) *Error {
+	if n.minItems != nil && len(a) < *n.minItems {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should have at least %d items", *n.minItems)}
+	}
+	if n.maxItems != nil && len(a) > *n.maxItems {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should have at most %d items", *n.maxItems)}
+	}
+	if n.uniqueItems {
+		for i := range a {
+			for j := i + 1; j < len(a); j++ {
+				if jsonEqual(a[i], a[j]) {
+					return &Error{Pointer: ptr, Message: "items should be unique"}
+				}
+			}
+		}
+	}
+
+	for i, item := range a {
+		s := n.items
+		if n.tupleItems != nil {
+			// additionalItems applies only to the items after a tuple
+			s = n.additionalItems
+			if i < len(n.tupleItems) {
+				s = n.tupleItems[i]
+			}
+		}
+		if s == nil {
+			break
+		}
+		if err := s.validate(item, ptr+"/"+strconv.Itoa(i)); err != nil {
+			if s == n.additionalItems && s.always != nil {
+				err.Message = "additional item is not allowed"
+			}
+			return err
+		}
+	}
+	if n.contains != nil {
+		found := false
+		for _, item := range a {
+			if n.contains.validate(item, ptr) == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &Error{Pointer: ptr, Message: "should contain an item matching the schema of contains"}
+		}
+	}
+	return nil
+}
+
+func (n *schemaNode) validateNumber(f float64, ptr string) *Error {
+	if n.minimum != nil && f < *n.minimum {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should be >= %g", *n.minimum)}
+	}
+	if n.maximum != nil && f > *n.maximum {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should be <= %g", *n.maximum)}
+	}
+	if n.exclusiveMinimum != nil && f <= *n.exclusiveMinimum {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should be > %g", *n.exclusiveMinimum)}
+	}
+	if n.exclusiveMaximum != nil && f >= *n.exclusiveMaximum {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should be < %g", *n.exclusiveMaximum)}
+	}
+	if n.multipleOf != nil {
+		if q := f / *n.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			return &Error{Pointer: ptr, Message: fmt.Sprintf("should be a multiple of %g", *n.multipleOf)}
+		}
+	}
+	return nil
+}
+
+func (n *schemaNode) validateString(s string, ptr string) *Error {
+	length := utf8.RuneCountInString(s)
+	if n.minLength != nil && length < *n.minLength {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("length should be >= %d", *n.minLength)}
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("length should be <= %d", *n.maxLength)}
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		return &Error{Pointer: ptr, Message: fmt.Sprintf("should match the pattern %s", n.pattern)}
+	}
+	return nil
+}
+
+// jsonEqual compares the decoded JSON values, numbers are float64 for any representation.
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
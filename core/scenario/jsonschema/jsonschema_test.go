@@ -0,0 +1,250 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package jsonschema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testUserSchema = `{
+	"type": "object",
+	"required": ["id", "name", "tags"],
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 1, "maxLength": 10, "pattern": "^[a-z]+$"},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+		"address": {"$ref": "#/definitions/address"},
+		"score": {"type": ["number", "null"], "exclusiveMaximum": 100, "multipleOf": 0.5},
+		"friends": {"type": "array", "items": {"$ref": "#"}}
+	},
+	"additionalProperties": false,
+	"definitions": {
+		"address": {
+			"type": "object",
+			"properties": {"zip/code": {"type": "string"}},
+			"oneOf": [{"required": ["city"]}, {"required": ["country"]}]
+		}
+	}
+}`
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    string
+		shouldErr bool
+	}{
+		{"Valid", testUserSchema, false},
+		{"Boolean", `true`, false},
+		{"Empty", `{}`, false},
+		{"UnknownKeyword", `{"format": "email", "title": "x"}`, false},
+		{"InvalidJson", `{"type": `, true},
+		{"NotAnObject", `"string"`, true},
+		{"UnknownType", `{"type": "text"}`, true},
+		{"InvalidTypeList", `{"type": [1]}`, true},
+		{"InvalidPattern", `{"pattern": "("}`, true},
+		{"InvalidPatternProperties", `{"patternProperties": {"(": {}}}`, true},
+		{"NegativeMinLength", `{"minLength": -1}`, true},
+		{"FractionMaxItems", `{"maxItems": 1.5}`, true},
+		{"StringMinimum", `{"minimum": "1"}`, true},
+		{"ZeroMultipleOf", `{"multipleOf": 0}`, true},
+		{"InvalidRequired", `{"required": [1]}`, true},
+		{"EmptyAnyOf", `{"anyOf": []}`, true},
+		{"InvalidSubschema", `{"properties": {"a": 1}}`, true},
+		{"UnresolvedRef", `{"$ref": "#/definitions/missing"}`, true},
+		{"RemoteRef", `{"$ref": "http://example.com/schema.json"}`, true},
+		{"InvalidContains", `{"contains": 1}`, true},
+		{"InvalidAdditionalItems", `{"items": [{}], "additionalItems": "x"}`, true},
+		{"InvalidPropertyNames", `{"propertyNames": []}`, true},
+		{"InvalidDependencies", `{"dependencies": []}`, true},
+		{"InvalidDependency", `{"dependencies": {"a": [1]}}`, true},
+		{"InvalidIf", `{"if": 1}`, true},
+		{"InvalidThen", `{"if": {}, "then": 1}`, true},
+		{"RecursiveProperty", `{"properties": {"child": {"$ref": "#"}}}`, false},
+		{"RecursiveItems", `{"definitions": {"a": {"items": {"$ref": "#/definitions/a"}}}, "$ref": "#/definitions/a"}`,
+			false},
+		{"SelfRef", `{"$ref": "#"}`, true},
+		{"AllOfSelfRef", `{"allOf": [{"$ref": "#"}]}`, true},
+		{"AnyOfSelfRef", `{"anyOf": [{"type": "null"}, {"$ref": "#"}]}`, true},
+		{"OneOfSelfRef", `{"oneOf": [{"$ref": "#"}]}`, true},
+		{"NotSelfRef", `{"not": {"$ref": "#"}}`, true},
+		{"MutualRefs", `{"definitions": {"a": {"$ref": "#/definitions/b"}, ` +
+			`"b": {"allOf": [{"$ref": "#/definitions/a"}]}}, "$ref": "#/definitions/a"}`, true},
+		{"NestedSelfRef", `{"properties": {"a": {"$ref": "#/properties/a"}}}`, true},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			_, err := Compile([]byte(test.schema))
+			if test.shouldErr && err == nil {
+				t.Errorf("Should be errored")
+			}
+			if !test.shouldErr && err != nil {
+				t.Errorf("Error occurred: %v", err)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestCompileRefCycle(t *testing.T) {
+	_, err := Compile([]byte(`{"definitions": {"a": {"$ref": "#/definitions/b"}, "b": {"$ref": "#/definitions/a"}}, ` +
+		`"properties": {"x": {"$ref": "#/definitions/a"}}}`))
+	expected := "#/definitions/a refers back to itself without descending into a property or an item"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, Found %v", expected, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile([]byte(testUserSchema))
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		doc      string
+		expected string
+	}{
+		{"Valid", `{"id": 1, "name": "ada", "tags": ["a"], "role": "admin", "score": 99.5}`, ""},
+		{"ValidNull", `{"id": 1, "name": "ada", "tags": [], "score": null}`, ""},
+		{"ValidRef", `{"id": 1, "name": "ada", "tags": [], "address": {"city": "x", "zip/code": "1"}}`, ""},
+		{"ValidRecursive", `{"id": 1, "name": "ada", "tags": [], "friends": [{"id": 2, "name": "bob", "tags": []}]}`, ""},
+		{"NotJson", `<html>`, "(root): body is not a valid json"},
+		{"RootType", `[]`, "(root): type should be object"},
+		{"Required", `{"id": 1, "name": "ada"}`, `(root): required property "tags" is missing`},
+		{"Integer", `{"id": 1.5, "name": "ada", "tags": []}`, "/id: type should be integer"},
+		{"Minimum", `{"id": 0, "name": "ada", "tags": []}`, "/id: should be >= 1"},
+		{"MinLength", `{"id": 1, "name": "", "tags": []}`, "/name: length should be >= 1"},
+		{"MaxLength", `{"id": 1, "name": "abcdefghijk", "tags": []}`, "/name: length should be <= 10"},
+		{"Pattern", `{"id": 1, "name": "Ada", "tags": []}`, "/name: should match the pattern ^[a-z]+$"},
+		{"Enum", `{"id": 1, "name": "ada", "tags": [], "role": "root"}`, "/role: value should be one of the enum values"},
+		{"ItemType", `{"id": 1, "name": "ada", "tags": ["a", 2]}`, "/tags/1: type should be string"},
+		{"MaxItems", `{"id": 1, "name": "ada", "tags": ["a", "b", "c"]}`, "/tags: should have at most 2 items"},
+		{"UniqueItems", `{"id": 1, "name": "ada", "tags": ["a", "a"]}`, "/tags: items should be unique"},
+		{"ExclusiveMaximum", `{"id": 1, "name": "ada", "tags": [], "score": 100}`, "/score: should be < 100"},
+		{"MultipleOf", `{"id": 1, "name": "ada", "tags": [], "score": 0.3}`, "/score: should be a multiple of 0.5"},
+		{"NullableType", `{"id": 1, "name": "ada", "tags": [], "score": "1"}`, "/score: type should be number or null"},
+		{"AdditionalProperty", `{"id": 1, "name": "ada", "tags": [], "extra": 1}`, "/extra: additional property is not allowed"},
+		{"OneOfNone", `{"id": 1, "name": "ada", "tags": [], "address": {}}`,
+			"/address: value should match exactly one schema of oneOf"},
+		{"OneOfBoth", `{"id": 1, "name": "ada", "tags": [], "address": {"city": "x", "country": "y"}}`,
+			"/address: value should match exactly one schema of oneOf"},
+		{"EscapedPointer", `{"id": 1, "name": "ada", "tags": [], "address": {"city": "x", "zip/code": 1}}`,
+			"/address/zip~1code: type should be string"},
+		{"RecursiveRef", `{"id": 1, "name": "ada", "tags": [], "friends": [{"id": 2, "name": "bob"}]}`,
+			`/friends/0: required property "tags" is missing`},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			err := schema.ValidateJSON([]byte(test.doc))
+			found := ""
+			if err != nil {
+				found = err.Error()
+			}
+			if found != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, found)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		doc      string
+		expected string
+	}{
+		{"AllOf", `{"allOf": [{"type": "number"}, {"minimum": 5}]}`, `3`, "(root): should be >= 5"},
+		{"AnyOf", `{"anyOf": [{"type": "string"}, {"type": "boolean"}]}`, `1`,
+			"(root): value should match at least one schema of anyOf"},
+		{"AnyOfMatched", `{"anyOf": [{"type": "string"}, {"type": "boolean"}]}`, `true`, ""},
+		{"Not", `{"not": {"type": "null"}}`, `null`, "(root): value should not match the schema of not"},
+		{"Const", `{"const": {"a": [1, 2]}}`, `{"a": [1, 2]}`, ""},
+		{"ConstMismatch", `{"const": 1}`, `1.5`, "(root): value should be the const value"},
+		{"FalseSchema", `false`, `{}`, "(root): value is not allowed"},
+		{"Tuple", `{"items": [{"type": "string"}, {"type": "number"}]}`, `["a", "b", 3]`, "/1: type should be number"},
+		{"PatternProperties", `{"patternProperties": {"^x-": {"type": "string"}}}`, `{"x-id": 1, "y": 1}`,
+			"/x-id: type should be string"},
+		{"MinProperties", `{"minProperties": 1}`, `{}`, "(root): should have at least 1 properties"},
+		{"Contains", `{"contains": {"type": "number"}}`, `["a", 1]`, ""},
+		{"ContainsNone", `{"contains": {"type": "number"}}`, `["a", "b"]`,
+			"(root): should contain an item matching the schema of contains"},
+		{"ContainsEmpty", `{"contains": {}}`, `[]`, "(root): should contain an item matching the schema of contains"},
+		{"AdditionalItems", `{"items": [{"type": "string"}], "additionalItems": {"type": "number"}}`, `["a", 1, "b"]`,
+			"/2: type should be number"},
+		{"AdditionalItemsFalse", `{"items": [{"type": "string"}], "additionalItems": false}`, `["a", "b"]`,
+			"/1: additional item is not allowed"},
+		{"AdditionalItemsWithoutTuple", `{"items": {"type": "string"}, "additionalItems": false}`, `["a", "b"]`, ""},
+		{"PropertyNames", `{"propertyNames": {"pattern": "^[a-z]+$"}}`, `{"id": 1, "Name": 1}`,
+			"/Name: property name is not valid: should match the pattern ^[a-z]+$"},
+		{"DependencyRequired", `{"dependencies": {"card": ["billing"]}}`, `{"card": 1}`,
+			`(root): property "billing" is required by the property "card"`},
+		{"DependencyMissingProperty", `{"dependencies": {"card": ["billing"]}}`, `{"name": 1}`, ""},
+		{"DependencySchema", `{"dependencies": {"card": {"required": ["billing"]}}}`, `{"card": 1}`,
+			`(root): required property "billing" is missing`},
+		{"IfThen", `{"if": {"required": ["card"]}, "then": {"required": ["billing"]}, "else": {"maxProperties": 0}}`,
+			`{"card": 1}`, `(root): required property "billing" is missing`},
+		{"IfElse", `{"if": {"required": ["card"]}, "then": {"required": ["billing"]}, "else": {"maxProperties": 0}}`,
+			`{"name": 1}`, "(root): should have at most 0 properties"},
+		{"ThenWithoutIf", `{"then": false}`, `{}`, ""},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			schema, err := Compile([]byte(test.schema))
+			if err != nil {
+				t.Fatalf("Error occurred: %v", err)
+			}
+			found := ""
+			if err := schema.ValidateJSON([]byte(test.doc)); err != nil {
+				found = err.Error()
+			}
+			if found != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, found)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	valid, invalid := filepath.Join(dir, "valid.json"), filepath.Join(dir, "invalid.json")
+	os.WriteFile(valid, []byte(testUserSchema), 0600)
+	os.WriteFile(invalid, []byte(`{"type": "text"}`), 0600)
+
+	if _, err := Load(valid); err != nil {
+		t.Errorf("Error occurred: %v", err)
+	}
+	if _, err := Load(invalid); err == nil {
+		t.Errorf("Invalid schema should be errored")
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Missing schema file should be errored")
+	}
+}
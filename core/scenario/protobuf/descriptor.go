@@ -0,0 +1,434 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// reader reads the values of the protobuf wire format.
+type reader struct {
+	b []byte
+}
+
+func (r *reader) done() bool {
+	return len(r.b) == 0
+}
+
+func (r *reader) varint() (uint64, error) {
+	var v uint64
+	for i := 0; i < 10; i++ {
+		if i >= len(r.b) {
+			return 0, errTruncated
+		}
+		c := r.b[i]
+		v |= uint64(c&0x7f) << (7 * i)
+		if c < 0x80 {
+			r.b = r.b[i+1:]
+			return v, nil
+		}
+	}
+	return 0, errors.New("invalid varint in protobuf message")
+}
+
+func (r *reader) tag() (int32, int, error) {
+	v, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	num, wt := v>>3, int(v&7)
+	if num < 1 || num > 1<<29-1 {
+		return 0, 0, fmt.Errorf("invalid field number %d in protobuf message", num)
+	}
+	return int32(num), wt, nil
+}
+
+func (r *reader) fixed32() (uint32, error) {
+	if len(r.b) < 4 {
+		return 0, errTruncated
+	}
+	v := uint32(r.b[0]) | uint32(r.b[1])<<8 | uint32(r.b[2])<<16 | uint32(r.b[3])<<24
+	r.b = r.b[4:]
+	return v, nil
+}
+
+func (r *reader) fixed64() (uint64, error) {
+	lo, err := r.fixed32()
+	if err != nil {
+		return 0, err
+	}
+	hi, err := r.fixed32()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(hi)<<32 | uint64(lo), nil
+}
+
+func (r *reader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.b)) {
+		return nil, errTruncated
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b, nil
+}
+
+// skip skips the value of an unknown field. Groups are deprecated and not supported.
+func (r *reader) skip(wireType int) (err error) {
+	switch wireType {
+	case wireVarint:
+		_, err = r.varint()
+	case wireFixed64:
+		_, err = r.fixed64()
+	case wireBytes:
+		_, err = r.bytes()
+	case wireFixed32:
+		_, err = r.fixed32()
+	default:
+		err = fmt.Errorf("unsupported wire type %d in protobuf message", wireType)
+	}
+	return
+}
+
+// Field numbers of the messages of descriptor.proto that describe the message types
+const (
+	fileSetFile = 1
+
+	filePackage     = 2
+	fileMessageType = 4
+	fileEnumType    = 5
+	fileSyntax      = 12
+
+	messageName       = 1
+	messageField      = 2
+	messageNestedType = 3
+	messageEnumType   = 4
+	messageOptions    = 7
+
+	messageOptionsMapEntry = 7
+
+	fieldName     = 1
+	fieldNumber   = 3
+	fieldLabel    = 4
+	fieldType     = 5
+	fieldTypeName = 6
+	fieldOptions  = 8
+	fieldJSONName = 10
+
+	fieldOptionsPacked = 2
+
+	enumName  = 1
+	enumValue = 2
+
+	enumValueName   = 1
+	enumValueNumber = 2
+
+	labelRepeated = 3
+)
+
+// addDescriptorSet adds the message types of a FileDescriptorSet.
+func (f *Files) addDescriptorSet(data []byte) error {
+	r := &reader{b: data}
+	for !r.done() {
+		num, wt, err := r.tag()
+		if err != nil {
+			return err
+		}
+		if num != fileSetFile || wt != wireBytes {
+			if err = r.skip(wt); err != nil {
+				return err
+			}
+			continue
+		}
+		file, err := r.bytes()
+		if err != nil {
+			return err
+		}
+		if err = f.addFileDescriptor(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Files) addFileDescriptor(data []byte) error {
+	var pkg, syntax string
+	var messages, enums [][]byte
+	err := eachField(data, func(num int32, r *reader, wt int) (err error) {
+		switch num {
+		case filePackage:
+			pkg, err = readString(r, wt)
+		case fileSyntax:
+			syntax, err = readString(r, wt)
+		case fileMessageType:
+			messages, err = appendBytes(messages, r, wt)
+		case fileEnumType:
+			enums, err = appendBytes(enums, r, wt)
+		default:
+			err = r.skip(wt)
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range enums {
+		if err = f.addEnumDescriptor(e, pkg); err != nil {
+			return err
+		}
+	}
+	for _, m := range messages {
+		if err = f.addMessageDescriptor(m, pkg, syntax == "proto3"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Files) addMessageDescriptor(data []byte, scope string, proto3 bool) error {
+	m := &Message{}
+	var fields, nested, enums [][]byte
+	err := eachField(data, func(num int32, r *reader, wt int) (err error) {
+		switch num {
+		case messageName:
+			m.name, err = readString(r, wt)
+		case messageField:
+			fields, err = appendBytes(fields, r, wt)
+		case messageNestedType:
+			nested, err = appendBytes(nested, r, wt)
+		case messageEnumType:
+			enums, err = appendBytes(enums, r, wt)
+		case messageOptions:
+			var opts []byte
+			if opts, err = readBytes(r, wt); err == nil {
+				err = eachField(opts, func(num int32, r *reader, wt int) error {
+					if num == messageOptionsMapEntry && wt == wireVarint {
+						v, err := r.varint()
+						m.mapEntry = v != 0
+						return err
+					}
+					return r.skip(wt)
+				})
+			}
+		default:
+			err = r.skip(wt)
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if m.name == "" {
+		return fmt.Errorf("message without a name in %s", scope)
+	}
+	m.name = joinPath(scope, m.name)
+
+	for _, fd := range fields {
+		field, err := parseFieldDescriptor(fd, m.name, proto3)
+		if err != nil {
+			return err
+		}
+		m.fields = append(m.fields, field)
+	}
+	if err = f.addMessage(m); err != nil {
+		return err
+	}
+	for _, e := range enums {
+		if err = f.addEnumDescriptor(e, m.name); err != nil {
+			return err
+		}
+	}
+	for _, n := range nested {
+		if err = f.addMessageDescriptor(n, m.name, proto3); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseFieldDescriptor(data []byte, scope string, proto3 bool) (*field, error) {
+	fd := &field{scope: scope, proto3: proto3}
+	err := eachField(data, func(num int32, r *reader, wt int) (err error) {
+		var v uint64
+		switch num {
+		case fieldName:
+			fd.name, err = readString(r, wt)
+		case fieldJSONName:
+			fd.jsonName, err = readString(r, wt)
+		case fieldTypeName:
+			fd.typeName, err = readString(r, wt)
+		case fieldNumber:
+			v, err = readVarint(r, wt)
+			fd.number = int32(v)
+		case fieldLabel:
+			v, err = readVarint(r, wt)
+			fd.repeated = v == labelRepeated
+		case fieldType:
+			v, err = readVarint(r, wt)
+			fd.kind = kind(v)
+		case fieldOptions:
+			var opts []byte
+			if opts, err = readBytes(r, wt); err == nil {
+				err = eachField(opts, func(num int32, r *reader, wt int) error {
+					if num == fieldOptionsPacked && wt == wireVarint {
+						v, err := r.varint()
+						packed := v != 0
+						fd.packedOption = &packed
+						return err
+					}
+					return r.skip(wt)
+				})
+			}
+		default:
+			err = r.skip(wt)
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fd.kind == kindGroup {
+		return nil, fmt.Errorf("group field %s.%s is not supported", scope, fd.name)
+	}
+	if fd.jsonName == "" {
+		fd.jsonName = jsonCamelCase(fd.name)
+	}
+	return fd, nil
+}
+
+func (f *Files) addEnumDescriptor(data []byte, scope string) error {
+	e := &enum{numbers: make(map[string]int32), names: make(map[int32]string)}
+	var values [][]byte
+	err := eachField(data, func(num int32, r *reader, wt int) (err error) {
+		switch num {
+		case enumName:
+			e.name, err = readString(r, wt)
+		case enumValue:
+			values, err = appendBytes(values, r, wt)
+		default:
+			err = r.skip(wt)
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+	e.name = joinPath(scope, e.name)
+	for _, val := range values {
+		var name string
+		var number int32
+		err = eachField(val, func(num int32, r *reader, wt int) (err error) {
+			var v uint64
+			switch num {
+			case enumValueName:
+				name, err = readString(r, wt)
+			case enumValueNumber:
+				v, err = readVarint(r, wt)
+				number = int32(v)
+			default:
+				err = r.skip(wt)
+			}
+			return
+		})
+		if err != nil {
+			return err
+		}
+		e.addValue(name, number)
+	}
+	return f.addEnum(e)
+}
+
+// addValue adds a value of the enum, the first name of a number is its JSON name if the number has aliases.
+func (e *enum) addValue(name string, number int32) {
+	e.numbers[name] = number
+	if _, ok := e.names[number]; !ok {
+		e.names[number] = name
+	}
+}
+
+// eachField calls fn for the fields of the message, fn reads or skips the value of each field.
+func eachField(data []byte, fn func(num int32, r *reader, wireType int) error) error {
+	r := &reader{b: data}
+	for !r.done() {
+		num, wt, err := r.tag()
+		if err != nil {
+			return err
+		}
+		if err = fn(num, r, wt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readBytes(r *reader, wireType int) ([]byte, error) {
+	if wireType != wireBytes {
+		return nil, fmt.Errorf("unexpected wire type %d in descriptor", wireType)
+	}
+	return r.bytes()
+}
+
+func readString(r *reader, wireType int) (string, error) {
+	b, err := readBytes(r, wireType)
+	return string(b), err
+}
+
+func readVarint(r *reader, wireType int) (uint64, error) {
+	if wireType != wireVarint {
+		return 0, fmt.Errorf("unexpected wire type %d in descriptor", wireType)
+	}
+	return r.varint()
+}
+
+func appendBytes(list [][]byte, r *reader, wireType int) ([][]byte, error) {
+	b, err := readBytes(r, wireType)
+	if err != nil {
+		return nil, err
+	}
+	return append(list, b), nil
+}
+
+// jsonCamelCase returns the default JSON name of a field like protoc, the underscores are dropped and the letters
+// after them are capitalized.
+func jsonCamelCase(name string) string {
+	b := make([]byte, 0, len(name))
+	upper := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b = append(b, c)
+	}
+	return string(b)
+}
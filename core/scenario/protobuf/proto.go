@@ -0,0 +1,684 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package protobuf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenSymbol
+	tokenEOF
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+// protoParser parses a .proto file. Only the definitions of the messages and the enums are kept, the services,
+// the extensions and the options except packed and json_name are skipped.
+type protoParser struct {
+	files  *Files
+	path   string
+	tokens []token
+	pos    int
+	pkg    string
+	proto3 bool
+}
+
+// parseProtoFile parses the .proto file and its imports. Imports are resolved relative to the directory of the
+// root file, then relative to the importing file.
+func (f *Files) parseProtoFile(path string) error {
+	return f.parseProto(path, filepath.Dir(path), make(map[string]bool))
+}
+
+func (f *Files) parseProto(path, root string, parsed map[string]bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if parsed[abs] {
+		return nil
+	}
+	parsed[abs] = true
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("proto file can not be read: %v", err)
+	}
+	tokens, err := tokenize(string(data))
+	if err != nil {
+		return fmt.Errorf("proto file %s is not valid: %v", path, err)
+	}
+	p := &protoParser{files: f, path: path, tokens: tokens}
+	imports, err := p.parseFile()
+	if err != nil {
+		return fmt.Errorf("proto file %s is not valid: %v", path, err)
+	}
+
+	for _, imp := range imports {
+		candidates := []string{filepath.Join(root, imp), filepath.Join(filepath.Dir(path), imp)}
+		found := ""
+		for _, c := range candidates {
+			if _, err := os.Stat(c); err == nil {
+				found = c
+				break
+			}
+		}
+		if found == "" {
+			return fmt.Errorf("import %s of proto file %s not found", imp, path)
+		}
+		if err = f.parseProto(found, root, parsed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case isLetter(c) || c == '.' && i+1 < len(src) && isLetter(src[i+1]):
+			// Fully qualified names have a leading dot
+			start := i
+			i++
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, src[start:i], line})
+		case isDigit(c) || (c == '-' || c == '.') && i+1 < len(src) && isDigit(src[i+1]):
+			start := i
+			i++
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i]) || src[i] == '.' ||
+				(src[i] == '-' || src[i] == '+') && (src[i-1] == 'e' || src[i-1] == 'E')) {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, src[start:i], line})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' {
+					i++
+				}
+				if i < len(src) && src[i] == '\n' {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			i++
+			raw := src[start:i]
+			if c == '\'' {
+				raw = `"` + strings.ReplaceAll(raw[1:len(raw)-1], `"`, `\"`) + `"`
+			}
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", line, src[start:i])
+			}
+			tokens = append(tokens, token{tokenString, s, line})
+		default:
+			tokens = append(tokens, token{tokenSymbol, string(c), line})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, line: line}), nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *protoParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *protoParser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *protoParser) expect(value string) error {
+	t := p.next()
+	if t.kind == tokenString || t.kind == tokenEOF || t.value != value {
+		return p.errorf(t, "expected %s, found %s", value, describe(t))
+	}
+	return nil
+}
+
+func (p *protoParser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return "", p.errorf(t, "expected a name, found %s", describe(t))
+	}
+	return t.value, nil
+}
+
+func (p *protoParser) str() (string, error) {
+	t := p.next()
+	if t.kind != tokenString {
+		return "", p.errorf(t, "expected a string, found %s", describe(t))
+	}
+	// Adjacent strings are concatenated
+	s := t.value
+	for p.peek().kind == tokenString {
+		s += p.next().value
+	}
+	return s, nil
+}
+
+func (p *protoParser) number() (int64, error) {
+	t := p.next()
+	n, err := strconv.ParseInt(t.value, 0, 32)
+	if t.kind != tokenNumber || err != nil {
+		return 0, p.errorf(t, "expected an integer, found %s", describe(t))
+	}
+	return n, nil
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenString:
+		return strconv.Quote(t.value)
+	}
+	return t.value
+}
+
+// skipStatement skips the tokens until the end of the statement, the blocks of the statement are skipped with it.
+func (p *protoParser) skipStatement() error {
+	depth := 0
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			return p.errorf(t, "unexpected end of file")
+		case t.kind != tokenSymbol:
+		case t.value == "{":
+			depth++
+		case t.value == "}":
+			depth--
+			if depth == 0 {
+				// Blocks like the services are not followed by a semicolon
+				if p.peek().value == ";" && p.peek().kind == tokenSymbol {
+					p.next()
+				}
+				return nil
+			}
+			if depth < 0 {
+				return p.errorf(t, "unexpected }")
+			}
+		case t.value == ";" && depth == 0:
+			return nil
+		}
+	}
+}
+
+func (p *protoParser) parseFile() (imports []string, err error) {
+	for {
+		t := p.next()
+		if t.kind == tokenEOF {
+			return imports, nil
+		}
+		if t.kind == tokenSymbol && t.value == ";" {
+			continue
+		}
+		if t.kind != tokenIdent {
+			return nil, p.errorf(t, "unexpected %s", describe(t))
+		}
+		switch t.value {
+		case "syntax":
+			if err = p.expect("="); err != nil {
+				return
+			}
+			var syntax string
+			if syntax, err = p.str(); err != nil {
+				return
+			}
+			if syntax != "proto2" && syntax != "proto3" {
+				return nil, p.errorf(t, "unsupported syntax %s", syntax)
+			}
+			p.proto3 = syntax == "proto3"
+			err = p.expect(";")
+		case "edition":
+			return nil, p.errorf(t, "editions are not supported")
+		case "package":
+			if p.pkg, err = p.ident(); err != nil {
+				return
+			}
+			err = p.expect(";")
+		case "import":
+			if v := p.peek().value; p.peek().kind == tokenIdent && (v == "public" || v == "weak") {
+				p.next()
+			}
+			var path string
+			if path, err = p.str(); err != nil {
+				return
+			}
+			imports = append(imports, path)
+			err = p.expect(";")
+		case "message":
+			err = p.parseMessage(p.pkg)
+		case "enum":
+			err = p.parseEnum(p.pkg)
+		case "option", "service", "extend":
+			err = p.skipStatement()
+		default:
+			return nil, p.errorf(t, "unexpected %s", t.value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *protoParser) parseMessage(scope string) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	m := &Message{name: joinPath(scope, name)}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	if err = p.parseMessageBody(m); err != nil {
+		return err
+	}
+	return p.files.addMessage(m)
+}
+
+func (p *protoParser) parseMessageBody(m *Message) error {
+	for {
+		t := p.peek()
+		if t.kind == tokenSymbol && t.value == "}" {
+			p.next()
+			return nil
+		}
+		if t.kind == tokenSymbol && t.value == ";" {
+			p.next()
+			continue
+		}
+		if t.kind != tokenIdent {
+			return p.errorf(t, "unexpected %s", describe(t))
+		}
+
+		var err error
+		switch t.value {
+		case "message":
+			p.next()
+			err = p.parseMessage(m.name)
+		case "enum":
+			p.next()
+			err = p.parseEnum(m.name)
+		case "oneof":
+			p.next()
+			err = p.parseOneof(m)
+		case "option", "reserved", "extensions", "extend":
+			p.next()
+			err = p.skipStatement()
+		case "map":
+			if p.tokens[p.pos+1].value != "<" {
+				err = p.parseField(m)
+				break
+			}
+			p.next()
+			err = p.parseMap(m)
+		default:
+			err = p.parseField(m)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *protoParser) parseOneof(m *Message) error {
+	if _, err := p.ident(); err != nil {
+		return err
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenSymbol && t.value == "}":
+			p.next()
+			return nil
+		case t.kind == tokenSymbol && t.value == ";":
+			p.next()
+		case t.kind == tokenIdent && t.value == "option":
+			p.next()
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		default:
+			if err := p.parseField(m); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseField parses a field like "repeated string tags = 3 [packed = false];".
+func (p *protoParser) parseField(m *Message) error {
+	fd := &field{scope: m.name, proto3: p.proto3}
+	typ, err := p.ident()
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case "repeated":
+		fd.repeated = true
+		fallthrough
+	case "optional", "required":
+		if typ, err = p.ident(); err != nil {
+			return err
+		}
+	}
+	if typ == "group" {
+		return p.errorf(p.peek(), "groups are not supported")
+	}
+	setType(fd, typ)
+	if err = p.parseFieldRest(fd); err != nil {
+		return err
+	}
+	m.fields = append(m.fields, fd)
+	return nil
+}
+
+// parseMap parses a map field like "map<string, int32> counts = 4;" and its synthesized entry message.
+func (p *protoParser) parseMap(m *Message) error {
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+	keyType, err := p.ident()
+	if err != nil {
+		return err
+	}
+	k, ok := scalarKinds[keyType]
+	if !ok || k == kindDouble || k == kindFloat || k == kindBytes {
+		return p.errorf(p.peek(), "invalid map key type %s", keyType)
+	}
+	key := &field{name: "key", jsonName: "key", number: 1, kind: k}
+	if err = p.expect(","); err != nil {
+		return err
+	}
+	valType, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if err = p.expect(">"); err != nil {
+		return err
+	}
+
+	fd := &field{scope: m.name, repeated: true}
+	if err = p.parseFieldRest(fd); err != nil {
+		return err
+	}
+	entry := &Message{name: m.name + "." + entryName(fd.name), mapEntry: true}
+	val := &field{name: "value", jsonName: "value", number: 2, scope: entry.name, proto3: p.proto3}
+	setType(val, valType)
+	entry.fields = []*field{key, val}
+	if err = p.files.addMessage(entry); err != nil {
+		return err
+	}
+	fd.typeName = "." + entry.name
+	m.fields = append(m.fields, fd)
+	return nil
+}
+
+// entryName is the name of the entry message of a map field like protoc, "item_counts" is "ItemCountsEntry".
+func entryName(field string) string {
+	name := jsonCamelCase(field)
+	if name != "" && name[0] >= 'a' && name[0] <= 'z' {
+		name = string(name[0]-('a'-'A')) + name[1:]
+	}
+	return name + "Entry"
+}
+
+// setType sets the kind of a scalar field, the message and enum types are resolved after all the files are parsed.
+func setType(fd *field, typ string) {
+	if k, ok := scalarKinds[typ]; ok {
+		fd.kind = k
+	} else {
+		fd.typeName = typ
+	}
+}
+
+// parseFieldRest parses the name, the number and the options of a field after its type.
+func (p *protoParser) parseFieldRest(fd *field) (err error) {
+	if fd.name, err = p.ident(); err != nil {
+		return
+	}
+	fd.jsonName = jsonCamelCase(fd.name)
+	if err = p.expect("="); err != nil {
+		return
+	}
+	n, err := p.number()
+	if err != nil {
+		return
+	}
+	fd.number = int32(n)
+
+	if t := p.peek(); t.kind == tokenSymbol && t.value == "[" {
+		p.next()
+		if err = p.parseFieldOptions(fd); err != nil {
+			return
+		}
+	}
+	return p.expect(";")
+}
+
+func (p *protoParser) parseFieldOptions(fd *field) error {
+	for {
+		name, err := p.optionName()
+		if err != nil {
+			return err
+		}
+		if err = p.expect("="); err != nil {
+			return err
+		}
+		value := p.next()
+		switch {
+		case value.kind == tokenSymbol && value.value == "{":
+			// Aggregate values of the custom options
+			p.pos--
+			if err = p.skipBlock(); err != nil {
+				return err
+			}
+		case value.kind == tokenEOF || value.kind == tokenSymbol && value.value != "-":
+			return p.errorf(value, "invalid value of option %s", name)
+		case value.kind == tokenSymbol:
+			// Negative identifiers like -inf
+			p.next()
+		}
+
+		switch name {
+		case "packed":
+			if value.value != "true" && value.value != "false" {
+				return p.errorf(value, "packed should be true or false")
+			}
+			packed := value.value == "true"
+			fd.packedOption = &packed
+		case "json_name":
+			if value.kind != tokenString {
+				return p.errorf(value, "json_name should be a string")
+			}
+			fd.jsonName = value.value
+		}
+
+		t := p.next()
+		if t.kind == tokenSymbol && t.value == "]" {
+			return nil
+		}
+		if t.kind != tokenSymbol || t.value != "," {
+			return p.errorf(t, "expected , or ], found %s", describe(t))
+		}
+	}
+}
+
+// optionName parses a simple option name like packed or a custom one like (validate.rules).string.
+func (p *protoParser) optionName() (string, error) {
+	t := p.peek()
+	if t.kind == tokenSymbol && t.value == "(" {
+		p.next()
+		name, err := p.ident()
+		if err != nil {
+			return "", err
+		}
+		if err = p.expect(")"); err != nil {
+			return "", err
+		}
+		name = "(" + name + ")"
+		if t := p.peek(); t.kind == tokenIdent && strings.HasPrefix(t.value, ".") {
+			name += p.next().value
+		}
+		return name, nil
+	}
+	return p.ident()
+}
+
+func (p *protoParser) skipBlock() error {
+	depth := 0
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			return p.errorf(t, "unexpected end of file")
+		case t.kind == tokenSymbol && t.value == "{":
+			depth++
+		case t.kind == tokenSymbol && t.value == "}":
+			if depth--; depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// parseEnum parses an enum like "enum Role { ROLE_UNSPECIFIED = 0; ADMIN = 1; }".
+func (p *protoParser) parseEnum(scope string) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	e := &enum{name: joinPath(scope, name), numbers: make(map[string]int32), names: make(map[int32]string)}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		t := p.next()
+		if t.kind == tokenSymbol && t.value == "}" {
+			return p.files.addEnum(e)
+		}
+		if t.kind == tokenSymbol && t.value == ";" {
+			continue
+		}
+		if t.kind != tokenIdent {
+			return p.errorf(t, "unexpected %s", describe(t))
+		}
+		if t.value == "option" || t.value == "reserved" {
+			if err = p.skipStatement(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = p.expect("="); err != nil {
+			return err
+		}
+		n, err := p.number()
+		if err != nil {
+			return err
+		}
+		if v := p.peek(); v.kind == tokenSymbol && v.value == "[" {
+			if err = p.skipOptions(); err != nil {
+				return err
+			}
+		}
+		if err = p.expect(";"); err != nil {
+			return err
+		}
+		e.addValue(t.value, int32(n))
+	}
+}
+
+// skipOptions skips the options in brackets, like the options of the enum values.
+func (p *protoParser) skipOptions() error {
+	depth := 0
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			return p.errorf(t, "unexpected end of file")
+		case t.kind == tokenSymbol && t.value == "[":
+			depth++
+		case t.kind == tokenSymbol && t.value == "]":
+			if depth--; depth == 0 {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,848 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package protobuf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kind is the type of a field, numbered like the types of the FieldDescriptorProto
+type kind int32
+
+const (
+	kindDouble   kind = 1
+	kindFloat    kind = 2
+	kindInt64    kind = 3
+	kindUint64   kind = 4
+	kindInt32    kind = 5
+	kindFixed64  kind = 6
+	kindFixed32  kind = 7
+	kindBool     kind = 8
+	kindString   kind = 9
+	kindGroup    kind = 10
+	kindMessage  kind = 11
+	kindBytes    kind = 12
+	kindUint32   kind = 13
+	kindEnum     kind = 14
+	kindSfixed32 kind = 15
+	kindSfixed64 kind = 16
+	kindSint32   kind = 17
+	kindSint64   kind = 18
+)
+
+// Wire types of the encoded values
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var scalarKinds = map[string]kind{
+	"double": kindDouble, "float": kindFloat, "int64": kindInt64, "uint64": kindUint64, "int32": kindInt32,
+	"fixed64": kindFixed64, "fixed32": kindFixed32, "bool": kindBool, "string": kindString, "bytes": kindBytes,
+	"uint32": kindUint32, "sfixed32": kindSfixed32, "sfixed64": kindSfixed64, "sint32": kindSint32,
+	"sint64": kindSint64,
+}
+
+// contentTypes are the media types of the protobuf bodies
+var contentTypes = [...]string{
+	"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf",
+	"application/x-google-protobuf",
+}
+
+// Files are the message types of a loaded .proto file with its imports, or of a compiled descriptor set.
+// The well-known types are not special cased, the JSON mapping of the messages follows the proto3 JSON mapping
+// of the plain messages.
+type Files struct {
+	messages map[string]*Message
+	enums    map[string]*enum
+}
+
+// Message is a message type to encode the JSON documents to the protobuf wire format and to decode them back.
+type Message struct {
+	name     string
+	fields   []*field
+	byNumber map[int32]*field
+
+	// Fields by both their names and their JSON names
+	byName map[string]*field
+
+	// Synthesized entry message of a map field
+	mapEntry bool
+}
+
+type field struct {
+	name     string
+	jsonName string
+	number   int32
+	kind     kind
+	repeated bool
+	packed   bool
+
+	// Explicit packed option of the field, the repeated scalars of the proto3 files are packed by default
+	packedOption *bool
+	proto3       bool
+
+	// Unresolved type name of the message and enum fields and the scope it is resolved in.
+	// Names with a leading dot are fully qualified.
+	typeName string
+	scope    string
+
+	message *Message
+	enum    *enum
+}
+
+type enum struct {
+	name    string
+	numbers map[string]int32
+	names   map[int32]string
+}
+
+// Load reads the message types of a .proto file, or of a descriptor set compiled by
+// "protoc --include_imports --descriptor_set_out" for the other extensions. Imports of a .proto file are resolved
+// relative to its directory.
+func Load(path string) (*Files, error) {
+	f := &Files{messages: make(map[string]*Message), enums: make(map[string]*enum)}
+	if strings.HasSuffix(path, ".proto") {
+		if err := f.parseProtoFile(path); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("protobuf descriptor set can not be read: %v", err)
+		}
+		if err = f.addDescriptorSet(data); err != nil {
+			return nil, fmt.Errorf("protobuf descriptor set %s is not valid: %v", path, err)
+		}
+	}
+	if err := f.link(); err != nil {
+		return nil, fmt.Errorf("protobuf definitions of %s are not valid: %v", path, err)
+	}
+	return f, nil
+}
+
+// Message returns the message type by its full name like "users.v1.User".
+func (f *Files) Message(name string) (*Message, error) {
+	m, ok := f.messages[strings.TrimPrefix(name, ".")]
+	if !ok || m.mapEntry {
+		return nil, fmt.Errorf("protobuf message not found: %s", name)
+	}
+	return m, nil
+}
+
+// IsContentType reports whether the media type of the Content-Type header is one of the protobuf media types.
+func IsContentType(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, c := range contentTypes {
+		if t == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Files) addMessage(m *Message) error {
+	if _, ok := f.messages[m.name]; ok {
+		return fmt.Errorf("duplicate message %s", m.name)
+	}
+	f.messages[m.name] = m
+	return nil
+}
+
+func (f *Files) addEnum(e *enum) error {
+	if _, ok := f.enums[e.name]; ok {
+		return fmt.Errorf("duplicate enum %s", e.name)
+	}
+	f.enums[e.name] = e
+	return nil
+}
+
+// link resolves the types of the message and enum fields and indexes the fields of the messages.
+func (f *Files) link() error {
+	for _, m := range f.messages {
+		m.byNumber = make(map[int32]*field, len(m.fields))
+		m.byName = make(map[string]*field, 2*len(m.fields))
+		sort.SliceStable(m.fields, func(i, j int) bool { return m.fields[i].number < m.fields[j].number })
+		for _, fd := range m.fields {
+			if fd.number < 1 || fd.number > 1<<29-1 {
+				return fmt.Errorf("invalid number %d of field %s.%s", fd.number, m.name, fd.name)
+			}
+			if _, ok := m.byNumber[fd.number]; ok {
+				return fmt.Errorf("duplicate number %d of field %s.%s", fd.number, m.name, fd.name)
+			}
+			m.byNumber[fd.number] = fd
+			m.byName[fd.name] = fd
+			m.byName[fd.jsonName] = fd
+
+			if fd.typeName != "" {
+				full, ok := f.resolve(fd.scope, fd.typeName)
+				if !ok {
+					return fmt.Errorf("unresolved type %s of field %s.%s", fd.typeName, m.name, fd.name)
+				}
+				if fd.message, ok = f.messages[full]; ok {
+					fd.kind = kindMessage
+				} else {
+					fd.enum, fd.kind = f.enums[full], kindEnum
+				}
+			}
+			if fd.repeated && fd.packable() {
+				fd.packed = fd.proto3
+				if fd.packedOption != nil {
+					fd.packed = *fd.packedOption
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolve finds the full name of a type reference from the innermost scope to the package root.
+func (f *Files) resolve(scope, ref string) (string, bool) {
+	if strings.HasPrefix(ref, ".") {
+		ref = ref[1:]
+		_, isMessage := f.messages[ref]
+		_, isEnum := f.enums[ref]
+		return ref, isMessage || isEnum
+	}
+	for {
+		full := ref
+		if scope != "" {
+			full = scope + "." + ref
+		}
+		_, isMessage := f.messages[full]
+		_, isEnum := f.enums[full]
+		if isMessage || isEnum {
+			return full, true
+		}
+		if scope == "" {
+			return "", false
+		}
+		if i := strings.LastIndexByte(scope, '.'); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// Name returns the full name of the message.
+func (m *Message) Name() string {
+	return m.name
+}
+
+// EncodeJSON encodes the JSON representation of the message to the protobuf wire format. Fields are encoded in
+// the order of their numbers, the map entries in the order of their keys, so the same JSON gives the same bytes.
+func (m *Message) EncodeJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid json: %v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid json: unexpected data after the message")
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("message %s should be a json object", m.name)
+	}
+	return m.encode(nil, obj, "")
+}
+
+func (m *Message) encode(b []byte, obj map[string]interface{}, path string) ([]byte, error) {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := m.byName[k]; !ok {
+			return nil, fmt.Errorf("unknown field %s of message %s", joinPath(path, k), m.name)
+		}
+	}
+
+	var err error
+	for _, fd := range m.fields {
+		v, ok := obj[fd.jsonName]
+		if !ok {
+			v = obj[fd.name]
+		}
+		if v == nil {
+			continue
+		}
+		p := joinPath(path, fd.jsonName)
+		switch {
+		case fd.message != nil && fd.message.mapEntry:
+			b, err = fd.encodeMap(b, v, p)
+		case fd.repeated:
+			b, err = fd.encodeList(b, v, p)
+		default:
+			b, err = fd.encodeValue(b, v, p)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (fd *field) encodeMap(b []byte, v interface{}, path string) ([]byte, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("field %s should be a json object", path)
+	}
+	key, val := fd.message.byNumber[1], fd.message.byNumber[2]
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := fmt.Sprintf("%s[%q]", path, k)
+		entry, err := key.encodeValue(nil, mapKey(key.kind, k), p)
+		if err != nil {
+			return nil, err
+		}
+		if obj[k] != nil {
+			if entry, err = val.encodeValue(entry, obj[k], p); err != nil {
+				return nil, err
+			}
+		}
+		b = appendTag(b, fd.number, wireBytes)
+		b = appendVarint(b, uint64(len(entry)))
+		b = append(b, entry...)
+	}
+	return b, nil
+}
+
+// mapKey converts the JSON object key to the JSON value of the map key type, bools like "true" are unquoted.
+func mapKey(k kind, key string) interface{} {
+	if k == kindBool {
+		if b, err := strconv.ParseBool(key); err == nil {
+			return b
+		}
+	}
+	return key
+}
+
+func (fd *field) encodeList(b []byte, v interface{}, path string) ([]byte, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("field %s should be a json array", path)
+	}
+	if fd.packed && len(list) > 0 {
+		var packed []byte
+		for i, e := range list {
+			var err error
+			if packed, err = fd.appendScalar(packed, e, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+		b = appendTag(b, fd.number, wireBytes)
+		b = appendVarint(b, uint64(len(packed)))
+		return append(b, packed...), nil
+	}
+	for i, e := range list {
+		if e == nil {
+			return nil, fmt.Errorf("field %s[%d] can not be null", path, i)
+		}
+		var err error
+		if b, err = fd.encodeValue(b, e, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// encodeValue appends a single value of the field with its tag.
+func (fd *field) encodeValue(b []byte, v interface{}, path string) ([]byte, error) {
+	if fd.kind == kindMessage {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("field %s should be a json object", path)
+		}
+		sub, err := fd.message.encode(nil, obj, path)
+		if err != nil {
+			return nil, err
+		}
+		b = appendTag(b, fd.number, wireBytes)
+		b = appendVarint(b, uint64(len(sub)))
+		return append(b, sub...), nil
+	}
+	return fd.appendScalar(appendTag(b, fd.number, wireTypeOf(fd.kind)), v, path)
+}
+
+// appendScalar appends a scalar value without its tag, like the elements of a packed field.
+func (fd *field) appendScalar(b []byte, v interface{}, path string) ([]byte, error) {
+	switch fd.kind {
+	case kindInt32, kindSint32, kindSfixed32:
+		n, err := jsonInt(v, 32)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", path, err)
+		}
+		switch fd.kind {
+		case kindSint32:
+			return appendVarint(b, uint64(uint32(n<<1)^uint32(n>>31))), nil
+		case kindSfixed32:
+			return appendFixed32(b, uint32(n)), nil
+		}
+		return appendVarint(b, uint64(n)), nil
+	case kindInt64, kindSint64, kindSfixed64:
+		n, err := jsonInt(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", path, err)
+		}
+		switch fd.kind {
+		case kindSint64:
+			return appendVarint(b, uint64(n<<1)^uint64(n>>63)), nil
+		case kindSfixed64:
+			return appendFixed64(b, uint64(n)), nil
+		}
+		return appendVarint(b, uint64(n)), nil
+	case kindUint32, kindFixed32:
+		n, err := jsonUint(v, 32)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", path, err)
+		}
+		if fd.kind == kindFixed32 {
+			return appendFixed32(b, uint32(n)), nil
+		}
+		return appendVarint(b, n), nil
+	case kindUint64, kindFixed64:
+		n, err := jsonUint(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", path, err)
+		}
+		if fd.kind == kindFixed64 {
+			return appendFixed64(b, n), nil
+		}
+		return appendVarint(b, n), nil
+	case kindFloat, kindDouble:
+		f, err := jsonFloat(v)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", path, err)
+		}
+		if fd.kind == kindFloat {
+			if !math.IsInf(f, 0) && !math.IsNaN(f) && math.Abs(f) > math.MaxFloat32 {
+				return nil, fmt.Errorf("field %s: float value out of range: %v", path, f)
+			}
+			return appendFixed32(b, math.Float32bits(float32(f))), nil
+		}
+		return appendFixed64(b, math.Float64bits(f)), nil
+	case kindBool:
+		t, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("field %s should be a boolean", path)
+		}
+		if t {
+			return appendVarint(b, 1), nil
+		}
+		return appendVarint(b, 0), nil
+	case kindString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s should be a string", path)
+		}
+		b = appendVarint(b, uint64(len(s)))
+		return append(b, s...), nil
+	case kindBytes:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s should be a base64 string", path)
+		}
+		raw, err := decodeBase64(s)
+		if err != nil {
+			return nil, fmt.Errorf("field %s should be a base64 string", path)
+		}
+		b = appendVarint(b, uint64(len(raw)))
+		return append(b, raw...), nil
+	case kindEnum:
+		if s, ok := v.(string); ok {
+			n, ok := fd.enum.numbers[s]
+			if !ok {
+				return nil, fmt.Errorf("field %s: unknown value %s of enum %s", path, s, fd.enum.name)
+			}
+			return appendVarint(b, uint64(n)), nil
+		}
+		n, err := jsonInt(v, 32)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", path, err)
+		}
+		return appendVarint(b, uint64(n)), nil
+	}
+	return nil, fmt.Errorf("field %s should be a json object", path)
+}
+
+// DecodeJSON decodes the message from the protobuf wire format to its JSON representation.
+// The 64-bit integers are strings, the enums are their names and the bytes are base64 like in the proto3 JSON
+// mapping. Unknown fields are skipped, the fields missing from the body are left out.
+func (m *Message) DecodeJSON(wire []byte) ([]byte, error) {
+	obj, err := m.decode(wire)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+func (m *Message) decode(wire []byte) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+
+	// Occurrences of a singular message field are merged, like their concatenated bytes are decoded
+	var merged map[*field][]byte
+	r := &reader{b: wire}
+	for !r.done() {
+		num, wt, err := r.tag()
+		if err != nil {
+			return nil, err
+		}
+		fd, ok := m.byNumber[num]
+		if !ok {
+			if err = r.skip(wt); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Repeated scalars are accepted either packed or not
+		if wt == wireBytes && fd.repeated && fd.packable() {
+			packed, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			list, _ := obj[fd.jsonName].([]interface{})
+			for pr := (&reader{b: packed}); !pr.done(); {
+				v, err := fd.decodeScalar(pr)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			obj[fd.jsonName] = list
+			continue
+		}
+		if want := wireTypeOf(fd.kind); wt != want {
+			return nil, fmt.Errorf("wire type %d of field %s.%s should be %d", wt, m.name, fd.name, want)
+		}
+
+		if fd.kind != kindMessage {
+			v, err := fd.decodeScalar(r)
+			if err != nil {
+				return nil, err
+			}
+			if fd.repeated {
+				list, _ := obj[fd.jsonName].([]interface{})
+				obj[fd.jsonName] = append(list, v)
+			} else {
+				obj[fd.jsonName] = v
+			}
+			continue
+		}
+
+		raw, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case fd.message.mapEntry:
+			entries, _ := obj[fd.jsonName].(map[string]interface{})
+			if entries == nil {
+				entries = make(map[string]interface{})
+				obj[fd.jsonName] = entries
+			}
+			k, v, err := fd.message.decodeEntry(raw)
+			if err != nil {
+				return nil, err
+			}
+			entries[k] = v
+		case fd.repeated:
+			sub, err := fd.message.decode(raw)
+			if err != nil {
+				return nil, err
+			}
+			list, _ := obj[fd.jsonName].([]interface{})
+			obj[fd.jsonName] = append(list, sub)
+		default:
+			if merged == nil {
+				merged = make(map[*field][]byte)
+			}
+			merged[fd] = append(merged[fd], raw...)
+		}
+	}
+	for fd, raw := range merged {
+		sub, err := fd.message.decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		obj[fd.jsonName] = sub
+	}
+	return obj, nil
+}
+
+// decodeEntry decodes a map entry, the missing key and value are their zero values.
+func (m *Message) decodeEntry(raw []byte) (string, interface{}, error) {
+	entry, err := m.decode(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	key, val := m.byNumber[1], m.byNumber[2]
+	k, ok := entry[key.jsonName]
+	if !ok {
+		k = key.zero()
+	}
+	v, ok := entry[val.jsonName]
+	if !ok {
+		v = val.zero()
+	}
+	return fmt.Sprint(k), v, nil
+}
+
+// zero returns the JSON value of the default value of the field.
+func (fd *field) zero() interface{} {
+	switch fd.kind {
+	case kindMessage:
+		return map[string]interface{}{}
+	case kindString, kindBytes:
+		return ""
+	case kindBool:
+		return false
+	case kindInt64, kindUint64, kindSint64, kindFixed64, kindSfixed64:
+		return "0"
+	case kindEnum:
+		if name, ok := fd.enum.names[0]; ok {
+			return name
+		}
+	}
+	return 0
+}
+
+func (fd *field) decodeScalar(r *reader) (interface{}, error) {
+	switch wireTypeOf(fd.kind) {
+	case wireFixed32:
+		n, err := r.fixed32()
+		if err != nil {
+			return nil, err
+		}
+		switch fd.kind {
+		case kindFloat:
+			return jsonFloatValue(float64(math.Float32frombits(n)), 32), nil
+		case kindSfixed32:
+			return int32(n), nil
+		}
+		return n, nil
+	case wireFixed64:
+		n, err := r.fixed64()
+		if err != nil {
+			return nil, err
+		}
+		switch fd.kind {
+		case kindDouble:
+			return jsonFloatValue(math.Float64frombits(n), 64), nil
+		case kindSfixed64:
+			return strconv.FormatInt(int64(n), 10), nil
+		}
+		return strconv.FormatUint(n, 10), nil
+	case wireBytes:
+		raw, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		if fd.kind == kindBytes {
+			return base64.StdEncoding.EncodeToString(raw), nil
+		}
+		return string(raw), nil
+	}
+
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	switch fd.kind {
+	case kindInt32:
+		return int32(n), nil
+	case kindUint32:
+		return uint32(n), nil
+	case kindSint32:
+		return int32(uint32(n)>>1) ^ -int32(n&1), nil
+	case kindInt64:
+		return strconv.FormatInt(int64(n), 10), nil
+	case kindSint64:
+		return strconv.FormatInt(int64(n>>1)^-int64(n&1), 10), nil
+	case kindBool:
+		return n != 0, nil
+	case kindEnum:
+		if name, ok := fd.enum.names[int32(n)]; ok {
+			return name, nil
+		}
+		return int32(n), nil
+	}
+	return strconv.FormatUint(n, 10), nil
+}
+
+// packable reports whether the values of the field can be packed, only the scalar numeric types can.
+func (fd *field) packable() bool {
+	t := wireTypeOf(fd.kind)
+	return t == wireVarint || t == wireFixed32 || t == wireFixed64
+}
+
+func wireTypeOf(k kind) int {
+	switch k {
+	case kindDouble, kindFixed64, kindSfixed64:
+		return wireFixed64
+	case kindFloat, kindFixed32, kindSfixed32:
+		return wireFixed32
+	case kindString, kindBytes, kindMessage:
+		return wireBytes
+	}
+	return wireVarint
+}
+
+// jsonInt parses an integer given either as a JSON number or as a string.
+func jsonInt(v interface{}, bits int) (int64, error) {
+	s, ok := jsonNumberText(v)
+	if !ok {
+		return 0, fmt.Errorf("invalid int%d value: %v", bits, v)
+	}
+	n, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil || f != math.Trunc(f) || f < -math.Pow(2, float64(bits-1)) || f >= math.Pow(2, float64(bits-1)) {
+			return 0, fmt.Errorf("invalid int%d value: %s", bits, s)
+		}
+		n = int64(f)
+	}
+	return n, nil
+}
+
+// jsonUint parses an unsigned integer given either as a JSON number or as a string.
+func jsonUint(v interface{}, bits int) (uint64, error) {
+	s, ok := jsonNumberText(v)
+	if !ok {
+		return 0, fmt.Errorf("invalid uint%d value: %v", bits, v)
+	}
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil || f != math.Trunc(f) || f < 0 || f >= math.Pow(2, float64(bits)) {
+			return 0, fmt.Errorf("invalid uint%d value: %s", bits, s)
+		}
+		n = uint64(f)
+	}
+	return n, nil
+}
+
+// jsonFloat parses a floating point number given as a JSON number, as a string or as one of the special values.
+func jsonFloat(v interface{}) (float64, error) {
+	switch v {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	s, ok := jsonNumberText(v)
+	if !ok {
+		return 0, fmt.Errorf("invalid floating point value: %v", v)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid floating point value: %s", s)
+	}
+	return f, nil
+}
+
+func jsonNumberText(v interface{}) (string, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.String(), true
+	case string:
+		return strings.TrimSpace(n), n != ""
+	}
+	return "", false
+}
+
+// jsonFloatValue returns the JSON value of a decoded float, the special values are strings.
+// Floats are formatted in their own precision, so 0.1 is not printed as 0.10000000149011612.
+func jsonFloatValue(f float64, bits int) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, bits))
+}
+
+// decodeBase64 decodes the standard or the URL safe base64, with or without the padding.
+func decodeBase64(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.DecodeString(s)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func appendTag(b []byte, number int32, wireType int) []byte {
+	return appendVarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendFixed32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	return appendFixed32(appendFixed32(b, uint32(v)), uint32(v>>32))
+}
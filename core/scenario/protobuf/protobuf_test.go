@@ -0,0 +1,424 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package protobuf
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testUserProto = `syntax = "proto3";
+
+// Users of the test
+package users.v1;
+
+import "common/meta.proto";
+
+option go_package = "example.com/users/v1";
+
+message CreateUser {
+  int64 id = 1;
+  string name = 2;
+  Role role = 3;
+  repeated string tags = 4;
+  repeated int32 scores = 5;
+  map<string, int32> counts = 6;
+  Address address = 7;
+  bytes avatar = 8;
+  sint32 delta = 9;
+  double ratio = 10;
+  bool active = 11;
+  string display_name = 12 [json_name = "display", (validate.rules).string.min_len = 1];
+  common.Meta meta = 13;
+  oneof contact {
+    string email = 14;
+    string phone = 15;
+  }
+  reserved 20 to 30;
+
+  message Address {
+    string city = 1;
+  }
+}
+
+/* Roles of
+   the users */
+enum Role {
+  ROLE_UNSPECIFIED = 0;
+  ADMIN = 1 [deprecated = true];
+}
+
+service Users {
+  rpc Create(CreateUser) returns (CreateUser) {
+    option (google.api.http) = { post: "/v1/users" body: "*" };
+  }
+}
+`
+
+const testMetaProto = `syntax = "proto3";
+package common;
+message Meta { uint64 version = 1; }
+`
+
+func loadTestUser(t *testing.T) *Message {
+	t.Helper()
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "common"), 0700)
+	os.WriteFile(filepath.Join(dir, "user.proto"), []byte(testUserProto), 0600)
+	os.WriteFile(filepath.Join(dir, "common", "meta.proto"), []byte(testMetaProto), 0600)
+
+	files, err := Load(filepath.Join(dir, "user.proto"))
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+	m, err := files.Message("users.v1.CreateUser")
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+	return m
+}
+
+func TestEncodeJSON(t *testing.T) {
+	m := loadTestUser(t)
+
+	tests := []struct {
+		name     string
+		json     string
+		expected string
+	}{
+		{"Int64", `{"id": 150}`, "089601"},
+		{"Int64String", `{"id": "150"}`, "089601"},
+		{"NegativeInt64", `{"id": -1}`, "08ffffffffffffffffff01"},
+		{"String", `{"name": "ab"}`, "12026162"},
+		{"EnumName", `{"role": "ADMIN"}`, "1801"},
+		{"EnumNumber", `{"role": 1}`, "1801"},
+		{"RepeatedString", `{"tags": ["a", "b"]}`, "220161220162"},
+		{"PackedInt32", `{"scores": [1, 2, 300]}`, "2a040102ac02"},
+		{"EmptyRepeated", `{"scores": []}`, ""},
+		{"Map", `{"counts": {"b": 2, "a": 1}}`, "32050a0161100132050a01621002"},
+		{"Message", `{"address": {"city": "x"}}`, "3a030a0178"},
+		{"Bytes", `{"avatar": "AQI="}`, "42020102"},
+		{"UnpaddedBytes", `{"avatar": "AQI"}`, "42020102"},
+		{"Sint32", `{"delta": -1}`, "4801"},
+		{"Double", `{"ratio": 1.5}`, "51000000000000f83f"},
+		{"Bool", `{"active": true}`, "5801"},
+		{"JSONName", `{"display": "d"}`, "620164"},
+		{"FieldName", `{"display_name": "d"}`, "620164"},
+		{"Imported", `{"meta": {"version": "7"}}`, "6a020807"},
+		{"Oneof", `{"email": "e"}`, "720165"},
+		{"Null", `{"name": null}`, ""},
+		{"NumberOrder", `{"name": "ab", "id": 1}`, "080112026162"},
+		{"ExponentInteger", `{"id": 1e2}`, "0864"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			b, err := m.EncodeJSON([]byte(test.json))
+			if err != nil {
+				t.Fatalf("Error occurred: %v", err)
+			}
+			if found := hex.EncodeToString(b); found != test.expected {
+				t.Errorf("Expected %s, Found %s", test.expected, found)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestEncodeJSONErrors(t *testing.T) {
+	m := loadTestUser(t)
+
+	tests := []struct {
+		name     string
+		json     string
+		expected string
+	}{
+		{"InvalidJson", `{"id": `, "invalid json: unexpected EOF"},
+		{"TrailingData", `{} {}`, "invalid json: unexpected data after the message"},
+		{"NotAnObject", `[1]`, "message users.v1.CreateUser should be a json object"},
+		{"UnknownField", `{"unknown": 1}`, "unknown field unknown of message users.v1.CreateUser"},
+		{"UnknownNestedField", `{"address": {"zip": "1"}}`,
+			"unknown field address.zip of message users.v1.CreateUser.Address"},
+		{"InvalidInt64", `{"id": "x"}`, "field id: invalid int64 value: x"},
+		{"Int32Range", `{"delta": 3000000000}`, "field delta: invalid int32 value: 3000000000"},
+		{"FractionInt32", `{"scores": [1.5]}`, "field scores[0]: invalid int32 value: 1.5"},
+		{"UnknownEnum", `{"role": "ROOT"}`, "field role: unknown value ROOT of enum users.v1.Role"},
+		{"NotAnArray", `{"tags": "a"}`, "field tags should be a json array"},
+		{"NullElement", `{"tags": [null]}`, "field tags[0] can not be null"},
+		{"StringType", `{"name": 1}`, "field name should be a string"},
+		{"BoolType", `{"active": "true"}`, "field active should be a boolean"},
+		{"InvalidBase64", `{"avatar": "%%"}`, "field avatar should be a base64 string"},
+		{"MapValue", `{"counts": {"a": "x"}}`, `field counts["a"]: invalid int32 value: x`},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			_, err := m.EncodeJSON([]byte(test.json))
+			if err == nil {
+				t.Fatalf("Should be errored")
+			}
+			if err.Error() != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, err.Error())
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	m := loadTestUser(t)
+
+	tests := []struct {
+		name     string
+		wire     string
+		expected string
+	}{
+		{"Int64", "089601", `{"id":"150"}`},
+		{"Packed", "2a040102ac02", `{"scores":[1,2,300]}`},
+		{"Unpacked", "28012802", `{"scores":[1,2]}`},
+		{"Map", "32050a01611001", `{"counts":{"a":1}}`},
+		{"MapMissingValue", "32030a0161", `{"counts":{"a":0}}`},
+		{"MergedMessage", "3a030a01783a030a0179", `{"address":{"city":"y"}}`},
+		{"RepeatedString", "220161220162", `{"tags":["a","b"]}`},
+		{"UnknownField", "a00601", `{}`},
+		{"EnumName", "1801", `{"role":"ADMIN"}`},
+		{"UnknownEnum", "1802", `{"role":2}`},
+		{"Sint32", "4801", `{"delta":-1}`},
+		{"Double", "51000000000000f83f", `{"ratio":1.5}`},
+		{"Bytes", "42020102", `{"avatar":"AQI="}`},
+		{"JSONName", "620164", `{"display":"d"}`},
+		{"Imported", "6a020807", `{"meta":{"version":"7"}}`},
+		{"Empty", "", `{}`},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			wire, _ := hex.DecodeString(test.wire)
+			b, err := m.DecodeJSON(wire)
+			if err != nil {
+				t.Fatalf("Error occurred: %v", err)
+			}
+			if string(b) != test.expected {
+				t.Errorf("Expected %s, Found %s", test.expected, b)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	m := loadTestUser(t)
+
+	tests := []struct {
+		name     string
+		wire     string
+		expected string
+	}{
+		{"Truncated", "0896", "truncated protobuf message"},
+		{"TruncatedBytes", "1205", "truncated protobuf message"},
+		{"WireType", "0a0161", "wire type 2 of field users.v1.CreateUser.id should be 0"},
+		{"FieldNumber", "00", "invalid field number 0 in protobuf message"},
+		{"Group", "a306", "unsupported wire type 3 in protobuf message"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			wire, _ := hex.DecodeString(test.wire)
+			_, err := m.DecodeJSON(wire)
+			if err == nil {
+				t.Fatalf("Should be errored")
+			}
+			if err.Error() != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, err.Error())
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	m := loadTestUser(t)
+	doc := `{"active":true,"address":{"city":"x"},"avatar":"AQI=","counts":{"a":1,"b":2},"delta":-5,` +
+		`"display":"d","id":"-7","meta":{"version":"7"},"name":"ada","phone":"1","ratio":0.25,"role":"ADMIN",` +
+		`"scores":[1,-2],"tags":["a","b"]}`
+
+	wire, err := m.EncodeJSON([]byte(doc))
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+	found, err := m.DecodeJSON(wire)
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+	if string(found) != doc {
+		t.Errorf("Expected %s, Found %s", doc, found)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		proto    string
+		expected string
+	}{
+		{"MissingImport", `syntax = "proto3"; import "missing.proto";`, "import missing.proto of proto file"},
+		{"UnresolvedType", `syntax = "proto3"; message A { B b = 1; }`, "unresolved type B of field A.b"},
+		{"DuplicateNumber", `syntax = "proto3"; message A { int32 a = 1; int32 b = 1; }`,
+			"duplicate number 1 of field A.b"},
+		{"DuplicateMessage", `syntax = "proto3"; message A {} message A {}`, "duplicate message A"},
+		{"SyntaxError", "syntax = \"proto3\";\nmessage A { int32 a 1; }", "line 2: expected =, found 1"},
+		{"UnterminatedComment", `syntax = "proto3"; /* message`, "line 1: unterminated comment"},
+		{"UnsupportedSyntax", `syntax = "proto4";`, "unsupported syntax proto4"},
+		{"Group", `syntax = "proto2"; message A { optional group G = 1 { } }`, "groups are not supported"},
+		{"InvalidMapKey", `syntax = "proto3"; message A { map<double, int32> m = 1; }`, "invalid map key type double"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.proto")
+			os.WriteFile(path, []byte(test.proto), 0600)
+			_, err := Load(path)
+			if err == nil {
+				t.Fatalf("Should be errored")
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected %q in %q", test.expected, err.Error())
+			}
+		}
+		t.Run(test.name, tf)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.proto")); err == nil {
+		t.Errorf("Missing proto file should be errored")
+	}
+	m := loadTestUser(t)
+	if m.Name() != "users.v1.CreateUser" {
+		t.Errorf("Expected users.v1.CreateUser, Found %s", m.Name())
+	}
+}
+
+func TestMessageNotFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.proto")
+	os.WriteFile(path, []byte(`syntax = "proto3"; message A { map<string, string> m = 1; }`), 0600)
+	files, err := Load(path)
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+
+	if _, err := files.Message(".A"); err != nil {
+		t.Errorf("Error occurred: %v", err)
+	}
+	for _, name := range []string{"B", "A.MEntry"} {
+		if _, err := files.Message(name); err == nil || err.Error() != "protobuf message not found: "+name {
+			t.Errorf("Expected not found error of %s, Found %v", name, err)
+		}
+	}
+}
+
+// testDescriptorSet is the descriptor set of
+//
+//	package shop;
+//	message Order { int64 id = 1; repeated Item items = 2; Status status = 3; message Item { string sku = 1; } }
+//	enum Status { NEW = 0; PAID = 1; }
+func testDescriptorSet() []byte {
+	str := func(num int32, s string) []byte {
+		b := appendTag(nil, num, wireBytes)
+		return append(appendVarint(b, uint64(len(s))), s...)
+	}
+	num := func(num int32, v uint64) []byte {
+		return appendVarint(appendTag(nil, num, wireVarint), v)
+	}
+	join := func(parts ...[]byte) string {
+		var b []byte
+		for _, p := range parts {
+			b = append(b, p...)
+		}
+		return string(b)
+	}
+
+	item := join(str(messageName, "Item"),
+		str(messageField, join(str(fieldName, "sku"), num(fieldNumber, 1), num(fieldType, uint64(kindString)))))
+	order := join(str(messageName, "Order"),
+		str(messageField, join(str(fieldName, "id"), num(fieldNumber, 1), num(fieldType, uint64(kindInt64)))),
+		str(messageField, join(str(fieldName, "items"), num(fieldNumber, 2), num(fieldLabel, labelRepeated),
+			num(fieldType, uint64(kindMessage)), str(fieldTypeName, ".shop.Order.Item"))),
+		str(messageField, join(str(fieldName, "status"), num(fieldNumber, 3), num(fieldType, uint64(kindEnum)),
+			str(fieldTypeName, ".shop.Status"))),
+		str(messageNestedType, item))
+	status := join(str(enumName, "Status"),
+		str(enumValue, join(str(enumValueName, "NEW"), num(enumValueNumber, 0))),
+		str(enumValue, join(str(enumValueName, "PAID"), num(enumValueNumber, 1))))
+	file := join(str(1, "shop.proto"), str(filePackage, "shop"), str(fileMessageType, order),
+		str(fileEnumType, status), str(fileSyntax, "proto3"))
+	return []byte(join(str(fileSetFile, file)))
+}
+
+func TestLoadDescriptorSet(t *testing.T) {
+	dir := t.TempDir()
+	valid, invalid := filepath.Join(dir, "shop.pb"), filepath.Join(dir, "invalid.pb")
+	os.WriteFile(valid, testDescriptorSet(), 0600)
+	os.WriteFile(invalid, []byte{0x0a, 0x05, 0x01}, 0600)
+
+	files, err := Load(valid)
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+	m, err := files.Message("shop.Order")
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+	wire, err := m.EncodeJSON([]byte(`{"id": "5", "items": [{"sku": "a"}], "status": "PAID"}`))
+	if err != nil {
+		t.Fatalf("Error occurred: %v", err)
+	}
+	if found := hex.EncodeToString(wire); found != "080512030a01611801" {
+		t.Errorf("Expected 080512030a01611801, Found %s", found)
+	}
+
+	if _, err := Load(invalid); err == nil {
+		t.Errorf("Invalid descriptor set should be errored")
+	}
+	if _, err := Load(filepath.Join(dir, "missing.pb")); err == nil {
+		t.Errorf("Missing descriptor set should be errored")
+	}
+}
+
+func TestIsContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"application/x-protobuf", true},
+		{"application/protobuf; proto=users.v1.User", true},
+		{"application/vnd.google.protobuf", true},
+		{"application/json", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if found := IsContentType(test.contentType); found != test.expected {
+			t.Errorf("%q: Expected %v, Found %v", test.contentType, test.expected, found)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Values of the "timeoutCut" of the results timed out by an adaptive timeout under its max, and at the max
+const (
+	timeoutCutAdaptive = "adaptive"
+	timeoutCutMax      = "max"
+)
+
+// adaptiveTimeout is the timeout of the requests of a step with an adaptive-timeout. It is set by the engine from the
+// rolling p99 of the step while the test is running, the requests time out at the max of the option until then.
+type adaptiveTimeout struct {
+	max time.Duration
+
+	// Nanoseconds of the current timeout
+	current int64
+}
+
+// newAdaptiveTimeout returns the adaptive timeout of the step, nil if the step has none.
+func newAdaptiveTimeout(s types.ScenarioStep) *adaptiveTimeout {
+	val, ok := s.Custom["adaptive-timeout"]
+	if !ok {
+		return nil
+	}
+	// Validated with the scenario
+	at, _ := types.ParseAdaptiveTimeout(val, s.Timeout)
+	return &adaptiveTimeout{max: at.Max, current: int64(at.Max)}
+}
+
+func (a *adaptiveTimeout) set(d time.Duration) {
+	if d > a.max {
+		d = a.max
+	}
+	atomic.StoreInt64(&a.current, int64(d))
+}
+
+func (a *adaptiveTimeout) get() time.Duration {
+	return time.Duration(atomic.LoadInt64(&a.current))
+}
+
+// bound returns the ctx of a request bounded by the current timeout, with the timeout.
+func (a *adaptiveTimeout) bound(ctx context.Context) (context.Context, context.CancelFunc, time.Duration) {
+	timeout := a.get()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// cut returns how a failed request is cut by the timeout, empty if it is not cut by the timeout. A request cut under
+// the max would have had more time with the static max.
+func (a *adaptiveTimeout) cut(ctx context.Context, timeout time.Duration) string {
+	if ctx.Err() != context.DeadlineExceeded {
+		return ""
+	}
+	if timeout < a.max {
+		return timeoutCutAdaptive
+	}
+	return timeoutCutMax
+}
+
+func isTimeoutReason(reason string) bool {
+	return reason == types.ReasonConnTimeout || reason == types.ReasonReadTimeout || reason == types.ReasonProxyTimeout
+}
+
+// SetTimeout sets the timeout of the next requests of a step with an adaptive-timeout, bounded by the max of the
+// option. It is a no-op for the other steps.
+func (h *HttpRequester) SetTimeout(d time.Duration) {
+	if h.adaptive != nil {
+		h.adaptive.set(d)
+	}
+}
@@ -25,25 +25,126 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
+	"go.ddosify.com/ddosify/core/clock"
 	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/warm"
 )
 
 // Requester is the interface that abstracts different protocols' request sending implementations.
-// Protocol field in the types.ScenarioStep determines which requester implementation to use.
+// Protocol field in the types.ScenarioStep determines which requester implementation to use, see Register.
+//
+// A requester is created for each step of each proxy and its Send is called concurrently by the iterations, so it
+// should keep the state of a request in the result or in the Iteration.
 type Requester interface {
+	// Init prepares the requester for the step before the test. The step is validated by types.ScenarioStep, its
+	// Custom field holds the protocol specific options of the config. The url is the proxy of the step, nil if the
+	// requests are direct. Requests should be canceled once the ctx is done. In the debug mode, the results should
+	// carry the DebugInfo described by Send.
 	Init(ctx context.Context, ss types.ScenarioStep, url *url.URL, debug bool) error
-	Send() *types.ScenarioStepResult
+
+	// Send sends the request of the step in the iteration, it reads and adds to the state of the iteration.
+	// The result should have the StepID, StepName, RequestID, RequestTime and Duration set, and Err set on failure.
+	// The Custom field carries the protocol specific metrics: time.Duration values under the keys ending in
+	// "Duration" are averaged by the reports, and int64 values under the keys ending in "Count" are summed. In the
+	// debug mode, DebugInfo should have "url" and "method" as string, "requestHeaders" and "responseHeaders" as
+	// http.Header, "requestBody" and "responseBody" as []byte. The response ones are omitted if no response is received.
+	Send(it *Iteration) *types.ScenarioStepResult
+
+	// Done releases the resources of the requester once the test is completed.
 	Done()
+
+	// MetricMeta declares the duration and count keys of the Custom field of the results, in the display order.
+	// The keys not declared are rendered by their words, like "queueWaitDuration" as "Queue Wait".
+	MetricMeta() []types.MetricMeta
+}
+
+// Previewer is the optional interface of the requesters that can render the request of the step without sending it.
+type Previewer interface {
+	Preview() *types.ScenarioStepResult
+}
+
+// SeedAware is the optional interface of the requesters that make random decisions.
+// The seed is set before Init, and all the random values of the requester should derive from it.
+type SeedAware interface {
+	SetSeed(seed int64)
+}
+
+// Capturer is the optional interface of the requesters that can record the full detail of the sampled requests,
+// while the other requests stay on the lean path of Send.
+type Capturer interface {
+	// SendCapture sends the request like Send and fills the DebugInfo of the result as in the debug mode.
+	SendCapture(it *Iteration) *types.ScenarioStepResult
+
+	// CaptureFailures makes Send fill the DebugInfo of the failed requests with the detail available on the lean path.
+	CaptureFailures()
+}
+
+// Prewarmer is the optional interface of the requesters that can establish the connections of the step before the test,
+// so the handshakes are not measured in the first seconds of the test.
+type Prewarmer interface {
+	// Prewarm returns the requested and the established connection counts, requested is 0 if the step has no prewarm.
+	Prewarm() (requested, established int, err error)
+}
+
+// Preflighter is the optional interface of the requesters that can probe the connectivity of the targets of the step
+// before the test, so an unreachable target fails the test before the load begins.
+type Preflighter interface {
+	Preflight() []PreflightProbe
+}
+
+// TimeoutAdjustable is the optional interface of the requesters whose timeout follows the latency of the step while
+// the test is running, like with the adaptive-timeout option. SetTimeout may be called concurrently with Send.
+type TimeoutAdjustable interface {
+	SetTimeout(d time.Duration)
+}
+
+// WarmStateAware is the optional interface of the requesters that can take the connection state carried across the
+// runs of the process, like the resolved hosts and the TLS sessions of the previous runs of a suite. The state is set
+// before Init.
+type WarmStateAware interface {
+	SetWarmState(s *warm.State)
+}
+
+// ClockAware is the optional interface of the requesters that read the time of the test on a clock, like the
+// HTTP-dates of the Retry-After headers and the timings of the duplicated requests. The clock is set before Init,
+// a test or a simulation sets a clock.Fake.
+type ClockAware interface {
+	SetClock(c clock.Clock)
+}
+
+// requesterFactories are the factories of the requesters by their upper case protocols.
+var requesterFactories = map[string]func() Requester{
+	types.ProtocolHTTP:  func() Requester { return &HttpRequester{} },
+	types.ProtocolHTTPS: func() Requester { return &HttpRequester{} },
+}
+
+// Register makes the requester of the protocol available to NewRequester, for the steps whose Protocol field is the
+// protocol, case insensitively. The factory is called for each step of each proxy and should return a new requester.
+// Register is intended to be called from the init function of the package of the requester, it is not safe for
+// concurrent use and panics if the protocol is empty or already registered.
+func Register(protocol string, factory func() Requester) {
+	p := strings.ToUpper(protocol)
+	if p == "" || strings.Contains(p, "://") {
+		panic(fmt.Sprintf("requester: invalid protocol %q", protocol))
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("requester: nil factory of protocol %s", p))
+	}
+	if _, dup := requesterFactories[p]; dup {
+		panic(fmt.Sprintf("requester: protocol %s is already registered", p))
+	}
+	requesterFactories[p] = factory
+	types.RegisterProtocol(p)
 }
 
 // NewRequester is the factory method of the Requester.
 func NewRequester(s types.ScenarioStep) (requester Requester, err error) {
-	if strings.EqualFold(s.Protocol, types.ProtocolHTTP) ||
-		strings.EqualFold(s.Protocol, types.ProtocolHTTPS) {
-		requester = &HttpRequester{}
+	if factory, ok := requesterFactories[strings.ToUpper(s.Protocol)]; ok {
+		requester = factory()
 	} else {
-		err = fmt.Errorf("unsupported requester")
+		err = fmt.Errorf("unsupported requester: %s", s.Protocol)
 	}
 	return
 }
@@ -53,3 +53,40 @@ func TestNewRequester(t *testing.T) {
 		t.Errorf("TestNewRequester invalid protocol should errored")
 	}
 }
+
+func TestRegister(t *testing.T) {
+	factory := func() Requester { return &HttpRequester{} }
+	Register("toy", factory)
+
+	service, err := NewRequester(types.ScenarioStep{Protocol: "Toy"})
+	if err != nil {
+		t.Fatalf("TestRegister %v", err)
+	}
+	if reflect.TypeOf(service) != reflect.TypeOf(&HttpRequester{}) {
+		t.Errorf("Expected %v, Found %v", reflect.TypeOf(&HttpRequester{}), reflect.TypeOf(service))
+	}
+
+	tests := []struct {
+		name     string
+		protocol string
+		factory  func() Requester
+	}{
+		{"Duplicate", "TOY", factory},
+		{"BuiltIn", types.ProtocolHTTPS, factory},
+		{"Empty", "", factory},
+		{"Scheme", "toy://", factory},
+		{"NilFactory", "other", nil},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register should panic")
+				}
+			}()
+			Register(test.protocol, test.factory)
+		}
+		t.Run(test.name, tf)
+	}
+}
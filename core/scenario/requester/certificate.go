@@ -0,0 +1,58 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import (
+	"crypto/x509"
+	"sync"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// certificateReporter reports the leaf certificate of the first TLS connection to each host of a step, so the
+// certificates are read once per host instead of on each request.
+type certificateReporter struct {
+	reported sync.Map
+}
+
+// first returns the certificate of the chain sent by the host, ok is false if the certificate of the host is
+// already reported or the chain is empty.
+func (c *certificateReporter) first(host string, chain []*x509.Certificate) (cert types.Certificate, ok bool) {
+	if len(chain) == 0 {
+		return cert, false
+	}
+	if _, loaded := c.reported.LoadOrStore(host, struct{}{}); loaded {
+		return cert, false
+	}
+
+	leaf := chain[0]
+	cert = types.Certificate{
+		Host:        host,
+		Subject:     leaf.Subject.String(),
+		Issuer:      leaf.Issuer.String(),
+		NotAfter:    leaf.NotAfter,
+		SANs:        append([]string{}, leaf.DNSNames...),
+		ChainLength: len(chain),
+	}
+	for _, ip := range leaf.IPAddresses {
+		cert.SANs = append(cert.SANs, ip.String())
+	}
+	return cert, true
+}
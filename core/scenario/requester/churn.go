@@ -0,0 +1,81 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// churnRetry retries the requests failed on a stale reused connection, like the keep-alive race of a connection
+// closed by the server while it was idle. It is an artifact of the connection churn rather than a failure of the target,
+// so the request is sent once more on a fresh connection. Only the idempotent methods are retried unless the step opts
+// in by the stale-retry option.
+type churnRetry struct {
+	// Methods that are not idempotent are retried too
+	all bool
+
+	// Client of the fresh connections, they are closed after the retry. Created on the first retry.
+	once   sync.Once
+	client *http.Client
+}
+
+// newChurnRetry returns the retry of the stale-retry option of the step, nil if it is disabled.
+func newChurnRetry(custom map[string]interface{}) *churnRetry {
+	val, ok := custom["stale-retry"]
+	if !ok {
+		return &churnRetry{}
+	}
+	if val.(bool) {
+		return &churnRetry{all: true}
+	}
+	return nil
+}
+
+// retries reports whether the requests of the method are retried. Safe to call on a nil churnRetry.
+func (c *churnRetry) retries(method string) bool {
+	return c != nil && (c.all || types.IsIdempotentMethod(method))
+}
+
+// freshClient returns a client like the shared client of the step whose connections are never reused.
+func (c *churnRetry) freshClient(h *HttpRequester) *http.Client {
+	c.once.Do(func() {
+		tr := h.initTransport(h.initTLSConfig())
+		tr.DisableKeepAlives = true
+		client := *h.client
+		client.Transport = tr
+		c.client = &client
+	})
+	return c.client
+}
+
+// isStaleConnErr reports whether the error is the one of a connection closed by the server, which is stale if the
+// connection is reused.
+func isStaleConnErr(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || strings.Contains(err.Error(), "server closed idle connection")
+}
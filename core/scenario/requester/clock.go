@@ -0,0 +1,41 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import "time"
+
+// since returns the time elapsed since t, 0 if t is not set. The times of the requester are the readings of time.Now
+// with the monotonic clock, which Since and Sub use, so the durations don't jump when the wall clock of the host is
+// stepped during a run, like by an NTP correction.
+func since(t time.Time) time.Duration {
+	return between(t, time.Now())
+}
+
+// between returns the time from start to end by their monotonic clock readings, 0 if either one is not set or end is
+// before start. Unset times have no monotonic reading, the wall clock time since the year 1 would be reported instead.
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	if d := end.Sub(start); d > 0 {
+		return d
+	}
+	return 0
+}
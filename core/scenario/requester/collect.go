@@ -0,0 +1,185 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// collectMemorySize is the upper bound of the bytes of the values of a capture kept in memory, the values after it
+// are spilled into a temporary file.
+const collectMemorySize = 8 << 20
+
+// Collector gathers the values of the collected captures of all the iterations, so the teardown steps can iterate
+// over them after the test. The first values of each capture are kept in memory and the rest are spilled into
+// a temporary file, the values over the limit are only counted. It is safe for concurrent use.
+type Collector struct {
+	mu      sync.Mutex
+	limit   int64
+	memSize int
+	lists   map[string]*collectedList
+}
+
+type collectedList struct {
+	mem      []string
+	memBytes int
+
+	// Spilled values, a JSON string on each line
+	file *os.File
+	w    *bufio.Writer
+
+	count   int64
+	dropped int64
+
+	// Set if the spill file can't be written, the values after it are dropped
+	err error
+}
+
+// NewCollector returns a collector keeping up to limit values of each capture.
+func NewCollector(limit int) *Collector {
+	return &Collector{limit: int64(limit), memSize: collectMemorySize, lists: make(map[string]*collectedList)}
+}
+
+func (c *Collector) add(name, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.lists[name]
+	if !ok {
+		l = &collectedList{}
+		c.lists[name] = l
+	}
+	if l.count >= c.limit || l.err != nil {
+		l.dropped++
+		return
+	}
+
+	if l.file == nil && l.memBytes+len(value) <= c.memSize {
+		l.mem = append(l.mem, value)
+		l.memBytes += len(value)
+		l.count++
+		return
+	}
+
+	if l.file == nil {
+		if l.file, l.err = os.CreateTemp("", "ddosify-collect-*.ndjson"); l.err != nil {
+			l.dropped++
+			return
+		}
+		l.w = bufio.NewWriter(l.file)
+	}
+	b, _ := json.Marshal(value)
+	if _, l.err = l.w.Write(append(b, '\n')); l.err != nil {
+		l.dropped++
+		return
+	}
+	l.count++
+}
+
+// Counts returns the count of the values collected for the capture and the count of the values dropped over
+// the limit or after a spill failure.
+func (c *Collector) Counts(name string) (collected, dropped int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.lists[name]; ok {
+		return l.count, l.dropped
+	}
+	return 0, 0
+}
+
+// Each calls fn with the values collected for the capture in the collection order, in batches of the given size,
+// until fn returns false. The values collected while it runs are not passed to fn.
+func (c *Collector) Each(name string, batch int, fn func(values []string) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.lists[name]
+	if !ok {
+		return nil
+	}
+
+	values := make([]string, 0, batch)
+	next := func(v string) bool {
+		values = append(values, v)
+		if len(values) < batch {
+			return true
+		}
+		ok := fn(values)
+		values = make([]string, 0, batch)
+		return ok
+	}
+
+	for _, v := range l.mem {
+		if !next(v) {
+			return nil
+		}
+	}
+
+	if l.file != nil {
+		if err := l.w.Flush(); err != nil {
+			return err
+		}
+		if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		// Later values are appended to the end of the file
+		defer l.file.Seek(0, io.SeekEnd)
+
+		r := bufio.NewReader(l.file)
+		for {
+			line, err := r.ReadBytes('\n')
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			var v string
+			if err = json.Unmarshal(line, &v); err != nil {
+				return err
+			}
+			if !next(v) {
+				return nil
+			}
+		}
+	}
+
+	if len(values) > 0 {
+		fn(values)
+	}
+	return nil
+}
+
+// Close removes the spill files of the collector.
+func (c *Collector) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, l := range c.lists {
+		if l.file != nil {
+			l.file.Close()
+			os.Remove(l.file.Name())
+			l.file = nil
+		}
+	}
+}
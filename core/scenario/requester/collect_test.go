@@ -0,0 +1,151 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCollectorEach(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		memSize  int
+		limit    int
+		batch    int
+		count    int
+		expected [][]string
+		dropped  int64
+	}{
+		{"InMemory", collectMemorySize, 10, 1, 3, [][]string{{"v0"}, {"v1"}, {"v2"}}, 0},
+		{"Batched", collectMemorySize, 10, 2, 5, [][]string{{"v0", "v1"}, {"v2", "v3"}, {"v4"}}, 0},
+		{"Spilled", 4, 10, 2, 5, [][]string{{"v0", "v1"}, {"v2", "v3"}, {"v4"}}, 0},
+		{"AllSpilled", 0, 10, 3, 4, [][]string{{"v0", "v1", "v2"}, {"v3"}}, 0},
+		{"Limit", 4, 3, 1, 5, [][]string{{"v0"}, {"v1"}, {"v2"}}, 2},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewCollector(test.limit)
+			c.memSize = test.memSize
+			defer c.Close()
+			for i := 0; i < test.count; i++ {
+				c.add("id", fmt.Sprintf("v%d", i))
+			}
+
+			var batches [][]string
+			if err := c.Each("id", test.batch, func(values []string) bool {
+				batches = append(batches, values)
+				return true
+			}); err != nil {
+				t.Fatalf("Each error occurred: %v", err)
+			}
+			if !reflect.DeepEqual(batches, test.expected) {
+				t.Errorf("Expected %v, Found %v", test.expected, batches)
+			}
+
+			collected, dropped := c.Counts("id")
+			if collected != int64(test.count)-test.dropped || dropped != test.dropped {
+				t.Errorf("Counts Expected %d %d, Found %d %d", int64(test.count)-test.dropped, test.dropped,
+					collected, dropped)
+			}
+		})
+	}
+}
+
+func TestCollectorEachStop(t *testing.T) {
+	c := NewCollector(10)
+	c.memSize = 4
+	defer c.Close()
+	for i := 0; i < 6; i++ {
+		c.add("id", fmt.Sprintf("v%d", i))
+	}
+
+	// The values after the stop are left
+	var values []string
+	c.Each("id", 1, func(v []string) bool {
+		values = append(values, v...)
+		return len(values) < 3
+	})
+	if expected := []string{"v0", "v1", "v2"}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, Found %v", expected, values)
+	}
+
+	// A value collected after Each is appended to the spill file
+	c.add("id", "v6")
+	values = nil
+	c.Each("id", 10, func(v []string) bool {
+		values = append(values, v...)
+		return true
+	})
+	if expected := []string{"v0", "v1", "v2", "v3", "v4", "v5", "v6"}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, Found %v", expected, values)
+	}
+}
+
+func TestCollectorConcurrentAdd(t *testing.T) {
+	c := NewCollector(1000)
+	c.memSize = 64
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.add("id", fmt.Sprintf("%d-%d", i, j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	c.Each("id", 7, func(values []string) bool {
+		for _, v := range values {
+			seen[v] = true
+		}
+		return true
+	})
+	if len(seen) != 1000 {
+		t.Errorf("Expected %d distinct values, Found %d", 1000, len(seen))
+	}
+}
+
+func TestCollectorClose(t *testing.T) {
+	c := NewCollector(10)
+	c.memSize = 0
+	c.add("id", "v0")
+	name := c.lists["id"].file.Name()
+
+	c.Close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Spill file should be removed: %s", name)
+	}
+}
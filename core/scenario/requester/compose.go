@@ -0,0 +1,230 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"go.ddosify.com/ddosify/core/scenario/scripting"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// composeFuncs are the helpers of the composed bodies to read and mutate the captured JSON documents.
+var composeFuncs = template.FuncMap{
+	"json_get":    jsonGet,
+	"json_set":    jsonSet,
+	"json_delete": jsonDelete,
+}
+
+// composeError is an error of a helper of the composed bodies, reported without the position in the template.
+type composeError struct {
+	msg string
+}
+
+func (e *composeError) Error() string {
+	return e.msg
+}
+
+func composeErrorf(format string, a ...interface{}) error {
+	return &composeError{msg: fmt.Sprintf(format, a...)}
+}
+
+// bodyComposer renders a body composed from the values captured by the previous steps of the iteration,
+// like {{ json_set .order "status" "CONFIRMED" }}. The dynamic variables are rendered by the same template.
+// The urls referring to the captured values are composed by it too.
+type bodyComposer struct {
+	tmpl  *template.Template
+	names []string
+
+	// Template refers to an iteration variable, it is rendered by a clone with the values of the request
+	iteration bool
+
+	// Composed part of the request in the errors, the body or the url
+	part string
+}
+
+// newBodyComposer returns the composer of the payload, or nil if the payload doesn't refer to a captured value.
+func newBodyComposer(payload string, vi *scripting.VariableInjector) (*bodyComposer, error) {
+	return newComposer("body", payload, vi)
+}
+
+// newURLComposer returns the composer of the url, or nil if the url doesn't refer to a captured value.
+func newURLComposer(rawURL string, vi *scripting.VariableInjector) (*bodyComposer, error) {
+	return newComposer("url", rawURL, vi)
+}
+
+func newComposer(part, text string, vi *scripting.VariableInjector) (*bodyComposer, error) {
+	names, err := types.ParseBodyCaptures(text)
+	if err != nil || names == nil {
+		return nil, err
+	}
+
+	t, err := template.New(part).Funcs(vi.Funcs()).Funcs(composeFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %v", part, err)
+	}
+	return &bodyComposer{tmpl: t, names: names, part: part, iteration: scripting.HasIterationVariable(text)}, nil
+}
+
+func (c *bodyComposer) compose(captures map[string]string, v scripting.IterationValues) (string, error) {
+	for _, name := range c.names {
+		if _, ok := captures[name]; !ok {
+			return "", fmt.Errorf("%s refers to a value that is not captured: %s", c.part, name)
+		}
+	}
+
+	tmpl := c.tmpl
+	if c.iteration {
+		// Clone has its own functions, so the concurrent requests don't share the values
+		var err error
+		if tmpl, err = c.tmpl.Clone(); err != nil {
+			return "", fmt.Errorf("%s composition failed: %v", c.part, err)
+		}
+		tmpl.Funcs(scripting.IterationFuncs(v))
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, captures); err != nil {
+		var ce *composeError
+		if errors.As(err, &ce) {
+			return "", ce
+		}
+		return "", fmt.Errorf("%s composition failed: %v", c.part, err)
+	}
+	return b.String(), nil
+}
+
+// jsonGet returns the value at the path of the JSON document. Strings are returned as they are, the others as JSON.
+func jsonGet(doc, path string) (string, error) {
+	root, err := decodeJSONDoc("json_get", doc)
+	if err != nil {
+		return "", err
+	}
+	v, ok := lookupJSONPath(root, path)
+	if !ok {
+		return "", composeErrorf("json_get: path not found: %s", path)
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	return encodeJSONDoc(v)
+}
+
+// jsonSet returns the JSON document with the value set at the path. The missing keys of the objects are created,
+// the indexes of the arrays should exist.
+func jsonSet(doc, path string, value interface{}) (string, error) {
+	root, err := decodeJSONDoc("json_set", doc)
+	if err != nil {
+		return "", err
+	}
+	if root, err = setJSONPath(root, strings.Split(path, "."), value, path); err != nil {
+		return "", err
+	}
+	return encodeJSONDoc(root)
+}
+
+// jsonDelete returns the JSON document without the value at the path. Missing paths are ignored.
+func jsonDelete(doc, path string) (string, error) {
+	root, err := decodeJSONDoc("json_delete", doc)
+	if err != nil {
+		return "", err
+	}
+	return encodeJSONDoc(deleteJSONPath(root, strings.Split(path, ".")))
+}
+
+func setJSONPath(node interface{}, keys []string, value interface{}, path string) (interface{}, error) {
+	if len(keys) == 0 {
+		return value, nil
+	}
+
+	key := keys[0]
+	switch n := node.(type) {
+	case nil:
+		v, err := setJSONPath(nil, keys[1:], value, path)
+		return map[string]interface{}{key: v}, err
+	case map[string]interface{}:
+		v, err := setJSONPath(n[key], keys[1:], value, path)
+		n[key] = v
+		return n, err
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(n) {
+			return nil, composeErrorf("json_set: index out of range: %s", path)
+		}
+		if n[i], err = setJSONPath(n[i], keys[1:], value, path); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, composeErrorf("json_set: path is not an object or an array: %s", path)
+}
+
+func deleteJSONPath(node interface{}, keys []string) interface{} {
+	key := keys[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(keys) == 1 {
+			delete(n, key)
+		} else if v, ok := n[key]; ok {
+			n[key] = deleteJSONPath(v, keys[1:])
+		}
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(n) {
+			return n
+		}
+		if len(keys) == 1 {
+			return append(n[:i:i], n[i+1:]...)
+		}
+		n[i] = deleteJSONPath(n[i], keys[1:])
+	}
+	return node
+}
+
+func decodeJSONDoc(name, doc string) (interface{}, error) {
+	var root interface{}
+	d := json.NewDecoder(strings.NewReader(doc))
+	d.UseNumber()
+	if err := d.Decode(&root); err != nil {
+		return nil, composeErrorf("%s: value is not a valid json", name)
+	}
+	return root, nil
+}
+
+// encodeJSONDoc encodes the document without escaping the HTML characters, the keys of the objects are sorted.
+func encodeJSONDoc(v interface{}) (string, error) {
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,102 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/scenario/scripting"
+)
+
+func TestBodyComposer(t *testing.T) {
+	t.Parallel()
+
+	order := `{"id": 7, "status": "PENDING", "total": 12.50, "items": [{"sku": "a", "qty": 1}, {"sku": "b", "qty": 2}]}`
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+		errMsg   string
+	}{
+		{"Passthrough", `{{ .order }}`, order, ""},
+		{"Set", `{{ json_set .order "status" "CONFIRMED" }}`,
+			`{"id":7,"items":[{"qty":1,"sku":"a"},{"qty":2,"sku":"b"}],"status":"CONFIRMED","total":12.50}`, ""},
+		{"SetTwoFields", `{{ json_set (json_set .order "status" "CONFIRMED") "items.1.qty" 3 }}`,
+			`{"id":7,"items":[{"qty":1,"sku":"a"},{"qty":3,"sku":"b"}],"status":"CONFIRMED","total":12.50}`, ""},
+		{"SetNewObject", `{{ json_set .order "meta.source" "<ddosify>" }}`,
+			`{"id":7,"items":[{"qty":1,"sku":"a"},{"qty":2,"sku":"b"}],"meta":{"source":"<ddosify>"},"status":"PENDING","total":12.50}`, ""},
+		{"Delete", `{{ json_delete (json_delete .order "items.0") "total" }}`,
+			`{"id":7,"items":[{"qty":2,"sku":"b"}],"status":"PENDING"}`, ""},
+		{"DeleteMissing", `{{ json_delete .order "coupon.code" }}`,
+			`{"id":7,"items":[{"qty":1,"sku":"a"},{"qty":2,"sku":"b"}],"status":"PENDING","total":12.50}`, ""},
+		{"Get", `{"order": {{ json_get .order "id" }}, "sku": "{{ json_get .order "items.1.sku" }}"}`,
+			`{"order": 7, "sku": "b"}`, ""},
+		{"DynamicVariable", `{{ json_set .order "id" _randomInt }}`, "", ""},
+		{"IterationVariables", `{"order": {{ json_get .order "id" }}, "it": "{{_iterationId}}-{{_vuId}}-{{_stepId}}"}`,
+			`{"order": 7, "it": "42-3-2"}`, ""},
+		{"NotCaptured", `{{ .cart }}`, "", "body refers to a value that is not captured: cart"},
+		{"IndexOutOfRange", `{{ json_set .order "items.5.qty" 1 }}`, "", "json_set: index out of range: items.5.qty"},
+		{"NotContainer", `{{ json_set .order "status.code" 1 }}`, "",
+			"json_set: path is not an object or an array: status.code"},
+		{"PathNotFound", `{{ json_get .order "coupon" }}`, "", "json_get: path not found: coupon"},
+		{"InvalidJSON", `{{ json_set .token "status" "CONFIRMED" }}`, "", "json_set: value is not a valid json"},
+	}
+
+	vi := &scripting.VariableInjector{}
+	vi.Init(rand.New(rand.NewSource(1)))
+	captures := map[string]string{"order": order, "token": "abc"}
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := newBodyComposer(test.payload, vi)
+			if err != nil {
+				t.Fatalf("Error occurred %v", err)
+			}
+			body, err := c.compose(captures, scripting.IterationValues{IterationID: 42, VUID: 3, StepID: 2})
+			if test.errMsg != "" {
+				if err == nil || err.Error() != test.errMsg {
+					t.Errorf("Error Expected %q, Found %v", test.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error occurred %v", err)
+			}
+			if test.expected != "" && body != test.expected {
+				t.Errorf("Expected %s, Found %s", test.expected, body)
+			}
+			if strings.Contains(body, "{{") {
+				t.Errorf("Template should be rendered, Found %s", body)
+			}
+		})
+	}
+
+	if c, err := newBodyComposer(`{"token": "{{token}}"}`, vi); c != nil || err != nil {
+		t.Errorf("Bodies that don't refer to a captured value should not be composed, Found %v, %v", c, err)
+	}
+	if _, err := newBodyComposer(`{{ json_sets .order "status" "CONFIRMED" }}`, vi); err == nil {
+		t.Errorf("Undefined functions should be errored")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Validators are the cache validators of the responses received by a virtual user, kept across the iterations it
+// runs so its later requests of the steps with conditional_requests revalidate them. They are kept by the URL of the
+// request after the templating, so each rendered URL of a step has its own validators.
+type Validators struct {
+	// Steps of a parallel group revalidate concurrently
+	mu    sync.Mutex
+	byURL map[string]validator
+}
+
+// validator is the ETag and the Last-Modified of the last response to a URL, empty if the response doesn't have it
+type validator struct {
+	etag         string
+	lastModified string
+}
+
+// NewValidators returns the empty validators of a virtual user.
+func NewValidators() *Validators {
+	return &Validators{byURL: make(map[string]validator)}
+}
+
+// apply sets the validators of the URL as the conditional headers of the request, unless the step sets the headers
+// itself. Returns whether the request is conditional, it is not for the first request to the URL.
+func (v *Validators) apply(url string, req *http.Request) bool {
+	v.mu.Lock()
+	val, ok := v.byURL[url]
+	v.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	conditional := false
+	if val.etag != "" && req.Header.Get("If-None-Match") == "" {
+		req.Header.Set("If-None-Match", val.etag)
+		conditional = true
+	}
+	if val.lastModified != "" && req.Header.Get("If-Modified-Since") == "" {
+		req.Header.Set("If-Modified-Since", val.lastModified)
+		conditional = true
+	}
+	return conditional
+}
+
+// keep records the validators of the response to the URL. A successful response replaces the validators of the URL,
+// a 304 response updates the ones it sends again and the other responses don't change them.
+func (v *Validators) keep(url string, res *http.Response) {
+	val := validator{etag: res.Header.Get("ETag"), lastModified: res.Header.Get("Last-Modified")}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		prev := v.byURL[url]
+		if val.etag == "" {
+			val.etag = prev.etag
+		}
+		if val.lastModified == "" {
+			val.lastModified = prev.lastModified
+		}
+		v.byURL[url] = val
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		if val == (validator{}) {
+			delete(v.byURL, url)
+			return
+		}
+		v.byURL[url] = val
+	}
+}
+
+// revalidate makes the request conditional by the validators of the virtual user for its URL, and returns whether it
+// is conditional. The header of the request should be its own copy.
+func (it *Iteration) revalidate(req *http.Request) bool {
+	if it == nil || it.Validators == nil {
+		return false
+	}
+	return it.Validators.apply(req.URL.String(), req)
+}
+
+// keepValidators records the validators of the response to the request for the next requests of the virtual user.
+func (it *Iteration) keepValidators(req *http.Request, res *http.Response) {
+	if it == nil || it.Validators == nil || res == nil {
+		return
+	}
+	it.Validators.keep(req.URL.String(), res)
+}
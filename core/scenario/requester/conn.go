@@ -0,0 +1,151 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+)
+
+// byteCounter counts the bytes sent and received over the connections of a transport, including the headers,
+// the TLS handshakes and the failed requests.
+type byteCounter struct {
+	sent     int64
+	received int64
+
+	// Connections closed by the server, and the ones of them not replaced by a new connection yet
+	serverCloses int64
+	unreplaced   int64
+}
+
+// dialContext returns a dialer like the default one of http.Transport, whose connections are counted by c.
+func (c *byteCounter) dialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: conn, counter: c}, nil
+	}
+}
+
+// unixSocketDial returns a dialer that connects to the unix socket at path instead of the address of the request.
+// The URL of the request still decides the Host header and the TLS server name, and no DNS lookup is made.
+func unixSocketDial(dial dialFunc, path string) dialFunc {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dial(ctx, "unix", path)
+	}
+}
+
+// claim returns the bytes counted since the previous claim. Since the connections are reused by the requests and the
+// transport reads ahead on the idle ones, a request may claim a few bytes of another request of the same step,
+// but each byte is claimed exactly once.
+func (c *byteCounter) claim() (sent, received int64) {
+	return atomic.SwapInt64(&c.sent, 0), atomic.SwapInt64(&c.received, 0)
+}
+
+// claimServerCloses returns the connections closed by the server since the previous claim.
+func (c *byteCounter) claimServerCloses() int64 {
+	return atomic.SwapInt64(&c.serverCloses, 0)
+}
+
+// replace reports whether a new connection replaces a connection closed by the server, each closed connection is
+// replaced once.
+func (c *byteCounter) replace() bool {
+	for {
+		n := atomic.LoadInt64(&c.unreplaced)
+		if n == 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&c.unreplaced, n, n-1) {
+			return true
+		}
+	}
+}
+
+type countingConn struct {
+	net.Conn
+	counter *byteCounter
+
+	// Requests sent over the connection, the streams of an h2 connection. clientCloses is set once a request asks
+	// the server to close the connection, and serverClosed once the server closes it otherwise.
+	requests     int64
+	clientCloses int32
+	serverClosed int32
+}
+
+// countedConn returns the counting connection under the TLS and the network shaping layers of the connection, nil
+// if the connection is not dialed by a byteCounter, like the one of a custom dialer.
+func countedConn(conn net.Conn) *countingConn {
+	for {
+		switch c := conn.(type) {
+		case *countingConn:
+			return c
+		case *tls.Conn:
+			conn = c.NetConn()
+		case *shapedConn:
+			conn = c.Conn
+		default:
+			return nil
+		}
+	}
+}
+
+// countRequest counts a request sent over the connection and returns its position on the connection, 1 for the
+// first one. Closes is set if the request asks the server to close the connection after its response.
+func (c *countingConn) countRequest(closes bool) int64 {
+	if closes {
+		atomic.StoreInt32(&c.clientCloses, 1)
+	}
+	return atomic.AddInt64(&c.requests, 1)
+}
+
+// closedByServer counts the connection as closed by the server once, unless a request asked for the close.
+func (c *countingConn) closedByServer() {
+	if atomic.LoadInt32(&c.clientCloses) == 1 || !atomic.CompareAndSwapInt32(&c.serverClosed, 0, 1) {
+		return
+	}
+	atomic.AddInt64(&c.counter.serverCloses, 1)
+	atomic.AddInt64(&c.counter.unreplaced, 1)
+}
+
+// Read counts the end of the stream as the close of the server, the client closing the connection itself gets
+// net.ErrClosed instead.
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.counter.received, int64(n))
+	if err != nil && (errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET)) {
+		c.closedByServer()
+	}
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.counter.sent, int64(n))
+	return n, err
+}
@@ -0,0 +1,61 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Connections are the HTTP clients of a virtual user or of an iteration, depending on the connection scope of the
+// scenario. The client of each step is created on the first use and has its own connections apart from the shared
+// client of the step. They are used by one iteration at a time.
+type Connections struct {
+	// Steps of a parallel group get their clients concurrently
+	mu      sync.Mutex
+	clients map[*HttpRequester]*http.Client
+}
+
+// NewConnections returns the empty connections of a virtual user or an iteration.
+func NewConnections() *Connections {
+	return &Connections{clients: make(map[*HttpRequester]*http.Client)}
+}
+
+// Close closes the connections. Once the iteration using them ends, all of them are idle.
+func (c *Connections) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, client := range c.clients {
+		client.CloseIdleConnections()
+	}
+}
+
+// client returns the client of the step, creating it on the first use.
+func (c *Connections) client(h *HttpRequester) *http.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	client, ok := c.clients[h]
+	if !ok {
+		client = h.newClient()
+		c.clients[h] = client
+	}
+	return client
+}
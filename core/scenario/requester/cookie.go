@@ -0,0 +1,161 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// cookieAssertion checks the cookies set by the responses of a step against its cookie-assertions.
+type cookieAssertion struct {
+	assertions []types.CookieAssertion
+}
+
+// newCookieAssertion returns nil if the step has no cookie-assertions.
+// Keys are already validated in types.scenario.validate().
+func newCookieAssertion(custom map[string]interface{}) (*cookieAssertion, error) {
+	val, ok := custom["cookie-assertions"]
+	if !ok {
+		return nil, nil
+	}
+
+	assertions, err := types.ParseCookieAssertions(val)
+	if err != nil {
+		return nil, err
+	}
+	return &cookieAssertion{assertions: assertions}, nil
+}
+
+// check returns the first mismatched attribute of the cookies as an assertion error.
+func (a *cookieAssertion) check(header http.Header) (types.RequestError, bool) {
+	for _, c := range a.assertions {
+		cookie, ok := lastSetCookie(header, c.Name)
+		if !ok {
+			return types.RequestError{
+				Type:   types.ErrorAssertion,
+				Reason: fmt.Sprintf("cookie assertion failed: %s is not set", c.Name),
+			}, false
+		}
+		if mismatch := matchCookie(c, parseCookieAttributes(cookie.Raw)); mismatch != "" {
+			return types.RequestError{
+				Type:   types.ErrorAssertion,
+				Reason: fmt.Sprintf("cookie assertion failed: %s %s", c.Name, mismatch),
+			}, false
+		}
+	}
+	return types.RequestError{}, true
+}
+
+// matchCookie returns the mismatch of the first attribute that doesn't match the assertion, empty if all match.
+func matchCookie(c types.CookieAssertion, attrs map[string]string) string {
+	flag := func(name string, expected bool) string {
+		if _, set := attrs[strings.ToLower(name)]; set != expected {
+			if expected {
+				return name + " is missing"
+			}
+			return name + " is not expected"
+		}
+		return ""
+	}
+	if c.Secure != nil {
+		if m := flag("Secure", *c.Secure); m != "" {
+			return m
+		}
+	}
+	if c.HttpOnly != nil {
+		if m := flag("HttpOnly", *c.HttpOnly); m != "" {
+			return m
+		}
+	}
+	value := func(name, expected string, equal func(v string) bool) string {
+		if v, ok := attrs[strings.ToLower(name)]; !ok {
+			return fmt.Sprintf("%s is missing, expected %s", name, expected)
+		} else if !equal(v) {
+			return fmt.Sprintf("%s is %s, expected %s", name, v, expected)
+		}
+		return ""
+	}
+	if c.SameSite != "" {
+		if m := value("SameSite", c.SameSite, func(v string) bool { return strings.EqualFold(v, c.SameSite) }); m != "" {
+			return m
+		}
+	}
+	if c.MinMaxAge != nil || c.MaxMaxAge != nil {
+		v, ok := attrs["max-age"]
+		if !ok {
+			return "Max-Age is missing"
+		}
+		maxAge, err := strconv.ParseInt(v, 10, 64)
+		switch {
+		case err != nil:
+			return fmt.Sprintf("Max-Age is not valid: %s", v)
+		case c.MinMaxAge != nil && c.MaxMaxAge != nil && *c.MinMaxAge == *c.MaxMaxAge && maxAge != *c.MinMaxAge:
+			return fmt.Sprintf("Max-Age is %d, expected %d", maxAge, *c.MinMaxAge)
+		case c.MinMaxAge != nil && maxAge < *c.MinMaxAge:
+			return fmt.Sprintf("Max-Age is %d, expected at least %d", maxAge, *c.MinMaxAge)
+		case c.MaxMaxAge != nil && maxAge > *c.MaxMaxAge:
+			return fmt.Sprintf("Max-Age is %d, expected at most %d", maxAge, *c.MaxMaxAge)
+		}
+	}
+	if c.Path != "" {
+		if m := value("Path", c.Path, func(v string) bool { return v == c.Path }); m != "" {
+			return m
+		}
+	}
+	if c.Domain != "" {
+		// Leading dot of a domain is ignored by RFC 6265
+		domain := strings.TrimPrefix(c.Domain, ".")
+		return value("Domain", c.Domain, func(v string) bool { return strings.EqualFold(strings.TrimPrefix(v, "."), domain) })
+	}
+	return ""
+}
+
+// lastSetCookie returns the last cookie of the name set by the response, like the browsers keep it.
+// Set-Cookie headers are parsed by net/http, the invalid ones are ignored.
+func lastSetCookie(header http.Header, name string) (*http.Cookie, bool) {
+	var last *http.Cookie
+	for _, c := range (&http.Response{Header: header}).Cookies() {
+		if c.Name == name {
+			last = c
+		}
+	}
+	return last, last != nil
+}
+
+// parseCookieAttributes returns the attributes of a Set-Cookie line keyed by their lowercase names, the flags
+// like Secure have empty values. net/http folds the attributes into the cookie fields, losing whether Max-Age
+// is set and the exact values of the attributes, so they are read from the raw line.
+func parseCookieAttributes(raw string) map[string]string {
+	parts := strings.Split(raw, ";")
+	attrs := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		key, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			attrs[key] = strings.TrimSpace(val)
+		}
+	}
+	return attrs
+}
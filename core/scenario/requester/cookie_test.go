@@ -0,0 +1,168 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func newCookieStep(url string, custom map[string]interface{}) types.ScenarioStep {
+	return types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      url,
+		Timeout:  types.DefaultTimeout,
+		Custom:   custom,
+	}
+}
+
+func TestSendCookieAssertions(t *testing.T) {
+	responses := [][]string{
+		{"session=s1; Path=/; Max-Age=3600; Secure; HttpOnly; SameSite=Lax"},
+		{"session=s1; Path=/; Max-Age=3600; Secure; HttpOnly; SameSite=None"},
+		{"session=s1; Path=/; Max-Age=3600; HttpOnly; SameSite=Lax"},
+		{"session=s1; Path=/; Max-Age=30; Secure; HttpOnly; SameSite=Lax"},
+		{"session=s1; Path=/; Expires=Wed, 21 Oct 2026 07:28:00 GMT; Secure; HttpOnly; SameSite=Lax"},
+		{"session=s1; Path=/; Max-Age=3600; Secure; SameSite=Lax"},
+		{"theme=dark"},
+		// The last one of the cookie is asserted
+		{"session=s0; Max-Age=10", "session=s1; Path=/; Max-Age=7200; Secure; HttpOnly; samesite=lax"},
+		{"session=s1; Path=/app; Max-Age=3600; Secure; HttpOnly; SameSite=Lax"},
+	}
+	var reqCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, c := range responses[atomic.AddInt32(&reqCount, 1)-1] {
+			w.Header().Add("Set-Cookie", c)
+		}
+	}))
+	defer server.Close()
+
+	s := newCookieStep(server.URL, map[string]interface{}{
+		"cookie-assertions": map[string]interface{}{
+			"session": map[string]interface{}{
+				"secure":    true,
+				"http_only": true,
+				"same_site": "Lax",
+				"max_age":   map[string]interface{}{"min": 60.0, "max": 86400.0},
+				"path":      "/",
+			},
+		},
+	})
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	expected := []string{
+		"",
+		"cookie assertion failed: session SameSite is None, expected Lax",
+		"cookie assertion failed: session Secure is missing",
+		"cookie assertion failed: session Max-Age is 30, expected at least 60",
+		"cookie assertion failed: session Max-Age is missing",
+		"cookie assertion failed: session HttpOnly is missing",
+		"cookie assertion failed: session is not set",
+		"",
+		"cookie assertion failed: session Path is /app, expected /",
+	}
+	for i, e := range expected {
+		expectedErr := types.RequestError{}
+		if e != "" {
+			expectedErr = types.RequestError{Type: types.ErrorAssertion, Reason: e}
+		}
+		if res := h.Send(&Iteration{}); res.Err != expectedErr {
+			t.Errorf("%d. Expected %#v, Found %#v", i, expectedErr, res.Err)
+		}
+	}
+}
+
+func TestMatchCookie(t *testing.T) {
+	t.Parallel()
+
+	exact, no := int64(300), false
+	tests := []struct {
+		name      string
+		assertion types.CookieAssertion
+		raw       string
+		expected  string
+	}{
+		{"ExactMaxAge", types.CookieAssertion{MinMaxAge: &exact, MaxMaxAge: &exact}, "a=1; Max-Age=300", ""},
+		{"ExactMaxAgeMismatch", types.CookieAssertion{MinMaxAge: &exact, MaxMaxAge: &exact}, "a=1; Max-Age=301",
+			"Max-Age is 301, expected 300"},
+		{"MaxAgeOverMax", types.CookieAssertion{MaxMaxAge: &exact}, "a=1; Max-Age=600",
+			"Max-Age is 600, expected at most 300"},
+		{"InvalidMaxAge", types.CookieAssertion{MaxMaxAge: &exact}, "a=1; Max-Age=1h", "Max-Age is not valid: 1h"},
+		{"NotSecure", types.CookieAssertion{Secure: &no}, "a=1; Secure", "Secure is not expected"},
+		{"NotSecureMatch", types.CookieAssertion{Secure: &no}, "a=1; HttpOnly", ""},
+		{"SameSiteMissing", types.CookieAssertion{SameSite: "Strict"}, "a=1", "SameSite is missing, expected Strict"},
+		{"DomainLeadingDot", types.CookieAssertion{Domain: "example.com"}, "a=1; Domain=.Example.com", ""},
+		{"DomainMismatch", types.CookieAssertion{Domain: "example.com"}, "a=1; Domain=api.example.com",
+			"Domain is api.example.com, expected example.com"},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if m := matchCookie(test.assertion, parseCookieAttributes(test.raw)); m != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, m)
+			}
+		})
+	}
+}
+
+func TestSendCookieCaptures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "csrf=old")
+		w.Header().Add("Set-Cookie", "csrf=t0k3n; Path=/; SameSite=Strict")
+	}))
+	defer server.Close()
+
+	s := newCookieStep(server.URL, map[string]interface{}{
+		"capture": map[string]interface{}{
+			"csrf":    map[string]interface{}{"cookie": "csrf"},
+			"missing": map[string]interface{}{"cookie": "session"},
+		},
+		"disable-cookies": true,
+	})
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	if h.capturesNeedBody {
+		t.Errorf("Cookie captures should not keep the body")
+	}
+
+	it := &Iteration{}
+	res := h.Send(it)
+	expected := map[string]string{"csrf": "t0k3n"}
+	if res.Err.Type != "" || !reflect.DeepEqual(it.Captures, expected) {
+		t.Errorf("Captures Expected %v, Found %v, err: %v", expected, it.Captures, res.Err)
+	}
+}
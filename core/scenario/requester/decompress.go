@@ -0,0 +1,202 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decoders are pooled, since creating them on each response allocates their windows and tables again.
+var (
+	gzipReaders   sync.Pool
+	brotliReaders sync.Pool
+	zstdReaders   sync.Pool
+)
+
+// decompressError is the error of decoding a response body whose bytes are received without an error.
+type decompressError struct {
+	encoding string
+	err      error
+}
+
+func (e *decompressError) Error() string {
+	return fmt.Sprintf("%s: %v", e.reason(), e.err)
+}
+
+// reason returns the error reason of the report, without the detail of the decoder.
+func (e *decompressError) reason() string {
+	return fmt.Sprintf("invalid %s response body", e.encoding)
+}
+
+// contentEncoding returns the content coding of the response if the requester can decompress it, otherwise "".
+func contentEncoding(res *http.Response) string {
+	switch e := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))); e {
+	case "gzip", "x-gzip":
+		return "gzip"
+	case "br", "zstd":
+		return e
+	}
+	return ""
+}
+
+// wireReader counts the bytes of a compressed body as they are received, and the time spent on waiting for them.
+type wireReader struct {
+	r       io.Reader
+	n       int64
+	elapsed time.Duration
+	err     error
+}
+
+func (w *wireReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := w.r.Read(p)
+	w.elapsed += since(start)
+	w.n += int64(n)
+	if err != nil && err != io.EOF {
+		w.err = err
+	}
+	return n, err
+}
+
+// compressedBody decompresses a response body while it is read, so the assertions and the captures of the step
+// work on the decompressed body. The time spent on decoding is measured apart from the time spent on the wire.
+type compressedBody struct {
+	encoding string
+	body     io.ReadCloser
+	wire     wireReader
+
+	decoder io.Reader
+	release func()
+	decoded int64
+	elapsed time.Duration
+}
+
+// newCompressedBody returns the decompressing body of the response, or nil if the response is not compressed
+// with a supported coding.
+func newCompressedBody(res *http.Response) *compressedBody {
+	encoding := contentEncoding(res)
+	if encoding == "" {
+		return nil
+	}
+	b := &compressedBody{encoding: encoding, body: res.Body}
+	b.wire.r = res.Body
+	return b
+}
+
+func (b *compressedBody) Read(p []byte) (n int, err error) {
+	start := time.Now()
+	defer func() {
+		b.elapsed += since(start)
+		b.decoded += int64(n)
+	}()
+
+	if b.decoder == nil {
+		if b.decoder, b.release, err = newDecoder(b.encoding, &b.wire); err != nil {
+			b.decoder = nil
+			// Bodies of the responses like 204 and 304 are empty, although they carry the coding of the resource.
+			if b.wire.n == 0 && b.wire.err == nil && errors.Is(err, io.EOF) {
+				return 0, io.EOF
+			}
+			return 0, b.readErr(err)
+		}
+	}
+
+	n, err = b.decoder.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.readErr(err)
+	}
+	return n, err
+}
+
+// readErr returns the error of the wire if there is one, so the timeouts and the truncated bodies are reported
+// as they are. Otherwise the body is not a valid stream of its coding.
+func (b *compressedBody) readErr(err error) error {
+	if b.wire.err != nil {
+		return b.wire.err
+	}
+	return &decompressError{encoding: b.encoding, err: err}
+}
+
+func (b *compressedBody) Close() error {
+	if b.release != nil {
+		b.release()
+		b.release = nil
+	}
+	return b.body.Close()
+}
+
+// decompressDuration returns the time spent on decoding the body, excluding the time spent on waiting for the server.
+func (b *compressedBody) decompressDuration() time.Duration {
+	if d := b.elapsed - b.wire.elapsed; d > 0 {
+		return d
+	}
+	return 0
+}
+
+// newDecoder returns a pooled decoder of the encoding reading from r, and the function putting it back to the pool.
+func newDecoder(encoding string, r io.Reader) (io.Reader, func(), error) {
+	switch encoding {
+	case "gzip":
+		zr, _ := gzipReaders.Get().(*gzip.Reader)
+		var err error
+		if zr == nil {
+			zr, err = gzip.NewReader(r)
+		} else {
+			err = zr.Reset(r)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, func() { gzipReaders.Put(zr) }, nil
+	case "br":
+		br, _ := brotliReaders.Get().(*brotli.Reader)
+		if br == nil {
+			br = brotli.NewReader(r)
+		} else if err := br.Reset(r); err != nil {
+			return nil, nil, err
+		}
+		return br, func() { brotliReaders.Put(br) }, nil
+	case "zstd":
+		zr, _ := zstdReaders.Get().(*zstd.Decoder)
+		if zr == nil {
+			// Decodes on the goroutine of the request, no background decoders are started.
+			var err error
+			if zr, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true)); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := zr.Reset(r); err != nil {
+			return nil, nil, err
+		}
+		return zr, func() { zstdReaders.Put(zr) }, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+}
@@ -0,0 +1,95 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// duplicator sends a share of the requests of a step twice to test the idempotency of the target. The duplicate is
+// sent once the response of the original is read, with the same rendered bytes, so the idempotency keys and the
+// signatures in it are the ones of the original. Its response is compared with the one of the original.
+type duplicator struct {
+	rate float64
+
+	// Count of the requests that are duplicated or not
+	requests int64
+}
+
+// newDuplicator returns the duplicator of the duplicate rate of the step, nil if the step has none.
+func newDuplicator(rate float64) *duplicator {
+	if rate <= 0 {
+		return nil
+	}
+	return &duplicator{rate: rate}
+}
+
+// sampled decides whether the next request is sent twice.
+func (d *duplicator) sampled() bool {
+	return evenlySampled(&d.requests, d.rate)
+}
+
+// send sends the duplicate of the request with the body of the original, without its trace, and compares its
+// response with the status code and the body of the original. The body of the duplicate is read like the one of the
+// original, up to the max body size, but it sets no cookies of the virtual user.
+func (d *duplicator) send(h *HttpRequester, client *http.Client, req *http.Request, body []byte, statusCode int,
+	respBody []byte) types.DuplicateResult {
+	dup := req.Clone(h.ctx)
+	dup.Body, dup.GetBody = http.NoBody, nil
+	if len(body) > 0 {
+		dup.Body = io.NopCloser(bytes.NewReader(body))
+		dup.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	// Pair is timed on the clock of the requester, so a test can advance a fake clock under the duplicate
+	var r types.DuplicateResult
+	c := h.timeSource()
+	start := c.Now()
+	res, err := client.Do(dup)
+	if err != nil {
+		r.Duration, r.Err = c.Since(start), fetchErrType(err)
+		return r
+	}
+	if h.decompress {
+		if compressed := newCompressedBody(res); compressed != nil {
+			res.Body = compressed
+		}
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, io.LimitReader(res.Body, h.maxBodySize))
+	res.Body.Close()
+	r.Duration, r.StatusCode = c.Since(start), res.StatusCode
+	if err != nil {
+		r.Err = fetchBodyErrType(err)
+		return r
+	}
+	original := sha256.Sum256(respBody)
+	r.StatusMatch = r.StatusCode == statusCode
+	r.BodyMatch = bytes.Equal(hash.Sum(nil), original[:])
+	return r
+}
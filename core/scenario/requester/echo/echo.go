@@ -0,0 +1,132 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package echo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"go.ddosify.com/ddosify/core/scenario/requester"
+	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+)
+
+// Protocol is the protocol of the steps sent by the echo requester, like "echo://localhost" as the url of the step.
+const Protocol = "ECHO"
+
+func init() {
+	requester.Register(Protocol, func() requester.Requester { return &Requester{} })
+}
+
+var metricMeta = []types.MetricMeta{
+	{Key: "delayDuration", Name: "Delay", JSONKey: "delay"},
+	{Key: "byteCount", Name: "Bytes", JSONKey: "bytes"},
+}
+
+// Requester is a toy requester answering each request of the step with its own headers and payload, after the
+// delay given by the "echo-delay" option in milliseconds. It is the example of the requesters registered out of the
+// tree, a program sends echo steps once it imports this package.
+type Requester struct {
+	ctx   context.Context
+	step  types.ScenarioStep
+	delay time.Duration
+	debug bool
+}
+
+// Init keeps the step, the echo requester has no proxy, so the proxy url is ignored.
+func (e *Requester) Init(ctx context.Context, ss types.ScenarioStep, proxyAddr *url.URL, debug bool) error {
+	e.ctx = ctx
+	e.step = ss
+	e.debug = debug
+	if val, ok := ss.Custom["echo-delay"]; ok {
+		ms, isNum := util.ToFloat64(val)
+		if !isNum || ms < 0 {
+			return fmt.Errorf("echo-delay should be a non negative number of milliseconds: %v", val)
+		}
+		e.delay = time.Duration(ms * float64(time.Millisecond))
+	}
+	return nil
+}
+
+// Send echoes the request of the step once the delay is passed, or fails once the ctx is done.
+func (e *Requester) Send(it *requester.Iteration) *types.ScenarioStepResult {
+	res := &types.ScenarioStepResult{
+		StepID:      e.step.ID,
+		StepName:    e.step.Name,
+		RequestID:   uuid.New(),
+		RequestTime: time.Now(),
+		Custom:      make(map[string]interface{}),
+	}
+
+	if err := e.wait(); err != nil {
+		res.Err = types.RequestError{Type: types.ErrorIntented, Reason: types.ReasonCtxCanceled}
+	} else {
+		res.StatusCode = http.StatusOK
+		res.ContentLength = int64(len(e.step.Payload))
+		res.Custom["byteCount"] = res.ContentLength
+	}
+	res.Duration = time.Since(res.RequestTime)
+	res.Custom["delayDuration"] = res.Duration
+
+	if e.debug {
+		headers := make(http.Header, len(e.step.Headers))
+		for k, v := range e.step.Headers {
+			headers.Set(k, v)
+		}
+		res.DebugInfo = map[string]interface{}{
+			"url":            e.step.URL,
+			"method":         e.step.Method,
+			"requestHeaders": headers,
+			"requestBody":    []byte(e.step.Payload),
+		}
+		if res.Err.Type == "" {
+			res.DebugInfo["responseHeaders"] = headers
+			res.DebugInfo["responseBody"] = []byte(e.step.Payload)
+		}
+	}
+	return res
+}
+
+// wait waits for the delay, it returns the error of the ctx if it is done before.
+func (e *Requester) wait() error {
+	if err := e.ctx.Err(); err != nil || e.delay == 0 {
+		return err
+	}
+	t := time.NewTimer(e.delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-e.ctx.Done():
+		return e.ctx.Err()
+	}
+}
+
+// Done does nothing, the echo requester holds no resources.
+func (e *Requester) Done() {}
+
+// MetricMeta declares the waited delay and the echoed bytes.
+func (e *Requester) MetricMeta() []types.MetricMeta {
+	return metricMeta
+}
@@ -0,0 +1,90 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package echo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/scenario/requester"
+	"go.ddosify.com/ddosify/core/scenario/requester/requestertest"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestConformance(t *testing.T) {
+	requestertest.Conformance(t, types.ScenarioStep{
+		ID:       1,
+		Name:     "echo",
+		Protocol: "echo",
+		Method:   "GET",
+		URL:      "echo://localhost",
+		Headers:  map[string]string{"Content-Type": "text/plain"},
+		Payload:  "hello",
+		Custom:   map[string]interface{}{"echo-delay": 1},
+	})
+}
+
+func TestEchoDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    interface{}
+		expected time.Duration
+		errored  bool
+	}{
+		{"Default", nil, 0, false},
+		{"Milliseconds", 20, 20 * time.Millisecond, false},
+		{"Fraction", 0.5, 500 * time.Microsecond, false},
+		{"Negative", -1, 0, true},
+		{"NotNumber", "fast", 0, true},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			step := types.ScenarioStep{ID: 1, Protocol: Protocol, Payload: "hello", Custom: map[string]interface{}{}}
+			if test.delay != nil {
+				step.Custom["echo-delay"] = test.delay
+			}
+			r := &Requester{}
+			err := r.Init(context.Background(), step, nil, false)
+			if test.errored {
+				if err == nil {
+					t.Errorf("Expected error for echo-delay %v", test.delay)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if r.delay != test.expected {
+				t.Errorf("Expected delay %s, Found %s", test.expected, r.delay)
+			}
+
+			res := r.Send(requester.NewIteration(0, 1))
+			if res.Duration < test.expected {
+				t.Errorf("Expected duration of at least %s, Found %s", test.expected, res.Duration)
+			}
+			if res.Custom["byteCount"] != int64(5) {
+				t.Errorf("Expected byteCount 5, Found %v", res.Custom["byteCount"])
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
@@ -0,0 +1,218 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// targetFeed feeds the URL of a step from a targets file, one target per line.
+// Blank lines and the lines starting with # are ignored. The file is streamed in sequential order and
+// only the line offsets are kept in memory in random order, and in sequential order once the targets are partitioned
+// across the virtual users, so big files don't have to fit in memory. It is safe for concurrent use.
+type targetFeed struct {
+	f        *os.File
+	random   bool
+	protocol string
+
+	// Sequential order
+	mu sync.Mutex
+	r  *bufio.Reader
+
+	// Random order, positions of the target lines in the file
+	rnd     *rand.Rand
+	offsets []int64
+	lengths []int32
+
+	// Count of the targets in the file
+	count int
+
+	// Positions of the target lines for the targets partitioned across the virtual users in sequential order,
+	// indexed on the first partitioned target
+	partitionOnce sync.Once
+	partitionErr  error
+}
+
+// newTargetFeed opens the targets file. rnd must be safe for concurrent use, it picks the targets in random order.
+func newTargetFeed(path string, order string, protocol string, rnd *rand.Rand) (*targetFeed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &targetFeed{f: f, random: order == types.TargetsOrderRandom, protocol: protocol, rnd: rnd}
+	if err = t.index(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.r = bufio.NewReader(f)
+	return t, nil
+}
+
+// index scans the file once to fail early for the invalid targets and
+// keeps the target positions for the random order.
+func (t *targetFeed) index() error {
+	r := bufio.NewReader(t.f)
+	var offset int64
+	count := 0
+	for lineNum := 1; ; lineNum++ {
+		line, err := r.ReadString('\n')
+		if target := parseTargetLine(line); target != "" {
+			if u, pErr := url.Parse(t.withProtocol(target)); pErr != nil || u.Host == "" {
+				return fmt.Errorf("invalid target at line %d of the targets file: %s", lineNum, target)
+			}
+
+			count++
+			if t.random {
+				t.offsets = append(t.offsets, offset)
+				t.lengths = append(t.lengths, int32(len(line)))
+			}
+		}
+		offset += int64(len(line))
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if count == 0 {
+		return fmt.Errorf("targets file has no target: %s", t.f.Name())
+	}
+	t.count = count
+	return nil
+}
+
+// indexPositions keeps the target positions for the sequential order. The file is read at its offsets, so the
+// stream of next is not moved.
+func (t *targetFeed) indexPositions() error {
+	r := bufio.NewReader(io.NewSectionReader(t.f, 0, math.MaxInt64))
+	var offset int64
+	for {
+		line, err := r.ReadString('\n')
+		if parseTargetLine(line) != "" {
+			t.offsets = append(t.offsets, offset)
+			t.lengths = append(t.lengths, int32(len(line)))
+		}
+		offset += int64(len(line))
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// next returns the next target. Sequential order starts over at the end of the file.
+func (t *targetFeed) next() (string, error) {
+	if t.random {
+		return t.at(t.rnd.Intn(len(t.offsets)))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for {
+		line, err := t.r.ReadString('\n')
+		if target := parseTargetLine(line); target != "" {
+			return t.withProtocol(target), nil
+		}
+
+		if err == io.EOF {
+			if _, err = t.f.Seek(0, io.SeekStart); err != nil {
+				return "", err
+			}
+			t.r.Reset(t.f)
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// nextOf returns the target of an iteration of a virtual user. In sequential order, each user reads its own range of
+// the targets by types.UserIteration.Row, so the users don't share the targets. Random order is not partitioned.
+func (t *targetFeed) nextOf(u types.UserIteration) (string, error) {
+	if t.random {
+		return t.next()
+	}
+
+	t.partitionOnce.Do(func() {
+		t.partitionErr = t.indexPositions()
+	})
+	if t.partitionErr != nil {
+		return "", t.partitionErr
+	}
+	return t.at(u.Row(len(t.offsets)))
+}
+
+// at returns the target at the i-th position of the offsets.
+func (t *targetFeed) at(i int) (string, error) {
+	buf := make([]byte, t.lengths[i])
+	if _, err := t.f.ReadAt(buf, t.offsets[i]); err != nil && err != io.EOF {
+		return "", err
+	}
+	return t.withProtocol(parseTargetLine(string(buf))), nil
+}
+
+// CountTargets returns the count of the targets in a targets file of a step with the given protocol.
+func CountTargets(path string, protocol string) (int, error) {
+	t, err := newTargetFeed(path, types.TargetsOrderSequential, protocol, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer t.close()
+	return t.count, nil
+}
+
+// withProtocol prepends the protocol of the step to the targets without a scheme.
+func (t *targetFeed) withProtocol(target string) string {
+	if !strings.Contains(target, "://") {
+		return strings.ToLower(t.protocol) + "://" + target
+	}
+	return target
+}
+
+func (t *targetFeed) close() error {
+	return t.f.Close()
+}
+
+func parseTargetLine(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return ""
+	}
+	return line
+}
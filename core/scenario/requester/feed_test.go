@@ -0,0 +1,256 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+)
+
+func writeTargetsFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("targets file could not be written: %v", err)
+	}
+	return path
+}
+
+func TestTargetFeedSequential(t *testing.T) {
+	path := writeTargetsFile(t, "# cache warm list\nhttps://a.com/1\n\n  example.com/2  \r\n# skip\nhttp://b.com/3")
+	feed, err := newTargetFeed(path, types.TargetsOrderSequential, types.ProtocolHTTPS, util.NewRand(1))
+	if err != nil {
+		t.Fatalf("newTargetFeed errored: %v", err)
+	}
+	defer feed.close()
+
+	expected := []string{"https://a.com/1", "https://example.com/2", "http://b.com/3", "https://a.com/1", "https://example.com/2"}
+	for i, e := range expected {
+		target, err := feed.next()
+		if err != nil {
+			t.Fatalf("next errored: %v", err)
+		}
+		if target != e {
+			t.Errorf("Target %d Expected %s, Found %s", i, e, target)
+		}
+	}
+}
+
+func TestTargetFeedUsers(t *testing.T) {
+	path := writeTargetsFile(t, "https://a.com/1\n# skip\nhttps://a.com/2\nhttps://a.com/3\n\nhttps://a.com/4\nhttps://a.com/5")
+	feed, err := newTargetFeed(path, types.TargetsOrderSequential, types.ProtocolHTTPS, util.NewRand(1))
+	if err != nil {
+		t.Fatalf("newTargetFeed errored: %v", err)
+	}
+	defer feed.close()
+
+	// 5 targets for 2 users, the first user takes a target more and each user starts over its own targets
+	expected := [][]string{
+		{"https://a.com/1", "https://a.com/2", "https://a.com/3", "https://a.com/1"},
+		{"https://a.com/4", "https://a.com/5", "https://a.com/4", "https://a.com/5"},
+	}
+	for user, targets := range expected {
+		for i, e := range targets {
+			target, err := feed.nextOf(types.UserIteration{User: user, Users: 2, Index: i})
+			if err != nil {
+				t.Fatalf("nextOf errored: %v", err)
+			}
+			if target != e {
+				t.Errorf("User %d target %d Expected %s, Found %s", user, i, e, target)
+			}
+		}
+	}
+
+	// Stream of the iterations without a user is not moved by the users
+	if target, _ := feed.next(); target != "https://a.com/1" {
+		t.Errorf("Sequential target Expected https://a.com/1, Found %s", target)
+	}
+	if n, err := CountTargets(path, types.ProtocolHTTPS); err != nil || n != 5 {
+		t.Errorf("CountTargets Expected 5, Found %d %v", n, err)
+	}
+}
+
+func TestTargetFeedRandom(t *testing.T) {
+	path := writeTargetsFile(t, "https://a.com/1\n#https://skipped.com\nhttps://b.com/2\n")
+	feed, err := newTargetFeed(path, types.TargetsOrderRandom, types.ProtocolHTTPS, util.NewRand(1))
+	if err != nil {
+		t.Fatalf("newTargetFeed errored: %v", err)
+	}
+	defer feed.close()
+
+	seen := make(map[string]int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			target, err := feed.next()
+			if err != nil {
+				t.Errorf("next errored: %v", err)
+			}
+			mu.Lock()
+			seen[target]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 2 || seen["https://a.com/1"] == 0 || seen["https://b.com/2"] == 0 {
+		t.Errorf("Random targets should be picked from the valid lines, Found %v", seen)
+	}
+}
+
+func TestTargetFeedRandomSeed(t *testing.T) {
+	path := writeTargetsFile(t, "https://a.com/1\nhttps://b.com/2\nhttps://c.com/3\nhttps://d.com/4\n")
+	picks := func(seed int64) []string {
+		feed, err := newTargetFeed(path, types.TargetsOrderRandom, types.ProtocolHTTPS, util.NewRand(seed))
+		if err != nil {
+			t.Fatalf("newTargetFeed errored: %v", err)
+		}
+		defer feed.close()
+
+		var targets []string
+		for i := 0; i < 20; i++ {
+			target, _ := feed.next()
+			targets = append(targets, target)
+		}
+		return targets
+	}
+
+	if a, b := picks(42), picks(42); !reflect.DeepEqual(a, b) {
+		t.Errorf("Same seed should pick the same targets, Found %v and %v", a, b)
+	}
+	if a, b := picks(42), picks(43); reflect.DeepEqual(a, b) {
+		t.Errorf("Different seeds should pick different targets, Found %v", a)
+	}
+}
+
+func TestTargetFeedInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errStr  string
+	}{
+		{"Empty", "", "has no target"},
+		{"OnlyComments", "# a\n\n#b\n", "has no target"},
+		{"InvalidTarget", "https://a.com\nhttp://%zz\n", "line 2"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			path := writeTargetsFile(t, test.content)
+			_, err := newTargetFeed(path, types.TargetsOrderSequential, types.ProtocolHTTPS, util.NewRand(1))
+			if err == nil || !strings.Contains(err.Error(), test.errStr) {
+				t.Errorf("Expected error containing %q, Found %v", test.errStr, err)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+
+	if _, err := newTargetFeed("not_exists.txt", types.TargetsOrderSequential, types.ProtocolHTTPS, util.NewRand(1)); err == nil {
+		t.Errorf("Missing targets file should be errored")
+	}
+}
+
+func TestSendTargetsFile(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path] = r.Host
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	path := writeTargetsFile(t, server.URL+"/a\n"+host+"/b\n")
+
+	h := &HttpRequester{}
+	err := h.Init(context.TODO(), types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		Timeout:  types.DefaultTimeout,
+		Custom:   map[string]interface{}{"targets-file": path},
+	}, nil, false)
+	if err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	for _, p := range []string{"/a", "/b"} {
+		res := h.Send(&Iteration{})
+		if res.Err.Type != "" {
+			t.Fatalf("Send errored: %v", res.Err)
+		}
+		if res.Custom["targetURL"] != server.URL+p {
+			t.Errorf("targetURL Expected %s, Found %v", server.URL+p, res.Custom["targetURL"])
+		}
+	}
+
+	for _, p := range []string{"/a", "/b"} {
+		if hits[p] != host {
+			t.Errorf("Target %s should be requested with host %s, Found %q", p, host, hits[p])
+		}
+	}
+}
+
+func TestSendTargetsFileURLGroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	path := writeTargetsFile(t, server.URL+"/users/42/orders\n"+server.URL+"/about\n")
+
+	h := &HttpRequester{}
+	err := h.Init(context.TODO(), types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		Timeout:  types.DefaultTimeout,
+		Custom: map[string]interface{}{
+			"targets-file": path,
+			"url-groups": []interface{}{
+				map[string]interface{}{"pattern": `/users/\d+`, "group": "/users/:id"},
+			},
+		},
+	}, nil, false)
+	if err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	for _, expected := range []string{"/users/:id/orders", types.CatchAllURLGroup} {
+		res := h.Send(&Iteration{})
+		if res.Custom["endpoint"] != expected {
+			t.Errorf("Endpoint Expected %s, Found %v", expected, res.Custom["endpoint"])
+		}
+	}
+}
@@ -0,0 +1,258 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.ddosify.com/ddosify/core/scenario/xpath"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// fileCapturer writes the values of the first successful response of a step to files, for the later runs.
+// The files are written once, the responses of the other iterations are ignored. It is safe for concurrent use.
+type fileCapturer struct {
+	rules    []types.FileCapture
+	sources  []valueSource
+	needBody bool
+
+	mu      sync.Mutex
+	written int32
+}
+
+func newFileCapturer(val interface{}, namespaces map[string]string) (*fileCapturer, error) {
+	rules, err := types.ParseFileCaptures(val)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &fileCapturer{rules: rules, sources: make([]valueSource, len(rules))}
+	for i, r := range rules {
+		if c.sources[i], err = newValueSource(r.Header, r.JSONPath, r.XPath, namespaces); err != nil {
+			return nil, err
+		}
+		if r.Header == "" {
+			c.needBody = true
+		}
+	}
+	return c, nil
+}
+
+// pending reports whether the files are not written yet, the response body should be kept for them.
+func (c *fileCapturer) pending() bool {
+	return atomic.LoadInt32(&c.written) == 0
+}
+
+// capture extracts the values from the response and writes them. Values are extracted before any write,
+// so the files of a response are written all or none.
+func (c *fileCapturer) capture(body []byte, header http.Header, doc *xmlBody) error {
+	if !c.pending() {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.pending() {
+		return nil
+	}
+
+	values := make([]string, len(c.rules))
+	for i, src := range c.sources {
+		v, err := src.extract(body, header, doc)
+		if err != nil {
+			return fmt.Errorf("capture-to-file %v", err)
+		}
+		values[i] = v
+	}
+
+	for i, r := range c.rules {
+		if err := writeFileAtomic(r.ToFile, []byte(values[i])); err != nil {
+			return fmt.Errorf("capture-to-file couldn't write %s: %v", r.ToFile, err)
+		}
+	}
+	atomic.StoreInt32(&c.written, 1)
+	return nil
+}
+
+// valueSource is the location of a captured value in the response, shared by the capture and capture-to-file rules.
+// The whole body is the value if none of the fields is set. Only the capture rules have a cookie.
+type valueSource struct {
+	header   string
+	cookie   string
+	jsonPath string
+	xpath    *xpath.Expr
+}
+
+func newValueSource(header, jsonPath, xpathExpr string, namespaces map[string]string) (s valueSource, err error) {
+	s = valueSource{header: header, jsonPath: jsonPath}
+	if xpathExpr != "" {
+		s.xpath, err = xpath.Compile(xpathExpr, namespaces)
+	}
+	return
+}
+
+// extract returns the value of the response header, the value of the cookie set by the response, the value at the json path of the body, or the string value
+// of the first node matching the xpath. errInvalidXML is returned if the xpath body is not a valid XML.
+func (s valueSource) extract(body []byte, header http.Header, x *xmlBody) (string, error) {
+	if s.header != "" {
+		if _, ok := header[http.CanonicalHeaderKey(s.header)]; !ok {
+			return "", fmt.Errorf("header not found: %s", s.header)
+		}
+		return header.Get(s.header), nil
+	}
+	if s.cookie != "" {
+		c, ok := lastSetCookie(header, s.cookie)
+		if !ok {
+			return "", fmt.Errorf("cookie not found: %s", s.cookie)
+		}
+		return c.Value, nil
+	}
+	if s.xpath != nil {
+		root, err := x.root()
+		if err != nil {
+			return "", err
+		}
+		values := s.xpath.Evaluate(root)
+		if len(values) == 0 {
+			return "", fmt.Errorf("xpath not found: %s", s.xpath)
+		}
+		return values[0], nil
+	}
+	if s.jsonPath == "" {
+		return string(body), nil
+	}
+
+	var doc interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return "", fmt.Errorf("json_path %s: body is not a valid json", s.jsonPath)
+	}
+
+	v, ok := lookupJSONPath(doc, s.jsonPath)
+	if !ok {
+		return "", fmt.Errorf("json_path not found: %s", s.jsonPath)
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// stepCapture is a compiled capture rule of a step. maxSize bounds the body size of the whole body captures,
+// collect is set if the captured values are collected across the iterations.
+type stepCapture struct {
+	name    string
+	maxSize int64
+	collect bool
+	valueSource
+}
+
+func newStepCaptures(val interface{}, namespaces map[string]string) ([]stepCapture, error) {
+	rules, err := types.ParseStepCaptures(val)
+	if err != nil {
+		return nil, err
+	}
+
+	captures := make([]stepCapture, len(rules))
+	for i, r := range rules {
+		captures[i].name = r.Name
+		captures[i].collect = r.Collect
+		if r.WholeBody() {
+			captures[i].maxSize = r.MaxSize
+		}
+		if captures[i].valueSource, err = newValueSource(r.Header, r.JSONPath, r.XPath, namespaces); err != nil {
+			return nil, err
+		}
+		captures[i].cookie = r.Cookie
+	}
+	return captures, nil
+}
+
+// lookupJSONPath returns the value at the dot separated path. Numeric segments index the arrays.
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// writeFileAtomic writes the file through a temporary file in the same directory, so the readers never see
+// a partial value. The file is only readable by the user since the captured values are mostly secrets.
+func writeFileAtomic(path string, data []byte) (err error) {
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = f.Chmod(0600); err != nil {
+		return
+	}
+	if _, err = f.Write(data); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,246 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestExtractValue(t *testing.T) {
+	body := []byte(`{"data": {"token": "abc", "id": 42, "items": [{"name": "x"}], "ok": true}}`)
+	header := http.Header{"X-Tenant-Id": []string{"t-1"}}
+
+	tests := []struct {
+		name      string
+		rule      types.FileCapture
+		expected  string
+		shouldErr bool
+	}{
+		{"Header", types.FileCapture{Header: "x-tenant-id"}, "t-1", false},
+		{"String", types.FileCapture{JSONPath: "data.token"}, "abc", false},
+		{"Number", types.FileCapture{JSONPath: "data.id"}, "42", false},
+		{"ArrayIndex", types.FileCapture{JSONPath: "data.items.0.name"}, "x", false},
+		{"Object", types.FileCapture{JSONPath: "data.items.0"}, `{"name":"x"}`, false},
+		{"Bool", types.FileCapture{JSONPath: "data.ok"}, "true", false},
+		{"Body", types.FileCapture{}, string(body), false},
+		{"MissingHeader", types.FileCapture{Header: "Authorization"}, "", true},
+		{"MissingPath", types.FileCapture{JSONPath: "data.items.1.name"}, "", true},
+	}
+
+	for _, test := range tests {
+		src, err := newValueSource(test.rule.Header, test.rule.JSONPath, test.rule.XPath, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		v, err := src.extract(body, header, newXMLBody(body))
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("%s should be errored", test.name)
+			}
+			continue
+		}
+		if err != nil || v != test.expected {
+			t.Errorf("%s: Expected %q, Found %q, err: %v", test.name, test.expected, v, err)
+		}
+	}
+
+	src, _ := newValueSource("", "token", "", nil)
+	if _, err := src.extract([]byte("not json"), header, newXMLBody([]byte("not json"))); err == nil {
+		t.Errorf("Json path of a non-json body should be errored")
+	}
+}
+
+func TestExtractXPathValue(t *testing.T) {
+	body := []byte(`<order id="o-1"><item sku="a">2</item><item sku="b">3</item><note/></order>`)
+	namespaces := map[string]string{}
+
+	tests := []struct {
+		name      string
+		xpath     string
+		expected  string
+		shouldErr bool
+	}{
+		{"Text", "//item[1]/text()", "2", false},
+		{"Attribute", "/order/@id", "o-1", false},
+		{"FirstOfNodeSet", "//item/@sku", "a", false},
+		{"Number", "sum(//item)", "5", false},
+		{"Bool", "count(//item) = 2", "true", false},
+		{"EmptyElement", "//note", "", false},
+		{"NoMatch", "//invoice", "", true},
+	}
+
+	for _, test := range tests {
+		src, err := newValueSource("", "", test.xpath, namespaces)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		v, err := src.extract(body, nil, newXMLBody(body))
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("%s should be errored", test.name)
+			}
+			continue
+		}
+		if err != nil || v != test.expected {
+			t.Errorf("%s: Expected %q, Found %q, err: %v", test.name, test.expected, v, err)
+		}
+	}
+
+	src, _ := newValueSource("", "", "//item", namespaces)
+	if _, err := src.extract([]byte("{}"), nil, newXMLBody([]byte("{}"))); err != errInvalidXML {
+		t.Errorf("Xpath of a non-xml body should be errored with errInvalidXML, Found %v", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets", "token")
+
+	for _, v := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(v)); err != nil {
+			t.Fatalf("writeFileAtomic errored: %v", err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil || string(b) != "second" {
+		t.Errorf("Expected %q, Found %q, err: %v", "second", b, err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Errorf("File mode Expected %v, Found %v", os.FileMode(0600), fi.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Temporary files should not be left, Found %v", entries)
+	}
+}
+
+func TestSendCaptureToFile(t *testing.T) {
+	var reqCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&reqCount, 1)
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Tenant-Id", fmt.Sprintf("tenant-%d", n))
+		fmt.Fprintf(w, `{"token": "token-%d"}`, n)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	tokenFile, tenantFile := filepath.Join(dir, "token"), filepath.Join(dir, "tenant")
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom: map[string]interface{}{
+			"capture-to-file": []interface{}{
+				map[string]interface{}{"json_path": "token", "to_file": tokenFile},
+				map[string]interface{}{"header": "X-Tenant-Id", "to_file": tenantFile},
+			},
+		},
+	}
+
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	// Failed responses are not captured, only the first successful one is.
+	for i := 0; i < 3; i++ {
+		if res := h.Send(&Iteration{}); res.Err.Type != "" {
+			t.Fatalf("Send errored: %v", res.Err)
+		}
+	}
+	for path, expected := range map[string]string{tokenFile: "token-2", tenantFile: "tenant-2"} {
+		if b, err := os.ReadFile(path); err != nil || string(b) != expected {
+			t.Errorf("%s Expected %q, Found %q, err: %v", path, expected, b, err)
+		}
+	}
+
+	// A later run reads the captured values
+	var received string
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Authorization") + " " + r.URL.Path
+	}))
+	defer consumer.Close()
+
+	s = types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      consumer.URL + fmt.Sprintf(`/tenants/{{file "%s"}}`, tenantFile),
+		Headers:  map[string]string{"Authorization": fmt.Sprintf(`Bearer {{ file "%s" }}`, tokenFile)},
+		Timeout:  types.DefaultTimeout,
+	}
+	h = &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	h.Send(&Iteration{})
+	if expected := "Bearer token-2 /tenants/tenant-2"; received != expected {
+		t.Errorf("Expected %q, Found %q", expected, received)
+	}
+
+	s.URL = consumer.URL + `/{{file "not-exists"}}`
+	if err := (&HttpRequester{}).Init(context.TODO(), s, nil, false); err == nil {
+		t.Errorf("Init should be errored for the missing file")
+	}
+}
+
+func TestSendCaptureToFileMissingValue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"other": 1}`))
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom: map[string]interface{}{
+			"capture-to-file": []interface{}{
+				map[string]interface{}{"json_path": "token", "to_file": filepath.Join(t.TempDir(), "token")},
+			},
+		},
+	}
+
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	if res := h.Send(&Iteration{}); res.Err.Type == "" {
+		t.Errorf("Send should be failed when the captured value is missing")
+	}
+}
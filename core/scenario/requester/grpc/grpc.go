@@ -0,0 +1,544 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"go.ddosify.com/ddosify/core/scenario/protobuf"
+	"go.ddosify.com/ddosify/core/scenario/requester"
+	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+	"golang.org/x/net/http2"
+)
+
+// Protocols of the gRPC steps, like "grpc://localhost:50051/users.v1.Users/ListUsers" as the url of the step. GRPC
+// calls the method over HTTP/2 without TLS, and GRPCS over TLS.
+const (
+	Protocol    = "GRPC"
+	ProtocolTLS = "GRPCS"
+)
+
+// Calls of the grpc-stream option
+const (
+	// Single request message and a single response message
+	CallUnary = "unary"
+
+	// Single request message and the response messages streamed until the stream ends
+	CallServerStream = "server"
+
+	// Request messages sent one after another while the response messages are streamed
+	CallBidiStream = "bidi"
+)
+
+const (
+	// Count of the first messages of a call shown in the debug mode without a grpc-debug-messages
+	defaultDebugMessages = 5
+
+	// Size limit of a received message, the default limit of the gRPC implementations
+	maxMessageSize = 4 << 20
+
+	// Each message is prefixed by its compressed flag and its length in 4 big-endian bytes
+	prefixSize = 5
+)
+
+func init() {
+	requester.Register(Protocol, func() requester.Requester { return &Requester{} })
+	requester.Register(ProtocolTLS, func() requester.Requester { return &Requester{} })
+}
+
+var metricMeta = []types.MetricMeta{
+	{Key: "firstMessageDuration", Name: "First Message", JSONKey: "first_message"},
+	{Key: "streamDuration", Name: "Stream", JSONKey: "stream"},
+	{Key: "messageCount", Name: "Messages Received", JSONKey: "messages_received"},
+	{Key: "sentMessageCount", Name: "Messages Sent", JSONKey: "messages_sent"},
+}
+
+// Names of the gRPC status codes by their values
+var statusNames = [...]string{"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND",
+	"ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE",
+	"UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED"}
+
+// Requester calls the gRPC method of the url of the step, a unary method or a server or bidirectional streaming one
+// by the grpc-stream option. The messages are the protobuf encodings of the JSON payloads by the payload_protobuf of
+// the step, or the payloads as they are without a message type. The gRPC requester has no proxy.
+type Requester struct {
+	ctx   context.Context
+	step  types.ScenarioStep
+	debug bool
+
+	url       string
+	headers   http.Header
+	transport *http2.Transport
+
+	call          string
+	maxMessages   int64
+	streamTimeout time.Duration
+	interval      time.Duration
+	debugMessages int
+
+	// Prefixed request messages of each call, and their JSON for the debug output
+	messages    [][]byte
+	requestJSON []byte
+
+	// Type of the response messages decoded in the debug mode, nil if they are shown as they are
+	response *protobuf.Message
+}
+
+// Init parses the method of the url and the options of the step and encodes the request messages once.
+func (r *Requester) Init(ctx context.Context, ss types.ScenarioStep, proxyAddr *url.URL, debug bool) error {
+	r.ctx, r.step, r.debug = ctx, ss, debug
+
+	u, err := url.Parse(ss.URL)
+	if err != nil {
+		return err
+	}
+	method := strings.Trim(u.Path, "/")
+	if parts := strings.Split(method, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("grpc url should have a /package.Service/Method path: %s", ss.URL)
+	}
+	scheme := "http"
+	if strings.EqualFold(u.Scheme, ProtocolTLS) {
+		scheme = "https"
+	}
+	r.url = scheme + "://" + u.Host + "/" + method
+
+	if err = r.initOptions(ss.Custom); err != nil {
+		return err
+	}
+	if err = r.initMessages(ss); err != nil {
+		return err
+	}
+
+	r.headers = make(http.Header, len(ss.Headers)+2)
+	for k, v := range ss.Headers {
+		r.headers.Set(k, v)
+	}
+	if r.headers.Get("Content-Type") == "" {
+		r.headers.Set("Content-Type", "application/grpc+proto")
+	}
+	r.headers.Set("Te", "trailers")
+
+	timeout := time.Duration(ss.Timeout) * time.Second
+	r.transport = &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	if scheme == "http" {
+		// HTTP/2 is spoken over the plain connection without an upgrade
+		r.transport.AllowHTTP = true
+		r.transport.DialTLS = func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			d := net.Dialer{Timeout: timeout}
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+	return nil
+}
+
+// initOptions parses the grpc options of the step.
+func (r *Requester) initOptions(custom map[string]interface{}) (err error) {
+	r.call = CallUnary
+	if v, ok := custom["grpc-stream"]; ok {
+		s, _ := v.(string)
+		switch s = strings.ToLower(s); s {
+		case CallUnary, CallServerStream, CallBidiStream:
+			r.call = s
+		default:
+			return fmt.Errorf("grpc-stream should be one of unary, server or bidi: %v", v)
+		}
+	}
+
+	var n float64
+	if n, err = option(custom, "grpc-max-messages", true); err != nil {
+		return err
+	}
+	r.maxMessages = int64(n)
+	if n, err = option(custom, "grpc-stream-timeout", true); err != nil {
+		return err
+	}
+	r.streamTimeout = time.Duration(n * float64(time.Second))
+	if n, err = option(custom, "grpc-message-interval", false); err != nil {
+		return err
+	}
+	r.interval = time.Duration(n * float64(time.Millisecond))
+	r.debugMessages = defaultDebugMessages
+	if _, ok := custom["grpc-debug-messages"]; ok {
+		if n, err = option(custom, "grpc-debug-messages", false); err != nil {
+			return err
+		}
+		r.debugMessages = int(n)
+	}
+
+	if r.call == CallUnary {
+		for _, k := range []string{"grpc-max-messages", "grpc-stream-timeout"} {
+			if _, ok := custom[k]; ok {
+				return fmt.Errorf("%s can only be used with a server or bidi grpc-stream", k)
+			}
+		}
+	}
+	if r.call != CallBidiStream {
+		for _, k := range []string{"grpc-messages", "grpc-message-interval"} {
+			if _, ok := custom[k]; ok {
+				return fmt.Errorf("%s can only be used with the bidi grpc-stream", k)
+			}
+		}
+	}
+	return nil
+}
+
+// option returns the number of the option, 0 if it is not set. The positive options can't be 0.
+func option(custom map[string]interface{}, key string, positive bool) (float64, error) {
+	v, ok := custom[key]
+	if !ok {
+		return 0, nil
+	}
+	n, isNum := util.ToFloat64(v)
+	if !isNum || n < 0 || (positive && n == 0) {
+		if positive {
+			return 0, fmt.Errorf("%s should be a positive number: %v", key, v)
+		}
+		return 0, fmt.Errorf("%s should be a non negative number: %v", key, v)
+	}
+	return n, nil
+}
+
+// initMessages encodes the request messages, the grpc-messages of a bidi stream or the payload of the step.
+func (r *Requester) initMessages(ss types.ScenarioStep) (err error) {
+	var request *protobuf.Message
+	if ss.Protobuf != nil {
+		if request, r.response, err = ss.Protobuf.Messages(); err != nil {
+			return err
+		}
+	}
+
+	payloads := []string{ss.Payload}
+	if v, ok := ss.Custom["grpc-messages"]; ok {
+		list, isList := v.([]interface{})
+		if !isList || len(list) == 0 {
+			return fmt.Errorf("grpc-messages should be a list of messages: %v", v)
+		}
+		payloads = payloads[:0]
+		for _, m := range list {
+			if s, isStr := m.(string); isStr {
+				payloads = append(payloads, s)
+				continue
+			}
+			b, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			payloads = append(payloads, string(b))
+		}
+	}
+
+	var messages []json.RawMessage
+	for i, p := range payloads {
+		wire := []byte(p)
+		if request != nil {
+			if wire, err = request.EncodeJSON(wire); err != nil {
+				return fmt.Errorf("grpc message %d of step %d can not be encoded: %v", i+1, ss.ID, err)
+			}
+			messages = append(messages, json.RawMessage(p))
+		}
+		r.messages = append(r.messages, prefixed(wire))
+	}
+	if len(messages) == 1 {
+		r.requestJSON = messages[0]
+	} else if len(messages) > 1 {
+		r.requestJSON, _ = json.Marshal(messages)
+	}
+	return nil
+}
+
+// prefixed returns the message with its prefix. The messages are sent uncompressed.
+func prefixed(msg []byte) []byte {
+	b := make([]byte, prefixSize+len(msg))
+	binary.BigEndian.PutUint32(b[1:prefixSize], uint32(len(msg)))
+	copy(b[prefixSize:], msg)
+	return b
+}
+
+// call is the state of a call of the step.
+type call struct {
+	start    time.Time
+	first    time.Duration
+	received int64
+	sent     int64
+	bytes    int64
+
+	// Set once the stream is ended by the grpc-max-messages or the grpc-stream-timeout, its cancel is not a failure
+	ended int32
+
+	// First messages of the call and their JSON for the debug output
+	debugBody     []byte
+	debugMessages []json.RawMessage
+}
+
+// Send calls the method of the step. The response messages are read until the stream ends, or until the
+// grpc-max-messages are received or the grpc-stream-timeout is over.
+func (r *Requester) Send(it *requester.Iteration) *types.ScenarioStepResult {
+	res := &types.ScenarioStepResult{
+		StepID:      r.step.ID,
+		StepName:    r.step.Name,
+		RequestID:   uuid.New(),
+		RequestTime: time.Now(),
+		Custom:      make(map[string]interface{}),
+	}
+	c := &call{start: res.RequestTime}
+
+	callCtx, cancelCall := r.ctx, context.CancelFunc(func() {})
+	if r.step.Timeout > 0 {
+		callCtx, cancelCall = context.WithTimeout(r.ctx, time.Duration(r.step.Timeout)*time.Second)
+	}
+	defer cancelCall()
+	ctx, end := context.WithCancel(callCtx)
+	defer end()
+	if r.streamTimeout > 0 {
+		t := time.AfterFunc(r.streamTimeout, func() {
+			atomic.StoreInt32(&c.ended, 1)
+			end()
+		})
+		defer t.Stop()
+	}
+
+	var body io.Reader
+	var sending chan struct{}
+	if r.call == CallBidiStream {
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		body, sending = pr, make(chan struct{})
+		go r.sendMessages(ctx, pw, c, sending)
+	} else {
+		body, c.sent = bytes.NewReader(r.messages[0]), 1
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, r.url, body)
+	req.Header = r.headers.Clone()
+
+	resp, err := r.transport.RoundTrip(req)
+	var trailer http.Header
+	if err != nil {
+		res.Err = r.failure(err, callCtx, false)
+	} else {
+		res.StatusCode = resp.StatusCode
+		res.Err = r.read(resp, c, end)
+		resp.Body.Close()
+		trailer = resp.Trailer
+	}
+	if sending != nil {
+		// Sender is blocked on the pipe until the body is closed
+		body.(*io.PipeReader).Close()
+		<-sending
+	}
+	res.Duration = time.Since(res.RequestTime)
+
+	res.ContentLength = c.bytes
+	res.Custom["streamDuration"] = res.Duration
+	if c.received > 0 {
+		res.Custom["firstMessageDuration"] = c.first
+	}
+	res.Custom["messageCount"] = c.received
+	res.Custom["sentMessageCount"] = atomic.LoadInt64(&c.sent)
+
+	if r.debug {
+		r.setDebugInfo(res, req, resp, trailer, c)
+	}
+	return res
+}
+
+// sendMessages writes the request messages of a bidi stream to the body of the call, the interval apart, and closes
+// the body once they are sent so the server sees the end of the stream.
+func (r *Requester) sendMessages(ctx context.Context, w *io.PipeWriter, c *call, done chan struct{}) {
+	defer close(done)
+	for i, m := range r.messages {
+		if i > 0 && r.interval > 0 {
+			t := time.NewTimer(r.interval)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				w.CloseWithError(ctx.Err())
+				return
+			}
+		}
+		if _, err := w.Write(m); err != nil {
+			return
+		}
+		atomic.AddInt64(&c.sent, 1)
+	}
+	w.Close()
+}
+
+// read reads the response messages of the call and returns the error of the call once the stream is ended, the
+// grpc-status of the trailers decides the result of a completed stream.
+func (r *Requester) read(resp *http.Response, c *call, end context.CancelFunc) types.RequestError {
+	if resp.StatusCode != http.StatusOK {
+		return types.RequestError{Type: types.ErrorStatus, Reason: "http status " + strconv.Itoa(resp.StatusCode)}
+	}
+
+	prefix := make([]byte, prefixSize)
+	var err error
+	for r.maxMessages == 0 || c.received < r.maxMessages {
+		if _, err = io.ReadFull(resp.Body, prefix); err != nil {
+			break
+		}
+		n := binary.BigEndian.Uint32(prefix[1:])
+		if n > maxMessageSize {
+			return types.RequestError{Type: types.ErrorBodyLimit, Reason: fmt.Sprintf("grpc message of %d bytes", n)}
+		}
+		msg := make([]byte, n)
+		if _, err = io.ReadFull(resp.Body, msg); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			break
+		}
+		if c.received == 0 {
+			c.first = time.Since(c.start)
+		}
+		c.received++
+		c.bytes += int64(n)
+		if r.debug {
+			r.keepMessage(c, prefix[0] != 0, msg)
+		}
+	}
+
+	if err == nil {
+		// Rest of the stream is canceled once the grpc-max-messages are received
+		atomic.StoreInt32(&c.ended, 1)
+		end()
+		return types.RequestError{}
+	}
+	if atomic.LoadInt32(&c.ended) == 1 && r.ctx.Err() == nil {
+		return types.RequestError{}
+	}
+	if err != io.EOF {
+		return r.failure(err, resp.Request.Context(), true)
+	}
+
+	// Status is in the headers of the responses without a message
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+	}
+	code, convErr := strconv.Atoi(status)
+	if convErr != nil {
+		return types.RequestError{Type: types.ErrorStream, Reason: types.ReasonStreamBroken}
+	}
+	if code != 0 {
+		name := strconv.Itoa(code)
+		if code > 0 && code < len(statusNames) {
+			name = statusNames[code]
+		}
+		return types.RequestError{Type: types.ErrorGrpcStatus, Reason: "grpc status " + name}
+	}
+	return types.RequestError{}
+}
+
+// failure returns the error of a call failed by err. The stream errors are the failures after the response headers
+// are received, or the streams reset by the server, and the others are the connection errors.
+func (r *Requester) failure(err error, ctx context.Context, started bool) types.RequestError {
+	var streamErr http2.StreamError
+	switch {
+	case r.ctx.Err() != nil:
+		return types.RequestError{Type: types.ErrorIntented, Reason: types.ReasonCtxCanceled}
+	case errors.As(err, &streamErr):
+		return types.RequestError{Type: types.ErrorStream, Reason: types.ReasonStreamReset}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) && started:
+		return types.RequestError{Type: types.ErrorStream, Reason: types.ReasonStreamDeadline}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnTimeout}
+	case started:
+		return types.RequestError{Type: types.ErrorStream, Reason: types.ReasonStreamBroken}
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return types.RequestError{Type: types.ErrorConn, Reason: types.ReasonConnRefused}
+	}
+	return types.RequestError{Type: types.ErrorConn, Reason: err.Error()}
+}
+
+// keepMessage keeps the message for the debug output if it is one of the first grpc-debug-messages of the call.
+// The messages are decoded by the response message of the step, the compressed ones are shown as they are.
+func (r *Requester) keepMessage(c *call, compressed bool, msg []byte) {
+	if c.received > int64(r.debugMessages) {
+		return
+	}
+	c.debugBody = append(c.debugBody, msg...)
+	if r.response == nil || compressed {
+		return
+	}
+	if decoded, err := r.response.DecodeJSON(msg); err == nil {
+		c.debugMessages = append(c.debugMessages, decoded)
+	}
+}
+
+// setDebugInfo sets the debug output of the call. The trailers are shown with the response headers.
+func (r *Requester) setDebugInfo(res *types.ScenarioStepResult, req *http.Request, resp *http.Response,
+	trailer http.Header, c *call) {
+	res.DebugInfo = map[string]interface{}{
+		"url":            r.step.URL,
+		"method":         http.MethodPost,
+		"requestHeaders": req.Header,
+		"requestBody":    bytes.Join(r.messages[:atomic.LoadInt64(&c.sent)], nil),
+	}
+	if r.requestJSON != nil {
+		res.DebugInfo["requestMessage"] = r.requestJSON
+	}
+	if resp == nil {
+		return
+	}
+
+	headers := resp.Header.Clone()
+	for k, v := range trailer {
+		headers[k] = append(headers[k], v...)
+	}
+	res.DebugInfo["responseHeaders"] = headers
+	res.DebugInfo["responseBody"] = c.debugBody
+	if c.debugBody == nil {
+		res.DebugInfo["responseBody"] = []byte{}
+	}
+	if len(c.debugMessages) > 0 {
+		res.DebugInfo["responseMessage"], _ = json.Marshal(c.debugMessages)
+	}
+}
+
+// Done closes the connections of the step.
+func (r *Requester) Done() {
+	if r.transport != nil {
+		r.transport.CloseIdleConnections()
+	}
+}
+
+// MetricMeta declares the time to the first message, the stream duration and the message counts.
+func (r *Requester) MetricMeta() []types.MetricMeta {
+	return metricMeta
+}
@@ -0,0 +1,280 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package grpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/scenario/requester"
+	"go.ddosify.com/ddosify/core/scenario/requester/requestertest"
+	"go.ddosify.com/ddosify/core/types"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const testProto = `syntax = "proto3";
+package test;
+message Note { string text = 1; int32 n = 2; }
+`
+
+// newTestServer starts a gRPC server over HTTP/2 without TLS. Its methods answer with the messages they receive.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test.Notes/Unary", func(w http.ResponseWriter, r *http.Request) {
+		msgs := readTestMessages(r.Body)
+		writeTestMessage(w, msgs[0])
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	})
+	mux.HandleFunc("/test.Notes/Server", func(w http.ResponseWriter, r *http.Request) {
+		msgs := readTestMessages(r.Body)
+		for i := 0; i < 3; i++ {
+			writeTestMessage(w, msgs[0])
+		}
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	})
+	mux.HandleFunc("/test.Notes/Bidi", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		prefix := make([]byte, prefixSize)
+		for {
+			if _, err := io.ReadFull(r.Body, prefix); err != nil {
+				break
+			}
+			msg := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+			io.ReadFull(r.Body, msg)
+			writeTestMessage(w, msg)
+		}
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	})
+	mux.HandleFunc("/test.Notes/Endless", func(w http.ResponseWriter, r *http.Request) {
+		for r.Context().Err() == nil {
+			writeTestMessage(w, []byte("\x0a\x01x"))
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+	mux.HandleFunc("/test.Notes/Reset", func(w http.ResponseWriter, r *http.Request) {
+		writeTestMessage(w, []byte("\x0a\x01x"))
+		panic(http.ErrAbortHandler)
+	})
+	mux.HandleFunc("/test.Notes/Stall", func(w http.ResponseWriter, r *http.Request) {
+		writeTestMessage(w, []byte("\x0a\x01x"))
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/test.Notes/Unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Grpc-Status", "14")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := httptest.NewUnstartedServer(h2c.NewHandler(mux, &http2.Server{}))
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readTestMessages(body io.Reader) (msgs [][]byte) {
+	b, _ := io.ReadAll(body)
+	for len(b) >= prefixSize {
+		n := int(binary.BigEndian.Uint32(b[1:prefixSize]))
+		msgs = append(msgs, b[prefixSize:prefixSize+n])
+		b = b[prefixSize+n:]
+	}
+	return msgs
+}
+
+func writeTestMessage(w http.ResponseWriter, msg []byte) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Write(prefixed(msg))
+	w.(http.Flusher).Flush()
+}
+
+func newTestStep(t *testing.T, server *httptest.Server, method string,
+	custom map[string]interface{}) types.ScenarioStep {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notes.proto")
+	if err := os.WriteFile(path, []byte(testProto), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return types.ScenarioStep{
+		ID:       1,
+		Name:     "notes",
+		Protocol: Protocol,
+		URL:      strings.Replace(server.URL, "http://", "grpc://", 1) + "/test.Notes/" + method,
+		Timeout:  types.DefaultTimeout,
+		Payload:  `{"text": "hi", "n": 1}`,
+		Protobuf: &types.ProtobufPayload{File: path, Message: "test.Note", ResponseMessage: "test.Note"},
+		Custom:   custom,
+	}
+}
+
+func TestConformance(t *testing.T) {
+	requestertest.Conformance(t, newTestStep(t, newTestServer(t), "Unary", nil))
+}
+
+func TestSendCalls(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		custom   map[string]interface{}
+		timeout  int
+		err      types.RequestError
+		received int64
+		sent     int64
+	}{
+		{"Unary", "Unary", nil, 0, types.RequestError{}, 1, 1},
+		{"ServerStream", "Server", map[string]interface{}{"grpc-stream": "server"}, 0, types.RequestError{}, 3, 1},
+		{"BidiStream", "Bidi", map[string]interface{}{"grpc-stream": "bidi", "grpc-message-interval": 1,
+			"grpc-messages": []interface{}{
+				map[string]interface{}{"text": "a"}, `{"text": "b"}`, map[string]interface{}{}}},
+			0, types.RequestError{}, 3, 3},
+		{"MaxMessages", "Endless", map[string]interface{}{"grpc-stream": "server", "grpc-max-messages": 4}, 0,
+			types.RequestError{}, 4, 1},
+		{"StreamTimeout", "Stall", map[string]interface{}{"grpc-stream": "server", "grpc-stream-timeout": 0.1}, 0,
+			types.RequestError{}, 1, 1},
+		{"Reset", "Reset", map[string]interface{}{"grpc-stream": "server"}, 0,
+			types.RequestError{Type: types.ErrorStream, Reason: types.ReasonStreamReset}, 1, 1},
+		{"Deadline", "Stall", map[string]interface{}{"grpc-stream": "server"}, 1,
+			types.RequestError{Type: types.ErrorStream, Reason: types.ReasonStreamDeadline}, 1, 1},
+		{"Status", "Unavailable", nil, 0,
+			types.RequestError{Type: types.ErrorGrpcStatus, Reason: "grpc status UNAVAILABLE"}, 0, 1},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			step := newTestStep(t, server, test.method, test.custom)
+			if test.timeout > 0 {
+				step.Timeout = test.timeout
+			}
+			r := &Requester{}
+			if err := r.Init(context.Background(), step, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer r.Done()
+
+			res := r.Send(requester.NewIteration(0, 1))
+			if res.Err != test.err {
+				t.Errorf("Expected error %v, Found %v", test.err, res.Err)
+			}
+			if res.Custom["messageCount"] != test.received || res.Custom["sentMessageCount"] != test.sent {
+				t.Errorf("Expected %d messages received and %d sent, Found %v and %v", test.received, test.sent,
+					res.Custom["messageCount"], res.Custom["sentMessageCount"])
+			}
+			first, ok := res.Custom["firstMessageDuration"].(time.Duration)
+			if ok != (test.received > 0) || first > res.Custom["streamDuration"].(time.Duration) {
+				t.Errorf("First message should be received before the end of the stream, Found %v of %v", first,
+					res.Custom["streamDuration"])
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSendConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	r := &Requester{}
+	step := types.ScenarioStep{ID: 1, Protocol: Protocol, URL: "grpc://" + addr + "/test.Notes/Unary", Timeout: 1}
+	if err := r.Init(context.Background(), step, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer r.Done()
+
+	// Connection failures are not the stream errors
+	if res := r.Send(requester.NewIteration(0, 1)); res.Err.Type != types.ErrorConn {
+		t.Errorf("Expected %s, Found %v", types.ErrorConn, res.Err)
+	}
+}
+
+func TestSendDebugMessages(t *testing.T) {
+	step := newTestStep(t, newTestServer(t), "Server", map[string]interface{}{"grpc-stream": "server",
+		"grpc-debug-messages": 2})
+	r := &Requester{}
+	if err := r.Init(context.Background(), step, nil, true); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer r.Done()
+
+	res := r.Send(requester.NewIteration(0, 1))
+	if res.Err.Type != "" {
+		t.Fatalf("Send errored: %v", res.Err)
+	}
+	var messages []map[string]interface{}
+	json.Unmarshal(res.DebugInfo["responseMessage"].([]byte), &messages)
+	expected := []map[string]interface{}{{"text": "hi", "n": float64(1)}, {"text": "hi", "n": float64(1)}}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Expected the first 2 messages %v, Found %s", expected, res.DebugInfo["responseMessage"])
+	}
+	if h := res.DebugInfo["responseHeaders"].(http.Header); h.Get("Grpc-Status") != "0" {
+		t.Errorf("Trailers should be in the response headers, Found %v", h)
+	}
+}
+
+func TestInitOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		custom map[string]interface{}
+		errStr string
+	}{
+		{"NoMethod", "/test.Notes", nil, "/package.Service/Method path"},
+		{"InvalidStream", "/test.Notes/Unary", map[string]interface{}{"grpc-stream": "client"}, "grpc-stream"},
+		{"UnaryMaxMessages", "/test.Notes/Unary", map[string]interface{}{"grpc-max-messages": 1},
+			"can only be used with a server or bidi"},
+		{"ZeroMaxMessages", "/test.Notes/Server", map[string]interface{}{"grpc-stream": "server",
+			"grpc-max-messages": 0}, "positive number"},
+		{"ServerMessages", "/test.Notes/Server", map[string]interface{}{"grpc-stream": "server",
+			"grpc-messages": []interface{}{"{}"}}, "can only be used with the bidi"},
+		{"EmptyMessages", "/test.Notes/Bidi", map[string]interface{}{"grpc-stream": "bidi",
+			"grpc-messages": []interface{}{}}, "list of messages"},
+		{"NegativeInterval", "/test.Notes/Bidi", map[string]interface{}{"grpc-stream": "bidi",
+			"grpc-message-interval": -1}, "non negative number"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			step := types.ScenarioStep{ID: 1, Protocol: Protocol, URL: "grpc://localhost:50051" + test.path,
+				Custom: test.custom}
+			err := (&Requester{}).Init(context.Background(), step, nil, false)
+			if err == nil || !strings.Contains(err.Error(), test.errStr) {
+				t.Errorf("Expected error containing %q, Found %v", test.errStr, err)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
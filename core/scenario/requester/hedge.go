@@ -0,0 +1,150 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// hedger hedges the requests of a step against the tail latency. If the request gets no response within the delay,
+// an identical request is sent and the first response wins, the other request is cancelled. The hedges are extra
+// load on the target, they are reported apart from the requests of the step.
+type hedger struct {
+	delay time.Duration
+}
+
+// newHedger returns the hedger of the hedge option of the step, nil if the step has none.
+func newHedger(custom map[string]interface{}, method string) *hedger {
+	val, ok := custom["hedge"]
+	if !ok {
+		return nil
+	}
+	// Validated with the scenario
+	hg, _ := types.ParseHedge(val, method)
+	return &hedger{delay: hg.Delay}
+}
+
+// hedgeOutcome is the outcome of a hedged request.
+type hedgeOutcome struct {
+	// Set once the hedge is sent, and if it won
+	fired bool
+	won   bool
+
+	// Time from the first request to the hedge, and the time of the first request until it is cancelled by the
+	// winning hedge, which is a lower bound of its duration
+	wait     time.Duration
+	unhedged time.Duration
+
+	// Cancels the winning request once its body is read, nil if both requests failed
+	cancel context.CancelFunc
+}
+
+// hedgeAttempt is the result of one of the requests of a hedged send.
+type hedgeAttempt struct {
+	res       *http.Response
+	err       error
+	durations *duration
+	cancel    context.CancelFunc
+	hedge     bool
+}
+
+// do sends the request over the client and hedges it if there is no response within the delay. The first successful
+// response wins and the durations are the ones of its request. If both requests fail, the error is the one of the
+// first request. A request failing before the delay is not hedged, the hedges are for the slow responses.
+func (hg *hedger) do(h *HttpRequester, client *http.Client, req *http.Request, reqCtx context.Context,
+	durations *duration) (*http.Response, error, *duration, hedgeOutcome) {
+	var o hedgeOutcome
+	start := time.Now()
+	results := make(chan hedgeAttempt, 2)
+	var attempts []hedgeAttempt
+	send := func(r *http.Request, d *duration, hedge bool) bool {
+		ctx, cancel := context.WithCancel(reqCtx)
+		r = r.WithContext(httptrace.WithClientTrace(ctx, newTrace(d, h.proxyAddr)))
+		if hedge && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return false
+			}
+			r.Body = body
+		}
+		a := hedgeAttempt{durations: d, cancel: cancel, hedge: hedge}
+		attempts = append(attempts, a)
+		go func() {
+			a.res, a.err = client.Do(r)
+			results <- a
+		}()
+		return true
+	}
+	send(req, durations, false)
+
+	timer := time.NewTimer(hg.delay)
+	defer timer.Stop()
+	var failed hedgeAttempt
+	for pending := 1; ; {
+		select {
+		case <-timer.C:
+			hedgeReq := req.Clone(reqCtx)
+			d := &duration{continueTimeout: durations.continueTimeout, closesConn: durations.closesConn}
+			if send(hedgeReq, d, true) {
+				o.fired, o.wait = true, since(start)
+				pending++
+			}
+		case a := <-results:
+			pending--
+			if a.err == nil {
+				// Loser is cancelled, its response, if it arrives anyway, is closed
+				for _, other := range attempts {
+					if other.hedge != a.hedge {
+						other.cancel()
+					}
+				}
+				if pending > 0 {
+					go func() {
+						if l := <-results; l.res != nil {
+							l.res.Body.Close()
+						}
+					}()
+				}
+				if o.won = a.hedge; o.won {
+					o.unhedged = since(start)
+				}
+				o.cancel = a.cancel
+				return a.res, nil, a.durations, o
+			}
+			if !a.hedge {
+				failed = a
+			}
+			// A failure before the delay is not hedged, otherwise the other request may still win
+			if !o.fired || pending == 0 {
+				for _, other := range attempts {
+					other.cancel()
+				}
+				return nil, failed.err, failed.durations, o
+			}
+		}
+	}
+}
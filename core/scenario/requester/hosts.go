@@ -0,0 +1,60 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"sync"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// hostRotation picks the host of each request from the hosts of a step by the smooth weighted round-robin,
+// so the picks of a host are spread evenly instead of in bursts of its weight. Transport keeps the connection
+// pools per host, so each host gets its own DNS resolution and connections. It is safe for concurrent use.
+type hostRotation struct {
+	mu      sync.Mutex
+	hosts   []types.WeightedHost
+	current []int
+	total   int
+}
+
+func newHostRotation(hosts []types.WeightedHost) *hostRotation {
+	r := &hostRotation{hosts: hosts, current: make([]int, len(hosts))}
+	for _, h := range hosts {
+		r.total += h.Weight
+	}
+	return r
+}
+
+func (r *hostRotation) next() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	picked := 0
+	for i, h := range r.hosts {
+		r.current[i] += h.Weight
+		if r.current[i] > r.current[picked] {
+			picked = i
+		}
+	}
+	r.current[picked] -= r.total
+	return r.hosts[picked].Host
+}
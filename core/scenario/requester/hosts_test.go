@@ -0,0 +1,66 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"reflect"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestHostRotation(t *testing.T) {
+	t.Parallel()
+
+	host := func(name string, weight int) types.WeightedHost {
+		return types.WeightedHost{Host: name, Weight: weight}
+	}
+
+	tests := []struct {
+		name     string
+		hosts    []types.WeightedHost
+		expected []string
+	}{
+		{"RoundRobin", []types.WeightedHost{host("a", 1), host("b", 1), host("c", 1)},
+			[]string{"a", "b", "c", "a", "b", "c"}},
+		{"Weighted", []types.WeightedHost{host("a", 2), host("b", 1), host("c", 1)},
+			[]string{"a", "b", "c", "a", "a", "b", "c", "a"}},
+		{"Smooth", []types.WeightedHost{host("a", 3), host("b", 1)},
+			[]string{"a", "a", "b", "a", "a", "a", "b", "a"}},
+		{"Single", []types.WeightedHost{host("a", 5)}, []string{"a", "a"}},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newHostRotation(test.hosts)
+			picks := make([]string, 0, len(test.expected))
+			for range test.expected {
+				picks = append(picks, r.next())
+			}
+			if !reflect.DeepEqual(picks, test.expected) {
+				t.Errorf("Expected %v, Found %v", test.expected, picks)
+			}
+		})
+	}
+}
@@ -24,33 +24,157 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptrace"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"go.ddosify.com/ddosify/core/clock"
 	"go.ddosify.com/ddosify/core/scenario/scripting"
 	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+	"go.ddosify.com/ddosify/core/warm"
 	"golang.org/x/net/http2"
 )
 
 const DynamicVariableRegex = `\{{(_)[^}]+\}}`
 
+// UnresolvedVariableRegex matches the template variables left in a rendered request.
+const UnresolvedVariableRegex = `\{{[^}]+\}}`
+
+var dynamicVariableRe = regexp.MustCompile(DynamicVariableRegex)
+
+// expectContinueTimeout is the time that the requests with the "Expect: 100-continue" header wait for the interim
+// response before sending their body.
+var expectContinueTimeout = time.Second
+
+// httpMetricMeta is the metrics of the HTTP requests, streaming mode adds the stream metrics.
+var httpMetricMeta = []types.MetricMeta{
+	{Key: "latencyDuration", Name: "Injected Latency", JSONKey: "injected_latency"},
+	{Key: "clientWaitDuration", Name: "Client Wait", JSONKey: "client_wait"},
+	{Key: "dnsDuration", Name: "DNS", JSONKey: "dns"},
+	{Key: "connDuration", Name: "Connection", JSONKey: "connection"},
+	{Key: "tlsDuration", Name: "TLS", JSONKey: "tls"},
+	{Key: "continueWaitDuration", Name: "Continue Wait", JSONKey: "continue_wait"},
+	{Key: "reqDuration", Name: "Request Write", JSONKey: "request_write"},
+	{Key: "serverProcessDuration", Name: "Server Processing", JSONKey: "server_processing"},
+	{Key: "resDuration", Name: "Response Read", JSONKey: "response_read"},
+	{Key: "decompressDuration", Name: "Decompression", JSONKey: "decompression"},
+	{Key: "firstByteDuration", Name: "Stream First Byte", JSONKey: "stream_first_byte"},
+	{Key: "lastByteDuration", Name: "Stream Last Byte", JSONKey: "stream_last_byte"},
+	{Key: "chunkCount", Name: "Chunks", JSONKey: "chunks"},
+	{Key: "byteCount", Name: "Bytes", JSONKey: "bytes"},
+	{Key: "eventCount", Name: "SSE Events", JSONKey: "sse_events"},
+}
+
 type HttpRequester struct {
-	ctx                  context.Context
-	proxyAddr            *url.URL
-	packet               types.ScenarioStep
-	client               *http.Client
-	request              *http.Request
-	vi                   *scripting.VariableInjector
-	containsDynamicField map[string]bool
-	debug                bool
+	ctx              context.Context
+	proxyAddr        *url.URL
+	packet           types.ScenarioStep
+	client           *http.Client
+	request          *http.Request
+	vi               *scripting.VariableInjector
+	bodyTmpl         *scripting.Template
+	composer         *bodyComposer
+	staticBody       string
+	staticGetBody    func() (io.ReadCloser, error)
+	urlTmpl          *urlTemplate
+	urlComposer      *bodyComposer
+	headerTmpls      []headerTemplate
+	usernameTmpl     *scripting.Template
+	passwordTmpl     *scripting.Template
+	stream           *streamConfig
+	targets          *targetFeed
+	hosts            *hostRotation
+	variants         map[string]types.ABVariant
+	fileCapture      *fileCapturer
+	schemaAssertion  *schemaAssertion
+	leakScanner      *leakScanner
+	churnRetry       *churnRetry
+	hedger           *hedger
+	duplicator       *duplicator
+	maxBodySize      int64
+	xmlAssertion     *xmlAssertion
+	cookieAssertion  *cookieAssertion
+	protobuf         *protobufCodec
+	captures         []stepCapture
+	capturesNeedBody bool
+	urlGroups        []types.URLGroup
+	dimensions       []string
+	transferred      *byteCounter
+	shaper           *networkShaper
+	unixSocket       string
+	decompress       bool
+	cookies          bool
+	successStatus    types.StatusRanges
+	customHost       bool
+	seed             int64
+	debug            bool
+	captureFailures  bool
+
+	// TLS sessions of the connections of the step, so the new connections resume them. nil means the session cache
+	// is disabled and each new connection does a full handshake.
+	sessionCache tls.ClientSessionCache
+
+	// Hosts whose certificates are reported by a request of the step
+	certificates certificateReporter
+
+	// Count of the connections established before the test, taken by the transport from the pool
+	prewarm int
+	pool    *connPool
+
+	// Set if the host of the url is rendered on each request, the requests follow the rendered host and the
+	// results are reported per host
+	dynamicHost bool
+
+	// Timeout of the requests following the latency of the step, nil if the step has no adaptive-timeout
+	adaptive *adaptiveTimeout
+
+	// Loader of the resources of the HTML page of the step, nil if the step has no page-load
+	pageLoader *pageLoader
+
+	// Runner of the sign command of the step, nil if the step has no sign-command
+	signer *signer
+
+	// Connection state carried across the runs, nil if the run has no warm state. The idle connections of a kept
+	// transport are left to the next run by Done.
+	warm          *warm.State
+	keptTransport bool
+
+	// Clock of the Retry-After dates and the timings of the duplicates, nil means the real clock
+	clock clock.Clock
+}
+
+// SetSeed sets the seed of the dynamic variables and the random target picks.
+// If it is not set, a seed is generated in Init.
+func (h *HttpRequester) SetSeed(seed int64) {
+	h.seed = seed
+}
+
+// SetClock sets the clock that the HTTP-dates of the Retry-After headers are read on and the duplicates of the
+// duplicate_rate are timed by.
+func (h *HttpRequester) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// timeSource returns the clock of the requester, the real clock if SetClock is not called.
+func (h *HttpRequester) timeSource() clock.Clock {
+	if h.clock == nil {
+		return clock.Real()
+	}
+	return h.clock
 }
 
 // Init creates a client with the given scenarioItem. HttpRequester uses the same http.Client for all requests
@@ -58,16 +182,125 @@ func (h *HttpRequester) Init(ctx context.Context, s types.ScenarioStep, proxyAdd
 	h.ctx = ctx
 	h.packet = s
 	h.proxyAddr = proxyAddr
+	h.transferred = &byteCounter{}
+	if h.seed == 0 {
+		h.seed = util.NewSeed()
+	}
 	h.vi = &scripting.VariableInjector{}
-	h.vi.Init()
-	h.containsDynamicField = make(map[string]bool)
+	h.vi.Init(util.NewRand(util.SubSeed(h.seed, "variables")))
+	h.stream = newStreamConfig(h.packet.Custom)
+	h.adaptive = newAdaptiveTimeout(h.packet)
 	h.debug = debug
 
+	if path, ok := h.packet.Custom["targets-file"].(string); ok {
+		order, _ := h.packet.Custom["targets-order"].(string)
+		h.targets, err = newTargetFeed(path, order, h.packet.Protocol,
+			util.NewRand(util.SubSeed(h.seed, "targets")))
+		if err != nil {
+			return
+		}
+
+		if val, ok := h.packet.Custom["url-groups"]; ok {
+			h.urlGroups, err = types.ParseURLGroups(val)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if val, ok := h.packet.Custom["report-dimensions"]; ok {
+		if h.dimensions, err = types.ParseReportDimensions(val); err != nil {
+			return
+		}
+	}
+
+	if val, ok := h.packet.Custom["hosts"]; ok {
+		var hosts []types.WeightedHost
+		if hosts, err = types.ParseHosts(val); err != nil {
+			return
+		}
+		h.hosts = newHostRotation(hosts)
+	}
+
+	if val, ok := h.packet.Custom["ab-variants"]; ok {
+		if h.variants, err = types.ParseABVariants(val); err != nil {
+			return
+		}
+	}
+
+	namespaces, err := types.ParseXMLNamespaces(h.packet.Custom["xml-namespaces"])
+	if err != nil {
+		return
+	}
+	if val, ok := h.packet.Custom["capture-to-file"]; ok {
+		if h.fileCapture, err = newFileCapturer(val, namespaces); err != nil {
+			return
+		}
+	}
+	if h.schemaAssertion, err = newSchemaAssertion(h.packet.Custom); err != nil {
+		return
+	}
+	if h.leakScanner, err = newLeakScanner(h.packet.Custom); err != nil {
+		return
+	}
+	h.churnRetry = newChurnRetry(h.packet.Custom)
+	h.hedger = newHedger(h.packet.Custom, h.packet.Method)
+	h.duplicator = newDuplicator(s.DuplicateRate)
+	h.pageLoader = newPageLoader(h.packet.Custom)
+	h.signer = newSigner(h.packet.Custom)
+	if h.maxBodySize = s.MaxBodySize; h.maxBodySize == 0 {
+		h.maxBodySize = types.DefaultMaxBodySize
+	}
+	if h.xmlAssertion, err = newXMLAssertion(h.packet.Custom, namespaces); err != nil {
+		return
+	}
+	if h.cookieAssertion, err = newCookieAssertion(h.packet.Custom); err != nil {
+		return
+	}
+	if h.protobuf, err = newProtobufCodec(h.packet.Protobuf); err != nil {
+		return
+	}
+	if h.shaper, err = newNetworkShaper(h.packet.Custom, util.NewRand(util.SubSeed(h.seed, "network"))); err != nil {
+		return
+	}
+	h.unixSocket, _ = h.packet.Custom["unix-socket"].(string)
+	disableDecompression, _ := h.packet.Custom["disable-decompression"].(bool)
+	h.decompress = !disableDecompression
+	disableCookies, _ := h.packet.Custom["disable-cookies"].(bool)
+	h.cookies = !disableCookies
+	if h.packet.SuccessStatus != "" {
+		if h.successStatus, err = types.ParseSuccessStatus(h.packet.SuccessStatus); err != nil {
+			return
+		}
+	}
+	if val, ok := h.packet.Custom["prewarm-connections"]; ok {
+		if h.prewarm, err = types.ParsePrewarmConnections(val); err != nil {
+			return
+		}
+	}
+	if h.captures, err = newStepCaptures(h.packet.Custom["capture"], namespaces); err != nil {
+		return
+	}
+	for _, c := range h.captures {
+		if c.header == "" && c.cookie == "" {
+			h.capturesNeedBody = true
+		}
+	}
+	if err = h.injectFiles(); err != nil {
+		return
+	}
+
 	// TlsConfig
+	if cache, ok := h.packet.Custom["tls-session-cache"].(bool); !ok || cache {
+		h.sessionCache = tls.NewLRUClientSessionCache(0)
+		if h.warm != nil {
+			h.sessionCache = h.warm.SessionCache()
+		}
+	}
 	tlsConfig := h.initTLSConfig()
 
 	// Transport segment
-	tr := h.initTransport(tlsConfig)
+	tr := h.transport(tlsConfig)
 
 	// http client
 	h.client = &http.Client{Transport: tr, Timeout: time.Duration(h.packet.Timeout) * time.Second}
@@ -85,55 +318,76 @@ func (h *HttpRequester) Init(ctx context.Context, s types.ScenarioStep, proxyAdd
 	if err != nil {
 		return
 	}
+	if err = h.initPrewarm(tr); err != nil {
+		return
+	}
 
-	re := regexp.MustCompile(DynamicVariableRegex)
-	if re.MatchString(h.packet.Payload) {
-		_, err = h.vi.Inject(h.packet.Payload)
-		if err != nil {
+	err = h.initTemplates()
+	return
+}
+
+// initTemplates parses the dynamic variables of the request once, the static parts are shared by all the requests.
+func (h *HttpRequester) initTemplates() (err error) {
+	// Verbatim payload is sent as it is, the dynamic variables of a composed body are rendered by its template.
+	// Static protobuf payloads are encoded once.
+	static := h.packet.RawPayload
+	if !static {
+		if h.composer, err = newBodyComposer(h.packet.Payload, h.vi); err != nil {
 			return
+		} else if h.composer == nil && dynamicVariableRe.MatchString(h.packet.Payload) {
+			if h.bodyTmpl, err = h.vi.NewTemplate(h.packet.Payload); err != nil {
+				return
+			}
+		} else {
+			static = h.composer == nil
 		}
-		h.containsDynamicField["body"] = true
+	}
+	if static {
+		if h.staticBody, err = h.protobuf.encode(h.packet.Payload); err != nil {
+			return
+		}
+		h.staticGetBody = bodyGetter(h.staticBody)
 	}
 
-	if re.MatchString(h.packet.URL) {
-		_, err = h.vi.Inject(h.packet.URL)
-		if err != nil {
+	if h.urlComposer, err = newURLComposer(h.packet.URL, h.vi); err != nil {
+		return
+	} else if h.urlComposer == nil {
+		if h.urlTmpl, err = newURLTemplate(h.packet.URL, h.vi); err != nil {
 			return
 		}
-		h.containsDynamicField["url"] = true
 	}
+	h.dynamicHost = h.targets == nil && (h.urlComposer != nil || h.urlTmpl != nil) && types.TemplatedHost(h.packet.URL)
 
-	for k, values := range h.request.Header {
-		for _, v := range values {
-			if re.MatchString(k) || re.MatchString(v) {
-				_, err = h.vi.Inject(k)
-				if err != nil {
+	// Sorted, so the dynamic variables are rendered in the same order on each request of a seeded run.
+	for _, k := range sortedHeaderKeys(h.request.Header) {
+		for i, v := range h.request.Header[k] {
+			if !dynamicVariableRe.MatchString(k) && !dynamicVariableRe.MatchString(v) {
+				continue
+			}
+
+			t := headerTemplate{key: k, value: v, index: i, raw: h.packet.IsRawHeader(k)}
+			if dynamicVariableRe.MatchString(k) {
+				if t.keyTmpl, err = h.vi.NewTemplate(k); err != nil {
 					return
 				}
-
-				_, err = h.vi.Inject(v)
-				if err != nil {
+			}
+			if dynamicVariableRe.MatchString(v) {
+				if t.valTmpl, err = h.vi.NewTemplate(v); err != nil {
 					return
 				}
-				h.containsDynamicField["header"] = true
-				break
 			}
+			h.headerTmpls = append(h.headerTmpls, t)
 		}
 	}
 
-	if re.MatchString(h.packet.Auth.Username) || re.MatchString(h.packet.Auth.Password) {
-		_, err = h.vi.Inject(h.packet.Auth.Username)
-		if err != nil {
+	if dynamicVariableRe.MatchString(h.packet.Auth.Username) || dynamicVariableRe.MatchString(h.packet.Auth.Password) {
+		if h.usernameTmpl, err = h.vi.NewTemplate(h.packet.Auth.Username); err != nil {
 			return
 		}
-
-		_, err = h.vi.Inject(h.packet.Auth.Password)
-		if err != nil {
+		if h.passwordTmpl, err = h.vi.NewTemplate(h.packet.Auth.Password); err != nil {
 			return
 		}
-		h.containsDynamicField["basicauth"] = true
 	}
-
 	return
 }
 
@@ -142,70 +396,367 @@ func (h *HttpRequester) Done() {
 	// let us reuse the connections when keep-alive enabled(default)
 	// When the Job is finished, we have to Close idle connections to prevent sockets to lock in at the TIME_WAIT state.
 	// Otherwise, the next job can't use these sockets because they are reserved for the current target host.
-	h.client.CloseIdleConnections()
+	if !h.keptTransport {
+		h.client.CloseIdleConnections()
+	}
+
+	if h.targets != nil {
+		h.targets.close()
+	}
+	if h.pool != nil {
+		h.pool.close()
+	}
+	h.signer.close()
+}
+
+func (h *HttpRequester) MetricMeta() []types.MetricMeta {
+	return httpMetricMeta
+}
+
+// Send sends the request with the body composed from the values captured by the previous steps of the iteration
+// and the cookies received by them. The values captured from the response are added to the iteration.
+func (h *HttpRequester) Send(it *Iteration) (res *types.ScenarioStepResult) {
+	return h.send(h.debug, it)
+}
+
+// SendCapture sends the request like Send, the DebugInfo of the result has the rendered request and the response.
+func (h *HttpRequester) SendCapture(it *Iteration) *types.ScenarioStepResult {
+	return h.send(true, it)
+}
+
+// CaptureFailures makes Send fill the DebugInfo of the failed requests. The response body is not included
+// since it is discarded on the lean path.
+func (h *HttpRequester) CaptureFailures() {
+	h.captureFailures = true
 }
 
-func (h *HttpRequester) Send() (res *types.ScenarioStepResult) {
+// send sends the request, the request and the response bodies are kept in memory only if capture is true.
+func (h *HttpRequester) send(capture bool, it *Iteration) (res *types.ScenarioStepResult) {
 	var statusCode int
 	var contentLength int64
 	var requestErr types.RequestError
 	var reqStartTime = time.Now()
 
-	// for debug mode
+	// for debug mode and the captured requests
 	var copiedReqBody bytes.Buffer
 	var respBody []byte
 	var respHeaders http.Header
 	var debugInfo map[string]interface{}
 
-	durations := &duration{}
-	trace := newTrace(durations, h.proxyAddr)
-	httpReq := h.prepareReq(trace)
+	durations := &duration{closesConn: h.request.Close}
+	if h.packet.ExpectContinue {
+		durations.continueTimeout = expectContinueTimeout
+	}
+
+	// Adaptive timeout bounds the whole request like the timeout of the client, under it
+	reqCtx := h.ctx
+	var timeout time.Duration
+	if h.adaptive != nil {
+		var cancelTimeout context.CancelFunc
+		reqCtx, cancelTimeout, timeout = h.adaptive.bound(reqCtx)
+		defer cancelTimeout()
+	}
+	timeoutCtx := reqCtx
 
-	if h.debug {
+	// Streaming mode aborts the body read by cancelling the request
+	var cancel context.CancelFunc
+	if h.stream != nil {
+		reqCtx, cancel = context.WithCancel(reqCtx)
+		defer cancel()
+	}
+	ctx := httptrace.WithClientTrace(reqCtx, newTrace(durations, h.proxyAddr))
+
+	httpReq, err := h.prepareReq(ctx, it)
+	if err != nil {
+		return h.prepareErrResult(reqStartTime, err)
+	}
+	// First request of the virtual user to the url has no validator, it is unconditional
+	conditional := h.packet.ConditionalRequests && it.revalidate(httpReq)
+
+	// Signed with the final headers, the time of the sign command is not in the durations of the request
+	if h.signer != nil {
+		if err = h.signer.sign(reqCtx, it, httpReq); err != nil {
+			res := h.prepareErrResult(reqStartTime, err)
+			res.Custom["failedURL"] = httpReq.URL.Redacted()
+			return res
+		}
+		reqStartTime = time.Now()
+	}
+
+	// Body of the response is kept for the captures, the sampled schema validations and leak scans, the xpath
+	// assertions, the page loads, the duplicated requests, and for the capture-to-file rules until their files
+	// are written
+	validateSchema := h.schemaAssertion != nil && h.schemaAssertion.sampled()
+	scanLeaks := h.leakScanner != nil && h.leakScanner.sampled()
+	duplicate := h.duplicator != nil && h.duplicator.sampled()
+	keepBody := capture || h.capturesNeedBody || validateSchema || scanLeaks || h.xmlAssertion != nil ||
+		h.pageLoader != nil || duplicate || (h.fileCapture != nil && h.fileCapture.needBody && h.fileCapture.pending())
+
+	// Rendered body of a duplicated request is copied even without the capture, the duplicate resends its bytes
+	if capture || duplicate {
 		io.Copy(&copiedReqBody, httpReq.Body)
 		httpReq.Body = io.NopCloser(bytes.NewReader(copiedReqBody.Bytes()))
 	}
 
+	// Simulated latency of the client network, it is reported apart from the durations of the target
+	var latency time.Duration
+	if h.shaper != nil {
+		latency = h.shaper.delay(ctx)
+	}
+
 	// Action
-	httpRes, err := h.client.Do(httpReq)
-	if err != nil {
+	var httpRes *http.Response
+	var hedged hedgeOutcome
+	if h.hedger != nil {
+		httpRes, err, durations, hedged = h.hedger.do(h, h.clientOf(it), httpReq, reqCtx, durations)
+		if hedged.cancel != nil {
+			defer hedged.cancel()
+		}
+	} else {
+		httpRes, err = h.clientOf(it).Do(httpReq)
+	}
+
+	// Request failed on a stale reused connection is retried once on a fresh one, the result has the timings of the
+	// retry and the time of the stale attempt is reported apart. If it is not retried, it fails with its own reason.
+	var churnTime time.Duration
+	var churned, stale bool
+	if _, reused, _ := durations.conn(); err != nil && reused && isStaleConnErr(err) {
+		if h.churnRetry.retries(httpReq.Method) {
+			churnTime, churned = since(reqStartTime), true
+			reqStartTime, latency = time.Now(), 0
+			durations = &duration{continueTimeout: durations.continueTimeout, closesConn: true}
+			httpReq = httpReq.WithContext(httptrace.WithClientTrace(reqCtx, newTrace(durations, h.proxyAddr)))
+			if httpReq.Body, err = httpReq.GetBody(); err == nil {
+				httpRes, err = h.churnRetry.freshClient(h).Do(httpReq)
+			}
+		} else {
+			stale = true
+		}
+	}
+	if stale {
+		requestErr = types.RequestError{Type: types.ErrorConn, Reason: types.ReasonStaleConn}
+	} else if err != nil {
 		requestErr = fetchErrType(err)
 	}
+	if h.packet.ExpectContinue {
+		durations.setFinalResponse(time.Now())
+	}
 	durations.setResDur()
+	durations.setClientWaitDur(reqStartTime, latency)
 
 	// From the DOC: If the Body is not both read to EOF and closed,
 	// the Client's underlying RoundTripper (typically Transport)
 	// may not be able to re-use a persistent TCP connection to the server for a subsequent "keep-alive" request.
 	var bodyReadErr error
+	var bodyRead int64
+	var bodyTooLarge bool
+	var stream streamStats
+	var compressed *compressedBody
 	if httpRes != nil {
-		if h.debug {
-			respBody, bodyReadErr = io.ReadAll(httpRes.Body)
-		} else { // do not write into memory, just read
-			_, bodyReadErr = io.Copy(io.Discard, httpRes.Body)
+		// Transport closes the connection itself after a response with the "Connection: close" header
+		if httpRes.Close {
+			durations.closedByServer()
+		}
+
+		// The gzip bodies requested by the transport itself are already decompressed by it, without the header.
+		if h.decompress {
+			if compressed = newCompressedBody(httpRes); compressed != nil {
+				httpRes.Body = compressed
+			}
+		}
+
+		// Body is only referenced until the end of the request unless it is captured, so its buffer is reused.
+		var keep *bytes.Buffer
+		if keepBody {
+			if capture {
+				keep = &bytes.Buffer{}
+			} else {
+				keep = getResponseBuffer()
+				defer putResponseBuffer(keep)
+			}
+		}
+		if h.stream != nil {
+			stream, bodyReadErr = h.stream.readStream(httpRes, reqStartTime, keep, cancel)
+			bodyRead = stream.bytes
+			if keep != nil {
+				respBody = keep.Bytes()
+			}
+		} else {
+			// A byte over the limit tells the larger bodies, the rest is not read and the connection is closed.
+			// The decompressed bytes are limited, so a compressed body doesn't inflate over it either.
+			body := io.LimitReader(httpRes.Body, h.maxBodySize+1)
+			if keepBody {
+				bodyRead, bodyReadErr = keep.ReadFrom(body)
+			} else { // do not write into memory, just read
+				bodyRead, bodyReadErr = io.Copy(io.Discard, body)
+			}
+			if bodyTooLarge = bodyRead > h.maxBodySize; bodyTooLarge {
+				bodyRead = h.maxBodySize
+				if keepBody {
+					keep.Truncate(int(h.maxBodySize))
+				}
+			}
+			if keepBody {
+				respBody = keep.Bytes()
+			}
 		}
 		if bodyReadErr != nil {
-			requestErr = fetchErrType(bodyReadErr)
+			requestErr = fetchBodyErrType(bodyReadErr)
+		}
+		if compressed != nil {
+			bodyRead = compressed.wire.n
 		}
 
 		httpRes.Body.Close()
+		if h.cookies {
+			it.setCookies(httpRes)
+		}
+		if h.packet.ConditionalRequests {
+			it.keepValidators(httpReq, httpRes)
+		}
 		respHeaders = httpRes.Header
 		contentLength = httpRes.ContentLength
 		statusCode = httpRes.StatusCode
 	}
 
+	// Cut body fails the checks over it with its own error, instead of a parse error of the partial body
+	if bodyTooLarge && requestErr.Type == "" && h.checksBody(validateSchema, respHeaders) {
+		requestErr = types.RequestError{Type: types.ErrorBodyLimit,
+			Reason: fmt.Sprintf("body too large: truncated at max_body_size %d bytes", h.maxBodySize)}
+	}
+
+	if h.successStatus != nil && httpRes != nil && requestErr.Type == "" && !h.successStatus.Contains(statusCode) {
+		requestErr = types.RequestError{Type: types.ErrorStatus,
+			Reason: fmt.Sprintf("unexpected status code: %d", statusCode)}
+	}
+
+	// Duplicate follows the response of the original, it is compared with the body as received
+	wireBody := respBody
+	var duplicated *types.DuplicateResult
+	if duplicate && httpRes != nil {
+		d := h.duplicator.send(h, h.clientOf(it), httpReq, copiedReqBody.Bytes(), statusCode, wireBody)
+		duplicated = &d
+	}
+
+	// Protobuf response is decoded once, the captures and the assertions over the body see its JSON
+	var leakScan *types.LeakScanResult
+	if scanLeaks && httpRes != nil {
+		scan := h.leakScanner.check(wireBody, httpReq.URL.Redacted(), httpReq.Header.Get("Authorization"))
+		leakScan = &scan
+	}
+	var respMessage []byte
+	if keepBody && httpRes != nil && requestErr.Type == "" && h.protobuf.decodes(respHeaders) {
+		if decoded, err, ok := h.protobuf.decodeResponse(respBody); ok {
+			respBody, respMessage = decoded, decoded
+		} else {
+			requestErr = err
+		}
+	}
+
+	if validateSchema && requestErr.Type == "" {
+		if err, ok := h.schemaAssertion.check(respBody); !ok {
+			requestErr = err
+		}
+	}
+
+	// XML body is parsed once if any xpath needs it
+	xmlResp := newXMLBody(respBody)
+	if h.xmlAssertion != nil && requestErr.Type == "" {
+		if err, ok := h.xmlAssertion.check(xmlResp); !ok {
+			requestErr = err
+		}
+	}
+
+	if h.cookieAssertion != nil && httpRes != nil && requestErr.Type == "" {
+		if err, ok := h.cookieAssertion.check(respHeaders); !ok {
+			requestErr = err
+		}
+	}
+
+	if h.fileCapture != nil && requestErr.Type == "" && statusCode >= 200 && statusCode < 300 {
+		if err := h.fileCapture.capture(respBody, respHeaders, xmlResp); err != nil {
+			requestErr = types.RequestError{Type: types.ErrorUnkown, Reason: err.Error()}
+		}
+	}
+
+	// Missing values are left out, their consumers fall back to the defaults.
+	// An xpath capture over an invalid XML body fails the request instead.
+	var captured map[string]string
+	if len(h.captures) > 0 && requestErr.Type == "" {
+		captured = make(map[string]string, len(h.captures))
+		for _, c := range h.captures {
+			if c.maxSize > 0 && int64(len(respBody)) > c.maxSize {
+				requestErr = types.RequestError{Type: types.ErrorAssertion,
+					Reason: fmt.Sprintf("capture %s: response body is larger than %d bytes", c.name, c.maxSize)}
+				captured = nil
+				break
+			}
+			v, err := c.extract(respBody, respHeaders, xmlResp)
+			if err == errInvalidXML {
+				requestErr = types.RequestError{Type: types.ErrorAssertion, Reason: "xpath capture: " + err.Error()}
+				captured = nil
+				break
+			}
+			if err == nil {
+				captured[c.name] = v
+			}
+		}
+	}
+	for _, c := range h.captures {
+		if v, ok := captured[c.name]; ok {
+			it.Capture(c.name, v)
+			if c.collect {
+				it.collect(c.name, v)
+			}
+		}
+	}
+
+	// Resources of a successfully fetched HTML page are loaded after it, over the connections of the page
+	var pageResources []types.PageResource
+	var resourcesTime time.Duration
+	pageLoaded := h.pageLoader != nil && httpRes != nil && requestErr.Type == "" && statusCode >= 200 &&
+		statusCode < 300 && isHTML(respHeaders)
+	if pageLoaded {
+		pageResources, resourcesTime = h.pageLoader.load(reqCtx, h, it, h.clientOf(it), httpRes.Request.URL, wireBody,
+			httpReq.Header)
+	}
+
 	var ddResTime time.Duration
 	if httpRes != nil && httpRes.Header.Get("x-ddsfy-response-time") != "" {
 		resTime, _ := strconv.ParseFloat(httpRes.Header.Get("x-ddsfy-response-time"), 8)
 		ddResTime = time.Duration(resTime*1000) * time.Millisecond
 	}
 
-	if h.debug {
+	if capture {
 		debugInfo = map[string]interface{}{
 			"url":             httpReq.URL.String(),
 			"method":          httpReq.Method,
 			"requestHeaders":  httpReq.Header,
 			"requestBody":     copiedReqBody.Bytes(),
-			"responseBody":    respBody,
+			"responseBody":    wireBody,
+			"responseHeaders": respHeaders,
+		}
+		if msg := h.protobuf.requestJSON(copiedReqBody.Bytes()); msg != nil {
+			debugInfo["requestMessage"] = msg
+		}
+		if respMessage != nil {
+			debugInfo["responseMessage"] = respMessage
+		}
+		if matches := h.xpathMatches(xmlResp); matches != nil {
+			debugInfo["xpathMatches"] = matches
+		}
+	} else if h.captureFailures && requestErr.Type != "" {
+		var reqBody []byte
+		if httpReq.GetBody != nil {
+			if b, err := httpReq.GetBody(); err == nil {
+				reqBody, _ = io.ReadAll(b)
+			}
+		}
+		debugInfo = map[string]interface{}{
+			"url":             httpReq.URL.String(),
+			"method":          httpReq.Method,
+			"requestHeaders":  httpReq.Header,
+			"requestBody":     reqBody,
 			"responseHeaders": respHeaders,
 		}
 	}
@@ -217,7 +768,7 @@ func (h *HttpRequester) Send() (res *types.ScenarioStepResult) {
 		RequestID:     uuid.New(),
 		StatusCode:    statusCode,
 		RequestTime:   reqStartTime,
-		Duration:      durations.totalDuration(),
+		Duration:      latency + durations.totalDuration(),
 		ContentLength: contentLength,
 		Err:           requestErr,
 		DebugInfo:     debugInfo,
@@ -227,61 +778,423 @@ func (h *HttpRequester) Send() (res *types.ScenarioStepResult) {
 			"reqDuration":           durations.getReqDur(),
 			"resDuration":           durations.getResDur(),
 			"serverProcessDuration": durations.getServerProcessDur(),
+			"clientWaitDuration":    durations.getClientWaitDur(),
 		},
 	}
+	if h.shaper != nil && (h.shaper.Latency > 0 || h.shaper.Jitter > 0) {
+		res.Custom["latencyDuration"] = latency
+	}
+	if h.packet.ConditionalRequests {
+		res.Custom["conditional"] = conditional
+	}
+	if churned {
+		res.Custom["churnRetried"] = true
+		res.Custom["churnRetryTime"] = churnTime
+	}
+	// Winning hedge is sent after the delay, the duration of the request is from the first one. Duration without
+	// the hedging is the one of the first request, or its time until it is cancelled by the winning hedge.
+	if h.hedger != nil {
+		unhedged := res.Duration
+		if hedged.won {
+			res.Duration += hedged.wait
+			unhedged = latency + hedged.unhedged
+			res.Custom["hedgeWon"] = true
+		}
+		if hedged.fired {
+			res.Custom["hedged"] = true
+		}
+		res.Custom["unhedgedTime"] = unhedged
+	}
+	// Load time of the page is from its request until its last resource, the resources are not the requests of the step
+	if pageLoaded {
+		res.Custom["pageLoadTime"] = res.Duration + resourcesTime
+		res.Custom["pageResources"] = pageResources
+	}
+	if got, reused, ip := durations.conn(); got {
+		res.Custom["connReused"] = reused
+		if n, reconnect := durations.connUse(); n > 0 {
+			res.Custom["connRequest"] = n
+			if reconnect {
+				res.Custom["connReconnect"] = true
+			}
+		}
+		// Address of a proxy is not the address of the target, and the IP hosts are not resolved
+		if host := httpReq.URL.Hostname(); ip != "" && h.proxyAddr == nil && net.ParseIP(host) == nil {
+			res.Custom["resolvedHost"] = host
+			res.Custom["resolvedIP"] = ip
+		}
+	}
+	if n := h.transferred.claimServerCloses(); n > 0 {
+		res.Custom["serverCloses"] = n
+	}
+	if sent, received := h.transferred.claim(); sent+received > 0 {
+		res.Custom["bytesSent"] = sent
+		res.Custom["bytesReceived"] = received
+	}
+	if compressed != nil {
+		res.Custom["compressedBytes"] = compressed.wire.n
+		res.Custom["decompressedBytes"] = compressed.decoded
+		res.Custom["decompressDuration"] = compressed.decompressDuration()
+	}
+	if wait, waited, timedOut := durations.continueWait(); waited {
+		res.Custom["continueWaitDuration"] = wait
+		if timedOut {
+			res.Custom["continueTimedOut"] = true
+		}
+	}
 	if h.packet.Protocol == types.ProtocolHTTPS {
 		res.Custom["tlsDuration"] = durations.getTLSDur()
+		if hs := durations.tls(); hs.done {
+			res.Custom["tlsResumed"] = hs.resumed
+			res.Custom["tlsVersion"] = tlsVersionName(hs.version)
+			if hs.alpn != "" {
+				res.Custom["tlsALPN"] = hs.alpn
+			}
+			// Server name of the handshake is the host of the last redirect
+			host := hs.serverName
+			if host == "" {
+				host = httpReq.URL.Hostname()
+			}
+			if cert, ok := h.certificates.first(host, hs.certs); ok {
+				res.Custom["tlsCertificate"] = cert
+			}
+		}
 	}
 	if ddResTime != 0 {
 		res.Custom["ddResponseTime"] = ddResTime
 	}
+	if h.stream != nil && httpRes != nil {
+		res.Custom["firstByteDuration"] = stream.firstByte
+		res.Custom["lastByteDuration"] = stream.lastByte
+		res.Custom["chunkCount"] = stream.chunks
+		res.Custom["byteCount"] = stream.bytes
+		if stream.sse {
+			res.Custom["eventCount"] = stream.events
+		}
+	}
+
+	// Reported as the failure samples of the step
+	if requestErr.Type != "" {
+		res.Custom["failedURL"] = httpReq.URL.Redacted()
+		if len(respBody) > 0 {
+			res.Custom["responseSnippet"] = failureSnippet(respBody, httpReq.Header.Get("Authorization"))
+		}
+	}
+
+	if h.targets != nil {
+		res.Custom["targetURL"] = httpReq.URL.String()
+		if h.urlGroups != nil {
+			res.Custom["endpoint"] = types.GroupURL(h.urlGroups, httpReq.URL)
+		}
+	}
+
+	if h.hosts != nil || h.dynamicHost {
+		res.Custom["host"] = httpReq.URL.Host
+	}
+
+	// Redirects are reported with the time spent before the final request, the failed redirects too
+	if chain, loop := redirectChain(httpRes); chain != nil {
+		res.Custom["redirects"] = chain
+		res.Custom["redirectTime"] = durations.redirectTime()
+		if loop {
+			res.Custom["redirectLoop"] = true
+		}
+	}
+
+	if h.adaptive != nil {
+		res.Custom["adaptiveTimeout"] = timeout
+		if isTimeoutReason(res.Err.Reason) {
+			if cut := h.adaptive.cut(timeoutCtx, timeout); cut != "" {
+				res.Custom["timeoutCut"] = cut
+			}
+		}
+	}
+
+	if h.dimensions != nil {
+		dimensions := make(map[string]string, len(h.dimensions))
+		for _, name := range h.dimensions {
+			value := respHeaders.Get(name)
+			if value == "" {
+				value = types.MissingDimensionValue
+			}
+			dimensions[name] = value
+		}
+		res.Custom["dimensions"] = dimensions
+	}
+
+	if requestErr.Type == types.ErrorTruncated {
+		res.Custom["truncatedBytes"] = bodyRead
+	}
+	if bodyTooLarge {
+		res.Custom["bodyTooLarge"] = true
+		res.Custom["bodyBytesRead"] = bodyRead
+	}
+
+	if leakScan != nil {
+		res.Custom["leakScan"] = *leakScan
+	}
+
+	if duplicated != nil {
+		res.Custom["duplicate"] = *duplicated
+	}
+
+	if _, ok := h.packet.Custom["retry-after"]; ok && statusCode == http.StatusTooManyRequests {
+		res.Custom["rateLimited"] = true
+		if backoff, ok := parseRetryAfter(respHeaders.Get("Retry-After"), h.timeSource().Now()); ok {
+			res.Custom["retryAfter"] = backoff
+		}
+	}
 
 	return
 }
 
-func (h *HttpRequester) prepareReq(trace *httptrace.ClientTrace) *http.Request {
-	re := regexp.MustCompile(DynamicVariableRegex)
-	httpReq := h.request.Clone(h.ctx)
+// checksBody reports whether the checks of the request read the response body, like the captures and the
+// assertions over it.
+func (h *HttpRequester) checksBody(validateSchema bool, respHeaders http.Header) bool {
+	return h.capturesNeedBody || validateSchema || h.xmlAssertion != nil ||
+		(h.fileCapture != nil && h.fileCapture.needBody) || h.protobuf.decodes(respHeaders)
+}
 
-	body := h.packet.Payload
-	if h.containsDynamicField["body"] {
-		body, _ = h.vi.Inject(h.packet.Payload)
+// Preview renders the request exactly as Send does but stops before the network write.
+// Template variables left in the rendered request are reported as unresolved.
+func (h *HttpRequester) Preview() (res *types.ScenarioStepResult) {
+	httpReq, err := h.prepareReq(h.ctx, nil)
+	if err != nil {
+		return h.prepareErrResult(time.Now(), err)
 	}
 
-	httpReq.Body = io.NopCloser(bytes.NewBufferString(body))
-	httpReq.ContentLength = int64(len(body))
+	var body bytes.Buffer
+	io.Copy(&body, httpReq.Body)
 
-	httpReq.URL, _ = url.Parse(h.packet.URL)
-	if h.containsDynamicField["url"] {
-		u, _ := h.vi.Inject(h.packet.URL)
-		httpReq.URL, _ = url.Parse(u)
+	unresolved := make([]string, 0)
+	re := regexp.MustCompile(UnresolvedVariableRegex)
+	unresolved = append(unresolved, re.FindAllString(httpReq.URL.String(), -1)...)
+	for k, values := range httpReq.Header {
+		unresolved = append(unresolved, re.FindAllString(k, -1)...)
+		for _, v := range values {
+			unresolved = append(unresolved, re.FindAllString(v, -1)...)
+		}
+	}
+	if !h.packet.RawPayload {
+		unresolved = append(unresolved, re.FindAllString(body.String(), -1)...)
 	}
 
-	if h.containsDynamicField["header"] {
-		for k, values := range httpReq.Header {
-			for _, v := range values {
-				kk := k
-				vv := v
-				if re.MatchString(v) {
-					vv, _ = h.vi.Inject(v)
-				}
-				if re.MatchString(k) {
-					kk, _ = h.vi.Inject(k)
-					httpReq.Header.Del(k)
-				}
-				httpReq.Header.Set(kk, vv)
-			}
+	res = &types.ScenarioStepResult{
+		StepID:      h.packet.ID,
+		StepName:    h.packet.Name,
+		RequestID:   uuid.New(),
+		RequestTime: time.Now(),
+		DebugInfo: map[string]interface{}{
+			"url":                 httpReq.URL.String(),
+			"method":              httpReq.Method,
+			"requestHeaders":      httpReq.Header,
+			"requestBody":         body.Bytes(),
+			"preview":             true,
+			"unresolvedVariables": unresolved,
+		},
+		Custom: map[string]interface{}{},
+	}
+	// Composed body of the preview is left as its JSON template, it is not encoded
+	if h.composer == nil {
+		if msg := h.protobuf.requestJSON(body.Bytes()); msg != nil {
+			res.DebugInfo["requestMessage"] = msg
 		}
 	}
+	return
+}
 
-	if h.containsDynamicField["basicauth"] {
-		username, _ := h.vi.Inject(h.packet.Auth.Username)
-		password, _ := h.vi.Inject(h.packet.Auth.Password)
-		httpReq.SetBasicAuth(username, password)
+// prepareErrResult returns the failed result of a request that couldn't be rendered.
+// Debug info is filled from the step since there is no rendered request.
+func (h *HttpRequester) prepareErrResult(reqStartTime time.Time, err error) *types.ScenarioStepResult {
+	errType := types.ErrorUnkown
+	custom := map[string]interface{}{}
+	switch e := err.(type) {
+	case *protobufEncodeError:
+		errType = types.ErrorProtobuf
+	case *signError:
+		errType = types.ErrorSign
+		if e.stderr != "" {
+			custom["signStderr"] = e.stderr
+		}
+	}
+	return &types.ScenarioStepResult{
+		StepID:      h.packet.ID,
+		StepName:    h.packet.Name,
+		RequestID:   uuid.New(),
+		RequestTime: reqStartTime,
+		Err:         types.RequestError{Type: errType, Reason: err.Error()},
+		DebugInfo: map[string]interface{}{
+			"url":            h.packet.URL,
+			"method":         h.packet.Method,
+			"requestHeaders": h.request.Header,
+			"requestBody":    []byte(h.packet.Payload),
+		},
+		Custom: custom,
 	}
+}
 
-	httpReq = httpReq.WithContext(httptrace.WithClientTrace(httpReq.Context(), trace))
-	return httpReq
+func sortedHeaderKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// prepareReq renders the request of the step with its dynamic fields. The hot path only renders the parts with the
+// dynamic variables, the parsed URL and the header map of the static steps are shared by the requests.
+// Without the captures, like in the preview mode, a composed body is left as its template.
+// prepareReq renders the request in the iteration, it is nil for the preview.
+func (h *HttpRequester) prepareReq(ctx context.Context, it *Iteration) (*http.Request, error) {
+	httpReq := h.request.WithContext(ctx)
+	values := it.values(h.packet.ID)
+
+	if h.composer != nil && it != nil {
+		body, err := h.composer.compose(it.Captures, values)
+		if err != nil {
+			return nil, err
+		}
+		if body, err = h.protobuf.encode(body); err != nil {
+			return nil, err
+		}
+		setBody(httpReq, body, nil)
+	} else if h.composer != nil {
+		setBody(httpReq, h.packet.Payload, nil)
+	} else if h.bodyTmpl != nil {
+		body, err := h.protobuf.encode(h.bodyTmpl.ExecuteFor(values))
+		if err != nil {
+			return nil, err
+		}
+		setBody(httpReq, body, nil)
+	} else {
+		setBody(httpReq, h.staticBody, h.staticGetBody)
+	}
+	// Unknown length makes the transport send the body chunked
+	if h.packet.Chunked && httpReq.ContentLength > 0 {
+		httpReq.ContentLength = -1
+	}
+
+	if h.targets != nil {
+		var target string
+		var err error
+		if it != nil && it.User != nil {
+			target, err = h.targets.nextOf(*it.User)
+		} else {
+			target, err = h.targets.next()
+		}
+		if err != nil {
+			return nil, err
+		}
+		httpReq.URL, _ = url.Parse(target)
+		if err = types.WireURL(httpReq.URL); err != nil {
+			return nil, err
+		}
+		if !h.customHost {
+			httpReq.Host = ""
+		}
+	} else if h.urlComposer != nil && it != nil {
+		raw, err := h.urlComposer.compose(it.Captures, values)
+		if err != nil {
+			return nil, err
+		}
+		if httpReq.URL, err = url.Parse(raw); err != nil {
+			return nil, fmt.Errorf("composed url is invalid: %v", err)
+		}
+		if err = types.WireURL(httpReq.URL); err != nil {
+			return nil, fmt.Errorf("composed url is invalid: %v", err)
+		}
+	} else if h.urlTmpl != nil {
+		u, err := h.urlTmpl.render(values)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.URL = u
+	}
+	// Host of the placeholder url is not the rendered one
+	if h.dynamicHost && !h.customHost {
+		httpReq.Host = ""
+	}
+
+	if h.hosts != nil {
+		u := *httpReq.URL
+		u.Host = h.hosts.next()
+		if err := types.WireURL(&u); err != nil {
+			return nil, err
+		}
+		httpReq.URL = &u
+		if !h.customHost {
+			httpReq.Host = ""
+		}
+	}
+
+	// Variant of the iteration replaces the scheme and the host of the url and overrides the headers of the step
+	var variant types.ABVariant
+	if it != nil && h.variants != nil {
+		variant = h.variants[it.Variant]
+	}
+	if variant.BaseURL != nil {
+		u := *httpReq.URL
+		u.Scheme, u.Host = variant.BaseURL.Scheme, variant.BaseURL.Host
+		httpReq.URL = &u
+		if !h.customHost {
+			httpReq.Host = ""
+		}
+	}
+
+	// Header of the request instance is shared, it is cloned before it is changed
+	var cookies []*http.Cookie
+	if h.cookies {
+		cookies = it.cookies(httpReq.URL)
+	}
+	if h.headerTmpls != nil || h.usernameTmpl != nil || cookies != nil || variant.Headers != nil ||
+		h.packet.ConditionalRequests {
+		httpReq.Header = h.request.Header.Clone()
+	}
+	for _, c := range cookies {
+		httpReq.AddCookie(c)
+	}
+	for _, t := range h.headerTmpls {
+		t.render(httpReq.Header, values)
+	}
+	for k, v := range variant.Headers {
+		if strings.EqualFold(k, "Host") {
+			httpReq.Host = v
+		} else {
+			httpReq.Header.Set(k, v)
+		}
+	}
+
+	if h.usernameTmpl != nil {
+		httpReq.SetBasicAuth(h.usernameTmpl.ExecuteFor(values), h.passwordTmpl.ExecuteFor(values))
+	}
+
+	return httpReq, nil
+}
+
+// parseRetryAfter parses the Retry-After header value, which can be either delay-seconds or an HTTP-date.
+// Returns false for missing, negative or malformed values.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 || secs > int64(math.MaxInt64/time.Second) {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
 }
 
 // Currently we can't detect exact error type by returned err.
@@ -320,12 +1233,29 @@ func fetchErrType(err error) types.RequestError {
 	return requestErr
 }
 
+// fetchBodyErrType returns the error of reading the response body. Since the status and the headers are
+// received, the errors other than the cancellations, the timeouts and the invalid compressed bodies are reported
+// as truncated responses.
+func fetchBodyErrType(err error) types.RequestError {
+	var netErr net.Error
+	var decompressErr *decompressError
+	if errors.As(err, &decompressErr) {
+		return types.RequestError{Type: types.ErrorDecompress, Reason: decompressErr.reason()}
+	} else if errors.Is(err, context.Canceled) {
+		return types.RequestError{Type: types.ErrorIntented, Reason: types.ReasonCtxCanceled}
+	} else if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return types.RequestError{Type: types.ErrorConn, Reason: types.ReasonReadTimeout}
+	}
+	return types.RequestError{Type: types.ErrorTruncated, Reason: types.ReasonTruncated}
+}
+
 func (h *HttpRequester) initTransport(tlsConfig *tls.Config) *http.Transport {
 	tr := &http.Transport{
 		TLSClientConfig:     tlsConfig,
 		Proxy:               http.ProxyURL(h.proxyAddr),
 		MaxIdleConnsPerHost: 60000,
 		MaxIdleConns:        0,
+		DialContext:         h.dialContext(),
 	}
 
 	tr.DisableKeepAlives = false
@@ -335,6 +1265,9 @@ func (h *HttpRequester) initTransport(tlsConfig *tls.Config) *http.Transport {
 	if val, ok := h.packet.Custom["disable-compression"]; ok {
 		tr.DisableCompression = val.(bool)
 	}
+	if h.packet.ExpectContinue {
+		tr.ExpectContinueTimeout = expectContinueTimeout
+	}
 	if val, ok := h.packet.Custom["h2"]; ok {
 		val := val.(bool)
 		if val {
@@ -344,9 +1277,65 @@ func (h *HttpRequester) initTransport(tlsConfig *tls.Config) *http.Transport {
 	return tr
 }
 
+// clientOf returns the client of the connection scope of the iteration.
+func (h *HttpRequester) clientOf(it *Iteration) *http.Client {
+	if it == nil || it.Connections == nil {
+		return h.client
+	}
+	return it.Connections.client(h)
+}
+
+// newClient returns a client like the shared client of the step, with its own connections. The prewarmed connections
+// are taken by the first clients.
+func (h *HttpRequester) newClient() *http.Client {
+	tr := h.initTransport(h.initTLSConfig())
+	if h.pool != nil {
+		if h.request.URL.Scheme == "https" {
+			tr.DialTLSContext = h.pool.dialContext()
+		} else {
+			tr.DialContext = h.pool.dialContext()
+		}
+	}
+	client := *h.client
+	client.Transport = tr
+	return &client
+}
+
+// dialContext returns the dialer of the connections, which are counted and shaped by the network option of the step.
+// If the step targets a unix socket, all the connections are dialed to the socket. Otherwise, the hosts are resolved
+// by the warm state if the run has one.
+func (h *HttpRequester) dialContext() dialFunc {
+	dial := dialFunc(h.transferred.dialContext())
+	if h.unixSocket != "" {
+		dial = unixSocketDial(dial, h.unixSocket)
+	} else if h.warm != nil {
+		dial = h.warm.DialContext(dial)
+	}
+	if h.shaper != nil {
+		dial = h.shaper.dialContext(dial)
+	}
+	return dial
+}
+
+// tlsVersionName returns the name of the TLS version like "TLS 1.3".
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04X", v)
+}
+
 func (h *HttpRequester) initTLSConfig() *tls.Config {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: true,
+		ClientSessionCache: h.sessionCache,
 	}
 
 	if h.packet.CertPool != nil && h.packet.Cert.Certificate != nil {
@@ -360,22 +1349,90 @@ func (h *HttpRequester) initTLSConfig() *tls.Config {
 	return tlsConfig
 }
 
+// injectFiles replaces the {{file "path"}} templates of the step once, the files are not read per request.
+func (h *HttpRequester) injectFiles() (err error) {
+	fi := &scripting.FileInjector{}
+	if h.packet.URL, err = fi.Inject(h.packet.URL); err != nil {
+		return
+	}
+	// Verbatim payload is not scanned for the file injections
+	if !h.packet.RawPayload {
+		if h.packet.Payload, err = fi.Inject(h.packet.Payload); err != nil {
+			return
+		}
+	}
+
+	if h.packet.Headers != nil {
+		headers := make(map[string]string, len(h.packet.Headers))
+		for k, v := range h.packet.Headers {
+			if headers[k], err = fi.Inject(v); err != nil {
+				return
+			}
+		}
+		h.packet.Headers = headers
+	}
+	if h.packet.RepeatedHeaders != nil {
+		headers := make(map[string][]string, len(h.packet.RepeatedHeaders))
+		for k, values := range h.packet.RepeatedHeaders {
+			headers[k] = make([]string, len(values))
+			for i, v := range values {
+				if headers[k][i], err = fi.Inject(v); err != nil {
+					return
+				}
+			}
+		}
+		h.packet.RepeatedHeaders = headers
+	}
+
+	if h.packet.Auth.Username, err = fi.Inject(h.packet.Auth.Username); err != nil {
+		return
+	}
+	h.packet.Auth.Password, err = fi.Inject(h.packet.Auth.Password)
+	return
+}
+
 func (h *HttpRequester) initRequestInstance() (err error) {
-	h.request, err = http.NewRequest(h.packet.Method, h.packet.URL, bytes.NewBufferString(h.packet.Payload))
+	// Dynamic URLs are rendered on each request, the variables are replaced with a placeholder here,
+	// so the variables in the host are parsable.
+	// The actions of a composed URL are replaced the same way.
+	rawURL := dynamicVariableRe.ReplaceAllString(h.packet.URL, "x")
+	if types.IsComposedBody(rawURL) {
+		rawURL = regexp.MustCompile(UnresolvedVariableRegex).ReplaceAllString(rawURL, "x")
+	}
+	h.request, err = http.NewRequest(h.packet.Method, rawURL, bytes.NewBufferString(h.packet.Payload))
 	if err != nil {
 		return
 	}
+	// Unicode host is sent as punycode, a Host header given by the step is sent as it is
+	host := h.request.URL.Host
+	if err = types.WireURL(h.request.URL); err != nil {
+		return
+	}
+	if h.request.URL.Host != host {
+		h.request.Host = h.request.URL.Host
+	}
 
 	// Headers
 	header := make(http.Header)
 	for k, v := range h.packet.Headers {
 		if strings.EqualFold(k, "Host") {
 			h.request.Host = v
+			h.customHost = true
 		} else {
-			header.Set(k, v)
+			addHeader(header, k, v, h.packet.IsRawHeader(k))
+		}
+	}
+	// Sorted, so the keys differing by case only are sent in the same order on each run
+	for _, k := range sortedHeaderKeys(h.packet.RepeatedHeaders) {
+		for _, v := range h.packet.RepeatedHeaders[k] {
+			addHeader(header, k, v, h.packet.IsRawHeader(k))
 		}
 	}
 
+	if h.packet.ExpectContinue && header.Get("Expect") == "" {
+		header.Set("Expect", "100-continue")
+	}
+
 	h.request.Header = header
 
 	// Auth should be set after header assignment.
@@ -392,58 +1449,65 @@ func (h *HttpRequester) initRequestInstance() (err error) {
 }
 
 func newTrace(duration *duration, proxyAddr *url.URL) *httptrace.ClientTrace {
-	var dnsStart, connStart, tlsStart, reqStart, serverProcessStart time.Time
-
+	start := &duration.start
 	// According to the doc in the trace.go;
 	// Some of the hooks below can be triggered multiple times in case of retried connections, "Happy Eyeballs" etc..
 	// Also, some of the hooks can be triggered after the TCP roundtrip if the request is not successfully finished.
 	// To fetch the time only at the first trigger and prevent data race we need to use the mutex mechanism.
 	// For start times, except resStart, this mutex is been using.
 	// For duration calculations, "duration" struct internally uses another mutex.
-	var m sync.Mutex
-
 	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			start.Lock()
+			// Each request of a redirect chain gets a connection, the last one is of the final request
+			now := time.Now()
+			if start.getConn.IsZero() {
+				start.getConn = now
+			}
+			start.lastGetConn = now
+			start.Unlock()
+		},
 		DNSStart: func(info httptrace.DNSStartInfo) {
-			m.Lock()
-			if dnsStart.IsZero() {
-				dnsStart = time.Now()
+			start.Lock()
+			if start.dns.IsZero() {
+				start.dns = time.Now()
 			}
-			m.Unlock()
+			start.Unlock()
 		},
 		DNSDone: func(dnsInfo httptrace.DNSDoneInfo) {
-			m.Lock()
+			start.Lock()
 			// no need to handle error in here. We can detect it at http.Client.Do return.
 			if dnsInfo.Err == nil {
-				duration.setDNSDur(time.Since(dnsStart))
+				duration.setDNSDur(since(start.dns))
 			}
-			m.Unlock()
+			start.Unlock()
 		},
 		ConnectStart: func(network, addr string) {
-			m.Lock()
-			if connStart.IsZero() {
-				connStart = time.Now()
+			start.Lock()
+			if start.conn.IsZero() {
+				start.conn = time.Now()
 			}
-			m.Unlock()
+			start.Unlock()
 		},
 		ConnectDone: func(network, addr string, err error) {
-			m.Lock()
+			start.Lock()
 			// no need to handle error in here. We can detect it at http.Client.Do return.
 			if err == nil {
-				duration.setConnDur(time.Since(connStart))
+				duration.setConnDur(since(start.conn))
 			}
-			m.Unlock()
+			start.Unlock()
 		},
 		TLSHandshakeStart: func() {
-			m.Lock()
+			start.Lock()
 			// This hook can be hit 2 times;
 			// If both proxy and target are HTTPS
 			//	First hit is for proxy, second is for target.
-			//  To catch the second TLS start time (for target), we can't perform tlsStart.IsZero() check here.
-			tlsStart = time.Now()
-			m.Unlock()
+			//  To catch the second TLS start time (for target), we can't perform start.tls.IsZero() check here.
+			start.tls = time.Now()
+			start.Unlock()
 		},
 		TLSHandshakeDone: func(cs tls.ConnectionState, e error) {
-			m.Lock()
+			start.Lock()
 			// This hook can be hit 2 times;
 			// If proxy: HTTPS, target: HTTPS
 			//	First hit is for proxy, second is for target TLS
@@ -451,32 +1515,72 @@ func newTrace(duration *duration, proxyAddr *url.URL) *httptrace.ClientTrace {
 
 			if e == nil {
 				if proxyAddr == nil || proxyAddr.Hostname() != cs.ServerName {
-					duration.setTLSDur(time.Since(tlsStart))
+					duration.setTLSDur(since(start.tls))
+					start.handshake = tlsHandshake{done: true, resumed: cs.DidResume, version: cs.Version,
+						alpn: cs.NegotiatedProtocol, serverName: cs.ServerName, certs: cs.PeerCertificates}
 				}
 			}
-			m.Unlock()
+			start.Unlock()
 		},
 		GotConn: func(connInfo httptrace.GotConnInfo) {
-			m.Lock()
-			if reqStart.IsZero() {
-				reqStart = time.Now()
+			start.Lock()
+			if start.req.IsZero() {
+				start.req = time.Now()
+				start.gotConnAt = start.req
+				start.gotConn, start.reused = true, connInfo.Reused
+				if connInfo.Conn != nil {
+					start.remoteIP, _, _ = net.SplitHostPort(connInfo.Conn.RemoteAddr().String())
+				}
+				if cc := countedConn(connInfo.Conn); cc != nil {
+					start.counted = cc
+					start.connRequest = cc.countRequest(duration.closesConn)
+					start.reconnect = !connInfo.Reused && cc.counter.replace()
+				}
+			}
+			start.Unlock()
+		},
+		Wait100Continue: func() {
+			start.Lock()
+			start.continueWait = time.Now()
+			start.Unlock()
+		},
+		Got100Continue: func() {
+			start.Lock()
+			// Request write is the upload of the body after the interim response
+			if !start.continueWait.IsZero() {
+				start.req = time.Now()
+				duration.setContinueWaitDur(between(start.continueWait, start.req), false)
 			}
-			m.Unlock()
+			start.Unlock()
 		},
 		WroteRequest: func(w httptrace.WroteRequestInfo) {
-			m.Lock()
+			start.Lock()
+			// Without the interim response, the body is sent at the timeout unless the final response comes first
+			if _, waited, _ := duration.continueWait(); !start.continueWait.IsZero() && !waited {
+				wait := since(start.continueWait)
+				timedOut := wait >= duration.continueTimeout
+				if timedOut {
+					wait = duration.continueTimeout
+				}
+				start.req = start.continueWait.Add(wait)
+				duration.setContinueWaitDur(wait, timedOut)
+			}
 			// no need to handle error in here. We can detect it at http.Client.Do return.
 			if w.Err == nil {
-				duration.setReqDur(time.Since(reqStart))
-				serverProcessStart = time.Now()
+				duration.setReqDur(since(start.req))
+				start.serverProcess = time.Now()
 			}
-			m.Unlock()
+			start.Unlock()
 		},
 		GotFirstResponseByte: func() {
-			m.Lock()
-			duration.setServerProcessDur(time.Since(serverProcessStart))
-			duration.setResStartTime(time.Now())
-			m.Unlock()
+			start.Lock()
+			// First byte of a request waiting to send its body is the interim or an early final response, the server
+			// processing is set by setFinalResponse then
+			if start.continueWait.IsZero() || !start.serverProcess.IsZero() {
+				duration.setServerProcessDur(since(start.serverProcess))
+				duration.setResStartTime(time.Now())
+			}
+			start.Unlock()
 		},
 	}
 }
@@ -503,7 +1607,103 @@ type duration struct {
 	// Resposne read duration
 	resDur time.Duration
 
+	// Duration that the request waits in the client until it gets a connection, excluding the dial of a new
+	// connection and the injected latency. It grows when the transport queues the requests of a saturated generator.
+	clientWaitDur time.Duration
+
+	// Duration that the request with the "Expect: 100-continue" header waits for the interim response before sending
+	// its body, bounded by continueTimeout. continueTimedOut is set if the server sent no interim response in time.
+	continueTimeout  time.Duration
+	continueWaitDur  time.Duration
+	continueWaited   bool
+	continueTimedOut bool
+
+	// Set if the request asks the server to close its connection, like without keep-alive, so the close is not
+	// counted as the one of the server
+	closesConn bool
+
 	mu sync.Mutex
+
+	// Start times of the trace hooks, kept here since the trace is created on each request
+	start struct {
+		sync.Mutex
+		dns, conn, tls, req, serverProcess time.Time
+
+		// Set once the request with the "Expect: 100-continue" header starts to wait for the interim response
+		continueWait time.Time
+
+		// Set once the request gets a connection, reused is set if it is an idle connection of a previous request
+		gotConn, reused bool
+		gotConnAt       time.Time
+
+		// IP address of the peer of the connection
+		remoteIP string
+
+		// Set once the first request and the last request of the redirect chain get their connections
+		getConn, lastGetConn time.Time
+
+		// TLS handshake of the new connection with the target
+		handshake tlsHandshake
+
+		// Counted connection of the request, the position of the request on it, and set if the connection is a new
+		// one replacing a connection closed by the server
+		counted     *countingConn
+		connRequest int64
+		reconnect   bool
+	}
+}
+
+// tlsHandshake is the kind of the TLS handshake of a new connection and what it negotiated.
+type tlsHandshake struct {
+	done    bool
+	resumed bool
+	version uint16
+	alpn    string
+
+	// Server name and the certificate chain sent by the target, the leaf first
+	serverName string
+	certs      []*x509.Certificate
+}
+
+// tls returns the TLS handshake of the connection of the request, done is false if the request does no TLS handshake
+// with the target, like on a reused connection.
+func (d *duration) tls() tlsHandshake {
+	d.start.Lock()
+	defer d.start.Unlock()
+	return d.start.handshake
+}
+
+// redirectTime returns the time from the first request of the redirect chain until its final request.
+func (d *duration) redirectTime() time.Duration {
+	d.start.Lock()
+	defer d.start.Unlock()
+	return between(d.start.getConn, d.start.lastGetConn)
+}
+
+// conn reports whether the request got a connection, whether the connection is reused and its remote IP address.
+func (d *duration) conn() (got bool, reused bool, ip string) {
+	d.start.Lock()
+	defer d.start.Unlock()
+	return d.start.gotConn, d.start.reused, d.start.remoteIP
+}
+
+// connUse returns the position of the request on its connection, 1 for the first one, and whether the connection
+// replaces one closed by the server. 0 if the connection is not counted.
+func (d *duration) connUse() (request int64, reconnect bool) {
+	d.start.Lock()
+	defer d.start.Unlock()
+	return d.start.connRequest, d.start.reconnect
+}
+
+// closedByServer counts the connection of the request as closed by the server, like after a response with the
+// "Connection: close" header.
+func (d *duration) closedByServer() {
+	d.start.Lock()
+	cc := d.start.counted
+	d.start.Unlock()
+	if cc != nil {
+		cc.closedByServer()
+	}
 }
 
 func (d *duration) setResStartTime(t time.Time) {
@@ -587,7 +1787,7 @@ func (d *duration) getServerProcessDur() time.Duration {
 func (d *duration) setResDur() {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.resDur = time.Since(d.resStart)
+	d.resDur = since(d.resStart)
 }
 
 func (d *duration) getResDur() time.Duration {
@@ -596,9 +1796,60 @@ func (d *duration) getResDur() time.Duration {
 	return d.resDur
 }
 
+// setClientWaitDur sets the client wait of the request sent at the given time, once the request is finished.
+func (d *duration) setClientWaitDur(sentAt time.Time, latency time.Duration) {
+	d.start.Lock()
+	gotConnAt := d.start.gotConnAt
+	d.start.Unlock()
+	if gotConnAt.IsZero() {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if wait := between(sentAt, gotConnAt) - latency - d.dnsDur - d.connDur - d.tlsDur; wait > 0 {
+		d.clientWaitDur = wait
+	}
+}
+
+func (d *duration) getClientWaitDur() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.clientWaitDur
+}
+
+// setFinalResponse sets the server processing of the request whose first response byte is read before its body is
+// sent, once the headers of the final response are read at t.
+func (d *duration) setFinalResponse(t time.Time) {
+	d.start.Lock()
+	serverProcess := d.start.serverProcess
+	d.start.Unlock()
+	if serverProcess.IsZero() {
+		return
+	}
+	d.setServerProcessDur(between(serverProcess, t))
+	d.setResStartTime(t)
+}
+
+func (d *duration) setContinueWaitDur(t time.Duration, timedOut bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.continueWaited {
+		d.continueWaitDur, d.continueTimedOut, d.continueWaited = t, timedOut, true
+	}
+}
+
+// continueWait returns the wait for the interim response, waited is false if the request didn't wait for it.
+func (d *duration) continueWait() (wait time.Duration, waited bool, timedOut bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.continueWaitDur, d.continueWaited, d.continueTimedOut
+}
+
 func (d *duration) totalDuration() time.Duration {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	return d.dnsDur + d.connDur + d.tlsDur + d.reqDur + d.serverProcessDur + d.resDur
+	return d.clientWaitDur + d.dnsDur + d.connDur + d.tlsDur + d.continueWaitDur + d.reqDur + d.serverProcessDur +
+		d.resDur
 }
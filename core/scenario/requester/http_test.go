@@ -21,17 +21,36 @@
 package requester
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/scenario/scripting"
 	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+	"go.ddosify.com/ddosify/core/warm"
 	"golang.org/x/net/http2"
 )
 
@@ -165,6 +184,12 @@ func TestInitClient(t *testing.T) {
 			transport := h.client.Transport.(*http.Transport)
 			tls := transport.TLSClientConfig
 
+			// Sessions of the cache are tested by TestSendTLSResumption
+			if tls.ClientSessionCache == nil {
+				t.Errorf("TLS session cache should be enabled by default")
+			}
+			test.tls.ClientSessionCache = tls.ClientSessionCache
+
 			// TLS Assert (Also check HTTP2 vs HTTP)
 			if !reflect.DeepEqual(test.tls, tls) {
 				t.Errorf("\nTLS Expected %#v, \nFound %#v", test.tls, tls)
@@ -389,7 +414,7 @@ func TestSendOnDebugModePopulatesDebugInfo(t *testing.T) {
 			debug := true
 			var proxy *url.URL
 			_ = h.Init(ctx, test.scenarioStep, proxy, debug)
-			res := h.Send()
+			res := h.Send(&Iteration{})
 
 			if len(res.DebugInfo) == 0 {
 				t.Errorf("debugInfo should have been populated on debug mode")
@@ -415,6 +440,136 @@ func TestSendOnDebugModePopulatesDebugInfo(t *testing.T) {
 	}
 }
 
+func TestPreviewRendersRequestWithoutSending(t *testing.T) {
+	sent := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      server.URL + "/{{_randomInt}}",
+		Payload:  `{"token": "{{token}}"}`,
+		Headers:  map[string]string{"X-Id": "{{_randomUUID}}", "X-Session": "{{session}}"},
+	}
+
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	res := h.Preview()
+
+	if sent {
+		t.Errorf("Preview should not send the request")
+	}
+	if preview, _ := res.DebugInfo["preview"].(bool); !preview {
+		t.Errorf("Preview flag should be set in DebugInfo")
+	}
+	if u := res.DebugInfo["url"].(string); strings.Contains(u, "{{_randomInt}}") {
+		t.Errorf("Dynamic variable in url should be rendered, found: %s", u)
+	}
+	if id := res.DebugInfo["requestHeaders"].(http.Header).Get("X-Id"); id == "" || strings.Contains(id, "{{") {
+		t.Errorf("Dynamic variable in header should be rendered, found: %s", id)
+	}
+	if body := string(res.DebugInfo["requestBody"].([]byte)); body != s.Payload {
+		t.Errorf("RequestBody Expected %s, Found: %s", s.Payload, body)
+	}
+
+	unresolved := res.DebugInfo["unresolvedVariables"].([]string)
+	sort.Strings(unresolved)
+	expected := []string{"{{session}}", "{{token}}"}
+	if !reflect.DeepEqual(unresolved, expected) {
+		t.Errorf("UnresolvedVariables Expected %v, Found: %v", expected, unresolved)
+	}
+}
+
+func TestSendCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("resbody"))
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      server.URL + "/{{_randomInt}}",
+		Payload:  "reqbody",
+		Timeout:  types.DefaultTimeout,
+	}
+
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	h.CaptureFailures()
+
+	if res := h.Send(&Iteration{}); res.DebugInfo != nil {
+		t.Errorf("Successful requests should stay on the lean path, found: %v", res.DebugInfo)
+	}
+
+	res := h.SendCapture(&Iteration{})
+	if u := res.DebugInfo["url"].(string); strings.Contains(u, "{{_randomInt}}") {
+		t.Errorf("Captured url should be rendered, found: %s", u)
+	}
+	if body := string(res.DebugInfo["requestBody"].([]byte)); body != "reqbody" {
+		t.Errorf("RequestBody Expected %s, Found: %s", "reqbody", body)
+	}
+	if body := string(res.DebugInfo["responseBody"].([]byte)); body != "resbody" {
+		t.Errorf("ResponseBody Expected %s, Found: %s", "resbody", body)
+	}
+
+	server.Close()
+	res = h.Send(&Iteration{})
+	if res.Err.Type == "" {
+		t.Fatalf("Request to the closed server should fail")
+	}
+	if body := string(res.DebugInfo["requestBody"].([]byte)); body != "reqbody" {
+		t.Errorf("RequestBody of the failed request Expected %s, Found: %s", "reqbody", body)
+	}
+	if _, ok := res.DebugInfo["responseBody"]; ok {
+		t.Errorf("ResponseBody of the failed request should not be captured on the lean path")
+	}
+}
+
+func TestDynamicVariablesSeed(t *testing.T) {
+	variables := []string{"randomInt", "randomBoolean", "randomUUID", "guid", "randomFullName", "randomCity",
+		"randomIP", "randomIPV6", "randomMACAddress", "randomUserAgent", "randomBitcoin", "randomAlphaNumeric",
+		"randomLoremWord", "randomString", "randomFloat", "randomColor"}
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      "http://test.com",
+		Headers:  map[string]string{},
+	}
+	for _, v := range variables {
+		s.Headers["X-"+v] = "{{_" + v + "}}"
+	}
+
+	render := func(seed int64) http.Header {
+		h := &HttpRequester{}
+		h.SetSeed(seed)
+		if err := h.Init(context.TODO(), s, nil, false); err != nil {
+			t.Fatalf("Init errored: %v", err)
+		}
+		return h.Preview().DebugInfo["requestHeaders"].(http.Header)
+	}
+
+	a, b := render(42), render(42)
+	for _, v := range variables {
+		if a.Get("X-"+v) != b.Get("X-"+v) {
+			t.Errorf("Same seed should render the same %s, Found %s and %s", v, a.Get("X-"+v), b.Get("X-"+v))
+		}
+	}
+	if c := render(43); reflect.DeepEqual(a, c) {
+		t.Errorf("Different seeds should render different variables, Found %v", a)
+	}
+}
+
 func TestDynamicVariableRegex(t *testing.T) {
 	// Sub Tests
 	tests := []struct {
@@ -452,3 +607,2799 @@ func TestDynamicVariableRegex(t *testing.T) {
 		t.Run(test.name, tf)
 	}
 }
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"Seconds", "3", 3 * time.Second, true},
+		{"SecondsWithSpaces", " 10 ", 10 * time.Second, true},
+		{"HttpDate", now.Add(5 * time.Second).Format(http.TimeFormat), 5 * time.Second, true},
+		{"PastHttpDate", now.Add(-5 * time.Second).Format(http.TimeFormat), 0, true},
+		{"Empty", "", 0, false},
+		{"Negative", "-1", 0, false},
+		{"Overflow", "99999999999999999", 0, false},
+		{"Malformed", "soon", 0, false},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			d, ok := parseRetryAfter(test.value, now)
+			if ok != test.ok {
+				t.Errorf("Ok Expected %v, Found %v", test.ok, ok)
+			}
+			if d != test.expected {
+				t.Errorf("Duration Expected %v, Found %v", test.expected, d)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSendRetryAfterClock(t *testing.T) {
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", now.Add(30*time.Second).Format(http.TimeFormat))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom:   map[string]interface{}{"retry-after": types.RetryAfterSleep},
+	}
+	h := &HttpRequester{}
+	h.SetClock(clock.NewFake(now))
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	// HTTP-date is read on the clock of the requester, not on the wall clock
+	res := h.Send(NewIteration(0, 0))
+	if backoff := res.Custom["retryAfter"]; backoff != 30*time.Second {
+		t.Errorf("Expected a backoff of 30s, Found %v", backoff)
+	}
+}
+
+func TestSendCapturesValues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Poll-After", "1500")
+		w.Write([]byte(`{"job": {"id": "j-1"}}`))
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom: map[string]interface{}{
+			"capture": map[string]interface{}{
+				"poll_after": map[string]interface{}{"header": "X-Poll-After"},
+				"job_id":     map[string]interface{}{"json_path": "job.id"},
+				"missing":    map[string]interface{}{"json_path": "job.status"},
+			},
+		},
+	}
+
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	it := &Iteration{}
+	h.Send(it)
+	expected := map[string]string{"poll_after": "1500", "job_id": "j-1"}
+	if !reflect.DeepEqual(it.Captures, expected) {
+		t.Errorf("Captures Expected %v, Found %v", expected, it.Captures)
+	}
+
+	server.Close()
+	it = &Iteration{}
+	if h.Send(it); it.Captures != nil {
+		t.Errorf("Failed requests should not capture, Found %v", it.Captures)
+	}
+}
+
+func TestSendHedge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		slow bool // first request of the server is slower than the hedge delay
+		fail bool // first request of the server is closed without a response
+		won  bool
+	}{
+		{"HedgeWins", true, false, true},
+		{"FastResponse", false, false, false},
+		{"FailedBeforeDelay", false, true, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			requests := 0
+			cancelled := make(chan struct{}, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				first := requests == 1
+				mu.Unlock()
+				if first && test.fail {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+					return
+				}
+				if first && test.slow {
+					select {
+					case <-r.Context().Done():
+						cancelled <- struct{}{}
+					case <-time.After(2 * time.Second):
+					}
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      server.URL,
+				Timeout:  types.DefaultTimeout,
+				Custom:   map[string]interface{}{"hedge": float64(50), "stale-retry": false},
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			res := h.Send(&Iteration{})
+			unhedged, _ := res.Custom["unhedgedTime"].(time.Duration)
+			_, fired := res.Custom["hedged"]
+			_, won := res.Custom["hedgeWon"]
+			if test.fail {
+				if res.Err.Type != types.ErrorConn || fired {
+					t.Errorf("Failure before the delay should not be hedged, Found %v and hedged %v", res.Err, fired)
+				}
+				return
+			}
+			if res.Err.Type != "" || res.StatusCode != http.StatusOK {
+				t.Fatalf("Expected to succeed, Found %d %v", res.StatusCode, res.Err)
+			}
+			if fired != test.won || won != test.won {
+				t.Errorf("Hedged and won Expected %v, Found %v and %v", test.won, fired, won)
+			}
+			if !test.won {
+				if unhedged != res.Duration {
+					t.Errorf("Unhedged duration should be the duration, Found %v and %v", unhedged, res.Duration)
+				}
+				return
+			}
+			// Duration of the winning hedge is from the first request, which is cancelled
+			if res.Duration < 50*time.Millisecond || res.Duration > time.Second || unhedged < 50*time.Millisecond {
+				t.Errorf("Duration Expected between the delay and the slow response, Found %v and unhedged %v",
+					res.Duration, unhedged)
+			}
+			select {
+			case <-cancelled:
+			case <-time.After(time.Second):
+				t.Errorf("Losing request should be cancelled")
+			}
+		})
+	}
+}
+
+func TestSendMaxBodySize(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"` + strings.Repeat("a", 1000) + `"}`))
+	}))
+	defer server.Close()
+
+	captureID := map[string]interface{}{"capture": map[string]interface{}{
+		"id": map[string]interface{}{"json_path": "id"}}}
+	tests := []struct {
+		name        string
+		maxBodySize int64
+		custom      map[string]interface{}
+		capture     bool
+		tooLarge    bool
+		errType     string
+	}{
+		{"Unchecked", 100, nil, false, true, ""},
+		{"CaptureMode", 100, nil, true, true, ""},
+		{"CheckedBody", 100, captureID, false, true, types.ErrorBodyLimit},
+		{"UnderLimit", 2000, captureID, false, false, ""},
+		{"Default", 0, captureID, false, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:          1,
+				Protocol:    types.ProtocolHTTP,
+				Method:      http.MethodGet,
+				URL:         server.URL,
+				Timeout:     types.DefaultTimeout,
+				MaxBodySize: test.maxBodySize,
+				Custom:      test.custom,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			it := &Iteration{}
+			var res *types.ScenarioStepResult
+			if test.capture {
+				res = h.SendCapture(it)
+			} else {
+				res = h.Send(it)
+			}
+			if res.Err.Type != test.errType {
+				t.Errorf("Err Expected %q, Found %v", test.errType, res.Err)
+			}
+			if _, ok := res.Custom["bodyTooLarge"]; ok != test.tooLarge {
+				t.Errorf("Body too large Expected %v, Found %v", test.tooLarge, ok)
+			}
+			if !test.tooLarge {
+				if it.Captures["id"] != strings.Repeat("a", 1000) {
+					t.Errorf("Body under the limit should be captured, Found %v", it.Captures)
+				}
+				return
+			}
+			if read := res.Custom["bodyBytesRead"]; read != test.maxBodySize {
+				t.Errorf("Bytes read Expected %d, Found %v", test.maxBodySize, read)
+			}
+			if test.capture {
+				if body := res.DebugInfo["responseBody"].([]byte); int64(len(body)) != test.maxBodySize {
+					t.Errorf("Kept body Expected %d bytes, Found %d", test.maxBodySize, len(body))
+				}
+			}
+			if test.errType != "" && !strings.Contains(res.Err.Reason, "truncated at max_body_size 100 bytes") {
+				t.Errorf("Reason should refer to the truncation, Found %q", res.Err.Reason)
+			}
+		})
+	}
+}
+
+func TestSendCountsTransferredBytes(t *testing.T) {
+	body := strings.Repeat("a", 1000)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(body))
+	})
+
+	tests := []struct {
+		name     string
+		server   *httptest.Server
+		protocol string
+	}{
+		{"HTTP", httptest.NewServer(handler), types.ProtocolHTTP},
+		{"HTTPS", httptest.NewTLSServer(handler), types.ProtocolHTTPS},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			defer test.server.Close()
+
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: test.protocol,
+				Method:   http.MethodPost,
+				URL:      test.server.URL,
+				Payload:  strings.Repeat("b", 500),
+				Timeout:  types.DefaultTimeout,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			// The second request reuses the connection, its bytes are counted once.
+			for i := 0; i < 2; i++ {
+				res := h.Send(&Iteration{})
+				if res.Err.Type != "" {
+					t.Fatalf("Send errored: %v", res.Err)
+				}
+				sent, _ := res.Custom["bytesSent"].(int64)
+				received, _ := res.Custom["bytesReceived"].(int64)
+				if sent <= 500 || sent > 5000 {
+					t.Errorf("Request %d: bytesSent should include the payload and the headers, Found %d", i, sent)
+				}
+				if received <= 1000 || received > 10000 {
+					t.Errorf("Request %d: bytesReceived should include the body and the headers, Found %d", i, received)
+				}
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSendRotatesHosts(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.Host]++
+		mu.Unlock()
+	})
+	a := httptest.NewServer(handler)
+	defer a.Close()
+	b := httptest.NewServer(handler)
+	defer b.Close()
+	hostA, hostB := a.Listener.Addr().String(), b.Listener.Addr().String()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      "http://placeholder.example.com/users?page=1",
+		Timeout:  types.DefaultTimeout,
+		Custom: map[string]interface{}{
+			"hosts": []interface{}{map[string]interface{}{"host": hostA, "weight": float64(3)}, hostB},
+		},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	expected := []string{hostA, hostA, hostB, hostA}
+	for i, host := range expected {
+		res := h.Send(&Iteration{})
+		if res.Err.Type != "" {
+			t.Fatalf("Request %d errored: %v", i, res.Err)
+		}
+		if res.Custom["host"] != host {
+			t.Errorf("Request %d: Expected host %s, Found %v", i, host, res.Custom["host"])
+		}
+	}
+	if received[hostA] != 3 || received[hostB] != 1 {
+		t.Errorf("Expected 3 requests to %s and 1 to %s, Found %v", hostA, hostB, received)
+	}
+	if h.request.URL.Host != "placeholder.example.com" {
+		t.Errorf("Shared request should not be modified, Found host %s", h.request.URL.Host)
+	}
+}
+
+func TestSendRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved?token=x", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop-back", http.StatusFound)
+	})
+	mux.HandleFunc("/loop-back", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		custom   map[string]interface{}
+		statuses []int
+		final    string
+		loop     bool
+	}{
+		{"Chain", "/start", nil, []int{301, 302, 200}, "/final", false},
+		{"NotRedirected", "/final", nil, nil, "", false},
+		{"Disabled", "/start", map[string]interface{}{"disable-redirect": true}, nil, "", false},
+		{"Loop", "/loop", nil, []int{302, 302, 302, 302, 302, 302, 302, 302, 302, 302}, "/loop-back", true},
+	}
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      server.URL + test.path,
+				Timeout:  types.DefaultTimeout,
+				Custom:   test.custom,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			res := h.Send(&Iteration{})
+			chain, _ := res.Custom["redirects"].([]types.RedirectHop)
+			var statuses []int
+			for _, hop := range chain {
+				statuses = append(statuses, hop.StatusCode)
+			}
+			if !reflect.DeepEqual(statuses, test.statuses) {
+				t.Fatalf("Statuses Expected %v, Found %v", test.statuses, statuses)
+			}
+			if chain == nil {
+				if _, ok := res.Custom["redirectTime"]; ok {
+					t.Errorf("Unexpected redirect time for a request that is not redirected")
+				}
+				return
+			}
+			if chain[0].URL != server.URL+test.path || chain[len(chain)-1].URL != server.URL+test.final {
+				t.Errorf("Chain Expected from %s to %s, Found %v", test.path, test.final, chain)
+			}
+			if _, loop := res.Custom["redirectLoop"]; loop != test.loop {
+				t.Errorf("Loop Expected %v, Found %v", test.loop, loop)
+			}
+			if d := res.Custom["redirectTime"].(time.Duration); d <= 0 || d > res.Duration {
+				t.Errorf("Redirect time should be in the duration %s, Found %s", res.Duration, d)
+			}
+		})
+	}
+}
+
+func TestSendPageLoad(t *testing.T) {
+	cross := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/gif")
+	}))
+	defer cross.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// Broken markup, the tags are not closed and the attributes are not quoted
+		fmt.Fprintf(w, `<html><head><base href="/assets/"><link rel="preload Stylesheet" href="site.css">
+<script src="app.js"></script><body><div><p>unclosed <img src=logo.png><img src="logo.png#top">
+<img src="data:image/png;base64,AA"><img src="%s/pixel.gif"><img src=missing.png><p>`, cross.URL)
+	})
+	mux.HandleFunc("/assets/site.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		fmt.Fprint(w, `@import url("theme.css"); body { background: url('bg.jpg') }`)
+	})
+	mux.HandleFunc("/assets/app.js", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "1" || r.Referer() == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/javascript")
+	})
+	mux.HandleFunc("/assets/missing.png", http.NotFound)
+	for _, path := range []string{"/assets/logo.png", "/assets/theme.css", "/assets/bg.jpg"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) { w.Write(make([]byte, 100)) })
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		pageLoad  interface{}
+		resources []string
+	}{
+		{"SameOrigin", "/", true, []string{"/assets/site.css", "/assets/app.js", "/assets/logo.png",
+			"/assets/missing.png"}},
+		{"CrossOriginDepth", "/", map[string]interface{}{"cross_origin": true, "depth": float64(2),
+			"concurrency": float64(1)}, []string{"/assets/site.css", "/assets/app.js", "/assets/logo.png",
+			cross.URL + "/pixel.gif", "/assets/missing.png", "/assets/theme.css", "/assets/bg.jpg"}},
+		{"MaxResources", "/", map[string]interface{}{"max_resources": float64(2)}, []string{"/assets/site.css",
+			"/assets/app.js"}},
+		{"NotHTML", "/assets/site.css", true, nil},
+	}
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      server.URL + test.path,
+				Timeout:  types.DefaultTimeout,
+				Custom:   map[string]interface{}{"page-load": test.pageLoad},
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			res := h.Send(&Iteration{})
+			if res.Err.Type != "" {
+				t.Fatalf("Page should be loaded, Found %v", res.Err)
+			}
+			if test.resources == nil {
+				if _, ok := res.Custom["pageLoadTime"]; ok {
+					t.Errorf("Response that is not HTML should not be loaded as a page")
+				}
+				return
+			}
+			resources := res.Custom["pageResources"].([]types.PageResource)
+			var urls []string
+			for _, r := range resources {
+				urls = append(urls, strings.TrimPrefix(r.URL, server.URL))
+				if failed := r.URL == server.URL+"/assets/missing.png"; r.Failed != failed {
+					t.Errorf("Resource %s Failed Expected %v, Found %+v", r.URL, failed, r)
+				}
+			}
+			if !reflect.DeepEqual(urls, test.resources) {
+				t.Errorf("Resources Expected %v, Found %v", test.resources, urls)
+			}
+			if resources[0].ContentType != "text/css" || len(resources) > 2 && resources[2].Bytes != 100 {
+				t.Errorf("Unexpected resource stats %+v", resources)
+			}
+			if d := res.Custom["pageLoadTime"].(time.Duration); d < res.Duration {
+				t.Errorf("Load time should include the page request %s, Found %s", res.Duration, d)
+			}
+		})
+	}
+}
+
+func TestSendAdaptiveTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom:   map[string]interface{}{"adaptive-timeout": map[string]interface{}{"max": float64(1000)}},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	// Requests time out at the max until the first update, which is bounded by the max too
+	tests := []struct {
+		timeout  time.Duration
+		expected time.Duration
+		cut      interface{}
+	}{
+		{0, time.Second, nil},
+		{20 * time.Millisecond, 20 * time.Millisecond, timeoutCutAdaptive},
+		{time.Minute, time.Second, nil},
+	}
+	for _, test := range tests {
+		if test.timeout > 0 {
+			h.SetTimeout(test.timeout)
+		}
+		res := h.Send(&Iteration{})
+		if res.Custom["adaptiveTimeout"] != test.expected {
+			t.Errorf("Timeout Expected %s, Found %v", test.expected, res.Custom["adaptiveTimeout"])
+		}
+		if res.Custom["timeoutCut"] != test.cut {
+			t.Errorf("Timeout of %s: Cut Expected %v, Found %v %v", test.expected, test.cut, res.Custom["timeoutCut"],
+				res.Err)
+		}
+		if (test.cut == nil) != (res.Err.Type == "") {
+			t.Errorf("Timeout of %s: Unexpected error %v", test.expected, res.Err)
+		}
+	}
+
+	// Timeout at the max would have timed out with the static max too
+	s.Custom = map[string]interface{}{"adaptive-timeout": map[string]interface{}{"min": float64(10),
+		"max": float64(30)}}
+	h = &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+	if res := h.Send(&Iteration{}); res.Custom["timeoutCut"] != timeoutCutMax ||
+		res.Err.Reason != types.ReasonConnTimeout {
+		t.Errorf("Expected a timeout at the max, Found %v %v", res.Custom["timeoutCut"], res.Err)
+	}
+}
+
+func TestSendTemplatedHost(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.Host]++
+		mu.Unlock()
+	})
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen errored: %v", err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	// Unicode host of the placeholder url is converted too, the Host header should follow the rendered host
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      "http://{{ .region }}.例え.jp/users",
+		Timeout:  types.DefaultTimeout,
+		Custom:   map[string]interface{}{"unix-socket": socket},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	for i, region := range []string{"eu", "us", "eu"} {
+		res := h.Send(&Iteration{Captures: map[string]string{"region": region}})
+		if res.Err.Type != "" {
+			t.Fatalf("Request %d errored: %v", i, res.Err)
+		}
+		if host := region + ".xn--r8jz45g.jp"; res.Custom["host"] != host {
+			t.Errorf("Request %d: Expected host %s, Found %v", i, host, res.Custom["host"])
+		}
+	}
+	expected := map[string]int{"eu.xn--r8jz45g.jp": 2, "us.xn--r8jz45g.jp": 1}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected %v, Found %v", expected, received)
+	}
+}
+
+func TestSendABVariants(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]string)
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[name] = r.URL.RequestURI() + " " + r.Header.Get("X-Impl") + " " + r.Header.Get("X-Static")
+			mu.Unlock()
+		}
+	}
+	a := httptest.NewServer(handler("a"))
+	defer a.Close()
+	b := httptest.NewServer(handler("b"))
+	defer b.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      a.URL + "/users?page=1",
+		Timeout:  types.DefaultTimeout,
+		Headers:  map[string]string{"X-Impl": "old", "X-Static": "static"},
+		Custom: map[string]interface{}{
+			"ab-variants": map[string]interface{}{
+				"a": map[string]interface{}{},
+				"b": map[string]interface{}{"base_url": b.URL, "headers": map[string]interface{}{"X-Impl": "new"}},
+			},
+		},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	for _, variant := range []string{types.VariantB, types.VariantA} {
+		if res := h.Send(&Iteration{Variant: variant}); res.Err.Type != "" {
+			t.Fatalf("Variant %s errored: %v", variant, res.Err)
+		}
+	}
+	expected := map[string]string{"a": "/users?page=1 old static", "b": "/users?page=1 new static"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected %v, Found %v", expected, received)
+	}
+	if h.request.URL.Host != a.Listener.Addr().String() || h.request.Header.Get("X-Impl") != "old" {
+		t.Errorf("Shared request should not be modified, Found %s %v", h.request.URL.Host, h.request.Header)
+	}
+}
+
+func TestSendConditionalRequests(t *testing.T) {
+	const lastModified = "Wed, 21 Oct 2015 07:28:00 GMT"
+	var mu sync.Mutex
+	var conditions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conditions = append(conditions, r.Header.Get("If-None-Match")+" "+r.Header.Get("If-Modified-Since"))
+		mu.Unlock()
+		etag := `"` + r.URL.Path + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:                  1,
+		Protocol:            types.ProtocolHTTP,
+		Method:              http.MethodGet,
+		URL:                 server.URL + "/items/{{_vuId}}",
+		Timeout:             types.DefaultTimeout,
+		ConditionalRequests: true,
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	v, other := NewValidators(), NewValidators()
+	tests := []struct {
+		it          *Iteration
+		conditional bool
+		status      int
+		condition   string
+	}{
+		{&Iteration{VUID: 0, Validators: v}, false, http.StatusOK, " "},
+		{&Iteration{VUID: 0, Validators: v}, true, http.StatusNotModified, `"/items/0" ` + lastModified},
+		// Validators are kept by the url after the templating
+		{&Iteration{VUID: 1, Validators: v}, false, http.StatusOK, " "},
+		// and by the virtual user
+		{&Iteration{VUID: 0, Validators: other}, false, http.StatusOK, " "},
+		{&Iteration{VUID: 0, Validators: v}, true, http.StatusNotModified, `"/items/0" ` + lastModified},
+	}
+	for i, test := range tests {
+		res := h.Send(test.it)
+		if res.Err.Type != "" {
+			t.Fatalf("Request %d errored: %v", i, res.Err)
+		}
+		if res.Custom["conditional"] != test.conditional || res.StatusCode != test.status {
+			t.Errorf("Request %d Expected conditional %v with %d, Found %v with %d", i, test.conditional,
+				test.status, res.Custom["conditional"], res.StatusCode)
+		}
+		if conditions[i] != test.condition {
+			t.Errorf("Request %d Expected the conditional headers %q, Found %q", i, test.condition, conditions[i])
+		}
+	}
+	if len(h.request.Header) != 0 {
+		t.Errorf("Shared request should not be modified, Found %v", h.request.Header)
+	}
+}
+
+func TestSendDuplicateRate(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	seen := make(map[string]bool)
+	fake := clock.NewFake(time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		key := r.Header.Get("Idempotency-Key") + " " + string(body)
+		mu.Lock()
+		received = append(received, key)
+		replayed := seen[key]
+		seen[key] = true
+		mu.Unlock()
+		// Replayed response has the body of the original with another status, the one of the 3rd request differs
+		if replayed {
+			fake.Advance(40 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+		if replayed && len(received) > 3 {
+			w.Write([]byte("duplicate"))
+			return
+		}
+		w.Write([]byte("created"))
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:            1,
+		Protocol:      types.ProtocolHTTP,
+		Method:        http.MethodPost,
+		URL:           server.URL,
+		Payload:       `{"id": "{{_randomUUID}}"}`,
+		Headers:       map[string]string{"Idempotency-Key": "{{_randomUUID}}"},
+		Timeout:       types.DefaultTimeout,
+		DuplicateRate: 0.5,
+	}
+	h := &HttpRequester{}
+	h.SetClock(fake)
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	// Half of the requests are sent twice, the first one is always duplicated
+	tests := []struct {
+		duplicated bool
+		bodyMatch  bool
+	}{
+		{true, true},
+		{false, false},
+		{true, false},
+		{false, false},
+	}
+	for i, test := range tests {
+		res := h.Send(NewIteration(uint64(i), 0))
+		if res.Err.Type != "" || res.StatusCode != http.StatusCreated {
+			t.Fatalf("Request %d Expected 201, Found %d %v", i, res.StatusCode, res.Err)
+		}
+		dup, ok := res.Custom["duplicate"].(types.DuplicateResult)
+		if ok != test.duplicated {
+			t.Fatalf("Request %d Expected duplicated %v, Found %v", i, test.duplicated, res.Custom["duplicate"])
+		}
+		if !ok {
+			continue
+		}
+		if dup.Err.Type != "" || dup.StatusCode != http.StatusOK || dup.StatusMatch || dup.BodyMatch != test.bodyMatch {
+			t.Errorf("Request %d Expected a 200 duplicate with body match %v, Found %+v", i, test.bodyMatch, dup)
+		}
+		// Duplicate is timed on the clock of the requester
+		if dup.Duration != 40*time.Millisecond {
+			t.Errorf("Request %d Expected the duplicate to take 40ms, Found %v", i, dup.Duration)
+		}
+	}
+
+	// Duplicates resend the rendered key and body of their originals
+	if len(received) != 6 || received[0] != received[1] || received[3] != received[4] || received[0] == received[3] {
+		t.Errorf("Expected the duplicates after their originals, Found %v", received)
+	}
+}
+
+func TestValidatorsKeep(t *testing.T) {
+	response := func(status int, etag, lastModified string) *http.Response {
+		res := &http.Response{StatusCode: status, Header: make(http.Header)}
+		if etag != "" {
+			res.Header.Set("ETag", etag)
+		}
+		if lastModified != "" {
+			res.Header.Set("Last-Modified", lastModified)
+		}
+		return res
+	}
+
+	tests := []struct {
+		name      string
+		responses []*http.Response
+		expected  validator
+		kept      bool
+	}{
+		{"Full", []*http.Response{response(200, `"1"`, "monday")}, validator{`"1"`, "monday"}, true},
+		{"Replaced", []*http.Response{response(200, `"1"`, "monday"), response(200, `"2"`, "")},
+			validator{`"2"`, ""}, true},
+		{"NotModifiedUpdates", []*http.Response{response(200, `"1"`, "monday"), response(304, `"2"`, "")},
+			validator{`"2"`, "monday"}, true},
+		{"Removed", []*http.Response{response(200, `"1"`, "monday"), response(200, "", "")}, validator{}, false},
+		{"ErrorKeeps", []*http.Response{response(200, `"1"`, "monday"), response(500, `"2"`, "tuesday")},
+			validator{`"1"`, "monday"}, true},
+		{"NoValidator", []*http.Response{response(404, `"1"`, "")}, validator{}, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			v := NewValidators()
+			for _, res := range test.responses {
+				v.keep("http://test.com", res)
+			}
+			val, ok := v.byURL["http://test.com"]
+			if ok != test.kept || val != test.expected {
+				t.Errorf("Expected %v %+v, Found %v %+v", test.kept, test.expected, ok, val)
+			}
+		})
+	}
+}
+
+func TestSendTruncatedResponse(t *testing.T) {
+	// Server advertises a 1000 bytes body and closes the connection after 100 bytes.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack errored: %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 1000\r\n\r\n")
+		buf.WriteString(`{"items": [` + strings.Repeat("1,", 44) + "1")
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	defer h.Done()
+
+	for _, res := range []*types.ScenarioStepResult{h.Send(&Iteration{}), h.SendCapture(&Iteration{})} {
+		if res.Err.Type != types.ErrorTruncated || res.Err.Reason != types.ReasonTruncated {
+			t.Errorf("Expected the truncated response error, Found %v", res.Err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Status code of the truncated response should be kept, Found %d", res.StatusCode)
+		}
+		if n := res.Custom["truncatedBytes"]; n != int64(100) {
+			t.Errorf("Expected 100 bytes before the cut, Found %v", n)
+		}
+		if _, ok := res.Custom["serverProcessDuration"]; !ok {
+			t.Errorf("Durations of the truncated response should be kept")
+		}
+	}
+
+	res := h.SendCapture(&Iteration{})
+	if body, _ := res.DebugInfo["responseBody"].([]byte); len(body) != 100 {
+		t.Errorf("Captured response should have the partial body, Found %d bytes", len(body))
+	}
+}
+
+func TestSendChurnRetry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		method  string
+		custom  map[string]interface{}
+		retried bool
+	}{
+		{"Idempotent", http.MethodPut, nil, true},
+		{"NotIdempotent", http.MethodPost, nil, false},
+		{"OptIn", http.MethodPost, map[string]interface{}{"stale-retry": true}, true},
+		{"Disabled", http.MethodDelete, map[string]interface{}{"stale-retry": false}, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Second request of a connection is read and the connection is closed without a response, like a
+			// connection closed by the server while it is idle
+			var mu sync.Mutex
+			requests := make(map[string]int)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				mu.Lock()
+				requests[r.RemoteAddr]++
+				n := requests[r.RemoteAddr]
+				mu.Unlock()
+				if n == 2 {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+				}
+			}))
+			defer server.Close()
+
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   test.method,
+				URL:      server.URL,
+				Payload:  "body",
+				Timeout:  types.DefaultTimeout,
+				Custom:   test.custom,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			if res := h.Send(&Iteration{}); res.Err.Type != "" {
+				t.Fatalf("First request errored: %v", res.Err)
+			}
+			res := h.Send(&Iteration{})
+			if test.retried {
+				if res.Err.Type != "" || res.StatusCode != http.StatusOK {
+					t.Errorf("Retry Expected to succeed, Found %d %v", res.StatusCode, res.Err)
+				}
+				if d, ok := res.Custom["churnRetryTime"].(time.Duration); !ok || d <= 0 {
+					t.Errorf("Time of the stale attempt should be reported, Found %v", res.Custom["churnRetryTime"])
+				}
+				if reused := res.Custom["connReused"]; reused != false {
+					t.Errorf("Retry should be sent on a fresh connection, Found reused %v", reused)
+				}
+				return
+			}
+			if res.Err.Type != types.ErrorConn || res.Err.Reason != types.ReasonStaleConn {
+				t.Errorf("Expected the stale connection error, Found %v", res.Err)
+			}
+			if _, ok := res.Custom["churnRetried"]; ok {
+				t.Errorf("Request should not be retried")
+			}
+		})
+	}
+}
+
+func TestFetchBodyErrType(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected types.RequestError
+	}{
+		{"UnexpectedEOF", io.ErrUnexpectedEOF, types.RequestError{Type: types.ErrorTruncated, Reason: types.ReasonTruncated}},
+		{"Canceled", context.Canceled, types.RequestError{Type: types.ErrorIntented, Reason: types.ReasonCtxCanceled}},
+		{"Timeout", context.DeadlineExceeded, types.RequestError{Type: types.ErrorConn, Reason: types.ReasonReadTimeout}},
+	}
+	for _, test := range tests {
+		if e := fetchBodyErrType(test.err); e != test.expected {
+			t.Errorf("%s: Expected %v, Found %v", test.name, test.expected, e)
+		}
+	}
+}
+
+func TestPrepareReqDynamicURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"Host", "http://{{_randomDomainWord}}.test.com/users?id=1"},
+		{"Path", "http://test.com/users/{{_randomInt}}?id=1"},
+		{"Query", "http://test.com/users?id={{_randomInt}}&name={{_randomFirstName}}"},
+		{"PathAndQuery", "http://test.com/users/{{_randomInt}}?id={{_randomInt}}"},
+		{"QueryWithFragment", "http://test.com/users?id={{_randomInt}}#top"},
+		{"QueryWithoutValue", "http://test.com/users?{{_randomWord}}"},
+		{"QueryWithControlCharacter", "http://test.com/users?lines={{_randomLoremLines}}"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      test.url,
+				Headers:  map[string]string{"Host": "custom.com"},
+			}
+			h := &HttpRequester{}
+			h.SetSeed(42)
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+
+			// Reference is parsed from the whole rendered URL with the same random values.
+			vi := &scripting.VariableInjector{}
+			vi.Init(util.NewRand(util.SubSeed(42, "variables")))
+			for i := 0; i < 3; i++ {
+				rendered, _ := vi.Inject(test.url)
+				expected, expectedErr := url.Parse(rendered)
+
+				req, err := h.prepareReq(h.ctx, nil)
+				if (err != nil) != (expectedErr != nil) {
+					t.Fatalf("Expected error %v, Found %v", expectedErr, err)
+				}
+				if err != nil {
+					continue
+				}
+				if !reflect.DeepEqual(req.URL, expected) {
+					t.Errorf("Expected %#v, Found %#v", expected, req.URL)
+				}
+				if req.Host != "custom.com" {
+					t.Errorf("Host Expected custom.com, Found %s", req.Host)
+				}
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestPrepareReqDoesNotModifySharedRequest(t *testing.T) {
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      "http://test.com",
+		Payload:  "static",
+		Headers:  map[string]string{"X-Static": "static", "X-{{_randomWord}}": "{{_randomUUID}}"},
+		Auth:     types.Auth{Username: "{{_randomUserName}}", Password: "pass"},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	shared := h.request.Header.Clone()
+
+	for i := 0; i < 2; i++ {
+		req, err := h.prepareReq(h.ctx, nil)
+		if err != nil {
+			t.Fatalf("prepareReq errored: %v", err)
+		}
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != "static" || req.ContentLength != int64(len("static")) {
+			t.Errorf("Body Expected static, Found %s (%d)", body, req.ContentLength)
+		}
+		if b, _ := req.GetBody(); b == nil {
+			t.Errorf("GetBody should return the body")
+		} else if body, _ := io.ReadAll(b); string(body) != "static" {
+			t.Errorf("GetBody Expected static, Found %s", body)
+		}
+		if _, ok := req.Header["X-{{_randomword}}"]; ok || len(req.Header) != 3 {
+			t.Errorf("Dynamic header should be rendered, Found %v", req.Header)
+		}
+		if _, _, ok := req.BasicAuth(); !ok {
+			t.Errorf("Basic auth should be set, Found %v", req.Header)
+		}
+	}
+	if !reflect.DeepEqual(h.request.Header, shared) {
+		t.Errorf("Shared header Expected %v, Found %v", shared, h.request.Header)
+	}
+}
+
+func TestSendIterationVariables(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = []string{r.URL.RawQuery, r.Header.Get("X-Request-Id"), string(body)}
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       2,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      server.URL + "/?it={{_iterationId}}",
+		Payload:  `{"vu": {{_vuId}}, "step": {{_stepId}}}`,
+		Headers:  map[string]string{"X-Request-Id": "{{_iterationId}}-{{_vuId}}-{{_stepId}}"},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	it := NewIteration(41, 0)
+	it.VUID = 3
+	if res := h.Send(it); res.Err.Type != "" {
+		t.Fatalf("Send errored: %v", res.Err)
+	}
+	expected := []string{"it=41", "41-3-2", `{"vu": 3, "step": 2}`}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected %v, Found %v", expected, received)
+	}
+
+	// Preview has no iteration, its IDs are zero
+	res := h.Preview()
+	if id := res.DebugInfo["requestHeaders"].(http.Header).Get("X-Request-Id"); id != "0-0-2" {
+		t.Errorf("Preview Expected 0-0-2, Found %s", id)
+	}
+	if unresolved, _ := res.DebugInfo["unresolvedVariables"].([]string); len(unresolved) != 0 {
+		t.Errorf("Iteration variables should be resolved in preview, Found %v", unresolved)
+	}
+}
+
+func TestInitInvalidDynamicVariable(t *testing.T) {
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      "http://test.com/{{_invalidVariable}}",
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err == nil {
+		t.Errorf("Invalid dynamic variable should be errored")
+	}
+}
+
+var benchmarkSteps = []struct {
+	name string
+	step types.ScenarioStep
+}{
+	{"Static", types.ScenarioStep{URL: "http://test.com/api/users?page=1",
+		Headers: map[string]string{"Accept": "application/json", "X-Token": "static"}, Payload: `{"name": "test"}`}},
+	{"DynamicPath", types.ScenarioStep{URL: "http://test.com/api/users/{{_randomInt}}?page=1",
+		Headers: map[string]string{"Accept": "application/json", "X-Token": "static"}, Payload: `{"name": "test"}`}},
+	{"DynamicQuery", types.ScenarioStep{URL: "http://test.com/api/users?id={{_randomInt}}&name={{_randomFirstName}}",
+		Headers: map[string]string{"Accept": "application/json", "X-Token": "static"}, Payload: `{"name": "test"}`}},
+	{"DynamicHeader", types.ScenarioStep{URL: "http://test.com/api/users?page=1",
+		Headers: map[string]string{"Accept": "application/json", "X-Token": "{{_randomUUID}}"}, Payload: `{"name": "test"}`}},
+	{"DynamicBody", types.ScenarioStep{URL: "http://test.com/api/users?page=1",
+		Headers: map[string]string{"Accept": "application/json", "X-Token": "static"},
+		Payload: `{"name": "{{_randomFullName}}", "age": {{_randomInt}}}`}},
+}
+
+// BenchmarkPrepareReq measures the request building of the hot path, run with -benchmem for the allocations.
+func BenchmarkPrepareReq(b *testing.B) {
+	for _, bs := range benchmarkSteps {
+		s := bs.step
+		s.ID = 1
+		s.Protocol = types.ProtocolHTTP
+		s.Method = http.MethodPost
+		s.Timeout = types.DefaultTimeout
+		b.Run(bs.name, func(b *testing.B) {
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				b.Fatalf("Init errored: %v", err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := h.prepareReq(h.ctx, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkTransport responds without the network, so BenchmarkSend measures the requester, not the test server.
+type benchmarkTransport struct{}
+
+func (benchmarkTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	io.Copy(io.Discard, r.Body)
+	r.Body.Close()
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: r}, nil
+}
+
+// BenchmarkSend measures the hot path of the requests from the rendering to the result.
+func BenchmarkSend(b *testing.B) {
+	for _, bs := range benchmarkSteps {
+		s := bs.step
+		s.ID = 1
+		s.Protocol = types.ProtocolHTTP
+		s.Method = http.MethodPost
+		s.Timeout = types.DefaultTimeout
+		b.Run(bs.name, func(b *testing.B) {
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				b.Fatalf("Init errored: %v", err)
+			}
+			h.client.Transport = benchmarkTransport{}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if res := h.Send(&Iteration{}); res.Err.Type != "" {
+					b.Fatal(res.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestSendFailureSample(t *testing.T) {
+	// Debug endpoint echoing the request headers, the body is not a valid xml
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"headers": {"Authorization": "` + r.Header.Get("Authorization") + `"}, "detail": "` +
+			strings.Repeat("x", 2*failureSnippetLimit) + `"}`))
+	}))
+	defer server.Close()
+
+	s := newSOAPStep(server.URL+"/orders?id=1", map[string]interface{}{
+		"xpath-assertions": []interface{}{"//Status = 'CREATED'"},
+	})
+	s.Headers["Authorization"] = "Bearer s3cr3t"
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	res := h.Send(&Iteration{})
+	if res.Err.Type != types.ErrorAssertion {
+		t.Fatalf("Expected assertion error, Found %#v", res.Err)
+	}
+	if u := res.Custom["failedURL"]; u != server.URL+"/orders?id=1" {
+		t.Errorf("Failed url Expected %s, Found %v", server.URL+"/orders?id=1", u)
+	}
+	snippet, _ := res.Custom["responseSnippet"].(string)
+	expected := `{"headers": {"Authorization": "[REDACTED]"}, "detail": "xxx`
+	if !strings.HasPrefix(snippet, expected) || len(snippet) != failureSnippetLimit {
+		t.Errorf("Snippet Expected %q prefix with %d bytes, Found %q", expected, failureSnippetLimit, snippet)
+	}
+}
+
+func TestSendFailedDurations(t *testing.T) {
+	// Port of a closed listener refuses the connections
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	h := &HttpRequester{}
+	s := types.ScenarioStep{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: "http://" + addr,
+		Timeout: types.DefaultTimeout}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	res := h.Send(&Iteration{})
+	if res.Err.Type != types.ErrorConn {
+		t.Fatalf("Expected connection error, Found %#v", res.Err)
+	}
+	// Response of the failed request is never read, its unset start time is not the wall clock time since the year 1
+	if d := res.Custom["resDuration"]; d != time.Duration(0) {
+		t.Errorf("Response read of the failed request Expected 0, Found %v", d)
+	}
+	if res.Duration < 0 || res.Duration > 2*time.Duration(s.Timeout)*time.Second {
+		t.Errorf("Duration of the failed request Expected within the timeout, Found %s", res.Duration)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		start, end time.Time
+		expected   time.Duration
+	}{
+		{"Monotonic", now, now.Add(time.Second), time.Second},
+		{"NotStarted", time.Time{}, now, 0},
+		{"NotEnded", now, time.Time{}, 0},
+		// Wall clock readings, like a time after a step back of the clock
+		{"Backwards", now.Round(0), now.Round(0).Add(-time.Second), 0},
+	}
+	for _, test := range tests {
+		if d := between(test.start, test.end); d != test.expected {
+			t.Errorf("%s: Expected %s, Found %s", test.name, test.expected, d)
+		}
+	}
+}
+
+func TestFailureSnippet(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		authorization string
+		expected      string
+	}{
+		{"Plain", "internal error", "", "internal error"},
+		{"RequestValue", "token Basic dXNlcjpwYXNz is invalid", "Basic dXNlcjpwYXNz", "token [REDACTED] is invalid"},
+		{"HeaderEcho", "GET / HTTP/1.1\r\nauthorization: Bearer abc\r\nHost: a", "", "GET / HTTP/1.1\r\nauthorization: [REDACTED]\r\nHost: a"},
+		{"JSONEcho", `{"Proxy-Authorization":"Basic abc","a":1}`, "", `{"Proxy-Authorization":"[REDACTED]","a":1}`},
+		{"Cut", strings.Repeat("ü", failureSnippetLimit), "", strings.Repeat("ü", failureSnippetLimit/2)},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			if s := failureSnippet([]byte(test.body), test.authorization); s != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, s)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSendReportDimensions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend-Pod", "pod-b")
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom: map[string]interface{}{
+			"report-dimensions": []interface{}{"x-backend-pod", "X-Region"},
+		},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	expected := map[string]string{"X-Backend-Pod": "pod-b", "X-Region": types.MissingDimensionValue}
+	if d := h.Send(&Iteration{}).Custom["dimensions"]; !reflect.DeepEqual(d, expected) {
+		t.Errorf("Expected %v, Found %v", expected, d)
+	}
+
+	// Failed requests without a response are grouped under the missing value
+	server.Close()
+	expected = map[string]string{"X-Backend-Pod": types.MissingDimensionValue, "X-Region": types.MissingDimensionValue}
+	if d := h.Send(&Iteration{}).Custom["dimensions"]; !reflect.DeepEqual(d, expected) {
+		t.Errorf("Expected %v, Found %v", expected, d)
+	}
+}
+
+func TestSendSuccessStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		successStatus string
+		code          int
+		expected      string
+	}{
+		{"Default", "", http.StatusInternalServerError, ""},
+		{"InRange", "200-299,404", http.StatusNoContent, ""},
+		{"Code", "200-299,404", http.StatusNotFound, ""},
+		{"NotInRange", "201", http.StatusOK, types.ErrorStatus},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:            1,
+				Protocol:      types.ProtocolHTTP,
+				Method:        http.MethodGet,
+				URL:           fmt.Sprintf("%s?code=%d", server.URL, test.code),
+				Timeout:       types.DefaultTimeout,
+				SuccessStatus: test.successStatus,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+
+			res := h.Send(&Iteration{})
+			if res.Err.Type != test.expected || res.StatusCode != test.code {
+				t.Errorf("Expected %q with %d, Found %#v with %d", test.expected, test.code, res.Err, res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestSendUnixSocket(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + r.URL.Path))
+	})
+
+	tests := []struct {
+		name     string
+		protocol string
+		scheme   string
+		start    func(s *httptest.Server)
+	}{
+		{"HTTP", types.ProtocolHTTP, "http", (*httptest.Server).Start},
+		{"HTTPS", types.ProtocolHTTPS, "https", (*httptest.Server).StartTLS},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			socket := filepath.Join(t.TempDir(), "app.sock")
+			l, err := net.Listen("unix", socket)
+			if err != nil {
+				t.Fatalf("Listen errored: %v", err)
+			}
+			server := httptest.NewUnstartedServer(handler)
+			server.Listener = l
+			test.start(server)
+			defer server.Close()
+
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: test.protocol,
+				Method:   http.MethodGet,
+				URL:      test.scheme + "://app.local/api/v1/users",
+				Timeout:  types.DefaultTimeout,
+				Custom: map[string]interface{}{
+					"unix-socket":         socket,
+					"prewarm-connections": float64(2),
+				},
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			if _, established, err := h.Prewarm(); err != nil || established != 2 {
+				t.Fatalf("Prewarm should establish 2 connections over the socket, Found %d, %v", established, err)
+			}
+
+			res := h.SendCapture(&Iteration{})
+			if res.Err.Type != "" {
+				t.Fatalf("Send errored: %v", res.Err)
+			}
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("Expected status %d, Found %d", http.StatusOK, res.StatusCode)
+			}
+			if body := string(res.DebugInfo["responseBody"].([]byte)); body != "app.local/api/v1/users" {
+				t.Errorf("Host and path of the URL should be sent over the socket, Found %s", body)
+			}
+			if d := res.Custom["dnsDuration"]; d != time.Duration(0) {
+				t.Errorf("DNS duration should be 0, Found %v", d)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSendDecompressesResponses(t *testing.T) {
+	body := `{"job": {"id": "j-1"}, "padding": "` + strings.Repeat("a", 2000) + `"}`
+	encode := func(encoding string) []byte {
+		var b bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&b)
+		case "br":
+			w = brotli.NewWriter(&b)
+		case "zstd":
+			w, _ = zstd.NewWriter(&b)
+		}
+		w.Write([]byte(body))
+		w.Close()
+		return b.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		encoded  []byte
+		disabled bool
+		errType  string
+	}{
+		{"Gzip", "gzip", encode("gzip"), false, ""},
+		{"Brotli", "br", encode("br"), false, ""},
+		{"Zstd", "zstd", encode("zstd"), false, ""},
+		{"Disabled", "br", encode("br"), true, ""},
+		{"Invalid", "br", []byte("not a brotli stream"), false, types.ErrorDecompress},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", test.encoding)
+				w.Write(test.encoded)
+			}))
+			defer server.Close()
+
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      server.URL,
+				Headers:  map[string]string{"Accept-Encoding": "gzip, br, zstd"},
+				Timeout:  types.DefaultTimeout,
+				Custom: map[string]interface{}{
+					"capture": map[string]interface{}{
+						"job_id": map[string]interface{}{"json_path": "job.id"},
+					},
+					"disable-decompression": test.disabled,
+				},
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			it := &Iteration{}
+			res := h.Send(it)
+			if res.Err.Type != test.errType {
+				t.Fatalf("Error Expected %q, Found %v", test.errType, res.Err)
+			}
+			if test.errType != "" {
+				expected := "invalid br response body"
+				if res.Err.Reason != expected {
+					t.Errorf("Reason Expected %s, Found %s", expected, res.Err.Reason)
+				}
+				return
+			}
+
+			if test.disabled {
+				if _, ok := res.Custom["decompressedBytes"]; ok {
+					t.Errorf("Body should not be decompressed")
+				}
+				if it.Captures != nil {
+					t.Errorf("Captures should not match the compressed body, Found %v", it.Captures)
+				}
+				return
+			}
+
+			expected := map[string]string{"job_id": "j-1"}
+			if !reflect.DeepEqual(it.Captures, expected) {
+				t.Errorf("Captures Expected %v, Found %v", expected, it.Captures)
+			}
+			if n := res.Custom["compressedBytes"]; n != int64(len(test.encoded)) {
+				t.Errorf("Compressed bytes Expected %d, Found %v", len(test.encoded), n)
+			}
+			if n := res.Custom["decompressedBytes"]; n != int64(len(body)) {
+				t.Errorf("Decompressed bytes Expected %d, Found %v", len(body), n)
+			}
+			if received, _ := res.Custom["bytesReceived"].(int64); received >= int64(len(body)) {
+				t.Errorf("Received bytes should be the bytes on the wire, Found %d", received)
+			}
+			if _, ok := res.Custom["decompressDuration"].(time.Duration); !ok {
+				t.Errorf("Decompression duration should be reported")
+			}
+
+			// The pooled decoder is reused by the next request
+			it = &Iteration{}
+			if h.Send(it); !reflect.DeepEqual(it.Captures, expected) {
+				t.Errorf("Captures of the next request Expected %v, Found %v", expected, it.Captures)
+			}
+		})
+	}
+}
+
+func TestSendComposedBody(t *testing.T) {
+	order := `{"id": 7, "status": "PENDING"}`
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(order))
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+	}))
+	defer server.Close()
+
+	capture := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom: map[string]interface{}{
+			"capture": map[string]interface{}{
+				"order": map[string]interface{}{"from": "body", "max_size": float64(len(order))},
+			},
+		},
+	}
+	compose := types.ScenarioStep{
+		ID:       2,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      server.URL,
+		Payload:  `{{ json_set .order "status" "CONFIRMED" }}`,
+		Timeout:  types.DefaultTimeout,
+	}
+
+	first, second := &HttpRequester{}, &HttpRequester{}
+	if err := first.Init(context.TODO(), capture, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	if err := second.Init(context.TODO(), compose, nil, true); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	it := &Iteration{}
+	first.Send(it)
+	if it.Captures["order"] != order {
+		t.Fatalf("Whole body should be captured, Found %v", it.Captures)
+	}
+
+	// Debug mode shows the composed body
+	res := second.Send(it)
+	expected := `{"id":7,"status":"CONFIRMED"}`
+	if received != expected {
+		t.Errorf("Composed body Expected %s, Found %s", expected, received)
+	}
+	if body := string(res.DebugInfo["requestBody"].([]byte)); body != expected {
+		t.Errorf("Debug request body Expected %s, Found %s", expected, body)
+	}
+
+	if res = second.Send(&Iteration{}); res.Err.Type != types.ErrorUnkown {
+		t.Errorf("Missing captured value should fail the request, Found %v", res.Err)
+	}
+	if body := string(second.Preview().DebugInfo["requestBody"].([]byte)); body != compose.Payload {
+		t.Errorf("Preview should show the template of the composed body, Found %s", body)
+	}
+
+	// Larger bodies are rejected
+	order = `{"id": 7, "status": "PENDING", "note": "too long"}`
+	it = &Iteration{}
+	res = first.Send(it)
+	if res.Err.Type != types.ErrorAssertion || it.Captures != nil {
+		t.Errorf("Body over the max_size should fail the capture, Found %v %v", res.Err, it.Captures)
+	}
+}
+
+func TestSendRawPayload(t *testing.T) {
+	t.Parallel()
+
+	var received []byte
+	var contentLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		contentLength = r.ContentLength
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"Binary", "\x0a\x03foo\x00\xff"},
+		{"Template", `{"id": "{{_randomInt}}", "order": {{ json_set .order "status" "CONFIRMED" }}}`},
+		{"FileInjection", `{{_file "payload.txt"}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:         1,
+				Protocol:   types.ProtocolHTTP,
+				Method:     http.MethodPost,
+				URL:        server.URL,
+				Payload:    test.payload,
+				RawPayload: true,
+				Timeout:    types.DefaultTimeout,
+			}
+
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, true); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+
+			res := h.Send(&Iteration{})
+			if res.Err.Type != "" {
+				t.Fatalf("Request errored: %v", res.Err)
+			}
+			if string(received) != test.payload {
+				t.Errorf("Body Expected %q, Found %q", test.payload, received)
+			}
+			if contentLength != int64(len(test.payload)) {
+				t.Errorf("Content-Length Expected %d, Found %d", len(test.payload), contentLength)
+			}
+			if body := string(res.DebugInfo["requestBody"].([]byte)); body != test.payload {
+				t.Errorf("Debug request body Expected %q, Found %q", test.payload, body)
+			}
+
+			if unresolved := h.Preview().DebugInfo["unresolvedVariables"].([]string); len(unresolved) != 0 {
+				t.Errorf("Verbatim body should have no unresolved variables, Found %v", unresolved)
+			}
+		})
+	}
+}
+
+func TestSendRepeatedHeaders(t *testing.T) {
+	t.Parallel()
+
+	// Header lines are read from the connection, the http server canonicalizes the keys
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var req []string
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if line = strings.TrimRight(line, "\r\n"); err != nil || line == "" {
+				break
+			}
+			req = append(req, line)
+		}
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+		lines <- req
+	}()
+
+	s := types.ScenarioStep{
+		ID:              1,
+		Protocol:        types.ProtocolHTTP,
+		Method:          http.MethodGet,
+		URL:             "http://" + l.Addr().String(),
+		Headers:         map[string]string{"Forwarded": "for=proxy", "x-API-key": "abc", "x-trace": "1"},
+		RepeatedHeaders: map[string][]string{"Forwarded": {"for=a", "for={{_randomInt}}"}, "x-id": {"1", "2"}},
+		RawHeaders:      []string{"X-Api-Key", "X-Id"},
+		Timeout:         types.DefaultTimeout,
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, true); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	res := h.Send(&Iteration{})
+	if res.Err.Type != "" {
+		t.Fatalf("Request errored: %v", res.Err)
+	}
+	var forwarded, others []string
+	for _, line := range <-lines {
+		if strings.HasPrefix(line, "Forwarded: ") {
+			forwarded = append(forwarded, strings.TrimPrefix(line, "Forwarded: "))
+		} else if strings.HasPrefix(strings.ToLower(line), "x-") {
+			others = append(others, line)
+		}
+	}
+	if len(forwarded) != 3 || forwarded[0] != "for=proxy" || forwarded[1] != "for=a" ||
+		!regexp.MustCompile(`^for=\d+$`).MatchString(forwarded[2]) {
+		t.Errorf("Forwarded lines Expected the value of the headers and the repeated values, Found %v", forwarded)
+	}
+	sort.Strings(others)
+	expected := []string{"X-Trace: 1", "x-API-key: abc", "x-id: 1", "x-id: 2"}
+	if !reflect.DeepEqual(others, expected) {
+		t.Errorf("Header lines Expected %v, Found %v", expected, others)
+	}
+	if debug := res.DebugInfo["requestHeaders"].(http.Header); len(debug["Forwarded"]) != 3 || len(debug["x-id"]) != 2 {
+		t.Errorf("Debug info should have each occurrence, Found %v", debug)
+	}
+}
+
+func TestSendExpectContinue(t *testing.T) {
+	oldTimeout := expectContinueTimeout
+	expectContinueTimeout = 300 * time.Millisecond
+	defer func() {
+		expectContinueTimeout = oldTimeout
+	}()
+
+	tests := []struct {
+		name          string
+		sendsContinue bool
+		timedOut      bool
+	}{
+		{"Continue", true, false},
+		{"NoContinue", false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Interim response is written on the connection, the http server sends it on the first body read
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			received := make(chan string, 1)
+			go func() {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				var expect string
+				length := 0
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if line = strings.TrimRight(line, "\r\n"); err != nil || line == "" {
+						break
+					}
+					if strings.HasPrefix(line, "Expect: ") {
+						expect = strings.TrimPrefix(line, "Expect: ")
+					} else if strings.HasPrefix(line, "Content-Length: ") {
+						length, _ = strconv.Atoi(strings.TrimPrefix(line, "Content-Length: "))
+					}
+				}
+				if test.sendsContinue {
+					time.Sleep(100 * time.Millisecond)
+					conn.Write([]byte("HTTP/1.1 100 Continue\r\n\r\n"))
+				}
+				body := make([]byte, length)
+				io.ReadFull(r, body)
+				conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+				received <- expect + " " + string(body)
+			}()
+
+			s := types.ScenarioStep{
+				ID:             1,
+				Protocol:       types.ProtocolHTTP,
+				Method:         http.MethodPost,
+				URL:            "http://" + l.Addr().String(),
+				Payload:        "large upload",
+				ExpectContinue: true,
+				Timeout:        types.DefaultTimeout,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+
+			res := h.Send(&Iteration{})
+			if res.Err.Type != "" || res.StatusCode != 200 {
+				t.Fatalf("Request errored: %v, status: %d", res.Err, res.StatusCode)
+			}
+			if r := <-received; r != "100-continue large upload" {
+				t.Errorf("Expected the expect header and the body, Found %q", r)
+			}
+
+			wait, _ := res.Custom["continueWaitDuration"].(time.Duration)
+			if _, timedOut := res.Custom["continueTimedOut"]; timedOut != test.timedOut {
+				t.Errorf("Expected timed out %v, Found %v", test.timedOut, timedOut)
+			}
+			if test.timedOut && wait != expectContinueTimeout {
+				t.Errorf("Continue wait should be the timeout, Found %v", wait)
+			}
+			if !test.timedOut && (wait < 100*time.Millisecond || wait >= expectContinueTimeout) {
+				t.Errorf("Continue wait should be the delay of the interim response, Found %v", wait)
+			}
+			if d := res.Custom["reqDuration"].(time.Duration); d >= 100*time.Millisecond {
+				t.Errorf("Request write should exclude the continue wait, Found %v", d)
+			}
+			if d := res.Custom["serverProcessDuration"].(time.Duration); d <= 0 || d >= 100*time.Millisecond {
+				t.Errorf("Server processing should be measured from the body upload, Found %v", d)
+			}
+			if res.Duration < wait {
+				t.Errorf("Duration %v should include the continue wait %v", res.Duration, wait)
+			}
+		})
+	}
+}
+
+func TestSendChunked(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		length   int64
+		encoding []string
+		body     string
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.ContentLength, r.TransferEncoding, string(body)}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		chunked  bool
+		expected request
+	}{
+		{"ContentLength", false, request{7, nil, "payload"}},
+		{"Chunked", true, request{-1, []string{"chunked"}, "payload"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodPost,
+				URL:      server.URL,
+				Payload:  "payload",
+				Chunked:  test.chunked,
+				Timeout:  types.DefaultTimeout,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+
+			if res := h.Send(&Iteration{}); res.Err.Type != "" {
+				t.Fatalf("Request errored: %v", res.Err)
+			}
+			if r := <-requests; !reflect.DeepEqual(r, test.expected) {
+				t.Errorf("Expected %+v, Found %+v", test.expected, r)
+			}
+		})
+	}
+}
+
+func TestSendResolvedAddress(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		name     string
+		url      string
+		resolved bool
+	}{
+		{"Host", fmt.Sprintf("http://localhost:%s", u.Port()), true},
+		{"IP", server.URL, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      test.url,
+				Timeout:  types.DefaultTimeout,
+			}
+
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			for i := 0; i < 2; i++ {
+				res := h.Send(&Iteration{})
+				if res.Err.Type != "" {
+					t.Fatalf("Request errored: %v", res.Err)
+				}
+				host, _ := res.Custom["resolvedHost"].(string)
+				ip, _ := res.Custom["resolvedIP"].(string)
+				if !test.resolved {
+					if host != "" || ip != "" {
+						t.Errorf("IP host should not be recorded, Found %s %s", host, ip)
+					}
+					continue
+				}
+				if host != "localhost" || ip != u.Hostname() {
+					t.Errorf("Resolved address Expected localhost %s, Found %s %s", u.Hostname(), host, ip)
+				}
+				if reused := res.Custom["connReused"].(bool); reused != (i > 0) {
+					t.Errorf("Request %d connection reused Expected %v, Found %v", i, i > 0, reused)
+				}
+			}
+		})
+	}
+}
+
+func TestSendComposedURL(t *testing.T) {
+	t.Parallel()
+
+	var path, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodDelete,
+		URL:      server.URL + "/orders/{{ .order_id }}?tag={{ urlquery .tag }}",
+		Timeout:  types.DefaultTimeout,
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, true); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	res := h.Send(&Iteration{Captures: map[string]string{"order_id": "42", "tag": "a b"}})
+	if res.Err.Type != "" {
+		t.Fatalf("Request errored: %v", res.Err)
+	}
+	if path != "/orders/42" || query != "tag=a+b" {
+		t.Errorf("URL Expected %s?%s, Found %s?%s", "/orders/42", "tag=a+b", path, query)
+	}
+
+	res = h.Send(&Iteration{})
+	if res.Err.Type == "" || !strings.Contains(res.Err.Reason, "url refers to a value that is not captured") {
+		t.Errorf("Should be errored for the missing capture, Found %v", res.Err)
+	}
+}
+
+func TestSendCollectCapture(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "order-1", "token": "t"}`))
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom: map[string]interface{}{"capture": map[string]interface{}{
+			"order_id": map[string]interface{}{"json_path": "id", "collect": true},
+			"token":    map[string]interface{}{"json_path": "token"},
+		}},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	c := NewCollector(10)
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if res := h.Send(&Iteration{Collector: c}); res.Err.Type != "" {
+			t.Fatalf("Request errored: %v", res.Err)
+		}
+	}
+
+	if collected, _ := c.Counts("order_id"); collected != 2 {
+		t.Errorf("Collected Expected %d, Found %d", 2, collected)
+	}
+	if collected, _ := c.Counts("token"); collected != 0 {
+		t.Errorf("Not collected capture Expected %d, Found %d", 0, collected)
+	}
+}
+
+func TestSendClientWait(t *testing.T) {
+	t.Parallel()
+
+	delay := 100 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+	}))
+	defer server.Close()
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+	// Requests over the single connection are queued in the transport like the requests of a saturated generator
+	h.client.Transport.(*http.Transport).MaxConnsPerHost = 1
+
+	res := h.Send(&Iteration{})
+	if w := res.Custom["clientWaitDuration"].(time.Duration); w >= delay/2 {
+		t.Errorf("Client wait of an unqueued request should be short, Found %v", w)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*types.ScenarioStepResult, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = h.Send(&Iteration{})
+		}(i)
+	}
+	wg.Wait()
+
+	var longest *types.ScenarioStepResult
+	for _, r := range results {
+		if r.Err.Type != "" {
+			t.Fatalf("Request errored: %v", r.Err)
+		}
+		if longest == nil || r.Custom["clientWaitDuration"].(time.Duration) >
+			longest.Custom["clientWaitDuration"].(time.Duration) {
+			longest = r
+		}
+	}
+	wait := longest.Custom["clientWaitDuration"].(time.Duration)
+	if wait < 3*delay/2 {
+		t.Errorf("Last queued request should wait for the 2 requests before it, Found %v", wait)
+	}
+	if server := longest.Custom["serverProcessDuration"].(time.Duration); server >= 2*delay {
+		t.Errorf("Client wait should not be counted as the server processing, Found %v", server)
+	}
+	if longest.Duration < wait+delay {
+		t.Errorf("Duration should include the client wait, Found %v for the wait %v", longest.Duration, wait)
+	}
+}
+
+func TestSendWireURL(t *testing.T) {
+	var host, sni, requestURI string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, requestURI = r.Host, r.RequestURI
+		if r.TLS != nil {
+			sni = r.TLS.ServerName
+		}
+	})
+
+	tests := []struct {
+		name        string
+		protocol    string
+		url         string
+		headers     map[string]string
+		host        string
+		sni         string
+		requestURI  string
+		composedURL bool
+	}{
+		{"EmojiDomain", types.ProtocolHTTP, "http://😀.example.com/", nil, "xn--e28h.example.com", "", "/", false},
+		{"EmojiDomainSNI", types.ProtocolHTTPS, "https://😀.example.com:8443/", nil, "xn--e28h.example.com:8443",
+			"xn--e28h.example.com", "/", false},
+		{"CustomHostHeader", types.ProtocolHTTP, "http://bücher.de/", map[string]string{"Host": "books.local"},
+			"books.local", "", "/", false},
+		{"EncodedSlash", types.ProtocolHTTP, "http://app.local/files/a%2Fb/über", nil, "app.local", "",
+			"/files/a%2Fb/%C3%BCber", false},
+		{"ReservedQuery", types.ProtocolHTTP, "http://app.local/search?q=a%26b&tags=x,y&next=/p?1&sum=1+2&s=a b", nil,
+			"app.local", "", "/search?q=a%26b&tags=x,y&next=/p?1&sum=1+2&s=a%20b", false},
+		{"DynamicQuery", types.ProtocolHTTP, "http://app.local/a%2Fb?n={{_randomInt}}&s=ä", nil, "app.local", "",
+			"/a%2Fb?n=", false},
+		{"ComposedURL", types.ProtocolHTTP, "http://😀.example.com/orders/{{ .id }}/a%2Fb", nil,
+			"xn--e28h.example.com", "", "/orders/a%2Fb/a%2Fb", true},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			socket := filepath.Join(t.TempDir(), "app.sock")
+			l, err := net.Listen("unix", socket)
+			if err != nil {
+				t.Fatalf("Listen errored: %v", err)
+			}
+			server := httptest.NewUnstartedServer(handler)
+			server.Listener = l
+			if test.protocol == types.ProtocolHTTPS {
+				server.StartTLS()
+			} else {
+				server.Start()
+			}
+			defer server.Close()
+
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: test.protocol,
+				Method:   http.MethodGet,
+				URL:      test.url,
+				Headers:  test.headers,
+				Timeout:  types.DefaultTimeout,
+				Custom:   map[string]interface{}{"unix-socket": socket},
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			it := &Iteration{}
+			if test.composedURL {
+				it.Captures = map[string]string{"id": "a%2Fb"}
+			}
+			host, sni, requestURI = "", "", ""
+			if res := h.Send(it); res.Err.Type != "" {
+				t.Fatalf("Send errored: %v", res.Err)
+			}
+			if host != test.host || sni != test.sni || !strings.HasPrefix(requestURI, test.requestURI) {
+				t.Errorf("Expected host %q, sni %q, request uri %q, Found %q, %q, %q", test.host, test.sni,
+					test.requestURI, host, sni, requestURI)
+			}
+			if test.name == "DynamicQuery" && !strings.HasSuffix(requestURI, "&s=%C3%A4") {
+				t.Errorf("Unicode query value should be encoded, Found %s", requestURI)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSendTLSCertificate(t *testing.T) {
+	t.Parallel()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		protocol string
+		url      string
+		reported bool
+	}{
+		{"HTTPS", types.ProtocolHTTPS, tlsServer.URL, true},
+		{"HTTP", types.ProtocolHTTP, server.URL, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: test.protocol,
+				Method:   http.MethodGet,
+				URL:      test.url,
+				Timeout:  types.DefaultTimeout,
+				Custom:   map[string]interface{}{"keep-alive": false},
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			var certs []types.Certificate
+			for i := 0; i < 3; i++ {
+				res := h.Send(&Iteration{})
+				if res.Err.Type != "" {
+					t.Fatalf("Request errored: %v", res.Err)
+				}
+				if c, ok := res.Custom["tlsCertificate"].(types.Certificate); ok {
+					certs = append(certs, c)
+				}
+			}
+			if !test.reported {
+				if len(certs) != 0 {
+					t.Errorf("Plaintext target should have no certificate, Found %+v", certs)
+				}
+				return
+			}
+
+			// Each request does a new handshake, the certificate of the host is reported once
+			leaf := tlsServer.Certificate()
+			if len(certs) != 1 {
+				t.Fatalf("Expected a certificate, Found %+v", certs)
+			}
+			c := certs[0]
+			if c.Host != "127.0.0.1" || c.Subject != leaf.Subject.String() || c.Issuer != leaf.Issuer.String() ||
+				!c.NotAfter.Equal(leaf.NotAfter) || c.ChainLength != 1 {
+				t.Errorf("Unexpected certificate %+v", c)
+			}
+			expected := append([]string{}, leaf.DNSNames...)
+			for _, ip := range leaf.IPAddresses {
+				expected = append(expected, ip.String())
+			}
+			if !reflect.DeepEqual(c.SANs, expected) {
+				t.Errorf("Expected the SANs %v, Found %v", expected, c.SANs)
+			}
+		})
+	}
+}
+
+func TestSendTLSResumption(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		custom  map[string]interface{}
+		resumed []bool
+	}{
+		{"SessionCache", map[string]interface{}{"keep-alive": false}, []bool{false, true, true}},
+		{"NoSessionCache", map[string]interface{}{"keep-alive": false, "tls-session-cache": false},
+			[]bool{false, false, false}},
+		{"ReusedConnection", map[string]interface{}{}, []bool{false}},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTPS,
+				Method:   http.MethodGet,
+				URL:      server.URL,
+				Timeout:  types.DefaultTimeout,
+				Custom:   test.custom,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			var resumed []bool
+			for i := 0; i < 3; i++ {
+				res := h.Send(&Iteration{})
+				if res.Err.Type != "" {
+					t.Fatalf("Request errored: %v", res.Err)
+				}
+				r, ok := res.Custom["tlsResumed"].(bool)
+				if !ok {
+					// Request over a reused connection does no handshake
+					continue
+				}
+				resumed = append(resumed, r)
+				if v := res.Custom["tlsVersion"]; v != "TLS 1.3" {
+					t.Errorf("Expected TLS 1.3, Found %v", v)
+				}
+			}
+			if !reflect.DeepEqual(resumed, test.resumed) {
+				t.Errorf("Expected the resumed handshakes %v, Found %v", test.resumed, resumed)
+			}
+		})
+	}
+}
+
+func TestSendWarmState(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	// Host is resolved by the warm state, the IP hosts are dialed as they are
+	u, _ := url.Parse(server.URL)
+	target := "https://localhost:" + u.Port()
+
+	tests := []struct {
+		name            string
+		keepConnections bool
+		cold            bool
+		custom          map[string]interface{}
+
+		// Of the first request of the second run
+		resolved bool
+		resumed  bool
+		reused   bool
+	}{
+		{"Warm", false, false, map[string]interface{}{"keep-alive": false}, false, true, false},
+		{"Cold", false, true, map[string]interface{}{"keep-alive": false}, true, false, false},
+		{"NoSessionCache", false, false, map[string]interface{}{"keep-alive": false, "tls-session-cache": false},
+			false, false, false},
+		{"KeepConnections", true, false, map[string]interface{}{}, false, false, true},
+		{"KeepConnectionsCold", true, true, map[string]interface{}{}, true, false, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			ws := warm.New(test.keepConnections)
+			defer ws.Reset()
+			run := func() *types.ScenarioStepResult {
+				s := types.ScenarioStep{
+					ID:       1,
+					Protocol: types.ProtocolHTTPS,
+					Method:   http.MethodGet,
+					URL:      target,
+					Timeout:  types.DefaultTimeout,
+					Custom:   test.custom,
+				}
+				h := &HttpRequester{}
+				h.SetWarmState(ws)
+				if err := h.Init(context.TODO(), s, nil, false); err != nil {
+					t.Fatalf("Init errored: %v", err)
+				}
+				defer h.Done()
+				res := h.Send(&Iteration{})
+				if res.Err.Type != "" {
+					t.Fatalf("Request errored: %v", res.Err)
+				}
+				return res
+			}
+
+			if res := run(); res.Custom["dnsDuration"].(time.Duration) == 0 || res.Custom["connReused"] == true {
+				t.Errorf("First run should resolve the host and dial a connection, Found %v", res.Custom)
+			}
+			if test.cold {
+				ws.Reset()
+			}
+			res := run()
+			if resolved := res.Custom["dnsDuration"].(time.Duration) > 0; resolved != test.resolved {
+				t.Errorf("Resolved Expected %v, Found %v", test.resolved, resolved)
+			}
+			if resumed, _ := res.Custom["tlsResumed"].(bool); resumed != test.resumed {
+				t.Errorf("Resumed Expected %v, Found %v", test.resumed, resumed)
+			}
+			if reused := res.Custom["connReused"] == true; reused != test.reused {
+				t.Errorf("Reused Expected %v, Found %v", test.reused, reused)
+			}
+			if sent, _ := res.Custom["bytesSent"].(int64); sent == 0 {
+				t.Errorf("Bytes of the second run should be counted")
+			}
+		})
+	}
+}
+
+func TestSendSignCommand(t *testing.T) {
+	var mu sync.Mutex
+	var received []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Clone())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		script    string
+		option    map[string]interface{}
+		sends     int
+		signature string
+		reason    string
+		stderr    string
+		// Count of the runs of the command, or of the spawns of the persistent processes
+		runs int
+	}{
+		{
+			name:      "OneShot",
+			script:    `cat > "$DIR/input"; echo run >> "$DIR/runs"; echo '{"X-Signature": "s1", "X-Remove": null}'`,
+			option:    map[string]interface{}{},
+			sends:     2,
+			signature: "s1",
+			runs:      2,
+		},
+		{
+			name: "Persistent",
+			script: `echo run >> "$DIR/runs"
+while read line; do echo "$line" > "$DIR/input"; echo '{"X-Signature": "p1", "X-Remove": null}'; done`,
+			option:    map[string]interface{}{"persistent": true},
+			sends:     3,
+			signature: "p1",
+			runs:      1,
+		},
+		{
+			name:   "ExitStatus",
+			script: `echo run >> "$DIR/runs"; echo "key not found" >&2; exit 3`,
+			option: map[string]interface{}{},
+			sends:  1,
+			reason: "sign command exited with status 3",
+			stderr: "key not found",
+			runs:   1,
+		},
+		{
+			name:   "InvalidPatch",
+			script: `echo run >> "$DIR/runs"; echo "signed"`,
+			option: map[string]interface{}{},
+			sends:  1,
+			reason: "sign command returned an invalid header patch",
+			runs:   1,
+		},
+		{
+			name:   "Timeout",
+			script: `echo run >> "$DIR/runs"; echo "signing" >&2; exec sleep 5`,
+			option: map[string]interface{}{"timeout": 100},
+			sends:  1,
+			reason: "sign command timed out",
+			stderr: "signing",
+			runs:   1,
+		},
+		{
+			// Timed out process is killed, the next request spawns a new one
+			name:   "PersistentTimeout",
+			script: `echo run >> "$DIR/runs"; read line; exec sleep 5`,
+			option: map[string]interface{}{"persistent": true, "timeout": 100},
+			sends:  2,
+			reason: "sign command timed out",
+			runs:   2,
+		},
+		{
+			name:   "PersistentExit",
+			script: `echo run >> "$DIR/runs"; read line; echo "expired" >&2; exit 2`,
+			option: map[string]interface{}{"persistent": true},
+			sends:  1,
+			reason: "sign command exited with status 2",
+			stderr: "expired",
+			runs:   1,
+		},
+	}
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			mu.Lock()
+			received = nil
+			mu.Unlock()
+			dir := t.TempDir()
+			script := filepath.Join(dir, "sign.sh")
+			if err := os.WriteFile(script, []byte("DIR="+dir+"\n"+test.script+"\n"), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			test.option["command"] = []interface{}{"/bin/sh", script}
+
+			h := &HttpRequester{}
+			s := types.ScenarioStep{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodPost,
+				URL: server.URL + "/pay", Headers: map[string]string{"X-Remove": "1", "Content-Type": "text/plain"}, Payload: "amount=10",
+				Timeout: types.DefaultTimeout, Custom: map[string]interface{}{"sign-command": test.option}}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			for i := 0; i < test.sends; i++ {
+				res := h.Send(&Iteration{})
+				if test.reason != "" {
+					if res.Err.Type != types.ErrorSign || res.Err.Reason != test.reason {
+						t.Errorf("Error Expected %s %q, Found %#v", types.ErrorSign, test.reason, res.Err)
+					}
+					if stderr, _ := res.Custom["signStderr"].(string); stderr != test.stderr {
+						t.Errorf("Stderr Expected %q, Found %q", test.stderr, stderr)
+					}
+					if res.Custom["failedURL"] != server.URL+"/pay" {
+						t.Errorf("Failed url Expected %s, Found %v", server.URL+"/pay", res.Custom["failedURL"])
+					}
+				} else if res.Err.Type != "" {
+					t.Errorf("Send errored: %#v", res.Err)
+				}
+			}
+			h.Done()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if test.reason != "" {
+				if len(received) != 0 {
+					t.Errorf("Requests failing the signing Expected not sent, Found %d", len(received))
+				}
+			} else {
+				if len(received) != test.sends {
+					t.Fatalf("Requests Expected %d, Found %d", test.sends, len(received))
+				}
+				for _, header := range received {
+					if header.Get("X-Signature") != test.signature {
+						t.Errorf("Signature Expected %s, Found %q", test.signature, header.Get("X-Signature"))
+					}
+					if _, ok := header["X-Remove"]; ok {
+						t.Errorf("Removed header Expected not sent, Found %v", header["X-Remove"])
+					}
+				}
+				in, err := os.ReadFile(filepath.Join(dir, "input"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var input signInput
+				if err := json.Unmarshal(in, &input); err != nil {
+					t.Fatalf("Input of the command is not JSON: %v", err)
+				}
+				host := strings.TrimPrefix(server.URL, "http://")
+				if input.Method != http.MethodPost || input.URL != server.URL+"/pay" || input.Body != "amount=10" ||
+					input.Headers["Content-Type"] != "text/plain" || input.Headers["Host"] != host {
+					t.Errorf("Input of the command Expected the rendered request, Found %#v", input)
+				}
+			}
+			runs, _ := os.ReadFile(filepath.Join(dir, "runs"))
+			if n := strings.Count(string(runs), "run"); n != test.runs {
+				t.Errorf("Runs Expected %d, Found %d", test.runs, n)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSendSignCommandPerIteration(t *testing.T) {
+	var mu sync.Mutex
+	var signatures []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		signatures = append(signatures, r.Header.Get("X-Signature"))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "sign.sh")
+	body := `echo run >> "` + dir + `/runs"; echo "{\"X-Signature\": \"$(wc -l < "` + dir + `/runs" | tr -d ' ')\"}"`
+	if err := os.WriteFile(script, []byte(body+"\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	option := map[string]interface{}{"command": "/bin/sh " + script, "per": types.SignPerIteration}
+
+	// Steps with the same command share the signature of the iteration
+	var requesters []*HttpRequester
+	for id := uint16(1); id <= 2; id++ {
+		h := &HttpRequester{}
+		s := types.ScenarioStep{ID: id, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: server.URL,
+			Timeout: types.DefaultTimeout, Custom: map[string]interface{}{"sign-command": option}}
+		if err := h.Init(context.TODO(), s, nil, false); err != nil {
+			t.Fatalf("Init errored: %v", err)
+		}
+		defer h.Done()
+		requesters = append(requesters, h)
+	}
+
+	for i := uint64(0); i < 2; i++ {
+		it := NewIteration(i, 1)
+		requesters[0].Send(it)
+		// Forks of a parallel group see the signature of the iteration
+		forks := []*Iteration{it.Fork(), it.Fork()}
+		var wg sync.WaitGroup
+		for _, f := range forks {
+			wg.Add(1)
+			go func(f *Iteration) {
+				defer wg.Done()
+				requesters[1].Send(f)
+			}(f)
+		}
+		wg.Wait()
+	}
+
+	expected := []string{"1", "1", "1", "2", "2", "2"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(expected, signatures) {
+		t.Errorf("Signatures Expected %v, Found %v", expected, signatures)
+	}
+}
+
+func TestSendConnectionUse(t *testing.T) {
+	// Every third request of a connection is answered with "Connection: close", like a load balancer does
+	var mu sync.Mutex
+	perConn := make(map[string]int)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		perConn[r.RemoteAddr]++
+		n := perConn[r.RemoteAddr]
+		mu.Unlock()
+		if r.ProtoMajor == 1 && n%3 == 0 {
+			w.Header().Set("Connection", "close")
+		}
+	})
+
+	tests := []struct {
+		name     string
+		server   func() *httptest.Server
+		protocol string
+		custom   map[string]interface{}
+		sends    int
+		// Server closes the idle connections before the send of the index
+		closeBefore int
+		positions   []int64
+		reconnects  []bool
+		closes      int64
+	}{
+		{
+			name:       "CloseHeader",
+			server:     func() *httptest.Server { return httptest.NewServer(handler) },
+			protocol:   types.ProtocolHTTP,
+			custom:     map[string]interface{}{},
+			sends:      7,
+			positions:  []int64{1, 2, 3, 1, 2, 3, 1},
+			reconnects: []bool{false, false, false, true, false, false, true},
+			closes:     2,
+		},
+		{
+			name:        "IdleClose",
+			server:      func() *httptest.Server { return httptest.NewServer(handler) },
+			protocol:    types.ProtocolHTTP,
+			custom:      map[string]interface{}{},
+			sends:       3,
+			closeBefore: 2,
+			positions:   []int64{1, 2, 1},
+			reconnects:  []bool{false, false, true},
+			closes:      1,
+		},
+		{
+			name:       "KeepAliveDisabled",
+			server:     func() *httptest.Server { return httptest.NewServer(handler) },
+			protocol:   types.ProtocolHTTP,
+			custom:     map[string]interface{}{"keep-alive": false},
+			sends:      3,
+			positions:  []int64{1, 1, 1},
+			reconnects: []bool{false, false, false},
+		},
+		{
+			// Streams of an h2 connection are its requests
+			name: "H2",
+			server: func() *httptest.Server {
+				server := httptest.NewUnstartedServer(handler)
+				server.EnableHTTP2 = true
+				server.StartTLS()
+				return server
+			},
+			protocol:   types.ProtocolHTTPS,
+			custom:     map[string]interface{}{"h2": true},
+			sends:      4,
+			positions:  []int64{1, 2, 3, 4},
+			reconnects: []bool{false, false, false, false},
+		},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			server := test.server()
+			defer server.Close()
+
+			h := &HttpRequester{}
+			s := types.ScenarioStep{ID: 1, Protocol: test.protocol, Method: http.MethodGet, URL: server.URL,
+				Timeout: types.DefaultTimeout, Custom: test.custom}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			var closes int64
+			for i := 0; i < test.sends; i++ {
+				if test.closeBefore > 0 && i == test.closeBefore {
+					server.CloseClientConnections()
+					time.Sleep(100 * time.Millisecond)
+				}
+				res := h.Send(&Iteration{})
+				if res.Err.Type != "" {
+					t.Fatalf("Send %d errored: %#v", i, res.Err)
+				}
+				if n, _ := res.Custom["connRequest"].(int64); n != test.positions[i] {
+					t.Errorf("Send %d position Expected %d, Found %d", i, test.positions[i], n)
+				}
+				if _, reconnect := res.Custom["connReconnect"]; reconnect != test.reconnects[i] {
+					t.Errorf("Send %d reconnect Expected %v, Found %v", i, test.reconnects[i], reconnect)
+				}
+				if n, ok := res.Custom["serverCloses"].(int64); ok {
+					closes += n
+				}
+			}
+			if closes != test.closes {
+				t.Errorf("Server closes Expected %d, Found %d", test.closes, closes)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"go.ddosify.com/ddosify/core/scenario/scripting"
+	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+)
+
+// Iteration is the state of an iteration of the scenario. The scenario service creates one for each iteration and
+// passes it to the requesters of the steps, which run one after the other, and a Fork of it to the steps of a parallel
+// group. It is never shared between the iterations, so two concurrent iterations can't see the values captured or
+// the cookies received by each other.
+type Iteration struct {
+	// Sequence number of the iteration in the run, starting from 0
+	ID uint64
+
+	// ID of the virtual user running the iteration, stable across its iterations
+	VUID uint64
+
+	// Variant of the steps with ab-variants in the iteration, types.VariantA or types.VariantB. All the steps of
+	// the iteration run on the same variant.
+	Variant string
+
+	// Values captured by the steps of the iteration so far, by their names
+	Captures map[string]string
+
+	// Connections of the virtual user or of the iteration itself, nil if the steps use the shared connections
+	Connections *Connections
+
+	// Cache validators of the virtual user for the steps with conditional_requests, nil if no step has it
+	Validators *Validators
+
+	// Collector of the values of the collected captures of all the iterations, nil if no capture is collected
+	Collector *Collector
+
+	// User and the order of the iteration in the vu load type, the sequential targets files are partitioned by it.
+	// nil for the other load types.
+	User *types.UserIteration
+
+	seed int64
+	rnd  *rand.Rand
+	jar  http.CookieJar
+
+	// Values captured by a fork, added to the iteration by Join. Nil if it is not a fork.
+	forked map[string]string
+
+	// Headers of the per-iteration sign commands, shared by the forks. Created on the first use.
+	signed *signatures
+}
+
+// signatures are the headers of the sign commands signing once per iteration, by the keys of the commands.
+type signatures struct {
+	mu      sync.Mutex
+	patches map[string]signPatch
+}
+
+// NewIteration returns the state of the iteration with the given ID, its random source derives from the seed.
+func NewIteration(id uint64, seed int64) *Iteration {
+	return &Iteration{ID: id, seed: seed}
+}
+
+// Capture records a value captured by a step for the later steps of the iteration.
+func (it *Iteration) Capture(name, value string) {
+	if it.Captures == nil {
+		it.Captures = make(map[string]string)
+	}
+	it.Captures[name] = value
+	if it.forked != nil {
+		it.forked[name] = value
+	}
+}
+
+// Fork returns the state of a step run concurrently with the other steps of its parallel group. The fork sees the
+// values captured before the group and shares the cookies and the connections of the iteration. Its own captures are
+// added to the iteration by Join once the group completes.
+func (it *Iteration) Fork() *Iteration {
+	f := &Iteration{ID: it.ID, VUID: it.VUID, Variant: it.Variant, Connections: it.Connections, Validators: it.Validators,
+		Collector: it.Collector, seed: it.seed, jar: it.Cookies(), forked: make(map[string]string),
+		signed: it.signatures(), User: it.User}
+	if it.Captures != nil {
+		f.Captures = make(map[string]string, len(it.Captures))
+		for k, v := range it.Captures {
+			f.Captures[k] = v
+		}
+	}
+	return f
+}
+
+// Join adds the values captured by the forks of a parallel group to the iteration, in the order of the forks.
+func (it *Iteration) Join(forks []*Iteration) {
+	for _, f := range forks {
+		for k, v := range f.forked {
+			it.Capture(k, v)
+		}
+	}
+}
+
+// signatures returns the headers of the per-iteration sign commands of the iteration, created on the first use.
+func (it *Iteration) signatures() *signatures {
+	if it.signed == nil {
+		it.signed = &signatures{}
+	}
+	return it.signed
+}
+
+// collect adds a value captured by a step to the values collected across the iterations.
+func (it *Iteration) collect(name, value string) {
+	if it.Collector != nil {
+		it.Collector.add(name, value)
+	}
+}
+
+// Rand returns the random source of the iteration. It derives from the seed and the ID of the iteration, so the random
+// decisions of an iteration don't depend on the scheduling of the concurrent ones. It is created on the first use
+// and is not safe for concurrent use.
+func (it *Iteration) Rand() *rand.Rand {
+	if it.rnd == nil {
+		it.rnd = rand.New(rand.NewSource(util.SubSeed(it.seed, "iteration/"+strconv.FormatUint(it.ID, 10))))
+	}
+	return it.rnd
+}
+
+// Cookies returns the cookie jar of the iteration, created on the first use.
+func (it *Iteration) Cookies() http.CookieJar {
+	if it.jar == nil {
+		// Error is always nil without the options
+		it.jar, _ = cookiejar.New(nil)
+	}
+	return it.jar
+}
+
+// values returns the values of the iteration variables of the given step, the IDs are zero without an iteration.
+func (it *Iteration) values(stepID uint16) scripting.IterationValues {
+	v := scripting.IterationValues{StepID: stepID}
+	if it != nil {
+		v.IterationID, v.VUID = it.ID, it.VUID
+	}
+	return v
+}
+
+// cookies returns the cookies of the iteration to send to the url, without creating the jar.
+func (it *Iteration) cookies(u *url.URL) []*http.Cookie {
+	if it == nil || it.jar == nil {
+		return nil
+	}
+	return it.jar.Cookies(u)
+}
+
+// setCookies keeps the cookies set by the response for the later steps of the iteration.
+func (it *Iteration) setCookies(res *http.Response) {
+	if it == nil || len(res.Header["Set-Cookie"]) == 0 {
+		return
+	}
+	it.Cookies().SetCookies(res.Request.URL, res.Cookies())
+}
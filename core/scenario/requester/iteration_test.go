@@ -0,0 +1,112 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestIterationRand(t *testing.T) {
+	t.Parallel()
+
+	first, again, next := NewIteration(3, 42), NewIteration(3, 42), NewIteration(4, 42)
+	a, b, c := first.Rand().Int63(), again.Rand().Int63(), next.Rand().Int63()
+	if a != b {
+		t.Errorf("Same iteration of the same seed should give the same values, Found %d and %d", a, b)
+	}
+	if a == c {
+		t.Errorf("Different iterations should give different values, Found %d", a)
+	}
+	if first.Rand() != first.Rand() {
+		t.Errorf("Random source should be created once per iteration")
+	}
+}
+
+func TestSendCookies(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: r.URL.Query().Get("user")})
+			return
+		}
+		if c, err := r.Cookie("session"); err == nil {
+			w.Header().Set("X-Session", c.Value)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		disabled bool
+		expected string
+	}{
+		{"Enabled", false, "ada"},
+		{"Disabled", true, ""},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			step := func(id uint16, path string) types.ScenarioStep {
+				return types.ScenarioStep{ID: id, Protocol: types.ProtocolHTTP, Method: http.MethodGet,
+					URL: server.URL + path, Timeout: types.DefaultTimeout,
+					Headers: map[string]string{"X-Static": "1"},
+					Custom: map[string]interface{}{
+						"disable-cookies": test.disabled,
+						"capture":         map[string]interface{}{"session": map[string]interface{}{"header": "X-Session"}},
+					}}
+			}
+			login, profile := &HttpRequester{}, &HttpRequester{}
+			if err := login.Init(context.TODO(), step(1, "/login?user=ada"), nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			if err := profile.Init(context.TODO(), step(2, "/profile"), nil, true); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+
+			it := &Iteration{}
+			login.Send(it)
+			res := profile.Send(it)
+			if it.Captures["session"] != test.expected {
+				t.Errorf("Session Expected %q, Found %q", test.expected, it.Captures["session"])
+			}
+
+			// Cookies of an iteration are not sent by the others, nor left in the shared request
+			other := &Iteration{}
+			profile.Send(other)
+			if session, ok := other.Captures["session"]; ok {
+				t.Errorf("Other iteration should not send the cookies, Found %q", session)
+			}
+			if profile.request.Header.Get("Cookie") != "" {
+				t.Errorf("Cookies should not be added to the shared request header")
+			}
+			if !test.disabled && res.DebugInfo["requestHeaders"].(http.Header).Get("X-Static") != "1" {
+				t.Errorf("Static headers should be kept with the cookies")
+			}
+		})
+	}
+}
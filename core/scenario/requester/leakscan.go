@@ -0,0 +1,85 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import (
+	"strings"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// leakExcerptContext is the count of the bytes of the body kept on each side of a match in its excerpt.
+const leakExcerptContext = 40
+
+// leakScanner scans the response bodies of a step for the patterns of its leak-scan. Since the regular expressions
+// are expensive on the high RPS, only a ratio of the responses and the leading bytes of their bodies are scanned.
+type leakScanner struct {
+	scan *types.LeakScan
+
+	// Count of the responses that are sampled or skipped
+	requests int64
+}
+
+// newLeakScanner compiles the patterns of the step once. Returns nil if the step has no leak-scan.
+// Keys are already validated in types.scenario.validate().
+func newLeakScanner(custom map[string]interface{}) (*leakScanner, error) {
+	val, ok := custom["leak-scan"]
+	if !ok {
+		return nil, nil
+	}
+	scan, err := types.ParseLeakScan(val)
+	if scan == nil || err != nil {
+		return nil, err
+	}
+	return &leakScanner{scan: scan}, nil
+}
+
+// sampled decides whether the response of the next request is scanned.
+func (l *leakScanner) sampled() bool {
+	return evenlySampled(&l.requests, l.scan.Sample)
+}
+
+// check scans the head of the body, each pattern is reported once by its first match. The authorization value of the
+// request is redacted in the excerpts of the matches.
+func (l *leakScanner) check(body []byte, url, authorization string) types.LeakScanResult {
+	if int64(len(body)) > l.scan.MaxBody {
+		body = body[:l.scan.MaxBody]
+	}
+	res := types.LeakScanResult{URL: url}
+	for _, p := range l.scan.Patterns {
+		if loc := p.Regexp.FindIndex(body); loc != nil {
+			res.Matches = append(res.Matches, types.LeakMatch{Pattern: p.Name, Excerpt: redactAuthorization(leakExcerpt(body, loc), authorization)})
+		}
+	}
+	return res
+}
+
+// leakExcerpt returns the match at the loc of the body with its context, on a single line.
+func leakExcerpt(body []byte, loc []int) string {
+	start, end := loc[0]-leakExcerptContext, loc[1]+leakExcerptContext
+	if start < 0 {
+		start = 0
+	}
+	if end > len(body) {
+		end = len(body)
+	}
+	excerpt := strings.ToValidUTF8(string(body[start:end]), "")
+	return strings.Join(strings.Fields(excerpt), " ")
+}
@@ -0,0 +1,184 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+const (
+	// Bucket of a capped connection holds the bytes of this window, so the throughput is even at the window scale.
+	shapingWindow = 100 * time.Millisecond
+
+	// Smallest write and read chunk of a capped connection, the syscall count stays low on the slow caps.
+	minShapingChunk = 512
+)
+
+// networkShaper simulates the network conditions of the clients. Each connection is capped by its own token buckets,
+// so the virtual users don't share the bandwidth, and each request is delayed by the latency and the jitter.
+type networkShaper struct {
+	types.NetworkShaping
+	rnd *rand.Rand
+}
+
+// newNetworkShaper returns nil if the step has no network option. rnd must be safe for concurrent use.
+func newNetworkShaper(custom map[string]interface{}, rnd *rand.Rand) (*networkShaper, error) {
+	val, ok := custom["network"]
+	if !ok {
+		return nil, nil
+	}
+	n, err := types.ParseNetworkShaping(val)
+	if err != nil {
+		return nil, err
+	}
+	return &networkShaper{NetworkShaping: n, rnd: rnd}, nil
+}
+
+// dialContext wraps the connections of dial with the throughput caps.
+func (s *networkShaper) dialContext(dial dialFunc) dialFunc {
+	if s.Download == 0 && s.Upload == 0 {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &shapedConn{Conn: conn, read: newTokenBucket(s.Download), write: newTokenBucket(s.Upload)}, nil
+	}
+}
+
+// delay waits the latency and a random jitter, and returns the waited duration. It returns early if ctx is done.
+func (s *networkShaper) delay(ctx context.Context) time.Duration {
+	d := s.Latency
+	if s.Jitter > 0 {
+		d += time.Duration(s.rnd.Int63n(int64(s.Jitter) + 1))
+	}
+	if d == 0 {
+		return 0
+	}
+
+	start := time.Now()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+	return since(start)
+}
+
+// tokenBucket paces the bytes of a connection direction. A nil bucket is unlimited.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	if rate == 0 {
+		return nil
+	}
+	burst := float64(rate) * shapingWindow.Seconds()
+	if burst < minShapingChunk {
+		burst = minShapingChunk
+	}
+	return &tokenBucket{rate: float64(rate), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// chunk returns the max byte count of a single read or write.
+func (b *tokenBucket) chunk() int {
+	return int(b.burst)
+}
+
+// take takes n bytes from the bucket and returns how long the caller should wait for them. The bucket goes into debt,
+// so the following takes wait for the bytes taken ahead.
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// shapedConn caps the read and the write throughput of a connection.
+type shapedConn struct {
+	net.Conn
+	read  *tokenBucket
+	write *tokenBucket
+}
+
+// Read delivers the bytes read from the connection once the bucket has them. Since the socket buffer is not drained
+// meanwhile, TCP flow control slows down the sender as well.
+func (c *shapedConn) Read(b []byte) (int, error) {
+	if c.read == nil {
+		return c.Conn.Read(b)
+	}
+	if len(b) > c.read.chunk() {
+		b = b[:c.read.chunk()]
+	}
+	n, err := c.Conn.Read(b)
+	if wait := c.read.take(n); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// Write writes b in chunks, each chunk waits for its bytes in the bucket.
+func (c *shapedConn) Write(b []byte) (written int, err error) {
+	if c.write == nil {
+		return c.Conn.Write(b)
+	}
+	for len(b) > 0 {
+		p := b
+		if len(p) > c.write.chunk() {
+			p = p[:c.write.chunk()]
+		}
+		if wait := c.write.take(len(p)); wait > 0 {
+			time.Sleep(wait)
+		}
+		n, err := c.Conn.Write(p)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,126 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(10000)
+	if b.chunk() != 1000 {
+		t.Errorf("Burst should hold the bytes of the shaping window, Found %d", b.chunk())
+	}
+	if wait := b.take(1000); wait != 0 {
+		t.Errorf("Burst should be taken without waiting, Found %v", wait)
+	}
+	// The bucket is empty, 500 more bytes are paid in 50ms.
+	if wait := b.take(500); wait < 40*time.Millisecond || wait > 50*time.Millisecond {
+		t.Errorf("Expected a wait of ~50ms, Found %v", wait)
+	}
+
+	if b := newTokenBucket(100); b.chunk() != minShapingChunk {
+		t.Errorf("Expected the min chunk on the slow caps, Found %d", b.chunk())
+	}
+	if b := newTokenBucket(0); b != nil {
+		t.Errorf("Unlimited direction should have no bucket")
+	}
+}
+
+func TestNetworkShaping(t *testing.T) {
+	// 200KB response, 100KB request
+	body := bytes.Repeat([]byte("a"), 200000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		network map[string]interface{}
+		min     time.Duration
+		max     time.Duration
+		latency bool
+	}{
+		// 200KB at 1MB/s, minus the 100KB burst
+		{"Download", map[string]interface{}{"download": "8Mbps"}, 90 * time.Millisecond, time.Second, false},
+		// 100KB at 500KB/s, minus the 50KB burst
+		{"Upload", map[string]interface{}{"upload": "4Mbps"}, 90 * time.Millisecond, time.Second, false},
+		{"Latency", map[string]interface{}{"latency": float64(150), "jitter": float64(50)},
+			150 * time.Millisecond, time.Second, true},
+		{"Unshaped", nil, 0, 90 * time.Millisecond, false},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodPost,
+				URL:      server.URL,
+				Payload:  string(bytes.Repeat([]byte("b"), 100000)),
+				Timeout:  types.DefaultTimeout,
+			}
+			if test.network != nil {
+				s.Custom = map[string]interface{}{"network": test.network}
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			start := time.Now()
+			res := h.Send(&Iteration{})
+			elapsed := time.Since(start)
+			if res.Err.Type != "" {
+				t.Fatalf("Send errored: %v", res.Err)
+			}
+			if elapsed < test.min || elapsed > test.max {
+				t.Errorf("Expected the request in [%v, %v], Found %v", test.min, test.max, elapsed)
+			}
+
+			latency, ok := res.Custom["latencyDuration"].(time.Duration)
+			if ok != test.latency {
+				t.Fatalf("Injected latency should be reported only if the step has latency, Found %v", latency)
+			}
+			if test.latency && (latency < 150*time.Millisecond || latency > 250*time.Millisecond) {
+				t.Errorf("Expected the injected latency in [150ms, 250ms], Found %v", latency)
+			}
+			if res.Duration < latency {
+				t.Errorf("Duration should include the injected latency, Found %v < %v", res.Duration, latency)
+			}
+			if server := res.Custom["serverProcessDuration"].(time.Duration); test.latency && server >= latency {
+				t.Errorf("Injected latency should not be counted as the server time, Found %v", server)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
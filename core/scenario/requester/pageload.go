@@ -0,0 +1,260 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+	"golang.org/x/net/html"
+)
+
+var (
+	// Imported stylesheets and the other url() values of a stylesheet, like the fonts and the background images
+	cssImportRegexp = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*)?['"]?([^'")\s;]+)`)
+	cssURLRegexp    = regexp.MustCompile(`(?i)url\(\s*['"]?([^'")\s]+)['"]?\s*\)`)
+)
+
+// pageLoader loads the page of a step like a browser, it fetches the images, the scripts and the stylesheets of the
+// HTML page concurrently over the connections and the cookies of the request of the page.
+type pageLoader struct {
+	types.PageLoad
+}
+
+// newPageLoader returns the loader of the page-load option of the step, nil if the step has none.
+func newPageLoader(custom map[string]interface{}) *pageLoader {
+	val, ok := custom["page-load"]
+	if !ok {
+		return nil
+	}
+	// Validated with the scenario
+	p, _ := types.ParsePageLoad(val)
+	return &pageLoader{PageLoad: p}
+}
+
+// pageRef is a resource referenced by a page or a stylesheet, stylesheet is set if its own resources are fetched on
+// the next level.
+type pageRef struct {
+	url        *url.URL
+	stylesheet bool
+}
+
+// isHTML reports whether the response is an HTML page.
+func isHTML(header http.Header) bool {
+	t, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return t == "text/html" || t == "application/xhtml+xml"
+}
+
+// load fetches the resources of the page whose final url is page, level by level up to the depth. It returns the
+// fetched resources and the time that the fetches took. The failed resources don't fail the page.
+func (p *pageLoader) load(ctx context.Context, h *HttpRequester, it *Iteration, client *http.Client, page *url.URL,
+	body []byte, header http.Header) ([]types.PageResource, time.Duration) {
+	start := time.Now()
+	// Jar is shared by the concurrent fetches, it is created beforehand
+	if h.cookies && it != nil {
+		it.Cookies()
+	}
+
+	seen := map[string]struct{}{page.String(): {}}
+	level := p.filter(htmlResources(body, page), page, seen)
+	var resources []types.PageResource
+	for depth := 1; len(level) > 0; depth++ {
+		fetched, bodies := p.fetchAll(ctx, h, it, client, level, page.String(), header, depth < p.Depth)
+		resources = append(resources, fetched...)
+		sheets := level
+		level = nil
+		for i, b := range bodies {
+			if b != nil {
+				level = append(level, p.filter(cssResources(b, sheets[i].url), page, seen)...)
+			}
+		}
+	}
+	return resources, since(start)
+}
+
+// filter returns the refs that are not fetched yet, of the origin of the page unless the cross origin resources are
+// fetched, within the resource limit of the page.
+func (p *pageLoader) filter(refs []pageRef, page *url.URL, seen map[string]struct{}) []pageRef {
+	var kept []pageRef
+	for _, r := range refs {
+		if len(seen) > p.MaxResources {
+			break
+		}
+		if r.url.Scheme != "http" && r.url.Scheme != "https" {
+			continue
+		}
+		if !p.CrossOrigin && (r.url.Scheme != page.Scheme || !strings.EqualFold(r.url.Host, page.Host)) {
+			continue
+		}
+		r.url.Fragment = ""
+		if _, ok := seen[r.url.String()]; ok {
+			continue
+		}
+		seen[r.url.String()] = struct{}{}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// fetchAll fetches the refs with at most Concurrency of them at the same time. The bodies of the stylesheets are
+// returned if keepStylesheets is set, in the order of the refs like the resources.
+func (p *pageLoader) fetchAll(ctx context.Context, h *HttpRequester, it *Iteration, client *http.Client, refs []pageRef,
+	referer string, header http.Header, keepStylesheets bool) ([]types.PageResource, [][]byte) {
+	resources := make([]types.PageResource, len(refs))
+	bodies := make([][]byte, len(refs))
+	sem := make(chan struct{}, p.Concurrency)
+	var wg sync.WaitGroup
+	for i, r := range refs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, r pageRef) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			resources[i], bodies[i] = p.fetch(ctx, h, it, client, r, referer, header, keepStylesheets && r.stylesheet)
+		}(i, r)
+	}
+	wg.Wait()
+	return resources, bodies
+}
+
+// fetch fetches a resource like a browser, with the user agent of the page request and the page as its referer.
+func (p *pageLoader) fetch(ctx context.Context, h *HttpRequester, it *Iteration, client *http.Client, r pageRef,
+	referer string, header http.Header, keepBody bool) (types.PageResource, []byte) {
+	start := time.Now()
+	res := types.PageResource{URL: r.url.Redacted()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url.String(), nil)
+	if err != nil {
+		res.Failed = true
+		return res, nil
+	}
+	req.Header.Set("Referer", referer)
+	for _, k := range []string{"User-Agent", "Accept-Language"} {
+		if v := header.Get(k); v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+	if h.cookies {
+		for _, c := range it.cookies(req.URL) {
+			req.AddCookie(c)
+		}
+	}
+
+	httpRes, err := client.Do(req)
+	if err != nil {
+		res.Failed, res.Duration = true, since(start)
+		return res, nil
+	}
+	var body *bytes.Buffer
+	var dst io.Writer = io.Discard
+	if keepBody {
+		body = &bytes.Buffer{}
+		dst = body
+	}
+	res.Bytes, err = io.Copy(dst, io.LimitReader(httpRes.Body, h.maxBodySize))
+	httpRes.Body.Close()
+	if h.cookies {
+		it.setCookies(httpRes)
+	}
+	res.StatusCode = httpRes.StatusCode
+	res.ContentType, _, _ = mime.ParseMediaType(httpRes.Header.Get("Content-Type"))
+	res.Failed = err != nil || httpRes.StatusCode >= http.StatusBadRequest
+	res.Duration = since(start)
+	if body == nil || res.Failed {
+		return res, nil
+	}
+	return res, body.Bytes()
+}
+
+// htmlResources returns the images, the scripts and the stylesheets of the page, resolved against the page url or
+// the base element of the page. The tokenizer is tolerant of the broken markup like the unclosed tags.
+func htmlResources(body []byte, page *url.URL) []pageRef {
+	base := page
+	var refs []pageRef
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return refs
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		t := z.Token()
+		attr := func(name string) string {
+			for _, a := range t.Attr {
+				if a.Key == name {
+					return strings.TrimSpace(a.Val)
+				}
+			}
+			return ""
+		}
+		var ref string
+		stylesheet := false
+		switch t.Data {
+		case "base":
+			if u, err := page.Parse(attr("href")); err == nil && attr("href") != "" {
+				base = u
+			}
+			continue
+		case "img", "script":
+			ref = attr("src")
+		case "link":
+			for _, rel := range strings.Fields(strings.ToLower(attr("rel"))) {
+				if rel == "stylesheet" {
+					ref, stylesheet = attr("href"), true
+				}
+			}
+		}
+		if ref == "" {
+			continue
+		}
+		if u, err := base.Parse(ref); err == nil {
+			refs = append(refs, pageRef{url: u, stylesheet: stylesheet})
+		}
+	}
+}
+
+// cssResources returns the imported stylesheets and the other resources of the stylesheet, resolved against its url.
+func cssResources(body []byte, sheet *url.URL) []pageRef {
+	var refs []pageRef
+	add := func(matches [][]byte, stylesheet bool) {
+		if u, err := sheet.Parse(string(matches[1])); err == nil {
+			refs = append(refs, pageRef{url: u, stylesheet: stylesheet})
+		}
+	}
+	for _, m := range cssImportRegexp.FindAllSubmatch(body, -1) {
+		add(m, true)
+	}
+	for _, m := range cssURLRegexp.FindAllSubmatch(body, -1) {
+		add(m, false)
+	}
+	return refs
+}
@@ -0,0 +1,104 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"crypto/tls"
+	"net/url"
+	"time"
+)
+
+// maxPreflightTimeout is the upper bound of the connectivity probe of an address, the step timeout is used if it is
+// shorter.
+const maxPreflightTimeout = 5 * time.Second
+
+// PreflightProbe is the outcome of the connectivity probe of an address of a step.
+type PreflightProbe struct {
+	Addr string
+
+	// Duration of the DNS resolution, the connection and the TLS handshake of the probe
+	Latency time.Duration
+	Err     error
+}
+
+// Preflight opens one connection to each address of the step, including the TLS handshake for the https targets,
+// so a typo in the host fails the test before the load begins. The connections through a proxy are probed up to
+// the proxy. Returns no probe if the host of the step is only known on the requests, like the dynamic hosts and
+// the targets-file.
+func (h *HttpRequester) Preflight() (probes []PreflightProbe) {
+	if h.targets != nil {
+		return nil
+	}
+	if u, err := url.Parse(h.packet.URL); err != nil || dynamicVariableRe.MatchString(u.Host) {
+		return nil
+	}
+
+	timeout := time.Duration(h.packet.Timeout) * time.Second
+	if timeout <= 0 || timeout > maxPreflightTimeout {
+		timeout = maxPreflightTimeout
+	}
+
+	u := h.request.URL
+	secure := u.Scheme == "https"
+	var addrs []string
+	if h.proxyAddr != nil {
+		addr, err := prewarmAddr(h.proxyAddr.Scheme, h.proxyAddr.Hostname(), h.proxyAddr.Port())
+		if err != nil {
+			return nil
+		}
+		addrs, secure = []string{addr}, false
+	} else {
+		hosts := []string{u.Host}
+		if h.hosts != nil {
+			hosts = hosts[:0]
+			for _, wh := range h.hosts.hosts {
+				hosts = append(hosts, wh.Host)
+			}
+		}
+		for _, host := range hosts {
+			hu := &url.URL{Host: host}
+			addr, err := prewarmAddr(u.Scheme, hu.Hostname(), hu.Port())
+			if err != nil {
+				return nil
+			}
+			addrs = append(addrs, addr)
+		}
+	}
+
+	config := h.initTLSConfig()
+	// Probes do full handshakes and don't leave their sessions to the requests of the test
+	config.ClientSessionCache = nil
+	for _, addr := range addrs {
+		p := newConnPool(addr, secure, h.dialContext(), func() *tls.Config { return config })
+		ctx, cancel := context.WithTimeout(h.ctx, timeout)
+		start := time.Now()
+		conn, err := p.connect(ctx, "tcp", addr)
+		probes = append(probes, PreflightProbe{Addr: addr, Latency: since(start), Err: err})
+		cancel()
+		if conn != nil {
+			conn.Close()
+		}
+	}
+	// Probes are not a part of the transferred bytes of the test.
+	h.transferred.claim()
+	return probes
+}
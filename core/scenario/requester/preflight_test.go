@@ -0,0 +1,108 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestPreflight(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	plainAddr := strings.TrimPrefix(plain.URL, "http://")
+	secureAddr := strings.TrimPrefix(secure.URL, "https://")
+	proxyAddr, _ := url.Parse(plain.URL)
+
+	tests := []struct {
+		name     string
+		url      string
+		custom   map[string]interface{}
+		proxy    *url.URL
+		expected []string
+		errors   []string
+	}{
+		{"HTTP", plain.URL + "/path", nil, nil, []string{plainAddr}, []string{""}},
+		{"HTTPS", secure.URL, nil, nil, []string{secureAddr}, []string{""}},
+		{"TLSToPlainServer", "https://" + plainAddr, nil, nil, []string{plainAddr}, []string{"tls"}},
+		{"Refused", "http://" + closedAddr, nil, nil, []string{closedAddr}, []string{"refused"}},
+		{"UnknownHost", "http://ddosify.invalid", nil, nil, []string{"ddosify.invalid:80"}, []string{"lookup"}},
+		{"Hosts", "http://app.local", map[string]interface{}{"hosts": []interface{}{plainAddr, closedAddr}}, nil,
+			[]string{plainAddr, closedAddr}, []string{"", "refused"}},
+		{"Proxy", "https://ddosify.invalid", nil, proxyAddr, []string{plainAddr}, []string{""}},
+		{"DynamicHost", "http://{{_randomInt}}.example.com", nil, nil, nil, nil},
+		{"TargetsFile", "", map[string]interface{}{"targets-file": writeTargetsFile(t, plain.URL)}, nil, nil, nil},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      test.url,
+				Timeout:  types.DefaultTimeout,
+				Custom:   test.custom,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, test.proxy, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+
+			probes := h.Preflight()
+			if len(probes) != len(test.expected) {
+				t.Fatalf("Expected %d probes, Found %v", len(test.expected), probes)
+			}
+			for i, p := range probes {
+				if p.Addr != test.expected[i] {
+					t.Errorf("Expected address %s, Found %s", test.expected[i], p.Addr)
+				}
+				if test.errors[i] == "" && (p.Err != nil || p.Latency <= 0) {
+					t.Errorf("Probe of %s should succeed, Found %v %v", p.Addr, p.Err, p.Latency)
+				}
+				if test.errors[i] != "" && (p.Err == nil || !strings.Contains(p.Err.Error(), test.errors[i])) {
+					t.Errorf("Probe of %s should fail with %q, Found %v", p.Addr, test.errors[i], p.Err)
+				}
+			}
+			if sent, received := h.transferred.claim(); sent != 0 || received != 0 {
+				t.Errorf("Probes should not be counted in the transferred bytes, Found %d %d", sent, received)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// prewarmWorkers is the count of the concurrent dials of the prewarm.
+const prewarmWorkers = 100
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Prewarm establishes the prewarm-connections of the step, including the TLS handshakes, before the test.
+func (h *HttpRequester) Prewarm() (requested, established int, err error) {
+	if h.prewarm == 0 {
+		return
+	}
+	if h.pool == nil {
+		return h.prewarm, 0, fmt.Errorf("connections through a proxy can't be prewarmed")
+	}
+
+	timeout := time.Duration(h.packet.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = types.DefaultTimeout * time.Second
+	}
+	established, err = h.pool.fill(h.ctx, h.prewarm, timeout)
+	// Handshakes of the prewarm are not a part of the transferred bytes of the test.
+	h.transferred.claim()
+	return h.prewarm, established, err
+}
+
+// initPrewarm makes the transport take the prewarmed connections before dialing new ones.
+// The connections through a proxy are not prewarmed, since the transport dials the proxy instead of the target.
+func (h *HttpRequester) initPrewarm(tr *http.Transport) error {
+	if h.prewarm == 0 || h.proxyAddr != nil {
+		return nil
+	}
+
+	u := h.request.URL
+	addr, err := prewarmAddr(u.Scheme, u.Hostname(), u.Port())
+	if err != nil {
+		return err
+	}
+	secure := u.Scheme == "https"
+	h.pool = newConnPool(addr, secure, h.dialContext(), func() *tls.Config { return tr.TLSClientConfig })
+	if secure {
+		tr.DialTLSContext = h.pool.dialContext()
+	} else {
+		tr.DialContext = h.pool.dialContext()
+	}
+	return nil
+}
+
+// connPool keeps the connections established before the test. The transport takes them instead of dialing new ones
+// until the pool is empty, so the handshakes of the first requests are not measured.
+type connPool struct {
+	addr   string
+	tls    bool
+	dial   dialFunc
+	config func() *tls.Config
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newConnPool(addr string, secure bool, dial dialFunc, config func() *tls.Config) *connPool {
+	return &connPool{addr: addr, tls: secure, dial: dial, config: config}
+}
+
+// fill establishes n connections to the address of the pool concurrently, including the TLS handshakes.
+// Each dial is bounded by the timeout. Returns the count of the established connections and the first dial error.
+func (p *connPool) fill(ctx context.Context, n int, timeout time.Duration) (established int, err error) {
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	sem := make(chan struct{}, prewarmWorkers)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			dialCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			conn, dErr := p.connect(dialCtx, "tcp", p.addr)
+			if dErr != nil {
+				errOnce.Do(func() { err = dErr })
+				return
+			}
+			p.mu.Lock()
+			p.conns = append(p.conns, conn)
+			p.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns), err
+}
+
+// connect dials a new connection, the TLS handshake is done on the dialed connection for the https targets.
+func (p *connPool) connect(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := p.dial(ctx, network, addr)
+	if err != nil || !p.tls {
+		return conn, err
+	}
+
+	config := p.config().Clone()
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	// Transport doesn't trace the handshakes of the custom TLS dialers, they are traced here for the TLS duration.
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	tc := tls.Client(conn, config)
+	err = tc.HandshakeContext(ctx)
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(tc.ConnectionState(), err)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// dialContext returns the dialer of the transport, which takes the prewarmed connections of the address first.
+func (p *connPool) dialContext() dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if conn := p.take(addr); conn != nil {
+			return conn, nil
+		}
+		return p.connect(ctx, network, addr)
+	}
+}
+
+func (p *connPool) take(addr string) net.Conn {
+	if addr != p.addr {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.conns) == 0 {
+		return nil
+	}
+	conn := p.conns[len(p.conns)-1]
+	p.conns = p.conns[:len(p.conns)-1]
+	return conn
+}
+
+// close closes the prewarmed connections that are not taken by the transport.
+func (p *connPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
+
+// prewarmAddr returns the dial address of the step url, like the transport does.
+func prewarmAddr(scheme, host, port string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("prewarm needs a static target host")
+	}
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(host, port), nil
+}
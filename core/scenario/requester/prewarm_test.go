@@ -0,0 +1,155 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestPrewarm(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		tls      bool
+		h2       bool
+	}{
+		{"HTTP", types.ProtocolHTTP, false, false},
+		{"HTTPS", types.ProtocolHTTPS, true, false},
+		{"H2", types.ProtocolHTTPS, true, true},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			var newConns int64
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.Config.ConnState = func(c net.Conn, s http.ConnState) {
+				if s == http.StateNew {
+					atomic.AddInt64(&newConns, 1)
+				}
+			}
+			if test.tls {
+				server.EnableHTTP2 = test.h2
+				server.StartTLS()
+			} else {
+				server.Start()
+			}
+			defer server.Close()
+
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: test.protocol,
+				Method:   http.MethodGet,
+				URL:      server.URL,
+				Timeout:  types.DefaultTimeout,
+				Custom:   map[string]interface{}{"prewarm-connections": float64(5), "h2": test.h2},
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			requested, established, err := h.Prewarm()
+			if requested != 5 || established != 5 || err != nil {
+				t.Fatalf("Expected 5 prewarmed connections, Found %d of %d, err: %v", established, requested, err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if res := h.Send(&Iteration{}); res.Err.Type != "" || res.StatusCode != http.StatusOK {
+						t.Errorf("Send errored: %v, status: %d", res.Err, res.StatusCode)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if n := atomic.LoadInt64(&newConns); n != 5 {
+				t.Errorf("Requests should use the prewarmed connections, Found %d connections", n)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestPrewarmFailures(t *testing.T) {
+	// Dials to the closed listener are refused.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	proxy, _ := url.Parse("http://127.0.0.1:8080")
+
+	tests := []struct {
+		name      string
+		custom    map[string]interface{}
+		proxy     *url.URL
+		requested int
+	}{
+		{"Disabled", nil, nil, 0},
+		{"ConnectionRefused", map[string]interface{}{"prewarm-connections": float64(3)}, nil, 3},
+		{"Proxy", map[string]interface{}{"prewarm-connections": float64(3)}, proxy, 3},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			s := types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      "http://" + l.Addr().String(),
+				Timeout:  types.DefaultTimeout,
+				Custom:   test.custom,
+			}
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, test.proxy, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			defer h.Done()
+
+			start := time.Now()
+			requested, established, err := h.Prewarm()
+			if requested != test.requested || established != 0 {
+				t.Errorf("Expected 0 of %d prewarmed connections, Found %d of %d", test.requested, established, requested)
+			}
+			if (err != nil) != (test.requested > 0) {
+				t.Errorf("Failed prewarm should be errored, err: %v", err)
+			}
+			if time.Since(start) > time.Second {
+				t.Errorf("Refused dials should fail fast")
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
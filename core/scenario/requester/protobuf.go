@@ -0,0 +1,91 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"net/http"
+
+	"go.ddosify.com/ddosify/core/scenario/protobuf"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// protobufCodec encodes the rendered JSON payloads of a step to its protobuf request message and decodes the
+// protobuf responses to the JSON of its response message.
+type protobufCodec struct {
+	request  *protobuf.Message
+	response *protobuf.Message
+}
+
+// protobufEncodeError is the error of encoding a rendered payload, it is reported as types.ErrorProtobuf.
+type protobufEncodeError struct {
+	err error
+}
+
+func (e *protobufEncodeError) Error() string {
+	return "protobuf request: " + e.err.Error()
+}
+
+// newProtobufCodec loads the message types of the step once. Returns nil if the step has no payload_protobuf.
+func newProtobufCodec(p *types.ProtobufPayload) (*protobufCodec, error) {
+	if p == nil {
+		return nil, nil
+	}
+	request, response, err := p.Messages()
+	if err != nil {
+		return nil, err
+	}
+	return &protobufCodec{request: request, response: response}, nil
+}
+
+// encode returns the wire format of the rendered payload, the payload is sent as it is without a request message.
+func (c *protobufCodec) encode(body string) (string, error) {
+	if c == nil || c.request == nil {
+		return body, nil
+	}
+	wire, err := c.request.EncodeJSON([]byte(body))
+	if err != nil {
+		return "", &protobufEncodeError{err: err}
+	}
+	return string(wire), nil
+}
+
+// requestJSON returns the JSON of the encoded request body for the debug output, nil if it is not protobuf.
+func (c *protobufCodec) requestJSON(body []byte) []byte {
+	if c == nil || c.request == nil {
+		return nil
+	}
+	decoded, _ := c.request.DecodeJSON(body)
+	return decoded
+}
+
+// decodes reports whether the response is decoded, only the responses with a protobuf content type are.
+func (c *protobufCodec) decodes(header http.Header) bool {
+	return c != nil && c.response != nil && protobuf.IsContentType(header.Get("Content-Type"))
+}
+
+// decodeResponse decodes the response body to the JSON of the response message.
+func (c *protobufCodec) decodeResponse(body []byte) ([]byte, types.RequestError, bool) {
+	decoded, err := c.response.DecodeJSON(body)
+	if err != nil {
+		return nil, types.RequestError{Type: types.ErrorProtobuf, Reason: "protobuf response: " + err.Error()}, false
+	}
+	return decoded, types.RequestError{}, true
+}
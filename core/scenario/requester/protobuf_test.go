@@ -0,0 +1,152 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+const testProtobufUser = `syntax = "proto3";
+package users.v1;
+message CreateUser { int64 id = 1; string name = 2; }
+message User { int64 id = 1; string name = 2; repeated string roles = 3; }
+`
+
+func newProtobufStep(t *testing.T, url, payload string, custom map[string]interface{}) types.ScenarioStep {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "user.proto")
+	os.WriteFile(path, []byte(testProtobufUser), 0600)
+	return types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      url,
+		Timeout:  types.DefaultTimeout,
+		Headers:  map[string]string{"Content-Type": types.ProtobufContentType},
+		Payload:  payload,
+		Protobuf: &types.ProtobufPayload{File: path, Message: "users.v1.CreateUser", ResponseMessage: "users.v1.User"},
+		Custom:   custom,
+	}
+}
+
+func TestSendProtobuf(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = hex.EncodeToString(body)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		// User{id: 7, name: "ada", roles: ["admin"]}
+		w.Write([]byte("\x08\x07\x12\x03ada\x1a\x05admin"))
+	}))
+	defer server.Close()
+
+	s := newProtobufStep(t, server.URL, `{"id": "7", "name": "ada"}`, map[string]interface{}{
+		"capture": map[string]interface{}{
+			"user_id": map[string]interface{}{"json_path": "id"},
+			"role":    map[string]interface{}{"json_path": "roles.0"},
+		},
+	})
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, true); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	it := &Iteration{}
+	res := h.Send(it)
+	if res.Err.Type != "" {
+		t.Fatalf("Send errored: %v", res.Err)
+	}
+	if received != "08071203616461" {
+		t.Errorf("Request body Expected 08071203616461, Found %s", received)
+	}
+	if expected := map[string]string{"user_id": "7", "role": "admin"}; !reflect.DeepEqual(it.Captures, expected) {
+		t.Errorf("Captures Expected %v, Found %v", expected, it.Captures)
+	}
+
+	if msg := string(res.DebugInfo["requestMessage"].([]byte)); msg != `{"id":"7","name":"ada"}` {
+		t.Errorf("Request message Expected the JSON, Found %s", msg)
+	}
+	if msg := string(res.DebugInfo["responseMessage"].([]byte)); msg != `{"id":"7","name":"ada","roles":["admin"]}` {
+		t.Errorf("Response message Expected the JSON, Found %s", msg)
+	}
+	if body := res.DebugInfo["responseBody"].([]byte); body[0] != 0x08 {
+		t.Errorf("Response body should be the wire format, Found %q", body)
+	}
+}
+
+func TestSendProtobufErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		contentType string
+		response    string
+		expected    types.RequestError
+	}{
+		{"EncodeError", `{"name": {{_randomInt}}}`, "application/x-protobuf", "",
+			types.RequestError{Type: types.ErrorProtobuf, Reason: "protobuf request: field name should be a string"}},
+		{"DecodeError", `{"id": 1}`, "application/x-protobuf", "\x12\x05ad",
+			types.RequestError{Type: types.ErrorProtobuf, Reason: "protobuf response: truncated protobuf message"}},
+		// Only the responses with a protobuf content type are decoded
+		{"JSONResponse", `{"id": 1}`, "application/json", `{"id": "1"}`, types.RequestError{}},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				w.Write([]byte(test.response))
+			}))
+			defer server.Close()
+
+			s := newProtobufStep(t, server.URL, test.payload, map[string]interface{}{
+				"capture": map[string]interface{}{"user_id": map[string]interface{}{"json_path": "id"}},
+			})
+			h := &HttpRequester{}
+			if err := h.Init(context.TODO(), s, nil, false); err != nil {
+				t.Fatalf("Init errored: %v", err)
+			}
+			if res := h.Send(&Iteration{}); res.Err != test.expected {
+				t.Errorf("Expected %#v, Found %#v", test.expected, res.Err)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestInitInvalidProtobuf(t *testing.T) {
+	s := newProtobufStep(t, "http://127.0.0.1", `{}`, nil)
+	s.Protobuf.Message = "users.v1.Missing"
+
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err == nil {
+		t.Errorf("Missing message should be errored")
+	}
+}
@@ -0,0 +1,58 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import (
+	"net/http"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// Requests of a redirect chain kept per request, the default policy of the client follows at most 10 redirects
+const maxRedirectHops = 10
+
+// redirectChain returns the requests of the redirect chain of a response from the first one, each with the status
+// code of its response. It is nil if the request is not redirected, like if the step disables the redirects. loop is
+// set if an url is requested twice in the chain.
+func redirectChain(res *http.Response) (chain []types.RedirectHop, loop bool) {
+	if res == nil || res.Request == nil || res.Request.Response == nil {
+		return nil, false
+	}
+	// Each redirect request points to the response that caused it
+	for r := res; r != nil && r.Request != nil; r = r.Request.Response {
+		chain = append(chain, types.RedirectHop{URL: r.Request.URL.Redacted(), StatusCode: r.StatusCode})
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+
+	seen := make(map[string]struct{}, len(chain))
+	for _, hop := range chain {
+		if _, ok := seen[hop.URL]; ok {
+			loop = true
+			break
+		}
+		seen[hop.URL] = struct{}{}
+	}
+	if len(chain) > maxRedirectHops {
+		chain = chain[:maxRedirectHops]
+	}
+	return chain, loop
+}
@@ -0,0 +1,210 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requestertest
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.ddosify.com/ddosify/core/scenario/requester"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// concurrentSends is the count of the concurrent Send calls of the conformance, run it with -race to catch the data
+// races of the requester.
+const concurrentSends = 8
+
+// cancelTimeout bounds the Send of a requester whose ctx is done.
+const cancelTimeout = 5 * time.Second
+
+// Conformance runs the conformance tests of the requester registered for the protocol of the step, against the
+// target of the step. A requester registered by requester.Register runs it in its own tests, like
+//
+//	func TestConformance(t *testing.T) {
+//		requestertest.Conformance(t, types.ScenarioStep{ID: 1, Name: "echo", Protocol: "ECHO", URL: "echo://test"})
+//	}
+//
+// The step should be a valid one that the requester succeeds to send.
+func Conformance(t *testing.T, step types.ScenarioStep) {
+	t.Helper()
+
+	t.Run("Registered", func(t *testing.T) {
+		r1, err := requester.NewRequester(step)
+		if err != nil {
+			t.Fatalf("protocol %s is not registered: %v", step.Protocol, err)
+		}
+		r2, _ := requester.NewRequester(step)
+		if v := reflect.ValueOf(r1); v.Kind() == reflect.Ptr && v.Pointer() == reflect.ValueOf(r2).Pointer() {
+			t.Errorf("factory of protocol %s should return a new requester on each call", step.Protocol)
+		}
+	})
+
+	t.Run("MetricMeta", func(t *testing.T) {
+		r := newRequester(t, context.Background(), step, false)
+		defer r.Done()
+
+		keys, jsonKeys := make(map[string]bool), make(map[string]bool)
+		for _, m := range r.MetricMeta() {
+			if !strings.Contains(m.Key, "Duration") && !strings.HasSuffix(m.Key, "Count") {
+				t.Errorf("metric key %q should contain Duration or end with Count", m.Key)
+			}
+			if m.Name == "" || m.JSONKey == "" {
+				t.Errorf("metric %q should have a name and a json key", m.Key)
+			}
+			if m.Key == "duration" || m.JSONKey == "total" {
+				t.Errorf("metric %q is reserved for the total duration", m.Key)
+			}
+			if keys[m.Key] || jsonKeys[m.JSONKey] {
+				t.Errorf("metric %q is declared more than once", m.Key)
+			}
+			keys[m.Key], jsonKeys[m.JSONKey] = true, true
+		}
+	})
+
+	t.Run("Send", func(t *testing.T) {
+		r := newRequester(t, context.Background(), step, false)
+		defer r.Done()
+
+		res := r.Send(requester.NewIteration(0, 1))
+		checkResult(t, step, res)
+		if res.Err.Type != "" {
+			t.Errorf("request of the step should succeed: %v", res.Err.Error())
+		}
+	})
+
+	t.Run("Debug", func(t *testing.T) {
+		r := newRequester(t, context.Background(), step, true)
+		defer r.Done()
+
+		res := r.Send(requester.NewIteration(0, 1))
+		checkResult(t, step, res)
+		checkDebugInfo(t, res, "url", "")
+		checkDebugInfo(t, res, "method", "")
+		checkDebugInfo(t, res, "requestHeaders", http.Header{})
+		checkDebugInfo(t, res, "requestBody", []byte{})
+		if res.Err.Type == "" {
+			checkDebugInfo(t, res, "responseHeaders", http.Header{})
+			checkDebugInfo(t, res, "responseBody", []byte{})
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		r := newRequester(t, context.Background(), step, false)
+		defer r.Done()
+
+		var wg sync.WaitGroup
+		results := make([]*types.ScenarioStepResult, concurrentSends)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = r.Send(requester.NewIteration(uint64(i), 1))
+			}(i)
+		}
+		wg.Wait()
+
+		ids := make(map[uuid.UUID]bool, len(results))
+		for _, res := range results {
+			checkResult(t, step, res)
+			if res != nil && ids[res.RequestID] {
+				t.Errorf("request id %s is not unique", res.RequestID)
+			}
+			if res != nil {
+				ids[res.RequestID] = true
+			}
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := newRequester(t, ctx, step, false)
+		defer r.Done()
+		cancel()
+
+		done := make(chan *types.ScenarioStepResult, 1)
+		go func() { done <- r.Send(requester.NewIteration(0, 1)) }()
+		select {
+		case res := <-done:
+			if res == nil || res.Err.Type == "" {
+				t.Errorf("request should fail once the ctx is done")
+			}
+		case <-time.After(cancelTimeout):
+			t.Errorf("request is not canceled in %s once the ctx is done", cancelTimeout)
+		}
+	})
+}
+
+// newRequester creates and initializes the requester of the step.
+func newRequester(t *testing.T, ctx context.Context, step types.ScenarioStep, debug bool) requester.Requester {
+	t.Helper()
+	r, err := requester.NewRequester(step)
+	if err != nil {
+		t.Fatalf("protocol %s is not registered: %v", step.Protocol, err)
+	}
+	if err := r.Init(ctx, step, nil, debug); err != nil {
+		t.Fatalf("requester is not initialized: %v", err)
+	}
+	return r
+}
+
+// checkResult checks the fields of the result that the reports rely on.
+func checkResult(t *testing.T, step types.ScenarioStep, res *types.ScenarioStepResult) {
+	t.Helper()
+	if res == nil {
+		t.Fatalf("result should not be nil")
+	}
+	if res.StepID != step.ID || res.StepName != step.Name {
+		t.Errorf("result should be of step %d %q, found %d %q", step.ID, step.Name, res.StepID, res.StepName)
+	}
+	if res.RequestID == uuid.Nil {
+		t.Errorf("result should have a request id")
+	}
+	if res.RequestTime.IsZero() {
+		t.Errorf("result should have the request time")
+	}
+	if res.Duration < 0 {
+		t.Errorf("duration should not be negative: %s", res.Duration)
+	}
+	for k, v := range res.Custom {
+		if _, ok := v.(time.Duration); strings.Contains(k, "Duration") && !ok {
+			t.Errorf("custom %s should be a time.Duration, found %T", k, v)
+		}
+		if _, ok := v.(int64); strings.HasSuffix(k, "Count") && !ok {
+			t.Errorf("custom %s should be an int64, found %T", k, v)
+		}
+	}
+}
+
+// checkDebugInfo checks the debug info of the key has the type of the sample.
+func checkDebugInfo(t *testing.T, res *types.ScenarioStepResult, key string, sample interface{}) {
+	t.Helper()
+	v, ok := res.DebugInfo[key]
+	if !ok {
+		t.Errorf("debug info should have %s", key)
+	} else if reflect.TypeOf(v) != reflect.TypeOf(sample) {
+		t.Errorf("debug info %s should be a %T, found %T", key, sample, v)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requestertest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestConformanceHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	Conformance(t, types.ScenarioStep{
+		ID:       1,
+		Name:     "http",
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+	})
+}
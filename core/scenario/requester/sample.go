@@ -0,0 +1,57 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Response bodies of the failed requests are cut to failureSnippetLimit bytes for the failure samples of the report.
+const failureSnippetLimit = 256
+
+const redactedValue = "[REDACTED]"
+
+// Authorization headers echoed in the bodies, like the request headers mirrored by the debug endpoints.
+var authorizationEcho = regexp.MustCompile(`(?i)(authorization"?\s*[:=]\s*"?)[^"\r\n,}]*`)
+
+// failureSnippet returns the head of the response body of a failed request, with the authorization value of
+// the request and the echoed authorization headers redacted.
+func failureSnippet(body []byte, authorization string) string {
+	// Redacted before the cut, so a secret crossing the limit is not left partially
+	head := body
+	if len(head) > 4*failureSnippetLimit {
+		head = head[:4*failureSnippetLimit]
+	}
+	s := redactAuthorization(string(head), authorization)
+	if len(s) > failureSnippetLimit {
+		s = strings.ToValidUTF8(s[:failureSnippetLimit], "")
+	}
+	return s
+}
+
+// redactAuthorization redacts the authorization value of the request and the echoed authorization headers in s.
+func redactAuthorization(s, authorization string) string {
+	if authorization != "" {
+		s = strings.ReplaceAll(s, authorization, redactedValue)
+	}
+	return authorizationEcho.ReplaceAllString(s, "${1}"+redactedValue)
+}
@@ -0,0 +1,86 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"go.ddosify.com/ddosify/core/scenario/jsonschema"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// schemaAssertion validates the response bodies of a step against the JSON Schema given by its json-schema key.
+// Since the validation is expensive on the high RPS, json-schema-sample limits it to a ratio of the responses.
+type schemaAssertion struct {
+	schema *jsonschema.Schema
+	sample float64
+
+	// Count of the requests that are sampled or skipped
+	requests int64
+}
+
+// newSchemaAssertion compiles the schema of the step once. Returns nil if the step has no json-schema.
+// Keys are already validated in types.scenario.validate().
+func newSchemaAssertion(custom map[string]interface{}) (*schemaAssertion, error) {
+	path, ok := custom["json-schema"].(string)
+	if !ok {
+		return nil, nil
+	}
+
+	schema, err := jsonschema.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &schemaAssertion{schema: schema, sample: 1}
+	if val, ok := custom["json-schema-sample"]; ok {
+		if a.sample, err = types.ParseJSONSchemaSample(val); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// sampled decides whether the response of the next request is validated.
+func (a *schemaAssertion) sampled() bool {
+	return evenlySampled(&a.requests, a.sample)
+}
+
+// evenlySampled decides whether the next request counted by the counter is in the sample of the ratio. Requests are
+// sampled evenly by the counter instead of a random pick, the first request is always sampled.
+func evenlySampled(requests *int64, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	n := atomic.AddInt64(requests, 1) - 1
+	return n == 0 || int64(float64(n)*ratio) != int64(float64(n-1)*ratio)
+}
+
+// check validates the body, the first validation error is returned as an assertion error.
+func (a *schemaAssertion) check(body []byte) (types.RequestError, bool) {
+	if err := a.schema.ValidateJSON(body); err != nil {
+		return types.RequestError{
+			Type:   types.ErrorAssertion,
+			Reason: fmt.Sprintf("json schema mismatch at %s", err),
+		}, false
+	}
+	return types.RequestError{}, true
+}
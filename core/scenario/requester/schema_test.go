@@ -0,0 +1,160 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func TestSchemaAssertionSampled(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   float64
+		requests int
+		expected []int
+	}{
+		{"All", 1, 3, []int{0, 1, 2}},
+		{"OnePercent", 0.01, 301, []int{0, 100, 200, 300}},
+		{"Half", 0.5, 6, []int{0, 2, 4}},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			a := &schemaAssertion{sample: test.sample}
+			found := []int{}
+			for i := 0; i < test.requests; i++ {
+				if a.sampled() {
+					found = append(found, i)
+				}
+			}
+			if fmt.Sprint(found) != fmt.Sprint(test.expected) {
+				t.Errorf("Expected %v, Found %v", test.expected, found)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSendJSONSchema(t *testing.T) {
+	var reqCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&reqCount, 1) {
+		case 1:
+			fmt.Fprint(w, `{"items": [{"id": 1}]}`)
+		case 2:
+			fmt.Fprint(w, `{"items": [{"id": 1}, {"id": "2"}]}`)
+		default:
+			fmt.Fprint(w, `<html></html>`)
+		}
+	}))
+	defer server.Close()
+
+	schema := filepath.Join(t.TempDir(), "schema.json")
+	os.WriteFile(schema, []byte(`{
+		"type": "object",
+		"required": ["items"],
+		"properties": {"items": {"type": "array", "items": {"properties": {"id": {"type": "integer"}}}}}
+	}`), 0600)
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom:   map[string]interface{}{"json-schema": schema},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	expected := []types.RequestError{
+		{},
+		{Type: types.ErrorAssertion, Reason: "json schema mismatch at /items/1/id: type should be integer"},
+		{Type: types.ErrorAssertion, Reason: "json schema mismatch at (root): body is not a valid json"},
+	}
+	for i, e := range expected {
+		if res := h.Send(&Iteration{}); res.Err != e {
+			t.Errorf("%d. Expected %#v, Found %#v", i, e, res.Err)
+		}
+	}
+}
+
+func TestSendJSONSchemaSample(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	schema := filepath.Join(t.TempDir(), "schema.json")
+	os.WriteFile(schema, []byte(`{"type": "object"}`), 0600)
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom:   map[string]interface{}{"json-schema": schema, "json-schema-sample": "25%"},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	failed := 0
+	for i := 0; i < 8; i++ {
+		if res := h.Send(&Iteration{}); res.Err.Type == types.ErrorAssertion {
+			failed++
+		}
+	}
+	if failed != 2 {
+		t.Errorf("Expected 2 validated responses, Found %d", failed)
+	}
+}
+
+func TestInitInvalidJSONSchema(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "schema.json")
+	os.WriteFile(schema, []byte(`{"type": "text"}`), 0600)
+
+	s := types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      "http://localhost",
+		Timeout:  types.DefaultTimeout,
+		Custom:   map[string]interface{}{"json-schema": schema},
+	}
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err == nil {
+		t.Errorf("Init should fail with an invalid schema")
+	}
+}
@@ -0,0 +1,380 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// signError is the failure of the sign command of a step, it is reported as types.ErrorSign. The reason is one of a
+// few messages so the failures are grouped, the tail of the stderr of the command goes to the failure samples.
+type signError struct {
+	reason string
+	stderr string
+}
+
+func (e *signError) Error() string {
+	return e.reason
+}
+
+// signInput is the request rendered for the sign command. A body that is not valid UTF-8 is sent in BodyBase64.
+type signInput struct {
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	BodyBase64 string            `json:"body_base64,omitempty"`
+}
+
+// signPatch is the output of the sign command, the headers to set and the nil ones to remove.
+type signPatch map[string]*string
+
+// signer runs the sign command of a step for its requests and applies the headers it returns.
+type signer struct {
+	types.SignCommand
+	key string
+
+	// Slots of the persistent processes, a nil slot is spawned on its first use or after its process failed
+	procs chan *signProcess
+}
+
+// newSigner returns the signer of the sign-command option of the step, nil if the step has none.
+func newSigner(custom map[string]interface{}) *signer {
+	val, ok := custom["sign-command"]
+	if !ok {
+		return nil
+	}
+	// Validated with the scenario
+	c, _ := types.ParseSignCommand(val)
+	s := &signer{SignCommand: c, key: c.Key()}
+	if c.Persistent {
+		s.procs = make(chan *signProcess, c.Processes)
+		for i := 0; i < c.Processes; i++ {
+			s.procs <- nil
+		}
+	}
+	return s
+}
+
+// sign applies the headers of the sign command to the request. With per iteration, the headers of the first signed
+// request of the iteration are applied to the later ones, the forks of the iteration share them.
+func (s *signer) sign(ctx context.Context, it *Iteration, req *http.Request) error {
+	if !s.PerIteration || it == nil {
+		patch, err := s.run(ctx, req)
+		if err != nil {
+			return err
+		}
+		patch.apply(req)
+		return nil
+	}
+
+	sigs := it.signatures()
+	sigs.mu.Lock()
+	defer sigs.mu.Unlock()
+	patch, ok := sigs.patches[s.key]
+	if !ok {
+		var err error
+		if patch, err = s.run(ctx, req); err != nil {
+			return err
+		}
+		if sigs.patches == nil {
+			sigs.patches = make(map[string]signPatch)
+		}
+		sigs.patches[s.key] = patch
+	}
+	patch.apply(req)
+	return nil
+}
+
+// run returns the headers of the sign command for the request.
+func (s *signer) run(ctx context.Context, req *http.Request) (signPatch, error) {
+	in, err := newSignInput(req)
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	line = append(line, '\n')
+
+	var out []byte
+	var stderr *stderrTail
+	if s.Persistent {
+		out, stderr, err = s.exchange(ctx, line)
+	} else {
+		out, stderr, err = s.exec(ctx, line)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Output is not sampled, it may have the signatures
+	var patch signPatch
+	if err := json.Unmarshal(out, &patch); err != nil || patch == nil {
+		return nil, &signError{reason: "sign command returned an invalid header patch", stderr: stderr.String()}
+	}
+	return patch, nil
+}
+
+// exec runs the command once for the input.
+func (s *signer) exec(ctx context.Context, in []byte) ([]byte, *stderrTail, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	stderr := &stderrTail{}
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, nil, &signError{reason: "sign command timed out", stderr: stderr.String()}
+	}
+	if err != nil {
+		return nil, nil, &signError{reason: exitReason(err), stderr: stderr.String()}
+	}
+	return stdout.Bytes(), stderr, nil
+}
+
+// exchange writes the input to a persistent process and reads a line of output from it. The process is killed if it
+// fails or doesn't answer in the timeout, the next request spawns a new one.
+func (s *signer) exchange(ctx context.Context, in []byte) ([]byte, *stderrTail, error) {
+	var p *signProcess
+	select {
+	case p = <-s.procs:
+	case <-ctx.Done():
+		return nil, nil, &signError{reason: "sign command timed out"}
+	}
+	if p == nil {
+		var err error
+		if p, err = startSignProcess(s.Command); err != nil {
+			s.procs <- nil
+			return nil, nil, &signError{reason: exitReason(err)}
+		}
+	}
+
+	type answer struct {
+		line []byte
+		err  error
+	}
+	answered := make(chan answer, 1)
+	go func() {
+		if _, err := p.stdin.Write(in); err != nil {
+			answered <- answer{err: err}
+			return
+		}
+		line, err := p.stdout.ReadBytes('\n')
+		answered <- answer{line: line, err: err}
+	}()
+
+	timer := time.NewTimer(s.Timeout)
+	defer timer.Stop()
+	select {
+	case a := <-answered:
+		if a.err == nil {
+			s.procs <- p
+			return a.line, p.stderr, nil
+		}
+		// Process exited by itself, its status is known once it is waited
+		p.kill()
+		s.procs <- nil
+		return nil, nil, &signError{reason: exitReason(p.waitErr), stderr: p.stderr.String()}
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	p.kill()
+	s.procs <- nil
+	return nil, nil, &signError{reason: "sign command timed out", stderr: p.stderr.String()}
+}
+
+// close stops the idle persistent processes, the requests of the step are completed.
+func (s *signer) close() {
+	if s == nil || s.procs == nil {
+		return
+	}
+	for i := 0; i < s.Processes; i++ {
+		select {
+		case p := <-s.procs:
+			if p != nil {
+				p.close(s.Timeout)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// newSignInput renders the request for the sign command, the body is read from GetBody without consuming it.
+func newSignInput(req *http.Request) (*signInput, error) {
+	in := &signInput{Method: req.Method, URL: req.URL.String(), Headers: make(map[string]string, len(req.Header)+1)}
+	for k, v := range req.Header {
+		in.Headers[k] = strings.Join(v, ", ")
+	}
+	if req.Host != "" {
+		in.Headers["Host"] = req.Host
+	} else {
+		in.Headers["Host"] = req.URL.Host
+	}
+
+	var body []byte
+	var err error
+	if req.GetBody != nil {
+		var rc io.ReadCloser
+		if rc, err = req.GetBody(); err == nil {
+			body, err = io.ReadAll(rc)
+			rc.Close()
+		}
+	} else if req.Body != nil && req.Body != http.NoBody {
+		if body, err = io.ReadAll(req.Body); err == nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("body of the request to sign can not be read: %v", err)
+	}
+	if utf8.Valid(body) {
+		in.Body = string(body)
+	} else {
+		in.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+	return in, nil
+}
+
+// apply sets and removes the headers of the patch. The header of the request may be shared, it is cloned first.
+func (p signPatch) apply(req *http.Request) {
+	if len(p) == 0 {
+		return
+	}
+	req.Header = req.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header, len(p))
+	}
+	for k, v := range p {
+		switch {
+		case strings.EqualFold(k, "Host") && v != nil:
+			req.Host = *v
+		case v == nil:
+			req.Header.Del(k)
+		default:
+			req.Header.Set(k, *v)
+		}
+	}
+}
+
+// exitReason returns the reason of the failed command without its varying details.
+func exitReason(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Sprintf("sign command exited with status %d", exitErr.ExitCode())
+	}
+	if err == nil {
+		return "sign command exited"
+	}
+	return "sign command failed to start"
+}
+
+// signProcess is a persistent process of a sign command.
+type signProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *bufio.Reader
+	stderr  *stderrTail
+	waitErr error
+}
+
+func startSignProcess(command []string) (*signProcess, error) {
+	p := &signProcess{cmd: exec.Command(command[0], command[1:]...), stderr: &stderrTail{}}
+	p.cmd.Stderr = p.stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.cmd.Start(); err != nil {
+		return nil, err
+	}
+	p.stdin, p.stdout = stdin, bufio.NewReader(stdout)
+	return p, nil
+}
+
+// kill stops the process and waits for it, so its stderr is complete.
+func (p *signProcess) kill() {
+	p.cmd.Process.Kill()
+	p.stdin.Close()
+	p.waitErr = p.cmd.Wait()
+}
+
+// close lets the process exit on the end of its stdin, it is killed if it doesn't exit in the timeout.
+func (p *signProcess) close(timeout time.Duration) {
+	p.stdin.Close()
+	exited := make(chan struct{})
+	go func() {
+		p.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(timeout):
+		p.cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// stderrTail keeps the last failureSnippetLimit bytes written to the stderr of a command.
+type stderrTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *stderrTail) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, b...)
+	if len(t.buf) > failureSnippetLimit {
+		t.buf = append(t.buf[:0:0], t.buf[len(t.buf)-failureSnippetLimit:]...)
+	}
+	return len(b), nil
+}
+
+func (t *stderrTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.ToValidUTF8(strings.TrimSpace(string(t.buf)), "")
+}
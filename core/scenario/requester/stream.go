@@ -0,0 +1,161 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+const streamReadBufferSize = 32 * 1024
+
+// streamConfig is the streaming mode configuration of a step, for long-poll and chunked endpoints.
+// Zero limits mean the response is read until EOF.
+type streamConfig struct {
+	maxBytes    int64
+	maxChunks   int64
+	maxDuration time.Duration
+}
+
+// newStreamConfig parses the streaming mode keys of the step's Custom field. Returns nil if the mode is disabled.
+// Keys are already validated in types.scenario.validate().
+func newStreamConfig(custom map[string]interface{}) *streamConfig {
+	if enabled, _ := custom["stream"].(bool); !enabled {
+		return nil
+	}
+
+	c := &streamConfig{}
+	if v, ok := util.ToFloat64(custom["stream-max-bytes"]); ok {
+		c.maxBytes = int64(v)
+	}
+	if v, ok := util.ToFloat64(custom["stream-max-chunks"]); ok {
+		c.maxChunks = int64(v)
+	}
+	if v, ok := util.ToFloat64(custom["stream-max-duration"]); ok {
+		c.maxDuration = time.Duration(v * float64(time.Second))
+	}
+	return c
+}
+
+// streamStats is the metrics of a streamed response body.
+type streamStats struct {
+	// Durations from the request start to the first and last body bytes
+	firstByte time.Duration
+	lastByte  time.Duration
+
+	// Count of the body reads which returned data
+	chunks int64
+	bytes  int64
+
+	// Dispatched Server-Sent Events, only counted for text/event-stream responses
+	sse    bool
+	events int64
+}
+
+// readStream reads the response body chunk by chunk until EOF or one of the configured limits is reached.
+// Reaching a limit ends the request without error. If keep is not nil, the read body is written into it.
+// cancel must cancel the context of the request, it is used to abort the ongoing read when the duration limit is reached.
+func (c *streamConfig) readStream(res *http.Response, reqStart time.Time, keep *bytes.Buffer,
+	cancel context.CancelFunc) (st streamStats, err error) {
+	st.sse = strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream")
+
+	var limitReached int32
+	if c.maxDuration > 0 {
+		t := time.AfterFunc(c.maxDuration-since(reqStart), func() {
+			atomic.StoreInt32(&limitReached, 1)
+			cancel()
+		})
+		defer t.Stop()
+	}
+
+	parser := &sseParser{}
+	buf := make([]byte, streamReadBufferSize)
+	for {
+		n, rErr := res.Body.Read(buf)
+		if n > 0 {
+			now := since(reqStart)
+			if st.chunks == 0 {
+				st.firstByte = now
+			}
+			st.lastByte = now
+			st.chunks++
+			st.bytes += int64(n)
+
+			if keep != nil {
+				keep.Write(buf[:n])
+			}
+			if st.sse {
+				st.events += parser.feed(buf[:n])
+			}
+
+			if (c.maxBytes > 0 && st.bytes >= c.maxBytes) || (c.maxChunks > 0 && st.chunks >= c.maxChunks) {
+				return
+			}
+		}
+
+		if rErr != nil {
+			if rErr != io.EOF && atomic.LoadInt32(&limitReached) == 0 {
+				err = rErr
+			}
+			return
+		}
+	}
+}
+
+// sseParser counts the dispatched events of a Server-Sent Events stream.
+// An event is dispatched by an empty line following at least one non-comment line.
+type sseParser struct {
+	lineLen   int
+	firstByte byte
+	hasField  bool
+}
+
+func (p *sseParser) feed(b []byte) (events int64) {
+	for _, c := range b {
+		switch c {
+		case '\r':
+			// CRLF line endings, the event is dispatched at LF.
+		case '\n':
+			if p.lineLen == 0 {
+				if p.hasField {
+					events++
+				}
+				p.hasField = false
+			} else if p.firstByte != ':' {
+				p.hasField = true
+			}
+			p.lineLen = 0
+		default:
+			if p.lineLen == 0 {
+				p.firstByte = c
+			}
+			p.lineLen++
+		}
+	}
+	return
+}
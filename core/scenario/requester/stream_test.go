@@ -0,0 +1,143 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+func newStreamingServer(contentType string, chunks []string, interval time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		flusher := w.(http.Flusher)
+		for _, c := range chunks {
+			fmt.Fprint(w, c)
+			flusher.Flush()
+			time.Sleep(interval)
+		}
+	}))
+}
+
+func TestNewStreamConfig(t *testing.T) {
+	if c := newStreamConfig(map[string]interface{}{}); c != nil {
+		t.Errorf("Stream config should be nil when the stream mode is disabled")
+	}
+
+	c := newStreamConfig(map[string]interface{}{
+		"stream":              true,
+		"stream-max-bytes":    float64(100),
+		"stream-max-chunks":   3,
+		"stream-max-duration": 1.5,
+	})
+	expected := &streamConfig{maxBytes: 100, maxChunks: 3, maxDuration: time.Duration(1500) * time.Millisecond}
+	if *c != *expected {
+		t.Errorf("Expected %#v, Found %#v", expected, c)
+	}
+}
+
+func TestSendStream(t *testing.T) {
+	chunks := []string{"chunk1", "chunk2", "chunk3", "chunk4"}
+	interval := time.Duration(50) * time.Millisecond
+
+	tests := []struct {
+		name           string
+		custom         map[string]interface{}
+		expectedChunks int64
+		expectedBytes  int64
+	}{
+		{"UntilEOF", map[string]interface{}{"stream": true}, 4, 24},
+		{"MaxChunks", map[string]interface{}{"stream": true, "stream-max-chunks": 2}, 2, 12},
+		{"MaxBytes", map[string]interface{}{"stream": true, "stream-max-bytes": 13}, 3, 18},
+		{"MaxDuration", map[string]interface{}{"stream": true, "stream-max-duration": 0.12}, 3, 18},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			server := newStreamingServer("text/plain", chunks, interval)
+			defer server.Close()
+
+			h := &HttpRequester{}
+			h.Init(context.TODO(), types.ScenarioStep{
+				ID:       1,
+				Protocol: types.ProtocolHTTP,
+				Method:   http.MethodGet,
+				URL:      server.URL,
+				Timeout:  types.DefaultTimeout,
+				Custom:   test.custom,
+			}, nil, false)
+			res := h.Send(&Iteration{})
+
+			if res.Err.Type != "" {
+				t.Fatalf("Unexpected error: %v", res.Err)
+			}
+			if res.Custom["chunkCount"] != test.expectedChunks {
+				t.Errorf("ChunkCount Expected %d, Found %v", test.expectedChunks, res.Custom["chunkCount"])
+			}
+			if res.Custom["byteCount"] != test.expectedBytes {
+				t.Errorf("ByteCount Expected %d, Found %v", test.expectedBytes, res.Custom["byteCount"])
+			}
+			if _, ok := res.Custom["eventCount"]; ok {
+				t.Errorf("EventCount should not be set for non SSE responses")
+			}
+
+			first := res.Custom["firstByteDuration"].(time.Duration)
+			last := res.Custom["lastByteDuration"].(time.Duration)
+			if first <= 0 || last < first+time.Duration(test.expectedChunks-1)*interval {
+				t.Errorf("Unexpected first byte %v and last byte %v durations", first, last)
+			}
+		})
+	}
+}
+
+func TestSendStreamServerSentEvents(t *testing.T) {
+	chunks := []string{
+		"data: first\n\n",
+		": comment\n\n",
+		"event: update\r\ndata: sec",
+		"ond\r\n\r\ndata: third\n",
+		"\n",
+	}
+	server := newStreamingServer("text/event-stream", chunks, time.Duration(10)*time.Millisecond)
+	defer server.Close()
+
+	h := &HttpRequester{}
+	h.Init(context.TODO(), types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodGet,
+		URL:      server.URL,
+		Timeout:  types.DefaultTimeout,
+		Custom:   map[string]interface{}{"stream": true},
+	}, nil, false)
+	res := h.Send(&Iteration{})
+
+	if res.Custom["eventCount"] != int64(3) {
+		t.Errorf("EventCount Expected %d, Found %v", 3, res.Custom["eventCount"])
+	}
+}
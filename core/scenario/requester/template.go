@@ -0,0 +1,197 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.ddosify.com/ddosify/core/scenario/scripting"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// urlTemplate renders the URL of a step with the dynamic variables. The URL is split once in Init, so only the
+// segment that contains the variables is rendered and parsed on each request.
+type urlTemplate struct {
+	// Parsed URL up to the query, set if only the query contains the variables
+	rawBase string
+	base    *url.URL
+	query   *scripting.Template
+
+	// Whole URL, set if the scheme, the host or the path contains the variables
+	full *scripting.Template
+}
+
+// newURLTemplate returns nil if the URL doesn't contain any dynamic variable.
+func newURLTemplate(raw string, vi *scripting.VariableInjector) (*urlTemplate, error) {
+	if !dynamicVariableRe.MatchString(raw) {
+		return nil, nil
+	}
+
+	// Fragment is rare for the targets, the URLs with a fragment are rendered as a whole.
+	base, query, hasQuery := strings.Cut(raw, "?")
+	if hasQuery && !dynamicVariableRe.MatchString(base) && !strings.Contains(query, "#") {
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, err
+		}
+		if err = types.WireURL(u); err != nil {
+			return nil, err
+		}
+		t, err := vi.NewTemplate(query)
+		if err != nil {
+			return nil, err
+		}
+		return &urlTemplate{rawBase: base, base: u, query: t}, nil
+	}
+
+	t, err := vi.NewTemplate(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &urlTemplate{full: t}, nil
+}
+
+func (t *urlTemplate) render(v scripting.IterationValues) (*url.URL, error) {
+	if t.full != nil {
+		u, err := url.Parse(t.full.ExecuteFor(v))
+		if err != nil {
+			return nil, err
+		}
+		return u, types.WireURL(u)
+	}
+
+	// Same as url.Parse of the whole URL, the query is kept as is.
+	// Control characters are rejected by url.Parse, the rendered URL is parsed for its error.
+	q := t.query.ExecuteFor(v)
+	if containsCTLByte(q) {
+		return url.Parse(t.rawBase + "?" + q)
+	}
+	u := *t.base
+	u.RawQuery = types.EncodeQuery(q)
+	u.ForceQuery = q == ""
+	return &u, nil
+}
+
+func containsCTLByte(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if b := s[i]; b < ' ' || b == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// headerTemplate is a header line of a step that contains the dynamic variables in its key or value.
+// Templates are nil for the static parts.
+type headerTemplate struct {
+	key     string
+	value   string
+	index   int  // Index of the value among the values of the key
+	raw     bool // Key is sent as it is written
+	keyTmpl *scripting.Template
+	valTmpl *scripting.Template
+}
+
+func (t headerTemplate) render(header http.Header, iv scripting.IterationValues) {
+	v := t.value
+	// Value is rendered before the key, so a seeded run renders the same values as before.
+	if t.valTmpl != nil {
+		v = t.valTmpl.ExecuteFor(iv)
+	}
+	if t.keyTmpl == nil {
+		header[t.key][t.index] = v
+		return
+	}
+	// All the values of a templated key have a template, each value is sent with its rendered key.
+	if t.index == 0 {
+		delete(header, t.key)
+	}
+	addHeader(header, t.keyTmpl.ExecuteFor(iv), v, t.raw)
+}
+
+// addHeader adds the value of the header as a new header line. Raw keys are not canonicalized.
+func addHeader(header http.Header, key, value string, raw bool) {
+	if raw {
+		header[key] = append(header[key], value)
+		return
+	}
+	header.Add(key, value)
+}
+
+// requestBody is the body of a rendered request, a single allocation instead of a reader with a NopCloser.
+type requestBody struct {
+	strings.Reader
+}
+
+func (b *requestBody) Close() error {
+	return nil
+}
+
+func newRequestBody(body string) *requestBody {
+	b := &requestBody{}
+	b.Reset(body)
+	return b
+}
+
+// bodyGetter returns the GetBody of the requests with the given body, for the redirects and the captured failures.
+func bodyGetter(body string) func() (io.ReadCloser, error) {
+	if body == "" {
+		return func() (io.ReadCloser, error) { return http.NoBody, nil }
+	}
+	return func() (io.ReadCloser, error) { return newRequestBody(body), nil }
+}
+
+// setBody sets the body of the request. getBody is created once for the static bodies.
+func setBody(req *http.Request, body string, getBody func() (io.ReadCloser, error)) {
+	if getBody == nil {
+		getBody = bodyGetter(body)
+	}
+	req.GetBody = getBody
+	req.ContentLength = int64(len(body))
+	if body == "" {
+		req.Body = http.NoBody
+	} else {
+		req.Body = newRequestBody(body)
+	}
+}
+
+// responseBufferPool keeps the buffers of the response bodies read for the captures and the schema validations.
+var responseBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getResponseBuffer() *bytes.Buffer {
+	b := responseBufferPool.Get().(*bytes.Buffer)
+	b.Reset()
+	return b
+}
+
+func putResponseBuffer(b *bytes.Buffer) {
+	// Large bodies are not kept, so a few huge responses don't pin the memory.
+	if b.Cap() <= 1<<20 {
+		responseBufferPool.Put(b)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package requester
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"go.ddosify.com/ddosify/core/warm"
+)
+
+// keptTransport is the transport of a step kept by the warm state, with the counter of the bytes of its connections.
+type keptTransport struct {
+	*http.Transport
+	transferred *byteCounter
+}
+
+// SetWarmState sets the connection state carried across the runs, it should be called before Init.
+func (h *HttpRequester) SetWarmState(s *warm.State) {
+	h.warm = s
+}
+
+// transport returns the transport of the shared client of the step. If the warm state keeps the connections, the
+// transport of the step of the previous run is taken with its idle connections.
+func (h *HttpRequester) transport(tlsConfig *tls.Config) *http.Transport {
+	key := h.warmTransportKey()
+	if key == "" {
+		return h.initTransport(tlsConfig)
+	}
+	t, reused := h.warm.Transport(key, func() warm.Transport {
+		return keptTransport{Transport: h.initTransport(tlsConfig), transferred: h.transferred}
+	})
+	kt := t.(keptTransport)
+	if reused {
+		// Bytes of the idle connections closed after the previous run are not a part of this run.
+		h.transferred = kt.transferred
+		h.transferred.claim()
+	}
+	h.keptTransport = true
+	return kt.Transport
+}
+
+// warmTransportKey returns the key of the transport of the step in the warm state, empty if the transport is not
+// kept. Steps are keyed by their ids, proxies and connection options, so a step of the next run takes the connections
+// only if they are dialed the same way. Prewarmed, shaped and non keep-alive connections are not kept.
+func (h *HttpRequester) warmTransportKey() string {
+	if h.warm == nil || !h.warm.KeepsConnections() || h.prewarm > 0 || h.shaper != nil {
+		return ""
+	}
+	if keepAlive, ok := h.packet.Custom["keep-alive"].(bool); ok && !keepAlive {
+		return ""
+	}
+
+	proxy := ""
+	if h.proxyAddr != nil {
+		proxy = h.proxyAddr.String()
+	}
+	var cert [sha256.Size]byte
+	if h.packet.CertPool != nil && h.packet.Cert.Certificate != nil {
+		cert = sha256.Sum256(bytes.Join(h.packet.Cert.Certificate, nil))
+	}
+	disableCompression, _ := h.packet.Custom["disable-compression"].(bool)
+	h2, _ := h.packet.Custom["h2"].(bool)
+	hostname, _ := h.packet.Custom["hostname"].(string)
+	return fmt.Sprintf("%d|%s|%s|%s|%t|%t|%t|%t|%x", h.packet.ID, proxy, h.unixSocket, hostname, disableCompression,
+		h2, h.packet.ExpectContinue, h.sessionCache != nil, cert)
+}
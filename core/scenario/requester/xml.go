@@ -0,0 +1,131 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"errors"
+	"fmt"
+
+	"go.ddosify.com/ddosify/core/scenario/xpath"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+var errInvalidXML = errors.New(xpath.ErrInvalidXML)
+
+// xmlBody parses the response body once for the xpath captures and assertions of a request.
+type xmlBody struct {
+	body   []byte
+	doc    *xpath.Node
+	err    error
+	parsed bool
+}
+
+func newXMLBody(body []byte) *xmlBody {
+	return &xmlBody{body: body}
+}
+
+// root returns the document of the body, errInvalidXML if the body is not a valid XML.
+func (b *xmlBody) root() (*xpath.Node, error) {
+	if !b.parsed {
+		b.parsed = true
+		if b.doc, b.err = xpath.Parse(b.body); b.err != nil {
+			b.err = errInvalidXML
+		}
+	}
+	return b.doc, b.err
+}
+
+// xmlAssertion checks the XML response bodies of a step against its xpath-assertions, each of them should be true.
+type xmlAssertion struct {
+	exprs []*xpath.Expr
+}
+
+// newXMLAssertion compiles the assertions of the step once. Returns nil if the step has no xpath-assertions.
+// Keys are already validated in types.scenario.validate().
+func newXMLAssertion(custom map[string]interface{}, namespaces map[string]string) (*xmlAssertion, error) {
+	val, ok := custom["xpath-assertions"]
+	if !ok {
+		return nil, nil
+	}
+
+	list, err := types.ParseXPathAssertions(val)
+	if err != nil {
+		return nil, err
+	}
+	a := &xmlAssertion{exprs: make([]*xpath.Expr, len(list))}
+	for i, expr := range list {
+		if a.exprs[i], err = xpath.Compile(expr, namespaces); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// check evaluates the assertions in order, the first false one is returned as an assertion error.
+func (a *xmlAssertion) check(body *xmlBody) (types.RequestError, bool) {
+	doc, err := body.root()
+	if err != nil {
+		return types.RequestError{Type: types.ErrorAssertion, Reason: "xpath assertion: " + err.Error()}, false
+	}
+	for _, x := range a.exprs {
+		if !x.Bool(doc) {
+			return types.RequestError{
+				Type:   types.ErrorAssertion,
+				Reason: fmt.Sprintf("xpath assertion failed: %s", x),
+			}, false
+		}
+	}
+	return types.RequestError{}, true
+}
+
+// xpathMatches returns the values matched by the xpaths of the step, keyed by the expressions, for the debug mode.
+// Returns nil if the step has no xpath or the body is not a valid XML.
+func (h *HttpRequester) xpathMatches(body *xmlBody) map[string][]string {
+	var exprs []*xpath.Expr
+	if h.xmlAssertion != nil {
+		exprs = append(exprs, h.xmlAssertion.exprs...)
+	}
+	for _, c := range h.captures {
+		if c.xpath != nil {
+			exprs = append(exprs, c.xpath)
+		}
+	}
+	if h.fileCapture != nil {
+		for _, src := range h.fileCapture.sources {
+			if src.xpath != nil {
+				exprs = append(exprs, src.xpath)
+			}
+		}
+	}
+	if len(exprs) == 0 {
+		return nil
+	}
+
+	doc, err := body.root()
+	if err != nil {
+		return nil
+	}
+	matches := make(map[string][]string, len(exprs))
+	for _, x := range exprs {
+		matches[x.String()] = x.Evaluate(doc)
+	}
+	return matches
+}
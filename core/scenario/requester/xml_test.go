@@ -0,0 +1,185 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package requester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+const soapOrderResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ord="urn:orders">
+  <soap:Body>
+    <ord:CreateOrderResponse>
+      <ord:OrderId>o-42</ord:OrderId>
+      <ord:Status>CREATED</ord:Status>
+    </ord:CreateOrderResponse>
+  </soap:Body>
+</soap:Envelope>`
+
+const soapFaultResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <soap:Fault><faultcode>soap:Server</faultcode><faultstring>out of stock</faultstring></soap:Fault>
+  </soap:Body>
+</soap:Envelope>`
+
+func newSOAPStep(url string, custom map[string]interface{}) types.ScenarioStep {
+	return types.ScenarioStep{
+		ID:       1,
+		Protocol: types.ProtocolHTTP,
+		Method:   http.MethodPost,
+		URL:      url,
+		Timeout:  types.DefaultTimeout,
+		Headers:  map[string]string{"Content-Type": "text/xml; charset=utf-8"},
+		Payload:  `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body/></soap:Envelope>`,
+		Custom:   custom,
+	}
+}
+
+func TestSendXPathAssertions(t *testing.T) {
+	var reqCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		switch atomic.AddInt32(&reqCount, 1) {
+		case 1:
+			fmt.Fprint(w, soapOrderResponse)
+		case 2:
+			fmt.Fprint(w, soapFaultResponse)
+		default:
+			fmt.Fprint(w, `{"order": "o-42"}`)
+		}
+	}))
+	defer server.Close()
+
+	s := newSOAPStep(server.URL, map[string]interface{}{
+		"xml-namespaces":   map[string]interface{}{"s": "http://schemas.xmlsoap.org/soap/envelope/"},
+		"xpath-assertions": []interface{}{"count(//s:Fault) = 0", "//Status = 'CREATED'"},
+	})
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	expected := []types.RequestError{
+		{},
+		{Type: types.ErrorAssertion, Reason: "xpath assertion failed: count(//s:Fault) = 0"},
+		{Type: types.ErrorAssertion, Reason: "xpath assertion: response body is not a valid xml"},
+	}
+	for i, e := range expected {
+		if res := h.Send(&Iteration{}); res.Err != e {
+			t.Errorf("%d. Expected %#v, Found %#v", i, e, res.Err)
+		}
+	}
+}
+
+func TestSendXPathCaptures(t *testing.T) {
+	var reqCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&reqCount, 1) == 1 {
+			fmt.Fprint(w, soapOrderResponse)
+			return
+		}
+		fmt.Fprint(w, `<html><body>`)
+	}))
+	defer server.Close()
+
+	s := newSOAPStep(server.URL, map[string]interface{}{
+		"xml-namespaces": map[string]interface{}{"o": "urn:orders"},
+		"capture": map[string]interface{}{
+			"order_id": map[string]interface{}{"xpath": "//o:OrderId/text()"},
+			"status":   map[string]interface{}{"xpath": "string(//Status)"},
+			"missing":  map[string]interface{}{"xpath": "//o:Invoice"},
+		},
+	})
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, false); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	it := &Iteration{}
+	res := h.Send(it)
+	expected := map[string]string{"order_id": "o-42", "status": "CREATED"}
+	if res.Err.Type != "" || !reflect.DeepEqual(it.Captures, expected) {
+		t.Errorf("Captures Expected %v, Found %v, err: %v", expected, it.Captures, res.Err)
+	}
+
+	it = &Iteration{}
+	res = h.Send(it)
+	expectedErr := types.RequestError{Type: types.ErrorAssertion, Reason: "xpath capture: response body is not a valid xml"}
+	if res.Err != expectedErr {
+		t.Errorf("Expected %#v, Found %#v", expectedErr, res.Err)
+	}
+	if it.Captures != nil {
+		t.Errorf("Invalid xml should not capture, Found %v", it.Captures)
+	}
+}
+
+func TestSendXPathMatchesOnDebugMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, soapOrderResponse)
+	}))
+	defer server.Close()
+
+	s := newSOAPStep(server.URL, map[string]interface{}{
+		"xpath-assertions": []interface{}{"count(//Fault) = 0"},
+		"capture": map[string]interface{}{
+			"order_id": map[string]interface{}{"xpath": "//OrderId"},
+		},
+	})
+	h := &HttpRequester{}
+	if err := h.Init(context.TODO(), s, nil, true); err != nil {
+		t.Fatalf("Init errored: %v", err)
+	}
+
+	res := h.Send(&Iteration{})
+	expected := map[string][]string{"count(//Fault) = 0": {"true"}, "//OrderId": {"o-42"}}
+	if !reflect.DeepEqual(res.DebugInfo["xpathMatches"], expected) {
+		t.Errorf("Expected %v, Found %v", expected, res.DebugInfo["xpathMatches"])
+	}
+}
+
+func TestInitInvalidXPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		custom map[string]interface{}
+	}{
+		{"UndeclaredPrefix", map[string]interface{}{"xpath-assertions": []interface{}{"//s:Fault"}}},
+		{"InvalidNamespaces", map[string]interface{}{"xml-namespaces": "soap"}},
+		{"InvalidCapture", map[string]interface{}{
+			"capture": map[string]interface{}{"id": map[string]interface{}{"xpath": "//OrderId["}},
+		}},
+	}
+
+	for _, test := range tests {
+		h := &HttpRequester{}
+		if err := h.Init(context.TODO(), newSOAPStep("http://localhost", test.custom), nil, false); err == nil {
+			t.Errorf("%s: Init should fail", test.name)
+		}
+	}
+}
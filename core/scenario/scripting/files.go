@@ -0,0 +1,41 @@
+package scripting
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var fileTemplateRegex = regexp.MustCompile(`\{\{\s*file\s+"([^"]+)"\s*\}\}`)
+
+// FileInjector replaces the {{file "path"}} templates with the contents of the files, like the values written by
+// the capture-to-file rules of a previous run. Each file is read once, a trailing newline is trimmed.
+type FileInjector struct {
+	contents map[string]string
+}
+
+func (fi *FileInjector) Inject(text string) (string, error) {
+	if fi.contents == nil {
+		fi.contents = make(map[string]string)
+	}
+
+	var err error
+	injected := fileTemplateRegex.ReplaceAllStringFunc(text, func(tmpl string) string {
+		path := fileTemplateRegex.FindStringSubmatch(tmpl)[1]
+		content, ok := fi.contents[path]
+		if !ok {
+			b, rErr := ioutil.ReadFile(path)
+			if rErr != nil {
+				if err == nil {
+					err = fmt.Errorf("file template couldn't be read: %v", rErr)
+				}
+				return tmpl
+			}
+			content = strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r")
+			fi.contents[path] = content
+		}
+		return content
+	})
+	return injected, err
+}
@@ -0,0 +1,100 @@
+package scripting
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	jfaker "github.com/jaswdr/faker"
+)
+
+// seededFakers returns the dynamic variables whose go-faker implementations don't use the generator of the faker,
+// but seed their own generators by the current time. These are reimplemented by the given rnd.
+func seededFakers(rnd *rand.Rand) map[string]interface{} {
+	// rnd is also a rand.Source, so jaswdr/faker draws from the same stream.
+	jf := jfaker.NewWithSeed(rnd)
+
+	return map[string]interface{}{
+		"guid":       func() uuid.UUID { return randomUUID(rnd) },
+		"randomUUID": func() uuid.UUID { return randomUUID(rnd) },
+
+		"randomAlphaNumeric": func() string { return randomChars(rnd, "abcdefghijklmnopqrstuvwxyz0123456789", 1) },
+		"randomBoolean":      func() bool { return jf.Bool() },
+		"randomInt":          func() int { return jf.IntBetween(0, 1000) },
+		"randomColor":        func() string { return jf.Color().SafeColorName() },
+		"randomHexColor":     func() string { return jf.Color().Hex() },
+
+		"randomIP":         func() string { return jf.Internet().Ipv4() },
+		"randomIPV6":       func() string { return randomIPv6(rnd) },
+		"randomMACAddress": func() string { return jf.Internet().MacAddress() },
+		"randomPassword":   func() string { return jf.Internet().Password() },
+		"randomLocale":     func() string { return jf.Language().LanguageAbbr() },
+		"randomUserAgent":  func() string { return jf.UserAgent().UserAgent() },
+
+		"randomFirstName":  func() string { return jf.Person().FirstName() },
+		"randomLastName":   func() string { return jf.Person().LastName() },
+		"randomFullName":   func() string { return jf.Person().Name() },
+		"randomNamePrefix": func() string { return jf.Person().Title() },
+		"randomNameSuffix": func() string { return jf.Person().Suffix() },
+
+		"randomCity":          func() string { return jf.Address().City() },
+		"randomStreetName":    func() string { return jf.Address().StreetName() },
+		"randomStreetAddress": func() string { return jf.Address().StreetAddress() },
+		"randomCountry":       func() string { return jf.Address().Country() },
+		"randomLatitude":      func() float64 { return jf.Address().Latitude() },
+		"randomLongitude":     func() float64 { return jf.Address().Longitude() },
+
+		"randomBitcoin": func() string {
+			return randomChars(rnd, "123456789abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ", rnd.Intn(35-26)+26)
+		},
+
+		// Same ranges with the go-faker implementations
+		"randomDateFuture": func() string {
+			now := time.Now().Unix()
+			return randomDate(rnd, now, now*10/9)
+		},
+		"randomDatePast": func() string {
+			now := time.Now().Unix()
+			return randomDate(rnd, now-now*2/10, now)
+		},
+		"randomDateRecent": func() string {
+			now := time.Now().Unix()
+			return randomDate(rnd, now-now/200, now)
+		},
+	}
+}
+
+// randomUUID returns a version 4 UUID. Not using rnd.Read since it is not safe for concurrent use.
+func randomUUID(rnd *rand.Rand) uuid.UUID {
+	var u uuid.UUID
+	for i := 0; i < len(u); i += 8 {
+		v := rnd.Uint64()
+		for j := 0; j < 8; j++ {
+			u[i+j] = byte(v >> (8 * j))
+		}
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // Version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // Variant is 10
+	return u
+}
+
+func randomChars(rnd *rand.Rand, letters string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[rnd.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+func randomIPv6(rnd *rand.Rand) string {
+	blocks := make([]string, 8)
+	for i := range blocks {
+		blocks[i] = randomChars(rnd, "abcdef0123456789", 4)
+	}
+	return strings.Join(blocks, ":")
+}
+
+func randomDate(rnd *rand.Rand, min, max int64) string {
+	return time.Unix(rnd.Int63n(max-min)+min, 0).Format(time.UnixDate)
+}
@@ -3,8 +3,10 @@ package scripting
 import (
 	"fmt"
 	"io"
+	"math/rand"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/ddosify/go-faker/faker"
 	"github.com/google/uuid"
@@ -16,8 +18,10 @@ type VariableInjector struct {
 	fakerMap map[string]interface{}
 }
 
-func (vi *VariableInjector) Init() {
-	vi.faker = faker.NewFaker()
+// Init creates the dynamic variables. All the random values are generated by the given rnd, so the values are
+// reproducible by seeding it.
+func (vi *VariableInjector) Init(rnd *rand.Rand) {
+	vi.faker = faker.Faker{Generator: rnd}
 	vi.fakerMap = map[string]interface{}{
 		/*
 		* Postman equivalents: https://learning.postman.com/docs/writing-scripts/script-references/variables-list
@@ -182,6 +186,69 @@ func (vi *VariableInjector) Init() {
 		"randomString": vi.faker.RandomString,
 	}
 
+	for tag, f := range seededFakers(rnd) {
+		vi.fakerMap[tag] = f
+	}
+}
+
+// IterationValues are the values of the iteration variables of a request, {{_iterationId}}, {{_vuId}} and
+// {{_stepId}}. They are rendered from the request instead of the faker, the zero values without an iteration,
+// like in the preview mode.
+type IterationValues struct {
+	IterationID uint64
+	VUID        uint64
+	StepID      uint16
+}
+
+// lookup returns the value of the iteration variable, false if the tag is not an iteration variable.
+func (v IterationValues) lookup(tag string) (uint64, bool) {
+	switch tag {
+	case "iterationId":
+		return v.IterationID, true
+	case "vuId":
+		return v.VUID, true
+	case "stepId":
+		return uint64(v.StepID), true
+	}
+	return 0, false
+}
+
+func (v IterationValues) render(w io.Writer, tag string) (int, bool) {
+	val, ok := v.lookup(tag)
+	if !ok {
+		return 0, false
+	}
+	n, _ := io.WriteString(w, strconv.FormatUint(val, 10))
+	return n, true
+}
+
+// IterationFuncs returns the iteration variables of a request as the functions of a text/template, named like
+// "_iterationId".
+func IterationFuncs(v IterationValues) map[string]interface{} {
+	funcs := make(map[string]interface{}, 3)
+	for _, tag := range iterationTags {
+		val, _ := v.lookup(tag)
+		s := strconv.FormatUint(val, 10)
+		funcs["_"+tag] = func() string { return s }
+	}
+	return funcs
+}
+
+var iterationTags = []string{"iterationId", "vuId", "stepId"}
+
+// HasIterationVariable reports whether the text refers to an iteration variable.
+func HasIterationVariable(text string) bool {
+	for _, tag := range iterationTags {
+		if strings.Contains(text, "_"+tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIterationTag(tag string) bool {
+	_, ok := IterationValues{}.lookup(tag)
+	return ok
 }
 
 func (vi *VariableInjector) Inject(text string) (string, error) {
@@ -195,30 +262,108 @@ func (vi *VariableInjector) fakeDataInjector(text string) (string, error) {
 	}
 
 	parsed := template.ExecuteFuncString(func(w io.Writer, tag string) (int, error) {
+		if n, ok := (IterationValues{}).render(w, tag); ok {
+			return n, nil
+		}
 		if _, ok := vi.fakerMap[tag]; !ok {
 			err = fmt.Errorf("%s is not a valid dynamic variable", tag)
 			return 0, nil
 		}
+		return vi.render(w, tag)
+	})
+	return parsed, err
+}
+
+func (vi *VariableInjector) render(w io.Writer, tag string) (int, error) {
+	f, ok := vi.fakerMap[tag]
+	if !ok {
+		return 0, nil
+	}
+	res := reflect.ValueOf(f).Call(nil)[0].Interface()
 
-		res := reflect.ValueOf(vi.fakerMap[tag]).Call(nil)[0].Interface()
-
-		var p string
-		switch res.(type) {
-		case int:
-			p = strconv.Itoa(res.(int))
-		case int64:
-			p = strconv.FormatInt(res.(int64), 10)
-		case float64:
-			p = fmt.Sprintf("%f", res.(float64))
-		case uuid.UUID:
-			p = res.(uuid.UUID).String()
-		case bool:
-			p = strconv.FormatBool(res.(bool))
-		default:
-			p = res.(string)
+	var p string
+	switch res.(type) {
+	case int:
+		p = strconv.Itoa(res.(int))
+	case int64:
+		p = strconv.FormatInt(res.(int64), 10)
+	case float64:
+		p = fmt.Sprintf("%f", res.(float64))
+	case uuid.UUID:
+		p = res.(uuid.UUID).String()
+	case bool:
+		p = strconv.FormatBool(res.(bool))
+	default:
+		p = res.(string)
+	}
+	return io.WriteString(w, p)
+}
+
+// Template is a text whose dynamic variables are parsed once, for the texts rendered on each request.
+type Template struct {
+	vi *VariableInjector
+	t  *fasttemplate.Template
+
+	// Text refers to an iteration variable
+	iteration bool
+}
+
+// NewTemplate parses the dynamic variables of the text. The variables are validated without rendering them,
+// so the random values of the injector are not consumed.
+func (vi *VariableInjector) NewTemplate(text string) (*Template, error) {
+	t, err := fasttemplate.NewTemplate(text, "{{_", "}}")
+	if err != nil {
+		return nil, err
+	}
+
+	var iteration bool
+	_, err = t.ExecuteFunc(io.Discard, func(w io.Writer, tag string) (int, error) {
+		if isIterationTag(tag) {
+			iteration = true
+			return 0, nil
 		}
-		return w.Write([]byte(p))
+		if _, ok := vi.fakerMap[tag]; !ok {
+			return 0, fmt.Errorf("%s is not a valid dynamic variable", tag)
+		}
+		return 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Template{vi: vi, t: t, iteration: iteration}, nil
+}
 
+// Execute renders the text with new values of the dynamic variables, the iteration variables are rendered as zero.
+func (t *Template) Execute() string {
+	return t.ExecuteFor(IterationValues{})
+}
+
+// ExecuteFor renders the text with new values of the dynamic variables and the given values of the iteration
+// variables.
+func (t *Template) ExecuteFor(v IterationValues) string {
+	if !t.iteration {
+		return t.t.ExecuteFuncString(t.vi.render)
+	}
+	return t.t.ExecuteFuncString(func(w io.Writer, tag string) (int, error) {
+		if n, ok := v.render(w, tag); ok {
+			return n, nil
+		}
+		return t.vi.render(w, tag)
 	})
-	return parsed, err
+}
+
+// Funcs returns the dynamic variables as the functions of a text/template, named like "_randomInt", so the
+// templates of the composed bodies render them with {{_randomInt}} like the other texts. The iteration variables are
+// rendered as zero, IterationFuncs overrides them for a request.
+func (vi *VariableInjector) Funcs() map[string]interface{} {
+	funcs := IterationFuncs(IterationValues{})
+	for tag := range vi.fakerMap {
+		tag := tag
+		funcs["_"+tag] = func() string {
+			var b strings.Builder
+			vi.render(&b, tag)
+			return b.String()
+		}
+	}
+	return funcs
 }
@@ -22,15 +22,22 @@ package scenario
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"math/rand"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.ddosify.com/ddosify/core/clock"
 	"go.ddosify.com/ddosify/core/scenario/requester"
 	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+	"go.ddosify.com/ddosify/core/warm"
 )
 
 // ScenarioService encapsulates proxy/scenario/requester information and runs the scenario.
@@ -43,8 +50,55 @@ type ScenarioService struct {
 	scenario types.Scenario
 	ctx      context.Context
 
+	// Clock of the sleeps and the backoffs of the steps, the real clock if it is not set
+	clock clock.Clock
+
 	clientMutex sync.Mutex
 	debug       bool
+
+	// Nil if the request capture is disabled
+	capturer *capturer
+
+	// Source of the variant assignments of the iterations and the share of the b variant, nil if no step has
+	// ab-variants
+	variantRand *rand.Rand
+	abSplit     float64
+
+	// Count of the started iterations, the ID of the next iteration
+	iterations uint64
+
+	// Virtual users running the iterations. An iteration is run by an idle user, a new user is created if all of them
+	// are busy, so the count of the users follows the concurrency of the test. Users are kept only if they have the
+	// connections of the user connection scope, to close them.
+	users     []*virtualUser
+	idleUsers []*virtualUser
+	usersMu   sync.Mutex
+
+	// Count of the created virtual users, the ID of the next user
+	vus uint64
+
+	// Virtual users of the vu load type by their indexes, each one runs the iterations of its index. Guarded by
+	// usersMu.
+	pinnedUsers map[int]*virtualUser
+
+	// Set if a step has conditional_requests, the virtual users keep the validators of their responses
+	conditional bool
+
+	// Values of the collected captures, nil if no capture is collected
+	collector *requester.Collector
+
+	// Timeouts of the steps with an adaptive-timeout, set while the test is running and applied to the requesters
+	// created after. Guarded by clientMutex.
+	timeouts map[uint16]time.Duration
+
+	// Requesters of the teardown steps, sent over the first proxy after the test
+	teardown       []scenarioItemRequester
+	teardownCtx    context.Context
+	teardownCancel context.CancelFunc
+
+	// Connection state carried across the runs, set to the requesters of the steps. nil means each requester starts
+	// with its own empty state.
+	warm *warm.State
 }
 
 // NewScenarioService is the constructor of the ScenarioService.
@@ -52,12 +106,47 @@ func NewScenarioService() *ScenarioService {
 	return &ScenarioService{}
 }
 
+// SetClock sets the clock of the sleeps and the backoffs of the steps, it should be called before Init.
+func (s *ScenarioService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetWarmState sets the connection state carried across the runs of the process, it should be called before Init.
+// The teardown steps don't take the state.
+func (s *ScenarioService) SetWarmState(ws *warm.State) {
+	s.warm = ws
+}
+
+// timeSource returns the clock of the service, the real clock if SetClock is not called.
+func (s *ScenarioService) timeSource() clock.Clock {
+	if s.clock == nil {
+		return clock.Real()
+	}
+	return s.clock
+}
+
 // Init initializes the ScenarioService.clients with the given types.Scenario and proxies.
 // Passes the given ctx to the underlying requestor so we are able to control the life of each request.
 func (s *ScenarioService) Init(ctx context.Context, scenario types.Scenario, proxies []*url.URL, debug bool) (err error) {
 	s.scenario = scenario
 	s.ctx = ctx
 	s.debug = debug
+	if scenario.Capture.Enabled() && !debug {
+		s.capturer, err = newCapturer(scenario.Capture, util.NewRand(util.SubSeed(scenario.Seed, "capture")))
+		if err != nil {
+			return
+		}
+	}
+	for _, st := range scenario.Steps {
+		s.conditional = s.conditional || st.ConditionalRequests
+	}
+	if scenario.HasABVariants() {
+		s.variantRand = util.NewRand(util.SubSeed(scenario.Seed, "ab"))
+		s.abSplit = scenario.ABSplit
+		if s.abSplit == 0 {
+			s.abSplit = types.DefaultABSplit
+		}
+	}
 	s.clients = make(map[*url.URL][]scenarioItemRequester, len(proxies))
 	for _, p := range proxies {
 		err = s.createRequesters(p)
@@ -65,38 +154,208 @@ func (s *ScenarioService) Init(ctx context.Context, scenario types.Scenario, pro
 			return
 		}
 	}
-	return
+
+	var proxy *url.URL
+	if len(proxies) > 0 {
+		proxy = proxies[0]
+	}
+	return s.initTeardown(proxy)
 }
 
 // Do executes the scenario for the given proxy.
 // Returns "types.Response" filled by the requester of the given Proxy, injects the given startTime to the response
 // Returns error only if types.Response.Err.Type is types.ErrorProxy or types.ErrorIntented
 func (s *ScenarioService) Do(proxy *url.URL, startTime time.Time) (
+	response *types.ScenarioResult, err *types.RequestError) {
+	return s.do(proxy, startTime, nil)
+}
+
+// DoUser executes the scenario like Do as an iteration of a virtual user of the vu load type. The iterations of a user
+// index are run by the same virtual user, its ID is the user index.
+func (s *ScenarioService) DoUser(proxy *url.URL, startTime time.Time, ui types.UserIteration) (
+	response *types.ScenarioResult, err *types.RequestError) {
+	return s.do(proxy, startTime, &ui)
+}
+
+func (s *ScenarioService) do(proxy *url.URL, startTime time.Time, ui *types.UserIteration) (
 	response *types.ScenarioResult, err *types.RequestError) {
 	response = &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{}}
 	response.StartTime = startTime
 	response.ProxyAddr = proxy
+	c := s.timeSource()
+	begin := c.Now()
 
 	requesters, e := s.getOrCreateRequesters(proxy)
 	if e != nil {
 		return nil, &types.RequestError{Type: types.ErrorUnkown, Reason: e.Error()}
 	}
 
-	for _, sr := range requesters {
-		res := sr.requester.Send()
-		if res.Err.Type == types.ErrorProxy || res.Err.Type == types.ErrorIntented {
-			err = &res.Err
-			if res.Err.Type == types.ErrorIntented {
-				// Stop the loop. ErrorProxy can be fixed in time. But ErrorIntented is a signal to stop all.
-				return
+	// Sampled before the requests are built, so the unsampled iterations skip the capture overhead.
+	sampled := s.capturer != nil && s.capturer.sample()
+	if s.capturer != nil {
+		defer func() {
+			s.capturer.capture(response, sampled)
+		}()
+	}
+	defer func() {
+		response.Duration = c.Since(begin)
+	}()
+
+	// State of the iteration, like the values captured by the steps so far for the sleep templates and
+	// the composed bodies. It is created for each iteration, so the concurrent iterations never share it.
+	it := requester.NewIteration(atomic.AddUint64(&s.iterations, 1)-1, s.scenario.Seed)
+	it.Collector = s.collector
+	var u *virtualUser
+	if ui != nil {
+		u = s.pinnedUser(ui.User)
+	} else {
+		u = s.acquireUser()
+		defer s.releaseUser(u)
+	}
+	it.VUID, it.Validators, it.User = u.id, u.validators, ui
+	// Variant is assigned once per iteration, so the steps of a flow run on the same variant
+	if s.variantRand != nil {
+		it.Variant = types.VariantA
+		if s.variantRand.Float64() < s.abSplit {
+			it.Variant = types.VariantB
+		}
+	}
+	switch s.scenario.ConnectionScope {
+	case types.ConnectionScopeIteration:
+		it.Connections = requester.NewConnections()
+		defer it.Connections.Close()
+	case types.ConnectionScopeUser:
+		it.Connections = u.connections
+	}
+
+	// The consecutive steps of a parallel group are sent together
+	for i := 0; i < len(requesters); {
+		end := i + 1
+		if name := requesters[i].parallelGroup; name != "" {
+			for end < len(requesters) && requesters[end].parallelGroup == name {
+				end++
 			}
 		}
-		response.StepResults = append(response.StepResults, res)
+		group := requesters[i:end]
+		results := s.send(group, it, sampled)
+		i = end
+
+		for j, res := range results {
+			res.ProxyAddr = group[j].proxyOf(proxy)
+			res.IterationID, res.VUID, res.Variant = it.ID, it.VUID, it.Variant
+			// Proxy errors of the steps with their own proxy are not the errors of the proxy of the iteration,
+			// so the proxy service doesn't replace it for them
+			if res.Err.Type == types.ErrorProxy && group[j].ownProxy {
+				response.StepResults = append(response.StepResults, res)
+				continue
+			}
+			if res.Err.Type == types.ErrorProxy || res.Err.Type == types.ErrorIntented {
+				err = &res.Err
+				if res.Err.Type == types.ErrorIntented {
+					// Stop the loop. ErrorProxy can be fixed in time. But ErrorIntented is a signal to stop all.
+					return
+				}
+			}
+			response.StepResults = append(response.StepResults, res)
+		}
+
+		// Honor the backoff advertised by the rate limited target before running the next step, the longest one
+		// of a parallel group
+		var backoff time.Duration
+		waiter := -1
+		for j, sr := range group {
+			if d, ok := results[j].Custom["retryAfter"].(time.Duration); ok && sr.retryAfterSleep && d >= backoff {
+				backoff, waiter = d, j
+			}
+		}
+		waitStart := c.Now()
+		if waiter >= 0 && i < len(requesters) {
+			s.backoff(backoff)
+		} else {
+			waiter = -1
+		}
+
+		// Sleep before running the next step. A parallel group has at most one sleep, it runs after the slowest step.
+		for j, sr := range group {
+			if sr.sleeper != nil && len(s.scenario.Steps) > 1 {
+				waiter = j
+				if clamped := sr.sleeper.sleep(c, it.Captures); clamped {
+					setCustom(results[j], "sleepClamped", true)
+				}
+			}
+		}
+		// Waits after the group, its backoff and its sleep, are the sleep time of the step waiting for them
+		if waiter >= 0 {
+			setCustom(results[waiter], "sleepTime", c.Since(waitStart))
+		}
+	}
+	return
+}
+
+func setCustom(res *types.ScenarioStepResult, key string, val interface{}) {
+	if res.Custom == nil {
+		res.Custom = make(map[string]interface{})
+	}
+	res.Custom[key] = val
+}
+
+// send sends the requests of the steps of a group in the iteration and returns their results in the order of
+// the steps. The steps of a parallel group are sent concurrently, each with a fork of the iteration, and their
+// captures are visible to the next steps once all of them are completed.
+func (s *ScenarioService) send(group []scenarioItemRequester, it *requester.Iteration,
+	sampled bool) []*types.ScenarioStepResult {
+	results := make([]*types.ScenarioStepResult, len(group))
+	if len(group) == 1 {
+		results[0] = sendStep(group[0], it, sampled)
+		return results
+	}
+
+	forks := make([]*requester.Iteration, len(group))
+	var wg sync.WaitGroup
+	for i, sr := range group {
+		forks[i] = it.Fork()
+		wg.Add(1)
+		go func(i int, sr scenarioItemRequester) {
+			defer wg.Done()
+			res := sendStep(sr, forks[i], sampled)
+			if res.Custom == nil {
+				res.Custom = make(map[string]interface{})
+			}
+			res.Custom["parallelGroup"] = sr.parallelGroup
+			results[i] = res
+		}(i, sr)
+	}
+	wg.Wait()
+	it.Join(forks)
+	return results
+}
 
-		// Sleep before running the next step
-		if sr.sleeper != nil && len(s.scenario.Steps) > 1 {
-			sr.sleeper.sleep()
+func sendStep(sr scenarioItemRequester, it *requester.Iteration, sampled bool) *types.ScenarioStepResult {
+	if c, ok := sr.requester.(requester.Capturer); ok && sampled {
+		return c.SendCapture(it)
+	}
+	return sr.requester.Send(it)
+}
+
+// Preview renders the requests of the scenario for the given proxy without sending them.
+// Returns error if the requester of a step doesn't implement requester.Previewer.
+func (s *ScenarioService) Preview(proxy *url.URL) (response *types.ScenarioResult, err error) {
+	requesters, err := s.getOrCreateRequesters(proxy)
+	if err != nil {
+		return
+	}
+
+	response = &types.ScenarioResult{StepResults: []*types.ScenarioStepResult{}}
+	response.StartTime = s.timeSource().Now()
+	response.ProxyAddr = proxy
+	for _, sr := range requesters {
+		p, ok := sr.requester.(requester.Previewer)
+		if !ok {
+			return nil, fmt.Errorf("preview is not supported for step: %d", sr.scenarioItemID)
 		}
+		res := p.Preview()
+		res.ProxyAddr = sr.proxyOf(proxy)
+		response.StepResults = append(response.StepResults, res)
 	}
 	return
 }
@@ -107,6 +366,185 @@ func (s *ScenarioService) Done() {
 			r.requester.Done()
 		}
 	}
+
+	for _, r := range s.teardown {
+		r.requester.Done()
+	}
+	if s.teardownCancel != nil {
+		s.teardownCancel()
+	}
+	if s.collector != nil {
+		s.collector.Close()
+	}
+
+	s.usersMu.Lock()
+	for _, u := range s.users {
+		u.connections.Close()
+	}
+	s.users, s.idleUsers, s.pinnedUsers = nil, nil, nil
+	s.usersMu.Unlock()
+
+	if s.capturer != nil {
+		s.capturer.close()
+	}
+}
+
+// virtualUser runs the iterations one at a time, its ID is the {{_vuId}} of its iterations.
+type virtualUser struct {
+	id uint64
+
+	// Connections of the user connection scope, nil for the other scopes
+	connections *requester.Connections
+
+	// Cache validators of the steps with conditional_requests, nil if no step has it
+	validators *requester.Validators
+}
+
+// acquireUser returns an idle virtual user, or a new one if all the users are busy.
+func (s *ScenarioService) acquireUser() *virtualUser {
+	s.usersMu.Lock()
+	if n := len(s.idleUsers); n > 0 {
+		u := s.idleUsers[n-1]
+		s.idleUsers = s.idleUsers[:n-1]
+		s.usersMu.Unlock()
+		return u
+	}
+	s.usersMu.Unlock()
+	return s.newUser(atomic.AddUint64(&s.vus, 1) - 1)
+}
+
+// pinnedUser returns the virtual user of a user index of the vu load type, created on its first iteration.
+func (s *ScenarioService) pinnedUser(index int) *virtualUser {
+	s.usersMu.Lock()
+	u, ok := s.pinnedUsers[index]
+	s.usersMu.Unlock()
+	if ok {
+		return u
+	}
+
+	u = s.newUser(uint64(index))
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	if s.pinnedUsers == nil {
+		s.pinnedUsers = make(map[int]*virtualUser)
+	}
+	s.pinnedUsers[index] = u
+	return u
+}
+
+// newUser returns a new virtual user with the given ID, kept in the users if it has the connections of the user
+// connection scope.
+func (s *ScenarioService) newUser(id uint64) *virtualUser {
+	u := &virtualUser{id: id}
+	if s.conditional {
+		u.validators = requester.NewValidators()
+	}
+	if s.scenario.ConnectionScope == types.ConnectionScopeUser {
+		u.connections = requester.NewConnections()
+		s.usersMu.Lock()
+		s.users = append(s.users, u)
+		s.usersMu.Unlock()
+	}
+	return u
+}
+
+// releaseUser makes the virtual user idle once its iteration ends, its connections are kept for its next iteration.
+func (s *ScenarioService) releaseUser(u *virtualUser) {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	s.idleUsers = append(s.idleUsers, u)
+}
+
+// Prewarm establishes the connections of the steps with the prewarm-connections before the test, for the requesters
+// created in Init. Returns the results of the steps that have a prewarm, in the order of the steps for each proxy.
+func (s *ScenarioService) Prewarm() (results []PrewarmResult) {
+	s.clientMutex.Lock()
+	defer s.clientMutex.Unlock()
+
+	for _, requesters := range s.clients {
+		for _, sr := range requesters {
+			p, ok := sr.requester.(requester.Prewarmer)
+			if !ok {
+				continue
+			}
+			requested, established, err := p.Prewarm()
+			if requested == 0 {
+				continue
+			}
+			results = append(results, PrewarmResult{
+				StepID:      sr.scenarioItemID,
+				Requested:   requested,
+				Established: established,
+				Required:    sr.prewarmRequired,
+				Err:         err,
+			})
+		}
+	}
+	return
+}
+
+// Preflight probes the connectivity of the targets of the steps before the test, for the requesters created in Init.
+// Returns the probes in the order of the steps, an address of a step is probed once even if it is shared by the
+// requesters of the proxies.
+func (s *ScenarioService) Preflight() (results []PreflightResult) {
+	s.clientMutex.Lock()
+	defer s.clientMutex.Unlock()
+
+	proxies := make([]*url.URL, 0, len(s.clients))
+	for p := range s.clients {
+		proxies = append(proxies, p)
+	}
+	// Requesters without a proxy are keyed by nil
+	sort.Slice(proxies, func(i, j int) bool {
+		if proxies[i] == nil || proxies[j] == nil {
+			return proxies[i] == nil && proxies[j] != nil
+		}
+		return proxies[i].String() < proxies[j].String()
+	})
+
+	type probed struct {
+		step uint16
+		addr string
+	}
+	seen := make(map[probed]bool)
+	for i := range s.scenario.Steps {
+		for _, proxy := range proxies {
+			requesters := s.clients[proxy]
+			if i >= len(requesters) {
+				continue
+			}
+			sr := requesters[i]
+			p, ok := sr.requester.(requester.Preflighter)
+			if !ok {
+				continue
+			}
+			for _, probe := range p.Preflight() {
+				k := probed{step: sr.scenarioItemID, addr: probe.Addr}
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				results = append(results, PreflightResult{StepID: sr.scenarioItemID, PreflightProbe: probe})
+			}
+		}
+	}
+	return
+}
+
+// MetricMeta returns the metrics declared by the requesters of the steps. Requesters of a step are the same type
+// for all the proxies.
+func (s *ScenarioService) MetricMeta() map[uint16][]types.MetricMeta {
+	s.clientMutex.Lock()
+	defer s.clientMutex.Unlock()
+
+	meta := make(map[uint16][]types.MetricMeta, len(s.scenario.Steps))
+	for _, requesters := range s.clients {
+		for _, sr := range requesters {
+			meta[sr.scenarioItemID] = sr.requester.MetricMeta()
+		}
+		break
+	}
+	return meta
 }
 
 func (s *ScenarioService) getOrCreateRequesters(proxy *url.URL) (requesters []scenarioItemRequester, err error) {
@@ -126,49 +564,148 @@ func (s *ScenarioService) getOrCreateRequesters(proxy *url.URL) (requesters []sc
 func (s *ScenarioService) createRequesters(proxy *url.URL) (err error) {
 	s.clients[proxy] = []scenarioItemRequester{}
 	for _, si := range s.scenario.Steps {
+		stepProxy := si.ProxyOf(proxy)
 		var r requester.Requester
 		r, err = requester.NewRequester(si)
 		if err != nil {
 			return
 		}
+
+		// Each step of each client has its own random streams, so adding a step or a proxy doesn't change the others.
+		seed := util.SubSeed(s.scenario.Seed, fmt.Sprintf("%s/%d", proxy, si.ID))
+		if sa, ok := r.(requester.SeedAware); ok {
+			sa.SetSeed(util.SubSeed(seed, "requester"))
+		}
+		if c, ok := r.(requester.Capturer); ok && s.capturer != nil && s.capturer.failures > 0 {
+			c.CaptureFailures()
+		}
+		if wa, ok := r.(requester.WarmStateAware); ok && s.warm != nil {
+			wa.SetWarmState(s.warm)
+		}
+		if ca, ok := r.(requester.ClockAware); ok && s.clock != nil {
+			ca.SetClock(s.clock)
+		}
+
 		s.clients[proxy] = append(
 			s.clients[proxy],
 			scenarioItemRequester{
-				scenarioItemID: si.ID,
-				sleeper:        newSleeper(si.Sleep),
-				requester:      r,
+				scenarioItemID:  si.ID,
+				sleeper:         newSleeper(si, util.NewRand(util.SubSeed(seed, "sleep"))),
+				requester:       r,
+				retryAfterSleep: si.Custom["retry-after"] == types.RetryAfterSleep,
+				prewarmRequired: si.Custom["prewarm-required"] == true,
+				parallelGroup:   si.ParallelGroup,
+				proxy:           stepProxy,
+				ownProxy:        si.Proxy != "",
 			},
 		)
 
-		err = r.Init(s.ctx, si, proxy, s.debug)
+		err = r.Init(s.ctx, si, stepProxy, s.debug)
 		if err != nil {
 			return
 		}
+		if d, ok := s.timeouts[si.ID]; ok {
+			if ta, isAdjustable := r.(requester.TimeoutAdjustable); isAdjustable {
+				ta.SetTimeout(d)
+			}
+		}
 	}
 	return err
 }
 
+// SetTimeout sets the timeout of the requests of the step on its requesters of all the proxies, and of the proxies
+// used later. Steps whose requesters don't support it keep their timeouts.
+func (s *ScenarioService) SetTimeout(stepID uint16, d time.Duration) {
+	s.clientMutex.Lock()
+	defer s.clientMutex.Unlock()
+
+	if s.timeouts == nil {
+		s.timeouts = make(map[uint16]time.Duration)
+	}
+	s.timeouts[stepID] = d
+	for _, requesters := range s.clients {
+		for _, sr := range requesters {
+			if ta, ok := sr.requester.(requester.TimeoutAdjustable); ok && sr.scenarioItemID == stepID {
+				ta.SetTimeout(d)
+			}
+		}
+	}
+}
+
+// backoff sleeps for the given duration, bounded by types.MaxRetryAfterSleep, unless the ctx is canceled.
+func (s *ScenarioService) backoff(d time.Duration) {
+	if d > types.MaxRetryAfterSleep {
+		d = types.MaxRetryAfterSleep
+	}
+
+	t := s.timeSource().NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+	case <-s.ctx.Done():
+	}
+}
+
 type scenarioItemRequester struct {
-	scenarioItemID uint16
-	sleeper        Sleeper
-	requester      requester.Requester
+	scenarioItemID  uint16
+	sleeper         Sleeper
+	requester       requester.Requester
+	retryAfterSleep bool
+	prewarmRequired bool
+	parallelGroup   string
+
+	// Proxy of the step and whether it overrides the proxy of the iteration, nil for the direct requests
+	proxy    *url.URL
+	ownProxy bool
+}
+
+// proxyOf returns the proxy that the requests of the step are sent over in an iteration with the given proxy.
+func (sr scenarioItemRequester) proxyOf(proxy *url.URL) *url.URL {
+	if sr.ownProxy {
+		return sr.proxy
+	}
+	return proxy
+}
+
+// PreflightResult is the outcome of the connectivity probe of an address of a step.
+type PreflightResult struct {
+	StepID uint16
+	requester.PreflightProbe
+}
+
+// PrewarmResult is the outcome of the connection prewarm of a step.
+type PrewarmResult struct {
+	StepID      uint16
+	Requested   int
+	Established int
+
+	// Set if the step fails the test when its prewarm fails
+	Required bool
+	Err      error
 }
 
 // Sleeper is the interface for implementing different sleep strategies.
+// sleep is called with the clock of the service and the values captured in the iteration so far, it returns true if
+// the resolved duration is out of range and clamped.
 type Sleeper interface {
-	sleep()
+	sleep(c clock.Clock, captures map[string]string) (clamped bool)
 }
 
 // RangeSleep is the implementation of the range sleep feature
 type RangeSleep struct {
 	min int
 	max int
+	rnd *rand.Rand
 }
 
-func (rs *RangeSleep) sleep() {
-	rand.Seed(time.Now().UnixNano())
-	dur := rand.Intn(rs.max-rs.min+1) + rs.min
-	time.Sleep(time.Duration(dur) * time.Millisecond)
+func (rs *RangeSleep) sleep(c clock.Clock, _ map[string]string) bool {
+	c.Sleep(rs.duration())
+	return false
+}
+
+func (rs *RangeSleep) duration() time.Duration {
+	dur := rs.rnd.Intn(rs.max-rs.min+1) + rs.min
+	return time.Duration(dur) * time.Millisecond
 }
 
 // DurationSleep is the implementation of the exact duration sleep feature
@@ -176,18 +713,62 @@ type DurationSleep struct {
 	duration int
 }
 
-func (ds *DurationSleep) sleep() {
-	time.Sleep(time.Duration(ds.duration) * time.Millisecond)
+func (ds *DurationSleep) sleep(c clock.Clock, _ map[string]string) bool {
+	c.Sleep(time.Duration(ds.duration) * time.Millisecond)
+	return false
+}
+
+// TemplateSleep is the implementation of the sleep resolved per iteration from a captured value in ms,
+// like the X-Poll-After header of the response.
+type TemplateSleep struct {
+	name string
+
+	// Used if the value is missing or not numeric
+	fallback time.Duration
+}
+
+func (ts *TemplateSleep) sleep(c clock.Clock, captures map[string]string) bool {
+	d, clamped := ts.duration(captures)
+	c.Sleep(d)
+	return clamped
+}
+
+// duration resolves the sleep duration. Negative and the values over types.MaxSleep are clamped.
+func (ts *TemplateSleep) duration(captures map[string]string) (time.Duration, bool) {
+	v, ok := captures[ts.name]
+	if !ok {
+		return ts.fallback, false
+	}
+	ms, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || math.IsNaN(ms) {
+		return ts.fallback, false
+	}
+
+	if ms < 0 {
+		return 0, true
+	}
+	if ms > float64(types.MaxSleep/time.Millisecond) {
+		return types.MaxSleep, true
+	}
+	return time.Duration(ms * float64(time.Millisecond)), false
 }
 
 // newSleeper is the factor method for the Sleeper implementations.
-func newSleeper(sleepStr string) Sleeper {
+// rnd must be safe for concurrent use, it picks the durations of the range sleeps.
+func newSleeper(si types.ScenarioStep, rnd *rand.Rand) Sleeper {
+	sleepStr := si.Sleep
 	if sleepStr == "" {
 		return nil
 	}
 
 	var sl Sleeper
 
+	// Resolved per iteration in Do
+	if name, ok := types.ParseSleepTemplate(sleepStr); ok {
+		fallback, _ := util.ToFloat64(si.Custom["sleep-default"])
+		return &TemplateSleep{name: name, fallback: time.Duration(fallback * float64(time.Millisecond))}
+	}
+
 	// Sleep field already validated in types.scenario.validate(). No need to check parsing errors here.
 	s := strings.Split(sleepStr, "-")
 	if len(s) == 2 {
@@ -200,6 +781,7 @@ func newSleeper(sleepStr string) Sleeper {
 		sl = &RangeSleep{
 			min: min,
 			max: max,
+			rnd: rnd,
 		}
 	} else {
 		dur, _ := strconv.Atoi(s[0])
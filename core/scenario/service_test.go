@@ -23,13 +23,23 @@ package scenario
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"go.ddosify.com/ddosify/core/clock"
 	"go.ddosify.com/ddosify/core/scenario/requester"
 	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
 )
 
 type MockRequester struct {
@@ -41,6 +51,16 @@ type MockRequester struct {
 	FailInitMsg string
 
 	ReturnSend *types.ScenarioStepResult
+
+	// Values captured into the iteration by Send
+	Capture map[string]string
+
+	// Iteration of the last Send and its captured values at the time of the Send
+	Iteration *requester.Iteration
+	Captures  map[string]string
+
+	// Duration of the Send
+	Delay time.Duration
 }
 
 func (m *MockRequester) Init(ctx context.Context, s types.ScenarioStep, proxyAddr *url.URL, debug bool) (err error) {
@@ -51,8 +71,19 @@ func (m *MockRequester) Init(ctx context.Context, s types.ScenarioStep, proxyAdd
 	return
 }
 
-func (m *MockRequester) Send() (res *types.ScenarioStepResult) {
+func (m *MockRequester) Send(it *requester.Iteration) (res *types.ScenarioStepResult) {
 	m.SendCalled = true
+	m.Iteration = it
+	time.Sleep(m.Delay)
+	if it.Captures != nil {
+		m.Captures = make(map[string]string, len(it.Captures))
+		for k, v := range it.Captures {
+			m.Captures[k] = v
+		}
+	}
+	for k, v := range m.Capture {
+		it.Capture(k, v)
+	}
 	return m.ReturnSend
 }
 
@@ -60,14 +91,19 @@ func (m *MockRequester) Done() {
 	m.DoneCalled = true
 }
 
+func (m *MockRequester) MetricMeta() []types.MetricMeta {
+	return []types.MetricMeta{{Key: "mockDuration", Name: "Mock", JSONKey: "mock"}}
+}
+
 type MockSleep struct {
 	SleepCalled    bool
 	SleepCallCount int
 }
 
-func (msl *MockSleep) sleep() {
+func (msl *MockSleep) sleep(clock.Clock, map[string]string) bool {
 	msl.SleepCalled = true
 	msl.SleepCallCount++
+	return false
 }
 
 func compareScenarioServiceClients(
@@ -106,7 +142,13 @@ func compareScenarioServiceClients(
 				return fmt.Errorf("[sleep] Expected %#v, Found %#v", expectedVal, val)
 			}
 
-			if !reflect.DeepEqual(expectedVal[i].sleeper, val[i].sleeper) {
+			// Random generators of the range sleeps are seeded per client, compared separately.
+			if rs, ok := val[i].sleeper.(*RangeSleep); ok {
+				expectedRs := expectedVal[i].sleeper.(*RangeSleep)
+				if rs.min != expectedRs.min || rs.max != expectedRs.max || rs.rnd == nil {
+					return fmt.Errorf("[sleep] Expected %#v, Found %#v", expectedVal, val)
+				}
+			} else if !reflect.DeepEqual(expectedVal[i].sleeper, val[i].sleeper) {
 				return fmt.Errorf("[sleep] Expected %#v, Found %#v", expectedVal, val)
 			}
 		}
@@ -276,7 +318,7 @@ func TestDo(t *testing.T) {
 	expectedResponse := types.ScenarioResult{
 		ProxyAddr: p1,
 		StepResults: []*types.ScenarioStepResult{
-			{StepID: 1}, {StepID: 2},
+			{StepID: 1, ProxyAddr: p1}, {StepID: 2, ProxyAddr: p1},
 		},
 	}
 	// Act
@@ -289,6 +331,10 @@ func TestDo(t *testing.T) {
 	if response.ProxyAddr != expectedResponse.ProxyAddr {
 		t.Fatalf("[ProxyAddr] Expected %v, Found: %v", expectedResponse.ProxyAddr, response.ProxyAddr)
 	}
+	if _, ok := response.StepResults[0].Custom["sleepTime"].(time.Duration); !ok {
+		t.Fatalf("[SleepTime] Sleep time of the step with the sleeper should be set")
+	}
+	response.StepResults[0].Custom = nil
 	if !reflect.DeepEqual(expectedResponse.StepResults, response.StepResults) {
 		t.Fatalf("[ResponseItem] Expected %#v, Found: %#v", expectedResponse.StepResults, response.StepResults)
 	}
@@ -300,6 +346,81 @@ func TestDo(t *testing.T) {
 	}
 }
 
+func TestDoRetryAfterSleep(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	scenario := types.Scenario{
+		Steps: []types.ScenarioStep{
+			{ID: 1, Protocol: types.DefaultProtocol, Method: types.DefaultMethod, URL: "test.com"},
+			{ID: 2, Protocol: types.DefaultProtocol, Method: types.DefaultMethod, URL: "test.com"},
+		},
+	}
+	p1, _ := url.Parse("http://proxy_server.com:80")
+	backoff := time.Duration(300) * time.Millisecond
+
+	rateLimitedRes := &types.ScenarioStepResult{
+		StepID:     1,
+		StatusCode: 429,
+		Custom:     map[string]interface{}{"rateLimited": true, "retryAfter": backoff},
+	}
+	next := &MockRequester{ReturnSend: &types.ScenarioStepResult{StepID: 2}}
+	requesters := []scenarioItemRequester{
+		{
+			scenarioItemID:  1,
+			requester:       &MockRequester{ReturnSend: rateLimitedRes},
+			retryAfterSleep: true,
+		},
+		{
+			scenarioItemID: 2,
+			requester:      next,
+		},
+	}
+	c := clock.NewFake(time.Now())
+	service := ScenarioService{
+		clients:  map[*url.URL][]scenarioItemRequester{p1: requesters},
+		scenario: scenario,
+		ctx:      context.TODO(),
+		clock:    c,
+	}
+
+	// Act
+	done := make(chan *types.RequestError)
+	go func() {
+		_, err := service.Do(p1, c.Now())
+		done <- err
+	}()
+	c.BlockUntil(1)
+	c.Advance(backoff - time.Millisecond)
+	waiting := c.Waiters() == 1
+	c.Advance(time.Millisecond)
+	err := <-done
+
+	// Assert
+	if err != nil {
+		t.Fatalf("TestDoRetryAfterSleep errored: %v", err)
+	}
+	if !waiting || !next.SendCalled {
+		t.Fatalf("Expected step 2 to be sent after the %v backoff", backoff)
+	}
+}
+
+func TestPreviewUnsupportedRequester(t *testing.T) {
+	t.Parallel()
+
+	p1, _ := url.Parse("http://proxy_server.com:80")
+	service := ScenarioService{
+		clients: map[*url.URL][]scenarioItemRequester{
+			p1: {{scenarioItemID: 1, requester: &MockRequester{}}},
+		},
+		ctx: context.TODO(),
+	}
+
+	if _, err := service.Preview(p1); err == nil {
+		t.Errorf("Preview should be errored for the requesters not implementing Previewer")
+	}
+}
+
 func TestDoErrorOnSend(t *testing.T) {
 	t.Parallel()
 
@@ -489,6 +610,33 @@ func TestDone(t *testing.T) {
 	}
 }
 
+func TestMetricMeta(t *testing.T) {
+	t.Parallel()
+
+	// Arrange
+	p1, _ := url.Parse("http://proxy_server.com:80")
+	service := ScenarioService{
+		clients: map[*url.URL][]scenarioItemRequester{
+			p1: {
+				{scenarioItemID: 1, requester: &MockRequester{}},
+				{scenarioItemID: 2, requester: &MockRequester{}},
+			},
+		},
+	}
+
+	// Act
+	meta := service.MetricMeta()
+
+	// Assert
+	expected := map[uint16][]types.MetricMeta{
+		1: {{Key: "mockDuration", Name: "Mock", JSONKey: "mock"}},
+		2: {{Key: "mockDuration", Name: "Mock", JSONKey: "mock"}},
+	}
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("Expected %v, Found %v", expected, meta)
+	}
+}
+
 func TestGetOrCreateRequesters(t *testing.T) {
 	t.Parallel()
 
@@ -684,76 +832,820 @@ func TestCreateRequestersErrorOnRequesterInit(t *testing.T) {
 	}
 }
 
-func TestnewSleeper(t *testing.T) {
+func TestNewSleeper(t *testing.T) {
 	t.Parallel()
 
 	sleepRange := "300-500"
 	sleepRangeReverse := "500-300"
 	sleepDuration := "1000"
 
+	rnd := util.NewRand(1)
 	expectedSleepRange := &RangeSleep{
 		min: 300,
 		max: 500,
+		rnd: rnd,
 	}
 	exptectedSleepDuration := &DurationSleep{
 		duration: 1000,
 	}
 
 	// "range" sleep strategy test
-	sleep := newSleeper(sleepRange)
+	sleep := newSleeper(types.ScenarioStep{Sleep: sleepRange}, rnd)
 	if !reflect.DeepEqual(sleep, expectedSleepRange) {
 		t.Errorf("Expected %v, Found: %v", expectedSleepRange, sleep)
 	}
-	sleep = newSleeper(sleepRangeReverse)
+	sleep = newSleeper(types.ScenarioStep{Sleep: sleepRangeReverse}, rnd)
 	if !reflect.DeepEqual(sleep, expectedSleepRange) {
 		t.Errorf("Expected %v, Found: %v", expectedSleepRange, sleep)
 	}
 
 	// "duration" sleep strategy test
-	sleep = newSleeper(sleepDuration)
+	sleep = newSleeper(types.ScenarioStep{Sleep: sleepDuration}, rnd)
 	if !reflect.DeepEqual(sleep, exptectedSleepDuration) {
 		t.Errorf("Expected %v, Found: %v", exptectedSleepDuration, sleep)
 	}
 }
 
+// slept returns the duration that the sleeper waits on a fake clock, the clock is advanced by a millisecond until
+// the sleep is done.
+func slept(sl Sleeper, captures map[string]string) (time.Duration, bool) {
+	c := clock.NewFake(time.Now())
+	done := make(chan bool)
+	go func() {
+		done <- sl.sleep(c, captures)
+	}()
+
+	c.BlockUntil(1)
+	start := c.Now()
+	for c.Waiters() > 0 {
+		c.Advance(time.Millisecond)
+	}
+	return c.Since(start), <-done
+}
+
 func TestSleep(t *testing.T) {
 	t.Parallel()
 
-	delta := time.Duration(100)
-	min := 300
-	max := 500
-	dur := 1000
-
-	if testing.Short() {
-		// Arrange durations for poor machines
-		delta = time.Duration(600)
-		min = 750
-		max = 1250
-		dur = 1000
-	}
-
+	min, max, dur := 300, 500, 1000
 	sleepDuration := &DurationSleep{
 		duration: dur,
 	}
 	sleepRange := &RangeSleep{
 		min: min,
 		max: max,
+		rnd: util.NewRand(1),
 	}
+	// Same seed resolves the same duration
+	expected := (&RangeSleep{min: min, max: max, rnd: util.NewRand(1)}).duration()
 
 	// Test range
-	start := time.Now()
-	sleepRange.sleep()
-	elapsed := time.Duration(time.Since(start) / time.Millisecond)
-	if elapsed > time.Duration(max)+delta || elapsed < time.Duration(min)-delta {
-		t.Errorf("Expected: [%d-%d], Found: %d", min, max, elapsed)
+	if d, _ := slept(sleepRange, nil); d != expected || d < time.Duration(min)*time.Millisecond ||
+		d > time.Duration(max)*time.Millisecond {
+		t.Errorf("Expected: %v in [%d-%d]ms, Found: %v", expected, min, max, d)
 	}
 
 	// Test exact duration
-	start = time.Now()
-	sleepDuration.sleep()
-	elapsed = time.Duration(time.Since(start) / time.Millisecond)
-	if elapsed > time.Duration(dur)+delta {
-		t.Errorf("Expected: %d, Found: %d", dur, elapsed)
+	if d, _ := slept(sleepDuration, nil); d != time.Duration(dur)*time.Millisecond {
+		t.Errorf("Expected: %dms, Found: %v", dur, d)
+	}
+}
+
+func TestRangeSleepSeed(t *testing.T) {
+	t.Parallel()
+
+	// Durations of a step shouldn't change when a step is added to the scenario.
+	durations := func(seed int64, steps []types.ScenarioStep) []time.Duration {
+		service := ScenarioService{
+			clients:  map[*url.URL][]scenarioItemRequester{},
+			scenario: types.Scenario{Steps: steps, Seed: seed},
+			ctx:      context.TODO(),
+		}
+		if err := service.createRequesters(nil); err != nil {
+			t.Fatalf("createRequesters errored: %v", err)
+		}
+
+		sl := service.clients[nil][0].sleeper.(*RangeSleep)
+		var d []time.Duration
+		for i := 0; i < 10; i++ {
+			d = append(d, sl.duration())
+		}
+		return d
+	}
+
+	step := types.ScenarioStep{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: "http://test.com",
+		Timeout: types.DefaultTimeout, Sleep: "100-5000"}
+	other := step
+	other.ID = 2
+
+	a := durations(42, []types.ScenarioStep{step})
+	if b := durations(42, []types.ScenarioStep{step, other}); !reflect.DeepEqual(a, b) {
+		t.Errorf("Same seed should produce the same sleep durations, Found %v and %v", a, b)
+	}
+	if b := durations(43, []types.ScenarioStep{step}); reflect.DeepEqual(a, b) {
+		t.Errorf("Different seeds should produce different sleep durations, Found %v", a)
+	}
+}
+
+func TestTemplateSleepDuration(t *testing.T) {
+	t.Parallel()
+
+	ts := &TemplateSleep{name: "poll_after", fallback: 200 * time.Millisecond}
+	tests := []struct {
+		name     string
+		captures map[string]string
+		expected time.Duration
+		clamped  bool
+	}{
+		{"Captured", map[string]string{"poll_after": "1500"}, 1500 * time.Millisecond, false},
+		{"Fraction", map[string]string{"poll_after": " 2.5 "}, 2500 * time.Microsecond, false},
+		{"Missing", map[string]string{"other": "1500"}, 200 * time.Millisecond, false},
+		{"NoCaptures", nil, 200 * time.Millisecond, false},
+		{"NonNumeric", map[string]string{"poll_after": "soon"}, 200 * time.Millisecond, false},
+		{"Negative", map[string]string{"poll_after": "-10"}, 0, true},
+		{"OverMax", map[string]string{"poll_after": "120000"}, types.MaxSleep, true},
+	}
+
+	for _, test := range tests {
+		d, clamped := ts.duration(test.captures)
+		if d != test.expected || clamped != test.clamped {
+			t.Errorf("%s: Expected %v (clamped: %v), Found %v (clamped: %v)",
+				test.name, test.expected, test.clamped, d, clamped)
+		}
+	}
+
+	sleep := newSleeper(types.ScenarioStep{
+		Sleep:  "{{ poll_after }}",
+		Custom: map[string]interface{}{"sleep-default": float64(200)},
+	}, util.NewRand(1))
+	if !reflect.DeepEqual(sleep, ts) {
+		t.Errorf("Expected %v, Found: %v", ts, sleep)
+	}
+}
+
+func TestDoSleepTemplate(t *testing.T) {
+	t.Parallel()
+
+	scenario := types.Scenario{Steps: []types.ScenarioStep{{ID: 1}, {ID: 2}, {ID: 3}}}
+	p1, _ := url.Parse("http://proxy_server.com:80")
+	result := func(id uint16) *types.ScenarioStepResult {
+		return &types.ScenarioStepResult{StepID: id, Custom: map[string]interface{}{}}
+	}
+
+	requesters := []scenarioItemRequester{
+		{
+			scenarioItemID: 1,
+			sleeper:        &TemplateSleep{name: "poll_after"},
+			requester:      &MockRequester{ReturnSend: result(1), Capture: map[string]string{"poll_after": "-5"}},
+		},
+		{
+			scenarioItemID: 2,
+			sleeper:        &TemplateSleep{name: "poll_after", fallback: time.Second},
+			requester:      &MockRequester{ReturnSend: result(2), Capture: map[string]string{"poll_after": "1"}},
+		},
+		{
+			scenarioItemID: 3,
+			requester:      &MockRequester{ReturnSend: result(3)},
+		},
+	}
+	c := clock.NewFake(time.Now())
+	service := ScenarioService{
+		clients:  map[*url.URL][]scenarioItemRequester{p1: requesters},
+		scenario: scenario,
+		ctx:      context.TODO(),
+		clock:    c,
+	}
+
+	type done struct {
+		response *types.ScenarioResult
+		err      *types.RequestError
+	}
+	doneChan := make(chan done)
+	go func() {
+		response, err := service.Do(p1, c.Now())
+		doneChan <- done{response, err}
+	}()
+	// Step 2 sleeps 1ms by its own capture instead of the 1s fallback
+	c.BlockUntil(1)
+	c.Advance(time.Millisecond)
+	if n := c.Waiters(); n != 0 {
+		t.Errorf("Sleep should be resolved from the captured value, Found %d sleeps waiting after 1ms", n)
+		c.Advance(time.Second)
+	}
+	d := <-doneChan
+	response, err := d.response, d.err
+	if err != nil {
+		t.Fatalf("TestDoSleepTemplate errored: %v", err)
+	}
+	if _, ok := response.StepResults[0].Custom["sleepClamped"]; !ok {
+		t.Errorf("Negative sleep of step 1 should be marked as clamped")
+	}
+	if _, ok := response.StepResults[1].Custom["sleepClamped"]; ok {
+		t.Errorf("Sleep of step 2 should not be marked as clamped")
+	}
+	if d := response.StepResults[1].Custom["sleepTime"]; d != time.Millisecond {
+		t.Errorf("Sleep time of step 2, Expected %v, Found %v", time.Millisecond, d)
+	}
+	if _, ok := response.StepResults[2].Custom["sleepTime"]; ok {
+		t.Errorf("Step 3 without a sleep should have no sleep time")
 	}
+	if response.Duration != time.Millisecond {
+		t.Errorf("Wall time of the iteration, Expected %v, Found %v", time.Millisecond, response.Duration)
+	}
+}
+
+func TestDoIteration(t *testing.T) {
+	t.Parallel()
+
+	scenario := types.Scenario{Steps: []types.ScenarioStep{{ID: 1}, {ID: 2}}}
+	p1, _ := url.Parse("http://proxy_server.com:80")
+	order := `{"id": 7}`
+
+	first := &MockRequester{ReturnSend: &types.ScenarioStepResult{StepID: 1}, Capture: map[string]string{"order": order}}
+	second := &MockRequester{ReturnSend: &types.ScenarioStepResult{StepID: 2}}
+	service := ScenarioService{
+		clients: map[*url.URL][]scenarioItemRequester{p1: {
+			{scenarioItemID: 1, requester: first},
+			{scenarioItemID: 2, requester: second},
+		}},
+		scenario: scenario,
+		ctx:      context.TODO(),
+	}
+
+	for i := uint64(0); i < 2; i++ {
+		if _, err := service.Do(p1, time.Now()); err != nil {
+			t.Fatalf("TestDoIteration errored: %v", err)
+		}
+		if first.Captures != nil {
+			t.Errorf("Iteration %d should start without the values captured by the previous one, Found %v",
+				i, first.Captures)
+		}
+		if !reflect.DeepEqual(second.Captures, map[string]string{"order": order}) {
+			t.Errorf("Step 2 should receive the values captured by step 1, Found %v", second.Captures)
+		}
+		if first.Iteration != second.Iteration || first.Iteration.ID != i {
+			t.Errorf("Steps should be sent in the same iteration %d, Found %d and %d",
+				i, first.Iteration.ID, second.Iteration.ID)
+		}
+		// Sequential iterations are run by the same virtual user
+		if r := second.ReturnSend; r.IterationID != i || r.VUID != 0 {
+			t.Errorf("Result Expected iteration %d of user 0, Found %d of %d", i, r.IterationID, r.VUID)
+		}
+	}
+}
 
+func TestDoConditionalRequests(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer server.Close()
+
+	scenario := types.Scenario{Steps: []types.ScenarioStep{
+		{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: server.URL, Timeout: types.DefaultTimeout,
+			ConditionalRequests: true},
+		{ID: 2, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: server.URL, Timeout: types.DefaultTimeout},
+	}}
+	service := NewScenarioService()
+	if err := service.Init(context.TODO(), scenario, []*url.URL{nil}, false); err != nil {
+		t.Fatalf("TestDoConditionalRequests errored: %v", err)
+	}
+	defer service.Done()
+
+	// Iterations run one after the other by the same virtual user, its validators are kept across them
+	expected := []int{http.StatusOK, http.StatusNotModified, http.StatusNotModified}
+	for i, status := range expected {
+		res, err := service.Do(nil, time.Now())
+		if err != nil {
+			t.Fatalf("TestDoConditionalRequests errored: %v", err)
+		}
+		step, other := res.StepResults[0], res.StepResults[1]
+		if step.VUID != 0 || step.StatusCode != status || step.Custom["conditional"] != (i > 0) {
+			t.Errorf("Iteration %d Expected %d of VU 0, Found %d of VU %d conditional %v", i, status,
+				step.StatusCode, step.VUID, step.Custom["conditional"])
+		}
+		// Steps without conditional_requests don't send the validators
+		if _, ok := other.Custom["conditional"]; ok || other.StatusCode != http.StatusOK {
+			t.Errorf("Iteration %d Expected an unconditional 200 of the other step, Found %d", i, other.StatusCode)
+		}
+	}
+}
+
+func TestDoABVariants(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	received := make(map[string]string)
+	handler := func(variant string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[r.Header.Get("X-Request-Id")] = variant
+			mu.Unlock()
+		}
+	}
+	a := httptest.NewServer(handler(types.VariantA))
+	defer a.Close()
+	b := httptest.NewServer(handler(types.VariantB))
+	defer b.Close()
+
+	variants := map[string]interface{}{"a": map[string]interface{}{}, "b": map[string]interface{}{"base_url": b.URL}}
+	step := func(id uint16) types.ScenarioStep {
+		return types.ScenarioStep{ID: id, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: a.URL,
+			Timeout: types.DefaultTimeout, Headers: map[string]string{"X-Request-Id": "{{_iterationId}}/{{_stepId}}"},
+			Custom: map[string]interface{}{"ab-variants": variants}}
+	}
+	scenario := types.Scenario{Steps: []types.ScenarioStep{step(1), step(2)}, Seed: 42, ABSplit: 0.3}
+	service := NewScenarioService()
+	if err := service.Init(context.TODO(), scenario, []*url.URL{nil}, false); err != nil {
+		t.Fatalf("TestDoABVariants errored: %v", err)
+	}
+	defer service.Done()
+
+	const iterations = 400
+	counts := make(map[string]int)
+	for i := 0; i < iterations; i++ {
+		res, err := service.Do(nil, time.Now())
+		if err != nil {
+			t.Fatalf("TestDoABVariants errored: %v", err)
+		}
+		variant := res.StepResults[0].Variant
+		counts[variant]++
+		// Steps of an iteration run on the variant of the iteration
+		for _, sr := range res.StepResults {
+			id := fmt.Sprintf("%d/%d", sr.IterationID, sr.StepID)
+			if sr.Variant != variant || received[id] != variant {
+				t.Errorf("Step %s Expected variant %s, Found %s sent to %s", id, variant, sr.Variant, received[id])
+			}
+		}
+	}
+	if counts[types.VariantA]+counts[types.VariantB] != iterations || counts[types.VariantB] < 90 ||
+		counts[types.VariantB] > 150 {
+		t.Errorf("Expected about 30%% of %d iterations on variant b, Found %v", iterations, counts)
+	}
+}
+
+func TestDoVirtualUsers(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	received := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.Header.Get("X-Request-Id")] = true
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	scenario := types.Scenario{Steps: []types.ScenarioStep{
+		{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: server.URL,
+			Timeout: types.DefaultTimeout, Headers: map[string]string{"X-Request-Id": "{{_iterationId}}/{{_vuId}}"}},
+	}}
+	service := NewScenarioService()
+	if err := service.Init(context.TODO(), scenario, []*url.URL{nil}, false); err != nil {
+		t.Fatalf("TestDoVirtualUsers errored: %v", err)
+	}
+	defer service.Done()
+
+	const workers, iterations = 8, 20
+	results := make(chan *types.ScenarioStepResult, workers*iterations)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				res, _ := service.Do(nil, time.Now())
+				results <- res.StepResults[0]
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[uint64]bool)
+	for sr := range results {
+		if seen[sr.IterationID] || sr.IterationID >= workers*iterations {
+			t.Errorf("Iteration IDs should be unique and sequential, Found %d", sr.IterationID)
+		}
+		seen[sr.IterationID] = true
+		// Busy users are at most the count of the concurrent iterations
+		if sr.VUID >= workers {
+			t.Errorf("User ID Expected less than %d, Found %d", workers, sr.VUID)
+		}
+		if id := fmt.Sprintf("%d/%d", sr.IterationID, sr.VUID); !received[id] {
+			t.Errorf("Result %s should match the rendered request", id)
+		}
+	}
+}
+
+func TestDoConcurrentIterations(t *testing.T) {
+	t.Parallel()
+
+	// Each session gets its own token as a header and a cookie, step 2 fails if it receives the token or
+	// the cookie of another session.
+	var sessions int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			token := strconv.FormatInt(atomic.AddInt64(&sessions, 1), 10)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: token})
+			w.Header().Set("X-Token", token)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if c, err := r.Cookie("session"); err != nil || c.Value != string(body) {
+			w.WriteHeader(http.StatusConflict)
+		}
+	}))
+	defer server.Close()
+
+	scenario := types.Scenario{Steps: []types.ScenarioStep{
+		{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: server.URL + "/login",
+			Timeout: types.DefaultTimeout,
+			Custom: map[string]interface{}{
+				"capture": map[string]interface{}{"token": map[string]interface{}{"header": "X-Token"}},
+			}},
+		{ID: 2, Protocol: types.ProtocolHTTP, Method: http.MethodPost, URL: server.URL + "/order",
+			Timeout: types.DefaultTimeout, Payload: "{{ .token }}"},
+	}}
+	service := NewScenarioService()
+	if err := service.Init(context.TODO(), scenario, []*url.URL{nil}, false); err != nil {
+		t.Fatalf("TestDoConcurrentIterations errored: %v", err)
+	}
+	defer service.Done()
+
+	const iterations = 500
+	var wg sync.WaitGroup
+	failures := make(chan string, iterations)
+	for i := 0; i < iterations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := service.Do(nil, time.Now())
+			if err != nil {
+				failures <- err.Error()
+				return
+			}
+			for _, sr := range res.StepResults {
+				if sr.Err.Type != "" || sr.StatusCode != http.StatusOK {
+					failures <- fmt.Sprintf("step %d: %d %v", sr.StepID, sr.StatusCode, sr.Err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(failures)
+
+	for f := range failures {
+		t.Errorf("Iterations should not see the state of each other, Found %s", f)
+	}
+	if sessions != iterations {
+		t.Errorf("Session count Expected %d, Found %d", iterations, sessions)
+	}
+}
+
+func TestDoConnectionScope(t *testing.T) {
+	t.Parallel()
+
+	const workers, iterations = 4, 5
+	tests := []struct {
+		scope string
+
+		// Connections opened by the sequential and the concurrent iterations, the concurrent ones give the upper bound
+		sequential int64
+		concurrent int64
+		exact      bool
+	}{
+		{types.ConnectionScopeIteration, 2 * iterations, 2 * workers * iterations, true},
+		{types.ConnectionScopeUser, 2, 2 * workers, false},
+		{types.ConnectionScopeGlobal, 2, 2 * workers, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.scope, func(t *testing.T) {
+			t.Parallel()
+
+			var opened, closed int64
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.Config.ConnState = func(c net.Conn, s http.ConnState) {
+				switch s {
+				case http.StateNew:
+					atomic.AddInt64(&opened, 1)
+				case http.StateClosed:
+					atomic.AddInt64(&closed, 1)
+				}
+			}
+			server.Start()
+			defer server.Close()
+
+			newService := func() *ScenarioService {
+				scenario := types.Scenario{ConnectionScope: test.scope, Steps: []types.ScenarioStep{
+					{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: server.URL + "/a",
+						Timeout: types.DefaultTimeout},
+					{ID: 2, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: server.URL + "/b",
+						Timeout: types.DefaultTimeout},
+				}}
+				service := NewScenarioService()
+				if err := service.Init(context.TODO(), scenario, []*url.URL{nil}, false); err != nil {
+					t.Fatalf("TestDoConnectionScope errored: %v", err)
+				}
+				return service
+			}
+			waitClosed := func() {
+				for i := 0; i < 100 && atomic.LoadInt64(&closed) < atomic.LoadInt64(&opened); i++ {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if c, o := atomic.LoadInt64(&closed), atomic.LoadInt64(&opened); c != o {
+					t.Errorf("Done should close all the connections, Found %d of %d closed", c, o)
+				}
+			}
+
+			// Sequential iterations, the reused connections are reported by the steps
+			service := newService()
+			var reused int
+			for i := 0; i < iterations; i++ {
+				res, _ := service.Do(nil, time.Now())
+				for _, sr := range res.StepResults {
+					if r, _ := sr.Custom["connReused"].(bool); r {
+						reused++
+					}
+				}
+			}
+			service.Done()
+			if o := atomic.LoadInt64(&opened); o != test.sequential {
+				t.Errorf("Sequential connections Expected %d, Found %d", test.sequential, o)
+			}
+			if expected := 2*iterations - int(test.sequential); reused != expected {
+				t.Errorf("Reused connections Expected %d, Found %d", expected, reused)
+			}
+			waitClosed()
+
+			// Concurrent iterations, each worker runs its iterations one after the other
+			atomic.StoreInt64(&opened, 0)
+			atomic.StoreInt64(&closed, 0)
+			service = newService()
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < iterations; i++ {
+						service.Do(nil, time.Now())
+					}
+				}()
+			}
+			wg.Wait()
+			service.Done()
+			if o := atomic.LoadInt64(&opened); o > test.concurrent || (test.exact && o != test.concurrent) {
+				t.Errorf("Concurrent connections Expected at most %d, Found %d", test.concurrent, o)
+			}
+			waitClosed()
+		})
+	}
+}
+
+func TestTeardown(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var next int
+	var deleted, bulk []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/orders":
+			next++
+			fmt.Fprintf(w, `{"id": "%d"}`, next)
+		case r.Method == http.MethodDelete:
+			id := strings.TrimPrefix(r.URL.Path, "/orders/")
+			if id == "3" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			deleted = append(deleted, id)
+		case r.URL.Path == "/orders/bulk-delete":
+			b, _ := io.ReadAll(r.Body)
+			bulk = append(bulk, string(b))
+		}
+	}))
+	defer server.Close()
+
+	scenario := types.Scenario{
+		CollectLimit: 4,
+		Steps: []types.ScenarioStep{{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodPost,
+			URL: server.URL + "/orders", Timeout: types.DefaultTimeout,
+			Custom: map[string]interface{}{"capture": map[string]interface{}{
+				"order_id": map[string]interface{}{"json_path": "id", "collect": true},
+			}}}},
+		Teardown: []types.ScenarioStep{
+			{ID: 1, Name: "Delete", Protocol: types.ProtocolHTTP, Method: http.MethodDelete,
+				URL: server.URL + "/orders/{{ .order_id }}", Timeout: types.DefaultTimeout, SuccessStatus: "200-299",
+				Custom: map[string]interface{}{"foreach": "order_id"}},
+			{ID: 2, Protocol: types.ProtocolHTTP, Method: http.MethodPost, URL: server.URL + "/orders/bulk-delete",
+				Payload: `{"ids": {{ .order_id }}}`, Timeout: types.DefaultTimeout,
+				Custom: map[string]interface{}{"foreach": "order_id", "foreach-batch": float64(3)}},
+			{ID: 3, Protocol: types.ProtocolHTTP, Method: http.MethodPost, URL: server.URL + "/orders/bulk-delete",
+				Payload: "all", Timeout: types.DefaultTimeout},
+		},
+	}
+
+	// Teardown runs once the ctx of the test is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	service := NewScenarioService()
+	if err := service.Init(ctx, scenario, []*url.URL{nil}, false); err != nil {
+		t.Fatalf("TestTeardown errored: %v", err)
+	}
+	defer service.Done()
+	for i := 0; i < 5; i++ {
+		service.Do(nil, time.Now())
+	}
+	cancel()
+
+	results := service.Teardown()
+	expected := []TeardownResult{
+		{StepID: 1, StepName: "Delete", Foreach: "order_id", Collected: 4, Dropped: 1, Processed: 3, Failed: 1,
+			Requests: 4, FailedRequests: 1},
+		{StepID: 2, Foreach: "order_id", Collected: 4, Dropped: 1, Processed: 4, Requests: 2},
+		{StepID: 3, Requests: 1},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, results)
+	}
+	if e := []string{"1", "2", "4"}; !reflect.DeepEqual(deleted, e) {
+		t.Errorf("Deleted Expected %v, Found %v", e, deleted)
+	}
+	if e := []string{`{"ids": ["1","2","3"]}`, `{"ids": ["4"]}`, "all"}; !reflect.DeepEqual(bulk, e) {
+		t.Errorf("Bulk Expected %v, Found %v", e, bulk)
+	}
+}
+
+func TestTeardownAbort(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "1"}`)
+	}))
+	defer server.Close()
+
+	scenario := types.Scenario{
+		Steps: []types.ScenarioStep{{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodPost,
+			URL: server.URL, Timeout: types.DefaultTimeout,
+			Custom: map[string]interface{}{"capture": map[string]interface{}{
+				"order_id": map[string]interface{}{"json_path": "id", "collect": true},
+			}}}},
+		Teardown: []types.ScenarioStep{{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodDelete,
+			URL: server.URL + "/{{ .order_id }}", Timeout: types.DefaultTimeout,
+			Custom: map[string]interface{}{"foreach": "order_id"}}},
+	}
+	service := NewScenarioService()
+	if err := service.Init(context.TODO(), scenario, []*url.URL{nil}, false); err != nil {
+		t.Fatalf("TestTeardownAbort errored: %v", err)
+	}
+	defer service.Done()
+	for i := 0; i < 3; i++ {
+		service.Do(nil, time.Now())
+	}
+
+	service.AbortTeardown()
+	results := service.Teardown()
+	expected := []TeardownResult{{StepID: 1, Foreach: "order_id", Collected: 3, Skipped: 3}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %+v, Found %+v", expected, results)
+	}
+}
+
+func TestDoParallelGroup(t *testing.T) {
+	t.Parallel()
+
+	scenario := types.Scenario{Steps: []types.ScenarioStep{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}}
+	p1, _ := url.Parse("http://proxy_server.com:80")
+	mock := func(id uint16, capture map[string]string) *MockRequester {
+		return &MockRequester{ReturnSend: &types.ScenarioStepResult{StepID: id}, Capture: capture,
+			Delay: 200 * time.Millisecond}
+	}
+
+	first := mock(1, map[string]string{"token": "t"})
+	first.Delay = 0
+	members := []*MockRequester{mock(2, map[string]string{"profile": "p"}), mock(3, map[string]string{"feed": "f"}),
+		mock(4, nil)}
+	last := mock(5, nil)
+	last.Delay = 0
+	sleeper := &MockSleep{}
+	service := ScenarioService{
+		clients: map[*url.URL][]scenarioItemRequester{p1: {
+			{scenarioItemID: 1, requester: first},
+			{scenarioItemID: 2, requester: members[0], parallelGroup: "home"},
+			{scenarioItemID: 3, requester: members[1], parallelGroup: "home", sleeper: sleeper},
+			{scenarioItemID: 4, requester: members[2], parallelGroup: "home"},
+			{scenarioItemID: 5, requester: last},
+		}},
+		scenario: scenario,
+		ctx:      context.TODO(),
+	}
+
+	start := time.Now()
+	response, err := service.Do(p1, time.Now())
+	if err != nil {
+		t.Fatalf("TestDoParallelGroup errored: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Steps of the group should run concurrently, Do took %v", elapsed)
+	}
+
+	var ids []uint16
+	for _, r := range response.StepResults {
+		ids = append(ids, r.StepID)
+	}
+	if expected := []uint16{1, 2, 3, 4, 5}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Results should be in the order of the steps, Expected %v, Found %v", expected, ids)
+	}
+	for i, r := range response.StepResults[1:4] {
+		if r.Custom["parallelGroup"] != "home" {
+			t.Errorf("Result of step %d should be marked with its group, Found %v", i+2, r.Custom)
+		}
+	}
+
+	// Captures of the group are visible after the group
+	for i, m := range members {
+		if !reflect.DeepEqual(m.Captures, map[string]string{"token": "t"}) {
+			t.Errorf("Step %d should only see the values captured before the group, Found %v", i+2, m.Captures)
+		}
+		if m.Iteration == first.Iteration {
+			t.Errorf("Step %d should be sent with a fork of the iteration", i+2)
+		}
+	}
+	expected := map[string]string{"token": "t", "profile": "p", "feed": "f"}
+	if !reflect.DeepEqual(last.Captures, expected) {
+		t.Errorf("Step 5 should see the values captured by the group, Expected %v, Found %v", expected, last.Captures)
+	}
+	if sleeper.SleepCallCount != 1 {
+		t.Errorf("Sleep of the group should run once, Found %d", sleeper.SleepCallCount)
+	}
+}
+
+func TestDoStepProxy(t *testing.T) {
+	t.Parallel()
+
+	p1, _ := url.Parse("http://proxy_server.com:80")
+	p2, _ := url.Parse("socks5://step_proxy.com:1080")
+	proxyErr := types.RequestError{Type: types.ErrorProxy, Reason: types.ReasonProxyFailed}
+	tests := []struct {
+		name      string
+		stepProxy string
+		failed    uint16
+		expected  *url.URL
+		shouldErr bool
+	}{
+		{"Inherited", "", 2, p1, true},
+		{"Direct", types.StepProxyNone, 1, nil, false},
+		{"Own", p2.String(), 1, p2, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			scenario := types.Scenario{Steps: []types.ScenarioStep{
+				{ID: 1, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: "http://admin.internal",
+					Proxy: test.stepProxy},
+				{ID: 2, Protocol: types.ProtocolHTTP, Method: http.MethodGet, URL: "http://test.com"},
+			}}
+			service := NewScenarioService()
+			if err := service.Init(context.TODO(), scenario, []*url.URL{p1}, false); err != nil {
+				t.Fatalf("TestDoStepProxy errored: %v", err)
+			}
+			requesters := service.clients[p1]
+			if !reflect.DeepEqual(requesters[0].proxyOf(p1), test.expected) || requesters[1].proxyOf(p1) != p1 {
+				t.Fatalf("Expected the proxies %v, %v, Found %v, %v", test.expected, p1, requesters[0].proxyOf(p1),
+					requesters[1].proxyOf(p1))
+			}
+
+			// Proxy error of the step with its own proxy doesn't replace the proxy of the iteration
+			for i := range requesters {
+				res := &types.ScenarioStepResult{StepID: requesters[i].scenarioItemID}
+				if res.StepID == test.failed {
+					res.Err = proxyErr
+				}
+				requesters[i].requester.Done()
+				requesters[i].requester = &MockRequester{ReturnSend: res}
+			}
+			response, err := service.Do(p1, time.Now())
+			if (err != nil) != test.shouldErr {
+				t.Errorf("Expected the proxy error to be returned: %v, Found %v", test.shouldErr, err)
+			}
+			if len(response.StepResults) != 2 {
+				t.Fatalf("Expected 2 results, Found %d", len(response.StepResults))
+			}
+			if !reflect.DeepEqual(response.StepResults[0].ProxyAddr, test.expected) ||
+				response.StepResults[1].ProxyAddr != p1 || response.ProxyAddr != p1 {
+				t.Errorf("Results should have the proxies actually used, Found %v, %v", response.StepResults[0].ProxyAddr,
+					response.StepResults[1].ProxyAddr)
+			}
+		})
+	}
 }
@@ -0,0 +1,155 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"go.ddosify.com/ddosify/core/scenario/requester"
+	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/util"
+)
+
+// TeardownResult is the outcome of a teardown step.
+type TeardownResult struct {
+	StepID   uint16
+	StepName string
+
+	// Collected capture iterated by the step, empty if the step runs once
+	Foreach   string
+	Collected int64
+	Dropped   int64
+
+	// Counts of the collected values, the values of a batch are failed together
+	Processed int64
+	Failed    int64
+	Skipped   int64
+
+	Requests       int64
+	FailedRequests int64
+
+	// Set if the collected values can't be read
+	Err error
+}
+
+// initTeardown creates the collector of the collected captures and the requesters of the teardown steps.
+// Teardown requesters have their own ctx, so the teardown still runs once the ctx of the test is canceled.
+func (s *ScenarioService) initTeardown(proxy *url.URL) error {
+	if len(s.scenario.CollectedCaptures()) > 0 {
+		limit := s.scenario.CollectLimit
+		if limit == 0 {
+			limit = types.DefaultCollectLimit
+		}
+		s.collector = requester.NewCollector(limit)
+	}
+	if len(s.scenario.Teardown) == 0 {
+		return nil
+	}
+
+	s.teardownCtx, s.teardownCancel = context.WithCancel(context.Background())
+	for _, si := range s.scenario.Teardown {
+		r, err := requester.NewRequester(si)
+		if err != nil {
+			return err
+		}
+		if sa, ok := r.(requester.SeedAware); ok {
+			sa.SetSeed(util.SubSeed(s.scenario.Seed, fmt.Sprintf("teardown/%d", si.ID)))
+		}
+		if ca, ok := r.(requester.ClockAware); ok && s.clock != nil {
+			ca.SetClock(s.clock)
+		}
+		stepProxy := si.ProxyOf(proxy)
+		s.teardown = append(s.teardown, scenarioItemRequester{scenarioItemID: si.ID, requester: r, proxy: stepProxy,
+			ownProxy: si.Proxy != ""})
+		if err = r.Init(s.teardownCtx, si, stepProxy, s.debug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Teardown runs the teardown steps in order after the test. A step with a foreach is sent for each batch of the values
+// collected by the iterations, the others are sent once. Returns the results in the order of the steps.
+func (s *ScenarioService) Teardown() (results []TeardownResult) {
+	var id uint64
+	for i, sr := range s.teardown {
+		si := s.scenario.Teardown[i]
+		r := TeardownResult{StepID: si.ID, StepName: si.Name}
+
+		// Sends the step with the captured value of the foreach, returns false once the teardown is aborted
+		send := func(captures map[string]string) bool {
+			it := requester.NewIteration(id, s.scenario.Seed)
+			id++
+			it.Captures = captures
+			res := sr.requester.Send(it)
+			if res.Err.Type == types.ErrorIntented {
+				return false
+			}
+			r.Requests++
+			if res.Err.Type != "" {
+				r.FailedRequests++
+			}
+			return true
+		}
+
+		foreach, _ := si.Custom["foreach"].(string)
+		if foreach == "" || s.collector == nil {
+			send(nil)
+			results = append(results, r)
+			continue
+		}
+
+		r.Foreach = foreach
+		r.Collected, r.Dropped = s.collector.Counts(foreach)
+		batch, _ := types.ParseForeachBatch(si.Custom["foreach-batch"])
+		r.Err = s.collector.Each(foreach, batch, func(values []string) bool {
+			value := values[0]
+			if batch > 1 {
+				b, _ := json.Marshal(values)
+				value = string(b)
+			}
+
+			failed := r.FailedRequests
+			if !send(map[string]string{foreach: value}) {
+				return false
+			}
+			if r.FailedRequests > failed {
+				r.Failed += int64(len(values))
+			} else {
+				r.Processed += int64(len(values))
+			}
+			return true
+		})
+		r.Skipped = r.Collected - r.Processed - r.Failed
+		results = append(results, r)
+	}
+	return
+}
+
+// AbortTeardown cancels the requests of the teardown steps, the values left are reported as skipped.
+func (s *ScenarioService) AbortTeardown() {
+	if s.teardownCancel != nil {
+		s.teardownCancel()
+	}
+}
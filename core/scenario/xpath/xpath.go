@@ -0,0 +1,1198 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package xpath
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidXML is the reason of the xpath captures and assertions over a response body that is not a valid XML.
+// It does not include the parse error, so the failures of the different responses are counted together.
+const ErrInvalidXML = "response body is not a valid xml"
+
+type xmlNodeKind int
+
+const (
+	xmlDocumentNode xmlNodeKind = iota
+	xmlElementNode
+	xmlAttributeNode
+	xmlTextNode
+)
+
+// Node is a node of a parsed XML document. The namespaces of the names are resolved to their URIs.
+type Node struct {
+	kind     xmlNodeKind
+	name     xml.Name
+	value    string
+	parent   *Node
+	children []*Node
+	attrs    []*Node
+
+	// Document order of the node
+	order int
+}
+
+// Parse parses the document, the body should have a single root element.
+func Parse(data []byte) (*Node, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = xmlCharsetReader
+
+	doc := &Node{kind: xmlDocumentNode}
+	cur, order, hasRoot := doc, 1, false
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			if cur == doc && hasRoot {
+				return nil, fmt.Errorf("xml document has more than one root element")
+			}
+			hasRoot = true
+			e := &Node{kind: xmlElementNode, name: t.Name, parent: cur, order: order}
+			order++
+			for _, a := range t.Attr {
+				// Namespace declarations are not attributes in the XPath data model
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					continue
+				}
+				e.attrs = append(e.attrs, &Node{kind: xmlAttributeNode, name: a.Name, value: a.Value, parent: e,
+					order: order})
+				order++
+			}
+			cur.children = append(cur.children, e)
+			cur = e
+		case xml.EndElement:
+			cur = cur.parent
+		case xml.CharData:
+			if cur == doc {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, fmt.Errorf("xml document has text outside the root element")
+				}
+				continue
+			}
+			// CDATA sections and the character references split the text into several tokens
+			if n := len(cur.children); n > 0 && cur.children[n-1].kind == xmlTextNode {
+				cur.children[n-1].value += string(t)
+				continue
+			}
+			cur.children = append(cur.children, &Node{kind: xmlTextNode, value: string(t), parent: cur,
+				order: order})
+			order++
+		}
+	}
+	if !hasRoot {
+		return nil, fmt.Errorf("xml document has no root element")
+	}
+	return doc, nil
+}
+
+// xmlCharsetReader decodes the documents declared as ISO-8859-1 besides the UTF-8 ones.
+func xmlCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "us-ascii":
+		return input, nil
+	case "iso-8859-1", "latin1":
+		b, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		return strings.NewReader(string(r)), nil
+	}
+	return nil, fmt.Errorf("unsupported xml charset: %s", charset)
+}
+
+// stringValue returns the text of the node, the concatenated descendant texts for the elements and the document.
+func (n *Node) stringValue() string {
+	if n.kind == xmlTextNode || n.kind == xmlAttributeNode {
+		return n.value
+	}
+	b := strings.Builder{}
+	var walk func(*Node)
+	walk = func(n *Node) {
+		for _, c := range n.children {
+			if c.kind == xmlTextNode {
+				b.WriteString(c.value)
+			} else {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// Expr is a compiled XPath 1.0 expression. The location paths with the abbreviated syntax and the child, descendant,
+// descendant-or-self, self, parent, ancestor, ancestor-or-self, attribute, following-sibling and preceding-sibling
+// axes, the operators and the common functions are supported, variables are not. Names without a prefix match
+// the elements of any namespace, prefixed names match the namespace the prefix is declared with.
+type Expr struct {
+	expr string
+	root expr
+}
+
+// Compile compiles the expression, the prefixes of the names are resolved by the given namespaces.
+func Compile(expr string, namespaces map[string]string) (*Expr, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %s: %v", expr, err)
+	}
+
+	p := &parser{tokens: tokens, namespaces: namespaces}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != xtEOF {
+		err = fmt.Errorf("unexpected %q at %d", p.peek().text, p.peek().pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %s: %v", expr, err)
+	}
+	return &Expr{expr: expr, root: root}, nil
+}
+
+func (x *Expr) String() string {
+	return x.expr
+}
+
+// Evaluate returns the string values of the matched nodes in the document order, or the single value of an expression
+// that is not a node-set, like "count(//Item)".
+func (x *Expr) Evaluate(doc *Node) []string {
+	v := x.root.eval(evalContext{node: doc, pos: 1, size: 1})
+	if nodes, ok := v.([]*Node); ok {
+		values := make([]string, len(nodes))
+		for i, n := range nodes {
+			values[i] = n.stringValue()
+		}
+		return values
+	}
+	return []string{toString(v)}
+}
+
+// Bool returns the boolean value of the expression over the document. A node-set is true if it is not empty.
+func (x *Expr) Bool(doc *Node) bool {
+	return toBool(x.root.eval(evalContext{node: doc, pos: 1, size: 1}))
+}
+
+/*
+ * Tokenizer
+ */
+
+type tokenKind int
+
+const (
+	xtEOF tokenKind = iota
+	xtName
+	xtNumber
+	xtLiteral
+	xtOperator
+	xtLParen
+	xtRParen
+	xtLBracket
+	xtRBracket
+	xtAt
+	xtComma
+	xtDot
+	xtDotDot
+	xtAxis
+	xtStar
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func isNameStart(c rune) bool {
+	return unicode.IsLetter(c) || c == '_'
+}
+
+func isNameRune(c rune) bool {
+	return isNameStart(c) || unicode.IsDigit(c) || c == '.' || c == '-'
+}
+
+// tokenize splits the expression into the tokens. By the XPath rules, * and the operator names like div are
+// operators only if they follow an operand, otherwise they are the name tests.
+func tokenize(expr string) (tokens []token, err error) {
+	r := []rune(expr)
+	followsOperand := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		switch tokens[len(tokens)-1].kind {
+		case xtAt, xtAxis, xtLParen, xtLBracket, xtComma, xtOperator:
+			return false
+		}
+		return true
+	}
+
+	for i := 0; i < len(r); {
+		c := r[i]
+		start := i
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, token{xtLParen, "(", start})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{xtRParen, ")", start})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{xtLBracket, "[", start})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{xtRBracket, "]", start})
+			i++
+		case c == '@':
+			tokens = append(tokens, token{xtAt, "@", start})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{xtComma, ",", start})
+			i++
+		case c == '*':
+			if followsOperand() {
+				tokens = append(tokens, token{xtOperator, "*", start})
+			} else {
+				tokens = append(tokens, token{xtStar, "*", start})
+			}
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(r) && r[end] != c {
+				end++
+			}
+			if end == len(r) {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			tokens = append(tokens, token{xtLiteral, string(r[i+1 : end]), start})
+			i = end + 1
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(r) && unicode.IsDigit(r[i+1])):
+			for i < len(r) && unicode.IsDigit(r[i]) {
+				i++
+			}
+			if i < len(r) && r[i] == '.' {
+				i++
+				for i < len(r) && unicode.IsDigit(r[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{xtNumber, string(r[start:i]), start})
+		case c == '.':
+			if i+1 < len(r) && r[i+1] == '.' {
+				tokens = append(tokens, token{xtDotDot, "..", start})
+				i += 2
+			} else {
+				tokens = append(tokens, token{xtDot, ".", start})
+				i++
+			}
+		case c == '/':
+			if i+1 < len(r) && r[i+1] == '/' {
+				tokens = append(tokens, token{xtOperator, "//", start})
+				i += 2
+			} else {
+				tokens = append(tokens, token{xtOperator, "/", start})
+				i++
+			}
+		case c == '!' || c == '<' || c == '>':
+			if i+1 < len(r) && r[i+1] == '=' {
+				tokens = append(tokens, token{xtOperator, string(r[i : i+2]), start})
+				i += 2
+			} else if c == '!' {
+				return nil, fmt.Errorf("unexpected %q at %d", c, start)
+			} else {
+				tokens = append(tokens, token{xtOperator, string(c), start})
+				i++
+			}
+		case c == '|' || c == '+' || c == '-' || c == '=':
+			tokens = append(tokens, token{xtOperator, string(c), start})
+			i++
+		case isNameStart(c):
+			for i < len(r) && isNameRune(r[i]) {
+				i++
+			}
+			name := string(r[start:i])
+			if i+1 < len(r) && r[i] == ':' && r[i+1] == ':' {
+				tokens = append(tokens, token{xtAxis, name, start})
+				i += 2
+				continue
+			}
+			if followsOperand() {
+				if name != "and" && name != "or" && name != "div" && name != "mod" {
+					return nil, fmt.Errorf("unexpected %s at %d", name, start)
+				}
+				tokens = append(tokens, token{xtOperator, name, start})
+				continue
+			}
+			if i+1 < len(r) && r[i] == ':' && (r[i+1] == '*' || isNameStart(r[i+1])) {
+				i++
+				if r[i] == '*' {
+					i++
+				} else {
+					for i < len(r) && isNameRune(r[i]) {
+						i++
+					}
+				}
+				name = string(r[start:i])
+			}
+			tokens = append(tokens, token{xtName, name, start})
+		case c == '$':
+			return nil, fmt.Errorf("variables are not supported")
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, start)
+		}
+	}
+	return append(tokens, token{xtEOF, "", len(r)}), nil
+}
+
+/*
+ * Parser
+ */
+
+type evalContext struct {
+	node *Node
+	pos  int
+	size int
+}
+
+type expr interface {
+	eval(ctx evalContext) interface{}
+}
+
+type axis int
+
+const (
+	axisChild axis = iota
+	axisDescendant
+	axisDescendantOrSelf
+	axisSelf
+	axisParent
+	axisAncestor
+	axisAncestorOrSelf
+	axisAttribute
+	axisFollowingSibling
+	axisPrecedingSibling
+)
+
+var axes = map[string]axis{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"descendant-or-self": axisDescendantOrSelf,
+	"self":               axisSelf,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"ancestor-or-self":   axisAncestorOrSelf,
+	"attribute":          axisAttribute,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+}
+
+// Arity bounds of the functions, -1 means variadic
+var functions = map[string][2]int{
+	"last":            {0, 0},
+	"position":        {0, 0},
+	"count":           {1, 1},
+	"local-name":      {0, 1},
+	"namespace-uri":   {0, 1},
+	"string":          {0, 1},
+	"concat":          {2, -1},
+	"contains":        {2, 2},
+	"starts-with":     {2, 2},
+	"normalize-space": {0, 1},
+	"string-length":   {0, 1},
+	"not":             {1, 1},
+	"true":            {0, 0},
+	"false":           {0, 0},
+	"boolean":         {1, 1},
+	"number":          {0, 1},
+	"sum":             {1, 1},
+}
+
+type parser struct {
+	tokens     []token
+	pos        int
+	namespaces map[string]string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// peekAt returns the token at the offset from the current one, EOF past the end.
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != xtEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOperator(ops ...string) bool {
+	t := p.peek()
+	if t.kind != xtOperator {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if t := p.next(); t.kind != kind {
+		if t.kind == xtEOF {
+			return fmt.Errorf("missing %s", text)
+		}
+		return fmt.Errorf("expected %s at %d, found %q", text, t.pos, t.text)
+	}
+	return nil
+}
+
+// parseBinary parses the left associative binary operators of a precedence level.
+func (p *parser) parseBinary(operand func() (expr, error), ops ...string) (expr, error) {
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator(ops...) {
+		op := p.next().text
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l = &binary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	return p.parseBinary(p.parseAnd, "or")
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	return p.parseBinary(p.parseEquality, "and")
+}
+
+func (p *parser) parseEquality() (expr, error) {
+	return p.parseBinary(p.parseRelational, "=", "!=")
+}
+
+func (p *parser) parseRelational() (expr, error) {
+	return p.parseBinary(p.parseAdditive, "<", "<=", ">", ">=")
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	return p.parseBinary(p.parseUnary, "*", "div", "mod")
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.isOperator("-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negation{x: x}, nil
+	}
+	return p.parseBinary(p.parsePath, "|")
+}
+
+// startsStep reports whether the current token starts a step of a location path.
+func (p *parser) startsStep() bool {
+	t := p.peek()
+	switch t.kind {
+	case xtStar, xtAt, xtDot, xtDotDot, xtAxis:
+		return true
+	case xtName:
+		// A name followed by ( is a function call unless it is a node type test
+		return p.peekAt(1).kind != xtLParen || t.text == "text" || t.text == "node"
+	}
+	return false
+}
+
+func (p *parser) parsePath() (expr, error) {
+	path := &locationPath{}
+	switch {
+	case p.isOperator("/"):
+		p.next()
+		path.absolute = true
+		if !p.startsStep() {
+			return path, nil
+		}
+	case p.isOperator("//"):
+		p.next()
+		path.absolute = true
+		path.steps = append(path.steps, descendantOrSelfStep())
+	case p.startsStep():
+	default:
+		filter, err := p.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOperator("/", "//") {
+			return filter, nil
+		}
+		path.filter = filter
+		if p.next().text == "//" {
+			path.steps = append(path.steps, descendantOrSelfStep())
+		}
+	}
+
+	for {
+		s, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, s)
+		if !p.isOperator("/", "//") {
+			return path, nil
+		}
+		if p.next().text == "//" {
+			path.steps = append(path.steps, descendantOrSelfStep())
+		}
+	}
+}
+
+func descendantOrSelfStep() *step {
+	return &step{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}}
+}
+
+func (p *parser) parseFilter() (expr, error) {
+	var primary expr
+	t := p.next()
+	switch t.kind {
+	case xtLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(xtRParen, ")"); err != nil {
+			return nil, err
+		}
+		primary = x
+	case xtLiteral:
+		primary = literal{value: t.text}
+	case xtNumber:
+		n, _ := strconv.ParseFloat(t.text, 64)
+		primary = literal{value: n}
+	case xtName:
+		f, err := p.parseFunction(t)
+		if err != nil {
+			return nil, err
+		}
+		primary = f
+	case xtEOF:
+		return nil, fmt.Errorf("unexpected end")
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+
+	preds, err := p.parsePredicates()
+	if err != nil || len(preds) == 0 {
+		return primary, err
+	}
+	return &filterExpr{primary: primary, predicates: preds}, nil
+}
+
+func (p *parser) parseFunction(name token) (expr, error) {
+	arity, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unsupported function %s", name.text)
+	}
+	p.next() // (
+
+	f := &functionCall{name: name.text}
+	for p.peek().kind != xtRParen {
+		if len(f.args) > 0 {
+			if err := p.expect(xtComma, ","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		f.args = append(f.args, arg)
+	}
+	p.next() // )
+
+	if len(f.args) < arity[0] || (arity[1] >= 0 && len(f.args) > arity[1]) {
+		return nil, fmt.Errorf("invalid argument count of %s: %d", name.text, len(f.args))
+	}
+	return f, nil
+}
+
+func (p *parser) parsePredicates() (preds []expr, err error) {
+	for p.peek().kind == xtLBracket {
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(xtRBracket, "]"); err != nil {
+			return nil, err
+		}
+		preds = append(preds, x)
+	}
+	return preds, nil
+}
+
+func (p *parser) parseStep() (*step, error) {
+	switch p.peek().kind {
+	case xtDot:
+		p.next()
+		return &step{axis: axisSelf, test: nodeTest{kind: testNode}}, nil
+	case xtDotDot:
+		p.next()
+		return &step{axis: axisParent, test: nodeTest{kind: testNode}}, nil
+	}
+
+	s := &step{axis: axisChild}
+	if t := p.peek(); t.kind == xtAt {
+		p.next()
+		s.axis = axisAttribute
+	} else if t.kind == xtAxis {
+		p.next()
+		axis, ok := axes[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unsupported axis %s", t.text)
+		}
+		s.axis = axis
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == xtStar:
+		s.test = nodeTest{kind: testName, anySpace: true, local: "*"}
+	case t.kind == xtName && p.peek().kind == xtLParen && (t.text == "text" || t.text == "node"):
+		p.next()
+		if err := p.expect(xtRParen, ")"); err != nil {
+			return nil, err
+		}
+		s.test = nodeTest{kind: testText}
+		if t.text == "node" {
+			s.test.kind = testNode
+		}
+	case t.kind == xtName:
+		test, err := p.nameTest(t.text)
+		if err != nil {
+			return nil, err
+		}
+		s.test = test
+	case t.kind == xtEOF:
+		return nil, fmt.Errorf("unexpected end")
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+
+	preds, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	s.predicates = preds
+	return s, nil
+}
+
+// nameTest resolves the prefix of the name by the declared namespaces.
+func (p *parser) nameTest(name string) (nodeTest, error) {
+	prefix, local, found := strings.Cut(name, ":")
+	if !found {
+		return nodeTest{kind: testName, anySpace: true, local: name}, nil
+	}
+	uri, ok := p.namespaces[prefix]
+	if !ok {
+		return nodeTest{}, fmt.Errorf("undeclared namespace prefix %s", prefix)
+	}
+	return nodeTest{kind: testName, space: uri, local: local}, nil
+}
+
+/*
+ * Evaluation
+ */
+
+type literal struct {
+	value interface{}
+}
+
+func (l literal) eval(evalContext) interface{} {
+	return l.value
+}
+
+type negation struct {
+	x expr
+}
+
+func (n *negation) eval(ctx evalContext) interface{} {
+	return -toNumber(n.x.eval(ctx))
+}
+
+type binary struct {
+	op   string
+	l, r expr
+}
+
+func (b *binary) eval(ctx evalContext) interface{} {
+	switch b.op {
+	case "or":
+		return toBool(b.l.eval(ctx)) || toBool(b.r.eval(ctx))
+	case "and":
+		return toBool(b.l.eval(ctx)) && toBool(b.r.eval(ctx))
+	case "|":
+		l, _ := b.l.eval(ctx).([]*Node)
+		r, _ := b.r.eval(ctx).([]*Node)
+		return sortNodes(append(append([]*Node(nil), l...), r...))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compare(b.op, b.l.eval(ctx), b.r.eval(ctx))
+	}
+
+	l, r := toNumber(b.l.eval(ctx)), toNumber(b.r.eval(ctx))
+	switch b.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "div":
+		return l / r
+	default:
+		return math.Mod(l, r)
+	}
+}
+
+type filterExpr struct {
+	primary    expr
+	predicates []expr
+}
+
+func (f *filterExpr) eval(ctx evalContext) interface{} {
+	nodes, ok := f.primary.eval(ctx).([]*Node)
+	if !ok {
+		return []*Node(nil)
+	}
+	return applyPredicates(nodes, f.predicates)
+}
+
+type locationPath struct {
+	// Expression whose node-set the steps start from, nil for the location paths
+	filter   expr
+	absolute bool
+	steps    []*step
+}
+
+func (p *locationPath) eval(ctx evalContext) interface{} {
+	var nodes []*Node
+	switch {
+	case p.filter != nil:
+		nodes, _ = p.filter.eval(ctx).([]*Node)
+	case p.absolute:
+		root := ctx.node
+		for root.parent != nil {
+			root = root.parent
+		}
+		nodes = []*Node{root}
+	default:
+		nodes = []*Node{ctx.node}
+	}
+
+	for _, s := range p.steps {
+		var next []*Node
+		for _, n := range nodes {
+			next = append(next, s.eval(n)...)
+		}
+		nodes = sortNodes(next)
+	}
+	return nodes
+}
+
+type nodeTestKind int
+
+const (
+	testName nodeTestKind = iota
+	testText
+	testNode
+)
+
+type nodeTest struct {
+	kind nodeTestKind
+
+	// Namespace URI and local name of the name tests, local is * for the wildcards
+	anySpace bool
+	space    string
+	local    string
+}
+
+func (t nodeTest) matches(n *Node, axis axis) bool {
+	switch t.kind {
+	case testNode:
+		return true
+	case testText:
+		return n.kind == xmlTextNode
+	}
+
+	// Principal node type of the attribute axis is the attribute, the element for the others
+	if (axis == axisAttribute) != (n.kind == xmlAttributeNode) || (axis != axisAttribute && n.kind != xmlElementNode) {
+		return false
+	}
+	if !t.anySpace && n.name.Space != t.space {
+		return false
+	}
+	return t.local == "*" || n.name.Local == t.local
+}
+
+type step struct {
+	axis       axis
+	test       nodeTest
+	predicates []expr
+}
+
+// eval returns the nodes of the step from n, in the order of the axis for the positions of the predicates.
+func (s *step) eval(n *Node) []*Node {
+	var nodes []*Node
+	add := func(c *Node) {
+		if s.test.matches(c, s.axis) {
+			nodes = append(nodes, c)
+		}
+	}
+	var descend func(*Node)
+	descend = func(n *Node) {
+		for _, c := range n.children {
+			add(c)
+			descend(c)
+		}
+	}
+
+	switch s.axis {
+	case axisChild:
+		for _, c := range n.children {
+			add(c)
+		}
+	case axisDescendant:
+		descend(n)
+	case axisDescendantOrSelf:
+		add(n)
+		descend(n)
+	case axisSelf:
+		add(n)
+	case axisParent:
+		if n.parent != nil {
+			add(n.parent)
+		}
+	case axisAncestor, axisAncestorOrSelf:
+		if s.axis == axisAncestorOrSelf {
+			add(n)
+		}
+		for p := n.parent; p != nil; p = p.parent {
+			add(p)
+		}
+	case axisAttribute:
+		for _, a := range n.attrs {
+			add(a)
+		}
+	case axisFollowingSibling, axisPrecedingSibling:
+		if n.parent == nil || n.kind == xmlAttributeNode {
+			break
+		}
+		siblings := n.parent.children
+		i := 0
+		for siblings[i] != n {
+			i++
+		}
+		if s.axis == axisFollowingSibling {
+			for _, c := range siblings[i+1:] {
+				add(c)
+			}
+		} else {
+			for j := i - 1; j >= 0; j-- {
+				add(siblings[j])
+			}
+		}
+	}
+	return applyPredicates(nodes, s.predicates)
+}
+
+// applyPredicates filters the nodes by each predicate in turn. A number predicate selects the node at that position.
+func applyPredicates(nodes []*Node, predicates []expr) []*Node {
+	for _, pred := range predicates {
+		filtered := make([]*Node, 0, len(nodes))
+		for i, n := range nodes {
+			v := pred.eval(evalContext{node: n, pos: i + 1, size: len(nodes)})
+			if f, ok := v.(float64); ok {
+				if f == float64(i+1) {
+					filtered = append(filtered, n)
+				}
+			} else if toBool(v) {
+				filtered = append(filtered, n)
+			}
+		}
+		nodes = filtered
+	}
+	return nodes
+}
+
+// sortNodes sorts the nodes in the document order and removes the duplicates.
+func sortNodes(nodes []*Node) []*Node {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].order < nodes[j].order })
+	unique := nodes[:0]
+	for i, n := range nodes {
+		if i == 0 || n != nodes[i-1] {
+			unique = append(unique, n)
+		}
+	}
+	return unique
+}
+
+type functionCall struct {
+	name string
+	args []expr
+}
+
+func (f *functionCall) eval(ctx evalContext) interface{} {
+	// arg returns the value of the i-th argument, or the node-set of the context node if it is not given.
+	arg := func(i int) interface{} {
+		if i < len(f.args) {
+			return f.args[i].eval(ctx)
+		}
+		return []*Node{ctx.node}
+	}
+	firstNode := func() *Node {
+		if nodes, _ := arg(0).([]*Node); len(nodes) > 0 {
+			return nodes[0]
+		}
+		return nil
+	}
+
+	switch f.name {
+	case "last":
+		return float64(ctx.size)
+	case "position":
+		return float64(ctx.pos)
+	case "count":
+		nodes, _ := arg(0).([]*Node)
+		return float64(len(nodes))
+	case "local-name", "namespace-uri":
+		n := firstNode()
+		if n == nil {
+			return ""
+		}
+		if f.name == "local-name" {
+			return n.name.Local
+		}
+		return n.name.Space
+	case "string":
+		return toString(arg(0))
+	case "concat":
+		b := strings.Builder{}
+		for i := range f.args {
+			b.WriteString(toString(arg(i)))
+		}
+		return b.String()
+	case "contains":
+		return strings.Contains(toString(arg(0)), toString(arg(1)))
+	case "starts-with":
+		return strings.HasPrefix(toString(arg(0)), toString(arg(1)))
+	case "normalize-space":
+		return strings.Join(strings.Fields(toString(arg(0))), " ")
+	case "string-length":
+		return float64(utf8.RuneCountInString(toString(arg(0))))
+	case "not":
+		return !toBool(arg(0))
+	case "true":
+		return true
+	case "false":
+		return false
+	case "boolean":
+		return toBool(arg(0))
+	case "number":
+		return toNumber(arg(0))
+	default: // sum
+		nodes, _ := arg(0).([]*Node)
+		var sum float64
+		for _, n := range nodes {
+			sum += toNumber(n.stringValue())
+		}
+		return sum
+	}
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case []*Node:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].stringValue()
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+func toNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+
+	s := strings.TrimSpace(toString(v))
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || strings.ContainsAny(s, "eExXpPiInN_") {
+		return math.NaN()
+	}
+	return f
+}
+
+func toBool(v interface{}) bool {
+	switch v := v.(type) {
+	case []*Node:
+		return len(v) > 0
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	return false
+}
+
+// compare compares the values by the XPath rules. A comparison with a node-set is true if it is true
+// for the string value of any node of it.
+func compare(op string, l, r interface{}) bool {
+	ln, lIsNodes := l.([]*Node)
+	rn, rIsNodes := r.([]*Node)
+	switch {
+	case lIsNodes && rIsNodes:
+		for _, a := range ln {
+			for _, b := range rn {
+				if compareAtoms(op, a.stringValue(), b.stringValue()) {
+					return true
+				}
+			}
+		}
+		return false
+	case lIsNodes || rIsNodes:
+		nodes, other, swapped := ln, r, false
+		if rIsNodes {
+			nodes, other, swapped = rn, l, true
+		}
+		if b, ok := other.(bool); ok {
+			if swapped {
+				return compareAtoms(op, b, len(nodes) > 0)
+			}
+			return compareAtoms(op, len(nodes) > 0, b)
+		}
+		for _, n := range nodes {
+			var v interface{} = n.stringValue()
+			if _, ok := other.(float64); ok {
+				v = toNumber(v)
+			}
+			if swapped && compareAtoms(op, other, v) || !swapped && compareAtoms(op, v, other) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, l, r)
+}
+
+// compareAtoms compares the values that are not node-sets.
+func compareAtoms(op string, l, r interface{}) bool {
+	if op == "=" || op == "!=" {
+		var eq bool
+		_, lBool := l.(bool)
+		_, rBool := r.(bool)
+		_, lNum := l.(float64)
+		_, rNum := r.(float64)
+		switch {
+		case lBool || rBool:
+			eq = toBool(l) == toBool(r)
+		case lNum || rNum:
+			eq = toNumber(l) == toNumber(r)
+		default:
+			eq = toString(l) == toString(r)
+		}
+		if op == "=" {
+			return eq
+		}
+		return !eq
+	}
+
+	a, b := toNumber(l), toNumber(r)
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
@@ -0,0 +1,176 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package xpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+const soapResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:orders">
+  <soap:Body>
+    <m:CreateOrderResponse status="created">
+      <m:OrderId>ord-42</m:OrderId>
+      <m:Items>
+        <m:Item sku="a1" qty="2">Pen</m:Item>
+        <m:Item sku="b2" qty="3"><![CDATA[Note]]>book</m:Item>
+        <m:Item sku="c3" qty="5">Ink &amp; Paper</m:Item>
+      </m:Items>
+      <Total currency="EUR">12.50</Total>
+    </m:CreateOrderResponse>
+  </soap:Body>
+</soap:Envelope>`
+
+func TestXPath(t *testing.T) {
+	t.Parallel()
+
+	doc, err := Parse([]byte(soapResponse))
+	if err != nil {
+		t.Fatalf("Error occurred %v", err)
+	}
+	ns := map[string]string{"soap": "http://schemas.xmlsoap.org/soap/envelope/", "o": "urn:orders"}
+
+	tests := []struct {
+		expr     string
+		expected []string
+		passed   bool
+	}{
+		{"//OrderId/text()", []string{"ord-42"}, true},
+		{"/soap:Envelope/soap:Body/o:CreateOrderResponse/o:OrderId", []string{"ord-42"}, true},
+		{"//o:Item/@sku", []string{"a1", "b2", "c3"}, true},
+		{"//Item[2]", []string{"Notebook"}, true},
+		{"//Item[last()]/text()", []string{"Ink & Paper"}, true},
+		{"//Item[@qty > 2]/@sku", []string{"b2", "c3"}, true},
+		{"//Item[@sku='c3']/preceding-sibling::Item[1]/@sku", []string{"b2"}, true},
+		{"//Item[. = 'Pen']/following-sibling::*/@sku", []string{"b2", "c3"}, true},
+		{"//Total/@currency", []string{"EUR"}, true},
+		{"//Total/../@status", []string{"created"}, true},
+		{"//OrderId/ancestor::*[1]/@status", []string{"created"}, true},
+		{"//soap:Body/*/@*", []string{"created"}, true},
+		{"//Item/@sku | //Total/@currency", []string{"a1", "b2", "c3", "EUR"}, true},
+		{"(//Item)[position() > 1]/@sku", []string{"b2", "c3"}, true},
+		{"local-name(//o:*[1])", []string{"CreateOrderResponse"}, true},
+		{"namespace-uri(//OrderId)", []string{"urn:orders"}, true},
+		{"count(//Fault) = 0", []string{"true"}, true},
+		{"count(//Fault)", []string{"0"}, false},
+		{"count(//Item)", []string{"3"}, true},
+		{"sum(//Item/@qty) * 2", []string{"20"}, true},
+		{"//Total * 2 div 5", []string{"5"}, true},
+		{"11 mod 4 - -1", []string{"4"}, true},
+		{"//Total > 12 and //Total < 13", []string{"true"}, true},
+		{"//Total >= 13 or not(//OrderId)", []string{"false"}, false},
+		{"//OrderId = 'ord-42'", []string{"true"}, true},
+		{"//Item != 'Pen'", []string{"true"}, true},
+		{"//Item = //OrderId", []string{"false"}, false},
+		{"concat(//OrderId, '/', string(count(//Item)))", []string{"ord-42/3"}, true},
+		{"contains(//Item[3], '&') and starts-with(//OrderId, 'ord')", []string{"true"}, true},
+		{"normalize-space('  a   b ')", []string{"a b"}, true},
+		{"string-length(//OrderId)", []string{"6"}, true},
+		{"number(//OrderId)", []string{"NaN"}, false},
+		{"boolean(//Missing) = false()", []string{"true"}, true},
+		{"//soap:Fault", []string{}, false},
+		{"//o:Total", []string{}, false},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.expr, func(t *testing.T) {
+			t.Parallel()
+
+			x, err := Compile(test.expr, ns)
+			if err != nil {
+				t.Fatalf("Error occurred %v", err)
+			}
+			if v := x.Evaluate(doc); !reflect.DeepEqual(v, test.expected) {
+				t.Errorf("Expected %q, Found %q", test.expected, v)
+			}
+			if b := x.Bool(doc); b != test.passed {
+				t.Errorf("Bool Expected %v, Found %v", test.passed, b)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{
+		"",
+		"//",
+		"//Item[",
+		"//Item[1",
+		"count(//Item",
+		"unknown(//Item)",
+		"count()",
+		"//x:Item",
+		"//Item[@sku='a1]",
+		"$order/Item",
+		"//Item ! 2",
+		"//Item foo",
+		"namespace::x",
+		"comment()",
+	} {
+		if _, err := Compile(expr, map[string]string{"o": "urn:orders"}); err == nil {
+			t.Errorf("%q should be errored", expr)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		body      string
+		shouldErr bool
+	}{
+		{"Valid", "<a><b>1</b></a>", false},
+		{"Latin1", "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a>caf\xe9</a>", false},
+		{"Empty", "", true},
+		{"JSON", `{"order": 1}`, true},
+		{"Unclosed", "<a><b>1</a>", true},
+		{"TwoRoots", "<a/><b/>", true},
+		{"TextOutsideRoot", "<a/>trailing", true},
+		{"UnsupportedCharset", "<?xml version=\"1.0\" encoding=\"EBCDIC\"?><a/>", true},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse([]byte(test.body))
+			if test.shouldErr && err == nil {
+				t.Errorf("Should be errored")
+			}
+			if !test.shouldErr && err != nil {
+				t.Errorf("Error occurred %v", err)
+			}
+		})
+	}
+
+	doc, _ := Parse([]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a>caf\xe9</a>"))
+	x, _ := Compile("/a", nil)
+	if v := x.Evaluate(doc); !reflect.DeepEqual(v, []string{"café"}) {
+		t.Errorf("Latin1 text should be decoded, Found %q", v)
+	}
+}
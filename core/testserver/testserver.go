@@ -0,0 +1,498 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package testserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"go.ddosify.com/ddosify/core/util"
+)
+
+const (
+	// Upper bound of the latencies and the stream durations, so a typo in a parameter doesn't hang the clients
+	MaxLatency = time.Minute
+
+	// Upper bound of the body sizes
+	MaxBodySize = 10 << 20
+
+	// Upper bound of the redirect chains
+	MaxRedirects = 100
+
+	// Latency distributions of the random latency route
+	DistributionUniform     = "uniform"
+	DistributionNormal      = "normal"
+	DistributionExponential = "exponential"
+)
+
+// Config is used for creating the Server.
+type Config struct {
+	// Seed of the random latencies and failures, the current time is used if 0
+	Seed int64
+}
+
+// Server is an HTTP server with the endpoints exercising the features of Ddosify, like the latencies, the status
+// codes, the redirects, the compressed and the streamed bodies, the cookies and the conditional requests. It is the
+// target of the demos and the self-validation of the engine.
+type Server struct {
+	rand   *rand.Rand
+	mux    *http.ServeMux
+	routes []route
+}
+
+// Route is an endpoint of the Server, described by the routes endpoint.
+type Route struct {
+	Path        string  `json:"path"`
+	Description string  `json:"description"`
+	Params      []Param `json:"params,omitempty"`
+	Example     string  `json:"example"`
+}
+
+// Param is a query parameter of a Route.
+type Param struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default,omitempty"`
+}
+
+// route is a Route with its handler. An error of the handler is a bad request, like an invalid parameter.
+type route struct {
+	Route
+	handle func(s *Server, w http.ResponseWriter, r *http.Request) error
+}
+
+var routes = []route{
+	{Route{Path: "/", Description: "Responds 200 with a short text body", Example: "/"}, handleRoot},
+	{Route{Path: "/routes", Description: "Describes the routes of the server as JSON", Example: "/routes"},
+		handleRoutes},
+	{Route{Path: "/latency", Description: "Responds after a fixed latency",
+		Params:  []Param{{Name: "ms", Description: "Latency in milliseconds", Default: "100"}},
+		Example: "/latency?ms=250"}, handleLatency},
+	{Route{Path: "/latency/random", Description: "Responds after a random latency of the distribution",
+		Params: []Param{
+			{Name: "dist", Description: "Distribution of the latency, uniform, normal or exponential",
+				Default: DistributionUniform},
+			{Name: "min", Description: "Lower bound of the uniform latency in milliseconds", Default: "0"},
+			{Name: "max", Description: "Upper bound of the uniform latency in milliseconds", Default: "200"},
+			{Name: "mean", Description: "Mean of the normal and the exponential latencies in milliseconds",
+				Default: "100"},
+			{Name: "stddev", Description: "Standard deviation of the normal latency in milliseconds", Default: "20"},
+		},
+		Example: "/latency/random?dist=normal&mean=100&stddev=20"}, handleRandomLatency},
+	{Route{Path: "/status", Description: "Responds with the status code",
+		Params:  []Param{{Name: "code", Description: "Status code of the response", Default: "200"}},
+		Example: "/status?code=503"}, handleStatus},
+	{Route{Path: "/redirect", Description: "Redirects n times before responding 200",
+		Params: []Param{
+			{Name: "n", Description: "Count of the redirects left in the chain", Default: "3"},
+			{Name: "code", Description: "Status code of the redirects", Default: "302"},
+		},
+		Example: "/redirect?n=5"}, handleRedirect},
+	{Route{Path: "/bytes", Description: "Responds with a body of the size",
+		Params:  []Param{{Name: "size", Description: "Body size in bytes", Default: "1024"}},
+		Example: "/bytes?size=65536"}, handleBytes},
+	{Route{Path: "/gzip", Description: "Responds with a gzip compressed body",
+		Params:  []Param{{Name: "size", Description: "Body size in bytes before the compression", Default: "1024"}},
+		Example: "/gzip?size=65536"}, handleGzip},
+	{Route{Path: "/brotli", Description: "Responds with a brotli compressed body",
+		Params:  []Param{{Name: "size", Description: "Body size in bytes before the compression", Default: "1024"}},
+		Example: "/brotli?size=65536"}, handleBrotli},
+	{Route{Path: "/stream", Description: "Streams the body in chunks flushed at the interval",
+		Params: []Param{
+			{Name: "chunks", Description: "Count of the chunks", Default: "10"},
+			{Name: "size", Description: "Chunk size in bytes", Default: "64"},
+			{Name: "interval", Description: "Interval between the chunks in milliseconds", Default: "100"},
+		},
+		Example: "/stream?chunks=20&interval=50"}, handleStream},
+	{Route{Path: "/cookies/set", Description: "Sets the cookies of the query parameters and responds with them",
+		Example: "/cookies/set?session=abc&theme=dark"}, handleSetCookies},
+	{Route{Path: "/cookies", Description: "Responds with the cookies of the request as JSON", Example: "/cookies"},
+		handleCookies},
+	{Route{Path: "/etag", Description: "Responds with the ETag, 304 if the If-None-Match of the request matches it",
+		Params:  []Param{{Name: "tag", Description: "Value of the ETag", Default: "v1"}},
+		Example: "/etag?tag=v2"}, handleETag},
+	{Route{Path: "/fail", Description: "Fails at the rate with the status code, responds 200 otherwise",
+		Params: []Param{
+			{Name: "rate", Description: "Failure rate in [0, 1]", Default: "0.5"},
+			{Name: "code", Description: "Status code of the failures", Default: "500"},
+		},
+		Example: "/fail?rate=0.1"}, handleFail},
+	{Route{Path: "/echo", Description: "Responds with the method, the url, the headers and the body of the request",
+		Example: "/echo"}, handleEcho},
+}
+
+// New creates a Server.
+func New(cfg Config) *Server {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	s := &Server{rand: util.NewRand(seed), mux: http.NewServeMux(), routes: routes}
+	for _, rt := range s.routes {
+		rt := rt
+		s.mux.HandleFunc(rt.Path, func(w http.ResponseWriter, r *http.Request) {
+			// The root pattern matches all the paths without a route
+			if rt.Path == "/" && r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+			if err := rt.handle(s, w, r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		})
+	}
+	return s
+}
+
+// Routes returns the routes of the server sorted by their paths.
+func (s *Server) Routes() []Route {
+	rs := make([]Route, 0, len(s.routes))
+	for _, rt := range s.routes {
+		rs = append(rs, rt.Route)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Path < rs[j].Path })
+	return rs
+}
+
+// ListenAndServe runs the server on the given address until the context is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func handleRoot(_ *Server, w http.ResponseWriter, _ *http.Request) error {
+	fmt.Fprintln(w, "Ddosify test server, the routes are at /routes")
+	return nil
+}
+
+func handleRoutes(s *Server, w http.ResponseWriter, _ *http.Request) error {
+	return writeJSON(w, s.Routes())
+}
+
+func handleLatency(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	ms, err := floatParam(r, "ms", 100)
+	if err != nil {
+		return err
+	}
+	wait(r.Context(), msDuration(ms))
+	fmt.Fprintln(w, "ok")
+	return nil
+}
+
+func handleRandomLatency(s *Server, w http.ResponseWriter, r *http.Request) error {
+	var ms float64
+	switch dist := r.URL.Query().Get("dist"); dist {
+	case "", DistributionUniform:
+		min, err := floatParam(r, "min", 0)
+		if err != nil {
+			return err
+		}
+		max, err := floatParam(r, "max", 200)
+		if err != nil {
+			return err
+		}
+		if max < min {
+			return fmt.Errorf("max should not be less than min: %v < %v", max, min)
+		}
+		ms = min + s.rand.Float64()*(max-min)
+	case DistributionNormal:
+		mean, err := floatParam(r, "mean", 100)
+		if err != nil {
+			return err
+		}
+		stddev, err := floatParam(r, "stddev", 20)
+		if err != nil {
+			return err
+		}
+		ms = mean + s.rand.NormFloat64()*stddev
+	case DistributionExponential:
+		mean, err := floatParam(r, "mean", 100)
+		if err != nil {
+			return err
+		}
+		ms = s.rand.ExpFloat64() * mean
+	default:
+		return fmt.Errorf("dist should be one of uniform, normal and exponential: %s", dist)
+	}
+	d := msDuration(ms)
+	wait(r.Context(), d)
+	fmt.Fprintf(w, "%d\n", d.Milliseconds())
+	return nil
+}
+
+func handleStatus(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	code, err := statusParam(r, "code", http.StatusOK)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(code)
+	fmt.Fprintln(w, http.StatusText(code))
+	return nil
+}
+
+func handleRedirect(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	n, err := intParam(r, "n", 3, 0, MaxRedirects)
+	if err != nil {
+		return err
+	}
+	code, err := intParam(r, "code", http.StatusFound, 300, 399)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		fmt.Fprintln(w, "ok")
+		return nil
+	}
+	q := r.URL.Query()
+	q.Set("n", strconv.Itoa(n-1))
+	http.Redirect(w, r, "/redirect?"+q.Encode(), code)
+	return nil
+}
+
+func handleBytes(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	size, err := intParam(r, "size", 1024, 0, MaxBodySize)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body(size))
+	return nil
+}
+
+func handleGzip(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	return writeCompressed(w, r, "gzip", func(b *bytes.Buffer) io.WriteCloser { return gzip.NewWriter(b) })
+}
+
+func handleBrotli(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	return writeCompressed(w, r, "br", func(b *bytes.Buffer) io.WriteCloser { return brotli.NewWriter(b) })
+}
+
+// writeCompressed writes the body of the size parameter compressed by the encoding, whatever the Accept-Encoding
+// of the request is.
+func writeCompressed(w http.ResponseWriter, r *http.Request, encoding string,
+	newWriter func(*bytes.Buffer) io.WriteCloser) error {
+	size, err := intParam(r, "size", 1024, 0, MaxBodySize)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	cw := newWriter(&b)
+	cw.Write(body(size))
+	cw.Close()
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	w.Write(b.Bytes())
+	return nil
+}
+
+func handleStream(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	chunks, err := intParam(r, "chunks", 10, 0, MaxBodySize)
+	if err != nil {
+		return err
+	}
+	size, err := intParam(r, "size", 64, 0, MaxBodySize)
+	if err != nil {
+		return err
+	}
+	interval, err := floatParam(r, "interval", 100)
+	if err != nil {
+		return err
+	}
+	if chunks*size > MaxBodySize {
+		return fmt.Errorf("body of the stream should be at most %d bytes: %d", MaxBodySize, chunks*size)
+	}
+	if time.Duration(chunks)*msDuration(interval) > MaxLatency {
+		return fmt.Errorf("stream should take at most %v", MaxLatency)
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	flusher, _ := w.(http.Flusher)
+	chunk := body(size)
+	for i := 0; i < chunks; i++ {
+		if i > 0 && !wait(r.Context(), msDuration(interval)) {
+			return nil
+		}
+		w.Write(chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return nil
+}
+
+func handleSetCookies(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	cookies := make(map[string]string)
+	for name, values := range r.URL.Query() {
+		cookies[name] = values[0]
+		http.SetCookie(w, &http.Cookie{Name: name, Value: values[0], Path: "/"})
+	}
+	return writeJSON(w, cookies)
+}
+
+func handleCookies(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	cookies := make(map[string]string)
+	for _, c := range r.Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	return writeJSON(w, cookies)
+}
+
+func handleETag(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		tag = "v1"
+	}
+	etag := strconv.Quote(tag)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if t = strings.TrimPrefix(strings.TrimSpace(t), "W/"); t == etag || t == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+	fmt.Fprintf(w, "content of %s\n", tag)
+	return nil
+}
+
+func handleFail(s *Server, w http.ResponseWriter, r *http.Request) error {
+	rate, err := floatParam(r, "rate", 0.5)
+	if err != nil {
+		return err
+	}
+	if rate > 1 {
+		return fmt.Errorf("rate should be in [0, 1]: %v", rate)
+	}
+	code, err := statusParam(r, "code", http.StatusInternalServerError)
+	if err != nil {
+		return err
+	}
+	if s.rand.Float64() < rate {
+		w.WriteHeader(code)
+		fmt.Fprintln(w, http.StatusText(code))
+		return nil
+	}
+	fmt.Fprintln(w, "ok")
+	return nil
+}
+
+func handleEcho(_ *Server, w http.ResponseWriter, r *http.Request) error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, struct {
+		Method  string      `json:"method"`
+		URL     string      `json:"url"`
+		Headers http.Header `json:"headers"`
+		Body    string      `json:"body"`
+	}{r.Method, r.URL.String(), r.Header, string(b)})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(v)
+}
+
+// wait waits for the duration, it returns false if the request is canceled before.
+func wait(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// msDuration returns the milliseconds as a duration in [0, MaxLatency].
+func msDuration(ms float64) time.Duration {
+	if ms <= 0 {
+		return 0
+	}
+	if ms > float64(MaxLatency/time.Millisecond) {
+		return MaxLatency
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// body returns a text body of the size.
+func body(size int) []byte {
+	const line = "The quick brown fox jumps over the lazy dog.\n"
+	return []byte(strings.Repeat(line, size/len(line)+1)[:size])
+}
+
+func floatParam(r *http.Request, name string, def float64) (float64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%s should be a non-negative number: %s", name, v)
+	}
+	return f, nil
+}
+
+func intParam(r *http.Request, name string, def, min, max int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s should be an integer between %d and %d: %s", name, min, max, v)
+	}
+	return n, nil
+}
+
+func statusParam(r *http.Request, name string, def int) (int, error) {
+	return intParam(r, name, def, 200, 599)
+}
@@ -0,0 +1,278 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package testserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(New(Config{Seed: 1}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, client *http.Client, url string, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request to %s failed: %v", url, err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("body of %s can not be read: %v", url, err)
+	}
+	return res, b
+}
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+
+	_, b := get(t, server.Client(), server.URL+"/routes", nil)
+	var routes []Route
+	if err := json.Unmarshal(b, &routes); err != nil {
+		t.Fatalf("routes should be a JSON list: %v", err)
+	}
+	if len(routes) != len(New(Config{}).Routes()) {
+		t.Errorf("Expected %d routes, Found %d", len(New(Config{}).Routes()), len(routes))
+	}
+	for i, r := range routes {
+		if i > 0 && routes[i-1].Path >= r.Path {
+			t.Errorf("Routes should be sorted by their paths, Found %s before %s", routes[i-1].Path, r.Path)
+		}
+		if r.Description == "" || !strings.HasPrefix(r.Example, r.Path) {
+			t.Errorf("Route %s should have a description and an example of its path, Found %+v", r.Path, r)
+		}
+		// Examples are the valid requests of the routes
+		if res, _ := get(t, server.Client(), server.URL+r.Example, nil); res.StatusCode >= 400 &&
+			r.Path != "/status" {
+			t.Errorf("Example of %s failed with %d", r.Path, res.StatusCode)
+		}
+	}
+}
+
+func TestResponses(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		header     http.Header
+		statusCode int
+		body       string
+	}{
+		{"Root", "/", nil, http.StatusOK, "Ddosify test server, the routes are at /routes\n"},
+		{"NotFound", "/missing", nil, http.StatusNotFound, "404 page not found\n"},
+		{"Status", "/status?code=503", nil, http.StatusServiceUnavailable, "Service Unavailable\n"},
+		{"StatusDefault", "/status", nil, http.StatusOK, "OK\n"},
+		{"InvalidStatus", "/status?code=99", nil, http.StatusBadRequest,
+			"code should be an integer between 200 and 599: 99\n"},
+		{"Bytes", "/bytes?size=10", nil, http.StatusOK, "The quick "},
+		{"ETag", "/etag?tag=v2", nil, http.StatusOK, "content of v2\n"},
+		{"ETagMatch", "/etag?tag=v2", http.Header{"If-None-Match": {`"v1", W/"v2"`}}, http.StatusNotModified, ""},
+		{"ETagMismatch", "/etag", http.Header{"If-None-Match": {`"v2"`}}, http.StatusOK, "content of v1\n"},
+		{"FailNever", "/fail?rate=0", nil, http.StatusOK, "ok\n"},
+		{"FailAlways", "/fail?rate=1&code=502", nil, http.StatusBadGateway, "Bad Gateway\n"},
+		{"InvalidRate", "/fail?rate=2", nil, http.StatusBadRequest, "rate should be in [0, 1]: 2\n"},
+		{"InvalidLatency", "/latency?ms=-1", nil, http.StatusBadRequest,
+			"ms should be a non-negative number: -1\n"},
+		{"InvalidDistribution", "/latency/random?dist=pareto", nil, http.StatusBadRequest,
+			"dist should be one of uniform, normal and exponential: pareto\n"},
+		{"InvalidRange", "/latency/random?min=10&max=5", nil, http.StatusBadRequest,
+			"max should not be less than min: 5 < 10\n"},
+		{"Redirects", "/redirect?n=3", nil, http.StatusOK, "ok\n"},
+		{"TooManyRedirects", "/redirect?n=101", nil, http.StatusBadRequest,
+			"n should be an integer between 0 and 100: 101\n"},
+		{"Stream", "/stream?chunks=3&size=4&interval=1", nil, http.StatusOK, "The The The "},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			res, b := get(t, server.Client(), server.URL+test.path, test.header)
+			if res.StatusCode != test.statusCode {
+				t.Errorf("Expected status %d, Found %d", test.statusCode, res.StatusCode)
+			}
+			if string(b) != test.body {
+				t.Errorf("Expected body %q, Found %q", test.body, string(b))
+			}
+		})
+	}
+}
+
+func TestRedirectChain(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+
+	var chain []string
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		chain = append(chain, req.URL.RequestURI())
+		return nil
+	}}
+	if res, _ := get(t, client, server.URL+"/redirect?n=2&code=301", nil); res.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 at the end of the chain, Found %d", res.StatusCode)
+	}
+	expected := []string{"/redirect?code=301&n=1", "/redirect?code=301&n=0"}
+	if strings.Join(chain, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected the chain %v, Found %v", expected, chain)
+	}
+}
+
+func TestCompressedBodies(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+
+	tests := []struct {
+		path     string
+		encoding string
+		decode   func(io.Reader) (io.Reader, error)
+	}{
+		{"/gzip?size=5000", "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"/brotli?size=5000", "br", func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+	}
+
+	// The transport doesn't decompress the bodies it hasn't asked for
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	for _, test := range tests {
+		res, b := get(t, client, server.URL+test.path, nil)
+		if e := res.Header.Get("Content-Encoding"); e != test.encoding {
+			t.Errorf("%s: Expected the %s encoding, Found %q", test.path, test.encoding, e)
+		}
+		if len(b) >= 5000 || res.Header.Get("Content-Length") != strconv.Itoa(len(b)) {
+			t.Errorf("%s: Expected a compressed body with its length, Found %d bytes, Content-Length %s",
+				test.path, len(b), res.Header.Get("Content-Length"))
+		}
+		r, err := test.decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: body can not be decompressed: %v", test.path, err)
+		}
+		if d, _ := io.ReadAll(r); len(d) != 5000 || string(d[:10]) != "The quick " {
+			t.Errorf("%s: Expected 5000 bytes after the decompression, Found %d", test.path, len(d))
+		}
+	}
+}
+
+func TestCookies(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	get(t, client, server.URL+"/cookies/set?session=abc&theme=dark", nil)
+	_, b := get(t, client, server.URL+"/cookies", nil)
+	var cookies map[string]string
+	if err := json.Unmarshal(b, &cookies); err != nil {
+		t.Fatalf("cookies should be a JSON object: %v", err)
+	}
+	if len(cookies) != 2 || cookies["session"] != "abc" || cookies["theme"] != "dark" {
+		t.Errorf("Expected the cookies set by the server, Found %v", cookies)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/echo?q=1", strings.NewReader("payload"))
+	req.Header.Set("X-Test", "value")
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("echo request failed: %v", err)
+	}
+	defer res.Body.Close()
+	var echo struct {
+		Method  string
+		URL     string
+		Headers http.Header
+		Body    string
+	}
+	if err = json.NewDecoder(res.Body).Decode(&echo); err != nil {
+		t.Fatalf("echo should be a JSON object: %v", err)
+	}
+	if echo.Method != http.MethodPost || echo.URL != "/echo?q=1" || echo.Headers.Get("X-Test") != "value" ||
+		echo.Body != "payload" {
+		t.Errorf("Expected the request echoed, Found %+v", echo)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		min, max time.Duration
+	}{
+		{"Fixed", "/latency?ms=50", 50 * time.Millisecond, time.Second},
+		{"Uniform", "/latency/random?min=20&max=40", 20 * time.Millisecond, time.Second},
+		{"Normal", "/latency/random?dist=normal&mean=30&stddev=0", 30 * time.Millisecond, time.Second},
+		{"Exponential", "/latency/random?dist=exponential&mean=1", 0, time.Second},
+	}
+
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			start := time.Now()
+			res, _ := get(t, server.Client(), server.URL+test.path, nil)
+			if elapsed := time.Since(start); res.StatusCode != http.StatusOK || elapsed < test.min ||
+				elapsed > test.max {
+				t.Errorf("Expected 200 in [%v, %v], Found %d in %v", test.min, test.max, res.StatusCode, elapsed)
+			}
+		})
+	}
+}
+
+func TestFailRate(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if res, _ := get(t, server.Client(), server.URL+"/fail?rate=0.2", nil); res.StatusCode == 500 {
+			failed++
+		}
+	}
+	if failed < 150 || failed > 250 {
+		t.Errorf("Expected about 200 of 1000 requests to fail, Found %d", failed)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+const (
+	// Variants of the steps with the ab-variants option, an iteration runs all its steps on one of them
+	VariantA = "a"
+	VariantB = "b"
+
+	// Default share of the iterations running the b variants
+	DefaultABSplit = 0.5
+)
+
+// ABVariant is a target variant of a step in the A/B mode. The requests of the variant go to its base url and
+// carry its headers, the rest of the request is the one of the step.
+type ABVariant struct {
+	// Scheme and host replacing the ones of the step url, nil if the variant keeps the url of the step
+	BaseURL *url.URL
+
+	// Headers added to the headers of the step, overriding the ones with the same names
+	Headers map[string]string
+}
+
+// ParseABVariants parses the ab-variants option of a step, an object with the a and b variants like
+// {"a": {}, "b": {"base_url": "https://new.example.com", "headers": {"X-Impl": "new"}}}.
+func ParseABVariants(val interface{}) (map[string]ABVariant, error) {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, problemf(CodeInvalidType, "ab-variants should be an object with the variants a and b: %v", val)
+	}
+	for name := range obj {
+		if name != VariantA && name != VariantB {
+			return nil, problemf(CodeUnsupported, "unsupported ab-variants variant: %s, only a and b are supported",
+				name)
+		}
+	}
+
+	variants := make(map[string]ABVariant, 2)
+	for _, name := range []string{VariantA, VariantB} {
+		raw, isSet := obj[name]
+		if !isSet {
+			return nil, problemf(CodeRequired, "ab-variants should have the variant %s", name)
+		}
+		v, err := parseABVariant(raw)
+		if err != nil {
+			return nil, problemsAt(name, fmt.Sprintf("ab-variants variant %s: ", name), err)
+		}
+		variants[name] = v
+	}
+	return variants, nil
+}
+
+func parseABVariant(val interface{}) (v ABVariant, err error) {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return v, problemf(CodeInvalidType, "variant should be an object: %v", val)
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch k {
+		case "base_url":
+			raw, _ := obj[k].(string)
+			u, err := url.Parse(raw)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") ||
+				(u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+				return v, problemf(CodeInvalidValue, "base_url should be a url like https://api.example.com: %v",
+					obj[k])
+			}
+			if err = WireURL(u); err != nil {
+				return v, err
+			}
+			v.BaseURL = &url.URL{Scheme: u.Scheme, Host: u.Host}
+		case "headers":
+			headers, isObj := obj[k].(map[string]interface{})
+			if !isObj {
+				return v, problemf(CodeInvalidType, "headers should be an object of the header names and values: %v",
+					obj[k])
+			}
+			v.Headers = make(map[string]string, len(headers))
+			for name, value := range headers {
+				str, isStr := value.(string)
+				if !isStr || name == "" {
+					return v, problemf(CodeInvalidType, "header %s should have a string value: %v", name, value)
+				}
+				v.Headers[name] = str
+			}
+		default:
+			return v, problemf(CodeUnsupported, "unsupported key: %s", k)
+		}
+	}
+	return v, nil
+}
+
+// ParseABSplit parses the share of the iterations running the b variants, either a percentage like "30%" or
+// a ratio like 0.3.
+func ParseABSplit(val interface{}) (float64, error) {
+	split, ok := parseSampleRatio(val)
+	if !ok || split >= 1 {
+		return 0, problemf(CodeOutOfRange, "ab_split should be a ratio between 0 and 1 or a percentage: %v", val)
+	}
+	return split, nil
+}
+
+// HasABVariants reports whether any step of the scenario has the ab-variants option, so its iterations are assigned
+// to the variants.
+func (s *Scenario) HasABVariants() bool {
+	for _, st := range s.Steps {
+		if _, ok := st.Custom["ab-variants"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scenario) validateAB() error {
+	if s.ABSplit < 0 || s.ABSplit >= 1 {
+		return problemf(CodeOutOfRange, "ab_split should be a ratio between 0 and 1: %v", s.ABSplit)
+	}
+	if s.ABSplit != 0 && !s.HasABVariants() {
+		return problemf(CodeRequired, "ab_split can only be used with the steps that have ab-variants").
+			withFix("add ab-variants to a step, or remove the ab_split")
+	}
+	for _, st := range s.Teardown {
+		if _, ok := st.Custom["ab-variants"]; ok {
+			return problemf(CodeConflict, "teardown step %d can not have ab-variants, teardown steps don't run in the "+
+				"iterations", st.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import (
+	"time"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+const (
+	// Defaults of the adaptive-timeout option of a step
+	DefaultAdaptiveTimeoutFactor   = 3
+	DefaultAdaptiveTimeoutMin      = 100 * time.Millisecond
+	DefaultAdaptiveTimeoutInterval = 5 * time.Second
+
+	// Window of the rolling p99 that the adaptive timeouts are computed from
+	AdaptiveTimeoutWindow = 30 * time.Second
+)
+
+// AdaptiveTimeout is the adaptive timeout of the requests of a step. Every Interval, the timeout is set to Factor
+// times the rolling p99 of the successful requests of the step, bounded by Min and Max. Until there is a successful
+// request, the requests time out at Max.
+type AdaptiveTimeout struct {
+	Factor   float64
+	Min      time.Duration
+	Max      time.Duration
+	Interval time.Duration
+}
+
+// Timeout returns the timeout of the rolling p99.
+func (a AdaptiveTimeout) Timeout(p99 time.Duration) time.Duration {
+	t := time.Duration(a.Factor * float64(p99))
+	if t < a.Min {
+		return a.Min
+	}
+	if t > a.Max {
+		return a.Max
+	}
+	return t
+}
+
+// ParseAdaptiveTimeout parses the adaptive-timeout option of a step, either true for the defaults or an object like
+// {"factor": 3, "min": 200, "max": 5000, "interval": 5} with the bounds in milliseconds and the interval in seconds.
+// Max is the timeout of the step if it is not set, and can't be over it. A step without a timeout needs a max.
+func ParseAdaptiveTimeout(val interface{}, stepTimeout int) (AdaptiveTimeout, error) {
+	a := AdaptiveTimeout{
+		Factor:   DefaultAdaptiveTimeoutFactor,
+		Min:      DefaultAdaptiveTimeoutMin,
+		Max:      time.Duration(stepTimeout) * time.Second,
+		Interval: DefaultAdaptiveTimeoutInterval,
+	}
+	if enabled, isBool := val.(bool); isBool && enabled {
+		return a, a.validate(stepTimeout)
+	}
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return a, problemf(CodeInvalidType, "adaptive-timeout should be true or an object: %v", val)
+	}
+	for k, v := range obj {
+		n, isNum := util.ToFloat64(v)
+		switch k {
+		case "factor":
+			if !isNum || n < 1 {
+				return a, problemf(CodeOutOfRange, "adaptive-timeout factor should be a number of at least 1: %v", v)
+			}
+			a.Factor = n
+		case "min", "max":
+			if !isNum || n <= 0 {
+				return a, problemf(CodeInvalidValue, "adaptive-timeout %s should be a positive number of "+
+					"milliseconds: %v", k, v)
+			}
+			if k == "min" {
+				a.Min = time.Duration(n * float64(time.Millisecond))
+			} else {
+				a.Max = time.Duration(n * float64(time.Millisecond))
+			}
+		case "interval":
+			if !isNum || n < 1 {
+				return a, problemf(CodeOutOfRange, "adaptive-timeout interval should be a number of at least 1 "+
+					"second: %v", v)
+			}
+			a.Interval = time.Duration(n * float64(time.Second))
+		default:
+			return a, problemf(CodeUnsupported, "unsupported adaptive-timeout key: %s", k)
+		}
+	}
+	return a, a.validate(stepTimeout)
+}
+
+func (a AdaptiveTimeout) validate(stepTimeout int) error {
+	if a.Max <= 0 {
+		return problemf(CodeRequired, "adaptive-timeout needs a max if the step has no timeout")
+	}
+	if stepTimeout > 0 && a.Max > time.Duration(stepTimeout)*time.Second {
+		return problemf(CodeOutOfRange, "adaptive-timeout max can't be over the timeout of the step: %s", a.Max)
+	}
+	if a.Min > a.Max {
+		return problemf(CodeOutOfRange, "adaptive-timeout min can't be over its max: %s > %s", a.Min, a.Max)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"math"
+	"time"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+// Constants of the burst policies
+const (
+	// Next burst starts on time even if the previous one is still running
+	BurstPolicyOverlap = "overlap"
+
+	// Next burst is skipped if the previous one is still running
+	BurstPolicySkip = "skip"
+
+	DefaultBurstPolicy = BurstPolicyOverlap
+)
+
+var burstPolicies = [...]string{BurstPolicyOverlap, BurstPolicySkip}
+
+// LoadBurst fires Size iterations as fast as possible every Interval and idles in between, for testing the reaction
+// of the autoscalers to the traffic spikes. It is used by the burst load type.
+type LoadBurst struct {
+	// Iteration count of each burst
+	Size int
+
+	// Time between the starts of the bursts
+	Interval time.Duration
+
+	// Total burst count. 0 means the bursts are fired until the test duration is over.
+	Count int
+
+	// Upper bound of the running iterations of a burst, the rest of the burst waits for them. 0 means unlimited.
+	MaxInFlight int
+
+	// What happens if a burst is still running when the next one is due, overlap or skip.
+	// Empty means DefaultBurstPolicy.
+	Policy string
+}
+
+// TestDuration returns the test duration in seconds that fits Count bursts, the last burst is given a whole
+// interval to complete.
+func (b *LoadBurst) TestDuration() int {
+	return int(math.Ceil((time.Duration(b.Count) * b.Interval).Seconds()))
+}
+
+func (b *LoadBurst) validate() error {
+	if b.Size <= 0 {
+		return problemf(CodeOutOfRange, "burst size should be greater than 0")
+	}
+	if b.Interval <= 0 {
+		return problemf(CodeOutOfRange, "burst interval should be greater than 0")
+	}
+	if b.Count < 0 {
+		return problemf(CodeOutOfRange, "burst count should be greater than or equal to 0")
+	}
+	if b.MaxInFlight < 0 {
+		return problemf(CodeOutOfRange, "burst max in-flight should be greater than or equal to 0")
+	}
+	if b.Policy != "" && !util.StringInSlice(b.Policy, burstPolicies[:]) {
+		return problemf(CodeUnsupported, "unsupported burst policy: %s", b.Policy)
+	}
+	return nil
+}
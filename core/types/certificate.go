@@ -0,0 +1,36 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import "time"
+
+// Certificate is the leaf certificate of a target host, reported by the first TLS connection of a step to the host.
+type Certificate struct {
+	Host     string
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+
+	// DNS names and IP addresses of the subject alternative names
+	SANs []string
+
+	// Count of the certificates sent by the server, including the leaf
+	ChainLength int
+}
@@ -0,0 +1,88 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"regexp"
+	"sort"
+	"text/template/parse"
+)
+
+// composedBodyRegex matches the start of a template action that refers to a captured value, like {{ .order }}.
+// The other bodies are not parsed as templates, so their braces are sent as they are.
+var composedBodyRegex = regexp.MustCompile(`\{\{[^}]*\.[A-Za-z]`)
+
+// IsComposedBody reports whether the body of a step is a template composed from the captured values, like
+// {{ json_set .order "status" "CONFIRMED" }}.
+func IsComposedBody(payload string) bool {
+	return composedBodyRegex.MatchString(payload)
+}
+
+// ParseBodyCaptures returns the sorted names of the captured values that the composed body of a step refers to.
+// Returns nil if the body is not composed. The functions are checked when the template is compiled by the requester.
+func ParseBodyCaptures(payload string) ([]string, error) {
+	if !IsComposedBody(payload) {
+		return nil, nil
+	}
+
+	tree := parse.New("body")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(payload, "", "", make(map[string]*parse.Tree)); err != nil {
+		return nil, problemf(CodeInvalidSyntax, "invalid body template: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			if n.ElseList != nil {
+				walk(n.ElseList)
+			}
+		case *parse.FieldNode:
+			seen[n.Ident[0]] = true
+		}
+	}
+	walk(tree.Root)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,165 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+)
+
+const (
+	// Default currency of the prices of the cost option
+	DefaultCostCurrency = "USD"
+
+	// Bytes of a GB of the per_gb price, like the data transfer prices of the clouds
+	costGB = 1 << 30
+)
+
+// Cost is the pricing of the target, like the per-request and the data transfer prices of an API gateway or a
+// serverless platform. The load of the test is estimated before the run, and a test whose estimated cost is over the
+// Budget needs a confirmation to start.
+type Cost struct {
+	// Price of a request
+	PerRequest float64
+
+	// Price of a GB sent and received
+	PerGB float64
+
+	// Expected size of the responses in bytes, the received bytes are not estimated if it is 0
+	ResponseSize int64
+
+	// Upper bound of the estimated cost that starts without a confirmation. 0 means disabled.
+	Budget float64
+
+	// Currency of the prices, only displayed. Empty means DefaultCostCurrency.
+	Currency string
+}
+
+// Of returns the cost of the requests and the bytes sent and received by them.
+func (c *Cost) Of(requests, bytes int64) float64 {
+	return float64(requests)*c.PerRequest + float64(bytes)/costGB*c.PerGB
+}
+
+// Format returns the amount with the currency of the prices, the small amounts with their significant digits.
+func (c *Cost) Format(amount float64) string {
+	currency := c.Currency
+	if currency == "" {
+		currency = DefaultCostCurrency
+	}
+	if amount != 0 && math.Abs(amount) < 0.01 {
+		return fmt.Sprintf("%s %s", strconv.FormatFloat(amount, 'g', 3, 64), currency)
+	}
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
+
+func (c *Cost) validate() error {
+	if c.PerRequest < 0 || c.PerGB < 0 {
+		return problemf(CodeOutOfRange, "cost prices should be greater than or equal to 0")
+	}
+	if c.ResponseSize < 0 {
+		return problemf(CodeOutOfRange, "cost response size should be greater than or equal to 0")
+	}
+	if c.Budget < 0 {
+		return problemf(CodeOutOfRange, "cost budget should be greater than or equal to 0")
+	}
+	if c.Budget > 0 && c.PerRequest == 0 && c.PerGB == 0 {
+		return problemf(CodeRequired, "cost budget needs a per_request or a per_gb price")
+	}
+	return nil
+}
+
+// Estimate is the load of the test estimated before the run from the planned iterations and the steps.
+type Estimate struct {
+	// Planned iterations, at the max rate for the dynamic rate
+	Iterations int64
+
+	// Requests of the planned iterations and the teardown steps, without the retries, the hedges and the page
+	// resources
+	Requests int64
+
+	// Set if the iterations are an upper bound, like the ones of the dynamic rate
+	UpperBound bool
+
+	// Bytes of the request lines, the headers and the bodies of the steps, and of the responses of the expected size
+	BytesSent     int64
+	BytesReceived int64
+
+	// Pricing of the target, nil if the test has no cost
+	Cost *Cost
+}
+
+// NewEstimate returns the estimate of the planned iterations of the scenario.
+func NewEstimate(s Scenario, iterations int64, upperBound bool, c *Cost) Estimate {
+	var perIteration, teardown int64
+	for _, st := range s.Steps {
+		perIteration += requestSize(st)
+	}
+	for _, st := range s.Teardown {
+		teardown += requestSize(st)
+	}
+	e := Estimate{
+		Iterations: iterations,
+		Requests:   iterations*int64(len(s.Steps)) + int64(len(s.Teardown)),
+		UpperBound: upperBound,
+		BytesSent:  iterations*perIteration + teardown,
+		Cost:       c,
+	}
+	if c != nil {
+		e.BytesReceived = e.Requests * c.ResponseSize
+	}
+	return e
+}
+
+// Price returns the estimated cost, 0 if the test has no cost.
+func (e Estimate) Price() float64 {
+	if e.Cost == nil {
+		return 0
+	}
+	return e.Cost.Of(e.Requests, e.BytesSent+e.BytesReceived)
+}
+
+// OverBudget reports whether the estimated cost is over the budget of the test.
+func (e Estimate) OverBudget() bool {
+	return e.Cost != nil && e.Cost.Budget > 0 && e.Price() > e.Cost.Budget
+}
+
+// requestSize returns the bytes of the request of the step as configured, the templates are counted as they are.
+func requestSize(st ScenarioStep) int64 {
+	// Request line, like "GET /path HTTP/1.1\r\n"
+	n := len(st.Method) + len(st.URL) + len("  HTTP/1.1\r\n")
+	if u, err := url.Parse(st.URL); err == nil {
+		n += len("Host: \r\n") + len(u.Host)
+	}
+	for k, v := range st.Headers {
+		n += len(k) + len(v) + len(": \r\n")
+	}
+	for k, vs := range st.RepeatedHeaders {
+		for _, v := range vs {
+			n += len(k) + len(v) + len(": \r\n")
+		}
+	}
+	for _, h := range st.RawHeaders {
+		n += len(h) + len("\r\n")
+	}
+	n += len("\r\n") + len(st.Payload)
+	return int64(n)
+}
@@ -0,0 +1,187 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dependencyGroupPrefix is the prefix of the parallel groups of the steps ordered by their dependencies, followed by
+// the level of the steps. The steps of a level depend only on the steps of the previous levels.
+const dependencyGroupPrefix = "depends-on-"
+
+// OrderSteps sorts the steps by their DependsOn, so each step runs after the steps it depends on and the captures of
+// its dependencies are visible to it. The steps of the same level run in parallel as a parallel group, in their list
+// order. The steps are returned as they are if none of them has a dependency.
+func OrderSteps(steps []ScenarioStep) ([]ScenarioStep, error) {
+	ordered := false
+	for _, st := range steps {
+		ordered = ordered || len(st.DependsOn) > 0
+	}
+	if !ordered {
+		return steps, nil
+	}
+
+	deps := make([][]int, len(steps))
+	for i, st := range steps {
+		if st.ParallelGroup != "" {
+			return nil, problemf(CodeConflict, "step %d can not have a parallel_group with depends_on, the "+
+				"independent steps run in parallel", st.ID)
+		}
+		for _, ref := range st.DependsOn {
+			j, err := dependencyOf(steps, st, ref)
+			if err != nil {
+				return nil, err
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	// Level of a step is one more than the deepest level of its dependencies, found by a depth-first search
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	levels := make([]int, len(steps))
+	states := make([]int, len(steps))
+	var path []int
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case visited:
+			return nil
+		case visiting:
+			return problemf(CodeInvalidValue, "steps depend on each other in a cycle: %s",
+				dependencyCycle(steps, path, i)).withFix("remove a depends_on of the cycle")
+		}
+		states[i] = visiting
+		path = append(path, i)
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+			if levels[j]+1 > levels[i] {
+				levels[i] = levels[j] + 1
+			}
+		}
+		path = path[:len(path)-1]
+		states[i] = visited
+		return nil
+	}
+	for i := range steps {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	sizes := make(map[int]int)
+	indexes := make([]int, len(steps))
+	for i := range steps {
+		indexes[i] = i
+		sizes[levels[i]]++
+	}
+	sort.SliceStable(indexes, func(a, b int) bool { return levels[indexes[a]] < levels[indexes[b]] })
+
+	sorted := make([]ScenarioStep, 0, len(steps))
+	for _, i := range indexes {
+		st := steps[i]
+		if sizes[levels[i]] > 1 {
+			st.ParallelGroup = dependencyGroupPrefix + strconv.Itoa(levels[i]+1)
+		}
+		sorted = append(sorted, st)
+	}
+	return sorted, nil
+}
+
+// dependencyOf returns the index of the step that the dependency of st refers to, by its name or by its id.
+func dependencyOf(steps []ScenarioStep, st ScenarioStep, ref string) (int, error) {
+	found := -1
+	for i, o := range steps {
+		if o.Name != "" && o.Name == ref {
+			if found >= 0 {
+				return 0, problemf(CodeUnknownReference, "depends_on of step %d refers to an ambiguous step name: %s",
+					st.ID, ref).withFix("refer to the step by its id, or give the steps distinct names")
+			}
+			found = i
+		}
+	}
+	if found >= 0 {
+		return found, nil
+	}
+	if id, err := strconv.ParseUint(ref, 10, 16); err == nil {
+		for i, o := range steps {
+			if o.ID == uint16(id) {
+				return i, nil
+			}
+		}
+	}
+	return 0, problemf(CodeUnknownReference, "depends_on of step %d refers to an unknown step: %s", st.ID, ref).
+		withFix("refer to a step by its name or id")
+}
+
+// dependencyCycle returns the steps of the cycle from the step at index i on the search path back to it, like
+// "login -> cart -> login". The steps without a name are shown by their ids.
+func dependencyCycle(steps []ScenarioStep, path []int, i int) string {
+	start := 0
+	for k, p := range path {
+		if p == i {
+			start = k
+		}
+	}
+	names := make([]string, 0, len(path)-start+1)
+	for _, p := range append(path[start:], i) {
+		names = append(names, steps[p].label())
+	}
+	return strings.Join(names, " -> ")
+}
+
+// label returns the name of the step, or its id if it has no name.
+func (si *ScenarioStep) label() string {
+	if si.Name != "" {
+		return si.Name
+	}
+	return "step " + strconv.Itoa(int(si.ID))
+}
+
+// validateDependencies checks that the steps run after the steps they depend on, in the order of OrderSteps.
+func (s *Scenario) validateDependencies() error {
+	runs := make(map[int]int, len(s.Steps))
+	for g, r := range ParallelGroups(s.Steps) {
+		for i := r[0]; i < r[1]; i++ {
+			runs[i] = g
+		}
+	}
+	for i, st := range s.Steps {
+		for _, ref := range st.DependsOn {
+			j, err := dependencyOf(s.Steps, st, ref)
+			if err != nil {
+				return err
+			}
+			if runs[j] >= runs[i] {
+				return problemf(CodeInvalidValue, "step %d depends on %s which does not run before it",
+					st.ID, s.Steps[j].label())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultDynamicRateInterval is the poll interval of the dynamic rate source if it is not set.
+const DefaultDynamicRateInterval = 10 * time.Second
+
+// DynamicRate sets the iterations per second of the test from an external metric that is polled while the test
+// is running, either an HTTP endpoint returning a number or a PromQL query against a Prometheus server.
+type DynamicRate struct {
+	// HTTP endpoint whose response body is a number
+	URL string
+
+	// Base URL of the Prometheus server and the PromQL query evaluated on it, used instead of URL
+	Prometheus string
+	Query      string
+
+	// Arithmetic expression of the rate over the polled value, like "value * 2". Empty means the value itself.
+	Expression string
+
+	// Poll interval of the source. 0 means DefaultDynamicRateInterval.
+	Interval time.Duration
+
+	// Bounds of the iterations per second
+	Min float64
+	Max float64
+}
+
+func (d *DynamicRate) validate() error {
+	if (d.URL == "") == (d.Prometheus == "") {
+		return problemf(CodeRequired, "dynamic rate should have either a url or a prometheus source")
+	}
+	for _, u := range []string{d.URL, d.Prometheus} {
+		if u == "" {
+			continue
+		}
+		if p, err := url.Parse(u); err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+			return problemf(CodeInvalidValue, "invalid dynamic rate source: %s", u)
+		}
+	}
+	if d.Prometheus != "" && strings.TrimSpace(d.Query) == "" {
+		return problemf(CodeRequired, "dynamic rate query is empty")
+	}
+	if d.Prometheus == "" && d.Query != "" {
+		return problemf(CodeRequired, "dynamic rate query can only be used with a prometheus source")
+	}
+	if d.Interval < 0 {
+		return problemf(CodeOutOfRange, "dynamic rate interval should be greater than or equal to 0")
+	}
+	if d.Min < 0 {
+		return problemf(CodeOutOfRange, "dynamic rate min should be greater than or equal to 0")
+	}
+	if d.Max <= 0 || d.Max < d.Min {
+		return problemf(CodeOutOfRange, "dynamic rate max should be greater than 0 and the min")
+	}
+	if _, err := ParseRateExpression(d.Expression); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PollInterval returns the poll interval of the source.
+func (d *DynamicRate) PollInterval() time.Duration {
+	if d.Interval == 0 {
+		return DefaultDynamicRateInterval
+	}
+	return d.Interval
+}
+
+// Clamp bounds the rate by the min and max of the dynamic rate.
+func (d *DynamicRate) Clamp(rate float64) float64 {
+	if math.IsNaN(rate) || rate < d.Min {
+		return d.Min
+	}
+	if rate > d.Max {
+		return d.Max
+	}
+	return rate
+}
+
+// RateExpression is a parsed arithmetic expression over the polled value of the dynamic rate source.
+// Numbers, the value identifier, + - * / and parentheses are supported. Example: (value - 100) * 2
+type RateExpression struct {
+	root rateNode
+}
+
+// ParseRateExpression parses the expression of the dynamic rate, an empty expression is the value itself.
+func ParseRateExpression(expr string) (*RateExpression, error) {
+	if strings.TrimSpace(expr) == "" {
+		return &RateExpression{root: rateValue{}}, nil
+	}
+
+	p := &rateParser{expr: []rune(expr)}
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.expr) {
+		return nil, problemf(CodeInvalidSyntax, "invalid dynamic rate expression: unexpected %q at %d",
+			p.expr[p.pos], p.pos)
+	}
+	return &RateExpression{root: root}, nil
+}
+
+// Eval returns the rate for the given value. Division by zero results in an infinite or NaN rate,
+// which is bounded by the caller.
+func (r *RateExpression) Eval(value float64) float64 {
+	return r.root.eval(value)
+}
+
+type rateNode interface {
+	eval(value float64) float64
+}
+
+type rateValue struct{}
+
+func (rateValue) eval(value float64) float64 { return value }
+
+type rateNumber float64
+
+func (n rateNumber) eval(float64) float64 { return float64(n) }
+
+type rateNeg struct{ x rateNode }
+
+func (n rateNeg) eval(value float64) float64 { return -n.x.eval(value) }
+
+type rateBinary struct {
+	op   rune
+	l, r rateNode
+}
+
+func (n rateBinary) eval(value float64) float64 {
+	l, r := n.l.eval(value), n.r.eval(value)
+	switch n.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	default:
+		return l / r
+	}
+}
+
+// rateParser is a recursive descent parser of the rate expressions.
+type rateParser struct {
+	expr []rune
+	pos  int
+}
+
+func (p *rateParser) skipSpace() {
+	for p.pos < len(p.expr) && unicode.IsSpace(p.expr[p.pos]) {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space rune, 0 at the end of the expression.
+func (p *rateParser) peek() rune {
+	p.skipSpace()
+	if p.pos >= len(p.expr) {
+		return 0
+	}
+	return p.expr[p.pos]
+}
+
+func (p *rateParser) parseSum() (rateNode, error) {
+	l, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l = rateBinary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *rateParser) parseProduct() (rateNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = rateBinary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *rateParser) parseUnary() (rateNode, error) {
+	if p.peek() == '-' {
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return rateNeg{x: x}, nil
+	}
+	return p.parseOperand()
+}
+
+func (p *rateParser) parseOperand() (rateNode, error) {
+	c := p.peek()
+	start := p.pos
+	switch {
+	case c == 0:
+		return nil, problemf(CodeInvalidSyntax, "invalid dynamic rate expression: unexpected end")
+	case c == '(':
+		p.pos++
+		x, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, problemf(CodeInvalidSyntax, "invalid dynamic rate expression: missing ) of %d", start)
+		}
+		p.pos++
+		return x, nil
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.expr) && (unicode.IsDigit(p.expr[p.pos]) || p.expr[p.pos] == '.') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(string(p.expr[start:p.pos]), 64)
+		if err != nil {
+			return nil, problemf(CodeInvalidSyntax, "invalid dynamic rate expression: invalid number at %d", start)
+		}
+		return rateNumber(n), nil
+	case unicode.IsLetter(c):
+		for p.pos < len(p.expr) && (unicode.IsLetter(p.expr[p.pos]) || unicode.IsDigit(p.expr[p.pos]) || p.expr[p.pos] == '_') {
+			p.pos++
+		}
+		if ident := string(p.expr[start:p.pos]); ident != "value" {
+			return nil, problemf(CodeUnknownReference, "invalid dynamic rate expression: unknown identifier %s", ident).
+				withFix("refer to the polled value as value")
+		}
+		return rateValue{}, nil
+	}
+	return nil, problemf(CodeInvalidSyntax, "invalid dynamic rate expression: unexpected %q at %d", c, start)
+}
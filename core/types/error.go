@@ -25,13 +25,22 @@ import "fmt"
 // Constants for custom error types and reasons
 const (
 	// Types
-	ErrorProxy    = "proxyError"
-	ErrorConn     = "connectionError"
-	ErrorUnkown   = "unknownError"
-	ErrorIntented = "intentedError" // Errors for created intentionally
-	ErrorDns      = "dnsError"
-	ErrorParse    = "parseError"
-	ErrorAddr     = "addressError"
+	ErrorProxy      = "proxyError"
+	ErrorConn       = "connectionError"
+	ErrorUnkown     = "unknownError"
+	ErrorIntented   = "intentedError" // Errors for created intentionally
+	ErrorAssertion  = "assertionError"
+	ErrorDns        = "dnsError"
+	ErrorParse      = "parseError"
+	ErrorAddr       = "addressError"
+	ErrorTruncated  = "truncatedResponseError" // Connection is closed while reading the response body
+	ErrorDecompress = "decompressionError"     // Response body is not a valid stream of its content encoding
+	ErrorStatus     = "statusError"            // Status code is not one of the success_status of the step
+	ErrorProtobuf   = "protobufError"          // Body is not encodable to or decodable from its protobuf message
+	ErrorBodyLimit  = "bodyTooLargeError"      // Body is larger than the max_body_size of the step, it can't be checked
+	ErrorSign       = "signError"              // Sign command of the step failed, the request is not sent
+	ErrorStream     = "streamError"            // Stream of a streaming call failed after it is started
+	ErrorGrpcStatus = "grpcStatusError"        // gRPC call ended with a status other than OK
 
 	// Reasons
 	ReasonProxyFailed  = "proxy connection refused"
@@ -39,6 +48,13 @@ const (
 	ReasonConnTimeout  = "connection timeout"
 	ReasonReadTimeout  = "read timeout"
 	ReasonConnRefused  = "connection refused"
+	ReasonTruncated    = "truncated response"
+	ReasonStaleConn    = "stale connection" // Reused connection is closed by the server, the request is not retried
+
+	// Reasons of the stream errors
+	ReasonStreamReset    = "stream reset"             // Stream is reset by the peer with a RST_STREAM frame
+	ReasonStreamDeadline = "stream deadline exceeded" // Step timeout is over while the stream is read
+	ReasonStreamBroken   = "stream broken"            // Stream is ended by the connection or a malformed message
 
 	// In gracefully stop, engine cancels the ongoing requests.
 	// We can detect the canceled requests with the help of this.
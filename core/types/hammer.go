@@ -21,11 +21,19 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"go.ddosify.com/ddosify/core/clock"
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/live"
 	"go.ddosify.com/ddosify/core/proxy"
 	"go.ddosify.com/ddosify/core/util"
+	"go.ddosify.com/ddosify/core/warm"
 )
 
 // Constants for Hammer field values
@@ -34,6 +42,9 @@ const (
 	LoadTypeLinear      = "linear"
 	LoadTypeIncremental = "incremental"
 	LoadTypeWaved       = "waved"
+	LoadTypeSchedule    = "schedule"
+	LoadTypeBurst       = "burst"
+	LoadTypeVU          = "vu"
 
 	// Default Values
 	DefaultIterCount  = 100
@@ -43,9 +54,25 @@ const (
 	DefaultProtocol   = ProtocolHTTPS
 	DefaultMethod     = http.MethodGet
 	DefaultOutputType = "stdout" // TODO: get this value from report.OutputTypeStdout when import cycle resolved.
+
+	// Size limit of the response bodies read by the steps without a max_body_size
+	DefaultMaxBodySize = 10 << 20
+
+	// Constants of the headless progress log formats
+	ProgressFormatLogfmt = "logfmt"
+	ProgressFormatJSON   = "json"
+
+	DefaultProgressInterval = 10 * time.Second
+	DefaultProgressFormat   = ProgressFormatLogfmt
+
+	DefaultShutdownTimeout = 30 * time.Second
+
+	DefaultResultBlockWarning = time.Second
 )
 
-var loadTypes = [...]string{LoadTypeLinear, LoadTypeIncremental, LoadTypeWaved}
+var loadTypes = [...]string{LoadTypeLinear, LoadTypeIncremental, LoadTypeWaved, LoadTypeSchedule, LoadTypeBurst,
+	LoadTypeVU}
+var progressFormats = [...]string{ProgressFormatLogfmt, ProgressFormatJSON}
 
 // TimeRunCount is the data structure to store manual load type data.
 type TimeRunCount []struct {
@@ -53,6 +80,18 @@ type TimeRunCount []struct {
 	Count    int
 }
 
+// HeadlessOptions is the configuration of the headless output, used in the containers and the CI runners.
+type HeadlessOptions struct {
+	// Interval of the single-line progress logs. 0 disables the progress logs.
+	ProgressInterval time.Duration
+
+	// Format of the progress logs, logfmt or json.
+	ProgressFormat string
+
+	// Listen address of the HTTP liveness and progress endpoint, like ":8080". Empty means disabled.
+	HealthcheckAddr string
+}
+
 // Hammer is like a lighter for the engine.
 // It includes attack metadata and all necessary data to initialize the internal services in the engine.
 type Hammer struct {
@@ -82,27 +121,364 @@ type Hammer struct {
 
 	// Debug mode on/off
 	Debug bool
+
+	// Count of the scenario iterations to render and print without sending the requests. 0 means disabled.
+	PreviewCount int
+
+	// Count of the scenario iterations to run one per second with the strict checks, the result is reported as
+	// a pass/fail matrix instead of the load summary. 0 means disabled.
+	VerifyCount int
+
+	// Skips the connectivity probe of the targets before the test, for the targets that only accept traffic after
+	// a setup step of the test runs.
+	SkipPreflight bool
+
+	// Skips the echo of the settings of the run, with the secrets redacted, in the report header.
+	NoConfigEcho bool
+
+	// Replaces the hostnames and the IP addresses of the report with placeholders, hashes the query values of the
+	// URLs and redacts the header values, so the report can be shared externally.
+	Anonymize bool
+
+	// JSON report of a previous run that the result is compared with. Empty means disabled.
+	Compare string
+
+	// Compares the result with the baseline of Compare even if the load or the steps of the run differ from it.
+	ForceCompare bool
+
+	// Expression evaluated over the final result that decides the exit code of the test. Empty means disabled.
+	SuccessCriteria string
+
+	// Failed request count that stops the test, the results collected so far are reported. 0 means disabled.
+	StopAfterFailures int
+
+	// Total bytes sent and received over the connections that stops the test. 0 means disabled.
+	MaxTransfer int64
+
+	// Rules over the windows of the step results that stop the test once one holds, like
+	// "steps.2.p95 > 800ms over 1m for 3 windows".
+	AbortRules []string
+
+	// Duration that the report service is given to consume the remaining results once the result channel is closed.
+	// 0 means DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	// Capacity of the result channel between the engine and the report service. 0 means the upper bound of
+	// the iteration count of the test, so the iterations never wait the report service.
+	ResultBufferSize int
+
+	// Total time the iterations wait on the full result channel over which a warning is logged.
+	// 0 means DefaultResultBlockWarning.
+	ResultBlockWarning time.Duration
+
+	// Options of the headless output, only used by the headless report service.
+	Headless HeadlessOptions
+
+	// Template of the live summary line of the stdout output and of the progress logs of the headless output.
+	// Empty means the default line.
+	LiveTemplate string
+
+	// Run id, labels and build info attached to the records of the outputs.
+	Metadata Metadata
+
+	// Source of the iterations per second polled while the test is running, overrides the load type.
+	// nil means disabled.
+	DynamicRate *DynamicRate
+
+	// Target iterations per second by the time of day, used by the schedule load type. nil means disabled.
+	Schedule *LoadSchedule
+
+	// Size, interval and count of the bursts, used by the burst load type. nil means disabled.
+	Burst *LoadBurst
+
+	// Users and their iteration counts, used by the vu load type. nil means disabled.
+	VirtualUsers *VirtualUsers
+
+	// Source of the time of the engine, the sleeps of the steps and the report services, like a clock.Fake of
+	// a simulation. nil means the real clock. The requests are timed by the real clock in any case, except the
+	// duplicates of the duplicate_rate.
+	Clock clock.Clock
+
+	// Receives the results of the iterations besides the report service, like a report.CallbackOutput of a library
+	// consumer. nil means only the report service receives them.
+	ResultListener ResultListener
+
+	// Connection state carried across the runs of the process, like the runs of a suite, so the run takes the resolved
+	// hosts, the TLS sessions and the kept connections of the previous runs. nil means the run starts cold.
+	WarmState *warm.State
+
+	// Sketch of the duration percentiles of the steps and the limit of the exact percentiles
+	Percentiles PercentileSketch
+
+	// Pricing of the target that the cost of the test is estimated by before the run. nil means the estimate has
+	// no cost.
+	Cost *Cost
 }
 
-// Validate validates attack metadata and executes the validation methods of the services.
+// ResultListener receives the result of each iteration of the test once it completes. Dispatch is called by the
+// concurrent iterations and should not block them. Close is called once the iterations have completed, or once the
+// test is aborted; the iterations still in flight after an abort may call Dispatch after Close.
+type ResultListener interface {
+	Dispatch(r *ScenarioResult)
+	Close()
+}
+
+// Validate validates attack metadata and executes the validation methods of the services. The problems of the
+// whole config are returned together as ValidationErrors.
 func (h *Hammer) Validate() error {
+	var errs ValidationErrors
 	if len(h.Scenario.Steps) == 0 {
-		return fmt.Errorf("scenario or target is empty")
-	} else if err := h.Scenario.validate(); err != nil {
-		return err
+		errs.Add("steps", &ValidationError{
+			Code:    CodeRequired,
+			Message: "scenario or target is empty",
+			Fix:     "add a step to the steps of the config, or pass a target with -t",
+		})
+	} else {
+		errs = append(errs, h.Scenario.validate()...)
+	}
+
+	if h.Proxy.Addr != nil {
+		for i, st := range h.Scenario.Steps {
+			if _, ok := st.Custom["unix-socket"]; ok && st.Proxy == "" {
+				errs.Add(fmt.Sprintf("steps[%d].others.unix-socket", i),
+					problemf(CodeConflict, "unix-socket of step %d can't be used with a proxy", st.ID).
+						withFix("remove the proxy or the unix-socket of the step"))
+			}
+		}
 	}
 
 	if h.LoadType != "" && !util.StringInSlice(h.LoadType, loadTypes[:]) {
-		return fmt.Errorf("unsupported LoadType: %s", h.LoadType)
+		errs.Add("load_type", &ValidationError{
+			Code:    CodeUnsupported,
+			Message: fmt.Sprintf("unsupported LoadType: %s", h.LoadType),
+			Fix:     "use one of " + strings.Join(loadTypes[:], ", "),
+		})
 	}
 
-	if len(h.TimeRunCountMap) > 0 {
-		for _, t := range h.TimeRunCountMap {
-			if t.Duration < 1 {
-				return fmt.Errorf("duration in manual_load should be greater than 0")
-			}
+	if (h.LoadType == LoadTypeSchedule) != (h.Schedule != nil) {
+		errs.Add("schedule", &ValidationError{
+			Code:    CodeRequired,
+			Message: "schedule load type should be used with a schedule",
+			Fix:     "set both the schedule load_type and the schedule, or neither",
+		})
+	}
+	if h.Schedule != nil {
+		errs.Add("schedule", h.Schedule.validate())
+		if len(h.TimeRunCountMap) > 0 || h.DynamicRate != nil {
+			errs.Add("schedule",
+				problemf(CodeConflict, "schedule can not be used with the manual load or the dynamic rate"))
+		}
+	}
+
+	if (h.LoadType == LoadTypeBurst) != (h.Burst != nil) {
+		errs.Add("burst", &ValidationError{
+			Code:    CodeRequired,
+			Message: "burst load type should be used with a burst",
+			Fix:     "set both the burst load_type and the burst, or neither",
+		})
+	}
+	if h.Burst != nil {
+		errs.Add("burst", h.Burst.validate())
+		if len(h.TimeRunCountMap) > 0 || h.DynamicRate != nil {
+			errs.Add("burst", problemf(CodeConflict, "burst can not be used with the manual load or the dynamic rate"))
+		}
+	}
+
+	if (h.LoadType == LoadTypeVU) != (h.VirtualUsers != nil) {
+		errs.Add("vu", &ValidationError{
+			Code:    CodeRequired,
+			Message: "vu load type should be used with the vu",
+			Fix:     "set both the vu load_type and the vu, or neither",
+		})
+	}
+	if h.VirtualUsers != nil {
+		errs.Add("vu", h.VirtualUsers.validate())
+		if len(h.TimeRunCountMap) > 0 || h.DynamicRate != nil {
+			errs.Add("vu", problemf(CodeConflict, "vu can not be used with the manual load or the dynamic rate"))
 		}
 	}
 
+	errs.Add("percentiles", h.Percentiles.validate())
+
+	if h.Cost != nil {
+		errs.Add("cost", h.Cost.validate())
+	}
+
+	if h.PreviewCount < 0 {
+		errs.Add("preview", problemf(CodeOutOfRange, "preview count should be greater than or equal to 0"))
+	}
+
+	if h.PreviewCount > 0 && h.Debug {
+		errs.Add("debug", problemf(CodeConflict, "preview and debug modes can not be used together"))
+	}
+
+	if h.VerifyCount < 0 {
+		errs.Add("verify", problemf(CodeOutOfRange, "verify count should be greater than or equal to 0"))
+	}
+
+	if h.VerifyCount > 0 && (h.Debug || h.PreviewCount > 0) {
+		errs.Add("verify", problemf(CodeConflict, "verify mode can not be used with the debug or preview modes"))
+	}
+
+	for i, t := range h.TimeRunCountMap {
+		if t.Duration < 1 {
+			errs.Add(fmt.Sprintf("manual_load[%d].duration", i),
+				problemf(CodeOutOfRange, "duration in manual_load should be greater than 0"))
+		}
+	}
+
+	if h.StopAfterFailures < 0 {
+		errs.Add("stop_after_failures",
+			problemf(CodeOutOfRange, "stop after failures should be greater than or equal to 0"))
+	}
+
+	if h.MaxTransfer < 0 {
+		errs.Add("max_transfer", problemf(CodeOutOfRange, "max transfer should be greater than or equal to 0"))
+	}
+
+	if h.ShutdownTimeout < 0 {
+		errs.Add("shutdown_timeout", problemf(CodeOutOfRange, "shutdown timeout should be greater than or equal to 0"))
+	}
+
+	if h.ResultBufferSize < 0 {
+		errs.Add("result_buffer_size",
+			problemf(CodeOutOfRange, "result buffer size should be greater than or equal to 0"))
+	}
+
+	if h.ResultBlockWarning < 0 {
+		errs.Add("result_block_warning",
+			problemf(CodeOutOfRange, "result block warning should be greater than or equal to 0"))
+	}
+
+	if h.Headless.ProgressInterval < 0 {
+		errs.Add("progress_interval",
+			problemf(CodeOutOfRange, "progress interval should be greater than or equal to 0"))
+	}
+
+	if h.Headless.ProgressFormat != "" && !util.StringInSlice(h.Headless.ProgressFormat, progressFormats[:]) {
+		errs.Add("progress_format", &ValidationError{
+			Code:    CodeUnsupported,
+			Message: fmt.Sprintf("unsupported progress format: %s", h.Headless.ProgressFormat),
+			Fix:     "use one of " + strings.Join(progressFormats[:], ", "),
+		})
+	}
+
+	if h.LiveTemplate != "" {
+		if _, err := live.Parse(h.LiveTemplate); err != nil {
+			errs.Add("live_template", problemOf(CodeInvalidSyntax, err))
+		}
+	}
+
+	errs.Add("metadata", h.Metadata.validate())
+
+	if h.DynamicRate != nil {
+		errs.Add("dynamic_rate", h.DynamicRate.validate())
+		if h.Debug || h.PreviewCount > 0 || h.VerifyCount > 0 {
+			errs.Add("dynamic_rate",
+				problemf(CodeConflict, "dynamic rate can not be used with the debug, preview or verify modes"))
+		}
+		if len(h.TimeRunCountMap) > 0 {
+			errs.Add("dynamic_rate", problemf(CodeConflict, "dynamic rate can not be used with the manual load"))
+		}
+	}
+
+	if h.Compare != "" && (h.Debug || h.PreviewCount > 0 || h.VerifyCount > 0) {
+		errs.Add("compare",
+			problemf(CodeConflict, "compare can not be used with the debug, preview or verify modes"))
+	}
+	if h.ForceCompare && h.Compare == "" {
+		errs.Add("force_compare", problemf(CodeRequired, "force compare requires a baseline to compare with"))
+	}
+
+	if h.Anonymize && (h.Debug || h.PreviewCount > 0 || h.VerifyCount > 0) {
+		errs.Add("anonymize",
+			problemf(CodeConflict, "anonymize can not be used with the debug, preview or verify modes"))
+	}
+
+	if h.SuccessCriteria != "" {
+		c, err := criteria.Parse(h.SuccessCriteria)
+		err = criteriaProblem(err)
+		if err == nil {
+			err = validateCriteria(c, h.Scenario.Steps)
+		}
+		errs.Add("success_criteria", err)
+	}
+
+	for i, expr := range h.AbortRules {
+		path := fmt.Sprintf("abort_rules[%d]", i)
+		r, err := criteria.ParseAbortRule(expr)
+		err = criteriaProblem(err)
+		if err == nil {
+			_, err = ResolveAbortRuleStep(h.Scenario.Steps, r.Ident().Step)
+		}
+		errs.Add(path, err)
+		if h.Debug || h.PreviewCount > 0 || h.VerifyCount > 0 {
+			errs.Add(path,
+				problemf(CodeConflict, "abort rules can not be used with the debug, preview or verify modes"))
+			break
+		}
+	}
+
+	return errs.Err()
+}
+
+// criteriaProblem returns the parse error of the success criteria or an abort rule as a problem, an identifier that
+// refers to no metric is an unknown reference.
+func criteriaProblem(err error) error {
+	var unknown *criteria.UnknownIdentError
+	if errors.As(err, &unknown) {
+		return problemOf(CodeUnknownReference, err)
+	}
+	return problemOf(CodeInvalidSyntax, err)
+}
+
+// validateCriteria checks that the steps referred by the criteria exist in the scenario.
+// A step is referred by its name, or by its ID if no step has the same name.
+func validateCriteria(c *criteria.Criteria, steps []ScenarioStep) error {
+	for _, id := range c.Idents() {
+		if id.Step == "" {
+			continue
+		}
+		if _, err := ResolveCriteriaStep(steps, id.Step); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// ResolveCriteriaStep returns the ID of the step referred by name or by ID in the success criteria.
+func ResolveCriteriaStep(steps []ScenarioStep, ref string) (uint16, error) {
+	return resolveStepRef(steps, ref, "success criteria")
+}
+
+// ResolveAbortRuleStep returns the ID of the step referred by name or by ID in an abort rule.
+func ResolveAbortRuleStep(steps []ScenarioStep, ref string) (uint16, error) {
+	return resolveStepRef(steps, ref, "abort rule")
+}
+
+// resolveStepRef returns the ID of the step of the name, or of the ID if no step has the same name.
+func resolveStepRef(steps []ScenarioStep, ref, referrer string) (uint16, error) {
+	var found []uint16
+	for _, st := range steps {
+		if st.Name != "" && st.Name == ref {
+			found = append(found, st.ID)
+		}
+	}
+	if len(found) > 1 {
+		return 0, problemf(CodeUnknownReference, "%s refers to the ambiguous step name: %s", referrer, ref).
+			withFix("refer to the step by its id, or give the steps distinct names")
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+
+	if id, err := strconv.ParseUint(ref, 10, 16); err == nil {
+		for _, st := range steps {
+			if st.ID == uint16(id) {
+				return st.ID, nil
+			}
+		}
+	}
+	return 0, problemf(CodeUnknownReference, "%s refers to an unknown step: %s", referrer, ref).
+		withFix("refer to a step by its name or id")
+}
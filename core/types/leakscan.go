@@ -0,0 +1,188 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import (
+	"regexp"
+	"sort"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+const (
+	// Ratio of the responses scanned and the leading bytes of a body scanned, if the leak-scan of a step doesn't set
+	DefaultLeakScanSample  = 0.1
+	DefaultLeakScanMaxBody = 64 << 10
+)
+
+// LeakPattern is a named regular expression of the sensitive data searched in the response bodies.
+type LeakPattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// LeakPatterns are the default patterns of the leak scan by their names, the ones used if a step doesn't list its own.
+var LeakPatterns = map[string]*regexp.Regexp{
+	"stack-trace": regexp.MustCompile(`Traceback \(most recent call last\)|Exception in thread "|` +
+		`goroutine \d+ \[running\]|\bat [\w$.]+\([\w$]+\.(?:java|kt|scala):\d+\)|\bat [^\s()]+ \([^\s()]+\.js:\d+:\d+\)|` +
+		`System\.[\w.]+Exception|Fatal error: .{1,200} on line \d+|#\d+ /[\w/.-]+\.php\(\d+\)`),
+	"aws-key":     regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	"private-key": regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |ENCRYPTED )?PRIVATE KEY-----`),
+	"internal-ip": regexp.MustCompile(`\b(?:10\.\d{1,3}|172\.(?:1[6-9]|2\d|3[01])|192\.168)\.\d{1,3}\.\d{1,3}\b`),
+	"internal-host": regexp.MustCompile(
+		`(?i)\b[a-z0-9](?:[a-z0-9-]*[a-z0-9])?(?:\.[a-z0-9](?:[a-z0-9-]*[a-z0-9])?)*\.(?:internal|corp|intranet|lan)\b`),
+}
+
+// LeakScan scans a ratio of the response bodies of a step for the sensitive data, like the stack traces or the
+// internal hostnames of the error pages under load.
+type LeakScan struct {
+	Patterns []LeakPattern
+
+	// Ratio of the responses scanned, evenly spread over the test
+	Sample float64
+
+	// Leading bytes of a body scanned, the rest of the body is not scanned
+	MaxBody int64
+}
+
+// LeakMatch is the first match of a pattern in a response body, with its context in the body.
+type LeakMatch struct {
+	Pattern string
+	Excerpt string
+}
+
+// LeakScanResult is the scan of a response body, in the "leakScan" custom of the step result. Matches is nil if no
+// pattern matches.
+type LeakScanResult struct {
+	URL     string
+	Matches []LeakMatch
+}
+
+// ParseLeakScan parses the leak-scan option of a step, either true for the default patterns, a list of the patterns
+// or an object like {"patterns": [...], "sample": "10%", "max_body": "64KB"}. A pattern is the name of a default
+// pattern like "stack-trace" or an object like {"name": "jwt", "pattern": "eyJ[\\w-]+\\.[\\w-]+"}.
+// The patterns are compiled here, so an invalid one fails the config validation. False means no scan and nil is
+// returned.
+func ParseLeakScan(val interface{}) (*LeakScan, error) {
+	s := &LeakScan{Sample: DefaultLeakScanSample, MaxBody: DefaultLeakScanMaxBody}
+	var patterns interface{}
+	switch v := val.(type) {
+	case bool:
+		if !v {
+			return nil, nil
+		}
+	case []interface{}:
+		patterns = v
+	case map[string]interface{}:
+		for k := range v {
+			if !util.StringInSlice(k, []string{"patterns", "sample", "max_body"}) {
+				return nil, problemf(CodeUnsupported, "unsupported leak-scan option: %s", k)
+			}
+		}
+		patterns = v["patterns"]
+		if sample, ok := v["sample"]; ok {
+			if s.Sample, ok = parseSampleRatio(sample); !ok {
+				return nil, problemf(CodeOutOfRange, "leak-scan sample should be a ratio between 0 and 1 or a "+
+					"percentage: %v", sample)
+			}
+		}
+		if max, ok := v["max_body"]; ok {
+			var err error
+			if s.MaxBody, err = parseLeakScanMaxBody(max); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, problemf(CodeInvalidType, "leak-scan should be true, a list of patterns or an object: %v", val)
+	}
+
+	var err error
+	if s.Patterns, err = parseLeakPatterns(patterns); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseLeakPatterns parses the list of the patterns, nil means all the default patterns in the order of their names.
+func parseLeakPatterns(val interface{}) ([]LeakPattern, error) {
+	if val == nil {
+		names := make([]string, 0, len(LeakPatterns))
+		for name := range LeakPatterns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		patterns := make([]LeakPattern, len(names))
+		for i, name := range names {
+			patterns[i] = LeakPattern{Name: name, Regexp: LeakPatterns[name]}
+		}
+		return patterns, nil
+	}
+
+	list, ok := val.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, problemf(CodeInvalidValue, "leak-scan patterns should be a non-empty list: %v", val)
+	}
+	patterns := make([]LeakPattern, 0, len(list))
+	names := make(map[string]bool, len(list))
+	for _, p := range list {
+		var lp LeakPattern
+		switch v := p.(type) {
+		case string:
+			re, ok := LeakPatterns[v]
+			if !ok {
+				return nil, problemf(CodeUnsupported, "unsupported leak-scan pattern: %s", v)
+			}
+			lp = LeakPattern{Name: v, Regexp: re}
+		case map[string]interface{}:
+			name, _ := v["name"].(string)
+			expr, _ := v["pattern"].(string)
+			if name == "" || expr == "" {
+				return nil, problemf(CodeRequired, "leak-scan pattern should have a name and a pattern: %v", p)
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, problemf(CodeInvalidSyntax, "leak-scan pattern %s is not valid: %v", name, err)
+			}
+			lp = LeakPattern{Name: name, Regexp: re}
+		default:
+			return nil, problemf(CodeInvalidType, "leak-scan pattern should be a default pattern name or an object: %v",
+				p)
+		}
+		if names[lp.Name] {
+			return nil, problemf(CodeDuplicate, "leak-scan has the pattern %s more than once", lp.Name)
+		}
+		names[lp.Name] = true
+		patterns = append(patterns, lp)
+	}
+	return patterns, nil
+}
+
+// parseLeakScanMaxBody parses the max_body of the leak-scan, either a byte count or a byte size like "64KB".
+func parseLeakScanMaxBody(val interface{}) (int64, error) {
+	n, ok := util.ToFloat64(val)
+	if str, isStr := val.(string); isStr {
+		size, err := ParseByteSize(str)
+		n, ok = float64(size), err == nil
+	}
+	if !ok || n < 1 {
+		return 0, problemf(CodeInvalidValue, "leak-scan max_body should be a positive byte size like \"64KB\": %v", val)
+	}
+	return int64(n), nil
+}
@@ -0,0 +1,142 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	MaxLabelKeyLength   = 64
+	MaxLabelValueLength = 256
+
+	// RedactedLabelValue replaces the value of the secret labels in the outputs.
+	RedactedLabelValue = "[REDACTED]"
+
+	// Starts of the runs with a warm state, a warm run takes the connection state of a previous run
+	StartWarm = "warm"
+	StartCold = "cold"
+)
+
+var labelKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// Label tags the outputs of a run, like the commit or the environment of the test.
+type Label struct {
+	Key   string
+	Value string
+
+	// Value of a secret label is redacted in the outputs, only the key is shown.
+	Secret bool
+}
+
+// Metadata identifies the run in the records of the outputs, so the results of many runs can be told apart.
+type Metadata struct {
+	// Unique id of the run, generated by the engine if empty
+	RunID string
+
+	Labels []Label
+
+	// SHA-256 of the config file, empty if the test is not run with a config file
+	ConfigHash string
+
+	// Version of the ddosify binary
+	Version string
+
+	// Hostname of the machine running the test, read by the engine if empty
+	Hostname string
+
+	// StartWarm or StartCold set by the engine if the run has a warm state, empty otherwise
+	Start string
+}
+
+// ParseLabel parses the label in the key=value format.
+func ParseLabel(s string, secret bool) (Label, error) {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return Label{}, problemf(CodeInvalidSyntax, "label should be in the key=value format: %s", s)
+	}
+	return Label{Key: k, Value: v, Secret: secret}, nil
+}
+
+// SetLabel adds the label, or replaces the label with the same key.
+func (m *Metadata) SetLabel(l Label) {
+	for i := range m.Labels {
+		if m.Labels[i].Key == l.Key {
+			m.Labels[i] = l
+			return
+		}
+	}
+	m.Labels = append(m.Labels, l)
+}
+
+// RedactedLabels returns the label values by their keys, the values of the secret labels are redacted.
+func (m Metadata) RedactedLabels() map[string]string {
+	if len(m.Labels) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(m.Labels))
+	for _, l := range m.Labels {
+		labels[l.Key] = l.Value
+		if l.Secret {
+			labels[l.Key] = RedactedLabelValue
+		}
+	}
+	return labels
+}
+
+// SortedLabels returns the redacted labels as key=value pairs sorted by their keys.
+func (m Metadata) SortedLabels() []string {
+	pairs := make([]string, 0, len(m.Labels))
+	for k, v := range m.RedactedLabels() {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func (m Metadata) validate() error {
+	keys := make(map[string]bool, len(m.Labels))
+	for _, l := range m.Labels {
+		if len(l.Key) == 0 || len(l.Key) > MaxLabelKeyLength {
+			return problemf(CodeOutOfRange, "label key should be between 1 and %d characters: %q",
+				MaxLabelKeyLength, l.Key)
+		}
+		if !labelKeyRegexp.MatchString(l.Key) {
+			return problemf(CodeInvalidValue, "label key can only have letters, digits, '_', '.' and '-': %q", l.Key)
+		}
+		if keys[l.Key] {
+			return problemf(CodeDuplicate, "duplicate label key: %s", l.Key).withFix("give each label a distinct key")
+		}
+		keys[l.Key] = true
+
+		if len(l.Value) > MaxLabelValueLength {
+			return problemf(CodeOutOfRange, "value of the label %s should be at most %d characters",
+				l.Key, MaxLabelValueLength)
+		}
+		if strings.IndexFunc(l.Value, unicode.IsControl) != -1 {
+			return problemf(CodeInvalidValue, "value of the label %s can not have control characters", l.Key)
+		}
+	}
+	return nil
+}
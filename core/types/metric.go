@@ -0,0 +1,34 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+// MetricMeta describes a duration or a count that a requester reports in the Custom field of its step results.
+// Requesters declare their metrics in the display order, so the reports render the keys of any protocol.
+type MetricMeta struct {
+	// Key of the metric in the Custom field, like "dnsDuration"
+	Key string
+
+	// Display name in the stdout report, like "DNS"
+	Name string
+
+	// Key of the metric in the JSON report, like "dns"
+	JSONKey string
+}
@@ -0,0 +1,148 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+// NetworkShaping simulates the network conditions of the clients, like the mobile ones, on the requests of a step.
+type NetworkShaping struct {
+	// Throughput caps of each connection in bytes per second, 0 means unlimited
+	Download int64
+	Upload   int64
+
+	// Delay added before each request is written. Jitter is the upper bound of the random delay on top of Latency.
+	Latency time.Duration
+	Jitter  time.Duration
+}
+
+// NetworkPresets are the network conditions by their names, like the throttling presets of the browser devtools.
+var NetworkPresets = map[string]NetworkShaping{
+	"slow-3g": {Download: 50000, Upload: 50000, Latency: 2000 * time.Millisecond},
+	"fast-3g": {Download: 180000, Upload: 84375, Latency: 563 * time.Millisecond},
+}
+
+// bitRateUnits are the multipliers of the bit rate units.
+var bitRateUnits = map[string]float64{
+	"BPS":  1,
+	"KBPS": 1e3,
+	"MBPS": 1e6,
+	"GBPS": 1e9,
+}
+
+// ParseBitRate parses a bit rate like "400kbps" or "1.5Mbps" and returns it in bytes per second.
+func ParseBitRate(v string) (int64, error) {
+	v = strings.TrimSpace(v)
+	i := strings.IndexFunc(v, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		return 0, problemf(CodeInvalidValue, "bit rate should have a unit like kbps or Mbps: %s", v)
+	}
+
+	n, err := strconv.ParseFloat(v[:i], 64)
+	unit, ok := bitRateUnits[strings.ToUpper(strings.TrimSpace(v[i:]))]
+	if err != nil || !ok || n <= 0 {
+		return 0, problemf(CodeInvalidValue, "invalid bit rate: %s", v)
+	}
+	rate := int64(n * unit / 8)
+	if rate < 1 {
+		return 0, problemf(CodeOutOfRange, "bit rate should be at least 8bps: %s", v)
+	}
+	return rate, nil
+}
+
+// ParseNetworkShaping parses the network option of a step, either a preset name like "fast-3g" or an object like
+// {"preset": "fast-3g", "download": "1Mbps", "upload": "500kbps", "latency": 300, "jitter": 50}.
+// Latency and jitter are in milliseconds, the given fields override the ones of the preset.
+func ParseNetworkShaping(val interface{}) (n NetworkShaping, err error) {
+	if name, ok := val.(string); ok {
+		return networkPreset(name)
+	}
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return n, problemf(CodeInvalidType, "network should be a preset name or an object: %v", val)
+	}
+
+	if p, ok := m["preset"]; ok {
+		name, isStr := p.(string)
+		if !isStr {
+			return n, problemf(CodeInvalidType, "network preset should be a string: %v", p)
+		}
+		if n, err = networkPreset(name); err != nil {
+			return
+		}
+	}
+	for _, k := range []string{"download", "upload"} {
+		v, ok := m[k]
+		if !ok {
+			continue
+		}
+		str, isStr := v.(string)
+		if !isStr {
+			return n, problemf(CodeInvalidValue, "network %s should be a bit rate like \"400kbps\": %v", k, v)
+		}
+		rate, err := ParseBitRate(str)
+		if err != nil {
+			return n, err
+		}
+		if k == "download" {
+			n.Download = rate
+		} else {
+			n.Upload = rate
+		}
+	}
+	for _, k := range []string{"latency", "jitter"} {
+		v, ok := m[k]
+		if !ok {
+			continue
+		}
+		ms, isNum := util.ToFloat64(v)
+		if !isNum || ms < 0 {
+			return n, problemf(CodeInvalidValue, "network %s should be a non-negative duration in milliseconds: %v",
+				k, v)
+		}
+		if k == "latency" {
+			n.Latency = time.Duration(ms * float64(time.Millisecond))
+		} else {
+			n.Jitter = time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	for k := range m {
+		if !util.StringInSlice(k, []string{"preset", "download", "upload", "latency", "jitter"}) {
+			return n, problemf(CodeUnsupported, "unsupported network option: %s", k)
+		}
+	}
+	return
+}
+
+func networkPreset(name string) (NetworkShaping, error) {
+	n, ok := NetworkPresets[strings.ToLower(name)]
+	if !ok {
+		return n, problemf(CodeUnsupported, "unsupported network preset: %s", name)
+	}
+	return n, nil
+}
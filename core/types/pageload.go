@@ -0,0 +1,102 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import (
+	"time"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+const (
+	// Defaults of the page-load option of a step, the concurrency is the connection limit per host of the browsers
+	DefaultPageLoadConcurrency  = 6
+	DefaultPageLoadMaxResources = 50
+	DefaultPageLoadDepth        = 1
+
+	// Upper bounds of the page-load option
+	MaxPageLoadResources = 1000
+	MaxPageLoadDepth     = 5
+)
+
+// PageLoad is the page-load option of a step. The HTML page of the step is parsed for its images, scripts and
+// stylesheets, and they are fetched like a browser loads the page. Only the resources of the origin of the page
+// are fetched unless CrossOrigin is set. Depth 1 fetches the resources of the page, each level over it fetches the
+// resources of the stylesheets of the previous one. MaxResources bounds the fetched resources of a page at all the
+// levels, and Concurrency the resources fetched at the same time.
+type PageLoad struct {
+	CrossOrigin  bool
+	Concurrency  int
+	MaxResources int
+	Depth        int
+}
+
+// PageResource is a resource fetched while loading the page of a step.
+type PageResource struct {
+	URL         string
+	ContentType string
+	StatusCode  int
+	Duration    time.Duration
+	Bytes       int64
+	Failed      bool
+}
+
+// ParsePageLoad parses the page-load option of a step, either true for the defaults or an object like
+// {"cross_origin": true, "concurrency": 6, "max_resources": 50, "depth": 2}.
+func ParsePageLoad(val interface{}) (PageLoad, error) {
+	p := PageLoad{
+		Concurrency:  DefaultPageLoadConcurrency,
+		MaxResources: DefaultPageLoadMaxResources,
+		Depth:        DefaultPageLoadDepth,
+	}
+	if enabled, isBool := val.(bool); isBool && enabled {
+		return p, nil
+	}
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return p, problemf(CodeInvalidType, "page-load should be true or an object: %v", val)
+	}
+	for k, v := range obj {
+		if k == "cross_origin" {
+			if p.CrossOrigin, ok = v.(bool); !ok {
+				return p, problemf(CodeInvalidType, "page-load cross_origin should be a boolean: %v", v)
+			}
+			continue
+		}
+		var target *int
+		var max int
+		switch k {
+		case "concurrency":
+			target, max = &p.Concurrency, MaxPageLoadResources
+		case "max_resources":
+			target, max = &p.MaxResources, MaxPageLoadResources
+		case "depth":
+			target, max = &p.Depth, MaxPageLoadDepth
+		default:
+			return p, problemf(CodeUnsupported, "unsupported page-load key: %s", k)
+		}
+		n, isNum := util.ToFloat64(v)
+		if !isNum || n < 1 || n > float64(max) || n != float64(int(n)) {
+			return p, problemf(CodeOutOfRange, "page-load %s should be an integer between 1 and %d: %v", k, max, v)
+		}
+		*target = int(n)
+	}
+	return p, nil
+}
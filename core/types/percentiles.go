@@ -0,0 +1,117 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import "go.ddosify.com/ddosify/core/util"
+
+// Constants of the sketches of the step percentiles
+const (
+	// Logarithmic buckets 2% wide, the percentiles are accurate within 1%
+	SketchLog = "log"
+
+	// HDR histogram with the significant digits of the durations, accurate within 10^-digits
+	SketchHDR = "hdr"
+
+	// T-digest with a compression factor, accurate at the tails with a small memory
+	SketchTDigest = "tdigest"
+
+	DefaultSketch = SketchLog
+
+	DefaultSignificantDigits = 3
+	MaxSignificantDigits     = 5
+
+	DefaultCompression = 100
+	MinCompression     = 20
+	MaxCompression     = 1000
+
+	// Upper bound of the exact limit, the raw durations of a step take 8 bytes each
+	MaxExactLimit = 10000000
+)
+
+var sketches = [...]string{SketchLog, SketchHDR, SketchTDigest}
+
+// PercentileSketch selects how the duration percentiles of the steps are estimated. The zero value is the SketchLog
+// without the exact percentiles.
+type PercentileSketch struct {
+	// SketchLog, SketchHDR or SketchTDigest. Empty means DefaultSketch.
+	Kind string
+
+	// Significant digits of the SketchHDR. 0 means DefaultSignificantDigits.
+	SignificantDigits int
+
+	// Compression factor of the SketchTDigest. 0 means DefaultCompression.
+	Compression int
+
+	// Steps of up to ExactLimit successful requests report the exact percentiles of their raw durations, the sketch is
+	// used once a step has more. 0 means the percentiles are always estimated by the sketch.
+	ExactLimit int
+}
+
+// Sketch returns the kind of the sketch, DefaultSketch if it is not set.
+func (s PercentileSketch) Sketch() string {
+	if s.Kind == "" {
+		return DefaultSketch
+	}
+	return s.Kind
+}
+
+// Digits returns the significant digits of the SketchHDR, DefaultSignificantDigits if they are not set.
+func (s PercentileSketch) Digits() int {
+	if s.SignificantDigits == 0 {
+		return DefaultSignificantDigits
+	}
+	return s.SignificantDigits
+}
+
+// CompressionFactor returns the compression of the SketchTDigest, DefaultCompression if it is not set.
+func (s PercentileSketch) CompressionFactor() int {
+	if s.Compression == 0 {
+		return DefaultCompression
+	}
+	return s.Compression
+}
+
+func (s PercentileSketch) validate() error {
+	if s.Kind != "" && !util.StringInSlice(s.Kind, sketches[:]) {
+		return problemf(CodeUnsupported, "unsupported percentiles sketch: %s", s.Kind)
+	}
+	if s.SignificantDigits != 0 {
+		if s.Sketch() != SketchHDR {
+			return problemf(CodeConflict, "percentiles significant_digits can only be used with the hdr sketch")
+		}
+		if s.SignificantDigits < 1 || s.SignificantDigits > MaxSignificantDigits {
+			return problemf(CodeOutOfRange, "percentiles significant_digits should be between 1 and %d",
+				MaxSignificantDigits)
+		}
+	}
+	if s.Compression != 0 {
+		if s.Sketch() != SketchTDigest {
+			return problemf(CodeConflict, "percentiles compression can only be used with the tdigest sketch")
+		}
+		if s.Compression < MinCompression || s.Compression > MaxCompression {
+			return problemf(CodeOutOfRange, "percentiles compression should be between %d and %d",
+				MinCompression, MaxCompression)
+		}
+	}
+	if s.ExactLimit < 0 || s.ExactLimit > MaxExactLimit {
+		return problemf(CodeOutOfRange, "percentiles exact_limit should be between 0 and %d", MaxExactLimit)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"strings"
+
+	"go.ddosify.com/ddosify/core/scenario/protobuf"
+)
+
+// ProtobufContentType is the content type of the protobuf request bodies if the step does not set one
+const ProtobufContentType = "application/x-protobuf"
+
+// ProtobufPayload is the Protocol Buffers schema of the bodies of a step. The payload of the step is the JSON
+// representation of Message, it is rendered like the other payloads and then encoded to the wire format on each
+// request. The protobuf responses are decoded to the JSON of ResponseMessage for the captures and the assertions.
+type ProtobufPayload struct {
+	// Path of the .proto file, or of the descriptor set compiled by protoc for the other extensions
+	File string
+
+	// Full names of the message types like "users.v1.CreateUser", either of them can be empty
+	Message         string
+	ResponseMessage string
+}
+
+// Messages loads the message types of the request and the response, nil if they are not set.
+func (p *ProtobufPayload) Messages() (request, response *protobuf.Message, err error) {
+	files, err := protobuf.Load(p.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Message != "" {
+		if request, err = files.Message(p.Message); err != nil {
+			return nil, nil, err
+		}
+	}
+	if p.ResponseMessage != "" {
+		if response, err = files.Message(p.ResponseMessage); err != nil {
+			return nil, nil, err
+		}
+	}
+	return request, response, nil
+}
+
+// validate loads the message types of the step. The static payloads are encoded once, so their errors fail the
+// validation instead of each request.
+func (p *ProtobufPayload) validate(si *ScenarioStep) error {
+	if p.File == "" {
+		return problemf(CodeInvalidValue, "payload_protobuf proto should be a file path")
+	}
+	if p.Message == "" && p.ResponseMessage == "" {
+		return problemf(CodeRequired, "payload_protobuf should have a message or a response_message")
+	}
+	request, _, err := p.Messages()
+	if err != nil {
+		return err
+	}
+	if request != nil && (si.RawPayload || !strings.Contains(si.Payload, "{{")) {
+		if _, err := request.EncodeJSON([]byte(si.Payload)); err != nil {
+			return problemf(CodeInvalidValue, "payload_protobuf json of step %d can not be encoded: %v", si.ID, err)
+		}
+	}
+	return nil
+}
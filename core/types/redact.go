@@ -0,0 +1,190 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"reflect"
+	"strings"
+)
+
+// RedactedValue replaces the secrets in the config echo of the report.
+const RedactedValue = "***"
+
+// Names of the headers, query parameters and fields whose values are secrets, matched in lower case without the
+// dashes and underscores. secretNames are matched as a part of the name, secretExactNames as the whole name.
+var (
+	secretNames = []string{"authorization", "token", "password", "passwd", "secret", "apikey", "accesskey",
+		"privatekey", "cookie", "session", "credential", "signature"}
+	secretExactNames = []string{"key", "pass", "pwd", "sig", "auth"}
+)
+
+// IsSecretName reports whether the header, query parameter or field with the name carries a secret.
+func IsSecretName(name string) bool {
+	n := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	for _, s := range secretExactNames {
+		if n == s {
+			return true
+		}
+	}
+	for _, s := range secretNames {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL redacts the password of the user info and the values of the secret query parameters of the url.
+// The url is not parsed, so the dynamic variables in it are kept as they are.
+func RedactURL(u string) string {
+	rest, fragment, hasFragment := strings.Cut(u, "#")
+	base, query, hasQuery := strings.Cut(rest, "?")
+
+	if scheme, hostPart, ok := strings.Cut(base, "://"); ok {
+		authority, path, hasPath := strings.Cut(hostPart, "/")
+		if at := strings.LastIndex(authority, "@"); at >= 0 {
+			if user, _, ok := strings.Cut(authority[:at], ":"); ok {
+				authority = user + ":" + RedactedValue + authority[at:]
+			} else {
+				// Tokens are passed as the username, like https://token@host
+				authority = RedactedValue + authority[at:]
+			}
+		}
+		base = scheme + "://" + authority
+		if hasPath {
+			base += "/" + path
+		}
+	}
+
+	if hasQuery {
+		params := strings.Split(query, "&")
+		for i, p := range params {
+			if k, v, ok := strings.Cut(p, "="); ok && v != "" && IsSecretName(k) {
+				params[i] = k + "=" + RedactedValue
+			}
+		}
+		base += "?" + strings.Join(params, "&")
+	}
+	if hasFragment {
+		base += "#" + fragment
+	}
+	return base
+}
+
+// RedactHeaders returns a copy of the headers with the values of the secret headers redacted.
+func RedactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if IsSecretName(k) && v != "" {
+			v = RedactedValue
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
+// RedactHeaderValues is RedactHeaders of the headers with multiple values.
+func RedactHeaderValues(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string][]string, len(headers))
+	for k, values := range headers {
+		if IsSecretName(k) {
+			values = append([]string{}, values...)
+			for i, v := range values {
+				if v != "" {
+					values[i] = RedactedValue
+				}
+			}
+		}
+		redacted[k] = values
+	}
+	return redacted
+}
+
+// Redact redacts the fields of the struct v points to by their secret tags, so a field added with the tag is
+// redacted wherever the struct is reported:
+//
+//	secret:"true"     the value of a string field, the values of a map[string]string field
+//	secret:"url"      a url string or a slice of them, by RedactURL
+//	secret:"headers"  a map[string]string of the headers, by RedactHeaders, or a map[string][]string of them
+//
+// The nested structs, the pointers to them and the slices of them are redacted too. Maps are replaced with their
+// redacted copies, so the maps shared with the config are not modified.
+func Redact(v interface{}) {
+	redactValue(reflect.ValueOf(v), "")
+}
+
+func redactValue(v reflect.Value, tag string) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			redactValue(v.Elem(), tag)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				redactValue(f, t.Field(i).Tag.Get("secret"))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			redactValue(v.Index(i), tag)
+		}
+	case reflect.String:
+		if !v.CanSet() || v.String() == "" {
+			return
+		}
+		switch tag {
+		case "true":
+			v.SetString(RedactedValue)
+		case "url":
+			v.SetString(RedactURL(v.String()))
+		}
+	case reflect.Map:
+		if !v.CanSet() {
+			return
+		}
+		if m, ok := v.Interface().(map[string][]string); ok && m != nil && tag == "headers" {
+			v.Set(reflect.ValueOf(RedactHeaderValues(m)))
+			return
+		}
+		m, ok := v.Interface().(map[string]string)
+		if !ok || m == nil {
+			return
+		}
+		switch tag {
+		case "true":
+			redacted := make(map[string]string, len(m))
+			for k := range m {
+				redacted[k] = RedactedValue
+			}
+			v.Set(reflect.ValueOf(redacted))
+		case "headers":
+			v.Set(reflect.ValueOf(RedactHeaders(m)))
+		}
+	}
+}
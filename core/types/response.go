@@ -21,6 +21,7 @@
 package types
 
 import (
+	"net/http"
 	"net/url"
 	"time"
 
@@ -32,6 +33,9 @@ type ScenarioResult struct {
 	// First request start time for the Scenario
 	StartTime time.Time
 
+	// Wall time of the iteration from the start of its first step to the end of the sleep of its last step
+	Duration time.Duration
+
 	ProxyAddr   *url.URL
 	StepResults []*ScenarioStepResult
 
@@ -50,6 +54,15 @@ type ScenarioStepResult struct {
 	// Each request has a unique ID.
 	RequestID uuid.UUID
 
+	// Sequence number of the iteration of the request in the run and the ID of its virtual user, the values of the
+	// {{_iterationId}} and {{_vuId}} variables. Zero for the previews and the teardown steps.
+	IterationID uint64
+	VUID        uint64
+
+	// Variant of the iteration of the request in the A/B mode, VariantA or VariantB. Empty if the scenario has no
+	// steps with ab-variants.
+	Variant string
+
 	// Returned status code. Has different meaning for different protocols.
 	StatusCode int
 
@@ -65,9 +78,79 @@ type ScenarioStepResult struct {
 	// Error occurred at request time.
 	Err RequestError
 
+	// Proxy that the request is sent over, the proxy of the step if it overrides the proxy of the iteration.
+	// nil for the direct requests.
+	ProxyAddr *url.URL
+
 	// Detailed Debug Info
 	DebugInfo map[string]interface{}
 
 	// Protocol spesific metrics. For ex: DNSLookupDuration: 1s for HTTP
 	Custom map[string]interface{}
 }
+
+// RedirectHop is a request of a redirect chain, the url that it is sent to and the status code of its response.
+type RedirectHop struct {
+	URL        string `json:"url" anonymize:"url"`
+	StatusCode int    `json:"status_code"`
+}
+
+// DuplicateResult is the duplicate of a request sent twice by the duplicate_rate of its step, in the "duplicate"
+// custom of the step result. The duplicate is compared with the response of the original request, the bodies by
+// their hashes.
+type DuplicateResult struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        RequestError
+
+	StatusMatch bool
+	BodyMatch   bool
+}
+
+// Clone returns a deep copy of the step result, to keep it beyond the call that it is passed to. The maps of the
+// debug info and the custom metrics are copied with their slices, maps and headers.
+func (r *ScenarioStepResult) Clone() *ScenarioStepResult {
+	c := *r
+	if r.ProxyAddr != nil {
+		u := *r.ProxyAddr
+		c.ProxyAddr = &u
+	}
+	c.DebugInfo = cloneValues(r.DebugInfo)
+	c.Custom = cloneValues(r.Custom)
+	return &c
+}
+
+func cloneValues(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = cloneValue(v)
+	}
+	return c
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		return append([]byte(nil), val...)
+	case []string:
+		return append([]string(nil), val...)
+	case []RedirectHop:
+		return append([]RedirectHop(nil), val...)
+	case []PageResource:
+		return append([]PageResource(nil), val...)
+	case http.Header:
+		return val.Clone()
+	case map[string]interface{}:
+		return cloneValues(val)
+	case map[string]string:
+		c := make(map[string]string, len(val))
+		for k, s := range val {
+			c[k] = s
+		}
+		return c
+	}
+	return v
+}
@@ -26,10 +26,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
-	validator "github.com/asaskevich/govalidator"
+	"go.ddosify.com/ddosify/core/scenario/jsonschema"
+	"go.ddosify.com/ddosify/core/scenario/xpath"
 	"go.ddosify.com/ddosify/core/util"
 )
 
@@ -42,11 +47,65 @@ const (
 	// Constants of the Auth types
 	AuthHttpBasic = "basic"
 
+	// Proxy of the step that sends its requests directly, overriding the proxy of the test
+	StepProxyNone = "none"
+
 	// Max sleep in ms (90s)
 	maxSleep = 90000
+
+	// Constants of the Retry-After handling modes for rate limited (429) responses
+	RetryAfterSleep  = "sleep"
+	RetryAfterReport = "report"
+
+	// Upper bound of the backoff applied in RetryAfterSleep mode
+	MaxRetryAfterSleep = maxSleep * time.Millisecond
+
+	// Upper bound of the sleeps resolved from the captured values
+	MaxSleep = maxSleep * time.Millisecond
+
+	// Constants of the orders of reading the targets file
+	TargetsOrderSequential = "sequential"
+	TargetsOrderRandom     = "random"
+
+	// Group of the targets that don't match any of the url-groups rules
+	CatchAllURLGroup = "(other)"
+
+	// Report dimension values of the responses without the header, and of the new values after the limit
+	MissingDimensionValue       = "(none)"
+	OverflowDimensionValue      = "(other)"
+	DefaultReportDimensionLimit = 50
+
+	// Distinct hosts reported per step, the new hosts after the limit are reported under OverflowHost
+	OverflowHost           = "(other)"
+	DefaultReportHostLimit = 100
+
+	// Final url of the redirected requests reported after the distinct final urls of a step reach the limit
+	OverflowRedirectURL = "(other)"
+
+	// Content type of the page resources without the header, and of the new content types after the limit
+	MissingContentType  = "(none)"
+	OverflowContentType = "(other)"
+
+	// Default values of the request capture
+	DefaultCaptureFile     = "ddosify_captures.ndjson"
+	DefaultCaptureFailures = 10
+
+	// Upper bound of the connections established per step before the test
+	MaxPrewarmConnections = 10000
+
+	// Source of the capture rules that capture the whole response body, and the default upper bound of its size
+	CaptureFromBody        = "body"
+	DefaultCaptureBodySize = 1 << 20
+
+	// Constants of the scopes of the connections. The connections of an iteration are closed at its end, the ones of
+	// a virtual user are kept across the iterations it runs, and the global ones are shared by all the iterations.
+	ConnectionScopeIteration = "iteration"
+	ConnectionScopeUser      = "user"
+	ConnectionScopeGlobal    = "global"
+	DefaultConnectionScope   = ConnectionScopeGlobal
 )
 
-// SupportedProtocols should be updated whenever a new requester.Requester interface implemented
+// SupportedProtocols are the protocols of the built-in requesters, the others are registered by requester.Register
 var SupportedProtocols = [...]string{ProtocolHTTP, ProtocolHTTPS}
 var supportedProtocolMethods = map[string][]string{
 	ProtocolHTTP: {
@@ -58,6 +117,18 @@ var supportedProtocolMethods = map[string][]string{
 		http.MethodPatch, http.MethodHead, http.MethodOptions,
 	},
 }
+
+// registeredProtocols are the protocols of the requesters registered out of the tree, by their upper case names.
+// The requester of a registered protocol validates its own methods, so any method is valid for these.
+var registeredProtocols = make(map[string]bool)
+
+var retryAfterModes = [...]string{RetryAfterSleep, RetryAfterReport}
+var targetsOrders = [...]string{TargetsOrderSequential, TargetsOrderRandom}
+var connectionScopes = [...]string{ConnectionScopeIteration, ConnectionScopeUser, ConnectionScopeGlobal}
+var streamLimits = [...]string{"stream-max-bytes", "stream-max-chunks", "stream-max-duration"}
+var sleepTemplateRegex = regexp.MustCompile(`^\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}$`)
+var captureNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+var xmlPrefixRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
 var supportedAuthentications = map[string][]string{
 	ProtocolHTTP: {
 		AuthHttpBasic,
@@ -67,26 +138,262 @@ var supportedAuthentications = map[string][]string{
 	},
 }
 
+// RegisterProtocol makes the protocol valid for the steps. It is called by requester.Register, the requesters of the
+// protocols should be registered there instead.
+func RegisterProtocol(protocol string) {
+	registeredProtocols[strings.ToUpper(protocol)] = true
+}
+
+// isRegisteredProtocol reports whether the protocol is registered by RegisterProtocol.
+func isRegisteredProtocol(protocol string) bool {
+	return registeredProtocols[strings.ToUpper(protocol)]
+}
+
 // Scenario struct contains a list of ScenarioStep so scenario.ScenarioService can execute the scenario step by step.
 type Scenario struct {
 	Steps []ScenarioStep
+
+	// Seed of all the random decisions made while running the scenario. The same seed reproduces the same decisions.
+	// Generated by the engine if not set.
+	Seed int64
+
+	// Sampling of the iterations whose full request and response detail is recorded during the test
+	Capture Capture
+
+	// Scope of the connections of the steps, one of the ConnectionScope constants. DefaultConnectionScope if empty.
+	ConnectionScope string
+
+	// Steps run once after the test, like the cleanup of the test data over the values collected by the steps
+	Teardown []ScenarioStep
+
+	// Upper bound of the values collected for each collected capture, DefaultCollectLimit if 0
+	CollectLimit int
+
+	// Share of the iterations running the b variants of the steps with ab-variants, DefaultABSplit if 0
+	ABSplit float64
 }
 
-func (s *Scenario) validate() error {
-	stepIds := make(map[uint16]struct{}, len(s.Steps))
-	for _, st := range s.Steps {
+// Capture is the sampling configuration of the iterations whose full request and response detail is written to
+// a NDJSON file while the other iterations stay on the lean path.
+type Capture struct {
+	// Ratio of the sampled iterations in [0, 1]
+	Rate float64
+
+	// Upper limit of the sampled iterations, 0 means no limit.
+	// If Rate is not set, the engine derives it from the iteration count of the test.
+	Count int
+
+	// First Failures failed requests of each error type are captured even if their iteration is not sampled.
+	// Only the failures are captured if neither Rate nor Count is set.
+	Failures int
+
+	// Path of the NDJSON file
+	File string
+}
+
+// Enabled reports whether any request is captured.
+func (c Capture) Enabled() bool {
+	return c.Rate > 0 || c.Count > 0 || c.Failures > 0
+}
+
+func (c Capture) validate() error {
+	if c.Rate < 0 || c.Rate > 1 {
+		return problemf(CodeOutOfRange, "capture_rate should be between 0%% and 100%%")
+	}
+	if c.Count < 0 {
+		return problemf(CodeOutOfRange, "capture_count should be greater than or equal to 0")
+	}
+	if c.Failures < 0 {
+		return problemf(CodeOutOfRange, "capture_failures should be greater than or equal to 0")
+	}
+	if c.Enabled() && c.File == "" {
+		return problemf(CodeRequired, "capture file should be set")
+	}
+	return nil
+}
+
+// ParseCaptureRate parses the sampling ratio of the capture, either a percentage like "0.1%" or a ratio like "0.001".
+func ParseCaptureRate(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	percentage := strings.HasSuffix(v, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	if err != nil {
+		return 0, problemf(CodeInvalidValue, "invalid capture_rate: %s", v)
+	}
+	if percentage {
+		rate /= 100
+	}
+	return rate, nil
+}
+
+// byteUnits are the multipliers of the byte size units, decimal like the metered egress and binary.
+var byteUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// ParseByteSize parses a byte size like "50GB", "512MiB" or "1000".
+func ParseByteSize(v string) (int64, error) {
+	v = strings.TrimSpace(v)
+	i := strings.IndexFunc(v, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(v)
+	}
+
+	n, err := strconv.ParseFloat(v[:i], 64)
+	unit, ok := byteUnits[strings.ToUpper(strings.TrimSpace(v[i:]))]
+	if err != nil || !ok || n < 0 {
+		return 0, problemf(CodeInvalidValue, "invalid byte size: %s", v)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+func (s *Scenario) validate() ValidationErrors {
+	var errs ValidationErrors
+	errs.Add("capture_rate", s.Capture.validate())
+	if s.ConnectionScope != "" && !util.StringInSlice(s.ConnectionScope, connectionScopes[:]) {
+		errs.Add("connection_scope", &ValidationError{
+			Code:    CodeUnsupported,
+			Message: fmt.Sprintf("unsupported connection_scope: %s", s.ConnectionScope),
+			Fix:     "use one of " + strings.Join(connectionScopes[:], ", "),
+		})
+	}
+	errs.Add("ab_split", s.validateAB())
+	errs.Add("steps", s.validateDependencies())
+
+	// Steps are validated one by one, the problems between the steps are only looked for once each step is valid
+	var stepErrs ValidationErrors
+	for i, st := range s.Steps {
 		if err := st.validate(); err != nil {
-			return err
+			stepErrs.Add(fmt.Sprintf("steps[%d]", i), err)
+		}
+	}
+	if stepErrs == nil {
+		stepErrs.Add("steps", s.validateFlow())
+	}
+	errs = append(errs, stepErrs...)
+	errs.Add("teardown", s.validateTeardown())
+	return errs
+}
+
+// validateFlow validates the steps together, the order of their captures and their parallel groups. The problems
+// are at the paths of the steps, relative to the steps.
+func (s *Scenario) validateFlow() error {
+	stepIds := make(map[uint16]struct{}, len(s.Steps))
+	captured := make(map[string]bool)
+	groups := make(map[string]bool)
+	for _, g := range ParallelGroups(s.Steps) {
+		group := s.Steps[g[0]:g[1]]
+		if name := group[0].ParallelGroup; name != "" {
+			if groups[name] {
+				return problemf(CodeInvalidValue, "steps of parallel group %s should be consecutive",
+					name).at(fmt.Sprintf("[%d].parallel_group", g[0]))
+			}
+			groups[name] = true
+		}
+
+		// Values captured by a step of a parallel group are visible to the steps after the group
+		groupCaptured := make(map[string]uint16)
+		sleeps := 0
+		for j, st := range group {
+			path := fmt.Sprintf("[%d]", g[0]+j)
+
+			// Url and body of a step are composed before its request, so they can only refer to the values of
+			// the previous steps
+			names, err := st.composedCaptures()
+			if err != nil {
+				return problemsAt(path, "", err)
+			}
+			for _, name := range names {
+				if !captured[name] {
+					return problemf(CodeUnknownReference, "step %d refers to a value that is not captured by a "+
+						"previous step: %s", st.ID, name).at(path).
+						withFix("capture the value in a step that runs before it")
+				}
+			}
+
+			captures, _ := ParseStepCaptures(st.Custom["capture"])
+			for _, c := range captures {
+				if id, ok := groupCaptured[c.Name]; ok && len(group) > 1 {
+					return problemf(CodeDuplicate, "steps %d and %d of parallel group %s capture the same value: %s",
+						id, st.ID, st.ParallelGroup, c.Name).at(path + ".others.capture")
+				}
+				groupCaptured[c.Name] = st.ID
+			}
+			if st.Sleep != "" {
+				sleeps++
+			}
+
+			if _, ok := stepIds[st.ID]; ok {
+				return problemf(CodeDuplicate, "duplicate step id: %d", st.ID).at(path + ".id").
+					withFix("give each step a distinct id")
+			}
+			stepIds[st.ID] = struct{}{}
+		}
+		if sleeps > 1 {
+			return problemf(CodeConflict, "only one step of parallel group %s can have a sleep, it runs after the "+
+				"group", group[0].ParallelGroup).at(fmt.Sprintf("[%d]", g[0]))
+		}
+		for name := range groupCaptured {
+			captured[name] = true
 		}
 
-		if _, ok := stepIds[st.ID]; ok {
-			return fmt.Errorf("duplicate step id: %d", st.ID)
+		// Sleep of a step runs after its captures, so it can refer to the values captured by itself
+		for j, st := range group {
+			if name, ok := ParseSleepTemplate(st.Sleep); ok && !captured[name] {
+				return problemf(CodeUnknownReference, "sleep of step %d refers to a value that is not captured: %s",
+					st.ID, name).at(fmt.Sprintf("[%d].sleep", g[0]+j)).
+					withFix("capture the value in the step or in a step that runs before it")
+			}
 		}
-		stepIds[st.ID] = struct{}{}
 	}
 	return nil
 }
 
+// ParallelGroups returns the ranges of the indexes of the steps that run together, as [start, end) pairs in the order
+// of the steps. The consecutive steps of a parallel group are in the same range, any other step is in its own range.
+func ParallelGroups(steps []ScenarioStep) [][2]int {
+	var groups [][2]int
+	for i := 0; i < len(steps); {
+		end := i + 1
+		if name := steps[i].ParallelGroup; name != "" {
+			for end < len(steps) && steps[end].ParallelGroup == name {
+				end++
+			}
+		}
+		groups = append(groups, [2]int{i, end})
+		i = end
+	}
+	return groups
+}
+
+// composedCaptures returns the sorted names of the captured values that the url and the body of the step refer to.
+func (si *ScenarioStep) composedCaptures() ([]string, error) {
+	names, err := ParseBodyCaptures(si.URL)
+	if err != nil {
+		return nil, err
+	}
+	if !si.RawPayload {
+		body, err := ParseBodyCaptures(si.Payload)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, body...)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 // ScenarioStep represents one step of a Scenario.
 // This struct should be able to include all necessary data in a network packet for SupportedProtocols.
 type ScenarioStep struct {
@@ -114,22 +421,111 @@ type ScenarioStep struct {
 	// Request Headers
 	Headers map[string]string
 
+	// Headers sent once per value in their own header lines, like the "Forwarded" chains. A key also in Headers is
+	// sent with the value of Headers first.
+	RepeatedHeaders map[string][]string
+
+	// Keys of the headers sent as they are written instead of canonicalized, for the servers expecting their casing.
+	// Matched case-insensitively. HTTP/2 requests send all the keys lowercased regardless.
+	RawHeaders []string
+
 	// Request payload
 	Payload string
 
+	// Payload is sent verbatim, the dynamic variables, the file injections and the captured values in it are not rendered
+	RawPayload bool
+
+	// Requests are sent with the "Expect: 100-continue" header, their body is sent once the server answers with
+	// the 100 Continue interim response or at the timeout of the wait
+	ExpectContinue bool
+
+	// Payload is sent with the chunked transfer encoding instead of an explicit Content-Length
+	Chunked bool
+
+	// Requests send the ETag and the Last-Modified validators of the previous response to the same URL as the
+	// If-None-Match and the If-Modified-Since headers. The validators are kept by each virtual user.
+	ConditionalRequests bool
+
+	// Share of the iterations sending the request of the step twice with the same rendered bytes, in [0, 1].
+	// The responses of the pairs are compared to test the idempotency of the target.
+	DuplicateRate float64
+
 	// Target URL
 	URL string
 
 	// Connection timeout duration of the request in seconds
 	Timeout int
 
+	// Size limit of the response bodies in bytes, the rest of a larger body is not read and its connection is closed.
+	// DefaultMaxBodySize if it is 0.
+	MaxBodySize int64
+
 	// Sleep duration after running the step. Can be a time range like "300-500" or an exact duration like "350" in ms
 	Sleep string
 
+	// Status codes of the successful responses like "200-299,404", the others fail the request.
+	// Any response is successful if it is empty.
+	SuccessStatus string
+
+	// Name of the parallel group of the step. The consecutive steps of a group run concurrently in the iteration.
+	ParallelGroup string
+
+	// Names or ids of the steps that this step runs after, the steps are ordered by them with OrderSteps
+	DependsOn []string
+
+	// Proxy of the step overriding the proxy of the test, a proxy URL or StepProxyNone for the direct requests.
+	// Empty means the proxy of the test.
+	Proxy string
+
+	// Protocol Buffers schema of the payload and the responses of the step, nil for the other bodies
+	Protobuf *ProtobufPayload
+
 	// Protocol spesific request parameters. For ex: DisableRedirects:true for Http requests
 	Custom map[string]interface{}
 }
 
+// hasHeader reports whether the step has the header, the key is matched case-insensitively.
+func (si *ScenarioStep) hasHeader(key string) bool {
+	for k := range si.Headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	for k := range si.RepeatedHeaders {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRawHeader reports whether the key of the header is sent as it is written.
+func (si *ScenarioStep) IsRawHeader(key string) bool {
+	for _, k := range si.RawHeaders {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Schemes of the proxies supported by the http.Transport
+var stepProxySchemes = [...]string{"http", "https", "socks5"}
+
+// ProxyOf returns the proxy that the requests of the step are sent over, given the proxy of the test.
+// nil means the requests are sent directly.
+func (si *ScenarioStep) ProxyOf(proxy *url.URL) *url.URL {
+	switch si.Proxy {
+	case "":
+		return proxy
+	case StepProxyNone:
+		return nil
+	}
+	// Validated by validate
+	u, _ := url.Parse(si.Proxy)
+	return u
+}
+
 // Auth struct should be able to include all necessary authentication realated data for supportedAuthentications.
 type Auth struct {
 	Type     string
@@ -138,44 +534,1001 @@ type Auth struct {
 }
 
 func (si *ScenarioStep) validate() error {
-	if !util.StringInSlice(si.Protocol, SupportedProtocols[:]) {
-		return fmt.Errorf("unsupported Protocol: %s", si.Protocol)
+	registered := isRegisteredProtocol(si.Protocol)
+	if !util.StringInSlice(si.Protocol, SupportedProtocols[:]) && !registered {
+		return problemf(CodeUnsupported, "unsupported Protocol: %s", si.Protocol).at("protocol")
 	}
-	if !util.StringInSlice(si.Method, supportedProtocolMethods[si.Protocol][:]) {
-		return fmt.Errorf("unsupported Request Method: %s", si.Method)
+	if !registered && !util.StringInSlice(si.Method, supportedProtocolMethods[si.Protocol][:]) {
+		return problemf(CodeUnsupported, "unsupported Request Method: %s", si.Method).at("method")
 	}
 	if si.Auth != (Auth{}) && !util.StringInSlice(si.Auth.Type, supportedAuthentications[si.Protocol][:]) {
-		return fmt.Errorf("unsupported Authentication Method (%s) For Protocol (%s) ", si.Auth.Type, si.Protocol)
+		return problemf(CodeUnsupported, "unsupported Authentication Method (%s) For Protocol (%s) ",
+			si.Auth.Type, si.Protocol).at("auth.type")
 	}
 	if si.ID == 0 {
-		return fmt.Errorf("step ID should be greater than zero")
+		return problemf(CodeOutOfRange, "step ID should be greater than zero").at("id")
+	}
+	// Steps fed by a targets file may omit the url
+	if _, fed := si.Custom["targets-file"]; (!fed || si.URL != "") && !isValidURL(si.URL) {
+		return problemf(CodeInvalidValue, "target is not valid: %s", si.URL).at("url")
+	}
+	if si.SuccessStatus != "" {
+		if _, err := ParseSuccessStatus(si.SuccessStatus); err != nil {
+			return problemsAt("success_status", "", err)
+		}
 	}
-	if !validator.IsURL(strings.ReplaceAll(si.URL, " ", "_")) {
-		return fmt.Errorf("target is not valid: %s", si.URL)
+	if si.Protobuf != nil {
+		if err := si.Protobuf.validate(si); err != nil {
+			return problemsAt("payload_protobuf", "", err)
+		}
+	}
+	if si.Proxy != "" && si.Proxy != StepProxyNone {
+		if u, err := url.Parse(si.Proxy); err != nil || !util.StringInSlice(u.Scheme, stepProxySchemes[:]) ||
+			u.Host == "" {
+			return problemf(CodeInvalidValue, "proxy is not valid: %s", RedactURL(si.Proxy)).at("proxy")
+		}
+		if _, ok := si.Custom["unix-socket"]; ok {
+			return problemf(CodeConflict, "unix-socket can't be used with a proxy").at("others.unix-socket").
+				withFix("remove the proxy or the unix-socket of the step")
+		}
 	}
-	if si.Sleep != "" {
+	for k := range si.RepeatedHeaders {
+		if strings.EqualFold(k, "Host") {
+			return problemf(CodeInvalidValue, "host header can not be repeated").at("headers")
+		}
+	}
+	for _, k := range si.RawHeaders {
+		if !si.hasHeader(k) {
+			return problemf(CodeUnknownReference, "raw header %s is not in the headers of the step", k).
+				at("raw_headers")
+		}
+	}
+	if _, ok := ParseSleepTemplate(si.Sleep); ok {
+		if val, ok := si.Custom["sleep-default"]; ok {
+			if n, isNum := util.ToFloat64(val); !isNum || n < 0 || n > maxSleep {
+				return problemf(CodeOutOfRange, "sleep-default should be a duration between 0 and %d ms: %v",
+					maxSleep, val).at("others.sleep-default")
+			}
+		}
+	} else if _, ok := si.Custom["sleep-default"]; ok {
+		return problemf(CodeRequired, "sleep-default can only be used with a sleep template like {{poll_after}}").
+			at("others.sleep-default")
+	} else if si.Sleep != "" {
 		sleep := strings.Split(si.Sleep, "-")
 
 		// Avoid invalid syntax like "-300-500"
 		if len(sleep) > 2 {
-			return fmt.Errorf("sleep expression is not valid: %s", si.Sleep)
+			return problemf(CodeInvalidSyntax, "sleep expression is not valid: %s", si.Sleep).at("sleep")
 		}
 
 		// Validate string to int conversion
 		for _, s := range sleep {
 			dur, err := strconv.Atoi(s)
 			if err != nil {
-				return fmt.Errorf("sleep is not valid: %s", si.Sleep)
+				return problemf(CodeInvalidSyntax, "sleep is not valid: %s", si.Sleep).at("sleep")
 			}
 
 			if dur > maxSleep {
-				return fmt.Errorf("maximum sleep limit exceeded. provided: %d ms, maximum: %d ms", dur, maxSleep)
+				return problemf(CodeOutOfRange, "maximum sleep limit exceeded. provided: %d ms, maximum: %d ms",
+					dur, maxSleep).at("sleep")
+			}
+		}
+	}
+	if val, ok := si.Custom["stream"]; ok {
+		if _, isBool := val.(bool); !isBool {
+			return problemf(CodeInvalidType, "stream should be a boolean: %v", val).at("others.stream")
+		}
+	}
+	for _, k := range streamLimits {
+		if val, ok := si.Custom[k]; ok {
+			if n, isNum := util.ToFloat64(val); !isNum || n <= 0 {
+				return problemf(CodeInvalidValue, "%s should be a positive number: %v", k, val).at("others." + k)
+			}
+		}
+	}
+	if val, ok := si.Custom["targets-file"]; ok {
+		if path, isStr := val.(string); !isStr || path == "" {
+			return problemf(CodeInvalidType, "targets-file should be a file path: %v", val).at("others.targets-file")
+		}
+	}
+	if val, ok := si.Custom["targets-order"]; ok {
+		order, isStr := val.(string)
+		if !isStr || !util.StringInSlice(order, targetsOrders[:]) {
+			return problemf(CodeUnsupported, "unsupported targets-order: %v", val).at("others.targets-order").
+				withFix("use one of " + strings.Join(targetsOrders[:], ", "))
+		}
+	}
+	if val, ok := si.Custom["url-groups"]; ok {
+		if _, fed := si.Custom["targets-file"]; !fed {
+			return problemf(CodeRequired, "url-groups can only be used with targets-file").at("others.url-groups")
+		}
+		if _, err := ParseURLGroups(val); err != nil {
+			return problemsAt("others.url-groups", "", err)
+		}
+	}
+	if val, ok := si.Custom["report-dimensions"]; ok {
+		if _, err := ParseReportDimensions(val); err != nil {
+			return problemsAt("others.report-dimensions", "", err)
+		}
+	}
+	if val, ok := si.Custom["report-dimension-limit"]; ok {
+		if _, isSet := si.Custom["report-dimensions"]; !isSet {
+			return problemf(CodeRequired, "report-dimension-limit can only be used with report-dimensions").
+				at("others.report-dimension-limit")
+		}
+		if _, err := ParseReportDimensionLimit(val); err != nil {
+			return problemsAt("others.report-dimension-limit", "", err)
+		}
+	}
+	if val, ok := si.Custom["report-host-limit"]; ok {
+		if _, err := ParseReportHostLimit(val); err != nil {
+			return problemsAt("others.report-host-limit", "", err)
+		}
+	}
+	if val, ok := si.Custom["hosts"]; ok {
+		if _, fed := si.Custom["targets-file"]; fed {
+			return problemf(CodeConflict, "hosts can't be used with targets-file").at("others.hosts")
+		}
+		if _, err := ParseHosts(val); err != nil {
+			return problemsAt("others.hosts", "", err)
+		}
+	}
+	if val, ok := si.Custom["disable-decompression"]; ok {
+		if _, isBool := val.(bool); !isBool {
+			return problemf(CodeInvalidType, "disable-decompression should be a boolean: %v", val).
+				at("others.disable-decompression")
+		}
+	}
+	if val, ok := si.Custom["disable-cookies"]; ok {
+		if _, isBool := val.(bool); !isBool {
+			return problemf(CodeInvalidType, "disable-cookies should be a boolean: %v", val).
+				at("others.disable-cookies")
+		}
+	}
+	if val, ok := si.Custom["tls-session-cache"]; ok {
+		if _, isBool := val.(bool); !isBool {
+			return problemf(CodeInvalidType, "tls-session-cache should be a boolean: %v", val).
+				at("others.tls-session-cache")
+		}
+	}
+	if val, ok := si.Custom["unix-socket"]; ok {
+		if path, isStr := val.(string); !isStr || path == "" {
+			return problemf(CodeInvalidType, "unix-socket should be a path: %v", val).at("others.unix-socket")
+		}
+		if _, rotated := si.Custom["hosts"]; rotated {
+			return problemf(CodeConflict, "unix-socket can't be used with hosts").at("others.unix-socket")
+		}
+	}
+	if val, ok := si.Custom["prewarm-connections"]; ok {
+		if _, err := ParsePrewarmConnections(val); err != nil {
+			return problemsAt("others.prewarm-connections", "", err)
+		}
+		if keepAlive, isBool := si.Custom["keep-alive"].(bool); isBool && !keepAlive {
+			return problemf(CodeConflict, "prewarm-connections can't be used with keep-alive disabled").
+				at("others.prewarm-connections")
+		}
+		_, fed := si.Custom["targets-file"]
+		if _, rotated := si.Custom["hosts"]; fed || rotated {
+			return problemf(CodeConflict, "prewarm-connections can't be used with targets-file or hosts").
+				at("others.prewarm-connections")
+		}
+		if TemplatedHost(si.URL) {
+			return problemf(CodeConflict, "prewarm-connections can't be used with a templated host, it needs a static "+
+				"target host").at("others.prewarm-connections")
+		}
+	}
+	if val, ok := si.Custom["prewarm-required"]; ok {
+		if _, isBool := val.(bool); !isBool {
+			return problemf(CodeInvalidType, "prewarm-required should be a boolean: %v", val).
+				at("others.prewarm-required")
+		}
+		if _, isSet := si.Custom["prewarm-connections"]; !isSet {
+			return problemf(CodeRequired, "prewarm-required can only be used with prewarm-connections").
+				at("others.prewarm-required")
+		}
+	}
+	if val, ok := si.Custom["network"]; ok {
+		if _, err := ParseNetworkShaping(val); err != nil {
+			return problemsAt("others.network", "", err)
+		}
+	}
+	if val, ok := si.Custom["retry-after"]; ok {
+		mode, isStr := val.(string)
+		if !isStr || !util.StringInSlice(mode, retryAfterModes[:]) {
+			return problemf(CodeUnsupported, "unsupported retry-after mode: %v", val).at("others.retry-after").
+				withFix("use one of " + strings.Join(retryAfterModes[:], ", "))
+		}
+	}
+	if si.ConditionalRequests && si.Method != http.MethodGet && si.Method != http.MethodHead {
+		return problemf(CodeConflict, "conditional_requests can only be used with the GET and HEAD methods").
+			at("conditional_requests")
+	}
+	if si.DuplicateRate < 0 || si.DuplicateRate > 1 {
+		return problemf(CodeOutOfRange, "duplicate_rate should be between 0 and 1: %v", si.DuplicateRate).
+			at("duplicate_rate")
+	}
+	if si.DuplicateRate > 0 {
+		if registered {
+			return problemf(CodeConflict, "duplicate_rate can only be used with the HTTP and HTTPS steps").
+				at("duplicate_rate")
+		}
+		if stream, _ := si.Custom["stream"].(bool); stream {
+			return problemf(CodeConflict, "duplicate_rate can not be used with stream").at("duplicate_rate")
+		}
+	}
+	if val, ok := si.Custom["adaptive-timeout"]; ok {
+		if _, err := ParseAdaptiveTimeout(val, si.Timeout); err != nil {
+			return problemsAt("others.adaptive-timeout", "", err)
+		}
+	}
+	if val, ok := si.Custom["page-load"]; ok {
+		if _, err := ParsePageLoad(val); err != nil {
+			return problemsAt("others.page-load", "", err)
+		}
+		if stream, _ := si.Custom["stream"].(bool); stream {
+			return problemf(CodeConflict, "page-load can not be used with stream").at("others.page-load")
+		}
+	}
+	if val, ok := si.Custom["sign-command"]; ok {
+		if _, err := ParseSignCommand(val); err != nil {
+			return problemsAt("others.sign-command", "", err)
+		}
+	}
+	if val, ok := si.Custom["hedge"]; ok {
+		if _, err := ParseHedge(val, si.Method); err != nil {
+			return problemsAt("others.hedge", "", err)
+		}
+		if stream, _ := si.Custom["stream"].(bool); stream {
+			return problemf(CodeConflict, "hedge can not be used with stream").at("others.hedge")
+		}
+	}
+	if val, ok := si.Custom["ab-variants"]; ok {
+		variants, err := ParseABVariants(val)
+		if err != nil {
+			return problemsAt("others.ab-variants", "", err)
+		}
+		_, fed := si.Custom["targets-file"]
+		_, rotated := si.Custom["hosts"]
+		if fed || rotated {
+			for _, v := range variants {
+				if v.BaseURL != nil {
+					return problemf(CodeConflict, "ab-variants base_url can't be used with targets-file or hosts").
+						at("others.ab-variants")
+				}
+			}
+		}
+	}
+	if val, ok := si.Custom["stale-retry"]; ok {
+		if _, isBool := val.(bool); !isBool {
+			return problemf(CodeInvalidType, "stale-retry should be a boolean: %v", val).at("others.stale-retry")
+		}
+	}
+	if val, ok := si.Custom["capture-to-file"]; ok {
+		if _, err := ParseFileCaptures(val); err != nil {
+			return problemsAt("others.capture-to-file", "", err)
+		}
+	}
+	if val, ok := si.Custom["capture"]; ok {
+		if _, err := ParseStepCaptures(val); err != nil {
+			return problemsAt("others.capture", "", err)
+		}
+	}
+	if val, ok := si.Custom["cookie-assertions"]; ok {
+		if _, err := ParseCookieAssertions(val); err != nil {
+			return problemsAt("others.cookie-assertions", "", err)
+		}
+	}
+	if err := validateXPaths(si.Custom); err != nil {
+		return err
+	}
+	if val, ok := si.Custom["json-schema"]; ok {
+		path, isStr := val.(string)
+		if !isStr || path == "" {
+			return problemf(CodeInvalidType, "json-schema should be a file path: %v", val).at("others.json-schema")
+		}
+		if _, err := jsonschema.Load(path); err != nil {
+			return problemsAt("others.json-schema", "", err)
+		}
+	}
+	if val, ok := si.Custom["json-schema-sample"]; ok {
+		if _, isSet := si.Custom["json-schema"]; !isSet {
+			return problemf(CodeRequired, "json-schema-sample can only be used with json-schema").
+				at("others.json-schema-sample")
+		}
+		if _, err := ParseJSONSchemaSample(val); err != nil {
+			return problemsAt("others.json-schema-sample", "", err)
+		}
+	}
+	if val, ok := si.Custom["leak-scan"]; ok {
+		if _, err := ParseLeakScan(val); err != nil {
+			return problemsAt("others.leak-scan", "", err)
+		}
+	}
+	return nil
+}
+
+// Hedge is the hedging of the requests of a step. If the request gets no response within the delay, an identical one
+// is sent and the first response wins, the other request is cancelled.
+type Hedge struct {
+	Delay time.Duration
+
+	// Requests of the methods that are not idempotent are hedged too
+	NonIdempotent bool
+}
+
+// ParseHedge parses the hedge option of a step, either the delay in milliseconds or an object like
+// {"delay": 200, "non_idempotent": true}. A hedged request may be processed twice by the target, so only the
+// idempotent methods are hedged unless non_idempotent is set.
+func ParseHedge(val interface{}, method string) (Hedge, error) {
+	var h Hedge
+	delay := val
+	if obj, isObj := val.(map[string]interface{}); isObj {
+		for k := range obj {
+			if k != "delay" && k != "non_idempotent" {
+				return h, problemf(CodeUnsupported, "unsupported hedge key: %s", k)
+			}
+		}
+		delay = obj["delay"]
+		if v, ok := obj["non_idempotent"]; ok {
+			if h.NonIdempotent, ok = v.(bool); !ok {
+				return h, problemf(CodeInvalidType, "hedge non_idempotent should be a boolean: %v", v)
+			}
+		}
+	}
+	ms, ok := util.ToFloat64(delay)
+	if !ok || ms <= 0 {
+		return h, problemf(CodeInvalidValue, "hedge delay should be a positive number of milliseconds: %v", delay)
+	}
+	h.Delay = time.Duration(ms * float64(time.Millisecond))
+	if !h.NonIdempotent && !IsIdempotentMethod(method) {
+		return h, problemf(CodeRequired, "hedge of the %s requests needs non_idempotent, they may be processed twice",
+			method).withFix("set non_idempotent of the hedge to true")
+	}
+	return h, nil
+}
+
+// IsIdempotentMethod reports whether the requests of the method can be sent again without changing the result,
+// as defined by RFC 9110.
+func IsIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// ParsePrewarmConnections parses the count of the connections established for a step before the test.
+func ParsePrewarmConnections(val interface{}) (int, error) {
+	n, ok := util.ToFloat64(val)
+	if !ok || n < 1 || n > MaxPrewarmConnections || n != float64(int(n)) {
+		return 0, problemf(CodeOutOfRange, "prewarm-connections should be an integer between 1 and %d: %v",
+			MaxPrewarmConnections, val)
+	}
+	return int(n), nil
+}
+
+// ParseJSONSchemaSample parses the ratio of the responses validated against the json-schema of a step,
+// either a percentage like "1%" or a ratio like 0.01.
+func ParseJSONSchemaSample(val interface{}) (float64, error) {
+	rate, ok := parseSampleRatio(val)
+	if !ok {
+		return 0, problemf(CodeOutOfRange, "json-schema-sample should be a ratio between 0 and 1 or a percentage: %v",
+			val)
+	}
+	return rate, nil
+}
+
+// ParseDuplicateRate parses the share of the iterations sending the request of a step twice, either a percentage
+// like "5%" or a ratio like 0.05.
+func ParseDuplicateRate(val interface{}) (float64, error) {
+	rate, ok := parseSampleRatio(val)
+	if !ok {
+		return 0, problemf(CodeOutOfRange, "duplicate_rate should be a ratio between 0 and 1 or a percentage: %v", val)
+	}
+	return rate, nil
+}
+
+// parseSampleRatio parses a ratio in (0, 1], either a percentage like "1%" or a ratio like 0.01.
+func parseSampleRatio(val interface{}) (float64, bool) {
+	rate, ok := util.ToFloat64(val)
+	if str, isStr := val.(string); isStr {
+		str = strings.TrimSpace(str)
+		r, err := strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+		if ok = err == nil; ok {
+			rate = r
+			if strings.HasSuffix(str, "%") {
+				rate /= 100
+			}
+		}
+	}
+	return rate, ok && rate > 0 && rate <= 1
+}
+
+// URLGroup is a normalization rule of the targets for reporting. The target paths matching the Pattern
+// are reported under the path where the matched part is replaced with the Group. Ex: /users/\d+ -> /users/:id
+type URLGroup struct {
+	Pattern *regexp.Regexp
+	Group   string
+}
+
+// ParseURLGroups parses the url-groups rules of a step, given as a list of {"pattern": ..., "group": ...} objects.
+func ParseURLGroups(val interface{}) ([]URLGroup, error) {
+	rules, ok := val.([]interface{})
+	if !ok {
+		return nil, problemf(CodeInvalidType, "url-groups should be a list of pattern and group pairs: %v", val)
+	}
+
+	groups := make([]URLGroup, 0, len(rules))
+	for _, r := range rules {
+		rule, _ := r.(map[string]interface{})
+		pattern, _ := rule["pattern"].(string)
+		group, _ := rule["group"].(string)
+		if pattern == "" || group == "" {
+			return nil, problemf(CodeRequired, "url-groups rule should have a pattern and a group: %v", r)
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, problemf(CodeInvalidSyntax, "url-groups pattern is not valid: %v", err)
+		}
+		groups = append(groups, URLGroup{Pattern: re, Group: group})
+	}
+	return groups, nil
+}
+
+// ParseReportDimensions parses the response header names whose values group the results of a step in the report.
+// The names are returned in their canonical forms.
+func ParseReportDimensions(val interface{}) ([]string, error) {
+	list, ok := val.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, problemf(CodeInvalidType, "report-dimensions should be a list of response header names: %v", val)
+	}
+
+	names := make([]string, 0, len(list))
+	for _, v := range list {
+		name, _ := v.(string)
+		if name = strings.TrimSpace(name); name == "" || strings.ContainsAny(name, ": ") {
+			return nil, problemf(CodeInvalidValue, "report-dimensions entry should be a header name like "+
+				"X-Backend-Pod: %v", v)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if util.StringInSlice(name, names) {
+			return nil, problemf(CodeDuplicate, "report-dimensions has the header %s more than once", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// ParseReportDimensionLimit parses the count of the distinct values reported per dimension of a step,
+// the values after the limit are reported under OverflowDimensionValue. Nil means DefaultReportDimensionLimit.
+func ParseReportDimensionLimit(val interface{}) (int, error) {
+	if val == nil {
+		return DefaultReportDimensionLimit, nil
+	}
+	n, ok := util.ToFloat64(val)
+	if !ok || n < 1 || n != float64(int(n)) {
+		return 0, problemf(CodeInvalidValue, "report-dimension-limit should be a positive integer: %v", val)
+	}
+	return int(n), nil
+}
+
+// ParseReportHostLimit parses the count of the distinct hosts reported per step, the hosts after the limit are
+// reported under OverflowHost. Nil means DefaultReportHostLimit.
+func ParseReportHostLimit(val interface{}) (int, error) {
+	if val == nil {
+		return DefaultReportHostLimit, nil
+	}
+	n, ok := util.ToFloat64(val)
+	if !ok || n < 1 || n != float64(int(n)) {
+		return 0, problemf(CodeInvalidValue, "report-host-limit should be a positive integer: %v", val)
+	}
+	return int(n), nil
+}
+
+// WeightedHost is a host of the hosts rotation of a step. A host with the weight 2 gets twice the requests
+// of a host with the weight 1.
+type WeightedHost struct {
+	Host   string
+	Weight int
+}
+
+// ParseHosts parses the hosts rotation of a step, given as a list of hosts like "api1.example.com:8080" or
+// {"host": ..., "weight": ...} objects. The weight is 1 if it is omitted.
+func ParseHosts(val interface{}) ([]WeightedHost, error) {
+	list, ok := val.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, problemf(CodeInvalidType, "hosts should be a list of hosts: %v", val)
+	}
+
+	hosts := make([]WeightedHost, 0, len(list))
+	for _, v := range list {
+		h := WeightedHost{Weight: 1}
+		switch e := v.(type) {
+		case string:
+			h.Host = e
+		case map[string]interface{}:
+			h.Host, _ = e["host"].(string)
+			if w, isSet := e["weight"]; isSet {
+				n, isNum := util.ToFloat64(w)
+				if !isNum || n < 1 || n != float64(int(n)) {
+					return nil, problemf(CodeInvalidValue, "weight of the host should be a positive integer: %v", v)
+				}
+				h.Weight = int(n)
+			}
+		}
+		if h.Host == "" || strings.ContainsAny(h.Host, "/?# ") {
+			return nil, problemf(CodeInvalidValue, "hosts entry should be a host like api.example.com:8080: %v", v)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// FileCapture is a rule that writes a value of the first successful response of a step to a file,
+// so a later run can read it with the {{file "path"}} template.
+type FileCapture struct {
+	// Response header of the value. If it is empty, the value is read from the body.
+	Header string
+
+	// Dot separated path of the value in the JSON body, like data.items.0.id. The whole body is used if it is empty.
+	JSONPath string
+
+	// XPath of the value in the XML body, like //OrderId/text(). The string value of the first match is used.
+	XPath string
+
+	ToFile string
+}
+
+// ParseFileCaptures parses the capture-to-file rules of a step,
+// given as a list of {"header": ..., "json_path": ..., "xpath": ..., "to_file": ...} objects.
+func ParseFileCaptures(val interface{}) ([]FileCapture, error) {
+	rules, ok := val.([]interface{})
+	if !ok {
+		return nil, problemf(CodeInvalidType, "capture-to-file should be a list of captures: %v", val)
+	}
+
+	captures := make([]FileCapture, 0, len(rules))
+	for _, r := range rules {
+		rule, _ := r.(map[string]interface{})
+		header, _ := rule["header"].(string)
+		jsonPath, _ := rule["json_path"].(string)
+		xpath, _ := rule["xpath"].(string)
+		toFile, _ := rule["to_file"].(string)
+		if toFile == "" {
+			return nil, problemf(CodeRequired, "capture-to-file rule should have a to_file path: %v", r)
+		}
+		if !singleValueSource(header, jsonPath, xpath) {
+			return nil, problemf(CodeConflict, "capture-to-file rule can have only one of a header, a json_path or an "+
+				"xpath: %v", r)
+		}
+		captures = append(captures, FileCapture{Header: header, JSONPath: jsonPath, XPath: xpath, ToFile: toFile})
+	}
+	return captures, nil
+}
+
+// StepCapture is a rule that captures a value of each response of a step for the rest of the iteration,
+// like the sleep templates.
+type StepCapture struct {
+	Name string
+
+	// Response header of the value. If it is empty, the value is read from the body.
+	Header string
+
+	// Name of the cookie set by the response, its value is captured apart from the cookies of the iteration
+	Cookie string
+
+	// Dot separated path of the value in the JSON body. The whole body is used if it is empty.
+	JSONPath string
+
+	// XPath of the value in the XML body, the string value of the first match is used
+	XPath string
+
+	// Upper bound of the body size if the whole body is captured. Larger bodies fail the request.
+	MaxSize int64
+
+	// Set if the values captured by all the iterations are collected for the teardown steps
+	Collect bool
+}
+
+// WholeBody reports whether the rule captures the whole response body, the rules parsed by ParseStepCaptures
+// have no other source only if they are from the body.
+func (c StepCapture) WholeBody() bool {
+	return c.Header == "" && c.Cookie == "" && c.JSONPath == "" && c.XPath == ""
+}
+
+// stepCaptureKeys are the keys of a capture rule.
+var stepCaptureKeys = map[string]bool{
+	"header": true, "cookie": true, "json_path": true, "xpath": true, "from": true, "max_size": true, "collect": true,
+}
+
+// ParseStepCaptures parses the capture rules of a step, given as an object of
+// {"<name>": {"header": ...}, {"cookie": ...}, {"json_path": ...}, {"xpath": ...} or {"from": "body"}} pairs.
+// Rules are sorted by their names.
+func ParseStepCaptures(val interface{}) ([]StepCapture, error) {
+	if val == nil {
+		return nil, nil
+	}
+	rules, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, problemf(CodeInvalidType, "capture should be an object of the captured values: %v", val)
+	}
+
+	captures := make([]StepCapture, 0, len(rules))
+	for name, r := range rules {
+		if !captureNameRegex.MatchString(name) {
+			return nil, problemf(CodeInvalidValue, "capture name should be alphanumeric and start with a letter: %s",
+				name)
+		}
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, problemf(CodeInvalidType, "capture %s should be an object with a header, a cookie, a "+
+				"json_path, an xpath or from body: %v", name, r)
+		}
+		for key := range rule {
+			if !stepCaptureKeys[key] {
+				return nil, problemf(CodeUnsupported, "capture %s has an unknown key %s: %v", name, key, r)
+			}
+		}
+		header, _ := rule["header"].(string)
+		cookie, _ := rule["cookie"].(string)
+		jsonPath, _ := rule["json_path"].(string)
+		xpath, _ := rule["xpath"].(string)
+		if !singleValueSource(header, cookie, jsonPath, xpath) {
+			return nil, problemf(CodeConflict, "capture %s can have only one of a header, a cookie, a json_path or an "+
+				"xpath: %v", name, r)
+		}
+		c := StepCapture{Name: name, Header: header, Cookie: cookie, JSONPath: jsonPath, XPath: xpath,
+			MaxSize: DefaultCaptureBodySize}
+
+		// The whole body is captured only if it is asked, not when the source is missing or misspelled
+		from, hasFrom := rule["from"]
+		if hasFrom && (from != CaptureFromBody || !c.WholeBody()) {
+			return nil, problemf(CodeConflict, "capture %s can only be from the body, without a header, a cookie, a "+
+				"json_path or an xpath: %v", name, r)
+		}
+		if !hasFrom && c.WholeBody() {
+			return nil, problemf(CodeRequired, "capture %s should have a header, a cookie, a json_path, an xpath or "+
+				"from body: %v", name, r)
+		}
+		if val, ok := rule["max_size"]; ok {
+			if !c.WholeBody() {
+				return nil, problemf(CodeConflict, "max_size of capture %s can only be used for the whole body", name)
+			}
+			var err error
+			if c.MaxSize, err = parseCaptureSize(val); err != nil {
+				return nil, problemf(CodeInvalidValue, "max_size of capture %s should be a positive byte size: %v",
+					name, val)
+			}
+		}
+		if val, ok := rule["collect"]; ok {
+			collect, isBool := val.(bool)
+			if !isBool {
+				return nil, problemf(CodeInvalidType, "collect of capture %s should be a boolean: %v", name, val)
+			}
+			c.Collect = collect
+		}
+		captures = append(captures, c)
+	}
+	sort.Slice(captures, func(i, j int) bool { return captures[i].Name < captures[j].Name })
+	return captures, nil
+}
+
+// parseCaptureSize parses a size given as bytes or as a byte size like "256KB".
+func parseCaptureSize(val interface{}) (int64, error) {
+	var n int64
+	var err error
+	switch v := val.(type) {
+	case float64:
+		n = int64(v)
+	case string:
+		n, err = ParseByteSize(v)
+	default:
+		err = problemf(CodeInvalidValue, "invalid byte size: %v", val)
+	}
+	if err == nil && n <= 0 {
+		err = problemf(CodeInvalidValue, "invalid byte size: %v", val)
+	}
+	return n, err
+}
+
+// ParseXMLNamespaces parses the xml-namespaces of a step, an object of the prefix and the namespace URI pairs.
+func ParseXMLNamespaces(val interface{}) (map[string]string, error) {
+	if val == nil {
+		return nil, nil
+	}
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, problemf(CodeInvalidType, "xml-namespaces should be an object of the prefixes and the namespace "+
+			"uris: %v", val)
+	}
+
+	ns := make(map[string]string, len(m))
+	for prefix, v := range m {
+		uri, isStr := v.(string)
+		if !xmlPrefixRegex.MatchString(prefix) || !isStr || uri == "" {
+			return nil, problemf(CodeInvalidValue, "invalid xml namespace %s: %v", prefix, v)
+		}
+		ns[prefix] = uri
+	}
+	return ns, nil
+}
+
+// ParseXPathAssertions parses the xpath-assertions of a step, a list of expressions that should be true
+// for each response, like "count(//Fault) = 0".
+func ParseXPathAssertions(val interface{}) ([]string, error) {
+	list, ok := val.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, problemf(CodeInvalidType, "xpath-assertions should be a list of expressions: %v", val)
+	}
+
+	exprs := make([]string, 0, len(list))
+	for _, v := range list {
+		expr, isStr := v.(string)
+		if !isStr || strings.TrimSpace(expr) == "" {
+			return nil, problemf(CodeInvalidValue, "xpath assertion should be a non-empty string: %v", v)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+// CookieAssertion is the expected attributes of a cookie that should be set by each response of a step.
+// Unset fields are not checked.
+type CookieAssertion struct {
+	Name string
+
+	Secure   *bool
+	HttpOnly *bool
+
+	// One of "Strict", "Lax" or "None"
+	SameSite string
+
+	// Bounds of the Max-Age attribute in seconds, a bound is not checked if it is nil
+	MinMaxAge *int64
+	MaxMaxAge *int64
+
+	Path   string
+	Domain string
+}
+
+// cookieAssertionKeys are the keys of a cookie assertion.
+var cookieAssertionKeys = map[string]bool{
+	"secure": true, "http_only": true, "same_site": true, "max_age": true, "path": true, "domain": true,
+}
+
+// cookieSameSiteModes are the SameSite values of a cookie, keyed by their lowercase forms.
+var cookieSameSiteModes = map[string]string{"strict": "Strict", "lax": "Lax", "none": "None"}
+
+// ParseCookieAssertions parses the cookie-assertions of a step, given as an object of
+// {"<cookie name>": {"secure": true, "http_only": true, "same_site": "Lax", "max_age": {"min": 60, "max": 86400}}}
+// pairs. A max_age number asserts the exact value. Assertions are sorted by the cookie names.
+func ParseCookieAssertions(val interface{}) ([]CookieAssertion, error) {
+	cookies, ok := val.(map[string]interface{})
+	if !ok || len(cookies) == 0 {
+		return nil, problemf(CodeInvalidType, "cookie-assertions should be an object of the cookie names and their "+
+			"attributes: %v", val)
+	}
+
+	assertions := make([]CookieAssertion, 0, len(cookies))
+	for name, v := range cookies {
+		if name == "" || strings.ContainsAny(name, " \t;,=") {
+			return nil, problemf(CodeInvalidValue, "invalid cookie name in cookie-assertions: %q", name)
+		}
+		attrs, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, problemf(CodeInvalidType, "cookie assertion %s should be an object of the expected "+
+				"attributes: %v", name, v)
+		}
+
+		a := CookieAssertion{Name: name}
+		for key, attr := range attrs {
+			if !cookieAssertionKeys[key] {
+				return nil, problemf(CodeUnsupported, "cookie assertion %s has an unknown key %s: %v", name, key, v)
+			}
+			b, isBool := attr.(bool)
+			s, isStr := attr.(string)
+			valid := true
+			switch key {
+			case "secure":
+				a.Secure, valid = &b, isBool
+			case "http_only":
+				a.HttpOnly, valid = &b, isBool
+			case "same_site":
+				a.SameSite, valid = cookieSameSiteModes[strings.ToLower(s)]
+			case "max_age":
+				a.MinMaxAge, a.MaxMaxAge, valid = parseMaxAgeBounds(attr)
+			case "path":
+				a.Path, valid = s, isStr && s != ""
+			case "domain":
+				a.Domain, valid = s, isStr && s != ""
+			}
+			if !valid {
+				return nil, problemf(CodeInvalidValue, "invalid %s of cookie assertion %s: %v", key, name, attr)
+			}
+		}
+		assertions = append(assertions, a)
+	}
+	sort.Slice(assertions, func(i, j int) bool { return assertions[i].Name < assertions[j].Name })
+	return assertions, nil
+}
+
+// parseMaxAgeBounds parses the max_age of a cookie assertion, either the exact seconds or an object of
+// the min and max seconds. At least one of the bounds should be set and min can't be greater than max.
+func parseMaxAgeBounds(val interface{}) (min, max *int64, ok bool) {
+	bound := func(v interface{}) (*int64, bool) {
+		n, isNum := util.ToFloat64(v)
+		if !isNum || n != float64(int64(n)) {
+			return nil, false
+		}
+		i := int64(n)
+		return &i, true
+	}
+
+	bounds, isObj := val.(map[string]interface{})
+	if !isObj {
+		min, ok = bound(val)
+		return min, min, ok
+	}
+	for key, v := range bounds {
+		switch key {
+		case "min":
+			min, ok = bound(v)
+		case "max":
+			max, ok = bound(v)
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, nil, false
+		}
+	}
+	if min == nil && max == nil || min != nil && max != nil && *min > *max {
+		return nil, nil, false
+	}
+	return min, max, true
+}
+
+// validateXPaths compiles the xpaths of the captures and the assertions of a step with its xml-namespaces.
+// Captures are already validated.
+func validateXPaths(custom map[string]interface{}) error {
+	namespaces, err := ParseXMLNamespaces(custom["xml-namespaces"])
+	if err != nil {
+		return problemsAt("others.xml-namespaces", "", err)
+	}
+
+	// Xpaths by the option they are given in
+	xpaths := make(map[string][]string)
+	if val, ok := custom["xpath-assertions"]; ok {
+		if xpaths["xpath-assertions"], err = ParseXPathAssertions(val); err != nil {
+			return problemsAt("others.xpath-assertions", "", err)
+		}
+	}
+	if val, ok := custom["capture-to-file"]; ok {
+		captures, _ := ParseFileCaptures(val)
+		for _, c := range captures {
+			xpaths["capture-to-file"] = append(xpaths["capture-to-file"], c.XPath)
+		}
+	}
+	if val, ok := custom["capture"]; ok {
+		captures, _ := ParseStepCaptures(val)
+		for _, c := range captures {
+			xpaths["capture"] = append(xpaths["capture"], c.XPath)
+		}
+	}
+
+	for _, key := range []string{"xpath-assertions", "capture-to-file", "capture"} {
+		for _, x := range xpaths[key] {
+			if x == "" {
+				continue
+			}
+			if _, err := xpath.Compile(x, namespaces); err != nil {
+				return problemsAt("others."+key, "", problemOf(CodeInvalidSyntax, err))
 			}
 		}
 	}
 	return nil
 }
 
+// singleValueSource reports whether at most one source of a captured value is set.
+func singleValueSource(sources ...string) bool {
+	set := 0
+	for _, s := range sources {
+		if s != "" {
+			set++
+		}
+	}
+	return set <= 1
+}
+
+// ParseSleepTemplate returns the name of the captured value if the sleep of a step is a template like
+// {{poll_after}}, which is resolved per iteration from the captured values.
+func ParseSleepTemplate(sleep string) (name string, ok bool) {
+	m := sleepTemplateRegex.FindStringSubmatch(strings.TrimSpace(sleep))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// StatusRange is an inclusive range of status codes like 200-299, a single code has the same min and max.
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// StatusRanges are the status codes of the success_status of a step.
+type StatusRanges []StatusRange
+
+// Contains reports whether the status code is in one of the ranges.
+func (r StatusRanges) Contains(code int) bool {
+	for _, s := range r {
+		if code >= s.Min && code <= s.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSuccessStatus parses the success_status of a step, a comma separated list of status codes and ranges
+// like "200-299,404".
+func ParseSuccessStatus(spec string) (StatusRanges, error) {
+	var ranges StatusRanges
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, problemf(CodeInvalidSyntax, "invalid success_status %q: empty status code", spec)
+		}
+
+		min, max, isRange := strings.Cut(part, "-")
+		r := StatusRange{}
+		var err error
+		if r.Min, err = parseStatusCode(spec, min); err != nil {
+			return nil, err
+		}
+		r.Max = r.Min
+		if isRange {
+			if r.Max, err = parseStatusCode(spec, max); err != nil {
+				return nil, err
+			}
+			if r.Min > r.Max {
+				return nil, problemf(CodeInvalidValue, "invalid success_status %q: range %s should start with the "+
+					"lower code", spec, part)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func parseStatusCode(spec, code string) (int, error) {
+	code = strings.TrimSpace(code)
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return 0, problemf(CodeInvalidValue, "invalid success_status %q: %q is not a status code", spec, code)
+	}
+	if n < 100 || n > 599 {
+		return 0, problemf(CodeOutOfRange, "invalid success_status %q: status code %d should be between 100 and 599",
+			spec, n)
+	}
+	return n, nil
+}
+
+// GroupURL returns the path of the target normalized by the first matching rule.
+// Targets which don't match any rule are grouped under CatchAllURLGroup.
+func GroupURL(groups []URLGroup, target *url.URL) string {
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, g := range groups {
+		if g.Pattern.MatchString(path) {
+			return g.Pattern.ReplaceAllString(path, g.Group)
+		}
+	}
+	return CatchAllURLGroup
+}
+
 func ParseTLS(certFile, keyFile string) (tls.Certificate, *x509.CertPool, error) {
 	if certFile == "" || keyFile == "" {
 		return tls.Certificate{}, nil, nil
@@ -204,11 +1557,13 @@ func ParseTLS(certFile, keyFile string) (tls.Certificate, *x509.CertPool, error)
 // If url is not valid, then error will be returned
 func AdjustUrlProtocol(url string, proto string) (string, string, error) {
 	var err error
-	if !validator.IsURL(strings.ReplaceAll(url, " ", "_")) {
-		err = fmt.Errorf("target is not valid: %s", url)
+	if !isValidURL(url) {
+		err = problemf(CodeInvalidValue, "target is not valid: %s", url)
 	} else {
 		tempURL := strings.ToUpper(url)
-		if strings.HasPrefix(tempURL, ProtocolHTTPS+"://") {
+		if i := strings.Index(tempURL, "://"); i > 0 && isRegisteredProtocol(tempURL[:i]) {
+			proto = tempURL[:i]
+		} else if strings.HasPrefix(tempURL, ProtocolHTTPS+"://") {
 			proto = ProtocolHTTPS
 		} else if strings.HasPrefix(tempURL, ProtocolHTTP+"://") {
 			proto = ProtocolHTTP
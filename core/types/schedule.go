@@ -0,0 +1,153 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// SchedulePoint is the target iterations per second of the schedule at a time of day.
+type SchedulePoint struct {
+	// Offset of the point from the midnight, in [0, 24h)
+	TimeOfDay time.Duration
+
+	Rate float64
+}
+
+// LoadSchedule sets the iterations per second of the test by the wall clock time of day instead of the elapsed
+// time, for the long soak tests following a daily traffic curve. The rate is interpolated linearly between the
+// points and wraps around the midnight.
+type LoadSchedule struct {
+	Points []SchedulePoint
+
+	// Time zone of the points. nil means UTC, so the DST transitions don't shift the curve unless a time zone
+	// is set explicitly.
+	Location *time.Location
+}
+
+// ParseTimeOfDay parses a time of day like "09:00" or "20:30:15" into its offset from the midnight.
+func ParseTimeOfDay(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, problemf(CodeInvalidValue, "invalid time of day: %s", s)
+	}
+	limits := []int{24, 60, 60}
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || len(p) != 2 || v < 0 || v >= limits[i] {
+			return 0, problemf(CodeInvalidValue, "invalid time of day: %s", s)
+		}
+		d += time.Duration(v) * units[i]
+	}
+	return d, nil
+}
+
+func (s *LoadSchedule) validate() error {
+	if len(s.Points) == 0 {
+		return problemf(CodeRequired, "schedule should have at least one point")
+	}
+	for i, p := range s.Points {
+		if p.TimeOfDay < 0 || p.TimeOfDay >= day {
+			return problemf(CodeOutOfRange, "time of the schedule point %d should be in a day", i)
+		}
+		if p.Rate < 0 {
+			return problemf(CodeOutOfRange, "rate of the schedule point %d should be greater than or equal to 0", i)
+		}
+		for _, o := range s.Points[:i] {
+			if o.TimeOfDay == p.TimeOfDay {
+				return problemf(CodeDuplicate, "schedule has more than one point at %s", formatTimeOfDay(p.TimeOfDay))
+			}
+		}
+	}
+	return nil
+}
+
+// RateAt returns the target iterations per second of the schedule at t.
+func (s *LoadSchedule) RateAt(t time.Time) float64 {
+	if len(s.Points) == 0 {
+		return 0
+	}
+	points := s.sorted()
+
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	h, m, sec := t.Clock()
+	offset := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second +
+		time.Duration(t.Nanosecond())
+
+	// Segment between the last point of the day and the first point of the next day
+	prev, next := points[len(points)-1], points[0]
+	next.TimeOfDay += day
+	if offset < points[0].TimeOfDay {
+		offset += day
+	}
+	for i := 1; i < len(points); i++ {
+		if offset < points[i].TimeOfDay {
+			prev, next = points[i-1], points[i]
+			break
+		}
+	}
+
+	span := next.TimeOfDay - prev.TimeOfDay
+	if span <= 0 {
+		return prev.Rate
+	}
+	ratio := float64(offset-prev.TimeOfDay) / float64(span)
+	return prev.Rate + (next.Rate-prev.Rate)*ratio
+}
+
+// MeanRate returns the mean target rate of the schedule between from and to, sampled per second.
+func (s *LoadSchedule) MeanRate(from, to time.Time) float64 {
+	if !to.After(from) {
+		return s.RateAt(from)
+	}
+	var sum float64
+	n := 0
+	for t := from; t.Before(to); t = t.Add(time.Second) {
+		sum += s.RateAt(t)
+		n++
+	}
+	return sum / float64(n)
+}
+
+func (s *LoadSchedule) sorted() []SchedulePoint {
+	if sort.SliceIsSorted(s.Points, func(i, j int) bool { return s.Points[i].TimeOfDay < s.Points[j].TimeOfDay }) {
+		return s.Points
+	}
+	points := append([]SchedulePoint(nil), s.Points...)
+	sort.Slice(points, func(i, j int) bool { return points[i].TimeOfDay < points[j].TimeOfDay })
+	return points
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second))
+}
@@ -0,0 +1,146 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import (
+	"strings"
+	"time"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+const (
+	// Defaults of the sign-command option of a step
+	DefaultSignTimeout   = time.Second
+	DefaultSignProcesses = 1
+
+	// Upper bound of the persistent processes of a sign command
+	MaxSignProcesses = 64
+
+	// Values of the per key of the sign-command option
+	SignPerRequest   = "request"
+	SignPerIteration = "iteration"
+)
+
+// SignCommand is the sign-command option of a step. The command receives the rendered method, url, headers and
+// body of a request as a JSON object on its stdin, and writes a JSON object of the headers to set on its stdout, a
+// null value removes the header. PerIteration runs the command once for the first signed request of an iteration,
+// the later requests of the iteration signed by the same command get its headers. Persistent spawns Processes of
+// the command once and speaks newline-delimited JSON with them, a JSON object per line each way. The command is
+// killed if it doesn't answer in Timeout.
+type SignCommand struct {
+	Command      []string
+	PerIteration bool
+	Timeout      time.Duration
+	Persistent   bool
+	Processes    int
+}
+
+// Key returns the key of the per-iteration signatures of the command, the steps with the same command share them.
+func (s SignCommand) Key() string {
+	return strings.Join(s.Command, "\x00")
+}
+
+// ParseSignCommand parses the sign-command option of a step, either the command line or an object like
+// {"command": ["./sign", "--key", "k1"], "per": "iteration", "timeout": 500, "persistent": true, "processes": 4}
+// with the timeout in milliseconds. A command line is split on the spaces, a list is passed as it is.
+func ParseSignCommand(val interface{}) (SignCommand, error) {
+	s := SignCommand{Timeout: DefaultSignTimeout, Processes: DefaultSignProcesses}
+	if line, isStr := val.(string); isStr {
+		s.Command = strings.Fields(line)
+		return s, s.validate()
+	}
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return s, problemf(CodeInvalidType, "sign-command should be a command line or an object: %v", val)
+	}
+	processesSet := false
+	for k, v := range obj {
+		switch k {
+		case "command":
+			command, err := parseSignCommandLine(v)
+			if err != nil {
+				return s, err
+			}
+			s.Command = command
+		case "per":
+			switch v {
+			case SignPerRequest:
+				s.PerIteration = false
+			case SignPerIteration:
+				s.PerIteration = true
+			default:
+				return s, problemf(CodeUnsupported, "sign-command per should be %s or %s: %v",
+					SignPerRequest, SignPerIteration, v)
+			}
+		case "timeout":
+			n, isNum := util.ToFloat64(v)
+			if !isNum || n <= 0 {
+				return s, problemf(CodeInvalidValue, "sign-command timeout should be a positive number of "+
+					"milliseconds: %v", v)
+			}
+			s.Timeout = time.Duration(n * float64(time.Millisecond))
+		case "persistent":
+			if s.Persistent, ok = v.(bool); !ok {
+				return s, problemf(CodeInvalidType, "sign-command persistent should be a boolean: %v", v)
+			}
+		case "processes":
+			n, isNum := util.ToFloat64(v)
+			if !isNum || n < 1 || n > MaxSignProcesses || n != float64(int(n)) {
+				return s, problemf(CodeOutOfRange, "sign-command processes should be an integer between 1 and %d: %v",
+					MaxSignProcesses, v)
+			}
+			s.Processes, processesSet = int(n), true
+		default:
+			return s, problemf(CodeUnsupported, "unsupported sign-command key: %s", k)
+		}
+	}
+	if processesSet && !s.Persistent {
+		return s, problemf(CodeRequired, "sign-command processes can only be used with persistent")
+	}
+	return s, s.validate()
+}
+
+func parseSignCommandLine(v interface{}) ([]string, error) {
+	if line, isStr := v.(string); isStr {
+		return strings.Fields(line), nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, problemf(CodeInvalidType, "sign-command command should be a command line or a list of its "+
+			"arguments: %v", v)
+	}
+	command := make([]string, 0, len(list))
+	for _, arg := range list {
+		str, isStr := arg.(string)
+		if !isStr {
+			return nil, problemf(CodeInvalidType, "sign-command command arguments should be strings: %v", arg)
+		}
+		command = append(command, str)
+	}
+	return command, nil
+}
+
+func (s SignCommand) validate() error {
+	if len(s.Command) == 0 || s.Command[0] == "" {
+		return problemf(CodeRequired, "sign-command needs a command")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"go.ddosify.com/ddosify/core/util"
+)
+
+const (
+	// Default upper bound of the values collected for each collected capture, the values over it are only counted
+	DefaultCollectLimit = 1000000
+
+	// Upper bound of the collected values sent in each request of a teardown step
+	MaxForeachBatch = 10000
+)
+
+// CollectedCaptures returns the sorted names of the captures of the steps whose values are collected across
+// the iterations for the teardown steps.
+func (s *Scenario) CollectedCaptures() []string {
+	var names []string
+	for _, st := range s.Steps {
+		captures, _ := ParseStepCaptures(st.Custom["capture"])
+		for _, c := range captures {
+			if c.Collect {
+				names = append(names, c.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseForeachBatch parses the count of the collected values sent in each request of a teardown step, 1 if it is
+// not set.
+func ParseForeachBatch(val interface{}) (int, error) {
+	if val == nil {
+		return 1, nil
+	}
+	n, ok := util.ToFloat64(val)
+	if !ok || n < 1 || n > MaxForeachBatch || n != float64(int(n)) {
+		return 0, problemf(CodeOutOfRange, "foreach-batch should be an integer between 1 and %d: %v",
+			MaxForeachBatch, val)
+	}
+	return int(n), nil
+}
+
+func (s *Scenario) validateTeardown() error {
+	if s.CollectLimit < 0 {
+		return problemf(CodeOutOfRange, "collect_limit should be greater than or equal to 0")
+	}
+
+	collected := make(map[string]bool)
+	for _, name := range s.CollectedCaptures() {
+		collected[name] = true
+	}
+
+	stepIds := make(map[uint16]struct{}, len(s.Teardown))
+	for i, st := range s.Teardown {
+		path := fmt.Sprintf("[%d]", i)
+		if err := st.validate(); err != nil {
+			return problemsAt(path, fmt.Sprintf("teardown step %d: ", st.ID), err)
+		}
+		if st.ParallelGroup != "" {
+			return problemf(CodeConflict, "teardown step %d can not have a parallel_group, teardown steps run one "+
+				"by one", st.ID).at(path + ".parallel_group")
+		}
+		if len(st.DependsOn) > 0 {
+			return problemf(CodeConflict, "teardown step %d can not have a depends_on, teardown steps run one by one",
+				st.ID).at(path + ".depends_on")
+		}
+		if st.ConditionalRequests {
+			return problemf(CodeConflict, "teardown step %d can not have conditional_requests, teardown steps don't "+
+				"run by the virtual users", st.ID).at(path + ".conditional_requests")
+		}
+		if st.DuplicateRate > 0 {
+			return problemf(CodeConflict, "teardown step %d can not have a duplicate_rate, teardown steps don't run "+
+				"by the virtual users", st.ID).at(path + ".duplicate_rate")
+		}
+
+		var foreach string
+		if val, ok := st.Custom["foreach"]; ok {
+			name, isStr := val.(string)
+			if !isStr || !collected[name] {
+				return problemf(CodeUnknownReference, "foreach of teardown step %d should be the name of a collected "+
+					"capture: %v", st.ID, val).at(path + ".others.foreach").
+					withFix("capture the value with collect in a step of the scenario")
+			}
+			foreach = name
+		}
+		if val, ok := st.Custom["foreach-batch"]; ok {
+			if foreach == "" {
+				return problemf(CodeRequired, "foreach-batch of teardown step %d can only be used with foreach",
+					st.ID).at(path + ".others.foreach-batch")
+			}
+			if _, err := ParseForeachBatch(val); err != nil {
+				return problemsAt(path+".others.foreach-batch", "", err)
+			}
+		}
+
+		// Teardown steps run once after the test, only the collected value of the foreach is known
+		names, err := st.composedCaptures()
+		if err != nil {
+			return problemsAt(path, "", err)
+		}
+		for _, name := range names {
+			if name != foreach {
+				return problemf(CodeUnknownReference, "teardown step %d can only refer to the value of its foreach: %s",
+					st.ID, name).at(path)
+			}
+		}
+
+		if _, ok := stepIds[st.ID]; ok {
+			return problemf(CodeDuplicate, "duplicate teardown step id: %d", st.ID).at(path + ".id").
+				withFix("give each teardown step a distinct id")
+		}
+		stepIds[st.ID] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	validator "github.com/asaskevich/govalidator"
+	"golang.org/x/net/idna"
+)
+
+// idnaProfile converts the unicode hosts to punycode like the browsers do, the emoji domains are allowed.
+var idnaProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+// templateActionRegex matches the template actions of a URL, the dynamic variables and the captured values.
+var templateActionRegex = regexp.MustCompile(`\{\{[^}]+\}\}`)
+
+// fileActionRegex matches the file injections, which are rendered once when the test starts.
+var fileActionRegex = regexp.MustCompile(`^\{\{\s*file\s`)
+
+// TemplatedHost reports whether the scheme or the host of the url is rendered on each request, so the requests of
+// a step may go to more than one host. A url without a scheme starts with its host, like the ones rendered from a
+// single variable.
+func TemplatedHost(raw string) bool {
+	hostStart := 0
+	if i := strings.Index(raw, "://"); i >= 0 {
+		hostStart = i + len("://")
+	}
+	// Actions are matched before cutting the path, since an action may contain a slash
+	for _, loc := range templateActionRegex.FindAllStringIndex(raw, -1) {
+		if fileActionRegex.MatchString(raw[loc[0]:loc[1]]) {
+			continue
+		}
+		return loc[0] < hostStart || strings.IndexAny(raw[hostStart:loc[0]], "/?#") < 0
+	}
+	return false
+}
+
+// WireURL normalizes the url in place to the form sent on the wire. The unicode host is converted to punycode for
+// the DNS and the SNI. The path and the query keep their valid percent-encodings, like an encoded slash, and only
+// the characters that can't be sent as they are, like the spaces and the unicode characters, are encoded.
+func WireURL(u *url.URL) error {
+	if host := u.Hostname(); !isASCII(host) {
+		ascii, err := idnaProfile.ToASCII(host)
+		if err != nil {
+			return problemf(CodeInvalidValue, "invalid host %s: %v", host, err)
+		}
+		if port := u.Port(); port != "" {
+			ascii = net.JoinHostPort(ascii, port)
+		}
+		u.Host = ascii
+	}
+
+	// Raw path is kept by url.Parse if it differs from the default encoding of the path, it is re-encoded from the
+	// decoded path if it has a character to encode, which would decode its %2F to a slash.
+	if u.RawPath != "" {
+		if p := encodeInvalid(u.RawPath, validPathByte); p != u.RawPath {
+			u.RawPath = p
+		}
+	}
+	u.RawQuery = EncodeQuery(u.RawQuery)
+	return nil
+}
+
+// EncodeQuery percent-encodes the characters of the raw query that can't be sent as they are. The reserved
+// characters and the valid percent-encodings are kept, so an encoded query is not encoded twice.
+func EncodeQuery(q string) string {
+	return encodeInvalid(q, validQueryByte)
+}
+
+// WireURLString returns the url as it is sent on the wire, without the fragment. The template actions are kept as
+// they are. Changed reports whether the url is encoded or its host is converted.
+func WireURLString(raw string) (wire string, changed bool, err error) {
+	// Actions are replaced with the ASCII tokens, which are neither encoded nor converted
+	actions := templateActionRegex.FindAllString(raw, -1)
+	tokenized := raw
+	for i, a := range actions {
+		tokenized = strings.Replace(tokenized, a, fmt.Sprintf("ddosifyaction%d", i), 1)
+	}
+
+	u, err := url.Parse(tokenized)
+	if err != nil {
+		return "", false, err
+	}
+	u.Fragment, u.RawFragment = "", ""
+	host, rawPath, query := u.Host, u.RawPath, u.RawQuery
+	if err = WireURL(u); err != nil {
+		return "", false, err
+	}
+	// Raw path is empty if the path is sent as it is given
+	changed = u.Host != host || (rawPath != "" && u.EscapedPath() != rawPath) || u.RawQuery != query
+	wire = u.String()
+	for i, a := range actions {
+		token := fmt.Sprintf("ddosifyaction%d", i)
+		if !strings.Contains(wire, token) {
+			return "", false, problemf(CodeInvalidValue, "template action %s can't be kept in the url", a)
+		}
+		wire = strings.Replace(wire, token, a, 1)
+	}
+	return wire, changed, nil
+}
+
+// isValidURL validates the target url. The unicode hosts and the characters encoded on the wire are validated by
+// the wire form of the url. The urls of the registered protocols are validated like the http ones.
+func isValidURL(raw string) bool {
+	if i := strings.Index(raw, "://"); i > 0 && isRegisteredProtocol(raw[:i]) {
+		raw = "http" + raw[i:]
+	}
+	if validator.IsURL(strings.ReplaceAll(raw, " ", "_")) {
+		return true
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	wire, _, err := WireURLString(templateActionRegex.ReplaceAllString(raw, "x"))
+	return err == nil && validator.IsURL(wire)
+}
+
+// encodeInvalid percent-encodes the bytes of s that are not valid, and the percent signs that don't start a valid
+// percent-encoding. s is returned as is if it is valid.
+func encodeInvalid(s string, valid func(byte) bool) string {
+	var b *strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		ok := valid(c)
+		if c == '%' {
+			ok = i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2])
+		}
+		if ok {
+			if b != nil {
+				b.WriteByte(c)
+			}
+			continue
+		}
+		if b == nil {
+			b = &strings.Builder{}
+			b.Grow(len(s) + 8)
+			b.WriteString(s[:i])
+		}
+		fmt.Fprintf(b, "%%%02X", c)
+	}
+	if b == nil {
+		return s
+	}
+	return b.String()
+}
+
+// validPathByte reports whether the byte can be in an encoded path as it is, like url.URL.EscapedPath accepts.
+func validPathByte(c byte) bool {
+	if isUnreserved(c) {
+		return true
+	}
+	return strings.IndexByte("!$&'()*+,;=:@[]/%", c) >= 0
+}
+
+// validQueryByte reports whether the byte can be in a query as it is. The printable characters are kept, like the
+// braces of the JSON values in the queries.
+func validQueryByte(c byte) bool {
+	return c > ' ' && c < 0x7f
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
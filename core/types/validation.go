@@ -0,0 +1,164 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Codes of the validation errors, stable for the tools that read the errors
+const (
+	CodeInvalidConfig    = "invalid_config"
+	CodeInvalidSyntax    = "invalid_syntax"
+	CodeInvalidType      = "invalid_type"
+	CodeInvalidValue     = "invalid_value"
+	CodeUnsupported      = "unsupported_value"
+	CodeOutOfRange       = "out_of_range"
+	CodeRequired         = "required"
+	CodeConflict         = "conflict"
+	CodeDuplicate        = "duplicate"
+	CodeUnknownReference = "unknown_reference"
+)
+
+// ValidationError is a problem of the config of a test. Path is the JSON path of the field of the problem in the
+// config, like "steps[1].others.hedge", or empty if the problem is not of a field. The steps are referred by their
+// index in the order they run, which is their order in the config unless depends_on reorders them. Fix is a
+// suggestion to solve the problem, empty if there is none.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors are the problems of the config in the order of the config. A config is validated as a whole,
+// each section is validated even if the previous ones have problems.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, ve := range e {
+		msgs[i] = ve.Message
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err returns the problems as an error, or nil if there is none.
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Add adds the problems of err at the path, nil err is ignored. The problems of a ValidationErrors or a
+// ValidationError keep their code and fix, and their path is relative to the path. Any other error is a problem
+// with CodeInvalidValue.
+func (e *ValidationErrors) Add(path string, err error) {
+	for _, ve := range AsValidationErrors(err) {
+		*e = append(*e, &ValidationError{
+			Path:    joinPath(path, ve.Path),
+			Code:    ve.Code,
+			Message: ve.Message,
+			Fix:     ve.Fix,
+		})
+	}
+}
+
+// AsValidationErrors returns the problems of err, nil if err is nil. The path of an error that is not a
+// ValidationErrors or a ValidationError is empty.
+func AsValidationErrors(err error) ValidationErrors {
+	if err == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ValidationErrors{ve}
+	}
+	return ValidationErrors{{Code: CodeInvalidValue, Message: err.Error()}}
+}
+
+// problemf returns a problem with the code, whose message is formatted like fmt.Sprintf.
+func problemf(code, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// at sets the path of the problem, relative to the path it is added at, and returns the problem.
+func (e *ValidationError) at(path string) *ValidationError {
+	e.Path = joinPath(path, e.Path)
+	return e
+}
+
+// withFix sets the fix of the problem and returns the problem.
+func (e *ValidationError) withFix(fix string) *ValidationError {
+	e.Fix = fix
+	return e
+}
+
+// problemOf returns the error of another package, like a parse error, as a problem with the code. Nil err is nil,
+// the problems of a ValidationErrors or a ValidationError keep their own codes.
+func problemOf(code string, err error) error {
+	var errs ValidationErrors
+	var ve *ValidationError
+	if err == nil || errors.As(err, &errs) || errors.As(err, &ve) {
+		return err
+	}
+	return &ValidationError{Code: code, Message: err.Error()}
+}
+
+// problemsAt returns the problems of err at the path with their messages prefixed, nil if err is nil. The problems
+// keep their codes and fixes.
+func problemsAt(path, prefix string, err error) error {
+	var errs ValidationErrors
+	for _, ve := range AsValidationErrors(err) {
+		errs = append(errs, &ValidationError{
+			Path:    joinPath(path, ve.Path),
+			Code:    ve.Code,
+			Message: prefix + ve.Message,
+			Fix:     ve.Fix,
+		})
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs.Err()
+}
+
+func joinPath(base, path string) string {
+	switch {
+	case base == "":
+		return path
+	case path == "":
+		return base
+	case strings.HasPrefix(path, "["):
+		return base + path
+	}
+	return base + "." + path
+}
@@ -0,0 +1,75 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package types
+
+// VirtualUsers runs a closed model of the load, a fixed count of users each running its iterations one after another
+// until all of them are done. It is used by the vu load type.
+type VirtualUsers struct {
+	// Count of the users running concurrently
+	Users int
+
+	// Iteration count of each user
+	IterationsPerUser int
+}
+
+// IterationCount returns the total iteration count of the users.
+func (v *VirtualUsers) IterationCount() int {
+	return v.Users * v.IterationsPerUser
+}
+
+func (v *VirtualUsers) validate() error {
+	if v.Users <= 0 {
+		return problemf(CodeOutOfRange, "vu users should be greater than 0")
+	}
+	if v.IterationsPerUser <= 0 {
+		return problemf(CodeOutOfRange, "vu iterations per user should be greater than 0")
+	}
+	return nil
+}
+
+// UserIteration is an iteration of a virtual user of the vu load type, the Index-th iteration of the user User of
+// Users users. Both indexes start from 0.
+type UserIteration struct {
+	User  int
+	Users int
+	Index int
+}
+
+// Row returns the row of the iteration in a sequential feed of rows, so the users read their own range of the feed
+// without an overlap. The ranges are contiguous in the order of the users, and the first rows%Users users have a row
+// more if the rows are not divisible by the users. A user starts over at the beginning of its range once it is read,
+// and the users share the rows by the user order if there are less rows than the users.
+func (u UserIteration) Row(rows int) int {
+	if rows <= 0 || u.Users <= 0 {
+		return 0
+	}
+	if rows < u.Users {
+		return u.User % rows
+	}
+
+	size, remainder := rows/u.Users, rows%u.Users
+	start := u.User*size + remainder
+	if u.User < remainder {
+		start = u.User * (size + 1)
+		size++
+	}
+	return start + u.Index%size
+}
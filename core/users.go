@@ -0,0 +1,98 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package core
+
+import (
+	"sync/atomic"
+
+	"go.ddosify.com/ddosify/core/report"
+	"go.ddosify.com/ddosify/core/scenario/requester"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// userRunner runs the virtual users of the vu load type, started on the tick planned by createUsersReqCountArr.
+// Each user runs its iterations one after another, so the test is over once the slowest user is done.
+type userRunner struct {
+	e     *engine
+	users *types.VirtualUsers
+}
+
+func newUserRunner(e *engine, v *types.VirtualUsers) *userRunner {
+	return &userRunner{e: e, users: v}
+}
+
+// start starts the users, the engine waits them on stop.
+func (r *userRunner) start() {
+	for u := 0; u < r.users.Users; u++ {
+		r.e.wg.Add(1)
+		go func(u int) {
+			defer r.e.wg.Done()
+			r.record(r.run(u))
+		}(u)
+	}
+}
+
+// run runs the iterations of a user. The iterations that are not started yet are dropped if the test is stopped.
+func (r *userRunner) run(user int) report.UserSummary {
+	e := r.e
+	s := report.UserSummary{User: user, Iterations: r.users.IterationsPerUser}
+	start := e.clock.Now()
+	for i := 0; i < s.Iterations && e.ctx.Err() == nil; i++ {
+		atomic.AddInt64(&e.startedIterations, 1)
+		atomic.AddInt64(&e.inFlight, 1)
+		e.runIteration(e.clock.Now(), &types.UserIteration{User: user, Users: r.users.Users, Index: i})
+		atomic.AddInt64(&e.completedIterations, 1)
+		atomic.AddInt64(&e.inFlight, -1)
+		s.Completed++
+	}
+	s.Duration = float32(e.clock.Since(start).Seconds())
+	return s
+}
+
+func (r *userRunner) record(s report.UserSummary) {
+	if rs, ok := r.e.reportService.(report.UserAware); ok {
+		rs.RecordUser(s)
+	}
+}
+
+// warnUserTargets warns the sequential targets files of the steps that have less targets than the iterations of the
+// virtual users, their users start over their own targets or share the targets.
+func (e *engine) warnUserTargets() {
+	v := e.hammer.VirtualUsers
+	for _, st := range e.hammer.Scenario.Steps {
+		path, ok := st.Custom["targets-file"].(string)
+		if order, _ := st.Custom["targets-order"].(string); !ok || order == types.TargetsOrderRandom {
+			continue
+		}
+		// Invalid files fail the requesters of the step
+		n, err := requester.CountTargets(path, st.Protocol)
+		if err != nil {
+			continue
+		}
+		if n < v.Users {
+			e.logOut.printf("warning: step %d: targets file has %d targets for %d users, the users share the targets",
+				st.ID, n, v.Users)
+		} else if n < v.IterationCount() {
+			e.logOut.printf("warning: step %d: targets file has %d targets for %d iterations, the users reuse "+
+				"their targets", st.ID, n, v.IterationCount())
+		}
+	}
+}
@@ -35,6 +35,22 @@ func StringInSlice(a string, list []string) bool {
 	return false
 }
 
+// ToFloat64 converts the numeric values in the dynamic fields to float64.
+// Numbers unmarshalled from json are float64 but the fields can be filled with integers programmatically.
+func ToFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
 // IsSystemInTestMode checks if the system is running for tests.
 func IsSystemInTestMode() bool {
 	for _, arg := range os.Args {
@@ -0,0 +1,78 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package util
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// NewSeed returns a seed for the runs without a configured one.
+func NewSeed() int64 {
+	for {
+		if s := time.Now().UnixNano(); s != 0 {
+			return s
+		}
+	}
+}
+
+// SubSeed derives the seed of an independent random stream, like the one of a single step, from the seed of the run.
+// So adding a stream to the run doesn't change the values of the other streams.
+func SubSeed(seed int64, stream string) int64 {
+	h := fnv.New64a()
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(seed))
+	h.Write(b[:])
+	h.Write([]byte(stream))
+	return int64(h.Sum64())
+}
+
+// NewRand returns a random generator that is safe for concurrent use, unlike the ones created by rand.New.
+// Read method of the returned generator must not be used concurrently.
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
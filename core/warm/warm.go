@@ -0,0 +1,203 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package warm
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// DNSTTL is the time the addresses of a host are kept by the state, the host is resolved again after it.
+const DNSTTL = 5 * time.Minute
+
+// sessionCacheSize is the capacity of the TLS sessions kept by the state, shared by all the hosts of the runs.
+const sessionCacheSize = 1024
+
+// State is the connection state carried across the runs of a process, like the runs of a suite: the resolved
+// addresses of the hosts, the TLS sessions and, if the connections are kept, the transports of the steps with their
+// idle connections. The requesters of a run take it from the engine instead of starting with an empty state, so the
+// following runs skip the lookups and the full handshakes. A state is used by one run at a time.
+type State struct {
+	mu sync.Mutex
+
+	keepConnections bool
+	runs            int
+
+	dns        map[string]dnsEntry
+	sessions   tls.ClientSessionCache
+	transports map[string]Transport
+
+	// Resolver of the hosts missing in the state, stubbed by the tests
+	lookup func(ctx context.Context, host string) ([]string, error)
+	now    func() time.Time
+}
+
+// Transport is a transport kept by the state, like an http.Transport of a step with the counters of its connections.
+type Transport interface {
+	CloseIdleConnections()
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// New returns an empty state, the transports of the steps are kept if keepConnections is set.
+func New(keepConnections bool) *State {
+	return &State{
+		keepConnections: keepConnections,
+		dns:             map[string]dnsEntry{},
+		sessions:        tls.NewLRUClientSessionCache(sessionCacheSize),
+		transports:      map[string]Transport{},
+		lookup:          lookupHost,
+		now:             time.Now,
+	}
+}
+
+// Begin marks the start of a run and reports whether the run starts warm, with the state of a previous run since
+// the last Reset.
+func (s *State) Begin() (warm bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs++
+	return s.runs > 1
+}
+
+// Warm reports whether the next run starts warm, without marking its start.
+func (s *State) Warm() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs > 0
+}
+
+// Reset drops the state, the idle connections of the kept transports are closed. The next run starts cold.
+func (s *State) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.transports {
+		t.CloseIdleConnections()
+	}
+	s.runs = 0
+	s.dns = map[string]dnsEntry{}
+	s.sessions = tls.NewLRUClientSessionCache(sessionCacheSize)
+	s.transports = map[string]Transport{}
+}
+
+// KeepsConnections reports whether the transports of the steps are kept across the runs.
+func (s *State) KeepsConnections() bool {
+	return s.keepConnections
+}
+
+// SessionCache returns the TLS session cache of the state, the connections of the next runs resume its sessions.
+func (s *State) SessionCache() tls.ClientSessionCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions
+}
+
+// Transport returns the transport of the key kept by a previous run, or the one built by build, which is kept for the
+// next runs. Transports are only kept if the state keeps the connections, otherwise build is returned as it is.
+func (s *State) Transport(key string, build func() Transport) (t Transport, reused bool) {
+	if !s.keepConnections {
+		return build(), false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.transports[key]; ok {
+		return t, true
+	}
+	t = build()
+	s.transports[key] = t
+	return t, false
+}
+
+// DialContext returns the dial of the resolved addresses of the state, the hosts are resolved once and dialed by the
+// given dial. The addresses are tried in order, the host is resolved again if none of them can be dialed.
+func (s *State) DialContext(
+	dial func(ctx context.Context, network, addr string) (net.Conn, error),
+) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		addrs, err := s.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var conn net.Conn
+		for _, a := range addrs {
+			if conn, err = dial(ctx, network, net.JoinHostPort(a, port)); err == nil {
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		s.forget(host)
+		return nil, err
+	}
+}
+
+// resolve returns the addresses of the host, from the state if they are not expired.
+func (s *State) resolve(ctx context.Context, host string) ([]string, error) {
+	s.mu.Lock()
+	e, ok := s.dns[host]
+	s.mu.Unlock()
+	if ok && s.now().Before(e.expires) {
+		return e.addrs, nil
+	}
+
+	addrs, err := s.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	s.mu.Lock()
+	s.dns[host] = dnsEntry{addrs: addrs, expires: s.now().Add(DNSTTL)}
+	s.mu.Unlock()
+	return addrs, nil
+}
+
+// lookupHost resolves the addresses of the host by the default resolver. The lookup is traced by the httptrace of the
+// ctx, unlike net.Resolver.LookupHost, so the lookups of a cold run are timed like the lookups of the dialer.
+func lookupHost(ctx context.Context, host string) ([]string, error) {
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, ip.String())
+	}
+	return addrs, nil
+}
+
+// forget drops the addresses of the host, so the host is resolved again.
+func (s *State) forget(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.dns, host)
+}
@@ -0,0 +1,164 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package warm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStateBegin(t *testing.T) {
+	t.Parallel()
+
+	s := New(false)
+	var starts []bool
+	for i := 0; i < 2; i++ {
+		starts = append(starts, s.Warm(), s.Begin())
+	}
+	s.Reset()
+	starts = append(starts, s.Warm(), s.Begin())
+	expected := []bool{false, false, true, true, false, false}
+	if !reflect.DeepEqual(starts, expected) {
+		t.Errorf("Expected the starts %v, Found %v", expected, starts)
+	}
+}
+
+func TestStateDialContext(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	s := New(false)
+	s.now = func() time.Time { return now }
+	var lookups []string
+	s.lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups = append(lookups, host)
+		if host == "unknown.test" {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return []string{"10.0.0.1", "10.0.0.2"}, nil
+	}
+	var dials []string
+	down := map[string]bool{}
+	dial := s.DialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials = append(dials, addr)
+		if down[addr] {
+			return nil, errors.New("connection refused")
+		}
+		return &net.TCPConn{}, nil
+	})
+
+	steps := []struct {
+		name    string
+		addr    string
+		down    []string
+		elapsed time.Duration
+		err     bool
+		lookups []string
+		dials   []string
+	}{
+		{"Resolved", "example.test:443", nil, 0, false, []string{"example.test"}, []string{"10.0.0.1:443"}},
+		{"Cached", "example.test:80", nil, 0, false, nil, []string{"10.0.0.1:80"}},
+		{"NextAddress", "example.test:443", []string{"10.0.0.1:443"}, 0, false, nil,
+			[]string{"10.0.0.1:443", "10.0.0.2:443"}},
+		{"IP", "10.0.0.9:443", nil, 0, false, nil, []string{"10.0.0.9:443"}},
+		{"Expired", "example.test:443", nil, DNSTTL, false, []string{"example.test"}, []string{"10.0.0.1:443"}},
+		{"AllDown", "example.test:443", []string{"10.0.0.1:443", "10.0.0.2:443"}, 0, true, nil,
+			[]string{"10.0.0.1:443", "10.0.0.2:443"}},
+		{"ResolvedAfterAllDown", "example.test:443", nil, 0, false, []string{"example.test"},
+			[]string{"10.0.0.1:443"}},
+		{"NotFound", "unknown.test:443", nil, 0, true, []string{"unknown.test"}, nil},
+	}
+	for _, step := range steps {
+		lookups, dials, down = nil, nil, map[string]bool{}
+		for _, a := range step.down {
+			down[a] = true
+		}
+		now = now.Add(step.elapsed)
+		_, err := dial(context.Background(), "tcp", step.addr)
+		if (err != nil) != step.err {
+			t.Errorf("%s: Expected error %v, Found %v", step.name, step.err, err)
+		}
+		if !reflect.DeepEqual(lookups, step.lookups) || !reflect.DeepEqual(dials, step.dials) {
+			t.Errorf("%s: Expected the lookups %v and the dials %v, Found %v, %v", step.name, step.lookups,
+				step.dials, lookups, dials)
+		}
+	}
+
+	s.Reset()
+	lookups = nil
+	dial(context.Background(), "tcp", "example.test:443")
+	if !reflect.DeepEqual(lookups, []string{"example.test"}) {
+		t.Errorf("Hosts should be resolved again after Reset, Found the lookups %v", lookups)
+	}
+}
+
+// idleCounter counts the closes of its idle connections.
+type idleCounter struct {
+	closes int
+}
+
+func (c *idleCounter) CloseIdleConnections() {
+	c.closes++
+}
+
+func TestStateTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		keepConnections bool
+		reused          bool
+	}{
+		{"KeepConnections", true, true},
+		{"NoKeepConnections", false, false},
+	}
+	for _, tc := range tests {
+		test := tc
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := New(test.keepConnections)
+			built := 0
+			build := func() Transport {
+				built++
+				return &idleCounter{}
+			}
+			first, _ := s.Transport("1", build)
+			second, reused := s.Transport("1", build)
+			other, _ := s.Transport("2", build)
+			if reused != test.reused || (first == second) != test.reused || other == first {
+				t.Errorf("Expected reused %v, Found %v", test.reused, reused)
+			}
+
+			s.Reset()
+			closes := first.(*idleCounter).closes + other.(*idleCounter).closes
+			if expected := map[bool]int{true: 2, false: 0}[test.keepConnections]; closes != expected {
+				t.Errorf("Expected %d closes on Reset, Found %d", expected, closes)
+			}
+			if _, reused = s.Transport("1", build); reused {
+				t.Errorf("Transports should be dropped by Reset")
+			}
+		})
+	}
+}
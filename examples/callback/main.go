@@ -0,0 +1,89 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"go.ddosify.com/ddosify/core"
+	"go.ddosify.com/ddosify/core/proxy"
+	"go.ddosify.com/ddosify/core/report"
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// main runs a short test against a local server with the core packages, and passes the step results to a callback
+// besides the report of the output. Run it by go run ./examples/callback.
+func main() {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cart" {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer target.Close()
+
+	// Callback is never called concurrently, the steps of an iteration are passed in order
+	var slowest *types.ScenarioStepResult
+	counts := make(map[uint16]int)
+	output := report.NewCallbackOutput(func(sr *types.ScenarioStepResult) {
+		counts[sr.StepID]++
+		if slowest == nil || sr.Duration > slowest.Duration {
+			// Results are shared with the report service, the kept one is copied
+			slowest = sr.Clone()
+		}
+	})
+
+	h := types.Hammer{
+		IterationCount:    20,
+		LoadType:          types.LoadTypeLinear,
+		TestDuration:      2,
+		Proxy:             proxy.Proxy{Strategy: proxy.ProxyTypeSingle},
+		ReportDestination: report.OutputTypeStdoutJson,
+		ResultListener:    output,
+		Scenario: types.Scenario{
+			Steps: []types.ScenarioStep{
+				{ID: 1, Name: "products", Protocol: types.ProtocolHTTP, Method: http.MethodGet,
+					URL: target.URL + "/products", Timeout: types.DefaultTimeout},
+				{ID: 2, Name: "cart", Protocol: types.ProtocolHTTP, Method: http.MethodPost,
+					URL: target.URL + "/cart", Timeout: types.DefaultTimeout},
+			},
+		},
+	}
+
+	e, err := core.NewEngine(context.Background(), h)
+	if err == nil {
+		err = e.Init()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "err: %v\n", err)
+		os.Exit(1)
+	}
+	e.Start()
+
+	// Engine closes the output once the test ends, the callback has seen all the results when Start returns
+	fmt.Fprintf(os.Stderr, "step results: %v, dropped: %d\n", counts, output.Dropped())
+	if slowest != nil {
+		fmt.Fprintf(os.Stderr, "slowest: step %d of iteration %d, %s\n", slowest.StepID, slowest.IterationID,
+			slowest.Duration)
+	}
+}
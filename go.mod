@@ -3,19 +3,21 @@ module go.ddosify.com/ddosify
 go 1.18
 
 require (
+	github.com/andybalholm/brotli v1.0.5
 	github.com/asaskevich/govalidator v0.0.0-20210307081110-f21760c49a8d
 	github.com/ddosify/go-faker v0.1.1
 	github.com/enescakir/emoji v1.0.0
 	github.com/fatih/color v1.13.0
 	github.com/google/uuid v1.3.0
+	github.com/jaswdr/faker v1.10.2
+	github.com/klauspost/compress v1.15.15
 	github.com/mattn/go-colorable v0.1.12
+	github.com/mattn/go-isatty v0.0.14
 	github.com/valyala/fasttemplate v1.2.1
 	golang.org/x/net v0.0.0-20220425223048-2871e0cb64e4
 )
 
 require (
-	github.com/jaswdr/faker v1.10.2 // indirect
-	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	golang.org/x/sys v0.0.0-20220422013727-9388b58f7150 // indirect
 	golang.org/x/text v0.3.7 // indirect
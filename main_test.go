@@ -22,23 +22,37 @@ package main
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"go.ddosify.com/ddosify/config"
+	"go.ddosify.com/ddosify/core/criteria"
 	"go.ddosify.com/ddosify/core/proxy"
+	"go.ddosify.com/ddosify/core/report"
 	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/warm"
 )
 
 func TestMain(m *testing.M) {
 	// Mock run function to prevent engine starting
-	run = func(h types.Hammer) {}
+	run = func(h types.Hammer) bool { return true }
+	// Tests don't depend on the stdin of the test binary
+	stdinPiped = func() bool { return false }
+	// Tests don't depend on the terminal of the test binary
+	outputIsTerminal = func() bool { return true }
+	stdoutIsTerminal = func() bool { return true }
+	stdinIsTerminal = func() bool { return false }
 	os.Exit(m.Run())
 }
 
@@ -52,9 +66,15 @@ func resetFlags() {
 	*payload = ""
 	*auth = ""
 	headers = header{}
+	labels = label{}
+	abortRules = nil
+	secretLabels = label{}
 
 	*target = ""
+	*targetsFile = ""
+	*targetsOrder = types.TargetsOrderSequential
 	*timeout = types.DefaultTimeout
+	*network = ""
 
 	*proxyFlag = ""
 	*output = types.DefaultOutputType
@@ -63,6 +83,32 @@ func resetFlags() {
 
 	*certPath = ""
 	*certKeyPath = ""
+
+	*preview = 0
+	*verify = 0
+	*seed = 0
+	*connectionScope = types.DefaultConnectionScope
+	*successCriteria = ""
+	*stopAfterFailures = 0
+	*noConfigEcho = false
+	*anonymize = false
+	*maxTransfer = ""
+	*shutdownTimeout = int(types.DefaultShutdownTimeout.Seconds())
+	*resultBufferSize = 0
+	*resultBlockWarning = types.DefaultResultBlockWarning.Seconds()
+	*captureRate = "0"
+	*captureCount = 0
+	*captureFile = types.DefaultCaptureFile
+	*ui = false
+	*uiRefresh = 500
+	*headless = false
+	*progressInterval = int(types.DefaultProgressInterval / time.Second)
+	*progressFormat = types.DefaultProgressFormat
+	*healthcheckAddr = ""
+	*timelineInterval = 60
+	*timelineCSV = ""
+	*format = ""
+	*formatLocale = ""
 }
 
 func TestDefaultFlagValues(t *testing.T) {
@@ -209,6 +255,241 @@ func TestDebugFlagOverridesConfig(t *testing.T) {
 	}
 }
 
+func TestSeedFlagOverridesConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int64
+	}{
+		{"UseConfigSeedWhenNoSeedFlagSpecified", []string{"-config", "config/config_testdata/config_seed.json"}, 1234567},
+		{"SeedFlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_seed.json", "-seed", "42"}, 42},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			// Arrange
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			// Act
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			// Assert
+			if h.Scenario.Seed != test.expected {
+				t.Errorf("Seed Expected %d, Found %d", test.expected, h.Scenario.Seed)
+			}
+		}
+
+		t.Run(test.name, tf)
+	}
+}
+
+func TestApplyUIFlags(t *testing.T) {
+	defer resetFlags()
+
+	h := types.Hammer{ReportDestination: report.OutputTypeStdout}
+	if err := applyUIFlags(&h); err != nil || h.ReportDestination != report.OutputTypeStdout {
+		t.Errorf("Output should not be changed without -ui flag, found %s, err: %v", h.ReportDestination, err)
+	}
+
+	*ui = true
+	*uiRefresh = 200
+	if err := applyUIFlags(&h); err != nil || h.ReportDestination != report.OutputTypeStdoutUI {
+		t.Errorf("Expected %s, Found %s, err: %v", report.OutputTypeStdoutUI, h.ReportDestination, err)
+	}
+
+	h = types.Hammer{ReportDestination: report.OutputTypeStdoutJson}
+	if err := applyUIFlags(&h); err != nil || h.ReportDestination != report.OutputTypeStdoutJson {
+		t.Errorf("Non stdout outputs should not be changed, found %s, err: %v", h.ReportDestination, err)
+	}
+
+	*uiRefresh = 0
+	if err := applyUIFlags(&h); err == nil {
+		t.Errorf("Invalid ui_refresh should be errored")
+	}
+}
+
+func TestApplyTimelineFlags(t *testing.T) {
+	defer resetFlags()
+	defer func() {
+		report.TimelineInterval = time.Minute
+		report.TimelineFile = ""
+	}()
+
+	*timelineInterval = 30
+	*timelineCSV = "timeline.csv"
+	if err := applyTimelineFlags(); err != nil {
+		t.Fatalf("Error occurred %v", err)
+	}
+	if report.TimelineInterval != 30*time.Second || report.TimelineFile != "timeline.csv" {
+		t.Errorf("Unexpected timeline settings %s, %s", report.TimelineInterval, report.TimelineFile)
+	}
+
+	*timelineInterval = 0
+	if err := applyTimelineFlags(); err == nil {
+		t.Errorf("Invalid timeline_interval should be errored")
+	}
+}
+
+func TestApplyFormatFlags(t *testing.T) {
+	defer resetFlags()
+	oldStdoutIsTerminal := stdoutIsTerminal
+	defer func() {
+		stdoutIsTerminal = oldStdoutIsTerminal
+		report.SetFormat(report.FormatRaw, "")
+	}()
+
+	tests := []struct {
+		name      string
+		format    string
+		locale    string
+		terminal  bool
+		shouldErr bool
+	}{
+		{"DefaultTerminal", "", "", true, false},
+		{"DefaultPiped", "", "", false, false},
+		{"Human", report.FormatHuman, "", false, false},
+		{"Raw", report.FormatRaw, "", true, false},
+		{"Locale", report.FormatHuman, "de_DE.UTF-8", true, false},
+		{"InvalidFormat", "pretty", "", true, true},
+		{"InvalidLocale", report.FormatHuman, "xx_XX", true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			*format, *formatLocale = test.format, test.locale
+			stdoutIsTerminal = func() bool { return test.terminal }
+
+			err := applyFormatFlags()
+			if test.shouldErr && err == nil {
+				t.Errorf("Should be errored")
+			}
+			if !test.shouldErr && err != nil {
+				t.Errorf("Error occurred %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyCorrelationsFlag(t *testing.T) {
+	defer func() {
+		*correlations = false
+		report.Correlations = false
+	}()
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		steps     int
+		shouldErr bool
+	}{
+		{"Disabled", false, 1, false},
+		{"TwoSteps", true, 2, false},
+		{"SixSteps", true, 6, false},
+		{"SingleStep", true, 1, true},
+		{"SevenSteps", true, 7, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			*correlations = test.enabled
+			h := types.Hammer{Scenario: types.Scenario{Steps: make([]types.ScenarioStep, test.steps)}}
+
+			err := applyCorrelationsFlag(&h)
+			if test.shouldErr && err == nil {
+				t.Errorf("Should be errored")
+			}
+			if !test.shouldErr && err != nil {
+				t.Errorf("Error occurred %v", err)
+			}
+			if enabled := test.enabled && !test.shouldErr; report.Correlations != enabled {
+				t.Errorf("Expected correlations %v, Found %v", enabled, report.Correlations)
+			}
+		})
+	}
+}
+
+func TestApplyHeadlessFlags(t *testing.T) {
+	defer resetFlags()
+	oldArgs, oldOutputIsTerminal := os.Args, outputIsTerminal
+	defer func() { os.Args, outputIsTerminal = oldArgs, oldOutputIsTerminal }()
+
+	// Terminal output is not changed
+	h := types.Hammer{ReportDestination: report.OutputTypeStdout}
+	if err := applyHeadlessFlags(&h); err != nil || h.ReportDestination != report.OutputTypeStdout {
+		t.Errorf("Output should not be changed on a terminal, found %s, err: %v", h.ReportDestination, err)
+	}
+
+	// Headless is the default without a terminal
+	outputIsTerminal = func() bool { return false }
+	*progressInterval = 5
+	*progressFormat = types.ProgressFormatJSON
+	*healthcheckAddr = ":8080"
+	if err := applyHeadlessFlags(&h); err != nil || h.ReportDestination != report.OutputTypeHeadless {
+		t.Errorf("Expected %s, Found %s, err: %v", report.OutputTypeHeadless, h.ReportDestination, err)
+	}
+	expected := types.HeadlessOptions{
+		ProgressInterval: 5 * time.Second,
+		ProgressFormat:   types.ProgressFormatJSON,
+		HealthcheckAddr:  ":8080",
+	}
+	if !reflect.DeepEqual(h.Headless, expected) {
+		t.Errorf("Expected %#v, Found %#v", expected, h.Headless)
+	}
+
+	// Other outputs and modes are not changed by default
+	for _, h := range []types.Hammer{
+		{ReportDestination: report.OutputTypeStdoutJson},
+		{ReportDestination: report.OutputTypeStdout, Debug: true},
+		{ReportDestination: report.OutputTypeStdout, PreviewCount: 1},
+		{ReportDestination: report.OutputTypeStdout, VerifyCount: 10},
+	} {
+		dest := h.ReportDestination
+		if err := applyHeadlessFlags(&h); err != nil || h.ReportDestination != dest {
+			t.Errorf("Output should not be changed, found %s, err: %v", h.ReportDestination, err)
+		}
+	}
+	*ui = true
+	h = types.Hammer{ReportDestination: report.OutputTypeStdout}
+	if err := applyHeadlessFlags(&h); err != nil || h.ReportDestination != report.OutputTypeStdout {
+		t.Errorf("Output should not be changed with -ui flag, found %s, err: %v", h.ReportDestination, err)
+	}
+	*ui = false
+
+	// -headless=false overrides the default
+	os.Args = []string{"cmd", "-headless=false"}
+	flag.Parse()
+	if err := applyHeadlessFlags(&h); err != nil || h.ReportDestination != report.OutputTypeStdout {
+		t.Errorf("Output should not be changed with -headless=false, found %s, err: %v", h.ReportDestination, err)
+	}
+
+	// -headless works on a terminal too
+	outputIsTerminal = func() bool { return true }
+	*headless = true
+	if err := applyHeadlessFlags(&h); err != nil || h.ReportDestination != report.OutputTypeHeadless {
+		t.Errorf("Expected %s, Found %s, err: %v", report.OutputTypeHeadless, h.ReportDestination, err)
+	}
+
+	h = types.Hammer{ReportDestination: report.OutputTypeStdoutJson}
+	if err := applyHeadlessFlags(&h); err == nil {
+		t.Errorf("Headless mode with a non stdout output should be errored")
+	}
+
+	*ui = true
+	h = types.Hammer{ReportDestination: report.OutputTypeStdout}
+	if err := applyHeadlessFlags(&h); err == nil {
+		t.Errorf("Headless mode with -ui flag should be errored")
+	}
+}
+
 func TestCreateScenario(t *testing.T) {
 	url := "https://test.com"
 	valid := types.Scenario{
@@ -222,6 +503,7 @@ func TestCreateScenario(t *testing.T) {
 				Headers:  map[string]string{},
 			},
 		},
+		ConnectionScope: types.DefaultConnectionScope,
 	}
 	validWithAuth := types.Scenario{
 		Steps: []types.ScenarioStep{
@@ -239,14 +521,41 @@ func TestCreateScenario(t *testing.T) {
 				},
 			},
 		},
+		ConnectionScope: types.DefaultConnectionScope,
+	}
+
+	validWithTargetsFile := types.Scenario{
+		Steps: []types.ScenarioStep{
+			{
+				ID:       1,
+				Protocol: types.DefaultProtocol,
+				Method:   types.DefaultMethod,
+				Timeout:  types.DefaultTimeout,
+				Headers:  map[string]string{},
+				Custom: map[string]interface{}{
+					"targets-file":  "urls.txt",
+					"targets-order": types.TargetsOrderRandom,
+				},
+			},
+		},
+		ConnectionScope: types.DefaultConnectionScope,
 	}
 
+	validWithSeed := valid
+	validWithSeed.Seed = 42
+
+	validWithConnectionScope := valid
+	validWithConnectionScope.ConnectionScope = types.ConnectionScopeUser
+
 	tests := []struct {
 		name      string
 		args      []string
 		shouldErr bool
 		expected  types.Scenario
 	}{
+		{"ValidWithSeed", []string{"-t=https://test.com", "-seed=42"}, false, validWithSeed},
+		{"ValidWithConnectionScope", []string{"-t=https://test.com", "-connection_scope=user"}, false, validWithConnectionScope},
+		{"ValidWithTargetsFile", []string{"-targets_file=urls.txt", "-targets_order=random"}, false, validWithTargetsFile},
 		{"InvalidAuth", []string{"-t=https://test.com", "-a=no_pass_included"}, true, types.Scenario{}},
 		{"InvalidTarget", []string{"-t=asds.x.x.x"}, true, types.Scenario{}},
 		{"Valid", []string{"-t=https://test.com"}, false, valid},
@@ -459,12 +768,36 @@ func TestParseHeaders(t *testing.T) {
 	}
 }
 
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		expected []string
+	}{
+		{"Empty", "", nil},
+		{"Single", "example.com", []string{"example.com"}},
+		{"Multi", "image/, text/css ,,javascript", []string{"image/", "text/css", "javascript"}},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			list := splitList(test.args)
+			if !reflect.DeepEqual(test.expected, list) {
+				t.Errorf("Expected  %#v, Found %#v", test.expected, list)
+			}
+		}
+
+		t.Run(test.name, tf)
+	}
+}
+
 func TestRun(t *testing.T) {
 	// Arrange
 	resetFlags()
 	runCalled := false
-	run = func(h types.Hammer) {
+	run = func(h types.Hammer) bool {
 		runCalled = true
+		return true
 	}
 
 	oldArgs := os.Args
@@ -646,3 +979,911 @@ OLDW6kKz5pI4T8lQJhdqjCU=
 
 	return cert, certKey
 }
+
+type signalRecorderEngine struct {
+	statusCount int
+	abortCount  int
+}
+
+func (e *signalRecorderEngine) Abort() {
+	e.abortCount++
+}
+
+func (e *signalRecorderEngine) WriteStatus(w io.Writer) {
+	e.statusCount++
+}
+
+type statusSignal struct{}
+
+func (statusSignal) String() string { return "status" }
+func (statusSignal) Signal()        {}
+
+func TestHandleSignals(t *testing.T) {
+	e := &signalRecorderEngine{}
+	c := make(chan os.Signal)
+	cancelCount := 0
+	cancel := func() { cancelCount++ }
+
+	returned := make(chan struct{})
+	go func() {
+		handleSignals(c, make(chan struct{}), e, cancel)
+		close(returned)
+	}()
+
+	// Signal channel is unbuffered, so a send returns once the previous signal is handled.
+	c <- os.Interrupt
+	c <- statusSignal{}
+	if cancelCount != 1 || e.abortCount != 0 {
+		t.Errorf("First interrupt should only cancel the test, cancel: %d abort: %d", cancelCount, e.abortCount)
+	}
+
+	c <- os.Interrupt
+	<-returned
+	if e.statusCount != 1 || cancelCount != 1 || e.abortCount != 1 {
+		t.Errorf("Second interrupt should abort the test, status: %d cancel: %d abort: %d",
+			e.statusCount, cancelCount, e.abortCount)
+	}
+}
+
+func TestHandleSignalsDone(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+	handleSignals(make(chan os.Signal), done, &signalRecorderEngine{}, func() {})
+}
+
+func TestIsStopSignal(t *testing.T) {
+	if !isStopSignal(os.Interrupt) {
+		t.Errorf("Interrupt should stop the test")
+	}
+	if isStopSignal(statusSignal{}) {
+		t.Errorf("Status signal should not stop the test")
+	}
+}
+
+func TestCaptureFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected types.Capture
+	}{
+		{"UseConfigCaptureWhenNoCaptureFlagSpecified", []string{"-config", "config/config_testdata/config_capture.json"},
+			types.Capture{Rate: 0.001, Count: 100, Failures: types.DefaultCaptureFailures, File: "captures.ndjson"}},
+		{"CaptureFlagsShouldOverrideConfig", []string{"-config", "config/config_testdata/config_capture.json",
+			"-capture_rate", "10%", "-capture_file", "out.ndjson"},
+			types.Capture{Rate: 0.1, Count: 100, Failures: types.DefaultCaptureFailures, File: "out.ndjson"}},
+		{"CaptureFlags", []string{"-t", "example.com", "-capture_rate", "0.5", "-capture_count", "10"},
+			types.Capture{Rate: 0.5, Count: 10, Failures: types.DefaultCaptureFailures, File: types.DefaultCaptureFile}},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.Scenario.Capture != test.expected {
+				t.Errorf("Capture Expected %#v, Found %#v", test.expected, h.Scenario.Capture)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSuccessCriteriaFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"UseConfigCriteriaWhenNoFlagSpecified", []string{"-config", "config/config_testdata/config_success_criteria.json"},
+			"steps.login.p95 < 300ms && result.fail_rate < 1%"},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_success_criteria.json",
+			"-success_criteria", "result.fail_count == 0"}, "result.fail_count == 0"},
+		{"Flag", []string{"-t", "example.com", "-success_criteria", "steps.1.p99 < 1s"}, "steps.1.p99 < 1s"},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.SuccessCriteria != test.expected {
+				t.Errorf("SuccessCriteria Expected %q, Found %q", test.expected, h.SuccessCriteria)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestStopAfterFailuresFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"UseConfigLimitWhenNoFlagSpecified", []string{"-config", "config/config_testdata/config_stop_after_failures.json"}, 25},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_stop_after_failures.json",
+			"-stop_after_failures", "100"}, 100},
+		{"Flag", []string{"-t", "example.com", "-stop_after_failures", "10"}, 10},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.StopAfterFailures != test.expected {
+				t.Errorf("StopAfterFailures Expected %d, Found %d", test.expected, h.StopAfterFailures)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestNoConfigEchoFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{"UseConfigWhenNoFlagSpecified", []string{"-config", "config/config_testdata/config_no_config_echo.json"}, true},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_no_config_echo.json",
+			"-no_config_echo=false"}, false},
+		{"Flag", []string{"-t", "example.com", "-no_config_echo"}, true},
+		{"Default", []string{"-t", "example.com"}, false},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.NoConfigEcho != test.expected {
+				t.Errorf("NoConfigEcho Expected %v, Found %v", test.expected, h.NoConfigEcho)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestAnonymizeFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{"UseConfigWhenNoFlagSpecified", []string{"-config", "config/config_testdata/config_anonymize.json"}, true},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_anonymize.json",
+			"-anonymize=false"}, false},
+		{"Flag", []string{"-t", "example.com", "-anonymize"}, true},
+		{"Default", []string{"-t", "example.com"}, false},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.Anonymize != test.expected {
+				t.Errorf("Anonymize Expected %v, Found %v", test.expected, h.Anonymize)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestMaxTransferFlag(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		expected  int64
+		shouldErr bool
+	}{
+		{"UseConfigLimitWhenNoFlagSpecified", []string{"-config", "config/config_testdata/config_max_transfer.json"},
+			50e9, false},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_max_transfer.json",
+			"-max_transfer", "1.5GB"}, 1.5e9, false},
+		{"Flag", []string{"-t", "example.com", "-max_transfer", "10MiB"}, 10 << 20, false},
+		{"InvalidFlag", []string{"-t", "example.com", "-max_transfer", "ten"}, 0, true},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.MaxTransfer != test.expected {
+				t.Errorf("MaxTransfer Expected %d, Found %d", test.expected, h.MaxTransfer)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestShutdownTimeoutFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected time.Duration
+	}{
+		{"UseConfigTimeoutWhenNoFlagSpecified", []string{"-config", "config/config_testdata/config_shutdown_timeout.json"},
+			time.Minute},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_shutdown_timeout.json",
+			"-shutdown_timeout", "5"}, 5 * time.Second},
+		{"Flag", []string{"-t", "example.com", "-shutdown_timeout", "10"}, 10 * time.Second},
+		{"Default", []string{"-t", "example.com"}, types.DefaultShutdownTimeout},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.ShutdownTimeout != test.expected {
+				t.Errorf("ShutdownTimeout Expected %v, Found %v", test.expected, h.ShutdownTimeout)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestResultBufferFlags(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		bufferSize   int
+		blockWarning time.Duration
+	}{
+		{"UseConfigWhenNoFlagSpecified", []string{"-config", "config/config_testdata/config_result_buffer.json"},
+			500, 2500 * time.Millisecond},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_result_buffer.json",
+			"-result_buffer_size", "100", "-result_block_warning", "0.5"}, 100, 500 * time.Millisecond},
+		{"Flag", []string{"-t", "example.com", "-result_buffer_size", "10"}, 10, types.DefaultResultBlockWarning},
+		{"Default", []string{"-t", "example.com"}, 0, types.DefaultResultBlockWarning},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.ResultBufferSize != test.bufferSize {
+				t.Errorf("ResultBufferSize Expected %v, Found %v", test.bufferSize, h.ResultBufferSize)
+			}
+			if h.ResultBlockWarning != test.blockWarning {
+				t.Errorf("ResultBlockWarning Expected %v, Found %v", test.blockWarning, h.ResultBlockWarning)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestVerifyFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"Config", []string{"-config", "config/config_testdata/config.json", "-verify", "10"}, 10},
+		{"Flag", []string{"-t", "example.com", "-verify", "5"}, 5},
+		{"Disabled", []string{"-t", "example.com"}, 0},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			if h.VerifyCount != test.expected {
+				t.Errorf("VerifyCount Expected %d, Found %d", test.expected, h.VerifyCount)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestConfirmEstimate(t *testing.T) {
+	oldStdinIsTerminal := stdinIsTerminal
+	defer func() {
+		stdinIsTerminal = oldStdinIsTerminal
+	}()
+
+	tests := []struct {
+		name      string
+		budget    float64
+		confirmed bool
+		terminal  bool
+		answer    string
+		shouldErr bool
+	}{
+		{"UnderBudget", 10, false, false, "", false},
+		{"NoBudget", 0, false, false, "", false},
+		{"Confirmed", 1, true, false, "", false},
+		{"NotTerminal", 1, false, false, "", true},
+		{"TerminalYes", 1, false, true, "y\n", false},
+		{"TerminalNo", 1, false, true, "n\n", true},
+		{"TerminalEmpty", 1, false, true, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useStdin(t, test.answer, false)
+			stdinIsTerminal = func() bool { return test.terminal }
+
+			// 1000 iterations of a step at 0.002 per request cost 2
+			h := types.Hammer{IterationCount: 1000, LoadType: types.LoadTypeLinear, TestDuration: 10,
+				ReportDestination: report.OutputTypeStdout,
+				Scenario: types.Scenario{Steps: []types.ScenarioStep{{ID: 1, Protocol: types.ProtocolHTTPS,
+					Method: types.DefaultMethod, URL: "https://test.com"}}},
+				Cost: &types.Cost{PerRequest: 0.002, Budget: test.budget}}
+
+			err := confirmEstimate(h, test.confirmed)
+			if test.shouldErr && err == nil {
+				t.Errorf("Should be errored")
+			}
+			if !test.shouldErr && err != nil {
+				t.Errorf("Error occurred %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteConfigErr(t *testing.T) {
+	t.Parallel()
+	errs := types.ValidationErrors{
+		{Path: "load_type", Code: types.CodeUnsupported, Message: "unsupported LoadType: x", Fix: "use linear"},
+		{Path: "steps[1].timeout", Code: types.CodeOutOfRange, Message: "timeout should be greater than 0"},
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		format   string
+		expected string
+	}{
+		{"Text", errs, errorFormatText, "err: unsupported LoadType: x\nerr: timeout should be greater than 0\n"},
+		{"Json", errs, errorFormatJson, `{"errors":[{"path":"load_type","code":"unsupported_value",` +
+			`"message":"unsupported LoadType: x","fix":"use linear"},{"path":"steps[1].timeout",` +
+			`"code":"out_of_range","message":"timeout should be greater than 0"}]}` + "\n"},
+		{"JsonPlainError", fmt.Errorf("scenario or target is empty"), errorFormatJson,
+			`{"errors":[{"path":"","code":"invalid_value","message":"scenario or target is empty"}]}` + "\n"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var jsonW, textW strings.Builder
+			writeConfigErr(&jsonW, &textW, test.err, test.format)
+			if found := jsonW.String() + textW.String(); found != test.expected {
+				t.Errorf("Expected %q, Found %q", test.expected, found)
+			}
+			if test.format == errorFormatJson && textW.Len() > 0 {
+				t.Errorf("Json problems should be written to the json writer, Found %q", textW.String())
+			}
+		})
+	}
+}
+
+func useStdin(t *testing.T, input string, piped bool) {
+	oldStdin, oldPiped := stdin, stdinPiped
+	stdin = strings.NewReader(input)
+	stdinPiped = func() bool { return piped }
+	t.Cleanup(func() {
+		stdin, stdinPiped = oldStdin, oldPiped
+		removeStdinFiles()
+	})
+}
+
+func TestStdinConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		piped     bool
+		shouldErr bool
+	}{
+		{"Config", `{"iteration_count": 7, "steps": [{"id": 1, "url": "https://test.com"}]}`, true, false},
+		{"Empty", " \n", true, true},
+		{"Terminal", "", false, true},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			useStdin(t, test.input, test.piped)
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = []string{"cmd", "-config", "-"}
+			flag.Parse()
+			h, err := createHammer()
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+			if h.IterationCount != 7 || h.Scenario.Steps[0].URL != "https://test.com" {
+				t.Errorf("Config should be read from the stdin, Found %#v", h)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestStdinTargets(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		input     string
+		piped     bool
+		shouldErr bool
+	}{
+		{"QuickMode", []string{"-d", "30", "-n", "1000"}, "https://a.com\n# comment\nb.com/x\n", true, false},
+		{"TargetsFileFlag", []string{"-targets_file", "-"}, "https://a.com", true, false},
+		{"Empty", []string{"-targets_file", "-"}, "\n# only comments\n", true, true},
+		{"Terminal", []string{"-targets_file", "-"}, "", false, true},
+		{"NoTargetOnTerminal", []string{"-d", "30"}, "", false, true},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			useStdin(t, test.input, test.piped)
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			path, _ := h.Scenario.Steps[0].Custom["targets-file"].(string)
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Spooled targets file couldn't be read: %v", err)
+			}
+			if string(content) != test.input {
+				t.Errorf("Spooled targets Expected %q, Found %q", test.input, content)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestStdinTargetsOfConfig(t *testing.T) {
+	resetFlags()
+	useStdin(t, "https://a.com\n", true)
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	f, err := ioutil.TempFile(t.TempDir(), "config_*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"steps": [{"id": 1, "others": {"targets-file": "-"}}, {"id": 2, "others": {"targets-file": "-"}}]}`)
+	f.Close()
+
+	os.Args = []string{"cmd", "-config", f.Name()}
+	flag.Parse()
+	h, err := createHammer()
+	if err != nil {
+		t.Fatalf("createHammer return %v", err)
+	}
+
+	for _, st := range h.Scenario.Steps {
+		if path := st.Custom["targets-file"]; path != stdinTargetsFile {
+			t.Errorf("Step %d should feed the stdin targets, Found %v", st.ID, path)
+		}
+	}
+}
+
+func TestMetadataFlags(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		labels       label
+		secretLabels label
+		expected     []types.Label
+		configHash   bool
+		shouldErr    bool
+	}{
+		{"Flags", []string{"-t", "example.com"}, label{"env=staging"}, label{"token=abc"},
+			[]types.Label{{Key: "env", Value: "staging"}, {Key: "token", Value: "abc", Secret: true}}, false, false},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_metadata.json"},
+			label{"env=prod"}, nil, []types.Label{
+				{Key: "api_token", Value: "abc", Secret: true}, {Key: "build", Value: "1024"}, {Key: "env", Value: "prod"},
+			}, true, false},
+		{"InvalidLabel", []string{"-t", "example.com"}, label{"env"}, nil, nil, false, true},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			labels, secretLabels = test.labels, test.secretLabels
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			err = applyMetadataFlags(&h)
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("Invalid label should be errored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyMetadataFlags return %v", err)
+			}
+			if !reflect.DeepEqual(h.Metadata.Labels, test.expected) {
+				t.Errorf("Labels Expected %v, Found %v", test.expected, h.Metadata.Labels)
+			}
+			if h.Metadata.Version != GitVersion {
+				t.Errorf("Version Expected %s, Found %s", GitVersion, h.Metadata.Version)
+			}
+			if hasHash := len(h.Metadata.ConfigHash) == 64; hasHash != test.configHash {
+				t.Errorf("Config hash Expected %v, Found %q", test.configHash, h.Metadata.ConfigHash)
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestNetworkFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected []interface{}
+	}{
+		{"UseConfigNetworkWhenNoFlagSpecified", []string{"-config", "config/config_testdata/config_network.json"},
+			[]interface{}{"slow-3g", map[string]interface{}{"latency": float64(100)}}},
+		{"FlagShouldOverrideConfig", []string{"-config", "config/config_testdata/config_network.json",
+			"-network", "fast-3g"}, []interface{}{"fast-3g", "fast-3g"}},
+		{"Flag", []string{"-t", "example.com", "-network", "slow-3g"}, []interface{}{"slow-3g"}},
+		{"Default", []string{"-t", "example.com"}, []interface{}{nil}},
+	}
+
+	for _, test := range tests {
+		tf := func(t *testing.T) {
+			resetFlags()
+			oldArgs := os.Args
+			defer func() {
+				os.Args = oldArgs
+			}()
+
+			os.Args = append([]string{"cmd"}, test.args...)
+			flag.Parse()
+			h, err := createHammer()
+			if err != nil {
+				t.Fatalf("createHammer return %v", err)
+			}
+
+			for i, s := range h.Scenario.Steps {
+				if !reflect.DeepEqual(s.Custom["network"], test.expected[i]) {
+					t.Errorf("Network of step %d Expected %v, Found %v", s.ID, test.expected[i], s.Custom["network"])
+				}
+			}
+		}
+		t.Run(test.name, tf)
+	}
+}
+
+func TestSuite(t *testing.T) {
+	tests := []struct {
+		name             string
+		args             []string
+		artifactsDirFlag bool
+		expectedPassed   bool
+		expectedStatuses []string
+	}{
+		{"StopOnFailure", nil, false, false, []string{suiteEntryPassed, suiteEntryFailed, suiteEntrySkipped}},
+		{"ContinueOnFailure", []string{"-continue_on_failure"}, false, false,
+			[]string{suiteEntryPassed, suiteEntryFailed, suiteEntryPassed}},
+		{"ArtifactsDirFlag", nil, true, false, []string{suiteEntryPassed, suiteEntryFailed, suiteEntrySkipped}},
+	}
+
+	oldRunSuiteEntry := runSuiteEntry
+	defer func() {
+		runSuiteEntry = oldRunSuiteEntry
+	}()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := `{"duration": 1, "steps": [{"id": 1, "url": "https://test.com"}]}`
+			os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644)
+			suite := `{"name": "release", "artifacts_dir": "results", "entries": [
+				{"name": "smoke test", "config": "config.json"},
+				{"name": "soak", "config": "config.json", "overrides": {"success_criteria": "result.fail_rate < 1%"}},
+				{"name": "spike", "config": "config.json", "pause": 0.01}]}`
+			os.WriteFile(filepath.Join(dir, "suite.json"), []byte(suite), 0644)
+
+			var hammers []types.Hammer
+			runSuiteEntry = func(h types.Hammer) (bool, *criteria.Result, error) {
+				hammers = append(hammers, h)
+				if h.SuccessCriteria != "" {
+					return false, &criteria.Result{Passed: false}, nil
+				}
+				return true, nil, nil
+			}
+
+			args, artifacts := test.args, filepath.Join(dir, "results")
+			if test.artifactsDirFlag {
+				artifacts = filepath.Join(dir, "out")
+				args = append(args, "-artifacts_dir", artifacts)
+			}
+			passed, err := runSuite(append(args, filepath.Join(dir, "suite.json")))
+			if err != nil {
+				t.Fatalf("runSuite return %v", err)
+			}
+			if passed != test.expectedPassed {
+				t.Errorf("Passed Expected %v, Found %v", test.expectedPassed, passed)
+			}
+
+			data, err := os.ReadFile(filepath.Join(artifacts, suiteSummaryFile))
+			if err != nil {
+				t.Fatalf("Suite summary should be written: %v", err)
+			}
+			var summary suiteSummary
+			json.Unmarshal(data, &summary)
+			var statuses []string
+			for _, e := range summary.Entries {
+				statuses = append(statuses, e.Status)
+			}
+			if summary.Name != "release" || summary.Passed != test.expectedPassed ||
+				!reflect.DeepEqual(statuses, test.expectedStatuses) {
+				t.Errorf("Summary Expected statuses %v, Found %s", test.expectedStatuses, data)
+			}
+			if summary.Entries[1].SuccessCriteria == nil {
+				t.Errorf("Success criteria of the failed entry should be in the summary")
+			}
+
+			for i, e := range summary.Entries {
+				folder := filepath.Join(artifacts, fmt.Sprintf("%02d-%s", i+1, strings.ReplaceAll(e.Name, " ", "_")))
+				if e.Artifacts != folder {
+					t.Errorf("Artifacts of entry %d Expected %s, Found %s", i+1, folder, e.Artifacts)
+				}
+				_, err := os.Stat(filepath.Join(folder, suiteResultFile))
+				if e.Status == suiteEntrySkipped && err == nil {
+					t.Errorf("Skipped entry %d should have no artifacts", i+1)
+				}
+				if e.Status != suiteEntrySkipped && err != nil {
+					t.Errorf("Result of entry %d should be written: %v", i+1, err)
+				}
+			}
+
+			if len(hammers) < 2 {
+				t.Fatalf("Expected at least 2 runs, Found %d", len(hammers))
+			}
+			if hammers[0].SuccessCriteria != "" || hammers[1].SuccessCriteria != "result.fail_rate < 1%" {
+				t.Errorf("Overrides should be applied to their entry only")
+			}
+			if hammers[0].Metadata.RunID == "" || hammers[0].Metadata.RunID != summary.Entries[0].RunID {
+				t.Errorf("Run id of the entry should be in the summary, Found %q", summary.Entries[0].RunID)
+			}
+			if report.TimelineFile != "" {
+				t.Errorf("Timeline file should be reset after the suite, Found %s", report.TimelineFile)
+			}
+		})
+	}
+}
+
+func TestSuiteWarmState(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		keepConnections bool
+		expectedStarts  []string
+	}{
+		{"Warm", nil, false, []string{types.StartCold, types.StartWarm, types.StartWarm}},
+		{"Cold", []string{"-cold"}, false, []string{types.StartCold, types.StartCold, types.StartCold}},
+		{"KeepConnections", []string{"-keep_connections"}, true,
+			[]string{types.StartCold, types.StartWarm, types.StartWarm}},
+	}
+
+	oldRunSuiteEntry := runSuiteEntry
+	defer func() {
+		runSuiteEntry = oldRunSuiteEntry
+	}()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"steps": [{"id": 1, "url": "https://test.com"}]}`),
+				0644)
+			suite := `{"entries": [{"config": "config.json"}, {"config": "config.json"}, {"config": "config.json"}]}`
+			os.WriteFile(filepath.Join(dir, "suite.json"), []byte(suite), 0644)
+
+			var states []*warm.State
+			runSuiteEntry = func(h types.Hammer) (bool, *criteria.Result, error) {
+				// Engine marks the start of the run on the state
+				states = append(states, h.WarmState)
+				h.WarmState.Begin()
+				return true, nil, nil
+			}
+			if _, err := runSuite(append(test.args, filepath.Join(dir, "suite.json"))); err != nil {
+				t.Fatalf("runSuite return %v", err)
+			}
+
+			data, _ := os.ReadFile(filepath.Join(dir, config.DefaultSuiteArtifactsDir, suiteSummaryFile))
+			var summary suiteSummary
+			json.Unmarshal(data, &summary)
+			var starts []string
+			for _, e := range summary.Entries {
+				starts = append(starts, e.Start)
+			}
+			if !reflect.DeepEqual(starts, test.expectedStarts) {
+				t.Errorf("Expected the starts %v, Found %s", test.expectedStarts, data)
+			}
+			if len(states) != 3 || states[0] == nil || states[1] != states[0] || states[2] != states[0] {
+				t.Fatalf("Entries should share the warm state, Found %v", states)
+			}
+			if states[0].KeepsConnections() != test.keepConnections {
+				t.Errorf("Keep connections Expected %v, Found %v", test.keepConnections, states[0].KeepsConnections())
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	suite := `{"keep_connections": true, "entries": [{"config": "a.json"}]}`
+	os.WriteFile(filepath.Join(dir, "suite.json"), []byte(suite), 0644)
+	if _, err := runSuite([]string{"-cold", filepath.Join(dir, "suite.json")}); err == nil ||
+		err.Error() != "keep_connections of the suite can not be used with cold" {
+		t.Errorf("Expected the cold error, Found %v", err)
+	}
+}
+
+func TestSuiteInvalidEntry(t *testing.T) {
+	oldRunSuiteEntry := runSuiteEntry
+	defer func() {
+		runSuiteEntry = oldRunSuiteEntry
+	}()
+	runSuiteEntry = func(h types.Hammer) (bool, *criteria.Result, error) {
+		t.Errorf("Invalid entry should not be run")
+		return true, nil, nil
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"steps": [{"id": 1, "url": "https://test.com"}]}`), 0644)
+	suite := `{"entries": [{"config": "config.json", "overrides": {"load_type": "unknown"}}, {"config": "config.json"}]}`
+	os.WriteFile(filepath.Join(dir, "suite.json"), []byte(suite), 0644)
+
+	passed, err := runSuite([]string{filepath.Join(dir, "suite.json")})
+	if err != nil || passed {
+		t.Fatalf("runSuite Expected not passed, Found %v, %v", passed, err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, config.DefaultSuiteArtifactsDir, suiteSummaryFile))
+	var summary suiteSummary
+	json.Unmarshal(data, &summary)
+	if summary.Name != "suite" || len(summary.Entries) != 2 || summary.Entries[0].Status != suiteEntryError ||
+		summary.Entries[0].Error == "" || summary.Entries[1].Status != suiteEntrySkipped {
+		t.Errorf("Invalid entry should stop the suite, Found %s", data)
+	}
+}
+
+func TestSuiteUsage(t *testing.T) {
+	if _, err := runSuite(nil); err == nil || !strings.HasPrefix(err.Error(), "usage: ddosify suite") {
+		t.Errorf("Expected usage error, Found %v", err)
+	}
+}
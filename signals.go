@@ -0,0 +1,45 @@
+//go:build !windows
+
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// stopSignals stop the running test gracefully, the second one aborts it.
+var stopSignals = []os.Signal{os.Interrupt}
+
+// statusSignals dump the live stats of the running test to stderr.
+var statusSignals = []os.Signal{syscall.SIGUSR1}
+
+// notifyStatusSignals relays the status signals to the channel.
+func notifyStatusSignals(c chan<- os.Signal) {
+	signal.Notify(c, statusSignals...)
+}
+
+// stopStatusSignals stops relaying the status signals to the channel, signal.Stop of the channel stops them too.
+func stopStatusSignals(c chan<- os.Signal) {
+	signal.Stop(c)
+}
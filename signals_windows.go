@@ -0,0 +1,94 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package main
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// stopSignals stop the running test gracefully, the second one aborts it.
+// CTRL+C is delivered as os.Interrupt. Closing the console window is delivered as SIGTERM, the final report is
+// printed if the test stops before Windows terminates the process.
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// statusSignals dump the live stats of the running test to stderr.
+// Windows has no user defined signals, CTRL+BREAK of the console dumps the stats instead of stopping the test.
+var statusSignals = []os.Signal{ctrlBreak}
+
+// consoleSignal is a console control event of Windows relayed as a signal.
+type consoleSignal string
+
+func (s consoleSignal) String() string { return string(s) }
+func (consoleSignal) Signal()          {}
+
+const ctrlBreak = consoleSignal("CTRL+BREAK")
+
+// Console control event of CTRL+BREAK, see the HandlerRoutine of the Windows console API
+const ctrlBreakEvent = 1
+
+var (
+	setConsoleCtrlHandler = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleCtrlHandler")
+	consoleHandlerOnce    sync.Once
+
+	// Channel that CTRL+BREAK is relayed to while a test runs, nil otherwise
+	statusMu   sync.Mutex
+	statusChan chan<- os.Signal
+)
+
+// notifyStatusSignals relays CTRL+BREAK to the channel. The console handler is registered once, after the handler
+// of the Go runtime, so it is called first and takes CTRL+BREAK before the runtime delivers it as os.Interrupt.
+func notifyStatusSignals(c chan<- os.Signal) {
+	statusMu.Lock()
+	statusChan = c
+	statusMu.Unlock()
+	consoleHandlerOnce.Do(func() {
+		setConsoleCtrlHandler.Call(syscall.NewCallback(consoleCtrlHandler), 1)
+	})
+}
+
+// stopStatusSignals stops relaying CTRL+BREAK to the channel, it is delivered as os.Interrupt again.
+func stopStatusSignals(c chan<- os.Signal) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	if statusChan == c {
+		statusChan = nil
+	}
+}
+
+// consoleCtrlHandler takes CTRL+BREAK while a test runs, the other events are passed to the handler of the runtime.
+func consoleCtrlHandler(event uintptr) uintptr {
+	if event != ctrlBreakEvent {
+		return 0
+	}
+	statusMu.Lock()
+	c := statusChan
+	statusMu.Unlock()
+	if c == nil {
+		return 0
+	}
+	select {
+	case c <- ctrlBreak:
+	default:
+	}
+	return 1
+}
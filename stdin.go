@@ -0,0 +1,149 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"go.ddosify.com/ddosify/core/types"
+)
+
+// stdinPath is the value of the -config and -targets_file flags that reads them from the stdin.
+const stdinPath = "-"
+
+var (
+	stdin io.Reader = os.Stdin
+
+	// stdinPiped reports whether the stdin is redirected from a pipe or a file instead of a terminal.
+	// Only the stdin is checked, the colors and the signal handling still depend on the stdout and the terminal.
+	stdinPiped = func() bool {
+		fi, err := os.Stdin.Stat()
+		if err != nil {
+			return false
+		}
+		return fi.Mode()&os.ModeNamedPipe != 0 || fi.Mode().IsRegular()
+	}
+
+	// Spooled targets of the stdin, removed by removeStdinFiles
+	stdinTargetsFile string
+)
+
+// readStdinConfig reads the whole config from the stdin for "-config -".
+func readStdinConfig() ([]byte, error) {
+	if !stdinPiped() {
+		return nil, fmt.Errorf("config is expected from the stdin, but nothing is piped into the stdin. " +
+			"Pipe the config like: cat config.json | ddosify -config -")
+	}
+
+	b, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, fmt.Errorf("config is expected from the stdin, but the stdin is empty")
+	}
+	return b, nil
+}
+
+// stdinTargets spools the targets of the stdin into a temporary file and returns its path, so they are fed like
+// a targets file without keeping them in memory. The stdin is read once, the later calls return the same file.
+func stdinTargets() (string, error) {
+	if stdinTargetsFile != "" {
+		return stdinTargetsFile, nil
+	}
+	if !stdinPiped() {
+		return "", fmt.Errorf("targets are expected from the stdin, but nothing is piped into the stdin. " +
+			"Pipe the targets like: cat urls.txt | ddosify")
+	}
+
+	f, err := ioutil.TempFile("", "ddosify_targets_*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	count, err := copyTargets(f, stdin)
+	if err == nil && count == 0 {
+		err = fmt.Errorf("targets are expected from the stdin, but no target is read. " +
+			"Pipe one target URL per line like: cat urls.txt | ddosify")
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	stdinTargetsFile = f.Name()
+	return stdinTargetsFile, nil
+}
+
+// copyTargets copies the lines of r into w and returns the count of the target lines.
+func copyTargets(w io.Writer, r io.Reader) (count int, err error) {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	for {
+		line, rErr := br.ReadString('\n')
+		if l := strings.TrimSpace(line); l != "" && !strings.HasPrefix(l, "#") {
+			count++
+		}
+		if _, err = bw.WriteString(line); err != nil {
+			return
+		}
+
+		if rErr == io.EOF {
+			break
+		} else if rErr != nil {
+			return count, rErr
+		}
+	}
+	return count, bw.Flush()
+}
+
+// useStdinTargets replaces the "-" targets files of the steps with the spooled targets of the stdin.
+func useStdinTargets(steps []types.ScenarioStep) error {
+	for i := range steps {
+		if path, _ := steps[i].Custom["targets-file"].(string); path != stdinPath {
+			continue
+		}
+		if *configPath == stdinPath {
+			return fmt.Errorf("stdin can't be used for both the config and the targets")
+		}
+
+		path, err := stdinTargets()
+		if err != nil {
+			return err
+		}
+		steps[i].Custom["targets-file"] = path
+	}
+	return nil
+}
+
+func removeStdinFiles() {
+	if stdinTargetsFile != "" {
+		os.Remove(stdinTargetsFile)
+		stdinTargetsFile = ""
+	}
+}
@@ -0,0 +1,274 @@
+/*
+*
+*	Ddosify - Load testing tool for any web system.
+*   Copyright (C) 2021  Ddosify (https://ddosify.com)
+*
+*   This program is free software: you can redistribute it and/or modify
+*   it under the terms of the GNU Affero General Public License as published
+*   by the Free Software Foundation, either version 3 of the License, or
+*   (at your option) any later version.
+*
+*   This program is distributed in the hope that it will be useful,
+*   but WITHOUT ANY WARRANTY; without even the implied warranty of
+*   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+*   GNU Affero General Public License for more details.
+*
+*   You should have received a copy of the GNU Affero General Public License
+*   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"go.ddosify.com/ddosify/config"
+	"go.ddosify.com/ddosify/core/criteria"
+	"go.ddosify.com/ddosify/core/report"
+	"go.ddosify.com/ddosify/core/types"
+	"go.ddosify.com/ddosify/core/warm"
+)
+
+const (
+	suiteEntryPassed  = "passed"
+	suiteEntryFailed  = "failed"
+	suiteEntryError   = "error" // The config of the entry is invalid or its engine can not be started
+	suiteEntrySkipped = "skipped"
+)
+
+// Files of the artifacts directory
+const (
+	suiteSummaryFile  = "suite.json"
+	suiteConfigFile   = "config.json"
+	suiteResultFile   = "result.json"
+	suiteTimelineFile = "timeline.csv"
+)
+
+// runSuiteEntry runs the hammer of a suite entry, stubbed by the tests.
+var runSuiteEntry = runEngine
+
+// suiteSummary is the combined summary of the suite written to the artifacts directory.
+type suiteSummary struct {
+	Name    string             `json:"name,omitempty"`
+	Passed  bool               `json:"passed"`
+	Entries []suiteEntryResult `json:"entries"`
+}
+
+// suiteEntryResult is the outcome of an entry of the suite, also written to the artifacts folder of the entry.
+type suiteEntryResult struct {
+	Name            string           `json:"name"`
+	Config          string           `json:"config"`
+	Status          string           `json:"status"`
+	RunID           string           `json:"run_id,omitempty"`
+	Start           string           `json:"start,omitempty"`
+	Duration        float64          `json:"duration"` // In seconds
+	Artifacts       string           `json:"artifacts"`
+	SuccessCriteria *criteria.Result `json:"success_criteria,omitempty"`
+	Error           string           `json:"error,omitempty"`
+}
+
+var artifactNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// runSuite runs the entries of the suite file of the "suite" subcommand back-to-back and returns false if any of
+// them is not passed.
+func runSuite(args []string) (passed bool, err error) {
+	fs := flag.NewFlagSet("suite", flag.ExitOnError)
+	artifactsDir := fs.String("artifacts_dir", "",
+		"Directory of the per-entry artifact folders and the suite summary. Overrides artifacts_dir of the suite file")
+	continueOnFailure := fs.Bool("continue_on_failure", false,
+		"Runs the remaining entries after a failed entry. Overrides continue_on_failure of the suite file")
+	cold := fs.Bool("cold", false,
+		"Resets the resolved hosts and the TLS sessions between the entries. Overrides cold of the suite file")
+	keepConnections := fs.Bool("keep_connections", false,
+		"Keeps the idle connections for the next entries. Overrides keep_connections of the suite file")
+	confirmed := fs.Bool("yes", false, "Runs the entries whose estimated cost is over their budget")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return false, fmt.Errorf(
+			"usage: ddosify suite [-artifacts_dir dir] [-continue_on_failure] [-cold] [-keep_connections] [-yes] " +
+				"<suite.json>")
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	s, err := config.NewSuite(data, filepath.Dir(fs.Arg(0)))
+	if err != nil {
+		return false, err
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(fs.Arg(0)), filepath.Ext(fs.Arg(0)))
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "artifacts_dir":
+			s.ArtifactsDir = *artifactsDir
+		case "continue_on_failure":
+			s.ContinueOnFailure = *continueOnFailure
+		case "cold":
+			s.Cold = *cold
+		case "keep_connections":
+			s.KeepConnections = *keepConnections
+		}
+	})
+	if s.Cold && s.KeepConnections {
+		return false, fmt.Errorf("keep_connections of the suite can not be used with cold")
+	}
+	if err = os.MkdirAll(s.ArtifactsDir, 0755); err != nil {
+		return false, err
+	}
+	if err = applyFormatFlags(); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), stopSignals...)
+	defer cancel()
+
+	// Entries take the connection state of the previous entries unless the suite is cold.
+	ws := warm.New(s.KeepConnections)
+	defer ws.Reset()
+
+	summary := suiteSummary{Name: s.Name, Passed: true}
+	stopped := false
+	for i, e := range s.Entries {
+		r := suiteEntryResult{
+			Name:      e.Name,
+			Config:    e.Path,
+			Status:    suiteEntrySkipped,
+			Artifacts: filepath.Join(s.ArtifactsDir, fmt.Sprintf("%02d-%s", i+1, artifactNameRegexp.ReplaceAllString(e.Name, "_"))),
+		}
+		if !stopped {
+			fmt.Fprintf(os.Stderr, "suite: running %s (%d/%d)\n", e.Name, i+1, len(s.Entries))
+			if err = os.MkdirAll(r.Artifacts, 0755); err != nil {
+				return false, err
+			}
+			if s.Cold {
+				ws.Reset()
+			}
+			runEntry(e, ws, *confirmed, &r)
+			if err = writeJson(filepath.Join(r.Artifacts, suiteResultFile), r); err != nil {
+				return false, err
+			}
+			// The engine stops the run on the interrupt, the remaining entries are skipped as well.
+			if ctx.Err() != nil || (r.Status != suiteEntryPassed && !s.ContinueOnFailure) {
+				stopped = true
+			}
+		}
+		summary.Passed = summary.Passed && r.Status == suiteEntryPassed
+		summary.Entries = append(summary.Entries, r)
+
+		if stopped || i == len(s.Entries)-1 || e.Pause <= 0 {
+			continue
+		}
+		select {
+		case <-time.After(e.Pause):
+		case <-ctx.Done():
+			stopped = true
+		}
+	}
+
+	if err = writeJson(filepath.Join(s.ArtifactsDir, suiteSummaryFile), summary); err != nil {
+		return false, err
+	}
+	printSuiteSummary(os.Stdout, summary)
+	return summary.Passed, nil
+}
+
+// runEntry runs the entry of the suite with its artifacts written into the existing r.Artifacts folder. The run takes
+// the connection state of the previous entries from ws. An entry whose estimated cost is over its budget needs to be
+// confirmed like a test.
+func runEntry(e config.SuiteEntry, ws *warm.State, confirmed bool, r *suiteEntryResult) {
+	start := time.Now()
+	defer func() {
+		r.Duration = time.Since(start).Round(time.Millisecond).Seconds()
+	}()
+
+	var h types.Hammer
+	byteValue, err := e.ReadConfig()
+	if err == nil {
+		err = os.WriteFile(filepath.Join(r.Artifacts, suiteConfigFile), byteValue, 0644)
+	}
+	if err == nil {
+		h, err = hammerFromConfig(byteValue)
+	}
+	if err == nil {
+		h.Metadata.RunID = uuid.NewString()
+		if h.Scenario.Capture.Enabled() {
+			h.Scenario.Capture.File = filepath.Join(r.Artifacts, filepath.Base(h.Scenario.Capture.File))
+		}
+		err = h.Validate()
+	}
+	if err == nil {
+		err = confirmEstimate(h, confirmed)
+	}
+	if err != nil {
+		r.Status, r.Error = suiteEntryError, err.Error()
+		return
+	}
+
+	r.RunID = h.Metadata.RunID
+	h.WarmState = ws
+	r.Start = types.StartCold
+	if ws.Warm() {
+		r.Start = types.StartWarm
+	}
+	report.TimelineFile = filepath.Join(r.Artifacts, suiteTimelineFile)
+	defer func() {
+		report.TimelineFile = ""
+	}()
+
+	passed, c, err := runSuiteEntry(h)
+	r.SuccessCriteria = c
+	switch {
+	case err != nil:
+		r.Status, r.Error = suiteEntryError, err.Error()
+	case passed:
+		r.Status = suiteEntryPassed
+	default:
+		r.Status = suiteEntryFailed
+	}
+}
+
+func writeJson(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func printSuiteSummary(out io.Writer, s suiteSummary) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\nSuite %s\n", s.Name)
+	fmt.Fprintln(w, "ENTRY\tSTATUS\tSTART\tDURATION\tARTIFACTS")
+	for _, e := range s.Entries {
+		status := e.Status
+		if e.Error != "" {
+			status += ": " + e.Error
+		}
+		start := e.Start
+		if start == "" {
+			start = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.3fs\t%s\n", e.Name, status, start, e.Duration, e.Artifacts)
+	}
+	result := "PASSED"
+	if !s.Passed {
+		result = "FAILED"
+	}
+	fmt.Fprintf(w, "Result: %s\n", result)
+	w.Flush()
+}